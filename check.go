@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/rjsadow/sortie/internal/config"
+	"github.com/rjsadow/sortie/internal/k8s"
+	"github.com/rjsadow/sortie/internal/preflight"
+)
+
+// runCheck implements `sortie check`, which validates the configuration in
+// the environment and the services it points at, and prints a report. It
+// returns 1 if the configuration is invalid or any check fails.
+func runCheck(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	skip := fs.String("skip", "", "Comma-separated checks to skip: database, kubernetes, images, jwt-secret, oidc")
+	mockRunner := fs.Bool("mock-runner", false, "Skip the Kubernetes and image checks, as for a server run with --mock-runner")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: sortie check [--skip checks] [--mock-runner]")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Validates the configuration and checks that the database is reachable and")
+		fmt.Fprintln(fs.Output(), "migratable, the Kubernetes credentials have the permissions sessions need,")
+		fmt.Fprintln(fs.Output(), "the sidecar images exist, the JWT secret is strong, and OIDC discovery works.")
+		fmt.Fprintln(fs.Output(), "Migrations are not applied. Exits 1 if anything fails.")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		return 1
+	}
+	k8s.Configure(cfg.Namespace, cfg.Kubeconfig, cfg.VNCSidecarImage)
+	k8s.ConfigureBrowserSidecar(cfg.BrowserSidecarImage)
+	k8s.ConfigureGuacdSidecar(cfg.GuacdSidecarImage)
+
+	opts := preflight.Options{Config: cfg, Images: k8s.SidecarImages(nil)}
+	if *skip != "" {
+		opts.Skip = strings.Split(*skip, ",")
+	}
+	if *mockRunner {
+		opts.Skip = append(opts.Skip, preflight.CheckKubernetes, preflight.CheckImages)
+	}
+
+	results := preflight.Run(context.Background(), opts)
+	preflight.Report(os.Stdout, results)
+	if preflight.Failed(results) {
+		return 1
+	}
+	return 0
+}
+
+// logPreflight logs the results of the startup preflight checks.
+func logPreflight(results []preflight.Result) {
+	for _, r := range results {
+		switch r.Status {
+		case preflight.StatusFail:
+			slog.Error("preflight check failed", "check", r.Name, "detail", r.Detail)
+		case preflight.StatusWarn:
+			slog.Warn("preflight check warning", "check", r.Name, "detail", r.Detail)
+		default:
+			slog.Info("preflight check", "check", r.Name, "status", r.Status, "detail", r.Detail)
+		}
+	}
+}
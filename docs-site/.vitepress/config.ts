@@ -27,6 +27,7 @@ export default defineConfig({
           { text: 'Templates', link: '/guide/templates' },
           { text: 'Sessions', link: '/guide/sessions' },
           { text: 'Session Sharing', link: '/guide/session-sharing' },
+          { text: 'Scheduled Sessions', link: '/guide/scheduled-sessions' },
           { text: 'Session Recording', link: '/guide/recording' },
         ],
       },
@@ -38,9 +39,41 @@ export default defineConfig({
           { text: 'Kubernetes', link: '/admin/kubernetes' },
           { text: 'Reverse Proxy', link: '/admin/reverse-proxy' },
           { text: 'Data Persistence', link: '/admin/data-persistence' },
+          { text: 'Data Residency', link: '/admin/data-residency' },
           { text: 'Session Recording', link: '/admin/recording' },
           { text: 'Disaster Recovery', link: '/admin/disaster-recovery' },
           { text: 'Network Egress', link: '/admin/network-egress' },
+          { text: 'Clipboard Policy', link: '/admin/clipboard-policy' },
+          { text: 'Session Lock', link: '/admin/session-lock' },
+          { text: 'Session DNS', link: '/admin/session-dns' },
+          { text: 'Session Hostnames', link: '/admin/session-hostnames' },
+          { text: 'Session Attestation', link: '/admin/session-attestation' },
+          { text: 'Compliance Reports', link: '/admin/compliance-reports' },
+          { text: 'Settings Approval', link: '/admin/settings-approval' },
+          { text: 'App Schedules', link: '/admin/app-schedules' },
+          { text: 'Warm Pools', link: '/admin/warm-pools' },
+          { text: 'Image Pre-Pull', link: '/admin/image-prepull' },
+          { text: 'Catalog Lint', link: '/admin/catalog-lint' },
+          { text: 'Template Catalogs', link: '/admin/template-catalogs' },
+          { text: 'Home Volumes', link: '/admin/home-volumes' },
+          { text: 'Multi-Container Apps', link: '/admin/app-containers' },
+          { text: 'Projects', link: '/admin/projects' },
+          { text: 'Session Hibernation', link: '/admin/session-hibernation' },
+          { text: 'Capacity Planning', link: '/admin/capacity-planning' },
+          { text: 'App SLOs', link: '/admin/app-slos' },
+          { text: 'API Usage', link: '/admin/api-usage' },
+          { text: 'Cost Reports', link: '/admin/cost-reports' },
+          { text: 'Inactive Accounts', link: '/admin/inactive-accounts' },
+          { text: 'Multi-Factor Auth', link: '/admin/mfa' },
+          { text: 'Passkeys', link: '/admin/passkeys' },
+          { text: 'Password Reset', link: '/admin/password-reset' },
+          { text: 'Forward Auth', link: '/admin/forward-auth' },
+          { text: 'Outbound Requests', link: '/admin/outbound-requests' },
+          { text: 'Air-Gapped Deployments', link: '/admin/air-gapped' },
+          { text: 'Health History', link: '/admin/health-history' },
+          { text: 'Status Page', link: '/admin/status-page' },
+          { text: 'Background Jobs', link: '/admin/background-jobs' },
+          { text: 'Tracing', link: '/admin/tracing' },
         ],
       },
       {
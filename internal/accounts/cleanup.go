@@ -0,0 +1,159 @@
+// Package accounts enforces the inactive-account policy: users who have not
+// logged in for a configurable number of days are disabled, and after a
+// longer period deleted.
+package accounts
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/leader"
+)
+
+// Cleaner periodically disables and deletes inactive user accounts.
+type Cleaner struct {
+	db          *db.DB
+	notifier    Notifier
+	disableDays int
+	deleteDays  int
+	interval    time.Duration
+	stopCh      chan struct{}
+	leader      leader.Checker
+}
+
+// NewCleaner creates a Cleaner that disables accounts idle for more than
+// disableDays and deletes accounts idle for more than deleteDays. Either
+// threshold may be 0 to skip that step; if both are 0 the cleaner does
+// nothing when started. A nil notifier defaults to LogNotifier.
+func NewCleaner(database *db.DB, disableDays, deleteDays int, notifier Notifier) *Cleaner {
+	if notifier == nil {
+		notifier = &LogNotifier{}
+	}
+	return &Cleaner{
+		db:          database,
+		notifier:    notifier,
+		disableDays: disableDays,
+		deleteDays:  deleteDays,
+		interval:    1 * time.Hour,
+		stopCh:      make(chan struct{}),
+	}
+}
+
+// Start launches the cleanup goroutine. It returns immediately.
+func (c *Cleaner) Start() {
+	if c.disableDays <= 0 && c.deleteDays <= 0 {
+		return
+	}
+	go c.loop()
+}
+
+// SetLeader makes the cleaner run only while l reports this replica as
+// leader. Call it before Start.
+func (c *Cleaner) SetLeader(l leader.Checker) {
+	c.leader = l
+}
+
+// Stop signals the cleanup goroutine to exit.
+func (c *Cleaner) Stop() {
+	close(c.stopCh)
+}
+
+func (c *Cleaner) loop() {
+	if leader.IsLeader(c.leader) {
+		c.run()
+	}
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if leader.IsLeader(c.leader) {
+				c.run()
+			}
+		case <-c.stopCh:
+			return
+		}
+	}
+}
+
+func (c *Cleaner) run() {
+	if err := c.Run(context.Background()); err != nil {
+		slog.Warn("Account cleanup failed", "error", err)
+	}
+}
+
+// Run disables and deletes inactive accounts once. It returns an error if
+// the accounts could not be listed or any of them could not be updated.
+func (c *Cleaner) Run(ctx context.Context) error {
+	now := time.Now()
+	failed := 0
+
+	// Delete first so accounts past both thresholds are removed rather than
+	// disabled and then deleted on the next run.
+	if c.deleteDays > 0 {
+		cutoff := now.Add(-time.Duration(c.deleteDays) * 24 * time.Hour)
+		users, err := c.db.ListInactiveUsers(cutoff)
+		if err != nil {
+			return fmt.Errorf("failed to list inactive users: %w", err)
+		}
+		for _, u := range users {
+			if err := c.db.DeleteUser(u.ID); err != nil {
+				slog.Warn("Account cleanup: failed to delete user", "user_id", u.ID, "error", err)
+				failed++
+				continue
+			}
+			c.db.LogAudit("system", "DELETE_INACTIVE_USER",
+				fmt.Sprintf("Deleted inactive user: %s (%s)", u.Username, u.ID))
+			c.notify(ctx, ActionDeleted, u, now)
+		}
+	}
+
+	if c.disableDays > 0 {
+		cutoff := now.Add(-time.Duration(c.disableDays) * 24 * time.Hour)
+		users, err := c.db.ListInactiveUsers(cutoff)
+		if err != nil {
+			return fmt.Errorf("failed to list inactive users: %w", err)
+		}
+		for _, u := range users {
+			if u.Disabled {
+				continue
+			}
+			if err := c.db.SetUserDisabled(u.ID, true); err != nil {
+				slog.Warn("Account cleanup: failed to disable user", "user_id", u.ID, "error", err)
+				failed++
+				continue
+			}
+			c.db.LogAudit("system", "DISABLE_INACTIVE_USER",
+				fmt.Sprintf("Disabled inactive user: %s (%s)", u.Username, u.ID))
+			c.notify(ctx, ActionDisabled, u, now)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to update %d inactive users", failed)
+	}
+	return nil
+}
+
+func (c *Cleaner) notify(ctx context.Context, action Action, u db.User, at time.Time) {
+	event := Event{
+		Action:      action,
+		UserID:      u.ID,
+		Username:    u.Username,
+		Email:       u.Email,
+		TenantID:    u.TenantID,
+		LastLoginAt: u.LastLoginAt,
+		Timestamp:   at,
+	}
+	if err := c.notifier.Notify(ctx, event); err != nil {
+		slog.Warn("Account cleanup: notification failed",
+			"notifier", c.notifier.Name(),
+			"user_id", u.ID,
+			"error", err)
+	}
+}
@@ -0,0 +1,155 @@
+package accounts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/db/dbtest"
+	"github.com/rjsadow/sortie/internal/ssrf/ssrftest"
+)
+
+// recordingNotifier captures events for assertions.
+type recordingNotifier struct {
+	events []Event
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, event Event) error {
+	n.events = append(n.events, event)
+	return nil
+}
+
+func (n *recordingNotifier) Name() string { return "recording" }
+
+func seedUser(t *testing.T, database *db.DB, id string, roles []string, lastLogin time.Time) {
+	t.Helper()
+	if err := database.CreateUser(db.User{ID: id, Username: id, Roles: roles}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if err := database.UpdateUserLastLogin(id, lastLogin); err != nil {
+		t.Fatalf("UpdateUserLastLogin: %v", err)
+	}
+}
+
+func TestCleaner_Run(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+	now := time.Now()
+
+	seedUser(t, database, "recent", []string{"user"}, now.Add(-24*time.Hour))
+	seedUser(t, database, "idle", []string{"user"}, now.Add(-45*24*time.Hour))
+	seedUser(t, database, "gone", []string{"user"}, now.Add(-120*24*time.Hour))
+	seedUser(t, database, "old-admin", []string{"admin"}, now.Add(-365*24*time.Hour))
+
+	notifier := &recordingNotifier{}
+	c := NewCleaner(database, 30, 90, notifier)
+	c.run()
+
+	recent, _ := database.GetUserByID("recent")
+	if recent == nil || recent.Disabled {
+		t.Errorf("recent user should be untouched, got %+v", recent)
+	}
+
+	idle, _ := database.GetUserByID("idle")
+	if idle == nil || !idle.Disabled {
+		t.Errorf("idle user should be disabled, got %+v", idle)
+	}
+
+	gone, _ := database.GetUserByID("gone")
+	if gone != nil {
+		t.Errorf("long-inactive user should be deleted, got %+v", gone)
+	}
+
+	admin, _ := database.GetUserByID("old-admin")
+	if admin == nil || admin.Disabled {
+		t.Errorf("admin should never be disabled or deleted, got %+v", admin)
+	}
+
+	if len(notifier.events) != 2 {
+		t.Fatalf("got %d notifications, want 2", len(notifier.events))
+	}
+	if notifier.events[0].Action != ActionDeleted || notifier.events[0].UserID != "gone" {
+		t.Errorf("first event = %+v, want delete of gone", notifier.events[0])
+	}
+	if notifier.events[1].Action != ActionDisabled || notifier.events[1].UserID != "idle" {
+		t.Errorf("second event = %+v, want disable of idle", notifier.events[1])
+	}
+
+	actions, err := database.GetAuditLogs(10)
+	if err != nil {
+		t.Fatalf("GetAuditLogs: %v", err)
+	}
+	found := map[string]bool{}
+	for _, a := range actions {
+		found[a.Action] = true
+	}
+	if !found["DISABLE_INACTIVE_USER"] || !found["DELETE_INACTIVE_USER"] {
+		t.Errorf("expected disable and delete audit entries, got %+v", actions)
+	}
+
+	// A second run must not re-disable or re-notify already disabled users
+	c.run()
+	if len(notifier.events) != 2 {
+		t.Errorf("got %d notifications after second run, want 2", len(notifier.events))
+	}
+}
+
+func TestCleaner_DisableOnly(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+	seedUser(t, database, "ancient", []string{"user"}, time.Now().Add(-1000*24*time.Hour))
+
+	c := NewCleaner(database, 30, 0, &recordingNotifier{})
+	c.run()
+
+	u, _ := database.GetUserByID("ancient")
+	if u == nil {
+		t.Fatal("user should not be deleted when delete threshold is 0")
+	}
+	if !u.Disabled {
+		t.Error("user should be disabled")
+	}
+}
+
+func TestCleaner_StartStopDisabled(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+
+	c := NewCleaner(database, 0, 0, nil)
+	c.Start() // should be a no-op
+	c.Stop()
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", ct)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	n := NewWebhookNotifier(srv.URL, ssrftest.LoopbackGuard(t), nil)
+	err := n.Notify(context.Background(), Event{Action: ActionDisabled, UserID: "u-1", Username: "alice"})
+	if err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if got.Action != ActionDisabled || got.UserID != "u-1" {
+		t.Errorf("received %+v, want disabled event for u-1", got)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	if err := NewWebhookNotifier(failing.URL, ssrftest.LoopbackGuard(t), nil).Notify(context.Background(), Event{}); err == nil {
+		t.Error("expected error for non-2xx response")
+	}
+}
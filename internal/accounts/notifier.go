@@ -0,0 +1,60 @@
+package accounts
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/notify"
+	"github.com/rjsadow/sortie/internal/ssrf"
+)
+
+// Action identifies what the inactive-account policy did to a user.
+type Action string
+
+const (
+	// ActionDisabled means the account was disabled for inactivity.
+	ActionDisabled Action = "disabled"
+	// ActionDeleted means the account was deleted for inactivity.
+	ActionDeleted Action = "deleted"
+)
+
+// Event describes a single policy action taken against a user account.
+type Event struct {
+	Action      Action     `json:"action"`
+	UserID      string     `json:"user_id"`
+	Username    string     `json:"username"`
+	Email       string     `json:"email,omitempty"`
+	TenantID    string     `json:"tenant_id,omitempty"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	Timestamp   time.Time  `json:"timestamp"`
+}
+
+// Notifier delivers inactive-account events to an external destination. Notify errors are logged
+// and do not stop the cleanup run.
+type Notifier = notify.Notifier[Event]
+
+// LogNotifier writes events to the application log.
+type LogNotifier struct{}
+
+// Notify logs the event.
+func (n *LogNotifier) Notify(_ context.Context, event Event) error {
+	slog.Info("Inactive account policy applied",
+		"action", event.Action,
+		"user_id", event.UserID,
+		"username", event.Username)
+	return nil
+}
+
+// Name returns "log".
+func (n *LogNotifier) Name() string { return "log" }
+
+// NewWebhookNotifier returns a notifier that POSTs each event as JSON to
+// endpoint through the outbound guard. An event for a tenant may only be
+// sent where that tenant's outbound allowlist, read with allowlist, permits.
+func NewWebhookNotifier(endpoint string, guard *ssrf.Guard, allowlist notify.AllowlistFunc) *notify.Webhook[Event] {
+	n := notify.NewWebhook[Event](endpoint, guard)
+	n.Tenant = func(event Event) string { return event.TenantID }
+	n.Allowlist = allowlist
+	return n
+}
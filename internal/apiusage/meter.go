@@ -0,0 +1,220 @@
+// Package apiusage meters API requests per principal: the user who made
+// them and the API token they authenticated with, if any. Counts are kept
+// per endpoint and hour, reported to admins, and checked against an hourly
+// threshold that alerts operators to principals calling too much.
+package apiusage
+
+import (
+	"bufio"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/middleware"
+	"github.com/rjsadow/sortie/internal/plugins/auth"
+)
+
+// flushInterval is how often a meter writes its counts to the database.
+const flushInterval = time.Minute
+
+// usageKey identifies one usage row.
+type usageKey struct {
+	bucket   time.Time
+	userID   string
+	tokenID  string
+	method   string
+	endpoint string
+}
+
+// Meter counts authenticated API requests in memory and adds the counts to
+// the database every minute. Every replica runs one; their counts add up.
+type Meter struct {
+	db       *db.DB
+	interval time.Duration
+	stopCh   chan struct{}
+	done     chan struct{}
+
+	mu      sync.Mutex
+	pending map[usageKey]*db.APIUsage
+}
+
+// NewMeter creates a Meter that stores counts in database. Call Start to
+// begin writing them.
+func NewMeter(database *db.DB) *Meter {
+	return &Meter{
+		db:       database,
+		interval: flushInterval,
+		stopCh:   make(chan struct{}),
+		done:     make(chan struct{}),
+		pending:  make(map[usageKey]*db.APIUsage),
+	}
+}
+
+// Start writes counts to the database every minute until Stop is called.
+func (m *Meter) Start() {
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stopCh:
+				m.flushOrLog()
+				return
+			case <-ticker.C:
+				m.flushOrLog()
+			}
+		}
+	}()
+}
+
+// Stop writes the remaining counts and stops the meter.
+func (m *Meter) Stop() {
+	close(m.stopCh)
+	<-m.done
+}
+
+// Record counts one request by a user, with the API token tokenID unless
+// it is empty. Responses with a 5xx status are counted as errors.
+func (m *Meter) Record(userID, tokenID, method, endpoint string, status int, at time.Time) {
+	k := usageKey{
+		bucket:   at.UTC().Truncate(time.Hour),
+		userID:   userID,
+		tokenID:  tokenID,
+		method:   method,
+		endpoint: endpoint,
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.pending[k]
+	if !ok {
+		u = &db.APIUsage{BucketStart: k.bucket, UserID: userID, TokenID: tokenID, Method: method, Endpoint: endpoint}
+		m.pending[k] = u
+	}
+	u.Requests++
+	if status >= http.StatusInternalServerError {
+		u.Errors++
+	}
+}
+
+// Flush adds the counts recorded since the last flush to the database.
+// Counts that fail to be written are kept for the next flush.
+func (m *Meter) Flush() error {
+	m.mu.Lock()
+	pending := m.pending
+	m.pending = make(map[usageKey]*db.APIUsage)
+	m.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	rows := make([]db.APIUsage, 0, len(pending))
+	for _, u := range pending {
+		rows = append(rows, *u)
+	}
+	if err := m.db.AddAPIUsage(rows); err != nil {
+		m.mu.Lock()
+		for k, u := range pending {
+			if cur, ok := m.pending[k]; ok {
+				cur.Requests += u.Requests
+				cur.Errors += u.Errors
+			} else {
+				m.pending[k] = u
+			}
+		}
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (m *Meter) flushOrLog() {
+	if err := m.Flush(); err != nil {
+		slog.Warn("API usage: failed to store counts", "error", err)
+	}
+}
+
+// Middleware counts the requests next serves for the authenticated user
+// in the request context. It must run after authentication. A nil Meter
+// counts nothing.
+func (m *Meter) Middleware(next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := middleware.GetUserFromContext(r.Context())
+		if user == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		sw := &statusWriter{ResponseWriter: w}
+		start := time.Now()
+		next.ServeHTTP(sw, r)
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		m.Record(user.ID, user.Metadata[auth.MetadataAPITokenID], r.Method, Endpoint(r.URL.Path), status, start)
+	})
+}
+
+// Endpoint returns path with the segments that name one resource, such as
+// a session ID, replaced by {id}, so requests for resources of the same
+// kind are counted together. A segment is taken to be an ID if it has a
+// digit in it or is longer than 32 characters; others, such as app IDs
+// chosen by admins, are kept.
+func Endpoint(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, s := range segments {
+		if len(s) > 32 || strings.IndexFunc(s, unicode.IsDigit) >= 0 {
+			segments[i] = "{id}"
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// statusWriter records the status code of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush supports streaming responses such as recording playback.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack supports WebSocket and raw TCP proxying.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return hj.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
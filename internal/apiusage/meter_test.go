@@ -0,0 +1,101 @@
+package apiusage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/db/dbtest"
+	"github.com/rjsadow/sortie/internal/middleware"
+	"github.com/rjsadow/sortie/internal/plugins"
+	"github.com/rjsadow/sortie/internal/plugins/auth"
+)
+
+func TestEndpoint(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/api/sessions", "/api/sessions"},
+		{"/api/sessions/", "/api/sessions"},
+		{"/api/sessions/3f2b9c1e-7d4a-4e8b-9c1d-2a3b4c5d6e7f/stop", "/api/sessions/{id}/stop"},
+		{"/api/apps/firefox", "/api/apps/firefox"},
+		{"/api/admin/users/user-1700000000", "/api/admin/users/{id}"},
+		{"/", "/"},
+	}
+	for _, tt := range tests {
+		if got := Endpoint(tt.path); got != tt.want {
+			t.Errorf("Endpoint(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMeter_Middleware(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+	meter := NewMeter(database)
+
+	handler := meter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	serve := func(path string, user *plugins.User) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if user != nil {
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, user))
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	alice := &plugins.User{ID: "alice"}
+	bot := &plugins.User{ID: "alice", Metadata: map[string]string{auth.MetadataAPITokenID: "tok-1"}}
+	serve("/api/apps", alice)
+	serve("/api/apps", alice)
+	serve("/api/fail", bot)
+	serve("/api/apps", nil) // Unauthenticated requests are not counted
+
+	if err := meter.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	serve("/api/apps", alice)
+	meter.Start()
+	meter.Stop()
+
+	filter := db.APIUsageFilter{From: time.Now().Add(-2 * time.Hour), To: time.Now().Add(time.Hour)}
+	principals, err := database.TopAPIPrincipals(filter, 10)
+	if err != nil {
+		t.Fatalf("TopAPIPrincipals() error = %v", err)
+	}
+	if len(principals) != 2 {
+		t.Fatalf("got %d principals, want 2: %+v", len(principals), principals)
+	}
+	if p := principals[0]; p.UserID != "alice" || p.TokenID != "" || p.Requests != 3 || p.Errors != 0 {
+		t.Errorf("principals[0] = %+v, want alice without a token, 3 requests", p)
+	}
+	if p := principals[1]; p.TokenID != "tok-1" || p.Requests != 1 || p.Errors != 1 {
+		t.Errorf("principals[1] = %+v, want tok-1 with 1 failed request", p)
+	}
+
+	endpoints, err := database.TopAPIEndpoints(filter, 10)
+	if err != nil {
+		t.Fatalf("TopAPIEndpoints() error = %v", err)
+	}
+	if len(endpoints) != 2 || endpoints[0].Endpoint != "/api/apps" || endpoints[0].Method != http.MethodGet {
+		t.Errorf("endpoints = %+v, want /api/apps first", endpoints)
+	}
+}
+
+func TestMeter_NilMiddleware(t *testing.T) {
+	var meter *Meter
+	called := false
+	handler := meter.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true }))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !called {
+		t.Error("nil meter did not call the next handler")
+	}
+}
@@ -0,0 +1,103 @@
+package apiusage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// DefaultRetention is how long API usage is kept.
+const DefaultRetention = 90 * 24 * time.Hour
+
+// maxPrincipals bounds how many principals are checked each run. Only the
+// busiest can be over the threshold, so this is plenty.
+const maxPrincipals = 1000
+
+// Monitor alerts operators when a principal makes more API requests in
+// the current hour than the threshold allows, once per principal and
+// hour, and deletes usage older than the retention. Alerts sent are
+// remembered in memory, so a new leader may announce one again.
+type Monitor struct {
+	db        *db.DB
+	threshold int64
+	retention time.Duration
+	notifiers []Notifier
+	alerted   map[string]time.Time // Hour alerted in, by user and token ID
+	now       func() time.Time
+}
+
+// NewMonitor creates a Monitor that sends alerts to notifiers when a
+// principal makes more than threshold requests in an hour. A threshold of
+// zero turns alerts off, and a retention of zero keeps usage forever. With
+// no notifiers, alerts are logged.
+func NewMonitor(database *db.DB, threshold int64, retention time.Duration, notifiers ...Notifier) *Monitor {
+	if len(notifiers) == 0 {
+		notifiers = []Notifier{&LogNotifier{}}
+	}
+	return &Monitor{
+		db:        database,
+		threshold: threshold,
+		retention: retention,
+		notifiers: notifiers,
+		alerted:   make(map[string]time.Time),
+		now:       time.Now,
+	}
+}
+
+// Run checks the current hour's usage against the threshold and deletes
+// expired usage. It is run as a background job.
+func (m *Monitor) Run(ctx context.Context) error {
+	now := m.now().UTC()
+	if m.retention > 0 {
+		if _, err := m.db.DeleteAPIUsageBefore(now.Add(-m.retention)); err != nil {
+			return fmt.Errorf("failed to delete expired API usage: %w", err)
+		}
+	}
+	if m.threshold <= 0 {
+		return nil
+	}
+
+	hour := now.Truncate(time.Hour)
+	for key, at := range m.alerted {
+		if at.Before(hour) {
+			delete(m.alerted, key)
+		}
+	}
+
+	principals, err := m.db.TopAPIPrincipals(db.APIUsageFilter{From: hour, To: hour.Add(time.Hour)}, maxPrincipals)
+	if err != nil {
+		return err
+	}
+	for _, p := range principals {
+		if p.Requests <= m.threshold {
+			break
+		}
+		key := p.UserID + "/" + p.TokenID
+		if _, ok := m.alerted[key]; ok {
+			continue
+		}
+		m.alerted[key] = hour
+		m.notify(ctx, Alert{
+			UserID:      p.UserID,
+			Username:    p.Username,
+			TokenID:     p.TokenID,
+			TokenName:   p.TokenName,
+			Requests:    p.Requests,
+			Threshold:   m.threshold,
+			WindowStart: hour,
+			Timestamp:   now,
+		})
+	}
+	return nil
+}
+
+func (m *Monitor) notify(ctx context.Context, alert Alert) {
+	for _, n := range m.notifiers {
+		if err := n.Notify(ctx, alert); err != nil {
+			slog.Warn("API usage monitor: failed to send alert", "notifier", n.Name(), "user_id", alert.UserID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package apiusage
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/db/dbtest"
+	"github.com/rjsadow/sortie/internal/ssrf/ssrftest"
+)
+
+// recordingNotifier captures alerts for assertions.
+type recordingNotifier struct {
+	alerts []Alert
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, alert Alert) error {
+	n.alerts = append(n.alerts, alert)
+	return nil
+}
+
+func (n *recordingNotifier) Name() string { return "recording" }
+
+func TestMonitor_Run(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+	now := time.Date(2026, 3, 1, 10, 30, 0, 0, time.UTC)
+	hour := now.Truncate(time.Hour)
+	rows := []db.APIUsage{
+		{BucketStart: hour, UserID: "alice", TokenID: "tok-1", Method: "GET", Endpoint: "/api/apps", Requests: 80},
+		{BucketStart: hour, UserID: "alice", TokenID: "tok-1", Method: "POST", Endpoint: "/api/sessions", Requests: 30},
+		{BucketStart: hour, UserID: "bob", Method: "GET", Endpoint: "/api/apps", Requests: 50},
+		{BucketStart: hour.Add(-time.Hour), UserID: "bob", Method: "GET", Endpoint: "/api/apps", Requests: 500},
+		{BucketStart: hour.Add(-100 * 24 * time.Hour), UserID: "bob", Method: "GET", Endpoint: "/api/apps", Requests: 1},
+	}
+	if err := database.AddAPIUsage(rows); err != nil {
+		t.Fatalf("AddAPIUsage() error = %v", err)
+	}
+
+	notifier := &recordingNotifier{}
+	monitor := NewMonitor(database, 100, DefaultRetention, notifier)
+	monitor.now = func() time.Time { return now }
+	ctx := context.Background()
+	if err := monitor.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(notifier.alerts) != 1 {
+		t.Fatalf("got %d alerts, want 1: %+v", len(notifier.alerts), notifier.alerts)
+	}
+	if a := notifier.alerts[0]; a.UserID != "alice" || a.TokenID != "tok-1" || a.Requests != 110 || a.Threshold != 100 || !a.WindowStart.Equal(hour) {
+		t.Errorf("alert = %+v", a)
+	}
+
+	// Expired usage is deleted
+	old, err := database.ListAPIUsageBuckets(db.APIUsageFilter{From: time.Time{}, To: hour.Add(-DefaultRetention)})
+	if err != nil {
+		t.Fatalf("ListAPIUsageBuckets() error = %v", err)
+	}
+	if len(old) != 0 {
+		t.Errorf("expired buckets = %+v, want none", old)
+	}
+
+	// The same principal is not alerted again in the same hour
+	if err := database.AddAPIUsage([]db.APIUsage{{BucketStart: hour, UserID: "bob", Method: "GET", Endpoint: "/api/apps", Requests: 60}}); err != nil {
+		t.Fatalf("AddAPIUsage() error = %v", err)
+	}
+	if err := monitor.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(notifier.alerts) != 2 || notifier.alerts[1].UserID != "bob" {
+		t.Fatalf("alerts = %+v, want a second alert for bob", notifier.alerts)
+	}
+
+	// A new hour starts afresh
+	monitor.now = func() time.Time { return now.Add(time.Hour) }
+	if err := database.AddAPIUsage([]db.APIUsage{{BucketStart: hour.Add(time.Hour), UserID: "alice", TokenID: "tok-1", Method: "GET", Endpoint: "/api/apps", Requests: 101}}); err != nil {
+		t.Fatalf("AddAPIUsage() error = %v", err)
+	}
+	if err := monitor.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(notifier.alerts) != 3 || notifier.alerts[2].UserID != "alice" {
+		t.Errorf("alerts = %+v, want alice alerted again in the next hour", notifier.alerts)
+	}
+}
+
+func TestMonitor_ThresholdOff(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+	hour := time.Now().UTC().Truncate(time.Hour)
+	if err := database.AddAPIUsage([]db.APIUsage{{BucketStart: hour, UserID: "alice", Method: "GET", Endpoint: "/api/apps", Requests: 1000}}); err != nil {
+		t.Fatalf("AddAPIUsage() error = %v", err)
+	}
+	notifier := &recordingNotifier{}
+	if err := NewMonitor(database, 0, 0, notifier).Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(notifier.alerts) != 0 {
+		t.Errorf("got %d alerts with threshold 0, want none", len(notifier.alerts))
+	}
+}
+
+func TestNotifiers(t *testing.T) {
+	var got Alert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	alert := Alert{UserID: "alice", TokenID: "tok-1", Requests: 200, Threshold: 100, Timestamp: time.Now()}
+	if err := NewWebhookNotifier(srv.URL, ssrftest.LoopbackGuard(t)).Notify(context.Background(), alert); err != nil {
+		t.Fatalf("WebhookNotifier.Notify() error = %v", err)
+	}
+	if got.UserID != "alice" || got.Requests != 200 {
+		t.Errorf("webhook received %+v", got)
+	}
+
+	pub := &fakePublisher{}
+	NewAdminNotifier(pub).Notify(context.Background(), alert)
+	if pub.role != "admin" || pub.event != "api_usage" {
+		t.Errorf("published %q to %q, want api_usage to admin", pub.event, pub.role)
+	}
+}
+
+type fakePublisher struct {
+	role, event string
+}
+
+func (p *fakePublisher) PublishToRole(role, event string, _ any) {
+	p.role, p.event = role, event
+}
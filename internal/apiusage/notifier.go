@@ -0,0 +1,57 @@
+package apiusage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/notify"
+	"github.com/rjsadow/sortie/internal/ssrf"
+)
+
+// Alert reports a principal that made more API requests in an hour than
+// the threshold allows.
+type Alert struct {
+	UserID      string    `json:"user_id"`
+	Username    string    `json:"username,omitempty"`
+	TokenID     string    `json:"token_id,omitempty"`
+	TokenName   string    `json:"token_name,omitempty"`
+	Requests    int64     `json:"requests"`
+	Threshold   int64     `json:"threshold"`
+	WindowStart time.Time `json:"window_start"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// Notifier delivers over-use alerts to operators. Notify errors are logged
+// and do not stop the monitor.
+type Notifier = notify.Notifier[Alert]
+
+// LogNotifier writes alerts to the application log as warnings.
+type LogNotifier struct{}
+
+// Notify logs the alert.
+func (n *LogNotifier) Notify(_ context.Context, alert Alert) error {
+	slog.Warn("API usage over threshold",
+		"user_id", alert.UserID,
+		"username", alert.Username,
+		"token_id", alert.TokenID,
+		"requests", alert.Requests,
+		"threshold", alert.Threshold,
+		"window_start", alert.WindowStart)
+	return nil
+}
+
+// Name returns "log".
+func (n *LogNotifier) Name() string { return "log" }
+
+// NewWebhookNotifier returns a notifier that POSTs each alert as JSON to
+// endpoint through the outbound guard.
+func NewWebhookNotifier(endpoint string, guard *ssrf.Guard) *notify.Webhook[Alert] {
+	return notify.NewWebhook[Alert](endpoint, guard)
+}
+
+// NewAdminNotifier returns a notifier that pushes each alert to connected
+// admins as an "api_usage" server-sent event.
+func NewAdminNotifier(publisher notify.RolePublisher) *notify.Admin[Alert] {
+	return &notify.Admin[Alert]{Publisher: publisher, Event: "api_usage"}
+}
@@ -0,0 +1,245 @@
+// Package attestation produces signed reports describing the environment a
+// session ran in: the images and digests actually run, their resource
+// limits, the network policy applied to the pod, who used it and when.
+//
+// Reports are signed with Ed25519 and wrapped in a DSSE envelope
+// (https://github.com/secure-systems-lab/dsse), so auditors can verify them
+// with the server's public key and standard tooling.
+package attestation
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// PayloadType identifies attestation reports inside a DSSE envelope.
+const PayloadType = "application/vnd.sortie.session-attestation+json"
+
+// ReportVersion is the version of the Report format.
+const ReportVersion = 1
+
+// Report is the attested description of a single session run. A session
+// that is stopped and restarted produces one report per run.
+type Report struct {
+	Version          int            `json:"version"`
+	SessionID        string         `json:"session_id"`
+	TenantID         string         `json:"tenant_id,omitempty"`
+	App              App            `json:"app"`
+	User             User           `json:"user"`
+	SessionCreatedAt time.Time      `json:"session_created_at"`
+	StartedAt        time.Time      `json:"started_at"`
+	EndedAt          time.Time      `json:"ended_at"`
+	FinalStatus      string         `json:"final_status"`
+	Reason           string         `json:"reason,omitempty"`
+	Workload         string         `json:"workload"`
+	Containers       []Container    `json:"containers"`
+	NetworkPolicy    *NetworkPolicy `json:"network_policy,omitempty"`
+	GeneratedAt      time.Time      `json:"generated_at"`
+}
+
+// App identifies the application a session ran.
+type App struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	LaunchType string `json:"launch_type"`
+	OsType     string `json:"os_type,omitempty"`
+}
+
+// User identifies the session owner.
+type User struct {
+	ID           string `json:"id"`
+	Username     string `json:"username,omitempty"`
+	Email        string `json:"email,omitempty"`
+	AuthProvider string `json:"auth_provider,omitempty"`
+}
+
+// Container describes a container as it ran, including the image digest the
+// runtime resolved its image to.
+type Container struct {
+	Name        string             `json:"name"`
+	Image       string             `json:"image"`
+	ImageDigest string             `json:"image_digest,omitempty"`
+	Resources   *db.ResourceLimits `json:"resources,omitempty"`
+}
+
+// NetworkPolicy is the network policy applied to the session's workload.
+type NetworkPolicy struct {
+	Name string          `json:"name"`
+	Spec json.RawMessage `json:"spec"`
+}
+
+// Envelope is a DSSE envelope holding a signed report.
+type Envelope struct {
+	PayloadType string      `json:"payloadType"`
+	Payload     string      `json:"payload"` // base64-encoded report JSON
+	Signatures  []Signature `json:"signatures"`
+}
+
+// Signature is a single DSSE signature.
+type Signature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"` // base64-encoded Ed25519 signature
+}
+
+// Signer signs reports with an Ed25519 key.
+type Signer struct {
+	key   ed25519.PrivateKey
+	keyID string
+}
+
+// NewSigner creates a signer from a 32-byte Ed25519 seed.
+func NewSigner(seed []byte) (*Signer, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("attestation key must be %d bytes, got %d", ed25519.SeedSize, len(seed))
+	}
+	key := ed25519.NewKeyFromSeed(seed)
+	return &Signer{key: key, keyID: KeyID(key.Public().(ed25519.PublicKey))}, nil
+}
+
+// ParseSigner creates a signer from a base64-encoded 32-byte Ed25519 seed,
+// as set in SORTIE_ATTESTATION_KEY.
+func ParseSigner(encoded string) (*Signer, error) {
+	seed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("attestation key is not valid base64: %w", err)
+	}
+	return NewSigner(seed)
+}
+
+// DeriveSigner creates a signer whose key is derived from a server secret,
+// so replicas sharing the secret share the key. Changing the secret changes
+// the key, so reports signed before the change no longer verify against
+// the current public key.
+func DeriveSigner(secret string) *Signer {
+	seed := sha256.Sum256([]byte("sortie-session-attestation\x00" + secret))
+	s, _ := NewSigner(seed[:])
+	return s
+}
+
+// KeyID returns the identifier of a public key: the first 16 hex characters
+// of its SHA-256 hash.
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// KeyID returns the identifier of the signer's key.
+func (s *Signer) KeyID() string {
+	return s.keyID
+}
+
+// PublicKey returns the signer's public key.
+func (s *Signer) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// PublicKeyPEM returns the signer's public key as a PEM-encoded
+// SubjectPublicKeyInfo block.
+func (s *Signer) PublicKeyPEM() string {
+	der, _ := x509.MarshalPKIXPublicKey(s.PublicKey())
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// Sign serializes a report and signs it.
+func (s *Signer) Sign(report *Report) (*Envelope, error) {
+	payload, err := json.Marshal(report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal report: %w", err)
+	}
+	return SignPayload(s.key, PayloadType, payload), nil
+}
+
+// SignPayload signs any payload with the signer's key; see SignPayload.
+func (s *Signer) SignPayload(payloadType string, payload []byte) *Envelope {
+	return SignPayload(s.key, payloadType, payload)
+}
+
+// SignPayload wraps any payload in a DSSE envelope signed with key. Other
+// signed documents, such as template catalogs, use it with their own
+// payload type.
+func SignPayload(key ed25519.PrivateKey, payloadType string, payload []byte) *Envelope {
+	sig := ed25519.Sign(key, pae(payloadType, payload))
+	return &Envelope{
+		PayloadType: payloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures:  []Signature{{KeyID: KeyID(key.Public().(ed25519.PublicKey)), Sig: base64.StdEncoding.EncodeToString(sig)}},
+	}
+}
+
+// Verify checks an envelope's signature against a public key and returns
+// the signed report.
+func Verify(env *Envelope, pub ed25519.PublicKey) (*Report, error) {
+	if _, err := VerifyPayload(env, PayloadType, pub); err != nil {
+		return nil, err
+	}
+	return Decode(env)
+}
+
+// VerifyPayload checks that an envelope holds a payload of payloadType
+// signed by pub, and returns the payload.
+func VerifyPayload(env *Envelope, payloadType string, pub ed25519.PublicKey) ([]byte, error) {
+	if env.PayloadType != payloadType {
+		return nil, fmt.Errorf("unexpected payload type %q", env.PayloadType)
+	}
+	payload, err := base64.StdEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload encoding: %w", err)
+	}
+	keyID := KeyID(pub)
+	for _, s := range env.Signatures {
+		if s.KeyID != keyID {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err == nil && ed25519.Verify(pub, pae(env.PayloadType, payload), sig) {
+			return payload, nil
+		}
+	}
+	return nil, errors.New("no valid signature for key " + keyID)
+}
+
+// ParsePublicKeyPEM parses a PEM-encoded Ed25519 public key, as returned
+// by PublicKeyPEM or written by `openssl pkey -pubout`.
+func ParsePublicKeyPEM(s string) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("not a PEM-encoded public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not an Ed25519 key")
+	}
+	return pub, nil
+}
+
+// Decode returns the report in an envelope without verifying it.
+func Decode(env *Envelope) (*Report, error) {
+	payload, err := base64.StdEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload encoding: %w", err)
+	}
+	var report Report
+	if err := json.Unmarshal(payload, &report); err != nil {
+		return nil, fmt.Errorf("invalid report: %w", err)
+	}
+	return &report, nil
+}
+
+// pae is the DSSE pre-authentication encoding of a payload.
+func pae(payloadType string, payload []byte) []byte {
+	return fmt.Appendf(nil, "DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload)
+}
@@ -0,0 +1,113 @@
+package attestation
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testReport() *Report {
+	return &Report{
+		Version:    ReportVersion,
+		SessionID:  "sess-1",
+		App:        App{ID: "app-1", Name: "App", LaunchType: "container"},
+		User:       User{ID: "user-1", Username: "alice"},
+		StartedAt:  time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		EndedAt:    time.Date(2026, 1, 2, 4, 4, 5, 0, time.UTC),
+		Workload:   "sortie-session-sess-1",
+		Containers: []Container{{Name: "app", Image: "nginx:latest", ImageDigest: "sha256:abc"}},
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	signer := DeriveSigner("test-secret")
+
+	env, err := signer.Sign(testReport())
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if env.PayloadType != PayloadType || len(env.Signatures) != 1 || env.Signatures[0].KeyID != signer.KeyID() {
+		t.Fatalf("unexpected envelope: %+v", env)
+	}
+
+	report, err := Verify(env, signer.PublicKey())
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if report.SessionID != "sess-1" || report.Containers[0].ImageDigest != "sha256:abc" {
+		t.Errorf("verified report = %+v", report)
+	}
+
+	// A tampered payload fails verification
+	tampered := *testReport()
+	tampered.Containers = []Container{{Name: "app", Image: "evil:latest"}}
+	other, _ := signer.Sign(&tampered)
+	forged := *env
+	forged.Payload = other.Payload
+	if _, err := Verify(&forged, signer.PublicKey()); err == nil {
+		t.Error("expected error verifying tampered payload")
+	}
+
+	// Another key does not verify it
+	if _, err := Verify(env, DeriveSigner("other-secret").PublicKey()); err == nil {
+		t.Error("expected error verifying with another key")
+	}
+}
+
+func TestSigners(t *testing.T) {
+	if DeriveSigner("a").KeyID() != DeriveSigner("a").KeyID() {
+		t.Error("derived keys should be stable")
+	}
+	if DeriveSigner("a").KeyID() == DeriveSigner("b").KeyID() {
+		t.Error("different secrets should derive different keys")
+	}
+
+	seed := bytes.Repeat([]byte{7}, ed25519.SeedSize)
+	s, err := ParseSigner(base64.StdEncoding.EncodeToString(seed))
+	if err != nil {
+		t.Fatalf("ParseSigner() error = %v", err)
+	}
+	if !bytes.Equal(s.PublicKey(), ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey)) {
+		t.Error("ParseSigner() used the wrong key")
+	}
+	if !strings.HasPrefix(s.PublicKeyPEM(), "-----BEGIN PUBLIC KEY-----") {
+		t.Errorf("PublicKeyPEM() = %q", s.PublicKeyPEM())
+	}
+	if pub, err := ParsePublicKeyPEM(s.PublicKeyPEM()); err != nil || !bytes.Equal(pub, s.PublicKey()) {
+		t.Errorf("ParsePublicKeyPEM() = %x, %v", pub, err)
+	}
+	if _, err := ParsePublicKeyPEM("not a key"); err == nil {
+		t.Error("expected error parsing a non-PEM key")
+	}
+
+	if _, err := ParseSigner("not base64!"); err == nil {
+		t.Error("expected error for invalid base64")
+	}
+	if _, err := ParseSigner(base64.StdEncoding.EncodeToString([]byte("short"))); err == nil {
+		t.Error("expected error for short key")
+	}
+}
+
+func TestSignPayload(t *testing.T) {
+	key := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{3}, ed25519.SeedSize))
+	env := SignPayload(key, "application/x-test", []byte("hello"))
+	pub := key.Public().(ed25519.PublicKey)
+
+	payload, err := VerifyPayload(env, "application/x-test", pub)
+	if err != nil || string(payload) != "hello" {
+		t.Fatalf("VerifyPayload() = %q, %v", payload, err)
+	}
+	if _, err := VerifyPayload(env, PayloadType, pub); err == nil {
+		t.Error("expected error verifying as another payload type")
+	}
+}
+
+func TestPAE(t *testing.T) {
+	got := string(pae("type", []byte("payload")))
+	if got != "DSSEv1 4 type 7 payload" {
+		t.Errorf("pae() = %q", got)
+	}
+}
@@ -79,7 +79,7 @@ func (c *Collector) OnEvent(_ context.Context, event sessions.SessionEventData)
 	switch event.Event {
 	case sessions.EventSessionReady:
 		c.handleSessionReady(event)
-	case sessions.EventSessionStopped, sessions.EventSessionExpired, sessions.EventSessionTerminated:
+	case sessions.EventSessionStopped, sessions.EventSessionExpired, sessions.EventSessionTerminated, sessions.EventSessionHibernated:
 		c.handleSessionEnd(event)
 	case sessions.EventSessionFailed:
 		c.handleSessionFailed(event)
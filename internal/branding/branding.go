@@ -0,0 +1,110 @@
+// Package branding stores the logo, favicon, and background images that
+// admins upload for a tenant. Theme colors and fonts live in the database
+// alongside the asset records (see db.TenantBranding); this package holds
+// the image bytes.
+package branding
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// MaxAssetSize is the largest image accepted for upload.
+const MaxAssetSize = 2 << 20 // 2 MiB
+
+// Asset kinds that can be uploaded.
+const (
+	KindLogo       = "logo"
+	KindFavicon    = "favicon"
+	KindBackground = "background"
+)
+
+// ValidKind reports whether kind is an uploadable asset kind.
+func ValidKind(kind string) bool {
+	switch kind {
+	case KindLogo, KindFavicon, KindBackground:
+		return true
+	}
+	return false
+}
+
+// contentTypeExt maps accepted image types to the file extension used in
+// storage paths.
+var contentTypeExt = map[string]string{
+	"image/png":                ".png",
+	"image/jpeg":               ".jpg",
+	"image/gif":                ".gif",
+	"image/webp":               ".webp",
+	"image/x-icon":             ".ico",
+	"image/vnd.microsoft.icon": ".ico",
+	"image/svg+xml":            ".svg",
+}
+
+// DetectContentType sniffs an uploaded image and returns its content type.
+// The client-supplied type is ignored; anything that is not a supported
+// image is rejected.
+func DetectContentType(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	if _, ok := contentTypeExt[contentType]; ok {
+		return contentType, nil
+	}
+	// SVG is XML text, which the standard sniffer reports as text/xml or
+	// text/plain.
+	if strings.HasPrefix(contentType, "text/") && isSVG(data) {
+		return "image/svg+xml", nil
+	}
+	return "", fmt.Errorf("unsupported image type %q (expected PNG, JPEG, GIF, WebP, ICO or SVG)", contentType)
+}
+
+// Extension returns the file extension, with its leading dot, used to store
+// images of an accepted content type.
+func Extension(contentType string) string {
+	return contentTypeExt[contentType]
+}
+
+func isSVG(data []byte) bool {
+	head := data
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	return bytes.Contains(bytes.ToLower(head), []byte("<svg"))
+}
+
+// AssetPath returns a new storage path for an asset. Each upload gets a
+// distinct path so cached copies of the previous image are never served
+// under the new one.
+func AssetPath(tenantID, kind, contentType string, now time.Time) string {
+	return fmt.Sprintf("%s/%s-%d%s", tenantID, kind, now.UnixNano(), Extension(contentType))
+}
+
+// AssetURL returns the public URL that serves a tenant's asset. The version
+// changes with each upload so browsers fetch the new image.
+func AssetURL(tenantID, kind string, asset db.BrandingAsset) string {
+	return fmt.Sprintf("/api/branding/assets/%s/%s?v=%d", tenantID, kind, asset.UpdatedAt.UnixNano())
+}
+
+// ReferencedPaths returns the storage paths of every asset recorded in the
+// database, for orphan detection.
+func ReferencedPaths(database *db.DB) (map[string]bool, error) {
+	all, err := database.ListTenantBranding()
+	if err != nil {
+		return nil, err
+	}
+	refs := make(map[string]bool)
+	for _, b := range all {
+		for _, asset := range b.Assets {
+			if asset.Path != "" {
+				refs[asset.Path] = true
+			}
+		}
+	}
+	return refs, nil
+}
@@ -0,0 +1,71 @@
+package branding
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+)
+
+func pngBytes(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDetectContentType(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{"png", pngBytes(t), "image/png", false},
+		{"jpeg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00"), "image/jpeg", false},
+		{"gif", []byte("GIF89a\x01\x00\x01\x00"), "image/gif", false},
+		{"ico", []byte("\x00\x00\x01\x00\x01\x00\x10\x10"), "image/x-icon", false},
+		{"svg", []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"></svg>`), "image/svg+xml", false},
+		{"bare svg", []byte(`<svg viewBox="0 0 1 1"></svg>`), "image/svg+xml", false},
+		{"html", []byte("<html><body>hi</body></html>"), "", true},
+		{"text", []byte("just some text"), "", true},
+		{"pdf", []byte("%PDF-1.4"), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DetectContentType(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DetectContentType() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("DetectContentType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssetPath(t *testing.T) {
+	now := time.Unix(0, 1700000000123)
+	got := AssetPath("acme", KindLogo, "image/svg+xml", now)
+	if got != "acme/logo-1700000000123.svg" {
+		t.Errorf("AssetPath() = %q", got)
+	}
+	if !strings.HasSuffix(AssetPath("acme", KindFavicon, "image/x-icon", now), ".ico") {
+		t.Error("favicon path should end in .ico")
+	}
+}
+
+func TestValidKind(t *testing.T) {
+	for _, kind := range []string{KindLogo, KindFavicon, KindBackground} {
+		if !ValidKind(kind) {
+			t.Errorf("ValidKind(%q) = false", kind)
+		}
+	}
+	if ValidKind("banner") || ValidKind("") {
+		t.Error("ValidKind accepted an unknown kind")
+	}
+}
@@ -0,0 +1,143 @@
+package branding
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rjsadow/sortie/internal/storagebrowser"
+)
+
+// Store abstracts branding asset storage. Paths are relative to the store
+// and have the form returned by AssetPath.
+type Store interface {
+	// Put writes the asset at the given path, replacing any existing object.
+	Put(path, contentType string, r io.Reader) error
+
+	// Get returns a ReadCloser for the asset at the given path.
+	Get(path string) (io.ReadCloser, error)
+
+	// Delete removes the asset at the given path.
+	Delete(path string) error
+
+	// List returns every stored asset, with paths in the same form as Put.
+	List() ([]storagebrowser.Object, error)
+}
+
+// LocalStore implements Store using the local filesystem.
+type LocalStore struct {
+	baseDir string
+}
+
+// NewLocalStore creates a LocalStore that writes to the given base directory.
+func NewLocalStore(baseDir string) *LocalStore {
+	return &LocalStore{baseDir: baseDir}
+}
+
+// resolve returns the absolute file path for a storage path, rejecting
+// paths that escape the base directory.
+func (s *LocalStore) resolve(path string) (string, error) {
+	absBase, err := filepath.Abs(s.baseDir)
+	if err != nil {
+		return "", fmt.Errorf("invalid base dir: %w", err)
+	}
+	absPath, err := filepath.Abs(filepath.Join(s.baseDir, path))
+	if err != nil {
+		return "", fmt.Errorf("invalid path: %w", err)
+	}
+	if !strings.HasPrefix(absPath, absBase+string(filepath.Separator)) {
+		return "", fmt.Errorf("path traversal detected: %s", path)
+	}
+	return absPath, nil
+}
+
+// Put writes the asset to disk. The content type is not stored; callers
+// keep it with the asset record.
+func (s *LocalStore) Put(path, _ string, r io.Reader) error {
+	absPath, err := s.resolve(path)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(absPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	f, err := os.Create(absPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", absPath, err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		os.Remove(absPath)
+		return fmt.Errorf("failed to write branding asset: %w", err)
+	}
+	return nil
+}
+
+// Get opens the asset at the given path for reading.
+func (s *LocalStore) Get(path string) (io.ReadCloser, error) {
+	absPath, err := s.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open branding asset: %w", err)
+	}
+	return f, nil
+}
+
+// Delete removes the asset at the given path. Deleting a missing asset is
+// not an error.
+func (s *LocalStore) Delete(path string) error {
+	absPath, err := s.resolve(path)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(absPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete branding asset: %w", err)
+	}
+	return nil
+}
+
+// List walks the base directory and returns every file with its path
+// relative to the base directory. A missing base directory is empty.
+func (s *LocalStore) List() ([]storagebrowser.Object, error) {
+	var objects []storagebrowser.Object
+	err := filepath.WalkDir(s.baseDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == s.baseDir && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(s.baseDir, path)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, storagebrowser.Object{
+			Path:    filepath.ToSlash(relPath),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branding assets: %w", err)
+	}
+	return objects, nil
+}
@@ -0,0 +1,64 @@
+package branding
+
+import (
+	"io"
+
+	"github.com/rjsadow/sortie/internal/storagebrowser"
+	"github.com/rjsadow/sortie/internal/storagecrypt"
+)
+
+// EncryptedStore encrypts assets written to another Store and decrypts
+// them on read. Assets stored before encryption was enabled are only read
+// if the cipher allows plaintext.
+type EncryptedStore struct {
+	store  Store
+	cipher *storagecrypt.Cipher
+}
+
+// NewEncryptedStore wraps store so assets are encrypted at rest with c.
+func NewEncryptedStore(store Store, c *storagecrypt.Cipher) *EncryptedStore {
+	return &EncryptedStore{store: store, cipher: c}
+}
+
+// Unwrap returns the underlying store, which reads and writes assets as
+// stored.
+func (s *EncryptedStore) Unwrap() Store {
+	return s.store
+}
+
+// Put encrypts and writes the asset. The stored object's content type is
+// application/octet-stream; callers keep the real one with the asset record.
+func (s *EncryptedStore) Put(path, _ string, r io.Reader) error {
+	enc, err := s.cipher.Encrypt(r)
+	if err != nil {
+		return err
+	}
+	return s.store.Put(path, "application/octet-stream", enc)
+}
+
+// Get returns the decrypted contents of the asset at the given path.
+func (s *EncryptedStore) Get(path string) (io.ReadCloser, error) {
+	rc, err := s.store.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := s.cipher.Decrypt(rc)
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{plain, rc}, nil
+}
+
+// Delete removes the asset at the given path.
+func (s *EncryptedStore) Delete(path string) error {
+	return s.store.Delete(path)
+}
+
+// List returns every stored asset. Sizes are the encrypted sizes.
+func (s *EncryptedStore) List() ([]storagebrowser.Object, error) {
+	return s.store.List()
+}
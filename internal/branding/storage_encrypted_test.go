@@ -0,0 +1,42 @@
+package branding
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/storagecrypt"
+)
+
+func TestEncryptedStore(t *testing.T) {
+	keys, err := storagecrypt.ParseKeys("test:" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+	if err != nil {
+		t.Fatalf("ParseKeys() error = %v", err)
+	}
+	mock := &mockS3Client{objects: map[string][]byte{}, types: map[string]string{}}
+	inner := NewS3Store(mock, "bucket", "branding/")
+	store := NewEncryptedStore(inner, storagecrypt.New(keys))
+
+	if err := store.Put("acme/logo-1.png", "image/png", strings.NewReader("logo")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	stored := mock.objects["branding/acme/logo-1.png"]
+	if bytes.Contains(stored, []byte("logo")) {
+		t.Error("asset stored in plaintext")
+	}
+	if ct := mock.types["branding/acme/logo-1.png"]; ct != "application/octet-stream" {
+		t.Errorf("stored content type = %q, want application/octet-stream", ct)
+	}
+
+	r, err := store.Get("acme/logo-1.png")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "logo" {
+		t.Errorf("Get() = %q, want %q", data, "logo")
+	}
+}
@@ -0,0 +1,100 @@
+package branding
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/rjsadow/sortie/internal/recordings"
+	"github.com/rjsadow/sortie/internal/storagebrowser"
+)
+
+// S3Store implements Store using an S3-compatible object store. Objects are
+// written under a key prefix; paths exclude the prefix so they do not change
+// when the prefix does.
+type S3Store struct {
+	client recordings.S3API
+	bucket string
+	prefix string
+}
+
+// NewS3Store creates an S3Store with the given client, bucket, and key prefix.
+func NewS3Store(client recordings.S3API, bucket, prefix string) *S3Store {
+	return &S3Store{
+		client: client,
+		bucket: bucket,
+		prefix: prefix,
+	}
+}
+
+// Put uploads the asset to S3.
+func (s *S3Store) Put(path, contentType string, r io.Reader) error {
+	body, cleanup, err := recordings.Seekable(r)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	_, err = s.client.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(s.prefix + path),
+		Body:        body,
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload branding asset to S3: %w", err)
+	}
+	return nil
+}
+
+// Get returns the S3 object body as an io.ReadCloser.
+func (s *S3Store) Get(path string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + path),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get branding asset from S3: %w", err)
+	}
+	return out.Body, nil
+}
+
+// Delete removes the asset object from S3.
+func (s *S3Store) Delete(path string) error {
+	_, err := s.client.DeleteObject(context.Background(), &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + path),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete branding asset from S3: %w", err)
+	}
+	return nil
+}
+
+// List returns every object under the store's prefix, with the prefix
+// removed from each path.
+func (s *S3Store) List() ([]storagebrowser.Object, error) {
+	var objects []storagebrowser.Object
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(s.prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list branding assets in S3: %w", err)
+		}
+		for _, obj := range page.Contents {
+			objects = append(objects, storagebrowser.Object{
+				Path:    strings.TrimPrefix(aws.ToString(obj.Key), s.prefix),
+				Size:    aws.ToInt64(obj.Size),
+				ModTime: aws.ToTime(obj.LastModified),
+			})
+		}
+	}
+	return objects, nil
+}
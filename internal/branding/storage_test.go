@@ -0,0 +1,139 @@
+package branding
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// testStore exercises a Store through a put, list, get, delete cycle.
+func testStore(t *testing.T, store Store) {
+	t.Helper()
+
+	if err := store.Put("acme/logo-1.png", "image/png", strings.NewReader("logo")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	objects, err := store.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(objects) != 1 || objects[0].Path != "acme/logo-1.png" || objects[0].Size != 4 {
+		t.Fatalf("List() = %+v", objects)
+	}
+
+	r, err := store.Get("acme/logo-1.png")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "logo" {
+		t.Errorf("Get() = %q, want %q", data, "logo")
+	}
+
+	if err := store.Delete("acme/logo-1.png"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := store.Get("acme/logo-1.png"); err == nil {
+		t.Error("Get() after Delete() should fail")
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	testStore(t, NewLocalStore(t.TempDir()))
+}
+
+func TestLocalStore_PathTraversal(t *testing.T) {
+	store := NewLocalStore(t.TempDir())
+	if err := store.Put("../escape.png", "image/png", strings.NewReader("x")); err == nil {
+		t.Error("Put() should reject paths outside the base directory")
+	}
+	if _, err := store.Get("../../etc/passwd"); err == nil {
+		t.Error("Get() should reject paths outside the base directory")
+	}
+}
+
+func TestLocalStore_ListMissingDir(t *testing.T) {
+	store := NewLocalStore(t.TempDir() + "/missing")
+	objects, err := store.List()
+	if err != nil || len(objects) != 0 {
+		t.Errorf("List() = %v, %v; want empty", objects, err)
+	}
+}
+
+// mockS3Client is an in-memory S3API.
+type mockS3Client struct {
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func (m *mockS3Client) PutObject(_ context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.objects[*input.Key] = data
+	m.types[*input.Key] = aws.ToString(input.ContentType)
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (m *mockS3Client) GetObject(_ context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	data, ok := m.objects[*input.Key]
+	if !ok {
+		return nil, fmt.Errorf("NoSuchKey: %s", *input.Key)
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func (m *mockS3Client) DeleteObject(_ context.Context, input *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	delete(m.objects, *input.Key)
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func (m *mockS3Client) ListObjectsV2(_ context.Context, input *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	var keys []string
+	for key := range m.objects {
+		if strings.HasPrefix(key, aws.ToString(input.Prefix)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	out := &s3.ListObjectsV2Output{}
+	for _, key := range keys {
+		out.Contents = append(out.Contents, types.Object{
+			Key:          aws.String(key),
+			Size:         aws.Int64(int64(len(m.objects[key]))),
+			LastModified: aws.Time(time.Now()),
+		})
+	}
+	return out, nil
+}
+
+func TestS3Store(t *testing.T) {
+	mock := &mockS3Client{objects: map[string][]byte{}, types: map[string]string{}}
+	// An object outside the prefix belongs to something else
+	mock.objects["recordings/2024/01/rec.vncrec"] = []byte("rec")
+
+	store := NewS3Store(mock, "bucket", "branding/")
+	if err := store.Put("acme/favicon-1.ico", "image/x-icon", strings.NewReader("ico")); err != nil {
+		t.Fatal(err)
+	}
+	if got := mock.types["branding/acme/favicon-1.ico"]; got != "image/x-icon" {
+		t.Errorf("stored content type = %q, want image/x-icon", got)
+	}
+	if err := store.Delete("acme/favicon-1.ico"); err != nil {
+		t.Fatal(err)
+	}
+
+	testStore(t, store)
+}
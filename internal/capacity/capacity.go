@@ -0,0 +1,181 @@
+// Package capacity turns session history into concurrency statistics for
+// capacity planning: an hour-of-week heat map of concurrent sessions and a
+// linear forecast of the weekly peak.
+package capacity
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Run is a period during which one session was running.
+type Run struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Cell is the concurrency of one hour of the week. Day 0 is Monday.
+type Cell struct {
+	Day  int `json:"day"`
+	Hour int `json:"hour"`
+	// Average is the time-weighted mean number of concurrent sessions
+	// during the hour, averaged over the weeks analyzed.
+	Average float64 `json:"average"`
+	// Peak is the most concurrent sessions seen during the hour in any
+	// week analyzed.
+	Peak int `json:"peak"`
+}
+
+// WeekPeak is the most concurrent sessions seen in one week.
+type WeekPeak struct {
+	WeekStart time.Time `json:"week_start"`
+	Peak      int       `json:"peak"`
+}
+
+// Forecast is the predicted peak of a future week.
+type Forecast struct {
+	WeekStart time.Time `json:"week_start"`
+	Peak      float64   `json:"peak"`
+}
+
+// Report is the concurrency of the weeks analyzed and the forecast for the
+// weeks after them.
+type Report struct {
+	From        time.Time  `json:"from"`
+	To          time.Time  `json:"to"`
+	Timezone    string     `json:"timezone"`
+	Heatmap     []Cell     `json:"heatmap"`
+	WeeklyPeaks []WeekPeak `json:"weekly_peaks"`
+	// TrendPerWeek is the slope of the line fitted to the weekly peaks.
+	TrendPerWeek float64    `json:"trend_per_week"`
+	Forecast     []Forecast `json:"forecast"`
+}
+
+// WeekStart returns midnight on the Monday of t's week, in t's location.
+func WeekStart(t time.Time) time.Time {
+	days := (int(t.Weekday()) + 6) % 7
+	y, m, d := t.Date()
+	return time.Date(y, m, d-days, 0, 0, 0, 0, t.Location())
+}
+
+// Analyze measures concurrency over the weeks that start at from, which
+// should be a WeekStart, and forecasts the peak of the forecastWeeks weeks
+// that follow. Hours of the week are those of from's location.
+func Analyze(runs []Run, from time.Time, weeks, forecastWeeks int) *Report {
+	loc := from.Location()
+	to := from.AddDate(0, 0, 7*weeks)
+
+	type event struct {
+		at    time.Time
+		delta int
+	}
+	events := make([]event, 0, 2*len(runs))
+	for _, r := range runs {
+		if !r.End.After(r.Start) || !r.End.After(from) || !r.Start.Before(to) {
+			continue
+		}
+		events = append(events, event{r.Start, 1}, event{r.End, -1})
+	}
+	// Ends sort before starts at the same instant, so back-to-back runs
+	// do not count as concurrent.
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].at.Equal(events[j].at) {
+			return events[i].at.Before(events[j].at)
+		}
+		return events[i].delta < events[j].delta
+	})
+
+	var (
+		sums   [7][24]float64
+		counts [7][24]int
+		peaks  [7][24]int
+	)
+	report := &Report{
+		From:        from,
+		To:          to,
+		Timezone:    loc.String(),
+		WeeklyPeaks: make([]WeekPeak, weeks),
+	}
+	for w := range report.WeeklyPeaks {
+		report.WeeklyPeaks[w].WeekStart = from.AddDate(0, 0, 7*w)
+	}
+
+	current, next := 0, 0
+	for hour := from; hour.Before(to); hour = hour.Add(time.Hour) {
+		end := hour.Add(time.Hour)
+		for next < len(events) && !events[next].at.After(hour) {
+			current += events[next].delta
+			next++
+		}
+		peak, area, last := current, 0.0, hour
+		for next < len(events) && events[next].at.Before(end) {
+			area += float64(current) * events[next].at.Sub(last).Seconds()
+			last = events[next].at
+			current += events[next].delta
+			peak = max(peak, current)
+			next++
+		}
+		area += float64(current) * end.Sub(last).Seconds()
+
+		local := hour.In(loc)
+		day, h := (int(local.Weekday())+6)%7, local.Hour()
+		sums[day][h] += area / time.Hour.Seconds()
+		counts[day][h]++
+		peaks[day][h] = max(peaks[day][h], peak)
+
+		w := sort.Search(weeks, func(i int) bool {
+			return report.WeeklyPeaks[i].WeekStart.After(hour)
+		}) - 1
+		report.WeeklyPeaks[w].Peak = max(report.WeeklyPeaks[w].Peak, peak)
+	}
+
+	report.Heatmap = make([]Cell, 0, 7*24)
+	for day := range 7 {
+		for h := range 24 {
+			cell := Cell{Day: day, Hour: h, Peak: peaks[day][h]}
+			if counts[day][h] > 0 {
+				cell.Average = round(sums[day][h] / float64(counts[day][h]))
+			}
+			report.Heatmap = append(report.Heatmap, cell)
+		}
+	}
+
+	slope, intercept := fitLine(report.WeeklyPeaks)
+	report.TrendPerWeek = round(slope)
+	report.Forecast = make([]Forecast, forecastWeeks)
+	for i := range report.Forecast {
+		x := float64(weeks + i)
+		report.Forecast[i] = Forecast{
+			WeekStart: to.AddDate(0, 0, 7*i),
+			Peak:      round(math.Max(0, intercept+slope*x)),
+		}
+	}
+	return report
+}
+
+// fitLine fits peak = intercept + slope*week by least squares, where week
+// counts from 0. With fewer than two weeks the line is flat.
+func fitLine(peaks []WeekPeak) (slope, intercept float64) {
+	n := float64(len(peaks))
+	if n == 0 {
+		return 0, 0
+	}
+	var sumX, sumY, sumXY, sumXX float64
+	for i, p := range peaks {
+		x, y := float64(i), float64(p.Peak)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	if denom := n*sumXX - sumX*sumX; denom != 0 {
+		slope = (n*sumXY - sumX*sumY) / denom
+	}
+	return slope, (sumY - slope*sumX) / n
+}
+
+// round rounds to two decimal places.
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,128 @@
+package capacity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWeekStart(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone data unavailable")
+	}
+	tests := []struct {
+		in, want time.Time
+	}{
+		{time.Date(2026, 10, 18, 15, 4, 5, 0, time.UTC), time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 11, 1, 12, 0, 0, 0, berlin), time.Date(2026, 10, 26, 0, 0, 0, 0, berlin)},
+	}
+	for _, tt := range tests {
+		if got := WeekStart(tt.in); !got.Equal(tt.want) || got.Location() != tt.want.Location() {
+			t.Errorf("WeekStart(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	from := time.Date(2026, 9, 7, 0, 0, 0, 0, time.UTC) // a Monday
+	at := func(week, day, hour, minute int) time.Time {
+		return from.AddDate(0, 0, 7*week+day).Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+
+	var runs []Run
+	// Every Monday from 09:00 to 10:00, week w has w+1 concurrent sessions
+	for w := range 3 {
+		for range w + 1 {
+			runs = append(runs, Run{at(w, 0, 9, 0), at(w, 0, 10, 0)})
+		}
+	}
+	// A Wednesday session covering half of 14:00 in week 0, followed
+	// back to back by another
+	runs = append(runs,
+		Run{at(0, 2, 14, 0), at(0, 2, 14, 30)},
+		Run{at(0, 2, 14, 30), at(0, 2, 15, 0)},
+	)
+	// Runs outside the window or empty are ignored
+	runs = append(runs,
+		Run{from.Add(-2 * time.Hour), from.Add(-time.Hour)},
+		Run{at(1, 3, 8, 0), at(1, 3, 8, 0)},
+	)
+
+	report := Analyze(runs, from, 3, 2)
+
+	if len(report.Heatmap) != 7*24 {
+		t.Fatalf("heat map has %d cells, want 168", len(report.Heatmap))
+	}
+	cell := func(day, hour int) Cell { return report.Heatmap[day*24+hour] }
+	if c := cell(0, 9); c.Day != 0 || c.Hour != 9 || c.Peak != 3 || c.Average != 2 {
+		t.Errorf("Monday 09:00 = %+v, want peak 3, average 2", c)
+	}
+	if c := cell(0, 10); c.Peak != 0 || c.Average != 0 {
+		t.Errorf("Monday 10:00 = %+v, want empty", c)
+	}
+	if c := cell(2, 14); c.Peak != 1 || c.Average != 0.33 {
+		t.Errorf("Wednesday 14:00 = %+v, want peak 1, average 0.33", c)
+	}
+	if c := cell(3, 8); c.Peak != 0 {
+		t.Errorf("Thursday 08:00 = %+v, want empty", c)
+	}
+
+	wantPeaks := []int{1, 2, 3}
+	if len(report.WeeklyPeaks) != len(wantPeaks) {
+		t.Fatalf("weekly peaks = %+v", report.WeeklyPeaks)
+	}
+	for i, p := range report.WeeklyPeaks {
+		if p.Peak != wantPeaks[i] || !p.WeekStart.Equal(from.AddDate(0, 0, 7*i)) {
+			t.Errorf("week %d = %+v, want peak %d", i, p, wantPeaks[i])
+		}
+	}
+
+	if report.TrendPerWeek != 1 {
+		t.Errorf("trend = %v, want 1", report.TrendPerWeek)
+	}
+	if len(report.Forecast) != 2 || report.Forecast[0].Peak != 4 || report.Forecast[1].Peak != 5 {
+		t.Errorf("forecast = %+v, want peaks 4 and 5", report.Forecast)
+	}
+	if !report.Forecast[0].WeekStart.Equal(report.To) {
+		t.Errorf("forecast starts %v, want %v", report.Forecast[0].WeekStart, report.To)
+	}
+}
+
+func TestAnalyze_Forecast(t *testing.T) {
+	from := time.Date(2026, 9, 7, 0, 0, 0, 0, time.UTC)
+
+	// No history forecasts no sessions
+	report := Analyze(nil, from, 4, 1)
+	if report.TrendPerWeek != 0 || report.Forecast[0].Peak != 0 {
+		t.Errorf("empty forecast = %v, %+v", report.TrendPerWeek, report.Forecast)
+	}
+
+	// A single week forecasts its peak
+	runs := []Run{{from.Add(time.Hour), from.Add(2 * time.Hour)}, {from.Add(time.Hour), from.Add(3 * time.Hour)}}
+	report = Analyze(runs, from, 1, 1)
+	if report.Forecast[0].Peak != 2 {
+		t.Errorf("single week forecast = %+v, want 2", report.Forecast)
+	}
+
+	// A falling trend does not forecast below zero
+	runs = []Run{{from.Add(time.Hour), from.Add(2 * time.Hour)}}
+	report = Analyze(runs, from, 2, 3)
+	if report.TrendPerWeek != -1 || report.Forecast[2].Peak != 0 {
+		t.Errorf("falling forecast = %v, %+v", report.TrendPerWeek, report.Forecast)
+	}
+}
+
+func TestAnalyze_TimeZone(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip("time zone data unavailable")
+	}
+	from := WeekStart(time.Date(2026, 9, 9, 0, 0, 0, 0, tokyo))
+	// Monday 00:30 UTC is Monday 09:30 in Tokyo
+	start := time.Date(2026, 9, 7, 0, 30, 0, 0, time.UTC)
+	report := Analyze([]Run{{start, start.Add(15 * time.Minute)}}, from, 1, 0)
+	if report.Timezone != "Asia/Tokyo" || report.Heatmap[9].Peak != 1 {
+		t.Errorf("report = %s, Monday 09:00 %+v", report.Timezone, report.Heatmap[9])
+	}
+}
@@ -0,0 +1,171 @@
+// Package chaos injects controlled faults into a running server, so that
+// backpressure, retries and reconnection can be seen to work: it delays
+// workloads becoming ready, drops WebSocket frames, and slows database
+// queries.
+//
+// Faults are only injected by binaries built with the chaos build tag:
+//
+//	go build -tags chaos .
+//
+// Without it, Enabled is false, the hooks compile to nothing and the
+// admin endpoint that sets faults is not registered. Faults are held in
+// memory, so each replica has its own and they are cleared on restart.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// MaxPodReadyDelaySeconds is the longest injected readiness delay.
+	MaxPodReadyDelaySeconds = 600
+
+	// MaxDBLatencyMS is the longest injected query latency.
+	MaxDBLatencyMS = 10000
+)
+
+// Faults are the faults to inject. The zero value injects none.
+type Faults struct {
+	// PodReadyDelaySeconds delays each launch before it waits for its
+	// workload to become ready. The delay counts against the pod ready
+	// timeout, so a delay longer than the timeout fails the launch.
+	PodReadyDelaySeconds float64 `json:"pod_ready_delay_seconds"`
+	// WebSocketDropRate is the probability, from 0 to 1, that a frame
+	// relayed between a client and a session's VNC or guacd connection is
+	// dropped.
+	WebSocketDropRate float64 `json:"websocket_drop_rate"`
+	// DBLatencyMS delays every database query.
+	DBLatencyMS int `json:"db_latency_ms"`
+}
+
+// Validate checks that the faults are in range.
+func (f Faults) Validate() error {
+	if f.PodReadyDelaySeconds < 0 || f.PodReadyDelaySeconds > MaxPodReadyDelaySeconds {
+		return fmt.Errorf("pod_ready_delay_seconds must be between 0 and %d", MaxPodReadyDelaySeconds)
+	}
+	if f.WebSocketDropRate < 0 || f.WebSocketDropRate > 1 {
+		return errors.New("websocket_drop_rate must be between 0 and 1")
+	}
+	if f.DBLatencyMS < 0 || f.DBLatencyMS > MaxDBLatencyMS {
+		return fmt.Errorf("db_latency_ms must be between 0 and %d", MaxDBLatencyMS)
+	}
+	return nil
+}
+
+// Injected counts the faults injected since the server started.
+type Injected struct {
+	PodReadyDelays int64 `json:"pod_ready_delays"`
+	DroppedFrames  int64 `json:"dropped_frames"`
+	DelayedQueries int64 `json:"delayed_queries"`
+}
+
+// Injector holds the faults to inject and injects them.
+type Injector struct {
+	mu     sync.RWMutex
+	faults Faults
+	rand   func() float64 // replaced in tests
+
+	podReadyDelays atomic.Int64
+	droppedFrames  atomic.Int64
+	delayedQueries atomic.Int64
+}
+
+// NewInjector creates an Injector that injects no faults.
+func NewInjector() *Injector {
+	return &Injector{rand: rand.Float64}
+}
+
+// Faults returns the faults being injected.
+func (i *Injector) Faults() Faults {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.faults
+}
+
+// Set replaces the faults to inject.
+func (i *Injector) Set(f Faults) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	i.mu.Lock()
+	i.faults = f
+	i.mu.Unlock()
+	return nil
+}
+
+// Injected returns the counts of faults injected.
+func (i *Injector) Injected() Injected {
+	return Injected{
+		PodReadyDelays: i.podReadyDelays.Load(),
+		DroppedFrames:  i.droppedFrames.Load(),
+		DelayedQueries: i.delayedQueries.Load(),
+	}
+}
+
+// DelayPodReady waits out the readiness delay, or until ctx is done.
+func (i *Injector) DelayPodReady(ctx context.Context) {
+	d := time.Duration(i.Faults().PodReadyDelaySeconds * float64(time.Second))
+	if d <= 0 {
+		return
+	}
+	i.podReadyDelays.Add(1)
+	sleep(ctx, d)
+}
+
+// DropFrame reports whether to drop the next frame.
+func (i *Injector) DropFrame() bool {
+	rate := i.Faults().WebSocketDropRate
+	if rate <= 0 || i.rand() >= rate {
+		return false
+	}
+	i.droppedFrames.Add(1)
+	return true
+}
+
+// DelayQuery waits out the query latency, or until ctx is done.
+func (i *Injector) DelayQuery(ctx context.Context) {
+	d := time.Duration(i.Faults().DBLatencyMS) * time.Millisecond
+	if d <= 0 {
+		return
+	}
+	i.delayedQueries.Add(1)
+	sleep(ctx, d)
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
+
+// Default is the server's Injector, set through the admin endpoint.
+var Default = NewInjector()
+
+// DelayPodReady injects the readiness delay when faults are enabled.
+func DelayPodReady(ctx context.Context) {
+	if Enabled {
+		Default.DelayPodReady(ctx)
+	}
+}
+
+// DropFrame reports whether to drop a relayed WebSocket frame. It is
+// always false unless faults are enabled.
+func DropFrame() bool {
+	return Enabled && Default.DropFrame()
+}
+
+// DelayQuery injects the query latency when faults are enabled.
+func DelayQuery(ctx context.Context) {
+	if Enabled {
+		Default.DelayQuery(ctx)
+	}
+}
@@ -0,0 +1,75 @@
+package chaos
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFaults_Validate(t *testing.T) {
+	tests := []struct {
+		faults  Faults
+		wantErr bool
+	}{
+		{Faults{}, false},
+		{Faults{PodReadyDelaySeconds: 30, WebSocketDropRate: 0.05, DBLatencyMS: 200}, false},
+		{Faults{WebSocketDropRate: 1}, false},
+		{Faults{PodReadyDelaySeconds: -1}, true},
+		{Faults{PodReadyDelaySeconds: MaxPodReadyDelaySeconds + 1}, true},
+		{Faults{WebSocketDropRate: 1.5}, true},
+		{Faults{DBLatencyMS: -10}, true},
+		{Faults{DBLatencyMS: MaxDBLatencyMS + 1}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.faults.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) error = %v, wantErr %v", tt.faults, err, tt.wantErr)
+		}
+	}
+}
+
+func TestInjector(t *testing.T) {
+	i := NewInjector()
+	if i.DropFrame() {
+		t.Error("DropFrame() = true with no faults")
+	}
+	if err := i.Set(Faults{WebSocketDropRate: 2}); err == nil {
+		t.Error("Set() accepted an invalid drop rate")
+	}
+
+	if err := i.Set(Faults{WebSocketDropRate: 0.5, DBLatencyMS: 20, PodReadyDelaySeconds: 60}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	i.rand = func() float64 { return 0.25 }
+	if !i.DropFrame() {
+		t.Error("DropFrame() = false below the drop rate")
+	}
+	i.rand = func() float64 { return 0.75 }
+	if i.DropFrame() {
+		t.Error("DropFrame() = true above the drop rate")
+	}
+
+	start := time.Now()
+	i.DelayQuery(context.Background())
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("DelayQuery() returned after %v, want at least 20ms", elapsed)
+	}
+
+	// The readiness delay gives up when the launch does
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	i.DelayPodReady(ctx)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("DelayPodReady() ignored its context for %v", elapsed)
+	}
+
+	want := Injected{PodReadyDelays: 1, DroppedFrames: 1, DelayedQueries: 1}
+	if got := i.Injected(); got != want {
+		t.Errorf("Injected() = %+v, want %+v", got, want)
+	}
+
+	i.Set(Faults{})
+	if i.DropFrame() || i.Faults() != (Faults{}) {
+		t.Errorf("faults = %+v after clearing", i.Faults())
+	}
+}
@@ -0,0 +1,315 @@
+// Package compliance builds compliance reports: point-in-time snapshots of
+// who can access what, for auditors.
+//
+// A report is a zip archive of CSV files, one per kind of access, with a
+// manifest listing each file's SHA-256 hash and a DSSE envelope signing
+// the manifest with the server's attestation key. Auditors can check the
+// files against the manifest and the manifest against the public key
+// without trusting the server that produced them.
+package compliance
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/attestation"
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// PayloadType identifies report manifests inside a DSSE envelope.
+const PayloadType = "application/vnd.sortie.compliance-manifest+json"
+
+// ManifestVersion is the version of the Manifest format.
+const ManifestVersion = "1"
+
+// Names of the manifest and its signature in a report.
+const (
+	ManifestFile  = "manifest.json"
+	SignatureFile = "manifest.sig.json"
+)
+
+// Manifest describes a report's files.
+type Manifest struct {
+	Version     string    `json:"version"`
+	GeneratedAt time.Time `json:"generated_at"`
+	GeneratedBy string    `json:"generated_by"`
+	Files       []File    `json:"files"`
+}
+
+// File is one CSV file of a report.
+type File struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+	Rows   int    `json:"rows"` // Not counting the header
+}
+
+// table is a CSV file's contents.
+type table struct {
+	name   string
+	header []string
+	rows   [][]string
+}
+
+// Snapshot is the data of a report, read at one point in time.
+type Snapshot struct {
+	GeneratedAt time.Time
+	tables      []table
+}
+
+// Collect reads a snapshot from the database in a single transaction.
+// settings are the admin settings as the settings API reports them.
+func Collect(database *db.DB, settings map[string]any) (*Snapshot, error) {
+	s := &Snapshot{GeneratedAt: time.Now().UTC()}
+	err := database.WithTx(func(tx *db.DB) error {
+		s.tables = nil
+		return s.collect(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.tables = append(s.tables, settingsTable(settings))
+	return s, nil
+}
+
+func (s *Snapshot) collect(tx *db.DB) error {
+	users, err := tx.ListUsers()
+	if err != nil {
+		return fmt.Errorf("list users: %w", err)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	usernames := make(map[string]string, len(users))
+	for _, u := range users {
+		usernames[u.ID] = u.Username
+	}
+
+	userRows := table{name: "users.csv", header: []string{
+		"user_id", "username", "email", "display_name", "tenant_id", "auth_provider", "disabled", "last_login_at", "created_at",
+	}}
+	roleRows := table{name: "role_assignments.csv", header: []string{"user_id", "username", "scope", "tenant_id", "role"}}
+	for _, u := range users {
+		userRows.rows = append(userRows.rows, []string{
+			u.ID, u.Username, u.Email, u.DisplayName, u.TenantID, u.AuthProvider,
+			strconv.FormatBool(u.Disabled), formatTimePtr(u.LastLoginAt), formatTime(u.CreatedAt),
+		})
+		for _, role := range u.Roles {
+			roleRows.rows = append(roleRows.rows, []string{u.ID, u.Username, "global", "", role})
+		}
+		for _, role := range u.TenantRoles {
+			roleRows.rows = append(roleRows.rows, []string{u.ID, u.Username, "tenant", u.TenantID, role})
+		}
+	}
+
+	categories, err := tx.ListCategories()
+	if err != nil {
+		return fmt.Errorf("list categories: %w", err)
+	}
+	aclRows := table{name: "category_acls.csv", header: []string{
+		"category_id", "category_name", "tenant_id", "user_id", "username", "access",
+	}}
+	for _, c := range categories {
+		admins, err := tx.ListCategoryAdmins(c.ID)
+		if err != nil {
+			return fmt.Errorf("list category admins: %w", err)
+		}
+		approved, err := tx.ListCategoryApprovedUsers(c.ID)
+		if err != nil {
+			return fmt.Errorf("list category approved users: %w", err)
+		}
+		for _, id := range admins {
+			aclRows.rows = append(aclRows.rows, []string{c.ID, c.Name, c.TenantID, id, usernames[id], "admin"})
+		}
+		for _, id := range approved {
+			aclRows.rows = append(aclRows.rows, []string{c.ID, c.Name, c.TenantID, id, usernames[id], "approved"})
+		}
+	}
+
+	apps, err := tx.ListApps()
+	if err != nil {
+		return fmt.Errorf("list apps: %w", err)
+	}
+	appRows := table{name: "app_visibility.csv", header: []string{"app_id", "app_name", "tenant_id", "category", "visibility"}}
+	for _, a := range apps {
+		visibility := a.Visibility
+		if visibility == "" {
+			visibility = db.CategoryVisibilityPublic
+		}
+		appRows.rows = append(appRows.rows, []string{a.ID, a.Name, a.TenantID, a.Category, string(visibility)})
+	}
+
+	shares, err := tx.ListActiveSessionShares()
+	if err != nil {
+		return fmt.Errorf("list shares: %w", err)
+	}
+	shareRows := table{name: "shares.csv", header: []string{
+		"share_id", "session_id", "app_id", "owner_id", "owner_username", "shared_with_id", "shared_with_username", "link", "permission", "created_by", "created_at",
+	}}
+	for _, sh := range shares {
+		shareRows.rows = append(shareRows.rows, []string{
+			sh.ID, sh.SessionID, sh.AppID, sh.OwnerID, usernames[sh.OwnerID], sh.UserID, usernames[sh.UserID],
+			strconv.FormatBool(sh.ShareToken != ""), string(sh.Permission), sh.CreatedBy, formatTime(sh.CreatedAt),
+		})
+	}
+
+	tokens, err := tx.ListAPITokens()
+	if err != nil {
+		return fmt.Errorf("list API keys: %w", err)
+	}
+	tokenRows := table{name: "api_keys.csv", header: []string{
+		"key_id", "user_id", "username", "name", "prefix", "scopes", "created_at", "last_used_at", "expires_at",
+	}}
+	for _, t := range tokens {
+		tokenRows.rows = append(tokenRows.rows, []string{
+			t.ID, t.UserID, usernames[t.UserID], t.Name, t.Prefix, t.Scopes,
+			formatTime(t.CreatedAt), formatTimePtr(t.LastUsedAt), formatTime(t.ExpiresAt),
+		})
+	}
+
+	s.tables = append(s.tables, userRows, roleRows, aclRows, appRows, shareRows, tokenRows)
+	return nil
+}
+
+// settingsTable lists settings by key. Values that are not strings are
+// written as JSON.
+func settingsTable(settings map[string]any) table {
+	t := table{name: "settings.csv", header: []string{"key", "value"}}
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		value, ok := settings[k].(string)
+		if !ok {
+			b, _ := json.Marshal(settings[k])
+			value = string(b)
+		}
+		t.rows = append(t.rows, []string{k, value})
+	}
+	return t
+}
+
+// WriteZip writes the snapshot as a report: its CSV files, then the
+// manifest and its signature.
+func (s *Snapshot) WriteZip(w io.Writer, signer *attestation.Signer, generatedBy string) error {
+	zw := zip.NewWriter(w)
+	manifest := Manifest{Version: ManifestVersion, GeneratedAt: s.GeneratedAt, GeneratedBy: generatedBy}
+	for _, t := range s.tables {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: t.name, Method: zip.Deflate, Modified: s.GeneratedAt})
+		if err != nil {
+			return err
+		}
+		h := sha256.New()
+		cw := csv.NewWriter(io.MultiWriter(f, h))
+		cw.Write(t.header)
+		for _, row := range t.rows {
+			cw.Write(row)
+		}
+		if cw.Flush(); cw.Error() != nil {
+			return cw.Error()
+		}
+		manifest.Files = append(manifest.Files, File{Name: t.name, SHA256: hex.EncodeToString(h.Sum(nil)), Rows: len(t.rows)})
+	}
+
+	payload, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	envelope, err := json.MarshalIndent(signer.SignPayload(PayloadType, payload), "", "  ")
+	if err != nil {
+		return err
+	}
+	for _, file := range []struct {
+		name string
+		data []byte
+	}{{ManifestFile, payload}, {SignatureFile, envelope}} {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: s.GeneratedAt})
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(file.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// Verify checks a report against the public key of the server that
+// produced it: the manifest's signature, and each file's hash. It returns
+// the manifest.
+func Verify(report []byte, pub ed25519.PublicKey) (*Manifest, error) {
+	zr, err := zip.NewReader(bytes.NewReader(report), int64(len(report)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid report: %w", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	sig, err := readFile(files, SignatureFile)
+	if err != nil {
+		return nil, err
+	}
+	var env attestation.Envelope
+	if err := json.Unmarshal(sig, &env); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", SignatureFile, err)
+	}
+	payload, err := attestation.VerifyPayload(&env, PayloadType, pub)
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(payload, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	for _, mf := range manifest.Files {
+		data, err := readFile(files, mf.Name)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != mf.SHA256 {
+			return nil, fmt.Errorf("%s does not match the manifest", mf.Name)
+		}
+	}
+	return &manifest, nil
+}
+
+func readFile(files map[string]*zip.File, name string) ([]byte, error) {
+	f, ok := files[name]
+	if !ok {
+		return nil, errors.New("report has no " + name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatTimePtr(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return formatTime(*t)
+}
@@ -0,0 +1,176 @@
+package compliance
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/attestation"
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/db/dbtest"
+)
+
+func readCSV(t *testing.T, report []byte, name string) [][]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(report), int64(len(report)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		rows, err := csv.NewReader(rc).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rows
+	}
+	t.Fatalf("report has no %s", name)
+	return nil
+}
+
+func TestReport(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+	for _, u := range []db.User{
+		{ID: "u1", Username: "alice", Roles: []string{"admin"}},
+		{ID: "u2", Username: "bob", Roles: []string{"user"}, TenantRoles: []string{"tenant-admin"}},
+	} {
+		if err := database.CreateUser(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := database.CreateCategory(db.Category{ID: "c1", Name: "Finance"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.AddCategoryApprovedUser("c1", "u2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.CreateApp(db.Application{ID: "ledger", Name: "Ledger", Category: "Finance", Visibility: db.CategoryVisibilityApproved}); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []db.Session{
+		{ID: "s1", UserID: "u1", AppID: "ledger", PodName: "p1", Status: db.SessionStatusRunning},
+		{ID: "s2", UserID: "u1", AppID: "ledger", PodName: "p2", Status: db.SessionStatusStopped},
+	} {
+		if err := database.CreateSession(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, sh := range []db.SessionShare{
+		{ID: "sh1", SessionID: "s1", UserID: "u2", Permission: db.SharePermissionReadOnly, CreatedBy: "u1"},
+		{ID: "sh2", SessionID: "s2", ShareToken: "t2", Permission: db.SharePermissionReadOnly, CreatedBy: "u1"},
+	} {
+		if err := database.CreateSessionShare(sh); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := database.CreateAPIToken(db.APIToken{
+		ID: "k1", UserID: "u2", Name: "ci", TokenHash: "hash", Prefix: "srt_abc", Scopes: "apps:read",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := Collect(database, map[string]any{"allow_registration": false, "theme": "dark"})
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	signer := attestation.DeriveSigner("secret")
+	var buf bytes.Buffer
+	if err := snapshot.WriteZip(&buf, signer, "alice"); err != nil {
+		t.Fatalf("WriteZip() error = %v", err)
+	}
+	report := buf.Bytes()
+
+	manifest, err := Verify(report, signer.PublicKey())
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if manifest.GeneratedBy != "alice" || len(manifest.Files) != 7 {
+		t.Errorf("manifest = %+v", manifest)
+	}
+
+	checks := map[string]struct {
+		rows int
+		want string
+	}{
+		"users.csv":            {2, "u2,bob,"},
+		"role_assignments.csv": {3, "u2,bob,tenant,default,tenant-admin"},
+		"category_acls.csv":    {1, "c1,Finance,default,u2,bob,approved"},
+		"app_visibility.csv":   {1, "ledger,Ledger,default,Finance,approved"},
+		"shares.csv":           {1, "sh1,s1,ledger,u1,alice,u2,bob,false,read_only,u1,"},
+		"api_keys.csv":         {1, "k1,u2,bob,ci,srt_abc,apps:read,"},
+		"settings.csv":         {2, "allow_registration,false"},
+	}
+	for name, c := range checks {
+		rows := readCSV(t, report, name)
+		if len(rows)-1 != c.rows {
+			t.Errorf("%s has %d rows, want %d: %v", name, len(rows)-1, c.rows, rows)
+			continue
+		}
+		found := false
+		for _, row := range rows[1:] {
+			if strings.HasPrefix(strings.Join(row, ","), c.want) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s has no row starting %q: %v", name, c.want, rows)
+		}
+	}
+}
+
+func TestVerify_Tampered(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+	if err := database.CreateUser(db.User{ID: "u1", Username: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := Collect(database, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := attestation.DeriveSigner("secret")
+	var buf bytes.Buffer
+	if err := snapshot.WriteZip(&buf, signer, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Verify(buf.Bytes(), attestation.DeriveSigner("other").PublicKey()); err == nil {
+		t.Error("Verify() with another key succeeded")
+	}
+
+	// Rewrite users.csv, keeping the signed manifest
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tampered bytes.Buffer
+	zw := zip.NewWriter(&tampered)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		if f.Name == "users.csv" {
+			data = bytes.ReplaceAll(data, []byte("alice"), []byte("mallory"))
+		}
+		w, _ := zw.Create(f.Name)
+		w.Write(data)
+	}
+	zw.Close()
+	if _, err := Verify(tampered.Bytes(), signer.PublicKey()); err == nil || !strings.Contains(err.Error(), "users.csv") {
+		t.Errorf("Verify() of a tampered report error = %v, want users.csv mismatch", err)
+	}
+}
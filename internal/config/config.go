@@ -5,12 +5,18 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
+	"net/mail"
+	"net/netip"
 	"net/url"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/rjsadow/sortie/internal/ssrf"
 )
 
 // Config holds all application configuration.
@@ -19,6 +25,17 @@ type Config struct {
 	Port int
 	DB   string // SQLite file path (backward compat, maps to DBPath)
 	Seed string
+	// Bootstrap is the path of a YAML file with the categories, default
+	// tenant settings, and apps a fresh install is created with
+	Bootstrap string
+
+	// Preflight is how startup treats failed preflight checks: "warn" logs
+	// them, "strict" exits, and "off" skips the checks
+	Preflight string
+
+	// StartupTimeout is how long startup retries the database and
+	// Kubernetes API before giving up (0 = retry until they are reachable)
+	StartupTimeout time.Duration
 
 	// Database configuration
 	DBType     string // "sqlite" (default) or "postgres"
@@ -30,6 +47,9 @@ type Config struct {
 	DBUser     string // PostgreSQL user
 	DBPassword string // PostgreSQL password
 	DBSSLMode  string // PostgreSQL SSL mode (default: "disable")
+	// DBSingleWriter serializes the server's SQLite writes so they never
+	// contend for the database lock
+	DBSingleWriter bool
 
 	// Branding configuration
 	BrandingConfigPath string
@@ -38,17 +58,56 @@ type Config struct {
 	SecondaryColor     string
 	TenantName         string
 
+	// Branding asset storage (uploaded logos, favicons, backgrounds). The
+	// "s3" backend uses the recording S3 bucket and credentials.
+	BrandingStorageBackend string // "local" or "s3"
+	BrandingStoragePath    string // Local storage path for branding assets
+	BrandingS3Prefix       string // Key prefix within the recording bucket
+
+	// Encryption at rest for recordings and branding assets. Vault
+	// connection settings are shared with the vault secrets provider.
+	StorageEncryption             string // "none", "local", or "vault"
+	StorageEncryptionKeys         string // Master keys for "local": "id:base64key,..." with the current key first
+	StorageEncryptionTransitKey   string // Vault transit key name for "vault"
+	StorageEncryptionTransitMount string // Vault transit engine mount path
+	// StorageEncryptionAllowPlaintext serves objects stored before
+	// encryption was enabled, which are otherwise refused, until
+	// rotate-storage-keys has encrypted them.
+	StorageEncryptionAllowPlaintext bool
+
 	// Kubernetes configuration
 	Namespace          string
 	Kubeconfig         string
 	VNCSidecarImage      string
 	BrowserSidecarImage  string
 	GuacdSidecarImage    string
+	K8sQPS               float64 // Client-side Kubernetes API request rate
+	K8sBurst             int     // Kubernetes API requests allowed above K8sQPS in a burst
+	CapacityCheck        bool    // Check launches against node allocatable resources and ResourceQuotas
+	// RegistryMirrors maps registry hosts to the mirrors session and
+	// sidecar images are pulled from instead, for clusters that cannot
+	// reach public registries
+	RegistryMirrors map[string]string
 
 	// Session configuration
 	SessionTimeout         time.Duration
 	SessionCleanupInterval time.Duration
 	PodReadyTimeout        time.Duration
+	ShutdownTimeout        time.Duration // How long shutdown waits for launches in flight
+	SessionMaxRestarts     int           // Container restarts after which a running session fails (0 = never)
+	SessionMaxReschedules  int           // Replacement pods tried for an evicted session before it fails (0 = fail at once)
+	SessionRetentionDays   int  // Days to keep ended sessions before archiving (0 = keep forever)
+	LeaderElection         bool // Run maintenance loops only on the replica holding the leader lease
+
+	// Session hostnames: web_proxy sessions get their own subdomain of
+	// SessionDomain, served through a per-session Ingress (empty = off)
+	SessionDomain             string
+	SessionTLSSecret          string            // Wildcard certificate Secret for *.SessionDomain (empty = per-session Secrets)
+	SessionIngressClass       string            // IngressClass of session Ingresses (empty = cluster default)
+	SessionIngressAnnotations map[string]string // Annotations added to session Ingresses
+	SessionIngressService     string            // "name:port" of the Service session Ingresses route to
+	SessionRouting            string            // "proxy" (through the server) or "direct" (to session pods)
+	SessionAuthURL            string            // Forward-auth URL for direct routes (empty = the server's Service)
 
 	// JWT Authentication configuration
 	JWTSecret            string
@@ -58,6 +117,10 @@ type Config struct {
 	AdminPassword        string
 	AllowRegistration    bool
 
+	// AttestationKey is a base64-encoded Ed25519 seed for signing session
+	// attestation reports. Empty derives the key from JWTSecret.
+	AttestationKey string
+
 	// OIDC/SSO configuration
 	OIDCIssuer       string
 	OIDCClientID     string
@@ -65,16 +128,80 @@ type Config struct {
 	OIDCRedirectURL  string
 	OIDCScopes       string
 
+	// Passkey (WebAuthn) relying party ID: the domain passkeys are bound
+	// to, and the exact page origins allowed to use them. Passkeys can
+	// only be enabled, with the allow_passkeys setting, once both are set.
+	WebAuthnRPID    string
+	WebAuthnOrigins []string
+
+	// Outgoing email over SMTP, used for password reset links. An empty
+	// SMTPHost disables email and password reset.
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string // Sender address, optionally with a display name
+	SMTPTLS      string // "starttls", "tls" (implicit) or "none"
+
+	// PublicURL is the address users reach Sortie at, for links in email.
+	// Links are never built from the request's Host header, which a client
+	// could forge.
+	PublicURL string
+
+	// OutboundAllowlist lists internal destinations (IPs, CIDR ranges,
+	// host names and "*.domain" wildcards, comma-separated) that outbound
+	// requests to admin-supplied URLs may reach. Loopback, private and
+	// link-local addresses are refused otherwise.
+	OutboundAllowlist string
+
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For and
+	// X-Real-Ip headers are believed. Requests from anywhere else are from
+	// their connection's address.
+	TrustedProxies []netip.Prefix
+
+	// Offline refuses every outbound connection to the internet, for
+	// air-gapped deployments. Internal addresses and OutboundAllowlist
+	// destinations stay reachable, and the update check is off.
+	Offline bool
+
 	// File transfer configuration
-	MaxUploadSize int64 // Maximum upload file size in bytes
+	MaxUploadSize             int64 // Maximum upload file size in bytes
+	FileTransferMaxConcurrent int   // Concurrent uploads and downloads per user (0 = unlimited)
+	FileTransferBandwidthKBps int   // Upload and download bandwidth per user in KiB/s (0 = unlimited)
+
+	// Upload policy, as comma-separated lists (empty = no restriction)
+	FileUploadAllowedExtensions string
+	FileUploadBlockedExtensions string
+	FileUploadAllowedTypes      string
+	FileUploadBlockedTypes      string
 
 	// Gateway configuration
-	GatewayRateLimit float64 // Requests per second per IP (0 = disabled)
-	GatewayBurst     int     // Maximum burst size for rate limiter
+	GatewayRateLimit        float64 // Requests per second per IP (0 = disabled)
+	GatewayBurst            int     // Maximum burst size for rate limiter
+	GatewayCompressionLevel int     // permessage-deflate level for VNC streams, 1-9 (0 = disabled)
+	// GatewayAllowedOrigins are the browser origins besides the one a
+	// stream was requested at that may open it ("*" = any)
+	GatewayAllowedOrigins []string
+	GatewayMaxMessageSize int64         // Largest message a stream client may send, in bytes (0 = unlimited)
+	GatewayIdleTimeout    time.Duration // How long a stream client may send nothing (0 = disabled)
+
+	// API rate limits: login and registration are limited per IP and per
+	// username, session creation per IP and per user
+	AuthRateLimit    float64 // Requests per minute (0 = disabled)
+	AuthRateBurst    int     // Maximum burst size
+	SessionRateLimit float64 // Requests per minute (0 = disabled)
+	SessionRateBurst int     // Maximum burst size
 
 	// Resource quota configuration
 	MaxSessionsPerUser int    // Maximum concurrent sessions per user (0 = unlimited)
 	MaxGlobalSessions  int    // Maximum concurrent sessions globally (0 = unlimited)
+
+	// Burst allowance above MaxSessionsPerUser, which becomes a soft limit
+	// when set. Users may run up to MaxSessionsPerUserBurst sessions for a
+	// total of SessionBurstDuration before time under the limit pays it back.
+	MaxSessionsPerUserBurst int           // 0 = disabled
+	SessionBurstDuration    time.Duration // Burst time allowance per user
+
 	DefaultCPURequest  string // Default CPU request for sessions (e.g., "500m")
 	DefaultCPULimit    string // Default CPU limit for sessions (e.g., "2")
 	DefaultMemRequest  string // Default memory request for sessions (e.g., "512Mi")
@@ -91,12 +218,14 @@ type Config struct {
 	RecordingStoragePath   string // Local storage path for recordings
 	RecordingMaxSizeMB     int    // Maximum recording upload size in MB
 	RecordingRetentionDays int    // Days to retain recordings (0 = keep forever)
+	RecordingRepairMinutes int    // Minutes without a chunk before an unfinished recording is finalized (0 = never)
 	RecordingS3Bucket   string // S3 bucket name
 	RecordingS3Region   string // AWS region
 	RecordingS3Endpoint string // Custom endpoint for MinIO/self-hosted S3
 	RecordingS3Prefix      string // Key prefix within bucket
 	RecordingS3AccessKeyID     string // Explicit AWS access key ID (optional)
 	RecordingS3SecretAccessKey string // Explicit AWS secret access key (optional)
+	RecordingS3PresignMinutes  int    // Lifetime of direct S3 download links for recordings (0 = serve through Sortie)
 
 	// Billing/metering configuration
 	BillingEnabled        bool          // Enable metering event collection
@@ -104,9 +233,42 @@ type Config struct {
 	BillingWebhookURL     string        // Webhook URL for billing export (when exporter=webhook)
 	BillingExportInterval time.Duration // How often to export metering events
 
+	// Cost reports
+	CostCPUCoreHour   float64 // Cost of one CPU core requested for an hour
+	CostMemoryGiBHour float64 // Cost of one GiB of memory requested for an hour
+	CostCurrency      string  // Currency the costs are in, shown in reports
+
+	// Tracing configuration, from the standard OpenTelemetry variables. The
+	// exporter reads its endpoint, headers, and timeout, and the SDK its
+	// sampler, from the other OTEL_* variables directly.
+	TracesExporter  string // OTEL_TRACES_EXPORTER: "none", "otlp", or "console"
+	OTLPProtocol    string // OTEL_EXPORTER_OTLP_[TRACES_]PROTOCOL: "http/protobuf" or "grpc"
+	OTelServiceName string // OTEL_SERVICE_NAME
+
 	// Session queueing configuration
 	QueueMaxSize      int           // Max queued requests when at capacity (0 = no queueing)
 	QueueTimeout      time.Duration // Per-request queue wait timeout
+
+	// Inactive account policy
+	InactiveUserDisableDays int    // Disable accounts with no login for this many days (0 = never)
+	InactiveUserDeleteDays  int    // Delete accounts with no login for this many days (0 = never)
+	InactiveUserWebhookURL  string // Optional webhook notified of disabled/deleted accounts
+
+	// Health history
+	HealthWebhookURL string // Optional webhook notified of component health transitions
+
+	// App SLOs
+	SLOWebhookURL string // Optional webhook notified of SLO burn rate alerts
+
+	// API usage metering
+	APIUsageAlertThreshold int64  // Requests per user or API token per hour that raise an alert (0 = disabled)
+	APIUsageWebhookURL     string // Optional webhook notified of API over-use alerts
+
+	// Update check. Admins can override UpdateCheck and UpdateChannel with
+	// the update_check and update_channel settings.
+	UpdateCheck    bool   // Periodically compare the running version with the latest release
+	UpdateChannel  string // "stable" or "prerelease"
+	UpdateCheckURL string // Release metadata, in the GitHub releases API format
 }
 
 // ValidationError represents a configuration validation error.
@@ -136,11 +298,17 @@ func (e ValidationErrors) Error() string {
 // Default values
 const (
 	DefaultPort                   = 8080
+	DefaultPreflight              = "warn"
 	DefaultDBPath                 = "sortie.db"
 	DefaultDBType                 = "sqlite"
 	DefaultDBPort                 = 5432
 	DefaultDBSSLMode              = "disable"
 	DefaultBrandingConfigPath     = "branding.json"
+	DefaultBrandingStorageBackend = "local"
+	DefaultBrandingStoragePath    = "/data/branding"
+	DefaultBrandingS3Prefix       = "branding/"
+	DefaultStorageEncryption      = "none"
+	DefaultStorageTransitMount    = "transit"
 	DefaultPrimaryColor           = "#1F2A3C"
 	DefaultSecondaryColor         = "#2B3445"
 	DefaultTenantName             = "Sortie"
@@ -148,19 +316,45 @@ const (
 	DefaultVNCSidecarImage        = "ghcr.io/rjsadow/sortie-vnc-sidecar:latest"
 	DefaultBrowserSidecarImage    = "ghcr.io/rjsadow/sortie-browser-sidecar:latest"
 	DefaultGuacdSidecarImage      = "guacamole/guacd:1.6.0"
+	DefaultK8sQPS                 = float64(20)
+	DefaultK8sBurst               = 40
+	DefaultSessionIngressService  = "sortie:80"
+	DefaultSessionRouting         = SessionRoutingProxy
 	DefaultSessionTimeout         = 2 * time.Hour
 	DefaultSessionCleanupInterval = 5 * time.Minute
 	DefaultPodReadyTimeout        = 2 * time.Minute
+	DefaultShutdownTimeout        = 25 * time.Second
+	DefaultSessionMaxRestarts     = 5
+	DefaultSessionMaxReschedules  = 3
 	DefaultJWTAccessExpiry        = 15 * time.Minute
 	DefaultJWTRefreshExpiry       = 24 * time.Hour
 	DefaultAdminUsername          = "admin"
+	DefaultSMTPPort               = 587
+	DefaultSMTPTLS                = "starttls"
 	DefaultMaxUploadSize         = int64(100 * 1024 * 1024) // 100MB
 	DefaultGatewayRateLimit      = float64(10)              // 10 requests/sec per IP
 	DefaultGatewayBurst          = 20                       // burst of 20
+	DefaultGatewayCompression    = 1                        // fastest deflate level
+	DefaultGatewayMaxMessageSize = int64(1024 * 1024)       // 1MB
+	DefaultGatewayIdleTimeout    = 60 * time.Second
+	DefaultAuthRateLimit         = float64(10)              // 10 requests/min per IP or username
+	DefaultAuthRateBurst         = 5
+	DefaultSessionRateLimit      = float64(30)              // 30 requests/min per IP or user
+	DefaultSessionRateBurst      = 10
+	DefaultAPIUsageThreshold     = int64(10000)             // 10k requests/hour per user or API token
 	DefaultMaxSessionsPerUser    = 5
 	DefaultMaxGlobalSessions     = 100
+	DefaultSessionBurstDuration  = 30 * time.Minute
 	DefaultBillingExporter       = "log"
 	DefaultBillingExportInterval = 5 * time.Minute
+	DefaultCostCPUCoreHour       = 0.04  // Roughly on-demand cloud pricing
+	DefaultCostMemoryGiBHour     = 0.005 // Roughly on-demand cloud pricing
+	DefaultCostCurrency          = "USD"
+	DefaultTracesExporter        = "none"
+	DefaultOTLPProtocol          = "http/protobuf"
+	DefaultOTelServiceName       = "sortie"
+	DefaultUpdateChannel         = "stable"
+	DefaultUpdateCheckURL        = "https://api.github.com/repos/rjsadow/sortie/releases"
 	DefaultDefaultCPURequest     = "500m"
 	DefaultDefaultCPULimit       = "2"
 	DefaultDefaultMemRequest     = "512Mi"
@@ -170,6 +364,7 @@ const (
 	DefaultRecordingStorageBackend = "local"
 	DefaultRecordingStoragePath    = "/data/recordings"
 	DefaultRecordingMaxSizeMB      = 500
+	DefaultRecordingRepairMinutes  = 10
 	DefaultRecordingS3Region       = "us-east-1"
 	DefaultRecordingS3Prefix       = "recordings/"
 )
@@ -180,8 +375,9 @@ const (
 func Load() (*Config, error) {
 	cfg := &Config{
 		// Server defaults
-		Port: DefaultPort,
-		DB:   DefaultDBPath,
+		Port:      DefaultPort,
+		DB:        DefaultDBPath,
+		Preflight: DefaultPreflight,
 
 		// Database defaults
 		DBType:    DefaultDBType,
@@ -194,33 +390,72 @@ func Load() (*Config, error) {
 		SecondaryColor:     DefaultSecondaryColor,
 		TenantName:         DefaultTenantName,
 
+		BrandingStorageBackend: DefaultBrandingStorageBackend,
+		BrandingStoragePath:    DefaultBrandingStoragePath,
+		BrandingS3Prefix:       DefaultBrandingS3Prefix,
+
+		StorageEncryption:             DefaultStorageEncryption,
+		StorageEncryptionTransitMount: DefaultStorageTransitMount,
+
 		// Kubernetes defaults
 		Namespace:         DefaultNamespace,
 		VNCSidecarImage:     DefaultVNCSidecarImage,
 		BrowserSidecarImage: DefaultBrowserSidecarImage,
 		GuacdSidecarImage:   DefaultGuacdSidecarImage,
+		K8sQPS:              DefaultK8sQPS,
+		K8sBurst:            DefaultK8sBurst,
+		CapacityCheck:       true,
 
 		// Session defaults
 		SessionTimeout:         DefaultSessionTimeout,
 		SessionCleanupInterval: DefaultSessionCleanupInterval,
 		PodReadyTimeout:        DefaultPodReadyTimeout,
+		ShutdownTimeout:        DefaultShutdownTimeout,
+		SessionMaxRestarts:     DefaultSessionMaxRestarts,
+		SessionMaxReschedules:  DefaultSessionMaxReschedules,
+		LeaderElection:         true,
+		SessionIngressService:  DefaultSessionIngressService,
+		SessionRouting:         DefaultSessionRouting,
 
 		// JWT defaults
 		JWTAccessExpiry:  DefaultJWTAccessExpiry,
 		JWTRefreshExpiry: DefaultJWTRefreshExpiry,
 		AdminUsername:    DefaultAdminUsername,
 
+		// Email defaults
+		SMTPPort: DefaultSMTPPort,
+		SMTPTLS:  DefaultSMTPTLS,
+
 		// File transfer defaults
 		MaxUploadSize: DefaultMaxUploadSize,
 
 		// Gateway defaults
-		GatewayRateLimit: DefaultGatewayRateLimit,
-		GatewayBurst:     DefaultGatewayBurst,
+		GatewayRateLimit:        DefaultGatewayRateLimit,
+		GatewayBurst:            DefaultGatewayBurst,
+		GatewayCompressionLevel: DefaultGatewayCompression,
+		GatewayMaxMessageSize:   DefaultGatewayMaxMessageSize,
+		GatewayIdleTimeout:      DefaultGatewayIdleTimeout,
+
+		// API rate limit defaults
+		AuthRateLimit:    DefaultAuthRateLimit,
+		AuthRateBurst:    DefaultAuthRateBurst,
+		SessionRateLimit: DefaultSessionRateLimit,
+		SessionRateBurst: DefaultSessionRateBurst,
+
+		// API usage defaults
+		APIUsageAlertThreshold: DefaultAPIUsageThreshold,
+
+		// Cost report defaults
+		CostCPUCoreHour:   DefaultCostCPUCoreHour,
+		CostMemoryGiBHour: DefaultCostMemoryGiBHour,
+		CostCurrency:      DefaultCostCurrency,
 
 		// Resource quota defaults
 		MaxSessionsPerUser: DefaultMaxSessionsPerUser,
 		MaxGlobalSessions:  DefaultMaxGlobalSessions,
 		DefaultCPURequest:  DefaultDefaultCPURequest,
+
+		SessionBurstDuration: DefaultSessionBurstDuration,
 		DefaultCPULimit:    DefaultDefaultCPULimit,
 		DefaultMemRequest:  DefaultDefaultMemRequest,
 		DefaultMemLimit:    DefaultDefaultMemLimit,
@@ -229,12 +464,22 @@ func Load() (*Config, error) {
 		RecordingStorageBackend: DefaultRecordingStorageBackend,
 		RecordingStoragePath:    DefaultRecordingStoragePath,
 		RecordingMaxSizeMB:      DefaultRecordingMaxSizeMB,
+		RecordingRepairMinutes:  DefaultRecordingRepairMinutes,
 		RecordingS3Region:       DefaultRecordingS3Region,
 		RecordingS3Prefix:       DefaultRecordingS3Prefix,
 
 		// Queue defaults
 		QueueMaxSize: DefaultQueueMaxSize,
 		QueueTimeout: DefaultQueueTimeout,
+
+		// Tracing defaults
+		TracesExporter:  DefaultTracesExporter,
+		OTLPProtocol:    DefaultOTLPProtocol,
+		OTelServiceName: DefaultOTelServiceName,
+
+		// Update check defaults
+		UpdateChannel:  DefaultUpdateChannel,
+		UpdateCheckURL: DefaultUpdateCheckURL,
 	}
 
 	// Load from environment variables
@@ -275,6 +520,31 @@ func (c *Config) loadFromEnv() error {
 		c.Seed = v
 	}
 
+	if v := os.Getenv("SORTIE_BOOTSTRAP"); v != "" {
+		c.Bootstrap = v
+	}
+
+	if v := os.Getenv("SORTIE_PREFLIGHT"); v != "" {
+		c.Preflight = strings.ToLower(v)
+	}
+
+	if v := os.Getenv("SORTIE_STARTUP_TIMEOUT"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_STARTUP_TIMEOUT",
+				Message: fmt.Sprintf("invalid timeout: %q (must be an integer representing seconds)", v),
+			})
+		} else if seconds < 0 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_STARTUP_TIMEOUT",
+				Message: fmt.Sprintf("timeout must not be negative: %d", seconds),
+			})
+		} else {
+			c.StartupTimeout = time.Duration(seconds) * time.Second
+		}
+	}
+
 	// Database configuration
 	if v := os.Getenv("SORTIE_DB_TYPE"); v != "" {
 		c.DBType = v
@@ -282,6 +552,9 @@ func (c *Config) loadFromEnv() error {
 	if v := os.Getenv("SORTIE_DB_DSN"); v != "" {
 		c.DBDSN = v
 	}
+	if v := os.Getenv("SORTIE_DB_SINGLE_WRITER"); v != "" {
+		c.DBSingleWriter = strings.EqualFold(v, "true") || v == "1"
+	}
 	if v := os.Getenv("SORTIE_DB_HOST"); v != "" {
 		c.DBHost = v
 	}
@@ -333,6 +606,38 @@ func (c *Config) loadFromEnv() error {
 		c.TenantName = v
 	}
 
+	if v := os.Getenv("SORTIE_BRANDING_STORAGE_BACKEND"); v != "" {
+		c.BrandingStorageBackend = v
+	}
+
+	if v := os.Getenv("SORTIE_BRANDING_STORAGE_PATH"); v != "" {
+		c.BrandingStoragePath = v
+	}
+
+	if v := os.Getenv("SORTIE_BRANDING_S3_PREFIX"); v != "" {
+		c.BrandingS3Prefix = v
+	}
+
+	if v := os.Getenv("SORTIE_STORAGE_ENCRYPTION"); v != "" {
+		c.StorageEncryption = strings.ToLower(v)
+	}
+
+	if v := os.Getenv("SORTIE_STORAGE_ENCRYPTION_KEYS"); v != "" {
+		c.StorageEncryptionKeys = v
+	}
+
+	if v := os.Getenv("SORTIE_STORAGE_ENCRYPTION_TRANSIT_KEY"); v != "" {
+		c.StorageEncryptionTransitKey = v
+	}
+
+	if v := os.Getenv("SORTIE_STORAGE_ENCRYPTION_TRANSIT_MOUNT"); v != "" {
+		c.StorageEncryptionTransitMount = v
+	}
+
+	if v := os.Getenv("SORTIE_STORAGE_ENCRYPTION_ALLOW_PLAINTEXT"); v != "" {
+		c.StorageEncryptionAllowPlaintext = strings.EqualFold(v, "true") || v == "1"
+	}
+
 	// Kubernetes configuration
 	if v := os.Getenv("SORTIE_NAMESPACE"); v != "" {
 		c.Namespace = v
@@ -354,6 +659,56 @@ func (c *Config) loadFromEnv() error {
 		c.GuacdSidecarImage = v
 	}
 
+	if v := os.Getenv("SORTIE_K8S_QPS"); v != "" {
+		qps, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_K8S_QPS",
+				Message: fmt.Sprintf("invalid rate: %q (must be a number)", v),
+			})
+		} else if qps <= 0 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_K8S_QPS",
+				Message: fmt.Sprintf("rate must be positive: %v", qps),
+			})
+		} else {
+			c.K8sQPS = qps
+		}
+	}
+
+	if v := os.Getenv("SORTIE_CAPACITY_CHECK"); v != "" {
+		c.CapacityCheck = !strings.EqualFold(v, "false") && v != "0"
+	}
+
+	if v := os.Getenv("SORTIE_REGISTRY_MIRRORS"); v != "" {
+		mirrors, err := parseRegistryMirrors(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_REGISTRY_MIRRORS",
+				Message: err.Error(),
+			})
+		} else {
+			c.RegistryMirrors = mirrors
+		}
+	}
+
+	if v := os.Getenv("SORTIE_K8S_BURST"); v != "" {
+		b, err := strconv.Atoi(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_K8S_BURST",
+				Message: fmt.Sprintf("invalid burst: %q (must be an integer)", v),
+			})
+		} else if b < 1 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_K8S_BURST",
+				Message: fmt.Sprintf("burst must be positive: %d", b),
+			})
+		} else {
+			c.K8sBurst = b
+		}
+	}
+
 	// Session configuration
 	if v := os.Getenv("SORTIE_SESSION_TIMEOUT"); v != "" {
 		minutes, err := strconv.Atoi(v)
@@ -389,6 +744,63 @@ func (c *Config) loadFromEnv() error {
 		}
 	}
 
+	if v := os.Getenv("SORTIE_SESSION_RETENTION_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_SESSION_RETENTION_DAYS",
+				Message: fmt.Sprintf("invalid value: %q (must be an integer)", v),
+			})
+		} else if n < 0 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_SESSION_RETENTION_DAYS",
+				Message: fmt.Sprintf("value must be non-negative: %d", n),
+			})
+		} else {
+			c.SessionRetentionDays = n
+		}
+	}
+
+	if v := os.Getenv("SORTIE_SESSION_DOMAIN"); v != "" {
+		c.SessionDomain = strings.ToLower(strings.TrimSuffix(v, "."))
+	}
+
+	if v := os.Getenv("SORTIE_SESSION_TLS_SECRET"); v != "" {
+		c.SessionTLSSecret = v
+	}
+
+	if v := os.Getenv("SORTIE_SESSION_INGRESS_CLASS"); v != "" {
+		c.SessionIngressClass = v
+	}
+
+	if v := os.Getenv("SORTIE_SESSION_INGRESS_ANNOTATIONS"); v != "" {
+		annotations, err := parseKeyValues(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_SESSION_INGRESS_ANNOTATIONS",
+				Message: err.Error(),
+			})
+		} else {
+			c.SessionIngressAnnotations = annotations
+		}
+	}
+
+	if v := os.Getenv("SORTIE_SESSION_INGRESS_SERVICE"); v != "" {
+		c.SessionIngressService = v
+	}
+
+	if v := os.Getenv("SORTIE_SESSION_ROUTING"); v != "" {
+		c.SessionRouting = strings.ToLower(v)
+	}
+
+	if v := os.Getenv("SORTIE_SESSION_AUTH_URL"); v != "" {
+		c.SessionAuthURL = v
+	}
+
+	if v := os.Getenv("SORTIE_LEADER_ELECTION"); v != "" {
+		c.LeaderElection = !strings.EqualFold(v, "false") && v != "0"
+	}
+
 	if v := os.Getenv("SORTIE_POD_READY_TIMEOUT"); v != "" {
 		seconds, err := strconv.Atoi(v)
 		if err != nil {
@@ -406,11 +818,66 @@ func (c *Config) loadFromEnv() error {
 		}
 	}
 
+	if v := os.Getenv("SORTIE_SESSION_MAX_RESTARTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_SESSION_MAX_RESTARTS",
+				Message: fmt.Sprintf("invalid restart count: %q (must be an integer)", v),
+			})
+		} else if n < 0 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_SESSION_MAX_RESTARTS",
+				Message: fmt.Sprintf("restart count must not be negative: %d", n),
+			})
+		} else {
+			c.SessionMaxRestarts = n
+		}
+	}
+
+	if v := os.Getenv("SORTIE_SESSION_MAX_RESCHEDULES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_SESSION_MAX_RESCHEDULES",
+				Message: fmt.Sprintf("invalid reschedule count: %q (must be an integer)", v),
+			})
+		} else if n < 0 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_SESSION_MAX_RESCHEDULES",
+				Message: fmt.Sprintf("reschedule count must not be negative: %d", n),
+			})
+		} else {
+			c.SessionMaxReschedules = n
+		}
+	}
+
+	if v := os.Getenv("SORTIE_SHUTDOWN_TIMEOUT"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_SHUTDOWN_TIMEOUT",
+				Message: fmt.Sprintf("invalid timeout: %q (must be an integer representing seconds)", v),
+			})
+		} else if seconds <= 0 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_SHUTDOWN_TIMEOUT",
+				Message: fmt.Sprintf("timeout must be positive: %d", seconds),
+			})
+		} else {
+			c.ShutdownTimeout = time.Duration(seconds) * time.Second
+		}
+	}
+
 	// JWT configuration
 	if v := os.Getenv("SORTIE_JWT_SECRET"); v != "" {
 		c.JWTSecret = v
 	}
 
+	if v := os.Getenv("SORTIE_ATTESTATION_KEY"); v != "" {
+		c.AttestationKey = v
+	}
+
 	if v := os.Getenv("SORTIE_JWT_ACCESS_EXPIRY"); v != "" {
 		minutes, err := strconv.Atoi(v)
 		if err != nil {
@@ -474,6 +941,69 @@ func (c *Config) loadFromEnv() error {
 		c.OIDCScopes = v
 	}
 
+	if v := os.Getenv("SORTIE_WEBAUTHN_RP_ID"); v != "" {
+		c.WebAuthnRPID = strings.ToLower(strings.TrimSuffix(v, "."))
+	}
+	if v := os.Getenv("SORTIE_WEBAUTHN_ORIGINS"); v != "" {
+		origins, err := parseOrigins(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_WEBAUTHN_ORIGINS",
+				Message: err.Error(),
+			})
+		} else {
+			c.WebAuthnOrigins = origins
+		}
+	}
+
+	// Email configuration
+	if v := os.Getenv("SORTIE_SMTP_HOST"); v != "" {
+		c.SMTPHost = v
+	}
+	if v := os.Getenv("SORTIE_SMTP_PORT"); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_SMTP_PORT",
+				Message: fmt.Sprintf("invalid port number: %q (must be an integer)", v),
+			})
+		} else {
+			c.SMTPPort = port
+		}
+	}
+	if v := os.Getenv("SORTIE_SMTP_USERNAME"); v != "" {
+		c.SMTPUsername = v
+	}
+	if v := os.Getenv("SORTIE_SMTP_PASSWORD"); v != "" {
+		c.SMTPPassword = v
+	}
+	if v := os.Getenv("SORTIE_SMTP_FROM"); v != "" {
+		c.SMTPFrom = v
+	}
+	if v := os.Getenv("SORTIE_SMTP_TLS"); v != "" {
+		c.SMTPTLS = strings.ToLower(v)
+	}
+	if v := os.Getenv("SORTIE_PUBLIC_URL"); v != "" {
+		c.PublicURL = strings.TrimSuffix(v, "/")
+	}
+	if v := os.Getenv("SORTIE_OUTBOUND_ALLOWLIST"); v != "" {
+		c.OutboundAllowlist = v
+	}
+	if v := os.Getenv("SORTIE_TRUSTED_PROXIES"); v != "" {
+		proxies, err := parsePrefixes(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_TRUSTED_PROXIES",
+				Message: err.Error(),
+			})
+		} else {
+			c.TrustedProxies = proxies
+		}
+	}
+	if v := os.Getenv("SORTIE_OFFLINE"); v != "" {
+		c.Offline = strings.EqualFold(v, "true") || v == "1"
+	}
+
 	// File transfer configuration
 	if v := os.Getenv("SORTIE_MAX_UPLOAD_SIZE"); v != "" {
 		size, err := strconv.ParseInt(v, 10, 64)
@@ -492,6 +1022,53 @@ func (c *Config) loadFromEnv() error {
 		}
 	}
 
+	if v := os.Getenv("SORTIE_FILE_TRANSFER_MAX_CONCURRENT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_FILE_TRANSFER_MAX_CONCURRENT",
+				Message: fmt.Sprintf("invalid value: %q (must be an integer)", v),
+			})
+		} else if n < 0 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_FILE_TRANSFER_MAX_CONCURRENT",
+				Message: fmt.Sprintf("value must be non-negative: %d", n),
+			})
+		} else {
+			c.FileTransferMaxConcurrent = n
+		}
+	}
+
+	if v := os.Getenv("SORTIE_FILE_TRANSFER_BANDWIDTH_KBPS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_FILE_TRANSFER_BANDWIDTH_KBPS",
+				Message: fmt.Sprintf("invalid value: %q (must be an integer)", v),
+			})
+		} else if n < 0 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_FILE_TRANSFER_BANDWIDTH_KBPS",
+				Message: fmt.Sprintf("value must be non-negative: %d", n),
+			})
+		} else {
+			c.FileTransferBandwidthKBps = n
+		}
+	}
+
+	if v := os.Getenv("SORTIE_FILE_UPLOAD_ALLOWED_EXTENSIONS"); v != "" {
+		c.FileUploadAllowedExtensions = v
+	}
+	if v := os.Getenv("SORTIE_FILE_UPLOAD_BLOCKED_EXTENSIONS"); v != "" {
+		c.FileUploadBlockedExtensions = v
+	}
+	if v := os.Getenv("SORTIE_FILE_UPLOAD_ALLOWED_TYPES"); v != "" {
+		c.FileUploadAllowedTypes = v
+	}
+	if v := os.Getenv("SORTIE_FILE_UPLOAD_BLOCKED_TYPES"); v != "" {
+		c.FileUploadBlockedTypes = v
+	}
+
 	// Gateway configuration
 	if v := os.Getenv("SORTIE_GATEWAY_RATE_LIMIT"); v != "" {
 		rl, err := strconv.ParseFloat(v, 64)
@@ -527,53 +1104,219 @@ func (c *Config) loadFromEnv() error {
 		}
 	}
 
-	// Resource quota configuration
-	if v := os.Getenv("SORTIE_MAX_SESSIONS_PER_USER"); v != "" {
-		n, err := strconv.Atoi(v)
+	// API rate limits
+	if v := os.Getenv("SORTIE_AUTH_RATE_LIMIT"); v != "" {
+		rl, err := strconv.ParseFloat(v, 64)
 		if err != nil {
 			parseErrors = append(parseErrors, ValidationError{
-				Field:   "SORTIE_MAX_SESSIONS_PER_USER",
-				Message: fmt.Sprintf("invalid value: %q (must be an integer)", v),
+				Field:   "SORTIE_AUTH_RATE_LIMIT",
+				Message: fmt.Sprintf("invalid rate: %q (must be a number)", v),
 			})
-		} else if n < 0 {
+		} else if rl < 0 {
 			parseErrors = append(parseErrors, ValidationError{
-				Field:   "SORTIE_MAX_SESSIONS_PER_USER",
-				Message: fmt.Sprintf("value must be non-negative: %d", n),
+				Field:   "SORTIE_AUTH_RATE_LIMIT",
+				Message: fmt.Sprintf("rate must be non-negative: %v", rl),
 			})
 		} else {
-			c.MaxSessionsPerUser = n
+			c.AuthRateLimit = rl
 		}
 	}
 
-	if v := os.Getenv("SORTIE_MAX_GLOBAL_SESSIONS"); v != "" {
-		n, err := strconv.Atoi(v)
+	if v := os.Getenv("SORTIE_AUTH_RATE_BURST"); v != "" {
+		b, err := strconv.Atoi(v)
 		if err != nil {
 			parseErrors = append(parseErrors, ValidationError{
-				Field:   "SORTIE_MAX_GLOBAL_SESSIONS",
-				Message: fmt.Sprintf("invalid value: %q (must be an integer)", v),
+				Field:   "SORTIE_AUTH_RATE_BURST",
+				Message: fmt.Sprintf("invalid burst: %q (must be an integer)", v),
 			})
-		} else if n < 0 {
+		} else if b < 1 {
 			parseErrors = append(parseErrors, ValidationError{
-				Field:   "SORTIE_MAX_GLOBAL_SESSIONS",
-				Message: fmt.Sprintf("value must be non-negative: %d", n),
+				Field:   "SORTIE_AUTH_RATE_BURST",
+				Message: fmt.Sprintf("burst must be positive: %d", b),
 			})
 		} else {
-			c.MaxGlobalSessions = n
+			c.AuthRateBurst = b
 		}
 	}
 
-	if v := os.Getenv("SORTIE_DEFAULT_CPU_REQUEST"); v != "" {
-		c.DefaultCPURequest = v
-	}
-
-	if v := os.Getenv("SORTIE_DEFAULT_CPU_LIMIT"); v != "" {
-		c.DefaultCPULimit = v
-	}
-
-	if v := os.Getenv("SORTIE_DEFAULT_MEM_REQUEST"); v != "" {
-		c.DefaultMemRequest = v
-	}
-
+	if v := os.Getenv("SORTIE_SESSION_RATE_LIMIT"); v != "" {
+		rl, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_SESSION_RATE_LIMIT",
+				Message: fmt.Sprintf("invalid rate: %q (must be a number)", v),
+			})
+		} else if rl < 0 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_SESSION_RATE_LIMIT",
+				Message: fmt.Sprintf("rate must be non-negative: %v", rl),
+			})
+		} else {
+			c.SessionRateLimit = rl
+		}
+	}
+
+	if v := os.Getenv("SORTIE_SESSION_RATE_BURST"); v != "" {
+		b, err := strconv.Atoi(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_SESSION_RATE_BURST",
+				Message: fmt.Sprintf("invalid burst: %q (must be an integer)", v),
+			})
+		} else if b < 1 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_SESSION_RATE_BURST",
+				Message: fmt.Sprintf("burst must be positive: %d", b),
+			})
+		} else {
+			c.SessionRateBurst = b
+		}
+	}
+
+	if v := os.Getenv("SORTIE_GATEWAY_COMPRESSION_LEVEL"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_GATEWAY_COMPRESSION_LEVEL",
+				Message: fmt.Sprintf("invalid level: %q (must be an integer)", v),
+			})
+		} else if n < 0 || n > 9 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_GATEWAY_COMPRESSION_LEVEL",
+				Message: fmt.Sprintf("level must be between 0 and 9: %d", n),
+			})
+		} else {
+			c.GatewayCompressionLevel = n
+		}
+	}
+
+	if v := os.Getenv("SORTIE_GATEWAY_ALLOWED_ORIGINS"); v != "" {
+		origins, err := parseOrigins(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_GATEWAY_ALLOWED_ORIGINS",
+				Message: err.Error(),
+			})
+		} else {
+			c.GatewayAllowedOrigins = origins
+		}
+	}
+
+	if v := os.Getenv("SORTIE_GATEWAY_MAX_MESSAGE_SIZE"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_GATEWAY_MAX_MESSAGE_SIZE",
+				Message: fmt.Sprintf("invalid size: %q (must be an integer representing bytes)", v),
+			})
+		} else if n < 0 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_GATEWAY_MAX_MESSAGE_SIZE",
+				Message: fmt.Sprintf("size must be non-negative: %d", n),
+			})
+		} else {
+			c.GatewayMaxMessageSize = n
+		}
+	}
+
+	if v := os.Getenv("SORTIE_GATEWAY_IDLE_TIMEOUT"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_GATEWAY_IDLE_TIMEOUT",
+				Message: fmt.Sprintf("invalid timeout: %q (must be an integer representing seconds)", v),
+			})
+		} else if seconds < 0 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_GATEWAY_IDLE_TIMEOUT",
+				Message: fmt.Sprintf("timeout must be non-negative: %d", seconds),
+			})
+		} else {
+			c.GatewayIdleTimeout = time.Duration(seconds) * time.Second
+		}
+	}
+
+	// Resource quota configuration
+	if v := os.Getenv("SORTIE_MAX_SESSIONS_PER_USER"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_MAX_SESSIONS_PER_USER",
+				Message: fmt.Sprintf("invalid value: %q (must be an integer)", v),
+			})
+		} else if n < 0 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_MAX_SESSIONS_PER_USER",
+				Message: fmt.Sprintf("value must be non-negative: %d", n),
+			})
+		} else {
+			c.MaxSessionsPerUser = n
+		}
+	}
+
+	if v := os.Getenv("SORTIE_MAX_GLOBAL_SESSIONS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_MAX_GLOBAL_SESSIONS",
+				Message: fmt.Sprintf("invalid value: %q (must be an integer)", v),
+			})
+		} else if n < 0 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_MAX_GLOBAL_SESSIONS",
+				Message: fmt.Sprintf("value must be non-negative: %d", n),
+			})
+		} else {
+			c.MaxGlobalSessions = n
+		}
+	}
+
+	if v := os.Getenv("SORTIE_MAX_SESSIONS_PER_USER_BURST"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_MAX_SESSIONS_PER_USER_BURST",
+				Message: fmt.Sprintf("invalid value: %q (must be an integer)", v),
+			})
+		} else if n < 0 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_MAX_SESSIONS_PER_USER_BURST",
+				Message: fmt.Sprintf("value must be non-negative: %d", n),
+			})
+		} else {
+			c.MaxSessionsPerUserBurst = n
+		}
+	}
+
+	if v := os.Getenv("SORTIE_SESSION_BURST_DURATION"); v != "" {
+		minutes, err := strconv.Atoi(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_SESSION_BURST_DURATION",
+				Message: fmt.Sprintf("invalid duration: %q (must be an integer representing minutes)", v),
+			})
+		} else if minutes <= 0 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_SESSION_BURST_DURATION",
+				Message: fmt.Sprintf("duration must be positive: %d", minutes),
+			})
+		} else {
+			c.SessionBurstDuration = time.Duration(minutes) * time.Minute
+		}
+	}
+
+	if v := os.Getenv("SORTIE_DEFAULT_CPU_REQUEST"); v != "" {
+		c.DefaultCPURequest = v
+	}
+
+	if v := os.Getenv("SORTIE_DEFAULT_CPU_LIMIT"); v != "" {
+		c.DefaultCPULimit = v
+	}
+
+	if v := os.Getenv("SORTIE_DEFAULT_MEM_REQUEST"); v != "" {
+		c.DefaultMemRequest = v
+	}
+
 	if v := os.Getenv("SORTIE_DEFAULT_MEM_LIMIT"); v != "" {
 		c.DefaultMemLimit = v
 	}
@@ -638,6 +1381,61 @@ func (c *Config) loadFromEnv() error {
 		c.BillingExportInterval = DefaultBillingExportInterval
 	}
 
+	// Cost reports
+	if v := os.Getenv("SORTIE_COST_CPU_CORE_HOUR"); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_COST_CPU_CORE_HOUR",
+				Message: fmt.Sprintf("invalid rate: %q (must be a number)", v),
+			})
+		} else if rate < 0 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_COST_CPU_CORE_HOUR",
+				Message: fmt.Sprintf("rate must be non-negative: %v", rate),
+			})
+		} else {
+			c.CostCPUCoreHour = rate
+		}
+	}
+
+	if v := os.Getenv("SORTIE_COST_MEMORY_GIB_HOUR"); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_COST_MEMORY_GIB_HOUR",
+				Message: fmt.Sprintf("invalid rate: %q (must be a number)", v),
+			})
+		} else if rate < 0 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_COST_MEMORY_GIB_HOUR",
+				Message: fmt.Sprintf("rate must be non-negative: %v", rate),
+			})
+		} else {
+			c.CostMemoryGiBHour = rate
+		}
+	}
+
+	if v := os.Getenv("SORTIE_COST_CURRENCY"); v != "" {
+		c.CostCurrency = v
+	}
+
+	// Tracing configuration
+	if v := os.Getenv("OTEL_TRACES_EXPORTER"); v != "" {
+		c.TracesExporter = strings.ToLower(strings.TrimSpace(v))
+	}
+	if v := os.Getenv("OTEL_SDK_DISABLED"); strings.EqualFold(v, "true") {
+		c.TracesExporter = "none"
+	}
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"); v != "" {
+		c.OTLPProtocol = v
+	} else if v := os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); v != "" {
+		c.OTLPProtocol = v
+	}
+	if v := os.Getenv("OTEL_SERVICE_NAME"); v != "" {
+		c.OTelServiceName = v
+	}
+
 	// Video recording configuration
 	if v := os.Getenv("SORTIE_VIDEO_RECORDING_ENABLED"); v != "" {
 		c.VideoRecordingEnabled = strings.EqualFold(v, "true") || v == "1"
@@ -685,6 +1483,23 @@ func (c *Config) loadFromEnv() error {
 		}
 	}
 
+	if v := os.Getenv("SORTIE_RECORDING_REPAIR_MINUTES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_RECORDING_REPAIR_MINUTES",
+				Message: fmt.Sprintf("invalid value: %q (must be an integer)", v),
+			})
+		} else if n < 0 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_RECORDING_REPAIR_MINUTES",
+				Message: fmt.Sprintf("value must be non-negative: %d", n),
+			})
+		} else {
+			c.RecordingRepairMinutes = n
+		}
+	}
+
 	if v := os.Getenv("SORTIE_RECORDING_S3_BUCKET"); v != "" {
 		c.RecordingS3Bucket = v
 	}
@@ -709,6 +1524,23 @@ func (c *Config) loadFromEnv() error {
 		c.RecordingS3SecretAccessKey = v
 	}
 
+	if v := os.Getenv("SORTIE_RECORDING_S3_PRESIGN_MINUTES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_RECORDING_S3_PRESIGN_MINUTES",
+				Message: fmt.Sprintf("invalid value: %q (must be an integer)", v),
+			})
+		} else if n < 0 || n > 7*24*60 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_RECORDING_S3_PRESIGN_MINUTES",
+				Message: fmt.Sprintf("value must be between 0 and 10080 (7 days): %d", n),
+			})
+		} else {
+			c.RecordingS3PresignMinutes = n
+		}
+	}
+
 	// Session queueing configuration
 	if v := os.Getenv("SORTIE_QUEUE_MAX_SIZE"); v != "" {
 		n, err := strconv.Atoi(v)
@@ -744,6 +1576,84 @@ func (c *Config) loadFromEnv() error {
 		}
 	}
 
+	if v := os.Getenv("SORTIE_INACTIVE_USER_DISABLE_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_INACTIVE_USER_DISABLE_DAYS",
+				Message: fmt.Sprintf("invalid value: %q (must be an integer)", v),
+			})
+		} else if n < 0 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_INACTIVE_USER_DISABLE_DAYS",
+				Message: fmt.Sprintf("value must be non-negative: %d", n),
+			})
+		} else {
+			c.InactiveUserDisableDays = n
+		}
+	}
+
+	if v := os.Getenv("SORTIE_INACTIVE_USER_DELETE_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_INACTIVE_USER_DELETE_DAYS",
+				Message: fmt.Sprintf("invalid value: %q (must be an integer)", v),
+			})
+		} else if n < 0 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_INACTIVE_USER_DELETE_DAYS",
+				Message: fmt.Sprintf("value must be non-negative: %d", n),
+			})
+		} else {
+			c.InactiveUserDeleteDays = n
+		}
+	}
+
+	if v := os.Getenv("SORTIE_INACTIVE_USER_WEBHOOK_URL"); v != "" {
+		c.InactiveUserWebhookURL = v
+	}
+
+	if v := os.Getenv("SORTIE_HEALTH_WEBHOOK_URL"); v != "" {
+		c.HealthWebhookURL = v
+	}
+
+	if v := os.Getenv("SORTIE_SLO_WEBHOOK_URL"); v != "" {
+		c.SLOWebhookURL = v
+	}
+
+	if v := os.Getenv("SORTIE_API_USAGE_ALERT_THRESHOLD"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_API_USAGE_ALERT_THRESHOLD",
+				Message: fmt.Sprintf("invalid value: %q (must be an integer)", v),
+			})
+		} else if n < 0 {
+			parseErrors = append(parseErrors, ValidationError{
+				Field:   "SORTIE_API_USAGE_ALERT_THRESHOLD",
+				Message: fmt.Sprintf("value must be non-negative: %d", n),
+			})
+		} else {
+			c.APIUsageAlertThreshold = n
+		}
+	}
+
+	if v := os.Getenv("SORTIE_API_USAGE_WEBHOOK_URL"); v != "" {
+		c.APIUsageWebhookURL = v
+	}
+
+	// Update check
+	if v := os.Getenv("SORTIE_UPDATE_CHECK"); v != "" {
+		c.UpdateCheck = strings.EqualFold(v, "true") || v == "1"
+	}
+	if v := os.Getenv("SORTIE_UPDATE_CHANNEL"); v != "" {
+		c.UpdateChannel = strings.ToLower(strings.TrimSpace(v))
+	}
+	if v := os.Getenv("SORTIE_UPDATE_CHECK_URL"); v != "" {
+		c.UpdateCheckURL = v
+	}
+
 	if len(parseErrors) > 0 {
 		return parseErrors
 	}
@@ -816,6 +1726,86 @@ func (c *Config) Validate() ValidationErrors {
 		})
 	}
 
+	// Validate branding asset storage backend
+	if c.BrandingStorageBackend != "" && c.BrandingStorageBackend != "local" && c.BrandingStorageBackend != "s3" {
+		errs = append(errs, ValidationError{
+			Field:   "SORTIE_BRANDING_STORAGE_BACKEND",
+			Message: fmt.Sprintf("invalid value: %q (must be \"local\" or \"s3\")", c.BrandingStorageBackend),
+		})
+	}
+	if c.BrandingStorageBackend == "s3" && c.RecordingS3Bucket == "" {
+		errs = append(errs, ValidationError{
+			Field:   "SORTIE_RECORDING_S3_BUCKET",
+			Message: "S3 bucket is required when branding storage backend is \"s3\"",
+		})
+	}
+
+	// Validate storage encryption. Key syntax is checked when the keys are
+	// parsed at startup.
+	switch c.StorageEncryption {
+	case "", "none":
+	case "local":
+		if c.StorageEncryptionKeys == "" {
+			errs = append(errs, ValidationError{
+				Field:   "SORTIE_STORAGE_ENCRYPTION_KEYS",
+				Message: "at least one master key is required when storage encryption is \"local\"",
+			})
+		}
+	case "vault":
+		if c.StorageEncryptionTransitKey == "" {
+			errs = append(errs, ValidationError{
+				Field:   "SORTIE_STORAGE_ENCRYPTION_TRANSIT_KEY",
+				Message: "transit key name is required when storage encryption is \"vault\"",
+			})
+		}
+	default:
+		errs = append(errs, ValidationError{
+			Field:   "SORTIE_STORAGE_ENCRYPTION",
+			Message: fmt.Sprintf("invalid value: %q (must be \"none\", \"local\", or \"vault\")", c.StorageEncryption),
+		})
+	}
+
+	switch c.Preflight {
+	case "", "warn", "strict", "off":
+	default:
+		errs = append(errs, ValidationError{
+			Field:   "SORTIE_PREFLIGHT",
+			Message: fmt.Sprintf("invalid value: %q (must be \"warn\", \"strict\", or \"off\")", c.Preflight),
+		})
+	}
+
+	switch c.UpdateChannel {
+	case "", "stable", "prerelease":
+	default:
+		errs = append(errs, ValidationError{
+			Field:   "SORTIE_UPDATE_CHANNEL",
+			Message: fmt.Sprintf("invalid value: %q (must be \"stable\" or \"prerelease\")", c.UpdateChannel),
+		})
+	}
+	if c.UpdateCheckURL != "" {
+		if u, err := url.Parse(c.UpdateCheckURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			errs = append(errs, ValidationError{
+				Field:   "SORTIE_UPDATE_CHECK_URL",
+				Message: fmt.Sprintf("invalid URL: %q", c.UpdateCheckURL),
+			})
+		}
+	}
+
+	switch c.TracesExporter {
+	case "", "none", "otlp", "console":
+	default:
+		errs = append(errs, ValidationError{
+			Field:   "OTEL_TRACES_EXPORTER",
+			Message: fmt.Sprintf("invalid value: %q (must be \"none\", \"otlp\", or \"console\")", c.TracesExporter),
+		})
+	}
+	if c.TracesExporter == "otlp" && c.OTLPProtocol != "http/protobuf" && c.OTLPProtocol != "grpc" {
+		errs = append(errs, ValidationError{
+			Field:   "OTEL_EXPORTER_OTLP_PROTOCOL",
+			Message: fmt.Sprintf("invalid value: %q (must be \"http/protobuf\" or \"grpc\")", c.OTLPProtocol),
+		})
+	}
+
 	// Validate S3 credentials: if one is set, both must be set
 	if (c.RecordingS3AccessKeyID != "") != (c.RecordingS3SecretAccessKey != "") {
 		errs = append(errs, ValidationError{
@@ -824,10 +1814,267 @@ func (c *Config) Validate() ValidationErrors {
 		})
 	}
 
+	if c.MaxSessionsPerUserBurst > 0 && (c.MaxSessionsPerUser == 0 || c.MaxSessionsPerUserBurst <= c.MaxSessionsPerUser) {
+		errs = append(errs, ValidationError{
+			Field:   "SORTIE_MAX_SESSIONS_PER_USER_BURST",
+			Message: fmt.Sprintf("must be greater than SORTIE_MAX_SESSIONS_PER_USER (%d), which must be set", c.MaxSessionsPerUser),
+		})
+	}
+
+	if c.AttestationKey != "" {
+		if seed, err := base64.StdEncoding.DecodeString(c.AttestationKey); err != nil || len(seed) != 32 {
+			errs = append(errs, ValidationError{
+				Field:   "SORTIE_ATTESTATION_KEY",
+				Message: "must be 32 bytes encoded as base64 (generate with: openssl rand -base64 32)",
+			})
+		}
+	}
+
+	if c.SessionDomain != "" {
+		if !isValidDomain(c.SessionDomain) {
+			errs = append(errs, ValidationError{
+				Field:   "SORTIE_SESSION_DOMAIN",
+				Message: fmt.Sprintf("invalid domain: %q", c.SessionDomain),
+			})
+		}
+		if name, port, ok := strings.Cut(c.SessionIngressService, ":"); !ok || name == "" || !isValidPort(port) {
+			errs = append(errs, ValidationError{
+				Field:   "SORTIE_SESSION_INGRESS_SERVICE",
+				Message: fmt.Sprintf("invalid service: %q (expected format: name:port)", c.SessionIngressService),
+			})
+		}
+		if c.SessionRouting != SessionRoutingProxy && c.SessionRouting != SessionRoutingDirect {
+			errs = append(errs, ValidationError{
+				Field:   "SORTIE_SESSION_ROUTING",
+				Message: fmt.Sprintf("invalid value: %q (must be \"proxy\" or \"direct\")", c.SessionRouting),
+			})
+		}
+		if c.SessionAuthURL != "" {
+			if u, err := url.Parse(c.SessionAuthURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+				errs = append(errs, ValidationError{
+					Field:   "SORTIE_SESSION_AUTH_URL",
+					Message: fmt.Sprintf("invalid URL: %q", c.SessionAuthURL),
+				})
+			}
+		}
+	}
+
+	if c.PublicURL != "" {
+		if u, err := url.Parse(c.PublicURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			errs = append(errs, ValidationError{
+				Field:   "SORTIE_PUBLIC_URL",
+				Message: fmt.Sprintf("invalid URL: %q", c.PublicURL),
+			})
+		}
+	}
+	if c.SMTPHost != "" {
+		if c.SMTPPort < 1 || c.SMTPPort > 65535 {
+			errs = append(errs, ValidationError{
+				Field:   "SORTIE_SMTP_PORT",
+				Message: fmt.Sprintf("port must be between 1 and 65535, got %d", c.SMTPPort),
+			})
+		}
+		switch c.SMTPTLS {
+		case "starttls", "tls", "none":
+		default:
+			errs = append(errs, ValidationError{
+				Field:   "SORTIE_SMTP_TLS",
+				Message: fmt.Sprintf("invalid value: %q (must be \"starttls\", \"tls\", or \"none\")", c.SMTPTLS),
+			})
+		}
+		if _, err := mail.ParseAddress(c.SMTPFrom); err != nil {
+			errs = append(errs, ValidationError{
+				Field:   "SORTIE_SMTP_FROM",
+				Message: fmt.Sprintf("a valid sender address is required when SORTIE_SMTP_HOST is set, got %q", c.SMTPFrom),
+			})
+		}
+		if c.PublicURL == "" {
+			errs = append(errs, ValidationError{
+				Field:   "SORTIE_PUBLIC_URL",
+				Message: "required when SORTIE_SMTP_HOST is set, for links in email",
+			})
+		}
+	}
+
+	if c.HealthWebhookURL != "" {
+		if u, err := url.Parse(c.HealthWebhookURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			errs = append(errs, ValidationError{
+				Field:   "SORTIE_HEALTH_WEBHOOK_URL",
+				Message: fmt.Sprintf("invalid URL: %q", c.HealthWebhookURL),
+			})
+		}
+	}
+
+	if c.SLOWebhookURL != "" {
+		if u, err := url.Parse(c.SLOWebhookURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			errs = append(errs, ValidationError{
+				Field:   "SORTIE_SLO_WEBHOOK_URL",
+				Message: fmt.Sprintf("invalid URL: %q", c.SLOWebhookURL),
+			})
+		}
+	}
+
+	if c.APIUsageWebhookURL != "" {
+		if u, err := url.Parse(c.APIUsageWebhookURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			errs = append(errs, ValidationError{
+				Field:   "SORTIE_API_USAGE_WEBHOOK_URL",
+				Message: fmt.Sprintf("invalid URL: %q", c.APIUsageWebhookURL),
+			})
+		}
+	}
+
+	if _, err := ssrf.SplitAllowlist(c.OutboundAllowlist); err != nil {
+		errs = append(errs, ValidationError{
+			Field:   "SORTIE_OUTBOUND_ALLOWLIST",
+			Message: err.Error(),
+		})
+	}
+
+	if c.WebAuthnRPID != "" && c.WebAuthnRPID != "localhost" && !isValidDomain(c.WebAuthnRPID) {
+		errs = append(errs, ValidationError{
+			Field:   "SORTIE_WEBAUTHN_RP_ID",
+			Message: fmt.Sprintf("invalid domain: %q", c.WebAuthnRPID),
+		})
+	}
+	if len(c.WebAuthnOrigins) > 0 && c.WebAuthnRPID == "" {
+		errs = append(errs, ValidationError{
+			Field:   "SORTIE_WEBAUTHN_ORIGINS",
+			Message: "requires SORTIE_WEBAUTHN_RP_ID",
+		})
+	}
+	for _, o := range c.WebAuthnOrigins {
+		// A passkey origin must be on the relying party's domain, over
+		// HTTPS except on localhost
+		u, err := url.Parse(o)
+		if err != nil || o == "*" {
+			u = &url.URL{}
+		}
+		host := u.Hostname()
+		onDomain := c.WebAuthnRPID != "" && (host == c.WebAuthnRPID || strings.HasSuffix(host, "."+c.WebAuthnRPID))
+		if !onDomain || (u.Scheme != "https" && host != "localhost") {
+			errs = append(errs, ValidationError{
+				Field:   "SORTIE_WEBAUTHN_ORIGINS",
+				Message: fmt.Sprintf("invalid origin for relying party %q: %q", c.WebAuthnRPID, o),
+			})
+		}
+	}
+
+	// Deleting before disabling would skip the grace period entirely
+	if c.InactiveUserDisableDays > 0 && c.InactiveUserDeleteDays > 0 &&
+		c.InactiveUserDeleteDays <= c.InactiveUserDisableDays {
+		errs = append(errs, ValidationError{
+			Field:   "SORTIE_INACTIVE_USER_DELETE_DAYS",
+			Message: fmt.Sprintf("must be greater than SORTIE_INACTIVE_USER_DISABLE_DAYS (%d), got %d", c.InactiveUserDisableDays, c.InactiveUserDeleteDays),
+		})
+	}
+
 	return errs
 }
 
 // isValidHexColor checks if a string is a valid hex color code.
+// isValidDomain reports whether s is a lowercase DNS name of at least two
+// labels.
+func isValidDomain(s string) bool {
+	labels := strings.Split(s, ".")
+	if len(s) > 253 || len(labels) < 2 {
+		return false
+	}
+	for _, label := range labels {
+		if !dnsLabelPattern.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
+
+var dnsLabelPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// isValidPort reports whether s is a TCP port number.
+func isValidPort(s string) bool {
+	n, err := strconv.Atoi(s)
+	return err == nil && n >= 1 && n <= 65535
+}
+
+// parseOrigins parses a comma-separated list of origins such as
+// "https://sortie.example.com", or "*" for any origin. Origins are
+// returned lowercased, as browsers send them.
+func parseOrigins(s string) ([]string, error) {
+	var origins []string
+	for _, o := range strings.Split(s, ",") {
+		o = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(o), "/"))
+		if o == "" {
+			continue
+		}
+		if o != "*" {
+			u, err := url.Parse(o)
+			if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+				return nil, fmt.Errorf("invalid origin: %q (expected format: https://host[:port],...)", o)
+			}
+		}
+		origins = append(origins, o)
+	}
+	return origins, nil
+}
+
+// parsePrefixes parses a comma-separated list of IP addresses and CIDR
+// ranges, such as "10.0.0.0/8,192.0.2.10".
+func parsePrefixes(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if p, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address or CIDR range: %q", entry)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// parseKeyValues parses "key=value,key=value" into a map.
+func parseKeyValues(s string) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("invalid entry: %q (expected format: key=value,...)", pair)
+		}
+		m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return m, nil
+}
+
+// parseRegistryMirrors parses "registry=mirror,..." pairs, where each
+// registry is a host such as docker.io or ghcr.io and each mirror is a
+// registry host with an optional path prefix.
+func parseRegistryMirrors(s string) (map[string]string, error) {
+	mirrors, err := parseKeyValues(s)
+	if err != nil {
+		return nil, err
+	}
+	for registry, mirror := range mirrors {
+		if strings.ContainsAny(registry, "/ ") {
+			return nil, fmt.Errorf("invalid registry: %q (expected a host such as docker.io)", registry)
+		}
+		if mirror == "" || strings.Contains(mirror, "://") || strings.ContainsAny(mirror, " @") {
+			return nil, fmt.Errorf("invalid mirror for %s: %q (expected a registry host and optional path)", registry, mirror)
+		}
+	}
+	return mirrors, nil
+}
+
 func isValidHexColor(s string) bool {
 	if len(s) != 7 || s[0] != '#' {
 		return false
@@ -880,6 +2127,36 @@ func (c *Config) OIDCEnabled() bool {
 	return c.OIDCIssuer != "" && c.OIDCClientID != "" && c.OIDCClientSecret != ""
 }
 
+// SessionIngressBackend splits SessionIngressService into the Service name
+// and port. Validate has already rejected malformed values.
+func (c *Config) SessionIngressBackend() (string, int32) {
+	name, port, _ := strings.Cut(c.SessionIngressService, ":")
+	n, _ := strconv.Atoi(port)
+	return name, int32(n)
+}
+
+// Session routing modes for session hostnames.
+const (
+	// SessionRoutingProxy routes session hostnames to the server, which
+	// proxies them to session pods.
+	SessionRoutingProxy = "proxy"
+	// SessionRoutingDirect routes session hostnames straight to session
+	// pods, with the ingress controller checking each request with the
+	// server's forward-auth endpoint.
+	SessionRoutingDirect = "direct"
+)
+
+// SessionAuthEndpoint returns the URL ingress controllers call to check
+// requests to directly routed sessions: SessionAuthURL if set, otherwise
+// the forward-auth endpoint on the server's in-cluster Service.
+func (c *Config) SessionAuthEndpoint() string {
+	if c.SessionAuthURL != "" {
+		return c.SessionAuthURL
+	}
+	name, port := c.SessionIngressBackend()
+	return fmt.Sprintf("http://%s.%s.svc:%d/api/auth/session-host", name, c.Namespace, port)
+}
+
 // MustLoad loads configuration and panics if it fails.
 // Use this for application startup where configuration errors are fatal.
 func MustLoad() *Config {
@@ -0,0 +1,81 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+		"@every 5m",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) should fail", expr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(loc *time.Location, s string) time.Time {
+		t.Helper()
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		expr string
+		from string
+		want string
+		loc  *time.Location
+	}{
+		{"*/15 * * * *", "2026-03-02 10:07", "2026-03-02 10:15", time.UTC},
+		{"0 8 * * 1-5", "2026-03-06 09:00", "2026-03-09 08:00", time.UTC}, // Friday after class, to Monday
+		{"0 8 * * MON-FRI", "2026-03-09 07:59", "2026-03-09 08:00", time.UTC},
+		{"30 13 * * tue,thu", "2026-03-03 13:30", "2026-03-05 13:30", time.UTC}, // Strictly after
+		{"0 0 1 * *", "2026-01-31 12:00", "2026-02-01 00:00", time.UTC},
+		{"0 0 31 * *", "2026-04-01 00:00", "2026-05-31 00:00", time.UTC},
+		{"0 9 1 * 0", "2026-03-02 00:00", "2026-03-08 09:00", time.UTC},    // 1st or Sunday
+		{"0 9 */10 * *", "2026-03-02 00:00", "2026-03-11 09:00", time.UTC}, // 1, 11, 21, 31
+		{"5/20 * * * *", "2026-03-02 10:26", "2026-03-02 10:45", time.UTC},
+		{"0 0 * * 7", "2026-03-02 00:00", "2026-03-08 00:00", time.UTC},
+		{"@daily", "2026-03-02 10:00", "2026-03-03 00:00", time.UTC},
+		{"@hourly", "2026-03-02 10:00", "2026-03-02 11:00", time.UTC},
+		{"0 0 29 2 *", "2026-03-01 00:00", "2028-02-29 00:00", time.UTC},
+		// In the schedule's timezone, across the start of daylight saving
+		{"0 8 * * *", "2026-03-07 09:00", "2026-03-08 08:00", ny},
+		{"30 2 * * *", "2026-03-07 03:00", "2026-03-09 02:30", ny}, // 02:30 does not exist on the 8th
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.expr, err)
+		}
+		got := s.Next(at(tt.loc, tt.from))
+		if want := at(tt.loc, tt.want); !got.Equal(want) {
+			t.Errorf("%q from %s: Next() = %s, want %s", tt.expr, tt.from, got, want)
+		}
+	}
+
+	s, _ := Parse("0 0 30 2 *")
+	if got := s.Next(time.Now()); !got.IsZero() {
+		t.Errorf("Next() of an impossible date = %s, want zero", got)
+	}
+}
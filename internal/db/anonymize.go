@@ -0,0 +1,295 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// scrubRule says how ExportAnonymized rewrites a column.
+type scrubRule int
+
+const (
+	scrubUserID      scrubRule = iota + 1 // consistent pseudonym for a user ID
+	scrubUsername                         // consistent pseudonym for a username
+	scrubDisplayName                      // the row's pseudonymized username
+	scrubClear                            // emptied
+	scrubToken                            // replaced with an unusable hash
+	scrubFreeText                         // known usernames, emails, and IDs replaced
+	scrubEnvVars                          // environment variable values redacted
+)
+
+// scrubRules lists the columns that hold personal data or secrets. Columns
+// not listed are exported unchanged.
+var scrubRules = map[string]map[string]scrubRule{
+	"users": {
+		"id":               scrubUserID,
+		"username":         scrubUsername,
+		"display_name":     scrubDisplayName,
+		"email":            scrubClear,
+		"password_hash":    scrubClear,
+		"auth_provider_id": scrubToken,
+	},
+	"sessions":                {"user_id": scrubUserID, "pod_ip": scrubClear},
+	"recordings":              {"user_id": scrubUserID},
+	"category_admins":         {"user_id": scrubUserID},
+	"category_approved_users": {"user_id": scrubUserID},
+	"session_shares":          {"user_id": scrubUserID, "created_by": scrubUserID, "share_token": scrubToken},
+	"audit_log":               {"user": scrubUsername, "details": scrubFreeText},
+	"session_file_events":     {"username": scrubUsername, "path": scrubFreeText},
+	"app_specs":               {"env_vars": scrubEnvVars},
+	"job_runs":                {"requested_by": scrubUsername, "error": scrubFreeText},
+	"scheduled_sessions":      {"user_id": scrubUserID, "created_by": scrubUserID},
+	"api_usage":               {"user_id": scrubUserID},
+	"projects":                {"created_by": scrubUserID},
+	"project_members":         {"user_id": scrubUserID},
+	"status_announcements":    {"created_by": scrubUsername},
+	"remote_catalogs":         {"created_by": scrubUsername},
+	"setting_change_requests": {"requested_by": scrubUsername, "reviewed_by": scrubUsername, "reason": scrubFreeText, "review_comment": scrubFreeText},
+}
+
+// droppedTables hold credentials and are never exported.
+var droppedTables = map[string]bool{
+	"refresh_tokens":        true,
+	"oidc_states":           true,
+	"idp_tokens":            true,
+	"api_tokens":            true,
+	"user_mfa":              true,
+	"mfa_backup_codes":      true,
+	"webauthn_credentials":  true,
+	"webauthn_challenges":   true,
+	"password_reset_tokens": true,
+	"registry_credentials":  true,
+}
+
+// systemActors are audit log users that are not people.
+var systemActors = map[string]bool{"system": true}
+
+// ExportReport summarizes one table written by ExportAnonymized.
+type ExportReport struct {
+	Table   string `json:"table"`
+	Rows    int    `json:"rows"`
+	Dropped bool   `json:"dropped"`
+}
+
+// anonymizer rewrites personal data using salted hashes, so the same input
+// always maps to the same pseudonym within one export.
+type anonymizer struct {
+	salt     string
+	replacer *strings.Replacer
+}
+
+func (a *anonymizer) pseudonym(prefix, v string) string {
+	if v == "" {
+		return v
+	}
+	sum := sha256.Sum256([]byte(a.salt + "\x00" + v))
+	return prefix + "-" + hex.EncodeToString(sum[:6])
+}
+
+func (a *anonymizer) username(v string) string {
+	if systemActors[v] {
+		return v
+	}
+	return a.pseudonym("user", v)
+}
+
+func (a *anonymizer) userID(v string) string {
+	return a.pseudonym("id", v)
+}
+
+// ExportAnonymized copies src into dst with personal data and credentials
+// scrubbed according to scrubRules, for use as a reproduction fixture.
+// Usernames and user IDs are replaced by salted hashes consistently across
+// tables, so relationships between rows survive. Any existing rows in dst
+// are replaced.
+func ExportAnonymized(src, dst *DB, salt string) ([]ExportReport, error) {
+	snaps := make([]*tableSnapshot, len(dataModels))
+	for i, model := range dataModels {
+		name := src.bun.Table(reflect.TypeOf(model).Elem()).Name
+		srcSnap, _, err := loadSnapshots(src, dst, name, true)
+		if err != nil {
+			return nil, err
+		}
+		snaps[i] = srcSnap
+	}
+
+	a := &anonymizer{salt: salt}
+	for _, snap := range snaps {
+		if snap.cols.table.Name == "users" {
+			a.replacer = a.freeTextReplacer(snap)
+		}
+	}
+	if a.replacer == nil {
+		a.replacer = strings.NewReplacer()
+	}
+
+	reports := make([]ExportReport, len(snaps))
+	diffs := make([]tableDiff, len(snaps))
+	for i, snap := range snaps {
+		name := snap.cols.table.Name
+		diffs[i] = tableDiff{cols: snap.cols}
+		reports[i] = ExportReport{Table: name, Dropped: droppedTables[name]}
+		if droppedTables[name] {
+			continue
+		}
+
+		keys := make([]string, 0, len(snap.rows))
+		for k := range snap.rows {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			row, err := a.scrubRow(snap.cols, snap.rows[k])
+			if err != nil {
+				return nil, fmt.Errorf("failed to scrub %s: %w", name, err)
+			}
+			diffs[i].inserts = append(diffs[i].inserts, row)
+		}
+		reports[i].Rows = len(diffs[i].inserts)
+	}
+
+	err := dst.runInTx(func(txCtx context.Context, tx bun.Tx) error {
+		for i := len(diffs) - 1; i >= 0; i-- {
+			table := diffs[i].cols.table.Name
+			if _, err := tx.ExecContext(txCtx, "DELETE FROM "+quoteIdent(table)); err != nil {
+				return fmt.Errorf("failed to clear %s: %w", table, err)
+			}
+		}
+		for _, diff := range diffs {
+			if err := applyInserts(txCtx, tx, diff); err != nil {
+				return err
+			}
+			if err := dst.resetSequences(txCtx, tx, diff.cols.table); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// freeTextReplacer builds a replacer that rewrites every username, email,
+// display name, and user ID found in the users table. Longer values are
+// replaced first so that one value containing another is fully scrubbed.
+func (a *anonymizer) freeTextReplacer(users *tableSnapshot) *strings.Replacer {
+	idx := make(map[string]int, len(users.cols.names))
+	for i, name := range users.cols.names {
+		idx[name] = i
+	}
+	str := func(row []any, col string) string {
+		i, ok := idx[col]
+		if !ok || row[i] == nil {
+			return ""
+		}
+		return fmt.Sprint(row[i])
+	}
+
+	replacements := make(map[string]string)
+	for _, row := range users.rows {
+		username := a.username(str(row, "username"))
+		add := func(v, with string) {
+			// Very short values would rewrite unrelated text
+			if len(v) >= 3 {
+				replacements[v] = with
+			}
+		}
+		add(str(row, "username"), username)
+		add(str(row, "display_name"), username)
+		add(str(row, "email"), username+"@example.invalid")
+		add(str(row, "id"), a.userID(str(row, "id")))
+	}
+
+	olds := make([]string, 0, len(replacements))
+	for old := range replacements {
+		olds = append(olds, old)
+	}
+	sort.Slice(olds, func(i, j int) bool {
+		if len(olds[i]) != len(olds[j]) {
+			return len(olds[i]) > len(olds[j])
+		}
+		return olds[i] < olds[j]
+	})
+	pairs := make([]string, 0, 2*len(olds))
+	for _, old := range olds {
+		pairs = append(pairs, old, replacements[old])
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// scrubRow returns a copy of row with the table's scrub rules applied.
+func (a *anonymizer) scrubRow(cols *tableColumns, row []any) ([]any, error) {
+	rules := scrubRules[cols.table.Name]
+	out := make([]any, len(row))
+	copy(out, row)
+	if len(rules) == 0 {
+		return out, nil
+	}
+
+	var username string
+	for i, name := range cols.names {
+		if name == "username" && row[i] != nil {
+			username = fmt.Sprint(row[i])
+		}
+	}
+
+	for i, name := range cols.names {
+		rule, ok := rules[name]
+		if !ok || row[i] == nil {
+			continue
+		}
+		v := fmt.Sprint(row[i])
+		switch rule {
+		case scrubUserID:
+			out[i] = a.userID(v)
+		case scrubUsername:
+			out[i] = a.username(v)
+		case scrubDisplayName:
+			out[i] = a.username(username)
+		case scrubClear:
+			out[i] = ""
+		case scrubToken:
+			out[i] = a.pseudonym("redacted", v)
+		case scrubFreeText:
+			out[i] = a.replacer.Replace(v)
+		case scrubEnvVars:
+			redacted, err := redactEnvVars(v)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", name, err)
+			}
+			out[i] = redacted
+		}
+	}
+	return out, nil
+}
+
+// redactEnvVars replaces the values in a JSON-encoded []EnvVar.
+func redactEnvVars(v string) (string, error) {
+	if v == "" {
+		return v, nil
+	}
+	var vars []EnvVar
+	if err := json.Unmarshal([]byte(v), &vars); err != nil {
+		return "", err
+	}
+	for i := range vars {
+		if vars[i].Value != "" {
+			vars[i].Value = "REDACTED"
+		}
+	}
+	b, err := json.Marshal(vars)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
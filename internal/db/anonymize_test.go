@@ -0,0 +1,177 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportAnonymized(t *testing.T) {
+	src := openSourceSQLite(t)
+	now := time.Now().Truncate(time.Second)
+
+	app := Application{
+		ID: "anon-app", Name: "Anon App", Description: "d",
+		URL: "http://x", Icon: "i", Category: "c",
+	}
+	if err := src.CreateApp(app); err != nil {
+		t.Fatalf("CreateApp() error = %v", err)
+	}
+	user := User{
+		ID: "admin-alice", Username: "alice", Email: "alice@corp.example",
+		DisplayName: "Alice Liddell", PasswordHash: "$2a$10$secrethash",
+		Roles: []string{"admin"}, CreatedAt: now, UpdatedAt: now,
+	}
+	if err := src.CreateUser(user); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	session := Session{
+		ID: "anon-sess", UserID: user.ID, AppID: app.ID,
+		PodName: "pod-1", PodIP: "10.1.2.3", Status: SessionStatusRunning,
+		CreatedAt: now, UpdatedAt: now,
+	}
+	if err := src.CreateSession(session); err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	share := SessionShare{
+		ID: "anon-share", SessionID: session.ID, Permission: SharePermissionReadOnly,
+		ShareToken: "share-secret-token", CreatedBy: user.ID, CreatedAt: now,
+	}
+	if err := src.CreateSessionShare(share); err != nil {
+		t.Fatalf("CreateSessionShare() error = %v", err)
+	}
+	spec := AppSpec{
+		ID: "anon-spec", Name: "Spec", Image: "img",
+		EnvVars: []EnvVar{{Name: "API_KEY", Value: "sk-live-123"}},
+	}
+	if err := src.CreateAppSpec(spec); err != nil {
+		t.Fatalf("CreateAppSpec() error = %v", err)
+	}
+	if err := src.CreateRefreshToken(RefreshToken{ID: "rt", UserID: user.ID, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("CreateRefreshToken() error = %v", err)
+	}
+	if err := src.SaveOIDCState("oidc-state", "/", now.Add(time.Hour)); err != nil {
+		t.Fatalf("SaveOIDCState() error = %v", err)
+	}
+	if err := src.LogAudit("alice", "CREATE_USER", "Created user: alice (alice@corp.example)"); err != nil {
+		t.Fatalf("LogAudit() error = %v", err)
+	}
+	if err := src.LogAudit("system", "CLEANUP", "Removed stale sessions"); err != nil {
+		t.Fatalf("LogAudit() error = %v", err)
+	}
+
+	export := func(t *testing.T, salt string) *DB {
+		t.Helper()
+		dst, err := OpenDB("sqlite", filepath.Join(t.TempDir(), "fixture.db"))
+		if err != nil {
+			t.Fatalf("failed to open fixture database: %v", err)
+		}
+		t.Cleanup(func() { dst.Close() })
+		if _, err := ExportAnonymized(src, dst, salt); err != nil {
+			t.Fatalf("ExportAnonymized() error = %v", err)
+		}
+		return dst
+	}
+
+	dst := export(t, "salt")
+
+	t.Run("no personal data or secrets remain", func(t *testing.T) {
+		secrets := []string{
+			"alice", "Alice Liddell", "corp.example", "secrethash",
+			"10.1.2.3", "share-secret-token", "sk-live-123",
+		}
+		for _, model := range dataModels {
+			name := dst.bun.Table(reflect.TypeOf(model).Elem()).Name
+			snap, err := dst.loadSnapshot(name, true)
+			if err != nil {
+				t.Fatalf("loadSnapshot(%s) error = %v", name, err)
+			}
+			for _, row := range snap.rows {
+				text := fmt.Sprint(row...)
+				for _, s := range secrets {
+					if strings.Contains(text, s) {
+						t.Errorf("%s row contains %q: %v", name, s, row)
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("credentials dropped", func(t *testing.T) {
+		tokens, err := dst.ListRefreshTokensByUser("admin-alice")
+		if err != nil {
+			t.Fatalf("ListRefreshTokensByUser() error = %v", err)
+		}
+		if len(tokens) != 0 {
+			t.Errorf("got %d refresh tokens, want 0", len(tokens))
+		}
+		var states int
+		if err := dst.bun.NewSelect().Model((*OIDCState)(nil)).ColumnExpr("COUNT(*)").Scan(ctx(), &states); err != nil {
+			t.Fatalf("count oidc_states error = %v", err)
+		}
+		if states != 0 {
+			t.Errorf("got %d OIDC states, want 0", states)
+		}
+	})
+
+	t.Run("relationships preserved", func(t *testing.T) {
+		users, err := dst.ListUsers()
+		if err != nil || len(users) != 1 {
+			t.Fatalf("ListUsers() = %v, %v; want 1 user", users, err)
+		}
+		got := users[0]
+		if got.DisplayName != got.Username || got.Email != "" || got.PasswordHash != "" {
+			t.Errorf("user not scrubbed: %+v", got)
+		}
+		if len(got.Roles) != 1 || got.Roles[0] != "admin" {
+			t.Errorf("roles = %v, want [admin]", got.Roles)
+		}
+
+		sessions, err := dst.ListSessionsByUser(got.ID)
+		if err != nil || len(sessions) != 1 {
+			t.Fatalf("ListSessionsByUser() = %v, %v; want 1 session", sessions, err)
+		}
+		shares, err := dst.ListSessionShares(sessions[0].ID)
+		if err != nil || len(shares) != 1 || shares[0].CreatedBy != got.ID {
+			t.Errorf("ListSessionShares() = %+v, %v; want 1 share created by %s", shares, err, got.ID)
+		}
+
+		logs, err := dst.GetAuditLogs(10)
+		if err != nil {
+			t.Fatalf("GetAuditLogs() error = %v", err)
+		}
+		actors := map[string]bool{}
+		for _, l := range logs {
+			actors[l.User] = true
+			if l.Action == "CREATE_USER" && !strings.Contains(l.Details, got.Username) {
+				t.Errorf("audit details = %q, want pseudonym %s", l.Details, got.Username)
+			}
+		}
+		if !actors[got.Username] || !actors["system"] {
+			t.Errorf("audit users = %v, want %s and system", actors, got.Username)
+		}
+
+		gotSpec, err := dst.GetAppSpec(spec.ID)
+		if err != nil || gotSpec == nil {
+			t.Fatalf("GetAppSpec() = %v, %v", gotSpec, err)
+		}
+		if len(gotSpec.EnvVars) != 1 || gotSpec.EnvVars[0].Name != "API_KEY" || gotSpec.EnvVars[0].Value != "REDACTED" {
+			t.Errorf("EnvVars = %+v, want API_KEY=REDACTED", gotSpec.EnvVars)
+		}
+	})
+
+	t.Run("pseudonyms depend on salt", func(t *testing.T) {
+		first, _ := dst.ListUsers()
+		same, _ := export(t, "salt").ListUsers()
+		other, _ := export(t, "pepper").ListUsers()
+		if first[0].Username != same[0].Username {
+			t.Errorf("same salt gave %s and %s", first[0].Username, same[0].Username)
+		}
+		if first[0].Username == other[0].Username {
+			t.Error("different salts gave the same pseudonym")
+		}
+	})
+}
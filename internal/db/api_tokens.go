@@ -0,0 +1,110 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// APIToken is a personal access token a user minted for scripts and CI.
+// Only the token's SHA-256 hash is stored; deleting the row revokes it.
+type APIToken struct {
+	bun.BaseModel `bun:"table:api_tokens"`
+
+	ID         string     `json:"id" bun:"id,pk"`
+	UserID     string     `json:"user_id" bun:"user_id,notnull"`
+	Name       string     `json:"name" bun:"name,notnull"`
+	TokenHash  string     `json:"-" bun:"token_hash,notnull"`
+	Prefix     string     `json:"prefix" bun:"prefix,notnull"`
+	Scopes     string     `json:"scopes" bun:"scopes,notnull"` // Comma-separated
+	CreatedAt  time.Time  `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" bun:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at" bun:"expires_at,notnull"`
+}
+
+// CreateAPIToken stores a newly minted API token and removes any of the
+// user's tokens that have already expired.
+func (db *DB) CreateAPIToken(token APIToken) error {
+	if _, err := db.conn.NewDelete().Model((*APIToken)(nil)).
+		Where("user_id = ?", token.UserID).
+		Where("expires_at < ?", time.Now()).
+		Exec(db.ctx()); err != nil {
+		return err
+	}
+	_, err := db.conn.NewInsert().Model(&token).Exec(db.ctx())
+	return err
+}
+
+// GetAPITokenByHash returns the API token with the given hash, or nil if
+// there is none.
+func (db *DB) GetAPITokenByHash(hash string) (*APIToken, error) {
+	var token APIToken
+	err := db.conn.NewSelect().Model(&token).Where("token_hash = ?", hash).Scan(db.ctx())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// TouchAPIToken records that an API token was used.
+func (db *DB) TouchAPIToken(id string, at time.Time) error {
+	_, err := db.conn.NewUpdate().Model((*APIToken)(nil)).
+		Set("last_used_at = ?", at).
+		Where("id = ?", id).
+		Exec(db.ctx())
+	return err
+}
+
+// ListAPITokensByUser returns a user's unexpired API tokens, most recently
+// created first.
+func (db *DB) ListAPITokensByUser(userID string) ([]APIToken, error) {
+	var tokens []APIToken
+	err := db.conn.NewSelect().Model(&tokens).
+		Where("user_id = ?", userID).
+		Where("expires_at > ?", time.Now()).
+		OrderExpr("created_at DESC").
+		Scan(db.ctx())
+	return tokens, err
+}
+
+// ListAPITokens returns every user's unexpired API tokens, oldest first.
+func (db *DB) ListAPITokens() ([]APIToken, error) {
+	var tokens []APIToken
+	err := db.conn.NewSelect().Model(&tokens).
+		Where("expires_at > ?", time.Now()).
+		OrderExpr("created_at ASC").
+		Scan(db.ctx())
+	return tokens, err
+}
+
+// DeleteUserAPIToken revokes one of a user's API tokens. The user ID is part
+// of the match so users cannot revoke each other's tokens.
+func (db *DB) DeleteUserAPIToken(userID, id string) error {
+	result, err := db.conn.NewDelete().Model((*APIToken)(nil)).
+		Where("id = ?", id).
+		Where("user_id = ?", userID).
+		Exec(db.ctx())
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteAPITokensByUser revokes all of a user's API tokens.
+func (db *DB) DeleteAPITokensByUser(userID string) error {
+	_, err := db.conn.NewDelete().Model((*APIToken)(nil)).
+		Where("user_id = ?", userID).
+		Exec(db.ctx())
+	return err
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestAPITokenStore(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Now().Truncate(time.Second)
+
+	tokens := []APIToken{
+		{ID: "pat-1", UserID: "user-1", Name: "ci", TokenHash: "hash-1", Prefix: "sortie_pat_aaaa", Scopes: "read,write", CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)},
+		{ID: "pat-2", UserID: "user-1", Name: "reports", TokenHash: "hash-2", Prefix: "sortie_pat_bbbb", Scopes: "read", CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{ID: "pat-3", UserID: "user-2", Name: "ci", TokenHash: "hash-3", Prefix: "sortie_pat_cccc", Scopes: "read", CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+	}
+	for _, tok := range tokens {
+		if err := db.CreateAPIToken(tok); err != nil {
+			t.Fatalf("CreateAPIToken() error = %v", err)
+		}
+	}
+
+	t.Run("get by hash", func(t *testing.T) {
+		got, err := db.GetAPITokenByHash("hash-1")
+		if err != nil || got == nil || got.ID != "pat-1" || got.Scopes != "read,write" {
+			t.Fatalf("GetAPITokenByHash() = %+v, %v; want pat-1", got, err)
+		}
+		if missing, err := db.GetAPITokenByHash("nope"); err != nil || missing != nil {
+			t.Errorf("GetAPITokenByHash(nope) = %+v, %v; want nil", missing, err)
+		}
+	})
+
+	t.Run("touch", func(t *testing.T) {
+		if err := db.TouchAPIToken("pat-1", now); err != nil {
+			t.Fatalf("TouchAPIToken() error = %v", err)
+		}
+		got, _ := db.GetAPITokenByHash("hash-1")
+		if got.LastUsedAt == nil || !got.LastUsedAt.Equal(now) {
+			t.Errorf("LastUsedAt = %v, want %v", got.LastUsedAt, now)
+		}
+	})
+
+	t.Run("list by user newest first", func(t *testing.T) {
+		got, err := db.ListAPITokensByUser("user-1")
+		if err != nil {
+			t.Fatalf("ListAPITokensByUser() error = %v", err)
+		}
+		if len(got) != 2 || got[0].ID != "pat-2" {
+			t.Errorf("got %+v, want pat-2 then pat-1", got)
+		}
+	})
+
+	t.Run("expired tokens are pruned", func(t *testing.T) {
+		expired := APIToken{ID: "pat-old", UserID: "user-3", Name: "old", TokenHash: "hash-old", Prefix: "sortie_pat_dddd", Scopes: "read", ExpiresAt: now.Add(-time.Minute)}
+		if err := db.CreateAPIToken(expired); err != nil {
+			t.Fatalf("CreateAPIToken() error = %v", err)
+		}
+		if got, _ := db.ListAPITokensByUser("user-3"); len(got) != 0 {
+			t.Errorf("listed %d expired tokens, want 0", len(got))
+		}
+		fresh := APIToken{ID: "pat-new", UserID: "user-3", Name: "new", TokenHash: "hash-new", Prefix: "sortie_pat_eeee", Scopes: "read", ExpiresAt: now.Add(time.Hour)}
+		if err := db.CreateAPIToken(fresh); err != nil {
+			t.Fatalf("CreateAPIToken() error = %v", err)
+		}
+		if got, _ := db.GetAPITokenByHash("hash-old"); got != nil {
+			t.Error("expired token not removed")
+		}
+	})
+
+	t.Run("delete is scoped to user", func(t *testing.T) {
+		if err := db.DeleteUserAPIToken("user-2", "pat-1"); err != sql.ErrNoRows {
+			t.Errorf("DeleteUserAPIToken() other user's token error = %v, want sql.ErrNoRows", err)
+		}
+		if err := db.DeleteUserAPIToken("user-1", "pat-1"); err != nil {
+			t.Fatalf("DeleteUserAPIToken() error = %v", err)
+		}
+		if got, _ := db.GetAPITokenByHash("hash-1"); got != nil {
+			t.Error("token still present after delete")
+		}
+	})
+
+	t.Run("delete all for user", func(t *testing.T) {
+		if err := db.DeleteAPITokensByUser("user-1"); err != nil {
+			t.Fatalf("DeleteAPITokensByUser() error = %v", err)
+		}
+		if got, _ := db.ListAPITokensByUser("user-1"); len(got) != 0 {
+			t.Errorf("got %d tokens after delete all, want 0", len(got))
+		}
+		if got, _ := db.ListAPITokensByUser("user-2"); len(got) != 1 {
+			t.Errorf("other user's tokens = %d, want 1", len(got))
+		}
+	})
+}
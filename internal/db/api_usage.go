@@ -0,0 +1,162 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// APIUsage counts the API requests one principal made to one endpoint in
+// one hour. The principal is a user, and the API token they used, if any.
+// Replicas add their counts to the same row.
+type APIUsage struct {
+	bun.BaseModel `bun:"table:api_usage"`
+
+	BucketStart time.Time `json:"bucket_start" bun:"bucket_start,pk"`
+	UserID      string    `json:"user_id" bun:"user_id,pk"`
+	TokenID     string    `json:"token_id,omitempty" bun:"token_id,pk"` // Empty for browser sessions
+	Method      string    `json:"method" bun:"method,pk"`
+	Endpoint    string    `json:"endpoint" bun:"endpoint,pk"` // Path with IDs replaced by {id}
+	Requests    int64     `json:"requests" bun:"requests,notnull"`
+	Errors      int64     `json:"errors" bun:"errors,notnull"` // Responses with a 5xx status
+}
+
+// AddAPIUsage adds counts to the usage rows with the same bucket,
+// principal and endpoint, creating rows that do not exist yet. rows must
+// not repeat a key.
+func (db *DB) AddAPIUsage(rows []APIUsage) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	_, err := db.conn.NewInsert().Model(&rows).
+		On("CONFLICT (bucket_start, user_id, token_id, method, endpoint) DO UPDATE").
+		Set("requests = api_usage.requests + EXCLUDED.requests, errors = api_usage.errors + EXCLUDED.errors").
+		Exec(db.ctx())
+	return err
+}
+
+// APIUsageFilter selects the usage buckets starting in [From, To),
+// optionally of one user or API token.
+type APIUsageFilter struct {
+	From    time.Time
+	To      time.Time
+	UserID  string
+	TokenID string
+}
+
+func (f APIUsageFilter) apply(q *bun.SelectQuery) *bun.SelectQuery {
+	q = q.Where("?TableAlias.bucket_start >= ?", f.From).
+		Where("?TableAlias.bucket_start < ?", f.To)
+	if f.UserID != "" {
+		q = q.Where("?TableAlias.user_id = ?", f.UserID)
+	}
+	if f.TokenID != "" {
+		q = q.Where("?TableAlias.token_id = ?", f.TokenID)
+	}
+	return q
+}
+
+// APIUsageBucket is the API usage in one hour.
+type APIUsageBucket struct {
+	BucketStart time.Time `json:"start" bun:"bucket_start"`
+	Requests    int64     `json:"requests" bun:"requests"`
+	Errors      int64     `json:"errors" bun:"errors"`
+}
+
+// APIEndpointUsage is the API usage of one endpoint.
+type APIEndpointUsage struct {
+	Method   string `json:"method" bun:"method"`
+	Endpoint string `json:"endpoint" bun:"endpoint"`
+	Requests int64  `json:"requests" bun:"requests"`
+	Errors   int64  `json:"errors" bun:"errors"`
+}
+
+// APIPrincipalUsage is the API usage of one user or API token. Username
+// and TokenName are empty once the user or token is deleted.
+type APIPrincipalUsage struct {
+	UserID    string `json:"user_id" bun:"user_id"`
+	Username  string `json:"username,omitempty" bun:"username"`
+	TokenID   string `json:"token_id,omitempty" bun:"token_id"`
+	TokenName string `json:"token_name,omitempty" bun:"token_name"`
+	Requests  int64  `json:"requests" bun:"requests"`
+	Errors    int64  `json:"errors" bun:"errors"`
+}
+
+// ListAPIUsageBuckets sums the usage matching the filter by hour, oldest
+// first. Hours without requests are left out.
+func (db *DB) ListAPIUsageBuckets(filter APIUsageFilter) ([]APIUsageBucket, error) {
+	buckets := []APIUsageBucket{}
+	err := filter.apply(db.conn.NewSelect().Model((*APIUsage)(nil))).
+		Column("bucket_start").
+		ColumnExpr("SUM(requests) AS requests").
+		ColumnExpr("SUM(errors) AS errors").
+		Group("bucket_start").
+		OrderExpr("bucket_start ASC").
+		Scan(db.ctx(), &buckets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API usage: %w", err)
+	}
+	return buckets, nil
+}
+
+// TopAPIEndpoints returns the limit endpoints with the most requests
+// matching the filter, busiest first.
+func (db *DB) TopAPIEndpoints(filter APIUsageFilter, limit int) ([]APIEndpointUsage, error) {
+	endpoints := []APIEndpointUsage{}
+	err := filter.apply(db.conn.NewSelect().Model((*APIUsage)(nil))).
+		Column("method", "endpoint").
+		ColumnExpr("SUM(requests) AS requests").
+		ColumnExpr("SUM(errors) AS errors").
+		Group("method", "endpoint").
+		OrderExpr("requests DESC, endpoint ASC, method ASC").
+		Limit(limit).
+		Scan(db.ctx(), &endpoints)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list top API endpoints: %w", err)
+	}
+	return endpoints, nil
+}
+
+// TopAPIPrincipals returns the limit users and API tokens with the most
+// requests matching the filter, busiest first. A user's requests with and
+// without each token are counted separately.
+func (db *DB) TopAPIPrincipals(filter APIUsageFilter, limit int) ([]APIPrincipalUsage, error) {
+	principals := []APIPrincipalUsage{}
+	err := filter.apply(db.conn.NewSelect().Model((*APIUsage)(nil))).
+		ColumnExpr("?TableAlias.user_id, ?TableAlias.token_id").
+		ColumnExpr("COALESCE(u.username, '') AS username").
+		ColumnExpr("COALESCE(t.name, '') AS token_name").
+		ColumnExpr("SUM(?TableAlias.requests) AS requests").
+		ColumnExpr("SUM(?TableAlias.errors) AS errors").
+		Join("LEFT JOIN users AS u ON u.id = ?TableAlias.user_id").
+		Join("LEFT JOIN api_tokens AS t ON t.id = ?TableAlias.token_id").
+		GroupExpr("?TableAlias.user_id, ?TableAlias.token_id, u.username, t.name").
+		OrderExpr("requests DESC, ?TableAlias.user_id ASC, ?TableAlias.token_id ASC").
+		Limit(limit).
+		Scan(db.ctx(), &principals)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list top API principals: %w", err)
+	}
+	return principals, nil
+}
+
+// DeleteAPIUsageBefore deletes the usage buckets that started before t and
+// returns how many were deleted.
+func (db *DB) DeleteAPIUsageBefore(t time.Time) (int64, error) {
+	result, err := db.conn.NewDelete().Model((*APIUsage)(nil)).
+		Where("bucket_start < ?", t).
+		Exec(db.ctx())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DeleteAPIUsageByUser deletes a user's API usage.
+func (db *DB) DeleteAPIUsageByUser(userID string) error {
+	_, err := db.conn.NewDelete().Model((*APIUsage)(nil)).
+		Where("user_id = ?", userID).
+		Exec(db.ctx())
+	return err
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAPIUsage(t *testing.T) {
+	db := setupTestDB(t)
+	hour := time.Now().UTC().Truncate(time.Hour)
+	earlier := hour.Add(-time.Hour)
+
+	if err := db.CreateUser(User{ID: "u1", Username: "alice", Roles: []string{"user"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CreateAPIToken(APIToken{ID: "tok1", UserID: "u1", Name: "ci", TokenHash: "h", Prefix: "p", Scopes: "read", ExpiresAt: hour.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Counts from two replicas add up
+	for range 2 {
+		if err := db.AddAPIUsage([]APIUsage{
+			{BucketStart: earlier, UserID: "u1", Method: "GET", Endpoint: "/api/apps", Requests: 2},
+			{BucketStart: hour, UserID: "u1", TokenID: "tok1", Method: "GET", Endpoint: "/api/sessions", Requests: 5, Errors: 1},
+			{BucketStart: hour, UserID: "u2", Method: "POST", Endpoint: "/api/sessions", Requests: 1},
+		}); err != nil {
+			t.Fatalf("AddAPIUsage() error = %v", err)
+		}
+	}
+
+	all := APIUsageFilter{From: earlier, To: hour.Add(time.Hour)}
+	buckets, err := db.ListAPIUsageBuckets(all)
+	if err != nil {
+		t.Fatalf("ListAPIUsageBuckets() error = %v", err)
+	}
+	if len(buckets) != 2 || !buckets[0].BucketStart.Equal(earlier) || buckets[0].Requests != 4 ||
+		!buckets[1].BucketStart.Equal(hour) || buckets[1].Requests != 12 || buckets[1].Errors != 2 {
+		t.Errorf("ListAPIUsageBuckets() = %+v, want 4 then 12 requests", buckets)
+	}
+
+	endpoints, err := db.TopAPIEndpoints(all, 2)
+	if err != nil {
+		t.Fatalf("TopAPIEndpoints() error = %v", err)
+	}
+	if len(endpoints) != 2 || endpoints[0].Endpoint != "/api/sessions" || endpoints[0].Method != "GET" || endpoints[0].Requests != 10 ||
+		endpoints[1].Endpoint != "/api/apps" {
+		t.Errorf("TopAPIEndpoints() = %+v, want GET /api/sessions then /api/apps", endpoints)
+	}
+
+	principals, err := db.TopAPIPrincipals(APIUsageFilter{From: hour, To: hour.Add(time.Hour)}, 10)
+	if err != nil {
+		t.Fatalf("TopAPIPrincipals() error = %v", err)
+	}
+	if len(principals) != 2 || principals[0].UserID != "u1" || principals[0].Username != "alice" || principals[0].TokenName != "ci" ||
+		principals[0].Requests != 10 || principals[1].UserID != "u2" || principals[1].Username != "" {
+		t.Errorf("TopAPIPrincipals() = %+v, want alice's ci token then u2", principals)
+	}
+
+	// Filtered by token
+	byToken := all
+	byToken.TokenID = "tok1"
+	if buckets, _ := db.ListAPIUsageBuckets(byToken); len(buckets) != 1 || buckets[0].Requests != 10 {
+		t.Errorf("ListAPIUsageBuckets(token) = %+v, want 10 requests", buckets)
+	}
+
+	if n, err := db.DeleteAPIUsageBefore(hour); err != nil || n != 1 {
+		t.Errorf("DeleteAPIUsageBefore() = %d, %v, want 1", n, err)
+	}
+	if err := db.DeleteUser("u1"); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if principals, _ := db.TopAPIPrincipals(all, 10); len(principals) != 1 || principals[0].UserID != "u2" {
+		t.Errorf("after deleting u1, TopAPIPrincipals() = %+v, want only u2", principals)
+	}
+}
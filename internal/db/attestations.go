@@ -0,0 +1,54 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// SessionAttestation is a signed report of the environment a session run
+// used, stored as the DSSE envelope produced by the attestation package.
+type SessionAttestation struct {
+	bun.BaseModel `bun:"table:session_attestations"`
+
+	ID        string    `json:"id" bun:"id,pk"`
+	SessionID string    `json:"session_id" bun:"session_id,notnull"`
+	TenantID  string    `json:"tenant_id,omitempty" bun:"tenant_id,notnull"`
+	KeyID     string    `json:"key_id" bun:"key_id,notnull"`
+	Envelope  string    `json:"-" bun:"envelope,notnull"`
+	CreatedAt time.Time `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// CreateSessionAttestation stores a signed session report.
+func (db *DB) CreateSessionAttestation(a SessionAttestation) error {
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now()
+	}
+	_, err := db.conn.NewInsert().Model(&a).Exec(db.ctx())
+	return err
+}
+
+// GetSessionAttestation returns an attestation by ID, or nil if it does not
+// exist.
+func (db *DB) GetSessionAttestation(id string) (*SessionAttestation, error) {
+	var a SessionAttestation
+	err := db.conn.NewSelect().Model(&a).Where("id = ?", id).Scan(db.ctx())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ListSessionAttestations returns a session's attestations, oldest first.
+func (db *DB) ListSessionAttestations(sessionID string) ([]SessionAttestation, error) {
+	var attestations []SessionAttestation
+	err := db.conn.NewSelect().Model(&attestations).
+		Where("session_id = ?", sessionID).
+		OrderExpr("created_at ASC, id ASC").
+		Scan(db.ctx())
+	return attestations, err
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionAttestations(t *testing.T) {
+	db := setupTestDB(t)
+
+	got, err := db.GetSessionAttestation("missing")
+	if err != nil || got != nil {
+		t.Fatalf("GetSessionAttestation(missing) = %v, %v; want nil, nil", got, err)
+	}
+
+	now := time.Now()
+	for _, a := range []SessionAttestation{
+		{ID: "a2", SessionID: "s1", KeyID: "k1", Envelope: `{"payload":"2"}`, CreatedAt: now},
+		{ID: "a1", SessionID: "s1", KeyID: "k1", Envelope: `{"payload":"1"}`, CreatedAt: now.Add(-time.Hour)},
+		{ID: "a3", SessionID: "s2", KeyID: "k1", Envelope: `{"payload":"3"}`},
+	} {
+		if err := db.CreateSessionAttestation(a); err != nil {
+			t.Fatalf("CreateSessionAttestation() error = %v", err)
+		}
+	}
+
+	list, err := db.ListSessionAttestations("s1")
+	if err != nil {
+		t.Fatalf("ListSessionAttestations() error = %v", err)
+	}
+	if len(list) != 2 || list[0].ID != "a1" || list[1].ID != "a2" {
+		t.Errorf("ListSessionAttestations() = %+v, want a1 then a2", list)
+	}
+
+	got, err = db.GetSessionAttestation("a3")
+	if err != nil || got == nil {
+		t.Fatalf("GetSessionAttestation() = %v, %v", got, err)
+	}
+	if got.SessionID != "s2" || got.Envelope != `{"payload":"3"}` || got.CreatedAt.IsZero() {
+		t.Errorf("GetSessionAttestation() = %+v", got)
+	}
+}
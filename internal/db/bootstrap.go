@@ -0,0 +1,193 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// bootstrapSettingKey records in settings when the bootstrap file was
+// applied, so it runs only on a fresh install.
+const bootstrapSettingKey = "bootstrapped_at"
+
+// Bootstrap is the data a fresh install is created with, read from the
+// YAML file named by SORTIE_BOOTSTRAP. Its fields use the same names as
+// the API's JSON.
+type Bootstrap struct {
+	// Tenant configures the default tenant.
+	Tenant *BootstrapTenant `json:"tenant,omitempty"`
+	// Categories are created in the default tenant.
+	Categories []Category `json:"categories,omitempty"`
+	// Apps are created in the default tenant. Categories they name that
+	// are not listed above are created too.
+	Apps []Application `json:"apps,omitempty"`
+}
+
+// BootstrapTenant configures the default tenant.
+type BootstrapTenant struct {
+	Name     string          `json:"name,omitempty"`
+	Settings *TenantSettings `json:"settings,omitempty"`
+	Quotas   *TenantQuotas   `json:"quotas,omitempty"`
+}
+
+// LoadBootstrap reads and validates a bootstrap file.
+func LoadBootstrap(path string) (*Bootstrap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bootstrap file: %w", err)
+	}
+	return ParseBootstrap(data)
+}
+
+// ParseBootstrap parses and validates bootstrap YAML. Unknown fields are
+// rejected, so a misspelt key is not silently ignored.
+func ParseBootstrap(data []byte) (*Bootstrap, error) {
+	var b Bootstrap
+	if err := yaml.UnmarshalStrict(data, &b); err != nil {
+		return nil, fmt.Errorf("failed to parse bootstrap file: %w", err)
+	}
+	if err := b.validate(); err != nil {
+		return nil, fmt.Errorf("invalid bootstrap file: %w", err)
+	}
+	return &b, nil
+}
+
+func (b *Bootstrap) validate() error {
+	if t := b.Tenant; t != nil && t.Settings != nil {
+		if !t.Settings.SpectatePolicy.Valid() {
+			return errors.New("tenant: spectate_policy must be silent, notify, or require_consent")
+		}
+		if t.Settings.UploadPolicy != nil {
+			if err := t.Settings.UploadPolicy.Validate(); err != nil {
+				return fmt.Errorf("tenant: %w", err)
+			}
+		}
+		if t.Settings.MFAPolicy != nil {
+			if err := t.Settings.MFAPolicy.Validate(); err != nil {
+				return fmt.Errorf("tenant: %w", err)
+			}
+		}
+		if t.Settings.Defaults != nil {
+			if err := t.Settings.Defaults.Validate(); err != nil {
+				return fmt.Errorf("tenant: defaults: %w", err)
+			}
+		}
+	}
+
+	names := map[string]bool{}
+	for i, cat := range b.Categories {
+		if cat.Name == "" {
+			return fmt.Errorf("categories[%d]: name is required", i)
+		}
+		if names[cat.Name] {
+			return fmt.Errorf("categories[%d]: category %q is listed more than once", i, cat.Name)
+		}
+		names[cat.Name] = true
+		if cat.Defaults != nil {
+			if err := cat.Defaults.Validate(); err != nil {
+				return fmt.Errorf("category %q: defaults: %w", cat.Name, err)
+			}
+		}
+	}
+
+	ids := map[string]bool{}
+	for i, app := range b.Apps {
+		if app.ID == "" || app.Name == "" {
+			return fmt.Errorf("apps[%d]: id and name are required", i)
+		}
+		if ids[app.ID] {
+			return fmt.Errorf("apps[%d]: app %q is listed more than once", i, app.ID)
+		}
+		ids[app.ID] = true
+		if app.LaunchType == LaunchTypeContainer || app.LaunchType == LaunchTypeWebProxy {
+			if app.ContainerImage == "" {
+				return fmt.Errorf("app %q: container_image is required for container and web_proxy apps", app.ID)
+			}
+		} else if app.URL == "" {
+			return fmt.Errorf("app %q: url is required", app.ID)
+		}
+	}
+	return nil
+}
+
+// ApplyBootstrap creates b's data if this is a fresh install: one that has
+// neither apps nor categories and has not been bootstrapped before. It
+// reports whether it did. Either all of b is created or none of it.
+func (db *DB) ApplyBootstrap(b *Bootstrap) (bool, error) {
+	applied := false
+	err := db.WithTx(func(tx *DB) error {
+		applied = false
+		done, err := tx.GetSetting(bootstrapSettingKey)
+		if err != nil {
+			return err
+		}
+		if done != "" {
+			return nil
+		}
+		// An install from before bootstrapping existed is not fresh
+		apps, err := tx.conn.NewSelect().Model((*Application)(nil)).Count(tx.ctx())
+		if err != nil {
+			return err
+		}
+		cats, err := tx.conn.NewSelect().Model((*Category)(nil)).Count(tx.ctx())
+		if err != nil {
+			return err
+		}
+		if apps == 0 && cats == 0 {
+			if err := tx.bootstrap(b); err != nil {
+				return err
+			}
+			applied = true
+		}
+		return tx.SetSetting(bootstrapSettingKey, time.Now().UTC().Format(time.RFC3339))
+	})
+	return applied, err
+}
+
+func (db *DB) bootstrap(b *Bootstrap) error {
+	if b.Tenant != nil {
+		tenant, err := db.GetTenant(DefaultTenantID)
+		if err != nil {
+			return err
+		}
+		if tenant == nil {
+			return errors.New("default tenant not found")
+		}
+		if b.Tenant.Name != "" {
+			tenant.Name = b.Tenant.Name
+		}
+		if b.Tenant.Settings != nil {
+			tenant.Settings = *b.Tenant.Settings
+		}
+		if b.Tenant.Quotas != nil {
+			tenant.Quotas = *b.Tenant.Quotas
+		}
+		if err := db.UpdateTenant(*tenant); err != nil {
+			return fmt.Errorf("failed to update default tenant: %w", err)
+		}
+	}
+
+	for _, cat := range b.Categories {
+		if cat.ID == "" {
+			cat.ID = fmt.Sprintf("cat-%s-%d", cat.Name, time.Now().UnixNano())
+		}
+		cat.TenantID = DefaultTenantID
+		if err := db.CreateCategory(cat); err != nil {
+			return fmt.Errorf("failed to create category %s: %w", cat.Name, err)
+		}
+	}
+
+	for _, app := range b.Apps {
+		app.TenantID = DefaultTenantID
+		if _, err := db.EnsureCategoryExists(app.Category, DefaultTenantID); err != nil {
+			return fmt.Errorf("failed to create category %s: %w", app.Category, err)
+		}
+		if err := db.CreateApp(app); err != nil {
+			return fmt.Errorf("failed to create app %s: %w", app.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseBootstrap_Example(t *testing.T) {
+	b, err := LoadBootstrap("../../examples/bootstrap.yaml")
+	if err != nil {
+		t.Fatalf("LoadBootstrap() error = %v", err)
+	}
+	if b.Tenant == nil || b.Tenant.Quotas == nil || b.Tenant.Quotas.MaxSessionsPerUser != 3 {
+		t.Errorf("tenant = %+v, want quotas with max_sessions_per_user 3", b.Tenant)
+	}
+	if len(b.Categories) == 0 || len(b.Apps) == 0 {
+		t.Errorf("got %d categories and %d apps, want some of each", len(b.Categories), len(b.Apps))
+	}
+}
+
+func TestParseBootstrap_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"unknown field", "categories:\n  - name: Dev\n    colour: red\n", "colour"},
+		{"category without name", "categories:\n  - description: x\n", "name is required"},
+		{"duplicate category", "categories:\n  - name: Dev\n  - name: Dev\n", "more than once"},
+		{"app without id", "apps:\n  - name: X\n    url: https://x\n", "id and name are required"},
+		{"url app without url", "apps:\n  - id: x\n    name: X\n", "url is required"},
+		{"container app without image", "apps:\n  - id: x\n    name: X\n    launch_type: container\n", "container_image"},
+		{"bad category defaults", "categories:\n  - name: Dev\n    defaults:\n      idle_timeout: -1\n", "idle_timeout"},
+		{"bad spectate policy", "tenant:\n  settings:\n    spectate_policy: loud\n", "spectate_policy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseBootstrap([]byte(tt.yaml))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseBootstrap() error = %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+const testBootstrap = `
+tenant:
+  name: Acme
+  quotas:
+    max_sessions_per_user: 2
+categories:
+  - name: Browsers
+    defaults:
+      idle_timeout: 900
+apps:
+  - id: firefox
+    name: Firefox
+    category: Browsers
+    launch_type: container
+    container_image: jlesage/firefox:latest
+  - id: wiki
+    name: Wiki
+    url: https://wiki.example.com
+    category: Docs
+`
+
+func TestApplyBootstrap_FreshInstall(t *testing.T) {
+	database := setupTestDB(t)
+	b, err := ParseBootstrap([]byte(testBootstrap))
+	if err != nil {
+		t.Fatalf("ParseBootstrap() error = %v", err)
+	}
+
+	applied, err := database.ApplyBootstrap(b)
+	if err != nil || !applied {
+		t.Fatalf("ApplyBootstrap() = %v, %v, want applied", applied, err)
+	}
+
+	tenant, err := database.GetTenant(DefaultTenantID)
+	if err != nil || tenant == nil {
+		t.Fatalf("GetTenant() = %v, %v", tenant, err)
+	}
+	if tenant.Name != "Acme" || tenant.Quotas.MaxSessionsPerUser != 2 {
+		t.Errorf("tenant name = %q, max_sessions_per_user = %d, want Acme and 2", tenant.Name, tenant.Quotas.MaxSessionsPerUser)
+	}
+
+	browsers, err := database.GetCategoryByName("Browsers")
+	if err != nil || browsers == nil {
+		t.Fatalf("GetCategoryByName(Browsers) = %v, %v", browsers, err)
+	}
+	if browsers.Defaults == nil || browsers.Defaults.IdleTimeout != 900 {
+		t.Errorf("Browsers defaults = %+v, want idle_timeout 900", browsers.Defaults)
+	}
+	// Categories named only by apps are created too
+	if docs, _ := database.GetCategoryByName("Docs"); docs == nil {
+		t.Error("category Docs was not created for the wiki app")
+	}
+
+	app, err := database.GetApp("firefox")
+	if err != nil || app == nil {
+		t.Fatalf("GetApp(firefox) = %v, %v", app, err)
+	}
+	if app.ContainerImage != "jlesage/firefox:latest" || app.TenantID != DefaultTenantID {
+		t.Errorf("firefox image = %q, tenant = %q", app.ContainerImage, app.TenantID)
+	}
+
+	// Restarting with the same file changes nothing
+	if err := database.DeleteApp("wiki"); err != nil {
+		t.Fatalf("DeleteApp() error = %v", err)
+	}
+	applied, err = database.ApplyBootstrap(b)
+	if err != nil || applied {
+		t.Errorf("second ApplyBootstrap() = %v, %v, want not applied", applied, err)
+	}
+	if app, _ := database.GetApp("wiki"); app != nil {
+		t.Error("second ApplyBootstrap() recreated a deleted app")
+	}
+}
+
+func TestApplyBootstrap_ExistingInstall(t *testing.T) {
+	database := setupTestDB(t)
+	if err := database.CreateApp(Application{ID: "existing", Name: "Existing", URL: "https://x"}); err != nil {
+		t.Fatalf("CreateApp() error = %v", err)
+	}
+	b, err := ParseBootstrap([]byte(testBootstrap))
+	if err != nil {
+		t.Fatalf("ParseBootstrap() error = %v", err)
+	}
+
+	applied, err := database.ApplyBootstrap(b)
+	if err != nil || applied {
+		t.Fatalf("ApplyBootstrap() = %v, %v, want not applied", applied, err)
+	}
+	if app, _ := database.GetApp("firefox"); app != nil {
+		t.Error("ApplyBootstrap() created apps in an existing install")
+	}
+	if tenant, _ := database.GetTenant(DefaultTenantID); tenant != nil && tenant.Name == "Acme" {
+		t.Error("ApplyBootstrap() changed the default tenant of an existing install")
+	}
+}
+
+func TestApplyBootstrap_RollsBackOnError(t *testing.T) {
+	database := setupTestDB(t)
+	// The second app reuses the first's ID, which validation would catch;
+	// build the Bootstrap directly to make the insert fail.
+	b := &Bootstrap{
+		Categories: []Category{{Name: "Browsers"}},
+		Apps: []Application{
+			{ID: "dup", Name: "One", URL: "https://one"},
+			{ID: "dup", Name: "Two", URL: "https://two"},
+		},
+	}
+
+	if _, err := database.ApplyBootstrap(b); err == nil {
+		t.Fatal("ApplyBootstrap() error = nil, want duplicate app error")
+	}
+	if cat, _ := database.GetCategoryByName("Browsers"); cat != nil {
+		t.Error("category kept after a failed bootstrap")
+	}
+	if v, _ := database.GetSetting(bootstrapSettingKey); v != "" {
+		t.Error("failed bootstrap was recorded as applied")
+	}
+}
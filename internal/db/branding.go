@@ -0,0 +1,113 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// TenantBranding holds branding that admins upload for a tenant: theme
+// colors and fonts, and the uploaded image assets.
+type TenantBranding struct {
+	bun.BaseModel `bun:"table:tenant_branding"`
+
+	TenantID  string                   `json:"tenant_id" bun:"tenant_id,pk"`
+	Theme     BrandingTheme            `json:"theme" bun:"-"`
+	Assets    map[string]BrandingAsset `json:"assets" bun:"-"`
+	UpdatedAt time.Time                `json:"updated_at" bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+
+	// JSON-serialized DB columns
+	ThemeJSON  string `json:"-" bun:"theme"`
+	AssetsJSON string `json:"-" bun:"assets"`
+}
+
+// BrandingTheme maps theme variable names to values, e.g. "primary" to
+// "#4f46e5" or "body" to "Inter, sans-serif".
+type BrandingTheme struct {
+	Colors map[string]string `json:"colors,omitempty"`
+	Fonts  map[string]string `json:"fonts,omitempty"`
+}
+
+// BrandingAsset records where an uploaded branding image is stored.
+type BrandingAsset struct {
+	Path        string    `json:"path"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// MaxBrandingThemeEntries caps the number of colors and fonts each.
+const MaxBrandingThemeEntries = 32
+
+var (
+	brandingKeyPattern   = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+	brandingColorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+)
+
+// Validate checks that theme keys are simple identifiers, colors are hex
+// colors, and fonts are plain font-family lists. Theme values end up in CSS,
+// so anything that could break out of a declaration is rejected.
+func (t BrandingTheme) Validate() error {
+	if len(t.Colors) > MaxBrandingThemeEntries || len(t.Fonts) > MaxBrandingThemeEntries {
+		return fmt.Errorf("theme may define at most %d colors and %d fonts", MaxBrandingThemeEntries, MaxBrandingThemeEntries)
+	}
+	for key, value := range t.Colors {
+		if !brandingKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid color name %q", key)
+		}
+		if !brandingColorPattern.MatchString(value) {
+			return fmt.Errorf("invalid color %q for %q (expected #RGB, #RRGGBB or #RRGGBBAA)", value, key)
+		}
+	}
+	for key, value := range t.Fonts {
+		if !brandingKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid font name %q", key)
+		}
+		if value == "" || len(value) > 200 || strings.ContainsAny(value, ";{}<>\\\n\r") {
+			return fmt.Errorf("invalid font family %q for %q", value, key)
+		}
+	}
+	return nil
+}
+
+// GetTenantBranding returns the branding for a tenant, or nil if none has
+// been saved.
+func (db *DB) GetTenantBranding(tenantID string) (*TenantBranding, error) {
+	var b TenantBranding
+	err := db.conn.NewSelect().Model(&b).Where("tenant_id = ?", tenantID).Scan(db.ctx())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// SaveTenantBranding inserts or replaces a tenant's branding.
+func (db *DB) SaveTenantBranding(branding TenantBranding) error {
+	branding.UpdatedAt = time.Now()
+	_, err := db.conn.NewInsert().Model(&branding).
+		On("CONFLICT (tenant_id) DO UPDATE").
+		Set("theme = EXCLUDED.theme, assets = EXCLUDED.assets, updated_at = EXCLUDED.updated_at").
+		Exec(db.ctx())
+	return err
+}
+
+// ListTenantBranding returns the branding of every tenant that has any.
+func (db *DB) ListTenantBranding() ([]TenantBranding, error) {
+	var branding []TenantBranding
+	err := db.conn.NewSelect().Model(&branding).OrderExpr("tenant_id").Scan(db.ctx())
+	return branding, err
+}
+
+// DeleteTenantBranding removes a tenant's branding. Deleting branding that
+// does not exist is not an error.
+func (db *DB) DeleteTenantBranding(tenantID string) error {
+	_, err := db.conn.NewDelete().Model((*TenantBranding)(nil)).Where("tenant_id = ?", tenantID).Exec(db.ctx())
+	return err
+}
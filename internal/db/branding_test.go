@@ -0,0 +1,113 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTenantBrandingCRUD(t *testing.T) {
+	db := setupTenantTestDB(t)
+
+	got, err := db.GetTenantBranding(DefaultTenantID)
+	if err != nil {
+		t.Fatalf("GetTenantBranding() error = %v", err)
+	}
+	if got != nil {
+		t.Fatalf("GetTenantBranding() = %+v, want nil", got)
+	}
+
+	branding := TenantBranding{
+		TenantID: DefaultTenantID,
+		Theme: BrandingTheme{
+			Colors: map[string]string{"primary": "#4f46e5"},
+			Fonts:  map[string]string{"body": "Inter, sans-serif"},
+		},
+		Assets: map[string]BrandingAsset{
+			"logo": {Path: "default/logo-1.png", ContentType: "image/png", Size: 42, UpdatedAt: time.Now()},
+		},
+	}
+	if err := db.SaveTenantBranding(branding); err != nil {
+		t.Fatalf("SaveTenantBranding() error = %v", err)
+	}
+
+	got, err = db.GetTenantBranding(DefaultTenantID)
+	if err != nil || got == nil {
+		t.Fatalf("GetTenantBranding() = %v, %v", got, err)
+	}
+	if got.Theme.Colors["primary"] != "#4f46e5" || got.Theme.Fonts["body"] != "Inter, sans-serif" {
+		t.Errorf("Theme = %+v", got.Theme)
+	}
+	if got.Assets["logo"].Path != "default/logo-1.png" || got.Assets["logo"].Size != 42 {
+		t.Errorf("Assets = %+v", got.Assets)
+	}
+
+	// Saving again replaces the previous branding
+	branding.Theme = BrandingTheme{Colors: map[string]string{"primary": "#000000"}}
+	branding.Assets = nil
+	if err := db.SaveTenantBranding(branding); err != nil {
+		t.Fatalf("SaveTenantBranding() update error = %v", err)
+	}
+	got, _ = db.GetTenantBranding(DefaultTenantID)
+	if got.Theme.Colors["primary"] != "#000000" || len(got.Theme.Fonts) != 0 || len(got.Assets) != 0 {
+		t.Errorf("after update got %+v", got)
+	}
+
+	all, err := db.ListTenantBranding()
+	if err != nil || len(all) != 1 {
+		t.Errorf("ListTenantBranding() = %v, %v; want 1 entry", all, err)
+	}
+}
+
+func TestDeleteTenantRemovesBranding(t *testing.T) {
+	db := setupTenantTestDB(t)
+
+	if err := db.CreateTenant(Tenant{ID: "t1", Name: "Test", Slug: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveTenantBranding(TenantBranding{TenantID: "t1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteTenant("t1"); err != nil {
+		t.Fatalf("DeleteTenant() error = %v", err)
+	}
+	if got, _ := db.GetTenantBranding("t1"); got != nil {
+		t.Error("branding should be deleted with the tenant")
+	}
+}
+
+func TestBrandingThemeValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		theme   BrandingTheme
+		wantErr bool
+	}{
+		{"empty", BrandingTheme{}, false},
+		{"valid", BrandingTheme{
+			Colors: map[string]string{"primary": "#fff", "accent-2": "#112233", "overlay": "#11223344"},
+			Fonts:  map[string]string{"body": `"Open Sans", Arial, sans-serif`},
+		}, false},
+		{"named color", BrandingTheme{Colors: map[string]string{"primary": "red"}}, true},
+		{"bad color key", BrandingTheme{Colors: map[string]string{"Primary Color": "#fff"}}, true},
+		{"css injection in font", BrandingTheme{Fonts: map[string]string{"body": "Arial; } body { display: none"}}, true},
+		{"html in font", BrandingTheme{Fonts: map[string]string{"body": "</style>"}}, true},
+		{"empty font", BrandingTheme{Fonts: map[string]string{"body": ""}}, true},
+		{"long font", BrandingTheme{Fonts: map[string]string{"body": strings.Repeat("a", 201)}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.theme.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	tooMany := BrandingTheme{Colors: map[string]string{}}
+	for i := 0; i <= MaxBrandingThemeEntries; i++ {
+		tooMany.Colors[fmt.Sprintf("c%d", i)] = "#fff"
+	}
+	if err := tooMany.Validate(); err == nil {
+		t.Error("Validate() accepted too many colors")
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"slices"
 	"time"
+
+	"github.com/uptrace/bun"
 )
 
 // --- Category CRUD ---
@@ -17,14 +19,14 @@ func (db *DB) CreateCategory(cat Category) error {
 	now := time.Now()
 	cat.CreatedAt = now
 	cat.UpdatedAt = now
-	_, err := db.bun.NewInsert().Model(&cat).Exec(ctx())
+	_, err := db.conn.NewInsert().Model(&cat).Exec(db.ctx())
 	return err
 }
 
 // GetCategory retrieves a category by ID
 func (db *DB) GetCategory(id string) (*Category, error) {
 	var cat Category
-	err := db.bun.NewSelect().Model(&cat).Where("id = ?", id).Scan(ctx())
+	err := db.conn.NewSelect().Model(&cat).Where("id = ?", id).Scan(db.ctx())
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -37,7 +39,7 @@ func (db *DB) GetCategory(id string) (*Category, error) {
 // GetCategoryByName retrieves a category by name
 func (db *DB) GetCategoryByName(name string) (*Category, error) {
 	var cat Category
-	err := db.bun.NewSelect().Model(&cat).Where("name = ?", name).Scan(ctx())
+	err := db.conn.NewSelect().Model(&cat).Where("name = ?", name).Scan(db.ctx())
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -50,25 +52,27 @@ func (db *DB) GetCategoryByName(name string) (*Category, error) {
 // ListCategories returns all categories
 func (db *DB) ListCategories() ([]Category, error) {
 	var cats []Category
-	err := db.bun.NewSelect().Model(&cats).OrderExpr("name").Scan(ctx())
+	err := db.conn.NewSelect().Model(&cats).OrderExpr("name").Scan(db.ctx())
 	return cats, err
 }
 
 // ListCategoriesByTenant returns categories for a specific tenant
 func (db *DB) ListCategoriesByTenant(tenantID string) ([]Category, error) {
 	var cats []Category
-	err := db.bun.NewSelect().Model(&cats).Where("tenant_id = ?", tenantID).OrderExpr("name").Scan(ctx())
+	err := db.conn.NewSelect().Model(&cats).Where("tenant_id = ?", tenantID).OrderExpr("name").Scan(db.ctx())
 	return cats, err
 }
 
-// UpdateCategory updates an existing category
+// UpdateCategory updates an existing category's name, description, and
+// defaults
 func (db *DB) UpdateCategory(cat Category) error {
-	result, err := db.bun.NewUpdate().Model((*Category)(nil)).
+	result, err := db.conn.NewUpdate().Model((*Category)(nil)).
 		Set("name = ?", cat.Name).
 		Set("description = ?", cat.Description).
+		Set("defaults = ?", marshalPolicyDefaults(cat.Defaults)).
 		Set("updated_at = ?", time.Now()).
 		Where("id = ?", cat.ID).
-		Exec(ctx())
+		Exec(db.ctx())
 	if err != nil {
 		return err
 	}
@@ -84,7 +88,7 @@ func (db *DB) UpdateCategory(cat Category) error {
 
 // DeleteCategory removes a category by ID
 func (db *DB) DeleteCategory(id string) error {
-	result, err := db.bun.NewDelete().Model((*Category)(nil)).Where("id = ?", id).Exec(ctx())
+	result, err := db.conn.NewDelete().Model((*Category)(nil)).Where("id = ?", id).Exec(db.ctx())
 	if err != nil {
 		return err
 	}
@@ -96,8 +100,8 @@ func (db *DB) DeleteCategory(id string) error {
 		return sql.ErrNoRows
 	}
 	// Clean up junction tables
-	db.bun.NewDelete().Model((*CategoryAdmin)(nil)).Where("category_id = ?", id).Exec(ctx())
-	db.bun.NewDelete().Model((*CategoryApprovedUser)(nil)).Where("category_id = ?", id).Exec(ctx())
+	db.conn.NewDelete().Model((*CategoryAdmin)(nil)).Where("category_id = ?", id).Exec(db.ctx())
+	db.conn.NewDelete().Model((*CategoryApprovedUser)(nil)).Where("category_id = ?", id).Exec(db.ctx())
 	return nil
 }
 
@@ -106,16 +110,16 @@ func (db *DB) DeleteCategory(id string) error {
 // AddCategoryAdmin adds a user as admin of a category
 func (db *DB) AddCategoryAdmin(categoryID, userID string) error {
 	admin := CategoryAdmin{CategoryID: categoryID, UserID: userID}
-	_, err := db.bun.NewInsert().Model(&admin).On("CONFLICT DO NOTHING").Exec(ctx())
+	_, err := db.conn.NewInsert().Model(&admin).On("CONFLICT DO NOTHING").Exec(db.ctx())
 	return err
 }
 
 // RemoveCategoryAdmin removes a user as admin of a category
 func (db *DB) RemoveCategoryAdmin(categoryID, userID string) error {
-	result, err := db.bun.NewDelete().Model((*CategoryAdmin)(nil)).
+	result, err := db.conn.NewDelete().Model((*CategoryAdmin)(nil)).
 		Where("category_id = ?", categoryID).
 		Where("user_id = ?", userID).
-		Exec(ctx())
+		Exec(db.ctx())
 	if err != nil {
 		return err
 	}
@@ -132,31 +136,31 @@ func (db *DB) RemoveCategoryAdmin(categoryID, userID string) error {
 // ListCategoryAdmins returns all admin user IDs for a category
 func (db *DB) ListCategoryAdmins(categoryID string) ([]string, error) {
 	var userIDs []string
-	err := db.bun.NewSelect().Model((*CategoryAdmin)(nil)).
+	err := db.conn.NewSelect().Model((*CategoryAdmin)(nil)).
 		Column("user_id").
 		Where("category_id = ?", categoryID).
 		OrderExpr("user_id").
-		Scan(ctx(), &userIDs)
+		Scan(db.ctx(), &userIDs)
 	return userIDs, err
 }
 
 // IsCategoryAdmin checks if a user is an admin of a category
 func (db *DB) IsCategoryAdmin(userID, categoryID string) (bool, error) {
-	count, err := db.bun.NewSelect().Model((*CategoryAdmin)(nil)).
+	count, err := db.conn.NewSelect().Model((*CategoryAdmin)(nil)).
 		Where("category_id = ?", categoryID).
 		Where("user_id = ?", userID).
-		Count(ctx())
+		Count(db.ctx())
 	return count > 0, err
 }
 
 // GetCategoriesAdminedByUser returns category IDs that a user admins
 func (db *DB) GetCategoriesAdminedByUser(userID string) ([]string, error) {
 	var categoryIDs []string
-	err := db.bun.NewSelect().Model((*CategoryAdmin)(nil)).
+	err := db.conn.NewSelect().Model((*CategoryAdmin)(nil)).
 		Column("category_id").
 		Where("user_id = ?", userID).
 		OrderExpr("category_id").
-		Scan(ctx(), &categoryIDs)
+		Scan(db.ctx(), &categoryIDs)
 	return categoryIDs, err
 }
 
@@ -165,16 +169,16 @@ func (db *DB) GetCategoriesAdminedByUser(userID string) ([]string, error) {
 // AddCategoryApprovedUser adds a user to a category's approved list
 func (db *DB) AddCategoryApprovedUser(categoryID, userID string) error {
 	approved := CategoryApprovedUser{CategoryID: categoryID, UserID: userID}
-	_, err := db.bun.NewInsert().Model(&approved).On("CONFLICT DO NOTHING").Exec(ctx())
+	_, err := db.conn.NewInsert().Model(&approved).On("CONFLICT DO NOTHING").Exec(db.ctx())
 	return err
 }
 
 // RemoveCategoryApprovedUser removes a user from a category's approved list
 func (db *DB) RemoveCategoryApprovedUser(categoryID, userID string) error {
-	result, err := db.bun.NewDelete().Model((*CategoryApprovedUser)(nil)).
+	result, err := db.conn.NewDelete().Model((*CategoryApprovedUser)(nil)).
 		Where("category_id = ?", categoryID).
 		Where("user_id = ?", userID).
-		Exec(ctx())
+		Exec(db.ctx())
 	if err != nil {
 		return err
 	}
@@ -191,20 +195,20 @@ func (db *DB) RemoveCategoryApprovedUser(categoryID, userID string) error {
 // ListCategoryApprovedUsers returns all approved user IDs for a category
 func (db *DB) ListCategoryApprovedUsers(categoryID string) ([]string, error) {
 	var userIDs []string
-	err := db.bun.NewSelect().Model((*CategoryApprovedUser)(nil)).
+	err := db.conn.NewSelect().Model((*CategoryApprovedUser)(nil)).
 		Column("user_id").
 		Where("category_id = ?", categoryID).
 		OrderExpr("user_id").
-		Scan(ctx(), &userIDs)
+		Scan(db.ctx(), &userIDs)
 	return userIDs, err
 }
 
 // IsCategoryApprovedUser checks if a user is approved for a category
 func (db *DB) IsCategoryApprovedUser(userID, categoryID string) (bool, error) {
-	count, err := db.bun.NewSelect().Model((*CategoryApprovedUser)(nil)).
+	count, err := db.conn.NewSelect().Model((*CategoryApprovedUser)(nil)).
 		Where("category_id = ?", categoryID).
 		Where("user_id = ?", userID).
-		Count(ctx())
+		Count(db.ctx())
 	return count > 0, err
 }
 
@@ -219,19 +223,13 @@ func (db *DB) ListVisibleCategoriesForUser(userID string, userRoles []string, te
 	}
 
 	var cats []Category
-	err := db.bun.NewRaw(`
-		SELECT DISTINCT c.id, c.name, c.description, c.tenant_id, c.created_at, c.updated_at
-		FROM categories c
-		INNER JOIN applications a ON a.category = c.name AND a.tenant_id = ?
-		LEFT JOIN category_admins ca ON c.id = ca.category_id AND ca.user_id = ?
-		LEFT JOIN category_approved_users cau ON c.id = cau.category_id AND cau.user_id = ?
-		WHERE c.tenant_id = ?
-		AND (a.visibility = 'public'
-		     OR (a.visibility = 'approved' AND (ca.user_id IS NOT NULL OR cau.user_id IS NOT NULL))
-		     OR (a.visibility = 'admin_only' AND ca.user_id IS NOT NULL))
-		ORDER BY c.name`,
-		tenantID, userID, userID, tenantID,
-	).Scan(ctx(), &cats)
+	err := db.conn.NewSelect().Model(&cats).Distinct().
+		Join("JOIN applications AS a ON a.category = ?TableAlias.name AND a.tenant_id = ?", tenantID).
+		Apply(joinCategoryAccess("?TableAlias.id", userID)).
+		Where("?TableAlias.tenant_id = ?", tenantID).
+		Where(appVisibleWhere("a")).
+		OrderExpr("?TableAlias.name").
+		Scan(db.ctx())
 	return cats, err
 }
 
@@ -244,25 +242,35 @@ func (db *DB) ListAppsForUser(userID string, userRoles []string, tenantID string
 	}
 
 	var apps []Application
-	err := db.bun.NewRaw(`
-		SELECT a.id, a.name, a.description, a.url, a.icon, a.category,
-		       a.visibility, a.launch_type, a.os_type, a.container_image, a.container_port,
-		       a.container_args, a.cpu_request, a.cpu_limit, a.memory_request,
-		       a.memory_limit, a.egress_policy
-		FROM applications a
-		LEFT JOIN categories c ON a.category = c.name AND c.tenant_id = ?
-		LEFT JOIN category_admins ca ON c.id = ca.category_id AND ca.user_id = ?
-		LEFT JOIN category_approved_users cau ON c.id = cau.category_id AND cau.user_id = ?
-		WHERE a.tenant_id = ?
-		AND (a.visibility = 'public'
-		     OR (a.visibility = 'approved' AND (ca.user_id IS NOT NULL OR cau.user_id IS NOT NULL))
-		     OR (a.visibility = 'admin_only' AND ca.user_id IS NOT NULL))
-		ORDER BY a.category, a.name`,
-		tenantID, userID, userID, tenantID,
-	).Scan(ctx(), &apps)
+	err := db.conn.NewSelect().Model(&apps).
+		Join("LEFT JOIN categories AS c ON c.name = ?TableAlias.category AND c.tenant_id = ?", tenantID).
+		Apply(joinCategoryAccess("c.id", userID)).
+		Where("?TableAlias.tenant_id = ?", tenantID).
+		Where(appVisibleWhere("?TableAlias")).
+		OrderExpr("?TableAlias.category, ?TableAlias.name").
+		Scan(db.ctx())
 	return apps, err
 }
 
+// joinCategoryAccess joins the user's category_admins (ca) and
+// category_approved_users (cau) rows for the category whose ID is
+// categoryID, leaving them NULL if the user has none.
+func joinCategoryAccess(categoryID, userID string) func(*bun.SelectQuery) *bun.SelectQuery {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.
+			Join("LEFT JOIN category_admins AS ca ON ca.category_id = "+categoryID+" AND ca.user_id = ?", userID).
+			Join("LEFT JOIN category_approved_users AS cau ON cau.category_id = "+categoryID+" AND cau.user_id = ?", userID)
+	}
+}
+
+// appVisibleWhere is the condition for the app aliased app being visible
+// to a user whose category access was joined by joinCategoryAccess.
+func appVisibleWhere(app string) string {
+	return fmt.Sprintf(`(%[1]s.visibility = 'public'
+		OR (%[1]s.visibility = 'approved' AND (ca.user_id IS NOT NULL OR cau.user_id IS NOT NULL))
+		OR (%[1]s.visibility = 'admin_only' AND ca.user_id IS NOT NULL))`, app)
+}
+
 // EnsureCategoryExists creates a category for the given name if it doesn't exist.
 // Returns the category ID. Used for backwards-compatible auto-creation.
 func (db *DB) EnsureCategoryExists(name, tenantID string) (string, error) {
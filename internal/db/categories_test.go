@@ -390,7 +390,7 @@ func TestListAppsForUser(t *testing.T) {
 
 	// Create apps with app-level visibility
 	for _, app := range []Application{
-		{ID: "app-pub", Name: "Public App", Description: "d", URL: "http://x", Icon: "i", Category: "Public", Visibility: CategoryVisibilityPublic, TenantID: "default"},
+		{ID: "app-pub", Name: "Public App", Description: "d", URL: "http://x", Icon: "i", Category: "Public", Visibility: CategoryVisibilityPublic, TenantID: "default", IdleTimeout: 600},
 		{ID: "app-appr", Name: "Approved App", Description: "d", URL: "http://x", Icon: "i", Category: "Approved", Visibility: CategoryVisibilityApproved, TenantID: "default"},
 		{ID: "app-admin", Name: "Admin App", Description: "d", URL: "http://x", Icon: "i", Category: "Admin Only", Visibility: CategoryVisibilityAdminOnly, TenantID: "default"},
 	} {
@@ -423,6 +423,10 @@ func TestListAppsForUser(t *testing.T) {
 		if apps[0].ID != "app-pub" {
 			t.Errorf("got %s, want app-pub", apps[0].ID)
 		}
+		// Every column is loaded, not just those the listing displays
+		if apps[0].IdleTimeout != 600 || apps[0].TenantID != "default" {
+			t.Errorf("idle_timeout = %d, tenant_id = %q, want 600 and default", apps[0].IdleTimeout, apps[0].TenantID)
+		}
 	})
 
 	t.Run("category admin sees their admin_only apps", func(t *testing.T) {
@@ -545,13 +549,11 @@ func TestDataMigrationExistingCategories(t *testing.T) {
 		}
 	}
 
-	// Force version back to 1 so version 2 (data migration) will run on next Open
-	_, err = conn.Exec("UPDATE schema_migrations SET version = 1")
-	if err != nil {
-		t.Fatalf("failed to force version: %v", err)
-	}
 	conn.Close()
 
+	// Rewind to version 1 so version 2 (data migration) will run on next Open
+	rewindMigrations(t, "sqlite", tmpFile.Name(), 1)
+
 	// Step 2: Open via normal path — should run migration 000002 and create categories
 	database, err := Open(tmpFile.Name())
 	if err != nil {
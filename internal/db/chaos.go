@@ -0,0 +1,20 @@
+package db
+
+import (
+	"context"
+
+	"github.com/rjsadow/sortie/internal/chaos"
+	"github.com/uptrace/bun"
+)
+
+// chaosHook delays queries by the injected database latency.
+type chaosHook struct{}
+
+var _ bun.QueryHook = chaosHook{}
+
+func (chaosHook) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	chaos.DelayQuery(ctx)
+	return ctx
+}
+
+func (chaosHook) AfterQuery(context.Context, *bun.QueryEvent) {}
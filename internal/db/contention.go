@@ -0,0 +1,205 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/uptrace/bun"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteBusyTimeout is how long SQLite waits for another connection's lock
+// before a statement fails with SQLITE_BUSY.
+var sqliteBusyTimeout = 5 * time.Second
+
+// Retry policy for write transactions that fail because the database is
+// locked.
+const (
+	busyRetries   = 5
+	busyBaseDelay = 10 * time.Millisecond
+	busyMaxDelay  = 500 * time.Millisecond
+)
+
+// contention serializes SQLite writes in single-writer mode and counts lock
+// contention. It is shared by a DB and the copies WithContext returns.
+type contention struct {
+	singleWriter atomic.Bool
+	writeMu      sync.Mutex
+
+	busy      atomic.Int64 // lock errors and conflicts seen by write transactions
+	retries   atomic.Int64 // write transactions retried after a lock error
+	failures  atomic.Int64 // write transactions that gave up
+	waits     atomic.Int64 // writes that waited for the single-writer lock
+	waitNanos atomic.Int64 // time spent waiting for the single-writer lock
+}
+
+// writeLockKey marks a context whose writes already hold the single-writer
+// lock, so statements in a transaction do not take it again.
+type writeLockKey struct{}
+
+// heldWriteLockKey marks a statement context that took the single-writer
+// lock in BeforeQuery and must release it in AfterQuery.
+type heldWriteLockKey struct{}
+
+// lockWrites takes the single-writer lock when enabled and returns the
+// function that releases it.
+func (c *contention) lockWrites() func() {
+	if !c.singleWriter.Load() {
+		return func() {}
+	}
+	if !c.writeMu.TryLock() {
+		start := time.Now()
+		c.writeMu.Lock()
+		c.waits.Add(1)
+		c.waitNanos.Add(int64(time.Since(start)))
+	}
+	return c.writeMu.Unlock
+}
+
+// SetSingleWriter makes writes from this process run one at a time, so
+// they never contend for SQLite's write lock. Reads are unaffected. It has
+// no effect on Postgres.
+func (db *DB) SetSingleWriter(enabled bool) {
+	if db.dbType == "sqlite" {
+		db.contention.singleWriter.Store(enabled)
+	}
+}
+
+// WithTx runs fn in a transaction, committing it if fn returns nil. The DB
+// passed to fn runs its queries in the transaction, and its methods that
+// use a transaction of their own join this one.
+//
+// On SQLite the transaction holds the write lock from its start, and on
+// Postgres it is serializable, so a read-check-write sequence in fn cannot
+// interleave with another. Transactions that conflict are retried, so fn
+// may run more than once and must only change state through tx.
+func (db *DB) WithTx(fn func(tx *DB) error) error {
+	var opts *sql.TxOptions
+	if db.dbType == "postgres" {
+		opts = &sql.TxOptions{Isolation: sql.LevelSerializable}
+	}
+	return db.runTx(opts, func(ctx context.Context, tx bun.Tx) error {
+		txDB := *db
+		txDB.conn, txDB.tx, txDB.reqCtx = tx, &tx, ctx
+		return fn(&txDB)
+	})
+}
+
+// runInTx runs fn in a transaction, or in db's transaction if it came from
+// WithTx. Transactions that fail because the database is locked (SQLite)
+// or conflicted with another (Postgres) are retried with exponential
+// backoff, and on SQLite in single-writer mode wait for the process's other
+// writes first. fn may run more than once, so it must only change state
+// through tx.
+func (db *DB) runInTx(fn func(ctx context.Context, tx bun.Tx) error) error {
+	return db.runTx(nil, fn)
+}
+
+func (db *DB) runTx(opts *sql.TxOptions, fn func(ctx context.Context, tx bun.Tx) error) error {
+	ctx := db.ctx()
+	if db.tx != nil {
+		return fn(ctx, *db.tx)
+	}
+
+	if db.dbType == "sqlite" {
+		unlock := db.contention.lockWrites()
+		defer unlock()
+		ctx = context.WithValue(ctx, writeLockKey{}, true)
+	}
+
+	delay := busyBaseDelay
+	for attempt := 0; ; attempt++ {
+		err := db.bun.RunInTx(ctx, opts, fn)
+		if err == nil || !isBusy(err) {
+			return err
+		}
+		db.contention.busy.Add(1)
+		if attempt == busyRetries {
+			db.contention.failures.Add(1)
+			return fmt.Errorf("database is busy after %d attempts: %w", attempt+1, err)
+		}
+		db.contention.retries.Add(1)
+
+		// Full jitter keeps retrying writers from colliding again
+		wait := time.Duration(rand.Int64N(int64(delay))) + time.Millisecond
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return err
+		}
+		delay = min(delay*2, busyMaxDelay)
+	}
+}
+
+// isBusy reports whether err means another connection holds a lock SQLite
+// needed, or that Postgres aborted the transaction in favour of another.
+func isBusy(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// serialization_failure, deadlock_detected
+		return pqErr.Code == "40001" || pqErr.Code == "40P01"
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() & 0xff {
+		case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+			return true
+		}
+		return false
+	}
+	return strings.Contains(err.Error(), "database is locked")
+}
+
+// writeLockHook holds the single-writer lock for writes made outside
+// runInTx.
+type writeLockHook struct {
+	c *contention
+}
+
+var _ bun.QueryHook = (*writeLockHook)(nil)
+
+func (h *writeLockHook) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	if !h.c.singleWriter.Load() || ctx.Value(writeLockKey{}) != nil {
+		return ctx
+	}
+	switch strings.ToUpper(event.Operation()) {
+	case "INSERT", "UPDATE", "DELETE", "REPLACE":
+		return context.WithValue(ctx, heldWriteLockKey{}, h.c.lockWrites())
+	}
+	return ctx
+}
+
+func (h *writeLockHook) AfterQuery(ctx context.Context, _ *bun.QueryEvent) {
+	if unlock, ok := ctx.Value(heldWriteLockKey{}).(func()); ok {
+		unlock()
+	}
+}
+
+// WritePrometheus writes the database lock contention metrics in the
+// Prometheus text exposition format.
+func (db *DB) WritePrometheus(w io.Writer) {
+	c := db.contention
+	metric := func(name, kind, help string, value float64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", name, help, name, kind, name, value)
+	}
+	metric("sortie_db_busy_total", "counter", "Write transactions that found the database locked or conflicted with another.",
+		float64(c.busy.Load()))
+	metric("sortie_db_busy_retries_total", "counter", "Write transactions retried after a lock error or conflict.",
+		float64(c.retries.Load()))
+	metric("sortie_db_busy_failures_total", "counter", "Write transactions that failed after exhausting their retries.",
+		float64(c.failures.Load()))
+	metric("sortie_db_write_lock_waits_total", "counter", "Writes that waited for the single-writer lock.",
+		float64(c.waits.Load()))
+	metric("sortie_db_write_lock_wait_seconds_total", "counter", "Time writes spent waiting for the single-writer lock.",
+		time.Duration(c.waitNanos.Load()).Seconds())
+}
@@ -0,0 +1,271 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// openContendedSQLite opens two handles on one SQLite file with a short
+// busy timeout, so lock errors surface quickly.
+func openContendedSQLite(t *testing.T) (*DB, *DB) {
+	t.Helper()
+	if testDBType() != "sqlite" {
+		t.Skip("SQLite lock contention test")
+	}
+	defer func(d time.Duration) { sqliteBusyTimeout = d }(sqliteBusyTimeout)
+	sqliteBusyTimeout = 20 * time.Millisecond
+
+	path := filepath.Join(t.TempDir(), "contended.db")
+	a, err := OpenDB("sqlite", path)
+	if err != nil {
+		t.Fatalf("OpenDB() error = %v", err)
+	}
+	t.Cleanup(func() { a.Close() })
+	b, err := OpenDB("sqlite", path)
+	if err != nil {
+		t.Fatalf("OpenDB() error = %v", err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return a, b
+}
+
+// holdWriteLock takes the write lock on database until the returned
+// function is called.
+func holdWriteLock(t *testing.T, database *DB) func() {
+	t.Helper()
+	conn, err := database.bun.DB.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Conn() error = %v", err)
+	}
+	if _, err := conn.ExecContext(context.Background(), "BEGIN IMMEDIATE"); err != nil {
+		t.Fatalf("BEGIN IMMEDIATE error = %v", err)
+	}
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			conn.ExecContext(context.Background(), "ROLLBACK")
+			conn.Close()
+		})
+	}
+	t.Cleanup(release)
+	return release
+}
+
+func insertSetting(key string) func(context.Context, bun.Tx) error {
+	return func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewInsert().Model(&Setting{Key: key, Value: "v", UpdatedAt: time.Now()}).Exec(ctx)
+		return err
+	}
+}
+
+func TestOpenDB_SQLiteSettingsOnEveryConnection(t *testing.T) {
+	if testDBType() != "sqlite" {
+		t.Skip("SQLite connection settings test")
+	}
+	database := newTestDatabase(t)
+
+	// Hold two connections at once so the pool must open a second one
+	ctx := context.Background()
+	for i := range 2 {
+		conn, err := database.bun.DB.Conn(ctx)
+		if err != nil {
+			t.Fatalf("Conn() error = %v", err)
+		}
+		defer conn.Close()
+
+		var timeout int
+		var mode string
+		if err := conn.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&timeout); err != nil {
+			t.Fatalf("busy_timeout query error = %v", err)
+		}
+		if err := conn.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&mode); err != nil {
+			t.Fatalf("journal_mode query error = %v", err)
+		}
+		if timeout != int(sqliteBusyTimeout.Milliseconds()) || mode != "wal" {
+			t.Errorf("connection %d: busy_timeout = %d, journal_mode = %q", i, timeout, mode)
+		}
+	}
+}
+
+func TestRunInTx_RetriesBusy(t *testing.T) {
+	a, b := openContendedSQLite(t)
+
+	release := holdWriteLock(t, a)
+	time.AfterFunc(100*time.Millisecond, release)
+
+	if err := b.runInTx(insertSetting("retried")); err != nil {
+		t.Fatalf("runInTx() error = %v, want success once the lock is released", err)
+	}
+	if got := b.contention.retries.Load(); got == 0 {
+		t.Error("retries = 0, want the transaction retried")
+	}
+	if got := b.contention.failures.Load(); got != 0 {
+		t.Errorf("failures = %d, want 0", got)
+	}
+	if v, err := b.GetSetting("retried"); err != nil || v != "v" {
+		t.Errorf("GetSetting() = %q, %v", v, err)
+	}
+}
+
+func TestRunInTx_GivesUpWhenBusy(t *testing.T) {
+	a, b := openContendedSQLite(t)
+	holdWriteLock(t, a)
+
+	err := b.runInTx(insertSetting("blocked"))
+	if err == nil || !isBusy(err) {
+		t.Fatalf("runInTx() error = %v, want database is locked", err)
+	}
+	if got := b.contention.failures.Load(); got != 1 {
+		t.Errorf("failures = %d, want 1", got)
+	}
+	if got := b.contention.retries.Load(); got != busyRetries {
+		t.Errorf("retries = %d, want %d", got, busyRetries)
+	}
+}
+
+func TestSingleWriter(t *testing.T) {
+	if testDBType() != "sqlite" {
+		t.Skip("SQLite single-writer test")
+	}
+	defer func(d time.Duration) { sqliteBusyTimeout = d }(sqliteBusyTimeout)
+	sqliteBusyTimeout = time.Millisecond
+	database := newTestDatabase(t)
+	database.SetSingleWriter(true)
+
+	// A write made while a transaction holds SQLite's write lock would fail
+	// after the 1ms busy timeout; in single-writer mode it waits instead.
+	inTx := make(chan struct{})
+	finishTx := make(chan struct{})
+	txDone := make(chan error, 1)
+	go func() {
+		txDone <- database.runInTx(func(ctx context.Context, tx bun.Tx) error {
+			if err := insertSetting("tx")(ctx, tx); err != nil {
+				return err
+			}
+			close(inTx)
+			<-finishTx
+			return nil
+		})
+	}()
+	<-inTx
+
+	writeDone := make(chan error, 1)
+	go func() { writeDone <- database.SetSetting("standalone", "v") }()
+	select {
+	case err := <-writeDone:
+		t.Fatalf("SetSetting() finished during the transaction: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(finishTx)
+	if err := <-txDone; err != nil {
+		t.Fatalf("runInTx() error = %v", err)
+	}
+	if err := <-writeDone; err != nil {
+		t.Fatalf("SetSetting() error = %v", err)
+	}
+	if got := database.contention.waits.Load(); got != 1 {
+		t.Errorf("waits = %d, want 1", got)
+	}
+
+	var buf bytes.Buffer
+	database.WritePrometheus(&buf)
+	for _, want := range []string{"sortie_db_busy_total 0", "sortie_db_write_lock_waits_total 1", "sortie_db_write_lock_wait_seconds_total"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("WritePrometheus() missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestWithTx_CommitsAndRollsBack(t *testing.T) {
+	database := newTestDatabase(t)
+
+	err := database.WithTx(func(tx *DB) error {
+		if err := tx.SetSetting("committed", "v"); err != nil {
+			return err
+		}
+		// Reads inside the transaction see its writes
+		v, err := tx.GetSetting("committed")
+		if err != nil || v != "v" {
+			t.Errorf("GetSetting() in tx = %q, %v", v, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx() error = %v", err)
+	}
+	if v, _ := database.GetSetting("committed"); v != "v" {
+		t.Errorf("GetSetting() after commit = %q, want v", v)
+	}
+
+	errAbort := errors.New("abort")
+	err = database.WithTx(func(tx *DB) error {
+		if err := tx.SetSetting("rolled-back", "v"); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if err != errAbort {
+		t.Fatalf("WithTx() error = %v, want the closure's error", err)
+	}
+	if v, _ := database.GetSetting("rolled-back"); v != "" {
+		t.Errorf("GetSetting() after rollback = %q, want empty", v)
+	}
+}
+
+func TestWithTx_JoinsNestedTransactions(t *testing.T) {
+	database := newTestDatabase(t)
+	if err := database.SaveOIDCState("state", "/home", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("SaveOIDCState() error = %v", err)
+	}
+
+	errAbort := errors.New("abort")
+	err := database.WithTx(func(tx *DB) error {
+		// ConsumeOIDCState runs its own transaction, which must join this one
+		if url, _, err := tx.ConsumeOIDCState("state"); err != nil || url != "/home" {
+			t.Errorf("ConsumeOIDCState() = %q, %v", url, err)
+		}
+		return errAbort
+	})
+	if err != errAbort {
+		t.Fatalf("WithTx() error = %v, want the closure's error", err)
+	}
+
+	// Rolling back the outer transaction undid the nested delete
+	if url, _, err := database.ConsumeOIDCState("state"); err != nil || url != "/home" {
+		t.Errorf("ConsumeOIDCState() after rollback = %q, %v, want the state kept", url, err)
+	}
+}
+
+func TestWithTx_SingleWriter(t *testing.T) {
+	if testDBType() != "sqlite" {
+		t.Skip("SQLite single-writer test")
+	}
+	database := newTestDatabase(t)
+	database.SetSingleWriter(true)
+	defer database.SetSingleWriter(false)
+
+	// Writes in the transaction must not wait on the lock it already holds
+	done := make(chan error, 1)
+	go func() {
+		done <- database.WithTx(func(tx *DB) error {
+			return tx.SetSetting("k", "v")
+		})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("WithTx() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WithTx() deadlocked in single-writer mode")
+	}
+}
@@ -0,0 +1,592 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// dataModels lists every table copied by SyncData, ordered so that rows
+// referenced by foreign keys are written before the rows that reference them.
+var dataModels = []any{
+	(*Tenant)(nil),
+	(*TenantBranding)(nil),
+	(*User)(nil),
+	(*Category)(nil),
+	(*CategoryAdmin)(nil),
+	(*CategoryApprovedUser)(nil),
+	(*SidecarTemplate)(nil),
+	(*Application)(nil),
+	(*Template)(nil),
+	(*AppSpec)(nil),
+	(*Setting)(nil),
+	(*OIDCState)(nil),
+	(*AuditLog)(nil),
+	(*Analytics)(nil),
+	(*Session)(nil),
+	(*SessionShare)(nil),
+	(*Recording)(nil),
+	(*SessionAttestation)(nil),
+	(*QuotaBurst)(nil),
+	(*ArchivedSession)(nil),
+	(*SessionHistory)(nil),
+	(*SessionFileEvent)(nil),
+	(*RefreshToken)(nil),
+	(*IdPToken)(nil),
+	(*APIToken)(nil),
+	(*UserMFA)(nil),
+	(*MFABackupCode)(nil),
+	(*WebAuthnCredential)(nil),
+	(*WebAuthnChallenge)(nil),
+	(*PasswordResetToken)(nil),
+	(*HealthEvent)(nil),
+	(*JobRun)(nil),
+	(*SessionLaunch)(nil),
+	(*ScheduledSession)(nil),
+	(*APIUsage)(nil),
+	(*Project)(nil),
+	(*ProjectMember)(nil),
+	(*StatusAnnouncement)(nil),
+	(*RegistryCredential)(nil),
+	(*LintWarning)(nil),
+	(*RemoteCatalog)(nil),
+	(*SettingChangeRequest)(nil),
+}
+
+// syncBatchSize is the number of rows written per INSERT statement.
+const syncBatchSize = 100
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// SyncDataOptions configures SyncData.
+type SyncDataOptions struct {
+	// DryRun computes the changes without writing to the target.
+	DryRun bool
+}
+
+// TableSyncReport summarizes the changes and verification of one table.
+type TableSyncReport struct {
+	Table          string `json:"table"`
+	Inserted       int    `json:"inserted"`
+	Updated        int    `json:"updated"`
+	Deleted        int    `json:"deleted"`
+	SourceRows     int    `json:"source_rows"`
+	TargetRows     int    `json:"target_rows"`
+	SourceChecksum string `json:"source_checksum"`
+	TargetChecksum string `json:"target_checksum"`
+}
+
+// Verified reports whether the target table matches the source.
+func (r TableSyncReport) Verified() bool {
+	return r.SourceRows == r.TargetRows && r.SourceChecksum == r.TargetChecksum
+}
+
+// tableColumns describes the columns of a table as they exist in a database.
+type tableColumns struct {
+	table *schema.Table
+	names []string
+	types []reflect.Type // model field types; nil for unmapped columns
+	pks   []int          // indexes of the primary key columns
+	data  []int          // indexes of the remaining columns
+}
+
+// tableSnapshot holds the normalized rows of a table keyed by primary key.
+type tableSnapshot struct {
+	cols   *tableColumns
+	rows   map[string][]any
+	hashes map[string][sha256.Size]byte
+}
+
+// checksum combines the row hashes in primary key order.
+func (s *tableSnapshot) checksum() string {
+	keys := make([]string, 0, len(s.hashes))
+	for k := range s.hashes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		sum := s.hashes[k]
+		h.Write(sum[:])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// tableDiff is the set of changes needed to bring a target table in line
+// with the source.
+type tableDiff struct {
+	cols    *tableColumns
+	inserts [][]any
+	updates [][]any
+	deletes [][]any // target rows missing from the source
+}
+
+// SyncData copies every table from src to dst, converting column values
+// between database types. Rows are compared by primary key, so only rows
+// that were added, changed, or removed since a previous run are written;
+// repeated runs can therefore be used to catch up a target before cutover.
+// All writes happen in a single transaction on dst. Unless DryRun is set,
+// the returned reports include row counts and checksums verified after the
+// copy.
+func SyncData(src, dst *DB, opts SyncDataOptions) ([]TableSyncReport, error) {
+	reports := make([]TableSyncReport, len(dataModels))
+	diffs := make([]tableDiff, len(dataModels))
+
+	for i, model := range dataModels {
+		name := src.bun.Table(reflect.TypeOf(model).Elem()).Name
+
+		srcSnap, dstSnap, err := loadSnapshots(src, dst, name, true)
+		if err != nil {
+			return nil, err
+		}
+
+		diff := tableDiff{cols: srcSnap.cols}
+		for key, row := range srcSnap.rows {
+			dstHash, ok := dstSnap.hashes[key]
+			switch {
+			case !ok:
+				diff.inserts = append(diff.inserts, row)
+			case dstHash != srcSnap.hashes[key]:
+				diff.updates = append(diff.updates, row)
+			}
+		}
+		for key := range dstSnap.hashes {
+			if _, ok := srcSnap.hashes[key]; !ok {
+				diff.deletes = append(diff.deletes, dstSnap.rows[key])
+			}
+		}
+		diffs[i] = diff
+
+		reports[i] = TableSyncReport{
+			Table:          name,
+			Inserted:       len(diff.inserts),
+			Updated:        len(diff.updates),
+			Deleted:        len(diff.deletes),
+			SourceRows:     len(srcSnap.hashes),
+			TargetRows:     len(dstSnap.hashes),
+			SourceChecksum: srcSnap.checksum(),
+			TargetChecksum: dstSnap.checksum(),
+		}
+	}
+
+	if opts.DryRun {
+		return reports, nil
+	}
+
+	err := dst.runInTx(func(txCtx context.Context, tx bun.Tx) error {
+		// Delete in reverse dependency order, then insert and update forwards
+		for i := len(diffs) - 1; i >= 0; i-- {
+			if err := applyDeletes(txCtx, tx, diffs[i]); err != nil {
+				return err
+			}
+		}
+		for _, diff := range diffs {
+			if err := applyInserts(txCtx, tx, diff); err != nil {
+				return err
+			}
+			if err := applyUpdates(txCtx, tx, diff); err != nil {
+				return err
+			}
+			if err := dst.resetSequences(txCtx, tx, diff.cols.table); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	verified, err := VerifyData(src, dst)
+	if err != nil {
+		return nil, err
+	}
+	for i := range reports {
+		reports[i].SourceRows = verified[i].SourceRows
+		reports[i].TargetRows = verified[i].TargetRows
+		reports[i].SourceChecksum = verified[i].SourceChecksum
+		reports[i].TargetChecksum = verified[i].TargetChecksum
+	}
+	return reports, nil
+}
+
+// VerifyData compares the row count and checksum of every table in src and
+// dst without writing to either database.
+func VerifyData(src, dst *DB) ([]TableSyncReport, error) {
+	reports := make([]TableSyncReport, len(dataModels))
+	for i, model := range dataModels {
+		name := src.bun.Table(reflect.TypeOf(model).Elem()).Name
+
+		srcSnap, dstSnap, err := loadSnapshots(src, dst, name, false)
+		if err != nil {
+			return nil, err
+		}
+
+		reports[i] = TableSyncReport{
+			Table:          name,
+			SourceRows:     len(srcSnap.hashes),
+			TargetRows:     len(dstSnap.hashes),
+			SourceChecksum: srcSnap.checksum(),
+			TargetChecksum: dstSnap.checksum(),
+		}
+	}
+	return reports, nil
+}
+
+// loadSnapshots reads a table from both databases, failing if their columns
+// differ (for example, when one side has not been migrated).
+func loadSnapshots(src, dst *DB, tableName string, keepRows bool) (*tableSnapshot, *tableSnapshot, error) {
+	srcSnap, err := src.loadSnapshot(tableName, keepRows)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read source table %s: %w", tableName, err)
+	}
+	dstSnap, err := dst.loadSnapshot(tableName, keepRows)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read target table %s: %w", tableName, err)
+	}
+	if strings.Join(srcSnap.cols.names, ",") != strings.Join(dstSnap.cols.names, ",") {
+		return nil, nil, fmt.Errorf("table %s has columns %v in the source but %v in the target",
+			tableName, srcSnap.cols.names, dstSnap.cols.names)
+	}
+	return srcSnap, dstSnap, nil
+}
+
+// loadSnapshot reads every row of a table, converting mapped columns to the
+// canonical Go type of their model field. Rows are only retained when
+// keepRows is set; hashes are always computed.
+func (db *DB) loadSnapshot(tableName string, keepRows bool) (*tableSnapshot, error) {
+	cols, err := db.loadColumns(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	quoted := make([]string, len(cols.names))
+	for i, name := range cols.names {
+		quoted[i] = quoteIdent(name)
+	}
+	rows, err := db.conn.QueryContext(db.ctx(), fmt.Sprintf("SELECT %s FROM %s", strings.Join(quoted, ", "), quoteIdent(tableName)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snap := &tableSnapshot{cols: cols, hashes: make(map[string][sha256.Size]byte)}
+	if keepRows {
+		snap.rows = make(map[string][]any)
+	}
+
+	raw := make([]any, len(cols.names))
+	dest := make([]any, len(cols.names))
+	for i := range raw {
+		dest[i] = &raw[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make([]any, len(cols.names))
+		for i := range cols.names {
+			v, err := convertValue(raw[i], cols.types[i])
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", cols.names[i], err)
+			}
+			row[i] = v
+		}
+
+		key := cols.rowKey(row)
+		snap.hashes[key] = rowHash(row)
+		if keepRows {
+			snap.rows[key] = row
+		}
+	}
+	return snap, rows.Err()
+}
+
+// loadColumns reads the column names of a table, sorted, and matches them
+// to the fields of its data model.
+func (db *DB) loadColumns(tableName string) (*tableColumns, error) {
+	var table *schema.Table
+	for _, model := range dataModels {
+		if t := db.bun.Table(reflect.TypeOf(model).Elem()); t.Name == tableName {
+			table = t
+		}
+	}
+	if table == nil {
+		return nil, fmt.Errorf("no model for table %s", tableName)
+	}
+
+	rows, err := db.conn.QueryContext(db.ctx(), fmt.Sprintf("SELECT * FROM %s LIMIT 0", quoteIdent(tableName)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	cols := &tableColumns{table: table, names: names, types: make([]reflect.Type, len(names))}
+	for i, name := range names {
+		f, ok := table.FieldMap[name]
+		if !ok {
+			cols.data = append(cols.data, i)
+			continue
+		}
+		cols.types[i] = f.IndirectType
+		if f.IsPK {
+			cols.pks = append(cols.pks, i)
+		} else {
+			cols.data = append(cols.data, i)
+		}
+	}
+	if len(cols.pks) != len(table.PKs) {
+		return nil, fmt.Errorf("table %s is missing primary key columns", tableName)
+	}
+	return cols, nil
+}
+
+// rowKey encodes a row's primary key values.
+func (c *tableColumns) rowKey(row []any) string {
+	parts := make([]string, len(c.pks))
+	for i, idx := range c.pks {
+		parts[i] = fmt.Sprint(row[idx])
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// pkCondition returns a WHERE clause matching a row by primary key.
+func (c *tableColumns) pkCondition() string {
+	conds := make([]string, len(c.pks))
+	for i, idx := range c.pks {
+		conds[i] = quoteIdent(c.names[idx]) + " = ?"
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// quoteIdent quotes an identifier; both SQLite and Postgres accept double
+// quotes.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func applyInserts(c context.Context, tx bun.Tx, diff tableDiff) error {
+	cols := diff.cols
+	quoted := make([]string, len(cols.names))
+	for i, name := range cols.names {
+		quoted[i] = quoteIdent(name)
+	}
+	placeholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(quoted)), ", ") + ")"
+
+	for start := 0; start < len(diff.inserts); start += syncBatchSize {
+		batch := diff.inserts[start:min(start+syncBatchSize, len(diff.inserts))]
+
+		values := make([]string, len(batch))
+		args := make([]any, 0, len(batch)*len(quoted))
+		for i, row := range batch {
+			values[i] = placeholders
+			args = append(args, row...)
+		}
+
+		query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s",
+			quoteIdent(cols.table.Name), strings.Join(quoted, ", "), strings.Join(values, ", "))
+		if _, err := tx.ExecContext(c, query, args...); err != nil {
+			return fmt.Errorf("failed to insert into %s: %w", cols.table.Name, err)
+		}
+	}
+	return nil
+}
+
+func applyUpdates(c context.Context, tx bun.Tx, diff tableDiff) error {
+	cols := diff.cols
+	if len(cols.data) == 0 {
+		return nil
+	}
+
+	sets := make([]string, len(cols.data))
+	for i, idx := range cols.data {
+		sets[i] = quoteIdent(cols.names[idx]) + " = ?"
+	}
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s",
+		quoteIdent(cols.table.Name), strings.Join(sets, ", "), cols.pkCondition())
+
+	for _, row := range diff.updates {
+		args := make([]any, 0, len(row))
+		for _, idx := range cols.data {
+			args = append(args, row[idx])
+		}
+		for _, idx := range cols.pks {
+			args = append(args, row[idx])
+		}
+		if _, err := tx.ExecContext(c, query, args...); err != nil {
+			return fmt.Errorf("failed to update %s: %w", cols.table.Name, err)
+		}
+	}
+	return nil
+}
+
+func applyDeletes(c context.Context, tx bun.Tx, diff tableDiff) error {
+	cols := diff.cols
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s", quoteIdent(cols.table.Name), cols.pkCondition())
+
+	for _, row := range diff.deletes {
+		args := make([]any, len(cols.pks))
+		for i, idx := range cols.pks {
+			args[i] = row[idx]
+		}
+		if _, err := tx.ExecContext(c, query, args...); err != nil {
+			return fmt.Errorf("failed to delete from %s: %w", cols.table.Name, err)
+		}
+	}
+	return nil
+}
+
+// resetSequences advances Postgres serial sequences past the copied IDs so
+// that rows created after cutover do not collide. SQLite tracks this itself.
+func (db *DB) resetSequences(c context.Context, tx bun.Tx, table *schema.Table) error {
+	if db.dbType != "postgres" {
+		return nil
+	}
+	for _, f := range table.PKs {
+		if !f.AutoIncrement {
+			continue
+		}
+		query := fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%s', '%s'), COALESCE(MAX(%s), 0) + 1, false) FROM %s",
+			table.Name, f.Name, f.SQLName, table.SQLName)
+		if _, err := tx.ExecContext(c, query); err != nil {
+			return fmt.Errorf("failed to reset sequence for %s.%s: %w", table.Name, f.Name, err)
+		}
+	}
+	return nil
+}
+
+// rowHash hashes a row's canonical values, so equal rows hash equally
+// regardless of the database they were read from.
+func rowHash(row []any) [sha256.Size]byte {
+	var b strings.Builder
+	for _, v := range row {
+		var s string
+		switch v := v.(type) {
+		case nil:
+			b.WriteString("N;")
+			continue
+		case time.Time:
+			s = "T" + v.Format(time.RFC3339Nano)
+		case bool:
+			s = "B" + strconv.FormatBool(v)
+		case int64:
+			s = "I" + strconv.FormatInt(v, 10)
+		case float64:
+			s = "F" + strconv.FormatFloat(v, 'g', -1, 64)
+		default:
+			s = "S" + fmt.Sprint(v)
+		}
+		fmt.Fprintf(&b, "%d:%s;", len(s), s)
+	}
+	return sha256.Sum256([]byte(b.String()))
+}
+
+// convertValue converts a raw column value to the canonical Go type for a
+// model field of type typ: time.Time (UTC, microsecond precision), bool,
+// int64, float64, or string. A nil typ leaves the value's type unchanged.
+func convertValue(v any, typ reflect.Type) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+
+	if typ == nil {
+		// Unmapped column: keep the driver's type, normalizing timestamps
+		if _, ok := v.(time.Time); ok {
+			return convertTime(v)
+		}
+		return v, nil
+	}
+	if typ == timeType {
+		return convertTime(v)
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		switch v := v.(type) {
+		case bool:
+			return v, nil
+		case int64:
+			return v != 0, nil
+		case string:
+			return strconv.ParseBool(v)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch v := v.(type) {
+		case int64:
+			return v, nil
+		case float64:
+			return int64(v), nil
+		case string:
+			return strconv.ParseInt(v, 10, 64)
+		}
+	case reflect.Float32, reflect.Float64:
+		switch v := v.(type) {
+		case float64:
+			return v, nil
+		case int64:
+			return float64(v), nil
+		case string:
+			return strconv.ParseFloat(v, 64)
+		}
+	default:
+		return fmt.Sprint(v), nil
+	}
+	return nil, fmt.Errorf("cannot convert %T to %s", v, typ)
+}
+
+// timeLayouts are the formats timestamps may be stored in as text, most
+// commonly by SQLite.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+func convertTime(v any) (any, error) {
+	var t time.Time
+	switch v := v.(type) {
+	case time.Time:
+		t = v
+	case int64:
+		t = time.Unix(v, 0)
+	case string:
+		if v == "" {
+			return nil, nil
+		}
+		var err error
+		for _, layout := range timeLayouts {
+			if t, err = time.Parse(layout, v); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse time %q", v)
+		}
+	default:
+		return nil, fmt.Errorf("cannot convert %T to time", v)
+	}
+	// Postgres stores microseconds; truncate so checksums match after a copy
+	return t.UTC().Truncate(time.Microsecond), nil
+}
@@ -0,0 +1,265 @@
+package db
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// openSourceSQLite opens a standalone SQLite database to copy data from.
+func openSourceSQLite(t *testing.T) *DB {
+	t.Helper()
+	database, err := OpenDB("sqlite", filepath.Join(t.TempDir(), "source.db"))
+	if err != nil {
+		t.Fatalf("failed to open source database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func reportFor(t *testing.T, reports []TableSyncReport, table string) TableSyncReport {
+	t.Helper()
+	for _, r := range reports {
+		if r.Table == table {
+			return r
+		}
+	}
+	t.Fatalf("no report for table %s", table)
+	return TableSyncReport{}
+}
+
+func assertVerified(t *testing.T, reports []TableSyncReport) {
+	t.Helper()
+	if len(reports) != len(dataModels) {
+		t.Errorf("got %d reports, want %d", len(reports), len(dataModels))
+	}
+	for _, r := range reports {
+		if !r.Verified() {
+			t.Errorf("table %s not verified: %+v", r.Table, r)
+		}
+	}
+}
+
+func TestSyncData(t *testing.T) {
+	src := openSourceSQLite(t)
+	dst := setupTestDB(t)
+	now := time.Now().Truncate(time.Second)
+
+	app := Application{
+		ID: "sync-app", Name: "Sync App", Description: "d",
+		URL: "http://x", Icon: "i", Category: "c",
+		LaunchType: LaunchTypeContainer, ContainerArgs: []string{"--flag", "value"},
+	}
+	if err := src.CreateApp(app); err != nil {
+		t.Fatalf("CreateApp() error = %v", err)
+	}
+	user := User{
+		ID: "sync-user", Username: "sync", PasswordHash: "hash",
+		Roles: []string{"admin"}, CreatedAt: now, UpdatedAt: now,
+	}
+	if err := src.CreateUser(user); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if err := src.UpdateUserLastLogin(user.ID, now); err != nil {
+		t.Fatalf("UpdateUserLastLogin() error = %v", err)
+	}
+	if err := src.SetUserDisabled(user.ID, true); err != nil {
+		t.Fatalf("SetUserDisabled() error = %v", err)
+	}
+	for _, id := range []string{"sync-sess-1", "sync-sess-2"} {
+		s := Session{
+			ID: id, UserID: user.ID, AppID: app.ID,
+			PodName: "pod-" + id, Status: SessionStatusRunning,
+			CreatedAt: now, UpdatedAt: now,
+		}
+		if err := src.CreateSession(s); err != nil {
+			t.Fatalf("CreateSession() error = %v", err)
+		}
+	}
+	rec := Recording{
+		ID: "sync-rec", SessionID: "sync-sess-1", UserID: user.ID,
+		Filename: "rec.vncrec", Status: RecordingStatusRecording, CreatedAt: now,
+	}
+	if err := src.CreateRecording(rec); err != nil {
+		t.Fatalf("CreateRecording() error = %v", err)
+	}
+	if err := src.UpdateRecordingComplete(rec.ID, "path/rec.vncrec", 1024, 12.5); err != nil {
+		t.Fatalf("UpdateRecordingComplete() error = %v", err)
+	}
+	for _, action := range []string{"LOGIN", "LAUNCH_APP"} {
+		if err := src.LogAudit(user.Username, action, "details"); err != nil {
+			t.Fatalf("LogAudit() error = %v", err)
+		}
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		reports, err := SyncData(src, dst, SyncDataOptions{DryRun: true})
+		if err != nil {
+			t.Fatalf("SyncData() error = %v", err)
+		}
+		if r := reportFor(t, reports, "sessions"); r.Inserted != 2 || r.TargetRows != 0 {
+			t.Errorf("sessions report = %+v, want 2 planned inserts into an empty table", r)
+		}
+		got, err := dst.GetUserByID(user.ID)
+		if err != nil {
+			t.Fatalf("GetUserByID() error = %v", err)
+		}
+		if got != nil {
+			t.Error("dry run wrote to the target")
+		}
+	})
+
+	t.Run("initial copy", func(t *testing.T) {
+		reports, err := SyncData(src, dst, SyncDataOptions{})
+		if err != nil {
+			t.Fatalf("SyncData() error = %v", err)
+		}
+		assertVerified(t, reports)
+
+		got, err := dst.GetUserByID(user.ID)
+		if err != nil || got == nil {
+			t.Fatalf("GetUserByID() = %v, %v", got, err)
+		}
+		if !got.Disabled || got.LastLoginAt == nil || !got.LastLoginAt.Equal(now) {
+			t.Errorf("user = %+v, want disabled with last login %v", got, now)
+		}
+
+		gotApp, err := dst.GetApp(app.ID)
+		if err != nil || gotApp == nil {
+			t.Fatalf("GetApp() = %v, %v", gotApp, err)
+		}
+		if len(gotApp.ContainerArgs) != 2 || gotApp.ContainerArgs[1] != "value" {
+			t.Errorf("ContainerArgs = %v, want [--flag value]", gotApp.ContainerArgs)
+		}
+
+		gotRec, err := dst.GetRecording(rec.ID)
+		if err != nil || gotRec == nil {
+			t.Fatalf("GetRecording() = %v, %v", gotRec, err)
+		}
+		if gotRec.DurationSeconds != 12.5 || gotRec.CompletedAt == nil {
+			t.Errorf("recording = %+v, want completed with duration 12.5", gotRec)
+		}
+
+		// New rows in the target must not collide with copied IDs
+		if err := dst.LogAudit("admin", "AFTER_CUTOVER", "details"); err != nil {
+			t.Fatalf("LogAudit() after copy error = %v", err)
+		}
+		logs, err := dst.GetAuditLogs(10)
+		if err != nil {
+			t.Fatalf("GetAuditLogs() error = %v", err)
+		}
+		if len(logs) != 3 {
+			t.Errorf("got %d audit logs, want 3", len(logs))
+		}
+	})
+
+	t.Run("incremental sync", func(t *testing.T) {
+		// Undo the target-only audit entry, then change the source
+		if _, err := dst.ExecRaw("DELETE FROM audit_log WHERE action = ?", "AFTER_CUTOVER"); err != nil {
+			t.Fatalf("ExecRaw() error = %v", err)
+		}
+		if err := src.SetUserDisabled(user.ID, false); err != nil {
+			t.Fatalf("SetUserDisabled() error = %v", err)
+		}
+		if err := src.DeleteSession("sync-sess-2"); err != nil {
+			t.Fatalf("DeleteSession() error = %v", err)
+		}
+		if err := src.LogAudit(user.Username, "LOGOUT", "details"); err != nil {
+			t.Fatalf("LogAudit() error = %v", err)
+		}
+
+		reports, err := SyncData(src, dst, SyncDataOptions{})
+		if err != nil {
+			t.Fatalf("SyncData() error = %v", err)
+		}
+		assertVerified(t, reports)
+
+		if r := reportFor(t, reports, "users"); r.Inserted != 0 || r.Updated != 1 || r.Deleted != 0 {
+			t.Errorf("users report = %+v, want 1 update", r)
+		}
+		if r := reportFor(t, reports, "sessions"); r.Inserted != 0 || r.Updated != 0 || r.Deleted != 1 {
+			t.Errorf("sessions report = %+v, want 1 delete", r)
+		}
+		if r := reportFor(t, reports, "audit_log"); r.Inserted != 1 || r.Updated != 0 || r.Deleted != 0 {
+			t.Errorf("audit_log report = %+v, want 1 insert", r)
+		}
+
+		got, err := dst.GetUserByID(user.ID)
+		if err != nil || got == nil {
+			t.Fatalf("GetUserByID() = %v, %v", got, err)
+		}
+		if got.Disabled {
+			t.Error("expected user to be re-enabled in target")
+		}
+	})
+
+	t.Run("no changes", func(t *testing.T) {
+		reports, err := SyncData(src, dst, SyncDataOptions{})
+		if err != nil {
+			t.Fatalf("SyncData() error = %v", err)
+		}
+		for _, r := range reports {
+			if r.Inserted+r.Updated+r.Deleted != 0 {
+				t.Errorf("table %s changed on a repeat run: %+v", r.Table, r)
+			}
+		}
+	})
+
+	t.Run("verify detects drift", func(t *testing.T) {
+		if _, err := dst.ExecRaw("UPDATE users SET display_name = ? WHERE id = ?", "changed", user.ID); err != nil {
+			t.Fatalf("ExecRaw() error = %v", err)
+		}
+
+		reports, err := VerifyData(src, dst)
+		if err != nil {
+			t.Fatalf("VerifyData() error = %v", err)
+		}
+		r := reportFor(t, reports, "users")
+		if r.Verified() {
+			t.Error("expected users checksum mismatch")
+		}
+		if r.SourceRows != r.TargetRows {
+			t.Errorf("row counts differ: %+v", r)
+		}
+	})
+}
+
+func TestConvertValue(t *testing.T) {
+	want := time.Date(2026, 3, 4, 5, 6, 7, 123456000, time.UTC)
+
+	tests := []struct {
+		name string
+		in   any
+		typ  any
+		want any
+	}{
+		{"nil", nil, "", nil},
+		{"sqlite bool", int64(1), false, true},
+		{"bytes to string", []byte("abc"), "", "abc"},
+		{"int", int64(42), int(0), int64(42)},
+		{"float from int", int64(3), float64(0), float64(3)},
+		{"sqlite timestamp", "2026-03-04 05:06:07.123456789+00:00", time.Time{}, want},
+		{"sqlite current_timestamp", "2026-03-04 05:06:07", time.Time{}, want.Truncate(time.Second)},
+		{"zoned time", want.In(time.FixedZone("x", 3600)), time.Time{}, want},
+		{"empty time", "", time.Time{}, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := convertValue(tc.in, reflect.TypeOf(tc.typ))
+			if err != nil {
+				t.Fatalf("convertValue() error = %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("convertValue() = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("invalid time", func(t *testing.T) {
+		if _, err := convertValue("yesterday", timeType); err == nil {
+			t.Error("expected error for unparseable time")
+		}
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/rjsadow/sortie/internal/chaos"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/pgdialect"
 	"github.com/uptrace/bun/dialect/sqlitedialect"
@@ -32,12 +33,17 @@ const (
 type Category struct {
 	bun.BaseModel `bun:"table:categories"`
 
-	ID          string    `json:"id" bun:"id,pk"`
-	Name        string    `json:"name" bun:"name,notnull"`
-	Description string    `json:"description" bun:"description"`
-	TenantID    string    `json:"tenant_id,omitempty" bun:"tenant_id"`
-	CreatedAt   time.Time `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
-	UpdatedAt   time.Time `json:"updated_at" bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+	ID          string `json:"id" bun:"id,pk"`
+	Name        string `json:"name" bun:"name,notnull"`
+	Description string `json:"description" bun:"description"`
+	TenantID    string `json:"tenant_id,omitempty" bun:"tenant_id"`
+	// Defaults are session settings for the category's apps that do not
+	// set their own.
+	Defaults  *PolicyDefaults `json:"defaults,omitempty" bun:"-"`
+	CreatedAt time.Time       `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	UpdatedAt time.Time       `json:"updated_at" bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+
+	DefaultsJSON string `json:"-" bun:"defaults"`
 }
 
 // LaunchType represents how an application is launched
@@ -76,6 +82,88 @@ type Application struct {
 	ResourceLimits *ResourceLimits    `json:"resource_limits,omitempty" bun:"-"`
 	EgressPolicy   *EgressPolicy      `json:"egress_policy,omitempty" bun:"-"`
 	TenantID       string             `json:"tenant_id,omitempty" bun:"tenant_id"`
+	// AuditSessionActivity logs in-session actions (clipboard, file
+	// transfer, printing, disconnects) to the audit log.
+	AuditSessionActivity bool `json:"audit_session_activity,omitempty" bun:"audit_session_activity,notnull"`
+	// WelcomeMessage is markdown shown to the owner when a session first
+	// connects, until they dismiss it.
+	WelcomeMessage string `json:"welcome_message,omitempty" bun:"welcome_message,notnull"`
+	// Sidecars names the sidecar templates added to the app's session pods,
+	// after any attached to its tenant.
+	Sidecars []string `json:"sidecars,omitempty" bun:"-"`
+	// DNS customizes hostname and name resolution in the app's session pods.
+	DNS *DNSConfig `json:"dns,omitempty" bun:"-"`
+	// Scheduling pins the app's session pods to nodes.
+	Scheduling *Scheduling `json:"scheduling,omitempty" bun:"-"`
+	// ImagePullSecret names the Secret in the sessions namespace used to
+	// pull the app's images from a private registry. Empty uses its
+	// tenant's default, if any.
+	ImagePullSecret string `json:"image_pull_secret,omitempty" bun:"image_pull_secret,notnull"`
+	// AttestSessions stores a signed report of each session's environment
+	// when it ends.
+	AttestSessions bool `json:"attest_sessions,omitempty" bun:"attest_sessions,notnull"`
+	// Schedule limits when sessions of the app may be launched.
+	Schedule *AppSchedule `json:"schedule,omitempty" bun:"-"`
+	// SidecarImages overrides the built-in sidecar images for the app's
+	// session pods.
+	SidecarImages *SidecarImages `json:"sidecar_images,omitempty" bun:"-"`
+	// RDPSettings tunes the RDP connection guacd opens for Windows apps.
+	RDPSettings *RDPSettings `json:"rdp_settings,omitempty" bun:"-"`
+	// Credentials points to the secrets that hold the login for the app's
+	// RDP server. The secrets are read when a client connects and are
+	// never stored on the app.
+	Credentials *CredentialRef `json:"credentials,omitempty" bun:"-"`
+	// IdleTimeout is the idle timeout in seconds for the app's sessions.
+	// 0 inherits from the category, tenant, or server.
+	IdleTimeout int64 `json:"idle_timeout,omitempty" bun:"idle_timeout,notnull"`
+	// LockTimeout is how long in seconds the app's sessions may go without
+	// input before their stream is locked until the owner signs in again.
+	// 0 inherits from the category, tenant, or server.
+	LockTimeout int64 `json:"lock_timeout,omitempty" bun:"lock_timeout,notnull"`
+	// RecordingPolicy says whether the app's sessions are recorded
+	// automatically. Empty inherits from the category, tenant, or server.
+	RecordingPolicy RecordingPolicy `json:"recording_policy,omitempty" bun:"recording_policy,notnull"`
+	// WarmPoolSize is how many idle pods are kept running for the app, so
+	// new sessions can claim one instead of waiting for a cold start.
+	WarmPoolSize int `json:"warm_pool_size,omitempty" bun:"warm_pool_size,notnull"`
+	// HomeVolume gives each user of the app a persistent volume, mounted
+	// in every session they launch, so their files outlive the session.
+	HomeVolume *HomeVolume `json:"home_volume,omitempty" bun:"-"`
+	// Containers run next to the app container in its session pods, such
+	// as a database for an IDE. The session is ready once all of them are.
+	Containers []AppContainer `json:"containers,omitempty" bun:"-"`
+	// SharedVolumes mounts volumes shared with Containers in the app
+	// container.
+	SharedVolumes []SharedVolumeMount `json:"shared_volumes,omitempty" bun:"-"`
+	// InitContainers run to completion, in order, before the session's
+	// containers start, e.g. to seed data or set up a license.
+	InitContainers []InitContainer `json:"init_containers,omitempty" bun:"-"`
+	// HibernateOnIdle hibernates the app's idle sessions instead of
+	// expiring them: their pods are deleted, but their workspace is kept
+	// on a volume until the session is resumed or deleted.
+	HibernateOnIdle bool `json:"hibernate_on_idle,omitempty" bun:"hibernate_on_idle,notnull"`
+	// SLO sets the availability objectives the app's launches are
+	// measured against.
+	SLO *AppSLO `json:"slo,omitempty" bun:"-"`
+	// ClipboardPolicy says which way clipboard data may flow between the
+	// browser and the app's sessions. Empty allows both ways.
+	ClipboardPolicy ClipboardPolicy `json:"clipboard_policy,omitempty" bun:"clipboard_policy,notnull"`
+	// FileTransfer says which way files may be transferred between the
+	// browser and the app's sessions. Empty allows both ways.
+	FileTransfer FileTransferPolicy `json:"file_transfer,omitempty" bun:"file_transfer,notnull"`
+	// MaxConcurrentSessions limits the app's active sessions across all
+	// users, such as to the seats of its license. 0 means no limit.
+	MaxConcurrentSessions int `json:"max_concurrent_sessions,omitempty" bun:"max_concurrent_sessions,notnull"`
+	// ActiveSessions is the app's active sessions, reported in API
+	// responses for apps with MaxConcurrentSessions set.
+	ActiveSessions *int `json:"active_sessions,omitempty" bun:"-"`
+	// LaunchConfirmation is shown with the app's launch preview, and may
+	// require users to confirm the preview before launching.
+	LaunchConfirmation *LaunchConfirmation `json:"launch_confirmation,omitempty" bun:"-"`
+	// PrePullSchedule is a cron expression for when the app's images are
+	// pulled onto nodes ahead of launches, in its schedule's timezone or
+	// UTC. Empty pre-pulls only when an admin asks.
+	PrePullSchedule string `json:"prepull_schedule,omitempty" bun:"prepull_schedule,notnull"`
 
 	// Flattened DB columns for ResourceLimits (not exported to JSON)
 	CPURequest    string `json:"-" bun:"cpu_request"`
@@ -84,8 +172,21 @@ type Application struct {
 	MemoryLimit   string `json:"-" bun:"memory_limit"`
 
 	// JSON-serialized DB columns
-	ContainerArgsJSON string `json:"-" bun:"container_args"`
-	EgressPolicyJSON  string `json:"-" bun:"egress_policy"`
+	ContainerArgsJSON      string `json:"-" bun:"container_args"`
+	EgressPolicyJSON       string `json:"-" bun:"egress_policy"`
+	SidecarsJSON           string `json:"-" bun:"sidecars"`
+	DNSJSON                string `json:"-" bun:"dns"`
+	SchedulingJSON         string `json:"-" bun:"scheduling"`
+	ScheduleJSON           string `json:"-" bun:"schedule"`
+	SidecarImagesJSON      string `json:"-" bun:"sidecar_images"`
+	RDPSettingsJSON        string `json:"-" bun:"rdp_settings"`
+	CredentialsJSON        string `json:"-" bun:"credentials"`
+	HomeVolumeJSON         string `json:"-" bun:"home_volume"`
+	ContainersJSON         string `json:"-" bun:"containers"`
+	SharedVolumesJSON      string `json:"-" bun:"shared_volumes"`
+	InitContainersJSON     string `json:"-" bun:"init_containers"`
+	SLOJSON                string `json:"-" bun:"slo"`
+	LaunchConfirmationJSON string `json:"-" bun:"launch_confirmation"`
 }
 
 // AppConfig is the JSON structure for apps.json
@@ -115,8 +216,11 @@ type Template struct {
 	Maintainer        string          `json:"maintainer,omitempty" bun:"maintainer"`
 	DocumentationURL  string          `json:"documentation_url,omitempty" bun:"documentation_url"`
 	RecommendedLimits *ResourceLimits `json:"recommended_limits,omitempty" bun:"-"`
-	CreatedAt         time.Time       `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
-	UpdatedAt         time.Time       `json:"updated_at" bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+	// Catalog is the remote catalog the template was synced from, or empty
+	// for a local template.
+	Catalog   string    `json:"catalog,omitempty" bun:"catalog,notnull"`
+	CreatedAt time.Time `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	UpdatedAt time.Time `json:"updated_at" bun:"updated_at,nullzero,notnull,default:current_timestamp"`
 
 	// Flattened DB columns for RecommendedLimits (not exported to JSON)
 	CPURequest    string `json:"-" bun:"cpu_request"`
@@ -124,6 +228,10 @@ type Template struct {
 	MemoryRequest string `json:"-" bun:"memory_request"`
 	MemoryLimit   string `json:"-" bun:"memory_limit"`
 
+	// CatalogHash is the hash of the template's content as last synced
+	// from its catalog.
+	CatalogHash string `json:"-" bun:"catalog_hash,notnull"`
+
 	// JSON-serialized DB columns
 	ContainerArgsJSON string `json:"-" bun:"container_args"`
 	TagsJSON          string `json:"-" bun:"tags"`
@@ -145,24 +253,28 @@ type AuditLog struct {
 	Action    string    `json:"action" bun:"action"`
 	Details   string    `json:"details" bun:"details"`
 	TenantID  string    `json:"-" bun:"tenant_id"`
+	RequestID string    `json:"request_id,omitempty" bun:"request_id,notnull"`
 }
 
 // User represents a user account
 type User struct {
 	bun.BaseModel `bun:"table:users"`
 
-	ID             string    `json:"id" bun:"id,pk"`
-	Username       string    `json:"username" bun:"username,unique,notnull"`
-	Email          string    `json:"email,omitempty" bun:"email"`
-	DisplayName    string    `json:"display_name,omitempty" bun:"display_name"`
-	PasswordHash   string    `json:"-" bun:"password_hash"`
-	Roles          []string  `json:"roles" bun:"-"`
-	AuthProvider   string    `json:"auth_provider,omitempty" bun:"auth_provider"`
-	AuthProviderID string    `json:"auth_provider_id,omitempty" bun:"auth_provider_id"`
-	TenantID       string    `json:"tenant_id,omitempty" bun:"tenant_id"`
-	TenantRoles    []string  `json:"tenant_roles,omitempty" bun:"-"`
-	CreatedAt      time.Time `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
-	UpdatedAt      time.Time `json:"updated_at" bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+	ID             string     `json:"id" bun:"id,pk"`
+	Username       string     `json:"username" bun:"username,unique,notnull"`
+	Email          string     `json:"email,omitempty" bun:"email"`
+	DisplayName    string     `json:"display_name,omitempty" bun:"display_name"`
+	PasswordHash   string     `json:"-" bun:"password_hash"`
+	Roles          []string   `json:"roles" bun:"-"`
+	AuthProvider   string     `json:"auth_provider,omitempty" bun:"auth_provider"`
+	AuthProviderID string     `json:"auth_provider_id,omitempty" bun:"auth_provider_id"`
+	TenantID       string     `json:"tenant_id,omitempty" bun:"tenant_id"`
+	TenantRoles    []string   `json:"tenant_roles,omitempty" bun:"-"`
+	Disabled       bool       `json:"disabled" bun:"disabled,notnull"`
+	LastLoginAt    *time.Time `json:"last_login_at,omitempty" bun:"last_login_at"`
+	Locale         string     `json:"locale,omitempty" bun:"locale,notnull"` // Preferred language; empty negotiates from Accept-Language
+	CreatedAt      time.Time  `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	UpdatedAt      time.Time  `json:"updated_at" bun:"updated_at,nullzero,notnull,default:current_timestamp"`
 
 	// JSON-serialized DB columns
 	RolesJSON       string `json:"-" bun:"roles"`
@@ -170,21 +282,43 @@ type User struct {
 }
 
 // SessionStatus represents the status of a container session.
-// Valid states: creating, running, failed, stopped, expired
+// Valid states: creating, running, failed, stopped, expired, hibernated
 // State machine:
-//   creating -> running (pod ready)
-//   creating -> failed  (pod creation failed)
-//   running  -> stopped (user terminated)
-//   running  -> expired (timeout cleanup)
-//   running  -> failed  (runtime error)
+//   creating   -> running    (pod ready)
+//   creating   -> failed     (pod creation failed)
+//   running    -> stopped    (user terminated)
+//   running    -> expired    (timeout cleanup)
+//   running    -> hibernated (timeout cleanup, app hibernates on idle)
+//   running    -> failed     (runtime error)
+//   hibernated -> creating   (user resumed)
 type SessionStatus string
 
 const (
-	SessionStatusCreating SessionStatus = "creating"
-	SessionStatusRunning  SessionStatus = "running"
-	SessionStatusFailed   SessionStatus = "failed"
-	SessionStatusStopped  SessionStatus = "stopped"
-	SessionStatusExpired  SessionStatus = "expired"
+	SessionStatusCreating   SessionStatus = "creating"
+	SessionStatusRunning    SessionStatus = "running"
+	SessionStatusFailed     SessionStatus = "failed"
+	SessionStatusStopped    SessionStatus = "stopped"
+	SessionStatusExpired    SessionStatus = "expired"
+	SessionStatusHibernated SessionStatus = "hibernated"
+)
+
+// SessionSubstatus narrows down what a creating session is waiting on, for
+// runners that can report it. It is kept when the session fails so the
+// stage it failed in stays visible, and cleared when it starts running.
+// A running session's substatus is degraded once its containers restart,
+// and rescheduling while it moves to a new workload after an eviction.
+type SessionSubstatus string
+
+const (
+	SubstatusScheduling         SessionSubstatus = "scheduling"          // Waiting for a node
+	SubstatusPullingImage       SessionSubstatus = "pulling_image"       // Pulling a container image
+	SubstatusInitializing       SessionSubstatus = "initializing"        // App's init containers running
+	SubstatusStartingSidecars   SessionSubstatus = "starting_sidecars"   // Injected sidecars not yet ready
+	SubstatusStartingContainers SessionSubstatus = "starting_containers" // App's extra containers not yet ready
+	SubstatusStartingApp        SessionSubstatus = "starting_app"        // App container not yet ready
+	SubstatusWaitingForDisplay  SessionSubstatus = "waiting_for_display" // VNC/RDP display not yet reachable
+	SubstatusDegraded           SessionSubstatus = "degraded"            // Containers have restarted
+	SubstatusRescheduling       SessionSubstatus = "rescheduling"        // Workload evicted, moving to a new one
 )
 
 // Session represents an active container session
@@ -201,6 +335,28 @@ type Session struct {
 	TenantID    string        `json:"tenant_id,omitempty" bun:"tenant_id"`
 	CreatedAt   time.Time     `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
 	UpdatedAt   time.Time     `json:"updated_at" bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+	// WelcomeDismissedAt is when the owner dismissed the app's welcome message.
+	WelcomeDismissedAt *time.Time `json:"welcome_dismissed_at,omitempty" bun:"welcome_dismissed_at"`
+	// LockedAt is when the session's stream was locked for going idle. It
+	// is cleared when the owner signs in again to unlock it.
+	LockedAt *time.Time `json:"locked_at,omitempty" bun:"locked_at"`
+	// Substatus and SubstatusDetail describe launch progress while creating.
+	Substatus       SessionSubstatus `json:"substatus,omitempty" bun:"substatus,notnull"`
+	SubstatusDetail string           `json:"substatus_detail,omitempty" bun:"substatus_detail,notnull"`
+	// SidecarImage is the built-in display sidecar image the session's
+	// workload runs, if it runs one.
+	SidecarImage string `json:"sidecar_image,omitempty" bun:"sidecar_image,notnull"`
+	// Hostname is the subdomain a web_proxy session is served at, when
+	// session hostnames are enabled.
+	Hostname string `json:"hostname,omitempty" bun:"hostname,notnull"`
+	// RestartCount is how many times the containers of the session's
+	// current workload have restarted.
+	RestartCount int `json:"restart_count,omitempty" bun:"restart_count,notnull"`
+	// RescheduleCount is how many times the session has been moved to a
+	// new workload after its workload was evicted.
+	RescheduleCount int `json:"reschedule_count,omitempty" bun:"reschedule_count,notnull"`
+	// ProjectID is the project the session was launched in, if any.
+	ProjectID string `json:"project_id,omitempty" bun:"project_id,notnull"`
 }
 
 // EnvVar represents an environment variable for an AppSpec
@@ -240,22 +396,346 @@ type EgressPolicy struct {
 	Rules []EgressRule `json:"rules,omitempty"` // Egress rules
 }
 
+// DNSConfig customizes hostname and name resolution inside session pods so
+// sessions can reach internal services that cluster DNS does not know about.
+// Policy is a Kubernetes dnsPolicy: "ClusterFirst" (default), "Default" or
+// "None"; with "None" at least one nameserver is required.
+type DNSConfig struct {
+	Policy      string      `json:"policy,omitempty"`
+	Hostname    string      `json:"hostname,omitempty"`    // Pod hostname; defaults to the pod name
+	Nameservers []string    `json:"nameservers,omitempty"` // Up to 3 IP addresses
+	Searches    []string    `json:"searches,omitempty"`    // Search domains
+	Options     []DNSOption `json:"options,omitempty"`     // resolv.conf options, e.g. ndots
+	HostAliases []HostAlias `json:"host_aliases,omitempty"`
+}
+
+// SidecarImages overrides the built-in sidecar images for an app, e.g. for
+// an app that needs a specific guacd build. Empty fields use the globally
+// configured images.
+type SidecarImages struct {
+	VNC     string `json:"vnc,omitempty"`     // VNC sidecar for Linux container apps
+	Browser string `json:"browser,omitempty"` // Browser sidecar for web proxy apps
+	Guacd   string `json:"guacd,omitempty"`   // guacd sidecar for Windows apps
+}
+
+// RDPSettings are the RDP connection parameters guacd uses for a Windows
+// app. Zero values keep the defaults: standard RDP security with the
+// server certificate ignored, no domain, no gateway and no console session.
+// The performance flags turn on desktop effects that are off by default to
+// save bandwidth.
+type RDPSettings struct {
+	// Security is the security mode: "any", "nla", "nla-ext", "tls",
+	// "vmconnect" or "rdp" (default).
+	Security string `json:"security,omitempty"`
+	// IgnoreCert ignores the server's certificate; nil means true.
+	IgnoreCert      *bool  `json:"ignore_cert,omitempty"`
+	Domain          string `json:"domain,omitempty"`
+	GatewayHostname string `json:"gateway_hostname,omitempty"` // Remote Desktop Gateway to connect through
+	GatewayPort     int    `json:"gateway_port,omitempty"`     // Defaults to 443
+	GatewayDomain   string `json:"gateway_domain,omitempty"`
+	Console         bool   `json:"console,omitempty"` // Attach to the server's console session
+
+	EnableWallpaper          bool `json:"enable_wallpaper,omitempty"`
+	EnableTheming            bool `json:"enable_theming,omitempty"`
+	EnableFontSmoothing      bool `json:"enable_font_smoothing,omitempty"`
+	EnableFullWindowDrag     bool `json:"enable_full_window_drag,omitempty"`
+	EnableDesktopComposition bool `json:"enable_desktop_composition,omitempty"`
+	EnableMenuAnimations     bool `json:"enable_menu_animations,omitempty"`
+}
+
+// CredentialRef names the secrets in the configured secrets provider that
+// hold an app's RDP login. Rotating the secrets changes the login of new
+// connections without editing the app.
+type CredentialRef struct {
+	Username    string `json:"username,omitempty"`     // Literal username, used when UsernameKey is empty
+	UsernameKey string `json:"username_key,omitempty"` // Secret holding the username
+	PasswordKey string `json:"password_key"`           // Secret holding the password
+}
+
+// DNSOption is a resolv.conf option such as ndots:2.
+type DNSOption struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+}
+
+// HostAlias maps hostnames to an IP address in the pod's /etc/hosts.
+type HostAlias struct {
+	IP        string   `json:"ip"`
+	Hostnames []string `json:"hostnames"`
+}
+
+// Scheduling pins an app's session pods to nodes, such as a GPU or
+// Windows node pool, with the Kubernetes scheduling fields of the same
+// names.
+type Scheduling struct {
+	// NodeSelector holds node labels a pod's node must have.
+	NodeSelector map[string]string `json:"node_selector,omitempty"`
+	// Tolerations let pods run on nodes with matching taints.
+	Tolerations []Toleration `json:"tolerations,omitempty"`
+	// NodeAffinity constrains or prefers nodes by label expressions.
+	NodeAffinity *NodeAffinity `json:"node_affinity,omitempty"`
+}
+
+// Toleration lets a pod run on nodes with a matching taint. Operator is
+// "Equal" (default) or "Exists"; an empty Effect matches all effects.
+type Toleration struct {
+	Key      string `json:"key,omitempty"`
+	Operator string `json:"operator,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Effect   string `json:"effect,omitempty"` // "NoSchedule", "PreferNoSchedule" or "NoExecute"
+	// TolerationSeconds is how long a pod stays on a node after a
+	// NoExecute taint is added; nil means forever.
+	TolerationSeconds *int64 `json:"toleration_seconds,omitempty"`
+}
+
+// NodeAffinity selects nodes by label expressions. A pod may only run on
+// a node that matches one of the Required terms, if any, and favours
+// nodes matching the Preferred terms by their weights.
+type NodeAffinity struct {
+	Required  []NodeSelectorTerm         `json:"required,omitempty"`
+	Preferred []WeightedNodeSelectorTerm `json:"preferred,omitempty"`
+}
+
+// NodeSelectorTerm matches nodes that meet all of its expressions.
+type NodeSelectorTerm struct {
+	MatchExpressions []NodeSelectorRequirement `json:"match_expressions"`
+}
+
+// NodeSelectorRequirement is a node label expression. Operator is "In",
+// "NotIn", "Exists", "DoesNotExist", "Gt" or "Lt".
+type NodeSelectorRequirement struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values,omitempty"`
+}
+
+// WeightedNodeSelectorTerm is a preferred term with a weight from 1 to 100.
+type WeightedNodeSelectorTerm struct {
+	Weight int32            `json:"weight"`
+	Term   NodeSelectorTerm `json:"term"`
+}
+
+// HomeVolume is a per-user persistent volume mounted in an app's sessions.
+// It is created the first time a user launches the app and reattached on
+// every later launch; size and storage class only apply on creation.
+type HomeVolume struct {
+	MountPath    string `json:"mount_path"`              // Absolute path in the app container
+	Size         string `json:"size,omitempty"`          // Requested capacity, e.g. "10Gi"
+	StorageClass string `json:"storage_class,omitempty"` // Empty uses the cluster default
+	FSGroup      *int64 `json:"fs_group,omitempty"`      // Group given ownership of the volume
+}
+
+// AppContainer is a container an app runs next to its app container, such
+// as a database or language server. It shares the session pod's network,
+// so the app reaches it on localhost, and can share files with the app
+// through shared volumes.
+type AppContainer struct {
+	// Name identifies the container within the pod. The container is
+	// named "app-<name>".
+	Name      string            `json:"name"`
+	Image     string            `json:"image"`
+	Command   []string          `json:"command,omitempty"`
+	Args      []string          `json:"args,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+	Ports     []int             `json:"ports,omitempty"` // The first port is probed for readiness
+	Resources *ResourceLimits   `json:"resources,omitempty"`
+	// VolumeMounts mounts shared volumes in the container.
+	VolumeMounts []SharedVolumeMount `json:"volume_mounts,omitempty"`
+}
+
+// InitContainer is a step an app runs before its session containers start,
+// such as seeding data or setting up a license. It must exit successfully
+// for the session to start, and can leave files for the app in shared
+// volumes.
+type InitContainer struct {
+	// Name identifies the container within the pod. The container is
+	// named "init-<name>".
+	Name      string            `json:"name"`
+	Image     string            `json:"image"`
+	Command   []string          `json:"command,omitempty"`
+	Args      []string          `json:"args,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+	Resources *ResourceLimits   `json:"resources,omitempty"`
+	// VolumeMounts mounts shared volumes in the container.
+	VolumeMounts []SharedVolumeMount `json:"volume_mounts,omitempty"`
+}
+
+// SharedVolumeMount mounts a shared volume: scratch storage that lives as
+// long as the session pod. A shared volume is created for each name the
+// app's containers mount.
+type SharedVolumeMount struct {
+	Name      string `json:"name"`
+	MountPath string `json:"mount_path"`
+	ReadOnly  bool   `json:"read_only,omitempty"`
+}
+
+// AppSchedule limits when sessions of an app may be launched. When Windows
+// is set, launches are only allowed inside one of them; launches are never
+// allowed during a blackout. Times are in Timezone, an IANA zone name
+// (default UTC).
+type AppSchedule struct {
+	Timezone  string           `json:"timezone,omitempty"`
+	Windows   []ScheduleWindow `json:"windows,omitempty"`
+	Blackouts []Blackout       `json:"blackouts,omitempty"`
+	// StopAtWindowEnd stops running sessions once the schedule closes,
+	// at the end of a window or the start of a blackout.
+	StopAtWindowEnd bool `json:"stop_at_window_end,omitempty"`
+}
+
+// ScheduleWindow is a recurring weekly window, such as business hours. An
+// End at or before Start runs past midnight into the next day.
+type ScheduleWindow struct {
+	Days  []string `json:"days,omitempty"` // "mon" to "sun"; empty = every day
+	Start string   `json:"start"`          // "HH:MM"
+	End   string   `json:"end"`            // "HH:MM", or "24:00" for midnight
+}
+
+// Blackout is a one-off period, such as maintenance, during which the app
+// cannot be launched.
+type Blackout struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// AppSLO is an app's availability objectives, measured over a rolling
+// window of WindowDays days (default 30). An objective left at zero is not
+// tracked.
+type AppSLO struct {
+	// LaunchSuccessTarget is the percentage of launches that must reach
+	// running, such as 99.5.
+	LaunchSuccessTarget float64 `json:"launch_success_target,omitempty"`
+	// StartLatencyP95Seconds is the time from request to running that
+	// 95% of successful launches must beat.
+	StartLatencyP95Seconds float64 `json:"start_latency_p95_seconds,omitempty"`
+	WindowDays             int     `json:"window_days,omitempty"`
+}
+
+// LaunchConfirmation describes what users should know before launching an
+// expensive app.
+type LaunchConfirmation struct {
+	// Required makes users confirm the launch preview: launches must carry
+	// the confirm token it returns.
+	Required bool `json:"required,omitempty"`
+	// Message is markdown shown with the preview, such as why the app is
+	// costly.
+	Message string `json:"message,omitempty"`
+	// Prerequisites are what users should have in place before launching,
+	// such as a license or a dataset.
+	Prerequisites []string `json:"prerequisites,omitempty"`
+}
+
+// Valid reports whether c has no blank prerequisites. A nil confirmation is
+// valid.
+func (c *LaunchConfirmation) Valid() bool {
+	if c == nil {
+		return true
+	}
+	for _, p := range c.Prerequisites {
+		if strings.TrimSpace(p) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// ClipboardPolicy says which way clipboard data may flow between the
+// browser and a session. It is enforced by the gateway, which drops the
+// clipboard messages the policy does not allow.
+type ClipboardPolicy string
+
+const (
+	// ClipboardBidirectional allows copying out of and pasting into the
+	// session. It is the default.
+	ClipboardBidirectional ClipboardPolicy = "bidirectional"
+	// ClipboardRead only allows copying out of the session.
+	ClipboardRead ClipboardPolicy = "read"
+	// ClipboardWrite only allows pasting into the session.
+	ClipboardWrite ClipboardPolicy = "write"
+	// ClipboardNone allows neither.
+	ClipboardNone ClipboardPolicy = "none"
+)
+
+// Valid reports whether p is a known policy or empty.
+func (p ClipboardPolicy) Valid() bool {
+	switch p {
+	case "", ClipboardBidirectional, ClipboardRead, ClipboardWrite, ClipboardNone:
+		return true
+	}
+	return false
+}
+
+// AllowsCopyOut reports whether clipboard data may flow from the session
+// to the browser.
+func (p ClipboardPolicy) AllowsCopyOut() bool {
+	return p == "" || p == ClipboardBidirectional || p == ClipboardRead
+}
+
+// AllowsPasteIn reports whether clipboard data may flow from the browser
+// into the session.
+func (p ClipboardPolicy) AllowsPasteIn() bool {
+	return p == "" || p == ClipboardBidirectional || p == ClipboardWrite
+}
+
+// FileTransferPolicy says which way files may be transferred between the
+// browser and a session's workspace. It is enforced by the files handler,
+// which rejects the transfers the policy does not allow.
+type FileTransferPolicy string
+
+const (
+	// FileTransferBoth allows uploads and downloads. It is the default.
+	FileTransferBoth FileTransferPolicy = "both"
+	// FileTransferUploadOnly only allows uploads into the session.
+	FileTransferUploadOnly FileTransferPolicy = "upload_only"
+	// FileTransferDownloadOnly only allows downloads out of the session.
+	FileTransferDownloadOnly FileTransferPolicy = "download_only"
+	// FileTransferNone allows neither.
+	FileTransferNone FileTransferPolicy = "none"
+)
+
+// Valid reports whether p is a known policy or empty.
+func (p FileTransferPolicy) Valid() bool {
+	switch p {
+	case "", FileTransferBoth, FileTransferUploadOnly, FileTransferDownloadOnly, FileTransferNone:
+		return true
+	}
+	return false
+}
+
+// AllowsUpload reports whether files may be uploaded into the session.
+func (p FileTransferPolicy) AllowsUpload() bool {
+	return p == "" || p == FileTransferBoth || p == FileTransferUploadOnly
+}
+
+// AllowsDownload reports whether files may be downloaded from the session.
+func (p FileTransferPolicy) AllowsDownload() bool {
+	return p == "" || p == FileTransferBoth || p == FileTransferDownloadOnly
+}
+
 // AppSpec defines an application specification for launching containers
 type AppSpec struct {
 	bun.BaseModel `bun:"table:app_specs"`
 
-	ID            string          `json:"id" bun:"id,pk"`
-	Name          string          `json:"name" bun:"name,notnull"`
-	Description   string          `json:"description,omitempty" bun:"description"`
-	Image         string          `json:"image" bun:"image,notnull"`
-	LaunchCommand string          `json:"launch_command,omitempty" bun:"launch_command"`
-	Resources     *ResourceLimits `json:"resources,omitempty" bun:"-"`
-	EnvVars       []EnvVar        `json:"env_vars,omitempty" bun:"-"`
-	Volumes       []VolumeMount   `json:"volumes,omitempty" bun:"-"`
-	NetworkRules  []NetworkRule   `json:"network_rules,omitempty" bun:"-"`
-	EgressPolicy  *EgressPolicy   `json:"egress_policy,omitempty" bun:"-"`
-	CreatedAt     time.Time       `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
-	UpdatedAt     time.Time       `json:"updated_at" bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+	ID              string              `json:"id" bun:"id,pk"`
+	Name            string              `json:"name" bun:"name,notnull"`
+	Description     string              `json:"description,omitempty" bun:"description"`
+	Image           string              `json:"image" bun:"image,notnull"`
+	LaunchCommand   string              `json:"launch_command,omitempty" bun:"launch_command"`
+	Resources       *ResourceLimits     `json:"resources,omitempty" bun:"-"`
+	EnvVars         []EnvVar            `json:"env_vars,omitempty" bun:"-"`
+	Volumes         []VolumeMount       `json:"volumes,omitempty" bun:"-"`
+	NetworkRules    []NetworkRule       `json:"network_rules,omitempty" bun:"-"`
+	EgressPolicy    *EgressPolicy       `json:"egress_policy,omitempty" bun:"-"`
+	DNS             *DNSConfig          `json:"dns,omitempty" bun:"-"`
+	Scheduling      *Scheduling         `json:"scheduling,omitempty" bun:"-"`
+	HomeVolume      *HomeVolume         `json:"home_volume,omitempty" bun:"-"`
+	Containers      []AppContainer      `json:"containers,omitempty" bun:"-"`
+	SharedVolumes   []SharedVolumeMount `json:"shared_volumes,omitempty" bun:"-"`
+	InitContainers  []InitContainer     `json:"init_containers,omitempty" bun:"-"`
+	ImagePullSecret string              `json:"image_pull_secret,omitempty" bun:"image_pull_secret,notnull"`
+	TenantID        string              `json:"tenant_id,omitempty" bun:"tenant_id"`
+	CreatedAt       time.Time           `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	UpdatedAt       time.Time           `json:"updated_at" bun:"updated_at,nullzero,notnull,default:current_timestamp"`
 
 	// Flattened DB columns for Resources (not exported to JSON)
 	CPURequest    string `json:"-" bun:"cpu_request"`
@@ -264,10 +744,16 @@ type AppSpec struct {
 	MemoryLimit   string `json:"-" bun:"memory_limit"`
 
 	// JSON-serialized DB columns
-	EnvVarsJSON      string `json:"-" bun:"env_vars"`
-	VolumesJSON      string `json:"-" bun:"volumes"`
-	NetworkRulesJSON string `json:"-" bun:"network_rules"`
-	EgressPolicyJSON string `json:"-" bun:"egress_policy"`
+	EnvVarsJSON        string `json:"-" bun:"env_vars"`
+	VolumesJSON        string `json:"-" bun:"volumes"`
+	NetworkRulesJSON   string `json:"-" bun:"network_rules"`
+	EgressPolicyJSON   string `json:"-" bun:"egress_policy"`
+	DNSJSON            string `json:"-" bun:"dns"`
+	SchedulingJSON     string `json:"-" bun:"scheduling"`
+	HomeVolumeJSON     string `json:"-" bun:"home_volume"`
+	ContainersJSON     string `json:"-" bun:"containers"`
+	SharedVolumesJSON  string `json:"-" bun:"shared_volumes"`
+	InitContainersJSON string `json:"-" bun:"init_containers"`
 }
 
 // Setting represents a key-value setting
@@ -316,8 +802,31 @@ type CategoryApprovedUser struct {
 
 // DB wraps the bun.DB connection
 type DB struct {
-	bun    *bun.DB
+	bun *bun.DB
+	// conn runs queries: bun, or the transaction of a DB from WithTx.
+	conn   bun.IDB
+	tx     *bun.Tx
 	dbType string
+	// reqCtx is the context queries run with; see WithContext.
+	reqCtx     context.Context
+	contention *contention
+}
+
+// WithContext returns a DB whose queries run with ctx, so they are traced
+// as part of the request or operation it carries.
+func (db *DB) WithContext(ctx context.Context) *DB {
+	c := *db
+	c.reqCtx = ctx
+	return &c
+}
+
+// ctx returns the context for db's queries: the one from WithContext, or
+// a background context.
+func (db *DB) ctx() context.Context {
+	if db.reqCtx != nil {
+		return db.reqCtx
+	}
+	return ctx()
 }
 
 // DBType returns the database type ("sqlite" or "postgres").
@@ -331,6 +840,24 @@ func Open(dbPath string) (*DB, error) {
 	return OpenDB("sqlite", dbPath)
 }
 
+// CheckConnection checks a PostgreSQL server is accepting connections, so
+// startup can wait for it before running migrations. SQLite databases are
+// local files and are not checked.
+func CheckConnection(ctx context.Context, dbType, dsn string) error {
+	if dbType != "postgres" {
+		return nil
+	}
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer conn.Close()
+	if err := conn.PingContext(ctx); err != nil {
+		return fmt.Errorf("database is not reachable: %w", err)
+	}
+	return nil
+}
+
 // OpenDB opens a database connection for the given type and DSN,
 // runs any pending migrations, and returns the DB handle.
 func OpenDB(dbType, dsn string) (*DB, error) {
@@ -351,6 +878,9 @@ func OpenDB(dbType, dsn string) (*DB, error) {
 		dsn = "file::memory:?cache=shared"
 		migrateDSN = dsn
 	}
+	if dbType == "sqlite" {
+		dsn = sqliteConnDSN(dsn)
+	}
 
 	conn, err := sql.Open(driverName, dsn)
 	if err != nil {
@@ -359,16 +889,11 @@ func OpenDB(dbType, dsn string) (*DB, error) {
 
 	// Configure SQLite-specific settings
 	if dbType == "sqlite" {
-		// busy_timeout waits up to 5 seconds for locks to clear
-		if _, err := conn.Exec("PRAGMA busy_timeout = 5000"); err != nil {
+		// The pragmas in the DSN apply to each new connection; check the
+		// first one takes them.
+		if err := conn.Ping(); err != nil {
 			conn.Close()
-			return nil, fmt.Errorf("failed to set busy_timeout: %w", err)
-		}
-
-		// WAL mode allows concurrent reads while writing
-		if _, err := conn.Exec("PRAGMA journal_mode = WAL"); err != nil {
-			conn.Close()
-			return nil, fmt.Errorf("failed to enable WAL mode: %w", err)
+			return nil, fmt.Errorf("failed to configure sqlite connection: %w", err)
 		}
 
 		// Keep at least one connection open to prevent in-memory databases
@@ -396,8 +921,31 @@ func OpenDB(dbType, dsn string) (*DB, error) {
 	case "postgres":
 		bunDB = bun.NewDB(conn, pgdialect.New())
 	}
+	bunDB.AddQueryHook(newTracingHook(dbType))
+	c := &contention{}
+	bunDB.AddQueryHook(&writeLockHook{c: c})
+	if chaos.Enabled {
+		bunDB.AddQueryHook(chaosHook{})
+	}
+
+	return &DB{bun: bunDB, conn: bunDB, dbType: dbType, contention: c}, nil
+}
 
-	return &DB{bun: bunDB, dbType: dbType}, nil
+// sqliteConnDSN adds the settings every SQLite connection needs to dsn.
+// Pragmas run with Exec only reach one pooled connection, so they are set
+// in the DSN, which the driver applies to each connection it opens:
+//
+//   - busy_timeout waits for other connections' locks to clear
+//   - WAL mode allows concurrent reads while writing
+//   - immediate transactions take the write lock at BEGIN, so SQLite can
+//     wait for it rather than fail when a read transaction later writes
+func sqliteConnDSN(dsn string) string {
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return fmt.Sprintf("%s%s_pragma=busy_timeout(%d)&_pragma=journal_mode(WAL)&_txlock=immediate",
+		dsn, sep, sqliteBusyTimeout.Milliseconds())
 }
 
 // Close closes the database connection
@@ -407,13 +955,13 @@ func (db *DB) Close() error {
 
 // Ping verifies the database connection is alive.
 func (db *DB) Ping() error {
-	return db.bun.PingContext(ctx())
+	return db.bun.PingContext(db.ctx())
 }
 
 // ExecRaw executes a raw SQL query. Bun's NewRaw automatically translates
 // ? placeholders to $1, $2, etc. for Postgres.
 func (db *DB) ExecRaw(query string, args ...any) (sql.Result, error) {
-	return db.bun.NewRaw(query, args...).Exec(ctx())
+	return db.conn.NewRaw(query, args...).Exec(db.ctx())
 }
 
 // IsDuplicateKeyError returns true if the error is a unique constraint
@@ -431,7 +979,7 @@ func IsDuplicateKeyError(err error) bool {
 
 // SeedFromJSON loads initial apps from a JSON file if the database is empty
 func (db *DB) SeedFromJSON(jsonPath string) error {
-	count, err := db.bun.NewSelect().Model((*Application)(nil)).Count(ctx())
+	count, err := db.conn.NewSelect().Model((*Application)(nil)).Count(db.ctx())
 	if err != nil {
 		return fmt.Errorf("failed to count applications: %w", err)
 	}
@@ -462,14 +1010,14 @@ func (db *DB) SeedFromJSON(jsonPath string) error {
 // ListApps returns all applications
 func (db *DB) ListApps() ([]Application, error) {
 	var apps []Application
-	err := db.bun.NewSelect().Model(&apps).OrderExpr("LOWER(category), LOWER(name)").Scan(ctx())
+	err := db.conn.NewSelect().Model(&apps).OrderExpr("LOWER(category), LOWER(name)").Scan(db.ctx())
 	return apps, err
 }
 
 // GetApp returns a single application by ID
 func (db *DB) GetApp(id string) (*Application, error) {
 	var app Application
-	err := db.bun.NewSelect().Model(&app).Where("id = ?", id).Scan(ctx())
+	err := db.conn.NewSelect().Model(&app).Where("id = ?", id).Scan(db.ctx())
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -481,13 +1029,13 @@ func (db *DB) GetApp(id string) (*Application, error) {
 
 // CreateApp inserts a new application
 func (db *DB) CreateApp(app Application) error {
-	_, err := db.bun.NewInsert().Model(&app).Exec(ctx())
+	_, err := db.conn.NewInsert().Model(&app).Exec(db.ctx())
 	return err
 }
 
 // UpdateApp updates an existing application
 func (db *DB) UpdateApp(app Application) error {
-	result, err := db.bun.NewUpdate().Model(&app).WherePK().Exec(ctx())
+	result, err := db.conn.NewUpdate().Model(&app).WherePK().Exec(db.ctx())
 	if err != nil {
 		return err
 	}
@@ -503,7 +1051,7 @@ func (db *DB) UpdateApp(app Application) error {
 
 // DeleteApp removes an application by ID
 func (db *DB) DeleteApp(id string) error {
-	result, err := db.bun.NewDelete().Model((*Application)(nil)).Where("id = ?", id).Exec(ctx())
+	result, err := db.conn.NewDelete().Model((*Application)(nil)).Where("id = ?", id).Exec(db.ctx())
 	if err != nil {
 		return err
 	}
@@ -514,7 +1062,7 @@ func (db *DB) DeleteApp(id string) error {
 	if rows == 0 {
 		return sql.ErrNoRows
 	}
-	return nil
+	return db.DeleteScheduledSessionsByApp(id)
 }
 
 // LogAudit creates an audit log entry
@@ -524,28 +1072,42 @@ func (db *DB) LogAudit(user, action, details string) error {
 		Action:  action,
 		Details: details,
 	}
-	_, err := db.bun.NewInsert().Model(&entry).Exec(ctx())
+	_, err := db.conn.NewInsert().Model(&entry).Exec(db.ctx())
+	return err
+}
+
+// LogAuditRequest records an audit log entry made while serving the API
+// request with the given X-Request-ID.
+func (db *DB) LogAuditRequest(requestID, user, action, details string) error {
+	entry := AuditLog{
+		User:      user,
+		Action:    action,
+		Details:   details,
+		RequestID: requestID,
+	}
+	_, err := db.conn.NewInsert().Model(&entry).Exec(db.ctx())
 	return err
 }
 
 // GetAuditLogs returns recent audit log entries
 func (db *DB) GetAuditLogs(limit int) ([]AuditLog, error) {
 	var logs []AuditLog
-	err := db.bun.NewSelect().Model(&logs).
+	err := db.conn.NewSelect().Model(&logs).
 		OrderExpr("timestamp DESC").
 		Limit(limit).
-		Scan(ctx())
+		Scan(db.ctx())
 	return logs, err
 }
 
 // AuditLogFilter holds query parameters for filtering audit logs
 type AuditLogFilter struct {
-	User   string
-	Action string
-	From   time.Time
-	To     time.Time
-	Limit  int
-	Offset int
+	User      string
+	Action    string
+	RequestID string
+	From      time.Time
+	To        time.Time
+	Limit     int
+	Offset    int
 }
 
 // AuditLogPage holds a page of audit log results with total count
@@ -556,7 +1118,7 @@ type AuditLogPage struct {
 
 // QueryAuditLogs returns audit logs matching the given filter with pagination
 func (db *DB) QueryAuditLogs(filter AuditLogFilter) (*AuditLogPage, error) {
-	q := db.bun.NewSelect().Model((*AuditLog)(nil))
+	q := db.conn.NewSelect().Model((*AuditLog)(nil))
 
 	if filter.User != "" {
 		q = q.Where("\"user\" = ?", filter.User)
@@ -564,6 +1126,9 @@ func (db *DB) QueryAuditLogs(filter AuditLogFilter) (*AuditLogPage, error) {
 	if filter.Action != "" {
 		q = q.Where("action = ?", filter.Action)
 	}
+	if filter.RequestID != "" {
+		q = q.Where("request_id = ?", filter.RequestID)
+	}
 	if !filter.From.IsZero() {
 		q = q.Where("timestamp >= ?", filter.From)
 	}
@@ -572,7 +1137,7 @@ func (db *DB) QueryAuditLogs(filter AuditLogFilter) (*AuditLogPage, error) {
 	}
 
 	// Get total count
-	total, err := q.Count(ctx())
+	total, err := q.Count(db.ctx())
 	if err != nil {
 		return nil, fmt.Errorf("failed to count audit logs: %w", err)
 	}
@@ -588,7 +1153,7 @@ func (db *DB) QueryAuditLogs(filter AuditLogFilter) (*AuditLogPage, error) {
 	err = q.OrderExpr("timestamp DESC").
 		Limit(limit).
 		Offset(offset).
-		Scan(ctx(), &logs)
+		Scan(db.ctx(), &logs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query audit logs: %w", err)
 	}
@@ -599,27 +1164,27 @@ func (db *DB) QueryAuditLogs(filter AuditLogFilter) (*AuditLogPage, error) {
 // GetAuditLogActions returns all distinct action values in the audit log
 func (db *DB) GetAuditLogActions() ([]string, error) {
 	var actions []string
-	err := db.bun.NewSelect().Model((*AuditLog)(nil)).
+	err := db.conn.NewSelect().Model((*AuditLog)(nil)).
 		ColumnExpr("DISTINCT action").
 		OrderExpr("action").
-		Scan(ctx(), &actions)
+		Scan(db.ctx(), &actions)
 	return actions, err
 }
 
 // GetAuditLogUsers returns all distinct user values in the audit log
 func (db *DB) GetAuditLogUsers() ([]string, error) {
 	var users []string
-	err := db.bun.NewSelect().Model((*AuditLog)(nil)).
+	err := db.conn.NewSelect().Model((*AuditLog)(nil)).
 		ColumnExpr("DISTINCT \"user\"").
 		OrderExpr("\"user\"").
-		Scan(ctx(), &users)
+		Scan(db.ctx(), &users)
 	return users, err
 }
 
 // RecordLaunch records an app launch for analytics
 func (db *DB) RecordLaunch(appID string) error {
 	entry := Analytics{AppID: appID}
-	_, err := db.bun.NewInsert().Model(&entry).Exec(ctx())
+	_, err := db.conn.NewInsert().Model(&entry).Exec(db.ctx())
 	return err
 }
 
@@ -639,20 +1204,21 @@ type AnalyticsStats struct {
 // GetAnalyticsStats returns analytics statistics
 func (db *DB) GetAnalyticsStats() (*AnalyticsStats, error) {
 	// Get total launches
-	totalLaunches, err := db.bun.NewSelect().Model((*Analytics)(nil)).Count(ctx())
+	totalLaunches, err := db.conn.NewSelect().Model((*Analytics)(nil)).Count(db.ctx())
 	if err != nil {
 		return nil, err
 	}
 
 	// Get per-app stats
 	var appStats []AppStats
-	err = db.bun.NewRaw(`
-		SELECT a.app_id, COALESCE(ap.name, a.app_id) as app_name, COUNT(*) as launch_count
-		FROM analytics a
-		LEFT JOIN applications ap ON a.app_id = ap.id
-		GROUP BY a.app_id, ap.name
-		ORDER BY launch_count DESC
-	`).Scan(ctx(), &appStats)
+	err = db.conn.NewSelect().Model((*Analytics)(nil)).
+		ColumnExpr("?TableAlias.app_id").
+		ColumnExpr("COALESCE(ap.name, ?TableAlias.app_id) AS app_name").
+		ColumnExpr("COUNT(*) AS launch_count").
+		Join("LEFT JOIN applications AS ap ON ap.id = ?TableAlias.app_id").
+		GroupExpr("?TableAlias.app_id, ap.name").
+		OrderExpr("launch_count DESC").
+		Scan(db.ctx(), &appStats)
 	if err != nil {
 		return nil, err
 	}
@@ -668,14 +1234,14 @@ func (db *DB) CreateSession(session Session) error {
 	if session.TenantID == "" {
 		session.TenantID = DefaultTenantID
 	}
-	_, err := db.bun.NewInsert().Model(&session).Exec(ctx())
+	_, err := db.conn.NewInsert().Model(&session).Exec(db.ctx())
 	return err
 }
 
 // GetSession returns a session by ID
 func (db *DB) GetSession(id string) (*Session, error) {
 	var session Session
-	err := db.bun.NewSelect().Model(&session).Where("id = ?", id).Scan(ctx())
+	err := db.conn.NewSelect().Model(&session).Where("id = ?", id).Scan(db.ctx())
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -688,31 +1254,31 @@ func (db *DB) GetSession(id string) (*Session, error) {
 // ListSessions returns all active sessions
 func (db *DB) ListSessions() ([]Session, error) {
 	var sessions []Session
-	err := db.bun.NewSelect().Model(&sessions).
+	err := db.conn.NewSelect().Model(&sessions).
 		Where("status NOT IN ('terminated', 'failed')").
 		OrderExpr("created_at DESC").
-		Scan(ctx())
+		Scan(db.ctx())
 	return sessions, err
 }
 
 // ListSessionsByUser returns all sessions for a specific user
 func (db *DB) ListSessionsByUser(userID string) ([]Session, error) {
 	var sessions []Session
-	err := db.bun.NewSelect().Model(&sessions).
+	err := db.conn.NewSelect().Model(&sessions).
 		Where("user_id = ?", userID).
 		Where("status NOT IN ('terminated', 'failed')").
 		OrderExpr("created_at DESC").
-		Scan(ctx())
+		Scan(db.ctx())
 	return sessions, err
 }
 
 // UpdateSessionStatus updates the status of a session
 func (db *DB) UpdateSessionStatus(id string, status SessionStatus) error {
-	result, err := db.bun.NewUpdate().Model((*Session)(nil)).
+	result, err := db.conn.NewUpdate().Model((*Session)(nil)).
 		Set("status = ?", status).
 		Set("updated_at = ?", time.Now()).
 		Where("id = ?", id).
-		Exec(ctx())
+		Exec(db.ctx())
 	if err != nil {
 		return err
 	}
@@ -728,11 +1294,11 @@ func (db *DB) UpdateSessionStatus(id string, status SessionStatus) error {
 
 // UpdateSessionPodIP updates the pod IP of a session
 func (db *DB) UpdateSessionPodIP(id string, podIP string) error {
-	result, err := db.bun.NewUpdate().Model((*Session)(nil)).
+	result, err := db.conn.NewUpdate().Model((*Session)(nil)).
 		Set("pod_ip = ?", podIP).
 		Set("updated_at = ?", time.Now()).
 		Where("id = ?", id).
-		Exec(ctx())
+		Exec(db.ctx())
 	if err != nil {
 		return err
 	}
@@ -746,14 +1312,17 @@ func (db *DB) UpdateSessionPodIP(id string, podIP string) error {
 	return nil
 }
 
-// UpdateSessionPodIPAndStatus updates both pod IP and status in a single operation
+// UpdateSessionPodIPAndStatus updates both pod IP and status in a single
+// operation, clearing the launch substatus
 func (db *DB) UpdateSessionPodIPAndStatus(id string, podIP string, status SessionStatus) error {
-	result, err := db.bun.NewUpdate().Model((*Session)(nil)).
+	result, err := db.conn.NewUpdate().Model((*Session)(nil)).
 		Set("pod_ip = ?", podIP).
 		Set("status = ?", status).
+		Set("substatus = ''").
+		Set("substatus_detail = ''").
 		Set("updated_at = ?", time.Now()).
 		Where("id = ?", id).
-		Exec(ctx())
+		Exec(db.ctx())
 	if err != nil {
 		return err
 	}
@@ -767,15 +1336,20 @@ func (db *DB) UpdateSessionPodIPAndStatus(id string, podIP string, status Sessio
 	return nil
 }
 
-// UpdateSessionRestart updates a session for restart with a new pod name and creating status
-func (db *DB) UpdateSessionRestart(id string, podName string) error {
-	result, err := db.bun.NewUpdate().Model((*Session)(nil)).
+// UpdateSessionRestart updates a session for restart with a new pod name,
+// display sidecar image and creating status
+func (db *DB) UpdateSessionRestart(id string, podName, sidecarImage string) error {
+	result, err := db.conn.NewUpdate().Model((*Session)(nil)).
 		Set("pod_name = ?", podName).
+		Set("sidecar_image = ?", sidecarImage).
 		Set("pod_ip = ''").
+		Set("restart_count = 0").
 		Set("status = ?", SessionStatusCreating).
+		Set("substatus = ''").
+		Set("substatus_detail = ''").
 		Set("updated_at = ?", time.Now()).
 		Where("id = ?", id).
-		Exec(ctx())
+		Exec(db.ctx())
 	if err != nil {
 		return err
 	}
@@ -789,9 +1363,276 @@ func (db *DB) UpdateSessionRestart(id string, podName string) error {
 	return nil
 }
 
+// UpdateSessionSubstatus records a creating session's launch progress. It
+// leaves updated_at alone, since that marks when the session was requested,
+// and does nothing once the session has left the creating state.
+func (db *DB) UpdateSessionSubstatus(id string, substatus SessionSubstatus, detail string) error {
+	_, err := db.conn.NewUpdate().Model((*Session)(nil)).
+		Set("substatus = ?", substatus).
+		Set("substatus_detail = ?", detail).
+		Where("id = ?", id).
+		Where("status = ?", SessionStatusCreating).
+		Exec(db.ctx())
+	return err
+}
+
+// RecordWorkloadRestarts records that the workload podName of a creating
+// or running session has restarted restarts times in total, and marks the
+// session degraded with detail. It returns the updated session, or nil if
+// no active session runs podName, a count at least as high was already
+// recorded, or the session is rescheduling.
+func (db *DB) RecordWorkloadRestarts(podName string, restarts int, detail string) (*Session, error) {
+	var session Session
+	err := db.conn.NewSelect().Model(&session).
+		Where("pod_name = ?", podName).
+		Where("status IN (?)", bun.In([]SessionStatus{SessionStatusCreating, SessionStatusRunning})).
+		Limit(1).
+		Scan(db.ctx())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := db.conn.NewUpdate().Model((*Session)(nil)).
+		Set("restart_count = ?", restarts).
+		Set("substatus = ?", SubstatusDegraded).
+		Set("substatus_detail = ?", detail).
+		Where("id = ?", session.ID).
+		Where("restart_count < ?", restarts).
+		Where("substatus <> ?", SubstatusRescheduling).
+		Exec(db.ctx())
+	if err != nil {
+		return nil, err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return nil, err
+	}
+	session.RestartCount = restarts
+	session.Substatus = SubstatusDegraded
+	session.SubstatusDetail = detail
+	return &session, nil
+}
+
+// GetRunningSessionByPodName returns the running session whose workload is
+// podName, or nil if none is.
+func (db *DB) GetRunningSessionByPodName(podName string) (*Session, error) {
+	var session Session
+	err := db.conn.NewSelect().Model(&session).
+		Where("pod_name = ?", podName).
+		Where("status = ?", SessionStatusRunning).
+		Limit(1).
+		Scan(db.ctx())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ClaimSessionDisruption marks the running session whose workload podName
+// was disrupted as rescheduling with detail, clearing its pod IP and
+// counting the reschedule. It returns the updated session, or nil if no
+// running session runs podName or the session is already rescheduling, so
+// with several replicas only one moves the session.
+func (db *DB) ClaimSessionDisruption(podName, detail string) (*Session, error) {
+	session, err := db.GetRunningSessionByPodName(podName)
+	if err != nil || session == nil {
+		return nil, err
+	}
+
+	result, err := db.conn.NewUpdate().Model((*Session)(nil)).
+		Set("substatus = ?", SubstatusRescheduling).
+		Set("substatus_detail = ?", detail).
+		Set("pod_ip = ''").
+		Set("restart_count = 0").
+		Set("reschedule_count = reschedule_count + 1").
+		Where("id = ?", session.ID).
+		Where("status = ?", SessionStatusRunning).
+		Where("substatus <> ?", SubstatusRescheduling).
+		Exec(db.ctx())
+	if err != nil {
+		return nil, err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return nil, err
+	}
+	session.Substatus = SubstatusRescheduling
+	session.SubstatusDetail = detail
+	session.PodIP = ""
+	session.RestartCount = 0
+	session.RescheduleCount++
+	return session, nil
+}
+
+// FinishSessionReschedule records the new workload of a rescheduling
+// session and clears its substatus. It returns sql.ErrNoRows if the session
+// has ended or stopped rescheduling meanwhile.
+func (db *DB) FinishSessionReschedule(id, podName, podIP, sidecarImage string) error {
+	result, err := db.conn.NewUpdate().Model((*Session)(nil)).
+		Set("pod_name = ?", podName).
+		Set("pod_ip = ?", podIP).
+		Set("sidecar_image = ?", sidecarImage).
+		Set("substatus = ''").
+		Set("substatus_detail = ''").
+		Where("id = ?", id).
+		Where("status = ?", SessionStatusRunning).
+		Where("substatus = ?", SubstatusRescheduling).
+		Exec(db.ctx())
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// LockSession records that the session's stream was locked. Locking an
+// already locked session keeps the first timestamp.
+func (db *DB) LockSession(id string) error {
+	result, err := db.conn.NewUpdate().Model((*Session)(nil)).
+		Set("locked_at = COALESCE(locked_at, ?)", time.Now()).
+		Where("id = ?", id).
+		Exec(db.ctx())
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UnlockSession clears the session's lock.
+func (db *DB) UnlockSession(id string) error {
+	result, err := db.conn.NewUpdate().Model((*Session)(nil)).
+		Set("locked_at = NULL").
+		Where("id = ?", id).
+		Exec(db.ctx())
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DismissSessionWelcome records that the owner dismissed the session's
+// welcome message. Dismissing again keeps the first timestamp.
+func (db *DB) DismissSessionWelcome(id string) error {
+	result, err := db.conn.NewUpdate().Model((*Session)(nil)).
+		Set("welcome_dismissed_at = COALESCE(welcome_dismissed_at, ?)", time.Now()).
+		Where("id = ?", id).
+		Exec(db.ctx())
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// hostnameHeldWhere matches sessions that hold their hostname: all but
+// those that have ended for good.
+const hostnameHeldWhere = "status NOT IN ('terminated', 'failed', 'expired')"
+
+// AllocateSessionHostname gives a session the hostname label.domain, or
+// label-2.domain, label-3.domain, ... if another session holds it, and
+// returns it. label must be a DNS label. A session that already has a
+// hostname keeps it.
+func (db *DB) AllocateSessionHostname(id, label, domain string) (string, error) {
+	var hostname string
+	err := db.WithTx(func(tx *DB) error {
+		session, err := tx.GetSession(id)
+		if err != nil {
+			return err
+		}
+		if session == nil {
+			return sql.ErrNoRows
+		}
+		if session.Hostname != "" {
+			hostname = session.Hostname
+			return nil
+		}
+
+		var held []string
+		err = tx.conn.NewSelect().Model((*Session)(nil)).
+			Column("hostname").
+			Where("hostname LIKE ?", label+"%."+domain).
+			Where(hostnameHeldWhere).
+			Scan(tx.ctx(), &held)
+		if err != nil {
+			return err
+		}
+		taken := make(map[string]bool, len(held))
+		for _, h := range held {
+			taken[h] = true
+		}
+
+		for n := 1; ; n++ {
+			candidate := label
+			if n > 1 {
+				suffix := fmt.Sprintf("-%d", n)
+				candidate = strings.TrimRight(label[:min(len(label), 63-len(suffix))], "-") + suffix
+			}
+			hostname = candidate + "." + domain
+			if !taken[hostname] {
+				break
+			}
+		}
+
+		_, err = tx.conn.NewUpdate().Model((*Session)(nil)).
+			Set("hostname = ?", hostname).
+			Where("id = ?", id).
+			Exec(tx.ctx())
+		return err
+	})
+	return hostname, err
+}
+
+// GetSessionByHostname returns the session that holds hostname, or nil if
+// none does.
+func (db *DB) GetSessionByHostname(hostname string) (*Session, error) {
+	var session Session
+	err := db.conn.NewSelect().Model(&session).
+		Where("hostname = ?", hostname).
+		Where(hostnameHeldWhere).
+		Limit(1).
+		Scan(db.ctx())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
 // DeleteSession removes a session by ID
 func (db *DB) DeleteSession(id string) error {
-	result, err := db.bun.NewDelete().Model((*Session)(nil)).Where("id = ?", id).Exec(ctx())
+	result, err := db.conn.NewDelete().Model((*Session)(nil)).Where("id = ?", id).Exec(db.ctx())
 	if err != nil {
 		return err
 	}
@@ -805,49 +1646,87 @@ func (db *DB) DeleteSession(id string) error {
 	return nil
 }
 
+// CountActiveSessionsByApp returns the number of active (creating or
+// running) sessions of an app, across all users.
+func (db *DB) CountActiveSessionsByApp(appID string) (int, error) {
+	count, err := db.conn.NewSelect().Model((*Session)(nil)).
+		Where("app_id = ?", appID).
+		Where("status IN ('creating', 'running')").
+		Count(db.ctx())
+	return count, err
+}
+
 // CountActiveSessionsByUser returns the number of active (creating or running) sessions for a user.
 func (db *DB) CountActiveSessionsByUser(userID string) (int, error) {
-	count, err := db.bun.NewSelect().Model((*Session)(nil)).
+	count, err := db.conn.NewSelect().Model((*Session)(nil)).
 		Where("user_id = ?", userID).
 		Where("status IN ('creating', 'running')").
-		Count(ctx())
+		Count(db.ctx())
 	return count, err
 }
 
 // CountActiveSessions returns the total number of active (creating or running) sessions globally.
 func (db *DB) CountActiveSessions() (int, error) {
-	count, err := db.bun.NewSelect().Model((*Session)(nil)).
+	count, err := db.conn.NewSelect().Model((*Session)(nil)).
 		Where("status IN ('creating', 'running')").
-		Count(ctx())
+		Count(db.ctx())
 	return count, err
 }
 
+// CountActiveSessionsPerApp returns the number of active (creating or
+// running) sessions of each app that has any.
+func (db *DB) CountActiveSessionsPerApp() (map[string]int, error) {
+	var rows []struct {
+		AppID string `bun:"app_id"`
+		Count int    `bun:"count"`
+	}
+	err := db.conn.NewSelect().Model((*Session)(nil)).
+		ColumnExpr("app_id").
+		ColumnExpr("COUNT(*) AS count").
+		Where("status IN ('creating', 'running')").
+		Group("app_id").
+		Scan(db.ctx(), &rows)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int, len(rows))
+	for _, r := range rows {
+		counts[r.AppID] = r.Count
+	}
+	return counts, nil
+}
+
 // GetStaleSessions returns sessions that have exceeded their idle timeout.
 // Sessions with a per-session idle_timeout use that value; others use the global default.
+//
+// Date arithmetic differs between SQLite and Postgres, so a cutoff is
+// computed in Go for each distinct per-session timeout and compared in SQL
+// as a bound parameter.
 func (db *DB) GetStaleSessions(defaultTimeout time.Duration) ([]Session, error) {
-	defaultCutoff := time.Now().Add(-defaultTimeout)
-
-	var query string
-	if db.dbType == "postgres" {
-		query = `SELECT id, user_id, app_id, pod_name, pod_ip, status, idle_timeout, tenant_id, created_at, updated_at
-			 FROM sessions
-			 WHERE status NOT IN ('terminated', 'failed', 'stopped', 'expired')
-			 AND (
-			   (idle_timeout > 0 AND updated_at < NOW() - (idle_timeout || ' seconds')::interval)
-			   OR (idle_timeout = 0 AND updated_at < ?)
-			 )`
-	} else {
-		query = `SELECT id, user_id, app_id, pod_name, pod_ip, status, idle_timeout, tenant_id, created_at, updated_at
-			 FROM sessions
-			 WHERE status NOT IN ('terminated', 'failed', 'stopped', 'expired')
-			 AND (
-			   (idle_timeout > 0 AND updated_at < datetime('now', '-' || idle_timeout || ' seconds'))
-			   OR (idle_timeout = 0 AND updated_at < ?)
-			 )`
+	const active = "status NOT IN ('terminated', 'failed', 'stopped', 'expired', 'hibernated')"
+	now := time.Now()
+
+	var timeouts []int64
+	err := db.conn.NewSelect().Model((*Session)(nil)).
+		ColumnExpr("DISTINCT idle_timeout").
+		Where(active).
+		Where("idle_timeout > 0").
+		Scan(db.ctx(), &timeouts)
+	if err != nil {
+		return nil, err
 	}
 
 	var sessions []Session
-	err := db.bun.NewRaw(query, defaultCutoff).Scan(ctx(), &sessions)
+	err = db.conn.NewSelect().Model(&sessions).
+		Where(active).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			q = q.Where("idle_timeout = 0 AND updated_at < ?", now.Add(-defaultTimeout))
+			for _, timeout := range timeouts {
+				q = q.WhereOr("idle_timeout = ? AND updated_at < ?", timeout, now.Add(-time.Duration(timeout)*time.Second))
+			}
+			return q
+		}).
+		Scan(db.ctx())
 	return sessions, err
 }
 
@@ -856,14 +1735,14 @@ func (db *DB) CreateUser(user User) error {
 	now := time.Now()
 	user.CreatedAt = now
 	user.UpdatedAt = now
-	_, err := db.bun.NewInsert().Model(&user).Exec(ctx())
+	_, err := db.conn.NewInsert().Model(&user).Exec(db.ctx())
 	return err
 }
 
 // GetUserByID retrieves a user by their ID
 func (db *DB) GetUserByID(id string) (*User, error) {
 	var user User
-	err := db.bun.NewSelect().Model(&user).Where("id = ?", id).Scan(ctx())
+	err := db.conn.NewSelect().Model(&user).Where("id = ?", id).Scan(db.ctx())
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -876,7 +1755,7 @@ func (db *DB) GetUserByID(id string) (*User, error) {
 // GetUserByUsername retrieves a user by their username
 func (db *DB) GetUserByUsername(username string) (*User, error) {
 	var user User
-	err := db.bun.NewSelect().Model(&user).Where("username = ?", username).Scan(ctx())
+	err := db.conn.NewSelect().Model(&user).Where("username = ?", username).Scan(db.ctx())
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -889,7 +1768,67 @@ func (db *DB) GetUserByUsername(username string) (*User, error) {
 // UpdateUser updates an existing user
 func (db *DB) UpdateUser(user User) error {
 	user.UpdatedAt = time.Now()
-	result, err := db.bun.NewUpdate().Model(&user).WherePK().Exec(ctx())
+	result, err := db.conn.NewUpdate().Model(&user).WherePK().Exec(db.ctx())
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UpdateUserLastLogin records a successful login for the user.
+func (db *DB) UpdateUserLastLogin(id string, at time.Time) error {
+	result, err := db.conn.NewUpdate().Model((*User)(nil)).
+		Set("last_login_at = ?", at).
+		Where("id = ?", id).
+		Exec(db.ctx())
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UpdateUserPassword replaces a user's password hash.
+func (db *DB) UpdateUserPassword(id, passwordHash string) error {
+	result, err := db.conn.NewUpdate().Model((*User)(nil)).
+		Set("password_hash = ?", passwordHash).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", id).
+		Exec(db.ctx())
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetUserLocale saves a user's preferred language. An empty locale clears
+// the preference.
+func (db *DB) SetUserLocale(id, locale string) error {
+	result, err := db.conn.NewUpdate().Model((*User)(nil)).
+		Set("locale = ?", locale).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", id).
+		Exec(db.ctx())
 	if err != nil {
 		return err
 	}
@@ -903,6 +1842,49 @@ func (db *DB) UpdateUser(user User) error {
 	return nil
 }
 
+// SetUserDisabled enables or disables a user account. Disabled accounts
+// cannot log in or refresh tokens; disabling one revokes its refresh
+// tokens.
+func (db *DB) SetUserDisabled(id string, disabled bool) error {
+	return db.runInTx(func(ctx context.Context, tx bun.Tx) error {
+		result, err := tx.NewUpdate().Model((*User)(nil)).
+			Set("disabled = ?", disabled).
+			Set("updated_at = ?", time.Now()).
+			Where("id = ?", id).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return sql.ErrNoRows
+		}
+		if !disabled {
+			return nil
+		}
+		_, err = tx.NewDelete().Model((*RefreshToken)(nil)).
+			Where("user_id = ?", id).
+			Exec(ctx)
+		return err
+	})
+}
+
+// ListInactiveUsers returns users whose last login (or creation time, for
+// users that have never logged in) is before the given cutoff. Users holding
+// the admin role are never returned so the policy cannot lock out operators.
+func (db *DB) ListInactiveUsers(olderThan time.Time) ([]User, error) {
+	var users []User
+	err := db.conn.NewSelect().Model(&users).
+		Where("COALESCE(last_login_at, created_at) < ?", olderThan).
+		Where("roles NOT LIKE ?", "%\"admin\"%").
+		OrderExpr("COALESCE(last_login_at, created_at) ASC").
+		Scan(db.ctx())
+	return users, err
+}
+
 // SeedAdminUser creates the admin user if it doesn't exist
 func (db *DB) SeedAdminUser(username, passwordHash string) error {
 	// Check if admin user already exists
@@ -930,19 +1912,106 @@ func (db *DB) SeedAdminUser(username, passwordHash string) error {
 // ListUsers returns all users
 func (db *DB) ListUsers() ([]User, error) {
 	var users []User
-	err := db.bun.NewSelect().Model(&users).
+	err := db.conn.NewSelect().Model(&users).
 		OrderExpr("created_at DESC").
-		Scan(ctx())
+		Scan(db.ctx())
 	return users, err
 }
 
+// UserFilter holds query parameters for searching and paginating users
+type UserFilter struct {
+	Query    string // Case-insensitive match against username, email, and display name
+	Role     string
+	TenantID string
+	Sort     string // One of UserSortFields; defaults to created_at
+	Desc     bool
+	Limit    int
+	Offset   int
+	All      bool // Return every match, ignoring Limit and Offset
+}
+
+// UserSortFields maps accepted sort keys to their database columns.
+var UserSortFields = map[string]string{
+	"username":     "username",
+	"email":        "email",
+	"display_name": "display_name",
+	"created_at":   "created_at",
+}
+
+// UserPage holds a page of user results with total count
+type UserPage struct {
+	Users []User `json:"users"`
+	Total int    `json:"total"`
+}
+
+// likeEscaper escapes LIKE wildcards so user input matches literally in
+// patterns that use ESCAPE '\'.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// QueryUsers returns users matching the given filter with pagination
+func (db *DB) QueryUsers(filter UserFilter) (*UserPage, error) {
+	q := db.conn.NewSelect().Model((*User)(nil))
+
+	if filter.Query != "" {
+		pattern := "%" + escapeLike(strings.ToLower(filter.Query)) + "%"
+		q = q.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("LOWER(username) LIKE ? ESCAPE '\\'", pattern).
+				WhereOr("LOWER(COALESCE(email, '')) LIKE ? ESCAPE '\\'", pattern).
+				WhereOr("LOWER(COALESCE(display_name, '')) LIKE ? ESCAPE '\\'", pattern)
+		})
+	}
+	if filter.Role != "" {
+		// Roles are stored as a JSON array, so match the quoted element.
+		q = q.Where("roles LIKE ? ESCAPE '\\'", "%\""+escapeLike(filter.Role)+"\"%")
+	}
+	if filter.TenantID != "" {
+		q = q.Where("tenant_id = ?", filter.TenantID)
+	}
+
+	total, err := q.Count(db.ctx())
+	if err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > 1000 {
+		limit = 50
+	}
+	offset := max(filter.Offset, 0)
+
+	column, ok := UserSortFields[filter.Sort]
+	if !ok {
+		column = "created_at"
+	}
+	direction := "ASC"
+	if filter.Desc {
+		direction = "DESC"
+	}
+
+	q = q.OrderExpr(column + " " + direction + ", id ASC")
+	if !filter.All {
+		q = q.Limit(limit).Offset(offset)
+	}
+	var users []User
+	err = q.Scan(db.ctx(), &users)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+
+	return &UserPage{Users: users, Total: total}, nil
+}
+
 // GetUserByAuthProvider retrieves a user by their external auth provider and subject ID.
 func (db *DB) GetUserByAuthProvider(provider, providerID string) (*User, error) {
 	var user User
-	err := db.bun.NewSelect().Model(&user).
+	err := db.conn.NewSelect().Model(&user).
 		Where("auth_provider = ?", provider).
 		Where("auth_provider_id = ?", providerID).
-		Scan(ctx())
+		Scan(db.ctx())
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -954,7 +2023,7 @@ func (db *DB) GetUserByAuthProvider(provider, providerID string) (*User, error)
 
 // DeleteUser removes a user by ID
 func (db *DB) DeleteUser(id string) error {
-	result, err := db.bun.NewDelete().Model((*User)(nil)).Where("id = ?", id).Exec(ctx())
+	result, err := db.conn.NewDelete().Model((*User)(nil)).Where("id = ?", id).Exec(db.ctx())
 	if err != nil {
 		return err
 	}
@@ -965,13 +2034,37 @@ func (db *DB) DeleteUser(id string) error {
 	if rows == 0 {
 		return sql.ErrNoRows
 	}
-	return nil
+	if err := db.DeleteIdPToken(id); err != nil {
+		return err
+	}
+	if err := db.DeleteAPITokensByUser(id); err != nil {
+		return err
+	}
+	if err := db.DeleteUserMFA(id); err != nil {
+		return err
+	}
+	if err := db.DeleteUserWebAuthnCredentials(id); err != nil {
+		return err
+	}
+	if err := db.DeletePasswordResetTokens(id); err != nil {
+		return err
+	}
+	if err := db.DeleteScheduledSessionsByUser(id); err != nil {
+		return err
+	}
+	if err := db.DeleteAPIUsageByUser(id); err != nil {
+		return err
+	}
+	if err := db.DeleteProjectMembershipsByUser(id); err != nil {
+		return err
+	}
+	return db.DeleteRefreshTokensByUser(id)
 }
 
 // GetSetting retrieves a setting value by key
 func (db *DB) GetSetting(key string) (string, error) {
 	var setting Setting
-	err := db.bun.NewSelect().Model(&setting).Where("key = ?", key).Scan(ctx())
+	err := db.conn.NewSelect().Model(&setting).Where("key = ?", key).Scan(db.ctx())
 	if err == sql.ErrNoRows {
 		return "", nil
 	}
@@ -988,17 +2081,17 @@ func (db *DB) SetSetting(key, value string) error {
 		Value:     value,
 		UpdatedAt: time.Now(),
 	}
-	_, err := db.bun.NewInsert().Model(&setting).
+	_, err := db.conn.NewInsert().Model(&setting).
 		On("CONFLICT (key) DO UPDATE").
 		Set("value = EXCLUDED.value, updated_at = EXCLUDED.updated_at").
-		Exec(ctx())
+		Exec(db.ctx())
 	return err
 }
 
 // GetAllSettings retrieves all settings
 func (db *DB) GetAllSettings() (map[string]string, error) {
 	var settings []Setting
-	err := db.bun.NewRaw("SELECT key, value FROM settings").Scan(ctx(), &settings)
+	err := db.conn.NewSelect().Model(&settings).Scan(db.ctx())
 	if err != nil {
 		return nil, err
 	}
@@ -1013,16 +2106,16 @@ func (db *DB) GetAllSettings() (map[string]string, error) {
 // ListTemplates returns all templates
 func (db *DB) ListTemplates() ([]Template, error) {
 	var templates []Template
-	err := db.bun.NewSelect().Model(&templates).
+	err := db.conn.NewSelect().Model(&templates).
 		OrderExpr("template_category, name").
-		Scan(ctx())
+		Scan(db.ctx())
 	return templates, err
 }
 
 // GetTemplate returns a single template by template_id
 func (db *DB) GetTemplate(templateID string) (*Template, error) {
 	var t Template
-	err := db.bun.NewSelect().Model(&t).Where("template_id = ?", templateID).Scan(ctx())
+	err := db.conn.NewSelect().Model(&t).Where("template_id = ?", templateID).Scan(db.ctx())
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -1037,14 +2130,18 @@ func (db *DB) CreateTemplate(t Template) error {
 	now := time.Now()
 	t.CreatedAt = now
 	t.UpdatedAt = now
-	_, err := db.bun.NewInsert().Model(&t).Exec(ctx())
+	_, err := db.conn.NewInsert().Model(&t).Exec(db.ctx())
 	return err
 }
 
-// UpdateTemplate updates an existing template
+// UpdateTemplate updates an existing template. The remote catalog it was
+// synced from, if any, is kept.
 func (db *DB) UpdateTemplate(t Template) error {
 	t.UpdatedAt = time.Now()
-	result, err := db.bun.NewUpdate().Model(&t).Where("template_id = ?", t.TemplateID).Exec(ctx())
+	result, err := db.conn.NewUpdate().Model(&t).
+		ExcludeColumn("catalog", "catalog_hash").
+		Where("template_id = ?", t.TemplateID).
+		Exec(db.ctx())
 	if err != nil {
 		return err
 	}
@@ -1060,7 +2157,7 @@ func (db *DB) UpdateTemplate(t Template) error {
 
 // DeleteTemplate removes a template by template_id
 func (db *DB) DeleteTemplate(templateID string) error {
-	result, err := db.bun.NewDelete().Model((*Template)(nil)).Where("template_id = ?", templateID).Exec(ctx())
+	result, err := db.conn.NewDelete().Model((*Template)(nil)).Where("template_id = ?", templateID).Exec(db.ctx())
 	if err != nil {
 		return err
 	}
@@ -1090,14 +2187,14 @@ func (db *DB) CreateAppSpec(spec AppSpec) error {
 	now := time.Now()
 	spec.CreatedAt = now
 	spec.UpdatedAt = now
-	_, err := db.bun.NewInsert().Model(&spec).Exec(ctx())
+	_, err := db.conn.NewInsert().Model(&spec).Exec(db.ctx())
 	return err
 }
 
 // GetAppSpec returns a single application specification by ID
 func (db *DB) GetAppSpec(id string) (*AppSpec, error) {
 	var spec AppSpec
-	err := db.bun.NewSelect().Model(&spec).Where("id = ?", id).Scan(ctx())
+	err := db.conn.NewSelect().Model(&spec).Where("id = ?", id).Scan(db.ctx())
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -1110,16 +2207,16 @@ func (db *DB) GetAppSpec(id string) (*AppSpec, error) {
 // ListAppSpecs returns all application specifications
 func (db *DB) ListAppSpecs() ([]AppSpec, error) {
 	var specs []AppSpec
-	err := db.bun.NewSelect().Model(&specs).
+	err := db.conn.NewSelect().Model(&specs).
 		OrderExpr("name").
-		Scan(ctx())
+		Scan(db.ctx())
 	return specs, err
 }
 
 // UpdateAppSpec updates an existing application specification
 func (db *DB) UpdateAppSpec(spec AppSpec) error {
 	spec.UpdatedAt = time.Now()
-	result, err := db.bun.NewUpdate().Model(&spec).WherePK().Exec(ctx())
+	result, err := db.conn.NewUpdate().Model(&spec).WherePK().Exec(db.ctx())
 	if err != nil {
 		return err
 	}
@@ -1135,7 +2232,7 @@ func (db *DB) UpdateAppSpec(spec AppSpec) error {
 
 // DeleteAppSpec removes an application specification by ID
 func (db *DB) DeleteAppSpec(id string) error {
-	result, err := db.bun.NewDelete().Model((*AppSpec)(nil)).Where("id = ?", id).Exec(ctx())
+	result, err := db.conn.NewDelete().Model((*AppSpec)(nil)).Where("id = ?", id).Exec(db.ctx())
 	if err != nil {
 		return err
 	}
@@ -1156,14 +2253,14 @@ func (db *DB) SaveOIDCState(state, redirectURL string, expiresAt time.Time) erro
 		RedirectURL: redirectURL,
 		ExpiresAt:   expiresAt,
 	}
-	_, err := db.bun.NewInsert().Model(&entry).Exec(ctx())
+	_, err := db.conn.NewInsert().Model(&entry).Exec(db.ctx())
 	return err
 }
 
 // ConsumeOIDCState atomically loads and deletes an OIDC state token.
 // Returns the redirect URL and expiry, or empty string if not found.
 func (db *DB) ConsumeOIDCState(state string) (redirectURL string, expiresAt time.Time, err error) {
-	err = db.bun.RunInTx(ctx(), nil, func(txCtx context.Context, tx bun.Tx) error {
+	err = db.runInTx(func(txCtx context.Context, tx bun.Tx) error {
 		var entry OIDCState
 		if err := tx.NewSelect().Model(&entry).Where("state = ?", state).Scan(txCtx); err != nil {
 			return err
@@ -1182,16 +2279,16 @@ func (db *DB) ConsumeOIDCState(state string) (redirectURL string, expiresAt time
 
 // CleanupExpiredOIDCStates removes expired OIDC state tokens.
 func (db *DB) CleanupExpiredOIDCStates() error {
-	_, err := db.bun.NewDelete().Model((*OIDCState)(nil)).
+	_, err := db.conn.NewDelete().Model((*OIDCState)(nil)).
 		Where("expires_at < ?", time.Now()).
-		Exec(ctx())
+		Exec(db.ctx())
 	return err
 }
 
 // SeedTemplatesFromData loads templates from JSON data if the templates table is empty
 func (db *DB) SeedTemplatesFromData(data []byte) error {
 	// Check if templates table is empty
-	count, err := db.bun.NewSelect().Model((*Template)(nil)).Count(ctx())
+	count, err := db.conn.NewSelect().Model((*Template)(nil)).Count(db.ctx())
 	if err != nil {
 		return fmt.Errorf("failed to count templates: %w", err)
 	}
@@ -1238,14 +2335,14 @@ type SessionShare struct {
 
 // CreateSessionShare inserts a new session share record.
 func (db *DB) CreateSessionShare(share SessionShare) error {
-	_, err := db.bun.NewInsert().Model(&share).Exec(ctx())
+	_, err := db.conn.NewInsert().Model(&share).Exec(db.ctx())
 	return err
 }
 
 // GetSessionShare returns a session share by ID.
 func (db *DB) GetSessionShare(id string) (*SessionShare, error) {
 	var share SessionShare
-	err := db.bun.NewSelect().Model(&share).Where("id = ?", id).Scan(ctx())
+	err := db.conn.NewSelect().Model(&share).Where("id = ?", id).Scan(db.ctx())
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -1258,7 +2355,7 @@ func (db *DB) GetSessionShare(id string) (*SessionShare, error) {
 // GetSessionShareByToken returns a session share by its token.
 func (db *DB) GetSessionShareByToken(token string) (*SessionShare, error) {
 	var share SessionShare
-	err := db.bun.NewSelect().Model(&share).Where("share_token = ?", token).Scan(ctx())
+	err := db.conn.NewSelect().Model(&share).Where("share_token = ?", token).Scan(db.ctx())
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -1271,10 +2368,10 @@ func (db *DB) GetSessionShareByToken(token string) (*SessionShare, error) {
 // ListSessionShares returns all shares for a given session.
 func (db *DB) ListSessionShares(sessionID string) ([]SessionShare, error) {
 	var shares []SessionShare
-	err := db.bun.NewSelect().Model(&shares).
+	err := db.conn.NewSelect().Model(&shares).
 		Where("session_id = ?", sessionID).
 		OrderExpr("created_at DESC").
-		Scan(ctx())
+		Scan(db.ctx())
 	return shares, err
 }
 
@@ -1289,36 +2386,29 @@ type SharedSessionRow struct {
 
 // ListSharedSessionsForUser returns sessions shared with the given user.
 func (db *DB) ListSharedSessionsForUser(userID string) ([]SharedSessionRow, error) {
-	type rawRow struct {
-		SessionID      string        `bun:"session_id"`
-		UserID         string        `bun:"user_id"`
-		AppID          string        `bun:"app_id"`
-		PodName        string        `bun:"pod_name"`
-		PodIP          string        `bun:"pod_ip"`
-		Status         SessionStatus `bun:"status"`
-		IdleTimeout    int64         `bun:"idle_timeout"`
-		SessionCreated time.Time     `bun:"session_created_at"`
-		SessionUpdated time.Time     `bun:"session_updated_at"`
-		AppName        string        `bun:"app_name"`
-		OwnerUsername  string        `bun:"owner_username"`
-		Permission     string        `bun:"permission"`
-		ShareID        string        `bun:"share_id"`
-	}
-
-	var rows []rawRow
-	err := db.bun.NewRaw(`
-		SELECT s.id AS session_id, s.user_id, s.app_id, s.pod_name, s.pod_ip, s.status,
-		       s.idle_timeout, s.created_at AS session_created_at, s.updated_at AS session_updated_at,
-		       COALESCE(a.name, s.app_id) AS app_name,
-		       COALESCE(u.username, s.user_id) AS owner_username,
-		       ss.permission, ss.id AS share_id
-		FROM session_shares ss
-		JOIN sessions s ON ss.session_id = s.id
-		LEFT JOIN applications a ON s.app_id = a.id
-		LEFT JOIN users u ON s.user_id = u.id
-		WHERE ss.user_id = ? AND s.status NOT IN ('terminated', 'failed', 'stopped', 'expired')
-		ORDER BY s.created_at DESC`, userID,
-	).Scan(ctx(), &rows)
+	// sharedSession extends Session with the joined columns, so every
+	// session column is scanned the same way as in GetSession.
+	type sharedSession struct {
+		Session         `bun:",extend"`
+		AppName         string          `bun:"app_name"`
+		OwnerUsername   string          `bun:"owner_username"`
+		SharePermission SharePermission `bun:"share_permission"`
+		ShareID         string          `bun:"share_id"`
+	}
+
+	var rows []sharedSession
+	err := db.conn.NewSelect().Model(&rows).
+		ColumnExpr("?TableAlias.*").
+		ColumnExpr("COALESCE(a.name, ?TableAlias.app_id) AS app_name").
+		ColumnExpr("COALESCE(u.username, ?TableAlias.user_id) AS owner_username").
+		ColumnExpr("ss.permission AS share_permission, ss.id AS share_id").
+		Join("JOIN session_shares AS ss ON ss.session_id = ?TableAlias.id").
+		Join("LEFT JOIN applications AS a ON a.id = ?TableAlias.app_id").
+		Join("LEFT JOIN users AS u ON u.id = ?TableAlias.user_id").
+		Where("ss.user_id = ?", userID).
+		Where("?TableAlias.status NOT IN (?)", bun.In([]string{"terminated", "failed", "stopped", "expired", "hibernated"})).
+		OrderExpr("?TableAlias.created_at DESC").
+		Scan(db.ctx())
 	if err != nil {
 		return nil, err
 	}
@@ -1326,34 +2416,46 @@ func (db *DB) ListSharedSessionsForUser(userID string) ([]SharedSessionRow, erro
 	var results []SharedSessionRow
 	for _, r := range rows {
 		results = append(results, SharedSessionRow{
-			Session: Session{
-				ID:          r.SessionID,
-				UserID:      r.UserID,
-				AppID:       r.AppID,
-				PodName:     r.PodName,
-				PodIP:       r.PodIP,
-				Status:      r.Status,
-				IdleTimeout: r.IdleTimeout,
-				CreatedAt:   r.SessionCreated,
-				UpdatedAt:   r.SessionUpdated,
-			},
+			Session:       r.Session,
 			AppName:       r.AppName,
 			OwnerUsername: r.OwnerUsername,
-			Permission:    SharePermission(r.Permission),
+			Permission:    r.SharePermission,
 			ShareID:       r.ShareID,
 		})
 	}
 	return results, nil
 }
 
+// ActiveShare is a share of a session that has not ended, with the
+// session's app and owner.
+type ActiveShare struct {
+	SessionShare `bun:",extend"`
+	AppID        string `bun:"app_id"`
+	OwnerID      string `bun:"owner_id"`
+}
+
+// ListActiveSessionShares returns the shares of every session that has not
+// ended, oldest first.
+func (db *DB) ListActiveSessionShares() ([]ActiveShare, error) {
+	var shares []ActiveShare
+	err := db.conn.NewSelect().Model(&shares).
+		ColumnExpr("?TableAlias.*").
+		ColumnExpr("s.app_id AS app_id, s.user_id AS owner_id").
+		Join("JOIN sessions AS s ON s.id = ?TableAlias.session_id").
+		Where("s.status NOT IN (?)", bun.In([]string{"terminated", "failed", "stopped", "expired", "hibernated"})).
+		OrderExpr("?TableAlias.created_at ASC").
+		Scan(db.ctx())
+	return shares, err
+}
+
 // CheckSessionAccess checks whether a user has share access to a session.
 func (db *DB) CheckSessionAccess(sessionID, userID string) (*SessionShare, error) {
 	var share SessionShare
-	err := db.bun.NewSelect().Model(&share).
+	err := db.conn.NewSelect().Model(&share).
 		Where("session_id = ?", sessionID).
 		Where("user_id = ?", userID).
 		Limit(1).
-		Scan(ctx())
+		Scan(db.ctx())
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -1365,7 +2467,7 @@ func (db *DB) CheckSessionAccess(sessionID, userID string) (*SessionShare, error
 
 // DeleteSessionShare removes a session share by ID.
 func (db *DB) DeleteSessionShare(id string) error {
-	result, err := db.bun.NewDelete().Model((*SessionShare)(nil)).Where("id = ?", id).Exec(ctx())
+	result, err := db.conn.NewDelete().Model((*SessionShare)(nil)).Where("id = ?", id).Exec(db.ctx())
 	if err != nil {
 		return err
 	}
@@ -1381,18 +2483,137 @@ func (db *DB) DeleteSessionShare(id string) error {
 
 // DeleteSessionSharesBySession removes all shares for a session.
 func (db *DB) DeleteSessionSharesBySession(sessionID string) error {
-	_, err := db.bun.NewDelete().Model((*SessionShare)(nil)).
+	_, err := db.conn.NewDelete().Model((*SessionShare)(nil)).
 		Where("session_id = ?", sessionID).
-		Exec(ctx())
+		Exec(db.ctx())
+	return err
+}
+
+// RefreshToken records a refresh token issued to a signed-in device. The ID
+// is embedded in the token as its jti claim; deleting the row revokes it.
+// A rotated token keeps its row briefly with ReplacedBy pointing at its
+// successor, so a client that missed the rotation response can recover.
+type RefreshToken struct {
+	bun.BaseModel `bun:"table:refresh_tokens"`
+
+	ID         string     `json:"id" bun:"id,pk"`
+	UserID     string     `json:"user_id" bun:"user_id,notnull"`
+	UserAgent  string     `json:"user_agent" bun:"user_agent,notnull"`
+	IPAddress  string     `json:"ip_address" bun:"ip_address,notnull"`
+	CreatedAt  time.Time  `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" bun:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at" bun:"expires_at,notnull"`
+	ReplacedBy string     `json:"-" bun:"replaced_by,notnull"`
+}
+
+// CreateRefreshToken stores a newly issued refresh token and removes any of
+// the user's tokens that have already expired.
+func (db *DB) CreateRefreshToken(token RefreshToken) error {
+	if _, err := db.conn.NewDelete().Model((*RefreshToken)(nil)).
+		Where("user_id = ?", token.UserID).
+		Where("expires_at < ?", time.Now()).
+		Exec(db.ctx()); err != nil {
+		return err
+	}
+	_, err := db.conn.NewInsert().Model(&token).Exec(db.ctx())
+	return err
+}
+
+// GetRefreshToken returns a refresh token by ID, or nil if it does not exist.
+func (db *DB) GetRefreshToken(id string) (*RefreshToken, error) {
+	var token RefreshToken
+	err := db.conn.NewSelect().Model(&token).Where("id = ?", id).Scan(db.ctx())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// TouchRefreshToken records that a refresh token was used.
+func (db *DB) TouchRefreshToken(id string, at time.Time) error {
+	_, err := db.conn.NewUpdate().Model((*RefreshToken)(nil)).
+		Set("last_used_at = ?", at).
+		Where("id = ?", id).
+		Exec(db.ctx())
+	return err
+}
+
+// RotateRefreshToken replaces a refresh token with its successor. The old
+// row stays valid until graceUntil and records the successor's ID. It returns
+// sql.ErrNoRows if the old token is missing or has already been rotated.
+func (db *DB) RotateRefreshToken(oldID string, next RefreshToken, graceUntil time.Time) error {
+	return db.runInTx(func(ctx context.Context, tx bun.Tx) error {
+		result, err := tx.NewUpdate().Model((*RefreshToken)(nil)).
+			Set("replaced_by = ?", next.ID).
+			Set("expires_at = ?", graceUntil).
+			Where("id = ?", oldID).
+			Where("replaced_by = ''").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return sql.ErrNoRows
+		}
+		_, err = tx.NewInsert().Model(&next).Exec(ctx)
+		return err
+	})
+}
+
+// ListRefreshTokensByUser returns a user's unexpired refresh tokens, most
+// recently issued first. Rotated tokens are left out.
+func (db *DB) ListRefreshTokensByUser(userID string) ([]RefreshToken, error) {
+	var tokens []RefreshToken
+	err := db.conn.NewSelect().Model(&tokens).
+		Where("user_id = ?", userID).
+		Where("expires_at > ?", time.Now()).
+		Where("replaced_by = ''").
+		OrderExpr("created_at DESC").
+		Scan(db.ctx())
+	return tokens, err
+}
+
+// DeleteUserRefreshToken revokes one of a user's refresh tokens. The user ID
+// is part of the match so users cannot revoke each other's devices.
+func (db *DB) DeleteUserRefreshToken(userID, id string) error {
+	result, err := db.conn.NewDelete().Model((*RefreshToken)(nil)).
+		Where("id = ?", id).
+		Where("user_id = ?", userID).
+		Exec(db.ctx())
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteRefreshTokensByUser revokes all of a user's refresh tokens.
+func (db *DB) DeleteRefreshTokensByUser(userID string) error {
+	_, err := db.conn.NewDelete().Model((*RefreshToken)(nil)).
+		Where("user_id = ?", userID).
+		Exec(db.ctx())
 	return err
 }
 
 // UpdateSessionShareUserID sets the user_id on a share (used when joining via link).
 func (db *DB) UpdateSessionShareUserID(id, userID string) error {
-	_, err := db.bun.NewUpdate().Model((*SessionShare)(nil)).
+	_, err := db.conn.NewUpdate().Model((*SessionShare)(nil)).
 		Set("user_id = ?", userID).
 		Where("id = ?", id).
-		Exec(ctx())
+		Exec(db.ctx())
 	return err
 }
 
@@ -1425,6 +2646,7 @@ type Recording struct {
 	TenantID        string          `json:"tenant_id,omitempty" bun:"tenant_id"`
 	CreatedAt       time.Time       `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
 	CompletedAt     *time.Time      `json:"completed_at,omitempty" bun:"completed_at"`
+	CheckpointAt    *time.Time      `json:"checkpoint_at,omitempty" bun:"checkpoint_at"` // When the last chunk arrived
 }
 
 // CreateRecording inserts a new recording record.
@@ -1432,14 +2654,14 @@ func (db *DB) CreateRecording(rec Recording) error {
 	if rec.TenantID == "" {
 		rec.TenantID = DefaultTenantID
 	}
-	_, err := db.bun.NewInsert().Model(&rec).Exec(ctx())
+	_, err := db.conn.NewInsert().Model(&rec).Exec(db.ctx())
 	return err
 }
 
 // GetRecording returns a recording by ID.
 func (db *DB) GetRecording(id string) (*Recording, error) {
 	var rec Recording
-	err := db.bun.NewSelect().Model(&rec).Where("id = ?", id).Scan(ctx())
+	err := db.conn.NewSelect().Model(&rec).Where("id = ?", id).Scan(db.ctx())
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -1451,10 +2673,10 @@ func (db *DB) GetRecording(id string) (*Recording, error) {
 
 // UpdateRecordingStatus updates the status of a recording.
 func (db *DB) UpdateRecordingStatus(id string, status RecordingStatus) error {
-	result, err := db.bun.NewUpdate().Model((*Recording)(nil)).
+	result, err := db.conn.NewUpdate().Model((*Recording)(nil)).
 		Set("status = ?", status).
 		Where("id = ?", id).
-		Exec(ctx())
+		Exec(db.ctx())
 	if err != nil {
 		return err
 	}
@@ -1471,14 +2693,14 @@ func (db *DB) UpdateRecordingStatus(id string, status RecordingStatus) error {
 // UpdateRecordingComplete marks a recording as ready with final metadata.
 func (db *DB) UpdateRecordingComplete(id string, storagePath string, sizeBytes int64, durationSeconds float64) error {
 	now := time.Now()
-	result, err := db.bun.NewUpdate().Model((*Recording)(nil)).
+	result, err := db.conn.NewUpdate().Model((*Recording)(nil)).
 		Set("status = ?", RecordingStatusReady).
 		Set("storage_path = ?", storagePath).
 		Set("size_bytes = ?", sizeBytes).
 		Set("duration_seconds = ?", durationSeconds).
 		Set("completed_at = ?", now).
 		Where("id = ?", id).
-		Exec(ctx())
+		Exec(db.ctx())
 	if err != nil {
 		return err
 	}
@@ -1494,10 +2716,10 @@ func (db *DB) UpdateRecordingComplete(id string, storagePath string, sizeBytes i
 
 // UpdateRecordingVideoPath sets the converted video path for a recording.
 func (db *DB) UpdateRecordingVideoPath(id string, videoPath string) error {
-	result, err := db.bun.NewUpdate().Model((*Recording)(nil)).
+	result, err := db.conn.NewUpdate().Model((*Recording)(nil)).
 		Set("video_path = ?", videoPath).
 		Where("id = ?", id).
-		Exec(ctx())
+		Exec(db.ctx())
 	if err != nil {
 		return err
 	}
@@ -1514,35 +2736,35 @@ func (db *DB) UpdateRecordingVideoPath(id string, videoPath string) error {
 // ListRecordingsByUser returns all recordings for a given user.
 func (db *DB) ListRecordingsByUser(userID string) ([]Recording, error) {
 	var recs []Recording
-	err := db.bun.NewSelect().Model(&recs).
+	err := db.conn.NewSelect().Model(&recs).
 		Where("user_id = ?", userID).
 		OrderExpr("created_at DESC").
-		Scan(ctx())
+		Scan(db.ctx())
 	return recs, err
 }
 
 // ListRecordingsBySession returns all recordings for a given session.
 func (db *DB) ListRecordingsBySession(sessionID string) ([]Recording, error) {
 	var recs []Recording
-	err := db.bun.NewSelect().Model(&recs).
+	err := db.conn.NewSelect().Model(&recs).
 		Where("session_id = ?", sessionID).
 		OrderExpr("created_at DESC").
-		Scan(ctx())
+		Scan(db.ctx())
 	return recs, err
 }
 
 // ListAllRecordings returns all recordings (admin use).
 func (db *DB) ListAllRecordings() ([]Recording, error) {
 	var recs []Recording
-	err := db.bun.NewSelect().Model(&recs).
+	err := db.conn.NewSelect().Model(&recs).
 		OrderExpr("created_at DESC").
-		Scan(ctx())
+		Scan(db.ctx())
 	return recs, err
 }
 
 // DeleteRecording removes a recording by ID.
 func (db *DB) DeleteRecording(id string) error {
-	result, err := db.bun.NewDelete().Model((*Recording)(nil)).Where("id = ?", id).Exec(ctx())
+	result, err := db.conn.NewDelete().Model((*Recording)(nil)).Where("id = ?", id).Exec(db.ctx())
 	if err != nil {
 		return err
 	}
@@ -1559,10 +2781,10 @@ func (db *DB) DeleteRecording(id string) error {
 // ListExpiredRecordings returns recordings with status 'ready' that completed before the given cutoff time.
 func (db *DB) ListExpiredRecordings(olderThan time.Time) ([]Recording, error) {
 	var recs []Recording
-	err := db.bun.NewSelect().Model(&recs).
+	err := db.conn.NewSelect().Model(&recs).
 		Where("status = ?", RecordingStatusReady).
 		Where("completed_at < ?", olderThan).
 		OrderExpr("completed_at ASC").
-		Scan(ctx())
+		Scan(db.ctx())
 	return recs, err
 }
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -694,6 +696,213 @@ func TestUserCRUD(t *testing.T) {
 	})
 }
 
+func TestQueryUsers(t *testing.T) {
+	db := setupTestDB(t)
+
+	users := []User{
+		{ID: "u-1", Username: "alice", Email: "alice@example.com", DisplayName: "Alice Smith", Roles: []string{"admin", "user"}},
+		{ID: "u-2", Username: "bob", Email: "bob@example.com", DisplayName: "Bob Jones", Roles: []string{"user"}},
+		{ID: "u-3", Username: "carol", Email: "carol@corp.test", DisplayName: "Carol Smith", Roles: []string{"user"}, TenantID: "acme"},
+	}
+	for _, u := range users {
+		if err := db.CreateUser(u); err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+	}
+
+	t.Run("search matches username, email, and display name", func(t *testing.T) {
+		page, err := db.QueryUsers(UserFilter{Query: "SMITH"})
+		if err != nil {
+			t.Fatalf("QueryUsers() error = %v", err)
+		}
+		if page.Total != 2 {
+			t.Errorf("got Total = %d, want 2", page.Total)
+		}
+
+		page, err = db.QueryUsers(UserFilter{Query: "corp.test"})
+		if err != nil {
+			t.Fatalf("QueryUsers() error = %v", err)
+		}
+		if page.Total != 1 || page.Users[0].Username != "carol" {
+			t.Errorf("got %+v, want only carol", page.Users)
+		}
+	})
+
+	t.Run("filter by role", func(t *testing.T) {
+		page, err := db.QueryUsers(UserFilter{Role: "admin"})
+		if err != nil {
+			t.Fatalf("QueryUsers() error = %v", err)
+		}
+		if page.Total != 1 || page.Users[0].Username != "alice" {
+			t.Errorf("got %+v, want only alice", page.Users)
+		}
+	})
+
+	t.Run("wildcards in filters match literally", func(t *testing.T) {
+		for _, filter := range []UserFilter{{Role: "%"}, {Role: "_dmin"}, {Query: "_"}, {Query: "%"}} {
+			page, err := db.QueryUsers(filter)
+			if err != nil {
+				t.Fatalf("QueryUsers(%+v) error = %v", filter, err)
+			}
+			if page.Total != 0 {
+				t.Errorf("QueryUsers(%+v) got %d users, want 0", filter, page.Total)
+			}
+		}
+	})
+
+	t.Run("filter by tenant", func(t *testing.T) {
+		page, err := db.QueryUsers(UserFilter{TenantID: "acme"})
+		if err != nil {
+			t.Fatalf("QueryUsers() error = %v", err)
+		}
+		if page.Total != 1 || page.Users[0].Username != "carol" {
+			t.Errorf("got %+v, want only carol", page.Users)
+		}
+	})
+
+	t.Run("sort and paginate", func(t *testing.T) {
+		page, err := db.QueryUsers(UserFilter{Sort: "username", Limit: 2})
+		if err != nil {
+			t.Fatalf("QueryUsers() error = %v", err)
+		}
+		if page.Total != 3 {
+			t.Errorf("got Total = %d, want 3", page.Total)
+		}
+		if len(page.Users) != 2 || page.Users[0].Username != "alice" || page.Users[1].Username != "bob" {
+			t.Errorf("got %+v, want [alice bob]", page.Users)
+		}
+
+		page, err = db.QueryUsers(UserFilter{Sort: "username", Desc: true, Limit: 2, Offset: 2})
+		if err != nil {
+			t.Fatalf("QueryUsers() error = %v", err)
+		}
+		if len(page.Users) != 1 || page.Users[0].Username != "alice" {
+			t.Errorf("got %+v, want [alice]", page.Users)
+		}
+
+		page, err = db.QueryUsers(UserFilter{Sort: "username", Limit: 1, Offset: 1, All: true})
+		if err != nil {
+			t.Fatalf("QueryUsers() error = %v", err)
+		}
+		if len(page.Users) != 3 {
+			t.Errorf("got %d users with All, want 3", len(page.Users))
+		}
+	})
+}
+
+func TestUserLastLoginAndDisabled(t *testing.T) {
+	db := setupTestDB(t)
+
+	users := []User{
+		{ID: "u-admin", Username: "root", Roles: []string{"admin"}},
+		{ID: "u-active", Username: "active", Roles: []string{"user"}},
+		{ID: "u-idle", Username: "idle", Roles: []string{"user"}},
+	}
+	for _, u := range users {
+		if err := db.CreateUser(u); err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+	}
+
+	t.Run("new users have no last login and are enabled", func(t *testing.T) {
+		u, err := db.GetUserByID("u-active")
+		if err != nil {
+			t.Fatalf("GetUserByID() error = %v", err)
+		}
+		if u.LastLoginAt != nil {
+			t.Errorf("got LastLoginAt = %v, want nil", u.LastLoginAt)
+		}
+		if u.Disabled {
+			t.Error("got Disabled = true, want false")
+		}
+	})
+
+	t.Run("UpdateUserLastLogin records timestamp", func(t *testing.T) {
+		at := time.Now().Add(-time.Hour).Truncate(time.Second)
+		if err := db.UpdateUserLastLogin("u-active", at); err != nil {
+			t.Fatalf("UpdateUserLastLogin() error = %v", err)
+		}
+		u, _ := db.GetUserByID("u-active")
+		if u.LastLoginAt == nil || !u.LastLoginAt.Equal(at) {
+			t.Errorf("got LastLoginAt = %v, want %v", u.LastLoginAt, at)
+		}
+	})
+
+	t.Run("UpdateUserLastLogin unknown user", func(t *testing.T) {
+		if err := db.UpdateUserLastLogin("missing", time.Now()); err != sql.ErrNoRows {
+			t.Errorf("got error = %v, want sql.ErrNoRows", err)
+		}
+	})
+
+	t.Run("SetUserDisabled toggles flag", func(t *testing.T) {
+		if err := db.SetUserDisabled("u-idle", true); err != nil {
+			t.Fatalf("SetUserDisabled() error = %v", err)
+		}
+		u, _ := db.GetUserByID("u-idle")
+		if !u.Disabled {
+			t.Error("got Disabled = false, want true")
+		}
+		if err := db.SetUserDisabled("u-idle", false); err != nil {
+			t.Fatalf("SetUserDisabled() error = %v", err)
+		}
+		u, _ = db.GetUserByID("u-idle")
+		if u.Disabled {
+			t.Error("got Disabled = true, want false")
+		}
+		if err := db.SetUserDisabled("missing", true); err != sql.ErrNoRows {
+			t.Errorf("got error = %v, want sql.ErrNoRows", err)
+		}
+	})
+
+	t.Run("SetUserLocale saves and clears preference", func(t *testing.T) {
+		if err := db.SetUserLocale("u-active", "es"); err != nil {
+			t.Fatalf("SetUserLocale() error = %v", err)
+		}
+		u, _ := db.GetUserByID("u-active")
+		if u.Locale != "es" {
+			t.Errorf("got Locale = %q, want %q", u.Locale, "es")
+		}
+		if err := db.SetUserLocale("u-active", ""); err != nil {
+			t.Fatalf("SetUserLocale() error = %v", err)
+		}
+		u, _ = db.GetUserByID("u-active")
+		if u.Locale != "" {
+			t.Errorf("got Locale = %q, want empty", u.Locale)
+		}
+		if err := db.SetUserLocale("missing", "es"); err != sql.ErrNoRows {
+			t.Errorf("got error = %v, want sql.ErrNoRows", err)
+		}
+	})
+
+	t.Run("ListInactiveUsers excludes recent logins and admins", func(t *testing.T) {
+		old := time.Now().Add(-90 * 24 * time.Hour)
+		if err := db.UpdateUserLastLogin("u-idle", old); err != nil {
+			t.Fatalf("UpdateUserLastLogin() error = %v", err)
+		}
+		if err := db.UpdateUserLastLogin("u-admin", old); err != nil {
+			t.Fatalf("UpdateUserLastLogin() error = %v", err)
+		}
+
+		inactive, err := db.ListInactiveUsers(time.Now().Add(-30 * 24 * time.Hour))
+		if err != nil {
+			t.Fatalf("ListInactiveUsers() error = %v", err)
+		}
+		if len(inactive) != 1 || inactive[0].ID != "u-idle" {
+			t.Errorf("got %+v, want only u-idle", inactive)
+		}
+	})
+
+	t.Run("ListInactiveUsers falls back to created_at", func(t *testing.T) {
+		inactive, err := db.ListInactiveUsers(time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatalf("ListInactiveUsers() error = %v", err)
+		}
+		if len(inactive) != 2 {
+			t.Errorf("got %d inactive users, want 2 (all non-admins)", len(inactive))
+		}
+	})
+}
+
 func TestSeedAdminUser(t *testing.T) {
 	db := setupTestDB(t)
 
@@ -907,6 +1116,30 @@ func TestSessionCRUD(t *testing.T) {
 		}
 	})
 
+	t.Run("dismiss session welcome", func(t *testing.T) {
+		if err := db.DismissSessionWelcome("sess-1"); err != nil {
+			t.Fatalf("DismissSessionWelcome() error = %v", err)
+		}
+		got, _ := db.GetSession("sess-1")
+		if got.WelcomeDismissedAt == nil {
+			t.Fatal("WelcomeDismissedAt not set")
+		}
+		first := *got.WelcomeDismissedAt
+
+		time.Sleep(10 * time.Millisecond)
+		if err := db.DismissSessionWelcome("sess-1"); err != nil {
+			t.Fatalf("second DismissSessionWelcome() error = %v", err)
+		}
+		got, _ = db.GetSession("sess-1")
+		if !got.WelcomeDismissedAt.Equal(first) {
+			t.Errorf("WelcomeDismissedAt changed from %v to %v", first, *got.WelcomeDismissedAt)
+		}
+
+		if err := db.DismissSessionWelcome("nonexistent"); err != sql.ErrNoRows {
+			t.Errorf("got error = %v, want sql.ErrNoRows", err)
+		}
+	})
+
 	t.Run("update session pod IP and status", func(t *testing.T) {
 		if err := db.UpdateSessionPodIPAndStatus("sess-1", "10.0.0.10", SessionStatusRunning); err != nil {
 			t.Fatalf("UpdateSessionPodIPAndStatus() error = %v", err)
@@ -935,7 +1168,7 @@ func TestSessionCRUD(t *testing.T) {
 		}
 
 		// Restart with new pod name
-		if err := db.UpdateSessionRestart("sess-1", "pod-sess-1-v2"); err != nil {
+		if err := db.UpdateSessionRestart("sess-1", "pod-sess-1-v2", "guacamole/guacd:1.6.0"); err != nil {
 			t.Fatalf("UpdateSessionRestart() error = %v", err)
 		}
 
@@ -949,10 +1182,13 @@ func TestSessionCRUD(t *testing.T) {
 		if got.Status != SessionStatusCreating {
 			t.Errorf("got Status = %s, want creating", got.Status)
 		}
+		if got.SidecarImage != "guacamole/guacd:1.6.0" {
+			t.Errorf("got SidecarImage = %s, want guacamole/guacd:1.6.0", got.SidecarImage)
+		}
 	})
 
 	t.Run("update session restart nonexistent", func(t *testing.T) {
-		err := db.UpdateSessionRestart("nonexistent", "pod-new")
+		err := db.UpdateSessionRestart("nonexistent", "pod-new", "")
 		if err != sql.ErrNoRows {
 			t.Errorf("got error = %v, want sql.ErrNoRows", err)
 		}
@@ -1394,6 +1630,33 @@ func TestQueryAuditLogs(t *testing.T) {
 	})
 }
 
+func TestLogAuditRequest(t *testing.T) {
+	db := setupTestDB(t)
+
+	if err := db.LogAuditRequest("req-1", "alice", "CREATE_APP", "Created app: TestApp"); err != nil {
+		t.Fatalf("LogAuditRequest() error = %v", err)
+	}
+	if err := db.LogAudit("bob", "LOGIN", "User bob logged in"); err != nil {
+		t.Fatalf("LogAudit() error = %v", err)
+	}
+
+	page, err := db.QueryAuditLogs(AuditLogFilter{RequestID: "req-1"})
+	if err != nil {
+		t.Fatalf("QueryAuditLogs() error = %v", err)
+	}
+	if page.Total != 1 || page.Logs[0].User != "alice" || page.Logs[0].RequestID != "req-1" {
+		t.Errorf("filtered by request ID = %+v, want alice's entry", page.Logs)
+	}
+
+	page, err = db.QueryAuditLogs(AuditLogFilter{User: "bob"})
+	if err != nil {
+		t.Fatalf("QueryAuditLogs() error = %v", err)
+	}
+	if page.Total != 1 || page.Logs[0].RequestID != "" {
+		t.Errorf("entry without request = %+v, want empty request ID", page.Logs)
+	}
+}
+
 func TestGetAuditLogActions(t *testing.T) {
 	db := setupTestDB(t)
 
@@ -1555,6 +1818,20 @@ func TestGetStaleSessionsPerSessionTimeout(t *testing.T) {
 	}
 	db.ExecRaw("UPDATE sessions SET updated_at = ? WHERE id = ?", twoMinAgo, "short-timeout")
 
+	// Session with a long per-session timeout (3h), updated 2 hours ago:
+	// idle past the global default, but not past its own timeout
+	twoHoursAgo := time.Now().Add(-2 * time.Hour)
+	long := Session{
+		ID: "long-timeout", UserID: "user-1", AppID: "test-app",
+		PodName: "pod-long", Status: SessionStatusRunning,
+		IdleTimeout: 3 * 3600,
+		CreatedAt:   twoHoursAgo, UpdatedAt: twoHoursAgo,
+	}
+	if err := db.CreateSession(long); err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	db.ExecRaw("UPDATE sessions SET updated_at = ? WHERE id = ?", twoHoursAgo, "long-timeout")
+
 	// With a 1-hour global default, only the per-session timeout should catch it
 	stale, err := db.GetStaleSessions(1 * time.Hour)
 	if err != nil {
@@ -1568,6 +1845,40 @@ func TestGetStaleSessionsPerSessionTimeout(t *testing.T) {
 	}
 }
 
+// sqliteOnlySQL matches SQL that only SQLite understands.
+var sqliteOnlySQL = regexp.MustCompile(`(?i)\b(datetime|julianday|strftime|unixepoch|ifnull|group_concat|instr)\s*\(|\bINSERT\s+OR\s+(REPLACE|IGNORE)\b|\bCOLLATE\s+NOCASE\b|\bPRAGMA\b`)
+
+// TestQueriesArePortable checks the package's queries avoid SQLite-only
+// functions and syntax, so they also run on Postgres.
+func TestQueriesArePortable(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, line := range strings.Split(string(data), "\n") {
+			trimmed := strings.TrimSpace(line)
+			if strings.HasPrefix(trimmed, "//") {
+				continue
+			}
+			// Connection setup for SQLite is the one place its pragmas belong.
+			if strings.Contains(trimmed, `conn.Exec("PRAGMA `) {
+				continue
+			}
+			if m := sqliteOnlySQL.FindString(line); m != "" {
+				t.Errorf("%s:%d uses SQLite-only %q: %s", file, i+1, m, trimmed)
+			}
+		}
+	}
+}
+
 // --- Template CRUD tests ---
 
 func TestTemplateCRUD(t *testing.T) {
@@ -2206,3 +2517,422 @@ func TestCountActiveSessions(t *testing.T) {
 		t.Errorf("CountActiveSessions() = %d, want 2", count)
 	}
 }
+
+func TestRecordWorkloadRestarts(t *testing.T) {
+	database := setupTestDB(t)
+
+	app := Application{
+		ID: "app-crash", Name: "Crash App", Description: "test",
+		URL: "", Icon: "i", Category: "test", LaunchType: LaunchTypeContainer,
+		ContainerImage: "test:latest",
+	}
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("CreateApp error: %v", err)
+	}
+	now := time.Now()
+	for _, s := range []Session{
+		{ID: "r1", UserID: "u1", AppID: "app-crash", PodName: "pod-running", Status: SessionStatusRunning, CreatedAt: now, UpdatedAt: now},
+		{ID: "r2", UserID: "u1", AppID: "app-crash", PodName: "pod-stopped", Status: SessionStatusStopped, CreatedAt: now, UpdatedAt: now},
+	} {
+		if err := database.CreateSession(s); err != nil {
+			t.Fatalf("CreateSession error: %v", err)
+		}
+	}
+
+	session, err := database.RecordWorkloadRestarts("pod-running", 2, "app: CrashLoopBackOff")
+	if err != nil {
+		t.Fatalf("RecordWorkloadRestarts error: %v", err)
+	}
+	if session == nil || session.ID != "r1" || session.RestartCount != 2 {
+		t.Fatalf("RecordWorkloadRestarts() = %+v, want r1 with 2 restarts", session)
+	}
+	got, _ := database.GetSession("r1")
+	if got.RestartCount != 2 || got.Substatus != SubstatusDegraded || got.SubstatusDetail != "app: CrashLoopBackOff" {
+		t.Errorf("session = %d restarts, %q (%q); want 2, degraded", got.RestartCount, got.Substatus, got.SubstatusDetail)
+	}
+
+	// Counts already recorded, ended sessions and unknown pods are ignored
+	for _, tc := range []struct {
+		pod      string
+		restarts int
+	}{{"pod-running", 2}, {"pod-running", 1}, {"pod-stopped", 3}, {"pod-unknown", 1}} {
+		if session, err := database.RecordWorkloadRestarts(tc.pod, tc.restarts, ""); err != nil || session != nil {
+			t.Errorf("RecordWorkloadRestarts(%s, %d) = %+v, %v; want nil", tc.pod, tc.restarts, session, err)
+		}
+	}
+
+	// A restarted session's new workload starts counting again
+	if err := database.UpdateSessionRestart("r1", "pod-new", ""); err != nil {
+		t.Fatalf("UpdateSessionRestart error: %v", err)
+	}
+	if got, _ := database.GetSession("r1"); got.RestartCount != 0 {
+		t.Errorf("RestartCount after restart = %d, want 0", got.RestartCount)
+	}
+}
+
+func TestClaimSessionDisruption(t *testing.T) {
+	database := setupTestDB(t)
+
+	app := Application{
+		ID: "app-evict", Name: "Evict App", Description: "test",
+		URL: "", Icon: "i", Category: "test", LaunchType: LaunchTypeContainer,
+		ContainerImage: "test:latest",
+	}
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("CreateApp error: %v", err)
+	}
+	now := time.Now()
+	for _, s := range []Session{
+		{ID: "e1", UserID: "u1", AppID: "app-evict", PodName: "pod-running", PodIP: "10.0.0.1", Status: SessionStatusRunning, RestartCount: 2, CreatedAt: now, UpdatedAt: now},
+		{ID: "e2", UserID: "u1", AppID: "app-evict", PodName: "pod-creating", Status: SessionStatusCreating, CreatedAt: now, UpdatedAt: now},
+	} {
+		if err := database.CreateSession(s); err != nil {
+			t.Fatalf("CreateSession error: %v", err)
+		}
+	}
+
+	session, err := database.ClaimSessionDisruption("pod-running", "EvictionByEvictionAPI")
+	if err != nil {
+		t.Fatalf("ClaimSessionDisruption error: %v", err)
+	}
+	if session == nil || session.ID != "e1" || session.RescheduleCount != 1 {
+		t.Fatalf("ClaimSessionDisruption() = %+v, want e1 with 1 reschedule", session)
+	}
+	got, _ := database.GetSession("e1")
+	if got.Substatus != SubstatusRescheduling || got.SubstatusDetail != "EvictionByEvictionAPI" ||
+		got.PodIP != "" || got.RestartCount != 0 || got.RescheduleCount != 1 {
+		t.Errorf("session after claim = %+v", got)
+	}
+
+	// A session already rescheduling, creating sessions and unknown pods
+	// are not claimed
+	for _, pod := range []string{"pod-running", "pod-creating", "pod-unknown"} {
+		if session, err := database.ClaimSessionDisruption(pod, ""); err != nil || session != nil {
+			t.Errorf("ClaimSessionDisruption(%s) = %+v, %v; want nil", pod, session, err)
+		}
+	}
+
+	if err := database.FinishSessionReschedule("e1", "pod-running", "10.0.0.2", "sidecar:v2"); err != nil {
+		t.Fatalf("FinishSessionReschedule error: %v", err)
+	}
+	got, _ = database.GetSession("e1")
+	if got.Substatus != "" || got.PodIP != "10.0.0.2" || got.SidecarImage != "sidecar:v2" || got.Status != SessionStatusRunning {
+		t.Errorf("session after reschedule = %+v", got)
+	}
+	if err := database.FinishSessionReschedule("e1", "pod-running", "10.0.0.3", ""); err != sql.ErrNoRows {
+		t.Errorf("FinishSessionReschedule() of a session not rescheduling error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestAllocateSessionHostname(t *testing.T) {
+	database := setupTestDB(t)
+
+	now := time.Now()
+	sessions := []Session{
+		{ID: "h1", UserID: "u1", AppID: "jupyter", Status: SessionStatusRunning, CreatedAt: now, UpdatedAt: now},
+		{ID: "h2", UserID: "u1", AppID: "jupyter", Status: SessionStatusCreating, CreatedAt: now, UpdatedAt: now},
+		{ID: "h3", UserID: "u1", AppID: "jupyter", Status: SessionStatusCreating, CreatedAt: now, UpdatedAt: now},
+		{ID: "h4", UserID: "u1", AppID: "jupyter", Status: SessionStatusCreating, CreatedAt: now, UpdatedAt: now},
+		{ID: "h5", UserID: "u1", AppID: "jupyter", Status: SessionStatusCreating, CreatedAt: now, UpdatedAt: now},
+	}
+	for _, s := range sessions {
+		if err := database.CreateSession(s); err != nil {
+			t.Fatalf("CreateSession error: %v", err)
+		}
+	}
+
+	const domain = "sortie.example.com"
+	for _, tt := range []struct{ id, want string }{
+		{"h1", "jupyter-alice.sortie.example.com"},
+		{"h2", "jupyter-alice-2.sortie.example.com"},
+		{"h1", "jupyter-alice.sortie.example.com"}, // kept
+	} {
+		got, err := database.AllocateSessionHostname(tt.id, "jupyter-alice", domain)
+		if err != nil {
+			t.Fatalf("AllocateSessionHostname(%s) error: %v", tt.id, err)
+		}
+		if got != tt.want {
+			t.Errorf("AllocateSessionHostname(%s) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+
+	// An ended session frees its hostname
+	if err := database.UpdateSessionStatus("h1", SessionStatusExpired); err != nil {
+		t.Fatalf("UpdateSessionStatus error: %v", err)
+	}
+	got, err := database.AllocateSessionHostname("h3", "jupyter-alice", domain)
+	if err != nil || got != "jupyter-alice.sortie.example.com" {
+		t.Errorf("AllocateSessionHostname(h3) = %q, %v, want the expired session's hostname", got, err)
+	}
+
+	// Suffixes fit within a DNS label
+	long := strings.Repeat("a", 63)
+	if _, err := database.AllocateSessionHostname("h4", long, "other.example.com"); err != nil {
+		t.Fatalf("AllocateSessionHostname error: %v", err)
+	}
+	want := strings.Repeat("a", 61) + "-2.other.example.com"
+	if got, _ := database.AllocateSessionHostname("h5", long, "other.example.com"); got != want {
+		t.Errorf("AllocateSessionHostname(h5) = %q, want %q", got, want)
+	}
+
+	session, err := database.GetSessionByHostname("jupyter-alice.sortie.example.com")
+	if err != nil || session == nil || session.ID != "h3" {
+		t.Errorf("GetSessionByHostname() = %+v, %v, want session h3", session, err)
+	}
+	if session, _ := database.GetSessionByHostname("nobody.sortie.example.com"); session != nil {
+		t.Errorf("GetSessionByHostname(unknown) = %+v, want nil", session)
+	}
+}
+
+func TestDNSConfigRoundtrip(t *testing.T) {
+	db := setupTestDB(t)
+
+	dns := &DNSConfig{
+		Policy:      "None",
+		Hostname:    "workstation",
+		Nameservers: []string{"10.0.0.53"},
+		Searches:    []string{"corp.example.com"},
+		Options:     []DNSOption{{Name: "ndots", Value: "2"}},
+		HostAliases: []HostAlias{{IP: "10.1.2.3", Hostnames: []string{"license.corp.example.com"}}},
+	}
+	if err := db.CreateApp(Application{ID: "dns-app", Name: "DNS", URL: "https://example.com", DNS: dns}); err != nil {
+		t.Fatalf("CreateApp() error = %v", err)
+	}
+	if err := db.CreateApp(Application{ID: "plain-app", Name: "Plain", URL: "https://example.com", DNS: &DNSConfig{}}); err != nil {
+		t.Fatalf("CreateApp() error = %v", err)
+	}
+	if err := db.CreateAppSpec(AppSpec{ID: "dns-spec", Name: "DNS", Image: "nginx:latest", DNS: dns}); err != nil {
+		t.Fatalf("CreateAppSpec() error = %v", err)
+	}
+
+	app, err := db.GetApp("dns-app")
+	if err != nil || app == nil {
+		t.Fatalf("GetApp() = %v, %v", app, err)
+	}
+	if app.DNS == nil || app.DNS.Policy != "None" || app.DNS.Hostname != "workstation" ||
+		len(app.DNS.Nameservers) != 1 || app.DNS.Options[0].Value != "2" ||
+		app.DNS.HostAliases[0].Hostnames[0] != "license.corp.example.com" {
+		t.Errorf("app DNS = %+v", app.DNS)
+	}
+
+	plain, _ := db.GetApp("plain-app")
+	if plain.DNS != nil {
+		t.Errorf("empty DNS config should read back as nil, got %+v", plain.DNS)
+	}
+
+	spec, err := db.GetAppSpec("dns-spec")
+	if err != nil || spec == nil {
+		t.Fatalf("GetAppSpec() = %v, %v", spec, err)
+	}
+	if spec.DNS == nil || len(spec.DNS.Searches) != 1 || spec.DNS.Searches[0] != "corp.example.com" {
+		t.Errorf("app spec DNS = %+v", spec.DNS)
+	}
+}
+
+func TestSchedulingRoundtrip(t *testing.T) {
+	db := setupTestDB(t)
+
+	scheduling := &Scheduling{
+		NodeSelector: map[string]string{"sortie.io/pool": "gpu"},
+		Tolerations:  []Toleration{{Key: "nvidia.com/gpu", Operator: "Exists", Effect: "NoSchedule"}},
+		NodeAffinity: &NodeAffinity{Required: []NodeSelectorTerm{{MatchExpressions: []NodeSelectorRequirement{
+			{Key: "topology.kubernetes.io/zone", Operator: "In", Values: []string{"us-east-1a"}},
+		}}}},
+	}
+	if err := db.CreateApp(Application{ID: "gpu-app", Name: "GPU", URL: "https://example.com", Scheduling: scheduling}); err != nil {
+		t.Fatalf("CreateApp() error = %v", err)
+	}
+	if err := db.CreateApp(Application{ID: "plain-app", Name: "Plain", URL: "https://example.com", Scheduling: &Scheduling{}}); err != nil {
+		t.Fatalf("CreateApp() error = %v", err)
+	}
+	if err := db.CreateAppSpec(AppSpec{ID: "gpu-spec", Name: "GPU", Image: "nginx:latest", Scheduling: scheduling}); err != nil {
+		t.Fatalf("CreateAppSpec() error = %v", err)
+	}
+
+	app, err := db.GetApp("gpu-app")
+	if err != nil || app == nil {
+		t.Fatalf("GetApp() = %v, %v", app, err)
+	}
+	if app.Scheduling == nil || app.Scheduling.NodeSelector["sortie.io/pool"] != "gpu" ||
+		app.Scheduling.Tolerations[0].Operator != "Exists" ||
+		app.Scheduling.NodeAffinity.Required[0].MatchExpressions[0].Values[0] != "us-east-1a" {
+		t.Errorf("app scheduling = %+v", app.Scheduling)
+	}
+
+	plain, _ := db.GetApp("plain-app")
+	if plain.Scheduling != nil {
+		t.Errorf("empty scheduling should read back as nil, got %+v", plain.Scheduling)
+	}
+
+	spec, err := db.GetAppSpec("gpu-spec")
+	if err != nil || spec == nil {
+		t.Fatalf("GetAppSpec() = %v, %v", spec, err)
+	}
+	if spec.Scheduling == nil || len(spec.Scheduling.Tolerations) != 1 {
+		t.Errorf("app spec scheduling = %+v", spec.Scheduling)
+	}
+}
+
+func TestHomeVolumeRoundtrip(t *testing.T) {
+	db := setupTestDB(t)
+
+	fsGroup := int64(1000)
+	vol := &HomeVolume{MountPath: "/home/user", Size: "5Gi", StorageClass: "fast", FSGroup: &fsGroup}
+	if err := db.CreateApp(Application{ID: "home-app", Name: "Home", URL: "https://example.com", HomeVolume: vol}); err != nil {
+		t.Fatalf("CreateApp() error = %v", err)
+	}
+	if err := db.CreateApp(Application{ID: "plain-app", Name: "Plain", URL: "https://example.com", HomeVolume: &HomeVolume{}}); err != nil {
+		t.Fatalf("CreateApp() error = %v", err)
+	}
+	if err := db.CreateAppSpec(AppSpec{ID: "home-spec", Name: "Home", Image: "nginx:latest", HomeVolume: vol}); err != nil {
+		t.Fatalf("CreateAppSpec() error = %v", err)
+	}
+
+	app, err := db.GetApp("home-app")
+	if err != nil || app == nil {
+		t.Fatalf("GetApp() = %v, %v", app, err)
+	}
+	if app.HomeVolume == nil || app.HomeVolume.MountPath != "/home/user" || app.HomeVolume.Size != "5Gi" ||
+		app.HomeVolume.StorageClass != "fast" || app.HomeVolume.FSGroup == nil || *app.HomeVolume.FSGroup != 1000 {
+		t.Errorf("app home volume = %+v", app.HomeVolume)
+	}
+
+	plain, _ := db.GetApp("plain-app")
+	if plain.HomeVolume != nil {
+		t.Errorf("home volume without a mount path should read back as nil, got %+v", plain.HomeVolume)
+	}
+
+	spec, err := db.GetAppSpec("home-spec")
+	if err != nil || spec == nil {
+		t.Fatalf("GetAppSpec() = %v, %v", spec, err)
+	}
+	if spec.HomeVolume == nil || spec.HomeVolume.MountPath != "/home/user" {
+		t.Errorf("app spec home volume = %+v", spec.HomeVolume)
+	}
+}
+
+func TestAppContainersRoundtrip(t *testing.T) {
+	db := setupTestDB(t)
+
+	containers := []AppContainer{{
+		Name:         "db",
+		Image:        "postgres:16",
+		Env:          map[string]string{"POSTGRES_PASSWORD": "dev"},
+		Ports:        []int{5432},
+		Resources:    &ResourceLimits{MemoryLimit: "1Gi"},
+		VolumeMounts: []SharedVolumeMount{{Name: "sockets", MountPath: "/var/run/postgresql"}},
+	}}
+	shared := []SharedVolumeMount{{Name: "sockets", MountPath: "/run/db", ReadOnly: true}}
+	inits := []InitContainer{{
+		Name:         "seed",
+		Image:        "busybox:1.36",
+		Command:      []string{"sh", "-c", "cp -r /seed/. /data"},
+		VolumeMounts: []SharedVolumeMount{{Name: "data", MountPath: "/data"}},
+	}}
+	if err := db.CreateApp(Application{ID: "ide", Name: "IDE", URL: "https://example.com", Containers: containers, SharedVolumes: shared, InitContainers: inits}); err != nil {
+		t.Fatalf("CreateApp() error = %v", err)
+	}
+	if err := db.CreateApp(Application{ID: "plain-app", Name: "Plain", URL: "https://example.com"}); err != nil {
+		t.Fatalf("CreateApp() error = %v", err)
+	}
+	if err := db.CreateAppSpec(AppSpec{ID: "ide-spec", Name: "IDE", Image: "ide:latest", Containers: containers, SharedVolumes: shared, InitContainers: inits}); err != nil {
+		t.Fatalf("CreateAppSpec() error = %v", err)
+	}
+
+	app, err := db.GetApp("ide")
+	if err != nil || app == nil {
+		t.Fatalf("GetApp() = %v, %v", app, err)
+	}
+	if !reflect.DeepEqual(app.Containers, containers) || !reflect.DeepEqual(app.SharedVolumes, shared) {
+		t.Errorf("app containers = %+v, shared volumes = %+v", app.Containers, app.SharedVolumes)
+	}
+	if !reflect.DeepEqual(app.InitContainers, inits) {
+		t.Errorf("app init containers = %+v", app.InitContainers)
+	}
+
+	plain, _ := db.GetApp("plain-app")
+	if plain.Containers != nil || plain.SharedVolumes != nil || plain.InitContainers != nil {
+		t.Errorf("app without containers read back %+v, %+v, %+v", plain.Containers, plain.SharedVolumes, plain.InitContainers)
+	}
+
+	spec, err := db.GetAppSpec("ide-spec")
+	if err != nil || spec == nil {
+		t.Fatalf("GetAppSpec() = %v, %v", spec, err)
+	}
+	if !reflect.DeepEqual(spec.Containers, containers) || !reflect.DeepEqual(spec.SharedVolumes, shared) {
+		t.Errorf("app spec containers = %+v, shared volumes = %+v", spec.Containers, spec.SharedVolumes)
+	}
+	if !reflect.DeepEqual(spec.InitContainers, inits) {
+		t.Errorf("app spec init containers = %+v", spec.InitContainers)
+	}
+}
+
+func TestSidecarImagesRoundtrip(t *testing.T) {
+	db := setupTestDB(t)
+
+	images := &SidecarImages{Guacd: "guacamole/guacd:1.5.5"}
+	if err := db.CreateApp(Application{ID: "win-app", Name: "Win", LaunchType: LaunchTypeContainer, OsType: "windows", ContainerImage: "win:latest", SidecarImages: images}); err != nil {
+		t.Fatalf("CreateApp() error = %v", err)
+	}
+	if err := db.CreateApp(Application{ID: "plain-app", Name: "Plain", URL: "https://example.com", SidecarImages: &SidecarImages{}}); err != nil {
+		t.Fatalf("CreateApp() error = %v", err)
+	}
+
+	app, err := db.GetApp("win-app")
+	if err != nil || app == nil {
+		t.Fatalf("GetApp() = %v, %v", app, err)
+	}
+	if app.SidecarImages == nil || *app.SidecarImages != *images {
+		t.Errorf("app sidecar images = %+v, want %+v", app.SidecarImages, images)
+	}
+
+	plain, _ := db.GetApp("plain-app")
+	if plain.SidecarImages != nil {
+		t.Errorf("empty sidecar images should read back as nil, got %+v", plain.SidecarImages)
+	}
+}
+
+func TestRDPSettingsRoundtrip(t *testing.T) {
+	db := setupTestDB(t)
+
+	ignoreCert := false
+	settings := &RDPSettings{Security: "nla", IgnoreCert: &ignoreCert, Domain: "CORP", GatewayHostname: "rdgw.example.com", EnableFontSmoothing: true}
+	if err := db.CreateApp(Application{ID: "win-app", Name: "Win", LaunchType: LaunchTypeContainer, OsType: "windows", ContainerImage: "win:latest", RDPSettings: settings}); err != nil {
+		t.Fatalf("CreateApp() error = %v", err)
+	}
+	if err := db.CreateApp(Application{ID: "plain-app", Name: "Plain", URL: "https://example.com", RDPSettings: &RDPSettings{}}); err != nil {
+		t.Fatalf("CreateApp() error = %v", err)
+	}
+
+	app, err := db.GetApp("win-app")
+	if err != nil || app == nil {
+		t.Fatalf("GetApp() = %v, %v", app, err)
+	}
+	got := app.RDPSettings
+	if got == nil || got.Security != "nla" || got.IgnoreCert == nil || *got.IgnoreCert || got.Domain != "CORP" ||
+		got.GatewayHostname != "rdgw.example.com" || !got.EnableFontSmoothing {
+		t.Errorf("app rdp settings = %+v, want %+v", got, settings)
+	}
+
+	plain, _ := db.GetApp("plain-app")
+	if plain.RDPSettings != nil {
+		t.Errorf("empty rdp settings should read back as nil, got %+v", plain.RDPSettings)
+	}
+}
+
+func TestCredentialRefRoundtrip(t *testing.T) {
+	db := setupTestDB(t)
+
+	ref := &CredentialRef{UsernameKey: "erp/username", PasswordKey: "erp/password"}
+	if err := db.CreateApp(Application{ID: "win-app", Name: "Win", LaunchType: LaunchTypeContainer, OsType: "windows", ContainerImage: "win:latest", Credentials: ref}); err != nil {
+		t.Fatalf("CreateApp() error = %v", err)
+	}
+
+	app, err := db.GetApp("win-app")
+	if err != nil || app == nil {
+		t.Fatalf("GetApp() = %v, %v", app, err)
+	}
+	if app.Credentials == nil || *app.Credentials != *ref {
+		t.Errorf("app credentials = %+v, want %+v", app.Credentials, ref)
+	}
+}
@@ -78,7 +78,7 @@ func truncateAllTables(t *testing.T, database *db.DB) {
 	t.Helper()
 
 	tables := []string{
-		"session_shares", "recordings",
+		"api_tokens", "refresh_tokens", "session_shares", "recordings",
 		"category_approved_users", "category_admins",
 		"categories", "oidc_states", "app_specs", "templates",
 		"settings", "analytics", "sessions", "audit_log",
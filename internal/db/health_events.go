@@ -0,0 +1,129 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// HealthEvent records a server component changing health state, such as
+// the database becoming unreachable or recovering.
+type HealthEvent struct {
+	bun.BaseModel `bun:"table:health_events"`
+
+	ID        int64     `json:"id" bun:"id,pk,autoincrement"`
+	Component string    `json:"component" bun:"component,notnull"`
+	FromState string    `json:"from" bun:"from_state,notnull"`
+	ToState   string    `json:"to" bun:"to_state,notnull"`
+	Reason    string    `json:"reason" bun:"reason,notnull"`
+	CreatedAt time.Time `json:"timestamp" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// HealthEventFilter holds query parameters for filtering health events
+type HealthEventFilter struct {
+	Component string
+	From      time.Time
+	To        time.Time
+	Limit     int
+	Offset    int
+}
+
+// HealthEventPage holds a page of health events with total count
+type HealthEventPage struct {
+	Events []HealthEvent `json:"events"`
+	Total  int           `json:"total"`
+}
+
+// CreateHealthEvent records a health state transition.
+func (db *DB) CreateHealthEvent(event *HealthEvent) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	_, err := db.conn.NewInsert().Model(event).Exec(db.ctx())
+	return err
+}
+
+// QueryHealthEvents returns health events matching the filter, newest
+// first, with pagination.
+func (db *DB) QueryHealthEvents(filter HealthEventFilter) (*HealthEventPage, error) {
+	q := db.conn.NewSelect().Model((*HealthEvent)(nil))
+	if filter.Component != "" {
+		q = q.Where("component = ?", filter.Component)
+	}
+	if !filter.From.IsZero() {
+		q = q.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		q = q.Where("created_at <= ?", filter.To)
+	}
+
+	total, err := q.Count(db.ctx())
+	if err != nil {
+		return nil, fmt.Errorf("failed to count health events: %w", err)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > 1000 {
+		limit = 50
+	}
+
+	events := []HealthEvent{}
+	err = q.OrderExpr("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(max(filter.Offset, 0)).
+		Scan(db.ctx(), &events)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query health events: %w", err)
+	}
+	return &HealthEventPage{Events: events, Total: total}, nil
+}
+
+// ListHealthEventsBetween returns the health events recorded in [from, to],
+// oldest first.
+func (db *DB) ListHealthEventsBetween(from, to time.Time) ([]HealthEvent, error) {
+	events := []HealthEvent{}
+	err := db.conn.NewSelect().Model(&events).
+		Where("created_at >= ?", from).
+		Where("created_at <= ?", to).
+		OrderExpr("created_at ASC, id ASC").
+		Scan(db.ctx())
+	return events, err
+}
+
+// LatestHealthEvents returns each component's most recent health event
+// recorded before t, keyed by component. A component with no earlier
+// event is absent.
+func (db *DB) LatestHealthEvents(before time.Time) (map[string]HealthEvent, error) {
+	var events []HealthEvent
+	err := db.conn.NewSelect().Model(&events).
+		Where("id IN (?)", db.conn.NewSelect().Model((*HealthEvent)(nil)).
+			ColumnExpr("MAX(id)").
+			Where("created_at < ?", before).
+			Group("component")).
+		Scan(db.ctx())
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[string]HealthEvent, len(events))
+	for _, e := range events {
+		latest[e.Component] = e
+	}
+	return latest, nil
+}
+
+// DeleteHealthEventsBefore removes health events recorded before t, except
+// each component's latest, which still gives its current state. It returns
+// the number of events removed.
+func (db *DB) DeleteHealthEventsBefore(t time.Time) (int64, error) {
+	res, err := db.conn.NewDelete().Model((*HealthEvent)(nil)).
+		Where("created_at < ?", t).
+		Where("id NOT IN (?)", db.conn.NewSelect().Model((*HealthEvent)(nil)).
+			ColumnExpr("MAX(id)").
+			Group("component")).
+		Exec(db.ctx())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHealthEvents(t *testing.T) {
+	db := setupTestDB(t)
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	for i, e := range []HealthEvent{
+		{Component: "database", FromState: "unknown", ToState: "unhealthy", Reason: "connection refused"},
+		{Component: "database", FromState: "unhealthy", ToState: "healthy"},
+		{Component: "sessions", FromState: "unknown", ToState: "unhealthy", Reason: "overloaded"},
+		{Component: "database", FromState: "healthy", ToState: "unhealthy", Reason: "timeout"},
+	} {
+		e.CreatedAt = base.Add(time.Duration(i) * 10 * time.Minute)
+		if err := db.CreateHealthEvent(&e); err != nil {
+			t.Fatalf("CreateHealthEvent() error = %v", err)
+		}
+	}
+
+	t.Run("query", func(t *testing.T) {
+		page, err := db.QueryHealthEvents(HealthEventFilter{Component: "database"})
+		if err != nil {
+			t.Fatalf("QueryHealthEvents() error = %v", err)
+		}
+		if page.Total != 3 || len(page.Events) != 3 {
+			t.Fatalf("got %d events (total %d), want 3", len(page.Events), page.Total)
+		}
+		if page.Events[0].Reason != "timeout" {
+			t.Errorf("first event = %+v, want the newest", page.Events[0])
+		}
+
+		page, _ = db.QueryHealthEvents(HealthEventFilter{From: base.Add(15 * time.Minute), Limit: 1})
+		if page.Total != 2 || len(page.Events) != 1 {
+			t.Errorf("got %d events (total %d), want 1 of 2", len(page.Events), page.Total)
+		}
+	})
+
+	t.Run("between", func(t *testing.T) {
+		events, err := db.ListHealthEventsBetween(base.Add(5*time.Minute), base.Add(25*time.Minute))
+		if err != nil {
+			t.Fatalf("ListHealthEventsBetween() error = %v", err)
+		}
+		if len(events) != 2 || events[0].ToState != "healthy" || events[1].Component != "sessions" {
+			t.Errorf("ListHealthEventsBetween() = %+v", events)
+		}
+	})
+
+	t.Run("latest", func(t *testing.T) {
+		latest, err := db.LatestHealthEvents(base.Add(25 * time.Minute))
+		if err != nil {
+			t.Fatalf("LatestHealthEvents() error = %v", err)
+		}
+		if len(latest) != 2 || latest["database"].ToState != "healthy" || latest["sessions"].ToState != "unhealthy" {
+			t.Errorf("LatestHealthEvents() = %+v", latest)
+		}
+		if latest, _ := db.LatestHealthEvents(base); len(latest) != 0 {
+			t.Errorf("LatestHealthEvents(before first) = %+v, want none", latest)
+		}
+	})
+
+	t.Run("retention keeps latest state", func(t *testing.T) {
+		n, err := db.DeleteHealthEventsBefore(time.Now())
+		if err != nil {
+			t.Fatalf("DeleteHealthEventsBefore() error = %v", err)
+		}
+		if n != 2 {
+			t.Errorf("deleted %d events, want 2", n)
+		}
+		latest, _ := db.LatestHealthEvents(time.Now())
+		if latest["database"].Reason != "timeout" || latest["sessions"].Reason != "overloaded" {
+			t.Errorf("LatestHealthEvents() after retention = %+v", latest)
+		}
+	})
+}
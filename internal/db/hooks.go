@@ -56,6 +56,69 @@ func (a *Application) BeforeAppendModel(_ context.Context, query bun.Query) erro
 		}
 	}
 
+	// Marshal Sidecars → SidecarsJSON
+	a.SidecarsJSON = "[]"
+	if len(a.Sidecars) > 0 {
+		if b, err := json.Marshal(a.Sidecars); err == nil {
+			a.SidecarsJSON = string(b)
+		}
+	}
+
+	a.DNSJSON = marshalDNSConfig(a.DNS)
+	a.SchedulingJSON = marshalScheduling(a.Scheduling)
+	a.HomeVolumeJSON = marshalHomeVolume(a.HomeVolume)
+	a.ContainersJSON = marshalList(a.Containers)
+	a.SharedVolumesJSON = marshalList(a.SharedVolumes)
+	a.InitContainersJSON = marshalList(a.InitContainers)
+
+	// Marshal Schedule → ScheduleJSON
+	a.ScheduleJSON = ""
+	if s := a.Schedule; s != nil && (len(s.Windows) > 0 || len(s.Blackouts) > 0) {
+		if b, err := json.Marshal(s); err == nil {
+			a.ScheduleJSON = string(b)
+		}
+	}
+
+	// Marshal SidecarImages → SidecarImagesJSON
+	a.SidecarImagesJSON = ""
+	if i := a.SidecarImages; i != nil && (i.VNC != "" || i.Browser != "" || i.Guacd != "") {
+		if b, err := json.Marshal(i); err == nil {
+			a.SidecarImagesJSON = string(b)
+		}
+	}
+
+	// Marshal RDPSettings → RDPSettingsJSON
+	a.RDPSettingsJSON = ""
+	if rs := a.RDPSettings; rs != nil && *rs != (RDPSettings{}) {
+		if b, err := json.Marshal(rs); err == nil {
+			a.RDPSettingsJSON = string(b)
+		}
+	}
+
+	// Marshal Credentials → CredentialsJSON
+	a.CredentialsJSON = ""
+	if c := a.Credentials; c != nil && *c != (CredentialRef{}) {
+		if b, err := json.Marshal(c); err == nil {
+			a.CredentialsJSON = string(b)
+		}
+	}
+
+	// Marshal SLO → SLOJSON
+	a.SLOJSON = ""
+	if o := a.SLO; o != nil && (o.LaunchSuccessTarget > 0 || o.StartLatencyP95Seconds > 0) {
+		if b, err := json.Marshal(o); err == nil {
+			a.SLOJSON = string(b)
+		}
+	}
+
+	// Marshal LaunchConfirmation → LaunchConfirmationJSON
+	a.LaunchConfirmationJSON = ""
+	if c := a.LaunchConfirmation; c != nil && (c.Required || c.Message != "" || len(c.Prerequisites) > 0) {
+		if b, err := json.Marshal(c); err == nil {
+			a.LaunchConfirmationJSON = string(b)
+		}
+	}
+
 	return nil
 }
 
@@ -98,6 +161,219 @@ func (a *Application) AfterScanRow(_ context.Context) error {
 		}
 	}
 
+	// Unmarshal SidecarsJSON → Sidecars
+	a.Sidecars = nil
+	if a.SidecarsJSON != "" && a.SidecarsJSON != "[]" {
+		json.Unmarshal([]byte(a.SidecarsJSON), &a.Sidecars)
+	}
+
+	a.DNS = unmarshalDNSConfig(a.DNSJSON)
+	a.Scheduling = unmarshalScheduling(a.SchedulingJSON)
+	a.HomeVolume = unmarshalHomeVolume(a.HomeVolumeJSON)
+	a.Containers = unmarshalList[AppContainer](a.ContainersJSON)
+	a.SharedVolumes = unmarshalList[SharedVolumeMount](a.SharedVolumesJSON)
+	a.InitContainers = unmarshalList[InitContainer](a.InitContainersJSON)
+
+	// Unmarshal ScheduleJSON → Schedule
+	a.Schedule = nil
+	if a.ScheduleJSON != "" {
+		var s AppSchedule
+		if json.Unmarshal([]byte(a.ScheduleJSON), &s) == nil {
+			a.Schedule = &s
+		}
+	}
+
+	// Unmarshal RDPSettingsJSON → RDPSettings
+	a.RDPSettings = nil
+	if a.RDPSettingsJSON != "" {
+		var rs RDPSettings
+		if json.Unmarshal([]byte(a.RDPSettingsJSON), &rs) == nil {
+			a.RDPSettings = &rs
+		}
+	}
+
+	// Unmarshal CredentialsJSON → Credentials
+	a.Credentials = nil
+	if a.CredentialsJSON != "" {
+		var c CredentialRef
+		if json.Unmarshal([]byte(a.CredentialsJSON), &c) == nil {
+			a.Credentials = &c
+		}
+	}
+
+	// Unmarshal SidecarImagesJSON → SidecarImages
+	a.SidecarImages = nil
+	if a.SidecarImagesJSON != "" {
+		var i SidecarImages
+		if json.Unmarshal([]byte(a.SidecarImagesJSON), &i) == nil {
+			a.SidecarImages = &i
+		}
+	}
+
+	// Unmarshal SLOJSON → SLO
+	a.SLO = nil
+	if a.SLOJSON != "" {
+		var o AppSLO
+		if json.Unmarshal([]byte(a.SLOJSON), &o) == nil {
+			a.SLO = &o
+		}
+	}
+
+	// Unmarshal LaunchConfirmationJSON → LaunchConfirmation
+	a.LaunchConfirmation = nil
+	if a.LaunchConfirmationJSON != "" {
+		var c LaunchConfirmation
+		if json.Unmarshal([]byte(a.LaunchConfirmationJSON), &c) == nil {
+			a.LaunchConfirmation = &c
+		}
+	}
+
+	return nil
+}
+
+// marshalDNSConfig serializes a DNS config for the dns column, storing an
+// empty config as an empty string.
+func marshalDNSConfig(c *DNSConfig) string {
+	if c == nil || (c.Policy == "" && c.Hostname == "" && len(c.Nameservers) == 0 &&
+		len(c.Searches) == 0 && len(c.Options) == 0 && len(c.HostAliases) == 0) {
+		return ""
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// unmarshalDNSConfig parses the dns column, returning nil when it is empty.
+func unmarshalDNSConfig(s string) *DNSConfig {
+	if s == "" {
+		return nil
+	}
+	var c DNSConfig
+	if json.Unmarshal([]byte(s), &c) != nil {
+		return nil
+	}
+	return &c
+}
+
+// marshalScheduling serializes scheduling settings for the scheduling
+// column, storing empty settings as an empty string.
+func marshalScheduling(c *Scheduling) string {
+	if c == nil || (len(c.NodeSelector) == 0 && len(c.Tolerations) == 0 && c.NodeAffinity == nil) {
+		return ""
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// unmarshalScheduling parses the scheduling column, returning nil when it
+// is empty.
+func unmarshalScheduling(s string) *Scheduling {
+	if s == "" {
+		return nil
+	}
+	var c Scheduling
+	if json.Unmarshal([]byte(s), &c) != nil {
+		return nil
+	}
+	return &c
+}
+
+// marshalHomeVolume serializes a home volume for the home_volume column,
+// storing a volume without a mount path as an empty string.
+func marshalHomeVolume(v *HomeVolume) string {
+	if v == nil || v.MountPath == "" {
+		return ""
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// unmarshalHomeVolume parses the home_volume column, returning nil when it
+// is empty.
+func unmarshalHomeVolume(s string) *HomeVolume {
+	if s == "" {
+		return nil
+	}
+	var v HomeVolume
+	if json.Unmarshal([]byte(s), &v) != nil {
+		return nil
+	}
+	return &v
+}
+
+// marshalProjectDrive serializes a project drive for the drive column.
+// A nil drive or one without a mount path is stored as empty.
+func marshalProjectDrive(d *ProjectDrive) string {
+	if d == nil || d.MountPath == "" {
+		return ""
+	}
+	b, err := json.Marshal(d)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// unmarshalProjectDrive parses the drive column, returning nil when it is
+// empty.
+func unmarshalProjectDrive(s string) *ProjectDrive {
+	if s == "" {
+		return nil
+	}
+	var d ProjectDrive
+	if json.Unmarshal([]byte(s), &d) != nil {
+		return nil
+	}
+	return &d
+}
+
+// marshalList serializes a list for a JSON column, storing an empty list
+// as an empty string.
+func marshalList[T any](items []T) string {
+	if len(items) == 0 {
+		return ""
+	}
+	b, err := json.Marshal(items)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// unmarshalList parses a JSON list column, returning nil when it is empty.
+func unmarshalList[T any](s string) []T {
+	if s == "" {
+		return nil
+	}
+	var items []T
+	if json.Unmarshal([]byte(s), &items) != nil {
+		return nil
+	}
+	return items
+}
+
+// --- Category hooks ---
+
+var _ bun.BeforeAppendModelHook = (*Category)(nil)
+var _ bun.AfterScanRowHook = (*Category)(nil)
+
+func (c *Category) BeforeAppendModel(_ context.Context, query bun.Query) error {
+	// Marshal Defaults → DefaultsJSON
+	c.DefaultsJSON = marshalPolicyDefaults(c.Defaults)
+	return nil
+}
+
+func (c *Category) AfterScanRow(_ context.Context) error {
+	// Unmarshal DefaultsJSON → Defaults
+	c.Defaults = unmarshalPolicyDefaults(c.DefaultsJSON)
 	return nil
 }
 
@@ -266,12 +542,78 @@ func (t *Tenant) AfterScanRow(_ context.Context) error {
 	return nil
 }
 
+// --- TenantBranding hooks ---
+
+var _ bun.BeforeAppendModelHook = (*TenantBranding)(nil)
+var _ bun.AfterScanRowHook = (*TenantBranding)(nil)
+
+func (b *TenantBranding) BeforeAppendModel(_ context.Context, query bun.Query) error {
+	// Marshal Theme → ThemeJSON
+	if j, err := json.Marshal(b.Theme); err == nil {
+		b.ThemeJSON = string(j)
+	}
+
+	// Marshal Assets → AssetsJSON
+	b.AssetsJSON = "{}"
+	if len(b.Assets) > 0 {
+		if j, err := json.Marshal(b.Assets); err == nil {
+			b.AssetsJSON = string(j)
+		}
+	}
+
+	return nil
+}
+
+func (b *TenantBranding) AfterScanRow(_ context.Context) error {
+	// Unmarshal ThemeJSON → Theme
+	b.Theme = BrandingTheme{}
+	if b.ThemeJSON != "" && b.ThemeJSON != "{}" {
+		json.Unmarshal([]byte(b.ThemeJSON), &b.Theme)
+	}
+
+	// Unmarshal AssetsJSON → Assets
+	b.Assets = nil
+	if b.AssetsJSON != "" && b.AssetsJSON != "{}" {
+		json.Unmarshal([]byte(b.AssetsJSON), &b.Assets)
+	}
+
+	return nil
+}
+
+// --- SidecarTemplate hooks ---
+
+var _ bun.BeforeAppendModelHook = (*SidecarTemplate)(nil)
+var _ bun.AfterScanRowHook = (*SidecarTemplate)(nil)
+
+func (t *SidecarTemplate) BeforeAppendModel(_ context.Context, query bun.Query) error {
+	// Marshal Spec → SpecJSON
+	if b, err := json.Marshal(t.Spec); err == nil {
+		t.SpecJSON = string(b)
+	}
+
+	return nil
+}
+
+func (t *SidecarTemplate) AfterScanRow(_ context.Context) error {
+	// Unmarshal SpecJSON → Spec
+	t.Spec = SidecarSpec{}
+	if t.SpecJSON != "" && t.SpecJSON != "{}" {
+		json.Unmarshal([]byte(t.SpecJSON), &t.Spec)
+	}
+
+	return nil
+}
+
 // --- AppSpec hooks ---
 
 var _ bun.BeforeAppendModelHook = (*AppSpec)(nil)
 var _ bun.AfterScanRowHook = (*AppSpec)(nil)
 
 func (s *AppSpec) BeforeAppendModel(_ context.Context, query bun.Query) error {
+	if s.TenantID == "" {
+		s.TenantID = DefaultTenantID
+	}
+
 	// Marshal EnvVars → EnvVarsJSON
 	s.EnvVarsJSON = "[]"
 	if len(s.EnvVars) > 0 {
@@ -304,6 +646,13 @@ func (s *AppSpec) BeforeAppendModel(_ context.Context, query bun.Query) error {
 		}
 	}
 
+	s.DNSJSON = marshalDNSConfig(s.DNS)
+	s.SchedulingJSON = marshalScheduling(s.Scheduling)
+	s.HomeVolumeJSON = marshalHomeVolume(s.HomeVolume)
+	s.ContainersJSON = marshalList(s.Containers)
+	s.SharedVolumesJSON = marshalList(s.SharedVolumes)
+	s.InitContainersJSON = marshalList(s.InitContainers)
+
 	// Flatten Resources → individual columns
 	if s.Resources != nil {
 		s.CPURequest = s.Resources.CPURequest
@@ -348,6 +697,13 @@ func (s *AppSpec) AfterScanRow(_ context.Context) error {
 		}
 	}
 
+	s.DNS = unmarshalDNSConfig(s.DNSJSON)
+	s.Scheduling = unmarshalScheduling(s.SchedulingJSON)
+	s.HomeVolume = unmarshalHomeVolume(s.HomeVolumeJSON)
+	s.Containers = unmarshalList[AppContainer](s.ContainersJSON)
+	s.SharedVolumes = unmarshalList[SharedVolumeMount](s.SharedVolumesJSON)
+	s.InitContainers = unmarshalList[InitContainer](s.InitContainersJSON)
+
 	// Reconstruct Resources from individual columns
 	if s.CPURequest != "" || s.CPULimit != "" || s.MemoryRequest != "" || s.MemoryLimit != "" {
 		s.Resources = &ResourceLimits{
@@ -362,3 +718,83 @@ func (s *AppSpec) AfterScanRow(_ context.Context) error {
 
 	return nil
 }
+
+// --- Project hooks ---
+
+var _ bun.BeforeAppendModelHook = (*Project)(nil)
+var _ bun.AfterScanRowHook = (*Project)(nil)
+
+func (p *Project) BeforeAppendModel(_ context.Context, query bun.Query) error {
+	// Marshal Drive → DriveJSON
+	p.DriveJSON = marshalProjectDrive(p.Drive)
+	return nil
+}
+
+func (p *Project) AfterScanRow(_ context.Context) error {
+	// Unmarshal DriveJSON → Drive
+	p.Drive = unmarshalProjectDrive(p.DriveJSON)
+	return nil
+}
+
+// --- RemoteCatalog hooks ---
+
+var _ bun.BeforeAppendModelHook = (*RemoteCatalog)(nil)
+var _ bun.AfterScanRowHook = (*RemoteCatalog)(nil)
+
+func (c *RemoteCatalog) BeforeAppendModel(_ context.Context, query bun.Query) error {
+	// Marshal SyncResult → SyncResultJSON
+	c.SyncResultJSON = ""
+	if c.SyncResult != nil {
+		if b, err := json.Marshal(c.SyncResult); err == nil {
+			c.SyncResultJSON = string(b)
+		}
+	}
+	return nil
+}
+
+func (c *RemoteCatalog) AfterScanRow(_ context.Context) error {
+	// Unmarshal SyncResultJSON → SyncResult
+	c.SyncResult = nil
+	if c.SyncResultJSON != "" {
+		c.SyncResult = &CatalogSyncResult{}
+		json.Unmarshal([]byte(c.SyncResultJSON), c.SyncResult)
+	}
+	return nil
+}
+
+// --- SettingChangeRequest hooks ---
+
+var _ bun.BeforeAppendModelHook = (*SettingChangeRequest)(nil)
+var _ bun.AfterScanRowHook = (*SettingChangeRequest)(nil)
+
+func (r *SettingChangeRequest) BeforeAppendModel(_ context.Context, query bun.Query) error {
+	// Marshal Changes → ChangesJSON
+	if b, err := json.Marshal(r.Changes); err == nil {
+		r.ChangesJSON = string(b)
+	}
+
+	// Marshal Tenant → TenantJSON
+	r.TenantJSON = ""
+	if r.Tenant != nil {
+		if b, err := json.Marshal(r.Tenant); err == nil {
+			r.TenantJSON = string(b)
+		}
+	}
+	return nil
+}
+
+func (r *SettingChangeRequest) AfterScanRow(_ context.Context) error {
+	// Unmarshal ChangesJSON → Changes
+	r.Changes = nil
+	if r.ChangesJSON != "" && r.ChangesJSON != "null" {
+		json.Unmarshal([]byte(r.ChangesJSON), &r.Changes)
+	}
+
+	// Unmarshal TenantJSON → Tenant
+	r.Tenant = nil
+	if r.TenantJSON != "" {
+		r.Tenant = &TenantChange{}
+		json.Unmarshal([]byte(r.TenantJSON), r.Tenant)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// IdPToken holds the refresh token an identity provider issued at a user's
+// last SSO login. It lets an admin re-read the user's groups without
+// waiting for them to sign in again.
+type IdPToken struct {
+	bun.BaseModel `bun:"table:idp_tokens"`
+
+	UserID       string    `json:"user_id" bun:"user_id,pk"`
+	Provider     string    `json:"provider" bun:"provider,notnull"`
+	RefreshToken string    `json:"-" bun:"refresh_token,notnull"`
+	UpdatedAt    time.Time `json:"updated_at" bun:"updated_at,notnull"`
+}
+
+// SaveIdPToken stores the identity provider refresh token for a user,
+// replacing any previous one.
+func (db *DB) SaveIdPToken(userID, provider, refreshToken string) error {
+	token := &IdPToken{UserID: userID, Provider: provider, RefreshToken: refreshToken, UpdatedAt: time.Now()}
+	_, err := db.conn.NewInsert().Model(token).
+		On("CONFLICT (user_id) DO UPDATE").
+		Set("provider = EXCLUDED.provider").
+		Set("refresh_token = EXCLUDED.refresh_token").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(db.ctx())
+	return err
+}
+
+// GetIdPToken returns the identity provider refresh token stored for a
+// user, or nil if there is none.
+func (db *DB) GetIdPToken(userID string) (*IdPToken, error) {
+	var token IdPToken
+	err := db.conn.NewSelect().Model(&token).Where("user_id = ?", userID).Scan(db.ctx())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// DeleteIdPToken removes the identity provider refresh token stored for a
+// user, e.g. once the provider has rejected it.
+func (db *DB) DeleteIdPToken(userID string) error {
+	_, err := db.conn.NewDelete().Model((*IdPToken)(nil)).
+		Where("user_id = ?", userID).
+		Exec(db.ctx())
+	return err
+}
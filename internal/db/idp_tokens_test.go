@@ -0,0 +1,29 @@
+package db
+
+import "testing"
+
+func TestIdPTokens(t *testing.T) {
+	db := setupTestDB(t)
+
+	if got, err := db.GetIdPToken("user-1"); err != nil || got != nil {
+		t.Fatalf("GetIdPToken() before save = %v, %v; want nil", got, err)
+	}
+
+	if err := db.SaveIdPToken("user-1", "oidc", "first"); err != nil {
+		t.Fatalf("SaveIdPToken() error = %v", err)
+	}
+	if err := db.SaveIdPToken("user-1", "oidc", "rotated"); err != nil {
+		t.Fatalf("SaveIdPToken() replace error = %v", err)
+	}
+	got, err := db.GetIdPToken("user-1")
+	if err != nil || got == nil || got.RefreshToken != "rotated" || got.Provider != "oidc" {
+		t.Fatalf("GetIdPToken() = %+v, %v; want rotated oidc token", got, err)
+	}
+
+	if err := db.DeleteIdPToken("user-1"); err != nil {
+		t.Fatalf("DeleteIdPToken() error = %v", err)
+	}
+	if got, _ := db.GetIdPToken("user-1"); got != nil {
+		t.Errorf("GetIdPToken() after delete = %+v, want nil", got)
+	}
+}
@@ -0,0 +1,169 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Job run statuses.
+const (
+	JobRunQueued    = "queued"
+	JobRunRunning   = "running"
+	JobRunSucceeded = "succeeded"
+	JobRunFailed    = "failed"
+)
+
+// Job run triggers.
+const (
+	JobTriggerSchedule = "schedule"
+	JobTriggerManual   = "manual"
+)
+
+// JobRun records one run of a background job, from when it was scheduled
+// or requested until it finished.
+type JobRun struct {
+	bun.BaseModel `bun:"table:job_runs"`
+
+	ID          int64      `json:"id" bun:"id,pk,autoincrement"`
+	Job         string     `json:"job" bun:"job,notnull"`
+	Trigger     string     `json:"trigger" bun:"trigger_type,notnull"`
+	RequestedBy string     `json:"requested_by,omitempty" bun:"requested_by,notnull"`
+	Status      string     `json:"status" bun:"status,notnull"`
+	Attempts    int        `json:"attempts" bun:"attempts,notnull"`
+	Error       string     `json:"error,omitempty" bun:"error,notnull"`
+	Replica     string     `json:"replica,omitempty" bun:"replica,notnull"`
+	CreatedAt   time.Time  `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	StartedAt   *time.Time `json:"started_at,omitempty" bun:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty" bun:"finished_at"`
+}
+
+// JobRunPage holds a page of job runs with total count
+type JobRunPage struct {
+	Runs  []JobRun `json:"runs"`
+	Total int      `json:"total"`
+}
+
+// CreateJobRun records a queued or started job run.
+func (db *DB) CreateJobRun(run *JobRun) error {
+	if run.CreatedAt.IsZero() {
+		run.CreatedAt = time.Now()
+	}
+	_, err := db.conn.NewInsert().Model(run).Exec(db.ctx())
+	return err
+}
+
+// ClaimJobRun marks a queued run as started by replica. It returns false if
+// the run is no longer queued, because another replica claimed it first.
+func (db *DB) ClaimJobRun(id int64, replica string, startedAt time.Time) (bool, error) {
+	res, err := db.conn.NewUpdate().Model((*JobRun)(nil)).
+		Set("status = ?", JobRunRunning).
+		Set("replica = ?", replica).
+		Set("started_at = ?", startedAt).
+		Where("id = ?", id).
+		Where("status = ?", JobRunQueued).
+		Exec(db.ctx())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// FinishJobRun records the outcome of a started run.
+func (db *DB) FinishJobRun(id int64, status string, attempts int, errMsg string, finishedAt time.Time) error {
+	_, err := db.conn.NewUpdate().Model((*JobRun)(nil)).
+		Set("status = ?", status).
+		Set("attempts = ?", attempts).
+		Set("error = ?", errMsg).
+		Set("finished_at = ?", finishedAt).
+		Where("id = ?", id).
+		Exec(db.ctx())
+	return err
+}
+
+// ListQueuedJobRuns returns runs waiting to be picked up, oldest first.
+func (db *DB) ListQueuedJobRuns() ([]JobRun, error) {
+	runs := []JobRun{}
+	err := db.conn.NewSelect().Model(&runs).
+		Where("status = ?", JobRunQueued).
+		OrderExpr("id ASC").
+		Scan(db.ctx())
+	return runs, err
+}
+
+// LatestJobRuns returns each job's most recent run, keyed by job.
+func (db *DB) LatestJobRuns() (map[string]JobRun, error) {
+	var runs []JobRun
+	err := db.conn.NewSelect().Model(&runs).
+		Where("id IN (?)", db.conn.NewSelect().Model((*JobRun)(nil)).
+			ColumnExpr("MAX(id)").
+			Group("job")).
+		Scan(db.ctx())
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[string]JobRun, len(runs))
+	for _, r := range runs {
+		latest[r.Job] = r
+	}
+	return latest, nil
+}
+
+// QueryJobRuns returns a job's runs, newest first, with pagination.
+func (db *DB) QueryJobRuns(job string, limit, offset int) (*JobRunPage, error) {
+	q := db.conn.NewSelect().Model((*JobRun)(nil)).Where("job = ?", job)
+
+	total, err := q.Count(db.ctx())
+	if err != nil {
+		return nil, fmt.Errorf("failed to count job runs: %w", err)
+	}
+
+	if limit <= 0 || limit > 1000 {
+		limit = 50
+	}
+
+	runs := []JobRun{}
+	err = q.OrderExpr("id DESC").
+		Limit(limit).
+		Offset(max(offset, 0)).
+		Scan(db.ctx(), &runs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job runs: %w", err)
+	}
+	return &JobRunPage{Runs: runs, Total: total}, nil
+}
+
+// InterruptJobRuns marks a job's runs that started before t and never
+// finished as failed, for runs whose replica went away. It returns the
+// number of runs marked.
+func (db *DB) InterruptJobRuns(job string, startedBefore time.Time) (int64, error) {
+	res, err := db.conn.NewUpdate().Model((*JobRun)(nil)).
+		Set("status = ?", JobRunFailed).
+		Set("error = ?", "interrupted").
+		Set("finished_at = ?", time.Now()).
+		Where("job = ?", job).
+		Where("status = ?", JobRunRunning).
+		Where("started_at < ?", startedBefore).
+		Exec(db.ctx())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteJobRunsBefore removes runs created before t, except each job's
+// latest. It returns the number of runs removed.
+func (db *DB) DeleteJobRunsBefore(t time.Time) (int64, error) {
+	res, err := db.conn.NewDelete().Model((*JobRun)(nil)).
+		Where("created_at < ?", t).
+		Where("id NOT IN (?)", db.conn.NewSelect().Model((*JobRun)(nil)).
+			ColumnExpr("MAX(id)").
+			Group("job")).
+		Exec(db.ctx())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJobRuns(t *testing.T) {
+	db := setupTestDB(t)
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	started := base
+	done := &JobRun{Job: "cleanup", Trigger: JobTriggerSchedule, Status: JobRunRunning, Replica: "a", CreatedAt: base, StartedAt: &started}
+	if err := db.CreateJobRun(done); err != nil {
+		t.Fatalf("CreateJobRun() error = %v", err)
+	}
+	if err := db.FinishJobRun(done.ID, JobRunFailed, 3, "boom", base.Add(time.Minute)); err != nil {
+		t.Fatalf("FinishJobRun() error = %v", err)
+	}
+	queued := &JobRun{Job: "cleanup", Trigger: JobTriggerManual, RequestedBy: "admin", Status: JobRunQueued, CreatedAt: base.Add(time.Hour)}
+	if err := db.CreateJobRun(queued); err != nil {
+		t.Fatalf("CreateJobRun() error = %v", err)
+	}
+	if err := db.CreateJobRun(&JobRun{Job: "repair", Trigger: JobTriggerSchedule, Status: JobRunRunning, CreatedAt: base, StartedAt: &started}); err != nil {
+		t.Fatalf("CreateJobRun() error = %v", err)
+	}
+
+	t.Run("claim", func(t *testing.T) {
+		runs, err := db.ListQueuedJobRuns()
+		if err != nil || len(runs) != 1 || runs[0].ID != queued.ID {
+			t.Fatalf("ListQueuedJobRuns() = %+v, %v", runs, err)
+		}
+		if ok, err := db.ClaimJobRun(queued.ID, "b", time.Now()); !ok || err != nil {
+			t.Fatalf("ClaimJobRun() = %v, %v", ok, err)
+		}
+		if ok, _ := db.ClaimJobRun(queued.ID, "c", time.Now()); ok {
+			t.Error("claimed a run twice")
+		}
+	})
+
+	t.Run("latest", func(t *testing.T) {
+		latest, err := db.LatestJobRuns()
+		if err != nil {
+			t.Fatalf("LatestJobRuns() error = %v", err)
+		}
+		if r := latest["cleanup"]; r.ID != queued.ID || r.Status != JobRunRunning || r.Replica != "b" {
+			t.Errorf("latest cleanup run = %+v", r)
+		}
+		if len(latest) != 2 {
+			t.Errorf("LatestJobRuns() = %d jobs, want 2", len(latest))
+		}
+	})
+
+	t.Run("query", func(t *testing.T) {
+		page, err := db.QueryJobRuns("cleanup", 1, 0)
+		if err != nil {
+			t.Fatalf("QueryJobRuns() error = %v", err)
+		}
+		if page.Total != 2 || len(page.Runs) != 1 || page.Runs[0].ID != queued.ID {
+			t.Errorf("QueryJobRuns() = %+v", page)
+		}
+		page, _ = db.QueryJobRuns("cleanup", 10, 1)
+		if r := page.Runs[0]; r.Status != JobRunFailed || r.Attempts != 3 || r.Error != "boom" || r.FinishedAt == nil {
+			t.Errorf("finished run = %+v", r)
+		}
+	})
+
+	t.Run("interrupt", func(t *testing.T) {
+		n, err := db.InterruptJobRuns("repair", base.Add(time.Second))
+		if err != nil || n != 1 {
+			t.Fatalf("InterruptJobRuns() = %d, %v; want 1", n, err)
+		}
+		latest, _ := db.LatestJobRuns()
+		if r := latest["repair"]; r.Status != JobRunFailed || r.Error != "interrupted" {
+			t.Errorf("interrupted run = %+v", r)
+		}
+	})
+
+	t.Run("retention", func(t *testing.T) {
+		n, err := db.DeleteJobRunsBefore(time.Now())
+		if err != nil {
+			t.Fatalf("DeleteJobRunsBefore() error = %v", err)
+		}
+		if n != 1 {
+			t.Errorf("deleted %d runs, want 1: each job's latest is kept", n)
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// LeaderLease records which replica holds a named lease, such as the one
+// that lets a replica run the session cleanup loop.
+type LeaderLease struct {
+	bun.BaseModel `bun:"table:leader_leases"`
+
+	Name       string    `json:"name" bun:"name,pk"`
+	Holder     string    `json:"holder" bun:"holder,notnull"`
+	AcquiredAt time.Time `json:"acquired_at" bun:"acquired_at,notnull"`
+	ExpiresAt  time.Time `json:"expires_at" bun:"expires_at,notnull"`
+}
+
+// AcquireLease takes or renews the named lease for holder until ttl from
+// now. It succeeds if the lease is free, already held by holder, or has
+// expired, and reports whether holder now holds it.
+func (db *DB) AcquireLease(name, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	lease := &LeaderLease{Name: name, Holder: holder, AcquiredAt: now, ExpiresAt: now.Add(ttl)}
+	result, err := db.conn.NewInsert().Model(lease).
+		On("CONFLICT (name) DO UPDATE").
+		Set("acquired_at = CASE WHEN leader_lease.holder = EXCLUDED.holder THEN leader_lease.acquired_at ELSE EXCLUDED.acquired_at END").
+		Set("holder = EXCLUDED.holder").
+		Set("expires_at = EXCLUDED.expires_at").
+		Where("leader_lease.holder = EXCLUDED.holder OR leader_lease.expires_at < ?", now).
+		Exec(db.ctx())
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// ReleaseLease gives up the named lease if holder holds it, so another
+// replica can take it over without waiting for it to expire.
+func (db *DB) ReleaseLease(name, holder string) error {
+	_, err := db.conn.NewDelete().Model((*LeaderLease)(nil)).
+		Where("name = ?", name).
+		Where("holder = ?", holder).
+		Exec(db.ctx())
+	return err
+}
+
+// GetLease returns the named lease, or nil if no replica has taken it.
+// The lease may have expired.
+func (db *DB) GetLease(name string) (*LeaderLease, error) {
+	var lease LeaderLease
+	err := db.conn.NewSelect().Model(&lease).Where("name = ?", name).Scan(db.ctx())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &lease, nil
+}
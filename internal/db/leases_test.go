@@ -0,0 +1,59 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAcquireLease(t *testing.T) {
+	db := setupTestDB(t)
+
+	ok, err := db.AcquireLease("cleanup", "replica-a", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("AcquireLease(a) = %v, %v; want true", ok, err)
+	}
+	first, _ := db.GetLease("cleanup")
+
+	// Another replica cannot take a live lease
+	ok, err = db.AcquireLease("cleanup", "replica-b", time.Minute)
+	if err != nil || ok {
+		t.Fatalf("AcquireLease(b) = %v, %v; want false", ok, err)
+	}
+
+	// The holder can renew it, keeping its acquisition time
+	time.Sleep(10 * time.Millisecond)
+	ok, err = db.AcquireLease("cleanup", "replica-a", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("renew = %v, %v; want true", ok, err)
+	}
+	renewed, _ := db.GetLease("cleanup")
+	if renewed.Holder != "replica-a" || !renewed.AcquiredAt.Equal(first.AcquiredAt) || !renewed.ExpiresAt.After(first.ExpiresAt) {
+		t.Errorf("renewed lease = %+v, first = %+v", renewed, first)
+	}
+
+	// An expired lease can be taken over
+	if ok, _ := db.AcquireLease("expiring", "replica-a", -time.Second); !ok {
+		t.Fatal("expected to acquire expiring lease")
+	}
+	ok, err = db.AcquireLease("expiring", "replica-b", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("takeover = %v, %v; want true", ok, err)
+	}
+	if lease, _ := db.GetLease("expiring"); lease.Holder != "replica-b" {
+		t.Errorf("expiring lease holder = %s, want replica-b", lease.Holder)
+	}
+
+	// Releasing only works for the holder
+	if err := db.ReleaseLease("cleanup", "replica-b"); err != nil {
+		t.Fatal(err)
+	}
+	if lease, _ := db.GetLease("cleanup"); lease == nil {
+		t.Fatal("lease released by a replica that does not hold it")
+	}
+	if err := db.ReleaseLease("cleanup", "replica-a"); err != nil {
+		t.Fatal(err)
+	}
+	if lease, _ := db.GetLease("cleanup"); lease != nil {
+		t.Errorf("expected lease to be released, got %+v", lease)
+	}
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Kinds of catalog entries a lint warning is about.
+const (
+	LintKindApp      = "app"
+	LintKindTemplate = "template"
+)
+
+// LintWarning is a best-practice rule that an app or template in the
+// catalog breaks, as found by the last catalog lint.
+type LintWarning struct {
+	bun.BaseModel `bun:"table:lint_warnings"`
+
+	ID int64 `json:"-" bun:"id,pk,autoincrement"`
+	// Kind is LintKindApp or LintKindTemplate.
+	Kind string `json:"kind" bun:"kind,notnull"`
+	// TargetID is the app ID or the template's template_id.
+	TargetID   string `json:"target_id" bun:"target_id,notnull"`
+	TargetName string `json:"target_name" bun:"target_name,notnull"`
+	TenantID   string `json:"tenant_id,omitempty" bun:"tenant_id,notnull"`
+	Rule       string `json:"rule" bun:"rule,notnull"`
+	Severity   string `json:"severity" bun:"severity,notnull"`
+	// Field is the JSON path of the setting the warning is about, if any.
+	Field     string    `json:"field,omitempty" bun:"field,notnull"`
+	Message   string    `json:"message" bun:"message,notnull"`
+	CheckedAt time.Time `json:"checked_at" bun:"checked_at,notnull"`
+}
+
+// LintWarningFilter narrows ListLintWarnings. Empty fields match every
+// warning.
+type LintWarningFilter struct {
+	Kind     string
+	Rule     string
+	Severity string
+	TenantID string
+}
+
+// ReplaceLintWarnings replaces every stored lint warning with warnings.
+func (db *DB) ReplaceLintWarnings(warnings []LintWarning) error {
+	return db.runInTx(func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewDelete().Model((*LintWarning)(nil)).Where("1 = 1").Exec(ctx); err != nil {
+			return err
+		}
+		if len(warnings) == 0 {
+			return nil
+		}
+		_, err := tx.NewInsert().Model(&warnings).Exec(ctx)
+		return err
+	})
+}
+
+// ListLintWarnings returns the stored lint warnings matching filter,
+// ordered by kind, target, and rule.
+func (db *DB) ListLintWarnings(filter LintWarningFilter) ([]LintWarning, error) {
+	warnings := []LintWarning{}
+	q := db.conn.NewSelect().Model(&warnings)
+	if filter.Kind != "" {
+		q = q.Where("kind = ?", filter.Kind)
+	}
+	if filter.Rule != "" {
+		q = q.Where("rule = ?", filter.Rule)
+	}
+	if filter.Severity != "" {
+		q = q.Where("severity = ?", filter.Severity)
+	}
+	if filter.TenantID != "" {
+		q = q.Where("tenant_id = ?", filter.TenantID)
+	}
+	err := q.OrderExpr("kind, target_id, rule, field, id").Scan(db.ctx())
+	return warnings, err
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLintWarnings(t *testing.T) {
+	db := setupTenantTestDB(t)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	first := []LintWarning{
+		{Kind: LintKindApp, TargetID: "cad", TenantID: "acme", Rule: "latest-tag", Severity: "warning", Field: "container_image", Message: "pin it", CheckedAt: now},
+		{Kind: LintKindApp, TargetID: "cad", TenantID: "acme", Rule: "icon", Severity: "info", Field: "icon", Message: "add one", CheckedAt: now},
+		{Kind: LintKindTemplate, TargetID: "ide", Rule: "icon", Severity: "info", Field: "icon", Message: "add one", CheckedAt: now},
+	}
+	if err := db.ReplaceLintWarnings(first); err != nil {
+		t.Fatalf("ReplaceLintWarnings() error = %v", err)
+	}
+
+	all, err := db.ListLintWarnings(LintWarningFilter{})
+	if err != nil || len(all) != 3 {
+		t.Fatalf("ListLintWarnings() = %+v, %v", all, err)
+	}
+	if all[0].Rule != "icon" || all[1].Rule != "latest-tag" || all[2].Kind != LintKindTemplate {
+		t.Errorf("ListLintWarnings() order = %+v", all)
+	}
+	if !all[0].CheckedAt.Equal(now) {
+		t.Errorf("CheckedAt = %v, want %v", all[0].CheckedAt, now)
+	}
+
+	for _, tt := range []struct {
+		filter LintWarningFilter
+		want   int
+	}{
+		{LintWarningFilter{Kind: LintKindTemplate}, 1},
+		{LintWarningFilter{Rule: "icon"}, 2},
+		{LintWarningFilter{Severity: "warning"}, 1},
+		{LintWarningFilter{TenantID: "acme", Rule: "icon"}, 1},
+	} {
+		got, err := db.ListLintWarnings(tt.filter)
+		if err != nil || len(got) != tt.want {
+			t.Errorf("ListLintWarnings(%+v) = %d warnings, %v; want %d", tt.filter, len(got), err, tt.want)
+		}
+	}
+
+	if err := db.ReplaceLintWarnings(first[2:]); err != nil {
+		t.Fatalf("ReplaceLintWarnings() error = %v", err)
+	}
+	if all, _ := db.ListLintWarnings(LintWarningFilter{}); len(all) != 1 || all[0].TargetID != "ide" {
+		t.Errorf("after replace = %+v", all)
+	}
+	if err := db.ReplaceLintWarnings(nil); err != nil {
+		t.Fatalf("ReplaceLintWarnings(nil) error = %v", err)
+	}
+	if all, _ := db.ListLintWarnings(LintWarningFilter{}); len(all) != 0 {
+		t.Errorf("after clearing = %+v", all)
+	}
+}
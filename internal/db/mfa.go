@@ -0,0 +1,154 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// UserMFA is a user's TOTP enrollment. It is pending until the user proves
+// their authenticator works by verifying a code, which sets EnabledAt.
+type UserMFA struct {
+	bun.BaseModel `bun:"table:user_mfa"`
+
+	UserID    string     `json:"user_id" bun:"user_id,pk"`
+	Secret    string     `json:"-" bun:"secret,notnull"`    // Base32-encoded TOTP key
+	LastStep  int64      `json:"-" bun:"last_step,notnull"` // Last accepted TOTP time step
+	CreatedAt time.Time  `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	EnabledAt *time.Time `json:"enabled_at,omitempty" bun:"enabled_at"`
+}
+
+// Enabled reports whether the enrollment has been verified.
+func (m *UserMFA) Enabled() bool {
+	return m != nil && m.EnabledAt != nil
+}
+
+// MFABackupCode is a one-time code a user can sign in with in place of a
+// TOTP code. Only the code's SHA-256 hash is stored.
+type MFABackupCode struct {
+	bun.BaseModel `bun:"table:mfa_backup_codes"`
+
+	ID       string     `json:"id" bun:"id,pk"`
+	UserID   string     `json:"user_id" bun:"user_id,notnull"`
+	CodeHash string     `json:"-" bun:"code_hash,notnull"`
+	UsedAt   *time.Time `json:"used_at,omitempty" bun:"used_at"`
+}
+
+// SaveUserMFA starts a TOTP enrollment for a user with the given secret,
+// replacing any pending or enabled enrollment.
+func (db *DB) SaveUserMFA(userID, secret string) error {
+	mfa := &UserMFA{UserID: userID, Secret: secret, CreatedAt: time.Now()}
+	_, err := db.conn.NewInsert().Model(mfa).
+		On("CONFLICT (user_id) DO UPDATE").
+		Set("secret = EXCLUDED.secret").
+		Set("last_step = 0").
+		Set("created_at = EXCLUDED.created_at").
+		Set("enabled_at = NULL").
+		Exec(db.ctx())
+	return err
+}
+
+// GetUserMFA returns a user's TOTP enrollment, or nil if they have none.
+func (db *DB) GetUserMFA(userID string) (*UserMFA, error) {
+	var mfa UserMFA
+	err := db.conn.NewSelect().Model(&mfa).Where("user_id = ?", userID).Scan(db.ctx())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &mfa, nil
+}
+
+// UseMFAStep records that a user's TOTP code for step was accepted. It
+// returns false if that step or a later one was already used, so each code
+// works once.
+func (db *DB) UseMFAStep(userID string, step int64) (bool, error) {
+	result, err := db.conn.NewUpdate().Model((*UserMFA)(nil)).
+		Set("last_step = ?", step).
+		Where("user_id = ?", userID).
+		Where("last_step < ?", step).
+		Exec(db.ctx())
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// EnableUserMFA marks a user's pending enrollment verified at step and
+// replaces their backup codes.
+func (db *DB) EnableUserMFA(userID string, step int64, codeHashes []string) error {
+	return db.WithTx(func(tx *DB) error {
+		if _, err := tx.conn.NewUpdate().Model((*UserMFA)(nil)).
+			Set("enabled_at = ?", time.Now()).
+			Set("last_step = ?", step).
+			Where("user_id = ?", userID).
+			Exec(tx.ctx()); err != nil {
+			return err
+		}
+		return tx.ReplaceMFABackupCodes(userID, codeHashes)
+	})
+}
+
+// ReplaceMFABackupCodes discards a user's backup codes and stores new ones.
+func (db *DB) ReplaceMFABackupCodes(userID string, codeHashes []string) error {
+	return db.WithTx(func(tx *DB) error {
+		if _, err := tx.conn.NewDelete().Model((*MFABackupCode)(nil)).
+			Where("user_id = ?", userID).
+			Exec(tx.ctx()); err != nil {
+			return err
+		}
+		if len(codeHashes) == 0 {
+			return nil
+		}
+		codes := make([]MFABackupCode, len(codeHashes))
+		for i, hash := range codeHashes {
+			codes[i] = MFABackupCode{ID: uuid.New().String(), UserID: userID, CodeHash: hash}
+		}
+		_, err := tx.conn.NewInsert().Model(&codes).Exec(tx.ctx())
+		return err
+	})
+}
+
+// UseMFABackupCode marks the user's unused backup code with the given hash
+// as used. It returns false if there is no such code.
+func (db *DB) UseMFABackupCode(userID, codeHash string) (bool, error) {
+	result, err := db.conn.NewUpdate().Model((*MFABackupCode)(nil)).
+		Set("used_at = ?", time.Now()).
+		Where("user_id = ?", userID).
+		Where("code_hash = ?", codeHash).
+		Where("used_at IS NULL").
+		Exec(db.ctx())
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// CountMFABackupCodes returns how many unused backup codes a user has left.
+func (db *DB) CountMFABackupCodes(userID string) (int, error) {
+	return db.conn.NewSelect().Model((*MFABackupCode)(nil)).
+		Where("user_id = ?", userID).
+		Where("used_at IS NULL").
+		Count(db.ctx())
+}
+
+// DeleteUserMFA removes a user's TOTP enrollment and backup codes.
+func (db *DB) DeleteUserMFA(userID string) error {
+	return db.WithTx(func(tx *DB) error {
+		if _, err := tx.conn.NewDelete().Model((*MFABackupCode)(nil)).
+			Where("user_id = ?", userID).
+			Exec(tx.ctx()); err != nil {
+			return err
+		}
+		_, err := tx.conn.NewDelete().Model((*UserMFA)(nil)).
+			Where("user_id = ?", userID).
+			Exec(tx.ctx())
+		return err
+	})
+}
@@ -0,0 +1,85 @@
+package db
+
+import "testing"
+
+func TestUserMFAStore(t *testing.T) {
+	db := setupTestDB(t)
+
+	if got, err := db.GetUserMFA("user-1"); err != nil || got != nil {
+		t.Fatalf("GetUserMFA() before enrollment = %+v, %v; want nil", got, err)
+	}
+
+	if err := db.SaveUserMFA("user-1", "SECRETONE"); err != nil {
+		t.Fatalf("SaveUserMFA() error = %v", err)
+	}
+	got, err := db.GetUserMFA("user-1")
+	if err != nil || got == nil || got.Secret != "SECRETONE" || got.Enabled() {
+		t.Fatalf("GetUserMFA() = %+v, %v; want a pending enrollment", got, err)
+	}
+
+	if err := db.EnableUserMFA("user-1", 100, []string{"hash-a", "hash-b"}); err != nil {
+		t.Fatalf("EnableUserMFA() error = %v", err)
+	}
+	got, _ = db.GetUserMFA("user-1")
+	if !got.Enabled() || got.LastStep != 100 {
+		t.Errorf("after EnableUserMFA() = %+v, want enabled at step 100", got)
+	}
+
+	t.Run("steps are used once", func(t *testing.T) {
+		for _, tt := range []struct {
+			step int64
+			want bool
+		}{{100, false}, {99, false}, {101, true}, {101, false}} {
+			if ok, err := db.UseMFAStep("user-1", tt.step); err != nil || ok != tt.want {
+				t.Errorf("UseMFAStep(%d) = %v, %v; want %v", tt.step, ok, err, tt.want)
+			}
+		}
+	})
+
+	t.Run("backup codes are used once", func(t *testing.T) {
+		if ok, err := db.UseMFABackupCode("user-1", "hash-a"); err != nil || !ok {
+			t.Fatalf("UseMFABackupCode() = %v, %v; want true", ok, err)
+		}
+		if ok, _ := db.UseMFABackupCode("user-1", "hash-a"); ok {
+			t.Error("UseMFABackupCode() accepted a used code")
+		}
+		if ok, _ := db.UseMFABackupCode("user-2", "hash-b"); ok {
+			t.Error("UseMFABackupCode() accepted another user's code")
+		}
+		if n, err := db.CountMFABackupCodes("user-1"); err != nil || n != 1 {
+			t.Errorf("CountMFABackupCodes() = %d, %v; want 1", n, err)
+		}
+
+		if err := db.ReplaceMFABackupCodes("user-1", []string{"hash-c", "hash-d", "hash-e"}); err != nil {
+			t.Fatalf("ReplaceMFABackupCodes() error = %v", err)
+		}
+		if n, _ := db.CountMFABackupCodes("user-1"); n != 3 {
+			t.Errorf("CountMFABackupCodes() after replace = %d, want 3", n)
+		}
+		if ok, _ := db.UseMFABackupCode("user-1", "hash-b"); ok {
+			t.Error("UseMFABackupCode() accepted a replaced code")
+		}
+	})
+
+	t.Run("re-enrolling resets the enrollment", func(t *testing.T) {
+		if err := db.SaveUserMFA("user-1", "SECRETTWO"); err != nil {
+			t.Fatalf("SaveUserMFA() error = %v", err)
+		}
+		got, _ := db.GetUserMFA("user-1")
+		if got.Secret != "SECRETTWO" || got.Enabled() || got.LastStep != 0 {
+			t.Errorf("after re-enrolling = %+v, want a pending enrollment with the new secret", got)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if err := db.DeleteUserMFA("user-1"); err != nil {
+			t.Fatalf("DeleteUserMFA() error = %v", err)
+		}
+		if got, _ := db.GetUserMFA("user-1"); got != nil {
+			t.Errorf("GetUserMFA() after delete = %+v, want nil", got)
+		}
+		if n, _ := db.CountMFABackupCodes("user-1"); n != 0 {
+			t.Errorf("CountMFABackupCodes() after delete = %d, want 0", n)
+		}
+	})
+}
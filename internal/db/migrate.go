@@ -3,6 +3,7 @@ package db
 import (
 	"database/sql"
 	"embed"
+	"errors"
 	"fmt"
 	"io/fs"
 	"log/slog"
@@ -11,6 +12,7 @@ import (
 	"github.com/golang-migrate/migrate/v4/database"
 	migratepostgres "github.com/golang-migrate/migrate/v4/database/postgres"
 	migratesqlite "github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
 )
 
@@ -37,9 +39,8 @@ func runMigrations(dbType, dsn string) error {
 	return nil
 }
 
-// newMigrator creates a golang-migrate instance for the given database type
-// using embedded SQL migration files.
-func newMigrator(conn *sql.DB, dbType string) (*migrate.Migrate, error) {
+// migrationSource returns the embedded SQL migrations for a database type.
+func migrationSource(dbType string) (source.Driver, error) {
 	var migrationFS fs.FS
 	var err error
 
@@ -55,10 +56,20 @@ func newMigrator(conn *sql.DB, dbType string) (*migrate.Migrate, error) {
 		return nil, fmt.Errorf("failed to create sub filesystem: %w", err)
 	}
 
-	source, err := iofs.New(migrationFS, ".")
+	src, err := iofs.New(migrationFS, ".")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create migration source: %w", err)
 	}
+	return src, nil
+}
+
+// newMigrator creates a golang-migrate instance for the given database type
+// using embedded SQL migration files.
+func newMigrator(conn *sql.DB, dbType string) (*migrate.Migrate, error) {
+	src, err := migrationSource(dbType)
+	if err != nil {
+		return nil, err
+	}
 
 	var driver database.Driver
 	switch dbType {
@@ -74,7 +85,7 @@ func newMigrator(conn *sql.DB, dbType string) (*migrate.Migrate, error) {
 		}
 	}
 
-	m, err := migrate.NewWithInstance("iofs", source, dbType, driver)
+	m, err := migrate.NewWithInstance("iofs", src, dbType, driver)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create migrator: %w", err)
 	}
@@ -200,3 +211,48 @@ func NewMigrator(dbType, dsn string) (*migrate.Migrate, error) {
 
 	return newMigrator(conn, dbType)
 }
+
+// MigrationStatus is a database's schema version compared with the
+// migrations built into this binary.
+type MigrationStatus struct {
+	Current uint // 0 if no migrations have been applied
+	Latest  uint
+	Dirty   bool // A migration failed part way and needs fixing by hand
+}
+
+// CheckMigrations connects to a database and reports its migration status
+// without applying any migrations.
+func CheckMigrations(dbType, dsn string) (*MigrationStatus, error) {
+	src, err := migrationSource(dbType)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	latest, err := src.First()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+	for {
+		next, err := src.Next(latest)
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migrations: %w", err)
+		}
+		latest = next
+	}
+
+	m, err := NewMigrator(dbType, dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+
+	status := &MigrationStatus{Latest: latest}
+	status.Current, status.Dirty, err = m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return status, nil
+}
@@ -32,7 +32,25 @@ func TestRunMigrations_Postgres(t *testing.T) {
 		"users", "settings", "templates", "app_specs",
 		"oidc_states", "tenants", "categories",
 		"category_admins", "category_approved_users",
-		"recordings", "session_shares",
+		"recordings", "session_shares", "tenant_branding", "sidecar_templates",
+		"session_attestations", "quota_bursts", "session_archive", "session_history", "leader_leases", "idp_tokens",
+		"recording_chunks",
+		"session_file_events",
+		"api_tokens",
+		"user_mfa", "mfa_backup_codes",
+		"webauthn_credentials", "webauthn_challenges",
+		"password_reset_tokens",
+		"health_events",
+		"job_runs",
+		"session_launches",
+		"scheduled_sessions",
+		"api_usage",
+		"projects", "project_members",
+		"status_announcements",
+		"registry_credentials",
+		"lint_warnings",
+		"remote_catalogs",
+		"setting_change_requests",
 	}
 
 	for _, table := range tables {
@@ -60,8 +78,8 @@ func TestRunMigrations_Postgres(t *testing.T) {
 	if err != nil {
 		t.Fatalf("schema_migrations query error: %v", err)
 	}
-	if version != 2 {
-		t.Errorf("migration version = %d, want 2", version)
+	if want := latestMigrationVersion(t, "postgres"); version != int(want) {
+		t.Errorf("migration version = %d, want %d", version, want)
 	}
 	if dirty {
 		t.Error("migration is dirty, want clean")
@@ -267,8 +285,8 @@ func TestNewMigrator_Postgres(t *testing.T) {
 	if err != nil {
 		t.Fatalf("m.Version() error = %v", err)
 	}
-	if version != 2 {
-		t.Errorf("version = %d, want 2", version)
+	if want := latestMigrationVersion(t, "postgres"); version != want {
+		t.Errorf("version = %d, want %d", version, want)
 	}
 	if dirty {
 		t.Error("dirty = true, want false")
@@ -293,18 +311,18 @@ func TestDownMigration_Postgres(t *testing.T) {
 	}
 	m.Close()
 
-	// Reopen and step down once (undoes 000002 data migration — no-op)
+	// Reopen and step down to the baseline (undoes all later migrations)
 	m, err = NewMigrator("postgres", dsn)
 	if err != nil {
 		t.Fatalf("NewMigrator() reopen error = %v", err)
 	}
 
-	if err := m.Steps(-1); err != nil {
-		t.Fatalf("m.Steps(-1) error = %v", err)
+	if err := m.Migrate(1); err != nil {
+		t.Fatalf("m.Migrate(1) error = %v", err)
 	}
 	version, _, _ := m.Version()
 	if version != 1 {
-		t.Errorf("after one step down, version = %d, want 1", version)
+		t.Errorf("after stepping down to baseline, version = %d, want 1", version)
 	}
 
 	// Step down again (undoes 000001 baseline — drops tables)
@@ -352,11 +370,8 @@ func TestUpDownUpCycle_Postgres(t *testing.T) {
 	if err != nil {
 		t.Fatalf("NewMigrator() error = %v", err)
 	}
-	if err := m.Steps(-1); err != nil {
-		t.Fatalf("m.Steps(-1) step 1 error = %v", err)
-	}
-	if err := m.Steps(-1); err != nil {
-		t.Fatalf("m.Steps(-1) step 2 error = %v", err)
+	if err := m.Down(); err != nil {
+		t.Fatalf("m.Down() error = %v", err)
 	}
 	m.Close()
 
@@ -371,8 +386,8 @@ func TestUpDownUpCycle_Postgres(t *testing.T) {
 	version, dirty, _ := m.Version()
 	m.Close()
 
-	if version != 2 {
-		t.Errorf("after up-down-up, version = %d, want 2", version)
+	if want := latestMigrationVersion(t, "postgres"); version != want {
+		t.Errorf("after up-down-up, version = %d, want %d", version, want)
 	}
 	if dirty {
 		t.Error("after up-down-up, dirty = true, want false")
@@ -401,7 +416,7 @@ func TestDataMigration_EmptyCategories_Postgres(t *testing.T) {
 	dsn := testPostgresDSN(t)
 	resetPostgresDB(t, dsn)
 
-	// Create DB at version 2 (full migration)
+	// Create DB with full migrations
 	if err := runMigrations("postgres", dsn); err != nil {
 		t.Fatalf("runMigrations error: %v", err)
 	}
@@ -417,13 +432,11 @@ func TestDataMigration_EmptyCategories_Postgres(t *testing.T) {
 		t.Fatalf("failed to insert app: %v", err)
 	}
 
-	// Force version back to 1 to re-run data migration
-	_, err = conn.Exec("UPDATE schema_migrations SET version = 1")
-	if err != nil {
-		t.Fatalf("failed to update version: %v", err)
-	}
 	conn.Close()
 
+	// Rewind to version 1 to re-run data migration
+	rewindMigrations(t, "postgres", dsn, 1)
+
 	// Re-run migrations (will run version 2 again)
 	if err := runMigrations("postgres", dsn); err != nil {
 		t.Fatalf("re-run migrations error: %v", err)
@@ -474,13 +487,11 @@ func TestDataMigration_DuplicateCategories_Postgres(t *testing.T) {
 		t.Fatalf("failed to insert category: %v", err)
 	}
 
-	// Force version back and re-run
-	_, err = conn.Exec("UPDATE schema_migrations SET version = 1")
-	if err != nil {
-		t.Fatalf("failed to update version: %v", err)
-	}
 	conn.Close()
 
+	// Rewind and re-run
+	rewindMigrations(t, "postgres", dsn, 1)
+
 	if err := runMigrations("postgres", dsn); err != nil {
 		t.Fatalf("re-run migrations error: %v", err)
 	}
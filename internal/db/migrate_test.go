@@ -2,7 +2,13 @@ package db
 
 import (
 	"database/sql"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
 	"testing"
 
 	_ "modernc.org/sqlite"
@@ -37,7 +43,25 @@ func TestRunMigrations_SQLite(t *testing.T) {
 		"users", "settings", "templates", "app_specs",
 		"oidc_states", "tenants", "categories",
 		"category_admins", "category_approved_users",
-		"recordings", "session_shares",
+		"recordings", "session_shares", "tenant_branding", "sidecar_templates",
+		"session_attestations", "quota_bursts", "session_archive", "session_history", "leader_leases", "idp_tokens",
+		"recording_chunks",
+		"session_file_events",
+		"api_tokens",
+		"user_mfa", "mfa_backup_codes",
+		"webauthn_credentials", "webauthn_challenges",
+		"password_reset_tokens",
+		"health_events",
+		"job_runs",
+		"session_launches",
+		"scheduled_sessions",
+		"api_usage",
+		"projects", "project_members",
+		"status_announcements",
+		"registry_credentials",
+		"lint_warnings",
+		"remote_catalogs",
+		"setting_change_requests",
 	}
 
 	for _, table := range tables {
@@ -65,8 +89,8 @@ func TestRunMigrations_SQLite(t *testing.T) {
 	if err != nil {
 		t.Fatalf("schema_migrations query error: %v", err)
 	}
-	if version != 2 {
-		t.Errorf("migration version = %d, want 2", version)
+	if want := latestMigrationVersion(t, "sqlite"); version != int(want) {
+		t.Errorf("migration version = %d, want %d", version, want)
 	}
 	if dirty {
 		t.Error("migration is dirty, want clean")
@@ -248,6 +272,36 @@ func TestOpenDB_SQLite(t *testing.T) {
 	}
 }
 
+func TestCheckMigrations_SQLite(t *testing.T) {
+	if testDBType() != "sqlite" {
+		t.Skip("SQLite-specific migration test")
+	}
+	path := filepath.Join(t.TempDir(), "check.db")
+
+	status, err := CheckMigrations("sqlite", path)
+	if err != nil {
+		t.Fatalf("CheckMigrations() error = %v", err)
+	}
+	if status.Current != 0 || status.Latest == 0 || status.Dirty {
+		t.Errorf("fresh database status = %+v, want nothing applied", status)
+	}
+	latest := status.Latest
+
+	database, err := OpenDB("sqlite", path)
+	if err != nil {
+		t.Fatalf("OpenDB() error = %v", err)
+	}
+	database.Close()
+
+	status, err = CheckMigrations("sqlite", path)
+	if err != nil {
+		t.Fatalf("CheckMigrations() error = %v", err)
+	}
+	if status.Current != latest || status.Latest != latest {
+		t.Errorf("migrated database status = %+v, want current %d", status, latest)
+	}
+}
+
 func TestOpenDB_UnsupportedType(t *testing.T) {
 	_, err := OpenDB("mysql", "test.db")
 	if err == nil {
@@ -403,23 +457,52 @@ func TestSchemaColumns_AllTables(t *testing.T) {
 	}
 	defer database.Close()
 
-	// Expected column counts per table (from baseline schema)
+	// Expected column counts per table (baseline schema plus later migrations)
 	expectedColumnCounts := map[string]int{
-		"applications":           18,
-		"audit_log":              6,
+		"applications":           44,
+		"audit_log":              7,
 		"analytics":              4,
-		"sessions":               10,
-		"users":                  12,
+		"sessions":               19,
+		"users":                  15,
 		"settings":               3,
-		"templates":              23,
-		"app_specs":              16,
+		"templates":              25,
+		"app_specs":              23,
 		"oidc_states":            3,
 		"tenants":                7,
-		"categories":             6,
+		"categories":             7,
 		"category_admins":        2,
 		"category_approved_users": 2,
-		"recordings":             14,
+		"recordings":             15,
+		"recording_chunks":       5,
+		"session_file_events":    9,
 		"session_shares":         7,
+		"refresh_tokens":         8,
+		"tenant_branding":        4,
+		"sidecar_templates":      6,
+		"session_attestations":   6,
+		"quota_bursts":           4,
+		"session_archive":        10,
+		"session_history":        19,
+		"leader_leases":          4,
+		"idp_tokens":             4,
+		"api_tokens":             9,
+		"user_mfa":               5,
+		"mfa_backup_codes":       4,
+		"webauthn_credentials":   7,
+		"webauthn_challenges":    3,
+		"password_reset_tokens":  4,
+		"health_events":          6,
+		"job_runs":               11,
+		"session_launches":       9,
+		"scheduled_sessions":     14,
+		"api_usage":              7,
+		"projects":               9,
+		"project_members":        4,
+		"status_announcements":   9,
+		"registry_credentials":   10,
+		"lint_warnings":          10,
+		"remote_catalogs":        12,
+		"setting_change_requests": 10,
 	}
 
 	for table, expected := range expectedColumnCounts {
@@ -435,6 +518,32 @@ func TestSchemaColumns_AllTables(t *testing.T) {
 	}
 }
 
+// TestModelsMatchSchema verifies that every model maps exactly the columns
+// its table has, so bun's queries neither miss a column nor select one that
+// doesn't exist.
+func TestModelsMatchSchema(t *testing.T) {
+	database := newTestDatabase(t)
+
+	for _, model := range dataModels {
+		table := database.bun.Table(reflect.TypeOf(model).Elem())
+		cols, err := database.loadColumns(table.Name)
+		if err != nil {
+			t.Errorf("loadColumns(%q) error = %v", table.Name, err)
+			continue
+		}
+		for i, name := range cols.names {
+			if cols.types[i] == nil {
+				t.Errorf("table %q column %q has no model field", table.Name, name)
+			}
+		}
+		for _, f := range table.Fields {
+			if !slices.Contains(cols.names, f.Name) {
+				t.Errorf("model for %q maps column %q, which the table lacks", table.Name, f.Name)
+			}
+		}
+	}
+}
+
 // TestSchemaIndexes verifies that all expected indexes are created.
 func TestSchemaIndexes(t *testing.T) {
 	if testDBType() != "sqlite" {
@@ -560,8 +669,8 @@ func TestNewMigrator_SQLite(t *testing.T) {
 	if err != nil {
 		t.Fatalf("m.Version() error = %v", err)
 	}
-	if version != 2 {
-		t.Errorf("version = %d, want 2", version)
+	if want := latestMigrationVersion(t, "sqlite"); version != want {
+		t.Errorf("version = %d, want %d", version, want)
 	}
 	if dirty {
 		t.Error("dirty = true, want false")
@@ -599,18 +708,18 @@ func TestDownMigration(t *testing.T) {
 	}
 	m.Close()
 
-	// Reopen and step down once (undoes 000002 data migration — no-op)
+	// Reopen and step down to the baseline (undoes all later migrations)
 	m, err = NewMigrator("sqlite", tmpFile.Name())
 	if err != nil {
 		t.Fatalf("NewMigrator() reopen error = %v", err)
 	}
 
-	if err := m.Steps(-1); err != nil {
-		t.Fatalf("m.Steps(-1) error = %v", err)
+	if err := m.Migrate(1); err != nil {
+		t.Fatalf("m.Migrate(1) error = %v", err)
 	}
 	version, _, _ := m.Version()
 	if version != 1 {
-		t.Errorf("after one step down, version = %d, want 1", version)
+		t.Errorf("after stepping down to baseline, version = %d, want 1", version)
 	}
 
 	// Step down again (undoes 000001 baseline — drops tables)
@@ -663,11 +772,8 @@ func TestUpDownUpCycle(t *testing.T) {
 	if err != nil {
 		t.Fatalf("NewMigrator() error = %v", err)
 	}
-	if err := m.Steps(-1); err != nil {
-		t.Fatalf("m.Steps(-1) step 1 error = %v", err)
-	}
-	if err := m.Steps(-1); err != nil {
-		t.Fatalf("m.Steps(-1) step 2 error = %v", err)
+	if err := m.Down(); err != nil {
+		t.Fatalf("m.Down() error = %v", err)
 	}
 	m.Close()
 
@@ -682,8 +788,8 @@ func TestUpDownUpCycle(t *testing.T) {
 	version, dirty, _ := m.Version()
 	m.Close()
 
-	if version != 2 {
-		t.Errorf("after up-down-up, version = %d, want 2", version)
+	if want := latestMigrationVersion(t, "sqlite"); version != want {
+		t.Errorf("after up-down-up, version = %d, want %d", version, want)
 	}
 	if dirty {
 		t.Error("after up-down-up, dirty = true, want false")
@@ -849,8 +955,8 @@ func TestEndToEndUpgrade_OldDBWithData(t *testing.T) {
 	if err != nil {
 		t.Fatalf("schema_migrations query error: %v", err)
 	}
-	if version != 2 {
-		t.Errorf("version = %d, want 2 (both migrations applied)", version)
+	if want := latestMigrationVersion(t, "sqlite"); version != int(want) {
+		t.Errorf("version = %d, want %d (all migrations applied)", version, want)
 	}
 	if dirty {
 		t.Error("dirty = true, want false")
@@ -892,10 +998,11 @@ func TestDataMigration_EmptyCategories(t *testing.T) {
 	// Insert app with empty category
 	conn.Exec("INSERT INTO applications (id, name, description, url, icon, category) VALUES ('empty-1', 'NoCategory', 'test', 'http://x', 'i', '')")
 
-	// Force version back to 1 to re-run data migration
-	conn.Exec("UPDATE schema_migrations SET version = 1")
 	conn.Close()
 
+	// Rewind to version 1 to re-run data migration
+	rewindMigrations(t, "sqlite", tmpFile.Name(), 1)
+
 	// Re-run migrations (will run version 2 again)
 	if err := runMigrations("sqlite", tmpFile.Name()); err != nil {
 		t.Fatalf("re-run migrations error: %v", err)
@@ -946,10 +1053,11 @@ func TestDataMigration_DuplicateCategories(t *testing.T) {
 	// Manually create the category first (simulating it already existing)
 	conn.Exec("INSERT INTO categories (id, name, description, tenant_id) VALUES ('existing-cat', 'Tools', 'existing', 'default')")
 
-	// Force version back and re-run
-	conn.Exec("UPDATE schema_migrations SET version = 1")
 	conn.Close()
 
+	// Rewind and re-run
+	rewindMigrations(t, "sqlite", tmpFile.Name(), 1)
+
 	if err := runMigrations("sqlite", tmpFile.Name()); err != nil {
 		t.Fatalf("re-run migrations error: %v", err)
 	}
@@ -993,6 +1101,7 @@ func TestSchemaColumns_Recordings(t *testing.T) {
 		"id", "session_id", "user_id", "filename", "size_bytes",
 		"duration_seconds", "format", "storage_backend", "storage_path",
 		"status", "tenant_id", "created_at", "completed_at", "video_path",
+		"checkpoint_at",
 	}
 
 	rows, err := database.bun.DB.Query("SELECT name FROM pragma_table_info('recordings')")
@@ -1060,3 +1169,60 @@ func TestSchemaColumns_Users(t *testing.T) {
 		}
 	}
 }
+
+// migrationObjectPattern matches the tables, columns, and indexes a
+// migration creates or drops.
+var migrationObjectPattern = regexp.MustCompile(`(?i)\b(CREATE TABLE|DROP TABLE|ADD COLUMN|DROP COLUMN|CREATE (?:UNIQUE )?INDEX|DROP INDEX)\s+(?:IF (?:NOT )?EXISTS\s+)?"?(\w+)`)
+
+// TestMigrationsMatchAcrossDialects checks every migration exists for both
+// backends and touches the same tables, columns, and indexes, so the
+// Postgres schema cannot drift from SQLite in builds that only test SQLite.
+func TestMigrationsMatchAcrossDialects(t *testing.T) {
+	objects := func(fsys fs.FS, dir string) map[string][]string {
+		t.Helper()
+		entries, err := fs.ReadDir(fsys, dir)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", dir, err)
+		}
+		files := make(map[string][]string, len(entries))
+		for _, e := range entries {
+			data, err := fs.ReadFile(fsys, dir+"/"+e.Name())
+			if err != nil {
+				t.Fatalf("failed to read %s: %v", e.Name(), err)
+			}
+			var objs []string
+			for _, m := range migrationObjectPattern.FindAllStringSubmatch(string(data), -1) {
+				objs = append(objs, strings.ToUpper(m[1])+" "+strings.ToLower(m[2]))
+			}
+			slices.Sort(objs)
+			files[e.Name()] = objs
+		}
+		return files
+	}
+
+	sqliteFiles := objects(sqliteMigrations, "migrations/sqlite")
+	postgresFiles := objects(postgresMigrations, "migrations/postgres")
+
+	for name, want := range sqliteFiles {
+		got, ok := postgresFiles[name]
+		if !ok {
+			t.Errorf("migration %s has no Postgres version", name)
+			continue
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("migration %s differs between dialects:\n  sqlite:   %v\n  postgres: %v", name, want, got)
+		}
+	}
+	for name := range postgresFiles {
+		if _, ok := sqliteFiles[name]; !ok {
+			t.Errorf("migration %s has no SQLite version", name)
+		}
+	}
+	for name := range sqliteFiles {
+		if base, ok := strings.CutSuffix(name, ".up.sql"); ok {
+			if _, ok := sqliteFiles[base+".down.sql"]; !ok {
+				t.Errorf("migration %s has no down migration", name)
+			}
+		}
+	}
+}
@@ -0,0 +1,4 @@
+-- Track the last successful login and whether the account has been disabled
+-- (e.g. by the inactive-account policy).
+ALTER TABLE users ADD COLUMN last_login_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT FALSE;
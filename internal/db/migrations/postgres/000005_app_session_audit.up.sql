@@ -0,0 +1,3 @@
+-- Per-app toggle for auditing in-session actions (clipboard, file transfer,
+-- printing, disconnects).
+ALTER TABLE applications ADD COLUMN audit_session_activity BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,2 @@
+ALTER TABLE sessions DROP COLUMN IF EXISTS welcome_dismissed_at;
+ALTER TABLE applications DROP COLUMN IF EXISTS welcome_message;
@@ -0,0 +1,4 @@
+-- Per-app welcome message shown when a session first connects, and when the
+-- session owner dismissed it.
+ALTER TABLE applications ADD COLUMN welcome_message TEXT NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN welcome_dismissed_at TIMESTAMPTZ;
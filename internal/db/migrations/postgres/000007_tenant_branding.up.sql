@@ -0,0 +1,8 @@
+-- Per-tenant branding uploaded by admins: theme colors and fonts, and the
+-- storage locations of uploaded logo, favicon and background images.
+CREATE TABLE tenant_branding (
+    tenant_id TEXT PRIMARY KEY,
+    theme TEXT NOT NULL DEFAULT '{}',
+    assets TEXT NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
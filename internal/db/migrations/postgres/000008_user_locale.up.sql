@@ -0,0 +1,3 @@
+-- Preferred language for server-generated text. Empty means negotiate from
+-- the browser's Accept-Language header.
+ALTER TABLE users ADD COLUMN locale TEXT NOT NULL DEFAULT '';
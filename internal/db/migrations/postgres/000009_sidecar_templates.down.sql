@@ -0,0 +1,3 @@
+ALTER TABLE applications DROP COLUMN IF EXISTS sidecars;
+
+DROP TABLE IF EXISTS sidecar_templates;
@@ -0,0 +1,14 @@
+-- Per-app toggle for signed session environment reports.
+ALTER TABLE applications ADD COLUMN attest_sessions BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Signed reports of the environment each session run used, generated when
+-- the session ends. Rows are kept after the session itself is removed.
+CREATE TABLE session_attestations (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    tenant_id TEXT NOT NULL DEFAULT '',
+    key_id TEXT NOT NULL DEFAULT '',
+    envelope TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX idx_session_attestations_session ON session_attestations(session_id);
@@ -0,0 +1,2 @@
+-- Per-app launch windows and blackout periods, stored as JSON.
+ALTER TABLE applications ADD COLUMN schedule TEXT NOT NULL DEFAULT '';
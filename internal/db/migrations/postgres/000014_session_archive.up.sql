@@ -0,0 +1,16 @@
+-- Ended sessions moved out of the sessions table once they are older than
+-- the session retention period.
+CREATE TABLE session_archive (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    app_id TEXT NOT NULL,
+    pod_name TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    idle_timeout INTEGER DEFAULT 0,
+    tenant_id TEXT DEFAULT 'default',
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    archived_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX idx_session_archive_user ON session_archive(user_id);
+CREATE INDEX idx_session_archive_archived_at ON session_archive(archived_at);
@@ -0,0 +1,23 @@
+-- Summary of every session run, kept indefinitely for reporting and
+-- independent of the sessions table.
+CREATE TABLE session_history (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    tenant_id TEXT DEFAULT 'default',
+    user_id TEXT NOT NULL,
+    username TEXT NOT NULL DEFAULT '',
+    app_id TEXT NOT NULL,
+    app_name TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMPTZ NOT NULL,
+    ended_at TIMESTAMPTZ NOT NULL,
+    duration_seconds BIGINT NOT NULL DEFAULT 0,
+    cpu_request TEXT NOT NULL DEFAULT '',
+    cpu_limit TEXT NOT NULL DEFAULT '',
+    memory_request TEXT NOT NULL DEFAULT '',
+    memory_limit TEXT NOT NULL DEFAULT '',
+    final_status TEXT NOT NULL,
+    exit_reason TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX idx_session_history_ended_at ON session_history(ended_at);
+CREATE INDEX idx_session_history_user ON session_history(user_id);
+CREATE INDEX idx_session_history_app ON session_history(app_id);
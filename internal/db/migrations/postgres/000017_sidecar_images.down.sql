@@ -0,0 +1,2 @@
+ALTER TABLE sessions DROP COLUMN IF EXISTS sidecar_image;
+ALTER TABLE applications DROP COLUMN IF EXISTS sidecar_images;
@@ -0,0 +1,4 @@
+-- Per-app overrides of the built-in sidecar images, stored as JSON, and the
+-- display sidecar image each session ran.
+ALTER TABLE applications ADD COLUMN sidecar_images TEXT NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN sidecar_image TEXT NOT NULL DEFAULT '';
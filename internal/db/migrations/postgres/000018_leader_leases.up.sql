@@ -0,0 +1,8 @@
+-- Named leases held by the replica that runs a maintenance loop. A lease
+-- can be taken over once it expires without being renewed.
+CREATE TABLE leader_leases (
+    name TEXT PRIMARY KEY,
+    holder TEXT NOT NULL,
+    acquired_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
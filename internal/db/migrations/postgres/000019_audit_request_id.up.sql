@@ -0,0 +1,3 @@
+-- The X-Request-ID of the API request that produced an audit entry, so
+-- entries can be matched with error responses and server logs.
+ALTER TABLE audit_log ADD COLUMN request_id TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- Per-app RDP connection parameters for Windows apps, stored as JSON.
+ALTER TABLE applications ADD COLUMN rdp_settings TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE recordings DROP COLUMN IF EXISTS checkpoint_at;
+DROP TABLE IF EXISTS recording_chunks;
@@ -0,0 +1,13 @@
+-- Chunks of in-progress recordings, uploaded during the session so that a
+-- recording can be finalized from what arrived if the client goes away.
+CREATE TABLE recording_chunks (
+    recording_id TEXT NOT NULL,
+    seq INTEGER NOT NULL,
+    storage_path TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (recording_id, seq)
+);
+
+-- When the last chunk of a recording arrived.
+ALTER TABLE recordings ADD COLUMN checkpoint_at TIMESTAMPTZ;
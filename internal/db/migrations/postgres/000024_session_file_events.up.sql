@@ -0,0 +1,16 @@
+-- File transfers in session workspaces through the files API, kept per
+-- session for the file history endpoint. Each event is also written to
+-- audit_log.
+CREATE TABLE session_file_events (
+    id BIGSERIAL PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    username TEXT NOT NULL,
+    direction TEXT NOT NULL,
+    path TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL DEFAULT 0,
+    sha256 TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_session_file_events_session ON session_file_events(session_id, created_at);
@@ -0,0 +1,3 @@
+ALTER TABLE applications DROP COLUMN IF EXISTS recording_policy;
+ALTER TABLE applications DROP COLUMN IF EXISTS idle_timeout;
+ALTER TABLE categories DROP COLUMN IF EXISTS defaults;
@@ -0,0 +1,5 @@
+-- Session defaults inherited by a category's apps, stored as JSON, and the
+-- per-app settings they fill in for.
+ALTER TABLE categories ADD COLUMN defaults TEXT NOT NULL DEFAULT '';
+ALTER TABLE applications ADD COLUMN idle_timeout BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE applications ADD COLUMN recording_policy TEXT NOT NULL DEFAULT '';
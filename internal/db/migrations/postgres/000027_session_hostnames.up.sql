@@ -0,0 +1,5 @@
+-- The subdomain a web_proxy session is served at when session hostnames
+-- are enabled. A session keeps it while stopped, so its URL survives a
+-- restart.
+ALTER TABLE sessions ADD COLUMN hostname TEXT NOT NULL DEFAULT '';
+CREATE INDEX idx_sessions_hostname ON sessions(hostname);
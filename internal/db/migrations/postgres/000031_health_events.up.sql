@@ -0,0 +1,12 @@
+-- Health state transitions of server components, recorded by the health
+-- watcher so operators can see when and why readiness changed.
+CREATE TABLE health_events (
+    id BIGSERIAL PRIMARY KEY,
+    component TEXT NOT NULL,
+    from_state TEXT NOT NULL,
+    to_state TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX idx_health_events_component ON health_events(component, created_at);
+CREATE INDEX idx_health_events_created ON health_events(created_at);
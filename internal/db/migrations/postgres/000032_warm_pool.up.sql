@@ -0,0 +1,2 @@
+-- Number of idle pods kept ready for each app's sessions.
+ALTER TABLE applications ADD COLUMN warm_pool_size INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,17 @@
+-- Runs of background jobs, scheduled or triggered by an admin. Queued runs
+-- are picked up by the leader replica.
+CREATE TABLE job_runs (
+    id BIGSERIAL PRIMARY KEY,
+    job TEXT NOT NULL,
+    trigger_type TEXT NOT NULL,
+    requested_by TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    replica TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ
+);
+CREATE INDEX idx_job_runs_job ON job_runs(job, created_at);
+CREATE INDEX idx_job_runs_status ON job_runs(status);
@@ -0,0 +1,3 @@
+-- Per-user persistent volume mounted in an app's sessions, stored as JSON.
+ALTER TABLE applications ADD COLUMN home_volume TEXT NOT NULL DEFAULT '';
+ALTER TABLE app_specs ADD COLUMN home_volume TEXT NOT NULL DEFAULT '';
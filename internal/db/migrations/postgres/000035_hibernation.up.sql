@@ -0,0 +1,2 @@
+-- Apps whose idle sessions are hibernated instead of expired.
+ALTER TABLE applications ADD COLUMN hibernate_on_idle BOOLEAN NOT NULL DEFAULT FALSE;
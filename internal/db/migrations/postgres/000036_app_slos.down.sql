@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS session_launches;
+ALTER TABLE applications DROP COLUMN IF EXISTS slo;
@@ -0,0 +1,2 @@
+-- Which way the app's sessions may copy and paste, empty for both ways.
+ALTER TABLE applications ADD COLUMN clipboard_policy TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- Which way the app's sessions may transfer files, empty for both ways.
+ALTER TABLE applications ADD COLUMN file_transfer TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- How often the containers of the session's current workload have restarted.
+ALTER TABLE sessions ADD COLUMN restart_count INTEGER NOT NULL DEFAULT 0;
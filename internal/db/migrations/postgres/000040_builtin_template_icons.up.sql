@@ -0,0 +1,20 @@
+-- Point the seeded templates that still use their upstream favicons at the
+-- built-in icons served by Sortie.
+UPDATE templates SET icon = '/api/assets/icons/builtin-vscode-server.svg' WHERE template_id = 'vscode-server' AND icon = 'https://code.visualstudio.com/favicon.ico';
+UPDATE templates SET icon = '/api/assets/icons/builtin-gitlab-ce.svg' WHERE template_id = 'gitlab-ce' AND icon = 'https://about.gitlab.com/ico/favicon.ico';
+UPDATE templates SET icon = '/api/assets/icons/builtin-jenkins.svg' WHERE template_id = 'jenkins' AND icon = 'https://www.jenkins.io/favicon.ico';
+UPDATE templates SET icon = '/api/assets/icons/builtin-gitea.svg' WHERE template_id = 'gitea' AND icon = 'https://gitea.io/images/gitea.png';
+UPDATE templates SET icon = '/api/assets/icons/builtin-jupyterlab.svg' WHERE template_id = 'jupyterlab' AND icon = 'https://jupyter.org/favicon.ico';
+UPDATE templates SET icon = '/api/assets/icons/builtin-libreoffice.svg' WHERE template_id = 'libreoffice' AND icon = 'https://www.libreoffice.org/themes/libreofficenew/favicon.ico';
+UPDATE templates SET icon = '/api/assets/icons/builtin-nextcloud.svg' WHERE template_id = 'nextcloud' AND icon = 'https://nextcloud.com/wp-content/uploads/2022/03/favicon.ico';
+UPDATE templates SET icon = '/api/assets/icons/builtin-onlyoffice.svg' WHERE template_id = 'onlyoffice' AND icon = 'https://www.onlyoffice.com/favicon.ico';
+UPDATE templates SET icon = '/api/assets/icons/builtin-mattermost.svg' WHERE template_id = 'mattermost' AND icon = 'https://mattermost.com/wp-content/uploads/2022/02/favicon.ico';
+UPDATE templates SET icon = '/api/assets/icons/builtin-rocketchat.svg' WHERE template_id = 'rocketchat' AND icon = 'https://rocket.chat/favicon.ico';
+UPDATE templates SET icon = '/api/assets/icons/builtin-firefox.svg' WHERE template_id = 'firefox' AND icon = 'https://www.mozilla.org/media/img/favicons/firefox/browser/favicon.ico';
+UPDATE templates SET icon = '/api/assets/icons/builtin-chromium.svg' WHERE template_id = 'chromium' AND icon = 'https://www.chromium.org/favicon.ico';
+UPDATE templates SET icon = '/api/assets/icons/builtin-grafana.svg' WHERE template_id = 'grafana' AND icon = 'https://grafana.com/static/assets/img/fav32.png';
+UPDATE templates SET icon = '/api/assets/icons/builtin-prometheus.svg' WHERE template_id = 'prometheus' AND icon = 'https://prometheus.io/assets/favicons/favicon.ico';
+UPDATE templates SET icon = '/api/assets/icons/builtin-uptime-kuma.svg' WHERE template_id = 'uptime-kuma' AND icon = 'https://uptime.kuma.pet/img/icon.svg';
+UPDATE templates SET icon = '/api/assets/icons/builtin-pgadmin.svg' WHERE template_id = 'pgadmin' AND icon = 'https://www.pgadmin.org/static/COMPILED/assets/img/favicon.ico';
+UPDATE templates SET icon = '/api/assets/icons/builtin-adminer.svg' WHERE template_id = 'adminer' AND icon = 'https://www.adminer.org/static/favicon.ico';
+UPDATE templates SET icon = '/api/assets/icons/builtin-gimp.svg' WHERE template_id = 'gimp' AND icon = 'https://www.gimp.org/images/frontpage/wilber-big.png';
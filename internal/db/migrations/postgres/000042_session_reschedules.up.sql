@@ -0,0 +1,3 @@
+-- How often the session has been moved to a new workload after its
+-- workload was evicted or deleted out from under it.
+ALTER TABLE sessions ADD COLUMN reschedule_count INTEGER NOT NULL DEFAULT 0;
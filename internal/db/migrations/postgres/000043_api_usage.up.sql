@@ -0,0 +1,13 @@
+-- API requests counted per principal (user, and API token if one was used),
+-- endpoint and hour. Each replica adds its counts to the same rows.
+CREATE TABLE api_usage (
+    bucket_start TIMESTAMPTZ NOT NULL,
+    user_id TEXT NOT NULL,
+    token_id TEXT NOT NULL DEFAULT '',
+    method TEXT NOT NULL,
+    endpoint TEXT NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    errors BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (bucket_start, user_id, token_id, method, endpoint)
+);
+CREATE INDEX idx_api_usage_user ON api_usage(user_id, bucket_start);
@@ -0,0 +1,2 @@
+ALTER TABLE session_history DROP COLUMN IF EXISTS memory_gib_seconds;
+ALTER TABLE session_history DROP COLUMN IF EXISTS cpu_core_seconds;
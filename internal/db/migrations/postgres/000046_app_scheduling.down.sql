@@ -0,0 +1,2 @@
+ALTER TABLE app_specs DROP COLUMN IF EXISTS scheduling;
+ALTER TABLE applications DROP COLUMN IF EXISTS scheduling;
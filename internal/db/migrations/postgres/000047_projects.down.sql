@@ -0,0 +1,5 @@
+ALTER TABLE session_history DROP COLUMN IF EXISTS project_id;
+DROP INDEX IF EXISTS idx_sessions_project;
+ALTER TABLE sessions DROP COLUMN IF EXISTS project_id;
+DROP TABLE IF EXISTS project_members;
+DROP TABLE IF EXISTS projects;
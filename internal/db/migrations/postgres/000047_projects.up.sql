@@ -0,0 +1,28 @@
+-- Projects group related sessions, their shares and a drive mounted in
+-- each of the project's sessions. Members have a role in the project.
+CREATE TABLE projects (
+    id TEXT PRIMARY KEY,
+    tenant_id TEXT NOT NULL DEFAULT 'default',
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    max_sessions INTEGER NOT NULL DEFAULT 0,
+    drive TEXT NOT NULL DEFAULT '',
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX idx_projects_tenant ON projects(tenant_id);
+
+CREATE TABLE project_members (
+    project_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    role TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (project_id, user_id)
+);
+CREATE INDEX idx_project_members_user ON project_members(user_id);
+
+-- The project a session was launched in, if any.
+ALTER TABLE sessions ADD COLUMN project_id TEXT NOT NULL DEFAULT '';
+CREATE INDEX idx_sessions_project ON sessions(project_id);
+ALTER TABLE session_history ADD COLUMN project_id TEXT NOT NULL DEFAULT '';
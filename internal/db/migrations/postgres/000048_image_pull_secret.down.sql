@@ -0,0 +1,2 @@
+ALTER TABLE app_specs DROP COLUMN IF EXISTS image_pull_secret;
+ALTER TABLE applications DROP COLUMN IF EXISTS image_pull_secret;
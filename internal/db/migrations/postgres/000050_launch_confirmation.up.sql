@@ -0,0 +1,3 @@
+-- Launch confirmation for expensive apps: whether users must confirm a
+-- launch preview, and the message and prerequisites shown with it.
+ALTER TABLE applications ADD COLUMN launch_confirmation TEXT NOT NULL DEFAULT '';
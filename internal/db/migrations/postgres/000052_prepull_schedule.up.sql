@@ -0,0 +1,3 @@
+-- Cron schedule on which the app's images are pulled onto nodes ahead of
+-- launches, in the timezone of the app's schedule.
+ALTER TABLE applications ADD COLUMN prepull_schedule TEXT NOT NULL DEFAULT '';
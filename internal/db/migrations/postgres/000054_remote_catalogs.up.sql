@@ -0,0 +1,23 @@
+-- Remote template catalogs that the marketplace syncs templates from.
+-- Each catalog is a signed JSON document; etag and the sync_ columns
+-- record the outcome of the last sync.
+CREATE TABLE remote_catalogs (
+    name TEXT PRIMARY KEY,
+    url TEXT NOT NULL,
+    public_key TEXT NOT NULL,
+    on_conflict TEXT NOT NULL DEFAULT 'keep_local',
+    etag TEXT NOT NULL DEFAULT '',
+    sync_status TEXT NOT NULL DEFAULT '',
+    sync_error TEXT NOT NULL DEFAULT '',
+    sync_result TEXT NOT NULL DEFAULT '',
+    synced_at TIMESTAMPTZ,
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- The remote catalog a template was synced from, or empty for templates
+-- created locally, and the hash of its content as last synced, which
+-- shows whether it has been edited locally since.
+ALTER TABLE templates ADD COLUMN catalog TEXT NOT NULL DEFAULT '';
+ALTER TABLE templates ADD COLUMN catalog_hash TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE sessions DROP COLUMN IF EXISTS locked_at;
+ALTER TABLE applications DROP COLUMN IF EXISTS lock_timeout;
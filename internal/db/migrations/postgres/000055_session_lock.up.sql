@@ -0,0 +1,4 @@
+-- Per-app timeout after which an idle session's stream is locked until the
+-- owner signs in again, and when the session was locked.
+ALTER TABLE applications ADD COLUMN lock_timeout BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE sessions ADD COLUMN locked_at TIMESTAMPTZ;
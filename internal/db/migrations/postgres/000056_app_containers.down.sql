@@ -0,0 +1,4 @@
+ALTER TABLE app_specs DROP COLUMN IF EXISTS shared_volumes;
+ALTER TABLE app_specs DROP COLUMN IF EXISTS containers;
+ALTER TABLE applications DROP COLUMN IF EXISTS shared_volumes;
+ALTER TABLE applications DROP COLUMN IF EXISTS containers;
@@ -0,0 +1,6 @@
+-- Extra containers an app runs next to its app container, and the shared
+-- volumes the app container mounts, stored as JSON.
+ALTER TABLE applications ADD COLUMN containers TEXT NOT NULL DEFAULT '';
+ALTER TABLE applications ADD COLUMN shared_volumes TEXT NOT NULL DEFAULT '';
+ALTER TABLE app_specs ADD COLUMN containers TEXT NOT NULL DEFAULT '';
+ALTER TABLE app_specs ADD COLUMN shared_volumes TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE app_specs DROP COLUMN IF EXISTS init_containers;
+ALTER TABLE applications DROP COLUMN IF EXISTS init_containers;
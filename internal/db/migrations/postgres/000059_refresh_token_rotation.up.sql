@@ -0,0 +1,4 @@
+-- A rotated refresh token points at the token that replaced it, and is kept
+-- for a short grace period so a client that missed the rotation's response
+-- can still refresh.
+ALTER TABLE refresh_tokens ADD COLUMN replaced_by TEXT NOT NULL DEFAULT '';
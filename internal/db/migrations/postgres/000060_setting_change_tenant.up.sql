@@ -0,0 +1,3 @@
+-- A change request can also stage a tenant's quotas and egress policy,
+-- stored as JSON; empty for settings-only requests.
+ALTER TABLE setting_change_requests ADD COLUMN tenant_change TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+-- Track the last successful login and whether the account has been disabled
+-- (e.g. by the inactive-account policy).
+ALTER TABLE users ADD COLUMN last_login_at DATETIME;
+ALTER TABLE users ADD COLUMN disabled INTEGER NOT NULL DEFAULT 0;
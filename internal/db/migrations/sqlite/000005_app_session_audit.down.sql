@@ -0,0 +1 @@
+ALTER TABLE applications DROP COLUMN audit_session_activity;
@@ -0,0 +1,2 @@
+ALTER TABLE sessions DROP COLUMN welcome_dismissed_at;
+ALTER TABLE applications DROP COLUMN welcome_message;
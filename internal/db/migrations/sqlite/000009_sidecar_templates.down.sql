@@ -0,0 +1,3 @@
+ALTER TABLE applications DROP COLUMN sidecars;
+
+DROP TABLE IF EXISTS sidecar_templates;
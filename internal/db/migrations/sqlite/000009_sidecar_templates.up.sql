@@ -0,0 +1,12 @@
+-- Admin-defined sidecar containers for session pods, attached to apps by
+-- name through applications.sidecars and to tenants through their settings.
+CREATE TABLE sidecar_templates (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    tenant_id TEXT NOT NULL DEFAULT '',
+    spec TEXT NOT NULL DEFAULT '{}',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE applications ADD COLUMN sidecars TEXT NOT NULL DEFAULT '[]';
@@ -0,0 +1,2 @@
+ALTER TABLE app_specs DROP COLUMN dns;
+ALTER TABLE applications DROP COLUMN dns;
@@ -0,0 +1,4 @@
+-- Per-app hostname, DNS policy, resolver config and host aliases rendered
+-- into session pods, stored as JSON.
+ALTER TABLE applications ADD COLUMN dns TEXT NOT NULL DEFAULT '';
+ALTER TABLE app_specs ADD COLUMN dns TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS session_attestations;
+
+ALTER TABLE applications DROP COLUMN attest_sessions;
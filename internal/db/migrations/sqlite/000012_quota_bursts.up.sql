@@ -0,0 +1,8 @@
+-- Per-user burst debt for soft session limits: how much burst time a user
+-- has used, and whether they were above the soft limit when last settled.
+CREATE TABLE quota_bursts (
+    user_id TEXT PRIMARY KEY,
+    debt_seconds INTEGER NOT NULL DEFAULT 0,
+    bursting INTEGER NOT NULL DEFAULT 0,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,2 @@
+ALTER TABLE sessions DROP COLUMN substatus_detail;
+ALTER TABLE sessions DROP COLUMN substatus;
@@ -0,0 +1,4 @@
+-- What a creating session is waiting on (scheduling, pulling an image,
+-- starting sidecars...), with detail from the runner.
+ALTER TABLE sessions ADD COLUMN substatus TEXT NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN substatus_detail TEXT NOT NULL DEFAULT '';
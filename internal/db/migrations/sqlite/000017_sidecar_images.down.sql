@@ -0,0 +1,2 @@
+ALTER TABLE sessions DROP COLUMN sidecar_image;
+ALTER TABLE applications DROP COLUMN sidecar_images;
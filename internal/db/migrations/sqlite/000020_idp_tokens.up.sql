@@ -0,0 +1,8 @@
+-- The refresh token an identity provider issued at a user's last SSO login,
+-- used to re-read their groups without waiting for them to sign in again.
+CREATE TABLE idp_tokens (
+    user_id TEXT PRIMARY KEY,
+    provider TEXT NOT NULL,
+    refresh_token TEXT NOT NULL,
+    updated_at DATETIME NOT NULL
+);
@@ -0,0 +1,2 @@
+-- References to the secrets holding each app's RDP login, stored as JSON.
+ALTER TABLE applications ADD COLUMN credentials TEXT NOT NULL DEFAULT '';
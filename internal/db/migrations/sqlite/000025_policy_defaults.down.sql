@@ -0,0 +1,3 @@
+ALTER TABLE applications DROP COLUMN recording_policy;
+ALTER TABLE applications DROP COLUMN idle_timeout;
+ALTER TABLE categories DROP COLUMN defaults;
@@ -0,0 +1,16 @@
+-- Personal access tokens for scripts and CI. Only a SHA-256 hash of each
+-- token is kept; prefix is its first characters, shown so users can tell
+-- their tokens apart.
+CREATE TABLE api_tokens (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    scopes TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME,
+    expires_at DATETIME NOT NULL
+);
+CREATE UNIQUE INDEX idx_api_tokens_hash ON api_tokens(token_hash);
+CREATE INDEX idx_api_tokens_user ON api_tokens(user_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_sessions_hostname;
+ALTER TABLE sessions DROP COLUMN hostname;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS mfa_backup_codes;
+DROP TABLE IF EXISTS user_mfa;
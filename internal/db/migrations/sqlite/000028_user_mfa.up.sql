@@ -0,0 +1,20 @@
+-- TOTP enrollment for local accounts. An enrollment is pending until the
+-- user verifies a code, which sets enabled_at. last_step is the last
+-- accepted TOTP time step, so a code cannot be used twice.
+CREATE TABLE user_mfa (
+    user_id TEXT PRIMARY KEY,
+    secret TEXT NOT NULL,
+    last_step INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    enabled_at DATETIME
+);
+
+-- One-time backup codes for users who lose their authenticator. Only a
+-- SHA-256 hash of each code is kept.
+CREATE TABLE mfa_backup_codes (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    code_hash TEXT NOT NULL,
+    used_at DATETIME
+);
+CREATE INDEX idx_mfa_backup_codes_user ON mfa_backup_codes(user_id);
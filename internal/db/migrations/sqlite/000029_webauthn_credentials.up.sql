@@ -0,0 +1,21 @@
+-- Passkeys (WebAuthn credentials) users can sign in with in place of a
+-- password. id is the base64url credential ID the authenticator chose;
+-- public_key is its COSE-encoded public key, base64url-encoded.
+CREATE TABLE webauthn_credentials (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    public_key TEXT NOT NULL,
+    sign_count INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME
+);
+CREATE INDEX idx_webauthn_credentials_user ON webauthn_credentials(user_id);
+
+-- Outstanding WebAuthn challenges. Each is consumed by the ceremony that
+-- issued it; user_id is empty for sign-in challenges.
+CREATE TABLE webauthn_challenges (
+    challenge TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL DEFAULT '',
+    expires_at DATETIME NOT NULL
+);
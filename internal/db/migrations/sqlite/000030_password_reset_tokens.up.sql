@@ -0,0 +1,9 @@
+-- One-time password reset tokens sent by email. Only an HMAC of each
+-- token, keyed with the JWT secret, is kept.
+CREATE TABLE password_reset_tokens (
+    token_hash TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NOT NULL
+);
+CREATE INDEX idx_password_reset_tokens_user ON password_reset_tokens(user_id);
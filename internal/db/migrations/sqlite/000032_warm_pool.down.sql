@@ -0,0 +1 @@
+ALTER TABLE applications DROP COLUMN warm_pool_size;
@@ -0,0 +1,2 @@
+ALTER TABLE app_specs DROP COLUMN home_volume;
+ALTER TABLE applications DROP COLUMN home_volume;
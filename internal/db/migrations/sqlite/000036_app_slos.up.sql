@@ -0,0 +1,19 @@
+-- Per-app availability objectives, stored as JSON.
+ALTER TABLE applications ADD COLUMN slo TEXT NOT NULL DEFAULT '';
+
+-- Outcome of every session launch, recorded when the workload becomes
+-- ready or fails, so launch success rate and start latency can be
+-- measured against the objectives.
+CREATE TABLE session_launches (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id TEXT NOT NULL,
+    tenant_id TEXT DEFAULT 'default',
+    app_id TEXT NOT NULL,
+    requested_at DATETIME NOT NULL,
+    finished_at DATETIME NOT NULL,
+    start_seconds REAL NOT NULL DEFAULT 0,
+    succeeded BOOLEAN NOT NULL,
+    reason TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX idx_session_launches_app ON session_launches(app_id, finished_at);
+CREATE INDEX idx_session_launches_finished ON session_launches(finished_at);
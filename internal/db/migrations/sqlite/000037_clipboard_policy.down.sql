@@ -0,0 +1 @@
+ALTER TABLE applications DROP COLUMN clipboard_policy;
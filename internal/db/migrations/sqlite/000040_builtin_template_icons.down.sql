@@ -0,0 +1,18 @@
+UPDATE templates SET icon = 'https://code.visualstudio.com/favicon.ico' WHERE template_id = 'vscode-server' AND icon = '/api/assets/icons/builtin-vscode-server.svg';
+UPDATE templates SET icon = 'https://about.gitlab.com/ico/favicon.ico' WHERE template_id = 'gitlab-ce' AND icon = '/api/assets/icons/builtin-gitlab-ce.svg';
+UPDATE templates SET icon = 'https://www.jenkins.io/favicon.ico' WHERE template_id = 'jenkins' AND icon = '/api/assets/icons/builtin-jenkins.svg';
+UPDATE templates SET icon = 'https://gitea.io/images/gitea.png' WHERE template_id = 'gitea' AND icon = '/api/assets/icons/builtin-gitea.svg';
+UPDATE templates SET icon = 'https://jupyter.org/favicon.ico' WHERE template_id = 'jupyterlab' AND icon = '/api/assets/icons/builtin-jupyterlab.svg';
+UPDATE templates SET icon = 'https://www.libreoffice.org/themes/libreofficenew/favicon.ico' WHERE template_id = 'libreoffice' AND icon = '/api/assets/icons/builtin-libreoffice.svg';
+UPDATE templates SET icon = 'https://nextcloud.com/wp-content/uploads/2022/03/favicon.ico' WHERE template_id = 'nextcloud' AND icon = '/api/assets/icons/builtin-nextcloud.svg';
+UPDATE templates SET icon = 'https://www.onlyoffice.com/favicon.ico' WHERE template_id = 'onlyoffice' AND icon = '/api/assets/icons/builtin-onlyoffice.svg';
+UPDATE templates SET icon = 'https://mattermost.com/wp-content/uploads/2022/02/favicon.ico' WHERE template_id = 'mattermost' AND icon = '/api/assets/icons/builtin-mattermost.svg';
+UPDATE templates SET icon = 'https://rocket.chat/favicon.ico' WHERE template_id = 'rocketchat' AND icon = '/api/assets/icons/builtin-rocketchat.svg';
+UPDATE templates SET icon = 'https://www.mozilla.org/media/img/favicons/firefox/browser/favicon.ico' WHERE template_id = 'firefox' AND icon = '/api/assets/icons/builtin-firefox.svg';
+UPDATE templates SET icon = 'https://www.chromium.org/favicon.ico' WHERE template_id = 'chromium' AND icon = '/api/assets/icons/builtin-chromium.svg';
+UPDATE templates SET icon = 'https://grafana.com/static/assets/img/fav32.png' WHERE template_id = 'grafana' AND icon = '/api/assets/icons/builtin-grafana.svg';
+UPDATE templates SET icon = 'https://prometheus.io/assets/favicons/favicon.ico' WHERE template_id = 'prometheus' AND icon = '/api/assets/icons/builtin-prometheus.svg';
+UPDATE templates SET icon = 'https://uptime.kuma.pet/img/icon.svg' WHERE template_id = 'uptime-kuma' AND icon = '/api/assets/icons/builtin-uptime-kuma.svg';
+UPDATE templates SET icon = 'https://www.pgadmin.org/static/COMPILED/assets/img/favicon.ico' WHERE template_id = 'pgadmin' AND icon = '/api/assets/icons/builtin-pgadmin.svg';
+UPDATE templates SET icon = 'https://www.adminer.org/static/favicon.ico' WHERE template_id = 'adminer' AND icon = '/api/assets/icons/builtin-adminer.svg';
+UPDATE templates SET icon = 'https://www.gimp.org/images/frontpage/wilber-big.png' WHERE template_id = 'gimp' AND icon = '/api/assets/icons/builtin-gimp.svg';
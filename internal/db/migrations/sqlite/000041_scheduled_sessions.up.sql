@@ -0,0 +1,20 @@
+-- Session launches scheduled ahead of time, once or on a cron recurrence.
+-- Due schedules are launched by the leader replica.
+CREATE TABLE scheduled_sessions (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    created_by TEXT NOT NULL DEFAULT '',
+    app_id TEXT NOT NULL,
+    tenant_id TEXT NOT NULL DEFAULT 'default',
+    start_at DATETIME NOT NULL,
+    recurrence TEXT NOT NULL DEFAULT '',
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    ends_at DATETIME,
+    next_run_at DATETIME,
+    last_run_at DATETIME,
+    last_session_id TEXT NOT NULL DEFAULT '',
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_scheduled_sessions_next_run ON scheduled_sessions(next_run_at);
+CREATE INDEX idx_scheduled_sessions_user ON scheduled_sessions(user_id);
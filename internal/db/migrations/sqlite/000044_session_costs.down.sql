@@ -0,0 +1,2 @@
+ALTER TABLE session_history DROP COLUMN memory_gib_seconds;
+ALTER TABLE session_history DROP COLUMN cpu_core_seconds;
@@ -0,0 +1,4 @@
+-- Resources a session run requested, multiplied by how long it ran, for
+-- cost reports: CPU in core-seconds and memory in GiB-seconds.
+ALTER TABLE session_history ADD COLUMN cpu_core_seconds REAL NOT NULL DEFAULT 0;
+ALTER TABLE session_history ADD COLUMN memory_gib_seconds REAL NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- Limit on the app's active sessions across all users, 0 for none.
+ALTER TABLE applications ADD COLUMN max_concurrent_sessions INTEGER NOT NULL DEFAULT 0;
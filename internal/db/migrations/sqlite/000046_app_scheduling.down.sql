@@ -0,0 +1,2 @@
+ALTER TABLE app_specs DROP COLUMN scheduling;
+ALTER TABLE applications DROP COLUMN scheduling;
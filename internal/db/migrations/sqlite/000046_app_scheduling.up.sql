@@ -0,0 +1,4 @@
+-- Node selector, tolerations and node affinity rendered into the app's
+-- session pods, stored as JSON.
+ALTER TABLE applications ADD COLUMN scheduling TEXT NOT NULL DEFAULT '';
+ALTER TABLE app_specs ADD COLUMN scheduling TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE app_specs DROP COLUMN image_pull_secret;
+ALTER TABLE applications DROP COLUMN image_pull_secret;
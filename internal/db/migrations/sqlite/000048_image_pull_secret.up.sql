@@ -0,0 +1,4 @@
+-- Name of the Secret used to pull the app's images from a private
+-- registry.
+ALTER TABLE applications ADD COLUMN image_pull_secret TEXT NOT NULL DEFAULT '';
+ALTER TABLE app_specs ADD COLUMN image_pull_secret TEXT NOT NULL DEFAULT '';
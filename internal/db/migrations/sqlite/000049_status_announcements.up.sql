@@ -0,0 +1,13 @@
+-- Incident and maintenance announcements shown on the public status page.
+CREATE TABLE status_announcements (
+    id TEXT PRIMARY KEY,
+    kind TEXT NOT NULL,
+    title TEXT NOT NULL,
+    message TEXT NOT NULL DEFAULT '',
+    starts_at DATETIME NOT NULL,
+    ends_at DATETIME,
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_status_announcements_ends ON status_announcements(ends_at);
@@ -0,0 +1 @@
+ALTER TABLE applications DROP COLUMN launch_confirmation;
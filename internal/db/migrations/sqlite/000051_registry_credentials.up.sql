@@ -0,0 +1,15 @@
+-- Container registry credentials managed by admins. Each is synced to a
+-- dockerconfigjson Secret of the same name that apps and tenants reference
+-- as their image pull secret. The password is encrypted by the server.
+CREATE TABLE registry_credentials (
+    name TEXT PRIMARY KEY,
+    server TEXT NOT NULL,
+    username TEXT NOT NULL,
+    password TEXT NOT NULL,
+    email TEXT NOT NULL DEFAULT '',
+    sync_error TEXT NOT NULL DEFAULT '',
+    synced_at DATETIME,
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,15 @@
+-- Best-practice rules broken by apps and templates in the catalog, as
+-- found by the last run of the catalog lint job. Each run replaces them.
+CREATE TABLE lint_warnings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL,
+    target_id TEXT NOT NULL,
+    target_name TEXT NOT NULL DEFAULT '',
+    tenant_id TEXT NOT NULL DEFAULT '',
+    rule TEXT NOT NULL,
+    severity TEXT NOT NULL,
+    field TEXT NOT NULL DEFAULT '',
+    message TEXT NOT NULL,
+    checked_at DATETIME NOT NULL
+);
+CREATE INDEX idx_lint_warnings_target ON lint_warnings(kind, target_id);
@@ -0,0 +1,3 @@
+ALTER TABLE templates DROP COLUMN catalog_hash;
+ALTER TABLE templates DROP COLUMN catalog;
+DROP TABLE IF EXISTS remote_catalogs;
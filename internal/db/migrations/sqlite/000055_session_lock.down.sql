@@ -0,0 +1,2 @@
+ALTER TABLE sessions DROP COLUMN locked_at;
+ALTER TABLE applications DROP COLUMN lock_timeout;
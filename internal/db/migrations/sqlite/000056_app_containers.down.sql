@@ -0,0 +1,4 @@
+ALTER TABLE app_specs DROP COLUMN shared_volumes;
+ALTER TABLE app_specs DROP COLUMN containers;
+ALTER TABLE applications DROP COLUMN shared_volumes;
+ALTER TABLE applications DROP COLUMN containers;
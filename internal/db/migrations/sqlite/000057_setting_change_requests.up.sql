@@ -0,0 +1,15 @@
+-- Staged changes to high-impact settings, applied only once an admin other
+-- than the requester approves them.
+CREATE TABLE setting_change_requests (
+    id TEXT PRIMARY KEY,
+    changes TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'pending',
+    requested_by TEXT NOT NULL,
+    reviewed_by TEXT NOT NULL DEFAULT '',
+    review_comment TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    reviewed_at DATETIME
+);
+
+CREATE INDEX idx_setting_change_requests_status ON setting_change_requests(status, created_at);
@@ -0,0 +1,2 @@
+ALTER TABLE app_specs DROP COLUMN init_containers;
+ALTER TABLE applications DROP COLUMN init_containers;
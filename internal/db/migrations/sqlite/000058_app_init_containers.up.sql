@@ -0,0 +1,4 @@
+-- Containers an app runs to completion before its session containers
+-- start, stored as JSON.
+ALTER TABLE applications ADD COLUMN init_containers TEXT NOT NULL DEFAULT '';
+ALTER TABLE app_specs ADD COLUMN init_containers TEXT NOT NULL DEFAULT '';
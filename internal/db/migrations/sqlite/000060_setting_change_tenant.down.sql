@@ -0,0 +1 @@
+ALTER TABLE setting_change_requests DROP COLUMN tenant_change;
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// PasswordResetToken is a one-time token, sent by email, that lets a user
+// choose a new password. Only a keyed hash of the token is stored.
+type PasswordResetToken struct {
+	bun.BaseModel `bun:"table:password_reset_tokens"`
+
+	TokenHash string    `bun:"token_hash,pk"`
+	UserID    string    `bun:"user_id,notnull"`
+	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	ExpiresAt time.Time `bun:"expires_at,notnull"`
+}
+
+// CreatePasswordResetToken stores a reset token for a user until
+// expiresAt, and removes tokens that expired without being used.
+func (db *DB) CreatePasswordResetToken(tokenHash, userID string, expiresAt time.Time) error {
+	return db.WithTx(func(tx *DB) error {
+		if _, err := tx.conn.NewDelete().Model((*PasswordResetToken)(nil)).
+			Where("expires_at < ?", time.Now()).
+			Exec(tx.ctx()); err != nil {
+			return err
+		}
+		_, err := tx.conn.NewInsert().Model(&PasswordResetToken{
+			TokenHash: tokenHash,
+			UserID:    userID,
+			CreatedAt: time.Now(),
+			ExpiresAt: expiresAt,
+		}).Exec(tx.ctx())
+		return err
+	})
+}
+
+// ConsumePasswordResetToken atomically loads and deletes a reset token, so
+// each can be used only once. It returns nil if the token is unknown or
+// has expired.
+func (db *DB) ConsumePasswordResetToken(tokenHash string) (*PasswordResetToken, error) {
+	var token PasswordResetToken
+	err := db.runInTx(func(txCtx context.Context, tx bun.Tx) error {
+		if err := tx.NewSelect().Model(&token).Where("token_hash = ?", tokenHash).Scan(txCtx); err != nil {
+			return err
+		}
+		_, err := tx.NewDelete().Model((*PasswordResetToken)(nil)).Where("token_hash = ?", tokenHash).Exec(txCtx)
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+// DeletePasswordResetTokens removes all of a user's reset tokens.
+func (db *DB) DeletePasswordResetTokens(userID string) error {
+	_, err := db.conn.NewDelete().Model((*PasswordResetToken)(nil)).
+		Where("user_id = ?", userID).
+		Exec(db.ctx())
+	return err
+}
+
+// ListUsersByEmail returns the users with an email address, compared
+// case-insensitively. Email addresses are not unique, so there may be
+// several.
+func (db *DB) ListUsersByEmail(email string) ([]User, error) {
+	users := []User{}
+	err := db.conn.NewSelect().Model(&users).
+		Where("LOWER(email) = ?", strings.ToLower(email)).
+		Order("username ASC").
+		Scan(db.ctx())
+	return users, err
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPasswordResetTokens(t *testing.T) {
+	db := setupTestDB(t)
+
+	if err := db.CreatePasswordResetToken("old", "user-1", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("CreatePasswordResetToken() error = %v", err)
+	}
+	if err := db.CreatePasswordResetToken("t1", "user-1", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("CreatePasswordResetToken() error = %v", err)
+	}
+
+	got, err := db.ConsumePasswordResetToken("t1")
+	if err != nil || got == nil || got.UserID != "user-1" {
+		t.Fatalf("ConsumePasswordResetToken() = %+v, %v", got, err)
+	}
+	if got, err := db.ConsumePasswordResetToken("t1"); err != nil || got != nil {
+		t.Errorf("second ConsumePasswordResetToken() = %+v, %v; want nil", got, err)
+	}
+	// Creating t1 cleared the expired token
+	if n, _ := db.conn.NewSelect().Model((*PasswordResetToken)(nil)).Count(db.ctx()); n != 0 {
+		t.Errorf("%d tokens left, want 0", n)
+	}
+
+	if err := db.CreatePasswordResetToken("t2", "user-1", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := db.ConsumePasswordResetToken("t2"); got != nil {
+		t.Errorf("ConsumePasswordResetToken(expired) = %+v, want nil", got)
+	}
+
+	db.CreatePasswordResetToken("t3", "user-1", time.Now().Add(time.Hour))
+	db.CreatePasswordResetToken("t4", "user-2", time.Now().Add(time.Hour))
+	if err := db.DeletePasswordResetTokens("user-1"); err != nil {
+		t.Fatalf("DeletePasswordResetTokens() error = %v", err)
+	}
+	if got, _ := db.ConsumePasswordResetToken("t3"); got != nil {
+		t.Error("DeletePasswordResetTokens() kept the user's token")
+	}
+	if got, _ := db.ConsumePasswordResetToken("t4"); got == nil {
+		t.Error("DeletePasswordResetTokens() deleted another user's token")
+	}
+}
+
+func TestListUsersByEmail(t *testing.T) {
+	db := setupTestDB(t)
+	for _, u := range []User{
+		{ID: "u1", Username: "bob", Email: "Team@Example.com", Roles: []string{"user"}},
+		{ID: "u2", Username: "alice", Email: "team@example.com", Roles: []string{"user"}},
+		{ID: "u3", Username: "carol", Email: "carol@example.com", Roles: []string{"user"}},
+	} {
+		if err := db.CreateUser(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	users, err := db.ListUsersByEmail("TEAM@example.com")
+	if err != nil || len(users) != 2 || users[0].Username != "alice" || users[1].Username != "bob" {
+		t.Errorf("ListUsersByEmail() = %+v, %v; want alice and bob", users, err)
+	}
+}
+
+func TestUpdateUserPassword(t *testing.T) {
+	db := setupTestDB(t)
+	if err := db.CreateUser(User{ID: "u1", Username: "bob", PasswordHash: "old", Roles: []string{"admin"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.UpdateUserPassword("u1", "new"); err != nil {
+		t.Fatalf("UpdateUserPassword() error = %v", err)
+	}
+	user, _ := db.GetUserByID("u1")
+	if user.PasswordHash != "new" || len(user.Roles) != 1 || user.Roles[0] != "admin" {
+		t.Errorf("user after UpdateUserPassword() = %+v", user)
+	}
+	if err := db.UpdateUserPassword("missing", "x"); err == nil {
+		t.Error("UpdateUserPassword(missing) = nil, want an error")
+	}
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// RecordingPolicy says whether sessions are recorded as soon as they start.
+type RecordingPolicy string
+
+const (
+	RecordingPolicyAuto   RecordingPolicy = "auto"
+	RecordingPolicyManual RecordingPolicy = "manual"
+)
+
+// Valid reports whether p is a known policy or empty.
+func (p RecordingPolicy) Valid() bool {
+	switch p {
+	case "", RecordingPolicyAuto, RecordingPolicyManual:
+		return true
+	}
+	return false
+}
+
+// PolicyDefaults are session settings a category or tenant applies to its
+// apps. An app's own settings win over its category's, which win over its
+// tenant's, which win over the server's. Empty fields inherit.
+type PolicyDefaults struct {
+	ResourceLimits  *ResourceLimits `json:"resource_limits,omitempty"`
+	EgressPolicy    *EgressPolicy   `json:"egress_policy,omitempty"`
+	IdleTimeout     int64           `json:"idle_timeout,omitempty"` // Seconds
+	RecordingPolicy RecordingPolicy `json:"recording_policy,omitempty"`
+	// LockTimeout is how long in seconds a session may go without input
+	// before its stream is locked until the owner signs in again.
+	LockTimeout int64 `json:"lock_timeout,omitempty"`
+}
+
+// Validate checks the timeouts, recording policy, and egress mode.
+func (d *PolicyDefaults) Validate() error {
+	if d.IdleTimeout < 0 {
+		return fmt.Errorf("idle_timeout must be non-negative, got %d", d.IdleTimeout)
+	}
+	if d.LockTimeout < 0 {
+		return fmt.Errorf("lock_timeout must be non-negative, got %d", d.LockTimeout)
+	}
+	if !d.RecordingPolicy.Valid() {
+		return fmt.Errorf("recording_policy must be auto or manual, got %q", d.RecordingPolicy)
+	}
+	if d.EgressPolicy != nil {
+		switch d.EgressPolicy.Mode {
+		case "", "allowlist", "denylist":
+		default:
+			return fmt.Errorf("egress_policy.mode must be allowlist or denylist, got %q", d.EgressPolicy.Mode)
+		}
+	}
+	return nil
+}
+
+// marshalPolicyDefaults encodes d for a JSON column; nil encodes as "".
+func marshalPolicyDefaults(d *PolicyDefaults) string {
+	if d == nil {
+		return ""
+	}
+	b, err := json.Marshal(d)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// unmarshalPolicyDefaults decodes a JSON column written by
+// marshalPolicyDefaults.
+func unmarshalPolicyDefaults(s string) *PolicyDefaults {
+	if s == "" {
+		return nil
+	}
+	var d PolicyDefaults
+	if json.Unmarshal([]byte(s), &d) != nil {
+		return nil
+	}
+	return &d
+}
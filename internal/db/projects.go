@@ -0,0 +1,297 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// ProjectRole is what a member may do in a project.
+type ProjectRole string
+
+const (
+	// ProjectRoleOwner members manage the project and its members, and
+	// launch sessions in it.
+	ProjectRoleOwner ProjectRole = "owner"
+	// ProjectRoleMember members launch sessions in the project.
+	ProjectRoleMember ProjectRole = "member"
+	// ProjectRoleViewer members see the project's sessions and shares.
+	ProjectRoleViewer ProjectRole = "viewer"
+)
+
+// Valid reports whether r is a known project role.
+func (r ProjectRole) Valid() bool {
+	switch r {
+	case ProjectRoleOwner, ProjectRoleMember, ProjectRoleViewer:
+		return true
+	}
+	return false
+}
+
+// CanLaunch reports whether members with the role may launch sessions in
+// the project.
+func (r ProjectRole) CanLaunch() bool {
+	return r == ProjectRoleOwner || r == ProjectRoleMember
+}
+
+// ProjectDrive is a volume shared by the sessions of a project, mounted in
+// the app container of each session launched in it.
+type ProjectDrive struct {
+	MountPath    string `json:"mount_path"`              // Absolute path in the app container
+	Size         string `json:"size,omitempty"`          // Requested capacity, e.g. "50Gi"
+	StorageClass string `json:"storage_class,omitempty"` // Must support ReadWriteMany; empty uses the cluster default
+}
+
+// Project is a workspace grouping related sessions, their shares and a
+// shared drive. Its members launch sessions in it, which count towards
+// MaxSessions and are attributed to it in session history.
+type Project struct {
+	bun.BaseModel `bun:"table:projects"`
+
+	ID          string `json:"id" bun:"id,pk"`
+	TenantID    string `json:"tenant_id,omitempty" bun:"tenant_id,notnull"`
+	Name        string `json:"name" bun:"name,notnull"`
+	Description string `json:"description,omitempty" bun:"description,notnull"`
+	// MaxSessions limits the project's active sessions, across all of its
+	// members (0 = unlimited).
+	MaxSessions int           `json:"max_sessions,omitempty" bun:"max_sessions,notnull"`
+	Drive       *ProjectDrive `json:"drive,omitempty" bun:"-"`
+	CreatedBy   string        `json:"created_by,omitempty" bun:"created_by,notnull"`
+	CreatedAt   time.Time     `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	UpdatedAt   time.Time     `json:"updated_at" bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+
+	// Role is the requesting user's role in the project, if they are a
+	// member. It is filled in by handlers, not stored.
+	Role ProjectRole `json:"role,omitempty" bun:"-"`
+
+	// JSON-serialized DB column
+	DriveJSON string `json:"-" bun:"drive"`
+}
+
+// ProjectMember is a user's membership of a project.
+type ProjectMember struct {
+	bun.BaseModel `bun:"table:project_members"`
+
+	ProjectID string      `json:"project_id" bun:"project_id,pk"`
+	UserID    string      `json:"user_id" bun:"user_id,pk"`
+	Role      ProjectRole `json:"role" bun:"role,notnull"`
+	CreatedAt time.Time   `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// CreateProject inserts a new project with its creator as its owner.
+func (db *DB) CreateProject(p Project) error {
+	if p.TenantID == "" {
+		p.TenantID = DefaultTenantID
+	}
+	return db.runInTx(func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(&p).Exec(ctx); err != nil {
+			return err
+		}
+		owner := ProjectMember{ProjectID: p.ID, UserID: p.CreatedBy, Role: ProjectRoleOwner}
+		_, err := tx.NewInsert().Model(&owner).Exec(ctx)
+		return err
+	})
+}
+
+// GetProject returns a project by ID, or nil if it does not exist.
+func (db *DB) GetProject(id string) (*Project, error) {
+	var p Project
+	err := db.conn.NewSelect().Model(&p).Where("id = ?", id).Scan(db.ctx())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListProjects returns every project, optionally only those of one tenant,
+// by name.
+func (db *DB) ListProjects(tenantID string) ([]Project, error) {
+	projects := []Project{}
+	q := db.conn.NewSelect().Model(&projects)
+	if tenantID != "" {
+		q = q.Where("tenant_id = ?", tenantID)
+	}
+	err := q.OrderExpr("name ASC, id ASC").Scan(db.ctx())
+	return projects, err
+}
+
+// ListProjectsForUser returns the projects a user is a member of, by name,
+// with Role set to their role in each.
+func (db *DB) ListProjectsForUser(userID string) ([]Project, error) {
+	var members []ProjectMember
+	err := db.conn.NewSelect().Model(&members).Where("user_id = ?", userID).Scan(db.ctx())
+	if err != nil {
+		return nil, err
+	}
+	projects := []Project{}
+	if len(members) == 0 {
+		return projects, nil
+	}
+	roles := make(map[string]ProjectRole, len(members))
+	ids := make([]string, 0, len(members))
+	for _, m := range members {
+		roles[m.ProjectID] = m.Role
+		ids = append(ids, m.ProjectID)
+	}
+	err = db.conn.NewSelect().Model(&projects).
+		Where("id IN (?)", bun.In(ids)).
+		OrderExpr("name ASC, id ASC").
+		Scan(db.ctx())
+	for i := range projects {
+		projects[i].Role = roles[projects[i].ID]
+	}
+	return projects, err
+}
+
+// UpdateProject updates a project's name, description, session limit and
+// drive.
+func (db *DB) UpdateProject(p Project) error {
+	p.UpdatedAt = time.Now()
+	result, err := db.conn.NewUpdate().Model(&p).
+		Column("name", "description", "max_sessions", "drive", "updated_at").
+		WherePK().
+		Exec(db.ctx())
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteProject deletes a project and its memberships. Its sessions keep
+// their project ID, so history stays attributed to it.
+func (db *DB) DeleteProject(id string) error {
+	return db.runInTx(func(ctx context.Context, tx bun.Tx) error {
+		result, err := tx.NewDelete().Model((*Project)(nil)).Where("id = ?", id).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return sql.ErrNoRows
+		}
+		_, err = tx.NewDelete().Model((*ProjectMember)(nil)).Where("project_id = ?", id).Exec(ctx)
+		return err
+	})
+}
+
+// GetProjectMember returns a user's membership of a project, or nil if
+// they are not a member.
+func (db *DB) GetProjectMember(projectID, userID string) (*ProjectMember, error) {
+	var m ProjectMember
+	err := db.conn.NewSelect().Model(&m).
+		Where("project_id = ?", projectID).
+		Where("user_id = ?", userID).
+		Scan(db.ctx())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// ListProjectMembers returns the members of a project, oldest first.
+func (db *DB) ListProjectMembers(projectID string) ([]ProjectMember, error) {
+	members := []ProjectMember{}
+	err := db.conn.NewSelect().Model(&members).
+		Where("project_id = ?", projectID).
+		OrderExpr("created_at ASC, user_id ASC").
+		Scan(db.ctx())
+	return members, err
+}
+
+// SetProjectMember adds a user to a project, or changes their role if
+// they are already a member.
+func (db *DB) SetProjectMember(m ProjectMember) error {
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = time.Now()
+	}
+	_, err := db.conn.NewInsert().Model(&m).
+		On("CONFLICT (project_id, user_id) DO UPDATE").
+		Set("role = EXCLUDED.role").
+		Exec(db.ctx())
+	return err
+}
+
+// DeleteProjectMember removes a user from a project.
+func (db *DB) DeleteProjectMember(projectID, userID string) error {
+	result, err := db.conn.NewDelete().Model((*ProjectMember)(nil)).
+		Where("project_id = ?", projectID).
+		Where("user_id = ?", userID).
+		Exec(db.ctx())
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CountProjectOwners returns the number of owners of a project.
+func (db *DB) CountProjectOwners(projectID string) (int, error) {
+	return db.conn.NewSelect().Model((*ProjectMember)(nil)).
+		Where("project_id = ?", projectID).
+		Where("role = ?", ProjectRoleOwner).
+		Count(db.ctx())
+}
+
+// DeleteProjectMembershipsByUser removes a user from every project.
+func (db *DB) DeleteProjectMembershipsByUser(userID string) error {
+	_, err := db.conn.NewDelete().Model((*ProjectMember)(nil)).Where("user_id = ?", userID).Exec(db.ctx())
+	return err
+}
+
+// CountActiveSessionsByProject returns the number of active (creating or
+// running) sessions launched in a project.
+func (db *DB) CountActiveSessionsByProject(projectID string) (int, error) {
+	return db.conn.NewSelect().Model((*Session)(nil)).
+		Where("project_id = ?", projectID).
+		Where("status IN ('creating', 'running')").
+		Count(db.ctx())
+}
+
+// ListSessionsByProject returns the sessions launched in a project that
+// have not terminated or failed, newest first.
+func (db *DB) ListSessionsByProject(projectID string) ([]Session, error) {
+	var sessions []Session
+	err := db.conn.NewSelect().Model(&sessions).
+		Where("project_id = ?", projectID).
+		Where("status NOT IN ('terminated', 'failed')").
+		OrderExpr("created_at DESC").
+		Scan(db.ctx())
+	return sessions, err
+}
+
+// ListProjectShares returns the shares of the sessions launched in a
+// project, newest first.
+func (db *DB) ListProjectShares(projectID string) ([]SessionShare, error) {
+	shares := []SessionShare{}
+	err := db.conn.NewSelect().Model(&shares).
+		Where("session_id IN (?)", db.conn.NewSelect().Model((*Session)(nil)).
+			Column("id").
+			Where("project_id = ?", projectID)).
+		OrderExpr("created_at DESC").
+		Scan(db.ctx())
+	return shares, err
+}
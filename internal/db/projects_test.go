@@ -0,0 +1,136 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestProjects(t *testing.T) {
+	db := setupTestDB(t)
+
+	drive := &ProjectDrive{MountPath: "/data", Size: "50Gi", StorageClass: "nfs"}
+	if err := db.CreateProject(Project{ID: "p1", Name: "Genomics", MaxSessions: 4, Drive: drive, CreatedBy: "alice"}); err != nil {
+		t.Fatalf("CreateProject() error = %v", err)
+	}
+	if err := db.CreateProject(Project{ID: "p2", Name: "Astronomy", CreatedBy: "bob"}); err != nil {
+		t.Fatalf("CreateProject() error = %v", err)
+	}
+
+	got, err := db.GetProject("p1")
+	if err != nil || got == nil {
+		t.Fatalf("GetProject() = %v, %v", got, err)
+	}
+	if got.TenantID != DefaultTenantID || got.MaxSessions != 4 || got.Drive == nil || *got.Drive != *drive {
+		t.Errorf("GetProject() = %+v", got)
+	}
+	if missing, err := db.GetProject("missing"); missing != nil || err != nil {
+		t.Errorf("GetProject(missing) = %v, %v, want nil", missing, err)
+	}
+
+	// The creator owns the project
+	owner, err := db.GetProjectMember("p1", "alice")
+	if err != nil || owner == nil || owner.Role != ProjectRoleOwner {
+		t.Fatalf("GetProjectMember(p1, alice) = %+v, %v, want owner", owner, err)
+	}
+
+	if err := db.SetProjectMember(ProjectMember{ProjectID: "p1", UserID: "bob", Role: ProjectRoleViewer}); err != nil {
+		t.Fatalf("SetProjectMember() error = %v", err)
+	}
+	if err := db.SetProjectMember(ProjectMember{ProjectID: "p1", UserID: "bob", Role: ProjectRoleMember}); err != nil {
+		t.Fatalf("SetProjectMember() again error = %v", err)
+	}
+	members, _ := db.ListProjectMembers("p1")
+	if len(members) != 2 || members[1].UserID != "bob" || members[1].Role != ProjectRoleMember {
+		t.Errorf("ListProjectMembers(p1) = %+v, want alice and bob as member", members)
+	}
+	if n, _ := db.CountProjectOwners("p1"); n != 1 {
+		t.Errorf("CountProjectOwners(p1) = %d, want 1", n)
+	}
+
+	mine, err := db.ListProjectsForUser("bob")
+	if err != nil {
+		t.Fatalf("ListProjectsForUser() error = %v", err)
+	}
+	if len(mine) != 2 || mine[0].ID != "p2" || mine[0].Role != ProjectRoleOwner || mine[1].ID != "p1" || mine[1].Role != ProjectRoleMember {
+		t.Errorf("ListProjectsForUser(bob) = %+v, want Astronomy as owner, Genomics as member", mine)
+	}
+	if none, _ := db.ListProjectsForUser("carol"); len(none) != 0 {
+		t.Errorf("ListProjectsForUser(carol) = %+v, want none", none)
+	}
+	if all, _ := db.ListProjects(""); len(all) != 2 {
+		t.Errorf("ListProjects() = %d projects, want 2", len(all))
+	}
+
+	got.Name = "Genomics Lab"
+	got.Drive = nil
+	if err := db.UpdateProject(*got); err != nil {
+		t.Fatalf("UpdateProject() error = %v", err)
+	}
+	if updated, _ := db.GetProject("p1"); updated.Name != "Genomics Lab" || updated.Drive != nil {
+		t.Errorf("GetProject() after update = %+v", updated)
+	}
+
+	if err := db.DeleteProjectMember("p1", "carol"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("DeleteProjectMember(non-member) error = %v, want ErrNoRows", err)
+	}
+	if err := db.DeleteProjectMembershipsByUser("bob"); err != nil {
+		t.Fatalf("DeleteProjectMembershipsByUser() error = %v", err)
+	}
+	if mine, _ := db.ListProjectsForUser("bob"); len(mine) != 0 {
+		t.Errorf("ListProjectsForUser(bob) after removal = %+v", mine)
+	}
+
+	if err := db.DeleteProject("p1"); err != nil {
+		t.Fatalf("DeleteProject() error = %v", err)
+	}
+	if m, _ := db.GetProjectMember("p1", "alice"); m != nil {
+		t.Errorf("membership of deleted project kept: %+v", m)
+	}
+	if err := db.DeleteProject("p1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("DeleteProject() again error = %v, want ErrNoRows", err)
+	}
+}
+
+func TestProjectSessionsAndShares(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Now()
+
+	for _, s := range []Session{
+		{ID: "s1", UserID: "alice", AppID: "app", Status: SessionStatusRunning, ProjectID: "p1", CreatedAt: now, UpdatedAt: now},
+		{ID: "s2", UserID: "bob", AppID: "app", Status: SessionStatusStopped, ProjectID: "p1", CreatedAt: now.Add(time.Minute), UpdatedAt: now},
+		{ID: "s3", UserID: "bob", AppID: "app", Status: SessionStatusFailed, ProjectID: "p1", CreatedAt: now, UpdatedAt: now},
+		{ID: "s4", UserID: "alice", AppID: "app", Status: SessionStatusRunning, CreatedAt: now, UpdatedAt: now},
+	} {
+		if err := db.CreateSession(s); err != nil {
+			t.Fatalf("CreateSession(%s) error = %v", s.ID, err)
+		}
+	}
+	for _, share := range []SessionShare{
+		{ID: "sh1", SessionID: "s1", UserID: "carol", ShareToken: "token-1", Permission: SharePermissionReadOnly, CreatedBy: "alice"},
+		{ID: "sh2", SessionID: "s4", UserID: "carol", ShareToken: "token-2", Permission: SharePermissionReadOnly, CreatedBy: "alice"},
+	} {
+		if err := db.CreateSessionShare(share); err != nil {
+			t.Fatalf("CreateSessionShare(%s) error = %v", share.ID, err)
+		}
+	}
+
+	if n, _ := db.CountActiveSessionsByProject("p1"); n != 1 {
+		t.Errorf("CountActiveSessionsByProject(p1) = %d, want 1", n)
+	}
+	sessions, err := db.ListSessionsByProject("p1")
+	if err != nil {
+		t.Fatalf("ListSessionsByProject() error = %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != "s2" || sessions[1].ID != "s1" {
+		t.Errorf("ListSessionsByProject(p1) = %+v, want s2, s1", sessions)
+	}
+	shares, err := db.ListProjectShares("p1")
+	if err != nil {
+		t.Fatalf("ListProjectShares() error = %v", err)
+	}
+	if len(shares) != 1 || shares[0].ID != "sh1" {
+		t.Errorf("ListProjectShares(p1) = %+v, want sh1", shares)
+	}
+}
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// QuotaBurst tracks a user's burst debt against a soft session limit: the
+// burst time used so far, and whether the user was above the soft limit
+// when it was last settled.
+type QuotaBurst struct {
+	bun.BaseModel `bun:"table:quota_bursts"`
+
+	UserID      string    `json:"user_id" bun:"user_id,pk"`
+	DebtSeconds int64     `json:"debt_seconds" bun:"debt_seconds,notnull"`
+	Bursting    bool      `json:"bursting" bun:"bursting,notnull"`
+	UpdatedAt   time.Time `json:"updated_at" bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// GetQuotaBurst returns a user's burst state, or nil if they have none.
+func (db *DB) GetQuotaBurst(userID string) (*QuotaBurst, error) {
+	var b QuotaBurst
+	err := db.conn.NewSelect().Model(&b).Where("user_id = ?", userID).Scan(db.ctx())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// SaveQuotaBurst inserts or replaces a user's burst state.
+func (db *DB) SaveQuotaBurst(b QuotaBurst) error {
+	if b.UpdatedAt.IsZero() {
+		b.UpdatedAt = time.Now()
+	}
+	_, err := db.conn.NewInsert().Model(&b).
+		On("CONFLICT (user_id) DO UPDATE").
+		Set("debt_seconds = EXCLUDED.debt_seconds, bursting = EXCLUDED.bursting, updated_at = EXCLUDED.updated_at").
+		Exec(db.ctx())
+	return err
+}
@@ -0,0 +1,31 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuotaBursts(t *testing.T) {
+	db := setupTestDB(t)
+
+	got, err := db.GetQuotaBurst("user-1")
+	if err != nil || got != nil {
+		t.Fatalf("GetQuotaBurst(missing) = %v, %v; want nil, nil", got, err)
+	}
+
+	at := time.Now().Add(-time.Minute).Truncate(time.Second)
+	if err := db.SaveQuotaBurst(QuotaBurst{UserID: "user-1", DebtSeconds: 60, Bursting: true, UpdatedAt: at}); err != nil {
+		t.Fatalf("SaveQuotaBurst() error = %v", err)
+	}
+	if err := db.SaveQuotaBurst(QuotaBurst{UserID: "user-1", DebtSeconds: 90, UpdatedAt: at.Add(30 * time.Second)}); err != nil {
+		t.Fatalf("SaveQuotaBurst() update error = %v", err)
+	}
+
+	got, err = db.GetQuotaBurst("user-1")
+	if err != nil || got == nil {
+		t.Fatalf("GetQuotaBurst() = %v, %v", got, err)
+	}
+	if got.DebtSeconds != 90 || got.Bursting || !got.UpdatedAt.Equal(at.Add(30*time.Second)) {
+		t.Errorf("GetQuotaBurst() = %+v", got)
+	}
+}
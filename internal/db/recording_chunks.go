@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// RecordingChunk is one piece of a recording, uploaded while the session is
+// still running. Chunks are concatenated in sequence order when the
+// recording is finalized.
+type RecordingChunk struct {
+	bun.BaseModel `bun:"table:recording_chunks"`
+
+	RecordingID string    `json:"recording_id" bun:"recording_id,pk"`
+	Seq         int       `json:"seq" bun:"seq,pk"`
+	StoragePath string    `json:"storage_path" bun:"storage_path,notnull"`
+	SizeBytes   int64     `json:"size_bytes" bun:"size_bytes,notnull"`
+	CreatedAt   time.Time `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// SaveRecordingChunk records an uploaded chunk, replacing an earlier upload
+// with the same sequence number, and checkpoints its recording: the size
+// becomes the total of its chunks, and the duration is set when positive.
+func (db *DB) SaveRecordingChunk(chunk RecordingChunk, durationSeconds float64) error {
+	now := time.Now()
+	if chunk.CreatedAt.IsZero() {
+		chunk.CreatedAt = now
+	}
+	return db.runInTx(func(txCtx context.Context, tx bun.Tx) error {
+		_, err := tx.NewInsert().Model(&chunk).
+			On("CONFLICT (recording_id, seq) DO UPDATE").
+			Set("storage_path = EXCLUDED.storage_path, size_bytes = EXCLUDED.size_bytes, created_at = EXCLUDED.created_at").
+			Exec(txCtx)
+		if err != nil {
+			return err
+		}
+
+		q := tx.NewUpdate().Model((*Recording)(nil)).
+			Set("size_bytes = (SELECT COALESCE(SUM(size_bytes), 0) FROM recording_chunks WHERE recording_id = ?)", chunk.RecordingID).
+			Set("checkpoint_at = ?", now).
+			Where("id = ?", chunk.RecordingID)
+		if durationSeconds > 0 {
+			q = q.Set("duration_seconds = ?", durationSeconds)
+		}
+		result, err := q.Exec(txCtx)
+		if err != nil {
+			return err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+}
+
+// ListRecordingChunks returns the chunks of a recording in sequence order.
+func (db *DB) ListRecordingChunks(recordingID string) ([]RecordingChunk, error) {
+	var chunks []RecordingChunk
+	err := db.conn.NewSelect().Model(&chunks).
+		Where("recording_id = ?", recordingID).
+		OrderExpr("seq ASC").
+		Scan(db.ctx())
+	return chunks, err
+}
+
+// ListAllRecordingChunks returns the chunks of every recording, for orphan
+// detection.
+func (db *DB) ListAllRecordingChunks() ([]RecordingChunk, error) {
+	var chunks []RecordingChunk
+	err := db.conn.NewSelect().Model(&chunks).
+		OrderExpr("recording_id ASC, seq ASC").
+		Scan(db.ctx())
+	return chunks, err
+}
+
+// DeleteRecordingChunks removes the chunk records of a recording.
+func (db *DB) DeleteRecordingChunks(recordingID string) error {
+	_, err := db.conn.NewDelete().Model((*RecordingChunk)(nil)).
+		Where("recording_id = ?", recordingID).
+		Exec(db.ctx())
+	return err
+}
+
+// BeginRecordingFinalize moves a recording that is still recording or
+// uploading to processing, so that chunk uploads stop and only one caller
+// assembles it. It returns false if the recording was not in either state.
+func (db *DB) BeginRecordingFinalize(id string) (bool, error) {
+	result, err := db.conn.NewUpdate().Model((*Recording)(nil)).
+		Set("status = ?", RecordingStatusProcessing).
+		Where("id = ?", id).
+		Where("status IN (?)", bun.In([]RecordingStatus{RecordingStatusRecording, RecordingStatusUploading})).
+		Exec(db.ctx())
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// ListStaleRecordings returns recordings still recording or uploading whose
+// last chunk, or creation if none arrived, is before the given time.
+func (db *DB) ListStaleRecordings(before time.Time) ([]Recording, error) {
+	var recs []Recording
+	err := db.conn.NewSelect().Model(&recs).
+		Where("status IN (?)", bun.In([]RecordingStatus{RecordingStatusRecording, RecordingStatusUploading})).
+		Where("COALESCE(checkpoint_at, created_at) < ?", before).
+		OrderExpr("created_at ASC").
+		Scan(db.ctx())
+	return recs, err
+}
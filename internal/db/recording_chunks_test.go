@@ -0,0 +1,147 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func setupChunkedRecording(t *testing.T, db *DB, id string) {
+	t.Helper()
+	app := Application{
+		ID: "chunk-app", Name: "Chunk App", Description: "d",
+		URL: "http://x", Icon: "i", Category: "c",
+		LaunchType: LaunchTypeContainer,
+	}
+	if existing, _ := db.GetApp(app.ID); existing == nil {
+		if err := db.CreateApp(app); err != nil {
+			t.Fatalf("CreateApp() error = %v", err)
+		}
+		now := time.Now()
+		session := Session{
+			ID: "chunk-sess", UserID: "user-1", AppID: app.ID,
+			PodName: "pod-1", Status: SessionStatusRunning,
+			CreatedAt: now, UpdatedAt: now,
+		}
+		if err := db.CreateSession(session); err != nil {
+			t.Fatalf("CreateSession() error = %v", err)
+		}
+	}
+	rec := Recording{
+		ID: id, SessionID: "chunk-sess", UserID: "user-1",
+		Filename: id + ".vncrec", Format: "vncrec", StorageBackend: "local",
+		Status: RecordingStatusRecording, CreatedAt: time.Now(),
+	}
+	if err := db.CreateRecording(rec); err != nil {
+		t.Fatalf("CreateRecording() error = %v", err)
+	}
+}
+
+func TestSaveRecordingChunk(t *testing.T) {
+	db := setupTestDB(t)
+	setupChunkedRecording(t, db, "rec-chunks")
+
+	for seq, size := range []int64{100, 200} {
+		chunk := RecordingChunk{RecordingID: "rec-chunks", Seq: seq, StoragePath: "old", SizeBytes: size}
+		if err := db.SaveRecordingChunk(chunk, float64(10*(seq+1))); err != nil {
+			t.Fatalf("SaveRecordingChunk(%d) error = %v", seq, err)
+		}
+	}
+	// A retried upload replaces the earlier one.
+	retry := RecordingChunk{RecordingID: "rec-chunks", Seq: 1, StoragePath: "new", SizeBytes: 250}
+	if err := db.SaveRecordingChunk(retry, 0); err != nil {
+		t.Fatalf("SaveRecordingChunk(retry) error = %v", err)
+	}
+
+	chunks, err := db.ListRecordingChunks("rec-chunks")
+	if err != nil {
+		t.Fatalf("ListRecordingChunks() error = %v", err)
+	}
+	if len(chunks) != 2 || chunks[0].Seq != 0 || chunks[1].StoragePath != "new" {
+		t.Fatalf("chunks = %+v, want seq 0 then the retried seq 1", chunks)
+	}
+
+	rec, err := db.GetRecording("rec-chunks")
+	if err != nil {
+		t.Fatalf("GetRecording() error = %v", err)
+	}
+	if rec.SizeBytes != 350 {
+		t.Errorf("SizeBytes = %d, want 350", rec.SizeBytes)
+	}
+	if rec.DurationSeconds != 20 {
+		t.Errorf("DurationSeconds = %v, want 20 (kept when no duration is given)", rec.DurationSeconds)
+	}
+	if rec.CheckpointAt == nil {
+		t.Error("CheckpointAt not set")
+	}
+
+	all, err := db.ListAllRecordingChunks()
+	if err != nil || len(all) != 2 {
+		t.Errorf("ListAllRecordingChunks() = %d chunks, %v; want 2", len(all), err)
+	}
+
+	if err := db.DeleteRecordingChunks("rec-chunks"); err != nil {
+		t.Fatalf("DeleteRecordingChunks() error = %v", err)
+	}
+	if chunks, _ := db.ListRecordingChunks("rec-chunks"); len(chunks) != 0 {
+		t.Errorf("after delete, %d chunks remain", len(chunks))
+	}
+}
+
+func TestSaveRecordingChunk_UnknownRecording(t *testing.T) {
+	db := setupTestDB(t)
+	err := db.SaveRecordingChunk(RecordingChunk{RecordingID: "missing", StoragePath: "p", SizeBytes: 1}, 0)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("SaveRecordingChunk() error = %v, want sql.ErrNoRows", err)
+	}
+	if chunks, _ := db.ListRecordingChunks("missing"); len(chunks) != 0 {
+		t.Errorf("chunk of unknown recording was kept: %+v", chunks)
+	}
+}
+
+func TestBeginRecordingFinalize(t *testing.T) {
+	db := setupTestDB(t)
+	setupChunkedRecording(t, db, "rec-final")
+
+	ok, err := db.BeginRecordingFinalize("rec-final")
+	if err != nil || !ok {
+		t.Fatalf("BeginRecordingFinalize() = %v, %v; want true", ok, err)
+	}
+	rec, _ := db.GetRecording("rec-final")
+	if rec.Status != RecordingStatusProcessing {
+		t.Errorf("Status = %s, want processing", rec.Status)
+	}
+
+	ok, err = db.BeginRecordingFinalize("rec-final")
+	if err != nil || ok {
+		t.Errorf("second BeginRecordingFinalize() = %v, %v; want false", ok, err)
+	}
+}
+
+func TestListStaleRecordings(t *testing.T) {
+	db := setupTestDB(t)
+	setupChunkedRecording(t, db, "rec-stale")
+	setupChunkedRecording(t, db, "rec-active")
+	setupChunkedRecording(t, db, "rec-done")
+
+	past := time.Now().Add(-time.Hour)
+	if _, err := db.ExecRaw("UPDATE recordings SET created_at = ? WHERE id IN (?, ?, ?)", past, "rec-stale", "rec-active", "rec-done"); err != nil {
+		t.Fatalf("backdate created_at: %v", err)
+	}
+	// A recent chunk keeps a recording active.
+	if err := db.SaveRecordingChunk(RecordingChunk{RecordingID: "rec-active", StoragePath: "p", SizeBytes: 1}, 0); err != nil {
+		t.Fatalf("SaveRecordingChunk() error = %v", err)
+	}
+	if err := db.UpdateRecordingComplete("rec-done", "p", 1, 1); err != nil {
+		t.Fatalf("UpdateRecordingComplete() error = %v", err)
+	}
+
+	stale, err := db.ListStaleRecordings(time.Now().Add(-10 * time.Minute))
+	if err != nil {
+		t.Fatalf("ListStaleRecordings() error = %v", err)
+	}
+	if len(stale) != 1 || stale[0].ID != "rec-stale" {
+		t.Errorf("ListStaleRecordings() = %+v, want only rec-stale", stale)
+	}
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestRefreshTokenStore(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Now().Truncate(time.Second)
+
+	tokens := []RefreshToken{
+		{ID: "rt-1", UserID: "user-1", UserAgent: "Firefox", IPAddress: "10.0.0.1", CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)},
+		{ID: "rt-2", UserID: "user-1", UserAgent: "Chrome", IPAddress: "10.0.0.2", CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{ID: "rt-3", UserID: "user-2", UserAgent: "Safari", IPAddress: "10.0.0.3", CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+	}
+	for _, tok := range tokens {
+		if err := db.CreateRefreshToken(tok); err != nil {
+			t.Fatalf("CreateRefreshToken() error = %v", err)
+		}
+	}
+
+	t.Run("get by ID", func(t *testing.T) {
+		got, err := db.GetRefreshToken("rt-1")
+		if err != nil {
+			t.Fatalf("GetRefreshToken() error = %v", err)
+		}
+		if got == nil || got.UserAgent != "Firefox" || got.IPAddress != "10.0.0.1" {
+			t.Errorf("got %+v, want rt-1 from Firefox at 10.0.0.1", got)
+		}
+
+		missing, err := db.GetRefreshToken("nope")
+		if err != nil {
+			t.Fatalf("GetRefreshToken() error = %v", err)
+		}
+		if missing != nil {
+			t.Errorf("got %+v, want nil", missing)
+		}
+	})
+
+	t.Run("list by user newest first", func(t *testing.T) {
+		got, err := db.ListRefreshTokensByUser("user-1")
+		if err != nil {
+			t.Fatalf("ListRefreshTokensByUser() error = %v", err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("got %d tokens, want 2", len(got))
+		}
+		if got[0].ID != "rt-2" {
+			t.Errorf("got first = %s, want rt-2", got[0].ID)
+		}
+	})
+
+	t.Run("touch records last use", func(t *testing.T) {
+		if err := db.TouchRefreshToken("rt-1", now); err != nil {
+			t.Fatalf("TouchRefreshToken() error = %v", err)
+		}
+		got, _ := db.GetRefreshToken("rt-1")
+		if got.LastUsedAt == nil || !got.LastUsedAt.Equal(now) {
+			t.Errorf("got LastUsedAt = %v, want %v", got.LastUsedAt, now)
+		}
+	})
+
+	t.Run("rotate keeps the old token until the grace period ends", func(t *testing.T) {
+		next := RefreshToken{ID: "rt-2b", UserID: "user-1", UserAgent: "Chrome", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+		grace := now.Add(time.Minute)
+		if err := db.RotateRefreshToken("rt-2", next, grace); err != nil {
+			t.Fatalf("RotateRefreshToken() error = %v", err)
+		}
+		old, _ := db.GetRefreshToken("rt-2")
+		if old == nil || old.ReplacedBy != "rt-2b" || !old.ExpiresAt.Equal(grace) {
+			t.Errorf("got old = %+v, want replaced by rt-2b until %v", old, grace)
+		}
+
+		// A second rotation of the same token loses and inserts nothing
+		if err := db.RotateRefreshToken("rt-2", RefreshToken{ID: "rt-2c", UserID: "user-1", ExpiresAt: now.Add(time.Hour)}, grace); err != sql.ErrNoRows {
+			t.Errorf("got error = %v, want sql.ErrNoRows", err)
+		}
+		if lost, _ := db.GetRefreshToken("rt-2c"); lost != nil {
+			t.Error("losing rotation should not store its token")
+		}
+
+		got, _ := db.ListRefreshTokensByUser("user-1")
+		if len(got) != 2 || got[0].ID != "rt-2b" {
+			t.Errorf("got %+v, want rt-2b and rt-1 (rotated excluded)", got)
+		}
+	})
+
+	t.Run("delete is scoped to owner", func(t *testing.T) {
+		if err := db.DeleteUserRefreshToken("user-2", "rt-1"); err != sql.ErrNoRows {
+			t.Errorf("got error = %v, want sql.ErrNoRows", err)
+		}
+		if err := db.DeleteUserRefreshToken("user-1", "rt-1"); err != nil {
+			t.Fatalf("DeleteUserRefreshToken() error = %v", err)
+		}
+		got, _ := db.GetRefreshToken("rt-1")
+		if got != nil {
+			t.Error("rt-1 should be deleted")
+		}
+	})
+
+	t.Run("expired tokens are hidden and purged", func(t *testing.T) {
+		expired := RefreshToken{ID: "rt-old", UserID: "user-2", CreatedAt: now.Add(-48 * time.Hour), ExpiresAt: now.Add(-24 * time.Hour)}
+		if err := db.CreateRefreshToken(expired); err != nil {
+			t.Fatalf("CreateRefreshToken() error = %v", err)
+		}
+		got, _ := db.ListRefreshTokensByUser("user-2")
+		if len(got) != 1 {
+			t.Errorf("got %d tokens, want 1 (expired excluded)", len(got))
+		}
+
+		// The next issue for the same user purges the expired row
+		if err := db.CreateRefreshToken(RefreshToken{ID: "rt-4", UserID: "user-2", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}); err != nil {
+			t.Fatalf("CreateRefreshToken() error = %v", err)
+		}
+		old, _ := db.GetRefreshToken("rt-old")
+		if old != nil {
+			t.Error("expired token should have been purged")
+		}
+	})
+
+	t.Run("deleting a user revokes their tokens", func(t *testing.T) {
+		if err := db.CreateUser(User{ID: "user-2", Username: "bob", Roles: []string{"user"}}); err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+		if err := db.DeleteUser("user-2"); err != nil {
+			t.Fatalf("DeleteUser() error = %v", err)
+		}
+		got, _ := db.ListRefreshTokensByUser("user-2")
+		if len(got) != 0 {
+			t.Errorf("got %d tokens after user delete, want 0", len(got))
+		}
+	})
+}
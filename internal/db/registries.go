@@ -0,0 +1,138 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// RegistryCredential is an admin-managed login to a container registry. It
+// is synced to an image pull Secret of the same name, which apps and
+// tenants reference through their image_pull_secret.
+type RegistryCredential struct {
+	bun.BaseModel `bun:"table:registry_credentials"`
+
+	// Name identifies the credential and names its Secret.
+	Name     string `json:"name" bun:"name,pk"`
+	Server   string `json:"server" bun:"server,notnull"`
+	Username string `json:"username" bun:"username,notnull"`
+	Email    string `json:"email,omitempty" bun:"email,notnull"`
+	// Password is the plaintext password. It is neither stored nor
+	// returned by the API; callers fill it in from EncryptedPassword.
+	Password string `json:"-" bun:"-"`
+	// EncryptedPassword is the password sealed by the server, base64
+	// encoded.
+	EncryptedPassword string `json:"-" bun:"password,notnull"`
+	// SyncError is why the Secret could not be written when the
+	// credential last changed, or empty if it was.
+	SyncError string     `json:"sync_error,omitempty" bun:"sync_error,notnull"`
+	SyncedAt  *time.Time `json:"synced_at,omitempty" bun:"synced_at"`
+	CreatedBy string     `json:"created_by" bun:"created_by,notnull"`
+	CreatedAt time.Time  `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	UpdatedAt time.Time  `json:"updated_at" bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// CreateRegistryCredential inserts a new registry credential.
+func (db *DB) CreateRegistryCredential(cred RegistryCredential) error {
+	now := time.Now()
+	cred.CreatedAt = now
+	cred.UpdatedAt = now
+	_, err := db.conn.NewInsert().Model(&cred).Exec(db.ctx())
+	return err
+}
+
+// GetRegistryCredential returns a registry credential by name, or nil if
+// it does not exist.
+func (db *DB) GetRegistryCredential(name string) (*RegistryCredential, error) {
+	var cred RegistryCredential
+	err := db.conn.NewSelect().Model(&cred).Where("name = ?", name).Scan(db.ctx())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cred, nil
+}
+
+// ListRegistryCredentials returns all registry credentials ordered by name.
+func (db *DB) ListRegistryCredentials() ([]RegistryCredential, error) {
+	var creds []RegistryCredential
+	err := db.conn.NewSelect().Model(&creds).OrderExpr("name").Scan(db.ctx())
+	return creds, err
+}
+
+// UpdateRegistryCredential updates a registry credential's server, login,
+// and encrypted password.
+func (db *DB) UpdateRegistryCredential(cred RegistryCredential) error {
+	cred.UpdatedAt = time.Now()
+	result, err := db.conn.NewUpdate().Model(&cred).
+		Column("server", "username", "email", "password", "updated_at").
+		WherePK().
+		Exec(db.ctx())
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetRegistryCredentialSync records the outcome of writing a credential's
+// Secret: the time it was written, or the error that prevented it.
+func (db *DB) SetRegistryCredentialSync(name string, syncErr error) error {
+	q := db.conn.NewUpdate().Model((*RegistryCredential)(nil)).Where("name = ?", name)
+	if syncErr != nil {
+		q = q.Set("sync_error = ?", syncErr.Error())
+	} else {
+		q = q.Set("sync_error = ''").Set("synced_at = ?", time.Now())
+	}
+	_, err := q.Exec(db.ctx())
+	return err
+}
+
+// DeleteRegistryCredential removes a registry credential by name.
+func (db *DB) DeleteRegistryCredential(name string) error {
+	result, err := db.conn.NewDelete().Model((*RegistryCredential)(nil)).Where("name = ?", name).Exec(db.ctx())
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RegistryCredentialUsers returns the IDs of the apps and tenants that
+// pull images with the named credential's Secret.
+func (db *DB) RegistryCredentialUsers(name string) (appIDs, tenantIDs []string, err error) {
+	err = db.conn.NewSelect().Model((*Application)(nil)).
+		Column("id").
+		Where("image_pull_secret = ?", name).
+		OrderExpr("id").
+		Scan(db.ctx(), &appIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tenants, err := db.ListTenants()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, tenant := range tenants {
+		if tenant.Settings.ImagePullSecret == name {
+			tenantIDs = append(tenantIDs, tenant.ID)
+		}
+	}
+	return appIDs, tenantIDs, nil
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestRegistryCredentialCRUD(t *testing.T) {
+	db := setupTenantTestDB(t)
+
+	got, err := db.GetRegistryCredential("ghcr")
+	if err != nil || got != nil {
+		t.Fatalf("GetRegistryCredential() = %v, %v; want nil, nil", got, err)
+	}
+
+	cred := RegistryCredential{
+		Name:              "ghcr",
+		Server:            "ghcr.io",
+		Username:          "bot",
+		EncryptedPassword: "c2VhbGVk",
+		CreatedBy:         "admin",
+	}
+	if err := db.CreateRegistryCredential(cred); err != nil {
+		t.Fatalf("CreateRegistryCredential() error = %v", err)
+	}
+	if err := db.CreateRegistryCredential(cred); !IsDuplicateKeyError(err) {
+		t.Errorf("CreateRegistryCredential() duplicate error = %v, want duplicate key", err)
+	}
+
+	got, err = db.GetRegistryCredential("ghcr")
+	if err != nil || got == nil {
+		t.Fatalf("GetRegistryCredential() = %v, %v", got, err)
+	}
+	if got.Server != "ghcr.io" || got.Username != "bot" || got.EncryptedPassword != "c2VhbGVk" || got.SyncedAt != nil {
+		t.Errorf("GetRegistryCredential() = %+v", got)
+	}
+
+	got.Username = "robot"
+	got.Email = "robot@example.com"
+	got.EncryptedPassword = "bmV3"
+	if err := db.UpdateRegistryCredential(*got); err != nil {
+		t.Fatalf("UpdateRegistryCredential() error = %v", err)
+	}
+	got, _ = db.GetRegistryCredential("ghcr")
+	if got.Username != "robot" || got.Email != "robot@example.com" || got.EncryptedPassword != "bmV3" {
+		t.Errorf("after update = %+v", got)
+	}
+	if err := db.UpdateRegistryCredential(RegistryCredential{Name: "missing"}); err != sql.ErrNoRows {
+		t.Errorf("UpdateRegistryCredential(missing) error = %v, want sql.ErrNoRows", err)
+	}
+
+	if err := db.SetRegistryCredentialSync("ghcr", errors.New("forbidden")); err != nil {
+		t.Fatalf("SetRegistryCredentialSync() error = %v", err)
+	}
+	got, _ = db.GetRegistryCredential("ghcr")
+	if got.SyncError != "forbidden" || got.SyncedAt != nil {
+		t.Errorf("after failed sync = %+v", got)
+	}
+	if err := db.SetRegistryCredentialSync("ghcr", nil); err != nil {
+		t.Fatalf("SetRegistryCredentialSync() error = %v", err)
+	}
+	got, _ = db.GetRegistryCredential("ghcr")
+	if got.SyncError != "" || got.SyncedAt == nil {
+		t.Errorf("after sync = %+v", got)
+	}
+
+	all, err := db.ListRegistryCredentials()
+	if err != nil || len(all) != 1 {
+		t.Errorf("ListRegistryCredentials() = %v, %v; want 1 entry", all, err)
+	}
+
+	if err := db.DeleteRegistryCredential("ghcr"); err != nil {
+		t.Fatalf("DeleteRegistryCredential() error = %v", err)
+	}
+	if err := db.DeleteRegistryCredential("ghcr"); err != sql.ErrNoRows {
+		t.Errorf("DeleteRegistryCredential(missing) error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestRegistryCredentialUsers(t *testing.T) {
+	db := setupTenantTestDB(t)
+
+	if err := db.CreateApp(Application{ID: "app1", Name: "App", URL: "https://example.com", ImagePullSecret: "ghcr"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CreateApp(Application{ID: "app2", Name: "Other", URL: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CreateTenant(Tenant{ID: "t1", Name: "Test", Slug: "test", Settings: TenantSettings{ImagePullSecret: "ghcr"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	appIDs, tenantIDs, err := db.RegistryCredentialUsers("ghcr")
+	if err != nil {
+		t.Fatalf("RegistryCredentialUsers() error = %v", err)
+	}
+	if len(appIDs) != 1 || appIDs[0] != "app1" || len(tenantIDs) != 1 || tenantIDs[0] != "t1" {
+		t.Errorf("RegistryCredentialUsers() = %v, %v; want [app1], [t1]", appIDs, tenantIDs)
+	}
+
+	appIDs, tenantIDs, err = db.RegistryCredentialUsers("quay")
+	if err != nil || len(appIDs) != 0 || len(tenantIDs) != 0 {
+		t.Errorf("RegistryCredentialUsers(unused) = %v, %v, %v", appIDs, tenantIDs, err)
+	}
+}
@@ -0,0 +1,213 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// How a catalog sync treats a template that was changed locally.
+const (
+	// CatalogConflictKeepLocal keeps the local template and reports the
+	// conflict.
+	CatalogConflictKeepLocal = "keep_local"
+	// CatalogConflictOverwrite replaces the local template with the
+	// catalog's.
+	CatalogConflictOverwrite = "overwrite"
+)
+
+// Outcomes of a catalog sync.
+const (
+	CatalogSyncOK          = "ok"
+	CatalogSyncNotModified = "not_modified"
+	CatalogSyncError       = "error"
+)
+
+// RemoteCatalog is a signed template catalog at a URL that the template
+// marketplace is synced from.
+type RemoteCatalog struct {
+	bun.BaseModel `bun:"table:remote_catalogs"`
+
+	// Name identifies the catalog. Templates synced from it record it as
+	// their catalog.
+	Name string `json:"name" bun:"name,pk"`
+	URL  string `json:"url" bun:"url,notnull"`
+	// PublicKey is the PEM-encoded Ed25519 key the catalog must be signed
+	// with.
+	PublicKey string `json:"public_key" bun:"public_key,notnull"`
+	// OnConflict is CatalogConflictKeepLocal or CatalogConflictOverwrite.
+	OnConflict string `json:"on_conflict" bun:"on_conflict,notnull"`
+	// ETag is the entity tag of the catalog as last synced, sent so an
+	// unchanged catalog is not downloaded again.
+	ETag string `json:"etag,omitempty" bun:"etag,notnull"`
+	// SyncStatus is the outcome of the last sync, one of the CatalogSync
+	// constants, or empty if the catalog has not been synced.
+	SyncStatus string `json:"sync_status,omitempty" bun:"sync_status,notnull"`
+	SyncError  string `json:"sync_error,omitempty" bun:"sync_error,notnull"`
+	// SyncResult is what the last sync that downloaded the catalog did.
+	SyncResult *CatalogSyncResult `json:"sync_result,omitempty" bun:"-"`
+	SyncedAt   *time.Time         `json:"synced_at,omitempty" bun:"synced_at"`
+	CreatedBy  string             `json:"created_by" bun:"created_by,notnull"`
+	CreatedAt  time.Time          `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	UpdatedAt  time.Time          `json:"updated_at" bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+
+	// JSON-serialized DB columns
+	SyncResultJSON string `json:"-" bun:"sync_result"`
+}
+
+// CatalogSyncResult lists the templates a catalog sync changed, by
+// template_id, and those it left alone because of a conflict.
+type CatalogSyncResult struct {
+	Created   []string          `json:"created"`
+	Updated   []string          `json:"updated"`
+	Removed   []string          `json:"removed"`
+	Conflicts []CatalogConflict `json:"conflicts"`
+}
+
+// CatalogConflict is a template that a catalog sync could not change
+// without losing a local edit or taking over another template.
+type CatalogConflict struct {
+	TemplateID string `json:"template_id"`
+	Reason     string `json:"reason"`
+	// Overwritten is true if the catalog's template replaced the local one
+	// anyway, as CatalogConflictOverwrite asks.
+	Overwritten bool `json:"overwritten,omitempty"`
+}
+
+// remoteCatalogNamePattern matches catalog names.
+var remoteCatalogNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// Validate checks the catalog's name, that its URL is http or https, and
+// its conflict handling. An empty OnConflict is set to
+// CatalogConflictKeepLocal. The public key is checked by the caller.
+func (c *RemoteCatalog) Validate() error {
+	if !remoteCatalogNamePattern.MatchString(c.Name) {
+		return fmt.Errorf("name %q must be lowercase letters, digits and hyphens", c.Name)
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http or https URL")
+	}
+	switch c.OnConflict {
+	case "":
+		c.OnConflict = CatalogConflictKeepLocal
+	case CatalogConflictKeepLocal, CatalogConflictOverwrite:
+	default:
+		return fmt.Errorf("on_conflict must be %s or %s", CatalogConflictKeepLocal, CatalogConflictOverwrite)
+	}
+	return nil
+}
+
+// CreateRemoteCatalog inserts a new remote catalog.
+func (db *DB) CreateRemoteCatalog(c RemoteCatalog) error {
+	now := time.Now()
+	c.CreatedAt = now
+	c.UpdatedAt = now
+	_, err := db.conn.NewInsert().Model(&c).Exec(db.ctx())
+	return err
+}
+
+// GetRemoteCatalog returns a remote catalog by name, or nil if it does not
+// exist.
+func (db *DB) GetRemoteCatalog(name string) (*RemoteCatalog, error) {
+	var c RemoteCatalog
+	err := db.conn.NewSelect().Model(&c).Where("name = ?", name).Scan(db.ctx())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ListRemoteCatalogs returns all remote catalogs ordered by name.
+func (db *DB) ListRemoteCatalogs() ([]RemoteCatalog, error) {
+	var catalogs []RemoteCatalog
+	err := db.conn.NewSelect().Model(&catalogs).OrderExpr("name").Scan(db.ctx())
+	return catalogs, err
+}
+
+// UpdateRemoteCatalog updates a remote catalog's URL, key and conflict
+// handling. Its ETag is cleared, so the next sync downloads the catalog
+// and applies it under the new settings.
+func (db *DB) UpdateRemoteCatalog(c RemoteCatalog) error {
+	c.ETag = ""
+	c.UpdatedAt = time.Now()
+	result, err := db.conn.NewUpdate().Model(&c).
+		Column("url", "public_key", "on_conflict", "etag", "updated_at").
+		WherePK().
+		Exec(db.ctx())
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetRemoteCatalogSync records the outcome of syncing a catalog: its
+// ETag, sync status, error, result and time.
+func (db *DB) SetRemoteCatalogSync(c RemoteCatalog) error {
+	_, err := db.conn.NewUpdate().Model(&c).
+		Column("etag", "sync_status", "sync_error", "sync_result", "synced_at").
+		WherePK().
+		Exec(db.ctx())
+	return err
+}
+
+// DeleteRemoteCatalog removes a remote catalog by name. Templates synced
+// from it are kept as local templates.
+func (db *DB) DeleteRemoteCatalog(name string) error {
+	return db.runInTx(func(ctx context.Context, tx bun.Tx) error {
+		result, err := tx.NewDelete().Model((*RemoteCatalog)(nil)).Where("name = ?", name).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return sql.ErrNoRows
+		}
+		_, err = tx.NewUpdate().Model((*Template)(nil)).
+			Set("catalog = ''").
+			Set("catalog_hash = ''").
+			Where("catalog = ?", name).
+			Exec(ctx)
+		return err
+	})
+}
+
+// SaveCatalogTemplate creates or replaces a template synced from a remote
+// catalog, including its catalog and catalog hash, which UpdateTemplate
+// leaves alone.
+func (db *DB) SaveCatalogTemplate(t Template) error {
+	t.UpdatedAt = time.Now()
+	result, err := db.conn.NewUpdate().Model(&t).
+		ExcludeColumn("id", "created_at").
+		Where("template_id = ?", t.TemplateID).
+		Exec(db.ctx())
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows > 0 {
+		return nil
+	}
+	return db.CreateTemplate(t)
+}
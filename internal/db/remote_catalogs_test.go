@@ -0,0 +1,126 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestRemoteCatalogCRUD(t *testing.T) {
+	db := setupTenantTestDB(t)
+
+	got, err := db.GetRemoteCatalog("vendor")
+	if err != nil || got != nil {
+		t.Fatalf("GetRemoteCatalog() = %v, %v; want nil, nil", got, err)
+	}
+
+	catalog := RemoteCatalog{
+		Name:       "vendor",
+		URL:        "https://catalog.example.com/templates.json",
+		PublicKey:  "-----BEGIN PUBLIC KEY-----",
+		OnConflict: CatalogConflictKeepLocal,
+		CreatedBy:  "admin",
+	}
+	if err := db.CreateRemoteCatalog(catalog); err != nil {
+		t.Fatalf("CreateRemoteCatalog() error = %v", err)
+	}
+	if err := db.CreateRemoteCatalog(catalog); !IsDuplicateKeyError(err) {
+		t.Errorf("CreateRemoteCatalog() duplicate error = %v, want duplicate key", err)
+	}
+
+	got, err = db.GetRemoteCatalog("vendor")
+	if err != nil || got == nil {
+		t.Fatalf("GetRemoteCatalog() = %v, %v", got, err)
+	}
+	if got.URL != catalog.URL || got.OnConflict != CatalogConflictKeepLocal || got.SyncedAt != nil || got.SyncResult != nil {
+		t.Errorf("GetRemoteCatalog() = %+v", got)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	got.ETag = `"v1"`
+	got.SyncStatus = CatalogSyncOK
+	got.SyncedAt = &now
+	got.SyncResult = &CatalogSyncResult{Created: []string{"ide"}, Conflicts: []CatalogConflict{{TemplateID: "lab", Reason: "edited"}}}
+	if err := db.SetRemoteCatalogSync(*got); err != nil {
+		t.Fatalf("SetRemoteCatalogSync() error = %v", err)
+	}
+	got, _ = db.GetRemoteCatalog("vendor")
+	if got.ETag != `"v1"` || got.SyncStatus != CatalogSyncOK || got.SyncedAt == nil || !got.SyncedAt.Equal(now) {
+		t.Errorf("after sync = %+v", got)
+	}
+	if r := got.SyncResult; r == nil || len(r.Created) != 1 || len(r.Conflicts) != 1 || r.Conflicts[0].TemplateID != "lab" {
+		t.Errorf("SyncResult = %+v", got.SyncResult)
+	}
+
+	got.OnConflict = CatalogConflictOverwrite
+	if err := db.UpdateRemoteCatalog(*got); err != nil {
+		t.Fatalf("UpdateRemoteCatalog() error = %v", err)
+	}
+	got, _ = db.GetRemoteCatalog("vendor")
+	if got.OnConflict != CatalogConflictOverwrite || got.ETag != "" || got.SyncResult == nil {
+		t.Errorf("after update = %+v; want the ETag cleared and the result kept", got)
+	}
+	if err := db.UpdateRemoteCatalog(RemoteCatalog{Name: "missing"}); err != sql.ErrNoRows {
+		t.Errorf("UpdateRemoteCatalog(missing) error = %v, want sql.ErrNoRows", err)
+	}
+
+	// Synced templates are saved with their catalog, which a local update
+	// keeps, and become local when the catalog is deleted
+	if err := db.SaveCatalogTemplate(Template{TemplateID: "ide", Name: "IDE", Catalog: "vendor", CatalogHash: "h1"}); err != nil {
+		t.Fatalf("SaveCatalogTemplate() create error = %v", err)
+	}
+	if err := db.SaveCatalogTemplate(Template{TemplateID: "ide", Name: "IDE 2", Catalog: "vendor", CatalogHash: "h2"}); err != nil {
+		t.Fatalf("SaveCatalogTemplate() update error = %v", err)
+	}
+	tmpl, _ := db.GetTemplate("ide")
+	if tmpl.Name != "IDE 2" || tmpl.Catalog != "vendor" || tmpl.CatalogHash != "h2" {
+		t.Errorf("after SaveCatalogTemplate = %+v", tmpl)
+	}
+	tmpl.Name = "Our IDE"
+	if err := db.UpdateTemplate(*tmpl); err != nil {
+		t.Fatal(err)
+	}
+	if tmpl, _ = db.GetTemplate("ide"); tmpl.Catalog != "vendor" || tmpl.CatalogHash != "h2" {
+		t.Errorf("UpdateTemplate changed the catalog: %+v", tmpl)
+	}
+
+	list, err := db.ListRemoteCatalogs()
+	if err != nil || len(list) != 1 {
+		t.Fatalf("ListRemoteCatalogs() = %+v, %v", list, err)
+	}
+
+	if err := db.DeleteRemoteCatalog("vendor"); err != nil {
+		t.Fatalf("DeleteRemoteCatalog() error = %v", err)
+	}
+	if err := db.DeleteRemoteCatalog("vendor"); err != sql.ErrNoRows {
+		t.Errorf("DeleteRemoteCatalog() again error = %v, want sql.ErrNoRows", err)
+	}
+	if tmpl, _ = db.GetTemplate("ide"); tmpl == nil || tmpl.Catalog != "" || tmpl.CatalogHash != "" {
+		t.Errorf("template after deleting its catalog = %+v", tmpl)
+	}
+}
+
+func TestRemoteCatalogValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		catalog RemoteCatalog
+		wantErr bool
+	}{
+		{"valid", RemoteCatalog{Name: "vendor-1", URL: "https://example.com/c.json"}, false},
+		{"bad name", RemoteCatalog{Name: "Vendor", URL: "https://example.com/c.json"}, true},
+		{"empty name", RemoteCatalog{URL: "https://example.com/c.json"}, true},
+		{"bad scheme", RemoteCatalog{Name: "vendor", URL: "file:///etc/passwd"}, true},
+		{"bad conflict", RemoteCatalog{Name: "vendor", URL: "http://example.com", OnConflict: "merge"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.catalog.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && tt.catalog.OnConflict != CatalogConflictKeepLocal {
+				t.Errorf("OnConflict = %q, want the keep_local default", tt.catalog.OnConflict)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// ScheduledSession is a session launch scheduled ahead of time, such as a
+// lab environment started before a class. One-off schedules launch at
+// StartAt; recurring ones at each time of their cron Recurrence, in
+// Timezone, from StartAt until EndsAt. NextRunAt is nil once a schedule
+// has no launches left.
+type ScheduledSession struct {
+	bun.BaseModel `bun:"table:scheduled_sessions"`
+
+	ID            string     `json:"id" bun:"id,pk"`
+	UserID        string     `json:"user_id" bun:"user_id,notnull"`
+	CreatedBy     string     `json:"created_by,omitempty" bun:"created_by,notnull"`
+	AppID         string     `json:"app_id" bun:"app_id,notnull"`
+	TenantID      string     `json:"tenant_id,omitempty" bun:"tenant_id,notnull"`
+	StartAt       time.Time  `json:"start_at" bun:"start_at,notnull"`
+	Recurrence    string     `json:"recurrence,omitempty" bun:"recurrence,notnull"`
+	Timezone      string     `json:"timezone" bun:"timezone,notnull"`
+	EndsAt        *time.Time `json:"ends_at,omitempty" bun:"ends_at"`
+	NextRunAt     *time.Time `json:"next_run_at,omitempty" bun:"next_run_at"`
+	LastRunAt     *time.Time `json:"last_run_at,omitempty" bun:"last_run_at"`
+	LastSessionID string     `json:"last_session_id,omitempty" bun:"last_session_id,notnull"`
+	LastError     string     `json:"last_error,omitempty" bun:"last_error,notnull"`
+	CreatedAt     time.Time  `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// CreateScheduledSession inserts a new scheduled session.
+func (db *DB) CreateScheduledSession(s ScheduledSession) error {
+	if s.TenantID == "" {
+		s.TenantID = DefaultTenantID
+	}
+	if s.Timezone == "" {
+		s.Timezone = "UTC"
+	}
+	_, err := db.conn.NewInsert().Model(&s).Exec(db.ctx())
+	return err
+}
+
+// GetScheduledSession returns a scheduled session by ID, or nil if it does
+// not exist.
+func (db *DB) GetScheduledSession(id string) (*ScheduledSession, error) {
+	var s ScheduledSession
+	err := db.conn.NewSelect().Model(&s).Where("id = ?", id).Scan(db.ctx())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ListScheduledSessions returns the scheduled sessions of a user, or of
+// every user if userID is empty, soonest first. Finished schedules come
+// last.
+func (db *DB) ListScheduledSessions(userID string) ([]ScheduledSession, error) {
+	schedules := []ScheduledSession{}
+	q := db.conn.NewSelect().Model(&schedules)
+	if userID != "" {
+		q = q.Where("user_id = ?", userID)
+	}
+	err := q.OrderExpr("next_run_at IS NULL, next_run_at ASC, created_at ASC").
+		Scan(db.ctx())
+	return schedules, err
+}
+
+// CountScheduledSessions returns the number of a user's schedules that
+// still have launches left.
+func (db *DB) CountScheduledSessions(userID string) (int, error) {
+	return db.conn.NewSelect().Model((*ScheduledSession)(nil)).
+		Where("user_id = ?", userID).
+		Where("next_run_at IS NOT NULL").
+		Count(db.ctx())
+}
+
+// ListDueScheduledSessions returns schedules whose next launch is at or
+// before now, most overdue first.
+func (db *DB) ListDueScheduledSessions(now time.Time) ([]ScheduledSession, error) {
+	schedules := []ScheduledSession{}
+	err := db.conn.NewSelect().Model(&schedules).
+		Where("next_run_at <= ?", now).
+		OrderExpr("next_run_at ASC").
+		Scan(db.ctx())
+	return schedules, err
+}
+
+// ClaimScheduledSession moves a schedule's next launch from due to next,
+// or finishes it if next is nil. It returns false if the schedule's next
+// launch is no longer due, because it was claimed or deleted meanwhile.
+func (db *DB) ClaimScheduledSession(id string, due time.Time, next *time.Time) (bool, error) {
+	res, err := db.conn.NewUpdate().Model((*ScheduledSession)(nil)).
+		Set("next_run_at = ?", next).
+		Where("id = ?", id).
+		Where("next_run_at = ?", due).
+		Exec(db.ctx())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// RecordScheduledSessionRun records the outcome of a launch: the session it
+// created, or why it failed.
+func (db *DB) RecordScheduledSessionRun(id string, ranAt time.Time, sessionID, errMsg string) error {
+	_, err := db.conn.NewUpdate().Model((*ScheduledSession)(nil)).
+		Set("last_run_at = ?", ranAt).
+		Set("last_session_id = ?", sessionID).
+		Set("last_error = ?", errMsg).
+		Where("id = ?", id).
+		Exec(db.ctx())
+	return err
+}
+
+// DeleteScheduledSession deletes a scheduled session. Sessions it already
+// launched are left running.
+func (db *DB) DeleteScheduledSession(id string) error {
+	result, err := db.conn.NewDelete().Model((*ScheduledSession)(nil)).Where("id = ?", id).Exec(db.ctx())
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteScheduledSessionsByUser deletes all of a user's schedules.
+func (db *DB) DeleteScheduledSessionsByUser(userID string) error {
+	_, err := db.conn.NewDelete().Model((*ScheduledSession)(nil)).Where("user_id = ?", userID).Exec(db.ctx())
+	return err
+}
+
+// DeleteScheduledSessionsByApp deletes all schedules for an app.
+func (db *DB) DeleteScheduledSessionsByApp(appID string) error {
+	_, err := db.conn.NewDelete().Model((*ScheduledSession)(nil)).Where("app_id = ?", appID).Exec(db.ctx())
+	return err
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestScheduledSessions(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	for _, s := range []ScheduledSession{
+		{ID: "due", UserID: "u1", AppID: "app-a", StartAt: now.Add(-time.Minute), NextRunAt: at(-time.Minute)},
+		{ID: "later", UserID: "u1", AppID: "app-b", StartAt: now.Add(time.Hour), Recurrence: "0 8 * * 1-5", NextRunAt: at(time.Hour)},
+		{ID: "done", UserID: "u1", AppID: "app-a", StartAt: now.Add(-time.Hour)},
+		{ID: "other", UserID: "u2", AppID: "app-a", StartAt: now.Add(-2 * time.Minute), NextRunAt: at(-2 * time.Minute)},
+	} {
+		if err := db.CreateScheduledSession(s); err != nil {
+			t.Fatalf("CreateScheduledSession(%s) error = %v", s.ID, err)
+		}
+	}
+
+	got, err := db.GetScheduledSession("later")
+	if err != nil || got == nil {
+		t.Fatalf("GetScheduledSession() = %v, %v", got, err)
+	}
+	if got.Recurrence != "0 8 * * 1-5" || got.Timezone != "UTC" || got.TenantID != DefaultTenantID {
+		t.Errorf("GetScheduledSession() = %+v", got)
+	}
+	if missing, err := db.GetScheduledSession("missing"); missing != nil || err != nil {
+		t.Errorf("GetScheduledSession(missing) = %v, %v, want nil", missing, err)
+	}
+
+	list, _ := db.ListScheduledSessions("u1")
+	if len(list) != 3 || list[0].ID != "due" || list[1].ID != "later" || list[2].ID != "done" {
+		t.Errorf("ListScheduledSessions(u1) = %+v, want due, later, done", list)
+	}
+	if n, _ := db.CountScheduledSessions("u1"); n != 2 {
+		t.Errorf("CountScheduledSessions(u1) = %d, want 2", n)
+	}
+
+	due, err := db.ListDueScheduledSessions(now)
+	if err != nil {
+		t.Fatalf("ListDueScheduledSessions() error = %v", err)
+	}
+	if len(due) != 2 || due[0].ID != "other" || due[1].ID != "due" {
+		t.Fatalf("ListDueScheduledSessions() = %+v, want other, due", due)
+	}
+
+	// Only the first claim of a due launch succeeds
+	if ok, err := db.ClaimScheduledSession("due", *due[1].NextRunAt, nil); !ok || err != nil {
+		t.Fatalf("ClaimScheduledSession() = %v, %v, want true", ok, err)
+	}
+	if ok, _ := db.ClaimScheduledSession("due", *due[1].NextRunAt, nil); ok {
+		t.Error("second ClaimScheduledSession() = true, want false")
+	}
+	if err := db.RecordScheduledSessionRun("due", now, "sess-1", ""); err != nil {
+		t.Fatalf("RecordScheduledSessionRun() error = %v", err)
+	}
+	got, _ = db.GetScheduledSession("due")
+	if got.NextRunAt != nil || got.LastRunAt == nil || got.LastSessionID != "sess-1" {
+		t.Errorf("after run = %+v", got)
+	}
+
+	if err := db.DeleteScheduledSession("later"); err != nil {
+		t.Fatalf("DeleteScheduledSession() error = %v", err)
+	}
+	if err := db.DeleteScheduledSession("later"); err != sql.ErrNoRows {
+		t.Errorf("DeleteScheduledSession(deleted) error = %v, want sql.ErrNoRows", err)
+	}
+	if err := db.DeleteScheduledSessionsByApp("app-a"); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := db.ListScheduledSessions(""); len(list) != 0 {
+		t.Errorf("after deleting app-a schedules: %+v", list)
+	}
+}
@@ -24,7 +24,25 @@ func TestPostgresSchema_AllTablesExist(t *testing.T) {
 		"users", "settings", "templates", "app_specs",
 		"oidc_states", "tenants", "categories",
 		"category_admins", "category_approved_users",
-		"recordings", "session_shares",
+		"recordings", "session_shares", "tenant_branding", "sidecar_templates",
+		"session_attestations", "quota_bursts", "session_archive", "session_history", "leader_leases", "idp_tokens",
+		"recording_chunks",
+		"session_file_events",
+		"api_tokens",
+		"user_mfa", "mfa_backup_codes",
+		"webauthn_credentials", "webauthn_challenges",
+		"password_reset_tokens",
+		"health_events",
+		"job_runs",
+		"session_launches",
+		"scheduled_sessions",
+		"api_usage",
+		"projects", "project_members",
+		"status_announcements",
+		"registry_credentials",
+		"lint_warnings",
+		"remote_catalogs",
+		"setting_change_requests",
 		"schema_migrations",
 	}
 
@@ -46,7 +64,7 @@ func TestPostgresSchema_AllTablesExist(t *testing.T) {
 }
 
 // TestPostgresSchema_ApplicationsColumns verifies the applications table has
-// all 18 expected columns with correct Postgres types and defaults.
+// all 20 expected columns with correct Postgres types and defaults.
 func TestPostgresSchema_ApplicationsColumns(t *testing.T) {
 	if testDBType() != "postgres" {
 		t.Skip("Postgres-specific schema test")
@@ -93,6 +111,7 @@ func TestPostgresSchema_ApplicationsColumns(t *testing.T) {
 		"visibility", "launch_type", "os_type", "container_image",
 		"container_port", "container_args", "cpu_request", "cpu_limit",
 		"memory_request", "memory_limit", "egress_policy", "tenant_id",
+		"audit_session_activity", "welcome_message",
 	}
 	for _, name := range expectedCols {
 		if _, ok := cols[name]; !ok {
@@ -100,8 +119,8 @@ func TestPostgresSchema_ApplicationsColumns(t *testing.T) {
 		}
 	}
 
-	if len(cols) != 18 {
-		t.Errorf("applications table has %d columns, want 18", len(cols))
+	if len(cols) != 20 {
+		t.Errorf("applications table has %d columns, want 20", len(cols))
 	}
 
 	// Verify specific column properties
@@ -166,21 +185,49 @@ func TestPostgresSchema_AllTableColumnCounts(t *testing.T) {
 
 	// Same expected column counts as SQLite tests
 	expectedColumnCounts := map[string]int{
-		"applications":            18,
-		"audit_log":               6,
+		"applications":            44,
+		"audit_log":               7,
 		"analytics":               4,
-		"sessions":                10,
-		"users":                   12,
+		"sessions":                19,
+		"users":                   15,
 		"settings":                3,
-		"templates":               23,
-		"app_specs":               16,
+		"templates":               25,
+		"app_specs":               23,
 		"oidc_states":             3,
 		"tenants":                 7,
-		"categories":              6,
+		"categories":              7,
 		"category_admins":         2,
 		"category_approved_users": 2,
-		"recordings":              14,
+		"recordings":              15,
+		"recording_chunks":        5,
+		"session_file_events":     9,
 		"session_shares":          7,
+		"tenant_branding":         4,
+		"sidecar_templates":       6,
+		"session_attestations":    6,
+		"quota_bursts":            4,
+		"session_archive":         10,
+		"session_history":         19,
+		"leader_leases":           4,
+		"idp_tokens":              4,
+		"api_tokens":              9,
+		"user_mfa":                5,
+		"mfa_backup_codes":        4,
+		"webauthn_credentials":    7,
+		"webauthn_challenges":     3,
+		"password_reset_tokens":   4,
+		"health_events":           6,
+		"job_runs":                11,
+		"session_launches":        9,
+		"scheduled_sessions":      14,
+		"api_usage":               7,
+		"projects":                9,
+		"project_members":         4,
+		"status_announcements":    9,
+		"registry_credentials":    10,
+		"lint_warnings":           10,
+		"remote_catalogs":         12,
+		"setting_change_requests": 10,
 	}
 
 	for table, expected := range expectedColumnCounts {
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// ArchivedSession is an ended session moved out of the sessions table by
+// session retention.
+type ArchivedSession struct {
+	bun.BaseModel `bun:"table:session_archive"`
+
+	ID          string        `json:"id" bun:"id,pk"`
+	UserID      string        `json:"user_id" bun:"user_id,notnull"`
+	AppID       string        `json:"app_id" bun:"app_id,notnull"`
+	PodName     string        `json:"pod_name" bun:"pod_name"`
+	Status      SessionStatus `json:"status" bun:"status,notnull"`
+	IdleTimeout int64         `json:"idle_timeout,omitempty" bun:"idle_timeout"`
+	TenantID    string        `json:"tenant_id,omitempty" bun:"tenant_id"`
+	CreatedAt   time.Time     `json:"created_at" bun:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at" bun:"updated_at"`
+	ArchivedAt  time.Time     `json:"archived_at" bun:"archived_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// ArchiveSessions moves up to limit ended sessions (stopped, failed or
+// expired) last updated before olderThan into the session archive, and
+// deletes their shares. Sessions that still have recordings are kept until
+// recording retention removes them. It returns the number of sessions
+// archived.
+func (db *DB) ArchiveSessions(olderThan time.Time, limit int) (int, error) {
+	var archived int
+	err := db.runInTx(func(txCtx context.Context, tx bun.Tx) error {
+		var ended []Session
+		err := tx.NewSelect().Model(&ended).
+			Where("status IN ('stopped', 'failed', 'expired', 'terminated')").
+			Where("updated_at < ?", olderThan).
+			Where("NOT EXISTS (SELECT 1 FROM recordings r WHERE r.session_id = ?TableAlias.id)").
+			OrderExpr("updated_at ASC").
+			Limit(limit).
+			Scan(txCtx)
+		if err != nil || len(ended) == 0 {
+			return err
+		}
+
+		now := time.Now()
+		ids := make([]string, len(ended))
+		rows := make([]ArchivedSession, len(ended))
+		for i, s := range ended {
+			ids[i] = s.ID
+			rows[i] = ArchivedSession{
+				ID:          s.ID,
+				UserID:      s.UserID,
+				AppID:       s.AppID,
+				PodName:     s.PodName,
+				Status:      s.Status,
+				IdleTimeout: s.IdleTimeout,
+				TenantID:    s.TenantID,
+				CreatedAt:   s.CreatedAt,
+				UpdatedAt:   s.UpdatedAt,
+				ArchivedAt:  now,
+			}
+		}
+
+		if _, err := tx.NewInsert().Model(&rows).On("CONFLICT (id) DO NOTHING").Exec(txCtx); err != nil {
+			return err
+		}
+		if _, err := tx.NewDelete().Model((*SessionShare)(nil)).Where("session_id IN (?)", bun.In(ids)).Exec(txCtx); err != nil {
+			return err
+		}
+		if _, err := tx.NewDelete().Model((*Session)(nil)).Where("id IN (?)", bun.In(ids)).Exec(txCtx); err != nil {
+			return err
+		}
+		archived = len(ended)
+		return nil
+	})
+	return archived, err
+}
+
+// ListArchivedSessions returns archived sessions, most recently ended
+// first, optionally only those of one user.
+func (db *DB) ListArchivedSessions(userID string, limit int) ([]ArchivedSession, error) {
+	var sessions []ArchivedSession
+	q := db.conn.NewSelect().Model(&sessions)
+	if userID != "" {
+		q = q.Where("user_id = ?", userID)
+	}
+	err := q.OrderExpr("updated_at DESC").Limit(limit).Scan(db.ctx())
+	return sessions, err
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestArchiveSessions(t *testing.T) {
+	db := setupTestDB(t)
+
+	app := Application{
+		ID: "archive-app", Name: "Archive App", Description: "d",
+		URL: "http://x", Icon: "i", Category: "c",
+		LaunchType: LaunchTypeContainer,
+	}
+	if err := db.CreateApp(app); err != nil {
+		t.Fatalf("CreateApp() error = %v", err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	old := now.Add(-48 * time.Hour)
+	for _, s := range []Session{
+		{ID: "old-stopped", Status: SessionStatusStopped, UpdatedAt: old},
+		{ID: "old-failed", Status: SessionStatusFailed, UpdatedAt: old.Add(time.Minute)},
+		{ID: "old-running", Status: SessionStatusRunning, UpdatedAt: old},
+		{ID: "old-recorded", Status: SessionStatusExpired, UpdatedAt: old},
+		{ID: "new-stopped", Status: SessionStatusStopped, UpdatedAt: now},
+	} {
+		s.UserID = "user-1"
+		s.AppID = "archive-app"
+		s.PodName = "pod-" + s.ID
+		s.CreatedAt = old.Add(-time.Hour)
+		if err := db.CreateSession(s); err != nil {
+			t.Fatalf("CreateSession(%s) error = %v", s.ID, err)
+		}
+	}
+	if err := db.CreateSessionShare(SessionShare{
+		ID: "share-1", SessionID: "old-stopped", UserID: "user-2",
+		Permission: SharePermissionReadOnly, CreatedBy: "user-1", CreatedAt: old,
+	}); err != nil {
+		t.Fatalf("CreateSessionShare() error = %v", err)
+	}
+	if err := db.CreateRecording(Recording{
+		ID: "rec-1", SessionID: "old-recorded", UserID: "user-1", Filename: "rec.webm",
+		Status: RecordingStatusReady, CreatedAt: old,
+	}); err != nil {
+		t.Fatalf("CreateRecording() error = %v", err)
+	}
+
+	cutoff := now.Add(-24 * time.Hour)
+	n, err := db.ArchiveSessions(cutoff, 1)
+	if err != nil || n != 1 {
+		t.Fatalf("ArchiveSessions(limit 1) = %d, %v; want 1", n, err)
+	}
+	n, err = db.ArchiveSessions(cutoff, 100)
+	if err != nil || n != 1 {
+		t.Fatalf("ArchiveSessions() = %d, %v; want 1", n, err)
+	}
+	if n, _ := db.ArchiveSessions(cutoff, 100); n != 0 {
+		t.Errorf("ArchiveSessions() again = %d, want 0", n)
+	}
+
+	for id, archived := range map[string]bool{
+		"old-stopped": true, "old-failed": true,
+		"old-running": false, "old-recorded": false, "new-stopped": false,
+	} {
+		s, err := db.GetSession(id)
+		if err != nil {
+			t.Fatalf("GetSession(%s) error = %v", id, err)
+		}
+		if (s == nil) != archived {
+			t.Errorf("session %s archived = %v, want %v", id, s == nil, archived)
+		}
+	}
+	if share, _ := db.GetSessionShare("share-1"); share != nil {
+		t.Error("expected share of archived session to be deleted")
+	}
+
+	list, err := db.ListArchivedSessions("user-1", 10)
+	if err != nil {
+		t.Fatalf("ListArchivedSessions() error = %v", err)
+	}
+	if len(list) != 2 || list[0].ID != "old-failed" || list[1].ID != "old-stopped" {
+		t.Fatalf("ListArchivedSessions() = %+v", list)
+	}
+	if got := list[1]; got.Status != SessionStatusStopped || got.PodName != "pod-old-stopped" || !got.UpdatedAt.Equal(old) || got.ArchivedAt.IsZero() {
+		t.Errorf("archived session = %+v", got)
+	}
+	if list, _ := db.ListArchivedSessions("user-2", 10); len(list) != 0 {
+		t.Errorf("ListArchivedSessions(user-2) = %d sessions, want 0", len(list))
+	}
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// FileDirection is what happened to a file in a session workspace.
+type FileDirection string
+
+const (
+	FileDirectionUpload   FileDirection = "upload"
+	FileDirectionDownload FileDirection = "download"
+	FileDirectionDelete   FileDirection = "delete"
+)
+
+// fileAuditActions maps file directions to audit log actions.
+var fileAuditActions = map[FileDirection]string{
+	FileDirectionUpload:   "FILE_UPLOAD",
+	FileDirectionDownload: "FILE_DOWNLOAD",
+	FileDirectionDelete:   "FILE_DELETE",
+}
+
+// SessionFileEvent records a file transferred to, from, or deleted in a
+// session workspace through the files API. Deletions have no size or hash.
+type SessionFileEvent struct {
+	bun.BaseModel `bun:"table:session_file_events"`
+
+	ID        int64         `json:"id" bun:"id,pk,autoincrement"`
+	SessionID string        `json:"session_id" bun:"session_id,notnull"`
+	Username  string        `json:"username" bun:"username,notnull"`
+	Direction FileDirection `json:"direction" bun:"direction,notnull"`
+	Path      string        `json:"path" bun:"path,notnull"`
+	SizeBytes int64         `json:"size_bytes" bun:"size_bytes,notnull"`
+	SHA256    string        `json:"sha256,omitempty" bun:"sha256,notnull"`
+	RequestID string        `json:"request_id,omitempty" bun:"request_id,notnull"`
+	CreatedAt time.Time     `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// auditDetails formats the event as key=value pairs for the audit log.
+func (e *SessionFileEvent) auditDetails() string {
+	details := fmt.Sprintf("session=%s path=%q", e.SessionID, e.Path)
+	if e.Direction != FileDirectionDelete {
+		details += fmt.Sprintf(" size=%d sha256=%s", e.SizeBytes, e.SHA256)
+	}
+	return details
+}
+
+// LogSessionFileEvent records a file event and its audit log entry
+// together.
+func (db *DB) LogSessionFileEvent(event SessionFileEvent) error {
+	action, ok := fileAuditActions[event.Direction]
+	if !ok {
+		return fmt.Errorf("unknown file direction %q", event.Direction)
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	entry := AuditLog{
+		Timestamp: event.CreatedAt,
+		User:      event.Username,
+		Action:    action,
+		Details:   event.auditDetails(),
+		RequestID: event.RequestID,
+	}
+	return db.runInTx(func(txCtx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(&event).Exec(txCtx); err != nil {
+			return err
+		}
+		_, err := tx.NewInsert().Model(&entry).Exec(txCtx)
+		return err
+	})
+}
+
+// ListSessionFileEvents returns the file events of a session, oldest
+// first. A non-empty path limits them to that file's history.
+func (db *DB) ListSessionFileEvents(sessionID, path string) ([]SessionFileEvent, error) {
+	events := []SessionFileEvent{}
+	q := db.conn.NewSelect().Model(&events).Where("session_id = ?", sessionID)
+	if path != "" {
+		q = q.Where("path = ?", path)
+	}
+	err := q.OrderExpr("created_at ASC, id ASC").Scan(db.ctx())
+	return events, err
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogSessionFileEvent(t *testing.T) {
+	db := setupTestDB(t)
+
+	base := time.Now().Add(-time.Minute)
+	events := []SessionFileEvent{
+		{SessionID: "sess-1", Username: "alice", Direction: FileDirectionUpload, Path: "report.pdf", SizeBytes: 42, SHA256: "abc123", RequestID: "req-1", CreatedAt: base},
+		{SessionID: "sess-1", Username: "alice", Direction: FileDirectionDownload, Path: "out/data.csv", SizeBytes: 7, SHA256: "def456", CreatedAt: base.Add(time.Second)},
+		{SessionID: "sess-1", Username: "admin", Direction: FileDirectionDelete, Path: "report.pdf", CreatedAt: base.Add(2 * time.Second)},
+		{SessionID: "sess-2", Username: "bob", Direction: FileDirectionUpload, Path: "report.pdf", SizeBytes: 1, SHA256: "x"},
+	}
+	for _, e := range events {
+		if err := db.LogSessionFileEvent(e); err != nil {
+			t.Fatalf("LogSessionFileEvent() error = %v", err)
+		}
+	}
+
+	all, err := db.ListSessionFileEvents("sess-1", "")
+	if err != nil {
+		t.Fatalf("ListSessionFileEvents() error = %v", err)
+	}
+	if len(all) != 3 || all[0].Direction != FileDirectionUpload || all[2].Direction != FileDirectionDelete {
+		t.Fatalf("events = %+v, want upload, download, delete", all)
+	}
+	if all[0].SizeBytes != 42 || all[0].SHA256 != "abc123" || all[0].RequestID != "req-1" {
+		t.Errorf("upload event = %+v", all[0])
+	}
+
+	history, err := db.ListSessionFileEvents("sess-1", "report.pdf")
+	if err != nil {
+		t.Fatalf("ListSessionFileEvents() error = %v", err)
+	}
+	if len(history) != 2 || history[1].Username != "admin" {
+		t.Errorf("report.pdf history = %+v, want upload then delete", history)
+	}
+
+	if none, err := db.ListSessionFileEvents("sess-3", ""); err != nil || none == nil || len(none) != 0 {
+		t.Errorf("unknown session = %v, %v; want empty slice", none, err)
+	}
+
+	page, err := db.QueryAuditLogs(AuditLogFilter{Action: "FILE_UPLOAD"})
+	if err != nil {
+		t.Fatalf("QueryAuditLogs() error = %v", err)
+	}
+	if page.Total != 2 {
+		t.Fatalf("FILE_UPLOAD audit entries = %d, want 2", page.Total)
+	}
+	page, _ = db.QueryAuditLogs(AuditLogFilter{RequestID: "req-1"})
+	if page.Total != 1 || page.Logs[0].User != "alice" ||
+		page.Logs[0].Details != `session=sess-1 path="report.pdf" size=42 sha256=abc123` {
+		t.Errorf("audit entry = %+v", page.Logs)
+	}
+	page, _ = db.QueryAuditLogs(AuditLogFilter{Action: "FILE_DELETE"})
+	if page.Total != 1 || strings.Contains(page.Logs[0].Details, "sha256") {
+		t.Errorf("delete audit entry = %+v", page.Logs)
+	}
+}
+
+func TestLogSessionFileEvent_UnknownDirection(t *testing.T) {
+	db := setupTestDB(t)
+	if err := db.LogSessionFileEvent(SessionFileEvent{SessionID: "s", Direction: "copy", Path: "p"}); err == nil {
+		t.Error("LogSessionFileEvent() accepted an unknown direction")
+	}
+}
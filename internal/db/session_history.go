@@ -0,0 +1,286 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// SessionHistory summarizes one run of a session, from when its workload
+// became ready (or was requested, if it never did) until it ended. A
+// session that is stopped and restarted has one entry per run. Entries are
+// never deleted, so reports do not depend on the sessions table.
+type SessionHistory struct {
+	bun.BaseModel `bun:"table:session_history"`
+
+	ID              string        `json:"id" bun:"id,pk"`
+	SessionID       string        `json:"session_id" bun:"session_id,notnull"`
+	TenantID        string        `json:"tenant_id,omitempty" bun:"tenant_id"`
+	UserID          string        `json:"user_id" bun:"user_id,notnull"`
+	Username        string        `json:"username" bun:"username,notnull"`
+	AppID           string        `json:"app_id" bun:"app_id,notnull"`
+	AppName         string        `json:"app_name" bun:"app_name,notnull"`
+	StartedAt       time.Time     `json:"started_at" bun:"started_at,notnull"`
+	EndedAt         time.Time     `json:"ended_at" bun:"ended_at,notnull"`
+	DurationSeconds int64         `json:"duration_seconds" bun:"duration_seconds,notnull"`
+	CPURequest      string        `json:"cpu_request,omitempty" bun:"cpu_request,notnull"`
+	CPULimit        string        `json:"cpu_limit,omitempty" bun:"cpu_limit,notnull"`
+	MemoryRequest   string        `json:"memory_request,omitempty" bun:"memory_request,notnull"`
+	MemoryLimit     string        `json:"memory_limit,omitempty" bun:"memory_limit,notnull"`
+	FinalStatus     SessionStatus `json:"final_status" bun:"final_status,notnull"`
+	ExitReason      string        `json:"exit_reason" bun:"exit_reason,notnull"`
+	// CPUCoreSeconds and MemoryGiBSeconds are the CPU and memory the run
+	// requested multiplied by its duration. Runs recorded before they were
+	// tracked have zero.
+	CPUCoreSeconds   float64 `json:"cpu_core_seconds" bun:"cpu_core_seconds,notnull"`
+	MemoryGiBSeconds float64 `json:"memory_gib_seconds" bun:"memory_gib_seconds,notnull"`
+	// ProjectID is the project the session was launched in, if any.
+	ProjectID string `json:"project_id,omitempty" bun:"project_id,notnull"`
+}
+
+// SessionHistoryFilter holds query parameters for filtering session history.
+// From and To match on when runs ended.
+type SessionHistoryFilter struct {
+	UserID   string
+	AppID    string
+	TenantID string
+	// ProjectID matches runs of sessions launched in a project.
+	ProjectID string
+	Status    string
+	From      time.Time
+	To        time.Time
+	Limit     int
+	Offset    int
+}
+
+// SessionHistoryPage holds a page of session history with total count
+type SessionHistoryPage struct {
+	Sessions []SessionHistory `json:"sessions"`
+	Total    int              `json:"total"`
+}
+
+// SessionHistoryGroup aggregates the session runs of one user or app.
+type SessionHistoryGroup struct {
+	Key                  string `json:"key" bun:"key"`
+	Sessions             int    `json:"sessions" bun:"sessions"`
+	TotalDurationSeconds int64  `json:"total_duration_seconds" bun:"total_duration_seconds"`
+	Failed               int    `json:"failed" bun:"failed"`
+}
+
+// SessionHistoryGroupBy maps the supported summary groupings to columns.
+var SessionHistoryGroupBy = map[string]string{
+	"app":    "app_id",
+	"user":   "user_id",
+	"status": "final_status",
+}
+
+// CreateSessionHistory records a finished session run.
+func (db *DB) CreateSessionHistory(h SessionHistory) error {
+	if h.TenantID == "" {
+		h.TenantID = DefaultTenantID
+	}
+	_, err := db.conn.NewInsert().Model(&h).Exec(db.ctx())
+	return err
+}
+
+func (f SessionHistoryFilter) apply(q *bun.SelectQuery) *bun.SelectQuery {
+	if f.UserID != "" {
+		q = q.Where("user_id = ?", f.UserID)
+	}
+	if f.AppID != "" {
+		q = q.Where("app_id = ?", f.AppID)
+	}
+	if f.TenantID != "" {
+		q = q.Where("tenant_id = ?", f.TenantID)
+	}
+	if f.ProjectID != "" {
+		q = q.Where("project_id = ?", f.ProjectID)
+	}
+	if f.Status != "" {
+		q = q.Where("final_status = ?", f.Status)
+	}
+	if !f.From.IsZero() {
+		q = q.Where("ended_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		q = q.Where("ended_at <= ?", f.To)
+	}
+	return q
+}
+
+// QuerySessionHistory returns session runs matching the filter, most
+// recently ended first, with pagination.
+func (db *DB) QuerySessionHistory(filter SessionHistoryFilter) (*SessionHistoryPage, error) {
+	q := filter.apply(db.conn.NewSelect().Model((*SessionHistory)(nil)))
+
+	total, err := q.Count(db.ctx())
+	if err != nil {
+		return nil, fmt.Errorf("failed to count session history: %w", err)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > 1000 {
+		limit = 50
+	}
+	offset := max(filter.Offset, 0)
+
+	var sessions []SessionHistory
+	err = q.OrderExpr("ended_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Scan(db.ctx(), &sessions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query session history: %w", err)
+	}
+
+	return &SessionHistoryPage{Sessions: sessions, Total: total}, nil
+}
+
+// SummarizeSessionHistory aggregates session runs matching the filter by
+// one of the SessionHistoryGroupBy keys, busiest first. The filter's limit
+// and offset are ignored.
+func (db *DB) SummarizeSessionHistory(filter SessionHistoryFilter, groupBy string) ([]SessionHistoryGroup, error) {
+	column, ok := SessionHistoryGroupBy[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("unsupported grouping: %s", groupBy)
+	}
+
+	var groups []SessionHistoryGroup
+	err := filter.apply(db.conn.NewSelect().Model((*SessionHistory)(nil))).
+		ColumnExpr("? AS key", bun.Ident(column)).
+		ColumnExpr("COUNT(*) AS sessions").
+		ColumnExpr("COALESCE(SUM(duration_seconds), 0) AS total_duration_seconds").
+		ColumnExpr("SUM(CASE WHEN final_status = ? THEN 1 ELSE 0 END) AS failed", SessionStatusFailed).
+		GroupExpr("?", bun.Ident(column)).
+		OrderExpr("sessions DESC, key ASC").
+		Scan(db.ctx(), &groups)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize session history: %w", err)
+	}
+	return groups, nil
+}
+
+// SessionCostGroup totals the resources requested by the session runs of
+// one user, app, tenant or project, for cost reports.
+type SessionCostGroup struct {
+	Key              string  `json:"key" bun:"key"`
+	Name             string  `json:"name,omitempty" bun:"name"`
+	Sessions         int     `json:"sessions" bun:"sessions"`
+	DurationSeconds  int64   `json:"duration_seconds" bun:"duration_seconds"`
+	CPUCoreSeconds   float64 `json:"cpu_core_seconds" bun:"cpu_core_seconds"`
+	MemoryGiBSeconds float64 `json:"memory_gib_seconds" bun:"memory_gib_seconds"`
+}
+
+// SessionCostGroupBy maps the supported cost report groupings to the
+// column grouped by and the column naming the group, if any.
+var SessionCostGroupBy = map[string][2]string{
+	"user":    {"user_id", "username"},
+	"app":     {"app_id", "app_name"},
+	"tenant":  {"tenant_id", ""},
+	"project": {"project_id", ""},
+}
+
+// SummarizeSessionCosts totals the resources requested by session runs
+// matching the filter by one of the SessionCostGroupBy keys, most CPU
+// first. A group's name is the latest one recorded. The filter's limit
+// and offset are ignored.
+func (db *DB) SummarizeSessionCosts(filter SessionHistoryFilter, groupBy string) ([]SessionCostGroup, error) {
+	columns, ok := SessionCostGroupBy[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("unsupported grouping: %s", groupBy)
+	}
+
+	q := filter.apply(db.conn.NewSelect().Model((*SessionHistory)(nil))).
+		ColumnExpr("? AS key", bun.Ident(columns[0]))
+	if columns[1] != "" {
+		q = q.ColumnExpr("MAX(?) AS name", bun.Ident(columns[1]))
+	}
+	var groups []SessionCostGroup
+	err := q.ColumnExpr("COUNT(*) AS sessions").
+		ColumnExpr("COALESCE(SUM(duration_seconds), 0) AS duration_seconds").
+		ColumnExpr("COALESCE(SUM(cpu_core_seconds), 0) AS cpu_core_seconds").
+		ColumnExpr("COALESCE(SUM(memory_gib_seconds), 0) AS memory_gib_seconds").
+		GroupExpr("?", bun.Ident(columns[0])).
+		OrderExpr("cpu_core_seconds DESC, key ASC").
+		Scan(db.ctx(), &groups)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize session costs: %w", err)
+	}
+	return groups, nil
+}
+
+// SessionRun is when a session was running, for concurrency reports.
+type SessionRun struct {
+	StartedAt time.Time `bun:"started_at"`
+	EndedAt   time.Time `bun:"ended_at"`
+}
+
+// ListSessionRuns returns the session runs, optionally of one app and
+// tenant, that overlap [from, to): finished runs from the session history,
+// and the current run of each creating or running session, which ends at
+// to. A current run starts when the session was created or, if it was
+// restarted, when its previous run ended.
+func (db *DB) ListSessionRuns(from, to time.Time, appID, tenantID string) ([]SessionRun, error) {
+	filter := SessionHistoryFilter{AppID: appID, TenantID: tenantID}
+
+	var runs []SessionRun
+	err := filter.apply(db.conn.NewSelect().Model((*SessionHistory)(nil))).
+		Column("started_at", "ended_at").
+		Where("started_at < ?", to).
+		Where("ended_at > ?", from).
+		Scan(db.ctx(), &runs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list session runs: %w", err)
+	}
+
+	var active []Session
+	q := db.conn.NewSelect().Model(&active).
+		Where("status IN (?)", bun.In([]SessionStatus{SessionStatusCreating, SessionStatusRunning})).
+		Where("created_at < ?", to)
+	if appID != "" {
+		q = q.Where("app_id = ?", appID)
+	}
+	if tenantID != "" {
+		q = q.Where("tenant_id = ?", tenantID)
+	}
+	if err := q.Scan(db.ctx()); err != nil {
+		return nil, fmt.Errorf("failed to list active sessions: %w", err)
+	}
+	if len(active) == 0 {
+		return runs, nil
+	}
+
+	ids := make([]string, len(active))
+	for i, s := range active {
+		ids[i] = s.ID
+	}
+	var previous []struct {
+		SessionID string    `bun:"session_id"`
+		EndedAt   time.Time `bun:"ended_at"`
+	}
+	err = db.conn.NewSelect().Model((*SessionHistory)(nil)).
+		Column("session_id").
+		ColumnExpr("MAX(ended_at) AS ended_at").
+		Where("session_id IN (?)", bun.In(ids)).
+		Group("session_id").
+		Scan(db.ctx(), &previous)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list previous session runs: %w", err)
+	}
+	restarted := make(map[string]time.Time, len(previous))
+	for _, p := range previous {
+		restarted[p.SessionID] = p.EndedAt
+	}
+
+	for _, s := range active {
+		start := s.CreatedAt
+		if ended, ok := restarted[s.ID]; ok && ended.After(start) {
+			start = ended
+		}
+		if start.Before(to) {
+			runs = append(runs, SessionRun{StartedAt: start, EndedAt: to})
+		}
+	}
+	return runs, nil
+}
@@ -0,0 +1,196 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionHistory(t *testing.T) {
+	db := setupTestDB(t)
+
+	base := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	for i, h := range []SessionHistory{
+		{UserID: "alice", AppID: "editor", DurationSeconds: 600, FinalStatus: SessionStatusStopped},
+		{UserID: "alice", AppID: "editor", DurationSeconds: 300, FinalStatus: SessionStatusExpired},
+		{UserID: "bob", AppID: "editor", DurationSeconds: 0, FinalStatus: SessionStatusFailed},
+		{UserID: "bob", AppID: "browser", DurationSeconds: 60, FinalStatus: SessionStatusStopped},
+	} {
+		h.ID = string(rune('a' + i))
+		h.SessionID = "session-" + h.ID
+		h.StartedAt = base.Add(time.Duration(i) * time.Hour)
+		h.EndedAt = h.StartedAt.Add(time.Duration(h.DurationSeconds) * time.Second)
+		if err := db.CreateSessionHistory(h); err != nil {
+			t.Fatalf("CreateSessionHistory() error = %v", err)
+		}
+	}
+
+	page, err := db.QuerySessionHistory(SessionHistoryFilter{})
+	if err != nil {
+		t.Fatalf("QuerySessionHistory() error = %v", err)
+	}
+	if page.Total != 4 || len(page.Sessions) != 4 || page.Sessions[0].ID != "d" {
+		t.Fatalf("QuerySessionHistory() = total %d, %+v", page.Total, page.Sessions)
+	}
+	if got := page.Sessions[3]; got.TenantID != DefaultTenantID || got.DurationSeconds != 600 || !got.StartedAt.Equal(base) {
+		t.Errorf("history entry = %+v", got)
+	}
+
+	page, err = db.QuerySessionHistory(SessionHistoryFilter{UserID: "alice", Limit: 1, Offset: 1})
+	if err != nil {
+		t.Fatalf("QuerySessionHistory(alice) error = %v", err)
+	}
+	if page.Total != 2 || len(page.Sessions) != 1 || page.Sessions[0].ID != "a" {
+		t.Errorf("QuerySessionHistory(alice, page 2) = total %d, %+v", page.Total, page.Sessions)
+	}
+
+	page, _ = db.QuerySessionHistory(SessionHistoryFilter{From: base.Add(90 * time.Minute)})
+	if page.Total != 2 {
+		t.Errorf("QuerySessionHistory(from) total = %d, want 2", page.Total)
+	}
+
+	groups, err := db.SummarizeSessionHistory(SessionHistoryFilter{}, "app")
+	if err != nil {
+		t.Fatalf("SummarizeSessionHistory(app) error = %v", err)
+	}
+	want := []SessionHistoryGroup{
+		{Key: "editor", Sessions: 3, TotalDurationSeconds: 900, Failed: 1},
+		{Key: "browser", Sessions: 1, TotalDurationSeconds: 60},
+	}
+	if len(groups) != len(want) || groups[0] != want[0] || groups[1] != want[1] {
+		t.Errorf("SummarizeSessionHistory(app) = %+v, want %+v", groups, want)
+	}
+
+	groups, err = db.SummarizeSessionHistory(SessionHistoryFilter{AppID: "editor"}, "user")
+	if err != nil {
+		t.Fatalf("SummarizeSessionHistory(user) error = %v", err)
+	}
+	if len(groups) != 2 || groups[0].Key != "alice" || groups[1] != (SessionHistoryGroup{Key: "bob", Sessions: 1, Failed: 1}) {
+		t.Errorf("SummarizeSessionHistory(user) = %+v", groups)
+	}
+
+	if _, err := db.SummarizeSessionHistory(SessionHistoryFilter{}, "pod"); err == nil {
+		t.Error("SummarizeSessionHistory(pod) expected error")
+	}
+}
+
+func TestSummarizeSessionCosts(t *testing.T) {
+	db := setupTestDB(t)
+
+	ended := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i, h := range []SessionHistory{
+		{UserID: "alice", Username: "alice", AppID: "editor", AppName: "Editor", DurationSeconds: 3600, CPUCoreSeconds: 1800, MemoryGiBSeconds: 3600},
+		{UserID: "alice", Username: "alice", AppID: "cad", AppName: "CAD", TenantID: "acme", DurationSeconds: 1800, CPUCoreSeconds: 7200, MemoryGiBSeconds: 14400},
+		{UserID: "bob", Username: "bob", AppID: "editor", AppName: "Editor", ProjectID: "genomics", DurationSeconds: 600, CPUCoreSeconds: 300, MemoryGiBSeconds: 600},
+	} {
+		h.ID = string(rune('a' + i))
+		h.SessionID = "session-" + h.ID
+		h.EndedAt = ended
+		h.StartedAt = ended.Add(-time.Duration(h.DurationSeconds) * time.Second)
+		h.FinalStatus = SessionStatusStopped
+		if err := db.CreateSessionHistory(h); err != nil {
+			t.Fatalf("CreateSessionHistory() error = %v", err)
+		}
+	}
+
+	groups, err := db.SummarizeSessionCosts(SessionHistoryFilter{}, "user")
+	if err != nil {
+		t.Fatalf("SummarizeSessionCosts(user) error = %v", err)
+	}
+	want := []SessionCostGroup{
+		{Key: "alice", Name: "alice", Sessions: 2, DurationSeconds: 5400, CPUCoreSeconds: 9000, MemoryGiBSeconds: 18000},
+		{Key: "bob", Name: "bob", Sessions: 1, DurationSeconds: 600, CPUCoreSeconds: 300, MemoryGiBSeconds: 600},
+	}
+	if len(groups) != len(want) || groups[0] != want[0] || groups[1] != want[1] {
+		t.Errorf("SummarizeSessionCosts(user) = %+v, want %+v", groups, want)
+	}
+
+	groups, err = db.SummarizeSessionCosts(SessionHistoryFilter{}, "app")
+	if err != nil {
+		t.Fatalf("SummarizeSessionCosts(app) error = %v", err)
+	}
+	if len(groups) != 2 || groups[0].Key != "cad" || groups[0].Name != "CAD" || groups[1].Sessions != 2 {
+		t.Errorf("SummarizeSessionCosts(app) = %+v", groups)
+	}
+
+	groups, err = db.SummarizeSessionCosts(SessionHistoryFilter{UserID: "alice"}, "tenant")
+	if err != nil {
+		t.Fatalf("SummarizeSessionCosts(tenant) error = %v", err)
+	}
+	if len(groups) != 2 || groups[0] != (SessionCostGroup{Key: "acme", Sessions: 1, DurationSeconds: 1800, CPUCoreSeconds: 7200, MemoryGiBSeconds: 14400}) {
+		t.Errorf("SummarizeSessionCosts(tenant) = %+v", groups)
+	}
+
+	groups, err = db.SummarizeSessionCosts(SessionHistoryFilter{ProjectID: "genomics"}, "project")
+	if err != nil {
+		t.Fatalf("SummarizeSessionCosts(project) error = %v", err)
+	}
+	if len(groups) != 1 || groups[0] != (SessionCostGroup{Key: "genomics", Sessions: 1, DurationSeconds: 600, CPUCoreSeconds: 300, MemoryGiBSeconds: 600}) {
+		t.Errorf("SummarizeSessionCosts(project) = %+v", groups)
+	}
+
+	groups, _ = db.SummarizeSessionCosts(SessionHistoryFilter{From: ended.Add(time.Minute)}, "user")
+	if len(groups) != 0 {
+		t.Errorf("SummarizeSessionCosts(from) = %+v, want none", groups)
+	}
+	if _, err := db.SummarizeSessionCosts(SessionHistoryFilter{}, "status"); err == nil {
+		t.Error("SummarizeSessionCosts(status) expected error")
+	}
+}
+
+func TestListSessionRuns(t *testing.T) {
+	db := setupTestDB(t)
+
+	from := time.Now().Add(-7 * 24 * time.Hour).Truncate(time.Second)
+	to := from.Add(7 * 24 * time.Hour)
+	for i, h := range []SessionHistory{
+		{SessionID: "s1", AppID: "editor", StartedAt: from.Add(time.Hour), EndedAt: from.Add(2 * time.Hour)},
+		{SessionID: "s2", AppID: "editor", StartedAt: from.Add(-2 * time.Hour), EndedAt: from.Add(-time.Hour)},
+		{SessionID: "s3", AppID: "browser", StartedAt: from.Add(-time.Hour), EndedAt: from.Add(time.Hour)},
+		{SessionID: "restarted", AppID: "editor", StartedAt: from.Add(3 * time.Hour), EndedAt: from.Add(4 * time.Hour)},
+	} {
+		h.ID = string(rune('a' + i))
+		h.UserID = "alice"
+		h.FinalStatus = SessionStatusStopped
+		if err := db.CreateSessionHistory(h); err != nil {
+			t.Fatalf("CreateSessionHistory() error = %v", err)
+		}
+	}
+	for _, s := range []Session{
+		{ID: "running", AppID: "editor", Status: SessionStatusRunning, CreatedAt: from.Add(5 * time.Hour)},
+		{ID: "restarted", AppID: "editor", Status: SessionStatusRunning, CreatedAt: from.Add(2 * time.Hour)},
+		{ID: "stopped", AppID: "editor", Status: SessionStatusStopped, CreatedAt: from.Add(time.Hour)},
+	} {
+		s.UserID = "alice"
+		if err := db.CreateSession(s); err != nil {
+			t.Fatalf("CreateSession() error = %v", err)
+		}
+	}
+
+	runs, err := db.ListSessionRuns(from, to, "editor", "")
+	if err != nil {
+		t.Fatalf("ListSessionRuns() error = %v", err)
+	}
+	starts := map[time.Duration]time.Time{}
+	for _, r := range runs {
+		starts[r.StartedAt.Sub(from)] = r.EndedAt
+	}
+	want := map[time.Duration]time.Time{
+		time.Hour:     from.Add(2 * time.Hour),
+		3 * time.Hour: from.Add(4 * time.Hour),
+		4 * time.Hour: to, // restarted after its previous run ended
+		5 * time.Hour: to,
+	}
+	if len(starts) != len(want) || len(runs) != len(want) {
+		t.Fatalf("ListSessionRuns(editor) = %+v", runs)
+	}
+	for offset, end := range want {
+		if got, ok := starts[offset]; !ok || !got.Equal(end) {
+			t.Errorf("run starting at +%v ends %v, want %v", offset, got, end)
+		}
+	}
+
+	runs, err = db.ListSessionRuns(from, to, "", DefaultTenantID)
+	if err != nil || len(runs) != 5 {
+		t.Errorf("ListSessionRuns(all) = %+v, %v; want 5 runs", runs, err)
+	}
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// SessionLaunch records how one launch of a session went: whether its
+// workload became ready and how long that took from the request. Restarts
+// and resumes are launches too. Entries are never deleted, like session
+// history.
+type SessionLaunch struct {
+	bun.BaseModel `bun:"table:session_launches"`
+
+	ID          int64     `json:"id" bun:"id,pk,autoincrement"`
+	SessionID   string    `json:"session_id" bun:"session_id,notnull"`
+	TenantID    string    `json:"tenant_id,omitempty" bun:"tenant_id"`
+	AppID       string    `json:"app_id" bun:"app_id,notnull"`
+	RequestedAt time.Time `json:"requested_at" bun:"requested_at,notnull"`
+	FinishedAt  time.Time `json:"finished_at" bun:"finished_at,notnull"`
+	// StartSeconds is the time from request to running. It is 0 for
+	// failed launches.
+	StartSeconds float64 `json:"start_seconds" bun:"start_seconds,notnull"`
+	Succeeded    bool    `json:"succeeded" bun:"succeeded,notnull"`
+	Reason       string  `json:"reason,omitempty" bun:"reason,notnull"`
+}
+
+// CreateSessionLaunch records a finished launch.
+func (db *DB) CreateSessionLaunch(l SessionLaunch) error {
+	if l.TenantID == "" {
+		l.TenantID = DefaultTenantID
+	}
+	_, err := db.conn.NewInsert().Model(&l).Exec(db.ctx())
+	return err
+}
+
+// ListSessionLaunches returns the launches of an app that finished in
+// [from, to], oldest first.
+func (db *DB) ListSessionLaunches(appID string, from, to time.Time) ([]SessionLaunch, error) {
+	launches := []SessionLaunch{}
+	err := db.conn.NewSelect().Model(&launches).
+		Where("app_id = ?", appID).
+		Where("finished_at >= ?", from).
+		Where("finished_at <= ?", to).
+		OrderExpr("finished_at ASC, id ASC").
+		Scan(db.ctx())
+	return launches, err
+}
+
+// CountSessionLaunchesPerApp returns the number of launches of each app
+// that were requested at or after since, including failed ones.
+func (db *DB) CountSessionLaunchesPerApp(since time.Time) (map[string]int, error) {
+	var rows []struct {
+		AppID string `bun:"app_id"`
+		Count int    `bun:"count"`
+	}
+	err := db.conn.NewSelect().Model((*SessionLaunch)(nil)).
+		ColumnExpr("app_id").
+		ColumnExpr("COUNT(*) AS count").
+		Where("requested_at >= ?", since).
+		Group("app_id").
+		Scan(db.ctx(), &rows)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int, len(rows))
+	for _, r := range rows {
+		counts[r.AppID] = r.Count
+	}
+	return counts, nil
+}
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionLaunches(t *testing.T) {
+	db := setupTestDB(t)
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	for i, l := range []SessionLaunch{
+		{SessionID: "s1", AppID: "app-a", StartSeconds: 12.5, Succeeded: true},
+		{SessionID: "s2", AppID: "app-a", Reason: "workload failed to become ready"},
+		{SessionID: "s3", AppID: "app-b", StartSeconds: 3, Succeeded: true},
+		{SessionID: "s4", AppID: "app-a", StartSeconds: 8, Succeeded: true},
+	} {
+		l.RequestedAt = base.Add(time.Duration(i) * 10 * time.Minute)
+		l.FinishedAt = l.RequestedAt.Add(time.Minute)
+		if err := db.CreateSessionLaunch(l); err != nil {
+			t.Fatalf("CreateSessionLaunch() error = %v", err)
+		}
+	}
+
+	launches, err := db.ListSessionLaunches("app-a", base, time.Now())
+	if err != nil {
+		t.Fatalf("ListSessionLaunches() error = %v", err)
+	}
+	if len(launches) != 3 {
+		t.Fatalf("got %d launches, want 3", len(launches))
+	}
+	first := launches[0]
+	if first.SessionID != "s1" || !first.Succeeded || first.StartSeconds != 12.5 || first.TenantID != DefaultTenantID {
+		t.Errorf("first launch = %+v", first)
+	}
+	if launches[1].Succeeded || launches[1].Reason == "" {
+		t.Errorf("second launch = %+v, want the failure", launches[1])
+	}
+
+	launches, _ = db.ListSessionLaunches("app-a", base.Add(15*time.Minute), time.Now())
+	if len(launches) != 1 || launches[0].SessionID != "s4" {
+		t.Errorf("launches from +15m = %+v, want s4", launches)
+	}
+}
@@ -0,0 +1,155 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Statuses of a setting change request.
+const (
+	SettingChangePending  = "pending"
+	SettingChangeApproved = "approved"
+	SettingChangeRejected = "rejected"
+	// SettingChangeCancelled is a request withdrawn by its requester.
+	SettingChangeCancelled = "cancelled"
+)
+
+// SettingChangeRequest is a staged change to settings, or to a tenant's
+// quotas and egress policy, that requires a second admin's approval. The
+// changes are applied when it is approved.
+type SettingChangeRequest struct {
+	bun.BaseModel `bun:"table:setting_change_requests"`
+
+	ID string `json:"id" bun:"id,pk"`
+	// Changes maps setting keys to their proposed values.
+	Changes map[string]string `json:"changes" bun:"-"`
+	// Tenant is the proposed tenant change, if any.
+	Tenant      *TenantChange `json:"tenant,omitempty" bun:"-"`
+	Reason      string        `json:"reason,omitempty" bun:"reason,notnull"`
+	Status      string        `json:"status" bun:"status,notnull"`
+	RequestedBy string        `json:"requested_by" bun:"requested_by,notnull"`
+	// ReviewedBy is the admin who approved or rejected the request, or its
+	// requester if they cancelled it.
+	ReviewedBy    string     `json:"reviewed_by,omitempty" bun:"reviewed_by,notnull"`
+	ReviewComment string     `json:"review_comment,omitempty" bun:"review_comment,notnull"`
+	CreatedAt     time.Time  `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty" bun:"reviewed_at"`
+
+	// JSON-serialized DB columns
+	ChangesJSON string `json:"-" bun:"changes"`
+	TenantJSON  string `json:"-" bun:"tenant_change"`
+}
+
+// TenantChange is a tenant's proposed quotas and egress policy. Both
+// replace the tenant's current ones when the request is approved.
+type TenantChange struct {
+	TenantID     string        `json:"tenant_id"`
+	Quotas       TenantQuotas  `json:"quotas"`
+	EgressPolicy *EgressPolicy `json:"egress_policy,omitempty"`
+}
+
+// CreateSettingChangeRequest inserts a new pending setting change request.
+func (db *DB) CreateSettingChangeRequest(req SettingChangeRequest) error {
+	req.Status = SettingChangePending
+	req.CreatedAt = time.Now()
+	_, err := db.conn.NewInsert().Model(&req).Exec(db.ctx())
+	return err
+}
+
+// GetSettingChangeRequest returns a setting change request by ID, or nil if
+// it does not exist.
+func (db *DB) GetSettingChangeRequest(id string) (*SettingChangeRequest, error) {
+	var req SettingChangeRequest
+	err := db.conn.NewSelect().Model(&req).Where("id = ?", id).Scan(db.ctx())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// ListSettingChangeRequests returns setting change requests with the given
+// status, or all of them if status is empty, newest first.
+func (db *DB) ListSettingChangeRequests(status string) ([]SettingChangeRequest, error) {
+	var reqs []SettingChangeRequest
+	q := db.conn.NewSelect().Model(&reqs).OrderExpr("created_at DESC, id DESC")
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	err := q.Scan(db.ctx())
+	return reqs, err
+}
+
+// ReviewSettingChangeRequest moves a pending request to status, one of
+// SettingChangeApproved, SettingChangeRejected or SettingChangeCancelled,
+// recording who reviewed it. Approving a request applies its changes, and
+// its tenant change if any, in the same transaction. It returns sql.ErrNoRows if the request does not exist
+// or is no longer pending.
+func (db *DB) ReviewSettingChangeRequest(id, status, reviewedBy, comment string) error {
+	return db.runInTx(func(ctx context.Context, tx bun.Tx) error {
+		var req SettingChangeRequest
+		err := tx.NewSelect().Model(&req).
+			Where("id = ?", id).
+			Where("status = ?", SettingChangePending).
+			Scan(ctx)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		result, err := tx.NewUpdate().Model((*SettingChangeRequest)(nil)).
+			Set("status = ?", status).
+			Set("reviewed_by = ?", reviewedBy).
+			Set("review_comment = ?", comment).
+			Set("reviewed_at = ?", now).
+			Where("id = ?", id).
+			Where("status = ?", SettingChangePending).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return sql.ErrNoRows
+		}
+
+		if status != SettingChangeApproved {
+			return nil
+		}
+		for key, value := range req.Changes {
+			setting := Setting{Key: key, Value: value, UpdatedAt: now}
+			_, err := tx.NewInsert().Model(&setting).
+				On("CONFLICT (key) DO UPDATE").
+				Set("value = EXCLUDED.value, updated_at = EXCLUDED.updated_at").
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		if req.Tenant == nil {
+			return nil
+		}
+		var tenant Tenant
+		if err := tx.NewSelect().Model(&tenant).Where("id = ?", req.Tenant.TenantID).Scan(ctx); err != nil {
+			return fmt.Errorf("tenant %s: %w", req.Tenant.TenantID, err)
+		}
+		tenant.Quotas = req.Tenant.Quotas
+		tenant.Settings.SetDefaultEgressPolicy(req.Tenant.EgressPolicy)
+		tenant.UpdatedAt = now
+		_, err = tx.NewUpdate().Model(&tenant).
+			Column("settings", "quotas", "updated_at").
+			WherePK().
+			Exec(ctx)
+		return err
+	})
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestSettingChangeRequests(t *testing.T) {
+	db := setupTestDB(t)
+
+	if err := db.CreateSettingChangeRequest(SettingChangeRequest{
+		ID: "cr-1", Changes: map[string]string{"max_global_sessions": "200"}, Reason: "Launch week", RequestedBy: "alice",
+	}); err != nil {
+		t.Fatalf("CreateSettingChangeRequest() error = %v", err)
+	}
+	if err := db.CreateSettingChangeRequest(SettingChangeRequest{
+		ID: "cr-2", Changes: map[string]string{"recording_auto_record": "false"}, RequestedBy: "alice",
+	}); err != nil {
+		t.Fatalf("CreateSettingChangeRequest() error = %v", err)
+	}
+
+	req, err := db.GetSettingChangeRequest("cr-1")
+	if err != nil || req == nil {
+		t.Fatalf("GetSettingChangeRequest() = %v, %v", req, err)
+	}
+	if req.Status != SettingChangePending || req.Changes["max_global_sessions"] != "200" || req.Reason != "Launch week" {
+		t.Errorf("request = %+v", req)
+	}
+	if missing, err := db.GetSettingChangeRequest("nope"); missing != nil || err != nil {
+		t.Errorf("GetSettingChangeRequest(nope) = %v, %v", missing, err)
+	}
+
+	if err := db.ReviewSettingChangeRequest("cr-1", SettingChangeApproved, "bob", "ok"); err != nil {
+		t.Fatalf("ReviewSettingChangeRequest(approve) error = %v", err)
+	}
+	if v, _ := db.GetSetting("max_global_sessions"); v != "200" {
+		t.Errorf("approved setting = %q, want 200", v)
+	}
+	req, _ = db.GetSettingChangeRequest("cr-1")
+	if req.Status != SettingChangeApproved || req.ReviewedBy != "bob" || req.ReviewComment != "ok" || req.ReviewedAt == nil {
+		t.Errorf("approved request = %+v", req)
+	}
+
+	// A reviewed request cannot be reviewed again
+	if err := db.ReviewSettingChangeRequest("cr-1", SettingChangeRejected, "carol", ""); err != sql.ErrNoRows {
+		t.Errorf("second review error = %v, want sql.ErrNoRows", err)
+	}
+
+	if err := db.ReviewSettingChangeRequest("cr-2", SettingChangeRejected, "bob", "no"); err != nil {
+		t.Fatalf("ReviewSettingChangeRequest(reject) error = %v", err)
+	}
+	if v, _ := db.GetSetting("recording_auto_record"); v != "" {
+		t.Errorf("rejected setting was applied: %q", v)
+	}
+
+	pending, err := db.ListSettingChangeRequests(SettingChangePending)
+	if err != nil || len(pending) != 0 {
+		t.Errorf("pending = %v, %v", pending, err)
+	}
+	all, err := db.ListSettingChangeRequests("")
+	if err != nil || len(all) != 2 {
+		t.Errorf("all = %v, %v", all, err)
+	}
+}
+
+func TestSettingChangeRequestTenant(t *testing.T) {
+	db := setupTestDB(t)
+
+	if err := db.CreateTenant(Tenant{
+		ID: "acme", Name: "Acme", Slug: "acme",
+		Settings: TenantSettings{DisplayName: "Acme Corp"},
+		Quotas:   TenantQuotas{MaxTotalSessions: 10},
+	}); err != nil {
+		t.Fatalf("CreateTenant() error = %v", err)
+	}
+	egress := &EgressPolicy{Mode: "allowlist", Rules: []EgressRule{{CIDR: "10.0.0.0/8"}}}
+	if err := db.CreateSettingChangeRequest(SettingChangeRequest{
+		ID:          "cr-1",
+		Changes:     map[string]string{},
+		Tenant:      &TenantChange{TenantID: "acme", Quotas: TenantQuotas{MaxTotalSessions: 50}, EgressPolicy: egress},
+		RequestedBy: "alice",
+	}); err != nil {
+		t.Fatalf("CreateSettingChangeRequest() error = %v", err)
+	}
+
+	req, err := db.GetSettingChangeRequest("cr-1")
+	if err != nil || req == nil || req.Tenant == nil {
+		t.Fatalf("GetSettingChangeRequest() = %+v, %v", req, err)
+	}
+	if req.Tenant.TenantID != "acme" || req.Tenant.Quotas.MaxTotalSessions != 50 || req.Tenant.EgressPolicy.Mode != "allowlist" {
+		t.Errorf("tenant change = %+v", req.Tenant)
+	}
+
+	if err := db.ReviewSettingChangeRequest("cr-1", SettingChangeApproved, "bob", ""); err != nil {
+		t.Fatalf("ReviewSettingChangeRequest(approve) error = %v", err)
+	}
+	tenant, err := db.GetTenant("acme")
+	if err != nil || tenant == nil {
+		t.Fatalf("GetTenant() = %v, %v", tenant, err)
+	}
+	if tenant.Quotas.MaxTotalSessions != 50 {
+		t.Errorf("max_total_sessions = %d, want 50", tenant.Quotas.MaxTotalSessions)
+	}
+	if p := tenant.Settings.DefaultEgressPolicy(); p == nil || p.Mode != "allowlist" || len(p.Rules) != 1 {
+		t.Errorf("egress policy = %+v", p)
+	}
+	if tenant.Settings.DisplayName != "Acme Corp" {
+		t.Errorf("display name = %q, want it kept", tenant.Settings.DisplayName)
+	}
+}
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// SidecarTemplate is an admin-defined container that can be attached to
+// apps or tenants and is added to their session pods, such as a VPN client,
+// logging agent, or license proxy.
+type SidecarTemplate struct {
+	bun.BaseModel `bun:"table:sidecar_templates"`
+
+	// Name identifies the template and names its container in session pods.
+	Name        string `json:"name" bun:"name,pk"`
+	Description string `json:"description,omitempty" bun:"description,notnull"`
+	// TenantID limits the template to one tenant's apps. Empty means the
+	// template is available to every tenant.
+	TenantID  string      `json:"tenant_id,omitempty" bun:"tenant_id,notnull"`
+	Spec      SidecarSpec `json:"spec" bun:"-"`
+	CreatedAt time.Time   `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	UpdatedAt time.Time   `json:"updated_at" bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+
+	// JSON-serialized DB column
+	SpecJSON string `json:"-" bun:"spec"`
+}
+
+// SidecarSpec describes the container a sidecar template runs.
+type SidecarSpec struct {
+	Image     string            `json:"image"`
+	Command   []string          `json:"command,omitempty"`
+	Args      []string          `json:"args,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+	Ports     []int             `json:"ports,omitempty"`
+	Resources *ResourceLimits   `json:"resources,omitempty"`
+	// MountWorkspace mounts the session's shared workspace volume.
+	MountWorkspace bool `json:"mount_workspace,omitempty"`
+}
+
+// AvailableTo reports whether the template may be attached to apps and
+// settings of the given tenant.
+func (t *SidecarTemplate) AvailableTo(tenantID string) bool {
+	if tenantID == "" {
+		tenantID = DefaultTenantID
+	}
+	return t.TenantID == "" || t.TenantID == tenantID
+}
+
+// CreateSidecarTemplate inserts a new sidecar template.
+func (db *DB) CreateSidecarTemplate(tmpl SidecarTemplate) error {
+	now := time.Now()
+	tmpl.CreatedAt = now
+	tmpl.UpdatedAt = now
+	_, err := db.conn.NewInsert().Model(&tmpl).Exec(db.ctx())
+	return err
+}
+
+// GetSidecarTemplate returns a sidecar template by name, or nil if it does
+// not exist.
+func (db *DB) GetSidecarTemplate(name string) (*SidecarTemplate, error) {
+	var tmpl SidecarTemplate
+	err := db.conn.NewSelect().Model(&tmpl).Where("name = ?", name).Scan(db.ctx())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tmpl, nil
+}
+
+// ListSidecarTemplates returns all sidecar templates ordered by name.
+func (db *DB) ListSidecarTemplates() ([]SidecarTemplate, error) {
+	var templates []SidecarTemplate
+	err := db.conn.NewSelect().Model(&templates).OrderExpr("name").Scan(db.ctx())
+	return templates, err
+}
+
+// UpdateSidecarTemplate updates a sidecar template's description, tenant,
+// and spec.
+func (db *DB) UpdateSidecarTemplate(tmpl SidecarTemplate) error {
+	tmpl.UpdatedAt = time.Now()
+	result, err := db.conn.NewUpdate().Model(&tmpl).
+		Column("description", "tenant_id", "spec", "updated_at").
+		WherePK().
+		Exec(db.ctx())
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteSidecarTemplate removes a sidecar template by name.
+func (db *DB) DeleteSidecarTemplate(name string) error {
+	result, err := db.conn.NewDelete().Model((*SidecarTemplate)(nil)).Where("name = ?", name).Exec(db.ctx())
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SidecarTemplateUsers returns the IDs of the apps and tenants that attach
+// the named sidecar template.
+func (db *DB) SidecarTemplateUsers(name string) (appIDs, tenantIDs []string, err error) {
+	var apps []Application
+	if err := db.conn.NewSelect().Model(&apps).Scan(db.ctx()); err != nil {
+		return nil, nil, err
+	}
+	for _, app := range apps {
+		if slices.Contains(app.Sidecars, name) {
+			appIDs = append(appIDs, app.ID)
+		}
+	}
+
+	tenants, err := db.ListTenants()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, tenant := range tenants {
+		if slices.Contains(tenant.Settings.Sidecars, name) {
+			tenantIDs = append(tenantIDs, tenant.ID)
+		}
+	}
+	return appIDs, tenantIDs, nil
+}
@@ -0,0 +1,115 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestSidecarTemplateCRUD(t *testing.T) {
+	db := setupTenantTestDB(t)
+
+	got, err := db.GetSidecarTemplate("vpn")
+	if err != nil || got != nil {
+		t.Fatalf("GetSidecarTemplate() = %v, %v; want nil, nil", got, err)
+	}
+
+	tmpl := SidecarTemplate{
+		Name:        "vpn",
+		Description: "Corporate VPN client",
+		Spec: SidecarSpec{
+			Image:     "example.com/vpn:1.0",
+			Args:      []string{"--config", "/etc/vpn.conf"},
+			Env:       map[string]string{"VPN_SERVER": "vpn.example.com"},
+			Ports:     []int{1080},
+			Resources: &ResourceLimits{CPULimit: "100m", MemoryLimit: "64Mi"},
+		},
+	}
+	if err := db.CreateSidecarTemplate(tmpl); err != nil {
+		t.Fatalf("CreateSidecarTemplate() error = %v", err)
+	}
+	if err := db.CreateSidecarTemplate(tmpl); !IsDuplicateKeyError(err) {
+		t.Errorf("CreateSidecarTemplate() duplicate error = %v, want duplicate key", err)
+	}
+
+	got, err = db.GetSidecarTemplate("vpn")
+	if err != nil || got == nil {
+		t.Fatalf("GetSidecarTemplate() = %v, %v", got, err)
+	}
+	if got.Spec.Image != "example.com/vpn:1.0" || got.Spec.Env["VPN_SERVER"] != "vpn.example.com" ||
+		len(got.Spec.Ports) != 1 || got.Spec.Resources == nil || got.Spec.Resources.CPULimit != "100m" {
+		t.Errorf("GetSidecarTemplate() spec = %+v", got.Spec)
+	}
+	if got.CreatedAt.IsZero() {
+		t.Error("CreatedAt should be set")
+	}
+
+	got.Description = "Updated"
+	got.TenantID = "t1"
+	got.Spec.Image = "example.com/vpn:2.0"
+	if err := db.UpdateSidecarTemplate(*got); err != nil {
+		t.Fatalf("UpdateSidecarTemplate() error = %v", err)
+	}
+	got, _ = db.GetSidecarTemplate("vpn")
+	if got.Description != "Updated" || got.TenantID != "t1" || got.Spec.Image != "example.com/vpn:2.0" {
+		t.Errorf("after update = %+v", got)
+	}
+	if err := db.UpdateSidecarTemplate(SidecarTemplate{Name: "missing"}); err != sql.ErrNoRows {
+		t.Errorf("UpdateSidecarTemplate(missing) error = %v, want sql.ErrNoRows", err)
+	}
+
+	all, err := db.ListSidecarTemplates()
+	if err != nil || len(all) != 1 {
+		t.Errorf("ListSidecarTemplates() = %v, %v; want 1 entry", all, err)
+	}
+
+	if err := db.DeleteSidecarTemplate("vpn"); err != nil {
+		t.Fatalf("DeleteSidecarTemplate() error = %v", err)
+	}
+	if err := db.DeleteSidecarTemplate("vpn"); err != sql.ErrNoRows {
+		t.Errorf("DeleteSidecarTemplate(missing) error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestSidecarTemplateUsers(t *testing.T) {
+	db := setupTenantTestDB(t)
+
+	if err := db.CreateApp(Application{ID: "app1", Name: "App", URL: "https://example.com", Sidecars: []string{"vpn", "logs"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CreateApp(Application{ID: "app2", Name: "Other", URL: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CreateTenant(Tenant{ID: "t1", Name: "Test", Slug: "test", Settings: TenantSettings{Sidecars: []string{"vpn"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	app, _ := db.GetApp("app1")
+	if len(app.Sidecars) != 2 || app.Sidecars[0] != "vpn" {
+		t.Errorf("app sidecars = %v", app.Sidecars)
+	}
+
+	appIDs, tenantIDs, err := db.SidecarTemplateUsers("vpn")
+	if err != nil {
+		t.Fatalf("SidecarTemplateUsers() error = %v", err)
+	}
+	if len(appIDs) != 1 || appIDs[0] != "app1" || len(tenantIDs) != 1 || tenantIDs[0] != "t1" {
+		t.Errorf("SidecarTemplateUsers() = %v, %v", appIDs, tenantIDs)
+	}
+
+	appIDs, tenantIDs, _ = db.SidecarTemplateUsers("unused")
+	if len(appIDs) != 0 || len(tenantIDs) != 0 {
+		t.Errorf("SidecarTemplateUsers(unused) = %v, %v", appIDs, tenantIDs)
+	}
+}
+
+func TestSidecarTemplateAvailableTo(t *testing.T) {
+	global := &SidecarTemplate{Name: "logs"}
+	scoped := &SidecarTemplate{Name: "vpn", TenantID: "t1"}
+
+	if !global.AvailableTo("t1") || !global.AvailableTo("") {
+		t.Error("global template should be available to every tenant")
+	}
+	if !scoped.AvailableTo("t1") || scoped.AvailableTo("t2") || scoped.AvailableTo("") {
+		t.Error("tenant template should only be available to its tenant")
+	}
+}
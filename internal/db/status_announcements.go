@@ -0,0 +1,124 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// AnnouncementKind is what a status announcement is about.
+type AnnouncementKind string
+
+const (
+	// AnnouncementIncident reports an unplanned disruption.
+	AnnouncementIncident AnnouncementKind = "incident"
+	// AnnouncementMaintenance reports planned maintenance.
+	AnnouncementMaintenance AnnouncementKind = "maintenance"
+)
+
+// Valid reports whether k is a known announcement kind.
+func (k AnnouncementKind) Valid() bool {
+	return k == AnnouncementIncident || k == AnnouncementMaintenance
+}
+
+// StatusAnnouncement is an incident or maintenance notice published on the
+// status page. It is active from StartsAt until EndsAt, or until an admin
+// ends it if EndsAt is nil.
+type StatusAnnouncement struct {
+	bun.BaseModel `bun:"table:status_announcements"`
+
+	ID        string           `json:"id" bun:"id,pk"`
+	Kind      AnnouncementKind `json:"kind" bun:"kind,notnull"`
+	Title     string           `json:"title" bun:"title,notnull"`
+	Message   string           `json:"message,omitempty" bun:"message,notnull"`
+	StartsAt  time.Time        `json:"starts_at" bun:"starts_at,notnull"`
+	EndsAt    *time.Time       `json:"ends_at,omitempty" bun:"ends_at"`
+	CreatedBy string           `json:"created_by,omitempty" bun:"created_by,notnull"`
+	CreatedAt time.Time        `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	UpdatedAt time.Time        `json:"updated_at" bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// ActiveAt reports whether the announcement is in effect at t.
+func (a *StatusAnnouncement) ActiveAt(t time.Time) bool {
+	return !a.StartsAt.After(t) && (a.EndsAt == nil || a.EndsAt.After(t))
+}
+
+// CreateStatusAnnouncement inserts a new announcement.
+func (db *DB) CreateStatusAnnouncement(a StatusAnnouncement) error {
+	_, err := db.conn.NewInsert().Model(&a).Exec(db.ctx())
+	return err
+}
+
+// GetStatusAnnouncement returns an announcement by ID, or nil if it does
+// not exist.
+func (db *DB) GetStatusAnnouncement(id string) (*StatusAnnouncement, error) {
+	var a StatusAnnouncement
+	err := db.conn.NewSelect().Model(&a).Where("id = ?", id).Scan(db.ctx())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ListStatusAnnouncements returns the most recently started announcements,
+// up to limit (0 = all).
+func (db *DB) ListStatusAnnouncements(limit int) ([]StatusAnnouncement, error) {
+	announcements := []StatusAnnouncement{}
+	q := db.conn.NewSelect().Model(&announcements).OrderExpr("starts_at DESC, id ASC")
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	err := q.Scan(db.ctx())
+	return announcements, err
+}
+
+// ListOpenStatusAnnouncements returns the announcements that have not ended
+// by t, active or upcoming, soonest first.
+func (db *DB) ListOpenStatusAnnouncements(t time.Time) ([]StatusAnnouncement, error) {
+	announcements := []StatusAnnouncement{}
+	err := db.conn.NewSelect().Model(&announcements).
+		Where("ends_at IS NULL OR ends_at > ?", t).
+		OrderExpr("starts_at ASC, id ASC").
+		Scan(db.ctx())
+	return announcements, err
+}
+
+// UpdateStatusAnnouncement updates an announcement's kind, text and times.
+func (db *DB) UpdateStatusAnnouncement(a StatusAnnouncement) error {
+	a.UpdatedAt = time.Now()
+	result, err := db.conn.NewUpdate().Model(&a).
+		Column("kind", "title", "message", "starts_at", "ends_at", "updated_at").
+		WherePK().
+		Exec(db.ctx())
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteStatusAnnouncement deletes an announcement.
+func (db *DB) DeleteStatusAnnouncement(id string) error {
+	result, err := db.conn.NewDelete().Model((*StatusAnnouncement)(nil)).Where("id = ?", id).Exec(db.ctx())
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStatusAnnouncements(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	ended := now.Add(-time.Hour)
+	later := now.Add(2 * time.Hour)
+
+	for _, a := range []StatusAnnouncement{
+		{ID: "a1", Kind: AnnouncementIncident, Title: "Slow launches", StartsAt: now.Add(-2 * time.Hour), EndsAt: &ended, CreatedBy: "admin"},
+		{ID: "a2", Kind: AnnouncementIncident, Title: "Database degraded", StartsAt: now.Add(-time.Minute), CreatedBy: "admin"},
+		{ID: "a3", Kind: AnnouncementMaintenance, Title: "Cluster upgrade", StartsAt: now.Add(time.Hour), EndsAt: &later, CreatedBy: "admin"},
+	} {
+		if err := db.CreateStatusAnnouncement(a); err != nil {
+			t.Fatalf("CreateStatusAnnouncement(%s) error = %v", a.ID, err)
+		}
+	}
+
+	got, err := db.GetStatusAnnouncement("a3")
+	if err != nil || got == nil || got.Kind != AnnouncementMaintenance || got.EndsAt == nil || !got.EndsAt.Equal(later) {
+		t.Fatalf("GetStatusAnnouncement(a3) = %+v, %v", got, err)
+	}
+	if got.ActiveAt(now) || !got.ActiveAt(now.Add(90*time.Minute)) || got.ActiveAt(later) {
+		t.Errorf("ActiveAt() of a3 is wrong around %v-%v", got.StartsAt, got.EndsAt)
+	}
+	if missing, err := db.GetStatusAnnouncement("missing"); missing != nil || err != nil {
+		t.Errorf("GetStatusAnnouncement(missing) = %v, %v, want nil", missing, err)
+	}
+
+	all, err := db.ListStatusAnnouncements(0)
+	if err != nil || len(all) != 3 || all[0].ID != "a3" || all[2].ID != "a1" {
+		t.Errorf("ListStatusAnnouncements() = %+v, %v, want a3, a2, a1", all, err)
+	}
+	if recent, _ := db.ListStatusAnnouncements(1); len(recent) != 1 {
+		t.Errorf("ListStatusAnnouncements(1) = %d announcements, want 1", len(recent))
+	}
+
+	open, err := db.ListOpenStatusAnnouncements(now)
+	if err != nil || len(open) != 2 || open[0].ID != "a2" || open[1].ID != "a3" {
+		t.Errorf("ListOpenStatusAnnouncements() = %+v, %v, want a2, a3", open, err)
+	}
+
+	// Ending an incident closes it
+	got, _ = db.GetStatusAnnouncement("a2")
+	got.EndsAt = &now
+	if err := db.UpdateStatusAnnouncement(*got); err != nil {
+		t.Fatalf("UpdateStatusAnnouncement() error = %v", err)
+	}
+	if open, _ := db.ListOpenStatusAnnouncements(now); len(open) != 1 || open[0].ID != "a3" {
+		t.Errorf("ListOpenStatusAnnouncements() after ending a2 = %+v, want a3", open)
+	}
+
+	if err := db.DeleteStatusAnnouncement("a1"); err != nil {
+		t.Fatalf("DeleteStatusAnnouncement() error = %v", err)
+	}
+	if err := db.DeleteStatusAnnouncement("a1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("DeleteStatusAnnouncement() again error = %v, want ErrNoRows", err)
+	}
+	if err := db.UpdateStatusAnnouncement(StatusAnnouncement{ID: "a1", Kind: AnnouncementIncident}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("UpdateStatusAnnouncement(deleted) error = %v, want ErrNoRows", err)
+	}
+}
@@ -3,6 +3,9 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/uptrace/bun"
@@ -27,10 +30,234 @@ type Tenant struct {
 
 // TenantSettings holds tenant-specific configuration
 type TenantSettings struct {
-	PrimaryColor   string `json:"primary_color,omitempty"`
-	SecondaryColor string `json:"secondary_color,omitempty"`
-	LogoURL        string `json:"logo_url,omitempty"`
-	DisplayName    string `json:"display_name,omitempty"`
+	PrimaryColor   string       `json:"primary_color,omitempty"`
+	SecondaryColor string       `json:"secondary_color,omitempty"`
+	LogoURL        string       `json:"logo_url,omitempty"`
+	DisplayName    string       `json:"display_name,omitempty"`
+	TokenPolicy    *TokenPolicy `json:"token_policy,omitempty"`
+	// MFAPolicy requires local accounts to sign in with a TOTP code.
+	MFAPolicy *MFAPolicy `json:"mfa_policy,omitempty"`
+	// SpectatePolicy controls how admins may watch users' sessions.
+	// Empty means DefaultSpectatePolicy.
+	SpectatePolicy SpectatePolicy `json:"spectate_policy,omitempty"`
+	// Sidecars names the sidecar templates added to every session pod of
+	// the tenant's apps.
+	Sidecars []string `json:"sidecars,omitempty"`
+	// UploadPolicy restricts files uploaded into the tenant's sessions,
+	// in addition to the server-wide policy.
+	UploadPolicy *UploadPolicy `json:"upload_policy,omitempty"`
+	// Defaults are session settings for the tenant's apps that neither the
+	// app nor its category set. Resource limits fall back to the
+	// default_* quotas.
+	Defaults *PolicyDefaults `json:"defaults,omitempty"`
+	// OutboundAllowlist limits the destinations of outbound requests made
+	// for the tenant, such as webhooks, to these IPs, CIDR ranges, host
+	// names and "*.domain" wildcards. It narrows the server-wide policy
+	// and cannot open internal addresses. Empty allows any destination the
+	// server-wide policy does.
+	OutboundAllowlist []string `json:"outbound_allowlist,omitempty"`
+	// Storage keeps the tenant's recordings in its own S3 bucket, for
+	// data residency, instead of the server's recording storage.
+	Storage *StorageLocation `json:"storage,omitempty"`
+	// ImagePullSecret names the Secret in the sessions namespace used to
+	// pull images of the tenant's apps that do not set their own.
+	ImagePullSecret string `json:"image_pull_secret,omitempty"`
+}
+
+// DefaultEgressPolicy returns the egress policy of the tenant's defaults, or
+// nil if it sets none.
+func (s TenantSettings) DefaultEgressPolicy() *EgressPolicy {
+	if s.Defaults == nil {
+		return nil
+	}
+	return s.Defaults.EgressPolicy
+}
+
+// SetDefaultEgressPolicy sets the egress policy of the tenant's defaults.
+func (s *TenantSettings) SetDefaultEgressPolicy(p *EgressPolicy) {
+	if s.Defaults == nil {
+		if p == nil {
+			return
+		}
+		s.Defaults = &PolicyDefaults{}
+	}
+	s.Defaults.EgressPolicy = p
+}
+
+// SpectatePolicy controls what a user is told when an admin watches their
+// session.
+type SpectatePolicy string
+
+const (
+	// SpectateSilent lets admins watch without notifying the user.
+	SpectateSilent SpectatePolicy = "silent"
+	// SpectateNotify notifies the user when an admin starts and stops watching.
+	SpectateNotify SpectatePolicy = "notify"
+	// SpectateRequireConsent requires the user to approve each request.
+	SpectateRequireConsent SpectatePolicy = "require_consent"
+)
+
+// DefaultSpectatePolicy applies to tenants that do not set a policy.
+const DefaultSpectatePolicy = SpectateNotify
+
+// Valid reports whether p is a known policy. The empty policy is valid and
+// means DefaultSpectatePolicy.
+func (p SpectatePolicy) Valid() bool {
+	switch p {
+	case "", SpectateSilent, SpectateNotify, SpectateRequireConsent:
+		return true
+	}
+	return false
+}
+
+// OrDefault returns p, or DefaultSpectatePolicy if p is empty.
+func (p SpectatePolicy) OrDefault() SpectatePolicy {
+	if p == "" {
+		return DefaultSpectatePolicy
+	}
+	return p
+}
+
+// TokenLifetimes overrides JWT lifetimes and refresh behavior. Zero values
+// inherit from the next level up: role, then tenant, then global config.
+type TokenLifetimes struct {
+	AccessExpiryMinutes int   `json:"access_expiry_minutes,omitempty"`
+	RefreshExpiryHours  int   `json:"refresh_expiry_hours,omitempty"`
+	RotateRefreshTokens *bool `json:"rotate_refresh_tokens,omitempty"` // Issue a new refresh token on every refresh
+}
+
+// TokenPolicy holds a tenant's token lifetime overrides, with optional
+// per-role overrides layered on top (e.g. shorter lifetimes for admins).
+type TokenPolicy struct {
+	TokenLifetimes
+	Roles map[string]TokenLifetimes `json:"roles,omitempty"`
+}
+
+// Validate checks that all configured lifetimes are non-negative.
+func (p *TokenPolicy) Validate() error {
+	check := func(scope string, l TokenLifetimes) error {
+		if l.AccessExpiryMinutes < 0 {
+			return fmt.Errorf("%s: access_expiry_minutes must be non-negative, got %d", scope, l.AccessExpiryMinutes)
+		}
+		if l.RefreshExpiryHours < 0 {
+			return fmt.Errorf("%s: refresh_expiry_hours must be non-negative, got %d", scope, l.RefreshExpiryHours)
+		}
+		return nil
+	}
+	if err := check("token_policy", p.TokenLifetimes); err != nil {
+		return err
+	}
+	for role, l := range p.Roles {
+		if err := check("token_policy.roles."+role, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MFAPolicy decides which of a tenant's local accounts must use TOTP. With
+// Required set it applies to everyone; otherwise only to users holding one
+// of Roles, matched against both global and tenant roles.
+type MFAPolicy struct {
+	Required bool     `json:"required,omitempty"`
+	Roles    []string `json:"roles,omitempty"`
+}
+
+// Validate checks that no role is empty.
+func (p *MFAPolicy) Validate() error {
+	for _, role := range p.Roles {
+		if strings.TrimSpace(role) == "" {
+			return fmt.Errorf("mfa_policy: roles must not be empty")
+		}
+	}
+	return nil
+}
+
+// Requires reports whether the policy requires MFA for a user with roles.
+func (p *MFAPolicy) Requires(roles []string) bool {
+	if p == nil {
+		return false
+	}
+	if p.Required {
+		return true
+	}
+	for _, role := range roles {
+		if slices.Contains(p.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// UploadPolicy allows or blocks uploaded files by extension and by MIME
+// type sniffed from their content. Extensions include the dot and may have
+// several parts (".tar.gz"). Types may end in "/*" to match a whole family
+// ("text/*"). Blocks win over allows, and a non-empty allow list rejects
+// anything it does not match.
+type UploadPolicy struct {
+	AllowedExtensions []string `json:"allowed_extensions,omitempty"`
+	BlockedExtensions []string `json:"blocked_extensions,omitempty"`
+	AllowedTypes      []string `json:"allowed_types,omitempty"`
+	BlockedTypes      []string `json:"blocked_types,omitempty"`
+}
+
+// Validate checks that extensions start with a dot and types have the
+// form type/subtype.
+func (p *UploadPolicy) Validate() error {
+	for _, ext := range append(slices.Clone(p.AllowedExtensions), p.BlockedExtensions...) {
+		if len(ext) < 2 || !strings.HasPrefix(ext, ".") {
+			return fmt.Errorf("upload_policy: extension %q must start with a dot", ext)
+		}
+	}
+	for _, t := range append(slices.Clone(p.AllowedTypes), p.BlockedTypes...) {
+		major, minor, ok := strings.Cut(t, "/")
+		if !ok || major == "" || minor == "" {
+			return fmt.Errorf("upload_policy: type %q must have the form type/subtype", t)
+		}
+	}
+	return nil
+}
+
+// IsEmpty reports whether the policy allows every file.
+func (p *UploadPolicy) IsEmpty() bool {
+	return p == nil || len(p.AllowedExtensions)+len(p.BlockedExtensions)+len(p.AllowedTypes)+len(p.BlockedTypes) == 0
+}
+
+// StorageLocation is an S3 bucket holding a tenant's data. It is reached
+// with the server's S3 endpoint and credentials.
+type StorageLocation struct {
+	Bucket string `json:"bucket"`
+	// Region of the bucket (empty = the server's S3 region)
+	Region string `json:"region,omitempty"`
+	// Prefix is prepended to object keys, e.g. "recordings/"
+	Prefix string `json:"prefix,omitempty"`
+	// KMSKeyID encrypts objects with this AWS KMS key (SSE-KMS) instead
+	// of the bucket's default encryption
+	KMSKeyID string `json:"kms_key_id,omitempty"`
+}
+
+// storageBucketPattern matches S3 bucket names.
+var storageBucketPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// storageRegionPattern matches AWS region names, such as eu-central-1.
+var storageRegionPattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// Validate checks the bucket and region names and that the prefix is
+// relative.
+func (l *StorageLocation) Validate() error {
+	if !storageBucketPattern.MatchString(l.Bucket) {
+		return fmt.Errorf("storage: bucket %q is not a valid S3 bucket name", l.Bucket)
+	}
+	if l.Region != "" && !storageRegionPattern.MatchString(l.Region) {
+		return fmt.Errorf("storage: region %q is not a valid region name", l.Region)
+	}
+	if strings.HasPrefix(l.Prefix, "/") {
+		return fmt.Errorf("storage: prefix must not start with /")
+	}
+	if strings.TrimSpace(l.KMSKeyID) != l.KMSKeyID {
+		return fmt.Errorf("storage: kms_key_id must not have surrounding spaces")
+	}
+	return nil
 }
 
 // TenantQuotas holds per-tenant resource quotas
@@ -43,6 +270,9 @@ type TenantQuotas struct {
 	DefaultCPULimit    string `json:"default_cpu_limit,omitempty"`
 	DefaultMemRequest  string `json:"default_mem_request,omitempty"`
 	DefaultMemLimit    string `json:"default_mem_limit,omitempty"`
+
+	MaxTransfersPerUser   int `json:"max_transfers_per_user,omitempty"`  // 0 = use global default
+	TransferBandwidthKBps int `json:"transfer_bandwidth_kbps,omitempty"` // 0 = use global default
 }
 
 // DefaultTenantID is the ID of the default tenant used for backwards compatibility
@@ -53,14 +283,14 @@ func (db *DB) CreateTenant(tenant Tenant) error {
 	now := time.Now()
 	tenant.CreatedAt = now
 	tenant.UpdatedAt = now
-	_, err := db.bun.NewInsert().Model(&tenant).Exec(ctx())
+	_, err := db.conn.NewInsert().Model(&tenant).Exec(db.ctx())
 	return err
 }
 
 // GetTenant retrieves a tenant by ID
 func (db *DB) GetTenant(id string) (*Tenant, error) {
 	var t Tenant
-	err := db.bun.NewSelect().Model(&t).Where("id = ?", id).Scan(ctx())
+	err := db.conn.NewSelect().Model(&t).Where("id = ?", id).Scan(db.ctx())
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -73,7 +303,7 @@ func (db *DB) GetTenant(id string) (*Tenant, error) {
 // GetTenantBySlug retrieves a tenant by slug
 func (db *DB) GetTenantBySlug(slug string) (*Tenant, error) {
 	var t Tenant
-	err := db.bun.NewSelect().Model(&t).Where("slug = ?", slug).Scan(ctx())
+	err := db.conn.NewSelect().Model(&t).Where("slug = ?", slug).Scan(db.ctx())
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -86,17 +316,17 @@ func (db *DB) GetTenantBySlug(slug string) (*Tenant, error) {
 // ListTenants returns all tenants
 func (db *DB) ListTenants() ([]Tenant, error) {
 	var tenants []Tenant
-	err := db.bun.NewSelect().Model(&tenants).OrderExpr("name").Scan(ctx())
+	err := db.conn.NewSelect().Model(&tenants).OrderExpr("name").Scan(db.ctx())
 	return tenants, err
 }
 
 // UpdateTenant updates an existing tenant
 func (db *DB) UpdateTenant(tenant Tenant) error {
 	tenant.UpdatedAt = time.Now()
-	result, err := db.bun.NewUpdate().Model(&tenant).
+	result, err := db.conn.NewUpdate().Model(&tenant).
 		Column("name", "slug", "settings", "quotas", "updated_at").
 		WherePK().
-		Exec(ctx())
+		Exec(db.ctx())
 	if err != nil {
 		return err
 	}
@@ -116,7 +346,7 @@ func (db *DB) DeleteTenant(id string) error {
 		return fmt.Errorf("cannot delete the default tenant")
 	}
 
-	result, err := db.bun.NewDelete().Model((*Tenant)(nil)).Where("id = ?", id).Exec(ctx())
+	result, err := db.conn.NewDelete().Model((*Tenant)(nil)).Where("id = ?", id).Exec(db.ctx())
 	if err != nil {
 		return err
 	}
@@ -127,7 +357,8 @@ func (db *DB) DeleteTenant(id string) error {
 	if rows == 0 {
 		return sql.ErrNoRows
 	}
-	return nil
+	// Uploaded assets become orphans and are removed from the storage browser
+	return db.DeleteTenantBranding(id)
 }
 
 // Tenant-scoped query methods
@@ -135,56 +366,79 @@ func (db *DB) DeleteTenant(id string) error {
 // ListAppsByTenant returns all applications belonging to a tenant
 func (db *DB) ListAppsByTenant(tenantID string) ([]Application, error) {
 	var apps []Application
-	err := db.bun.NewSelect().Model(&apps).
+	err := db.conn.NewSelect().Model(&apps).
 		Where("tenant_id = ?", tenantID).
 		OrderExpr("category, name").
-		Scan(ctx())
+		Scan(db.ctx())
 	return apps, err
 }
 
 // ListUsersByTenant returns all users belonging to a tenant
 func (db *DB) ListUsersByTenant(tenantID string) ([]User, error) {
 	var users []User
-	err := db.bun.NewSelect().Model(&users).
+	err := db.conn.NewSelect().Model(&users).
 		Where("tenant_id = ?", tenantID).
 		OrderExpr("created_at DESC").
-		Scan(ctx())
+		Scan(db.ctx())
 	return users, err
 }
 
 // ListSessionsByTenant returns all active sessions belonging to a tenant
 func (db *DB) ListSessionsByTenant(tenantID string) ([]Session, error) {
 	var sessions []Session
-	err := db.bun.NewSelect().Model(&sessions).
+	err := db.conn.NewSelect().Model(&sessions).
 		Where("tenant_id = ?", tenantID).
 		Where("status NOT IN (?, ?)", "terminated", "failed").
 		OrderExpr("created_at DESC").
-		Scan(ctx())
+		Scan(db.ctx())
 	return sessions, err
 }
 
 // CountActiveSessionsByTenant returns the number of active sessions for a tenant
 func (db *DB) CountActiveSessionsByTenant(tenantID string) (int, error) {
-	count, err := db.bun.NewSelect().Model((*Session)(nil)).
+	count, err := db.conn.NewSelect().Model((*Session)(nil)).
 		Where("tenant_id = ?", tenantID).
 		Where("status IN (?, ?)", "creating", "running").
-		Count(ctx())
+		Count(db.ctx())
 	return count, err
 }
 
+// CountActiveSessionsPerTenant returns the number of active (creating or
+// running) sessions in each tenant that has any.
+func (db *DB) CountActiveSessionsPerTenant() (map[string]int, error) {
+	var rows []struct {
+		TenantID string `bun:"tenant_id"`
+		Count    int    `bun:"count"`
+	}
+	err := db.conn.NewSelect().Model((*Session)(nil)).
+		ColumnExpr("tenant_id").
+		ColumnExpr("COUNT(*) AS count").
+		Where("status IN (?, ?)", "creating", "running").
+		Group("tenant_id").
+		Scan(db.ctx(), &rows)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int, len(rows))
+	for _, r := range rows {
+		counts[r.TenantID] = r.Count
+	}
+	return counts, nil
+}
+
 // CountUsersByTenant returns the number of users in a tenant
 func (db *DB) CountUsersByTenant(tenantID string) (int, error) {
-	count, err := db.bun.NewSelect().Model((*User)(nil)).
+	count, err := db.conn.NewSelect().Model((*User)(nil)).
 		Where("tenant_id = ?", tenantID).
-		Count(ctx())
+		Count(db.ctx())
 	return count, err
 }
 
 // CountAppsByTenant returns the number of apps in a tenant
 func (db *DB) CountAppsByTenant(tenantID string) (int, error) {
-	count, err := db.bun.NewSelect().Model((*Application)(nil)).
+	count, err := db.conn.NewSelect().Model((*Application)(nil)).
 		Where("tenant_id = ?", tenantID).
-		Count(ctx())
+		Count(db.ctx())
 	return count, err
 }
 
@@ -196,13 +450,13 @@ func (db *DB) LogAuditWithTenant(tenantID, user, action, details string) error {
 		Action:   action,
 		Details:  details,
 	}
-	_, err := db.bun.NewInsert().Model(&log).Exec(ctx())
+	_, err := db.conn.NewInsert().Model(&log).Exec(db.ctx())
 	return err
 }
 
 // QueryAuditLogsByTenant returns audit logs for a specific tenant
 func (db *DB) QueryAuditLogsByTenant(tenantID string, filter AuditLogFilter) (*AuditLogPage, error) {
-	q := db.bun.NewSelect().Model((*AuditLog)(nil)).Where("tenant_id = ?", tenantID)
+	q := db.conn.NewSelect().Model((*AuditLog)(nil)).Where("tenant_id = ?", tenantID)
 
 	if filter.User != "" {
 		q = q.Where("\"user\" = ?", filter.User)
@@ -210,6 +464,9 @@ func (db *DB) QueryAuditLogsByTenant(tenantID string, filter AuditLogFilter) (*A
 	if filter.Action != "" {
 		q = q.Where("action = ?", filter.Action)
 	}
+	if filter.RequestID != "" {
+		q = q.Where("request_id = ?", filter.RequestID)
+	}
 	if !filter.From.IsZero() {
 		q = q.Where("timestamp >= ?", filter.From)
 	}
@@ -217,7 +474,7 @@ func (db *DB) QueryAuditLogsByTenant(tenantID string, filter AuditLogFilter) (*A
 		q = q.Where("timestamp <= ?", filter.To)
 	}
 
-	total, err := q.Count(ctx())
+	total, err := q.Count(db.ctx())
 	if err != nil {
 		return nil, fmt.Errorf("failed to count audit logs: %w", err)
 	}
@@ -229,7 +486,7 @@ func (db *DB) QueryAuditLogsByTenant(tenantID string, filter AuditLogFilter) (*A
 	offset := max(filter.Offset, 0)
 
 	var logs []AuditLog
-	err = q.OrderExpr("timestamp DESC").Limit(limit).Offset(offset).Scan(ctx(), &logs)
+	err = q.OrderExpr("timestamp DESC").Limit(limit).Offset(offset).Scan(db.ctx(), &logs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query audit logs: %w", err)
 	}
@@ -231,3 +231,50 @@ func TestUserTenantFields(t *testing.T) {
 		t.Errorf("expected tenant_roles [tenant-admin], got %v", got.TenantRoles)
 	}
 }
+
+func TestUploadPolicyValidate(t *testing.T) {
+	valid := UploadPolicy{
+		AllowedExtensions: []string{".csv", ".tar.gz"},
+		BlockedTypes:      []string{"application/x-executable", "text/*"},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+
+	for _, p := range []UploadPolicy{
+		{BlockedExtensions: []string{"exe"}},
+		{AllowedExtensions: []string{"."}},
+		{AllowedTypes: []string{"text"}},
+		{BlockedTypes: []string{"/plain"}},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", p)
+		}
+	}
+
+	var none *UploadPolicy
+	if !none.IsEmpty() || !(&UploadPolicy{}).IsEmpty() || valid.IsEmpty() {
+		t.Error("IsEmpty() wrong")
+	}
+}
+
+func TestMFAPolicy(t *testing.T) {
+	var none *MFAPolicy
+	if none.Requires([]string{"admin"}) {
+		t.Error("nil policy requires MFA")
+	}
+	if !(&MFAPolicy{Required: true}).Requires(nil) {
+		t.Error("Required policy does not require MFA")
+	}
+	byRole := &MFAPolicy{Roles: []string{"admin", "tenant-admin"}}
+	if !byRole.Requires([]string{"user", "tenant-admin"}) || byRole.Requires([]string{"user"}) {
+		t.Error("role policy does not match on roles")
+	}
+
+	if err := byRole.Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+	if err := (&MFAPolicy{Roles: []string{" "}}).Validate(); err == nil {
+		t.Error("Validate() accepted an empty role")
+	}
+}
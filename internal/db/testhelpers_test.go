@@ -2,10 +2,16 @@ package db
 
 import (
 	"database/sql"
+	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/golang-migrate/migrate/v4"
+
 	_ "github.com/lib/pq"
 )
 
@@ -60,7 +66,7 @@ func truncateAllTables(t *testing.T, database *DB) {
 	t.Helper()
 
 	tables := []string{
-		"session_shares", "recordings",
+		"setting_change_requests", "remote_catalogs", "lint_warnings", "registry_credentials", "status_announcements", "project_members", "projects", "api_usage", "scheduled_sessions", "session_launches", "job_runs", "health_events", "password_reset_tokens", "webauthn_challenges", "webauthn_credentials", "mfa_backup_codes", "user_mfa", "api_tokens", "recording_chunks", "session_file_events", "idp_tokens", "leader_leases", "session_history", "session_archive", "quota_bursts", "session_attestations", "sidecar_templates", "tenant_branding", "refresh_tokens", "session_shares", "recordings",
 		"category_approved_users", "category_admins",
 		"categories", "oidc_states", "app_specs", "templates",
 		"settings", "analytics", "sessions", "audit_log",
@@ -135,6 +141,56 @@ func openRawPostgresConn(t *testing.T, dsn string) *sql.DB {
 	return conn
 }
 
+// latestMigrationVersion returns the highest migration version embedded for
+// the given database type, so tests don't need updating for every new
+// migration.
+func latestMigrationVersion(t *testing.T, dbType string) uint {
+	t.Helper()
+
+	fsys, dir := sqliteMigrations, "migrations/sqlite"
+	if dbType == "postgres" {
+		fsys, dir = postgresMigrations, "migrations/postgres"
+	}
+
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		t.Fatalf("latestMigrationVersion: failed to read migrations: %v", err)
+	}
+
+	var latest uint
+	for _, e := range entries {
+		prefix, _, ok := strings.Cut(e.Name(), "_")
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			continue
+		}
+		if uint(v) > latest {
+			latest = uint(v)
+		}
+	}
+	return latest
+}
+
+// rewindMigrations runs down migrations until the database is at the given
+// version. Used by data-migration tests that need later migrations to run
+// again on the next Open.
+func rewindMigrations(t *testing.T, dbType, dsn string, version uint) {
+	t.Helper()
+
+	m, err := NewMigrator(dbType, dsn)
+	if err != nil {
+		t.Fatalf("rewindMigrations: NewMigrator() error = %v", err)
+	}
+	defer m.Close()
+
+	if err := m.Migrate(version); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatalf("rewindMigrations: m.Migrate(%d) error = %v", version, err)
+	}
+}
+
 // --- Tests for the test helper infrastructure itself ---
 
 func TestNewTestDatabase_ReturnsWorkingDB(t *testing.T) {
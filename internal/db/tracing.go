@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/uptrace/bun"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans this package creates.
+const tracerName = "github.com/rjsadow/sortie/internal/db"
+
+// maxTracedQuery caps the SQL recorded on a span.
+const maxTracedQuery = 2048
+
+// tracingHook records a span for each query run within a trace, which
+// means through a DB from WithContext with a context carrying a span.
+type tracingHook struct {
+	system attribute.KeyValue
+}
+
+var _ bun.QueryHook = tracingHook{}
+
+func newTracingHook(dbType string) tracingHook {
+	if dbType == "postgres" {
+		return tracingHook{system: semconv.DBSystemNamePostgreSQL}
+	}
+	return tracingHook{system: semconv.DBSystemNameSQLite}
+}
+
+func (h tracingHook) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		// Don't start a trace for every background query
+		return ctx
+	}
+	ctx, _ = otel.Tracer(tracerName).Start(ctx, "db."+event.Operation(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(h.system, semconv.DBOperationName(event.Operation())))
+	return ctx
+}
+
+func (h tracingHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return
+	}
+	span := trace.SpanFromContext(ctx)
+	query := event.Query
+	if len(query) > maxTracedQuery {
+		query = query[:maxTracedQuery]
+	}
+	span.SetAttributes(semconv.DBQueryText(query))
+	if event.Err != nil && !errors.Is(event.Err, sql.ErrNoRows) {
+		span.RecordError(event.Err)
+		span.SetStatus(codes.Error, event.Err.Error())
+	}
+	span.End()
+}
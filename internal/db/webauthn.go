@@ -0,0 +1,152 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// WebAuthnCredential is a passkey a user registered to sign in with.
+type WebAuthnCredential struct {
+	bun.BaseModel `bun:"table:webauthn_credentials"`
+
+	ID         string     `json:"id" bun:"id,pk"` // Base64url credential ID
+	UserID     string     `json:"user_id" bun:"user_id,notnull"`
+	Name       string     `json:"name" bun:"name,notnull"`
+	PublicKey  string     `json:"-" bun:"public_key,notnull"` // Base64url COSE key
+	SignCount  int64      `json:"-" bun:"sign_count,notnull"`
+	CreatedAt  time.Time  `json:"created_at" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" bun:"last_used_at"`
+}
+
+// WebAuthnChallenge is a challenge issued to begin registering a passkey
+// (with UserID set) or signing in with one (UserID empty).
+type WebAuthnChallenge struct {
+	bun.BaseModel `bun:"table:webauthn_challenges"`
+
+	Challenge string    `bun:"challenge,pk"`
+	UserID    string    `bun:"user_id,notnull"`
+	ExpiresAt time.Time `bun:"expires_at,notnull"`
+}
+
+// CreateWebAuthnCredential stores a newly registered passkey.
+func (db *DB) CreateWebAuthnCredential(cred WebAuthnCredential) error {
+	if cred.CreatedAt.IsZero() {
+		cred.CreatedAt = time.Now()
+	}
+	_, err := db.conn.NewInsert().Model(&cred).Exec(db.ctx())
+	return err
+}
+
+// GetWebAuthnCredential returns a passkey by credential ID, or nil if there
+// is none.
+func (db *DB) GetWebAuthnCredential(id string) (*WebAuthnCredential, error) {
+	var cred WebAuthnCredential
+	err := db.conn.NewSelect().Model(&cred).Where("id = ?", id).Scan(db.ctx())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cred, nil
+}
+
+// ListWebAuthnCredentials returns a user's passkeys, oldest first.
+func (db *DB) ListWebAuthnCredentials(userID string) ([]WebAuthnCredential, error) {
+	creds := []WebAuthnCredential{}
+	err := db.conn.NewSelect().Model(&creds).
+		Where("user_id = ?", userID).
+		Order("created_at ASC").
+		Scan(db.ctx())
+	return creds, err
+}
+
+// UseWebAuthnCredential records a sign-in with a passkey and the
+// authenticator's new signature counter. It returns false if another
+// sign-in already moved the counter to signCount or beyond, which suggests
+// a cloned authenticator. Authenticators that don't count always report 0,
+// which is accepted.
+func (db *DB) UseWebAuthnCredential(id string, signCount int64) (bool, error) {
+	q := db.conn.NewUpdate().Model((*WebAuthnCredential)(nil)).
+		Set("sign_count = ?", signCount).
+		Set("last_used_at = ?", time.Now()).
+		Where("id = ?", id)
+	if signCount > 0 {
+		q = q.Where("sign_count < ?", signCount)
+	} else {
+		q = q.Where("sign_count = 0")
+	}
+	result, err := q.Exec(db.ctx())
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// DeleteWebAuthnCredential removes one of a user's passkeys. It returns
+// false if the user has no passkey with that ID.
+func (db *DB) DeleteWebAuthnCredential(userID, id string) (bool, error) {
+	result, err := db.conn.NewDelete().Model((*WebAuthnCredential)(nil)).
+		Where("id = ?", id).
+		Where("user_id = ?", userID).
+		Exec(db.ctx())
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// DeleteUserWebAuthnCredentials removes all of a user's passkeys.
+func (db *DB) DeleteUserWebAuthnCredentials(userID string) error {
+	_, err := db.conn.NewDelete().Model((*WebAuthnCredential)(nil)).
+		Where("user_id = ?", userID).
+		Exec(db.ctx())
+	return err
+}
+
+// SaveWebAuthnChallenge stores a challenge until expiresAt, and removes
+// challenges that expired without being used.
+func (db *DB) SaveWebAuthnChallenge(challenge, userID string, expiresAt time.Time) error {
+	return db.WithTx(func(tx *DB) error {
+		if _, err := tx.conn.NewDelete().Model((*WebAuthnChallenge)(nil)).
+			Where("expires_at < ?", time.Now()).
+			Exec(tx.ctx()); err != nil {
+			return err
+		}
+		_, err := tx.conn.NewInsert().Model(&WebAuthnChallenge{
+			Challenge: challenge,
+			UserID:    userID,
+			ExpiresAt: expiresAt,
+		}).Exec(tx.ctx())
+		return err
+	})
+}
+
+// ConsumeWebAuthnChallenge atomically loads and deletes a challenge, so
+// each can complete only one ceremony. It returns nil if the challenge is
+// unknown or has expired.
+func (db *DB) ConsumeWebAuthnChallenge(challenge string) (*WebAuthnChallenge, error) {
+	var entry WebAuthnChallenge
+	err := db.runInTx(func(txCtx context.Context, tx bun.Tx) error {
+		if err := tx.NewSelect().Model(&entry).Where("challenge = ?", challenge).Scan(txCtx); err != nil {
+			return err
+		}
+		_, err := tx.NewDelete().Model((*WebAuthnChallenge)(nil)).Where("challenge = ?", challenge).Exec(txCtx)
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(entry.ExpiresAt) {
+		return nil, nil
+	}
+	return &entry, nil
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWebAuthnCredentials(t *testing.T) {
+	db := setupTestDB(t)
+
+	if err := db.CreateWebAuthnCredential(WebAuthnCredential{ID: "cred-1", UserID: "user-1", Name: "Laptop", PublicKey: "key-1"}); err != nil {
+		t.Fatalf("CreateWebAuthnCredential() error = %v", err)
+	}
+	if err := db.CreateWebAuthnCredential(WebAuthnCredential{ID: "cred-2", UserID: "user-1", Name: "Phone", PublicKey: "key-2", SignCount: 5}); err != nil {
+		t.Fatalf("CreateWebAuthnCredential() error = %v", err)
+	}
+	if err := db.CreateWebAuthnCredential(WebAuthnCredential{ID: "cred-1", UserID: "user-2", PublicKey: "key-x"}); err == nil {
+		t.Error("CreateWebAuthnCredential() accepted a duplicate credential ID")
+	}
+
+	got, err := db.GetWebAuthnCredential("cred-1")
+	if err != nil || got == nil || got.UserID != "user-1" || got.PublicKey != "key-1" {
+		t.Fatalf("GetWebAuthnCredential() = %+v, %v", got, err)
+	}
+	if got, err := db.GetWebAuthnCredential("missing"); err != nil || got != nil {
+		t.Errorf("GetWebAuthnCredential(missing) = %+v, %v; want nil", got, err)
+	}
+	if creds, err := db.ListWebAuthnCredentials("user-1"); err != nil || len(creds) != 2 {
+		t.Errorf("ListWebAuthnCredentials() = %v, %v; want 2", creds, err)
+	}
+
+	t.Run("sign counter", func(t *testing.T) {
+		for _, tt := range []struct {
+			id    string
+			count int64
+			want  bool
+		}{
+			{"cred-1", 0, true}, // Authenticators without a counter
+			{"cred-1", 0, true},
+			{"cred-2", 5, false}, // Counter must increase
+			{"cred-2", 6, true},
+			{"cred-2", 0, false}, // A counting authenticator can't stop
+		} {
+			if ok, err := db.UseWebAuthnCredential(tt.id, tt.count); err != nil || ok != tt.want {
+				t.Errorf("UseWebAuthnCredential(%s, %d) = %v, %v; want %v", tt.id, tt.count, ok, err, tt.want)
+			}
+		}
+		if got, _ := db.GetWebAuthnCredential("cred-2"); got.SignCount != 6 || got.LastUsedAt == nil {
+			t.Errorf("after use = %+v, want count 6 and a last use", got)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if ok, _ := db.DeleteWebAuthnCredential("user-2", "cred-1"); ok {
+			t.Error("DeleteWebAuthnCredential() deleted another user's passkey")
+		}
+		if ok, err := db.DeleteWebAuthnCredential("user-1", "cred-1"); err != nil || !ok {
+			t.Errorf("DeleteWebAuthnCredential() = %v, %v; want true", ok, err)
+		}
+		if err := db.DeleteUserWebAuthnCredentials("user-1"); err != nil {
+			t.Fatalf("DeleteUserWebAuthnCredentials() error = %v", err)
+		}
+		if creds, _ := db.ListWebAuthnCredentials("user-1"); len(creds) != 0 {
+			t.Errorf("ListWebAuthnCredentials() after delete = %v, want none", creds)
+		}
+	})
+}
+
+func TestWebAuthnChallenges(t *testing.T) {
+	db := setupTestDB(t)
+
+	if err := db.SaveWebAuthnChallenge("old", "", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("SaveWebAuthnChallenge() error = %v", err)
+	}
+	if err := db.SaveWebAuthnChallenge("c1", "user-1", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("SaveWebAuthnChallenge() error = %v", err)
+	}
+
+	got, err := db.ConsumeWebAuthnChallenge("c1")
+	if err != nil || got == nil || got.UserID != "user-1" {
+		t.Fatalf("ConsumeWebAuthnChallenge() = %+v, %v", got, err)
+	}
+	if got, err := db.ConsumeWebAuthnChallenge("c1"); err != nil || got != nil {
+		t.Errorf("second ConsumeWebAuthnChallenge() = %+v, %v; want nil", got, err)
+	}
+	// Saving c1 cleared the expired challenge
+	if n, _ := db.conn.NewSelect().Model((*WebAuthnChallenge)(nil)).Count(db.ctx()); n != 0 {
+		t.Errorf("%d challenges left, want 0", n)
+	}
+
+	if err := db.SaveWebAuthnChallenge("c2", "", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := db.ConsumeWebAuthnChallenge("c2"); got != nil {
+		t.Errorf("ConsumeWebAuthnChallenge(expired) = %+v, want nil", got)
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/rjsadow/sortie/internal/config"
 	"github.com/rjsadow/sortie/internal/db"
 	"github.com/rjsadow/sortie/internal/plugins"
+	"github.com/rjsadow/sortie/internal/version"
 )
 
 // Collector gathers diagnostic information from the system.
@@ -49,6 +50,7 @@ type Bundle struct {
 
 // SystemInfo contains basic system information.
 type SystemInfo struct {
+	Version      version.Info `json:"version"`
 	GoVersion    string `json:"go_version"`
 	GOOS         string `json:"goos"`
 	GOARCH       string `json:"goarch"`
@@ -72,6 +74,7 @@ type RedactedConfig struct {
 	MaxUploadSize      int64  `json:"max_upload_size"`
 	GatewayRateLimit   float64 `json:"gateway_rate_limit"`
 	GatewayBurst       int    `json:"gateway_burst"`
+	GatewayCompressionLevel int `json:"gateway_compression_level"`
 	MaxSessionsPerUser int    `json:"max_sessions_per_user"`
 	MaxGlobalSessions  int    `json:"max_global_sessions"`
 	DefaultCPURequest  string `json:"default_cpu_request"`
@@ -79,6 +82,7 @@ type RedactedConfig struct {
 	DefaultMemRequest  string `json:"default_mem_request"`
 	DefaultMemLimit    string `json:"default_mem_limit"`
 	RecordingEnabled   bool   `json:"recording_enabled"`
+	StorageEncryption  string `json:"storage_encryption"`
 }
 
 // HealthSummary contains the overall health status.
@@ -204,6 +208,7 @@ func (c *Collector) collectSystemInfo() SystemInfo {
 	uptime := time.Since(c.started)
 
 	return SystemInfo{
+		Version:       version.Get(),
 		GoVersion:     runtime.Version(),
 		GOOS:          runtime.GOOS,
 		GOARCH:        runtime.GOARCH,
@@ -228,6 +233,7 @@ func (c *Collector) collectRedactedConfig() RedactedConfig {
 		MaxUploadSize:       c.config.MaxUploadSize,
 		GatewayRateLimit:    c.config.GatewayRateLimit,
 		GatewayBurst:        c.config.GatewayBurst,
+		GatewayCompressionLevel: c.config.GatewayCompressionLevel,
 		MaxSessionsPerUser:  c.config.MaxSessionsPerUser,
 		MaxGlobalSessions:   c.config.MaxGlobalSessions,
 		DefaultCPURequest:   c.config.DefaultCPURequest,
@@ -235,6 +241,7 @@ func (c *Collector) collectRedactedConfig() RedactedConfig {
 		DefaultMemRequest:   c.config.DefaultMemRequest,
 		DefaultMemLimit:     c.config.DefaultMemLimit,
 		RecordingEnabled:    c.config.RecordingEnabled,
+		StorageEncryption:   c.config.StorageEncryption,
 	}
 }
 
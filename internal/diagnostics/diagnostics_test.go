@@ -57,6 +57,9 @@ func TestCollect(t *testing.T) {
 	if bundle.System.GoVersion == "" {
 		t.Error("expected non-empty GoVersion")
 	}
+	if bundle.System.Version.Version == "" {
+		t.Error("expected non-empty Version")
+	}
 	if bundle.System.GOOS == "" {
 		t.Error("expected non-empty GOOS")
 	}
@@ -0,0 +1,182 @@
+// Package email sends plain-text mail over SMTP, for messages such as
+// password reset links.
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/offline"
+)
+
+// TLS modes for the connection to the SMTP server.
+const (
+	// TLSStartTLS upgrades a plain connection with STARTTLS, usually on
+	// port 587. The server must support it.
+	TLSStartTLS = "starttls"
+	// TLSImplicit connects over TLS from the start, usually on port 465.
+	TLSImplicit = "tls"
+	// TLSNone sends mail unencrypted, for relays on a trusted network.
+	TLSNone = "none"
+)
+
+// dialTimeout bounds connecting to the SMTP server when the context has no
+// deadline.
+const dialTimeout = 10 * time.Second
+
+// Message is a plain-text email to one recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers email.
+type Sender interface {
+	// Send delivers a single message.
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTPSender delivers email through an SMTP server.
+type SMTPSender struct {
+	Host     string
+	Port     int
+	Username string // Empty skips authentication
+	Password string
+	From     string // Sender address, optionally with a display name
+	TLS      string // One of TLSStartTLS, TLSImplicit or TLSNone
+}
+
+// Send delivers msg. Authentication is refused by net/smtp over an
+// unencrypted connection to anything but localhost.
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	from, err := mail.ParseAddress(s.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %w", err)
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+	data, err := s.buildMessage(from, to, msg)
+	if err != nil {
+		return err
+	}
+
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	c, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SMTP handshake failed: %w", err)
+	}
+	defer c.Close()
+
+	if s.TLS == TLSStartTLS {
+		if err := c.StartTLS(s.clientTLSConfig()); err != nil {
+			return fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return fmt.Errorf("SMTP MAIL failed: %w", err)
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		return fmt.Errorf("SMTP RCPT failed: %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA failed: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("SMTP server rejected message: %w", err)
+	}
+	return c.Quit()
+}
+
+// dial connects to the server, over TLS in implicit mode. The connection
+// takes the context's deadline, or dialTimeout.
+func (s *SMTPSender) dial(ctx context.Context) (net.Conn, error) {
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(dialTimeout)
+	}
+	dialer := &net.Dialer{Deadline: deadline}
+
+	conn, err := offline.Dial(ctx, dialer, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(deadline)
+	if s.TLS == TLSImplicit {
+		tlsConn := tls.Client(conn, s.clientTLSConfig())
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+	return conn, nil
+}
+
+func (s *SMTPSender) clientTLSConfig() *tls.Config {
+	return &tls.Config{ServerName: s.Host, MinVersion: tls.VersionTLS12}
+}
+
+// buildMessage formats msg as a MIME message with a quoted-printable
+// UTF-8 body.
+func (s *SMTPSender) buildMessage(from, to *mail.Address, msg Message) ([]byte, error) {
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		return nil, errors.New("subject must be a single line")
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+
+	var buf bytes.Buffer
+	header := func(name, value string) { fmt.Fprintf(&buf, "%s: %s\r\n", name, value) }
+	header("From", from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", "<"+hex.EncodeToString(id)+"@"+domain+">")
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	buf.WriteString("\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+	body := strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n")
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
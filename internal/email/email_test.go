@@ -0,0 +1,155 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+// fakeSMTP is a minimal SMTP server that accepts one message per
+// connection and records it.
+type fakeSMTP struct {
+	ln       net.Listener
+	messages chan string
+	rcpts    chan string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{ln: ln, messages: make(chan string, 1), rcpts: make(chan string, 1)}
+	t.Cleanup(func() { ln.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *fakeSMTP) port() int { return s.ln.Addr().(*net.TCPAddr).Port }
+
+func (s *fakeSMTP) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTP) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 fake")
+		case strings.HasPrefix(cmd, "MAIL FROM"):
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO"):
+			s.rcpts <- strings.TrimSpace(line[len("RCPT TO:"):])
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.messages <- data.String()
+			reply("250 queued")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestSMTPSender_Send(t *testing.T) {
+	server := newFakeSMTP(t)
+	sender := &SMTPSender{Host: "127.0.0.1", Port: server.port(), From: "Sortie <noreply@example.com>", TLS: TLSNone}
+
+	err := sender.Send(context.Background(), Message{
+		To:      "alice@example.com",
+		Subject: "Réinitialiser",
+		Body:    "Hello\nVisit https://sortie.example.com/reset-password?token=abc=def\n",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if rcpt := <-server.rcpts; rcpt != "<alice@example.com>" {
+		t.Errorf("RCPT TO = %q, want <alice@example.com>", rcpt)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(<-server.messages))
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	if got := msg.Header.Get("From"); got != `"Sortie" <noreply@example.com>` {
+		t.Errorf("From = %q", got)
+	}
+	if subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); subject != "Réinitialiser" {
+		t.Errorf("Subject = %q, want Réinitialiser", subject)
+	}
+	body, _ := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if want := "Hello\r\nVisit https://sortie.example.com/reset-password?token=abc=def\r\n"; string(body) != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+}
+
+func TestSMTPSender_Errors(t *testing.T) {
+	server := newFakeSMTP(t)
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name   string
+		sender SMTPSender
+		msg    Message
+	}{
+		{"invalid sender", SMTPSender{From: "not an address", TLS: TLSNone}, Message{To: "a@example.com"}},
+		{"invalid recipient", SMTPSender{From: "noreply@example.com", TLS: TLSNone}, Message{To: "nobody"}},
+		{"header injection", SMTPSender{From: "noreply@example.com", TLS: TLSNone}, Message{To: "a@example.com", Subject: "Hi\r\nBcc: x@example.com"}},
+		// The fake server does not offer STARTTLS, so nothing is sent in the clear
+		{"no STARTTLS", SMTPSender{From: "noreply@example.com", TLS: TLSStartTLS}, Message{To: "a@example.com"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.sender.Host = "127.0.0.1"
+			tt.sender.Port = server.port()
+			if err := tt.sender.Send(ctx, tt.msg); err == nil {
+				t.Error("Send() = nil, want an error")
+			}
+		})
+	}
+
+	t.Run("unreachable", func(t *testing.T) {
+		ln, _ := net.Listen("tcp", "127.0.0.1:0")
+		port := ln.Addr().(*net.TCPAddr).Port
+		ln.Close()
+		sender := &SMTPSender{Host: "127.0.0.1", Port: port, From: "noreply@example.com", TLS: TLSNone}
+		if err := sender.Send(ctx, Message{To: "a@example.com"}); err == nil {
+			t.Error("Send() = nil, want a connection error")
+		}
+	})
+}
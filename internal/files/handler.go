@@ -1,15 +1,22 @@
 package files
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"log/slog"
+	"mime/multipart"
 	"net/http"
 	"strings"
 
 	"github.com/rjsadow/sortie/internal/db"
 	"github.com/rjsadow/sortie/internal/middleware"
 	"github.com/rjsadow/sortie/internal/sessions"
+	"golang.org/x/time/rate"
 )
 
 // Handler handles file transfer HTTP requests for session workspaces.
@@ -17,6 +24,9 @@ type Handler struct {
 	sessionManager *sessions.Manager
 	database       *db.DB
 	maxUploadSize  int64
+	limits         Limits
+	throttle       *Throttle
+	uploadPolicy   *db.UploadPolicy
 }
 
 // NewHandler creates a new file transfer handler.
@@ -25,15 +35,29 @@ func NewHandler(sm *sessions.Manager, database *db.DB, maxUploadSize int64) *Han
 		sessionManager: sm,
 		database:       database,
 		maxUploadSize:  maxUploadSize,
+		throttle:       NewThrottle(),
 	}
 }
 
+// SetUploadPolicy sets the server-wide policy on uploaded files. A
+// tenant's upload policy applies on top of it for its sessions.
+func (h *Handler) SetUploadPolicy(policy *db.UploadPolicy) {
+	h.uploadPolicy = policy
+}
+
+// SetTransferLimits sets the default per-user limits on uploads and
+// downloads. A tenant's quotas override them for its sessions.
+func (h *Handler) SetTransferLimits(limits Limits) {
+	h.limits = limits
+}
+
 // ServeHTTP routes file transfer requests.
 // Expected paths:
 //   - POST   /api/sessions/{id}/files/upload
 //   - GET    /api/sessions/{id}/files/download?path=<path>
 //   - GET    /api/sessions/{id}/files
 //   - DELETE /api/sessions/{id}/files?path=<path>
+//   - GET    /api/sessions/{id}/files/history[?path=<path>]
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Extract session ID and sub-action from the path
 	// Path format: /api/sessions/{id}/files[/upload|/download]
@@ -62,7 +86,8 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Session not found", http.StatusNotFound)
 		return
 	}
-	if session.Status != db.SessionStatusRunning {
+	// History stays readable after the session ends
+	if session.Status != db.SessionStatusRunning && action != "history" {
 		http.Error(w, "Session is not running", http.StatusConflict)
 		return
 	}
@@ -89,6 +114,8 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.handleUpload(w, r, session)
 	case "download":
 		h.handleDownload(w, r, session)
+	case "history":
+		h.handleHistory(w, r, session)
 	case "":
 		switch r.Method {
 		case http.MethodGet:
@@ -110,8 +137,21 @@ func (h *Handler) handleUpload(w http.ResponseWriter, r *http.Request, session *
 		return
 	}
 
+	if !h.allowTransfer(w, r, session, db.FileDirectionUpload, "") {
+		return
+	}
+
+	limiter, release, ok := h.startTransfer(w, r, session)
+	if !ok {
+		return
+	}
+	defer release()
+
 	// Limit request body size
 	r.Body = http.MaxBytesReader(w, r.Body, h.maxUploadSize)
+	if limiter != nil {
+		r.Body = &throttledReader{ctx: r.Context(), r: r.Body, limiter: limiter}
+	}
 
 	// Parse multipart form
 	if err := r.ParseMultipartForm(h.maxUploadSize); err != nil {
@@ -137,14 +177,26 @@ func (h *Handler) handleUpload(w http.ResponseWriter, r *http.Request, session *
 		filename = strings.TrimPrefix(targetPath, "/") + "/" + filename
 	}
 
-	if err := UploadFile(r.Context(), session.PodName, filename, file, header.Size); err != nil {
+	if err := h.checkUploadPolicy(session, filename, file); err != nil {
+		var policyErr *PolicyError
+		if errors.As(err, &policyErr) {
+			h.logBlockedUpload(r, session, filename, policyErr)
+			http.Error(w, "Upload blocked: "+policyErr.Reason, http.StatusUnsupportedMediaType)
+			return
+		}
+		slog.Error("failed to check upload policy", "session", session.ID, "filename", filename, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	digest := sha256.New()
+	if err := UploadFile(r.Context(), session.PodName, filename, io.TeeReader(file, digest), header.Size); err != nil {
 		slog.Error("file upload failed", "session", session.ID, "filename", filename, "error", err)
 		http.Error(w, "Upload failed: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Audit log
-	h.database.LogAudit(session.UserID, "FILE_UPLOAD", fmt.Sprintf("Uploaded %s to session %s", filename, session.ID))
+	h.logEvent(r, session, db.FileDirectionUpload, filename, header.Size, hex.EncodeToString(digest.Sum(nil)))
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -167,13 +219,28 @@ func (h *Handler) handleDownload(w http.ResponseWriter, r *http.Request, session
 		return
 	}
 
+	if !h.allowTransfer(w, r, session, db.FileDirectionDownload, filePath) {
+		return
+	}
+
+	limiter, release, ok := h.startTransfer(w, r, session)
+	if !ok {
+		return
+	}
+	defer release()
+
 	// Set content disposition for download
 	parts := strings.Split(filePath, "/")
 	filename := parts[len(parts)-1]
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
 	w.Header().Set("Content-Type", "application/octet-stream")
 
-	if err := DownloadFile(r.Context(), session.PodName, filePath, w); err != nil {
+	var out io.Writer = w
+	if limiter != nil {
+		out = &throttledWriter{ctx: r.Context(), w: w, limiter: limiter}
+	}
+	dw := &digestWriter{w: out, hash: sha256.New()}
+	if err := DownloadFile(r.Context(), session.PodName, filePath, dw); err != nil {
 		// Can't set error headers after starting to write body,
 		// but if we haven't written anything yet (error happened early), reset headers
 		if strings.Contains(err.Error(), "file not found") {
@@ -187,8 +254,7 @@ func (h *Handler) handleDownload(w http.ResponseWriter, r *http.Request, session
 		return
 	}
 
-	// Audit log
-	h.database.LogAudit(session.UserID, "FILE_DOWNLOAD", fmt.Sprintf("Downloaded %s from session %s", filePath, session.ID))
+	h.logEvent(r, session, db.FileDirectionDownload, filePath, dw.n, hex.EncodeToString(dw.hash.Sum(nil)))
 }
 
 // handleList handles GET /api/sessions/{id}/files?path=<path>
@@ -227,8 +293,197 @@ func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request, session *
 		return
 	}
 
-	// Audit log
-	h.database.LogAudit(session.UserID, "FILE_DELETE", fmt.Sprintf("Deleted %s from session %s", filePath, session.ID))
+	h.logEvent(r, session, db.FileDirectionDelete, filePath, 0, "")
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// handleHistory handles GET /api/sessions/{id}/files/history?path=<path>,
+// listing the session's file events oldest first. With path, only that
+// file's events are listed.
+func (h *Handler) handleHistory(w http.ResponseWriter, r *http.Request, session *db.Session) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	events, err := h.database.ListSessionFileEvents(session.ID, r.URL.Query().Get("path"))
+	if err != nil {
+		slog.Error("failed to list file history", "session", session.ID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+// allowTransfer checks an upload or download against the file transfer
+// policy of the session's app. If the policy blocks it, the attempt is
+// recorded in the audit log and allowTransfer responds 403 and returns
+// false. Uploads are checked before the body is read, so they have no path.
+func (h *Handler) allowTransfer(w http.ResponseWriter, r *http.Request, session *db.Session, direction db.FileDirection, path string) bool {
+	app, err := h.database.GetApp(session.AppID)
+	if err != nil {
+		slog.Error("failed to get app for file transfer policy", "session", session.ID, "app", session.AppID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+	if app == nil {
+		return true
+	}
+	policy := app.FileTransfer
+	if direction == db.FileDirectionUpload && policy.AllowsUpload() ||
+		direction == db.FileDirectionDownload && policy.AllowsDownload() {
+		return true
+	}
+
+	username := session.UserID
+	if user := middleware.GetUserFromContext(r.Context()); user != nil {
+		username = user.Username
+	}
+	details := fmt.Sprintf("session=%s app=%s direction=%s policy=%s", session.ID, app.ID, direction, policy)
+	if path != "" {
+		details += fmt.Sprintf(" path=%q", path)
+	}
+	if err := h.database.LogAuditRequest(middleware.GetRequestID(r.Context()), username, "FILE_TRANSFER_BLOCKED", details); err != nil {
+		slog.Error("failed to audit blocked file transfer", "session", session.ID, "path", path, "error", err)
+	}
+	http.Error(w, fmt.Sprintf("File %ss are disabled for this application", direction), http.StatusForbidden)
+	return false
+}
+
+// startTransfer counts an upload or download against the requesting user's
+// limits. If the user is at their concurrent transfer limit it responds
+// 429 and returns false. The returned limiter, nil when bandwidth is
+// unlimited, throttles the transfer.
+func (h *Handler) startTransfer(w http.ResponseWriter, r *http.Request, session *db.Session) (*rate.Limiter, func(), bool) {
+	limits, err := h.transferLimits(session)
+	if err != nil {
+		slog.Error("failed to get transfer limits", "session", session.ID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	key := session.UserID
+	if user := middleware.GetUserFromContext(r.Context()); user != nil {
+		key = user.ID
+	}
+	limiter, release, ok := h.throttle.Acquire(key, limits)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Too many file transfers in progress (max %d)", limits.MaxConcurrent), http.StatusTooManyRequests)
+		return nil, nil, false
+	}
+	return limiter, release, true
+}
+
+// sessionTenant returns the session's tenant, or nil if it has none.
+func (h *Handler) sessionTenant(session *db.Session) (*db.Tenant, error) {
+	if session.TenantID == "" {
+		return nil, nil
+	}
+	tenant, err := h.database.GetTenant(session.TenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+	return tenant, nil
+}
+
+// transferLimits returns the transfer limits for a session, with the
+// session tenant's quotas overriding the defaults.
+func (h *Handler) transferLimits(session *db.Session) (Limits, error) {
+	limits := h.limits
+	tenant, err := h.sessionTenant(session)
+	if err != nil {
+		return limits, err
+	}
+	if tenant != nil {
+		if tenant.Quotas.MaxTransfersPerUser > 0 {
+			limits.MaxConcurrent = tenant.Quotas.MaxTransfersPerUser
+		}
+		if tenant.Quotas.TransferBandwidthKBps > 0 {
+			limits.BytesPerSecond = int64(tenant.Quotas.TransferBandwidthKBps) * 1024
+		}
+	}
+	return limits, nil
+}
+
+// checkUploadPolicy sniffs the type of an uploaded file and checks it and
+// the filename against the server-wide and tenant upload policies. It
+// returns a *PolicyError if either rejects the file, and leaves file
+// positioned at the start.
+func (h *Handler) checkUploadPolicy(session *db.Session, filename string, file multipart.File) error {
+	tenant, err := h.sessionTenant(session)
+	if err != nil {
+		return err
+	}
+	var tenantPolicy *db.UploadPolicy
+	if tenant != nil {
+		tenantPolicy = tenant.Settings.UploadPolicy
+	}
+	if h.uploadPolicy.IsEmpty() && tenantPolicy.IsEmpty() {
+		return nil
+	}
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("failed to read upload: %w", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind upload: %w", err)
+	}
+	mimeType := SniffType(head[:n])
+
+	if err := CheckUpload(h.uploadPolicy, filename, mimeType); err != nil {
+		return err
+	}
+	return CheckUpload(tenantPolicy, filename, mimeType)
+}
+
+// logBlockedUpload records an upload rejected by policy in the audit log.
+func (h *Handler) logBlockedUpload(r *http.Request, session *db.Session, filename string, policyErr *PolicyError) {
+	username := session.UserID
+	if user := middleware.GetUserFromContext(r.Context()); user != nil {
+		username = user.Username
+	}
+	details := fmt.Sprintf("session=%s path=%q reason=%q", session.ID, filename, policyErr.Reason)
+	if err := h.database.LogAuditRequest(middleware.GetRequestID(r.Context()), username, "FILE_UPLOAD_BLOCKED", details); err != nil {
+		slog.Error("failed to audit blocked upload", "session", session.ID, "path", filename, "error", err)
+	}
+}
+
+// logEvent records a file event for the history and audit log. The user is
+// whoever made the request, which may be an admin rather than the owner.
+func (h *Handler) logEvent(r *http.Request, session *db.Session, direction db.FileDirection, path string, size int64, sha string) {
+	username := session.UserID
+	if user := middleware.GetUserFromContext(r.Context()); user != nil {
+		username = user.Username
+	}
+	event := db.SessionFileEvent{
+		SessionID: session.ID,
+		Username:  username,
+		Direction: direction,
+		Path:      path,
+		SizeBytes: size,
+		SHA256:    sha,
+		RequestID: middleware.GetRequestID(r.Context()),
+	}
+	if err := h.database.LogSessionFileEvent(event); err != nil {
+		slog.Error("failed to record file event", "session", session.ID, "path", path, "direction", direction, "error", err)
+	}
+}
+
+// digestWriter counts and hashes what is written through it.
+type digestWriter struct {
+	w    io.Writer
+	hash hash.Hash
+	n    int64
+}
+
+func (d *digestWriter) Write(p []byte) (int, error) {
+	n, err := d.w.Write(p)
+	d.hash.Write(p[:n])
+	d.n += int64(n)
+	return n, err
+}
@@ -1,6 +1,9 @@
 package files
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -42,3 +45,18 @@ func TestHandler_PathParsing(t *testing.T) {
 		})
 	}
 }
+
+func TestDigestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	dw := &digestWriter{w: &buf, hash: sha256.New()}
+	dw.Write([]byte("hello "))
+	dw.Write([]byte("world"))
+
+	sum := sha256.Sum256([]byte("hello world"))
+	if got := hex.EncodeToString(dw.hash.Sum(nil)); got != hex.EncodeToString(sum[:]) {
+		t.Errorf("hash = %s, want %x", got, sum)
+	}
+	if dw.n != 11 || buf.String() != "hello world" {
+		t.Errorf("wrote %d bytes %q, want 11 bytes %q", dw.n, buf.String(), "hello world")
+	}
+}
@@ -0,0 +1,118 @@
+package files
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// sniffLen is how much of a file SniffType looks at.
+const sniffLen = 512
+
+// executableSignatures are magic numbers of native executables and scripts,
+// which http.DetectContentType reports as generic binary or text.
+var executableSignatures = []struct {
+	magic    []byte
+	mimeType string
+}{
+	{[]byte("\x7fELF"), "application/x-executable"},
+	{[]byte("MZ"), "application/vnd.microsoft.portable-executable"},
+	{[]byte("\xfe\xed\xfa\xce"), "application/x-mach-binary"},
+	{[]byte("\xfe\xed\xfa\xcf"), "application/x-mach-binary"},
+	{[]byte("\xce\xfa\xed\xfe"), "application/x-mach-binary"},
+	{[]byte("\xcf\xfa\xed\xfe"), "application/x-mach-binary"},
+	{[]byte("#!"), "text/x-shellscript"},
+}
+
+// SniffType returns the MIME type of a file from its first bytes, without
+// parameters such as charset.
+func SniffType(head []byte) string {
+	for _, sig := range executableSignatures {
+		if bytes.HasPrefix(head, sig.magic) {
+			return sig.mimeType
+		}
+	}
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return mediaType
+}
+
+// ParseList splits a comma-separated setting into trimmed, lowercased,
+// non-empty entries.
+func ParseList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// PolicyError explains why an upload was rejected.
+type PolicyError struct {
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return e.Reason
+}
+
+// CheckUpload returns a *PolicyError if a file named filename whose
+// content has MIME type mimeType is not allowed by policy.
+func CheckUpload(policy *db.UploadPolicy, filename, mimeType string) error {
+	if policy.IsEmpty() {
+		return nil
+	}
+	name := strings.ToLower(path.Base(filename))
+
+	if ext, ok := matchExtension(name, policy.BlockedExtensions); ok {
+		return &PolicyError{Reason: fmt.Sprintf("files with extension %s are not allowed", ext)}
+	}
+	if len(policy.AllowedExtensions) > 0 {
+		if _, ok := matchExtension(name, policy.AllowedExtensions); !ok {
+			return &PolicyError{Reason: fmt.Sprintf("file extension must be one of %s", strings.Join(policy.AllowedExtensions, ", "))}
+		}
+	}
+	if matchType(mimeType, policy.BlockedTypes) {
+		return &PolicyError{Reason: fmt.Sprintf("files of type %s are not allowed", mimeType)}
+	}
+	if len(policy.AllowedTypes) > 0 && !matchType(mimeType, policy.AllowedTypes) {
+		return &PolicyError{Reason: fmt.Sprintf("file type %s is not allowed, must be one of %s", mimeType, strings.Join(policy.AllowedTypes, ", "))}
+	}
+	return nil
+}
+
+// matchExtension returns the first extension in exts that name ends with.
+func matchExtension(name string, exts []string) (string, bool) {
+	for _, ext := range exts {
+		ext = strings.ToLower(ext)
+		if strings.HasSuffix(name, ext) && len(name) > len(ext) {
+			return ext, true
+		}
+	}
+	return "", false
+}
+
+// matchType reports whether mimeType matches any of patterns, where
+// "type/*" matches every subtype.
+func matchType(mimeType string, patterns []string) bool {
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		if prefix, ok := strings.CutSuffix(p, "/*"); ok {
+			if strings.HasPrefix(mimeType, prefix+"/") {
+				return true
+			}
+		} else if p == mimeType {
+			return true
+		}
+	}
+	return false
+}
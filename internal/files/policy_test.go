@@ -0,0 +1,79 @@
+package files
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+func TestSniffType(t *testing.T) {
+	tests := []struct {
+		name string
+		head string
+		want string
+	}{
+		{"elf", "\x7fELF\x02\x01\x01", "application/x-executable"},
+		{"pe", "MZ\x90\x00\x03", "application/vnd.microsoft.portable-executable"},
+		{"mach-o", "\xcf\xfa\xed\xfe\x07", "application/x-mach-binary"},
+		{"script", "#!/bin/sh\necho hi\n", "text/x-shellscript"},
+		{"text", "name,count\nfoo,1\n", "text/plain"},
+		{"pdf", "%PDF-1.7\n", "application/pdf"},
+		{"empty", "", "text/plain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SniffType([]byte(tt.head)); got != tt.want {
+				t.Errorf("SniffType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseList(t *testing.T) {
+	got := ParseList(" .EXE, .msi ,,")
+	if len(got) != 2 || got[0] != ".exe" || got[1] != ".msi" {
+		t.Errorf("ParseList() = %q", got)
+	}
+	if got := ParseList(""); got != nil {
+		t.Errorf("ParseList(\"\") = %q, want nil", got)
+	}
+}
+
+func TestCheckUpload(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   *db.UploadPolicy
+		filename string
+		mimeType string
+		allowed  bool
+	}{
+		{"no policy", nil, "tool.exe", "application/vnd.microsoft.portable-executable", true},
+		{"blocked extension", &db.UploadPolicy{BlockedExtensions: []string{".exe"}}, "dir/Tool.EXE", "text/plain", false},
+		{"other extension", &db.UploadPolicy{BlockedExtensions: []string{".exe"}}, "notes.txt", "text/plain", true},
+		{"multi-part extension", &db.UploadPolicy{AllowedExtensions: []string{".tar.gz"}}, "data.tar.gz", "application/x-gzip", true},
+		{"extension alone is not a match", &db.UploadPolicy{BlockedExtensions: []string{".bashrc"}}, ".bashrc", "text/plain", true},
+		{"not in allowed extensions", &db.UploadPolicy{AllowedExtensions: []string{".csv"}}, "report.pdf", "application/pdf", false},
+		{"no extension with allow list", &db.UploadPolicy{AllowedExtensions: []string{".csv"}}, "Makefile", "text/plain", false},
+		{"blocked type", &db.UploadPolicy{BlockedTypes: []string{"application/x-executable"}}, "data.csv", "application/x-executable", false},
+		{"blocked type family", &db.UploadPolicy{BlockedTypes: []string{"application/x-*", "text/*"}}, "notes.txt", "text/plain", false},
+		{"allowed type family", &db.UploadPolicy{AllowedTypes: []string{"text/*"}}, "notes.txt", "text/plain", true},
+		{"not in allowed types", &db.UploadPolicy{AllowedTypes: []string{"text/*"}}, "notes.txt", "application/pdf", false},
+		{"block wins over allow", &db.UploadPolicy{AllowedExtensions: []string{".sh"}, BlockedTypes: []string{"text/x-shellscript"}}, "run.sh", "text/x-shellscript", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckUpload(tt.policy, tt.filename, tt.mimeType)
+			if tt.allowed {
+				if err != nil {
+					t.Errorf("CheckUpload() = %v, want allowed", err)
+				}
+				return
+			}
+			var policyErr *PolicyError
+			if !errors.As(err, &policyErr) {
+				t.Errorf("CheckUpload() = %v, want *PolicyError", err)
+			}
+		})
+	}
+}
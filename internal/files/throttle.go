@@ -0,0 +1,144 @@
+package files
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// minBurst is the smallest token bucket burst, so low bandwidth limits
+// still move data in reasonably sized chunks.
+const minBurst = 32 * 1024
+
+// Limits caps one user's file transfers. Zero values mean unlimited.
+type Limits struct {
+	MaxConcurrent  int   // Transfers in progress at once
+	BytesPerSecond int64 // Bandwidth shared by all of the user's transfers
+}
+
+// Throttle tracks file transfers per user, limiting how many run at once
+// and sharing a token bucket between them so parallel transfers cannot
+// exceed the user's bandwidth.
+type Throttle struct {
+	mu    sync.Mutex
+	users map[string]*userTransfers
+}
+
+type userTransfers struct {
+	active  int
+	limiter *rate.Limiter
+}
+
+// NewThrottle creates an empty Throttle.
+func NewThrottle() *Throttle {
+	return &Throttle{users: make(map[string]*userTransfers)}
+}
+
+// Acquire starts a transfer for user. It returns false if the user already
+// has limits.MaxConcurrent transfers in progress. Otherwise it returns the
+// limiter for the transfer, nil when bandwidth is unlimited, and a release
+// function to call when the transfer ends.
+func (t *Throttle) Acquire(user string, limits Limits) (*rate.Limiter, func(), bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	u := t.users[user]
+	if u == nil {
+		u = &userTransfers{}
+		t.users[user] = u
+	}
+	if limits.MaxConcurrent > 0 && u.active >= limits.MaxConcurrent {
+		return nil, nil, false
+	}
+
+	// Limits can change between transfers, for example when a tenant's
+	// quotas are edited; apply the latest to the shared bucket.
+	if limits.BytesPerSecond > 0 {
+		burst := int(max(limits.BytesPerSecond, minBurst))
+		if u.limiter == nil {
+			u.limiter = rate.NewLimiter(rate.Limit(limits.BytesPerSecond), burst)
+		} else {
+			u.limiter.SetLimit(rate.Limit(limits.BytesPerSecond))
+			u.limiter.SetBurst(burst)
+		}
+	} else {
+		u.limiter = nil
+	}
+	limiter := u.limiter
+	u.active++
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			u.active--
+			if u.active == 0 {
+				delete(t.users, user)
+			}
+		})
+	}
+	return limiter, release, true
+}
+
+// Active returns the number of transfers user has in progress.
+func (t *Throttle) Active(user string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if u := t.users[user]; u != nil {
+		return u.active
+	}
+	return 0
+}
+
+// throttledWriter waits for the limiter before each write, splitting
+// writes larger than the limiter's burst.
+type throttledWriter struct {
+	ctx     context.Context
+	w       io.Writer
+	limiter *rate.Limiter
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := min(len(p), t.limiter.Burst())
+		if err := t.limiter.WaitN(t.ctx, chunk); err != nil {
+			return written, err
+		}
+		n, err := t.w.Write(p[:chunk])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[chunk:]
+	}
+	return written, nil
+}
+
+// throttledReader waits for the limiter after each read, reading at most
+// the limiter's burst at a time.
+type throttledReader struct {
+	ctx     context.Context
+	r       io.ReadCloser
+	limiter *rate.Limiter
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > t.limiter.Burst() {
+		p = p[:t.limiter.Burst()]
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		if werr := t.limiter.WaitN(t.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (t *throttledReader) Close() error {
+	return t.r.Close()
+}
@@ -0,0 +1,131 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestThrottle_Concurrent(t *testing.T) {
+	th := NewThrottle()
+	limits := Limits{MaxConcurrent: 2}
+
+	_, release1, ok := th.Acquire("alice", limits)
+	if !ok {
+		t.Fatal("first transfer rejected")
+	}
+	_, release2, ok := th.Acquire("alice", limits)
+	if !ok {
+		t.Fatal("second transfer rejected")
+	}
+	if _, _, ok := th.Acquire("alice", limits); ok {
+		t.Error("third transfer allowed over limit of 2")
+	}
+	if _, release, ok := th.Acquire("bob", limits); !ok {
+		t.Error("other user's transfer rejected")
+	} else {
+		release()
+	}
+
+	release1()
+	release1() // releasing twice counts once
+	if got := th.Active("alice"); got != 1 {
+		t.Errorf("Active = %d, want 1", got)
+	}
+	if _, release, ok := th.Acquire("alice", limits); !ok {
+		t.Error("transfer rejected after release")
+	} else {
+		release()
+	}
+	release2()
+
+	if got := len(th.users); got != 0 {
+		t.Errorf("%d users tracked after all transfers ended, want 0", got)
+	}
+}
+
+func TestThrottle_Unlimited(t *testing.T) {
+	th := NewThrottle()
+	for i := 0; i < 10; i++ {
+		limiter, _, ok := th.Acquire("alice", Limits{})
+		if !ok {
+			t.Fatalf("transfer %d rejected with no limit", i)
+		}
+		if limiter != nil {
+			t.Fatal("limiter returned with no bandwidth limit")
+		}
+	}
+}
+
+func TestThrottle_SharedLimiter(t *testing.T) {
+	th := NewThrottle()
+
+	l1, release1, _ := th.Acquire("alice", Limits{BytesPerSecond: 1024})
+	defer release1()
+	l2, release2, _ := th.Acquire("alice", Limits{BytesPerSecond: 4096})
+	defer release2()
+
+	if l1 != l2 {
+		t.Fatal("transfers by the same user should share a limiter")
+	}
+	if l2.Limit() != 4096 {
+		t.Errorf("Limit = %v, want latest limit 4096", l2.Limit())
+	}
+	if l2.Burst() != minBurst {
+		t.Errorf("Burst = %d, want %d", l2.Burst(), minBurst)
+	}
+}
+
+func TestThrottledWriter(t *testing.T) {
+	// A burst of 1000 bytes refilling at 10000/s: 3000 bytes needs about
+	// 200ms after the initial burst.
+	limiter := rate.NewLimiter(10000, 1000)
+	var buf bytes.Buffer
+	w := &throttledWriter{ctx: context.Background(), w: &buf, limiter: limiter}
+
+	data := bytes.Repeat([]byte("x"), 3000)
+	start := time.Now()
+	n, err := w.Write(data)
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if n != len(data) || !bytes.Equal(buf.Bytes(), data) {
+		t.Fatalf("wrote %d bytes, want %d", n, len(data))
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Write took %v, want it throttled to about 200ms", elapsed)
+	}
+}
+
+func TestThrottledWriter_Canceled(t *testing.T) {
+	limiter := rate.NewLimiter(1, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := &throttledWriter{ctx: ctx, w: io.Discard, limiter: limiter}
+
+	if _, err := w.Write(make([]byte, 100)); err == nil {
+		t.Error("Write succeeded after the context was canceled")
+	}
+}
+
+func TestThrottledReader(t *testing.T) {
+	limiter := rate.NewLimiter(10000, 1000)
+	data := bytes.Repeat([]byte("y"), 3000)
+	r := &throttledReader{ctx: context.Background(), r: io.NopCloser(bytes.NewReader(data)), limiter: limiter}
+
+	start := time.Now()
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("read %d bytes, want %d", len(got), len(data))
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("read took %v, want it throttled to about 200ms", elapsed)
+	}
+}
@@ -4,12 +4,17 @@ import (
 	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/rjsadow/sortie/internal/db"
 	"github.com/rjsadow/sortie/internal/guacamole"
+	"github.com/rjsadow/sortie/internal/idlelock"
 	"github.com/rjsadow/sortie/internal/middleware"
 	"github.com/rjsadow/sortie/internal/plugins"
+	"github.com/rjsadow/sortie/internal/presence"
 	"github.com/rjsadow/sortie/internal/sessions"
+	"github.com/rjsadow/sortie/internal/spectate"
+	"github.com/rjsadow/sortie/internal/streamstats"
 	"github.com/rjsadow/sortie/internal/websocket"
 )
 
@@ -21,8 +26,13 @@ type Handler struct {
 	authProvider   plugins.AuthProvider
 	database       *db.DB
 	limiter        *RateLimiter
+	spectate       *spectate.Manager
+	presence       *presence.Tracker
+	notifier       Notifier
+	locks          *idlelock.Tracker
 	vncHandler     *websocket.Handler
 	guacHandler    *guacamole.Handler
+	checkOrigin    func(r *http.Request) bool
 }
 
 // Config holds configuration for the gateway handler.
@@ -31,18 +41,75 @@ type Config struct {
 	AuthProvider   plugins.AuthProvider
 	Database       *db.DB
 	RateLimiter    *RateLimiter
+	Spectate       *spectate.Manager // nil disables admin spectate
+	Presence       *presence.Tracker // nil disables presence tracking
+	Notifier       Notifier          // nil disables welcome messages and lock events
+	Secrets        guacamole.Secrets // nil disables app credential references
+	VNCProxy       websocket.ProxyOptions
+	StreamStats    *streamstats.Tracker // nil disables stream stats
+	AllowedOrigins []string             // origins besides the request's own; see OriginChecker
+	MaxMessageSize int64                // largest message a client may send, in bytes (0 = unlimited)
+	IdleTimeout    time.Duration        // disconnects clients that send nothing this long (0 = never)
+}
+
+// Notifier delivers events to a user's connected browsers.
+type Notifier interface {
+	Publish(userID, event string, payload any)
+}
+
+// EventWelcome is published to a session owner when they connect to a
+// session whose app has a welcome message they have not dismissed yet.
+const EventWelcome = "session_welcome"
+
+// Welcome is the payload of EventWelcome.
+type Welcome struct {
+	SessionID string `json:"session_id"`
+	AppID     string `json:"app_id"`
+	AppName   string `json:"app_name"`
+	Message   string `json:"message"` // markdown
+}
+
+// EventLocked is published to a session owner when their session's stream
+// is locked for going idle.
+const EventLocked = "session_locked"
+
+// Locked is the payload of EventLocked.
+type Locked struct {
+	SessionID string    `json:"session_id"`
+	LockedAt  time.Time `json:"locked_at"`
 }
 
 // NewHandler creates a new gateway handler.
 func NewHandler(cfg Config) *Handler {
-	return &Handler{
+	h := &Handler{
 		sessionManager: cfg.SessionManager,
 		authProvider:   cfg.AuthProvider,
 		database:       cfg.Database,
 		limiter:        cfg.RateLimiter,
+		spectate:       cfg.Spectate,
+		presence:       cfg.Presence,
+		notifier:       cfg.Notifier,
+		locks:          idlelock.NewTracker(),
 		vncHandler:     websocket.NewHandler(cfg.SessionManager),
 		guacHandler:    guacamole.NewHandler(cfg.SessionManager),
+		checkOrigin:    OriginChecker(cfg.AllowedOrigins),
+	}
+	vncProxy := cfg.VNCProxy
+	vncProxy.CheckOrigin = h.checkOrigin
+	vncProxy.MaxMessageSize = cfg.MaxMessageSize
+	vncProxy.IdleTimeout = cfg.IdleTimeout
+	h.vncHandler.SetProxyOptions(vncProxy)
+	h.vncHandler.SetStreamStats(cfg.StreamStats)
+	h.guacHandler.SetClientOptions(guacamole.ClientOptions{
+		CheckOrigin:    h.checkOrigin,
+		MaxMessageSize: cfg.MaxMessageSize,
+		IdleTimeout:    cfg.IdleTimeout,
+	})
+	h.guacHandler.SetStreamStats(cfg.StreamStats)
+	if cfg.Secrets != nil {
+		h.guacHandler.SetSecrets(cfg.Secrets)
 	}
+	return h
 }
 
 // ServeHTTP routes incoming WebSocket requests through auth and rate limiting
@@ -52,13 +119,24 @@ func NewHandler(cfg Config) *Handler {
 //
 //	/ws/sessions/{id}      -> VNC proxy
 //	/ws/guac/sessions/{id} -> Guacamole (RDP) proxy
+//
+// Admins watching a session pass "spectate={grantID}" to connect read-only
+// under a grant from the spectate manager, or "mode=observe" to connect
+// read-only at once under the tenant's spectate policy.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// --- Rate limiting ---
-	if h.limiter != nil && !h.limiter.Allow(clientIP(r)) {
+	if h.limiter != nil && !h.limiter.Allow(middleware.ClientIP(r)) {
 		http.Error(w, "Too many requests", http.StatusTooManyRequests)
 		return
 	}
 
+	// --- Origin (before cookies are trusted to authenticate) ---
+	if h.checkOrigin != nil && !h.checkOrigin(r) {
+		slog.Warn("gateway: rejected cross-origin stream request", "origin", r.Header.Get("Origin"), "host", r.Host)
+		http.Error(w, "Forbidden origin", http.StatusForbidden)
+		return
+	}
+
 	// --- Authentication ---
 	user, err := h.authenticate(r)
 	if err != nil || user == nil {
@@ -89,8 +167,44 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Enforce ownership: non-admin users can only access their own sessions or shared sessions
-	if !middleware.HasRole(user.Roles, middleware.RoleAdmin) && session.UserID != user.ID {
+	viewer := presence.Viewer{UserID: user.ID, Username: user.Username, Role: presence.RoleOwner}
+
+	// --- Admin spectate: read-only under an approved grant ---
+	var grant *spectate.Grant
+	grantID := r.URL.Query().Get("spectate")
+	observe := r.URL.Query().Get("mode") == "observe"
+	if grantID != "" || observe {
+		if h.spectate == nil || !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		var g spectate.Grant
+		if grantID != "" {
+			g, err = h.spectate.Authorize(grantID, sessionID, user.ID)
+		} else {
+			if session.UserID == user.ID {
+				http.Error(w, "Cannot observe your own session", http.StatusBadRequest)
+				return
+			}
+			policy, perr := spectate.PolicyFor(h.database, session)
+			if perr != nil {
+				slog.Error("gateway: error getting spectate policy", "session_id", sessionID, "error", perr)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			g, err = h.spectate.Observe(sessionID, session.UserID, user.ID, user.Username, policy)
+		}
+		if err != nil {
+			http.Error(w, "Forbidden: "+err.Error(), http.StatusForbidden)
+			return
+		}
+		grant = &g
+		setViewOnly(r)
+		viewer.Role = presence.RoleSpectator
+		viewer.Hidden = g.Policy == db.SpectateSilent
+	} else if session.UserID != user.ID {
+		// Enforce ownership: other users, admins included, need a share;
+		// admins without one spectate under the tenant's policy instead
 		share, err := h.database.CheckSessionAccess(sessionID, user.ID)
 		if err != nil || share == nil {
 			http.Error(w, "Forbidden", http.StatusForbidden)
@@ -98,14 +212,98 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 		// For read-only shares, add view_only query param so the frontend can enforce it
 		if share.Permission == db.SharePermissionReadOnly {
-			q := r.URL.Query()
-			q.Set("view_only", "true")
-			r.URL.RawQuery = q.Encode()
+			setViewOnly(r)
 		}
+		viewer.Role = presence.RoleShared
+	}
+	viewer.ViewOnly = r.URL.Query().Get("view_only") == "true"
+
+	// --- Idle lock: the owner must sign in again to unlock the stream ---
+	if viewer.Role == presence.RoleOwner && session.LockedAt != nil {
+		http.Error(w, "Session is locked", http.StatusLocked)
+		return
 	}
 
 	// --- Audit ---
-	h.database.LogAudit(user.Username, "GATEWAY_CONNECT", "session="+sessionID+" backend="+backend)
+	reqID := middleware.GetRequestID(r.Context())
+	if grant != nil {
+		details := "session=" + sessionID + " backend=" + backend + " policy=" + string(grant.Policy)
+		if observe && grantID == "" {
+			details += " mode=observe"
+		}
+		h.database.LogAuditRequest(reqID, user.Username, "SPECTATE_CONNECT", details)
+		h.spectate.Started(*grant)
+		connectedAt := time.Now()
+		defer func() {
+			h.spectate.Ended(*grant)
+			h.database.LogAuditRequest(reqID, user.Username, "SPECTATE_DISCONNECT", details+" duration="+time.Since(connectedAt).Round(time.Second).String())
+		}()
+	} else {
+		h.database.LogAuditRequest(reqID, user.Username, "GATEWAY_CONNECT", "session="+sessionID+" backend="+backend)
+	}
+
+	app := h.lookupApp(session.AppID)
+
+	// --- Welcome message (owner only, until dismissed) ---
+	if welcome := welcomeFor(app, session, viewer.Role); welcome != nil && h.notifier != nil {
+		h.notifier.Publish(user.ID, EventWelcome, welcome)
+	}
+
+	// --- RDP connection settings (Windows apps) ---
+	if app != nil && app.RDPSettings != nil {
+		r = r.WithContext(guacamole.WithRDPSettings(r.Context(), app.RDPSettings))
+	}
+
+	// --- RDP login from the secrets provider (Windows apps) ---
+	if app != nil && app.Credentials != nil {
+		r = r.WithContext(guacamole.WithCredentialRef(r.Context(), app.Credentials))
+	}
+
+	// --- In-session activity audit (opt-in per app) ---
+	if app != nil && app.AuditSessionActivity {
+		details := "session=" + sessionID + " app=" + session.AppID
+		r = r.WithContext(guacamole.WithActivityFunc(r.Context(), func(a guacamole.Activity) {
+			h.database.LogAuditRequest(reqID, user.Username, activityAction[a.Kind], details+" mimetype="+a.Mimetype+" filename="+a.Filename)
+		}))
+		connectedAt := time.Now()
+		defer func() {
+			h.database.LogAuditRequest(reqID, user.Username, "GATEWAY_DISCONNECT", details+" backend="+backend+" duration="+time.Since(connectedAt).Round(time.Second).String())
+		}()
+	}
+
+	// --- Clipboard policy (blocked transfers are always audited) ---
+	if app != nil && !(app.ClipboardPolicy.AllowsPasteIn() && app.ClipboardPolicy.AllowsCopyOut()) {
+		details := "session=" + sessionID + " app=" + session.AppID + " policy=" + string(app.ClipboardPolicy)
+		logBlocked := func(pasteIn bool) {
+			direction := "copy_out"
+			if pasteIn {
+				direction = "paste_in"
+			}
+			h.database.LogAuditRequest(reqID, user.Username, "CLIPBOARD_BLOCKED", details+" direction="+direction)
+		}
+		r = r.WithContext(websocket.WithClipboardPolicy(r.Context(), app.ClipboardPolicy, logBlocked))
+		r = r.WithContext(guacamole.WithClipboardPolicy(r.Context(), app.ClipboardPolicy, func(a guacamole.Activity) {
+			logBlocked(a.Kind == guacamole.ActivityClipboardIn)
+		}))
+	}
+
+	// --- Idle lock (the owner's connections, which share one timer) ---
+	if app != nil && viewer.Role == presence.RoleOwner {
+		if timeout := h.lockTimeout(app); timeout > 0 {
+			lock, release := h.locks.Watch(sessionID, timeout, func() {
+				h.lockSession(sessionID, user.ID, user.Username, reqID)
+			})
+			defer release()
+			r = r.WithContext(websocket.WithIdleLock(r.Context(), lock))
+			r = r.WithContext(guacamole.WithIdleLock(r.Context(), lock))
+		}
+	}
+
+	// --- Presence (the backends block until the client disconnects) ---
+	if h.presence != nil {
+		leave := h.presence.Join(sessionID, viewer)
+		defer leave()
+	}
 
 	// --- Delegate to backend ---
 	switch backend {
@@ -118,6 +316,70 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// activityAction maps in-session activity to audit log actions.
+var activityAction = map[guacamole.ActivityKind]string{
+	guacamole.ActivityClipboardIn:  "SESSION_CLIPBOARD_IN",
+	guacamole.ActivityClipboardOut: "SESSION_CLIPBOARD_OUT",
+	guacamole.ActivityFileUpload:   "SESSION_FILE_UPLOAD",
+	guacamole.ActivityFileDownload: "SESSION_FILE_DOWNLOAD",
+	guacamole.ActivityPrint:        "SESSION_PRINT",
+}
+
+// lookupApp returns the session's app, or nil if it cannot be found.
+func (h *Handler) lookupApp(appID string) *db.Application {
+	app, err := h.database.GetApp(appID)
+	if err != nil {
+		slog.Error("gateway: error fetching app", "app_id", appID, "error", err)
+		return nil
+	}
+	return app
+}
+
+// lockTimeout returns how long the app's sessions may go without input
+// before they are locked, or 0 if they are never locked.
+func (h *Handler) lockTimeout(app *db.Application) time.Duration {
+	policy, err := h.sessionManager.EffectivePolicy(app)
+	if err != nil {
+		slog.Error("gateway: error resolving session policy", "app_id", app.ID, "error", err)
+		return 0
+	}
+	return time.Duration(policy.LockTimeout) * time.Second
+}
+
+// lockSession records that a session was locked for going idle and tells
+// its owner's browsers, which hide the stream until they sign in again.
+func (h *Handler) lockSession(sessionID, userID, username, reqID string) {
+	if err := h.database.LockSession(sessionID); err != nil {
+		slog.Error("gateway: error locking session", "session_id", sessionID, "error", err)
+		return
+	}
+	h.database.LogAuditRequest(reqID, username, "SESSION_LOCKED", "session="+sessionID)
+	if h.notifier != nil {
+		h.notifier.Publish(userID, EventLocked, Locked{SessionID: sessionID, LockedAt: time.Now().UTC()})
+	}
+}
+
+// welcomeFor returns the welcome message to show a viewer, or nil. Only the
+// session owner sees it, and only until they dismiss it.
+func welcomeFor(app *db.Application, session *db.Session, role presence.Role) *Welcome {
+	if app == nil || app.WelcomeMessage == "" || role != presence.RoleOwner || session.WelcomeDismissedAt != nil {
+		return nil
+	}
+	return &Welcome{
+		SessionID: session.ID,
+		AppID:     app.ID,
+		AppName:   app.Name,
+		Message:   app.WelcomeMessage,
+	}
+}
+
+// setViewOnly marks the request read-only for the backend proxies.
+func setViewOnly(r *http.Request) {
+	q := r.URL.Query()
+	q.Set("view_only", "true")
+	r.URL.RawQuery = q.Encode()
+}
+
 // authenticate extracts and validates a JWT from the request.
 // WebSocket clients cannot set custom headers, so the token is accepted from:
 //  1. query parameter "token"
@@ -1,10 +1,17 @@
 package gateway
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/db/dbtest"
+	"github.com/rjsadow/sortie/internal/plugins"
+	"github.com/rjsadow/sortie/internal/presence"
+	"github.com/rjsadow/sortie/internal/sessions"
 	"golang.org/x/time/rate"
 )
 
@@ -41,62 +48,6 @@ func TestRateLimiter_AllowDefault(t *testing.T) {
 	}
 }
 
-func TestClientIP(t *testing.T) {
-	tests := []struct {
-		name       string
-		xff        string
-		xri        string
-		remoteAddr string
-		want       string
-	}{
-		{
-			name:       "X-Forwarded-For single",
-			xff:        "203.0.113.50",
-			remoteAddr: "127.0.0.1:1234",
-			want:       "203.0.113.50",
-		},
-		{
-			name:       "X-Forwarded-For chain",
-			xff:        "203.0.113.50, 70.41.3.18, 150.172.238.178",
-			remoteAddr: "127.0.0.1:1234",
-			want:       "203.0.113.50",
-		},
-		{
-			name:       "X-Real-Ip",
-			xri:        "203.0.113.50",
-			remoteAddr: "127.0.0.1:1234",
-			want:       "203.0.113.50",
-		},
-		{
-			name:       "RemoteAddr with port",
-			remoteAddr: "192.168.1.1:54321",
-			want:       "192.168.1.1",
-		},
-		{
-			name:       "RemoteAddr without port",
-			remoteAddr: "192.168.1.1",
-			want:       "192.168.1.1",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			r := httptest.NewRequest(http.MethodGet, "/", nil)
-			r.RemoteAddr = tt.remoteAddr
-			if tt.xff != "" {
-				r.Header.Set("X-Forwarded-For", tt.xff)
-			}
-			if tt.xri != "" {
-				r.Header.Set("X-Real-Ip", tt.xri)
-			}
-			got := clientIP(r)
-			if got != tt.want {
-				t.Errorf("clientIP() = %q, want %q", got, tt.want)
-			}
-		})
-	}
-}
-
 func TestParseRoute(t *testing.T) {
 	h := &Handler{}
 
@@ -125,6 +76,39 @@ func TestParseRoute(t *testing.T) {
 	}
 }
 
+func TestWelcomeFor(t *testing.T) {
+	app := &db.Application{ID: "app-1", Name: "Lab", WelcomeMessage: "# Read me"}
+	session := &db.Session{ID: "sess-1", AppID: "app-1"}
+	dismissed := &db.Session{ID: "sess-2", AppID: "app-1", WelcomeDismissedAt: new(time.Time)}
+
+	tests := []struct {
+		name    string
+		app     *db.Application
+		session *db.Session
+		role    presence.Role
+		want    bool
+	}{
+		{"owner", app, session, presence.RoleOwner, true},
+		{"shared viewer", app, session, presence.RoleShared, false},
+		{"spectator", app, session, presence.RoleSpectator, false},
+		{"dismissed", app, dismissed, presence.RoleOwner, false},
+		{"no message", &db.Application{ID: "app-1"}, session, presence.RoleOwner, false},
+		{"unknown app", nil, session, presence.RoleOwner, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := welcomeFor(tt.app, tt.session, tt.role)
+			if (got != nil) != tt.want {
+				t.Fatalf("welcomeFor() = %+v, want message: %v", got, tt.want)
+			}
+			if got != nil && (got.SessionID != "sess-1" || got.AppName != "Lab" || got.Message != "# Read me") {
+				t.Errorf("welcomeFor() = %+v", got)
+			}
+		})
+	}
+}
+
 func TestHandler_ServeHTTP_RateLimited(t *testing.T) {
 	rl := NewRateLimiter(1, 1) // very strict: 1 req/s, burst 1
 
@@ -150,6 +134,22 @@ func TestHandler_ServeHTTP_RateLimited(t *testing.T) {
 	}
 }
 
+func TestHandler_ServeHTTP_RateLimitIgnoresForwardedFor(t *testing.T) {
+	h := &Handler{limiter: NewRateLimiter(1, 1)}
+
+	// A client cannot get a fresh bucket by naming another address
+	for i, xff := range []string{"203.0.113.1", "203.0.113.2"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/ws/sessions/test", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("X-Forwarded-For", xff)
+		h.ServeHTTP(w, r)
+		if limited := w.Code == http.StatusTooManyRequests; limited != (i == 1) {
+			t.Errorf("request %d: status %d", i+1, w.Code)
+		}
+	}
+}
+
 func TestHandler_ServeHTTP_Unauthorized(t *testing.T) {
 	h := &Handler{
 		limiter: NewRateLimiter(100, 100),
@@ -181,3 +181,97 @@ func TestHandler_ServeHTTP_BadPath(t *testing.T) {
 		t.Errorf("expected 401, got %d", w.Code)
 	}
 }
+
+func TestOriginChecker(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{name: "no origin header", origin: "", want: true},
+		{name: "same origin", origin: "https://sortie.example.com", want: true},
+		{name: "same origin case insensitive", origin: "https://Sortie.Example.com", want: true},
+		{name: "cross origin", origin: "https://evil.example.com", want: false},
+		{name: "same host other port", origin: "https://sortie.example.com:8443", want: false},
+		{name: "malformed origin", origin: "null", want: false},
+		{name: "allowed origin", allowed: []string{"https://portal.example.com"}, origin: "https://portal.example.com", want: true},
+		{name: "allowed origin other scheme", allowed: []string{"https://portal.example.com"}, origin: "http://portal.example.com", want: false},
+		{name: "any origin", allowed: []string{"*"}, origin: "https://evil.example.com", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/ws/sessions/test", nil)
+			r.Host = "sortie.example.com"
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if got := OriginChecker(tt.allowed)(r); got != tt.want {
+				t.Errorf("OriginChecker(%v)(%q) = %v, want %v", tt.allowed, tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_ServeHTTP_ForbiddenOrigin(t *testing.T) {
+	h := NewHandler(Config{RateLimiter: NewRateLimiter(100, 100)})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/ws/sessions/test", nil)
+	r.Header.Set("Origin", "https://evil.example.com")
+	r.RemoteAddr = "10.0.0.1:1234"
+	h.ServeHTTP(w, r)
+
+	// Rejected before authentication is attempted
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", w.Code)
+	}
+}
+
+// tokenAuth authenticates each token as the user it names.
+type tokenAuth struct {
+	plugins.AuthProvider
+	users map[string]*plugins.User
+}
+
+func (a tokenAuth) Authenticate(_ context.Context, token string) (*plugins.AuthResult, error) {
+	user := a.users[token]
+	return &plugins.AuthResult{Authenticated: user != nil, User: user}, nil
+}
+
+func TestHandler_ServeHTTP_AdminNeedsGrant(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+	if err := database.CreateApp(db.Application{ID: "app", Name: "App", URL: "https://example.com", LaunchType: db.LaunchTypeContainer}); err != nil {
+		t.Fatalf("CreateApp() error = %v", err)
+	}
+	if err := database.CreateSession(db.Session{ID: "s1", UserID: "owner", AppID: "app", Status: db.SessionStatusCreating}); err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	if err := database.UpdateSessionPodIPAndStatus("s1", "10.0.0.9", db.SessionStatusRunning); err != nil {
+		t.Fatalf("UpdateSessionPodIPAndStatus() error = %v", err)
+	}
+
+	h := NewHandler(Config{
+		SessionManager: sessions.NewManager(database),
+		Database:       database,
+		AuthProvider: tokenAuth{users: map[string]*plugins.User{
+			"admin": {ID: "admin-1", Username: "admin", Roles: []string{"admin", "user"}},
+		}},
+	})
+
+	// Without a spectate grant or a share, an admin is refused like anyone else
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/ws/sessions/s1?token=admin", nil)
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", w.Code)
+	}
+
+	// Spectating needs the spectate manager, which is off here
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/ws/sessions/s1?token=admin&mode=observe", nil)
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("observe: expected 403, got %d", w.Code)
+	}
+}
@@ -0,0 +1,34 @@
+package gateway
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// OriginChecker returns a function that reports whether a WebSocket
+// request's Origin header may open a stream. Browsers send the page's
+// origin with every WebSocket request, so checking it stops other sites
+// from opening streams with a signed-in user's cookies. The origin the
+// request was sent to is always allowed, as are the allowed origins, such
+// as "https://sortie.example.com", and any origin if allowed has "*".
+// Requests without an Origin header do not come from a browser and are
+// allowed.
+func OriginChecker(allowed []string) func(r *http.Request) bool {
+	anyOrigin := slices.Contains(allowed, "*")
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || anyOrigin {
+			return true
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Host == "" {
+			return false
+		}
+		if strings.EqualFold(u.Host, r.Host) {
+			return true
+		}
+		return slices.Contains(allowed, strings.ToLower(u.Scheme+"://"+u.Host))
+	}
+}
@@ -0,0 +1,191 @@
+package guacamole
+
+import (
+	"context"
+	"strconv"
+	"unicode/utf8"
+)
+
+// ActivityKind identifies an in-session action seen on the Guacamole stream.
+type ActivityKind string
+
+const (
+	// ActivityClipboardIn is clipboard data sent from the browser into the session.
+	ActivityClipboardIn ActivityKind = "clipboard_in"
+	// ActivityClipboardOut is clipboard data sent from the session to the browser.
+	ActivityClipboardOut ActivityKind = "clipboard_out"
+	// ActivityFileUpload is a file sent from the browser into the session.
+	ActivityFileUpload ActivityKind = "file_upload"
+	// ActivityFileDownload is a file sent from the session to the browser.
+	ActivityFileDownload ActivityKind = "file_download"
+	// ActivityPrint is a printed document. guacd delivers print jobs as
+	// PDF file streams, so every PDF sent to the browser counts as printed.
+	ActivityPrint ActivityKind = "print"
+)
+
+// Activity is one in-session action.
+type Activity struct {
+	Kind     ActivityKind
+	Mimetype string
+	Filename string
+}
+
+// ActivityFunc receives the activities of one connected client.
+type ActivityFunc func(Activity)
+
+type activityFuncKey struct{}
+
+// WithActivityFunc returns a context that makes the Guacamole handler report
+// the connecting client's in-session activity to fn.
+func WithActivityFunc(ctx context.Context, fn ActivityFunc) context.Context {
+	return context.WithValue(ctx, activityFuncKey{}, fn)
+}
+
+func activityFuncFrom(ctx context.Context) ActivityFunc {
+	fn, _ := ctx.Value(activityFuncKey{}).(ActivityFunc)
+	return fn
+}
+
+// clientActivities returns the activities in instructions sent by a client.
+func clientActivities(data []byte) []Activity {
+	var out []Activity
+	forEachInstruction(data, auditedOpcodes, func(opcode string, args []string) {
+		switch opcode {
+		case "clipboard": // stream, mimetype
+			out = append(out, Activity{Kind: ActivityClipboardIn, Mimetype: arg(args, 1)})
+		case "file": // stream, mimetype, filename
+			out = append(out, Activity{Kind: ActivityFileUpload, Mimetype: arg(args, 1), Filename: arg(args, 2)})
+		case "put": // object, stream, mimetype, name
+			out = append(out, Activity{Kind: ActivityFileUpload, Mimetype: arg(args, 2), Filename: arg(args, 3)})
+		}
+	})
+	return out
+}
+
+// serverActivities returns the activities in instructions sent by guacd.
+func serverActivities(data []byte) []Activity {
+	var out []Activity
+	forEachInstruction(data, auditedOpcodes, func(opcode string, args []string) {
+		switch opcode {
+		case "clipboard": // stream, mimetype
+			out = append(out, Activity{Kind: ActivityClipboardOut, Mimetype: arg(args, 1)})
+		case "file": // stream, mimetype, filename
+			a := Activity{Kind: ActivityFileDownload, Mimetype: arg(args, 1), Filename: arg(args, 2)}
+			if a.Mimetype == "application/pdf" {
+				a.Kind = ActivityPrint
+			}
+			out = append(out, a)
+		case "body": // object, stream, mimetype, name
+			out = append(out, Activity{Kind: ActivityFileDownload, Mimetype: arg(args, 2), Filename: arg(args, 3)})
+		}
+	})
+	return out
+}
+
+// auditedOpcodes are the only instructions whose arguments are decoded;
+// everything else (notably large image data) is skipped.
+var auditedOpcodes = map[string]bool{
+	"clipboard": true,
+	"file":      true,
+	"put":       true,
+	"body":      true,
+}
+
+// forEachInstruction calls fn for every complete instruction in data whose
+// opcode is in opcodes. Element lengths count Unicode code points, as in the
+// Guacamole protocol. Decoding stops at the first malformed element.
+func forEachInstruction(data []byte, opcodes map[string]bool, fn func(opcode string, args []string)) {
+	for len(data) > 0 {
+		var (
+			opcode string
+			args   []string
+			wanted bool
+		)
+		for i := 0; ; i++ {
+			value, rest, ok := nextElement(data)
+			if !ok {
+				return
+			}
+			switch {
+			case i == 0:
+				opcode = string(value)
+				wanted = opcodes[opcode]
+			case wanted:
+				args = append(args, string(value))
+			}
+			terminator := rest[0]
+			data = rest[1:]
+			if terminator == ';' {
+				break
+			}
+			if terminator != ',' {
+				return
+			}
+		}
+		if wanted {
+			fn(opcode, args)
+		}
+	}
+}
+
+// nextElement decodes one "LENGTH.VALUE" element and returns the value and
+// the remaining data, which starts with the terminator (',' or ';').
+func nextElement(data []byte) (value, rest []byte, ok bool) {
+	value, rest, status := scanElement(data)
+	return value, rest, status == elementOK
+}
+
+// elementStatus is the result of decoding an element.
+type elementStatus int
+
+const (
+	elementOK         elementStatus = iota
+	elementIncomplete               // data ends before the element does
+	elementMalformed
+)
+
+// scanElement is nextElement that tells an element cut off by the end of
+// data from a malformed one.
+func scanElement(data []byte) (value, rest []byte, status elementStatus) {
+	dot := -1
+	for i, c := range data {
+		if c == '.' {
+			dot = i
+			break
+		}
+		if c < '0' || c > '9' {
+			return nil, nil, elementMalformed
+		}
+	}
+	if dot < 0 {
+		return nil, nil, elementIncomplete
+	}
+	if dot == 0 {
+		return nil, nil, elementMalformed
+	}
+	n, err := strconv.Atoi(string(data[:dot]))
+	if err != nil {
+		return nil, nil, elementMalformed
+	}
+
+	start := dot + 1
+	end := start
+	for i := 0; i < n; i++ {
+		if end >= len(data) {
+			return nil, nil, elementIncomplete
+		}
+		_, size := utf8.DecodeRune(data[end:])
+		end += size
+	}
+	if end >= len(data) {
+		return nil, nil, elementIncomplete
+	}
+	return data[start:end], data[end:], elementOK
+}
+
+func arg(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
@@ -0,0 +1,156 @@
+package guacamole
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestClientActivities(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []Activity
+	}{
+		{
+			name: "input only",
+			data: encodeInstruction("mouse", "1", "2", "0") + encodeInstruction("key", "65", "1"),
+			want: nil,
+		},
+		{
+			name: "clipboard",
+			data: encodeInstruction("clipboard", "0", "text/plain") + encodeInstruction("blob", "0", "aGk="),
+			want: []Activity{{Kind: ActivityClipboardIn, Mimetype: "text/plain"}},
+		},
+		{
+			name: "file upload",
+			data: encodeInstruction("file", "1", "text/csv", "report.csv"),
+			want: []Activity{{Kind: ActivityFileUpload, Mimetype: "text/csv", Filename: "report.csv"}},
+		},
+		{
+			name: "filesystem put",
+			data: encodeInstruction("put", "2", "3", "image/png", "/Download/a.png"),
+			want: []Activity{{Kind: ActivityFileUpload, Mimetype: "image/png", Filename: "/Download/a.png"}},
+		},
+		{
+			name: "lengths count code points",
+			data: "4.file,1.1,10.text/plain,6.résumé;",
+			want: []Activity{{Kind: ActivityFileUpload, Mimetype: "text/plain", Filename: "résumé"}},
+		},
+		{
+			name: "truncated instruction ignored",
+			data: "4.file,1.1,10.text/pl",
+			want: nil,
+		},
+		{
+			name: "malformed length stops decoding",
+			data: "x.file;" + encodeInstruction("clipboard", "0", "text/plain"),
+			want: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := clientActivities([]byte(tc.data))
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("clientActivities() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestServerActivities(t *testing.T) {
+	data := encodeInstruction("png", "0", "0", "0", "0", "iVBORw0KGgo=") +
+		encodeInstruction("clipboard", "5", "text/plain") +
+		encodeInstruction("file", "6", "application/pdf", "Print Job") +
+		encodeInstruction("file", "7", "text/plain", "notes.txt") +
+		encodeInstruction("body", "1", "8", "text/plain", "/notes.txt")
+
+	want := []Activity{
+		{Kind: ActivityClipboardOut, Mimetype: "text/plain"},
+		{Kind: ActivityPrint, Mimetype: "application/pdf", Filename: "Print Job"},
+		{Kind: ActivityFileDownload, Mimetype: "text/plain", Filename: "notes.txt"},
+		{Kind: ActivityFileDownload, Mimetype: "text/plain", Filename: "/notes.txt"},
+	}
+	if got := serverActivities([]byte(data)); !reflect.DeepEqual(got, want) {
+		t.Errorf("serverActivities() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSharedSession_ReportsActivity(t *testing.T) {
+	guacd := newFakeGuacd(t)
+
+	done := make(chan struct{})
+	go func() {
+		guacd.acceptAndHandshake(t)
+		close(done)
+	}()
+
+	registry := NewSessionRegistry()
+	shared, err := registry.GetOrCreate("sess-activity", guacd.addr(), "127.0.0.1", "3389", "u", "p", "1024", "768")
+	if err != nil {
+		t.Fatalf("GetOrCreate failed: %v", err)
+	}
+	<-done
+
+	var (
+		mu  sync.Mutex
+		got []Activity
+	)
+	record := func(a Activity) {
+		mu.Lock()
+		got = append(got, a)
+		mu.Unlock()
+	}
+
+	client, server := createWSPair(t)
+	voClient, voServer := createWSPair(t)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		shared.AddClientWithActivity(server, false, record)
+	}()
+	go func() {
+		defer wg.Done()
+		shared.AddClientWithActivity(voServer, true, record)
+	}()
+	waitForClients(t, shared, 2)
+
+	// Input from a view-only client never reaches guacd, so it is not reported.
+	if err := voClient.WriteMessage(websocket.TextMessage, []byte(encodeInstruction("clipboard", "0", "text/plain"))); err != nil {
+		t.Fatalf("view-only client write failed: %v", err)
+	}
+
+	upload := encodeInstruction("file", "1", "text/csv", "data.csv")
+	if err := client.WriteMessage(websocket.TextMessage, []byte(upload)); err != nil {
+		t.Fatalf("client write failed: %v", err)
+	}
+	if received := guacd.read(t); received != upload {
+		t.Fatalf("guacd received %q, want %q", received, upload)
+	}
+
+	guacd.send(t, encodeInstruction("file", "2", "application/pdf", "Print Job"))
+	for _, c := range []*websocket.Conn{client, voClient} {
+		if _, _, err := c.ReadMessage(); err != nil {
+			t.Fatalf("client read failed: %v", err)
+		}
+	}
+
+	client.Close()
+	voClient.Close()
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	counts := map[ActivityKind]int{}
+	for _, a := range got {
+		counts[a.Kind]++
+	}
+	want := map[ActivityKind]int{ActivityFileUpload: 1, ActivityPrint: 2}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("reported activity = %v, want %v", counts, want)
+	}
+}
@@ -0,0 +1,147 @@
+package guacamole
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// clipboardRule is the clipboard policy applied to one client.
+type clipboardRule struct {
+	policy    db.ClipboardPolicy
+	onBlocked ActivityFunc // optional; receives each blocked clipboard transfer
+}
+
+type clipboardRuleKey struct{}
+
+// WithClipboardPolicy returns a context that makes the Guacamole handler
+// drop the clipboard data policy does not allow between the connecting
+// client and the session. Each blocked transfer is reported to onBlocked,
+// which may be nil.
+func WithClipboardPolicy(ctx context.Context, policy db.ClipboardPolicy, onBlocked ActivityFunc) context.Context {
+	return context.WithValue(ctx, clipboardRuleKey{}, clipboardRule{policy: policy, onBlocked: onBlocked})
+}
+
+func clipboardRuleFrom(ctx context.Context) clipboardRule {
+	rule, _ := ctx.Value(clipboardRuleKey{}).(clipboardRule)
+	return rule
+}
+
+// maxCarry bounds the incomplete instruction the clipboard filter holds
+// back. guacd's instructions are at most 8 KB, so anything longer is
+// malformed and dropped.
+const maxCarry = 64 * 1024
+
+// clipboardOpcode is how a clipboard instruction starts.
+var clipboardOpcode = []byte("9.clipboard,")
+
+// clipboardFilter drops clipboard streams from one direction of a
+// Guacamole connection. A clipboard stream is a "clipboard" instruction
+// followed by "blob" instructions carrying the data and an "end"
+// instruction, which may arrive in later messages, so the filter
+// remembers the streams it is dropping until they end.
+type clipboardFilter struct {
+	kind    ActivityKind        // reported for each dropped stream
+	streams map[string]struct{} // dropped streams that have not ended
+	carry   []byte              // incomplete instruction from the last call
+}
+
+func newClipboardFilter(kind ActivityKind) *clipboardFilter {
+	return &clipboardFilter{kind: kind, streams: make(map[string]struct{})}
+}
+
+// filter returns data without the clipboard streams in it, and the
+// streams it dropped. An incomplete instruction at the end of data is held
+// back until the rest of it arrives. Malformed data is dropped up to the
+// end of the instruction it is in, so it cannot hide a clipboard stream.
+func (f *clipboardFilter) filter(data []byte) ([]byte, []Activity) {
+	if len(f.carry) == 0 && len(f.streams) == 0 && !bytes.Contains(data, clipboardOpcode) {
+		return data, nil
+	}
+	if len(f.carry) > 0 {
+		data = append(f.carry, data...)
+		f.carry = nil
+	}
+
+	var (
+		out     []byte
+		blocked []Activity
+	)
+	for len(data) > 0 {
+		n, opcode, args := scanInstruction(data)
+		if n == 0 {
+			if len(data) <= maxCarry {
+				f.carry = append([]byte(nil), data...)
+			}
+			break
+		}
+		if n < 0 {
+			end := bytes.IndexByte(data, ';')
+			if end < 0 {
+				break
+			}
+			data = data[end+1:]
+			continue
+		}
+
+		drop := false
+		switch opcode {
+		case "clipboard": // stream, mimetype
+			f.streams[arg(args, 0)] = struct{}{}
+			blocked = append(blocked, Activity{Kind: f.kind, Mimetype: arg(args, 1)})
+			drop = true
+		case "blob": // stream, data
+			_, drop = f.streams[arg(args, 0)]
+		case "end": // stream
+			if _, drop = f.streams[arg(args, 0)]; drop {
+				delete(f.streams, arg(args, 0))
+			}
+		}
+		if !drop {
+			out = append(out, data[:n]...)
+		}
+		data = data[n:]
+	}
+	return out, blocked
+}
+
+// streamOpcodes are the instructions whose arguments the clipboard filter
+// reads; the arguments of everything else are skipped.
+var streamOpcodes = map[string]bool{
+	"clipboard": true,
+	"blob":      true,
+	"end":       true,
+}
+
+// scanInstruction returns the length of the instruction at the start of
+// data, its opcode, and its arguments if the opcode is in streamOpcodes.
+// The length is 0 if the instruction is incomplete and -1 if it is
+// malformed.
+func scanInstruction(data []byte) (n int, opcode string, args []string) {
+	var wanted bool
+	for i := 0; ; i++ {
+		value, rest, status := scanElement(data[n:])
+		if status != elementOK {
+			if status == elementIncomplete {
+				return 0, "", nil
+			}
+			return -1, "", nil
+		}
+		switch {
+		case i == 0:
+			opcode = string(value)
+			wanted = streamOpcodes[opcode]
+		case wanted:
+			args = append(args, string(value))
+		}
+		n = len(data) - len(rest) + 1
+		switch rest[0] {
+		case ';':
+			return n, opcode, args
+		case ',':
+		default:
+			return -1, "", nil
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package guacamole
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+func TestClipboardFilter(t *testing.T) {
+	mouse := encodeInstruction("mouse", "1", "2", "0")
+	f := newClipboardFilter(ActivityClipboardIn)
+
+	// Without clipboard data, data is relayed as is
+	if got, blocked := f.filter([]byte(mouse)); string(got) != mouse || blocked != nil {
+		t.Errorf("filter(mouse) = %q, %v", got, blocked)
+	}
+
+	// A clipboard stream is dropped, including its blobs in later messages,
+	// but other streams are not
+	data := mouse + encodeInstruction("clipboard", "3", "text/plain") + encodeInstruction("blob", "3", "aGk=") +
+		encodeInstruction("blob", "4", "ZmlsZQ==")
+	got, blocked := f.filter([]byte(data))
+	if want := mouse + encodeInstruction("blob", "4", "ZmlsZQ=="); string(got) != want {
+		t.Errorf("filter() = %q, want %q", got, want)
+	}
+	if want := []Activity{{Kind: ActivityClipboardIn, Mimetype: "text/plain"}}; !reflect.DeepEqual(blocked, want) {
+		t.Errorf("blocked = %+v, want %+v", blocked, want)
+	}
+
+	// An instruction split across messages is held back until it is whole
+	end := encodeInstruction("end", "3")
+	if got, _ := f.filter([]byte(end[:3])); len(got) != 0 {
+		t.Errorf("filter(partial end) = %q, want nothing", got)
+	}
+	if got, _ := f.filter([]byte(end[3:] + mouse)); string(got) != mouse {
+		t.Errorf("filter(rest of end) = %q, want %q", got, mouse)
+	}
+
+	// Once the stream ends, its index is free for other streams
+	blob := encodeInstruction("blob", "3", "aGk=")
+	if got, _ := f.filter([]byte(blob)); string(got) != blob {
+		t.Errorf("filter(blob after end) = %q, want %q", got, blob)
+	}
+
+	// Malformed data cannot hide a clipboard instruction
+	got, blocked = f.filter([]byte("x.junk;" + encodeInstruction("clipboard", "5", "text/plain") + mouse))
+	if string(got) != mouse || len(blocked) != 1 {
+		t.Errorf("filter(malformed) = %q, %v; want %q and one blocked stream", got, blocked, mouse)
+	}
+}
+
+func TestSharedSession_ClipboardPolicy(t *testing.T) {
+	guacd := newFakeGuacd(t)
+
+	done := make(chan struct{})
+	go func() {
+		guacd.acceptAndHandshake(t)
+		close(done)
+	}()
+
+	registry := NewSessionRegistry()
+	shared, err := registry.GetOrCreate("sess-clipboard", guacd.addr(), "127.0.0.1", "3389", "u", "p", "1024", "768")
+	if err != nil {
+		t.Fatalf("GetOrCreate failed: %v", err)
+	}
+	<-done
+
+	var (
+		mu      sync.Mutex
+		blocked []Activity
+	)
+	rule := clipboardRule{policy: db.ClipboardNone, onBlocked: func(a Activity) {
+		mu.Lock()
+		blocked = append(blocked, a)
+		mu.Unlock()
+	}}
+
+	client, server := createWSPair(t)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		shared.addClient(server, false, nil, nil, rule, 0, nil)
+	}()
+	waitForClients(t, shared, 1)
+
+	// Pasted text never reaches guacd; the key press after it does
+	paste := encodeInstruction("clipboard", "0", "text/plain") + encodeInstruction("blob", "0", "c2VjcmV0") + encodeInstruction("end", "0")
+	key := encodeInstruction("key", "65", "1")
+	if err := client.WriteMessage(websocket.TextMessage, []byte(paste)); err != nil {
+		t.Fatalf("client write failed: %v", err)
+	}
+	if err := client.WriteMessage(websocket.TextMessage, []byte(key)); err != nil {
+		t.Fatalf("client write failed: %v", err)
+	}
+	if received := guacd.read(t); received != key {
+		t.Errorf("guacd received %q, want only %q", received, key)
+	}
+
+	// Copied text never reaches the client; the frame after it does
+	frame := encodeInstruction("sync", "1234")
+	guacd.send(t, encodeInstruction("clipboard", "1", "text/plain")+encodeInstruction("blob", "1", "c2VjcmV0")+encodeInstruction("end", "1")+frame)
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, msg, err := client.ReadMessage()
+	if err != nil {
+		t.Fatalf("client read failed: %v", err)
+	}
+	if string(msg) != frame {
+		t.Errorf("client received %q, want only %q", msg, frame)
+	}
+
+	client.Close()
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []Activity{
+		{Kind: ActivityClipboardIn, Mimetype: "text/plain"},
+		{Kind: ActivityClipboardOut, Mimetype: "text/plain"},
+	}
+	if !reflect.DeepEqual(blocked, want) {
+		t.Errorf("blocked = %+v, want %+v", blocked, want)
+	}
+}
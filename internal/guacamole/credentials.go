@@ -0,0 +1,98 @@
+package guacamole
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// The login used for apps without a credential reference. It matches the
+// account baked into the xrdp test image.
+const (
+	defaultRDPUsername = "testuser"
+	defaultRDPPassword = "testpass"
+)
+
+// maxSecretKeyLen bounds the length of a secret key in a credential
+// reference.
+const maxSecretKeyLen = 512
+
+// Secrets looks up secret values by key. *secrets.Manager implements it.
+type Secrets interface {
+	Get(ctx context.Context, key string) (string, error)
+}
+
+// ErrNoSecrets is returned when an app references credentials but no
+// secrets provider is configured.
+var ErrNoSecrets = errors.New("no secrets provider configured")
+
+// ValidateCredentialRef checks that an app's credential reference names
+// usable secret keys. A nil reference is valid and uses the default login.
+func ValidateCredentialRef(ref *db.CredentialRef) error {
+	if ref == nil {
+		return nil
+	}
+	if ref.PasswordKey == "" {
+		return fmt.Errorf("password_key is required")
+	}
+	if err := validateSecretKey("password_key", ref.PasswordKey); err != nil {
+		return err
+	}
+	if err := validateSecretKey("username_key", ref.UsernameKey); err != nil {
+		return err
+	}
+	if ref.Username != "" && ref.UsernameKey != "" {
+		return fmt.Errorf("set username or username_key, not both")
+	}
+	if strings.ContainsFunc(ref.Username, func(r rune) bool { return r < 0x20 || r == 0x7f }) {
+		return fmt.Errorf("username must not contain control characters")
+	}
+	return nil
+}
+
+func validateSecretKey(field, key string) error {
+	if len(key) > maxSecretKeyLen {
+		return fmt.Errorf("%s must be at most %d characters", field, maxSecretKeyLen)
+	}
+	if strings.ContainsFunc(key, func(r rune) bool { return r <= 0x20 || r == 0x7f }) {
+		return fmt.Errorf("%s must not contain spaces or control characters", field)
+	}
+	return nil
+}
+
+// resolveCredentials reads the login a credential reference points to. A
+// nil reference returns the default login.
+func resolveCredentials(ctx context.Context, store Secrets, ref *db.CredentialRef) (username, password string, err error) {
+	if ref == nil {
+		return defaultRDPUsername, defaultRDPPassword, nil
+	}
+	if store == nil {
+		return "", "", ErrNoSecrets
+	}
+	username = ref.Username
+	if ref.UsernameKey != "" {
+		if username, err = store.Get(ctx, ref.UsernameKey); err != nil {
+			return "", "", fmt.Errorf("username secret %q: %w", ref.UsernameKey, err)
+		}
+	}
+	if password, err = store.Get(ctx, ref.PasswordKey); err != nil {
+		return "", "", fmt.Errorf("password secret %q: %w", ref.PasswordKey, err)
+	}
+	return username, password, nil
+}
+
+type credentialRefKey struct{}
+
+// WithCredentialRef returns a context that makes the Guacamole handler log
+// in to the RDP server with the credentials ref points to.
+func WithCredentialRef(ctx context.Context, ref *db.CredentialRef) context.Context {
+	return context.WithValue(ctx, credentialRefKey{}, ref)
+}
+
+func credentialRefFrom(ctx context.Context) *db.CredentialRef {
+	ref, _ := ctx.Value(credentialRefKey{}).(*db.CredentialRef)
+	return ref
+}
@@ -0,0 +1,82 @@
+package guacamole
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+var errFakeSecretNotFound = errors.New("secret not found")
+
+// fakeSecrets is an in-memory Secrets store.
+type fakeSecrets map[string]string
+
+func (f fakeSecrets) Get(_ context.Context, key string) (string, error) {
+	v, ok := f[key]
+	if !ok {
+		return "", errFakeSecretNotFound
+	}
+	return v, nil
+}
+
+func TestValidateCredentialRef(t *testing.T) {
+	tests := []struct {
+		name    string
+		ref     *db.CredentialRef
+		wantErr bool
+	}{
+		{"nil", nil, false},
+		{"password only", &db.CredentialRef{PasswordKey: "erp/password"}, false},
+		{"literal username", &db.CredentialRef{Username: "CORP\\svc-erp", PasswordKey: "erp/password"}, false},
+		{"username key", &db.CredentialRef{UsernameKey: "erp/username", PasswordKey: "erp/password"}, false},
+		{"missing password key", &db.CredentialRef{Username: "svc-erp"}, true},
+		{"both usernames", &db.CredentialRef{Username: "svc-erp", UsernameKey: "erp/username", PasswordKey: "erp/password"}, true},
+		{"space in key", &db.CredentialRef{PasswordKey: "erp password"}, true},
+		{"long key", &db.CredentialRef{PasswordKey: strings.Repeat("k", maxSecretKeyLen+1)}, true},
+		{"control character in username", &db.CredentialRef{Username: "svc\n", PasswordKey: "erp/password"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCredentialRef(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCredentialRef() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveCredentials(t *testing.T) {
+	ctx := context.Background()
+	store := fakeSecrets{"erp/username": "svc-erp", "erp/password": "s3cret"}
+
+	username, password, err := resolveCredentials(ctx, nil, nil)
+	if err != nil || username != defaultRDPUsername || password != defaultRDPPassword {
+		t.Errorf("resolveCredentials(nil) = %q, %q, %v; want the default login", username, password, err)
+	}
+
+	username, password, err = resolveCredentials(ctx, store, &db.CredentialRef{UsernameKey: "erp/username", PasswordKey: "erp/password"})
+	if err != nil || username != "svc-erp" || password != "s3cret" {
+		t.Errorf("resolveCredentials(keys) = %q, %q, %v", username, password, err)
+	}
+
+	username, password, err = resolveCredentials(ctx, store, &db.CredentialRef{Username: "admin", PasswordKey: "erp/password"})
+	if err != nil || username != "admin" || password != "s3cret" {
+		t.Errorf("resolveCredentials(literal username) = %q, %q, %v", username, password, err)
+	}
+
+	// Rotating the secret changes the next resolution
+	store["erp/password"] = "rotated"
+	if _, password, _ = resolveCredentials(ctx, store, &db.CredentialRef{Username: "admin", PasswordKey: "erp/password"}); password != "rotated" {
+		t.Errorf("password after rotation = %q, want %q", password, "rotated")
+	}
+
+	if _, _, err = resolveCredentials(ctx, store, &db.CredentialRef{PasswordKey: "missing"}); !errors.Is(err, errFakeSecretNotFound) {
+		t.Errorf("resolveCredentials(missing) error = %v, want %v", err, errFakeSecretNotFound)
+	}
+	if _, _, err = resolveCredentials(ctx, nil, &db.CredentialRef{PasswordKey: "erp/password"}); !errors.Is(err, ErrNoSecrets) {
+		t.Errorf("resolveCredentials(no store) error = %v, want %v", err, ErrNoSecrets)
+	}
+}
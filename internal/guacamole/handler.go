@@ -3,26 +3,48 @@ package guacamole
 import (
 	"log"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/rjsadow/sortie/internal/db"
 	"github.com/rjsadow/sortie/internal/sessions"
+	"github.com/rjsadow/sortie/internal/streamstats"
 )
 
+// guacSubprotocol is the WebSocket subprotocol guacamole-common-js speaks.
+const guacSubprotocol = "guacamole"
+
+// upgrader accepts clients from the origin the request was sent to; the
+// handler's client options may allow others.
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  4096,
 	WriteBufferSize: 4096,
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
-	Subprotocols: []string{"guacamole"},
+	Subprotocols:    []string{guacSubprotocol},
+}
+
+// ClientOptions limit the WebSocket connections of a handler's clients.
+// The zero value allows the request's own origin and sets no limits.
+type ClientOptions struct {
+	// CheckOrigin reports whether a client's Origin header may connect.
+	// nil allows only the origin the request was sent to.
+	CheckOrigin func(r *http.Request) bool
+	// MaxMessageSize is the largest message in bytes a client may send;
+	// a client sending a larger one is disconnected. 0 disables the limit.
+	MaxMessageSize int64
+	// IdleTimeout disconnects a client that sends nothing, not even an
+	// answer to the handler's pings, for this long. 0 disables it.
+	IdleTimeout time.Duration
 }
 
 // Handler handles Guacamole WebSocket connections for Windows RDP sessions
 type Handler struct {
 	sessionManager *sessions.Manager
 	registry       *SessionRegistry
+	secrets        Secrets // resolves app credential references; may be nil
+	stats          *streamstats.Tracker
+	clientOpts     ClientOptions
 }
 
 // NewHandler creates a new Guacamole WebSocket handler
@@ -33,6 +55,21 @@ func NewHandler(sessionManager *sessions.Manager) *Handler {
 	}
 }
 
+// SetSecrets sets the store app credential references are resolved from.
+func (h *Handler) SetSecrets(s Secrets) {
+	h.secrets = s
+}
+
+// SetStreamStats sets the tracker clients' stream stats are recorded to.
+func (h *Handler) SetStreamStats(t *streamstats.Tracker) {
+	h.stats = t
+}
+
+// SetClientOptions sets the limits client connections are held to.
+func (h *Handler) SetClientOptions(opts ClientOptions) {
+	h.clientOpts = opts
+}
+
 // ServeHTTP handles WebSocket upgrade requests for Guacamole sessions
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Extract session ID from path: /ws/guac/sessions/{id}
@@ -44,6 +81,13 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A client that asks for subprotocols must be able to speak
+	// Guacamole's
+	if offered := websocket.Subprotocols(r); len(offered) > 0 && !slices.Contains(offered, guacSubprotocol) {
+		http.Error(w, "Unsupported WebSocket subprotocol", http.StatusBadRequest)
+		return
+	}
+
 	// Get the session
 	session, err := h.sessionManager.GetSession(r.Context(), sessionID)
 	if err != nil {
@@ -67,13 +111,27 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Upgrade to WebSocket
-	clientConn, err := upgrader.Upgrade(w, r, nil)
+	// Resolve the RDP login before upgrading so a missing secret is
+	// reported to the client as an HTTP error
+	username, password, err := resolveCredentials(r.Context(), h.secrets, credentialRefFrom(r.Context()))
+	if err != nil {
+		log.Printf("Failed to resolve RDP credentials for session %s: %v", sessionID, err)
+		http.Error(w, "Session credentials unavailable", http.StatusBadGateway)
+		return
+	}
+
+	// Upgrade to WebSocket; a disallowed origin is refused with 403
+	u := upgrader
+	u.CheckOrigin = h.clientOpts.CheckOrigin
+	clientConn, err := u.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("Failed to upgrade Guacamole WebSocket for session %s: %v", sessionID, err)
 		return
 	}
 	defer clientConn.Close()
+	if h.clientOpts.MaxMessageSize > 0 {
+		clientConn.SetReadLimit(h.clientOpts.MaxMessageSize)
+	}
 
 	// Get screen dimensions from query params (optional, fallback to defaults)
 	width := r.URL.Query().Get("width")
@@ -93,7 +151,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// The RDP server runs in the app container, accessible via localhost:3389 from guacd's perspective.
 	guacdAddr := session.PodIP + ":4822"
 
-	shared, err := h.registry.GetOrCreate(sessionID, guacdAddr, "127.0.0.1", "3389", "testuser", "testpass", width, height)
+	shared, err := h.registry.GetOrCreateWithRDP(sessionID, guacdAddr, "127.0.0.1", "3389", username, password, width, height, rdpSettingsFrom(r.Context()))
 	if err != nil {
 		log.Printf("Failed to create shared session for %s: %v", sessionID, err)
 		return
@@ -101,6 +159,8 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("Client joining shared Guacamole session %s (viewOnly=%v)", sessionID, viewOnly)
 
-	// AddClient blocks until this client disconnects
-	shared.AddClient(clientConn, viewOnly)
+	// addClient blocks until this client disconnects
+	stream := h.stats.Open(sessionID, streamstats.BackendGuac)
+	defer stream.Close()
+	shared.addClient(clientConn, viewOnly, activityFuncFrom(r.Context()), stream, clipboardRuleFrom(r.Context()), h.clientOpts.IdleTimeout, idleLockFrom(r.Context()))
 }
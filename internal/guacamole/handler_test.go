@@ -174,4 +174,43 @@ func TestUpgraderConfig(t *testing.T) {
 	if upgrader.WriteBufferSize != 4096 {
 		t.Errorf("upgrader.WriteBufferSize = %d, want 4096", upgrader.WriteBufferSize)
 	}
+
+	// A nil CheckOrigin allows only the request's own origin
+	if upgrader.CheckOrigin != nil {
+		t.Error("upgrader.CheckOrigin is set, want the same-origin default")
+	}
+}
+
+func TestGuacHandler_UnsupportedSubprotocol(t *testing.T) {
+	database := setupTestDB(t)
+	h := NewHandler(sessions.NewManager(database))
+
+	req := httptest.NewRequest(http.MethodGet, "/ws/guac/sessions/sess-1", nil)
+	req.Header.Set("Sec-WebSocket-Protocol", "binary, base64")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("got status %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestGuacHandler_CredentialsUnavailable(t *testing.T) {
+	database := setupTestDB(t)
+	setupWindowsApp(t, database)
+	mgr := sessions.NewManager(database)
+	h := NewHandler(mgr)
+	h.SetSecrets(fakeSecrets{})
+
+	setupTestSession(t, database, "guac-creds", db.SessionStatusRunning, "10.0.0.5")
+
+	req := httptest.NewRequest(http.MethodGet, "/ws/guac/sessions/guac-creds", nil)
+	req = req.WithContext(WithCredentialRef(req.Context(), &db.CredentialRef{Username: "admin", PasswordKey: "missing"}))
+	rr := httptest.NewRecorder()
+
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadGateway {
+		t.Errorf("got status %d, want %d", rr.Code, http.StatusBadGateway)
+	}
 }
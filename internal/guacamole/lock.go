@@ -0,0 +1,37 @@
+package guacamole
+
+import (
+	"context"
+
+	"github.com/rjsadow/sortie/internal/idlelock"
+)
+
+type idleLockKey struct{}
+
+// WithIdleLock returns a context that makes the Guacamole handler report
+// the connecting client's keyboard and mouse input to lock, and close the
+// client with idlelock.CloseCode once lock locks the session.
+func WithIdleLock(ctx context.Context, lock *idlelock.Watcher) context.Context {
+	return context.WithValue(ctx, idleLockKey{}, lock)
+}
+
+func idleLockFrom(ctx context.Context) *idlelock.Watcher {
+	lock, _ := ctx.Value(idleLockKey{}).(*idlelock.Watcher)
+	return lock
+}
+
+// inputOpcodes are the instructions a client sends for the user's input.
+var inputOpcodes = map[string]bool{
+	"key":   true,
+	"mouse": true,
+	"touch": true,
+}
+
+// hasInput reports whether instructions sent by a client hold input.
+func hasInput(data []byte) bool {
+	found := false
+	forEachInstruction(data, inputOpcodes, func(string, []string) {
+		found = true
+	})
+	return found
+}
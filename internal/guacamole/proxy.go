@@ -10,6 +10,8 @@ import (
 	"sync"
 
 	"github.com/gorilla/websocket"
+	"github.com/rjsadow/sortie/internal/chaos"
+	"github.com/rjsadow/sortie/internal/db"
 )
 
 // GuacdProxy manages a connection between a WebSocket client and guacd.
@@ -101,12 +103,12 @@ func (p *GuacdProxy) Serve(clientConn *websocket.Conn) error {
 
 // handshake delegates to the package-level performHandshake function.
 func (p *GuacdProxy) handshake(conn net.Conn) ([]byte, error) {
-	return performHandshake(conn, p.hostname, p.port, p.username, p.password, p.width, p.height)
+	return performHandshake(conn, p.hostname, p.port, p.username, p.password, p.width, p.height, nil)
 }
 
 // buildConnectArgs delegates to the package-level buildRDPConnectArgs function.
 func (p *GuacdProxy) buildConnectArgs(argNames []string) []string {
-	return buildRDPConnectArgs(argNames, p.hostname, p.port, p.username, p.password, p.width, p.height)
+	return buildRDPConnectArgs(argNames, p.hostname, p.port, p.username, p.password, p.width, p.height, nil)
 }
 
 // performHandshake performs the Guacamole protocol handshake with guacd.
@@ -119,7 +121,7 @@ func (p *GuacdProxy) buildConnectArgs(argNames []string) []string {
 //
 // Returns any excess data read beyond the "ready" instruction, which contains
 // initial display updates that must be forwarded to the client.
-func performHandshake(conn net.Conn, hostname, port, username, password, width, height string) ([]byte, error) {
+func performHandshake(conn net.Conn, hostname, port, username, password, width, height string, rdp *db.RDPSettings) ([]byte, error) {
 	// Step 1: Send select instruction
 	selectInstr := encodeInstruction("select", "rdp")
 	if _, err := conn.Write([]byte(selectInstr)); err != nil {
@@ -150,7 +152,7 @@ func performHandshake(conn net.Conn, hostname, port, username, password, width,
 
 	// Step 4: Send connect instruction with RDP parameters
 	args := parseInstruction(argsResponse)
-	connectArgs := buildRDPConnectArgs(args, hostname, port, username, password, width, height)
+	connectArgs := buildRDPConnectArgs(args, hostname, port, username, password, width, height, rdp)
 	connectInstr := encodeInstruction("connect", connectArgs...)
 	if _, err := conn.Write([]byte(connectInstr)); err != nil {
 		return nil, fmt.Errorf("failed to send connect: %w", err)
@@ -179,8 +181,9 @@ func performHandshake(conn net.Conn, hostname, port, username, password, width,
 	return nil, nil
 }
 
-// buildRDPConnectArgs maps guacd's requested parameter names to RDP connection values.
-func buildRDPConnectArgs(argNames []string, hostname, port, username, password, width, height string) []string {
+// buildRDPConnectArgs maps guacd's requested parameter names to RDP connection
+// values. A nil rdp uses the default settings.
+func buildRDPConnectArgs(argNames []string, hostname, port, username, password, width, height string, rdp *db.RDPSettings) []string {
 	paramMap := map[string]string{
 		"VERSION_1_5_0": "VERSION_1_5_0",
 		"hostname":      hostname,
@@ -191,11 +194,11 @@ func buildRDPConnectArgs(argNames []string, hostname, port, username, password,
 		"height":        height,
 		"dpi":           "96",
 		"color-depth":   "24",
-		"security":      "rdp",
-		"ignore-cert":   "true",
-		"disable-auth":  "true",
 		"resize-method": "display-update",
 	}
+	for name, val := range rdpParams(rdp) {
+		paramMap[name] = val
+	}
 
 	result := make([]string, len(argNames))
 	for i, name := range argNames {
@@ -218,7 +221,7 @@ func (p *GuacdProxy) relayWSToTCP(ws *websocket.Conn, tcp net.Conn) error {
 			}
 			return err
 		}
-		if len(message) == 0 {
+		if len(message) == 0 || chaos.DropFrame() {
 			continue
 		}
 		if _, err := tcp.Write(message); err != nil {
@@ -271,8 +274,10 @@ func (p *GuacdProxy) relayTCPToWS(tcp net.Conn, ws *websocket.Conn) error {
 
 		// Send all complete instructions up to and including the last ';'
 		toSend := data[:lastSemi+1]
-		if err := ws.WriteMessage(websocket.TextMessage, toSend); err != nil {
-			return err
+		if !chaos.DropFrame() {
+			if err := ws.WriteMessage(websocket.TextMessage, toSend); err != nil {
+				return err
+			}
 		}
 
 		// Carry over any partial instruction after the last ';'
@@ -162,7 +162,7 @@ func TestBuildConnectArgs_SecurityParams(t *testing.T) {
 
 func TestBuildRDPConnectArgs(t *testing.T) {
 	argNames := []string{"hostname", "port", "username", "password", "width", "height", "dpi", "unknown-param"}
-	result := buildRDPConnectArgs(argNames, "127.0.0.1", "3389", "testuser", "testpass", "1920", "1080")
+	result := buildRDPConnectArgs(argNames, "127.0.0.1", "3389", "testuser", "testpass", "1920", "1080", nil)
 
 	expected := []string{"127.0.0.1", "3389", "testuser", "testpass", "1920", "1080", "96", ""}
 
@@ -0,0 +1,127 @@
+package guacamole
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// rdpSecurityModes are the security modes guacd's RDP plugin accepts.
+var rdpSecurityModes = map[string]bool{
+	"any":       true,
+	"nla":       true,
+	"nla-ext":   true,
+	"tls":       true,
+	"vmconnect": true,
+	"rdp":       true,
+}
+
+var rdpHostnamePattern = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9]*[A-Za-z0-9])?(\.[A-Za-z0-9]([-A-Za-z0-9]*[A-Za-z0-9])?)*$`)
+
+// ValidateRDPSettings checks that an app's RDP settings can be passed to
+// guacd. A nil value is valid and uses the defaults.
+func ValidateRDPSettings(rs *db.RDPSettings) error {
+	if rs == nil {
+		return nil
+	}
+	if rs.Security != "" && !rdpSecurityModes[rs.Security] {
+		return fmt.Errorf("invalid security mode %q: must be any, nla, nla-ext, tls, vmconnect or rdp", rs.Security)
+	}
+	if err := validateRDPDomain("domain", rs.Domain); err != nil {
+		return err
+	}
+	if err := validateRDPDomain("gateway domain", rs.GatewayDomain); err != nil {
+		return err
+	}
+	if rs.GatewayHostname != "" && net.ParseIP(rs.GatewayHostname) == nil &&
+		(len(rs.GatewayHostname) > 253 || !rdpHostnamePattern.MatchString(rs.GatewayHostname)) {
+		return fmt.Errorf("invalid gateway hostname %q", rs.GatewayHostname)
+	}
+	if rs.GatewayPort < 0 || rs.GatewayPort > 65535 {
+		return fmt.Errorf("invalid gateway port %d: must be between 1 and 65535", rs.GatewayPort)
+	}
+	if rs.GatewayPort != 0 && rs.GatewayHostname == "" {
+		return fmt.Errorf("gateway port requires a gateway hostname")
+	}
+	if rs.GatewayDomain != "" && rs.GatewayHostname == "" {
+		return fmt.Errorf("gateway domain requires a gateway hostname")
+	}
+	return nil
+}
+
+// validateRDPDomain rejects Windows domain names that could not have been
+// meant literally.
+func validateRDPDomain(field, domain string) error {
+	if len(domain) > 255 {
+		return fmt.Errorf("%s must be at most 255 characters", field)
+	}
+	if strings.ContainsFunc(domain, func(r rune) bool { return r < 0x20 || r == 0x7f }) {
+		return fmt.Errorf("%s must not contain control characters", field)
+	}
+	return nil
+}
+
+// rdpParams returns the guacd connect parameters the settings change.
+// disable-auth is only set for modes that do not authenticate the user
+// before the session starts, since guacd cannot combine it with NLA.
+func rdpParams(rs *db.RDPSettings) map[string]string {
+	if rs == nil {
+		rs = &db.RDPSettings{}
+	}
+	security := rs.Security
+	if security == "" {
+		security = "rdp"
+	}
+	params := map[string]string{
+		"security":     security,
+		"ignore-cert":  "true",
+		"disable-auth": strconv.FormatBool(security != "nla" && security != "nla-ext" && security != "any"),
+	}
+	if rs.IgnoreCert != nil {
+		params["ignore-cert"] = strconv.FormatBool(*rs.IgnoreCert)
+	}
+	if rs.Domain != "" {
+		params["domain"] = rs.Domain
+	}
+	if rs.GatewayHostname != "" {
+		params["gateway-hostname"] = rs.GatewayHostname
+		params["gateway-port"] = "443"
+		if rs.GatewayPort != 0 {
+			params["gateway-port"] = strconv.Itoa(rs.GatewayPort)
+		}
+		params["gateway-domain"] = rs.GatewayDomain
+	}
+	flags := map[string]bool{
+		"console":                    rs.Console,
+		"enable-wallpaper":           rs.EnableWallpaper,
+		"enable-theming":             rs.EnableTheming,
+		"enable-font-smoothing":      rs.EnableFontSmoothing,
+		"enable-full-window-drag":    rs.EnableFullWindowDrag,
+		"enable-desktop-composition": rs.EnableDesktopComposition,
+		"enable-menu-animations":     rs.EnableMenuAnimations,
+	}
+	for name, on := range flags {
+		if on {
+			params[name] = "true"
+		}
+	}
+	return params
+}
+
+type rdpSettingsKey struct{}
+
+// WithRDPSettings returns a context that makes the Guacamole handler open
+// new connections with the given RDP settings.
+func WithRDPSettings(ctx context.Context, rs *db.RDPSettings) context.Context {
+	return context.WithValue(ctx, rdpSettingsKey{}, rs)
+}
+
+func rdpSettingsFrom(ctx context.Context) *db.RDPSettings {
+	rs, _ := ctx.Value(rdpSettingsKey{}).(*db.RDPSettings)
+	return rs
+}
@@ -0,0 +1,83 @@
+package guacamole
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+func TestValidateRDPSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		rs      *db.RDPSettings
+		wantErr bool
+	}{
+		{"nil", nil, false},
+		{"empty", &db.RDPSettings{}, false},
+		{"nla with domain", &db.RDPSettings{Security: "nla", Domain: "CORP"}, false},
+		{"gateway", &db.RDPSettings{GatewayHostname: "rdgw.corp.example.com", GatewayPort: 8443, GatewayDomain: "CORP"}, false},
+		{"gateway ip", &db.RDPSettings{GatewayHostname: "10.0.0.5"}, false},
+		{"unknown security", &db.RDPSettings{Security: "kerberos"}, true},
+		{"bad gateway hostname", &db.RDPSettings{GatewayHostname: "rdgw example"}, true},
+		{"gateway port out of range", &db.RDPSettings{GatewayHostname: "rdgw", GatewayPort: 70000}, true},
+		{"gateway port without hostname", &db.RDPSettings{GatewayPort: 443}, true},
+		{"gateway domain without hostname", &db.RDPSettings{GatewayDomain: "CORP"}, true},
+		{"control character in domain", &db.RDPSettings{Domain: "CORP\n"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRDPSettings(tt.rs)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRDPSettings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildRDPConnectArgs_Defaults(t *testing.T) {
+	argNames := []string{"security", "ignore-cert", "disable-auth", "domain", "gateway-hostname", "console", "enable-wallpaper"}
+	result := buildRDPConnectArgs(argNames, "127.0.0.1", "3389", "u", "p", "1920", "1080", nil)
+
+	expected := []string{"rdp", "true", "true", "", "", "", ""}
+	for i, v := range result {
+		if v != expected[i] {
+			t.Errorf("buildRDPConnectArgs()[%d] = %q, want %q (param: %s)", i, v, expected[i], argNames[i])
+		}
+	}
+}
+
+func TestBuildRDPConnectArgs_Settings(t *testing.T) {
+	ignoreCert := false
+	rs := &db.RDPSettings{
+		Security:             "nla",
+		IgnoreCert:           &ignoreCert,
+		Domain:               "CORP",
+		GatewayHostname:      "rdgw.corp.example.com",
+		GatewayDomain:        "GW",
+		Console:              true,
+		EnableWallpaper:      true,
+		EnableMenuAnimations: true,
+	}
+	argNames := []string{"security", "ignore-cert", "disable-auth", "domain", "gateway-hostname", "gateway-port", "gateway-domain",
+		"console", "enable-wallpaper", "enable-theming", "enable-menu-animations", "hostname"}
+	result := buildRDPConnectArgs(argNames, "127.0.0.1", "3389", "u", "p", "1920", "1080", rs)
+
+	expected := []string{"nla", "false", "false", "CORP", "rdgw.corp.example.com", "443", "GW",
+		"true", "true", "", "true", "127.0.0.1"}
+	for i, v := range result {
+		if v != expected[i] {
+			t.Errorf("buildRDPConnectArgs()[%d] = %q, want %q (param: %s)", i, v, expected[i], argNames[i])
+		}
+	}
+}
+
+func TestRDPSettingsContext(t *testing.T) {
+	if rs := rdpSettingsFrom(context.Background()); rs != nil {
+		t.Errorf("rdpSettingsFrom(empty context) = %+v, want nil", rs)
+	}
+	want := &db.RDPSettings{Security: "tls"}
+	if rs := rdpSettingsFrom(WithRDPSettings(context.Background(), want)); rs != want {
+		t.Errorf("rdpSettingsFrom() = %+v, want %+v", rs, want)
+	}
+}
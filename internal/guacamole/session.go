@@ -6,17 +6,28 @@ import (
 	"log"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/idlelock"
+	"github.com/rjsadow/sortie/internal/streamstats"
 )
 
 // Client represents a single WebSocket viewer connected to a SharedSession.
 type Client struct {
-	conn     *websocket.Conn
-	viewOnly bool
-	writeMu  sync.Mutex   // serializes WS writes (broadcast + close frames)
-	done     chan struct{} // closed when client disconnects
-	closeOnce sync.Once
+	conn       *websocket.Conn
+	viewOnly   bool
+	onActivity ActivityFunc        // optional in-session activity observer
+	stream     *streamstats.Stream // optional stream stats recorder
+	onBlocked  ActivityFunc        // optional observer of blocked clipboard transfers
+	clipIn     *clipboardFilter    // drops pasted clipboard data; nil allows it
+	clipOut    *clipboardFilter    // drops copied clipboard data; nil allows it
+	idle       time.Duration       // disconnects a silent client; 0 disables it
+	lock       *idlelock.Watcher   // optional idle lock fed the client's input
+	writeMu    sync.Mutex          // serializes WS writes (broadcast + close frames)
+	done       chan struct{}       // closed when client disconnects
+	closeOnce  sync.Once
 }
 
 // close signals that this client has disconnected.
@@ -33,6 +44,36 @@ func (c *Client) writeMessage(msgType int, data []byte) error {
 	return c.conn.WriteMessage(msgType, data)
 }
 
+// keepAlive pings the client twice per idle timeout until it disconnects.
+// Browsers answer pings on their own, and the pongs extend the client's
+// read deadline, so only a client that has gone away without closing its
+// connection idles out.
+func (c *Client) keepAlive() {
+	interval := c.idle / 2
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		// WriteControl may be called alongside writeMessage
+		if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(interval)); err != nil {
+			return
+		}
+	}
+}
+
+// extendReadDeadline gives the client its idle timeout more to send
+// something. It does nothing when the client has no idle timeout.
+func (c *Client) extendReadDeadline() error {
+	if c.idle <= 0 {
+		return nil
+	}
+	return c.conn.SetReadDeadline(time.Now().Add(c.idle))
+}
+
 // maxDisplayBuf caps the display replay buffer at 16 MB. When the buffer
 // exceeds this, old data is discarded. Late-joining clients may see a
 // partially rendered screen until the next full redraw.
@@ -40,12 +81,17 @@ const maxDisplayBuf = 16 * 1024 * 1024
 
 // SharedSession maintains exactly one guacd TCP connection and broadcasts
 // display data to all connected WebSocket clients. Input from non-read-only
-// clients is multiplexed onto the single guacd connection.
+// clients is multiplexed onto the single guacd connection. If guacd drops
+// the connection, e.g. because the sidecar restarted, it is re-established
+// with the same parameters and the clients stay connected.
 type SharedSession struct {
 	sessionID string
-	guacdConn net.Conn
 	excess    []byte // initial display data from handshake
 
+	connMu    sync.Mutex
+	guacdConn net.Conn
+	params    connParams // replayed on reconnect; width and height follow client resizes
+
 	mu      sync.RWMutex
 	clients map[*Client]struct{}
 
@@ -64,19 +110,13 @@ type SharedSession struct {
 
 // newSharedSession dials guacd, performs the handshake, and starts the
 // broadcast loop. The onClose callback is invoked once when the session closes.
-func newSharedSession(sessionID, guacdAddr, hostname, port, username, password, width, height string, onClose func()) (*SharedSession, error) {
-	guacdConn, err := net.Dial("tcp", guacdAddr)
+func newSharedSession(sessionID string, params connParams, onClose func()) (*SharedSession, error) {
+	guacdConn, excess, err := dialGuacd(params)
 	if err != nil {
 		return nil, err
 	}
 
-	excess, err := performHandshake(guacdConn, hostname, port, username, password, width, height)
-	if err != nil {
-		guacdConn.Close()
-		return nil, err
-	}
-
-	log.Printf("SharedSession %s: guacd handshake complete at %s", sessionID, guacdAddr)
+	log.Printf("SharedSession %s: guacd handshake complete at %s", sessionID, params.guacdAddr)
 
 	// Seed the display buffer with any excess data from the handshake
 	var displayBuf []byte
@@ -88,6 +128,7 @@ func newSharedSession(sessionID, guacdAddr, hostname, port, username, password,
 	s := &SharedSession{
 		sessionID:  sessionID,
 		guacdConn:  guacdConn,
+		params:     params,
 		excess:     excess,
 		displayBuf: displayBuf,
 		clients:    make(map[*Client]struct{}),
@@ -95,18 +136,54 @@ func newSharedSession(sessionID, guacdAddr, hostname, port, username, password,
 		onClose:    onClose,
 	}
 
+	tunnelStats.opened.Add(1)
+	tunnelStats.active.Add(1)
 	go s.broadcastLoop()
 
 	return s, nil
 }
 
+func (s *SharedSession) conn() net.Conn {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	return s.guacdConn
+}
+
 // AddClient registers a new WebSocket connection and blocks until the client
 // disconnects. The caller's goroutine is consumed for the lifetime of the client.
 func (s *SharedSession) AddClient(conn *websocket.Conn, viewOnly bool) {
+	s.AddClientWithActivity(conn, viewOnly, nil)
+}
+
+// AddClientWithActivity is like AddClient but also reports the client's
+// in-session activity (clipboard, file transfer, printing) to onActivity.
+// A nil onActivity disables reporting.
+func (s *SharedSession) AddClientWithActivity(conn *websocket.Conn, viewOnly bool, onActivity ActivityFunc) {
+	s.addClient(conn, viewOnly, onActivity, nil, clipboardRule{}, 0, nil)
+}
+
+// addClient is AddClientWithActivity that also records the client's
+// stream stats to stream, which may be nil, applies the clipboard policy
+// in clipboard to what the client sends and receives, and with idle > 0
+// disconnects the client once it sends nothing for that long. With a lock,
+// the client's input is reported to it, and the client is closed with
+// idlelock.CloseCode once it locks the session.
+func (s *SharedSession) addClient(conn *websocket.Conn, viewOnly bool, onActivity ActivityFunc, stream *streamstats.Stream, clipboard clipboardRule, idle time.Duration, lock *idlelock.Watcher) {
 	client := &Client{
-		conn:     conn,
-		viewOnly: viewOnly,
-		done:     make(chan struct{}),
+		conn:       conn,
+		viewOnly:   viewOnly,
+		onActivity: onActivity,
+		stream:     stream,
+		onBlocked:  clipboard.onBlocked,
+		idle:       idle,
+		lock:       lock,
+		done:       make(chan struct{}),
+	}
+	if !clipboard.policy.AllowsPasteIn() {
+		client.clipIn = newClipboardFilter(ActivityClipboardIn)
+	}
+	if !clipboard.policy.AllowsCopyOut() {
+		client.clipOut = newClipboardFilter(ActivityClipboardOut)
 	}
 
 	// Hold the write lock while replaying the display buffer AND adding
@@ -123,6 +200,10 @@ func (s *SharedSession) AddClient(conn *websocket.Conn, viewOnly bool) {
 	// Replay accumulated display data so the new client sees the current screen.
 	// This includes the handshake excess plus everything broadcastLoop has sent.
 	replayData := s.displayBuf
+	if client.clipOut != nil {
+		// Not reported: the clipboard was copied before the client joined
+		replayData, _ = client.clipOut.filter(replayData)
+	}
 	if len(replayData) > 0 {
 		if err := client.writeMessage(websocket.TextMessage, replayData); err != nil {
 			s.mu.Unlock()
@@ -136,10 +217,26 @@ func (s *SharedSession) AddClient(conn *websocket.Conn, viewOnly bool) {
 
 	log.Printf("SharedSession %s: client added (viewOnly=%v, total=%d)", s.sessionID, viewOnly, s.clientCount())
 
+	if idle > 0 {
+		// Set before the client is read from, which calls it
+		conn.SetPongHandler(func(string) error {
+			return client.extendReadDeadline()
+		})
+		go client.keepAlive()
+	}
 	go s.handleClientInput(client)
 
-	// Block until client disconnects
-	<-client.done
+	// Block until client disconnects, or the session is locked
+	var locked <-chan struct{}
+	if lock != nil {
+		locked = lock.Locked()
+	}
+	select {
+	case <-client.done:
+	case <-locked:
+		log.Printf("SharedSession %s: session locked, closing client", s.sessionID)
+		client.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(idlelock.CloseCode, idlelock.CloseReason), time.Now().Add(time.Second))
+	}
 
 	s.RemoveClient(client)
 }
@@ -170,7 +267,8 @@ func (s *SharedSession) Close() {
 	s.closeOnce.Do(func() {
 		log.Printf("SharedSession %s: closing", s.sessionID)
 		close(s.done)
-		s.guacdConn.Close()
+		s.conn().Close()
+		tunnelStats.active.Add(-1)
 
 		s.mu.RLock()
 		clients := make([]*Client, 0, len(s.clients))
@@ -193,19 +291,33 @@ func (s *SharedSession) Close() {
 // broadcastLoop reads complete Guacamole instructions from the guacd TCP
 // connection and writes them to all connected clients. This is the same
 // instruction-buffering logic as relayTCPToWS but broadcasting to N clients.
+// When the connection fails or goes silent, it is re-established; the
+// session closes only once reconnecting fails.
 func (s *SharedSession) broadcastLoop() {
 	buf := make([]byte, 65536)
 	var carry []byte
 
 	for {
-		n, err := s.guacdConn.Read(buf)
+		conn := s.conn()
+		conn.SetReadDeadline(time.Now().Add(guacdIdleTimeout))
+		n, err := conn.Read(buf)
 		if err != nil {
-			if len(carry) > 0 {
-				s.broadcast(carry)
+			select {
+			case <-s.done:
+				return
+			default:
 			}
-			if err != io.EOF {
+			// A partial instruction cannot be completed by a new connection
+			carry = nil
+			tunnelStats.errors.Add(1)
+			if err == io.EOF {
+				log.Printf("SharedSession %s: guacd closed the connection", s.sessionID)
+			} else {
 				log.Printf("SharedSession %s: guacd read error: %v", s.sessionID, err)
 			}
+			if s.reconnect() {
+				continue
+			}
 			s.Close()
 			return
 		}
@@ -241,6 +353,104 @@ func (s *SharedSession) broadcastLoop() {
 	}
 }
 
+// reconnect re-dials guacd with the session's parameters, backing off
+// between attempts. On success the display buffer restarts from the new
+// connection's initial display data, which is also sent to the clients.
+func (s *SharedSession) reconnect() bool {
+	s.conn().Close()
+	backoff := guacdReconnectBackoff
+	for attempt := 1; attempt <= guacdReconnectAttempts; attempt++ {
+		select {
+		case <-s.done:
+			return false
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+
+		s.connMu.Lock()
+		params := s.params
+		s.connMu.Unlock()
+		conn, excess, err := dialGuacd(params)
+		if err != nil {
+			log.Printf("SharedSession %s: reconnect attempt %d/%d failed: %v", s.sessionID, attempt, guacdReconnectAttempts, err)
+			continue
+		}
+
+		// Swap under inputMu so client input is not written half to each
+		// connection.
+		s.inputMu.Lock()
+		s.connMu.Lock()
+		s.guacdConn = conn
+		s.connMu.Unlock()
+		s.inputMu.Unlock()
+		select {
+		case <-s.done:
+			// Closed while dialing; Close may have missed the new connection
+			conn.Close()
+			return false
+		default:
+		}
+
+		s.mu.Lock()
+		s.displayBuf = nil
+		s.mu.Unlock()
+		if len(excess) > 0 {
+			s.broadcast(excess)
+		}
+		s.recordReconnect()
+		tunnelStats.reconnects.Add(1)
+		log.Printf("SharedSession %s: reconnected to guacd at %s", s.sessionID, params.guacdAddr)
+		return true
+	}
+	tunnelStats.reconnectFailures.Add(1)
+	log.Printf("SharedSession %s: giving up on guacd after %d attempts", s.sessionID, guacdReconnectAttempts)
+	return false
+}
+
+// recordReconnect counts a guacd reconnect once in the session's stream
+// stats, through any client that records them.
+func (s *SharedSession) recordReconnect() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for c := range s.clients {
+		if c.stream != nil {
+			c.stream.Reconnected()
+			return
+		}
+	}
+}
+
+// syncOpcodes selects the instruction that ends a frame.
+var syncOpcodes = map[string]bool{"sync": true}
+
+// syncTimestamps returns the timestamps of the sync instructions in data,
+// one per frame.
+func syncTimestamps(data []byte) []string {
+	var out []string
+	forEachInstruction(data, syncOpcodes, func(_ string, args []string) {
+		if len(args) > 0 {
+			out = append(out, args[0])
+		}
+	})
+	return out
+}
+
+// sizeOpcodes selects the client's display size instruction.
+var sizeOpcodes = map[string]bool{"size": true}
+
+// trackSize records the display size a client asked for, so a reconnect
+// opens the display at the size the client is showing.
+func (s *SharedSession) trackSize(message []byte) {
+	forEachInstruction(message, sizeOpcodes, func(_ string, args []string) {
+		if len(args) < 2 {
+			return
+		}
+		s.connMu.Lock()
+		s.params.width, s.params.height = args[0], args[1]
+		s.connMu.Unlock()
+	})
+}
+
 // broadcast appends data to the display buffer and sends it to all connected
 // clients, removing any that error.
 func (s *SharedSession) broadcast(data []byte) {
@@ -254,16 +464,53 @@ func (s *SharedSession) broadcast(data []byte) {
 		s.displayBuf = s.displayBuf[:len(s.displayBuf)-half]
 	}
 	clients := make([]*Client, 0, len(s.clients))
+	observed, measured := false, false
 	for c := range s.clients {
 		clients = append(clients, c)
+		observed = observed || c.onActivity != nil
+		measured = measured || c.stream != nil
 	}
 	s.mu.Unlock()
 
+	var activities []Activity
+	if observed {
+		activities = serverActivities(data)
+	}
+	var syncs []string
+	if measured {
+		syncs = syncTimestamps(data)
+	}
+
 	for _, c := range clients {
+		data := data
+		if c.clipOut != nil {
+			var blocked []Activity
+			data, blocked = c.clipOut.filter(data)
+			c.reportBlocked(blocked)
+		}
+		if c.onActivity != nil {
+			for _, a := range activities {
+				if a.Kind == ActivityClipboardOut && c.clipOut != nil {
+					continue
+				}
+				c.onActivity(a)
+			}
+		}
+		if len(data) == 0 {
+			continue
+		}
 		if err := c.writeMessage(websocket.TextMessage, data); err != nil {
 			log.Printf("SharedSession %s: broadcast write error, removing client: %v", s.sessionID, err)
+			c.stream.Dropped(len(syncs))
 			c.conn.Close()
 			c.close()
+			continue
+		}
+		if c.stream != nil {
+			c.stream.Sent(len(syncs), len(data))
+			for _, ts := range syncs {
+				c.stream.Ping(ts)
+			}
 		}
 	}
 }
@@ -275,6 +522,9 @@ func (s *SharedSession) handleClientInput(client *Client) {
 	defer client.close()
 
 	for {
+		if err := client.extendReadDeadline(); err != nil {
+			return
+		}
 		_, message, err := client.conn.ReadMessage()
 		if err != nil {
 			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) && err != io.EOF {
@@ -283,20 +533,62 @@ func (s *SharedSession) handleClientInput(client *Client) {
 			return
 		}
 
+		// Clients answer each frame's sync with the same timestamp once
+		// they have rendered it, which estimates the round-trip time
+		if client.stream != nil {
+			for _, ts := range syncTimestamps(message) {
+				client.stream.Pong(ts)
+			}
+		}
+
+		if client.lock != nil && hasInput(message) {
+			client.lock.Input()
+		}
+
 		if client.viewOnly || len(message) == 0 {
 			continue
 		}
 
+		if client.clipIn != nil {
+			var blocked []Activity
+			message, blocked = client.clipIn.filter(message)
+			client.reportBlocked(blocked)
+			if len(message) == 0 {
+				continue
+			}
+		}
+
+		if client.onActivity != nil {
+			for _, a := range clientActivities(message) {
+				client.onActivity(a)
+			}
+		}
+
+		s.trackSize(message)
+
+		// A failed write means the guacd connection is down; broadcastLoop
+		// notices too and reconnects, so the client stays connected and
+		// only this input is lost.
 		s.inputMu.Lock()
-		_, err = s.guacdConn.Write(message)
+		_, err = s.conn().Write(message)
 		s.inputMu.Unlock()
 		if err != nil {
 			log.Printf("SharedSession %s: guacd write error: %v", s.sessionID, err)
-			return
 		}
 	}
 }
 
+// reportBlocked reports clipboard transfers dropped by the client's
+// clipboard policy.
+func (c *Client) reportBlocked(blocked []Activity) {
+	if c.onBlocked == nil {
+		return
+	}
+	for _, a := range blocked {
+		c.onBlocked(a)
+	}
+}
+
 func (s *SharedSession) clientCount() int {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -304,7 +596,8 @@ func (s *SharedSession) clientCount() int {
 }
 
 // SessionRegistry is a thread-safe map of session ID → SharedSession.
-// It ensures exactly one guacd connection exists per Sortie session.
+// It ensures exactly one guacd connection exists per Sortie session, shared
+// by every client viewing it.
 type SessionRegistry struct {
 	mu       sync.Mutex
 	sessions map[string]*SharedSession
@@ -320,6 +613,13 @@ func NewSessionRegistry() *SessionRegistry {
 // GetOrCreate returns the existing SharedSession for the given session ID,
 // or creates a new one by dialing guacd and performing the handshake.
 func (r *SessionRegistry) GetOrCreate(sessionID, guacdAddr, hostname, port, username, password, width, height string) (*SharedSession, error) {
+	return r.GetOrCreateWithRDP(sessionID, guacdAddr, hostname, port, username, password, width, height, nil)
+}
+
+// GetOrCreateWithRDP is like GetOrCreate but opens a new session's guacd
+// connection with the given RDP settings. The settings of an existing
+// session are left as they are. A nil rdp uses the defaults.
+func (r *SessionRegistry) GetOrCreateWithRDP(sessionID, guacdAddr, hostname, port, username, password, width, height string, rdp *db.RDPSettings) (*SharedSession, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -334,7 +634,17 @@ func (r *SessionRegistry) GetOrCreate(sessionID, guacdAddr, hostname, port, user
 		}
 	}
 
-	s, err := newSharedSession(sessionID, guacdAddr, hostname, port, username, password, width, height, func() {
+	params := connParams{
+		guacdAddr: guacdAddr,
+		hostname:  hostname,
+		port:      port,
+		username:  username,
+		password:  password,
+		width:     width,
+		height:    height,
+		rdp:       rdp,
+	}
+	s, err := newSharedSession(sessionID, params, func() {
 		r.mu.Lock()
 		delete(r.sessions, sessionID)
 		r.mu.Unlock()
@@ -12,14 +12,16 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/rjsadow/sortie/internal/idlelock"
 )
 
 // fakeGuacd simulates a guacd server for testing. It performs a minimal
 // Guacamole handshake and then allows tests to send/receive data.
 type fakeGuacd struct {
-	listener net.Listener
-	conn     net.Conn // set after accept
-	mu       sync.Mutex
+	listener  net.Listener
+	conn      net.Conn // set after accept
+	handshake string   // client instructions received during the last handshake
+	mu        sync.Mutex
 }
 
 func newFakeGuacd(t *testing.T) *fakeGuacd {
@@ -80,6 +82,9 @@ func (f *fakeGuacd) acceptAndHandshake(t *testing.T) {
 		}
 		handshakeData += string(buf[:n])
 	}
+	f.mu.Lock()
+	f.handshake = handshakeData
+	f.mu.Unlock()
 
 	// Send ready response
 	readyInstr := encodeInstruction("ready", "test-conn-id")
@@ -123,6 +128,16 @@ func (f *fakeGuacd) closeConn() {
 	}
 }
 
+// fastReconnect shortens the guacd reconnect tunables for the test.
+func fastReconnect(t *testing.T) {
+	t.Helper()
+	dial, attempts, backoff := guacdDialTimeout, guacdReconnectAttempts, guacdReconnectBackoff
+	guacdDialTimeout, guacdReconnectAttempts, guacdReconnectBackoff = 200*time.Millisecond, 2, 10*time.Millisecond
+	t.Cleanup(func() {
+		guacdDialTimeout, guacdReconnectAttempts, guacdReconnectBackoff = dial, attempts, backoff
+	})
+}
+
 // wsDialer creates a WebSocket client connected to the given server URL.
 func wsDialer(t *testing.T, url string) *websocket.Conn {
 	t.Helper()
@@ -449,6 +464,7 @@ func TestSharedSession_LastClientCleanup(t *testing.T) {
 }
 
 func TestSharedSession_GuacdDisconnect(t *testing.T) {
+	fastReconnect(t)
 	guacd := newFakeGuacd(t)
 
 	done := make(chan struct{})
@@ -479,7 +495,10 @@ func TestSharedSession_GuacdDisconnect(t *testing.T) {
 
 	waitForClients(t, shared, 2)
 
-	// Simulate guacd crashing — close the TCP connection
+	// Simulate guacd going away for good — close the TCP connection and
+	// stop listening so reconnect attempts fail
+	failuresBefore := Stats().ReconnectFailures
+	guacd.listener.Close()
 	guacd.closeConn()
 
 	// Both clients should be disconnected
@@ -502,6 +521,82 @@ func TestSharedSession_GuacdDisconnect(t *testing.T) {
 			t.Errorf("client %d: expected error after guacd disconnect, got none", i+1)
 		}
 	}
+	if got := Stats().ReconnectFailures; got != failuresBefore+1 {
+		t.Errorf("ReconnectFailures = %d, want %d", got, failuresBefore+1)
+	}
+}
+
+func TestSharedSession_ReconnectsAfterGuacdRestart(t *testing.T) {
+	fastReconnect(t)
+	guacd := newFakeGuacd(t)
+
+	done := make(chan struct{})
+	go func() {
+		guacd.acceptAndHandshake(t)
+		close(done)
+	}()
+
+	registry := NewSessionRegistry()
+	shared, err := registry.GetOrCreate("sess-reconnect", guacd.addr(), "127.0.0.1", "3389", "u", "p", "1024", "768")
+	if err != nil {
+		t.Fatalf("GetOrCreate failed: %v", err)
+	}
+	<-done
+
+	client, server := createWSPair(t)
+	go shared.AddClient(server, false)
+	waitForClients(t, shared, 1)
+
+	// The client resizes its display before guacd restarts
+	if err := client.WriteMessage(websocket.TextMessage, []byte("4.size,4.1280,3.720;")); err != nil {
+		t.Fatalf("client write failed: %v", err)
+	}
+	if got := guacd.read(t); !strings.Contains(got, "4.size,4.1280,3.720;") {
+		t.Fatalf("guacd did not receive size instruction: %q", got)
+	}
+
+	before := Stats()
+	restarted := make(chan struct{})
+	go func() {
+		guacd.acceptAndHandshake(t)
+		close(restarted)
+	}()
+	guacd.closeConn()
+
+	select {
+	case <-restarted:
+	case <-time.After(3 * time.Second):
+		t.Fatal("session did not reconnect to guacd")
+	}
+
+	// The new handshake replays the size the client was showing
+	guacd.mu.Lock()
+	handshake := guacd.handshake
+	guacd.mu.Unlock()
+	if !strings.Contains(handshake, "4.size,4.1280,3.720,2.96;") {
+		t.Errorf("reconnect handshake did not replay client size: %q", handshake)
+	}
+
+	// The client is still attached and receives display data from the new connection
+	guacd.send(t, "4.sync,3.123;")
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, msg, err := client.ReadMessage()
+	if err != nil {
+		t.Fatalf("client read after reconnect failed: %v", err)
+	}
+	if string(msg) != "4.sync,3.123;" {
+		t.Errorf("client got %q after reconnect, want sync instruction", msg)
+	}
+	if shared.clientCount() != 1 {
+		t.Errorf("expected client to stay connected, have %d", shared.clientCount())
+	}
+
+	after := Stats()
+	if after.Errors != before.Errors+1 || after.Reconnects != before.Reconnects+1 {
+		t.Errorf("stats after reconnect = %+v, before = %+v; want one more error and reconnect", after, before)
+	}
+
+	shared.Close()
 }
 
 func TestSharedSession_InstructionBuffering(t *testing.T) {
@@ -898,3 +993,101 @@ func TestSharedSession_ConcurrentInputSerialization(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestSharedSession_IdleClientDisconnected(t *testing.T) {
+	guacd := newFakeGuacd(t)
+	done := make(chan struct{})
+	go func() {
+		guacd.acceptAndHandshake(t)
+		close(done)
+	}()
+
+	registry := NewSessionRegistry()
+	shared, err := registry.GetOrCreate("sess-idle", guacd.addr(), "127.0.0.1", "3389", "u", "p", "1024", "768")
+	if err != nil {
+		t.Fatalf("GetOrCreate failed: %v", err)
+	}
+	<-done
+
+	// A client that reads answers the pings and stays connected
+	live, liveServer := createWSPair(t)
+	go shared.addClient(liveServer, false, nil, nil, clipboardRule{}, 200*time.Millisecond, nil)
+	go func() {
+		for {
+			if _, _, err := live.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// A client that never reads never answers, and is removed
+	_, silentServer := createWSPair(t)
+	removed := make(chan struct{})
+	go func() {
+		shared.addClient(silentServer, false, nil, nil, clipboardRule{}, 200*time.Millisecond, nil)
+		close(removed)
+	}()
+
+	select {
+	case <-removed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("idle client was not disconnected")
+	}
+	if n := shared.clientCount(); n != 1 {
+		t.Errorf("clientCount() = %d, want the live client only", n)
+	}
+}
+
+func TestSharedSession_IdleLock(t *testing.T) {
+	guacd := newFakeGuacd(t)
+	done := make(chan struct{})
+	go func() {
+		guacd.acceptAndHandshake(t)
+		close(done)
+	}()
+
+	registry := NewSessionRegistry()
+	shared, err := registry.GetOrCreate("sess-lock", guacd.addr(), "127.0.0.1", "3389", "u", "p", "1024", "768")
+	if err != nil {
+		t.Fatalf("GetOrCreate failed: %v", err)
+	}
+	<-done
+
+	lock, release := idlelock.NewTracker().Watch("sess-lock", 300*time.Millisecond, nil)
+	defer release()
+	client, server := createWSPair(t)
+	removed := make(chan struct{})
+	go func() {
+		shared.addClient(server, false, nil, nil, clipboardRule{}, 0, lock)
+		close(removed)
+	}()
+
+	// Mouse input keeps the session unlocked; other instructions do not
+	for i := 0; i < 5; i++ {
+		if err := client.WriteMessage(websocket.TextMessage, []byte("5.mouse,2.10,2.20,1.0;")); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	select {
+	case <-lock.Locked():
+		t.Fatal("locked while the client sent input")
+	default:
+	}
+	client.WriteMessage(websocket.TextMessage, []byte("4.sync,3.123;"))
+
+	client.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		if _, _, err := client.ReadMessage(); err != nil {
+			if !websocket.IsCloseError(err, idlelock.CloseCode) {
+				t.Errorf("read error = %v, want a close with code %d", err, idlelock.CloseCode)
+			}
+			break
+		}
+	}
+	select {
+	case <-removed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("locked client was not removed")
+	}
+}
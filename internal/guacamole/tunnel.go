@@ -0,0 +1,87 @@
+package guacamole
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// Tunnel tuning. These are variables so tests can shorten them.
+var (
+	// guacdDialTimeout bounds connecting to guacd and completing the
+	// handshake.
+	guacdDialTimeout = 10 * time.Second
+	// guacdIdleTimeout is how long guacd may stay silent before its
+	// connection is treated as dead. guacd sends keep-alive nop
+	// instructions every few seconds, so silence means the connection was
+	// lost without being closed, e.g. when the sidecar's node went away.
+	guacdIdleTimeout = 30 * time.Second
+	// guacdReconnectAttempts is how many times a dropped connection is
+	// re-dialed before the session's clients are disconnected.
+	guacdReconnectAttempts = 5
+	// guacdReconnectBackoff is the delay before the first reconnect
+	// attempt. It doubles after each failed attempt.
+	guacdReconnectBackoff = 500 * time.Millisecond
+)
+
+// connParams are the parameters a shared session's guacd connection was
+// opened with. They are replayed when the connection is re-established.
+type connParams struct {
+	guacdAddr string
+	hostname  string
+	port      string
+	username  string
+	password  string
+	width     string
+	height    string
+	rdp       *db.RDPSettings
+}
+
+// dialGuacd connects to guacd and performs the RDP handshake. It returns
+// the connection and any display data that arrived with the ready
+// instruction.
+func dialGuacd(p connParams) (net.Conn, []byte, error) {
+	conn, err := net.DialTimeout("tcp", p.guacdAddr, guacdDialTimeout)
+	if err != nil {
+		tunnelStats.dialFailures.Add(1)
+		return nil, nil, fmt.Errorf("failed to connect to guacd at %s: %w", p.guacdAddr, err)
+	}
+	conn.SetDeadline(time.Now().Add(guacdDialTimeout))
+	excess, err := performHandshake(conn, p.hostname, p.port, p.username, p.password, p.width, p.height, p.rdp)
+	if err != nil {
+		conn.Close()
+		tunnelStats.dialFailures.Add(1)
+		return nil, nil, fmt.Errorf("guacamole handshake failed: %w", err)
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, excess, nil
+}
+
+// TunnelStats counts guacd tunnel events since the process started.
+type TunnelStats struct {
+	Active            int64 `json:"active"`             // Shared sessions with an open guacd connection
+	Opened            int64 `json:"opened"`             // Shared sessions opened
+	Errors            int64 `json:"errors"`             // guacd connections lost while in use
+	Reconnects        int64 `json:"reconnects"`         // Lost connections re-established
+	ReconnectFailures int64 `json:"reconnect_failures"` // Lost connections given up on
+	DialFailures      int64 `json:"dial_failures"`      // Failed attempts to connect or handshake
+}
+
+var tunnelStats struct {
+	active, opened, errors, reconnects, reconnectFailures, dialFailures atomic.Int64
+}
+
+// Stats returns the guacd tunnel counters.
+func Stats() TunnelStats {
+	return TunnelStats{
+		Active:            tunnelStats.active.Load(),
+		Opened:            tunnelStats.opened.Load(),
+		Errors:            tunnelStats.errors.Load(),
+		Reconnects:        tunnelStats.reconnects.Load(),
+		ReconnectFailures: tunnelStats.reconnectFailures.Load(),
+		DialFailures:      tunnelStats.dialFailures.Load(),
+	}
+}
@@ -0,0 +1,56 @@
+package healthwatch
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/notify"
+	"github.com/rjsadow/sortie/internal/ssrf"
+)
+
+// Event describes a component changing health state.
+type Event struct {
+	Component string    `json:"component"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Reason    string    `json:"reason,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Notifier delivers health events to operators. Notify errors are logged
+// and do not stop the watcher.
+type Notifier = notify.Notifier[Event]
+
+// LogNotifier writes events to the application log: degradations as
+// warnings, recoveries as info.
+type LogNotifier struct{}
+
+// Notify logs the event.
+func (n *LogNotifier) Notify(_ context.Context, event Event) error {
+	log := slog.Info
+	if event.To != StateHealthy {
+		log = slog.Warn
+	}
+	log("Component health changed",
+		"component", event.Component,
+		"from", event.From,
+		"to", event.To,
+		"reason", event.Reason)
+	return nil
+}
+
+// Name returns "log".
+func (n *LogNotifier) Name() string { return "log" }
+
+// NewWebhookNotifier returns a notifier that POSTs each event as JSON to
+// endpoint through the outbound guard.
+func NewWebhookNotifier(endpoint string, guard *ssrf.Guard) *notify.Webhook[Event] {
+	return notify.NewWebhook[Event](endpoint, guard)
+}
+
+// NewAdminNotifier returns a notifier that pushes each event to connected
+// admins as a "health" server-sent event.
+func NewAdminNotifier(publisher notify.RolePublisher) *notify.Admin[Event] {
+	return &notify.Admin[Event]{Publisher: publisher, Event: "health"}
+}
@@ -0,0 +1,300 @@
+// Package healthwatch records when server components change health state.
+// /readyz only reports the present; the watcher polls the same checks,
+// stores each transition in the database as a history operators can query,
+// and notifies them of degradations and recoveries as they happen.
+package healthwatch
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/leader"
+)
+
+// Health states. A component the watcher has no record of is unknown.
+const (
+	StateHealthy   = "healthy"
+	StateUnhealthy = "unhealthy"
+	StateUnknown   = "unknown"
+)
+
+// ComponentDatabase is the component name of the database check.
+const ComponentDatabase = "database"
+
+// DefaultRetention is how long health events are kept. Each component's
+// latest event is kept regardless, since it records the current state.
+const DefaultRetention = 90 * 24 * time.Hour
+
+// pruneInterval is how often expired events are deleted.
+const pruneInterval = time.Hour
+
+// Status is the result of checking one component.
+type Status struct {
+	Component string
+	State     string
+	Reason    string
+}
+
+// CheckFunc reports the current state of every component.
+type CheckFunc func(ctx context.Context) []Status
+
+// Watcher periodically checks component health and records transitions.
+type Watcher struct {
+	db        *db.DB
+	check     CheckFunc
+	notifiers []Notifier
+	interval  time.Duration
+	retention time.Duration
+	stopCh    chan struct{}
+	leader    leader.Checker
+
+	active    bool // Watched on the last tick, as leader or through an outage
+	states    map[string]string
+	pending   []db.HealthEvent // Transitions not yet stored
+	lastPrune time.Time
+}
+
+// NewWatcher creates a Watcher that runs check every interval and sends
+// each transition to notifiers. With no notifiers, events are logged.
+func NewWatcher(database *db.DB, check CheckFunc, interval time.Duration, notifiers ...Notifier) *Watcher {
+	if len(notifiers) == 0 {
+		notifiers = []Notifier{&LogNotifier{}}
+	}
+	return &Watcher{
+		db:        database,
+		check:     check,
+		notifiers: notifiers,
+		interval:  interval,
+		retention: DefaultRetention,
+		stopCh:    make(chan struct{}),
+		states:    make(map[string]string),
+	}
+}
+
+// Start launches the watch goroutine. It returns immediately.
+func (w *Watcher) Start() {
+	go w.loop()
+}
+
+// SetLeader makes the watcher run only while l reports this replica as
+// leader, so each transition is recorded once, and through a database
+// outage that began while it led. Call it before Start.
+func (w *Watcher) SetLeader(l leader.Checker) {
+	w.leader = l
+}
+
+// Stop signals the watch goroutine to exit.
+func (w *Watcher) Stop() {
+	close(w.stopCh)
+}
+
+func (w *Watcher) loop() {
+	w.tick(context.Background())
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.tick(context.Background())
+		case <-w.stopCh:
+			return
+		}
+	}
+}
+
+// tick checks and records component health if this replica is the leader.
+// A leader that loses the database also loses its lease, and no replica
+// can take over until the database is back, so the last leader keeps
+// watching through a database outage to record it and the recovery.
+func (w *Watcher) tick(ctx context.Context) {
+	leading := leader.IsLeader(w.leader)
+	if !leading && !w.active {
+		return
+	}
+	statuses := w.check(ctx)
+	w.record(ctx, statuses)
+	w.active = leading || databaseDown(statuses)
+}
+
+// databaseDown reports whether statuses include an unhealthy database.
+func databaseDown(statuses []Status) bool {
+	for _, s := range statuses {
+		if s.Component == ComponentDatabase && s.State != StateHealthy {
+			return true
+		}
+	}
+	return false
+}
+
+// record stores and announces transitions from the last known states.
+func (w *Watcher) record(ctx context.Context, statuses []Status) {
+	w.flush()
+
+	// Pick up transitions recorded by a previous leader. Without a
+	// database, or with transitions still unsaved, keep the states seen
+	// here.
+	if len(w.pending) == 0 {
+		if latest, err := w.db.LatestHealthEvents(time.Now()); err == nil {
+			for component, event := range latest {
+				w.states[component] = event.ToState
+			}
+		}
+	}
+
+	for _, status := range statuses {
+		prev, known := w.states[status.Component]
+		if prev == status.State {
+			continue
+		}
+		w.states[status.Component] = status.State
+		if !known && status.State == StateHealthy {
+			// Healthy from the start is not news
+			continue
+		}
+		if !known {
+			prev = StateUnknown
+		}
+
+		event := Event{
+			Component: status.Component,
+			From:      prev,
+			To:        status.State,
+			Reason:    status.Reason,
+			Timestamp: time.Now(),
+		}
+		w.pending = append(w.pending, db.HealthEvent{
+			Component: event.Component,
+			FromState: event.From,
+			ToState:   event.To,
+			Reason:    event.Reason,
+			CreatedAt: event.Timestamp,
+		})
+		w.notify(ctx, event)
+	}
+
+	w.flush()
+
+	if time.Since(w.lastPrune) >= pruneInterval {
+		if _, err := w.db.DeleteHealthEventsBefore(time.Now().Add(-w.retention)); err != nil {
+			slog.Warn("Health watch: failed to delete old events", "error", err)
+		} else {
+			w.lastPrune = time.Now()
+		}
+	}
+}
+
+// flush stores pending transitions in order. Transitions detected while
+// the database is down are kept until it comes back.
+func (w *Watcher) flush() {
+	for len(w.pending) > 0 {
+		if err := w.db.CreateHealthEvent(&w.pending[0]); err != nil {
+			slog.Warn("Health watch: failed to record event", "pending", len(w.pending), "error", err)
+			return
+		}
+		w.pending = w.pending[1:]
+	}
+}
+
+func (w *Watcher) notify(ctx context.Context, event Event) {
+	for _, n := range w.notifiers {
+		if err := n.Notify(ctx, event); err != nil {
+			slog.Warn("Health watch: notification failed",
+				"notifier", n.Name(),
+				"component", event.Component,
+				"error", err)
+		}
+	}
+}
+
+// Uptime summarizes a component's health over a window.
+type Uptime struct {
+	// Percent is the share of the window, excluding time in an unknown
+	// state, that the component was healthy.
+	Percent float64 `json:"percent"`
+	// UnhealthySeconds is the time spent in any state but healthy.
+	UnhealthySeconds int64 `json:"unhealthy_seconds"`
+	// Incidents counts transitions out of healthy.
+	Incidents int `json:"incidents"`
+}
+
+// Advance moves the start of an uptime window forward to t. Given each
+// component's latest event before the current start and the events since,
+// oldest first, it returns each component's latest event before t and the
+// events from t on, for ComputeUptime over a window starting at t.
+func Advance(before map[string]db.HealthEvent, events []db.HealthEvent, t time.Time) (map[string]db.HealthEvent, []db.HealthEvent) {
+	latest := make(map[string]db.HealthEvent, len(before))
+	for component, e := range before {
+		latest[component] = e
+	}
+	var rest []db.HealthEvent
+	for _, e := range events {
+		if e.CreatedAt.Before(t) {
+			latest[e.Component] = e
+		} else {
+			rest = append(rest, e)
+		}
+	}
+	return latest, rest
+}
+
+// ComputeUptime returns each component's uptime over [from, to], given
+// its latest event before from and its events in the window, oldest first.
+// Components with no known state in the window are omitted.
+func ComputeUptime(before map[string]db.HealthEvent, events []db.HealthEvent, from, to time.Time) map[string]Uptime {
+	type tally struct {
+		state     string
+		since     time.Time
+		healthy   time.Duration
+		unhealthy time.Duration
+		incidents int
+	}
+	tallies := make(map[string]*tally)
+	for component, e := range before {
+		tallies[component] = &tally{state: e.ToState, since: from}
+	}
+
+	add := func(t *tally, until time.Time) {
+		d := until.Sub(t.since)
+		switch t.state {
+		case StateHealthy:
+			t.healthy += d
+		case StateUnknown:
+		default:
+			t.unhealthy += d
+		}
+		t.since = until
+	}
+
+	for _, e := range events {
+		t, ok := tallies[e.Component]
+		if !ok {
+			t = &tally{state: e.FromState, since: from}
+			tallies[e.Component] = t
+		}
+		add(t, e.CreatedAt)
+		if t.state == StateHealthy && e.ToState != StateHealthy {
+			t.incidents++
+		}
+		t.state = e.ToState
+	}
+
+	uptime := make(map[string]Uptime, len(tallies))
+	for component, t := range tallies {
+		add(t, to)
+		known := t.healthy + t.unhealthy
+		if known <= 0 {
+			continue
+		}
+		uptime[component] = Uptime{
+			Percent:          math.Round(float64(t.healthy)/float64(known)*10000) / 100,
+			UnhealthySeconds: int64(t.unhealthy / time.Second),
+			Incidents:        t.incidents,
+		}
+	}
+	return uptime
+}
@@ -0,0 +1,230 @@
+package healthwatch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/db/dbtest"
+	"github.com/rjsadow/sortie/internal/ssrf/ssrftest"
+)
+
+// recordingNotifier captures events for assertions.
+type recordingNotifier struct {
+	events []Event
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, event Event) error {
+	n.events = append(n.events, event)
+	return nil
+}
+
+func (n *recordingNotifier) Name() string { return "recording" }
+
+// fakeCheck returns a CheckFunc reporting whatever states holds.
+func fakeCheck(states map[string]Status) CheckFunc {
+	return func(context.Context) []Status {
+		var out []Status
+		for _, s := range states {
+			out = append(out, s)
+		}
+		return out
+	}
+}
+
+func TestWatcher_Run(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+	states := map[string]Status{
+		"database": {Component: "database", State: StateHealthy},
+		"sessions": {Component: "sessions", State: StateUnhealthy, Reason: "overloaded"},
+	}
+	notifier := &recordingNotifier{}
+	w := NewWatcher(database, fakeCheck(states), time.Minute, notifier)
+
+	// First run: a healthy component is not news, an unhealthy one is
+	w.tick(context.Background())
+	if len(notifier.events) != 1 {
+		t.Fatalf("got %d events, want 1: %+v", len(notifier.events), notifier.events)
+	}
+	if e := notifier.events[0]; e.Component != "sessions" || e.From != StateUnknown || e.To != StateUnhealthy || e.Reason != "overloaded" {
+		t.Errorf("event = %+v", e)
+	}
+
+	// No change, no event
+	w.tick(context.Background())
+	if len(notifier.events) != 1 {
+		t.Fatalf("got %d events after a steady run, want 1", len(notifier.events))
+	}
+
+	states["database"] = Status{Component: "database", State: StateUnhealthy, Reason: "connection refused"}
+	states["sessions"] = Status{Component: "sessions", State: StateHealthy}
+	w.tick(context.Background())
+	if len(notifier.events) != 3 {
+		t.Fatalf("got %d events, want 3", len(notifier.events))
+	}
+
+	page, err := database.QueryHealthEvents(db.HealthEventFilter{Component: "database"})
+	if err != nil {
+		t.Fatalf("QueryHealthEvents() error = %v", err)
+	}
+	if page.Total != 1 || page.Events[0].FromState != StateHealthy || page.Events[0].Reason != "connection refused" {
+		t.Errorf("database events = %+v", page.Events)
+	}
+
+	// A new leader picks up the recorded states rather than starting over
+	next := &recordingNotifier{}
+	w2 := NewWatcher(database, fakeCheck(states), time.Minute, next)
+	w2.tick(context.Background())
+	if len(next.events) != 0 {
+		t.Errorf("new watcher sent %+v, want nothing", next.events)
+	}
+}
+
+func TestWatcher_KeepsEventsWhileDatabaseDown(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+	states := map[string]Status{"database": {Component: "database", State: StateUnhealthy, Reason: "down"}}
+	notifier := &recordingNotifier{}
+	w := NewWatcher(database, fakeCheck(states), time.Minute, notifier)
+
+	database.Close()
+	w.tick(context.Background())
+	if len(notifier.events) != 1 {
+		t.Fatalf("got %d notifications, want 1 even without a database", len(notifier.events))
+	}
+	if len(w.pending) != 1 {
+		t.Errorf("%d pending events, want 1", len(w.pending))
+	}
+}
+
+// fakeLeader is a leader.Checker whose answer the test sets.
+type fakeLeader struct{ leading bool }
+
+func (l *fakeLeader) IsLeader() bool { return l.leading }
+
+func TestWatcher_LeaderLosesDatabase(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+	states := map[string]Status{"database": {Component: "database", State: StateHealthy}}
+	notifier := &recordingNotifier{}
+	w := NewWatcher(database, fakeCheck(states), time.Minute, notifier)
+	l := &fakeLeader{}
+	w.SetLeader(l)
+
+	// Followers do nothing
+	states["database"] = Status{Component: "database", State: StateUnhealthy}
+	w.tick(context.Background())
+	if len(notifier.events) != 0 {
+		t.Fatalf("follower sent %+v", notifier.events)
+	}
+
+	// The leader sees the outage and, with it, loses the lease
+	states["database"] = Status{Component: "database", State: StateHealthy}
+	l.leading = true
+	w.tick(context.Background())
+	states["database"] = Status{Component: "database", State: StateUnhealthy, Reason: "down"}
+	w.tick(context.Background())
+	l.leading = false
+
+	// It keeps watching until the database is back, then stands down
+	w.tick(context.Background())
+	states["database"] = Status{Component: "database", State: StateHealthy}
+	w.tick(context.Background())
+	if len(notifier.events) != 2 || notifier.events[1].To != StateHealthy {
+		t.Fatalf("events = %+v, want the outage and the recovery", notifier.events)
+	}
+	states["database"] = Status{Component: "database", State: StateUnhealthy}
+	w.tick(context.Background())
+	if len(notifier.events) != 2 {
+		t.Errorf("watcher kept running after standing down: %+v", notifier.events)
+	}
+}
+
+func TestComputeUptime(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(10 * time.Hour)
+	before := map[string]db.HealthEvent{
+		"database": {Component: "database", ToState: StateHealthy},
+	}
+	events := []db.HealthEvent{
+		{Component: "database", FromState: StateHealthy, ToState: StateUnhealthy, CreatedAt: from.Add(2 * time.Hour)},
+		{Component: "sessions", FromState: StateUnknown, ToState: StateUnhealthy, CreatedAt: from.Add(5 * time.Hour)},
+		{Component: "database", FromState: StateUnhealthy, ToState: StateHealthy, CreatedAt: from.Add(3 * time.Hour)},
+		{Component: "sessions", FromState: StateUnhealthy, ToState: StateHealthy, CreatedAt: from.Add(6 * time.Hour)},
+	}
+
+	uptime := ComputeUptime(before, events, from, to)
+	if got := uptime["database"]; got.Percent != 90 || got.UnhealthySeconds != 3600 || got.Incidents != 1 {
+		t.Errorf("database uptime = %+v", got)
+	}
+	// Time before the first event, in an unknown state, does not count
+	if got := uptime["sessions"]; got.Percent != 80 || got.Incidents != 0 {
+		t.Errorf("sessions uptime = %+v", got)
+	}
+
+	if got := ComputeUptime(nil, nil, from, to); len(got) != 0 {
+		t.Errorf("ComputeUptime(no events) = %+v, want empty", got)
+	}
+}
+
+func TestAdvance(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := map[string]db.HealthEvent{
+		"database": {Component: "database", ToState: StateHealthy},
+	}
+	events := []db.HealthEvent{
+		{Component: "database", FromState: StateHealthy, ToState: StateUnhealthy, CreatedAt: from.Add(time.Hour)},
+		{Component: "sessions", FromState: StateUnknown, ToState: StateUnhealthy, CreatedAt: from.Add(2 * time.Hour)},
+		{Component: "database", FromState: StateUnhealthy, ToState: StateHealthy, CreatedAt: from.Add(4 * time.Hour)},
+	}
+
+	latest, rest := Advance(before, events, from.Add(3*time.Hour))
+	if latest["database"].ToState != StateUnhealthy || latest["sessions"].ToState != StateUnhealthy {
+		t.Errorf("Advance() states = %+v, want both unhealthy", latest)
+	}
+	if len(rest) != 1 || !rest[0].CreatedAt.Equal(from.Add(4*time.Hour)) {
+		t.Errorf("Advance() events = %+v, want the database recovery", rest)
+	}
+	if before["database"].ToState != StateHealthy {
+		t.Error("Advance() modified its input")
+	}
+
+	// Uptime over the later window only sees the time from its start
+	uptime := ComputeUptime(latest, rest, from.Add(3*time.Hour), from.Add(5*time.Hour))
+	if got := uptime["database"]; got.Percent != 50 || got.Incidents != 0 {
+		t.Errorf("database uptime = %+v", got)
+	}
+}
+
+func TestNotifiers(t *testing.T) {
+	var got Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	event := Event{Component: "database", From: StateHealthy, To: StateUnhealthy, Timestamp: time.Now()}
+	if err := NewWebhookNotifier(srv.URL, ssrftest.LoopbackGuard(t)).Notify(context.Background(), event); err != nil {
+		t.Fatalf("WebhookNotifier.Notify() error = %v", err)
+	}
+	if got.Component != "database" || got.To != StateUnhealthy {
+		t.Errorf("webhook received %+v", got)
+	}
+
+	pub := &fakePublisher{}
+	NewAdminNotifier(pub).Notify(context.Background(), event)
+	if pub.role != "admin" || pub.event != "health" {
+		t.Errorf("published %q to %q, want health to admin", pub.event, pub.role)
+	}
+}
+
+type fakePublisher struct {
+	role, event string
+}
+
+func (p *fakePublisher) PublishToRole(role, event string, _ any) {
+	p.role, p.event = role, event
+}
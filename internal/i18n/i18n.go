@@ -0,0 +1,143 @@
+// Package i18n translates user-facing text generated by the server, such as
+// error messages and the SSO callback page.
+//
+// Messages are keyed by their English text, so a string with no translation
+// falls back to English unchanged. Translations live in locales/<tag>.json,
+// one file per language, mapping English text (including any fmt verbs) to
+// the translated text. Adding a language means adding a file; it is picked
+// up at build time through go:embed.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// DefaultLocale is the language messages are written in.
+const DefaultLocale = "en"
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+var (
+	catalogs = map[string]map[string]string{}
+	tags     = []language.Tag{language.English} // DefaultLocale first
+	matcher  language.Matcher
+)
+
+func init() {
+	entries, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		panic(fmt.Sprintf("i18n: read locales: %v", err))
+	}
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		tag, err := language.Parse(name)
+		if err != nil {
+			panic(fmt.Sprintf("i18n: invalid locale file name %q: %v", entry.Name(), err))
+		}
+		data, err := localeFiles.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("i18n: read %s: %v", entry.Name(), err))
+		}
+		messages := map[string]string{}
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("i18n: parse %s: %v", entry.Name(), err))
+		}
+		catalogs[tag.String()] = messages
+		if tag != language.English {
+			tags = append(tags, tag)
+		}
+	}
+	matcher = language.NewMatcher(tags)
+}
+
+// Supported returns the supported locales, sorted, including DefaultLocale.
+func Supported() []string {
+	locales := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		locales = append(locales, tag.String())
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// IsSupported reports whether locale names a supported language exactly, as
+// returned by Supported.
+func IsSupported(locale string) bool {
+	for _, tag := range tags {
+		if tag.String() == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// Negotiate picks the locale for a request. A supported preferred locale
+// (the user's saved preference) wins; otherwise the best match for the
+// Accept-Language header is used, falling back to DefaultLocale.
+func Negotiate(preferred, acceptLanguage string) string {
+	if preferred != "" {
+		if tag, err := language.Parse(preferred); err == nil {
+			if _, _, conf := matcher.Match(tag); conf >= language.High {
+				return match(tag)
+			}
+		}
+	}
+	if acceptLanguage != "" {
+		desired, _, err := language.ParseAcceptLanguage(acceptLanguage)
+		if err == nil && len(desired) > 0 {
+			if _, _, conf := matcher.Match(desired...); conf != language.No {
+				return match(desired...)
+			}
+		}
+	}
+	return DefaultLocale
+}
+
+// match returns the supported locale that best matches the given tags.
+func match(desired ...language.Tag) string {
+	_, index, _ := matcher.Match(desired...)
+	return tags[index].String()
+}
+
+// Localizer translates messages into one locale.
+type Localizer struct {
+	locale   string
+	messages map[string]string
+}
+
+// New returns a Localizer for locale. Unsupported locales translate
+// nothing, so every message is returned in English.
+func New(locale string) *Localizer {
+	return &Localizer{locale: locale, messages: catalogs[locale]}
+}
+
+// Locale returns the locale the Localizer translates into.
+func (l *Localizer) Locale() string {
+	if l == nil || l.messages == nil {
+		return DefaultLocale
+	}
+	return l.locale
+}
+
+// T translates message and, when args are given, formats it with
+// fmt.Sprintf. Messages without a translation are returned in English.
+// A nil Localizer translates into English.
+func (l *Localizer) T(message string, args ...any) string {
+	if l != nil {
+		if translated, ok := l.messages[message]; ok && translated != "" {
+			message = translated
+		}
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
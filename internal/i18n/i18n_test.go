@@ -0,0 +1,94 @@
+package i18n
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name           string
+		preferred      string
+		acceptLanguage string
+		want           string
+	}{
+		{"nothing", "", "", "en"},
+		{"accept-language", "", "es-MX,es;q=0.9,en;q=0.8", "es"},
+		{"quality order", "", "en;q=0.5,es;q=0.9", "es"},
+		{"unsupported falls back", "", "ja,zh;q=0.9", "en"},
+		{"malformed header", "", ";;;", "en"},
+		{"preference wins", "es", "en-US", "es"},
+		{"regional preference", "es-AR", "", "es"},
+		{"unsupported preference ignored", "ja", "es", "es"},
+		{"invalid preference ignored", "not a locale!", "es", "es"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Negotiate(tt.preferred, tt.acceptLanguage); got != tt.want {
+				t.Errorf("Negotiate(%q, %q) = %q, want %q", tt.preferred, tt.acceptLanguage, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalizer(t *testing.T) {
+	es := New("es")
+	if got := es.T("Invalid credentials"); got != "Credenciales no válidas" {
+		t.Errorf("T() = %q", got)
+	}
+	if got := es.T("SSO error: %s", "denied"); got != "Error de inicio de sesión único: denied" {
+		t.Errorf("T() with args = %q", got)
+	}
+	if got := es.T("A message nobody translated"); got != "A message nobody translated" {
+		t.Errorf("untranslated T() = %q", got)
+	}
+	if es.Locale() != "es" {
+		t.Errorf("Locale() = %q", es.Locale())
+	}
+
+	for _, l := range []*Localizer{New("en"), New("xx"), nil} {
+		if got := l.T("Invalid credentials"); got != "Invalid credentials" {
+			t.Errorf("T() = %q, want English", got)
+		}
+		if l.Locale() != DefaultLocale {
+			t.Errorf("Locale() = %q, want %q", l.Locale(), DefaultLocale)
+		}
+	}
+}
+
+func TestSupported(t *testing.T) {
+	locales := Supported()
+	if len(locales) < 2 || locales[0] != "en" {
+		t.Fatalf("Supported() = %v", locales)
+	}
+	for _, locale := range locales {
+		if !IsSupported(locale) {
+			t.Errorf("IsSupported(%q) = false", locale)
+		}
+	}
+	if IsSupported("es-MX") || IsSupported("") {
+		t.Error("IsSupported should only accept exact supported locales")
+	}
+}
+
+// Translations must keep the fmt verbs of the English message, in order,
+// or formatting them produces garbage.
+func TestCatalogVerbs(t *testing.T) {
+	verb := regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+	for locale, messages := range catalogs {
+		for message, translated := range messages {
+			want := verb.FindAllString(message, -1)
+			got := verb.FindAllString(translated, -1)
+			if len(want) != len(got) {
+				t.Errorf("%s: %q has verbs %v, translation has %v", locale, message, want, got)
+				continue
+			}
+			for i := range want {
+				if want[i] != got[i] {
+					t.Errorf("%s: %q has verbs %v, translation has %v", locale, message, want, got)
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Package icons stores the application and template icons that admins
+// upload and provides the built-in icons used by the seeded templates.
+// Icons are content-addressed: an uploaded icon's ID is derived from its
+// bytes, so a URL always serves the same image and can be cached forever.
+// The image bytes live in the branding store under "icons/".
+package icons
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"net/url"
+	"strings"
+
+	"github.com/rjsadow/sortie/internal/branding"
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// MaxSize is the largest icon accepted for upload.
+const MaxSize = 512 << 10 // 512 KiB
+
+// URLPrefix is the path under which icons are served.
+const URLPrefix = "/api/assets/icons/"
+
+// builtinPrefix marks the IDs of the icons embedded in the binary.
+const builtinPrefix = "builtin-"
+
+//go:embed builtin/*.svg
+var builtinFS embed.FS
+
+// extContentType maps icon ID extensions to the content type served.
+var extContentType = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".ico":  "image/x-icon",
+	".svg":  "image/svg+xml",
+}
+
+// ID returns the ID of an icon with the given bytes and content type.
+func ID(data []byte, contentType string) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16]) + branding.Extension(contentType)
+}
+
+// Path returns the storage path of an uploaded icon.
+func Path(id string) string {
+	return "icons/" + id
+}
+
+// URL returns the URL that serves the icon with the given ID.
+func URL(id string) string {
+	return URLPrefix + id
+}
+
+// BuiltinURL returns the URL of the built-in icon with the given name,
+// which is the ID of the template it was drawn for.
+func BuiltinURL(name string) string {
+	return URL(builtinPrefix + name + ".svg")
+}
+
+// ContentType returns the content type of the icon with the given ID. It
+// reports false if id is not a well-formed icon ID.
+func ContentType(id string) (string, bool) {
+	dot := strings.LastIndexByte(id, '.')
+	if dot < 0 {
+		return "", false
+	}
+	name, ext := id[:dot], id[dot:]
+	contentType, ok := extContentType[ext]
+	if !ok {
+		return "", false
+	}
+	if IsBuiltin(id) {
+		return contentType, ext == ".svg" && validBuiltinName(strings.TrimPrefix(name, builtinPrefix))
+	}
+	if len(name) != 32 {
+		return "", false
+	}
+	for _, c := range name {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return "", false
+		}
+	}
+	return contentType, true
+}
+
+// IsBuiltin reports whether id names a built-in icon rather than an upload.
+func IsBuiltin(id string) bool {
+	return strings.HasPrefix(id, builtinPrefix)
+}
+
+func validBuiltinName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// Builtin returns the image of a built-in icon. It reports false if there
+// is no built-in icon with that ID.
+func Builtin(id string) ([]byte, bool) {
+	if _, ok := ContentType(id); !ok || !IsBuiltin(id) {
+		return nil, false
+	}
+	data, err := builtinFS.ReadFile("builtin/" + strings.TrimPrefix(id, builtinPrefix))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// IsRemote reports whether icon is an absolute URL, which browsers load
+// from another host rather than from Sortie.
+func IsRemote(icon string) bool {
+	u, err := url.Parse(strings.TrimSpace(icon))
+	return err == nil && (u.Host != "" || u.Scheme == "http" || u.Scheme == "https")
+}
+
+// ReferencedPaths returns the storage paths of the uploaded icons that
+// applications and templates use, for orphan detection.
+func ReferencedPaths(database *db.DB) (map[string]bool, error) {
+	apps, err := database.ListApps()
+	if err != nil {
+		return nil, err
+	}
+	templates, err := database.ListTemplates()
+	if err != nil {
+		return nil, err
+	}
+	refs := make(map[string]bool)
+	add := func(icon string) {
+		id, ok := strings.CutPrefix(icon, URLPrefix)
+		if !ok || IsBuiltin(id) {
+			return
+		}
+		if _, ok := ContentType(id); ok {
+			refs[Path(id)] = true
+		}
+	}
+	for _, app := range apps {
+		add(app.Icon)
+	}
+	for _, t := range templates {
+		add(t.Icon)
+	}
+	return refs, nil
+}
@@ -0,0 +1,134 @@
+package icons
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+func TestID(t *testing.T) {
+	data := []byte(`<svg viewBox="0 0 1 1"></svg>`)
+	id := ID(data, "image/svg+xml")
+	if len(id) != 36 || !strings.HasSuffix(id, ".svg") {
+		t.Fatalf("ID() = %q, want 32 hex digits and .svg", id)
+	}
+	if ID(data, "image/svg+xml") != id {
+		t.Error("ID() should be stable for the same bytes")
+	}
+	if ID([]byte(`<svg></svg>`), "image/svg+xml") == id {
+		t.Error("ID() should differ for different bytes")
+	}
+	if got, ok := ContentType(id); !ok || got != "image/svg+xml" {
+		t.Errorf("ContentType(%q) = %q, %v", id, got, ok)
+	}
+}
+
+func TestContentType(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+		ok   bool
+	}{
+		{"0123456789abcdef0123456789abcdef.png", "image/png", true},
+		{"0123456789abcdef0123456789abcdef.ico", "image/x-icon", true},
+		{"builtin-jupyterlab.svg", "image/svg+xml", true},
+		{"0123456789ABCDEF0123456789abcdef.png", "", false},
+		{"0123456789abcdef.png", "", false},
+		{"0123456789abcdef0123456789abcdef.exe", "", false},
+		{"0123456789abcdef0123456789abcdef", "", false},
+		{"builtin-jupyterlab.png", "", false},
+		{"builtin-../secret.svg", "", false},
+		{"builtin-.svg", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ContentType(tt.id)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("ContentType(%q) = %q, %v; want %q, %v", tt.id, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestBuiltin(t *testing.T) {
+	data, ok := Builtin("builtin-jupyterlab.svg")
+	if !ok || !strings.Contains(string(data), "<svg") {
+		t.Fatalf("Builtin(jupyterlab) = %q, %v", data, ok)
+	}
+	for _, id := range []string{"builtin-missing.svg", "builtin-../icons.go", "0123456789abcdef0123456789abcdef.svg"} {
+		if _, ok := Builtin(id); ok {
+			t.Errorf("Builtin(%q) should not be found", id)
+		}
+	}
+}
+
+func TestIsRemote(t *testing.T) {
+	for icon, want := range map[string]bool{
+		"https://example.com/favicon.ico":           true,
+		"http://intranet/icon.png":                  true,
+		"//cdn.example.com/icon.svg":                true,
+		" https://example.com/icon.png":             true,
+		URL("0123456789abcdef0123456789abcdef.png"): false,
+		BuiltinURL("gimp"):                          false,
+		"data:image/svg+xml;base64,PHN2Zz4=":        false,
+		"":                                          false,
+	} {
+		if got := IsRemote(icon); got != want {
+			t.Errorf("IsRemote(%q) = %v, want %v", icon, got, want)
+		}
+	}
+}
+
+// TestBuiltin_SeededTemplates checks that every seeded template uses a
+// built-in icon that exists.
+func TestBuiltin_SeededTemplates(t *testing.T) {
+	data, err := os.ReadFile("../../web/src/data/templates.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var catalog db.TemplateCatalog
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		t.Fatal(err)
+	}
+	for _, tmpl := range catalog.Templates {
+		if tmpl.Icon != BuiltinURL(tmpl.TemplateID) {
+			t.Errorf("template %s icon = %q, want %q", tmpl.TemplateID, tmpl.Icon, BuiltinURL(tmpl.TemplateID))
+			continue
+		}
+		if _, ok := Builtin(strings.TrimPrefix(tmpl.Icon, URLPrefix)); !ok {
+			t.Errorf("template %s has no built-in icon", tmpl.TemplateID)
+		}
+	}
+}
+
+func TestReferencedPaths(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	uploaded := "0123456789abcdef0123456789abcdef.png"
+	for _, app := range []db.Application{
+		{ID: "uploaded", Name: "Uploaded", Icon: URL(uploaded), URL: "https://example.com"},
+		{ID: "builtin", Name: "Builtin", Icon: BuiltinURL("gimp"), URL: "https://example.com"},
+		{ID: "external", Name: "External", Icon: "https://example.com/favicon.ico", URL: "https://example.com"},
+	} {
+		if err := database.CreateApp(app); err != nil {
+			t.Fatalf("CreateApp(%s): %v", app.ID, err)
+		}
+	}
+	tmplIcon := "fedcba9876543210fedcba9876543210.svg"
+	if err := database.CreateTemplate(db.Template{TemplateID: "t1", Name: "T1", Icon: URL(tmplIcon)}); err != nil {
+		t.Fatalf("CreateTemplate: %v", err)
+	}
+
+	refs, err := ReferencedPaths(database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 2 || !refs[Path(uploaded)] || !refs[Path(tmplIcon)] {
+		t.Errorf("ReferencedPaths() = %v, want the uploaded app and template icons", refs)
+	}
+}
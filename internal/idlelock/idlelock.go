@@ -0,0 +1,131 @@
+// Package idlelock locks a session's display stream once its owner has
+// sent no input for a while, so an unattended workstation does not keep
+// showing the session.
+package idlelock
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CloseCode is the WebSocket close code a locked stream is closed with, in
+// the range RFC 6455 leaves to applications. Clients should not reconnect
+// on it until the session is unlocked.
+const CloseCode = 4003
+
+// CloseReason is the close reason sent with CloseCode.
+const CloseReason = "Session locked"
+
+// Watcher locks one session after a timeout without input. It is safe for
+// concurrent use by the stream connections of the session.
+type Watcher struct {
+	timeout   time.Duration
+	lastInput atomic.Int64 // UnixNano
+	locked    chan struct{}
+	lockOnce  sync.Once
+	onLock    func()
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	stopped bool
+	refs    int
+}
+
+func newWatcher(timeout time.Duration, onLock func()) *Watcher {
+	w := &Watcher{timeout: timeout, locked: make(chan struct{}), onLock: onLock}
+	w.lastInput.Store(time.Now().UnixNano())
+	w.timer = time.AfterFunc(timeout, w.check)
+	return w
+}
+
+// Input records input from the session's owner, pushing back the lock.
+func (w *Watcher) Input() {
+	w.lastInput.Store(time.Now().UnixNano())
+}
+
+// Locked returns a channel that is closed once the session is locked.
+func (w *Watcher) Locked() <-chan struct{} {
+	return w.locked
+}
+
+// check runs when the timeout may have passed. Input is only timestamped,
+// so the timer is re-armed for the rest of the timeout if there was some.
+func (w *Watcher) check() {
+	idle := time.Since(time.Unix(0, w.lastInput.Load()))
+	if idle < w.timeout {
+		w.mu.Lock()
+		if !w.stopped {
+			w.timer = time.AfterFunc(w.timeout-idle, w.check)
+		}
+		w.mu.Unlock()
+		return
+	}
+	// onLock records the lock before the streams close, so a client that
+	// reloads the session on disconnect already sees it locked
+	w.lockOnce.Do(func() {
+		if w.onLock != nil {
+			w.onLock()
+		}
+		close(w.locked)
+	})
+}
+
+// stop stops the timer, so the session is never locked.
+func (w *Watcher) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopped = true
+	w.timer.Stop()
+}
+
+// Tracker keeps one Watcher per session, shared by all of the session's
+// stream connections on this server. It is safe for concurrent use.
+type Tracker struct {
+	mu       sync.Mutex
+	watchers map[string]*Watcher
+}
+
+// NewTracker creates an empty Tracker.
+func NewTracker() *Tracker {
+	return &Tracker{watchers: make(map[string]*Watcher)}
+}
+
+// Watch returns the session's Watcher, starting one that locks the
+// session after timeout without input and then calls onLock, and a
+// function that releases it again. A locked Watcher is replaced, since a
+// new connection means the session was unlocked. The Watcher stops when
+// its last connection releases it.
+func (t *Tracker) Watch(sessionID string, timeout time.Duration, onLock func()) (w *Watcher, release func()) {
+	t.mu.Lock()
+	w, ok := t.watchers[sessionID]
+	if ok {
+		select {
+		case <-w.locked:
+			ok = false
+		default:
+		}
+	}
+	if !ok {
+		w = newWatcher(timeout, onLock)
+		t.watchers[sessionID] = w
+	}
+	w.refs++
+	t.mu.Unlock()
+
+	var once sync.Once
+	return w, func() {
+		once.Do(func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			w.refs--
+			if w.refs > 0 {
+				return
+			}
+			w.stop()
+			if t.watchers[sessionID] == w {
+				delete(t.watchers, sessionID)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package idlelock
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatcherLocksAfterTimeout(t *testing.T) {
+	tracker := NewTracker()
+	var locks atomic.Int32
+	w, release := tracker.Watch("s1", 50*time.Millisecond, func() { locks.Add(1) })
+	defer release()
+
+	// Input keeps the session unlocked past the timeout
+	deadline := time.Now().Add(150 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		w.Input()
+		select {
+		case <-w.Locked():
+			t.Fatal("locked while receiving input")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	select {
+	case <-w.Locked():
+	case <-time.After(time.Second):
+		t.Fatal("not locked after the timeout without input")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := locks.Load(); n != 1 {
+		t.Errorf("onLock called %d times, want 1", n)
+	}
+}
+
+func TestTrackerSharesWatcher(t *testing.T) {
+	tracker := NewTracker()
+	w1, release1 := tracker.Watch("s1", time.Hour, nil)
+	w2, release2 := tracker.Watch("s1", time.Hour, nil)
+	if w1 != w2 {
+		t.Error("connections to one session got different watchers")
+	}
+	other, releaseOther := tracker.Watch("s2", time.Hour, nil)
+	defer releaseOther()
+	if other == w1 {
+		t.Error("sessions share a watcher")
+	}
+
+	release1()
+	release1() // releasing twice counts once
+	if w, release := tracker.Watch("s1", time.Hour, nil); w != w2 {
+		t.Error("watcher replaced while still in use")
+	} else {
+		release()
+	}
+	release2()
+	if w, release := tracker.Watch("s1", time.Hour, nil); w == w1 {
+		t.Error("released watcher reused")
+	} else {
+		release()
+	}
+}
+
+func TestTrackerReplacesLockedWatcher(t *testing.T) {
+	tracker := NewTracker()
+	locked, release := tracker.Watch("s1", time.Millisecond, nil)
+	defer release()
+	select {
+	case <-locked.Locked():
+	case <-time.After(time.Second):
+		t.Fatal("not locked")
+	}
+
+	w, releaseNew := tracker.Watch("s1", time.Hour, nil)
+	if w == locked {
+		t.Fatal("new connection got the locked watcher")
+	}
+	// The old connection going away leaves the new watcher in place
+	release()
+	if again, releaseAgain := tracker.Watch("s1", time.Hour, nil); again != w {
+		t.Error("releasing the locked watcher dropped its replacement")
+	} else {
+		releaseAgain()
+	}
+	releaseNew()
+}
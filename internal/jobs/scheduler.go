@@ -0,0 +1,446 @@
+// Package jobs runs the server's periodic background work, such as session
+// cleanup and recording retention, on the leader replica. Every run is
+// recorded in the database, so operators can see from any replica when
+// each job last ran and how it went, and request a run by hand.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/leader"
+)
+
+const (
+	// DefaultPollInterval is how often the leader checks for due and
+	// requested runs.
+	DefaultPollInterval = 5 * time.Second
+
+	// DefaultRetryDelay is the wait before a failed job's first retry. It
+	// doubles for each further retry.
+	DefaultRetryDelay = 10 * time.Second
+
+	// DefaultTimeout bounds a single attempt of a job.
+	DefaultTimeout = time.Hour
+
+	// DefaultRetention is how long job runs are kept. Each job's latest run
+	// is kept regardless.
+	DefaultRetention = 7 * 24 * time.Hour
+
+	// pruneInterval is how often expired runs are deleted.
+	pruneInterval = time.Hour
+)
+
+var (
+	// ErrUnknownJob is returned for a job name that was never registered.
+	ErrUnknownJob = errors.New("unknown job")
+
+	// ErrJobActive is returned when triggering a job that is already
+	// queued or running.
+	ErrJobActive = errors.New("job is already queued or running")
+)
+
+// Job is a unit of periodic background work.
+type Job struct {
+	// Name identifies the job in the API and metrics.
+	Name string
+	// Description says what the job does, for operators.
+	Description string
+	// Interval is the time from the start of one run to the next.
+	Interval time.Duration
+	// Retries is how many more attempts a failed run makes (0 = none).
+	Retries int
+	// RetryDelay is the wait before the first retry (0 = DefaultRetryDelay).
+	RetryDelay time.Duration
+	// Timeout bounds each attempt (0 = DefaultTimeout).
+	Timeout time.Duration
+	// Run does the work. An error fails the attempt.
+	Run func(ctx context.Context) error
+}
+
+// Status describes a registered job and its latest run.
+type Status struct {
+	Name            string     `json:"name"`
+	Description     string     `json:"description"`
+	IntervalSeconds int64      `json:"interval_seconds"`
+	Retries         int        `json:"retries"`
+	LastRun         *db.JobRun `json:"last_run,omitempty"`
+	NextRun         *time.Time `json:"next_run,omitempty"`
+}
+
+// Metrics counts one job's runs on this replica.
+type Metrics struct {
+	Runs                int64   `json:"runs"`
+	Failures            int64   `json:"failures"`
+	Running             bool    `json:"running"`
+	LastDurationSeconds float64 `json:"last_duration_seconds"`
+	LastSuccess         int64   `json:"last_success_timestamp"`
+}
+
+type entry struct {
+	job     Job
+	running bool
+	metrics Metrics
+}
+
+// Scheduler runs registered jobs on the leader replica.
+type Scheduler struct {
+	db           *db.DB
+	identity     string
+	leader       leader.Checker
+	pollInterval time.Duration
+	retention    time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	order   []string
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	lastPrune time.Time
+}
+
+// NewScheduler creates a Scheduler that records runs in database under
+// identity, the replica's leader election identity.
+func NewScheduler(database *db.DB, identity string) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		db:           database,
+		identity:     identity,
+		pollInterval: DefaultPollInterval,
+		retention:    DefaultRetention,
+		entries:      make(map[string]*entry),
+		ctx:          ctx,
+		cancel:       cancel,
+		stopCh:       make(chan struct{}),
+	}
+}
+
+// SetLeader makes the scheduler run jobs only while l reports this replica
+// as leader. Call it before Start.
+func (s *Scheduler) SetLeader(l leader.Checker) {
+	s.leader = l
+}
+
+// Register adds a job. Names must be unique and intervals positive.
+func (s *Scheduler) Register(job Job) error {
+	if job.Name == "" || job.Run == nil {
+		return fmt.Errorf("job needs a name and a Run function")
+	}
+	if job.Interval <= 0 {
+		return fmt.Errorf("job %s: interval must be positive", job.Name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[job.Name]; ok {
+		return fmt.Errorf("job %s is already registered", job.Name)
+	}
+	s.entries[job.Name] = &entry{job: job}
+	s.order = append(s.order, job.Name)
+	return nil
+}
+
+// Start launches the scheduling goroutine. It returns immediately.
+func (s *Scheduler) Start() {
+	go s.loop()
+}
+
+// Stop cancels running jobs and waits for them to return.
+func (s *Scheduler) Stop() {
+	close(s.stopCh)
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop() {
+	s.tick()
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.tick()
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// tick starts requested and due runs if this replica is the leader. A job
+// is due an interval after its latest run was created, so a new leader
+// carries on the previous leader's schedule.
+func (s *Scheduler) tick() {
+	if !leader.IsLeader(s.leader) {
+		return
+	}
+
+	queued, err := s.db.ListQueuedJobRuns()
+	if err != nil {
+		slog.Warn("Jobs: failed to list queued runs", "error", err)
+		return
+	}
+	for _, run := range queued {
+		e := s.entry(run.Job)
+		if e == nil || !s.reserve(e) {
+			continue
+		}
+		ok, err := s.db.ClaimJobRun(run.ID, s.identity, time.Now())
+		if err != nil || !ok {
+			s.release(e)
+			continue
+		}
+		s.start(e, run)
+	}
+
+	latest, err := s.db.LatestJobRuns()
+	if err != nil {
+		slog.Warn("Jobs: failed to read latest runs", "error", err)
+		return
+	}
+	now := time.Now()
+	for _, name := range s.names() {
+		e := s.entry(name)
+		if last, ok := latest[name]; ok {
+			if last.Status == db.JobRunRunning {
+				// Runs on a replica that stopped never finish; give up on
+				// them once they are well past their timeout
+				s.db.InterruptJobRuns(name, now.Add(-e.job.timeout()-time.Minute))
+				continue
+			}
+			if last.Status == db.JobRunQueued || now.Before(last.CreatedAt.Add(e.job.Interval)) {
+				continue
+			}
+		}
+		if !s.reserve(e) {
+			continue
+		}
+		run := db.JobRun{
+			Job:       name,
+			Trigger:   db.JobTriggerSchedule,
+			Status:    db.JobRunRunning,
+			Replica:   s.identity,
+			CreatedAt: now,
+			StartedAt: &now,
+		}
+		if err := s.db.CreateJobRun(&run); err != nil {
+			slog.Warn("Jobs: failed to record run", "job", name, "error", err)
+			s.release(e)
+			continue
+		}
+		s.start(e, run)
+	}
+
+	if time.Since(s.lastPrune) >= pruneInterval {
+		if _, err := s.db.DeleteJobRunsBefore(time.Now().Add(-s.retention)); err != nil {
+			slog.Warn("Jobs: failed to delete old runs", "error", err)
+		} else {
+			s.lastPrune = time.Now()
+		}
+	}
+}
+
+// Trigger requests a run of the named job by user. On the leader it
+// starts straight away; otherwise it is queued for the leader to start.
+func (s *Scheduler) Trigger(name, user string) (*db.JobRun, error) {
+	e := s.entry(name)
+	if e == nil {
+		return nil, ErrUnknownJob
+	}
+	latest, err := s.db.LatestJobRuns()
+	if err != nil {
+		return nil, err
+	}
+	if last, ok := latest[name]; ok && (last.Status == db.JobRunQueued || last.Status == db.JobRunRunning) {
+		return nil, ErrJobActive
+	}
+
+	run := db.JobRun{
+		Job:         name,
+		Trigger:     db.JobTriggerManual,
+		RequestedBy: user,
+		Status:      db.JobRunQueued,
+	}
+	if !leader.IsLeader(s.leader) {
+		if err := s.db.CreateJobRun(&run); err != nil {
+			return nil, err
+		}
+		return &run, nil
+	}
+
+	if !s.reserve(e) {
+		return nil, ErrJobActive
+	}
+	now := time.Now()
+	run.Status = db.JobRunRunning
+	run.Replica = s.identity
+	run.CreatedAt = now
+	run.StartedAt = &now
+	if err := s.db.CreateJobRun(&run); err != nil {
+		s.release(e)
+		return nil, err
+	}
+	s.start(e, run)
+	return &run, nil
+}
+
+// start runs a recorded run in the background. The entry must be reserved.
+func (s *Scheduler) start(e *entry, run db.JobRun) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.execute(e, run)
+	}()
+}
+
+// execute runs the job, retrying failed attempts, and records the outcome.
+func (s *Scheduler) execute(e *entry, run db.JobRun) {
+	job := e.job
+	started := time.Now()
+	delay := job.RetryDelay
+	if delay <= 0 {
+		delay = DefaultRetryDelay
+	}
+
+	var err error
+	attempts := 0
+	for {
+		attempts++
+		err = s.attempt(job)
+		if err == nil || attempts > job.Retries {
+			break
+		}
+		slog.Warn("Job failed, retrying", "job", job.Name, "attempt", attempts, "retry_in", delay, "error", err)
+		select {
+		case <-time.After(delay):
+		case <-s.stopCh:
+		}
+		if s.ctx.Err() != nil {
+			break
+		}
+		delay *= 2
+	}
+	finished := time.Now()
+
+	status, msg := db.JobRunSucceeded, ""
+	if err != nil {
+		status, msg = db.JobRunFailed, err.Error()
+		slog.Warn("Job failed", "job", job.Name, "attempts", attempts, "error", err)
+	}
+	if dbErr := s.db.FinishJobRun(run.ID, status, attempts, msg, finished); dbErr != nil {
+		slog.Warn("Jobs: failed to record run outcome", "job", job.Name, "error", dbErr)
+	}
+
+	s.mu.Lock()
+	e.running = false
+	e.metrics.Runs++
+	if err != nil {
+		e.metrics.Failures++
+	} else {
+		e.metrics.LastSuccess = finished.Unix()
+	}
+	e.metrics.LastDurationSeconds = finished.Sub(started).Seconds()
+	s.mu.Unlock()
+}
+
+// attempt runs the job once under its timeout, turning a panic into an
+// error.
+func (s *Scheduler) attempt(job Job) (err error) {
+	ctx, cancel := context.WithTimeout(s.ctx, job.timeout())
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+// Status returns each registered job, in registration order, with its
+// latest run from any replica.
+func (s *Scheduler) Status() ([]Status, error) {
+	latest, err := s.db.LatestJobRuns()
+	if err != nil {
+		return nil, err
+	}
+	statuses := []Status{}
+	for _, name := range s.names() {
+		job := s.entry(name).job
+		status := Status{
+			Name:            job.Name,
+			Description:     job.Description,
+			IntervalSeconds: int64(job.Interval / time.Second),
+			Retries:         job.Retries,
+		}
+		if last, ok := latest[name]; ok {
+			status.LastRun = &last
+			next := last.CreatedAt.Add(job.Interval)
+			status.NextRun = &next
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Has reports whether a job with the name is registered.
+func (s *Scheduler) Has(name string) bool {
+	return s.entry(name) != nil
+}
+
+// Metrics returns run counters for each job, from runs on this replica.
+func (s *Scheduler) Metrics() map[string]Metrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	metrics := make(map[string]Metrics, len(s.entries))
+	for name, e := range s.entries {
+		m := e.metrics
+		m.Running = e.running
+		metrics[name] = m
+	}
+	return metrics
+}
+
+func (s *Scheduler) entry(name string) *entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries[name]
+}
+
+func (s *Scheduler) names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.order...)
+}
+
+// reserve marks the job as running here, unless it already is.
+func (s *Scheduler) reserve(e *entry) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e.running {
+		return false
+	}
+	e.running = true
+	return true
+}
+
+func (s *Scheduler) release(e *entry) {
+	s.mu.Lock()
+	e.running = false
+	s.mu.Unlock()
+}
+
+func (j Job) timeout() time.Duration {
+	if j.Timeout > 0 {
+		return j.Timeout
+	}
+	return DefaultTimeout
+}
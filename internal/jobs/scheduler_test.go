@@ -0,0 +1,150 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/db/dbtest"
+)
+
+type fixedLeader bool
+
+func (l fixedLeader) IsLeader() bool { return bool(l) }
+
+func latestRun(t *testing.T, database *db.DB, job string) db.JobRun {
+	t.Helper()
+	latest, err := database.LatestJobRuns()
+	if err != nil {
+		t.Fatalf("LatestJobRuns() error = %v", err)
+	}
+	return latest[job]
+}
+
+func TestScheduler_RunsDueJobs(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+	var calls atomic.Int32
+	s := NewScheduler(database, "replica-a")
+	if err := s.Register(Job{Name: "count", Interval: time.Hour, Run: func(context.Context) error {
+		calls.Add(1)
+		return nil
+	}}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := s.Register(Job{Name: "count", Interval: time.Hour, Run: func(context.Context) error { return nil }}); err == nil {
+		t.Error("Register() accepted a duplicate name")
+	}
+
+	s.tick()
+	s.wg.Wait()
+	s.tick()
+	s.wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("job ran %d times, want once per interval", calls.Load())
+	}
+	run := latestRun(t, database, "count")
+	if run.Status != db.JobRunSucceeded || run.Trigger != db.JobTriggerSchedule || run.Replica != "replica-a" || run.Attempts != 1 {
+		t.Errorf("run = %+v", run)
+	}
+
+	statuses, err := s.Status()
+	if err != nil || len(statuses) != 1 {
+		t.Fatalf("Status() = %v, %v", statuses, err)
+	}
+	if st := statuses[0]; st.LastRun == nil || st.NextRun == nil || !st.NextRun.Equal(run.CreatedAt.Add(time.Hour)) {
+		t.Errorf("status = %+v", st)
+	}
+	if m := s.Metrics()["count"]; m.Runs != 1 || m.Failures != 0 || m.LastSuccess == 0 {
+		t.Errorf("metrics = %+v", m)
+	}
+}
+
+func TestScheduler_Retries(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+	var calls atomic.Int32
+	s := NewScheduler(database, "replica-a")
+	s.Register(Job{Name: "flaky", Interval: time.Hour, Retries: 2, RetryDelay: time.Millisecond, Run: func(context.Context) error {
+		if calls.Add(1) < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	}})
+	s.Register(Job{Name: "broken", Interval: time.Hour, Run: func(context.Context) error {
+		panic("boom")
+	}})
+
+	s.tick()
+	s.wg.Wait()
+
+	if run := latestRun(t, database, "flaky"); run.Status != db.JobRunSucceeded || run.Attempts != 3 {
+		t.Errorf("flaky run = %+v, want success on the third attempt", run)
+	}
+	run := latestRun(t, database, "broken")
+	if run.Status != db.JobRunFailed || run.Attempts != 1 || !strings.Contains(run.Error, "boom") {
+		t.Errorf("broken run = %+v", run)
+	}
+	if m := s.Metrics()["broken"]; m.Failures != 1 {
+		t.Errorf("broken metrics = %+v", m)
+	}
+}
+
+func TestScheduler_Trigger(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+	var calls atomic.Int32
+	job := Job{Name: "sync", Interval: time.Hour, Run: func(context.Context) error {
+		calls.Add(1)
+		return nil
+	}}
+
+	follower := NewScheduler(database, "replica-b")
+	follower.SetLeader(fixedLeader(false))
+	follower.Register(job)
+	leader := NewScheduler(database, "replica-a")
+	leader.SetLeader(fixedLeader(true))
+	leader.Register(job)
+
+	if _, err := follower.Trigger("missing", "admin"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("Trigger(missing) error = %v, want ErrUnknownJob", err)
+	}
+
+	// A follower queues the run for the leader
+	run, err := follower.Trigger("sync", "admin")
+	if err != nil {
+		t.Fatalf("Trigger() error = %v", err)
+	}
+	if run.Status != db.JobRunQueued || run.RequestedBy != "admin" {
+		t.Errorf("triggered run = %+v", run)
+	}
+	if _, err := follower.Trigger("sync", "admin"); !errors.Is(err, ErrJobActive) {
+		t.Errorf("second Trigger() error = %v, want ErrJobActive", err)
+	}
+	follower.tick()
+	if calls.Load() != 0 {
+		t.Fatal("follower ran a job")
+	}
+
+	leader.tick()
+	leader.wg.Wait()
+	if calls.Load() != 1 {
+		t.Fatalf("job ran %d times, want once", calls.Load())
+	}
+	got := latestRun(t, database, "sync")
+	if got.ID != run.ID || got.Status != db.JobRunSucceeded || got.Trigger != db.JobTriggerManual || got.Replica != "replica-a" {
+		t.Errorf("run = %+v", got)
+	}
+
+	// The leader runs a triggered job straight away
+	run, err = leader.Trigger("sync", "admin")
+	if err != nil || run.Status != db.JobRunRunning {
+		t.Fatalf("leader Trigger() = %+v, %v", run, err)
+	}
+	leader.wg.Wait()
+	if calls.Load() != 2 {
+		t.Errorf("job ran %d times, want twice", calls.Load())
+	}
+}
@@ -0,0 +1,368 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// capacityTTL is how long the node and ResourceQuota listings used by
+// CheckPodCapacity are reused before they are listed again.
+const capacityTTL = 15 * time.Second
+
+// capacityCache holds the last node and ResourceQuota listings, and the
+// errors listing them, so a burst of launches lists each once.
+var capacityCache struct {
+	mu        sync.Mutex
+	fetchedAt time.Time
+	nodes     []corev1.Node
+	quotas    []corev1.ResourceQuota
+	err       error
+}
+
+// capacityCheckDisabled turns CheckPodCapacity off, for clusters that add
+// nodes on demand.
+var capacityCheckDisabled bool
+
+// ConfigureCapacityCheck sets whether CheckPodCapacity checks pods against
+// the cluster. It is on unless disabled.
+func ConfigureCapacityCheck(enabled bool) {
+	capacityCheckDisabled = !enabled
+}
+
+// CapacityShortfall explains why a pod cannot be scheduled.
+type CapacityShortfall struct {
+	Reason string
+	// Temporary is set when the pod would fit once other pods end
+	Temporary bool
+}
+
+// CheckPodCapacity reports whether the pod could be scheduled, from the
+// allocatable resources of the nodes it may run on and the ResourceQuotas
+// of the namespace. A pod that no node is large enough for, or that asks
+// for more than a quota allows, can never fit; one that fits a quota's
+// hard limit but not what is left of it may fit later. It returns nil if
+// the pod may fit, and an error if the nodes or quotas could not be
+// listed, in which case what could be listed is still checked.
+//
+// Only resource requests, node selectors, required node affinity and
+// taints are considered, so a pod that passes may still not schedule.
+// Quotas with scopes are skipped.
+func CheckPodCapacity(ctx context.Context, pod *corev1.Pod) (*CapacityShortfall, error) {
+	if capacityCheckDisabled {
+		return nil, nil
+	}
+	nodes, quotas, err := capacitySnapshot(ctx)
+
+	requests := podResources(pod, func(c *corev1.Container) corev1.ResourceList { return c.Resources.Requests })
+	if shortfall := checkNodes(pod, requests, nodes); shortfall != nil {
+		return shortfall, err
+	}
+	limits := podResources(pod, func(c *corev1.Container) corev1.ResourceList { return c.Resources.Limits })
+	return checkQuotas(requests, limits, quotas), err
+}
+
+// capacitySnapshot returns the cached node and ResourceQuota listings,
+// listing them again once they are older than capacityTTL.
+func capacitySnapshot(ctx context.Context) ([]corev1.Node, []corev1.ResourceQuota, error) {
+	c := &capacityCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.fetchedAt.IsZero() && time.Since(c.fetchedAt) < capacityTTL {
+		return c.nodes, c.quotas, c.err
+	}
+
+	client, err := GetClient()
+	if err != nil {
+		return nil, nil, err
+	}
+	c.nodes, c.quotas = nil, nil
+	var errs []error
+	if nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{}); err != nil {
+		errs = append(errs, fmt.Errorf("failed to list nodes: %w", err))
+	} else {
+		c.nodes = nodes.Items
+	}
+	if quotas, err := client.CoreV1().ResourceQuotas(GetNamespace()).List(ctx, metav1.ListOptions{}); err != nil {
+		errs = append(errs, fmt.Errorf("failed to list resource quotas: %w", err))
+	} else {
+		c.quotas = quotas.Items
+	}
+	c.err = errors.Join(errs...)
+	c.fetchedAt = time.Now()
+	return c.nodes, c.quotas, c.err
+}
+
+// resetCapacityCache drops the cached listings.
+func resetCapacityCache() {
+	capacityCache.mu.Lock()
+	defer capacityCache.mu.Unlock()
+	capacityCache.fetchedAt = time.Time{}
+	capacityCache.nodes = nil
+	capacityCache.quotas = nil
+	capacityCache.err = nil
+}
+
+// podResources totals one kind of container resources for a pod the way
+// the scheduler does: app containers and sidecars (restartable init
+// containers) run together, other init containers run one at a time
+// before them, alongside the sidecars started ahead of them.
+func podResources(pod *corev1.Pod, get func(*corev1.Container) corev1.ResourceList) corev1.ResourceList {
+	total := corev1.ResourceList{}
+	sidecars := corev1.ResourceList{}
+	initPeak := corev1.ResourceList{}
+	for i := range pod.Spec.InitContainers {
+		c := &pod.Spec.InitContainers[i]
+		if c.RestartPolicy != nil && *c.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			addResources(sidecars, get(c))
+			continue
+		}
+		step := sidecars.DeepCopy()
+		addResources(step, get(c))
+		maxResources(initPeak, step)
+	}
+	for i := range pod.Spec.Containers {
+		addResources(total, get(&pod.Spec.Containers[i]))
+	}
+	addResources(total, sidecars)
+	maxResources(total, initPeak)
+	addResources(total, pod.Spec.Overhead)
+	return total
+}
+
+func addResources(dst, src corev1.ResourceList) {
+	for name, q := range src {
+		sum := dst[name]
+		sum.Add(q)
+		dst[name] = sum
+	}
+}
+
+func maxResources(dst, src corev1.ResourceList) {
+	for name, q := range src {
+		if cur, ok := dst[name]; !ok || q.Cmp(cur) > 0 {
+			dst[name] = q.DeepCopy()
+		}
+	}
+}
+
+// checkNodes reports a shortfall if no node the pod may run on has the
+// allocatable resources it requests. Nodes that are cordoned or not ready
+// only make it wait. Without nodes to check, as when they cannot be
+// listed, it reports none.
+func checkNodes(pod *corev1.Pod, requests corev1.ResourceList, nodes []corev1.Node) *CapacityShortfall {
+	if len(nodes) == 0 {
+		return nil
+	}
+	largest := corev1.ResourceList{}
+	matched, fitsUnavailable := 0, false
+	for i := range nodes {
+		node := &nodes[i]
+		if !nodeMatches(node, pod) {
+			continue
+		}
+		matched++
+		if fitsNode(requests, node.Status.Allocatable) {
+			if nodeAvailable(node) {
+				return nil
+			}
+			fitsUnavailable = true
+		}
+		for name := range requests {
+			if q, ok := node.Status.Allocatable[name]; ok {
+				maxResources(largest, corev1.ResourceList{name: q})
+			}
+		}
+	}
+	if matched == 0 {
+		return &CapacityShortfall{Reason: "no node matches the session's node selector, node affinity and tolerations"}
+	}
+	if fitsUnavailable {
+		return &CapacityShortfall{
+			Reason:    "the nodes large enough for the session are cordoned or not ready",
+			Temporary: true,
+		}
+	}
+	most := corev1.ResourceList{}
+	for name := range requests {
+		most[name] = largest[name]
+	}
+	return &CapacityShortfall{Reason: fmt.Sprintf("no node can fit the session's requests (%s); the most any node has allocatable is %s",
+		formatResources(requests), formatResources(most))}
+}
+
+// conditionTaints are the taints Kubernetes adds to nodes while they are
+// cordoned or unhealthy. They make a node unavailable for now, rather than
+// unsuitable for a pod.
+var conditionTaints = map[string]bool{
+	corev1.TaintNodeNotReady:           true,
+	corev1.TaintNodeUnreachable:        true,
+	corev1.TaintNodeUnschedulable:      true,
+	corev1.TaintNodeMemoryPressure:     true,
+	corev1.TaintNodeDiskPressure:       true,
+	corev1.TaintNodePIDPressure:        true,
+	corev1.TaintNodeNetworkUnavailable: true,
+}
+
+// nodeAvailable reports whether the node is ready, not cordoned and
+// without condition taints that keep new pods off it.
+func nodeAvailable(node *corev1.Node) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	for _, taint := range node.Spec.Taints {
+		if conditionTaints[taint.Key] && taint.Effect != corev1.TaintEffectPreferNoSchedule {
+			return false
+		}
+	}
+	for _, c := range node.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// nodeMatches reports whether the pod may be placed on the node: the node
+// has the labels of the pod's node selector and required node affinity,
+// and no taints the pod does not tolerate.
+func nodeMatches(node *corev1.Node, pod *corev1.Pod) bool {
+	for key, value := range pod.Spec.NodeSelector {
+		if node.Labels[key] != value {
+			return false
+		}
+	}
+	if !matchesRequiredAffinity(node, pod) {
+		return false
+	}
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule || conditionTaints[taint.Key] {
+			continue
+		}
+		tolerated := false
+		for j := range pod.Spec.Tolerations {
+			if tolerates(&pod.Spec.Tolerations[j], taint) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return false
+		}
+	}
+	return true
+}
+
+// tolerates reports whether the toleration matches the taint, for the
+// Equal and Exists operators.
+func tolerates(t *corev1.Toleration, taint *corev1.Taint) bool {
+	if t.Effect != "" && t.Effect != taint.Effect {
+		return false
+	}
+	if t.Key != "" && t.Key != taint.Key {
+		return false
+	}
+	switch t.Operator {
+	case corev1.TolerationOpExists:
+		return true
+	case corev1.TolerationOpEqual, "":
+		return t.Key != "" && t.Value == taint.Value
+	}
+	return false
+}
+
+func fitsNode(requests, allocatable corev1.ResourceList) bool {
+	for name, q := range requests {
+		if q.IsZero() {
+			continue
+		}
+		if avail, ok := allocatable[name]; !ok || q.Cmp(avail) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// checkQuotas reports a shortfall if the pod exceeds a quota's hard limit,
+// or what is left of it. A limit the pod can never fit under is reported
+// ahead of one it may fit under later.
+func checkQuotas(requests, limits corev1.ResourceList, quotas []corev1.ResourceQuota) *CapacityShortfall {
+	sort.Slice(quotas, func(i, j int) bool { return quotas[i].Name < quotas[j].Name })
+	var later *CapacityShortfall
+	for i := range quotas {
+		quota := &quotas[i]
+		if len(quota.Spec.Scopes) > 0 || quota.Spec.ScopeSelector != nil {
+			continue
+		}
+		names := make([]string, 0, len(quota.Status.Hard))
+		for name := range quota.Status.Hard {
+			names = append(names, string(name))
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			want, ok := quotaDemand(corev1.ResourceName(name), requests, limits)
+			if !ok || want.IsZero() {
+				continue
+			}
+			hard := quota.Status.Hard[corev1.ResourceName(name)]
+			if want.Cmp(hard) > 0 {
+				return &CapacityShortfall{Reason: fmt.Sprintf("ResourceQuota %s allows %s=%s and the session needs %s",
+					quota.Name, name, hard.String(), want.String())}
+			}
+			used := quota.Status.Used[corev1.ResourceName(name)]
+			total := used.DeepCopy()
+			total.Add(want)
+			if total.Cmp(hard) > 0 && later == nil {
+				left := hard.DeepCopy()
+				left.Sub(used)
+				later = &CapacityShortfall{
+					Reason: fmt.Sprintf("ResourceQuota %s has %s=%s of %s left and the session needs %s",
+						quota.Name, name, left.String(), hard.String(), want.String()),
+					Temporary: true,
+				}
+			}
+		}
+	}
+	return later
+}
+
+// quotaDemand returns how much of a quota resource a pod uses. It reports
+// false for resources that are not about pods, such as services.
+func quotaDemand(name corev1.ResourceName, requests, limits corev1.ResourceList) (resource.Quantity, bool) {
+	switch {
+	case name == corev1.ResourcePods:
+		return resource.MustParse("1"), true
+	case strings.HasPrefix(string(name), "requests."):
+		return requests[corev1.ResourceName(strings.TrimPrefix(string(name), "requests."))], true
+	case strings.HasPrefix(string(name), "limits."):
+		return limits[corev1.ResourceName(strings.TrimPrefix(string(name), "limits."))], true
+	case name == corev1.ResourceCPU || name == corev1.ResourceMemory || name == corev1.ResourceEphemeralStorage:
+		return requests[name], true
+	}
+	return resource.Quantity{}, false
+}
+
+// formatResources formats a resource list as "cpu=2, memory=4Gi", sorted
+// by name.
+func formatResources(list corev1.ResourceList) string {
+	names := make([]string, 0, len(list))
+	for name := range list {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		q := list[corev1.ResourceName(name)]
+		parts = append(parts, name+"="+q.String())
+	}
+	return strings.Join(parts, ", ")
+}
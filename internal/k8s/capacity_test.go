@@ -0,0 +1,201 @@
+package k8s
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func resources(pairs ...string) corev1.ResourceList {
+	list := corev1.ResourceList{}
+	for i := 0; i < len(pairs); i += 2 {
+		list[corev1.ResourceName(pairs[i])] = resource.MustParse(pairs[i+1])
+	}
+	return list
+}
+
+func testNode(name string, allocatable corev1.ResourceList) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"kubernetes.io/os": "linux"}},
+		Status: corev1.NodeStatus{
+			Allocatable: allocatable,
+			Conditions:  []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+		},
+	}
+}
+
+func TestPodResources(t *testing.T) {
+	always := corev1.ContainerRestartPolicyAlways
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		InitContainers: []corev1.Container{
+			{Name: "setup", Resources: corev1.ResourceRequirements{Requests: resources("cpu", "3", "memory", "1Gi")}},
+			{Name: "proxy", RestartPolicy: &always, Resources: corev1.ResourceRequirements{Requests: resources("cpu", "500m")}},
+		},
+		Containers: []corev1.Container{
+			{Name: "app", Resources: corev1.ResourceRequirements{Requests: resources("cpu", "1", "memory", "2Gi")}},
+			{Name: "vnc", Resources: corev1.ResourceRequirements{Requests: resources("cpu", "250m", "memory", "256Mi")}},
+		},
+		Overhead: resources("memory", "64Mi"),
+	}}
+
+	got := podResources(pod, func(c *corev1.Container) corev1.ResourceList { return c.Resources.Requests })
+	// The setup init container needs more CPU than the containers and the
+	// sidecar together; memory is the containers' and the overhead
+	if want := "cpu=3, memory=2368Mi"; formatResources(got) != want {
+		t.Errorf("podResources() = %s, want %s", formatResources(got), want)
+	}
+}
+
+func TestCheckNodes(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{NodeSelector: map[string]string{"kubernetes.io/os": "linux"}}}
+	requests := resources("cpu", "4", "memory", "8Gi")
+	small := testNode("small", resources("cpu", "2", "memory", "16Gi"))
+	large := testNode("large", resources("cpu", "8", "memory", "32Gi"))
+
+	cordoned := large
+	cordoned.Spec.Unschedulable = true
+	notReady := large
+	notReady.Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionFalse}}
+	notReady.Spec.Taints = []corev1.Taint{{Key: corev1.TaintNodeNotReady, Effect: corev1.TaintEffectNoSchedule}}
+	tainted := large
+	tainted.Spec.Taints = []corev1.Taint{{Key: "gpu", Value: "true", Effect: corev1.TaintEffectNoSchedule}}
+	windows := large
+	windows.Labels = map[string]string{"kubernetes.io/os": "windows"}
+
+	tests := []struct {
+		name      string
+		nodes     []corev1.Node
+		want      string // substring of the reason, or "" for no shortfall
+		temporary bool
+	}{
+		{"no nodes listed", nil, "", false},
+		{"a node fits", []corev1.Node{small, large}, "", false},
+		{"too large", []corev1.Node{small}, "the most any node has allocatable is cpu=2, memory=16Gi", false},
+		{"cordoned", []corev1.Node{small, cordoned}, "cordoned or not ready", true},
+		{"not ready", []corev1.Node{notReady}, "cordoned or not ready", true},
+		{"untolerated taint", []corev1.Node{small, tainted}, "no node can fit", false},
+		{"no matching node", []corev1.Node{windows}, "node selector", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := checkNodes(pod, requests, tt.nodes)
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("checkNodes() = %+v, want none", got)
+				}
+				return
+			}
+			if got == nil || !strings.Contains(got.Reason, tt.want) || got.Temporary != tt.temporary {
+				t.Errorf("checkNodes() = %+v, want %q (temporary %v)", got, tt.want, tt.temporary)
+			}
+		})
+	}
+
+	// A tolerated taint leaves the node usable
+	pod.Spec.Tolerations = []corev1.Toleration{{Key: "gpu", Operator: corev1.TolerationOpEqual, Value: "true", Effect: corev1.TaintEffectNoSchedule}}
+	if got := checkNodes(pod, requests, []corev1.Node{small, tainted}); got != nil {
+		t.Errorf("checkNodes() with a toleration = %+v, want none", got)
+	}
+
+	// Required node affinity rules out nodes without the labels
+	pod.Spec.Affinity = &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{
+			{MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "pool", Operator: corev1.NodeSelectorOpIn, Values: []string{"cad"}}}},
+		}},
+	}}
+	if got := checkNodes(pod, requests, []corev1.Node{large}); got == nil || !strings.Contains(got.Reason, "node selector") {
+		t.Errorf("checkNodes() with unmatched affinity = %+v, want no matching node", got)
+	}
+	pooled := large
+	pooled.Labels = map[string]string{"kubernetes.io/os": "linux", "pool": "cad"}
+	if got := checkNodes(pod, requests, []corev1.Node{large, pooled}); got != nil {
+		t.Errorf("checkNodes() with matched affinity = %+v, want none", got)
+	}
+}
+
+func TestCheckQuotas(t *testing.T) {
+	requests := resources("cpu", "2", "memory", "4Gi")
+	limits := resources("cpu", "4", "memory", "4Gi")
+	quota := func(name string, hard, used corev1.ResourceList) corev1.ResourceQuota {
+		return corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     corev1.ResourceQuotaStatus{Hard: hard, Used: used},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		quotas    []corev1.ResourceQuota
+		want      string
+		temporary bool
+	}{
+		{"room", []corev1.ResourceQuota{quota("q", resources("requests.cpu", "10", "pods", "5"), resources("requests.cpu", "2", "pods", "1"))}, "", false},
+		{"hard limit too low", []corev1.ResourceQuota{quota("q", resources("limits.cpu", "2"), nil)}, "ResourceQuota q allows limits.cpu=2 and the session needs 4", false},
+		{"used up", []corev1.ResourceQuota{quota("q", resources("pods", "3"), resources("pods", "3"))}, "ResourceQuota q has pods=0 of 3 left", true},
+		{"never fitting reported first", []corev1.ResourceQuota{
+			quota("a", resources("pods", "3"), resources("pods", "3")),
+			quota("b", resources("memory", "1Gi"), nil),
+		}, "ResourceQuota b allows memory=1Gi", false},
+		{"unrelated resources", []corev1.ResourceQuota{quota("q", resources("services", "0", "count/configmaps", "0"), nil)}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := checkQuotas(requests, limits, tt.quotas)
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("checkQuotas() = %+v, want none", got)
+				}
+				return
+			}
+			if got == nil || !strings.Contains(got.Reason, tt.want) || got.Temporary != tt.temporary {
+				t.Errorf("checkQuotas() = %+v, want %q (temporary %v)", got, tt.want, tt.temporary)
+			}
+		})
+	}
+
+	// Scoped quotas only apply to some pods, so they are skipped
+	scoped := quota("scoped", resources("pods", "0"), nil)
+	scoped.Spec.Scopes = []corev1.ResourceQuotaScope{corev1.ResourceQuotaScopeBestEffort}
+	if got := checkQuotas(requests, limits, []corev1.ResourceQuota{scoped}); got != nil {
+		t.Errorf("checkQuotas() with a scoped quota = %+v, want none", got)
+	}
+}
+
+func TestCheckPodCapacity(t *testing.T) {
+	defer ResetClient()
+	fakeClient := setFakeClient(t)
+	ctx := context.Background()
+
+	node := testNode("node-1", resources("cpu", "1", "memory", "2Gi"))
+	if _, err := fakeClient.CoreV1().Nodes().Create(ctx, &node, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	podConfig := DefaultPodConfig("sess-capacity", "app-1", "App", "myapp:v1")
+	podConfig.CPURequest = "2"
+	podConfig.CPULimit = "4"
+	pod := BuildPodSpec(podConfig)
+	shortfall, err := CheckPodCapacity(ctx, pod)
+	if err != nil {
+		t.Fatalf("CheckPodCapacity() error = %v", err)
+	}
+	if shortfall == nil || shortfall.Temporary || !strings.Contains(shortfall.Reason, "no node can fit") {
+		t.Errorf("CheckPodCapacity() = %+v, want no node large enough", shortfall)
+	}
+
+	// Listings are reused, so a burst of launches lists nodes once
+	fakeClient.ClearActions()
+	CheckPodCapacity(ctx, pod)
+	if n := len(fakeClient.Actions()); n != 0 {
+		t.Errorf("second check made %d API requests, want 0", n)
+	}
+
+	ConfigureCapacityCheck(false)
+	if shortfall, err := CheckPodCapacity(ctx, pod); shortfall != nil || err != nil {
+		t.Errorf("CheckPodCapacity() while disabled = %+v, %v; want nothing", shortfall, err)
+	}
+}
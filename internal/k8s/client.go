@@ -1,11 +1,14 @@
 package k8s
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
 	"sync"
 
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -24,6 +27,8 @@ var (
 	configuredVNCSidecarImage     string
 	configuredBrowserSidecarImage string
 	configuredGuacdSidecarImage   string
+	configuredQPS                 float32
+	configuredBurst               int
 )
 
 // Configure sets the Kubernetes configuration from the application config.
@@ -39,6 +44,14 @@ func ConfigureBrowserSidecar(browserSidecarImage string) {
 	configuredBrowserSidecarImage = browserSidecarImage
 }
 
+// ConfigureRateLimit sets the client-side rate limit for API requests: qps
+// requests per second on average, and up to burst at once. Zero values keep
+// client-go's defaults. It must be called before the client is first used.
+func ConfigureRateLimit(qps float32, burst int) {
+	configuredQPS = qps
+	configuredBurst = burst
+}
+
 // GetVNCSidecarImage returns the configured VNC sidecar image.
 func GetVNCSidecarImage() string {
 	if configuredVNCSidecarImage != "" {
@@ -114,9 +127,22 @@ func GetClient() (kubernetes.Interface, error) {
 			}
 		}
 
+		if configuredQPS > 0 {
+			cfg.QPS = configuredQPS
+		}
+		if configuredBurst > 0 {
+			cfg.Burst = configuredBurst
+		}
+		registerMetrics()
 		restConfig = cfg
 
-		client, clientErr = kubernetes.NewForConfig(cfg)
+		// Trace API calls. The clientset gets its own copy of the config so
+		// exec and port-forward upgrades made with restConfig are untouched.
+		clientCfg := rest.CopyConfig(cfg)
+		clientCfg.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			return otelhttp.NewTransport(rt)
+		})
+		client, clientErr = kubernetes.NewForConfig(clientCfg)
 		if clientErr != nil {
 			clientErr = fmt.Errorf("failed to create kubernetes client: %w", clientErr)
 			return
@@ -126,6 +152,24 @@ func GetClient() (kubernetes.Interface, error) {
 	return client, clientErr
 }
 
+// CheckConnection checks the API server can be reached with the
+// configured credentials.
+func CheckConnection(ctx context.Context) error {
+	c, err := GetClient()
+	if err != nil {
+		return err
+	}
+	if rc := c.Discovery().RESTClient(); rc != nil {
+		err = rc.Get().AbsPath("/version").Do(ctx).Error()
+	} else {
+		_, err = c.Discovery().ServerVersion()
+	}
+	if err != nil {
+		return fmt.Errorf("kubernetes API server is not reachable: %w", err)
+	}
+	return nil
+}
+
 // GetRESTConfig returns the Kubernetes REST config, initializing the client if necessary.
 func GetRESTConfig() (*rest.Config, error) {
 	if _, err := GetClient(); err != nil {
@@ -169,4 +213,10 @@ func ResetClient() {
 	configuredVNCSidecarImage = ""
 	configuredBrowserSidecarImage = ""
 	configuredGuacdSidecarImage = ""
+	configuredQPS = 0
+	configuredBurst = 0
+	capacityCheckDisabled = false
+	registryMirrors = nil
+	stopPodCache()
+	resetCapacityCache()
 }
@@ -1,7 +1,10 @@
 package k8s
 
 import (
+	"context"
+	"errors"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -39,6 +42,38 @@ func TestConfigureGuacdSidecar(t *testing.T) {
 	}
 }
 
+func TestConfigureRateLimit(t *testing.T) {
+	defer ResetClient()
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	err := os.WriteFile(kubeconfig, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: https://127.0.0.1:6443
+contexts:
+- name: test
+  context:
+    cluster: test
+current-context: test
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Configure("test-ns", kubeconfig, "")
+	ConfigureRateLimit(25, 50)
+	cfg, err := GetRESTConfig()
+	if err != nil {
+		t.Fatalf("GetRESTConfig() error = %v", err)
+	}
+	if cfg.QPS != 25 || cfg.Burst != 50 {
+		t.Errorf("rate limit = %v/%d, want 25/50", cfg.QPS, cfg.Burst)
+	}
+}
+
 func TestGetVNCSidecarImage_Default(t *testing.T) {
 	defer ResetClient()
 
@@ -163,6 +198,7 @@ func TestResetClient(t *testing.T) {
 	Configure("ns", "/kube", "vnc:img")
 	ConfigureBrowserSidecar("browser:img")
 	ConfigureGuacdSidecar("guacd:img")
+	ConfigureRateLimit(10, 20)
 	// Cache namespace
 	GetNamespace()
 
@@ -183,6 +219,9 @@ func TestResetClient(t *testing.T) {
 	if configuredGuacdSidecarImage != "" {
 		t.Errorf("configuredGuacdSidecarImage not reset, got %q", configuredGuacdSidecarImage)
 	}
+	if configuredQPS != 0 || configuredBurst != 0 {
+		t.Errorf("rate limit not reset, got %v/%d", configuredQPS, configuredBurst)
+	}
 	if client != nil {
 		t.Error("client not reset")
 	}
@@ -190,3 +229,18 @@ func TestResetClient(t *testing.T) {
 		t.Error("clientErr not reset")
 	}
 }
+
+func TestCheckConnection(t *testing.T) {
+	defer ResetClient()
+	setFakeClient(t)
+	if err := CheckConnection(context.Background()); err != nil {
+		t.Errorf("CheckConnection() error = %v", err)
+	}
+
+	ResetClient()
+	clientErr = errors.New("no credentials")
+	clientOnce.Do(func() {})
+	if err := CheckConnection(context.Background()); err == nil {
+		t.Error("CheckConnection() expected an error without a client")
+	}
+}
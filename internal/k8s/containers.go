@@ -0,0 +1,253 @@
+package k8s
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/rjsadow/sortie/internal/db"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// AppContainerPrefix is prepended to the names of an app's extra
+	// containers to form their container names.
+	AppContainerPrefix = "app-"
+
+	// InitContainerPrefix is prepended to the names of an app's init
+	// containers to form their container names.
+	InitContainerPrefix = "init-"
+
+	// SharedVolumePrefix is prepended to shared volume names to form their
+	// pod volume names, keeping them apart from the built-in volumes.
+	SharedVolumePrefix = "shared-"
+)
+
+// MaxAppContainerNameLength, MaxInitContainerNameLength and
+// MaxSharedVolumeNameLength keep prefixed names within the 63 character
+// limit Kubernetes places on them.
+const (
+	MaxAppContainerNameLength  = 63 - len(AppContainerPrefix)
+	MaxInitContainerNameLength = 63 - len(InitContainerPrefix)
+	MaxSharedVolumeNameLength  = 63 - len(SharedVolumePrefix)
+)
+
+// ValidateAppContainers checks that an app's extra containers and the
+// shared volumes its app container mounts can be rendered into a pod.
+// Container names and ports must be unique, since the containers share the
+// pod's network.
+func ValidateAppContainers(containers []db.AppContainer, shared []db.SharedVolumeMount) error {
+	if err := validateSharedVolumeMounts(shared); err != nil {
+		return fmt.Errorf("shared_volumes: %w", err)
+	}
+	names := map[string]bool{}
+	ports := map[int]string{}
+	for _, c := range containers {
+		if len(c.Name) > MaxAppContainerNameLength || !sidecarNamePattern.MatchString(c.Name) {
+			return fmt.Errorf("invalid container name %q: must be a lowercase DNS label of at most %d characters", c.Name, MaxAppContainerNameLength)
+		}
+		if names[c.Name] {
+			return fmt.Errorf("duplicate container %q", c.Name)
+		}
+		names[c.Name] = true
+		if err := validateContainer(c.Name, c.Image, c.Env, c.Resources, c.VolumeMounts); err != nil {
+			return err
+		}
+		for _, port := range c.Ports {
+			if port < 1 || port > 65535 {
+				return fmt.Errorf("container %s: invalid port %d", c.Name, port)
+			}
+			if reservedSidecarPorts[port] {
+				return fmt.Errorf("container %s: port %d is reserved for session containers", c.Name, port)
+			}
+			if other, ok := ports[port]; ok {
+				return fmt.Errorf("containers %s and %s both use port %d", other, c.Name, port)
+			}
+			ports[port] = c.Name
+		}
+	}
+	return nil
+}
+
+// ValidateInitContainers checks that an app's init containers can be
+// rendered into a pod.
+func ValidateInitContainers(inits []db.InitContainer) error {
+	names := map[string]bool{}
+	for _, c := range inits {
+		if len(c.Name) > MaxInitContainerNameLength || !sidecarNamePattern.MatchString(c.Name) {
+			return fmt.Errorf("invalid init container name %q: must be a lowercase DNS label of at most %d characters", c.Name, MaxInitContainerNameLength)
+		}
+		if names[c.Name] {
+			return fmt.Errorf("duplicate init container %q", c.Name)
+		}
+		names[c.Name] = true
+		if err := validateContainer(c.Name, c.Image, c.Env, c.Resources, c.VolumeMounts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateContainer checks the fields app containers and init containers
+// have in common.
+func validateContainer(name, image string, env map[string]string, resources *db.ResourceLimits, mounts []db.SharedVolumeMount) error {
+	if image == "" || strings.ContainsAny(image, " \t\r\n") {
+		return fmt.Errorf("container %s: invalid image %q", name, image)
+	}
+	for key := range env {
+		if !envVarNamePattern.MatchString(key) {
+			return fmt.Errorf("container %s: invalid environment variable name %q", name, key)
+		}
+	}
+	if r := resources; r != nil {
+		for field, value := range map[string]string{
+			"cpu_request":    r.CPURequest,
+			"cpu_limit":      r.CPULimit,
+			"memory_request": r.MemoryRequest,
+			"memory_limit":   r.MemoryLimit,
+		} {
+			if value == "" {
+				continue
+			}
+			if _, err := resource.ParseQuantity(value); err != nil {
+				return fmt.Errorf("container %s: invalid %s %q", name, field, value)
+			}
+		}
+	}
+	if err := validateSharedVolumeMounts(mounts); err != nil {
+		return fmt.Errorf("container %s: %w", name, err)
+	}
+	return nil
+}
+
+// validateSharedVolumeMounts checks one container's shared volume mounts.
+func validateSharedVolumeMounts(mounts []db.SharedVolumeMount) error {
+	paths := map[string]bool{}
+	for _, m := range mounts {
+		if len(m.Name) > MaxSharedVolumeNameLength || !sidecarNamePattern.MatchString(m.Name) {
+			return fmt.Errorf("invalid volume name %q: must be a lowercase DNS label of at most %d characters", m.Name, MaxSharedVolumeNameLength)
+		}
+		if !path.IsAbs(m.MountPath) || path.Clean(m.MountPath) != m.MountPath || m.MountPath == "/" {
+			return fmt.Errorf("volume %s: invalid mount_path %q: must be a clean absolute path other than /", m.Name, m.MountPath)
+		}
+		for _, reserved := range []string{WorkspaceMountPath, "/tmp/.X11-unix"} {
+			if m.MountPath == reserved {
+				return fmt.Errorf("volume %s: mount_path %s is used by Sortie", m.Name, reserved)
+			}
+		}
+		if paths[m.MountPath] {
+			return fmt.Errorf("volume %s: mount_path %s is mounted twice", m.Name, m.MountPath)
+		}
+		paths[m.MountPath] = true
+	}
+	return nil
+}
+
+// applyAppContainers adds an app's extra containers to the pod, creates a
+// scratch volume for each shared volume name they or the app container
+// mount, and mounts the app container's shared volumes. Each container
+// with ports is ready once its first port accepts connections, so the pod
+// is not ready until all of them are. Containers are expected to have
+// passed ValidateAppContainers.
+func applyAppContainers(spec *corev1.PodSpec, containers []db.AppContainer, shared []db.SharedVolumeMount) {
+	if len(containers) == 0 && len(shared) == 0 {
+		return
+	}
+
+	appMounts := sharedVolumeMounts(spec, shared)
+	for i := range spec.Containers {
+		if spec.Containers[i].Name == "app" {
+			spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts, appMounts...)
+		}
+	}
+
+	for _, ac := range containers {
+		c := corev1.Container{
+			Name:            AppContainerPrefix + ac.Name,
+			Image:           ac.Image,
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Command:         ac.Command,
+			Args:            ac.Args,
+			VolumeMounts:    sharedVolumeMounts(spec, ac.VolumeMounts),
+		}
+		for key, value := range ac.Env {
+			c.Env = append(c.Env, corev1.EnvVar{Name: key, Value: value})
+		}
+		for _, port := range ac.Ports {
+			c.Ports = append(c.Ports, corev1.ContainerPort{ContainerPort: int32(port), Protocol: corev1.ProtocolTCP})
+		}
+		if len(ac.Ports) > 0 {
+			c.ReadinessProbe = &corev1.Probe{
+				ProbeHandler: corev1.ProbeHandler{
+					TCPSocket: &corev1.TCPSocketAction{
+						Port: intstr.FromInt(ac.Ports[0]),
+					},
+				},
+				InitialDelaySeconds: 2,
+				PeriodSeconds:       5,
+				TimeoutSeconds:      2,
+				SuccessThreshold:    1,
+				FailureThreshold:    12,
+			}
+		}
+		if r := ac.Resources; r != nil {
+			c.Resources.Limits = quantities(r.CPULimit, r.MemoryLimit)
+			c.Resources.Requests = quantities(r.CPURequest, r.MemoryRequest)
+		}
+		spec.Containers = append(spec.Containers, c)
+	}
+}
+
+// applyInitContainers adds an app's init containers to the pod, after any
+// already there, creating the shared volumes they mount. Kubernetes runs
+// them in order and starts the pod's containers once all have exited
+// successfully. Containers are expected to have passed
+// ValidateInitContainers.
+func applyInitContainers(spec *corev1.PodSpec, inits []db.InitContainer) {
+	for _, ic := range inits {
+		c := corev1.Container{
+			Name:            InitContainerPrefix + ic.Name,
+			Image:           ic.Image,
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Command:         ic.Command,
+			Args:            ic.Args,
+			VolumeMounts:    sharedVolumeMounts(spec, ic.VolumeMounts),
+		}
+		for key, value := range ic.Env {
+			c.Env = append(c.Env, corev1.EnvVar{Name: key, Value: value})
+		}
+		if r := ic.Resources; r != nil {
+			c.Resources.Limits = quantities(r.CPULimit, r.MemoryLimit)
+			c.Resources.Requests = quantities(r.CPURequest, r.MemoryRequest)
+		}
+		spec.InitContainers = append(spec.InitContainers, c)
+	}
+}
+
+// sharedVolumeMounts returns the volume mounts for shared volume mounts,
+// adding a scratch volume to the pod for each name it does not have yet.
+func sharedVolumeMounts(spec *corev1.PodSpec, mounts []db.SharedVolumeMount) []corev1.VolumeMount {
+	var out []corev1.VolumeMount
+	for _, m := range mounts {
+		name := SharedVolumePrefix + m.Name
+		if !hasVolume(spec, name) {
+			spec.Volumes = append(spec.Volumes, corev1.Volume{
+				Name:         name,
+				VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+			})
+		}
+		out = append(out, corev1.VolumeMount{Name: name, MountPath: m.MountPath, ReadOnly: m.ReadOnly})
+	}
+	return out
+}
+
+func hasVolume(spec *corev1.PodSpec, name string) bool {
+	for _, v := range spec.Volumes {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,244 @@
+package k8s
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/db"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestValidateAppContainers(t *testing.T) {
+	valid := db.AppContainer{
+		Name:         "db",
+		Image:        "postgres:16",
+		Env:          map[string]string{"POSTGRES_PASSWORD": "dev"},
+		Ports:        []int{5432},
+		Resources:    &db.ResourceLimits{MemoryLimit: "1Gi"},
+		VolumeMounts: []db.SharedVolumeMount{{Name: "sockets", MountPath: "/var/run/postgresql"}},
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(c *db.AppContainer, shared *[]db.SharedVolumeMount)
+		wantErr string
+	}{
+		{"valid", func(c *db.AppContainer, shared *[]db.SharedVolumeMount) {}, ""},
+		{"uppercase name", func(c *db.AppContainer, shared *[]db.SharedVolumeMount) { c.Name = "DB" }, "invalid container name"},
+		{"long name", func(c *db.AppContainer, shared *[]db.SharedVolumeMount) {
+			c.Name = strings.Repeat("a", MaxAppContainerNameLength+1)
+		}, "invalid container name"},
+		{"missing image", func(c *db.AppContainer, shared *[]db.SharedVolumeMount) { c.Image = "" }, "invalid image"},
+		{"bad env name", func(c *db.AppContainer, shared *[]db.SharedVolumeMount) { c.Env = map[string]string{"1BAD": "x"} }, "environment variable"},
+		{"reserved port", func(c *db.AppContainer, shared *[]db.SharedVolumeMount) { c.Ports = []int{6080} }, "reserved"},
+		{"bad quantity", func(c *db.AppContainer, shared *[]db.SharedVolumeMount) {
+			c.Resources = &db.ResourceLimits{CPULimit: "fast"}
+		}, "cpu_limit"},
+		{"bad volume name", func(c *db.AppContainer, shared *[]db.SharedVolumeMount) {
+			c.VolumeMounts = []db.SharedVolumeMount{{Name: "My Data", MountPath: "/data"}}
+		}, "invalid volume name"},
+		{"relative mount path", func(c *db.AppContainer, shared *[]db.SharedVolumeMount) {
+			c.VolumeMounts = []db.SharedVolumeMount{{Name: "data", MountPath: "data"}}
+		}, "invalid mount_path"},
+		{"workspace mount path", func(c *db.AppContainer, shared *[]db.SharedVolumeMount) {
+			c.VolumeMounts = []db.SharedVolumeMount{{Name: "data", MountPath: WorkspaceMountPath}}
+		}, "used by Sortie"},
+		{"path mounted twice", func(c *db.AppContainer, shared *[]db.SharedVolumeMount) {
+			c.VolumeMounts = []db.SharedVolumeMount{{Name: "a", MountPath: "/data"}, {Name: "b", MountPath: "/data"}}
+		}, "mounted twice"},
+		{"bad app mount", func(c *db.AppContainer, shared *[]db.SharedVolumeMount) {
+			*shared = []db.SharedVolumeMount{{Name: "sockets", MountPath: "/"}}
+		}, "shared_volumes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid
+			shared := []db.SharedVolumeMount{{Name: "sockets", MountPath: "/run/db"}}
+			tt.modify(&c, &shared)
+			err := ValidateAppContainers([]db.AppContainer{c}, shared)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateAppContainers() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateAppContainers() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateAppContainers_Conflicts(t *testing.T) {
+	a := db.AppContainer{Name: "a", Image: "a:1", Ports: []int{9000}}
+	b := db.AppContainer{Name: "b", Image: "b:1", Ports: []int{9001}}
+
+	if err := ValidateAppContainers([]db.AppContainer{a, b}, nil); err != nil {
+		t.Errorf("ValidateAppContainers() error = %v", err)
+	}
+	if err := ValidateAppContainers([]db.AppContainer{a, a}, nil); err == nil {
+		t.Error("expected error for duplicate container names")
+	}
+	b.Ports = []int{9000}
+	if err := ValidateAppContainers([]db.AppContainer{a, b}, nil); err == nil {
+		t.Error("expected error for containers sharing a port")
+	}
+}
+
+func TestBuildPodSpecs_WithAppContainers(t *testing.T) {
+	containers := []db.AppContainer{
+		{
+			Name:         "db",
+			Image:        "postgres:16",
+			Env:          map[string]string{"POSTGRES_PASSWORD": "dev"},
+			Ports:        []int{5432},
+			Resources:    &db.ResourceLimits{MemoryLimit: "1Gi"},
+			VolumeMounts: []db.SharedVolumeMount{{Name: "sockets", MountPath: "/var/run/postgresql"}},
+		},
+		{Name: "worker", Image: "worker:1"},
+	}
+	shared := []db.SharedVolumeMount{{Name: "sockets", MountPath: "/run/db", ReadOnly: true}}
+
+	builders := map[string]func(*PodConfig) *corev1.Pod{
+		"standard":  BuildPodSpec,
+		"web proxy": BuildWebProxyPodSpec,
+		"windows":   BuildWindowsPodSpec,
+	}
+	for name, build := range builders {
+		t.Run(name, func(t *testing.T) {
+			config := DefaultPodConfig("sess-1", "app-1", "Test App", "ubuntu:latest")
+			base := build(config)
+
+			config.Containers = containers
+			config.SharedVolumes = shared
+			pod := build(config)
+			if len(pod.Spec.Containers) != len(base.Spec.Containers)+2 {
+				t.Fatalf("len(Containers) = %d, want %d", len(pod.Spec.Containers), len(base.Spec.Containers)+2)
+			}
+			if len(pod.Spec.Volumes) != len(base.Spec.Volumes)+1 {
+				t.Fatalf("len(Volumes) = %d, want one shared volume added", len(pod.Spec.Volumes))
+			}
+			if v := pod.Spec.Volumes[len(pod.Spec.Volumes)-1]; v.Name != "shared-sockets" || v.EmptyDir == nil {
+				t.Errorf("shared volume = %+v", v)
+			}
+
+			byName := map[string]corev1.Container{}
+			for _, c := range pod.Spec.Containers {
+				byName[c.Name] = c
+			}
+			app := byName["app"]
+			if m := app.VolumeMounts[len(app.VolumeMounts)-1]; m.Name != "shared-sockets" || m.MountPath != "/run/db" || !m.ReadOnly {
+				t.Errorf("app shared mount = %+v", m)
+			}
+
+			pg := byName["app-db"]
+			if pg.Image != "postgres:16" {
+				t.Errorf("app-db image = %q", pg.Image)
+			}
+			if len(pg.Env) != 1 || pg.Env[0].Name != "POSTGRES_PASSWORD" {
+				t.Errorf("Env = %v", pg.Env)
+			}
+			if len(pg.VolumeMounts) != 1 || pg.VolumeMounts[0].Name != "shared-sockets" || pg.VolumeMounts[0].MountPath != "/var/run/postgresql" {
+				t.Errorf("VolumeMounts = %v", pg.VolumeMounts)
+			}
+			if p := pg.ReadinessProbe; p == nil || p.TCPSocket == nil || p.TCPSocket.Port.IntValue() != 5432 {
+				t.Errorf("ReadinessProbe = %+v, want a TCP probe on 5432", p)
+			}
+			if pg.Resources.Limits.Memory().String() != "1Gi" || pg.Resources.Requests != nil {
+				t.Errorf("Resources = %+v", pg.Resources)
+			}
+
+			worker := byName["app-worker"]
+			if worker.Image != "worker:1" || worker.ReadinessProbe != nil {
+				t.Errorf("app-worker = %+v, want no readiness probe without ports", worker)
+			}
+		})
+	}
+}
+
+func TestValidateInitContainers(t *testing.T) {
+	valid := db.InitContainer{
+		Name:         "seed",
+		Image:        "busybox:1.36",
+		Command:      []string{"sh", "-c", "cp -r /seed/. /data"},
+		VolumeMounts: []db.SharedVolumeMount{{Name: "data", MountPath: "/data"}},
+	}
+	if err := ValidateInitContainers([]db.InitContainer{valid}); err != nil {
+		t.Errorf("ValidateInitContainers() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		inits   []db.InitContainer
+		wantErr string
+	}{
+		{"bad name", []db.InitContainer{{Name: "Seed", Image: "busybox"}}, "invalid init container name"},
+		{"long name", []db.InitContainer{{Name: strings.Repeat("a", MaxInitContainerNameLength+1), Image: "busybox"}}, "invalid init container name"},
+		{"duplicate", []db.InitContainer{valid, valid}, "duplicate init container"},
+		{"missing image", []db.InitContainer{{Name: "seed"}}, "invalid image"},
+		{"bad mount", []db.InitContainer{{Name: "seed", Image: "busybox", VolumeMounts: []db.SharedVolumeMount{{Name: "data", MountPath: "/"}}}}, "invalid mount_path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateInitContainers(tt.inits)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateInitContainers() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildPodSpecs_WithInitContainers(t *testing.T) {
+	inits := []db.InitContainer{
+		{
+			Name:         "seed",
+			Image:        "busybox:1.36",
+			Command:      []string{"sh", "-c", "cp -r /seed/. /data"},
+			Env:          map[string]string{"SEED": "demo"},
+			Resources:    &db.ResourceLimits{CPULimit: "500m"},
+			VolumeMounts: []db.SharedVolumeMount{{Name: "data", MountPath: "/data"}},
+		},
+		{Name: "license", Image: "license-setup:1"},
+	}
+	shared := []db.SharedVolumeMount{{Name: "data", MountPath: "/opt/data"}}
+
+	builders := map[string]func(*PodConfig) *corev1.Pod{
+		"standard":  BuildPodSpec,
+		"web proxy": BuildWebProxyPodSpec,
+		"windows":   BuildWindowsPodSpec,
+	}
+	for name, build := range builders {
+		t.Run(name, func(t *testing.T) {
+			config := DefaultPodConfig("sess-1", "app-1", "Test App", "ubuntu:latest")
+			base := build(config)
+
+			config.SharedVolumes = shared
+			config.InitContainers = inits
+			pod := build(config)
+			if len(pod.Spec.InitContainers) != len(base.Spec.InitContainers)+2 {
+				t.Fatalf("len(InitContainers) = %d, want %d", len(pod.Spec.InitContainers), len(base.Spec.InitContainers)+2)
+			}
+			// The app container and the init container share one volume
+			if len(pod.Spec.Volumes) != len(base.Spec.Volumes)+1 {
+				t.Fatalf("len(Volumes) = %d, want one shared volume added", len(pod.Spec.Volumes))
+			}
+
+			seed := pod.Spec.InitContainers[len(pod.Spec.InitContainers)-2]
+			if seed.Name != "init-seed" || seed.Image != "busybox:1.36" || len(seed.Command) != 3 {
+				t.Errorf("init-seed = %+v", seed)
+			}
+			if len(seed.Env) != 1 || seed.Env[0].Name != "SEED" {
+				t.Errorf("Env = %v", seed.Env)
+			}
+			if len(seed.VolumeMounts) != 1 || seed.VolumeMounts[0].Name != "shared-data" || seed.VolumeMounts[0].MountPath != "/data" {
+				t.Errorf("VolumeMounts = %v", seed.VolumeMounts)
+			}
+			if seed.Resources.Limits.Cpu().String() != "500m" {
+				t.Errorf("Resources = %+v", seed.Resources)
+			}
+			if license := pod.Spec.InitContainers[len(pod.Spec.InitContainers)-1]; license.Name != "init-license" || license.VolumeMounts != nil {
+				t.Errorf("init-license = %+v", license)
+			}
+		})
+	}
+}
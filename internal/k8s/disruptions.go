@@ -0,0 +1,169 @@
+package k8s
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+)
+
+// PodDisruption reports that a session pod is going away without Sortie
+// having asked, such as when a node is drained.
+type PodDisruption struct {
+	PodName string
+	// Reason says why, such as "EvictionByEvictionAPI: Eviction API:
+	// evicting". It is empty for a pod deleted without a recorded reason.
+	Reason string
+	// Deleted is set once the pod is gone. Sortie deletes the pods of
+	// sessions it ends too, so a deletion on its own is not proof of a
+	// disruption.
+	Deleted bool
+}
+
+// disruptionHandlers are called by the pod cache's watch when session pods
+// are disrupted.
+var disruptionHandlers struct {
+	mu   sync.RWMutex
+	next int
+	fns  map[int]func(PodDisruption)
+}
+
+// OnPodDisruption registers fn to be called from the pod cache's watch
+// when a session pod is evicted, preempted or shut down with its node, and
+// when a session pod is deleted. fn is called on the watch's goroutine, so
+// it must not block for long. It returns a function that unregisters fn.
+// Nothing is reported while the pod cache is not running.
+func OnPodDisruption(fn func(PodDisruption)) (remove func()) {
+	disruptionHandlers.mu.Lock()
+	defer disruptionHandlers.mu.Unlock()
+	if disruptionHandlers.fns == nil {
+		disruptionHandlers.fns = make(map[int]func(PodDisruption))
+	}
+	id := disruptionHandlers.next
+	disruptionHandlers.next++
+	disruptionHandlers.fns[id] = fn
+	return func() {
+		disruptionHandlers.mu.Lock()
+		defer disruptionHandlers.mu.Unlock()
+		delete(disruptionHandlers.fns, id)
+	}
+}
+
+// disruptionEventHandler reports pod disruptions to the registered
+// handlers.
+var disruptionEventHandler = cache.ResourceEventHandlerFuncs{
+	AddFunc: func(obj interface{}) {
+		if pod, ok := obj.(*corev1.Pod); ok {
+			if reason, disrupted := podDisruption(pod); disrupted {
+				notifyDisruption(PodDisruption{PodName: pod.Name, Reason: reason})
+			}
+		}
+	},
+	UpdateFunc: func(oldObj, newObj interface{}) {
+		old, ok := oldObj.(*corev1.Pod)
+		if !ok {
+			return
+		}
+		pod, ok := newObj.(*corev1.Pod)
+		if !ok {
+			return
+		}
+		if _, was := podDisruption(old); was {
+			return
+		}
+		if reason, disrupted := podDisruption(pod); disrupted {
+			notifyDisruption(PodDisruption{PodName: pod.Name, Reason: reason})
+		}
+	},
+	DeleteFunc: func(obj interface{}) {
+		// A pod deleted while the watch was disconnected arrives as a
+		// tombstone
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		if pod, ok := obj.(*corev1.Pod); ok {
+			reason, _ := podDisruption(pod)
+			notifyDisruption(PodDisruption{PodName: pod.Name, Reason: reason, Deleted: true})
+		}
+	},
+}
+
+func notifyDisruption(d PodDisruption) {
+	disruptionHandlers.mu.RLock()
+	defer disruptionHandlers.mu.RUnlock()
+	for _, fn := range disruptionHandlers.fns {
+		fn(d)
+	}
+}
+
+// disruptionReasons are the pod status reasons the kubelet and node
+// lifecycle controller set on pods they stop.
+var disruptionReasons = map[string]bool{
+	"Evicted":      true,
+	"Preempting":   true,
+	"Shutdown":     true,
+	"NodeShutdown": true,
+	"Terminated":   true,
+	"NodeLost":     true,
+}
+
+// podDisruption reports whether pod is being disrupted, from its
+// DisruptionTarget condition or, on clusters that do not set it, its
+// status reason, and describes why.
+func podDisruption(pod *corev1.Pod) (string, bool) {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.DisruptionTarget && c.Status == corev1.ConditionTrue {
+			if c.Message == "" {
+				return c.Reason, true
+			}
+			return c.Reason + ": " + c.Message, true
+		}
+	}
+	if disruptionReasons[pod.Status.Reason] {
+		if pod.Status.Message == "" {
+			return pod.Status.Reason, true
+		}
+		return pod.Status.Reason + ": " + pod.Status.Message, true
+	}
+	return "", false
+}
+
+// forceDeleteAfter is how long a terminating pod may outlive its grace
+// period before it is force deleted. Pods on a node that has gone away are
+// never confirmed deleted by its kubelet.
+const forceDeleteAfter = 30 * time.Second
+
+// WaitForPodDeleted waits until the named pod no longer exists, so a pod
+// with the same name can be created in its place. A pod still terminating
+// well after its grace period is force deleted.
+func WaitForPodDeleted(ctx context.Context, podName string) error {
+	client, err := GetClient()
+	if err != nil {
+		return err
+	}
+	pods := client.CoreV1().Pods(GetNamespace())
+
+	return wait.PollUntilContextCancel(ctx, time.Second, true, func(ctx context.Context) (bool, error) {
+		pod, err := pods.Get(ctx, podName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		// The deletion timestamp is when the grace period ends
+		if ts := pod.DeletionTimestamp; ts != nil && time.Since(ts.Time) > forceDeleteAfter {
+			zero := int64(0)
+			err := pods.Delete(ctx, podName, metav1.DeleteOptions{GracePeriodSeconds: &zero})
+			if err != nil && !apierrors.IsNotFound(err) {
+				return false, err
+			}
+		}
+		return false, nil
+	})
+}
@@ -0,0 +1,118 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodDisruption(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     corev1.PodStatus
+		wantReason string
+		want       bool
+	}{
+		{name: "running", status: corev1.PodStatus{Phase: corev1.PodRunning}},
+		{
+			name: "drained",
+			status: corev1.PodStatus{Conditions: []corev1.PodCondition{{
+				Type: corev1.DisruptionTarget, Status: corev1.ConditionTrue,
+				Reason: "EvictionByEvictionAPI", Message: "Eviction API: evicting",
+			}}},
+			wantReason: "EvictionByEvictionAPI: Eviction API: evicting",
+			want:       true,
+		},
+		{
+			name: "condition false",
+			status: corev1.PodStatus{Conditions: []corev1.PodCondition{{
+				Type: corev1.DisruptionTarget, Status: corev1.ConditionFalse,
+			}}},
+		},
+		{
+			name:       "evicted by the kubelet",
+			status:     corev1.PodStatus{Phase: corev1.PodFailed, Reason: "Evicted", Message: "The node was low on resource: memory."},
+			wantReason: "Evicted: The node was low on resource: memory.",
+			want:       true,
+		},
+		{name: "other failure", status: corev1.PodStatus{Phase: corev1.PodFailed, Reason: "DeadlineExceeded"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, got := podDisruption(&corev1.Pod{Status: tt.status})
+			if got != tt.want || reason != tt.wantReason {
+				t.Errorf("podDisruption() = %q, %v; want %q, %v", reason, got, tt.wantReason, tt.want)
+			}
+		})
+	}
+}
+
+func TestOnPodDisruption(t *testing.T) {
+	defer ResetClient()
+	fakeClient := setFakeClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	disruptions := make(chan PodDisruption, 10)
+	remove := OnPodDisruption(func(d PodDisruption) { disruptions <- d })
+	defer remove()
+
+	pod := BuildPodSpec(DefaultPodConfig("sess-drained", "app-1", "App", "myapp:v1"))
+	if _, err := CreatePod(ctx, pod); err != nil {
+		t.Fatalf("CreatePod() error = %v", err)
+	}
+	if err := StartPodCache(ctx); err != nil {
+		t.Fatalf("StartPodCache() error = %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := cachedPod(pod.Name); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("pod cache did not sync")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	next := func() PodDisruption {
+		t.Helper()
+		select {
+		case d := <-disruptions:
+			return d
+		case <-time.After(5 * time.Second):
+			t.Fatal("disruption was not reported")
+		}
+		return PodDisruption{}
+	}
+
+	current, err := fakeClient.CoreV1().Pods(GetNamespace()).Get(ctx, pod.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	current.Status.Conditions = append(current.Status.Conditions, corev1.PodCondition{
+		Type: corev1.DisruptionTarget, Status: corev1.ConditionTrue, Reason: "EvictionByEvictionAPI",
+	})
+	if _, err := fakeClient.CoreV1().Pods(GetNamespace()).UpdateStatus(ctx, current, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if d := next(); d.PodName != pod.Name || d.Reason != "EvictionByEvictionAPI" || d.Deleted {
+		t.Errorf("reported %+v, want the eviction of %s", d, pod.Name)
+	}
+
+	if err := DeletePod(ctx, pod.Name); err != nil {
+		t.Fatal(err)
+	}
+	if d := next(); d.PodName != pod.Name || !d.Deleted {
+		t.Errorf("reported %+v, want the deletion of %s", d, pod.Name)
+	}
+
+	waitCtx, waitCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer waitCancel()
+	if err := WaitForPodDeleted(waitCtx, pod.Name); err != nil {
+		t.Errorf("WaitForPodDeleted() error = %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package k8s
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+
+	"github.com/rjsadow/sortie/internal/db"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Limits Kubernetes places on a pod's DNS config.
+const (
+	maxDNSNameservers   = 3
+	maxDNSSearches      = 32
+	maxDNSSearchListLen = 2048
+)
+
+var (
+	hostnamePattern  = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	subdomainPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+)
+
+// ValidateDNSConfig checks that an app's DNS settings can be rendered into a
+// pod spec. A nil config is valid and leaves the cluster defaults in place.
+func ValidateDNSConfig(c *db.DNSConfig) error {
+	if c == nil {
+		return nil
+	}
+	switch corev1.DNSPolicy(c.Policy) {
+	case "", corev1.DNSClusterFirst, corev1.DNSDefault:
+	case corev1.DNSNone:
+		if len(c.Nameservers) == 0 {
+			return fmt.Errorf("dns policy None requires at least one nameserver")
+		}
+	default:
+		return fmt.Errorf("invalid dns policy %q: must be ClusterFirst, Default or None", c.Policy)
+	}
+	if c.Hostname != "" && (len(c.Hostname) > 63 || !hostnamePattern.MatchString(c.Hostname)) {
+		return fmt.Errorf("invalid hostname %q: must be a lowercase DNS label of at most 63 characters", c.Hostname)
+	}
+	if len(c.Nameservers) > maxDNSNameservers {
+		return fmt.Errorf("at most %d nameservers may be set", maxDNSNameservers)
+	}
+	for _, ns := range c.Nameservers {
+		if net.ParseIP(ns) == nil {
+			return fmt.Errorf("invalid nameserver %q: must be an IP address", ns)
+		}
+	}
+	if len(c.Searches) > maxDNSSearches {
+		return fmt.Errorf("at most %d search domains may be set", maxDNSSearches)
+	}
+	searchLen := 0
+	for _, search := range c.Searches {
+		if !isDNSSubdomain(search) {
+			return fmt.Errorf("invalid search domain %q", search)
+		}
+		searchLen += len(search) + 1
+	}
+	if searchLen > maxDNSSearchListLen {
+		return fmt.Errorf("search domains exceed %d characters", maxDNSSearchListLen)
+	}
+	for _, opt := range c.Options {
+		if opt.Name == "" {
+			return fmt.Errorf("dns option name is required")
+		}
+	}
+	for _, alias := range c.HostAliases {
+		if net.ParseIP(alias.IP) == nil {
+			return fmt.Errorf("invalid host alias IP %q", alias.IP)
+		}
+		if len(alias.Hostnames) == 0 {
+			return fmt.Errorf("host alias %s has no hostnames", alias.IP)
+		}
+		for _, hostname := range alias.Hostnames {
+			if !isDNSSubdomain(hostname) {
+				return fmt.Errorf("invalid host alias hostname %q", hostname)
+			}
+		}
+	}
+	return nil
+}
+
+// isDNSSubdomain reports whether s is a lowercase RFC 1123 subdomain.
+func isDNSSubdomain(s string) bool {
+	return len(s) <= 253 && subdomainPattern.MatchString(s)
+}
+
+// applyDNSConfig renders an app's DNS settings into a pod spec. The config
+// is expected to have passed ValidateDNSConfig.
+func applyDNSConfig(spec *corev1.PodSpec, c *db.DNSConfig) {
+	if c == nil {
+		return
+	}
+	spec.DNSPolicy = corev1.DNSPolicy(c.Policy)
+	spec.Hostname = c.Hostname
+	if len(c.Nameservers) > 0 || len(c.Searches) > 0 || len(c.Options) > 0 {
+		spec.DNSConfig = &corev1.PodDNSConfig{
+			Nameservers: c.Nameservers,
+			Searches:    c.Searches,
+		}
+		for _, opt := range c.Options {
+			o := corev1.PodDNSConfigOption{Name: opt.Name}
+			if opt.Value != "" {
+				value := opt.Value
+				o.Value = &value
+			}
+			spec.DNSConfig.Options = append(spec.DNSConfig.Options, o)
+		}
+	}
+	for _, alias := range c.HostAliases {
+		spec.HostAliases = append(spec.HostAliases, corev1.HostAlias{IP: alias.IP, Hostnames: alias.Hostnames})
+	}
+}
@@ -0,0 +1,95 @@
+package k8s
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/db"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestValidateDNSConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *db.DNSConfig
+		wantErr string
+	}{
+		{"nil", nil, ""},
+		{"empty", &db.DNSConfig{}, ""},
+		{"valid", &db.DNSConfig{
+			Policy:      "None",
+			Hostname:    "workstation",
+			Nameservers: []string{"10.0.0.53", "fd00::53"},
+			Searches:    []string{"corp.example.com"},
+			Options:     []db.DNSOption{{Name: "ndots", Value: "2"}},
+			HostAliases: []db.HostAlias{{IP: "10.1.2.3", Hostnames: []string{"license.corp.example.com"}}},
+		}, ""},
+		{"unknown policy", &db.DNSConfig{Policy: "ClusterFirstWithHostNet"}, "invalid dns policy"},
+		{"none without nameservers", &db.DNSConfig{Policy: "None"}, "requires at least one nameserver"},
+		{"bad hostname", &db.DNSConfig{Hostname: "Work_Station"}, "invalid hostname"},
+		{"long hostname", &db.DNSConfig{Hostname: strings.Repeat("a", 64)}, "invalid hostname"},
+		{"bad nameserver", &db.DNSConfig{Nameservers: []string{"dns.example.com"}}, "invalid nameserver"},
+		{"too many nameservers", &db.DNSConfig{Nameservers: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"}}, "at most 3 nameservers"},
+		{"bad search", &db.DNSConfig{Searches: []string{"corp..example.com"}}, "invalid search domain"},
+		{"empty option", &db.DNSConfig{Options: []db.DNSOption{{Value: "2"}}}, "option name"},
+		{"bad alias ip", &db.DNSConfig{HostAliases: []db.HostAlias{{IP: "nope", Hostnames: []string{"a"}}}}, "invalid host alias IP"},
+		{"alias without hostnames", &db.DNSConfig{HostAliases: []db.HostAlias{{IP: "10.0.0.1"}}}, "no hostnames"},
+		{"bad alias hostname", &db.DNSConfig{HostAliases: []db.HostAlias{{IP: "10.0.0.1", Hostnames: []string{"bad host"}}}}, "invalid host alias hostname"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateDNSConfig(tt.config)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateDNSConfig() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateDNSConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildPodSpecs_WithDNS(t *testing.T) {
+	dns := &db.DNSConfig{
+		Policy:      "None",
+		Hostname:    "workstation",
+		Nameservers: []string{"10.0.0.53"},
+		Searches:    []string{"corp.example.com"},
+		Options:     []db.DNSOption{{Name: "ndots", Value: "2"}, {Name: "rotate"}},
+		HostAliases: []db.HostAlias{{IP: "10.1.2.3", Hostnames: []string{"license.corp.example.com"}}},
+	}
+
+	builders := map[string]func(*PodConfig) *corev1.Pod{
+		"standard":  BuildPodSpec,
+		"web proxy": BuildWebProxyPodSpec,
+		"windows":   BuildWindowsPodSpec,
+	}
+	for name, build := range builders {
+		t.Run(name, func(t *testing.T) {
+			config := DefaultPodConfig("sess-1", "app-1", "Test App", "ubuntu:latest")
+			pod := build(config)
+			if pod.Spec.DNSPolicy != "" || pod.Spec.DNSConfig != nil || pod.Spec.Hostname != "" || pod.Spec.HostAliases != nil {
+				t.Fatalf("pod without DNS config should keep defaults, got %+v", pod.Spec)
+			}
+
+			config.DNS = dns
+			spec := build(config).Spec
+			if spec.DNSPolicy != corev1.DNSNone || spec.Hostname != "workstation" {
+				t.Errorf("DNSPolicy = %q, Hostname = %q", spec.DNSPolicy, spec.Hostname)
+			}
+			if spec.DNSConfig == nil || len(spec.DNSConfig.Nameservers) != 1 || spec.DNSConfig.Searches[0] != "corp.example.com" {
+				t.Fatalf("DNSConfig = %+v", spec.DNSConfig)
+			}
+			opts := spec.DNSConfig.Options
+			if len(opts) != 2 || opts[0].Value == nil || *opts[0].Value != "2" || opts[1].Value != nil {
+				t.Errorf("DNSConfig.Options = %+v", opts)
+			}
+			if len(spec.HostAliases) != 1 || spec.HostAliases[0].IP != "10.1.2.3" {
+				t.Errorf("HostAliases = %+v", spec.HostAliases)
+			}
+		})
+	}
+}
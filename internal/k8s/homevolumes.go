@@ -0,0 +1,181 @@
+package k8s
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"regexp"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// HomeVolumeName is the name of the home volume in a session pod
+	HomeVolumeName = "home"
+
+	// HomeVolumeComponent is the component label value of home volume claims
+	HomeVolumeComponent = "home-volume"
+
+	// DefaultHomeVolumeSize is the capacity requested for a home volume
+	// whose app sets no size
+	DefaultHomeVolumeSize = "10Gi"
+
+	// homeVolumeUserAnnotation and homeVolumeAppAnnotation record a claim's
+	// owner. User IDs are not valid label values, so they are annotations.
+	homeVolumeUserAnnotation = "sortie.io/user-id"
+	homeVolumeAppAnnotation  = "sortie.io/app-id"
+)
+
+var storageClassPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+
+// ValidateHomeVolume checks that an app's home volume can be created and
+// mounted. A nil volume is valid.
+func ValidateHomeVolume(v *db.HomeVolume) error {
+	if v == nil {
+		return nil
+	}
+	if !path.IsAbs(v.MountPath) || path.Clean(v.MountPath) != v.MountPath || v.MountPath == "/" {
+		return fmt.Errorf("invalid mount_path %q: must be a clean absolute path other than /", v.MountPath)
+	}
+	for _, reserved := range []string{WorkspaceMountPath, "/tmp/.X11-unix"} {
+		if v.MountPath == reserved {
+			return fmt.Errorf("mount_path %s is used by Sortie", reserved)
+		}
+	}
+	if v.Size != "" {
+		q, err := resource.ParseQuantity(v.Size)
+		if err != nil || q.Sign() <= 0 {
+			return fmt.Errorf("invalid size %q: must be a positive quantity such as 10Gi", v.Size)
+		}
+	}
+	if v.StorageClass != "" && (len(v.StorageClass) > 253 || !storageClassPattern.MatchString(v.StorageClass)) {
+		return fmt.Errorf("invalid storage_class %q", v.StorageClass)
+	}
+	if v.FSGroup != nil && *v.FSGroup < 0 {
+		return fmt.Errorf("fs_group must be non-negative")
+	}
+	return nil
+}
+
+// HomeVolumeClaimName returns the name of a user's home volume claim for an
+// app. It is derived from both IDs, so each user gets one claim per app.
+func HomeVolumeClaimName(userID, appID string) string {
+	sum := sha256.Sum256([]byte(userID + "\x00" + appID))
+	return "sortie-home-" + hex.EncodeToString(sum[:8])
+}
+
+// BuildHomeVolumeClaim creates the PersistentVolumeClaim for a user's home
+// volume. The volume is expected to have passed ValidateHomeVolume.
+func BuildHomeVolumeClaim(userID, appID string, v *db.HomeVolume) *corev1.PersistentVolumeClaim {
+	size := v.Size
+	if size == "" {
+		size = DefaultHomeVolumeSize
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      HomeVolumeClaimName(userID, appID),
+			Namespace: GetNamespace(),
+			Labels: map[string]string{
+				AppLabelKey:       appID,
+				ComponentLabelKey: HomeVolumeComponent,
+			},
+			Annotations: map[string]string{
+				homeVolumeUserAnnotation: userID,
+				homeVolumeAppAnnotation:  appID,
+				"sortie.io/created-at":   time.Now().UTC().Format(time.RFC3339),
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse(size),
+				},
+			},
+		},
+	}
+	if v.StorageClass != "" {
+		storageClass := v.StorageClass
+		pvc.Spec.StorageClassName = &storageClass
+	}
+	return pvc
+}
+
+// EnsurePVC creates a PersistentVolumeClaim, or returns the existing claim
+// of the same name. An existing claim is returned as it is, even if pvc
+// asks for a different size or storage class.
+func EnsurePVC(ctx context.Context, pvc *corev1.PersistentVolumeClaim) (*corev1.PersistentVolumeClaim, error) {
+	client, err := GetClient()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := client.CoreV1().PersistentVolumeClaims(GetNamespace())
+	created, err := claims.Create(ctx, pvc, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return claims.Get(ctx, pvc.Name, metav1.GetOptions{})
+	}
+	return created, err
+}
+
+// ListHomeVolumeClaims lists the home volume claims of every user.
+func ListHomeVolumeClaims(ctx context.Context) (*corev1.PersistentVolumeClaimList, error) {
+	client, err := GetClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.CoreV1().PersistentVolumeClaims(GetNamespace()).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", ComponentLabelKey, HomeVolumeComponent),
+	})
+}
+
+// DeletePVC deletes a PersistentVolumeClaim by name. Kubernetes keeps a
+// claim that a pod still mounts until the pod is gone.
+func DeletePVC(ctx context.Context, name string) error {
+	client, err := GetClient()
+	if err != nil {
+		return err
+	}
+
+	return client.CoreV1().PersistentVolumeClaims(GetNamespace()).Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+// HomeVolumeOwner returns the user and app a home volume claim belongs to.
+func HomeVolumeOwner(pvc *corev1.PersistentVolumeClaim) (userID, appID string) {
+	return pvc.Annotations[homeVolumeUserAnnotation], pvc.Annotations[homeVolumeAppAnnotation]
+}
+
+// applyHomeVolume mounts a home volume claim in the pod's app container.
+// With fsGroup set, the pod's volumes are made writable by that group.
+func applyHomeVolume(spec *corev1.PodSpec, claimName, mountPath string, fsGroup *int64) {
+	if claimName == "" {
+		return
+	}
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name: HomeVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+		},
+	})
+	for i := range spec.Containers {
+		if spec.Containers[i].Name == "app" {
+			spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts,
+				corev1.VolumeMount{Name: HomeVolumeName, MountPath: mountPath})
+		}
+	}
+	if fsGroup != nil {
+		if spec.SecurityContext == nil {
+			spec.SecurityContext = &corev1.PodSecurityContext{}
+		}
+		group := *fsGroup
+		spec.SecurityContext.FSGroup = &group
+	}
+}
@@ -0,0 +1,129 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/db"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestValidateHomeVolume(t *testing.T) {
+	negative := int64(-1)
+	tests := []struct {
+		name    string
+		vol     *db.HomeVolume
+		wantErr bool
+	}{
+		{"nil", nil, false},
+		{"path only", &db.HomeVolume{MountPath: "/home/user"}, false},
+		{"all fields", &db.HomeVolume{MountPath: "/config", Size: "5Gi", StorageClass: "fast-ssd"}, false},
+		{"relative path", &db.HomeVolume{MountPath: "home"}, true},
+		{"unclean path", &db.HomeVolume{MountPath: "/home/../etc"}, true},
+		{"root", &db.HomeVolume{MountPath: "/"}, true},
+		{"workspace", &db.HomeVolume{MountPath: WorkspaceMountPath}, true},
+		{"bad size", &db.HomeVolume{MountPath: "/home/user", Size: "lots"}, true},
+		{"zero size", &db.HomeVolume{MountPath: "/home/user", Size: "0"}, true},
+		{"bad storage class", &db.HomeVolume{MountPath: "/home/user", StorageClass: "Fast SSD"}, true},
+		{"negative fs group", &db.HomeVolume{MountPath: "/home/user", FSGroup: &negative}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateHomeVolume(tt.vol); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateHomeVolume() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHomeVolumeClaims(t *testing.T) {
+	defer ResetClient()
+	setFakeClient(t)
+	ctx := context.Background()
+
+	vol := &db.HomeVolume{MountPath: "/home/user", StorageClass: "fast"}
+	pvc, err := EnsurePVC(ctx, BuildHomeVolumeClaim("user-alice@example.com", "app-1", vol))
+	if err != nil {
+		t.Fatalf("EnsurePVC() error = %v", err)
+	}
+	if pvc.Name != HomeVolumeClaimName("user-alice@example.com", "app-1") {
+		t.Errorf("claim name = %s", pvc.Name)
+	}
+	if q := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; q.String() != DefaultHomeVolumeSize {
+		t.Errorf("requested %s, want the default %s", q.String(), DefaultHomeVolumeSize)
+	}
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName != "fast" {
+		t.Errorf("storage class = %v, want fast", pvc.Spec.StorageClassName)
+	}
+
+	// A later launch reuses the claim, even after the app's size changes
+	again, err := EnsurePVC(ctx, BuildHomeVolumeClaim("user-alice@example.com", "app-1", &db.HomeVolume{MountPath: "/home/user", Size: "50Gi"}))
+	if err != nil || again.Name != pvc.Name {
+		t.Fatalf("EnsurePVC() again = %v, %v", again, err)
+	}
+	if q := again.Spec.Resources.Requests[corev1.ResourceStorage]; q.String() != DefaultHomeVolumeSize {
+		t.Errorf("existing claim resized to %s", q.String())
+	}
+
+	if _, err := EnsurePVC(ctx, BuildHomeVolumeClaim("user-bob", "app-1", vol)); err != nil {
+		t.Fatalf("EnsurePVC() error = %v", err)
+	}
+	list, err := ListHomeVolumeClaims(ctx)
+	if err != nil || len(list.Items) != 2 {
+		t.Fatalf("ListHomeVolumeClaims() = %v, %v; want 2 claims", list, err)
+	}
+	for _, item := range list.Items {
+		userID, appID := HomeVolumeOwner(&item)
+		if item.Name != HomeVolumeClaimName(userID, appID) {
+			t.Errorf("claim %s owned by %q/%q", item.Name, userID, appID)
+		}
+	}
+
+	if err := DeletePVC(ctx, pvc.Name); err != nil {
+		t.Fatalf("DeletePVC() error = %v", err)
+	}
+	if list, _ := ListHomeVolumeClaims(ctx); len(list.Items) != 1 {
+		t.Errorf("%d claims left after delete, want 1", len(list.Items))
+	}
+}
+
+func TestBuildPodSpec_HomeVolume(t *testing.T) {
+	fsGroup := int64(1000)
+	config := DefaultPodConfig("sess-1", "app-1", "App", "myapp:v1")
+	config.HomeVolumeClaim = "sortie-home-abc"
+	config.HomeMountPath = "/home/user"
+	config.HomeFSGroup = &fsGroup
+
+	for _, pod := range []*corev1.Pod{BuildPodSpec(config), BuildWebProxyPodSpec(config), BuildWindowsPodSpec(config)} {
+		var claim string
+		for _, v := range pod.Spec.Volumes {
+			if v.Name == HomeVolumeName && v.PersistentVolumeClaim != nil {
+				claim = v.PersistentVolumeClaim.ClaimName
+			}
+		}
+		if claim != "sortie-home-abc" {
+			t.Errorf("home volume claim = %q", claim)
+		}
+		for _, c := range pod.Spec.Containers {
+			mounted := false
+			for _, m := range c.VolumeMounts {
+				if m.Name == HomeVolumeName && m.MountPath == "/home/user" {
+					mounted = true
+				}
+			}
+			if mounted != (c.Name == "app") {
+				t.Errorf("container %s mounts home volume = %v", c.Name, mounted)
+			}
+		}
+		if pod.Spec.SecurityContext == nil || pod.Spec.SecurityContext.FSGroup == nil || *pod.Spec.SecurityContext.FSGroup != 1000 {
+			t.Errorf("pod security context = %+v, want fsGroup 1000", pod.Spec.SecurityContext)
+		}
+	}
+
+	plain := BuildPodSpec(DefaultPodConfig("sess-2", "app-1", "App", "myapp:v1"))
+	for _, v := range plain.Spec.Volumes {
+		if v.Name == HomeVolumeName {
+			t.Error("pod without a home volume claim has a home volume")
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/rjsadow/sortie/internal/db"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// dockerHubRegistry is where images without a registry host are pulled from.
+const dockerHubRegistry = "registry-1.docker.io"
+
+// manifestMediaTypes are the manifest formats a container runtime accepts.
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+var (
+	repositoryPattern = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+	tagPattern        = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	digestPattern     = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,}$`)
+
+	// ErrImageNotFound means the registry has no manifest for an image.
+	ErrImageNotFound = errors.New("image not found")
+	// ErrImageUnauthorized means the registry requires credentials to pull
+	// an image. Pull secrets are not checked, so such images may still be
+	// pullable by the cluster.
+	ErrImageUnauthorized = errors.New("registry requires credentials")
+)
+
+// ImageReference is a parsed container image name.
+type ImageReference struct {
+	Registry   string // Registry host, e.g. registry-1.docker.io
+	Repository string // e.g. library/nginx
+	Reference  string // Tag or digest
+}
+
+// ParseImageReference parses an image name the way container runtimes do:
+// images without a registry host come from Docker Hub, and images without
+// a tag or digest use "latest".
+func ParseImageReference(image string) (*ImageReference, error) {
+	ref := &ImageReference{Registry: dockerHubRegistry, Reference: "latest"}
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, ref.Reference = name[:i], name[i+1:]
+		if !digestPattern.MatchString(ref.Reference) {
+			return nil, fmt.Errorf("invalid image %q: malformed digest", image)
+		}
+	} else if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.Reference = name[:i], name[i+1:]
+		if !tagPattern.MatchString(ref.Reference) {
+			return nil, fmt.Errorf("invalid image %q: malformed tag", image)
+		}
+	}
+
+	if host, rest, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(host, ".:") || host == "localhost") {
+		ref.Registry, name = host, rest
+	}
+	if ref.Registry == dockerHubRegistry && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	if !repositoryPattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid image %q: malformed repository", image)
+	}
+	ref.Repository = name
+	return ref, nil
+}
+
+// registryMirrors maps registry hosts to the mirrors images are pulled
+// from instead, each a registry host with an optional path prefix.
+var registryMirrors map[string]string
+
+// ConfigureRegistryMirrors sets the registry mirrors, keyed by the
+// registry they stand in for. "docker.io" and "index.docker.io" name
+// Docker Hub, as in image names.
+func ConfigureRegistryMirrors(mirrors map[string]string) {
+	registryMirrors = make(map[string]string, len(mirrors))
+	for registry, mirror := range mirrors {
+		registry = strings.ToLower(registry)
+		if registry == "docker.io" || registry == "index.docker.io" {
+			registry = dockerHubRegistry
+		}
+		registryMirrors[registry] = strings.TrimSuffix(mirror, "/")
+	}
+}
+
+// MirrorImage returns image rewritten to pull from the mirror configured
+// for its registry, or image unchanged if there is none. The repository
+// keeps its full path, so Docker Hub's official images keep "library/".
+func MirrorImage(image string) string {
+	if len(registryMirrors) == 0 {
+		return image
+	}
+	ref, err := ParseImageReference(image)
+	if err != nil {
+		return image
+	}
+	mirror, ok := registryMirrors[ref.Registry]
+	if !ok {
+		return image
+	}
+	sep := ":"
+	if strings.Contains(ref.Reference, ":") {
+		sep = "@"
+	}
+	return mirror + "/" + ref.Repository + sep + ref.Reference
+}
+
+// mirrorPodImages points every container in pod at the configured
+// mirrors.
+func mirrorPodImages(pod *corev1.Pod) {
+	for i := range pod.Spec.InitContainers {
+		pod.Spec.InitContainers[i].Image = MirrorImage(pod.Spec.InitContainers[i].Image)
+	}
+	for i := range pod.Spec.Containers {
+		pod.Spec.Containers[i].Image = MirrorImage(pod.Spec.Containers[i].Image)
+	}
+}
+
+// ValidateSidecarImages checks that an app's sidecar image overrides are
+// well-formed image names. A nil value is valid and uses the configured
+// images.
+func ValidateSidecarImages(images *db.SidecarImages) error {
+	if images == nil {
+		return nil
+	}
+	for _, image := range []string{images.VNC, images.Browser, images.Guacd} {
+		if image == "" {
+			continue
+		}
+		if _, err := ParseImageReference(image); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SidecarImages returns the configured built-in sidecar images and any
+// overrides the given apps set, without duplicates.
+func SidecarImages(apps []db.Application) []string {
+	images := []string{GetVNCSidecarImage(), GetBrowserSidecarImage(), GetGuacdSidecarImage()}
+	for _, app := range apps {
+		if o := app.SidecarImages; o != nil {
+			images = append(images, o.VNC, o.Browser, o.Guacd)
+		}
+	}
+	images = slices.DeleteFunc(images, func(image string) bool { return image == "" })
+	slices.Sort(images)
+	return slices.Compact(images)
+}
+
+// DisplaySidecarImage returns the image of the built-in sidecar that serves
+// a session pod's display, or "" if the app serves it itself.
+func DisplaySidecarImage(pod *corev1.Pod) string {
+	for _, c := range pod.Spec.Containers {
+		if displayContainers[c.Name] {
+			return c.Image
+		}
+	}
+	return ""
+}
+
+// CheckImage checks that a registry has a manifest for image, using the
+// registry API rather than pulling it. Anonymous bearer tokens are
+// requested when the registry asks for them. Registries on localhost are
+// reached over plain HTTP.
+func CheckImage(ctx context.Context, client *http.Client, image string) error {
+	ref, err := ParseImageReference(image)
+	if err != nil {
+		return err
+	}
+
+	scheme := "https"
+	if host := strings.Split(ref.Registry, ":")[0]; host == "localhost" || host == "127.0.0.1" {
+		scheme = "http"
+	}
+	manifestURL := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", scheme, ref.Registry, ref.Repository, ref.Reference)
+
+	resp, err := headManifest(ctx, client, manifestURL, "")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		token, err := anonymousToken(ctx, client, resp.Header.Get("WWW-Authenticate"), ref.Repository)
+		if err != nil {
+			return err
+		}
+		if resp, err = headManifest(ctx, client, manifestURL, token); err != nil {
+			return err
+		}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return ErrImageNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrImageUnauthorized
+	default:
+		return fmt.Errorf("registry returned status %d", resp.StatusCode)
+	}
+}
+
+func headManifest(ctx context.Context, client *http.Client, manifestURL, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach registry: %w", err)
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// anonymousToken requests a pull token for repository from the bearer
+// realm in a registry's WWW-Authenticate challenge.
+func anonymousToken(ctx context.Context, client *http.Client, challenge, repository string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", ErrImageUnauthorized
+	}
+	attrs := map[string]string{}
+	for _, p := range strings.Split(params, ",") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(p), "="); ok {
+			attrs[k] = strings.Trim(v, `"`)
+		}
+	}
+	realm, err := url.Parse(attrs["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("registry sent an invalid auth challenge: %q", challenge)
+	}
+	q := realm.Query()
+	if attrs["service"] != "" {
+		q.Set("service", attrs["service"])
+	}
+	q.Set("scope", "repository:"+repository+":pull")
+	realm.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get registry token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", ErrImageUnauthorized
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode registry token: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
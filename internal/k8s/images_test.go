@@ -0,0 +1,160 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+func TestParseImageReference(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("ab", 32)
+	tests := []struct {
+		image   string
+		want    ImageReference
+		wantErr bool
+	}{
+		{image: "nginx", want: ImageReference{dockerHubRegistry, "library/nginx", "latest"}},
+		{image: "guacamole/guacd:1.6.0", want: ImageReference{dockerHubRegistry, "guacamole/guacd", "1.6.0"}},
+		{image: "ghcr.io/rjsadow/sortie-vnc-sidecar:latest", want: ImageReference{"ghcr.io", "rjsadow/sortie-vnc-sidecar", "latest"}},
+		{image: "localhost:5000/guacd", want: ImageReference{"localhost:5000", "guacd", "latest"}},
+		{image: "registry.example.com:8443/team/guacd@" + digest, want: ImageReference{"registry.example.com:8443", "team/guacd", digest}},
+		{image: "Guacd:1.6.0", wantErr: true},
+		{image: "guacd:bad tag", wantErr: true},
+		{image: "guacd@sha256:short", wantErr: true},
+		{image: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseImageReference(tt.image)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseImageReference(%q) = %+v, want error", tt.image, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseImageReference(%q) error = %v", tt.image, err)
+			continue
+		}
+		if *got != tt.want {
+			t.Errorf("ParseImageReference(%q) = %+v, want %+v", tt.image, *got, tt.want)
+		}
+	}
+}
+
+func TestMirrorImage(t *testing.T) {
+	defer ResetClient()
+	digest := "sha256:" + strings.Repeat("ab", 32)
+
+	if got := MirrorImage("nginx:1.27"); got != "nginx:1.27" {
+		t.Errorf("MirrorImage() without mirrors = %q, want unchanged", got)
+	}
+
+	ConfigureRegistryMirrors(map[string]string{
+		"docker.io": "registry.local:5000/dockerhub/",
+		"GHCR.io":   "registry.local:5000/ghcr",
+	})
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{"nginx:1.27", "registry.local:5000/dockerhub/library/nginx:1.27"},
+		{"guacamole/guacd", "registry.local:5000/dockerhub/guacamole/guacd:latest"},
+		{"docker.io/guacamole/guacd:1.6.0", "docker.io/guacamole/guacd:1.6.0"},
+		{"ghcr.io/rjsadow/sortie-vnc-sidecar@" + digest, "registry.local:5000/ghcr/rjsadow/sortie-vnc-sidecar@" + digest},
+		{"quay.io/team/app:v1", "quay.io/team/app:v1"},
+		{"Not An Image", "Not An Image"},
+	}
+	for _, tt := range tests {
+		if got := MirrorImage(tt.image); got != tt.want {
+			t.Errorf("MirrorImage(%q) = %q, want %q", tt.image, got, tt.want)
+		}
+	}
+}
+
+func TestValidateSidecarImages(t *testing.T) {
+	if err := ValidateSidecarImages(nil); err != nil {
+		t.Errorf("nil overrides: %v", err)
+	}
+	if err := ValidateSidecarImages(&db.SidecarImages{Guacd: "guacamole/guacd:1.5.5"}); err != nil {
+		t.Errorf("valid override: %v", err)
+	}
+	if err := ValidateSidecarImages(&db.SidecarImages{VNC: "not a valid image"}); err == nil {
+		t.Error("expected malformed override to be rejected")
+	}
+}
+
+func TestSidecarImages(t *testing.T) {
+	defer ResetClient()
+	Configure("test-ns", "", "")
+	ConfigureGuacdSidecar("guacamole/guacd:1.6.0")
+
+	apps := []db.Application{
+		{ID: "a", SidecarImages: &db.SidecarImages{Guacd: "guacamole/guacd:1.5.5"}},
+		{ID: "b", SidecarImages: &db.SidecarImages{Guacd: "guacamole/guacd:1.5.5"}},
+		{ID: "c"},
+	}
+	got := SidecarImages(apps)
+	want := []string{BrowserSidecarImage, VNCSidecarImage, "guacamole/guacd:1.5.5", "guacamole/guacd:1.6.0"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("SidecarImages() = %v, want %v", got, want)
+	}
+}
+
+// fakeRegistry serves manifests for the given repositories and tags,
+// requiring an anonymous bearer token like Docker Hub does.
+func fakeRegistry(t *testing.T, manifests map[string]bool, grantTokens bool) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if !grantTokens {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Query().Get("scope") == "" || r.URL.Query().Get("service") != "fake" {
+				t.Errorf("unexpected token request %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"token":"anon"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer anon" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+srv.URL+`/token",service="fake"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodHead || !manifests[r.URL.Path] {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCheckImage(t *testing.T) {
+	ctx := context.Background()
+	srv := fakeRegistry(t, map[string]bool{"/v2/team/guacd/manifests/1.5.5": true}, true)
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	if err := CheckImage(ctx, srv.Client(), host+"/team/guacd:1.5.5"); err != nil {
+		t.Errorf("existing image: %v", err)
+	}
+	if err := CheckImage(ctx, srv.Client(), host+"/team/guacd:9.9.9"); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("missing tag: got %v, want ErrImageNotFound", err)
+	}
+	if err := CheckImage(ctx, srv.Client(), "Not Valid"); err == nil {
+		t.Error("expected malformed image to fail")
+	}
+
+	private := fakeRegistry(t, nil, false)
+	privateHost := strings.TrimPrefix(private.URL, "http://")
+	if err := CheckImage(ctx, private.Client(), privateHost+"/team/guacd:1.5.5"); !errors.Is(err, ErrImageUnauthorized) {
+		t.Errorf("private image: got %v, want ErrImageUnauthorized", err)
+	}
+}
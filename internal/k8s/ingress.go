@@ -0,0 +1,262 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// SessionIngressLabelKey is the label key used to mark Ingresses that serve
+// sessions at their own hostnames
+const SessionIngressLabelKey = "sortie.io/session-ingress"
+
+// NginxAuthURLAnnotation makes ingress-nginx check each request with a
+// forward-auth endpoint before passing it to the backend.
+const NginxAuthURLAnnotation = "nginx.ingress.kubernetes.io/auth-url"
+
+// nginxBackendProtocolAnnotation tells ingress-nginx to speak HTTPS to the
+// backend.
+const nginxBackendProtocolAnnotation = "nginx.ingress.kubernetes.io/backend-protocol"
+
+// sessionServicePort is the port of the per-session Services used for
+// direct routing.
+const sessionServicePort = 80
+
+// SessionIngressConfig configures the Ingresses that serve web_proxy
+// sessions at their own hostnames. By default each routes its hostname to
+// the Sortie server, which proxies it to the session. With Direct set, each
+// routes straight to a Service in front of the session's pod, and the
+// ingress controller asks AuthURL whether to allow each request.
+type SessionIngressConfig struct {
+	ServiceName string            // Service of the Sortie server
+	ServicePort int32             // Port of ServiceName
+	ClassName   string            // IngressClass (empty = cluster default)
+	TLSSecret   string            // Secret with a wildcard certificate (empty = one Secret per session)
+	Annotations map[string]string // Added to every session Ingress
+	Direct      bool              // Route to the session pod rather than the Sortie server
+	AuthURL     string            // Forward-auth endpoint for direct routes
+}
+
+var configuredSessionIngress SessionIngressConfig
+
+// ConfigureSessionIngress sets the configuration of session Ingresses.
+func ConfigureSessionIngress(cfg SessionIngressConfig) {
+	configuredSessionIngress = cfg
+}
+
+// GetSessionIngressConfig returns the configuration of session Ingresses.
+func GetSessionIngressConfig() SessionIngressConfig {
+	return configuredSessionIngress
+}
+
+// SessionIngressPermissions are the permissions session Ingresses need on
+// top of RequiredPermissions. Without a wildcard certificate the server
+// also deletes each session's certificate Secret.
+func SessionIngressPermissions(cfg SessionIngressConfig) []Permission {
+	perms := []Permission{
+		{Group: "networking.k8s.io", Resource: "ingresses", Verb: "create"},
+		{Group: "networking.k8s.io", Resource: "ingresses", Verb: "delete"},
+	}
+	if cfg.TLSSecret == "" {
+		perms = append(perms, Permission{Resource: "secrets", Verb: "delete"})
+	}
+	if cfg.Direct {
+		perms = append(perms,
+			Permission{Resource: "services", Verb: "create"},
+			Permission{Resource: "services", Verb: "delete"},
+		)
+	}
+	return perms
+}
+
+func sessionIngressName(sessionID string) string {
+	return fmt.Sprintf("sortie-session-%s", sessionID)
+}
+
+func sessionTLSSecretName(sessionID string) string {
+	return fmt.Sprintf("sortie-session-%s-tls", sessionID)
+}
+
+// BuildSessionService creates a Service in front of a session's pod,
+// forwarding sessionServicePort to the web app's port, for direct routes.
+func BuildSessionService(sessionID, appID string, port int32) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      sessionIngressName(sessionID),
+			Namespace: GetNamespace(),
+			Labels: map[string]string{
+				SessionLabelKey:        sessionID,
+				AppLabelKey:            appID,
+				SessionIngressLabelKey: "true",
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{
+				SessionLabelKey:   sessionID,
+				ComponentLabelKey: "session",
+			},
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "http",
+					Port:       sessionServicePort,
+					TargetPort: intstr.FromInt32(port),
+					Protocol:   corev1.ProtocolTCP,
+				},
+			},
+		},
+	}
+}
+
+// BuildSessionIngress creates an Ingress that routes host to the Sortie
+// server or, with cfg.Direct, to the session's Service behind cfg.AuthURL.
+// With a wildcard certificate configured it terminates TLS with that;
+// otherwise it names a per-session Secret for a certificate issuer such as
+// cert-manager to fill, requested through cfg.Annotations.
+func BuildSessionIngress(sessionID, appID, host string, cfg SessionIngressConfig) *networkingv1.Ingress {
+	pathType := networkingv1.PathTypePrefix
+	secret := cfg.TLSSecret
+	if secret == "" {
+		secret = sessionTLSSecretName(sessionID)
+	}
+	backend := networkingv1.IngressServiceBackend{
+		Name: cfg.ServiceName,
+		Port: networkingv1.ServiceBackendPort{Number: cfg.ServicePort},
+	}
+	if cfg.Direct {
+		backend = networkingv1.IngressServiceBackend{
+			Name: sessionIngressName(sessionID),
+			Port: networkingv1.ServiceBackendPort{Number: sessionServicePort},
+		}
+	}
+
+	ing := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      sessionIngressName(sessionID),
+			Namespace: GetNamespace(),
+			Labels: map[string]string{
+				SessionLabelKey:        sessionID,
+				AppLabelKey:            appID,
+				SessionIngressLabelKey: "true",
+			},
+		},
+		Spec: networkingv1.IngressSpec{
+			TLS: []networkingv1.IngressTLS{
+				{Hosts: []string{host}, SecretName: secret},
+			},
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: host,
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									Path:     "/",
+									PathType: &pathType,
+									Backend: networkingv1.IngressBackend{
+										Service: &backend,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if cfg.ClassName != "" {
+		className := cfg.ClassName
+		ing.Spec.IngressClassName = &className
+	}
+	if cfg.Direct || len(cfg.Annotations) > 0 {
+		ing.Annotations = make(map[string]string, len(cfg.Annotations)+1)
+		if cfg.Direct {
+			ing.Annotations[NginxAuthURLAnnotation] = sessionAuthURL(cfg.AuthURL, host)
+		}
+		// Configured annotations win, so other controllers' forward-auth
+		// settings can replace the ingress-nginx one
+		for k, v := range cfg.Annotations {
+			ing.Annotations[k] = v
+		}
+	}
+
+	return ing
+}
+
+// sessionAuthURL adds the session's host, and the method of each request
+// as an nginx variable, to the forward-auth URL. Fixing them in the URL
+// stops clients choosing them with forwarded headers.
+func sessionAuthURL(authURL, host string) string {
+	sep := "?"
+	if strings.Contains(authURL, "?") {
+		sep = "&"
+	}
+	return authURL + sep + "host=" + url.QueryEscape(host) + "&method=$request_method"
+}
+
+// SetBackendHTTPS marks a direct session Ingress as speaking HTTPS to the
+// session's pod, for apps such as code-server that only serve HTTPS.
+func SetBackendHTTPS(ing *networkingv1.Ingress) {
+	if ing.Annotations == nil {
+		ing.Annotations = make(map[string]string)
+	}
+	if _, ok := ing.Annotations[nginxBackendProtocolAnnotation]; !ok {
+		ing.Annotations[nginxBackendProtocolAnnotation] = "HTTPS"
+	}
+}
+
+// CreateIngress creates an Ingress in the cluster
+func CreateIngress(ctx context.Context, ing *networkingv1.Ingress) (*networkingv1.Ingress, error) {
+	client, err := GetClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.NetworkingV1().Ingresses(GetNamespace()).Create(ctx, ing, metav1.CreateOptions{})
+}
+
+// CreateService creates a Service in the cluster
+func CreateService(ctx context.Context, svc *corev1.Service) (*corev1.Service, error) {
+	client, err := GetClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.CoreV1().Services(GetNamespace()).Create(ctx, svc, metav1.CreateOptions{})
+}
+
+// DeleteSessionIngress deletes the Ingress for a session and, without a
+// wildcard certificate, its certificate Secret. With direct routing it
+// also deletes the session's Service. Ignores not-found errors since a
+// session may have none of them.
+func DeleteSessionIngress(ctx context.Context, sessionID string, cfg SessionIngressConfig) error {
+	client, err := GetClient()
+	if err != nil {
+		return err
+	}
+
+	err = client.NetworkingV1().Ingresses(GetNamespace()).Delete(ctx, sessionIngressName(sessionID), metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if cfg.Direct {
+		err = client.CoreV1().Services(GetNamespace()).Delete(ctx, sessionIngressName(sessionID), metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	if cfg.TLSSecret != "" {
+		return nil
+	}
+	err = client.CoreV1().Secrets(GetNamespace()).Delete(ctx, sessionTLSSecretName(sessionID), metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
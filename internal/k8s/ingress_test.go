@@ -0,0 +1,176 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBuildSessionIngress_WildcardCertificate(t *testing.T) {
+	defer ResetClient()
+	cfg := SessionIngressConfig{
+		ServiceName: "sortie",
+		ServicePort: 80,
+		ClassName:   "nginx",
+		TLSSecret:   "sortie-wildcard-tls",
+		Annotations: map[string]string{"nginx.ingress.kubernetes.io/proxy-read-timeout": "3600"},
+	}
+	ing := BuildSessionIngress("sess-1", "jupyter", "jupyter-alice.sortie.example.com", cfg)
+
+	if ing.Name != "sortie-session-sess-1" {
+		t.Errorf("Name = %q, want sortie-session-sess-1", ing.Name)
+	}
+	if ing.Labels[SessionLabelKey] != "sess-1" || ing.Labels[AppLabelKey] != "jupyter" || ing.Labels[SessionIngressLabelKey] != "true" {
+		t.Errorf("Labels = %v", ing.Labels)
+	}
+	if ing.Annotations["nginx.ingress.kubernetes.io/proxy-read-timeout"] != "3600" {
+		t.Errorf("Annotations = %v", ing.Annotations)
+	}
+	if ing.Spec.IngressClassName == nil || *ing.Spec.IngressClassName != "nginx" {
+		t.Errorf("IngressClassName = %v, want nginx", ing.Spec.IngressClassName)
+	}
+	if len(ing.Spec.TLS) != 1 || ing.Spec.TLS[0].SecretName != "sortie-wildcard-tls" || ing.Spec.TLS[0].Hosts[0] != "jupyter-alice.sortie.example.com" {
+		t.Errorf("TLS = %+v, want the wildcard secret for the session host", ing.Spec.TLS)
+	}
+
+	if len(ing.Spec.Rules) != 1 {
+		t.Fatalf("got %d rules, want 1", len(ing.Spec.Rules))
+	}
+	rule := ing.Spec.Rules[0]
+	if rule.Host != "jupyter-alice.sortie.example.com" {
+		t.Errorf("Host = %q", rule.Host)
+	}
+	backend := rule.HTTP.Paths[0].Backend.Service
+	if backend.Name != "sortie" || backend.Port.Number != 80 {
+		t.Errorf("backend = %s:%d, want sortie:80", backend.Name, backend.Port.Number)
+	}
+
+	// Annotations are copied, not shared with the config
+	ing.Annotations["extra"] = "x"
+	if _, ok := cfg.Annotations["extra"]; ok {
+		t.Error("BuildSessionIngress shares its annotations map with the config")
+	}
+}
+
+func TestBuildSessionIngress_PerSessionCertificate(t *testing.T) {
+	defer ResetClient()
+	ing := BuildSessionIngress("sess-2", "jupyter", "jupyter-bob.sortie.example.com", SessionIngressConfig{ServiceName: "sortie", ServicePort: 80})
+
+	if got := ing.Spec.TLS[0].SecretName; got != "sortie-session-sess-2-tls" {
+		t.Errorf("SecretName = %q, want sortie-session-sess-2-tls", got)
+	}
+	if ing.Spec.IngressClassName != nil {
+		t.Errorf("IngressClassName = %v, want cluster default", *ing.Spec.IngressClassName)
+	}
+}
+
+func TestSessionIngressPermissions(t *testing.T) {
+	if got := len(SessionIngressPermissions(SessionIngressConfig{TLSSecret: "wildcard"})); got != 2 {
+		t.Errorf("with a wildcard certificate got %d permissions, want 2", got)
+	}
+	perms := SessionIngressPermissions(SessionIngressConfig{})
+	if len(perms) != 3 || perms[2].Resource != "secrets" {
+		t.Errorf("without a wildcard certificate got %v, want secret deletion too", perms)
+	}
+	perms = SessionIngressPermissions(SessionIngressConfig{TLSSecret: "wildcard", Direct: true})
+	if len(perms) != 4 || perms[2].Resource != "services" || perms[3].Resource != "services" {
+		t.Errorf("with direct routing got %v, want service creation and deletion too", perms)
+	}
+}
+
+func TestSessionIngress_WithFakeClient(t *testing.T) {
+	defer ResetClient()
+	fakeClient := setFakeClient(t)
+	ctx := context.Background()
+	cfg := SessionIngressConfig{ServiceName: "sortie", ServicePort: 80}
+
+	ing := BuildSessionIngress("sess-3", "jupyter", "jupyter-carol.sortie.example.com", cfg)
+	if _, err := CreateIngress(ctx, ing); err != nil {
+		t.Fatalf("CreateIngress() error = %v", err)
+	}
+	// The certificate issuer's Secret
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "sortie-session-sess-3-tls", Namespace: "test-ns"}}
+	if _, err := fakeClient.CoreV1().Secrets("test-ns").Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("create secret: %v", err)
+	}
+
+	if err := DeleteSessionIngress(ctx, "sess-3", cfg); err != nil {
+		t.Fatalf("DeleteSessionIngress() error = %v", err)
+	}
+	if _, err := fakeClient.NetworkingV1().Ingresses("test-ns").Get(ctx, "sortie-session-sess-3", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Ingress still exists after delete (err = %v)", err)
+	}
+	if _, err := fakeClient.CoreV1().Secrets("test-ns").Get(ctx, "sortie-session-sess-3-tls", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("certificate Secret still exists after delete (err = %v)", err)
+	}
+
+	// Deleting again is not an error
+	if err := DeleteSessionIngress(ctx, "sess-3", cfg); err != nil {
+		t.Errorf("second DeleteSessionIngress() error = %v", err)
+	}
+}
+
+func TestBuildSessionIngress_Direct(t *testing.T) {
+	defer ResetClient()
+	cfg := SessionIngressConfig{
+		ServiceName: "sortie",
+		ServicePort: 80,
+		TLSSecret:   "sortie-wildcard-tls",
+		Direct:      true,
+		AuthURL:     "http://sortie.apps.svc:80/api/auth/session-host",
+	}
+	ing := BuildSessionIngress("sess-4", "jupyter", "jupyter-dave.sortie.example.com", cfg)
+
+	backend := ing.Spec.Rules[0].HTTP.Paths[0].Backend.Service
+	if backend.Name != "sortie-session-sess-4" || backend.Port.Number != 80 {
+		t.Errorf("backend = %s:%d, want the session's Service", backend.Name, backend.Port.Number)
+	}
+	want := "http://sortie.apps.svc:80/api/auth/session-host?host=jupyter-dave.sortie.example.com&method=$request_method"
+	if got := ing.Annotations[NginxAuthURLAnnotation]; got != want {
+		t.Errorf("auth URL = %q, want %q", got, want)
+	}
+
+	// Configured annotations replace the generated one
+	cfg.Annotations = map[string]string{NginxAuthURLAnnotation: "http://custom/auth"}
+	ing = BuildSessionIngress("sess-4", "jupyter", "jupyter-dave.sortie.example.com", cfg)
+	if got := ing.Annotations[NginxAuthURLAnnotation]; got != "http://custom/auth" {
+		t.Errorf("auth URL = %q, want the configured one", got)
+	}
+
+	SetBackendHTTPS(ing)
+	if ing.Annotations[nginxBackendProtocolAnnotation] != "HTTPS" {
+		t.Errorf("Annotations = %v, want an HTTPS backend", ing.Annotations)
+	}
+
+	svc := BuildSessionService("sess-4", "jupyter", 8888)
+	if svc.Name != "sortie-session-sess-4" || svc.Spec.Selector[SessionLabelKey] != "sess-4" {
+		t.Errorf("Service = %s selecting %v", svc.Name, svc.Spec.Selector)
+	}
+	if p := svc.Spec.Ports[0]; p.Port != 80 || p.TargetPort.IntValue() != 8888 {
+		t.Errorf("Service port = %d -> %s, want 80 -> 8888", p.Port, p.TargetPort.String())
+	}
+}
+
+func TestSessionIngress_DirectWithFakeClient(t *testing.T) {
+	defer ResetClient()
+	fakeClient := setFakeClient(t)
+	ctx := context.Background()
+	cfg := SessionIngressConfig{TLSSecret: "wildcard", Direct: true, AuthURL: "http://sortie/api/auth/session-host"}
+
+	if _, err := CreateService(ctx, BuildSessionService("sess-5", "jupyter", 8888)); err != nil {
+		t.Fatalf("CreateService() error = %v", err)
+	}
+	if _, err := CreateIngress(ctx, BuildSessionIngress("sess-5", "jupyter", "jupyter-erin.sortie.example.com", cfg)); err != nil {
+		t.Fatalf("CreateIngress() error = %v", err)
+	}
+
+	if err := DeleteSessionIngress(ctx, "sess-5", cfg); err != nil {
+		t.Fatalf("DeleteSessionIngress() error = %v", err)
+	}
+	if _, err := fakeClient.CoreV1().Services("test-ns").Get(ctx, "sortie-session-sess-5", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Service still exists after delete (err = %v)", err)
+	}
+}
@@ -0,0 +1,107 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/client-go/tools/metrics"
+)
+
+// throttledWait is how long a request must wait for the client-side rate
+// limiter to count as throttled. client-go logs waits from the same point.
+const throttledWait = 50 * time.Millisecond
+
+// apiMetrics counts Kubernetes API requests, how long they waited for the
+// client-side rate limiter, and the responses the API server sent back.
+// 429 responses are the API server's priority and fairness rejecting
+// requests; client-go retries them after the server's Retry-After.
+var apiMetrics struct {
+	registered atomic.Bool
+	waits      atomic.Int64 // requests that waited at least throttledWait
+	waitNanos  atomic.Int64 // total time requests waited for the rate limiter
+	retries    atomic.Int64
+
+	mu      sync.Mutex
+	results map[string]int64 // response code, or "<error>", to requests
+}
+
+var registerOnce sync.Once
+
+// registerMetrics hooks the API metrics into client-go. client-go accepts
+// only the first registration in a process, so it is done once.
+func registerMetrics() {
+	registerOnce.Do(func() {
+		metrics.Register(metrics.RegisterOpts{
+			RateLimiterLatency: rateLimiterLatency{},
+			RequestResult:      requestResult{},
+			RequestRetry:       requestRetry{},
+		})
+		apiMetrics.registered.Store(true)
+	})
+}
+
+type rateLimiterLatency struct{}
+
+func (rateLimiterLatency) Observe(_ context.Context, _ string, _ url.URL, latency time.Duration) {
+	apiMetrics.waitNanos.Add(int64(latency))
+	if latency >= throttledWait {
+		apiMetrics.waits.Add(1)
+	}
+}
+
+type requestResult struct{}
+
+func (requestResult) Increment(_ context.Context, code, _, _ string) {
+	apiMetrics.mu.Lock()
+	defer apiMetrics.mu.Unlock()
+	if apiMetrics.results == nil {
+		apiMetrics.results = make(map[string]int64)
+	}
+	apiMetrics.results[code]++
+}
+
+type requestRetry struct{}
+
+func (requestRetry) IncrementRetry(context.Context, string, string, string) {
+	apiMetrics.retries.Add(1)
+}
+
+// WritePrometheus writes the Kubernetes API client metrics in the
+// Prometheus text exposition format. It writes nothing if the client has
+// not been created.
+func WritePrometheus(w io.Writer) {
+	if !apiMetrics.registered.Load() {
+		return
+	}
+
+	apiMetrics.mu.Lock()
+	codes := make([]string, 0, len(apiMetrics.results))
+	for code := range apiMetrics.results {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	fmt.Fprintf(w, "# HELP sortie_k8s_requests_total Kubernetes API requests by response code.\n# TYPE sortie_k8s_requests_total counter\n")
+	for _, code := range codes {
+		fmt.Fprintf(w, "sortie_k8s_requests_total{code=%q} %d\n", code, apiMetrics.results[code])
+	}
+	throttled := apiMetrics.results["429"]
+	apiMetrics.mu.Unlock()
+
+	metric := func(name, kind, help string, value float64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", name, help, name, kind, name, value)
+	}
+	metric("sortie_k8s_server_throttled_total", "counter", "Kubernetes API requests rejected with 429 Too Many Requests.",
+		float64(throttled))
+	metric("sortie_k8s_request_retries_total", "counter", "Kubernetes API requests retried by the client.",
+		float64(apiMetrics.retries.Load()))
+	metric("sortie_k8s_client_throttled_total", "counter", "Kubernetes API requests delayed by the client-side rate limiter.",
+		float64(apiMetrics.waits.Load()))
+	metric("sortie_k8s_client_throttle_seconds_total", "counter", "Time Kubernetes API requests waited for the client-side rate limiter.",
+		time.Duration(apiMetrics.waitNanos.Load()).Seconds())
+}
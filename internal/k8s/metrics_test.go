@@ -0,0 +1,41 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	registerMetrics()
+	ctx := context.Background()
+
+	before := apiMetrics.waits.Load()
+	rateLimiterLatency{}.Observe(ctx, "GET", url.URL{}, time.Millisecond)
+	rateLimiterLatency{}.Observe(ctx, "GET", url.URL{}, 200*time.Millisecond)
+	if got := apiMetrics.waits.Load() - before; got != 1 {
+		t.Errorf("throttled waits = %d, want 1", got)
+	}
+	requestResult{}.Increment(ctx, "429", "GET", "api")
+	requestResult{}.Increment(ctx, "200", "GET", "api")
+	requestRetry{}.IncrementRetry(ctx, "429", "GET", "api")
+
+	var buf bytes.Buffer
+	WritePrometheus(&buf)
+	out := buf.String()
+	for _, want := range []string{
+		`sortie_k8s_requests_total{code="200"} `,
+		`sortie_k8s_requests_total{code="429"} `,
+		"# TYPE sortie_k8s_server_throttled_total counter\nsortie_k8s_server_throttled_total ",
+		"sortie_k8s_request_retries_total ",
+		"sortie_k8s_client_throttled_total ",
+		"sortie_k8s_client_throttle_seconds_total ",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/rjsadow/sortie/internal/db"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
@@ -169,6 +170,22 @@ func DeleteNetworkPolicy(ctx context.Context, name string) error {
 	return client.NetworkingV1().NetworkPolicies(GetNamespace()).Delete(ctx, name, metav1.DeleteOptions{})
 }
 
+// GetSessionNetworkPolicy returns the NetworkPolicy for a session, or nil if
+// the session has none.
+func GetSessionNetworkPolicy(ctx context.Context, sessionID string) (*networkingv1.NetworkPolicy, error) {
+	client, err := GetClient()
+	if err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("sortie-egress-%s", sessionID)
+	np, err := client.NetworkingV1().NetworkPolicies(GetNamespace()).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	return np, err
+}
+
 // DeleteSessionNetworkPolicy deletes the NetworkPolicy for a session.
 // Ignores not-found errors since the policy may not exist.
 func DeleteSessionNetworkPolicy(ctx context.Context, sessionID string) error {
@@ -0,0 +1,80 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Permission is an API verb the server needs on a resource in its
+// namespace.
+type Permission struct {
+	Group       string
+	Resource    string
+	Subresource string
+	Verb        string
+}
+
+func (p Permission) String() string {
+	resource := p.Resource
+	if p.Subresource != "" {
+		resource += "/" + p.Subresource
+	}
+	if p.Group != "" {
+		resource += "." + p.Group
+	}
+	return p.Verb + " " + resource
+}
+
+// RequiredPermissions are the permissions session management uses. They
+// match the Role in the Helm chart.
+var RequiredPermissions = []Permission{
+	{Resource: "pods", Verb: "create"},
+	{Resource: "pods", Verb: "delete"},
+	{Resource: "pods", Verb: "get"},
+	{Resource: "pods", Verb: "list"},
+	{Resource: "pods", Verb: "update"},
+	{Resource: "pods", Verb: "watch"},
+	{Resource: "pods", Subresource: "exec", Verb: "create"},
+	{Resource: "events", Verb: "list"},
+	{Resource: "persistentvolumeclaims", Verb: "create"},
+	{Resource: "persistentvolumeclaims", Verb: "delete"},
+	{Resource: "persistentvolumeclaims", Verb: "get"},
+	{Resource: "persistentvolumeclaims", Verb: "list"},
+	{Group: "networking.k8s.io", Resource: "networkpolicies", Verb: "create"},
+	{Group: "networking.k8s.io", Resource: "networkpolicies", Verb: "delete"},
+}
+
+// MissingPermissions asks the API server which of perms the server's
+// credentials lack in its namespace.
+func MissingPermissions(ctx context.Context, perms []Permission) ([]Permission, error) {
+	client, err := GetClient()
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []Permission
+	for _, p := range perms {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace:   GetNamespace(),
+					Group:       p.Group,
+					Resource:    p.Resource,
+					Subresource: p.Subresource,
+					Verb:        p.Verb,
+				},
+			},
+		}
+		result, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to review access to %s: %w", p, err)
+		}
+		if !result.Status.Allowed {
+			missing = append(missing, p)
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,37 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestMissingPermissions(t *testing.T) {
+	defer ResetClient()
+	fakeClient := setFakeClient(t)
+	fakeClient.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		if attrs.Namespace != "test-ns" {
+			t.Errorf("review namespace = %q, want test-ns", attrs.Namespace)
+		}
+		review.Status.Allowed = attrs.Subresource != "exec"
+		return true, review, nil
+	})
+
+	missing, err := MissingPermissions(context.Background(), RequiredPermissions)
+	if err != nil {
+		t.Fatalf("MissingPermissions() error = %v", err)
+	}
+	if len(missing) != 1 || missing[0].String() != "create pods/exec" {
+		t.Errorf("MissingPermissions() = %v, want [create pods/exec]", missing)
+	}
+
+	np := Permission{Group: "networking.k8s.io", Resource: "networkpolicies", Verb: "delete"}
+	if got := np.String(); got != "delete networkpolicies.networking.k8s.io" {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -0,0 +1,136 @@
+package k8s
+
+import (
+	"context"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// podCache mirrors the session and warm pool pods in the namespace from a
+// watch, so launch and status polling read them from memory instead of
+// GETting each pod from the API server.
+var podCache struct {
+	mu     sync.RWMutex
+	lister corelisters.PodLister
+	synced cache.InformerSynced
+	stop   context.CancelFunc
+}
+
+// StartPodCache starts watching the pods with the session component label
+// until ctx is done. Until the first list completes, or once ctx is done,
+// pod reads go to the API server. The watch also reports pod restarts to
+// the handlers registered with OnPodRestart, and disruptions to those
+// registered with OnPodDisruption.
+func StartPodCache(ctx context.Context) error {
+	client, err := GetClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	factory := informers.NewSharedInformerFactoryWithOptions(client, 0,
+		informers.WithNamespace(GetNamespace()),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = ComponentLabelKey + "=session"
+		}))
+	pods := factory.Core().V1().Pods()
+	informer := pods.Informer()
+	lister := pods.Lister()
+	if _, err := informer.AddEventHandler(restartEventHandler); err != nil {
+		cancel()
+		return err
+	}
+	if _, err := informer.AddEventHandler(disruptionEventHandler); err != nil {
+		cancel()
+		return err
+	}
+
+	podCache.mu.Lock()
+	if podCache.stop != nil {
+		podCache.stop()
+	}
+	podCache.lister = lister
+	podCache.synced = informer.HasSynced
+	podCache.stop = cancel
+	podCache.mu.Unlock()
+
+	factory.Start(ctx.Done())
+	go func() {
+		<-ctx.Done()
+		podCache.mu.Lock()
+		if podCache.lister == lister {
+			podCache.lister = nil
+			podCache.synced = nil
+			podCache.stop = nil
+		}
+		podCache.mu.Unlock()
+		factory.Shutdown()
+	}()
+	return nil
+}
+
+// stopPodCache stops the pod cache, if one is running.
+func stopPodCache() {
+	podCache.mu.Lock()
+	defer podCache.mu.Unlock()
+	if podCache.stop != nil {
+		podCache.stop()
+	}
+	podCache.lister = nil
+	podCache.synced = nil
+	podCache.stop = nil
+}
+
+// cachedPods returns the pod cache's lister for the namespace, or nil if
+// the cache is not running or has not synced yet.
+func cachedPods() corelisters.PodNamespaceLister {
+	podCache.mu.RLock()
+	defer podCache.mu.RUnlock()
+	if podCache.lister == nil || !podCache.synced() {
+		return nil
+	}
+	return podCache.lister.Pods(GetNamespace())
+}
+
+// cachedPod returns a copy of the named pod from the cache. It reports false
+// if the cache is not running or does not have the pod, which may just have
+// been created.
+func cachedPod(name string) (*corev1.Pod, bool) {
+	pods := cachedPods()
+	if pods == nil {
+		return nil, false
+	}
+	pod, err := pods.Get(name)
+	if err != nil {
+		return nil, false
+	}
+	return pod.DeepCopy(), true
+}
+
+// listCachedPods lists copies of the cached pods matching selector. It
+// reports false if the cache is not running.
+func listCachedPods(selector string) (*corev1.PodList, bool) {
+	pods := cachedPods()
+	if pods == nil {
+		return nil, false
+	}
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		return nil, false
+	}
+	matched, err := pods.List(sel)
+	if err != nil {
+		return nil, false
+	}
+	list := &corev1.PodList{Items: make([]corev1.Pod, 0, len(matched))}
+	for _, pod := range matched {
+		list.Items = append(list.Items, *pod.DeepCopy())
+	}
+	return list, true
+}
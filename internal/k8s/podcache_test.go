@@ -0,0 +1,88 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPodCache(t *testing.T) {
+	defer ResetClient()
+	fakeClient := setFakeClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	session := BuildPodSpec(DefaultPodConfig("sess-cached", "app-1", "App", "myapp:v1"))
+	warm := BuildPodSpec(DefaultPodConfig("warm-cached", "app-1", "App", "myapp:v1"))
+	MakeWarmPod(warm, "pool-a")
+	if _, err := CreatePod(ctx, session); err != nil {
+		t.Fatalf("CreatePod() error = %v", err)
+	}
+	if _, err := CreatePod(ctx, warm); err != nil {
+		t.Fatalf("CreatePod() error = %v", err)
+	}
+
+	if err := StartPodCache(ctx); err != nil {
+		t.Fatalf("StartPodCache() error = %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := cachedPod(session.Name); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("pod cache did not sync")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Reads are served from the cache once it has synced
+	fakeClient.ClearActions()
+	if _, err := GetPod(ctx, session.Name); err != nil {
+		t.Fatalf("GetPod() error = %v", err)
+	}
+	if ip, err := GetPodIP(ctx, session.Name); err == nil {
+		t.Errorf("GetPodIP() = %q, want an error for a pod without an IP", ip)
+	}
+	if pods, err := ListSessionPods(ctx); err != nil || len(pods.Items) != 1 || pods.Items[0].Name != session.Name {
+		t.Errorf("ListSessionPods() = %v, %v; want the session pod", pods, err)
+	}
+	if pods, err := ListWarmPods(ctx); err != nil || len(pods.Items) != 1 || pods.Items[0].Name != warm.Name {
+		t.Errorf("ListWarmPods() = %v, %v; want the warm pod", pods, err)
+	}
+	if actions := fakeClient.Actions(); len(actions) != 0 {
+		t.Errorf("cached reads made %d API requests: %v", len(actions), actions)
+	}
+
+	// Pods the cache has not seen yet are read from the API server
+	if _, err := GetPod(ctx, "sortie-session-unknown"); err == nil {
+		t.Error("GetPod() of a missing pod succeeded")
+	}
+	if len(fakeClient.Actions()) != 1 {
+		t.Errorf("uncached read made %d API requests, want 1", len(fakeClient.Actions()))
+	}
+
+	// Copies are returned, so callers cannot change the cache
+	pod, _ := GetPod(ctx, session.Name)
+	pod.Labels[SessionLabelKey] = "changed"
+	if cached, _ := cachedPod(session.Name); cached.Labels[SessionLabelKey] != "sess-cached" {
+		t.Error("changing a returned pod changed the cache")
+	}
+
+	// Once stopped, reads go back to the API server
+	cancel()
+	deadline = time.Now().Add(5 * time.Second)
+	for cachedPods() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("pod cache still in use after its context was done")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	fakeClient.ClearActions()
+	if _, err := GetPod(context.Background(), session.Name); err != nil {
+		t.Fatalf("GetPod() error = %v", err)
+	}
+	if len(fakeClient.Actions()) != 1 {
+		t.Errorf("read after stop made %d API requests, want 1", len(fakeClient.Actions()))
+	}
+}
@@ -6,6 +6,12 @@ import (
 	"strings"
 	"time"
 
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/plugins"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -13,6 +19,9 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 )
 
+// tracer records spans for Kubernetes operations.
+var tracer = otel.Tracer("github.com/rjsadow/sortie/internal/k8s")
+
 const (
 	// VNCSidecarImage is the default VNC sidecar container image
 	VNCSidecarImage = "ghcr.io/rjsadow/sortie-vnc-sidecar:latest"
@@ -38,6 +47,10 @@ const (
 
 	// ComponentLabelKey is the label key for component identification
 	ComponentLabelKey = "app.kubernetes.io/component"
+
+	// WarmPoolLabelKey marks an unclaimed pod in a warm pool, in place of
+	// the session label. Its value names the pool.
+	WarmPoolLabelKey = "sortie.io/warm-pool"
 )
 
 // PodConfig contains configuration for creating a session pod
@@ -57,6 +70,40 @@ type PodConfig struct {
 	ScreenResolution string // e.g. "1920x1080x24", empty uses sidecar default
 	ScreenWidth      int    // Screen width in pixels, passed to app container
 	ScreenHeight     int    // Screen height in pixels, passed to app container
+	// Sidecars are extra containers added to the pod. Validate them with
+	// ValidateSidecars before building.
+	Sidecars []plugins.Sidecar
+	// Containers are the app's extra containers and SharedVolumes the
+	// shared volumes its app container mounts. Validate them with
+	// ValidateAppContainers before building.
+	Containers    []db.AppContainer
+	SharedVolumes []db.SharedVolumeMount
+	// InitContainers run before the pod's containers start. Validate them
+	// with ValidateInitContainers before building.
+	InitContainers []db.InitContainer
+	// DNS customizes the pod's hostname and name resolution. Validate it
+	// with ValidateDNSConfig before building.
+	DNS *db.DNSConfig
+	// Scheduling pins the pod to nodes. Validate it with
+	// ValidateScheduling before building.
+	Scheduling *db.Scheduling
+	// ImagePullSecret names the Secret the pod's images are pulled with.
+	// Validate it with ValidateImagePullSecret before building.
+	ImagePullSecret string
+	// SidecarImages overrides the configured built-in sidecar images.
+	SidecarImages *db.SidecarImages
+	// HomeVolumeClaim names a user's home volume claim, mounted in the app
+	// container at HomeMountPath. HomeFSGroup, if set, owns the volume.
+	HomeVolumeClaim string
+	HomeMountPath   string
+	HomeFSGroup     *int64
+	// WorkspaceClaim, if set, backs the workspace volume with a claim
+	// instead of an emptyDir, so it outlives the pod.
+	WorkspaceClaim string
+	// ProjectDriveClaim names the drive claim of the session's project,
+	// mounted in the app container at ProjectDriveMountPath.
+	ProjectDriveClaim     string
+	ProjectDriveMountPath string
 }
 
 // DefaultPodConfig returns a PodConfig with sensible defaults
@@ -84,8 +131,11 @@ func BuildPodSpec(config *PodConfig) *corev1.Pod {
 	// Detect if this is a jlesage image (has built-in VNC on port 5800)
 	jlesageImage := isJlesageImage(config.ContainerImage)
 
-	// Get VNC sidecar image from centralized config
+	// Get VNC sidecar image from the app's override or centralized config
 	vncImage := GetVNCSidecarImage()
+	if config.SidecarImages != nil && config.SidecarImages.VNC != "" {
+		vncImage = config.SidecarImages.VNC
+	}
 
 	// Sanitize pod name (must be DNS-1123 compliant)
 	podName := fmt.Sprintf("sortie-session-%s", config.SessionID)
@@ -299,17 +349,44 @@ func BuildPodSpec(config *PodConfig) *corev1.Pod {
 		}
 	}
 
+	pod.Spec.Containers = append(pod.Spec.Containers, buildSidecarContainers(config.Sidecars)...)
+	applyAppContainers(&pod.Spec, config.Containers, config.SharedVolumes)
+	applyInitContainers(&pod.Spec, config.InitContainers)
+	applyDNSConfig(&pod.Spec, config.DNS)
+	applyScheduling(&pod.Spec, config.Scheduling)
+	applyImagePullSecret(&pod.Spec, config.ImagePullSecret)
+	applyHomeVolume(&pod.Spec, config.HomeVolumeClaim, config.HomeMountPath, config.HomeFSGroup)
+	applyProjectDrive(&pod.Spec, config.ProjectDriveClaim, config.ProjectDriveMountPath)
+	applyWorkspaceClaim(&pod.Spec, config.WorkspaceClaim)
+
 	return pod
 }
 
 // CreatePod creates a new pod in the cluster
 func CreatePod(ctx context.Context, pod *corev1.Pod) (*corev1.Pod, error) {
+	ctx, span := tracer.Start(ctx, "k8s.CreatePod", trace.WithAttributes(
+		semconv.K8SNamespaceName(GetNamespace()),
+		semconv.K8SPodName(pod.Name),
+	))
+	defer span.End()
+
 	client, err := GetClient()
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
 
-	return client.CoreV1().Pods(GetNamespace()).Create(ctx, pod, metav1.CreateOptions{})
+	// Mirrors apply here, where every session and warm pool pod is
+	// created, so no image can bypass them
+	mirrorPodImages(pod)
+	created, err := client.CoreV1().Pods(GetNamespace()).Create(ctx, pod, metav1.CreateOptions{})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return created, nil
 }
 
 // DeletePod deletes a pod by name
@@ -322,8 +399,12 @@ func DeletePod(ctx context.Context, podName string) error {
 	return client.CoreV1().Pods(GetNamespace()).Delete(ctx, podName, metav1.DeleteOptions{})
 }
 
-// GetPod retrieves a pod by name
+// GetPod retrieves a pod by name, from the pod cache when it has the pod
 func GetPod(ctx context.Context, podName string) (*corev1.Pod, error) {
+	if pod, ok := cachedPod(podName); ok {
+		return pod, nil
+	}
+
 	client, err := GetClient()
 	if err != nil {
 		return nil, err
@@ -334,13 +415,12 @@ func GetPod(ctx context.Context, podName string) (*corev1.Pod, error) {
 
 // WaitForPodReady waits for a pod to be ready with a timeout
 func WaitForPodReady(ctx context.Context, podName string, timeout time.Duration) error {
-	client, err := GetClient()
-	if err != nil {
+	if _, err := GetClient(); err != nil {
 		return err
 	}
 
 	return wait.PollUntilContextTimeout(ctx, 2*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
-		pod, err := client.CoreV1().Pods(GetNamespace()).Get(ctx, podName, metav1.GetOptions{})
+		pod, err := GetPod(ctx, podName)
 		if err != nil {
 			return false, err
 		}
@@ -354,6 +434,9 @@ func WaitForPodReady(ctx context.Context, podName string, timeout time.Duration)
 
 		// Check if pod has failed
 		if pod.Status.Phase == corev1.PodFailed || pod.Status.Phase == corev1.PodSucceeded {
+			if detail := failedInitContainer(pod); detail != "" {
+				return false, fmt.Errorf("pod %s is in terminal state: %s: init container %s", podName, pod.Status.Phase, detail)
+			}
 			return false, fmt.Errorf("pod %s is in terminal state: %s", podName, pod.Status.Phase)
 		}
 
@@ -377,13 +460,18 @@ func GetPodIP(ctx context.Context, podName string) (string, error) {
 
 // ListSessionPods lists all pods belonging to sortie sessions
 func ListSessionPods(ctx context.Context) (*corev1.PodList, error) {
+	selector := fmt.Sprintf("%s,%s=session", SessionLabelKey, ComponentLabelKey)
+	if pods, ok := listCachedPods(selector); ok {
+		return pods, nil
+	}
+
 	client, err := GetClient()
 	if err != nil {
 		return nil, err
 	}
 
 	return client.CoreV1().Pods(GetNamespace()).List(ctx, metav1.ListOptions{
-		LabelSelector: fmt.Sprintf("%s,%s=session", SessionLabelKey, ComponentLabelKey),
+		LabelSelector: selector,
 	})
 }
 
@@ -397,8 +485,11 @@ func BuildWebProxyPodSpec(config *PodConfig) *corev1.Pod {
 		port = 8080
 	}
 
-	// Get browser sidecar image from centralized config
+	// Get browser sidecar image from the app's override or centralized config
 	browserImage := GetBrowserSidecarImage()
+	if config.SidecarImages != nil && config.SidecarImages.Browser != "" {
+		browserImage = config.SidecarImages.Browser
+	}
 
 	// Sanitize pod name (must be DNS-1123 compliant)
 	podName := fmt.Sprintf("sortie-session-%s", config.SessionID)
@@ -541,6 +632,16 @@ func BuildWebProxyPodSpec(config *PodConfig) *corev1.Pod {
 		},
 	}
 
+	pod.Spec.Containers = append(pod.Spec.Containers, buildSidecarContainers(config.Sidecars)...)
+	applyAppContainers(&pod.Spec, config.Containers, config.SharedVolumes)
+	applyInitContainers(&pod.Spec, config.InitContainers)
+	applyDNSConfig(&pod.Spec, config.DNS)
+	applyScheduling(&pod.Spec, config.Scheduling)
+	applyImagePullSecret(&pod.Spec, config.ImagePullSecret)
+	applyHomeVolume(&pod.Spec, config.HomeVolumeClaim, config.HomeMountPath, config.HomeFSGroup)
+	applyProjectDrive(&pod.Spec, config.ProjectDriveClaim, config.ProjectDriveMountPath)
+	applyWorkspaceClaim(&pod.Spec, config.WorkspaceClaim)
+
 	return pod
 }
 
@@ -549,8 +650,11 @@ func BuildWebProxyPodSpec(config *PodConfig) *corev1.Pod {
 // RDP to the Guacamole protocol. The frontend connects via WebSocket to our server,
 // which connects to guacd on port 4822.
 func BuildWindowsPodSpec(config *PodConfig) *corev1.Pod {
-	// Get guacd sidecar image from centralized config
+	// Get guacd sidecar image from the app's override or centralized config
 	guacdImage := GetGuacdSidecarImage()
+	if config.SidecarImages != nil && config.SidecarImages.Guacd != "" {
+		guacdImage = config.SidecarImages.Guacd
+	}
 
 	// Sanitize pod name (must be DNS-1123 compliant)
 	podName := fmt.Sprintf("sortie-session-%s", config.SessionID)
@@ -681,6 +785,16 @@ func BuildWindowsPodSpec(config *PodConfig) *corev1.Pod {
 		},
 	}
 
+	pod.Spec.Containers = append(pod.Spec.Containers, buildSidecarContainers(config.Sidecars)...)
+	applyAppContainers(&pod.Spec, config.Containers, config.SharedVolumes)
+	applyInitContainers(&pod.Spec, config.InitContainers)
+	applyDNSConfig(&pod.Spec, config.DNS)
+	applyScheduling(&pod.Spec, config.Scheduling)
+	applyImagePullSecret(&pod.Spec, config.ImagePullSecret)
+	applyHomeVolume(&pod.Spec, config.HomeVolumeClaim, config.HomeMountPath, config.HomeFSGroup)
+	applyProjectDrive(&pod.Spec, config.ProjectDriveClaim, config.ProjectDriveMountPath)
+	applyWorkspaceClaim(&pod.Spec, config.WorkspaceClaim)
+
 	return pod
 }
 
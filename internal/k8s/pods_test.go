@@ -3,9 +3,11 @@ package k8s
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/rjsadow/sortie/internal/db"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
@@ -534,6 +536,35 @@ func TestBuildWindowsPodSpec_CustomGuacdImage(t *testing.T) {
 	}
 }
 
+func TestBuildPodSpecs_AppSidecarImageOverrides(t *testing.T) {
+	defer ResetClient()
+	Configure("test-ns", "", "custom-vnc:v2")
+	ConfigureGuacdSidecar("custom-guacd:v3")
+
+	config := DefaultPodConfig("sess-1", "app-1", "App", "myapp:v1")
+	config.SidecarImages = &db.SidecarImages{VNC: "app-vnc:v1", Browser: "app-browser:v1", Guacd: "guacamole/guacd:1.5.5"}
+
+	tests := []struct {
+		name string
+		pod  *corev1.Pod
+		want string
+	}{
+		{"vnc", BuildPodSpec(config), "app-vnc:v1"},
+		{"browser", BuildWebProxyPodSpec(config), "app-browser:v1"},
+		{"guacd", BuildWindowsPodSpec(config), "guacamole/guacd:1.5.5"},
+	}
+	for _, tt := range tests {
+		if got := DisplaySidecarImage(tt.pod); got != tt.want {
+			t.Errorf("%s sidecar image = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	config.SidecarImages = &db.SidecarImages{Browser: "app-browser:v1"}
+	if got := DisplaySidecarImage(BuildWindowsPodSpec(config)); got != "custom-guacd:v3" {
+		t.Errorf("guacd image without override = %q, want configured custom-guacd:v3", got)
+	}
+}
+
 func TestBuildWindowsPodSpec_EnvVars(t *testing.T) {
 	defer ResetClient()
 	Configure("test-ns", "", "")
@@ -585,6 +616,26 @@ func TestCreatePod_WithFakeClient(t *testing.T) {
 	}
 }
 
+func TestCreatePod_RegistryMirrors(t *testing.T) {
+	defer ResetClient()
+	setFakeClient(t)
+	ConfigureRegistryMirrors(map[string]string{
+		"docker.io": "mirror.local/hub",
+		"ghcr.io":   "mirror.local/ghcr",
+	})
+
+	config := DefaultPodConfig("sess-mirror", "app-1", "App", "myapp:v1")
+	created, err := CreatePod(context.Background(), BuildPodSpec(config))
+	if err != nil {
+		t.Fatalf("CreatePod() error = %v", err)
+	}
+	for _, c := range append(created.Spec.InitContainers, created.Spec.Containers...) {
+		if !strings.HasPrefix(c.Image, "mirror.local/") {
+			t.Errorf("container %s image = %q, want a mirror", c.Name, c.Image)
+		}
+	}
+}
+
 func TestGetPod_WithFakeClient(t *testing.T) {
 	defer ResetClient()
 	setFakeClient(t)
@@ -750,6 +801,39 @@ func TestWaitForPodReady_Failed(t *testing.T) {
 	}
 }
 
+func TestWaitForPodReady_InitContainerFailed(t *testing.T) {
+	defer ResetClient()
+	fakeClient := setFakeClient(t)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "init-failed-pod",
+			Namespace: "test-ns",
+		},
+	}
+
+	createdPod, err := fakeClient.CoreV1().Pods("test-ns").Create(context.Background(), pod, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	createdPod.Status = corev1.PodStatus{
+		Phase: corev1.PodFailed,
+		InitContainerStatuses: []corev1.ContainerStatus{{
+			Name:  "init-seed",
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 2, Reason: "Error"}},
+		}},
+	}
+	_, err = fakeClient.CoreV1().Pods("test-ns").UpdateStatus(context.Background(), createdPod, metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatalf("UpdateStatus() error = %v", err)
+	}
+
+	err = WaitForPodReady(context.Background(), "init-failed-pod", 5*time.Second)
+	if err == nil || !strings.Contains(err.Error(), "init container init-seed: exited with code 2") {
+		t.Errorf("WaitForPodReady() error = %v, want it to name the failed init container", err)
+	}
+}
+
 func TestWaitForPodReady_Timeout(t *testing.T) {
 	defer ResetClient()
 	fakeClient := setFakeClient(t)
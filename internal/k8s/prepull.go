@@ -0,0 +1,197 @@
+package k8s
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// PrePullComponent is the component label value of image pre-pull
+	// DaemonSets and their pods
+	PrePullComponent = "image-prepull"
+
+	// prePullStartedAnnotation records when a pre-pull was started.
+	prePullStartedAnnotation = "sortie.io/created-at"
+
+	// prePullCommand keeps a pre-pull container idle once its image is
+	// pulled. Images without a shell are still pulled; their containers
+	// restart until the pre-pull is deleted.
+	prePullCommand = "sleep 2147483647"
+)
+
+// PrePullDaemonSetName returns the name of an app's pre-pull DaemonSet.
+func PrePullDaemonSetName(appID string) string {
+	sum := sha256.Sum256([]byte(appID))
+	return "sortie-prepull-" + hex.EncodeToString(sum[:8])
+}
+
+// PodImages returns the distinct images of a pod's containers, init
+// containers first.
+func PodImages(pod *corev1.Pod) []string {
+	var images []string
+	seen := make(map[string]bool)
+	for _, c := range append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...) {
+		if c.Image != "" && !seen[c.Image] {
+			seen[c.Image] = true
+			images = append(images, c.Image)
+		}
+	}
+	return images
+}
+
+// BuildPrePullDaemonSet creates a DaemonSet that pulls the images of an
+// app's session pod onto every node the pod may be scheduled on. Each image
+// gets an idle container with a minimal footprint, and the pod takes the
+// session pod's node selection, tolerations, and image pull secrets.
+func BuildPrePullDaemonSet(appID string, pod *corev1.Pod) *appsv1.DaemonSet {
+	labels := map[string]string{
+		AppLabelKey:       appID,
+		ComponentLabelKey: PrePullComponent,
+	}
+
+	var containers []corev1.Container
+	for i, image := range PodImages(pod) {
+		containers = append(containers, corev1.Container{
+			Name:            fmt.Sprintf("pull-%d", i),
+			Image:           image,
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Command:         []string{"/bin/sh", "-c", prePullCommand},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("1m"),
+					corev1.ResourceMemory: resource.MustParse("8Mi"),
+				},
+				Limits: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("10m"),
+					corev1.ResourceMemory: resource.MustParse("32Mi"),
+				},
+			},
+		})
+	}
+
+	terminationGrace := int64(0)
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      PrePullDaemonSetName(appID),
+			Namespace: GetNamespace(),
+			Labels:    labels,
+			Annotations: map[string]string{
+				prePullStartedAnnotation: time.Now().UTC().Format(time.RFC3339),
+			},
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers:                    containers,
+					NodeSelector:                  pod.Spec.NodeSelector,
+					Affinity:                      pod.Spec.Affinity,
+					Tolerations:                   pod.Spec.Tolerations,
+					ImagePullSecrets:              pod.Spec.ImagePullSecrets,
+					AutomountServiceAccountToken:  boolPtr(false),
+					TerminationGracePeriodSeconds: &terminationGrace,
+				},
+			},
+		},
+	}
+}
+
+// ApplyPrePull creates an app's pre-pull DaemonSet, replacing the one
+// already running for the app so that a new pre-pull starts over.
+func ApplyPrePull(ctx context.Context, ds *appsv1.DaemonSet) error {
+	client, err := GetClient()
+	if err != nil {
+		return err
+	}
+
+	daemonSets := client.AppsV1().DaemonSets(GetNamespace())
+	if err := DeletePrePull(ctx, ds.Name); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	_, err = daemonSets.Create(ctx, ds, metav1.CreateOptions{})
+	return err
+}
+
+// DeletePrePull deletes a pre-pull DaemonSet and its pods by name.
+func DeletePrePull(ctx context.Context, name string) error {
+	client, err := GetClient()
+	if err != nil {
+		return err
+	}
+
+	propagation := metav1.DeletePropagationBackground
+	return client.AppsV1().DaemonSets(GetNamespace()).Delete(ctx, name, metav1.DeleteOptions{
+		PropagationPolicy: &propagation,
+	})
+}
+
+// PrePullState is a pre-pull DaemonSet with its pods.
+type PrePullState struct {
+	DaemonSet appsv1.DaemonSet
+	Pods      []corev1.Pod
+}
+
+// ListPrePulls returns every pre-pull DaemonSet with its pods.
+func ListPrePulls(ctx context.Context) ([]PrePullState, error) {
+	client, err := GetClient()
+	if err != nil {
+		return nil, err
+	}
+
+	selector := fmt.Sprintf("%s=%s", ComponentLabelKey, PrePullComponent)
+	daemonSets, err := client.AppsV1().DaemonSets(GetNamespace()).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	pods, err := client.CoreV1().Pods(GetNamespace()).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]PrePullState, 0, len(daemonSets.Items))
+	for _, ds := range daemonSets.Items {
+		state := PrePullState{DaemonSet: ds}
+		for _, pod := range pods.Items {
+			if pod.Labels[AppLabelKey] == ds.Labels[AppLabelKey] {
+				state.Pods = append(state.Pods, pod)
+			}
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// PrePullStartedAt returns when a pre-pull DaemonSet was started.
+func PrePullStartedAt(ds *appsv1.DaemonSet) time.Time {
+	if t, err := time.Parse(time.RFC3339, ds.Annotations[prePullStartedAnnotation]); err == nil {
+		return t
+	}
+	return ds.CreationTimestamp.Time
+}
+
+// PrePullPodProgress returns how many of its images a pre-pull pod's node
+// has, and why an image could not be pulled, if one could not. An image
+// counts as pulled once its container has an image ID, whether or not the
+// container then runs.
+func PrePullPodProgress(pod *corev1.Pod) (pulled int, pullErr string) {
+	for _, s := range pod.Status.ContainerStatuses {
+		if s.ImageID != "" {
+			pulled++
+			continue
+		}
+		if w := s.State.Waiting; w != nil && imagePullFailures[w.Reason] && pullErr == "" {
+			pullErr = fmt.Sprintf("%s: %s: %s", s.Image, w.Reason, w.Message)
+		}
+	}
+	return pulled, pullErr
+}
@@ -0,0 +1,93 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/db"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBuildPrePullDaemonSet(t *testing.T) {
+	config := DefaultPodConfig("prepull", "cad", "CAD", "registry.example.com/cad:1")
+	config.ImagePullSecret = "private-creds"
+	config.Scheduling = &db.Scheduling{NodeSelector: map[string]string{"gpu": "true"}}
+	pod := BuildPodSpec(config)
+
+	ds := BuildPrePullDaemonSet("cad", pod)
+	if ds.Name != PrePullDaemonSetName("cad") || ds.Labels[ComponentLabelKey] != PrePullComponent {
+		t.Errorf("daemonset metadata = %+v", ds.ObjectMeta)
+	}
+	spec := ds.Spec.Template.Spec
+	images := PodImages(pod)
+	if len(spec.Containers) != len(images) || len(images) < 2 {
+		t.Fatalf("containers = %d, want one per image %v", len(spec.Containers), images)
+	}
+	for i, c := range spec.Containers {
+		if c.Image != images[i] || c.ImagePullPolicy != corev1.PullIfNotPresent {
+			t.Errorf("container %d = %s (%s), want %s", i, c.Image, c.ImagePullPolicy, images[i])
+		}
+	}
+	if spec.NodeSelector["gpu"] != "true" {
+		t.Errorf("NodeSelector = %v, want the session pod's", spec.NodeSelector)
+	}
+	if len(spec.ImagePullSecrets) != 1 || spec.ImagePullSecrets[0].Name != "private-creds" {
+		t.Errorf("ImagePullSecrets = %v", spec.ImagePullSecrets)
+	}
+}
+
+func TestPrePullLifecycle(t *testing.T) {
+	defer ResetClient()
+	fakeClient := setFakeClient(t)
+	ctx := context.Background()
+
+	pod := BuildPodSpec(DefaultPodConfig("prepull", "cad", "CAD", "cad:1"))
+	if err := ApplyPrePull(ctx, BuildPrePullDaemonSet("cad", pod)); err != nil {
+		t.Fatalf("ApplyPrePull() error = %v", err)
+	}
+	// Starting again replaces the running pre-pull
+	if err := ApplyPrePull(ctx, BuildPrePullDaemonSet("cad", pod)); err != nil {
+		t.Fatalf("ApplyPrePull() again error = %v", err)
+	}
+
+	nodePod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "prepull-node-1",
+			Namespace: "test-ns",
+			Labels:    map[string]string{AppLabelKey: "cad", ComponentLabelKey: PrePullComponent},
+		},
+		Spec: corev1.PodSpec{NodeName: "node-1"},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+			{Name: "pull-0", Image: "cad:1", ImageID: "docker-pullable://cad@sha256:abc"},
+			{Name: "pull-1", Image: "vnc:1", State: corev1.ContainerState{
+				Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "not found"},
+			}},
+		}},
+	}
+	if _, err := fakeClient.CoreV1().Pods("test-ns").Create(ctx, nodePod, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	states, err := ListPrePulls(ctx)
+	if err != nil {
+		t.Fatalf("ListPrePulls() error = %v", err)
+	}
+	if len(states) != 1 || len(states[0].Pods) != 1 {
+		t.Fatalf("ListPrePulls() = %+v, want one pre-pull with one pod", states)
+	}
+	if PrePullStartedAt(&states[0].DaemonSet).IsZero() {
+		t.Error("PrePullStartedAt() is zero")
+	}
+	pulled, pullErr := PrePullPodProgress(&states[0].Pods[0])
+	if pulled != 1 || pullErr != "vnc:1: ImagePullBackOff: not found" {
+		t.Errorf("PrePullPodProgress() = %d, %q", pulled, pullErr)
+	}
+
+	if err := DeletePrePull(ctx, PrePullDaemonSetName("cad")); err != nil {
+		t.Fatalf("DeletePrePull() error = %v", err)
+	}
+	if states, _ := ListPrePulls(ctx); len(states) != 0 {
+		t.Errorf("ListPrePulls() after delete = %+v", states)
+	}
+}
@@ -0,0 +1,221 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// displayContainers are the built-in containers that serve a session's
+// display. Their readiness probes pass once VNC or guacd accepts
+// connections.
+var displayContainers = map[string]bool{
+	"vnc-sidecar":     true,
+	"browser-sidecar": true,
+	"guacd-sidecar":   true,
+}
+
+// imagePullFailures are container waiting reasons that mean an image
+// cannot currently be pulled.
+var imagePullFailures = map[string]bool{
+	"ErrImagePull":     true,
+	"ImagePullBackOff": true,
+	"InvalidImageName": true,
+}
+
+// GetPodProgress reports what a starting session pod is waiting on, from
+// its status and events. It returns an empty substatus once the pod is
+// ready.
+func GetPodProgress(ctx context.Context, podName string) (db.SessionSubstatus, string, error) {
+	client, err := GetClient()
+	if err != nil {
+		return "", "", err
+	}
+
+	pod, err := GetPod(ctx, podName)
+	if err != nil {
+		return "", "", err
+	}
+	events, err := client.CoreV1().Events(GetNamespace()).List(ctx, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("involvedObject.kind=Pod,involvedObject.name=%s", podName),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to list pod events: %w", err)
+	}
+
+	substatus, detail := PodProgress(pod, events.Items)
+	return substatus, detail, nil
+}
+
+// PodProgress decides what a starting pod is waiting on. Stages are checked
+// in launch order: scheduling, image pulls, init containers, injected
+// sidecars, the app's extra containers, the app container, and finally the
+// display sidecar.
+func PodProgress(pod *corev1.Pod, events []corev1.Event) (db.SessionSubstatus, string) {
+	if !podScheduled(pod) {
+		detail := "waiting for a node"
+		for _, c := range pod.Status.Conditions {
+			if c.Type == corev1.PodScheduled && c.Message != "" {
+				detail = c.Message
+			}
+		}
+		return db.SubstatusScheduling, detail
+	}
+
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, s := range statuses {
+		if w := s.State.Waiting; w != nil && imagePullFailures[w.Reason] {
+			return db.SubstatusPullingImage, fmt.Sprintf("%s: %s: %s", s.Name, w.Reason, w.Message)
+		}
+	}
+	if image := pullingImage(events); image != "" {
+		return db.SubstatusPullingImage, "pulling image " + image
+	}
+
+	for _, s := range pod.Status.InitContainerStatuses {
+		if t := s.State.Terminated; !s.Ready && (t == nil || t.ExitCode != 0) {
+			if strings.HasPrefix(s.Name, InitContainerPrefix) {
+				return db.SubstatusInitializing, containerDetail(s)
+			}
+			return db.SubstatusStartingSidecars, containerDetail(s)
+		}
+	}
+
+	byName := map[string]corev1.ContainerStatus{}
+	for _, s := range pod.Status.ContainerStatuses {
+		byName[s.Name] = s
+	}
+	var extra, app, display []string
+	for _, c := range pod.Spec.Containers {
+		switch {
+		case displayContainers[c.Name]:
+			display = append(display, c.Name)
+		case strings.HasPrefix(c.Name, SidecarContainerPrefix):
+			if s := byName[c.Name]; !s.Ready {
+				return db.SubstatusStartingSidecars, containerDetail(s)
+			}
+		case strings.HasPrefix(c.Name, AppContainerPrefix):
+			extra = append(extra, c.Name)
+		default:
+			app = append(app, c.Name)
+		}
+	}
+	for _, name := range extra {
+		if s := byName[name]; !s.Ready {
+			return db.SubstatusStartingContainers, containerDetail(s)
+		}
+	}
+	for _, name := range app {
+		if s := byName[name]; !s.Ready {
+			return db.SubstatusStartingApp, containerDetail(s)
+		}
+	}
+	for _, name := range display {
+		if s := byName[name]; !s.Ready {
+			return db.SubstatusWaitingForDisplay, containerDetail(s)
+		}
+	}
+	return "", ""
+}
+
+func podScheduled(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodScheduled {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return pod.Spec.NodeName != ""
+}
+
+// pullingImage returns the image of the most recent pull that has started
+// but not finished, according to the pod's events.
+func pullingImage(events []corev1.Event) string {
+	sorted := append([]corev1.Event{}, events...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return eventTime(sorted[i]).Before(eventTime(sorted[j]))
+	})
+
+	var pulling []string
+	for _, e := range sorted {
+		image := quotedImage(e.Message)
+		if image == "" {
+			continue
+		}
+		switch e.Reason {
+		case "Pulling":
+			pulling = append(pulling, image)
+		case "Pulled", "Failed":
+			for i, p := range pulling {
+				if p == image {
+					pulling = append(pulling[:i], pulling[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+	if len(pulling) == 0 {
+		return ""
+	}
+	return pulling[len(pulling)-1]
+}
+
+func eventTime(e corev1.Event) time.Time {
+	if !e.LastTimestamp.IsZero() {
+		return e.LastTimestamp.Time
+	}
+	if !e.EventTime.IsZero() {
+		return e.EventTime.Time
+	}
+	return e.FirstTimestamp.Time
+}
+
+// quotedImage extracts the image name kubelet quotes in its pull events,
+// e.g. `Pulling image "nginx:latest"`.
+func quotedImage(message string) string {
+	start := strings.IndexByte(message, '"')
+	if start < 0 {
+		return ""
+	}
+	end := strings.IndexByte(message[start+1:], '"')
+	if end < 0 {
+		return ""
+	}
+	return message[start+1 : start+1+end]
+}
+
+// containerDetail describes why a container is not ready yet.
+func containerDetail(s corev1.ContainerStatus) string {
+	switch {
+	case s.Name == "":
+		return "waiting for container status"
+	case s.State.Waiting != nil && s.State.Waiting.Message != "":
+		return fmt.Sprintf("%s: %s: %s", s.Name, s.State.Waiting.Reason, s.State.Waiting.Message)
+	case s.State.Waiting != nil:
+		return fmt.Sprintf("%s: %s", s.Name, s.State.Waiting.Reason)
+	case s.State.Terminated != nil && s.State.Terminated.Message != "":
+		return fmt.Sprintf("%s: exited with code %d: %s", s.Name, s.State.Terminated.ExitCode, strings.TrimSpace(s.State.Terminated.Message))
+	case s.State.Terminated != nil:
+		return fmt.Sprintf("%s: exited with code %d", s.Name, s.State.Terminated.ExitCode)
+	case s.State.Running != nil:
+		return s.Name + ": running, not ready"
+	default:
+		return s.Name + ": starting"
+	}
+}
+
+// failedInitContainer describes the init container that stopped a failed
+// pod from starting, or returns "" if none did.
+func failedInitContainer(pod *corev1.Pod) string {
+	for _, s := range pod.Status.InitContainerStatuses {
+		if t := s.State.Terminated; t != nil && t.ExitCode != 0 {
+			return containerDetail(s)
+		}
+	}
+	return ""
+}
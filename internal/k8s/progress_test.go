@@ -0,0 +1,183 @@
+package k8s
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func progressPod(containers ...string) *corev1.Pod {
+	pod := &corev1.Pod{
+		Status: corev1.PodStatus{
+			Conditions: []corev1.PodCondition{{Type: corev1.PodScheduled, Status: corev1.ConditionTrue}},
+		},
+	}
+	for _, name := range containers {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: name})
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{
+			Name:  name,
+			Ready: true,
+			State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+		})
+	}
+	return pod
+}
+
+func setContainer(pod *corev1.Pod, name string, ready bool, state corev1.ContainerState) {
+	for i := range pod.Status.ContainerStatuses {
+		if pod.Status.ContainerStatuses[i].Name == name {
+			pod.Status.ContainerStatuses[i].Ready = ready
+			pod.Status.ContainerStatuses[i].State = state
+		}
+	}
+}
+
+func podEvent(reason, message string, at time.Time) corev1.Event {
+	return corev1.Event{Reason: reason, Message: message, LastTimestamp: metav1.NewTime(at)}
+}
+
+func TestPodProgress(t *testing.T) {
+	waiting := func(reason, message string) corev1.ContainerState {
+		return corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason, Message: message}}
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		pod        func() *corev1.Pod
+		events     []corev1.Event
+		want       db.SessionSubstatus
+		wantDetail string
+	}{
+		{
+			name: "unschedulable",
+			pod: func() *corev1.Pod {
+				p := progressPod("vnc-sidecar", "app")
+				p.Status.Conditions = []corev1.PodCondition{{
+					Type: corev1.PodScheduled, Status: corev1.ConditionFalse,
+					Message: "0/3 nodes are available: 3 Insufficient cpu.",
+				}}
+				return p
+			},
+			want:       db.SubstatusScheduling,
+			wantDetail: "Insufficient cpu",
+		},
+		{
+			name: "pulling image",
+			pod: func() *corev1.Pod {
+				p := progressPod("vnc-sidecar", "app")
+				setContainer(p, "app", false, waiting("ContainerCreating", ""))
+				return p
+			},
+			events: []corev1.Event{
+				podEvent("Pulled", `Container image "vnc:latest" already present on machine`, now.Add(-2*time.Second)),
+				podEvent("Pulling", `Pulling image "registry.example.com/cad:2026"`, now.Add(-time.Second)),
+			},
+			want:       db.SubstatusPullingImage,
+			wantDetail: "registry.example.com/cad:2026",
+		},
+		{
+			name: "image pull backoff",
+			pod: func() *corev1.Pod {
+				p := progressPod("vnc-sidecar", "app")
+				setContainer(p, "app", false, waiting("ImagePullBackOff", `Back-off pulling image "cad:missing"`))
+				return p
+			},
+			want:       db.SubstatusPullingImage,
+			wantDetail: "app: ImagePullBackOff",
+		},
+		{
+			name: "pull finished, sidecar starting",
+			pod: func() *corev1.Pod {
+				p := progressPod("vnc-sidecar", "app", "sidecar-vpn")
+				setContainer(p, "sidecar-vpn", false, corev1.ContainerState{Running: &corev1.ContainerStateRunning{}})
+				return p
+			},
+			events: []corev1.Event{
+				podEvent("Pulling", `Pulling image "vpn:1"`, now.Add(-2*time.Second)),
+				podEvent("Pulled", `Successfully pulled image "vpn:1" in 1.2s`, now.Add(-time.Second)),
+			},
+			want:       db.SubstatusStartingSidecars,
+			wantDetail: "sidecar-vpn: running, not ready",
+		},
+		{
+			name: "init container running",
+			pod: func() *corev1.Pod {
+				p := progressPod("vnc-sidecar", "app")
+				p.Status.InitContainerStatuses = []corev1.ContainerStatus{
+					{Name: "init-license", Ready: true, State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}},
+					{Name: "init-seed", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+				}
+				return p
+			},
+			want:       db.SubstatusInitializing,
+			wantDetail: "init-seed: running, not ready",
+		},
+		{
+			name: "init container failed",
+			pod: func() *corev1.Pod {
+				p := progressPod("vnc-sidecar", "app")
+				p.Status.InitContainerStatuses = []corev1.ContainerStatus{{
+					Name: "init-seed",
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+						ExitCode: 1, Reason: "Error", Message: "seed bucket not found\n",
+					}},
+				}}
+				return p
+			},
+			want:       db.SubstatusInitializing,
+			wantDetail: "init-seed: exited with code 1: seed bucket not found",
+		},
+		{
+			name: "app's database starting",
+			pod: func() *corev1.Pod {
+				p := progressPod("vnc-sidecar", "app", "app-db")
+				setContainer(p, "app-db", false, corev1.ContainerState{Running: &corev1.ContainerStateRunning{}})
+				setContainer(p, "app", false, corev1.ContainerState{Running: &corev1.ContainerStateRunning{}})
+				return p
+			},
+			want:       db.SubstatusStartingContainers,
+			wantDetail: "app-db: running, not ready",
+		},
+		{
+			name: "app crash looping",
+			pod: func() *corev1.Pod {
+				p := progressPod("vnc-sidecar", "app")
+				setContainer(p, "app", false, waiting("CrashLoopBackOff", ""))
+				return p
+			},
+			want:       db.SubstatusStartingApp,
+			wantDetail: "app: CrashLoopBackOff",
+		},
+		{
+			name: "waiting for VNC",
+			pod: func() *corev1.Pod {
+				p := progressPod("vnc-sidecar", "app")
+				setContainer(p, "vnc-sidecar", false, corev1.ContainerState{Running: &corev1.ContainerStateRunning{}})
+				return p
+			},
+			want: db.SubstatusWaitingForDisplay,
+		},
+		{
+			name: "ready",
+			pod:  func() *corev1.Pod { return progressPod("vnc-sidecar", "app") },
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, detail := PodProgress(tt.pod(), tt.events)
+			if got != tt.want {
+				t.Errorf("PodProgress() = %q (%s), want %q", got, detail, tt.want)
+			}
+			if !strings.Contains(detail, tt.wantDetail) {
+				t.Errorf("PodProgress() detail = %q, want it to contain %q", detail, tt.wantDetail)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package k8s
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ProjectDriveName is the name of the project drive in a session pod
+	ProjectDriveName = "project-drive"
+
+	// ProjectDriveComponent is the component label value of project drive
+	// claims
+	ProjectDriveComponent = "project-drive"
+
+	// DefaultProjectDriveSize is the capacity requested for a project
+	// drive that sets no size
+	DefaultProjectDriveSize = "10Gi"
+
+	// projectDriveAnnotation records the project a drive claim belongs to.
+	projectDriveAnnotation = "sortie.io/project-id"
+)
+
+// ValidateProjectDrive checks that a project's drive can be created and
+// mounted. A nil drive is valid. The rules are those of home volumes.
+func ValidateProjectDrive(d *db.ProjectDrive) error {
+	if d == nil {
+		return nil
+	}
+	return ValidateHomeVolume(&db.HomeVolume{MountPath: d.MountPath, Size: d.Size, StorageClass: d.StorageClass})
+}
+
+// ProjectDriveClaimName returns the name of a project's drive claim.
+func ProjectDriveClaimName(projectID string) string {
+	sum := sha256.Sum256([]byte(projectID))
+	return "sortie-project-" + hex.EncodeToString(sum[:8])
+}
+
+// BuildProjectDriveClaim creates the PersistentVolumeClaim for a project's
+// drive. Sessions of the project may run on different nodes at once, so
+// the claim is ReadWriteMany. The drive is expected to have passed
+// ValidateProjectDrive.
+func BuildProjectDriveClaim(projectID string, d *db.ProjectDrive) *corev1.PersistentVolumeClaim {
+	size := d.Size
+	if size == "" {
+		size = DefaultProjectDriveSize
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ProjectDriveClaimName(projectID),
+			Namespace: GetNamespace(),
+			Labels: map[string]string{
+				ComponentLabelKey: ProjectDriveComponent,
+			},
+			Annotations: map[string]string{
+				projectDriveAnnotation: projectID,
+				"sortie.io/created-at": time.Now().UTC().Format(time.RFC3339),
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse(size),
+				},
+			},
+		},
+	}
+	if d.StorageClass != "" {
+		storageClass := d.StorageClass
+		pvc.Spec.StorageClassName = &storageClass
+	}
+	return pvc
+}
+
+// applyProjectDrive mounts a project drive claim in the pod's app
+// container.
+func applyProjectDrive(spec *corev1.PodSpec, claimName, mountPath string) {
+	if claimName == "" {
+		return
+	}
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name: ProjectDriveName,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+		},
+	})
+	for i := range spec.Containers {
+		if spec.Containers[i].Name == "app" {
+			spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts,
+				corev1.VolumeMount{Name: ProjectDriveName, MountPath: mountPath})
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/db"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestValidateProjectDrive(t *testing.T) {
+	tests := []struct {
+		name    string
+		drive   *db.ProjectDrive
+		wantErr bool
+	}{
+		{"nil", nil, false},
+		{"path only", &db.ProjectDrive{MountPath: "/data"}, false},
+		{"all fields", &db.ProjectDrive{MountPath: "/data", Size: "100Gi", StorageClass: "nfs"}, false},
+		{"relative path", &db.ProjectDrive{MountPath: "data"}, true},
+		{"workspace", &db.ProjectDrive{MountPath: WorkspaceMountPath}, true},
+		{"bad size", &db.ProjectDrive{MountPath: "/data", Size: "lots"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateProjectDrive(tt.drive); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateProjectDrive() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProjectDriveClaim(t *testing.T) {
+	defer ResetClient()
+	setFakeClient(t)
+
+	pvc, err := EnsurePVC(context.Background(), BuildProjectDriveClaim("project-1", &db.ProjectDrive{MountPath: "/data", StorageClass: "nfs"}))
+	if err != nil {
+		t.Fatalf("EnsurePVC() error = %v", err)
+	}
+	if pvc.Name != ProjectDriveClaimName("project-1") || pvc.Annotations[projectDriveAnnotation] != "project-1" {
+		t.Errorf("claim = %s, annotations %v", pvc.Name, pvc.Annotations)
+	}
+	if len(pvc.Spec.AccessModes) != 1 || pvc.Spec.AccessModes[0] != corev1.ReadWriteMany {
+		t.Errorf("access modes = %v, want ReadWriteMany", pvc.Spec.AccessModes)
+	}
+	if q := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; q.String() != DefaultProjectDriveSize {
+		t.Errorf("requested %s, want the default %s", q.String(), DefaultProjectDriveSize)
+	}
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName != "nfs" {
+		t.Errorf("storage class = %v, want nfs", pvc.Spec.StorageClassName)
+	}
+}
+
+func TestBuildPodSpec_ProjectDrive(t *testing.T) {
+	config := DefaultPodConfig("sess-1", "app-1", "App", "myapp:v1")
+	config.ProjectDriveClaim = "sortie-project-abc"
+	config.ProjectDriveMountPath = "/data"
+
+	for _, pod := range []*corev1.Pod{BuildPodSpec(config), BuildWebProxyPodSpec(config), BuildWindowsPodSpec(config)} {
+		var claim string
+		for _, v := range pod.Spec.Volumes {
+			if v.Name == ProjectDriveName && v.PersistentVolumeClaim != nil {
+				claim = v.PersistentVolumeClaim.ClaimName
+			}
+		}
+		if claim != "sortie-project-abc" {
+			t.Errorf("project drive claim = %q", claim)
+		}
+		for _, c := range pod.Spec.Containers {
+			mounted := false
+			for _, m := range c.VolumeMounts {
+				if m.Name == ProjectDriveName && m.MountPath == "/data" {
+					mounted = true
+				}
+			}
+			if mounted != (c.Name == "app") {
+				t.Errorf("container %s mounts project drive = %v", c.Name, mounted)
+			}
+		}
+	}
+}
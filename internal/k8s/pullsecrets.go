@@ -0,0 +1,126 @@
+package k8s
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ValidateImagePullSecret checks that name can reference a Secret. An
+// empty name is valid and pulls images without credentials.
+func ValidateImagePullSecret(name string) error {
+	if name == "" {
+		return nil
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return fmt.Errorf("invalid image pull secret %q: %s", name, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// applyImagePullSecret has the kubelet pull the pod's images, including
+// its sidecars', with the credentials in the named Secret. The Secret must
+// be in the pod's namespace.
+func applyImagePullSecret(spec *corev1.PodSpec, name string) {
+	if name == "" {
+		return
+	}
+	spec.ImagePullSecrets = append(spec.ImagePullSecrets, corev1.LocalObjectReference{Name: name})
+}
+
+// RegistrySecretComponent is the component label value of the image pull
+// Secrets written from registry credentials.
+const RegistrySecretComponent = "registry-credentials"
+
+// dockerConfigAuth is one registry's entry in a .dockerconfigjson.
+type dockerConfigAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email,omitempty"`
+	Auth     string `json:"auth"`
+}
+
+// BuildRegistrySecret creates the kubernetes.io/dockerconfigjson Secret
+// that logs in to server with username and password. The name must pass
+// ValidateImagePullSecret.
+func BuildRegistrySecret(name, server, username, password, email string) (*corev1.Secret, error) {
+	config, err := json.Marshal(map[string]map[string]dockerConfigAuth{
+		"auths": {
+			server: {
+				Username: username,
+				Password: password,
+				Email:    email,
+				Auth:     base64.StdEncoding.EncodeToString([]byte(username + ":" + password)),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: GetNamespace(),
+			Labels: map[string]string{
+				ComponentLabelKey: RegistrySecretComponent,
+			},
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: config,
+		},
+	}, nil
+}
+
+// ApplyRegistrySecret creates a registry Secret, or replaces the data of
+// the one of the same name. A Secret of that name that was not written
+// from a registry credential is left alone and reported as an error.
+func ApplyRegistrySecret(ctx context.Context, secret *corev1.Secret) error {
+	client, err := GetClient()
+	if err != nil {
+		return err
+	}
+
+	secrets := client.CoreV1().Secrets(GetNamespace())
+	existing, err := secrets.Get(ctx, secret.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = secrets.Create(ctx, secret, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if existing.Labels[ComponentLabelKey] != RegistrySecretComponent {
+		return fmt.Errorf("secret %s exists and is not managed by sortie", secret.Name)
+	}
+	existing.Type = secret.Type
+	existing.Data = secret.Data
+	_, err = secrets.Update(ctx, existing, metav1.UpdateOptions{})
+	return err
+}
+
+// DeleteRegistrySecret deletes a registry Secret by name. Secrets not
+// written from a registry credential are left alone.
+func DeleteRegistrySecret(ctx context.Context, name string) error {
+	client, err := GetClient()
+	if err != nil {
+		return err
+	}
+
+	secrets := client.CoreV1().Secrets(GetNamespace())
+	existing, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if existing.Labels[ComponentLabelKey] != RegistrySecretComponent {
+		return fmt.Errorf("secret %s is not managed by sortie", name)
+	}
+	return secrets.Delete(ctx, name, metav1.DeleteOptions{})
+}
@@ -0,0 +1,116 @@
+package k8s
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateImagePullSecret(t *testing.T) {
+	for _, name := range []string{"", "registry-creds", "ghcr.io.token"} {
+		if err := ValidateImagePullSecret(name); err != nil {
+			t.Errorf("ValidateImagePullSecret(%q) error = %v", name, err)
+		}
+	}
+	for _, name := range []string{"Registry", "creds/other", "-creds", strings.Repeat("a", 254)} {
+		if err := ValidateImagePullSecret(name); err == nil {
+			t.Errorf("ValidateImagePullSecret(%q) accepted an invalid name", name)
+		}
+	}
+}
+
+func TestBuildPodSpecs_WithImagePullSecret(t *testing.T) {
+	builders := map[string]func(*PodConfig) *corev1.Pod{
+		"standard":  BuildPodSpec,
+		"web proxy": BuildWebProxyPodSpec,
+		"windows":   BuildWindowsPodSpec,
+	}
+	for name, build := range builders {
+		t.Run(name, func(t *testing.T) {
+			config := DefaultPodConfig("sess-1", "app-1", "Test App", "registry.example.com/app:latest")
+			if secrets := build(config).Spec.ImagePullSecrets; secrets != nil {
+				t.Fatalf("pod without a pull secret has ImagePullSecrets %+v", secrets)
+			}
+
+			config.ImagePullSecret = "registry-creds"
+			secrets := build(config).Spec.ImagePullSecrets
+			if len(secrets) != 1 || secrets[0].Name != "registry-creds" {
+				t.Errorf("ImagePullSecrets = %+v, want registry-creds", secrets)
+			}
+		})
+	}
+}
+
+func TestApplyRegistrySecret(t *testing.T) {
+	defer ResetClient()
+	fakeClient := setFakeClient(t)
+	ctx := context.Background()
+
+	secret, err := BuildRegistrySecret("ghcr", "ghcr.io", "bot", "s3cret", "")
+	if err != nil {
+		t.Fatalf("BuildRegistrySecret() error = %v", err)
+	}
+	if err := ApplyRegistrySecret(ctx, secret); err != nil {
+		t.Fatalf("ApplyRegistrySecret() error = %v", err)
+	}
+
+	got, err := fakeClient.CoreV1().Secrets("test-ns").Get(ctx, "ghcr", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Type != corev1.SecretTypeDockerConfigJson {
+		t.Errorf("Type = %s, want %s", got.Type, corev1.SecretTypeDockerConfigJson)
+	}
+	var config struct {
+		Auths map[string]dockerConfigAuth `json:"auths"`
+	}
+	if err := json.Unmarshal(got.Data[corev1.DockerConfigJsonKey], &config); err != nil {
+		t.Fatalf("invalid .dockerconfigjson: %v", err)
+	}
+	auth := config.Auths["ghcr.io"]
+	if auth.Username != "bot" || auth.Password != "s3cret" || auth.Auth != base64.StdEncoding.EncodeToString([]byte("bot:s3cret")) {
+		t.Errorf("auths[ghcr.io] = %+v", auth)
+	}
+
+	// Applying again replaces the credentials
+	secret, _ = BuildRegistrySecret("ghcr", "ghcr.io", "bot", "rotated", "")
+	if err := ApplyRegistrySecret(ctx, secret); err != nil {
+		t.Fatalf("ApplyRegistrySecret() update error = %v", err)
+	}
+	got, _ = fakeClient.CoreV1().Secrets("test-ns").Get(ctx, "ghcr", metav1.GetOptions{})
+	if !strings.Contains(string(got.Data[corev1.DockerConfigJsonKey]), "rotated") {
+		t.Errorf("secret not updated: %s", got.Data[corev1.DockerConfigJsonKey])
+	}
+
+	if err := DeleteRegistrySecret(ctx, "ghcr"); err != nil {
+		t.Fatalf("DeleteRegistrySecret() error = %v", err)
+	}
+	if _, err := fakeClient.CoreV1().Secrets("test-ns").Get(ctx, "ghcr", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("secret still exists after delete: %v", err)
+	}
+}
+
+func TestApplyRegistrySecret_UnmanagedSecret(t *testing.T) {
+	defer ResetClient()
+	fakeClient := setFakeClient(t)
+	ctx := context.Background()
+
+	unmanaged := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "ghcr", Namespace: "test-ns"}}
+	if _, err := fakeClient.CoreV1().Secrets("test-ns").Create(ctx, unmanaged, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	secret, _ := BuildRegistrySecret("ghcr", "ghcr.io", "bot", "s3cret", "")
+	if err := ApplyRegistrySecret(ctx, secret); err == nil {
+		t.Error("ApplyRegistrySecret() overwrote a secret it does not manage")
+	}
+	if err := DeleteRegistrySecret(ctx, "ghcr"); err == nil {
+		t.Error("DeleteRegistrySecret() deleted a secret it does not manage")
+	}
+}
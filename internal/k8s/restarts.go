@@ -0,0 +1,113 @@
+package k8s
+
+import (
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// PodRestart reports that the containers of a session pod have restarted.
+type PodRestart struct {
+	PodName string
+	// Restarts is the total restart count of the pod's containers
+	Restarts int
+	// Detail names the container that restarted most and how it last
+	// exited, such as "app: CrashLoopBackOff, last exited with Error (exit code 1)"
+	Detail string
+}
+
+// restartHandlers are called by the pod cache's watch when session pods
+// restart.
+var restartHandlers struct {
+	mu   sync.RWMutex
+	next int
+	fns  map[int]func(PodRestart)
+}
+
+// OnPodRestart registers fn to be called from the pod cache's watch each
+// time the containers of a session pod restart, and once for each pod that
+// had already restarted when the cache loads it. fn is called on the
+// watch's goroutine, so it must not block for long. It returns a function
+// that unregisters fn. Nothing is reported while the pod cache is not
+// running.
+func OnPodRestart(fn func(PodRestart)) (remove func()) {
+	restartHandlers.mu.Lock()
+	defer restartHandlers.mu.Unlock()
+	if restartHandlers.fns == nil {
+		restartHandlers.fns = make(map[int]func(PodRestart))
+	}
+	id := restartHandlers.next
+	restartHandlers.next++
+	restartHandlers.fns[id] = fn
+	return func() {
+		restartHandlers.mu.Lock()
+		defer restartHandlers.mu.Unlock()
+		delete(restartHandlers.fns, id)
+	}
+}
+
+// restartEventHandler reports pod restarts to the registered handlers.
+var restartEventHandler = cache.ResourceEventHandlerFuncs{
+	AddFunc: func(obj interface{}) {
+		if pod, ok := obj.(*corev1.Pod); ok {
+			notifyRestarts(0, pod)
+		}
+	},
+	UpdateFunc: func(oldObj, newObj interface{}) {
+		old, ok := oldObj.(*corev1.Pod)
+		if !ok {
+			return
+		}
+		if pod, ok := newObj.(*corev1.Pod); ok {
+			restarts, _ := podRestarts(old)
+			notifyRestarts(restarts, pod)
+		}
+	},
+}
+
+// notifyRestarts calls the restart handlers if pod has restarted more than
+// before times.
+func notifyRestarts(before int, pod *corev1.Pod) {
+	restarts, detail := podRestarts(pod)
+	if restarts <= before {
+		return
+	}
+	restartHandlers.mu.RLock()
+	defer restartHandlers.mu.RUnlock()
+	for _, fn := range restartHandlers.fns {
+		fn(PodRestart{PodName: pod.Name, Restarts: restarts, Detail: detail})
+	}
+}
+
+// podRestarts returns the total restart count of a pod's containers and
+// init containers, and a description of the one that restarted most.
+func podRestarts(pod *corev1.Pod) (int, string) {
+	total := 0
+	var worst *corev1.ContainerStatus
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for i := range statuses {
+		s := &statuses[i]
+		total += int(s.RestartCount)
+		if s.RestartCount > 0 && (worst == nil || s.RestartCount > worst.RestartCount) {
+			worst = s
+		}
+	}
+	if worst == nil {
+		return 0, ""
+	}
+
+	detail := worst.Name + ": restarted"
+	if w := worst.State.Waiting; w != nil && w.Reason != "" {
+		detail = worst.Name + ": " + w.Reason
+	}
+	if t := worst.LastTerminationState.Terminated; t != nil {
+		reason := t.Reason
+		if reason == "" {
+			reason = "exit"
+		}
+		detail += fmt.Sprintf(", last exited with %s (exit code %d)", reason, t.ExitCode)
+	}
+	return total, detail
+}
@@ -0,0 +1,95 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodRestarts(t *testing.T) {
+	pod := &corev1.Pod{Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+		{Name: "vnc-sidecar", RestartCount: 1},
+		{
+			Name:                 "app",
+			RestartCount:         4,
+			State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+			LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 127}},
+		},
+	}}}
+
+	restarts, detail := podRestarts(pod)
+	if restarts != 5 {
+		t.Errorf("restarts = %d, want 5", restarts)
+	}
+	if want := "app: CrashLoopBackOff, last exited with Error (exit code 127)"; detail != want {
+		t.Errorf("detail = %q, want %q", detail, want)
+	}
+
+	if restarts, detail := podRestarts(&corev1.Pod{}); restarts != 0 || detail != "" {
+		t.Errorf("podRestarts() of a new pod = %d, %q; want 0, \"\"", restarts, detail)
+	}
+}
+
+func TestOnPodRestart(t *testing.T) {
+	defer ResetClient()
+	fakeClient := setFakeClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	restarts := make(chan PodRestart, 10)
+	remove := OnPodRestart(func(r PodRestart) { restarts <- r })
+	defer remove()
+
+	pod := BuildPodSpec(DefaultPodConfig("sess-crashing", "app-1", "App", "myapp:v1"))
+	if _, err := CreatePod(ctx, pod); err != nil {
+		t.Fatalf("CreatePod() error = %v", err)
+	}
+	if err := StartPodCache(ctx); err != nil {
+		t.Fatalf("StartPodCache() error = %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := cachedPod(pod.Name); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("pod cache did not sync")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	setRestarts := func(n int32) {
+		t.Helper()
+		current, err := fakeClient.CoreV1().Pods(GetNamespace()).Get(ctx, pod.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		current.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "app", RestartCount: n}}
+		if _, err := fakeClient.CoreV1().Pods(GetNamespace()).UpdateStatus(ctx, current, metav1.UpdateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	setRestarts(2)
+	select {
+	case r := <-restarts:
+		if r.PodName != pod.Name || r.Restarts != 2 || r.Detail != "app: restarted" {
+			t.Errorf("reported %+v, want 2 restarts of %s", r, pod.Name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("restart was not reported")
+	}
+
+	// Status changes without new restarts are not reported
+	setRestarts(2)
+	remove()
+	setRestarts(3)
+	select {
+	case r := <-restarts:
+		t.Errorf("reported %+v after the handler was removed or without a restart", r)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
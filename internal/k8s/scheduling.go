@@ -0,0 +1,202 @@
+package k8s
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rjsadow/sortie/internal/db"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ValidateScheduling checks that an app's scheduling settings can be
+// rendered into a pod spec. A nil config is valid and lets pods run on any
+// node.
+func ValidateScheduling(c *db.Scheduling) error {
+	if c == nil {
+		return nil
+	}
+	for key, value := range c.NodeSelector {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid node selector key %q: %s", key, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("invalid node selector value %q: %s", value, strings.Join(errs, "; "))
+		}
+	}
+	for _, t := range c.Tolerations {
+		if err := validateToleration(t); err != nil {
+			return err
+		}
+	}
+	if a := c.NodeAffinity; a != nil {
+		for _, term := range a.Required {
+			if err := validateNodeSelectorTerm(term); err != nil {
+				return err
+			}
+		}
+		for _, p := range a.Preferred {
+			if p.Weight < 1 || p.Weight > 100 {
+				return fmt.Errorf("invalid preferred node affinity weight %d: must be between 1 and 100", p.Weight)
+			}
+			if err := validateNodeSelectorTerm(p.Term); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateToleration(t db.Toleration) error {
+	if t.Key != "" {
+		if errs := validation.IsQualifiedName(t.Key); len(errs) > 0 {
+			return fmt.Errorf("invalid toleration key %q: %s", t.Key, strings.Join(errs, "; "))
+		}
+	}
+	switch corev1.TolerationOperator(t.Operator) {
+	case "", corev1.TolerationOpEqual:
+		if t.Key == "" {
+			return fmt.Errorf("toleration with operator Equal requires a key")
+		}
+	case corev1.TolerationOpExists:
+		if t.Value != "" {
+			return fmt.Errorf("toleration %q with operator Exists must not have a value", t.Key)
+		}
+	default:
+		return fmt.Errorf("invalid toleration operator %q: must be Equal or Exists", t.Operator)
+	}
+	switch corev1.TaintEffect(t.Effect) {
+	case "", corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+	default:
+		return fmt.Errorf("invalid toleration effect %q: must be NoSchedule, PreferNoSchedule or NoExecute", t.Effect)
+	}
+	if t.TolerationSeconds != nil && corev1.TaintEffect(t.Effect) != corev1.TaintEffectNoExecute {
+		return fmt.Errorf("toleration %q sets toleration_seconds, which requires effect NoExecute", t.Key)
+	}
+	return nil
+}
+
+func validateNodeSelectorTerm(term db.NodeSelectorTerm) error {
+	if len(term.MatchExpressions) == 0 {
+		return fmt.Errorf("node affinity term has no match expressions")
+	}
+	for _, r := range term.MatchExpressions {
+		if errs := validation.IsQualifiedName(r.Key); len(errs) > 0 {
+			return fmt.Errorf("invalid node affinity key %q: %s", r.Key, strings.Join(errs, "; "))
+		}
+		switch corev1.NodeSelectorOperator(r.Operator) {
+		case corev1.NodeSelectorOpIn, corev1.NodeSelectorOpNotIn:
+			if len(r.Values) == 0 {
+				return fmt.Errorf("node affinity expression on %q with operator %s requires values", r.Key, r.Operator)
+			}
+		case corev1.NodeSelectorOpExists, corev1.NodeSelectorOpDoesNotExist:
+			if len(r.Values) > 0 {
+				return fmt.Errorf("node affinity expression on %q with operator %s must not have values", r.Key, r.Operator)
+			}
+		case corev1.NodeSelectorOpGt, corev1.NodeSelectorOpLt:
+			if len(r.Values) != 1 {
+				return fmt.Errorf("node affinity expression on %q with operator %s requires one value", r.Key, r.Operator)
+			}
+			if _, err := strconv.ParseInt(r.Values[0], 10, 64); err != nil {
+				return fmt.Errorf("node affinity expression on %q with operator %s requires an integer value", r.Key, r.Operator)
+			}
+		default:
+			return fmt.Errorf("invalid node affinity operator %q: must be In, NotIn, Exists, DoesNotExist, Gt or Lt", r.Operator)
+		}
+	}
+	return nil
+}
+
+// applyScheduling renders an app's scheduling settings into a pod spec.
+// The config is expected to have passed ValidateScheduling.
+func applyScheduling(spec *corev1.PodSpec, c *db.Scheduling) {
+	if c == nil {
+		return
+	}
+	if len(c.NodeSelector) > 0 {
+		spec.NodeSelector = c.NodeSelector
+	}
+	for _, t := range c.Tolerations {
+		spec.Tolerations = append(spec.Tolerations, corev1.Toleration{
+			Key:               t.Key,
+			Operator:          corev1.TolerationOperator(t.Operator),
+			Value:             t.Value,
+			Effect:            corev1.TaintEffect(t.Effect),
+			TolerationSeconds: t.TolerationSeconds,
+		})
+	}
+	a := c.NodeAffinity
+	if a == nil || (len(a.Required) == 0 && len(a.Preferred) == 0) {
+		return
+	}
+	affinity := &corev1.NodeAffinity{}
+	if len(a.Required) > 0 {
+		affinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{}
+		for _, term := range a.Required {
+			affinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms = append(
+				affinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms, nodeSelectorTerm(term))
+		}
+	}
+	for _, p := range a.Preferred {
+		affinity.PreferredDuringSchedulingIgnoredDuringExecution = append(
+			affinity.PreferredDuringSchedulingIgnoredDuringExecution,
+			corev1.PreferredSchedulingTerm{Weight: p.Weight, Preference: nodeSelectorTerm(p.Term)})
+	}
+	spec.Affinity = &corev1.Affinity{NodeAffinity: affinity}
+}
+
+func nodeSelectorTerm(term db.NodeSelectorTerm) corev1.NodeSelectorTerm {
+	var t corev1.NodeSelectorTerm
+	for _, r := range term.MatchExpressions {
+		t.MatchExpressions = append(t.MatchExpressions, corev1.NodeSelectorRequirement{
+			Key:      r.Key,
+			Operator: corev1.NodeSelectorOperator(r.Operator),
+			Values:   r.Values,
+		})
+	}
+	return t
+}
+
+// nodeSelectorOperators maps node affinity operators to label selector
+// operators, which match the same way.
+var nodeSelectorOperators = map[corev1.NodeSelectorOperator]selection.Operator{
+	corev1.NodeSelectorOpIn:           selection.In,
+	corev1.NodeSelectorOpNotIn:        selection.NotIn,
+	corev1.NodeSelectorOpExists:       selection.Exists,
+	corev1.NodeSelectorOpDoesNotExist: selection.DoesNotExist,
+	corev1.NodeSelectorOpGt:           selection.GreaterThan,
+	corev1.NodeSelectorOpLt:           selection.LessThan,
+}
+
+// matchesRequiredAffinity reports whether the node's labels match one of
+// the pod's required node affinity terms, if it has any. Terms on node
+// fields are not checked.
+func matchesRequiredAffinity(node *corev1.Node, pod *corev1.Pod) bool {
+	if pod.Spec.Affinity == nil || pod.Spec.Affinity.NodeAffinity == nil ||
+		pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return true
+	}
+	nodeLabels := labels.Set(node.Labels)
+	for _, term := range pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		if len(term.MatchExpressions) == 0 {
+			continue
+		}
+		selector := labels.NewSelector()
+		valid := true
+		for _, r := range term.MatchExpressions {
+			req, err := labels.NewRequirement(r.Key, nodeSelectorOperators[r.Operator], r.Values)
+			if err != nil {
+				valid = false
+				break
+			}
+			selector = selector.Add(*req)
+		}
+		if valid && selector.Matches(nodeLabels) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package k8s
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/db"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestValidateScheduling(t *testing.T) {
+	seconds := int64(300)
+	tests := []struct {
+		name    string
+		config  *db.Scheduling
+		wantErr string
+	}{
+		{"nil", nil, ""},
+		{"empty", &db.Scheduling{}, ""},
+		{"valid", &db.Scheduling{
+			NodeSelector: map[string]string{"sortie.io/pool": "gpu"},
+			Tolerations: []db.Toleration{
+				{Key: "nvidia.com/gpu", Operator: "Exists", Effect: "NoSchedule"},
+				{Key: "dedicated", Value: "cad", Effect: "NoExecute", TolerationSeconds: &seconds},
+			},
+			NodeAffinity: &db.NodeAffinity{
+				Required: []db.NodeSelectorTerm{{MatchExpressions: []db.NodeSelectorRequirement{
+					{Key: "node.kubernetes.io/instance-type", Operator: "In", Values: []string{"g5.xlarge", "g5.2xlarge"}},
+				}}},
+				Preferred: []db.WeightedNodeSelectorTerm{{Weight: 50, Term: db.NodeSelectorTerm{MatchExpressions: []db.NodeSelectorRequirement{
+					{Key: "gpu-memory-gib", Operator: "Gt", Values: []string{"16"}},
+				}}}},
+			},
+		}, ""},
+		{"bad selector key", &db.Scheduling{NodeSelector: map[string]string{"bad key": "x"}}, "invalid node selector key"},
+		{"bad selector value", &db.Scheduling{NodeSelector: map[string]string{"pool": "not valid!"}}, "invalid node selector value"},
+		{"equal without key", &db.Scheduling{Tolerations: []db.Toleration{{Value: "x"}}}, "requires a key"},
+		{"exists with value", &db.Scheduling{Tolerations: []db.Toleration{{Key: "gpu", Operator: "Exists", Value: "x"}}}, "must not have a value"},
+		{"unknown operator", &db.Scheduling{Tolerations: []db.Toleration{{Key: "gpu", Operator: "In"}}}, "invalid toleration operator"},
+		{"unknown effect", &db.Scheduling{Tolerations: []db.Toleration{{Key: "gpu", Effect: "Evict"}}}, "invalid toleration effect"},
+		{"seconds without NoExecute", &db.Scheduling{Tolerations: []db.Toleration{{Key: "gpu", Effect: "NoSchedule", TolerationSeconds: &seconds}}}, "requires effect NoExecute"},
+		{"empty term", &db.Scheduling{NodeAffinity: &db.NodeAffinity{Required: []db.NodeSelectorTerm{{}}}}, "no match expressions"},
+		{"in without values", &db.Scheduling{NodeAffinity: &db.NodeAffinity{Required: []db.NodeSelectorTerm{{MatchExpressions: []db.NodeSelectorRequirement{
+			{Key: "pool", Operator: "In"},
+		}}}}}, "requires values"},
+		{"exists with values", &db.Scheduling{NodeAffinity: &db.NodeAffinity{Required: []db.NodeSelectorTerm{{MatchExpressions: []db.NodeSelectorRequirement{
+			{Key: "pool", Operator: "Exists", Values: []string{"gpu"}},
+		}}}}}, "must not have values"},
+		{"gt not integer", &db.Scheduling{NodeAffinity: &db.NodeAffinity{Required: []db.NodeSelectorTerm{{MatchExpressions: []db.NodeSelectorRequirement{
+			{Key: "cores", Operator: "Gt", Values: []string{"many"}},
+		}}}}}, "requires an integer value"},
+		{"unknown affinity operator", &db.Scheduling{NodeAffinity: &db.NodeAffinity{Required: []db.NodeSelectorTerm{{MatchExpressions: []db.NodeSelectorRequirement{
+			{Key: "pool", Operator: "Equals", Values: []string{"gpu"}},
+		}}}}}, "invalid node affinity operator"},
+		{"bad weight", &db.Scheduling{NodeAffinity: &db.NodeAffinity{Preferred: []db.WeightedNodeSelectorTerm{{Weight: 0, Term: db.NodeSelectorTerm{MatchExpressions: []db.NodeSelectorRequirement{
+			{Key: "pool", Operator: "Exists"},
+		}}}}}}, "weight 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateScheduling(tt.config)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateScheduling() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateScheduling() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildPodSpecs_WithScheduling(t *testing.T) {
+	scheduling := &db.Scheduling{
+		NodeSelector: map[string]string{"sortie.io/pool": "gpu"},
+		Tolerations:  []db.Toleration{{Key: "nvidia.com/gpu", Operator: "Exists", Effect: "NoSchedule"}},
+		NodeAffinity: &db.NodeAffinity{
+			Required: []db.NodeSelectorTerm{{MatchExpressions: []db.NodeSelectorRequirement{
+				{Key: "topology.kubernetes.io/zone", Operator: "In", Values: []string{"us-east-1a"}},
+			}}},
+			Preferred: []db.WeightedNodeSelectorTerm{{Weight: 10, Term: db.NodeSelectorTerm{MatchExpressions: []db.NodeSelectorRequirement{
+				{Key: "spot", Operator: "DoesNotExist"},
+			}}}},
+		},
+	}
+
+	builders := map[string]func(*PodConfig) *corev1.Pod{
+		"standard":  BuildPodSpec,
+		"web proxy": BuildWebProxyPodSpec,
+		"windows":   BuildWindowsPodSpec,
+	}
+	for name, build := range builders {
+		t.Run(name, func(t *testing.T) {
+			config := DefaultPodConfig("sess-1", "app-1", "Test App", "ubuntu:latest")
+			pod := build(config)
+			if pod.Spec.NodeSelector != nil || pod.Spec.Tolerations != nil || pod.Spec.Affinity != nil {
+				t.Fatalf("pod without scheduling should run anywhere, got %+v", pod.Spec)
+			}
+
+			config.Scheduling = scheduling
+			spec := build(config).Spec
+			if spec.NodeSelector["sortie.io/pool"] != "gpu" {
+				t.Errorf("NodeSelector = %v", spec.NodeSelector)
+			}
+			if len(spec.Tolerations) != 1 || spec.Tolerations[0].Operator != corev1.TolerationOpExists {
+				t.Errorf("Tolerations = %+v", spec.Tolerations)
+			}
+			if spec.Affinity == nil || spec.Affinity.NodeAffinity == nil {
+				t.Fatalf("Affinity = %+v", spec.Affinity)
+			}
+			required := spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+			if required == nil || len(required.NodeSelectorTerms) != 1 ||
+				required.NodeSelectorTerms[0].MatchExpressions[0].Values[0] != "us-east-1a" {
+				t.Errorf("required node affinity = %+v", required)
+			}
+			preferred := spec.Affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution
+			if len(preferred) != 1 || preferred[0].Weight != 10 ||
+				preferred[0].Preference.MatchExpressions[0].Operator != corev1.NodeSelectorOpDoesNotExist {
+				t.Errorf("preferred node affinity = %+v", preferred)
+			}
+		})
+	}
+}
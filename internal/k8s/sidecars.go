@@ -0,0 +1,146 @@
+package k8s
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rjsadow/sortie/internal/plugins"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// SidecarContainerPrefix is prepended to sidecar names to form their
+// container names, keeping them apart from the built-in containers.
+const SidecarContainerPrefix = "sidecar-"
+
+// MaxSidecarNameLength keeps prefixed container names within the 63
+// character limit Kubernetes places on them.
+const MaxSidecarNameLength = 63 - len(SidecarContainerPrefix)
+
+var (
+	sidecarNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	envVarNamePattern  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// reservedSidecarPorts are the ports used by the built-in session containers
+// (jlesage web UI, VNC, websockify, RDP and guacd).
+var reservedSidecarPorts = map[int]bool{5800: true, 5900: true, 6080: true, 3389: true, 4822: true}
+
+// ValidateSidecar checks that a sidecar can be rendered into a pod: its name
+// is a DNS label, its image is set, and its env names, ports and resource
+// quantities are well formed.
+func ValidateSidecar(sc plugins.Sidecar) error {
+	if len(sc.Name) > MaxSidecarNameLength || !sidecarNamePattern.MatchString(sc.Name) {
+		return fmt.Errorf("invalid sidecar name %q: must be a lowercase DNS label of at most %d characters", sc.Name, MaxSidecarNameLength)
+	}
+	if sc.Image == "" || strings.ContainsAny(sc.Image, " \t\r\n") {
+		return fmt.Errorf("sidecar %s: invalid image %q", sc.Name, sc.Image)
+	}
+	for key := range sc.Env {
+		if !envVarNamePattern.MatchString(key) {
+			return fmt.Errorf("sidecar %s: invalid environment variable name %q", sc.Name, key)
+		}
+	}
+	seen := map[int]bool{}
+	for _, port := range sc.Ports {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("sidecar %s: invalid port %d", sc.Name, port)
+		}
+		if reservedSidecarPorts[port] {
+			return fmt.Errorf("sidecar %s: port %d is reserved for session containers", sc.Name, port)
+		}
+		if seen[port] {
+			return fmt.Errorf("sidecar %s: duplicate port %d", sc.Name, port)
+		}
+		seen[port] = true
+	}
+	if r := sc.Resources; r != nil {
+		for field, value := range map[string]string{
+			"cpu_request":    r.CPURequest,
+			"cpu_limit":      r.CPULimit,
+			"memory_request": r.MemoryRequest,
+			"memory_limit":   r.MemoryLimit,
+		} {
+			if value == "" {
+				continue
+			}
+			if _, err := resource.ParseQuantity(value); err != nil {
+				return fmt.Errorf("sidecar %s: invalid %s %q", sc.Name, field, value)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateSidecars validates each sidecar and checks that names and ports
+// are unique across them.
+func ValidateSidecars(sidecars []plugins.Sidecar) error {
+	names := map[string]bool{}
+	ports := map[int]string{}
+	for _, sc := range sidecars {
+		if err := ValidateSidecar(sc); err != nil {
+			return err
+		}
+		if names[sc.Name] {
+			return fmt.Errorf("duplicate sidecar %q", sc.Name)
+		}
+		names[sc.Name] = true
+		for _, port := range sc.Ports {
+			if other, ok := ports[port]; ok {
+				return fmt.Errorf("sidecars %s and %s both use port %d", other, sc.Name, port)
+			}
+			ports[port] = sc.Name
+		}
+	}
+	return nil
+}
+
+// buildSidecarContainers renders sidecars as pod containers. Sidecars are
+// expected to have passed ValidateSidecars; unparseable resource quantities
+// are left unset.
+func buildSidecarContainers(sidecars []plugins.Sidecar) []corev1.Container {
+	var containers []corev1.Container
+	for _, sc := range sidecars {
+		c := corev1.Container{
+			Name:            SidecarContainerPrefix + sc.Name,
+			Image:           sc.Image,
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Command:         sc.Command,
+			Args:            sc.Args,
+		}
+		for key, value := range sc.Env {
+			c.Env = append(c.Env, corev1.EnvVar{Name: key, Value: value})
+		}
+		for _, port := range sc.Ports {
+			c.Ports = append(c.Ports, corev1.ContainerPort{ContainerPort: int32(port), Protocol: corev1.ProtocolTCP})
+		}
+		if r := sc.Resources; r != nil {
+			c.Resources.Limits = quantities(r.CPULimit, r.MemoryLimit)
+			c.Resources.Requests = quantities(r.CPURequest, r.MemoryRequest)
+		}
+		if sc.MountWorkspace {
+			c.VolumeMounts = []corev1.VolumeMount{
+				{Name: WorkspaceVolumeName, MountPath: WorkspaceMountPath},
+			}
+		}
+		containers = append(containers, c)
+	}
+	return containers
+}
+
+// quantities builds a resource list from CPU and memory values, skipping
+// empty or invalid ones. It returns nil when neither is set.
+func quantities(cpu, memory string) corev1.ResourceList {
+	list := corev1.ResourceList{}
+	if q, err := resource.ParseQuantity(cpu); cpu != "" && err == nil {
+		list[corev1.ResourceCPU] = q
+	}
+	if q, err := resource.ParseQuantity(memory); memory != "" && err == nil {
+		list[corev1.ResourceMemory] = q
+	}
+	if len(list) == 0 {
+		return nil
+	}
+	return list
+}
@@ -0,0 +1,124 @@
+package k8s
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/plugins"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestValidateSidecar(t *testing.T) {
+	valid := plugins.Sidecar{
+		Name:      "vpn",
+		Image:     "example.com/vpn:1.0",
+		Env:       map[string]string{"VPN_SERVER": "vpn.example.com"},
+		Ports:     []int{1080},
+		Resources: &plugins.ResourceLimits{CPULimit: "100m", MemoryLimit: "64Mi"},
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(sc *plugins.Sidecar)
+		wantErr string
+	}{
+		{"valid", func(sc *plugins.Sidecar) {}, ""},
+		{"uppercase name", func(sc *plugins.Sidecar) { sc.Name = "VPN" }, "invalid sidecar name"},
+		{"empty name", func(sc *plugins.Sidecar) { sc.Name = "" }, "invalid sidecar name"},
+		{"long name", func(sc *plugins.Sidecar) { sc.Name = strings.Repeat("a", MaxSidecarNameLength+1) }, "invalid sidecar name"},
+		{"missing image", func(sc *plugins.Sidecar) { sc.Image = "" }, "invalid image"},
+		{"image with space", func(sc *plugins.Sidecar) { sc.Image = "vpn latest" }, "invalid image"},
+		{"bad env name", func(sc *plugins.Sidecar) { sc.Env = map[string]string{"1BAD": "x"} }, "environment variable"},
+		{"port out of range", func(sc *plugins.Sidecar) { sc.Ports = []int{70000} }, "invalid port"},
+		{"reserved port", func(sc *plugins.Sidecar) { sc.Ports = []int{5900} }, "reserved"},
+		{"duplicate port", func(sc *plugins.Sidecar) { sc.Ports = []int{1080, 1080} }, "duplicate port"},
+		{"bad quantity", func(sc *plugins.Sidecar) { sc.Resources = &plugins.ResourceLimits{MemoryLimit: "lots"} }, "memory_limit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := valid
+			tt.modify(&sc)
+			err := ValidateSidecar(sc)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateSidecar() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateSidecar() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSidecars_Conflicts(t *testing.T) {
+	a := plugins.Sidecar{Name: "a", Image: "a:1", Ports: []int{9000}}
+	b := plugins.Sidecar{Name: "b", Image: "b:1", Ports: []int{9001}}
+
+	if err := ValidateSidecars([]plugins.Sidecar{a, b}); err != nil {
+		t.Errorf("ValidateSidecars() error = %v", err)
+	}
+	if err := ValidateSidecars([]plugins.Sidecar{a, a}); err == nil {
+		t.Error("expected error for duplicate sidecar names")
+	}
+	b.Ports = []int{9000}
+	if err := ValidateSidecars([]plugins.Sidecar{a, b}); err == nil {
+		t.Error("expected error for sidecars sharing a port")
+	}
+}
+
+func TestBuildPodSpecs_WithSidecars(t *testing.T) {
+	sidecars := []plugins.Sidecar{
+		{
+			Name:           "logs",
+			Image:          "example.com/logs:1.0",
+			Command:        []string{"/agent"},
+			Env:            map[string]string{"LEVEL": "info"},
+			Ports:          []int{24224},
+			Resources:      &plugins.ResourceLimits{CPULimit: "100m", MemoryRequest: "32Mi"},
+			MountWorkspace: true,
+		},
+	}
+
+	builders := map[string]func(*PodConfig) *corev1.Pod{
+		"standard":  BuildPodSpec,
+		"web proxy": BuildWebProxyPodSpec,
+		"windows":   BuildWindowsPodSpec,
+	}
+	for name, build := range builders {
+		t.Run(name, func(t *testing.T) {
+			config := DefaultPodConfig("sess-1", "app-1", "Test App", "ubuntu:latest")
+			base := len(build(config).Spec.Containers)
+
+			config.Sidecars = sidecars
+			pod := build(config)
+			if len(pod.Spec.Containers) != base+1 {
+				t.Fatalf("len(Containers) = %d, want %d", len(pod.Spec.Containers), base+1)
+			}
+
+			c := pod.Spec.Containers[len(pod.Spec.Containers)-1]
+			if c.Name != "sidecar-logs" || c.Image != "example.com/logs:1.0" {
+				t.Errorf("sidecar container = %s (%s)", c.Name, c.Image)
+			}
+			if len(c.Command) != 1 || c.Command[0] != "/agent" {
+				t.Errorf("Command = %v", c.Command)
+			}
+			if len(c.Env) != 1 || c.Env[0].Name != "LEVEL" || c.Env[0].Value != "info" {
+				t.Errorf("Env = %v", c.Env)
+			}
+			if len(c.Ports) != 1 || c.Ports[0].ContainerPort != 24224 {
+				t.Errorf("Ports = %v", c.Ports)
+			}
+			if c.Resources.Limits.Cpu().String() != "100m" || c.Resources.Requests.Memory().String() != "32Mi" {
+				t.Errorf("Resources = %+v", c.Resources)
+			}
+			if _, ok := c.Resources.Limits[corev1.ResourceMemory]; ok {
+				t.Error("unset memory limit should not be rendered")
+			}
+			if len(c.VolumeMounts) != 1 || c.VolumeMounts[0].Name != WorkspaceVolumeName {
+				t.Errorf("VolumeMounts = %v", c.VolumeMounts)
+			}
+		})
+	}
+}
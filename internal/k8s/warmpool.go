@@ -0,0 +1,82 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MakeWarmPod turns a session pod into an unclaimed pod of a warm pool. It
+// keeps the session component label, so the session NetworkPolicy isolates
+// it, but drops the session label, so session listings and per-session
+// resources ignore it until it is claimed.
+func MakeWarmPod(pod *corev1.Pod, pool string) {
+	delete(pod.Labels, SessionLabelKey)
+	pod.Labels[WarmPoolLabelKey] = pool
+}
+
+// ListWarmPods lists the unclaimed pods of every warm pool.
+func ListWarmPods(ctx context.Context) (*corev1.PodList, error) {
+	if pods, ok := listCachedPods(WarmPoolLabelKey); ok {
+		return pods, nil
+	}
+
+	client, err := GetClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.CoreV1().Pods(GetNamespace()).List(ctx, metav1.ListOptions{
+		LabelSelector: WarmPoolLabelKey,
+	})
+}
+
+// ClaimWarmPod relabels a ready pod of the pool as the session's pod and
+// returns it, or nil if the pool has none ready. Each update is conditional
+// on the pod's resource version, so a pod another replica claims first is
+// skipped.
+func ClaimWarmPod(ctx context.Context, pool, sessionID string) (*corev1.Pod, error) {
+	client, err := GetClient()
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := client.CoreV1().Pods(GetNamespace()).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", WarmPoolLabelKey, pool),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.DeletionTimestamp != nil || !IsPodReady(pod) {
+			continue
+		}
+		delete(pod.Labels, WarmPoolLabelKey)
+		pod.Labels[SessionLabelKey] = sessionID
+
+		claimed, err := client.CoreV1().Pods(GetNamespace()).Update(ctx, pod, metav1.UpdateOptions{})
+		if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return claimed, nil
+	}
+	return nil, nil
+}
+
+// IsPodReady reports whether the pod's Ready condition is true.
+func IsPodReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestClaimWarmPod(t *testing.T) {
+	defer ResetClient()
+	fakeClient := setFakeClient(t)
+	ctx := context.Background()
+
+	// One starting and one ready pod in the pool
+	for _, name := range []string{"warm-starting", "warm-ready"} {
+		pod := BuildPodSpec(DefaultPodConfig(name, "app-1", "App", "myapp:v1"))
+		MakeWarmPod(pod, "pool-a")
+		if name == "warm-ready" {
+			pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		}
+		if _, err := CreatePod(ctx, pod); err != nil {
+			t.Fatalf("CreatePod() error = %v", err)
+		}
+	}
+
+	warm, err := ListWarmPods(ctx)
+	if err != nil || len(warm.Items) != 2 {
+		t.Fatalf("ListWarmPods() = %v, %v; want 2 pods", warm, err)
+	}
+	if sessions, _ := ListSessionPods(ctx); len(sessions.Items) != 0 {
+		t.Errorf("ListSessionPods() included %d unclaimed pods", len(sessions.Items))
+	}
+
+	if pod, err := ClaimWarmPod(ctx, "pool-b", "sess-1"); err != nil || pod != nil {
+		t.Errorf("ClaimWarmPod(other pool) = %v, %v; want nil", pod, err)
+	}
+
+	pod, err := ClaimWarmPod(ctx, "pool-a", "sess-1")
+	if err != nil || pod == nil {
+		t.Fatalf("ClaimWarmPod() = %v, %v", pod, err)
+	}
+	if pod.Name != "sortie-session-warm-ready" {
+		t.Errorf("claimed %s, want the ready pod", pod.Name)
+	}
+	got, _ := fakeClient.CoreV1().Pods("test-ns").Get(ctx, pod.Name, metav1.GetOptions{})
+	if got.Labels[SessionLabelKey] != "sess-1" || got.Labels[WarmPoolLabelKey] != "" || got.Labels[ComponentLabelKey] != "session" {
+		t.Errorf("claimed pod labels = %v", got.Labels)
+	}
+	if sessions, _ := ListSessionPods(ctx); len(sessions.Items) != 1 {
+		t.Errorf("ListSessionPods() = %d pods, want the claimed one", len(sessions.Items))
+	}
+
+	if pod, _ := ClaimWarmPod(ctx, "pool-a", "sess-2"); pod != nil {
+		t.Errorf("claimed %s, which is not ready", pod.Name)
+	}
+}
@@ -0,0 +1,81 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// WorkspaceVolumeComponent is the component label value of session
+	// workspace claims
+	WorkspaceVolumeComponent = "workspace-volume"
+
+	// DefaultWorkspaceVolumeSize is the capacity requested for a session's
+	// workspace claim
+	DefaultWorkspaceVolumeSize = "10Gi"
+)
+
+// WorkspaceClaimName returns the name of a session's workspace claim.
+func WorkspaceClaimName(sessionID string) string {
+	return "sortie-workspace-" + sessionID
+}
+
+// BuildWorkspaceClaim creates the PersistentVolumeClaim that holds a
+// session's workspace while its pod is deleted, so a hibernated session
+// gets its files back when resumed.
+func BuildWorkspaceClaim(sessionID, appID string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      WorkspaceClaimName(sessionID),
+			Namespace: GetNamespace(),
+			Labels: map[string]string{
+				AppLabelKey:       appID,
+				SessionLabelKey:   sessionID,
+				ComponentLabelKey: WorkspaceVolumeComponent,
+			},
+			Annotations: map[string]string{
+				"sortie.io/created-at": time.Now().UTC().Format(time.RFC3339),
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse(DefaultWorkspaceVolumeSize),
+				},
+			},
+		},
+	}
+}
+
+// ListWorkspaceClaims lists the workspace claims of every session.
+func ListWorkspaceClaims(ctx context.Context) (*corev1.PersistentVolumeClaimList, error) {
+	client, err := GetClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.CoreV1().PersistentVolumeClaims(GetNamespace()).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", ComponentLabelKey, WorkspaceVolumeComponent),
+	})
+}
+
+// applyWorkspaceClaim backs the pod's workspace volume with a claim instead
+// of an emptyDir.
+func applyWorkspaceClaim(spec *corev1.PodSpec, claimName string) {
+	if claimName == "" {
+		return
+	}
+	for i := range spec.Volumes {
+		if spec.Volumes[i].Name == WorkspaceVolumeName {
+			spec.Volumes[i].VolumeSource = corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+			}
+		}
+	}
+}
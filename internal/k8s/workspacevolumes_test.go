@@ -0,0 +1,63 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/db"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestWorkspaceClaims(t *testing.T) {
+	defer ResetClient()
+	setFakeClient(t)
+	ctx := context.Background()
+
+	pvc, err := EnsurePVC(ctx, BuildWorkspaceClaim("sess-1", "app-1"))
+	if err != nil {
+		t.Fatalf("EnsurePVC() error = %v", err)
+	}
+	if pvc.Name != WorkspaceClaimName("sess-1") || pvc.Labels[SessionLabelKey] != "sess-1" || pvc.Labels[AppLabelKey] != "app-1" {
+		t.Errorf("claim = %s %v", pvc.Name, pvc.Labels)
+	}
+	if q := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; q.String() != DefaultWorkspaceVolumeSize {
+		t.Errorf("requested %s, want %s", q.String(), DefaultWorkspaceVolumeSize)
+	}
+
+	// Home volume claims are listed separately
+	if _, err := EnsurePVC(ctx, BuildHomeVolumeClaim("user-1", "app-1", &db.HomeVolume{MountPath: "/home/user"})); err != nil {
+		t.Fatalf("EnsurePVC() error = %v", err)
+	}
+	list, err := ListWorkspaceClaims(ctx)
+	if err != nil || len(list.Items) != 1 || list.Items[0].Name != pvc.Name {
+		t.Errorf("ListWorkspaceClaims() = %v, %v; want only %s", list, err, pvc.Name)
+	}
+}
+
+func TestBuildPodSpec_WorkspaceClaim(t *testing.T) {
+	config := DefaultPodConfig("sess-1", "app-1", "App", "myapp:v1")
+	config.WorkspaceClaim = "sortie-workspace-sess-1"
+
+	for _, pod := range []*corev1.Pod{BuildPodSpec(config), BuildWebProxyPodSpec(config), BuildWindowsPodSpec(config)} {
+		found := false
+		for _, v := range pod.Spec.Volumes {
+			if v.Name != WorkspaceVolumeName {
+				continue
+			}
+			found = true
+			if v.PersistentVolumeClaim == nil || v.PersistentVolumeClaim.ClaimName != "sortie-workspace-sess-1" || v.EmptyDir != nil {
+				t.Errorf("workspace volume = %+v, want the claim", v.VolumeSource)
+			}
+		}
+		if !found {
+			t.Error("pod has no workspace volume")
+		}
+	}
+
+	plain := BuildPodSpec(DefaultPodConfig("sess-2", "app-1", "App", "myapp:v1"))
+	for _, v := range plain.Spec.Volumes {
+		if v.Name == WorkspaceVolumeName && v.EmptyDir == nil {
+			t.Errorf("workspace volume without a claim = %+v, want an emptyDir", v.VolumeSource)
+		}
+	}
+}
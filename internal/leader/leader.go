@@ -0,0 +1,152 @@
+// Package leader elects one replica to run maintenance loops, such as
+// session cleanup and expiry, using a lease stored in the shared database.
+// The leader renews its lease periodically; if it stops, another replica
+// takes over once the lease expires.
+package leader
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// MaintenanceLease is the lease held by the replica that runs maintenance
+// loops.
+const MaintenanceLease = "maintenance"
+
+const (
+	// DefaultLeaseDuration is how long a lease lasts without being renewed.
+	DefaultLeaseDuration = 15 * time.Second
+	// renewFraction of the lease duration passes between renewals.
+	renewFraction = 3
+)
+
+// Checker reports whether this replica should run maintenance loops. A nil
+// Checker means it always should.
+type Checker interface {
+	IsLeader() bool
+}
+
+// IsLeader reports whether c allows running maintenance loops.
+func IsLeader(c Checker) bool {
+	return c == nil || c.IsLeader()
+}
+
+// Elector campaigns for a named lease and tracks whether this replica
+// holds it.
+type Elector struct {
+	db       *db.DB
+	name     string
+	identity string
+	ttl      time.Duration
+	leader   atomic.Bool
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewElector creates an Elector for the named lease. identity must be
+// unique per replica; ttl <= 0 uses DefaultLeaseDuration.
+func NewElector(database *db.DB, name, identity string, ttl time.Duration) *Elector {
+	if ttl <= 0 {
+		ttl = DefaultLeaseDuration
+	}
+	return &Elector{
+		db:       database,
+		name:     name,
+		identity: identity,
+		ttl:      ttl,
+		stopCh:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Identity returns a replica identity from the hostname, which is the pod
+// name in Kubernetes, and process ID.
+func Identity() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// Start makes a first attempt to take the lease, so a single replica is
+// leader as soon as it starts, then keeps campaigning in the background.
+func (e *Elector) Start() {
+	e.campaign()
+	go e.loop()
+}
+
+// Stop stops campaigning and releases the lease if this replica holds it.
+func (e *Elector) Stop() {
+	e.stopOnce.Do(func() {
+		close(e.stopCh)
+		<-e.done
+		if e.leader.Swap(false) {
+			if err := e.db.ReleaseLease(e.name, e.identity); err != nil {
+				slog.Warn("failed to release leader lease", "lease", e.name, "error", err)
+			}
+		}
+	})
+}
+
+// IsLeader reports whether this replica held the lease at its last renewal.
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Identity returns the identity this replica campaigns with.
+func (e *Elector) Identity() string {
+	return e.identity
+}
+
+// Leader returns the identity of the replica holding the lease, or "" if
+// no replica holds an unexpired one.
+func (e *Elector) Leader() (string, error) {
+	lease, err := e.db.GetLease(e.name)
+	if err != nil {
+		return "", err
+	}
+	if lease == nil || lease.ExpiresAt.Before(time.Now()) {
+		return "", nil
+	}
+	return lease.Holder, nil
+}
+
+func (e *Elector) loop() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.ttl / renewFraction)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			e.campaign()
+		case <-e.stopCh:
+			return
+		}
+	}
+}
+
+// campaign takes or renews the lease. On a database error this replica
+// steps down, since it cannot tell whether another replica took over.
+func (e *Elector) campaign() {
+	held, err := e.db.AcquireLease(e.name, e.identity, e.ttl)
+	if err != nil {
+		slog.Warn("failed to renew leader lease", "lease", e.name, "error", err)
+		held = false
+	}
+	if was := e.leader.Swap(held); was != held {
+		if held {
+			slog.Info("acquired leader lease", "lease", e.name, "identity", e.identity)
+		} else {
+			slog.Info("lost leader lease", "lease", e.name, "identity", e.identity)
+		}
+	}
+}
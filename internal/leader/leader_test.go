@@ -0,0 +1,68 @@
+package leader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db/dbtest"
+)
+
+func TestElector_SingleLeaderAndFailover(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+
+	a := NewElector(database, MaintenanceLease, "replica-a", time.Minute)
+	b := NewElector(database, MaintenanceLease, "replica-b", time.Minute)
+	a.Start()
+	b.Start()
+	defer b.Stop()
+
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("leaders: a=%v b=%v, want only a", a.IsLeader(), b.IsLeader())
+	}
+	if leader, err := b.Leader(); err != nil || leader != "replica-a" {
+		t.Errorf("Leader() = %q, %v; want replica-a", leader, err)
+	}
+
+	// Stopping the leader releases the lease for the next campaign
+	a.Stop()
+	if a.IsLeader() {
+		t.Error("stopped elector still reports leadership")
+	}
+	b.campaign()
+	if !b.IsLeader() {
+		t.Fatal("expected b to take over after a released the lease")
+	}
+	if leader, _ := a.Leader(); leader != "replica-b" {
+		t.Errorf("Leader() = %q, want replica-b", leader)
+	}
+}
+
+func TestElector_TakesOverExpiredLease(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+
+	// A crashed replica's lease is never released, only left to expire
+	if ok, err := database.AcquireLease(MaintenanceLease, "crashed", 50*time.Millisecond); err != nil || !ok {
+		t.Fatalf("AcquireLease() = %v, %v", ok, err)
+	}
+
+	e := NewElector(database, MaintenanceLease, "replica-a", 30*time.Millisecond)
+	e.Start()
+	defer e.Stop()
+	if e.IsLeader() {
+		t.Fatal("took over a lease that has not expired")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !e.IsLeader() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !e.IsLeader() {
+		t.Fatal("expected elector to take over the expired lease")
+	}
+}
+
+func TestIsLeader_NilChecker(t *testing.T) {
+	if !IsLeader(nil) {
+		t.Error("a nil checker should always allow maintenance")
+	}
+}
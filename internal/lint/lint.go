@@ -0,0 +1,305 @@
+// Package lint checks apps and templates against best-practice rules, such
+// as setting resource limits and pinning image tags. Authors lint a draft
+// from the app and template forms before saving it, and a background job
+// lints the whole catalog for the admin report.
+package lint
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// Severity says how much a warning matters.
+type Severity string
+
+const (
+	// SeverityWarning is a setting that can hurt security, stability or
+	// reproducibility.
+	SeverityWarning Severity = "warning"
+	// SeverityInfo is a cosmetic gap, such as a missing icon.
+	SeverityInfo Severity = "info"
+)
+
+// Names of the rules.
+const (
+	RuleResourceLimits = "resource-limits"
+	RuleLatestTag      = "latest-tag"
+	RuleEgressPolicy   = "egress-policy"
+	RuleRDPCertificate = "rdp-certificate"
+	RuleNodeDNS        = "node-dns"
+	RuleIcon           = "icon"
+	RuleDescription    = "description"
+)
+
+// Warning is a rule that an app or template breaks.
+type Warning struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	// Field is the JSON path of the setting the warning is about, if any.
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// subject is the part of an app or template the rules look at. Templates
+// have no egress, RDP or DNS settings, so those are nil for them.
+type subject struct {
+	launchType   string
+	osType       string
+	images       []image
+	limitsField  string
+	limits       *db.ResourceLimits
+	egress       *db.EgressPolicy
+	rdp          *db.RDPSettings
+	dns          *db.DNSConfig
+	icon         string
+	description  string
+	isTemplate   bool
+	launchesPods bool
+}
+
+// image is a container image and the field it is set in.
+type image struct {
+	field string
+	ref   string
+}
+
+type rule struct {
+	name     string
+	severity Severity
+	check    func(s *subject) []Warning
+}
+
+var rules = []rule{
+	{RuleResourceLimits, SeverityWarning, checkResourceLimits},
+	{RuleLatestTag, SeverityWarning, checkLatestTag},
+	{RuleEgressPolicy, SeverityWarning, checkEgressPolicy},
+	{RuleRDPCertificate, SeverityWarning, checkRDPCertificate},
+	{RuleNodeDNS, SeverityWarning, checkNodeDNS},
+	{RuleIcon, SeverityInfo, checkIcon},
+	{RuleDescription, SeverityInfo, checkDescription},
+}
+
+// App returns the rules app breaks, or an empty slice if it breaks none.
+func App(app *db.Application) []Warning {
+	s := &subject{
+		launchType:  string(app.LaunchType),
+		osType:      app.OsType,
+		limitsField: "resource_limits",
+		limits:      app.ResourceLimits,
+		egress:      app.EgressPolicy,
+		rdp:         app.RDPSettings,
+		dns:         app.DNS,
+		icon:        app.Icon,
+		description: app.Description,
+	}
+	if app.ContainerImage != "" {
+		s.images = append(s.images, image{"container_image", app.ContainerImage})
+	}
+	if si := app.SidecarImages; si != nil {
+		for _, img := range []image{
+			{"sidecar_images.vnc", si.VNC},
+			{"sidecar_images.browser", si.Browser},
+			{"sidecar_images.guacd", si.Guacd},
+		} {
+			if img.ref != "" {
+				s.images = append(s.images, img)
+			}
+		}
+	}
+	return run(s)
+}
+
+// Template returns the rules t breaks, or an empty slice if it breaks
+// none. The resource limits rule looks at its recommended limits.
+func Template(t *db.Template) []Warning {
+	s := &subject{
+		launchType:  t.LaunchType,
+		osType:      t.OsType,
+		limitsField: "recommended_limits",
+		limits:      t.RecommendedLimits,
+		icon:        t.Icon,
+		description: t.Description,
+		isTemplate:  true,
+	}
+	if t.ContainerImage != "" {
+		s.images = append(s.images, image{"container_image", t.ContainerImage})
+	}
+	return run(s)
+}
+
+func run(s *subject) []Warning {
+	s.launchesPods = s.launchType == string(db.LaunchTypeContainer) || s.launchType == string(db.LaunchTypeWebProxy)
+	warnings := []Warning{}
+	for _, r := range rules {
+		for _, w := range r.check(s) {
+			w.Rule = r.name
+			w.Severity = r.severity
+			warnings = append(warnings, w)
+		}
+	}
+	return warnings
+}
+
+func checkResourceLimits(s *subject) []Warning {
+	if !s.launchesPods {
+		return nil
+	}
+	var limits db.ResourceLimits
+	if s.limits != nil {
+		limits = *s.limits
+	}
+	var warnings []Warning
+	if limits.CPULimit == "" {
+		warnings = append(warnings, Warning{
+			Field:   s.limitsField + ".cpu_limit",
+			Message: "No CPU limit is set, so sessions get the inherited default, which may not suit this app",
+		})
+	}
+	if limits.MemoryLimit == "" {
+		warnings = append(warnings, Warning{
+			Field:   s.limitsField + ".memory_limit",
+			Message: "No memory limit is set, so sessions get the inherited default, which may not suit this app",
+		})
+	}
+	return warnings
+}
+
+func checkLatestTag(s *subject) []Warning {
+	if !s.launchesPods {
+		return nil
+	}
+	var warnings []Warning
+	for _, img := range s.images {
+		if tag, pinned := imageTag(img.ref); !pinned && (tag == "" || tag == "latest") {
+			warnings = append(warnings, Warning{
+				Field:   img.field,
+				Message: fmt.Sprintf("Image %s uses the latest tag, so sessions may run a different version each launch; pin a version tag or digest", img.ref),
+			})
+		}
+	}
+	return warnings
+}
+
+// imageTag returns ref's tag, and whether ref is pinned to a digest.
+func imageTag(ref string) (tag string, pinned bool) {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		return "", true
+	}
+	name := ref
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[i+1:], false
+	}
+	return "", false
+}
+
+func checkEgressPolicy(s *subject) []Warning {
+	if s.isTemplate || !s.launchesPods {
+		return nil
+	}
+	if s.egress != nil && s.egress.Mode != "" {
+		return nil
+	}
+	return []Warning{{
+		Field:   "egress_policy",
+		Message: "No egress policy is set, so sessions may reach any network destination the cluster default allows",
+	}}
+}
+
+func checkRDPCertificate(s *subject) []Warning {
+	if s.isTemplate || !s.launchesPods || s.osType != "windows" {
+		return nil
+	}
+	if s.rdp != nil && s.rdp.IgnoreCert != nil && !*s.rdp.IgnoreCert {
+		return nil
+	}
+	return []Warning{{
+		Field:   "rdp_settings.ignore_cert",
+		Message: "The RDP server's certificate is not verified; set ignore_cert to false once the server has a trusted certificate",
+	}}
+}
+
+func checkNodeDNS(s *subject) []Warning {
+	if s.dns == nil || s.dns.Policy != "Default" {
+		return nil
+	}
+	return []Warning{{
+		Field:   "dns.policy",
+		Message: "Session pods use the node's resolvers instead of cluster DNS, which bypasses DNS-based egress controls",
+	}}
+}
+
+func checkIcon(s *subject) []Warning {
+	if s.icon != "" {
+		return nil
+	}
+	return []Warning{{Field: "icon", Message: "No icon is set, so the catalog shows a placeholder"}}
+}
+
+func checkDescription(s *subject) []Warning {
+	if strings.TrimSpace(s.description) != "" {
+		return nil
+	}
+	return []Warning{{Field: "description", Message: "No description is set, so users cannot tell what the app is for"}}
+}
+
+// Checker lints the catalog and stores the warnings for the admin report.
+type Checker struct {
+	db  *db.DB
+	now func() time.Time
+}
+
+// NewChecker creates a Checker that stores warnings in database.
+func NewChecker(database *db.DB) *Checker {
+	return &Checker{db: database, now: time.Now}
+}
+
+// Run lints every app and template and replaces the stored warnings with
+// the ones found. It is run as a background job.
+func (c *Checker) Run(ctx context.Context) error {
+	apps, err := c.db.ListApps()
+	if err != nil {
+		return fmt.Errorf("failed to list apps: %w", err)
+	}
+	templates, err := c.db.ListTemplates()
+	if err != nil {
+		return fmt.Errorf("failed to list templates: %w", err)
+	}
+
+	now := c.now().UTC()
+	var stored []db.LintWarning
+	add := func(kind, id, name, tenantID string, warnings []Warning) {
+		for _, w := range warnings {
+			stored = append(stored, db.LintWarning{
+				Kind:       kind,
+				TargetID:   id,
+				TargetName: name,
+				TenantID:   tenantID,
+				Rule:       w.Rule,
+				Severity:   string(w.Severity),
+				Field:      w.Field,
+				Message:    w.Message,
+				CheckedAt:  now,
+			})
+		}
+	}
+	for i := range apps {
+		add(db.LintKindApp, apps[i].ID, apps[i].Name, apps[i].TenantID, App(&apps[i]))
+	}
+	for i := range templates {
+		add(db.LintKindTemplate, templates[i].TemplateID, templates[i].Name, "", Template(&templates[i]))
+	}
+
+	if err := c.db.ReplaceLintWarnings(stored); err != nil {
+		return fmt.Errorf("failed to store lint warnings: %w", err)
+	}
+	slog.Info("linted catalog", "apps", len(apps), "templates", len(templates), "warnings", len(stored))
+	return nil
+}
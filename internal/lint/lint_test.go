@@ -0,0 +1,141 @@
+package lint
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/db/dbtest"
+)
+
+// rulesOf returns the rule and field of each warning, as "rule field".
+func rulesOf(warnings []Warning) map[string]bool {
+	got := map[string]bool{}
+	for _, w := range warnings {
+		got[w.Rule+" "+w.Field] = true
+	}
+	return got
+}
+
+func TestApp(t *testing.T) {
+	ignore := false
+	tests := []struct {
+		name string
+		app  db.Application
+		want []string
+	}{
+		{
+			name: "clean container app",
+			app: db.Application{
+				LaunchType: db.LaunchTypeContainer, ContainerImage: "registry.example.com/cad:2026.1",
+				Icon: "cad.png", Description: "CAD",
+				ResourceLimits: &db.ResourceLimits{CPULimit: "2", MemoryLimit: "4Gi"},
+				EgressPolicy:   &db.EgressPolicy{Mode: "allowlist"},
+			},
+		},
+		{
+			name: "bare container app",
+			app:  db.Application{LaunchType: db.LaunchTypeWebProxy, ContainerImage: "localhost:5000/ide"},
+			want: []string{
+				"resource-limits resource_limits.cpu_limit",
+				"resource-limits resource_limits.memory_limit",
+				"latest-tag container_image",
+				"egress-policy egress_policy",
+				"icon icon",
+				"description description",
+			},
+		},
+		{
+			name: "url app only needs an icon and description",
+			app:  db.Application{LaunchType: db.LaunchTypeURL, URL: "https://example.com"},
+			want: []string{"icon icon", "description description"},
+		},
+		{
+			name: "privileged settings",
+			app: db.Application{
+				LaunchType: db.LaunchTypeContainer, OsType: "windows", ContainerImage: "win@sha256:abc",
+				Icon: "win.png", Description: "Windows",
+				ResourceLimits: &db.ResourceLimits{CPULimit: "2", MemoryLimit: "4Gi"},
+				EgressPolicy:   &db.EgressPolicy{Mode: "denylist"},
+				SidecarImages:  &db.SidecarImages{Guacd: "guacamole/guacd:latest"},
+				DNS:            &db.DNSConfig{Policy: "Default"},
+			},
+			want: []string{
+				"latest-tag sidecar_images.guacd",
+				"rdp-certificate rdp_settings.ignore_cert",
+				"node-dns dns.policy",
+			},
+		},
+		{
+			name: "verified RDP certificate",
+			app: db.Application{
+				LaunchType: db.LaunchTypeContainer, OsType: "windows", ContainerImage: "win:1",
+				Icon: "win.png", Description: "Windows",
+				ResourceLimits: &db.ResourceLimits{CPULimit: "2", MemoryLimit: "4Gi"},
+				EgressPolicy:   &db.EgressPolicy{Mode: "allowlist"},
+				RDPSettings:    &db.RDPSettings{IgnoreCert: &ignore},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings := App(&tt.app)
+			got := rulesOf(warnings)
+			if len(warnings) != len(tt.want) {
+				t.Errorf("App() = %+v, want %v", warnings, tt.want)
+			}
+			for _, w := range tt.want {
+				if !got[w] {
+					t.Errorf("App() missing %q in %+v", w, warnings)
+				}
+			}
+		})
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	warnings := Template(&db.Template{
+		LaunchType: "container", ContainerImage: "codercom/code-server:latest",
+		Icon: "code.png", Description: "VS Code",
+		RecommendedLimits: &db.ResourceLimits{CPULimit: "2"},
+	})
+	got := rulesOf(warnings)
+	if len(warnings) != 2 || !got["latest-tag container_image"] || !got["resource-limits recommended_limits.memory_limit"] {
+		t.Errorf("Template() = %+v", warnings)
+	}
+	for _, w := range warnings {
+		if w.Severity != SeverityWarning {
+			t.Errorf("warning %+v has severity %s", w, w.Severity)
+		}
+	}
+}
+
+func TestCheckerRun(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+	if err := database.CreateApp(db.Application{ID: "docs", Name: "Docs", LaunchType: db.LaunchTypeURL, URL: "https://example.com", Description: "Docs"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.CreateTemplate(db.Template{TemplateID: "ide", Name: "IDE", LaunchType: "url", URL: "https://example.com", Icon: "ide.png"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.ReplaceLintWarnings([]db.LintWarning{{Kind: db.LintKindApp, TargetID: "gone", Rule: RuleIcon, Severity: "info", Message: "stale"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewChecker(database).Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	stored, err := database.ListLintWarnings(db.LintWarningFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 2 {
+		t.Fatalf("stored warnings = %+v, want the app's icon and the template's description", stored)
+	}
+	if stored[0].Kind != db.LintKindApp || stored[0].TargetID != "docs" || stored[0].Rule != RuleIcon {
+		t.Errorf("stored[0] = %+v", stored[0])
+	}
+	if stored[1].Kind != db.LintKindTemplate || stored[1].TargetID != "ide" || stored[1].Rule != RuleDescription {
+		t.Errorf("stored[1] = %+v", stored[1])
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/rjsadow/sortie/internal/plugins"
+	"github.com/rjsadow/sortie/internal/plugins/auth"
 )
 
 // contextKey is a custom type for context keys to avoid collisions
@@ -26,27 +27,27 @@ func AuthMiddleware(authProvider plugins.AuthProvider) func(http.Handler) http.H
 			// Extract token from Authorization header
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
-				http.Error(w, "Authorization header required", http.StatusUnauthorized)
+				LocalizedError(w, r, "Authorization header required", http.StatusUnauthorized)
 				return
 			}
 
 			// Expect "Bearer <token>" format
 			parts := strings.SplitN(authHeader, " ", 2)
 			if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
-				http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
+				LocalizedError(w, r, "Invalid authorization header format", http.StatusUnauthorized)
 				return
 			}
 
 			token := parts[1]
 			if token == "" {
-				http.Error(w, "Token required", http.StatusUnauthorized)
+				LocalizedError(w, r, "Token required", http.StatusUnauthorized)
 				return
 			}
 
 			// Authenticate the token
 			result, err := authProvider.Authenticate(r.Context(), token)
 			if err != nil {
-				http.Error(w, "Authentication failed", http.StatusUnauthorized)
+				LocalizedError(w, r, "Authentication failed", http.StatusUnauthorized)
 				return
 			}
 
@@ -55,10 +56,18 @@ func AuthMiddleware(authProvider plugins.AuthProvider) func(http.Handler) http.H
 				if result.Message != "" {
 					msg = result.Message
 				}
-				http.Error(w, msg, http.StatusUnauthorized)
+				LocalizedError(w, r, msg, http.StatusUnauthorized)
 				return
 			}
 
+			// API tokens only allow the requests their scopes cover
+			if result.User != nil {
+				if scopes, ok := result.User.Metadata[auth.MetadataAPITokenScopes]; ok && !auth.ScopesAllow(scopes, r.Method) {
+					LocalizedError(w, r, "Insufficient token scope", http.StatusForbidden)
+					return
+				}
+			}
+
 			// Add user to request context
 			ctx := context.WithValue(r.Context(), UserContextKey, result.User)
 			next.ServeHTTP(w, r.WithContext(ctx))
@@ -373,3 +373,18 @@ func TestGetUserFromContext_ValidUser(t *testing.T) {
 		t.Errorf("expected user ID %q, got %q", expected.ID, user.ID)
 	}
 }
+
+func TestAuthMiddleware_APITokenScopes(t *testing.T) {
+	user := &plugins.User{ID: "u1", Username: "ci", Metadata: map[string]string{"api_token_scopes": "read"}}
+	handler := AuthMiddleware(newMockProvider(user))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for method, want := range map[string]int{http.MethodGet: http.StatusOK, http.MethodPost: http.StatusForbidden} {
+		req := httptest.NewRequest(method, "/api/sessions", nil)
+		req.Header.Set("Authorization", "Bearer valid-token")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("%s with a read-only token: status %d, want %d", method, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// clientIPContextKey is the context key for the client address resolved
+// by RealIP.
+const clientIPContextKey contextKey = "client_ip"
+
+// RealIP is middleware that resolves each request's client address and
+// adds it to the request context for ClientIP. Forwarding headers are only
+// honored on requests from the trusted proxies: the client is then the
+// right-most X-Forwarded-For hop that is not a trusted proxy, since every
+// hop left of it may have been written by the client. Requests from
+// anywhere else are from their RemoteAddr.
+func RealIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := resolveClientIP(r, trusted)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPContextKey, ip)))
+		})
+	}
+}
+
+// ClientIP returns the client address RealIP resolved for r, or the host
+// of its RemoteAddr if RealIP did not run.
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPContextKey).(string); ok {
+		return ip
+	}
+	return remoteHost(r)
+}
+
+func resolveClientIP(r *http.Request, trusted []netip.Prefix) string {
+	remote := remoteHost(r)
+	if !isTrustedProxy(remote, trusted) {
+		return remote
+	}
+
+	var hops []string
+	for _, xff := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(xff, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !isTrustedProxy(hops[i], trusted) {
+			return hops[i]
+		}
+	}
+	if len(hops) > 0 {
+		// Every hop is a trusted proxy; the left-most is nearest the client
+		return hops[0]
+	}
+	if xri := strings.TrimSpace(r.Header.Get("X-Real-Ip")); xri != "" {
+		return xri
+	}
+	return remote
+}
+
+// remoteHost returns the host of r's RemoteAddr.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func isTrustedProxy(ip string, trusted []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
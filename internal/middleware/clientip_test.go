@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	tests := []struct {
+		name       string
+		trusted    []netip.Prefix
+		remoteAddr string
+		xff        []string
+		xri        string
+		want       string
+	}{
+		{name: "direct", remoteAddr: "203.0.113.5:1234", want: "203.0.113.5"},
+		{name: "headers ignored without trusted proxies", remoteAddr: "203.0.113.5:1234", xff: []string{"198.51.100.1"}, xri: "198.51.100.2", want: "203.0.113.5"},
+		{name: "headers ignored from an untrusted peer", trusted: trusted, remoteAddr: "203.0.113.5:1234", xff: []string{"198.51.100.1"}, want: "203.0.113.5"},
+		{name: "forwarded by a trusted proxy", trusted: trusted, remoteAddr: "10.0.0.2:1234", xff: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "spoofed hops left of the client", trusted: trusted, remoteAddr: "10.0.0.2:1234", xff: []string{"1.2.3.4, 198.51.100.1"}, want: "198.51.100.1"},
+		{name: "chain of trusted proxies", trusted: trusted, remoteAddr: "10.0.0.2:1234", xff: []string{"1.2.3.4, 198.51.100.1, 10.0.0.7"}, want: "198.51.100.1"},
+		{name: "repeated headers", trusted: trusted, remoteAddr: "10.0.0.2:1234", xff: []string{"1.2.3.4", "198.51.100.1"}, want: "198.51.100.1"},
+		{name: "X-Real-Ip from a trusted proxy", trusted: trusted, remoteAddr: "10.0.0.2:1234", xri: "198.51.100.2", want: "198.51.100.2"},
+		{name: "trusted proxy without headers", trusted: trusted, remoteAddr: "10.0.0.2:1234", want: "10.0.0.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := RealIP(tt.trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = ClientIP(r)
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if tt.xri != "" {
+				r.Header.Set("X-Real-Ip", tt.xri)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+			if got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIPKey_IgnoresForwardedFor(t *testing.T) {
+	// Without RealIP, a client cannot pick its own bucket
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "203.0.113.5:1234"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	if got := ClientIPKey(r); got != "ip:203.0.113.5" {
+		t.Errorf("ClientIPKey() = %q, want ip:203.0.113.5", got)
+	}
+}
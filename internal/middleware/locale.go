@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/rjsadow/sortie/internal/i18n"
+)
+
+// LocaleMetadataKey is the plugins.User metadata key holding the user's
+// saved locale preference.
+const LocaleMetadataKey = "locale"
+
+// Localizer returns the localizer for a request: the authenticated user's
+// saved locale when it is supported, otherwise the best match for the
+// Accept-Language header.
+func Localizer(r *http.Request) *i18n.Localizer {
+	preferred := ""
+	if user := GetUserFromContext(r.Context()); user != nil {
+		preferred = user.Metadata[LocaleMetadataKey]
+	}
+	return i18n.New(i18n.Negotiate(preferred, r.Header.Get("Accept-Language")))
+}
+
+// LocalizedError replies to the request like http.Error, with the message
+// translated into the request's locale.
+func LocalizedError(w http.ResponseWriter, r *http.Request, message string, code int) {
+	l := Localizer(r)
+	w.Header().Set("Content-Language", l.Locale())
+	w.Header().Add("Vary", "Accept-Language")
+	http.Error(w, l.T(message), code)
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/plugins"
+)
+
+func TestLocalizedError_AcceptLanguage(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", "es-MX,es;q=0.9")
+	rec := httptest.NewRecorder()
+
+	LocalizedError(rec, req, "Invalid credentials", http.StatusUnauthorized)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if got := strings.TrimSpace(rec.Body.String()); got != "Credenciales no válidas" {
+		t.Errorf("body = %q", got)
+	}
+	if got := rec.Header().Get("Content-Language"); got != "es" {
+		t.Errorf("Content-Language = %q, want es", got)
+	}
+}
+
+func TestLocalizedError_UserPreferenceWins(t *testing.T) {
+	user := &plugins.User{ID: "u1", Metadata: map[string]string{LocaleMetadataKey: "es"}}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", "en-US")
+	req = req.WithContext(context.WithValue(req.Context(), UserContextKey, user))
+	rec := httptest.NewRecorder()
+
+	LocalizedError(rec, req, "Invalid credentials", http.StatusUnauthorized)
+
+	if got := strings.TrimSpace(rec.Body.String()); got != "Credenciales no válidas" {
+		t.Errorf("body = %q", got)
+	}
+}
+
+func TestLocalizedError_DefaultsToEnglish(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", "ja")
+	rec := httptest.NewRecorder()
+
+	LocalizedError(rec, req, "Invalid credentials", http.StatusUnauthorized)
+
+	if got := strings.TrimSpace(rec.Body.String()); got != "Invalid credentials" {
+		t.Errorf("body = %q", got)
+	}
+	if got := rec.Header().Get("Content-Language"); got != "en" {
+		t.Errorf("Content-Language = %q, want en", got)
+	}
+}
@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimit is a token-bucket limit: Rate requests per minute on average,
+// with bursts of up to Burst. A Rate of 0 disables the limit.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimiter keeps a token bucket per key, such as a client IP or user.
+// Like the gateway limiter it is per replica: with N replicas behind a load
+// balancer a client may get up to N times the limit.
+type RateLimiter struct {
+	limits  func() RateLimit
+	refresh time.Duration // how long a limits() result is reused
+	cleanup time.Duration
+	stopCh  chan struct{}
+
+	mu        sync.Mutex
+	limit     RateLimit
+	fetchedAt time.Time
+	buckets   map[string]*bucket
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewRateLimiter creates a RateLimiter whose limits are read from limits.
+// The result is reused for up to 30 seconds, so limits read from admin
+// settings take effect without a restart. Call Stop to end the goroutine
+// that forgets idle clients.
+func NewRateLimiter(limits func() RateLimit) *RateLimiter {
+	rl := &RateLimiter{
+		limits:  limits,
+		refresh: 30 * time.Second,
+		cleanup: 3 * time.Minute,
+		stopCh:  make(chan struct{}),
+		buckets: make(map[string]*bucket),
+	}
+	go rl.cleanupLoop()
+	return rl
+}
+
+// Stop ends the cleanup goroutine.
+func (rl *RateLimiter) Stop() {
+	close(rl.stopCh)
+}
+
+// Allow takes a token from key's bucket. When the bucket is empty it
+// returns false and how long until a token is available.
+func (rl *RateLimiter) Allow(key string) (bool, time.Duration) {
+	rl.mu.Lock()
+	now := time.Now()
+	if rl.fetchedAt.IsZero() || now.Sub(rl.fetchedAt) >= rl.refresh {
+		if limit := rl.limits(); limit != rl.limit {
+			// Start every client afresh under the new limit
+			rl.limit = limit
+			clear(rl.buckets)
+		}
+		rl.fetchedAt = now
+	}
+	if rl.limit.Rate <= 0 {
+		rl.mu.Unlock()
+		return true, 0
+	}
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(rate.Limit(rl.limit.Rate/60), max(rl.limit.Burst, 1))}
+		rl.buckets[key] = b
+	}
+	b.lastSeen = now
+	rl.mu.Unlock()
+
+	res := b.limiter.ReserveN(now, 1)
+	if delay := res.DelayFrom(now); delay > 0 {
+		res.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// cleanupLoop forgets clients that haven't been seen recently.
+func (rl *RateLimiter) cleanupLoop() {
+	ticker := time.NewTicker(rl.cleanup)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			rl.mu.Lock()
+			for key, b := range rl.buckets {
+				if time.Since(b.lastSeen) > rl.cleanup {
+					delete(rl.buckets, key)
+				}
+			}
+			rl.mu.Unlock()
+		case <-rl.stopCh:
+			return
+		}
+	}
+}
+
+// RateLimitKey returns the bucket a request is counted against, or "" if
+// it has none.
+type RateLimitKey func(r *http.Request) string
+
+// ClientIPKey keys requests by client IP, as resolved by RealIP.
+func ClientIPKey(r *http.Request) string {
+	return "ip:" + ClientIP(r)
+}
+
+// UserKey keys requests by the authenticated user. It must run after
+// AuthMiddleware.
+func UserKey(r *http.Request) string {
+	if user := GetUserFromContext(r.Context()); user != nil {
+		return "user:" + user.ID
+	}
+	return ""
+}
+
+// maxUsernameBody bounds how much of a request body UsernameKey reads.
+const maxUsernameBody = 64 << 10
+
+// UsernameKey keys login and registration requests by the username in
+// their JSON body, so guessing one account's password from many addresses
+// is limited too. The body is left for the handler to read.
+func UsernameKey(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxUsernameBody))
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+	if err != nil {
+		return ""
+	}
+	var req struct {
+		Username string `json:"username"`
+	}
+	if json.Unmarshal(body, &req) != nil || req.Username == "" {
+		return ""
+	}
+	return "user:" + strings.ToLower(req.Username)
+}
+
+// RateLimitMiddleware counts each request against every key the key
+// functions return, and replies 429 Too Many Requests with a Retry-After
+// header once any of them is over the limit.
+func RateLimitMiddleware(rl *RateLimiter, keys ...RateLimitKey) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, key := range keys {
+				k := key(r)
+				if k == "" {
+					continue
+				}
+				if ok, retryAfter := rl.Allow(k); !ok {
+					w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+					LocalizedError(w, r, "Too many requests", http.StatusTooManyRequests)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
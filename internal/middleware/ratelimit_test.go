@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/plugins"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	rl := NewRateLimiter(func() RateLimit { return RateLimit{Rate: 6, Burst: 2} })
+	defer rl.Stop()
+
+	for i := range 2 {
+		if ok, _ := rl.Allow("a"); !ok {
+			t.Fatalf("request %d within burst rejected", i+1)
+		}
+	}
+	ok, retryAfter := rl.Allow("a")
+	if ok {
+		t.Fatal("request over burst allowed")
+	}
+	// 6 per minute refills a token every 10 seconds
+	if retryAfter <= 0 || retryAfter > 10*time.Second {
+		t.Errorf("retryAfter = %v, want up to 10s", retryAfter)
+	}
+	if ok, _ := rl.Allow("b"); !ok {
+		t.Error("other key rejected")
+	}
+}
+
+func TestRateLimiter_LimitChanges(t *testing.T) {
+	limit := RateLimit{Rate: 1, Burst: 1}
+	rl := NewRateLimiter(func() RateLimit { return limit })
+	defer rl.Stop()
+	rl.refresh = 0
+
+	rl.Allow("a")
+	if ok, _ := rl.Allow("a"); ok {
+		t.Fatal("request over burst allowed")
+	}
+
+	limit = RateLimit{}
+	for range 5 {
+		if ok, _ := rl.Allow("a"); !ok {
+			t.Fatal("request rejected with rate limiting disabled")
+		}
+	}
+
+	limit = RateLimit{Rate: 1, Burst: 3}
+	for i := range 3 {
+		if ok, _ := rl.Allow("a"); !ok {
+			t.Fatalf("request %d within new burst rejected", i+1)
+		}
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	rl := NewRateLimiter(func() RateLimit { return RateLimit{Rate: 1, Burst: 1} })
+	defer rl.Stop()
+
+	var body string
+	handler := RateLimitMiddleware(rl, ClientIPKey, UsernameKey)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	login := func(addr, username string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(`{"username":"`+username+`"}`))
+		req.RemoteAddr = addr + ":1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := login("10.0.0.1", "alice"); rec.Code != http.StatusOK || body != `{"username":"alice"}` {
+		t.Fatalf("first request: status %d, handler read %q", rec.Code, body)
+	}
+
+	// Same address, different account
+	rec := login("10.0.0.1", "bob")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429 for the same IP", rec.Code)
+	}
+	if n, err := strconv.Atoi(rec.Header().Get("Retry-After")); err != nil || n < 1 || n > 60 {
+		t.Errorf("Retry-After = %q, want 1-60 seconds", rec.Header().Get("Retry-After"))
+	}
+
+	// Different address, same account
+	if rec := login("10.0.0.2", "ALICE"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429 for the same username", rec.Code)
+	}
+
+	if rec := login("10.0.0.3", "carol"); rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200 for a new IP and username", rec.Code)
+	}
+}
+
+func TestUserKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/sessions", nil)
+	if got := UserKey(req); got != "" {
+		t.Errorf("UserKey() = %q without a user, want empty", got)
+	}
+	req = req.WithContext(context.WithValue(req.Context(), UserContextKey, &plugins.User{ID: "u1"}))
+	if got := UserKey(req); got != "user:u1" {
+		t.Errorf("UserKey() = %q, want user:u1", got)
+	}
+}
@@ -26,12 +26,12 @@ func RequireRole(roles ...string) func(http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			user := GetUserFromContext(r.Context())
 			if user == nil {
-				http.Error(w, "Authentication required", http.StatusUnauthorized)
+				LocalizedError(w, r, "Authentication required", http.StatusUnauthorized)
 				return
 			}
 
 			if !HasRole(user.Roles, roles...) {
-				http.Error(w, "Insufficient permissions", http.StatusForbidden)
+				LocalizedError(w, r, "Insufficient permissions", http.StatusForbidden)
 				return
 			}
 
@@ -49,7 +49,7 @@ func RequireTenantRole(roles ...string) func(http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			user := GetUserFromContext(r.Context())
 			if user == nil {
-				http.Error(w, "Authentication required", http.StatusUnauthorized)
+				LocalizedError(w, r, "Authentication required", http.StatusUnauthorized)
 				return
 			}
 
@@ -62,14 +62,14 @@ func RequireTenantRole(roles ...string) func(http.Handler) http.Handler {
 			// Check tenant-scoped roles from user metadata
 			tenant := GetTenantFromContext(r.Context())
 			if tenant == nil {
-				http.Error(w, "Tenant context required", http.StatusBadRequest)
+				LocalizedError(w, r, "Tenant context required", http.StatusBadRequest)
 				return
 			}
 
 			// Get tenant roles from metadata
 			tenantRoles := getTenantRolesFromUser(user)
 			if !HasTenantRole(tenantRoles, roles...) {
-				http.Error(w, "Insufficient tenant permissions", http.StatusForbidden)
+				LocalizedError(w, r, "Insufficient tenant permissions", http.StatusForbidden)
 				return
 			}
 
@@ -1,8 +1,12 @@
 package middleware
 
 import (
+	"bufio"
 	"context"
+	"fmt"
+	"net"
 	"net/http"
+	"regexp"
 
 	"github.com/google/uuid"
 )
@@ -15,13 +19,20 @@ const (
 	RequestIDHeader = "X-Request-ID"
 )
 
+// requestIDPattern limits the request IDs accepted from clients and
+// proxies, since they are echoed into responses and stored in audit logs.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
 // RequestID is middleware that generates a UUID for each request,
 // adds it to the request context, and sets it as a response header.
+// Plain-text error responses written with http.Error also get the ID
+// appended to their body, so it shows up wherever the message is displayed.
 func RequestID(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Use existing request ID from header if present, otherwise generate one
+		// Use a well-formed request ID from the header if present,
+		// otherwise generate one
 		reqID := r.Header.Get(RequestIDHeader)
-		if reqID == "" {
+		if !requestIDPattern.MatchString(reqID) {
 			reqID = uuid.New().String()
 		}
 
@@ -30,7 +41,11 @@ func RequestID(next http.Handler) http.Handler {
 
 		// Add to context
 		ctx := context.WithValue(r.Context(), RequestIDKey, reqID)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		ew := &errorIDWriter{ResponseWriter: w}
+		next.ServeHTTP(ew, r.WithContext(ctx))
+		if ew.annotate && !ew.hijacked {
+			fmt.Fprintf(w, "Request ID: %s\n", reqID)
+		}
 	})
 }
 
@@ -41,3 +56,56 @@ func GetRequestID(ctx context.Context) string {
 	}
 	return ""
 }
+
+// errorIDWriter notes whether a response is an http.Error reply, whose
+// body can safely have the request ID appended.
+type errorIDWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	annotate    bool
+	hijacked    bool
+}
+
+func (w *errorIDWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		h := w.Header()
+		w.annotate = code >= http.StatusBadRequest &&
+			h.Get("Content-Type") == "text/plain; charset=utf-8" &&
+			h.Get("Content-Length") == "" &&
+			h.Get("Content-Encoding") == ""
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *errorIDWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush supports streaming responses such as server-sent events.
+func (w *errorIDWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack supports WebSocket and raw TCP proxying.
+func (w *errorIDWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := hj.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *errorIDWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestID_GeneratesAndPropagates(t *testing.T) {
+	var fromCtx string
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fromCtx = GetRequestID(r.Context())
+		w.Write([]byte("OK"))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	header := rec.Header().Get(RequestIDHeader)
+	if header == "" || header != fromCtx {
+		t.Errorf("header = %q, context = %q, want the same generated ID", header, fromCtx)
+	}
+	if rec.Body.String() != "OK" {
+		t.Errorf("body = %q, want success body unchanged", rec.Body.String())
+	}
+}
+
+func TestRequestID_IncomingHeader(t *testing.T) {
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		incoming string
+		kept     bool
+	}{
+		{"abc-123", true},
+		{"trace.id:42_x", true},
+		{"has space", false},
+		{"line\nbreak", false},
+		{strings.Repeat("a", 129), false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(RequestIDHeader, tt.incoming)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		got := rec.Header().Get(RequestIDHeader)
+		if kept := got == tt.incoming; kept != tt.kept {
+			t.Errorf("incoming %q: response ID %q, kept = %v, want %v", tt.incoming, got, kept, tt.kept)
+		}
+	}
+}
+
+func TestRequestID_AppendsToErrorBody(t *testing.T) {
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/error":
+			http.Error(w, "Session not found", http.StatusNotFound)
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status":"invalid"}`))
+		}
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/error", nil)
+	req.Header.Set(RequestIDHeader, "req-42")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if want := "Session not found\nRequest ID: req-42\n"; rec.Body.String() != want {
+		t.Errorf("error body = %q, want %q", rec.Body.String(), want)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/json", nil))
+	if want := `{"status":"invalid"}`; rec.Body.String() != want {
+		t.Errorf("JSON body = %q, want it unchanged", rec.Body.String())
+	}
+}
+
+func TestRequestID_PreservesFlusher(t *testing.T) {
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("wrapped writer does not implement http.Flusher")
+		}
+		if _, ok := w.(http.Hijacker); !ok {
+			t.Error("wrapped writer does not implement http.Hijacker")
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
@@ -27,19 +27,19 @@ func TenantMiddleware(database *db.DB) func(http.Handler) http.Handler {
 
 			tenant, err := database.GetTenant(tenantID)
 			if err != nil {
-				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				LocalizedError(w, r, "Internal server error", http.StatusInternalServerError)
 				return
 			}
 			if tenant == nil {
 				// Try by slug
 				tenant, err = database.GetTenantBySlug(tenantID)
 				if err != nil {
-					http.Error(w, "Internal server error", http.StatusInternalServerError)
+					LocalizedError(w, r, "Internal server error", http.StatusInternalServerError)
 					return
 				}
 			}
 			if tenant == nil {
-				http.Error(w, "Tenant not found", http.StatusNotFound)
+				LocalizedError(w, r, "Tenant not found", http.StatusNotFound)
 				return
 			}
 
@@ -74,7 +74,7 @@ func RequireTenantAccess() func(http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			user := GetUserFromContext(r.Context())
 			if user == nil {
-				http.Error(w, "Authentication required", http.StatusUnauthorized)
+				LocalizedError(w, r, "Authentication required", http.StatusUnauthorized)
 				return
 			}
 
@@ -86,7 +86,7 @@ func RequireTenantAccess() func(http.Handler) http.Handler {
 
 			tenant := GetTenantFromContext(r.Context())
 			if tenant == nil {
-				http.Error(w, "Tenant context required", http.StatusBadRequest)
+				LocalizedError(w, r, "Tenant context required", http.StatusBadRequest)
 				return
 			}
 
@@ -96,7 +96,7 @@ func RequireTenantAccess() func(http.Handler) http.Handler {
 				return
 			}
 
-			http.Error(w, "Access denied: user does not belong to this tenant", http.StatusForbidden)
+			LocalizedError(w, r, "Access denied: user does not belong to this tenant", http.StatusForbidden)
 		})
 	}
 }
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing is middleware that records a server span for each request,
+// continuing any trace the caller propagated in a traceparent header.
+// It must wrap the ServeMux directly so spans can be named after the
+// matched route; place it inside RequestID so spans carry the request ID.
+func Tracing(next http.Handler) http.Handler {
+	tagged := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := GetRequestID(r.Context()); id != "" {
+			trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("sortie.request_id", id))
+		}
+		next.ServeHTTP(w, r)
+	})
+	return otelhttp.NewHandler(tagged, "http.request",
+		otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+			if r.Pattern != "" {
+				return r.Method + " " + r.Pattern
+			}
+			return r.Method
+		}))
+}
@@ -0,0 +1,145 @@
+// Package notify delivers operator notifications for background features
+// such as health watching, SLO alerts and account cleanup. Each feature
+// defines its own event type and log notifier; posting events to a
+// webhook and pushing them to connected admins are shared here.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/ssrf"
+)
+
+// webhookTimeout bounds each webhook request.
+const webhookTimeout = 10 * time.Second
+
+// Notifier delivers events of type T to an external destination.
+type Notifier[T any] interface {
+	// Notify sends a single event. Errors are logged by the caller and do
+	// not stop the feature that raised the event.
+	Notify(ctx context.Context, event T) error
+
+	// Name returns the notifier's name for logging.
+	Name() string
+}
+
+// AllowlistFunc returns a tenant's outbound allowlist.
+type AllowlistFunc func(tenantID string) (ssrf.Allowlist, error)
+
+// TenantAllowlists returns an AllowlistFunc that reads tenants' outbound
+// allowlists from database. A tenant that does not exist has none.
+func TenantAllowlists(database *db.DB) AllowlistFunc {
+	return func(tenantID string) (ssrf.Allowlist, error) {
+		tenant, err := database.GetTenant(tenantID)
+		if err != nil || tenant == nil {
+			return ssrf.Allowlist{}, err
+		}
+		return ssrf.ParseAllowlist(tenant.Settings.OutboundAllowlist)
+	}
+}
+
+// Webhook POSTs each event as JSON to an HTTP endpoint. Requests go through
+// the outbound guard, so the endpoint cannot reach internal addresses the
+// server-wide allowlist does not name.
+type Webhook[T any] struct {
+	Endpoint string
+	// Tenant returns the tenant an event belongs to, if any. That tenant's
+	// outbound allowlist, read with Allowlist, then also limits where the
+	// event may be sent.
+	Tenant    func(event T) string
+	Allowlist AllowlistFunc
+
+	guard  *ssrf.Guard
+	client *http.Client // For events without a tenant allowlist
+}
+
+// NewWebhook creates a Webhook for the given endpoint whose requests guard
+// checks.
+func NewWebhook[T any](endpoint string, guard *ssrf.Guard) *Webhook[T] {
+	return &Webhook[T]{
+		Endpoint: endpoint,
+		guard:    guard,
+		client:   guard.Client(webhookTimeout, ssrf.Allowlist{}),
+	}
+}
+
+// clientFor returns the client to send event with, narrowed to the
+// allowlist of the event's tenant when it has one.
+func (n *Webhook[T]) clientFor(event T) (*http.Client, error) {
+	if n.Tenant == nil || n.Allowlist == nil {
+		return n.client, nil
+	}
+	tenantID := n.Tenant(event)
+	if tenantID == "" {
+		return n.client, nil
+	}
+	only, err := n.Allowlist(tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get outbound allowlist of tenant %s: %w", tenantID, err)
+	}
+	if only.Empty() {
+		return n.client, nil
+	}
+	return n.guard.Client(webhookTimeout, only), nil
+}
+
+// Notify sends the event to the configured endpoint. Any non-2xx response is
+// treated as an error.
+func (n *Webhook[T]) Notify(ctx context.Context, event T) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	client, err := n.clientFor(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Name returns "webhook".
+func (n *Webhook[T]) Name() string { return "webhook" }
+
+// RolePublisher sends a server-sent event to every connected user with a
+// role. It is satisfied by *sse.Hub.
+type RolePublisher interface {
+	PublishToRole(role, event string, payload any)
+}
+
+// Admin pushes each event to connected admins as a server-sent event.
+type Admin[T any] struct {
+	Publisher RolePublisher
+	Event     string // Server-sent event name, such as "health"
+}
+
+// Notify publishes the event to admins.
+func (n *Admin[T]) Notify(_ context.Context, event T) error {
+	n.Publisher.PublishToRole("admin", n.Event, event)
+	return nil
+}
+
+// Name returns "sse".
+func (n *Admin[T]) Name() string { return "sse" }
@@ -0,0 +1,125 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/ssrf"
+)
+
+type testEvent struct {
+	Name   string `json:"name"`
+	Tenant string `json:"tenant,omitempty"`
+}
+
+func allowlist(t *testing.T, entries ...string) ssrf.Allowlist {
+	t.Helper()
+	a, err := ssrf.ParseAllowlist(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestWebhook(t *testing.T) {
+	var got testEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", ct)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	if err := NewWebhook[testEvent](srv.URL, ssrf.NewGuard(allowlist(t, "127.0.0.1"))).Notify(context.Background(), testEvent{Name: "db"}); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if got.Name != "db" {
+		t.Errorf("received %+v, want db event", got)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	if err := NewWebhook[testEvent](failing.URL, ssrf.NewGuard(allowlist(t, "127.0.0.1"))).Notify(context.Background(), testEvent{}); err == nil {
+		t.Error("expected error for non-2xx response")
+	}
+}
+
+func TestWebhook_Guarded(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	t.Run("internal address refused by default", func(t *testing.T) {
+		err := NewWebhook[testEvent](srv.URL, ssrf.NewGuard(ssrf.Allowlist{})).Notify(context.Background(), testEvent{Name: "db"})
+		if !errors.Is(err, ssrf.ErrBlocked) {
+			t.Errorf("Notify() error = %v, want ErrBlocked", err)
+		}
+	})
+
+	t.Run("metadata endpoint refused", func(t *testing.T) {
+		n := NewWebhook[testEvent]("http://169.254.169.254/latest/meta-data/", ssrf.NewGuard(allowlist(t, "127.0.0.1")))
+		if err := n.Notify(context.Background(), testEvent{}); !errors.Is(err, ssrf.ErrBlocked) {
+			t.Errorf("Notify() error = %v, want ErrBlocked", err)
+		}
+	})
+
+	t.Run("tenant allowlist narrows the send", func(t *testing.T) {
+		n := NewWebhook[testEvent](srv.URL, ssrf.NewGuard(allowlist(t, "127.0.0.1")))
+		n.Tenant = func(e testEvent) string { return e.Tenant }
+		n.Allowlist = func(tenantID string) (ssrf.Allowlist, error) {
+			if tenantID == "acme" {
+				return allowlist(t, "hooks.acme.example"), nil
+			}
+			return ssrf.Allowlist{}, nil
+		}
+		if err := n.Notify(context.Background(), testEvent{Tenant: "acme"}); !errors.Is(err, ssrf.ErrBlocked) {
+			t.Errorf("Notify() for acme error = %v, want ErrBlocked", err)
+		}
+		if err := n.Notify(context.Background(), testEvent{Tenant: "globex"}); err != nil {
+			t.Errorf("Notify() for tenant without allowlist error = %v", err)
+		}
+		if err := n.Notify(context.Background(), testEvent{}); err != nil {
+			t.Errorf("Notify() without tenant error = %v", err)
+		}
+	})
+
+	if got := hits.Load(); got != 2 {
+		t.Errorf("server received %d requests, want 2 (blocked sends never connect)", got)
+	}
+}
+
+func TestAdmin(t *testing.T) {
+	pub := &fakePublisher{}
+	n := &Admin[testEvent]{Publisher: pub, Event: "health"}
+	if err := n.Notify(context.Background(), testEvent{Name: "db"}); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if pub.role != "admin" || pub.event != "health" || pub.payload.(testEvent).Name != "db" {
+		t.Errorf("published %q to %q with %v, want health to admin", pub.event, pub.role, pub.payload)
+	}
+}
+
+type fakePublisher struct {
+	role, event string
+	payload     any
+}
+
+func (p *fakePublisher) PublishToRole(role, event string, payload any) {
+	p.role, p.event, p.payload = role, event, payload
+}
@@ -0,0 +1,144 @@
+// Package offline enforces air-gapped operation. In offline mode,
+// connections the server makes may only reach internal addresses
+// (loopback, private, link-local and cluster ranges) or destinations on
+// the outbound allowlist; anything on the public internet fails with
+// ErrOffline before a connection is attempted.
+//
+// Every HTTP client and dialer that calls out of the server is built from
+// this package, so the rule holds for features added later without each
+// one having to remember it. A test checks that no other package creates
+// its own client.
+package offline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/ssrf"
+)
+
+// ErrOffline is returned for a public destination in offline mode.
+var ErrOffline = errors.New("outbound connection to the internet blocked in offline mode")
+
+// state is the process-wide offline policy. It is set once at startup.
+var state struct {
+	enabled atomic.Bool
+	allow   atomic.Pointer[ssrf.Allowlist]
+}
+
+// lookup resolves host names. Tests replace it.
+var lookup = func(ctx context.Context, host string) ([]netip.Addr, error) {
+	return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+}
+
+// Enable turns on offline mode. Public destinations matching allow may
+// still be reached.
+func Enable(allow ssrf.Allowlist) {
+	state.allow.Store(&allow)
+	state.enabled.Store(true)
+}
+
+// Disable turns off offline mode.
+func Disable() {
+	state.enabled.Store(false)
+	state.allow.Store(nil)
+}
+
+// Enabled reports whether offline mode is on.
+func Enabled() bool {
+	return state.enabled.Load()
+}
+
+// Check returns the addresses a connection to host would use, or an error
+// wrapping ErrOffline if offline mode forbids it. Outside offline mode it
+// returns nil addresses and no error, and callers dial host as usual.
+func Check(ctx context.Context, host string) ([]netip.Addr, error) {
+	if !Enabled() {
+		return nil, nil
+	}
+	host = strings.TrimSuffix(strings.ToLower(strings.Trim(host, "[]")), ".")
+
+	var addrs []netip.Addr
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = []netip.Addr{addr}
+	} else {
+		if addrs, err = lookup(ctx, host); err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", host, err)
+		}
+		if len(addrs) == 0 {
+			return nil, fmt.Errorf("failed to resolve %s: no addresses", host)
+		}
+	}
+
+	var allow ssrf.Allowlist
+	if a := state.allow.Load(); a != nil {
+		allow = *a
+	}
+	for i, addr := range addrs {
+		addr = addr.Unmap().WithZone("")
+		addrs[i] = addr
+		if !ssrf.IsInternal(addr) && !allow.Allows(host, addr) {
+			return nil, fmt.Errorf("%w: %s is a public address", ErrOffline, host)
+		}
+	}
+	return addrs, nil
+}
+
+// Dial connects like d.DialContext, refusing public destinations in
+// offline mode. It connects to the checked addresses rather than
+// resolving the name again.
+func Dial(ctx context.Context, d *net.Dialer, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := Check(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if addrs == nil {
+		return d.DialContext(ctx, network, address)
+	}
+	var dialErr error
+	for _, addr := range addrs {
+		conn, err := d.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		dialErr = err
+	}
+	return nil, dialErr
+}
+
+// Transport returns an HTTP transport with the standard settings that
+// refuses public destinations in offline mode. Proxy environment
+// variables are ignored in offline mode, since a proxy would make the
+// connection this package cannot see.
+func Transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	t.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return Dial(ctx, dialer, network, address)
+	}
+	t.Proxy = func(req *http.Request) (*url.URL, error) {
+		if Enabled() {
+			return nil, nil
+		}
+		return http.ProxyFromEnvironment(req)
+	}
+	return t
+}
+
+// Client returns an HTTP client with the given timeout, zero for none,
+// that refuses public destinations in offline mode.
+func Client(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: Transport()}
+}
@@ -0,0 +1,169 @@
+package offline
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/ssrf"
+)
+
+// fakeOffline enables offline mode with a stub resolver for the test.
+func fakeOffline(t *testing.T, allow []string, hosts map[string][]string) {
+	t.Helper()
+	a, err := ssrf.ParseAllowlist(allow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := lookup
+	lookup = func(_ context.Context, host string) ([]netip.Addr, error) {
+		ips, ok := hosts[host]
+		if !ok {
+			return nil, errors.New("no such host")
+		}
+		var addrs []netip.Addr
+		for _, ip := range ips {
+			addrs = append(addrs, netip.MustParseAddr(ip))
+		}
+		return addrs, nil
+	}
+	Enable(a)
+	t.Cleanup(func() {
+		Disable()
+		lookup = orig
+	})
+}
+
+func TestCheck(t *testing.T) {
+	fakeOffline(t, []string{"updates.example.com", "203.0.113.0/24"}, map[string][]string{
+		"github.com":          {"140.82.112.3"},
+		"updates.example.com": {"93.184.216.34"},
+		"mirror.example.com":  {"203.0.113.7"},
+		"vault.corp.example":  {"10.0.0.8"},
+		"mixed.example.com":   {"10.0.0.9", "93.184.216.35"},
+	})
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		host    string
+		allowed bool
+	}{
+		{"github.com", false},
+		{"8.8.8.8", false},
+		{"[2001:4860:4860::8888]", false},
+		{"mixed.example.com", false},
+		{"updates.example.com", true}, // Allowlisted name
+		{"mirror.example.com", true},  // Allowlisted range
+		{"vault.corp.example", true},
+		{"127.0.0.1", true},
+		{"[::1]", true},
+		{"192.168.1.10", true},
+	} {
+		_, err := Check(ctx, tt.host)
+		if tt.allowed && err != nil {
+			t.Errorf("Check(%s) error = %v, want allowed", tt.host, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrOffline) {
+			t.Errorf("Check(%s) error = %v, want ErrOffline", tt.host, err)
+		}
+	}
+
+	if _, err := Check(ctx, "unknown.example.com"); err == nil || errors.Is(err, ErrOffline) {
+		t.Errorf("unresolvable host: error = %v, want a lookup error", err)
+	}
+}
+
+func TestCheck_Disabled(t *testing.T) {
+	Disable()
+	addrs, err := Check(context.Background(), "github.com")
+	if err != nil || addrs != nil {
+		t.Errorf("Check() = %v, %v; want no check outside offline mode", addrs, err)
+	}
+}
+
+func TestClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(srv.URL, "http://"))
+
+	fakeOffline(t, nil, map[string][]string{
+		"local.test":  {"127.0.0.1"},
+		"public.test": {"93.184.216.34"},
+	})
+	client := Client(5 * time.Second)
+
+	resp, err := client.Get("http://local.test:" + port + "/")
+	if err != nil {
+		t.Fatalf("internal destination: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("status = %d, want 204", resp.StatusCode)
+	}
+
+	if _, err := client.Get("http://public.test:" + port + "/"); !errors.Is(err, ErrOffline) {
+		t.Errorf("public destination: error = %v, want ErrOffline", err)
+	}
+}
+
+// TestNoUnguardedClients checks that outbound HTTP goes through this
+// package, so that new code cannot bypass offline mode by accident.
+func TestNoUnguardedClients(t *testing.T) {
+	forbidden := []string{
+		"&http.Client{",
+		"http.Client{}",
+		"http.DefaultClient",
+		"http.DefaultTransport",
+		"http.Get(",
+		"http.Post(",
+		"http.Head(",
+		"http.PostForm(",
+	}
+	exempt := map[string]bool{
+		"internal/offline": true, // Builds the clients
+		"internal/ssrf":    true, // Guard clients apply a stricter policy
+		"tests":            true,
+		"web":              true,
+		"node_modules":     true,
+	}
+	root := "../.."
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		if d.IsDir() {
+			if exempt[filepath.ToSlash(rel)] || strings.HasPrefix(d.Name(), ".") && rel != "." {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, f := range forbidden {
+			if strings.Contains(string(data), f) {
+				t.Errorf("%s uses %s; build HTTP clients with offline.Client", rel, f)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/plugins"
+)
+
+// APITokenPrefix starts every personal access token, so they are easy to
+// tell from JWTs and to find with secret scanners.
+const APITokenPrefix = "sortie_pat_"
+
+// API token scopes.
+const (
+	ScopeRead  = "read"  // GET, HEAD, and OPTIONS requests
+	ScopeWrite = "write" // Requests with any method
+	ScopeAdmin = "admin" // Keep the user's admin and tenant-admin roles
+)
+
+// APITokenScopes lists the valid API token scopes.
+var APITokenScopes = []string{ScopeRead, ScopeWrite, ScopeAdmin}
+
+// Metadata keys set on users authenticated with an API token.
+const (
+	MetadataAPITokenID     = "api_token_id"
+	MetadataAPITokenScopes = "api_token_scopes" // Comma-separated
+)
+
+// apiTokenTouchInterval limits how often a token's last-used time is
+// written, so scripts polling the API do not write on every request.
+const apiTokenTouchInterval = time.Minute
+
+// ErrInvalidScope is returned when an API token is requested with an
+// unknown scope or none at all.
+var ErrInvalidScope = errors.New("invalid scope")
+
+// CreateAPIToken mints a personal access token for user and stores its
+// hash. The returned token is the only copy of the secret.
+func CreateAPIToken(database *db.DB, user *db.User, name string, scopes []string, expiry time.Duration) (string, *db.APIToken, error) {
+	if len(scopes) == 0 {
+		return "", nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+	for _, s := range scopes {
+		if !slices.Contains(APITokenScopes, s) {
+			return "", nil, fmt.Errorf("%w: %q", ErrInvalidScope, s)
+		}
+	}
+	if !slices.Contains(scopes, ScopeRead) && !slices.Contains(scopes, ScopeWrite) {
+		return "", nil, fmt.Errorf("%w: the admin scope needs read or write", ErrInvalidScope)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := APITokenPrefix + hex.EncodeToString(secret)
+
+	now := time.Now()
+	record := db.APIToken{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		Name:      name,
+		TokenHash: hashAPIToken(token),
+		Prefix:    token[:len(APITokenPrefix)+8],
+		Scopes:    strings.Join(scopes, ","),
+		CreatedAt: now,
+		ExpiresAt: now.Add(expiry),
+	}
+	if err := database.CreateAPIToken(record); err != nil {
+		return "", nil, fmt.Errorf("failed to store API token: %w", err)
+	}
+	return token, &record, nil
+}
+
+// ScopesAllow reports whether an API token with the given comma-separated
+// scopes may make a request with method.
+func ScopesAllow(scopes, method string) bool {
+	list := strings.Split(scopes, ",")
+	if slices.Contains(list, ScopeWrite) {
+		return true
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return slices.Contains(list, ScopeRead)
+	}
+	return false
+}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// authenticateAPIToken authenticates a personal access token. The user gets
+// the token's scopes in their metadata, and loses their admin roles unless
+// the token has the admin scope.
+func (p *JWTAuthProvider) authenticateAPIToken(ctx context.Context, token string) (*plugins.AuthResult, error) {
+	if p.database == nil {
+		return nil, errors.New("database not configured")
+	}
+
+	stored, err := p.database.GetAPITokenByHash(hashAPIToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	now := time.Now()
+	if stored == nil {
+		return &plugins.AuthResult{Authenticated: false, Message: "Invalid token"}, nil
+	}
+	if !now.Before(stored.ExpiresAt) {
+		return &plugins.AuthResult{Authenticated: false, Message: "Token expired"}, nil
+	}
+
+	user, err := p.database.GetUserByID(stored.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if user == nil {
+		return &plugins.AuthResult{Authenticated: false, Message: "Invalid token"}, nil
+	}
+	if user.Disabled {
+		return &plugins.AuthResult{Authenticated: false, Message: "Account disabled"}, nil
+	}
+
+	if stored.LastUsedAt == nil || now.Sub(*stored.LastUsedAt) >= apiTokenTouchInterval {
+		if err := p.database.TouchAPIToken(stored.ID, now); err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+	}
+
+	scopes := strings.Split(stored.Scopes, ",")
+	roles, tenantRoles := user.Roles, user.TenantRoles
+	if !slices.Contains(scopes, ScopeAdmin) {
+		roles = slices.DeleteFunc(slices.Clone(roles), func(r string) bool { return r == "admin" })
+		tenantRoles = slices.DeleteFunc(slices.Clone(tenantRoles), func(r string) bool { return r == "tenant-admin" })
+	}
+
+	metadata := map[string]string{
+		MetadataAPITokenID:     stored.ID,
+		MetadataAPITokenScopes: stored.Scopes,
+	}
+	if user.TenantID != "" {
+		metadata["tenant_id"] = user.TenantID
+	}
+	if user.Locale != "" {
+		metadata["locale"] = user.Locale
+	}
+
+	expiresAt := stored.ExpiresAt
+	return &plugins.AuthResult{
+		Authenticated: true,
+		User: &plugins.User{
+			ID:       user.ID,
+			Username: user.Username,
+			Email:    user.Email,
+			Name:     user.DisplayName,
+			Roles:    roles,
+			Groups:   tenantRoles, // Tenant roles stored in Groups
+			Metadata: metadata,
+		},
+		Token:     token,
+		ExpiresAt: &expiresAt,
+	}, nil
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateAPIToken(t *testing.T) {
+	_, database := setupTestProvider(t)
+	user := seedTestUser(t, database, "ci", "password123", []string{"user"})
+
+	token, record, err := CreateAPIToken(database, user, "deploy", []string{ScopeRead, ScopeWrite}, time.Hour)
+	if err != nil {
+		t.Fatalf("CreateAPIToken() error = %v", err)
+	}
+	if !strings.HasPrefix(token, APITokenPrefix) || !strings.HasPrefix(token, record.Prefix) {
+		t.Errorf("token = %q, prefix = %q", token, record.Prefix)
+	}
+	if record.TokenHash == "" || strings.Contains(record.TokenHash, token) {
+		t.Error("stored record does not hold a hash of the token")
+	}
+
+	for _, scopes := range [][]string{nil, {"delete"}, {ScopeAdmin}} {
+		if _, _, err := CreateAPIToken(database, user, "bad", scopes, time.Hour); !errors.Is(err, ErrInvalidScope) {
+			t.Errorf("CreateAPIToken(%v) error = %v, want ErrInvalidScope", scopes, err)
+		}
+	}
+}
+
+func TestAuthenticate_APIToken(t *testing.T) {
+	provider, database := setupTestProvider(t)
+	admin := seedTestUser(t, database, "root", "password123", []string{"admin"})
+	ctx := context.Background()
+
+	token, record, err := CreateAPIToken(database, admin, "scripts", []string{ScopeRead}, time.Hour)
+	if err != nil {
+		t.Fatalf("CreateAPIToken() error = %v", err)
+	}
+
+	result, err := provider.Authenticate(ctx, token)
+	if err != nil || !result.Authenticated {
+		t.Fatalf("Authenticate() = %+v, %v; want authenticated", result, err)
+	}
+	if result.User.ID != admin.ID || result.User.Metadata[MetadataAPITokenID] != record.ID ||
+		result.User.Metadata[MetadataAPITokenScopes] != ScopeRead {
+		t.Errorf("user = %+v", result.User)
+	}
+	if slices.Contains(result.User.Roles, "admin") {
+		t.Error("token without the admin scope kept the admin role")
+	}
+	if stored, _ := database.GetAPITokenByHash(hashAPIToken(token)); stored.LastUsedAt == nil {
+		t.Error("LastUsedAt not recorded")
+	}
+
+	adminToken, _, _ := CreateAPIToken(database, admin, "admin scripts", []string{ScopeWrite, ScopeAdmin}, time.Hour)
+	if result, _ := provider.Authenticate(ctx, adminToken); !slices.Contains(result.User.Roles, "admin") {
+		t.Errorf("admin-scoped token roles = %v, want admin", result.User.Roles)
+	}
+
+	t.Run("rejected tokens", func(t *testing.T) {
+		expired, _, _ := CreateAPIToken(database, admin, "expired", []string{ScopeRead}, -time.Minute)
+		tests := map[string]string{
+			"unknown": APITokenPrefix + "0000",
+			"expired": expired,
+		}
+		for name, tok := range tests {
+			if result, err := provider.Authenticate(ctx, tok); err != nil || result.Authenticated {
+				t.Errorf("%s: Authenticate() = %+v, %v; want rejected", name, result, err)
+			}
+		}
+	})
+
+	t.Run("disabled user", func(t *testing.T) {
+		if err := database.SetUserDisabled(admin.ID, true); err != nil {
+			t.Fatalf("SetUserDisabled() error = %v", err)
+		}
+		if result, _ := provider.Authenticate(ctx, token); result.Authenticated {
+			t.Error("token of a disabled user authenticated")
+		}
+	})
+}
+
+func TestScopesAllow(t *testing.T) {
+	tests := []struct {
+		scopes, method string
+		want           bool
+	}{
+		{"read", http.MethodGet, true},
+		{"read", http.MethodPost, false},
+		{"read,admin", http.MethodDelete, false},
+		{"write", http.MethodPost, true},
+		{"write", http.MethodGet, true},
+		{"admin", http.MethodGet, false},
+	}
+	for _, tt := range tests {
+		if got := ScopesAllow(tt.scopes, tt.method); got != tt.want {
+			t.Errorf("ScopesAllow(%q, %s) = %v, want %v", tt.scopes, tt.method, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// ErrRefreshTokenRevoked is returned when a refresh token is well-formed but
+// no longer present in the refresh-token store (revoked or never recorded).
+var ErrRefreshTokenRevoked = errors.New("refresh token revoked")
+
+// ClientInfo describes the device a login originates from. It is recorded
+// alongside each refresh token so users can recognise their devices.
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+type clientInfoKey struct{}
+
+// WithClientInfo returns a context carrying the client's device information.
+// Providers read it when issuing refresh tokens.
+func WithClientInfo(ctx context.Context, info ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, info)
+}
+
+func clientInfoFromContext(ctx context.Context) ClientInfo {
+	if info, ok := ctx.Value(clientInfoKey{}).(ClientInfo); ok {
+		return info
+	}
+	return ClientInfo{}
+}
+
+// recordRefreshToken stores a new refresh-token row for the user and returns
+// its ID, which is used as the token's jti and the access token's device ID.
+func recordRefreshToken(ctx context.Context, database *db.DB, user *db.User, expiry time.Duration) (string, error) {
+	info := clientInfoFromContext(ctx)
+	now := time.Now()
+	token := db.RefreshToken{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		UserAgent: info.UserAgent,
+		IPAddress: info.IPAddress,
+		CreatedAt: now,
+		ExpiresAt: now.Add(expiry),
+	}
+	if err := database.CreateRefreshToken(token); err != nil {
+		return "", fmt.Errorf("failed to store refresh token: %w", err)
+	}
+	return token.ID, nil
+}
+
+// rotatedRefreshTokenGrace is how long a rotated refresh token keeps working
+// after it is replaced. It covers clients that never received the rotation
+// response, such as a tab that refreshed while the server restarted or two
+// tabs refreshing at once.
+const rotatedRefreshTokenGrace = time.Minute
+
+// maxRefreshTokenHops bounds how many rotations checkRefreshToken follows
+// from a presented token to its current successor.
+const maxRefreshTokenHops = 5
+
+// checkRefreshToken verifies that the refresh token identified by claims is
+// still present in the store and belongs to the claimed user, and records
+// its use. A token rotated within the grace period resolves to its current
+// successor, whose ID then differs from claims.ID.
+func checkRefreshToken(database *db.DB, claims *Claims) (*db.RefreshToken, error) {
+	if claims.ID == "" {
+		return nil, ErrRefreshTokenRevoked
+	}
+	id := claims.ID
+	var stored *db.RefreshToken
+	for hops := 0; ; hops++ {
+		var err error
+		stored, err = database.GetRefreshToken(id)
+		if err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		if stored == nil || stored.UserID != claims.UserID {
+			return nil, ErrRefreshTokenRevoked
+		}
+		if stored.ReplacedBy == "" {
+			break
+		}
+		if hops == maxRefreshTokenHops || time.Now().After(stored.ExpiresAt) {
+			return nil, ErrRefreshTokenRevoked
+		}
+		id = stored.ReplacedBy
+	}
+	if err := database.TouchRefreshToken(stored.ID, time.Now()); err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return stored, nil
+}
+
+// rotateRefreshToken replaces a stored refresh token with a new one for the
+// same device. The presented token keeps working for the grace period and
+// then expires. It returns the new ID, or the winner's ID if another request
+// rotated the same token first.
+func rotateRefreshToken(database *db.DB, stored *db.RefreshToken, expiry time.Duration) (string, error) {
+	now := time.Now()
+	next := db.RefreshToken{
+		ID:         uuid.New().String(),
+		UserID:     stored.UserID,
+		UserAgent:  stored.UserAgent,
+		IPAddress:  stored.IPAddress,
+		CreatedAt:  stored.CreatedAt,
+		LastUsedAt: &now,
+		ExpiresAt:  now.Add(expiry),
+	}
+	err := database.RotateRefreshToken(stored.ID, next, now.Add(rotatedRefreshTokenGrace))
+	if errors.Is(err, sql.ErrNoRows) {
+		current, err := database.GetRefreshToken(stored.ID)
+		if err != nil {
+			return "", fmt.Errorf("database error: %w", err)
+		}
+		if current == nil || current.ReplacedBy == "" {
+			return "", ErrRefreshTokenRevoked
+		}
+		return current.ReplacedBy, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	return next.ID, nil
+}
+
+// refreshTokenDevice resolves the device a refresh request continues and
+// reports whether a new refresh token must be issued for it. A token that
+// resolved to a successor is answered with that successor rather than being
+// rotated again, so retries do not lengthen the rotation chain.
+func refreshTokenDevice(database *db.DB, claims *Claims, stored *db.RefreshToken, settings TokenSettings) (string, time.Duration, bool, error) {
+	if stored.ID != claims.ID {
+		return stored.ID, time.Until(stored.ExpiresAt), true, nil
+	}
+	if !settings.RotateRefresh {
+		return stored.ID, 0, false, nil
+	}
+	id, err := rotateRefreshToken(database, stored, settings.RefreshExpiry)
+	if err != nil {
+		return "", 0, false, err
+	}
+	return id, settings.RefreshExpiry, true, nil
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -19,6 +22,7 @@ type TokenType string
 const (
 	TokenTypeAccess  TokenType = "access"
 	TokenTypeRefresh TokenType = "refresh"
+	TokenTypeMFA     TokenType = "mfa" // Proves the password step of an MFA login
 )
 
 // Claims represents JWT claims for Sortie tokens
@@ -26,20 +30,35 @@ type Claims struct {
 	jwt.RegisteredClaims
 	UserID      string    `json:"user_id"`
 	Username    string    `json:"username"`
+	Email       string    `json:"email,omitempty"`
 	Roles       []string  `json:"roles"`
 	TokenType   TokenType `json:"token_type"`
 	TenantID    string    `json:"tenant_id,omitempty"`
 	TenantRoles []string  `json:"tenant_roles,omitempty"`
+	DeviceID    string    `json:"device_id,omitempty"` // Refresh-token store ID this token was issued under
+	Locale      string    `json:"locale,omitempty"`    // User's preferred language for server-generated text
+	// AuthTime is when the user last signed in. Refreshed tokens keep it,
+	// so it tells a fresh sign-in apart from a long-lived refresh chain.
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
 }
 
-// LoginResult contains the result of a successful login
+// LoginResult contains the result of a successful login. When the user
+// must also enter a TOTP code, it carries only an MFA token for the second
+// step, and ExpiresIn is that token's lifetime.
 type LoginResult struct {
-	AccessToken  string       `json:"access_token"`
-	RefreshToken string       `json:"refresh_token"`
-	ExpiresIn    int64        `json:"expires_in"`
-	User         *plugins.User `json:"user"`
+	AccessToken      string        `json:"access_token,omitempty"`
+	RefreshToken     string        `json:"refresh_token,omitempty"`
+	ExpiresIn        int64         `json:"expires_in"`
+	User             *plugins.User `json:"user,omitempty"`
+	MFARequired      bool          `json:"mfa_required,omitempty"`
+	MFASetupRequired bool          `json:"mfa_setup_required,omitempty"` // The user must enroll before they can finish
+	MFAToken         string        `json:"mfa_token,omitempty"`
 }
 
+// ErrAccountDisabled is returned when a disabled user attempts to log in or
+// refresh a token.
+var ErrAccountDisabled = errors.New("account disabled")
+
 // JWTAuthProvider implements AuthProvider using JWT tokens
 type JWTAuthProvider struct {
 	config        map[string]string
@@ -140,6 +159,10 @@ func (p *JWTAuthProvider) Authenticate(ctx context.Context, tokenString string)
 		}, nil
 	}
 
+	if strings.HasPrefix(tokenString, APITokenPrefix) {
+		return p.authenticateAPIToken(ctx, tokenString)
+	}
+
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -176,10 +199,34 @@ func (p *JWTAuthProvider) Authenticate(ctx context.Context, tokenString string)
 		}, nil
 	}
 
+	// Disabling an account cuts off its access tokens too, rather than
+	// leaving them valid until they expire
+	if p.database != nil {
+		user, err := p.database.GetUserByID(claims.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		if user != nil && user.Disabled {
+			return &plugins.AuthResult{
+				Authenticated: false,
+				Message:       "Account disabled",
+			}, nil
+		}
+	}
+
 	authMetadata := map[string]string{}
 	if claims.TenantID != "" {
 		authMetadata["tenant_id"] = claims.TenantID
 	}
+	if claims.DeviceID != "" {
+		authMetadata["device_id"] = claims.DeviceID
+	}
+	if claims.Locale != "" {
+		authMetadata["locale"] = claims.Locale
+	}
+	if claims.AuthTime != nil {
+		authMetadata["auth_time"] = strconv.FormatInt(claims.AuthTime.Unix(), 10)
+	}
 
 	expiresAt := claims.ExpiresAt.Time
 	return &plugins.AuthResult{
@@ -187,6 +234,7 @@ func (p *JWTAuthProvider) Authenticate(ctx context.Context, tokenString string)
 		User: &plugins.User{
 			ID:       claims.UserID,
 			Username: claims.Username,
+			Email:    claims.Email,
 			Roles:    claims.Roles,
 			Groups:   claims.TenantRoles, // Tenant roles stored in Groups
 			Metadata: authMetadata,
@@ -196,7 +244,9 @@ func (p *JWTAuthProvider) Authenticate(ctx context.Context, tokenString string)
 	}, nil
 }
 
-// LoginWithCredentials authenticates a user with username and password
+// LoginWithCredentials authenticates a user with username and password.
+// Users with MFA enabled, or required by their tenant, get an MFA challenge
+// in place of tokens.
 func (p *JWTAuthProvider) LoginWithCredentials(ctx context.Context, username, password string) (*LoginResult, error) {
 	if p.database == nil {
 		return nil, errors.New("database not configured")
@@ -214,17 +264,51 @@ func (p *JWTAuthProvider) LoginWithCredentials(ctx context.Context, username, pa
 		return nil, errors.New("invalid credentials")
 	}
 
-	// Generate tokens
-	accessToken, err := p.generateToken(user, TokenTypeAccess)
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+
+	mfa, err := p.database.GetUserMFA(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	required, err := MFARequired(p.database, user)
+	if err != nil {
+		return nil, err
+	}
+	if mfa.Enabled() || required {
+		return p.mfaChallenge(user, mfa.Enabled())
+	}
+
+	return p.IssueTokens(ctx, user)
+}
+
+// IssueTokens logs in a user whose credentials have been checked, recording
+// the device and returning new access and refresh tokens.
+func (p *JWTAuthProvider) IssueTokens(ctx context.Context, user *db.User) (*LoginResult, error) {
+	settings := p.tokenSettings(user)
+
+	// Record the device, then generate tokens bound to it
+	deviceID, err := recordRefreshToken(ctx, p.database, user, settings.RefreshExpiry)
+	if err != nil {
+		return nil, err
+	}
+
+	authTime := time.Now()
+	accessToken, err := p.generateToken(user, TokenTypeAccess, deviceID, authTime, settings.AccessExpiry)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
 
-	refreshToken, err := p.generateToken(user, TokenTypeRefresh)
+	refreshToken, err := p.generateToken(user, TokenTypeRefresh, deviceID, authTime, settings.RefreshExpiry)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
 
+	if err := p.database.UpdateUserLastLogin(user.ID, time.Now()); err != nil {
+		slog.Warn("failed to record last login", "user_id", user.ID, "error", err)
+	}
+
 	loginMetadata := map[string]string{}
 	if user.TenantID != "" {
 		loginMetadata["tenant_id"] = user.TenantID
@@ -233,7 +317,7 @@ func (p *JWTAuthProvider) LoginWithCredentials(ctx context.Context, username, pa
 	return &LoginResult{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
-		ExpiresIn:    int64(p.accessExpiry.Seconds()),
+		ExpiresIn:    int64(settings.AccessExpiry.Seconds()),
 		User: &plugins.User{
 			ID:       user.ID,
 			Username: user.Username,
@@ -267,6 +351,11 @@ func (p *JWTAuthProvider) RefreshAccessToken(ctx context.Context, refreshTokenSt
 		return nil, errors.New("invalid token type")
 	}
 
+	stored, err := checkRefreshToken(p.database, claims)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get fresh user data from database
 	user, err := p.database.GetUserByID(claims.UserID)
 	if err != nil {
@@ -275,9 +364,28 @@ func (p *JWTAuthProvider) RefreshAccessToken(ctx context.Context, refreshTokenSt
 	if user == nil {
 		return nil, errors.New("user not found")
 	}
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+
+	settings := p.tokenSettings(user)
+	authTime := claims.signInTime()
+
+	// Rotate the refresh token if the user's policy requires it, or hand
+	// back the successor of a token that was already rotated
+	deviceID, refreshExpiry, reissue, err := refreshTokenDevice(p.database, claims, stored, settings)
+	if err != nil {
+		return nil, err
+	}
+	if reissue {
+		refreshTokenString, err = p.generateToken(user, TokenTypeRefresh, deviceID, authTime, refreshExpiry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+		}
+	}
 
 	// Generate new access token
-	accessToken, err := p.generateToken(user, TokenTypeAccess)
+	accessToken, err := p.generateToken(user, TokenTypeAccess, deviceID, authTime, settings.AccessExpiry)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
@@ -289,8 +397,8 @@ func (p *JWTAuthProvider) RefreshAccessToken(ctx context.Context, refreshTokenSt
 
 	return &LoginResult{
 		AccessToken:  accessToken,
-		RefreshToken: refreshTokenString, // Return same refresh token
-		ExpiresIn:    int64(p.accessExpiry.Seconds()),
+		RefreshToken: refreshTokenString, // Same refresh token unless rotated
+		ExpiresIn:    int64(settings.AccessExpiry.Seconds()),
 		User: &plugins.User{
 			ID:       user.ID,
 			Username: user.Username,
@@ -302,15 +410,31 @@ func (p *JWTAuthProvider) RefreshAccessToken(ctx context.Context, refreshTokenSt
 	}, nil
 }
 
-// generateToken creates a new JWT token for the user
-func (p *JWTAuthProvider) generateToken(user *db.User, tokenType TokenType) (string, error) {
-	var expiry time.Duration
-	if tokenType == TokenTypeAccess {
-		expiry = p.accessExpiry
-	} else {
-		expiry = p.refreshExpiry
+// tokenSettings resolves the token lifetimes for a user from the global
+// configuration and their tenant's token policy.
+func (p *JWTAuthProvider) tokenSettings(user *db.User) TokenSettings {
+	return tokenSettingsFor(p.database, TokenSettings{
+		AccessExpiry:  p.accessExpiry,
+		RefreshExpiry: p.refreshExpiry,
+	}, user)
+}
+
+// signInTime returns when the user signed in to get a token. Tokens issued
+// before the auth_time claim existed fall back to when they were issued.
+func (c *Claims) signInTime() time.Time {
+	if c.AuthTime != nil {
+		return c.AuthTime.Time
+	}
+	if c.IssuedAt != nil {
+		return c.IssuedAt.Time
 	}
+	return time.Time{}
+}
 
+// generateToken creates a new JWT token for the user that expires after
+// expiry. Refresh tokens use deviceID as their jti; access tokens carry it as
+// the device_id claim. authTime is when the user signed in.
+func (p *JWTAuthProvider) generateToken(user *db.User, tokenType TokenType, deviceID string, authTime time.Time, expiry time.Duration) (string, error) {
 	claims := Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiry)),
@@ -320,10 +444,18 @@ func (p *JWTAuthProvider) generateToken(user *db.User, tokenType TokenType) (str
 		},
 		UserID:      user.ID,
 		Username:    user.Username,
+		Email:       user.Email,
 		Roles:       user.Roles,
 		TokenType:   tokenType,
 		TenantID:    user.TenantID,
 		TenantRoles: user.TenantRoles,
+		Locale:      user.Locale,
+		AuthTime:    jwt.NewNumericDate(authTime),
+	}
+	if tokenType == TokenTypeRefresh {
+		claims.ID = deviceID
+	} else {
+		claims.DeviceID = deviceID
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
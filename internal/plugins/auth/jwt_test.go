@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -201,6 +202,27 @@ func TestLoginWithCredentials(t *testing.T) {
 		}
 	})
 
+	t.Run("records last login", func(t *testing.T) {
+		user, err := database.GetUserByUsername("alice")
+		if err != nil {
+			t.Fatalf("GetUserByUsername failed: %v", err)
+		}
+		if user.LastLoginAt == nil {
+			t.Error("expected last_login_at to be set after login")
+		}
+	})
+
+	t.Run("disabled account", func(t *testing.T) {
+		u := seedTestUser(t, database, "dave", "password123", []string{"user"})
+		if err := database.SetUserDisabled(u.ID, true); err != nil {
+			t.Fatalf("SetUserDisabled failed: %v", err)
+		}
+		_, err := provider.LoginWithCredentials(context.Background(), "dave", "password123")
+		if !errors.Is(err, ErrAccountDisabled) {
+			t.Errorf("expected ErrAccountDisabled, got %v", err)
+		}
+	})
+
 	t.Run("no database", func(t *testing.T) {
 		p := NewJWTAuthProvider()
 		p.Initialize(context.Background(), map[string]string{"jwt_secret": testSecret})
@@ -361,6 +383,12 @@ func TestRefreshAccessToken(t *testing.T) {
 		if !authResult.Authenticated {
 			t.Error("new access token should be valid")
 		}
+
+		// The refreshed token keeps the time of the original sign-in
+		loginAuth, _ := provider.Authenticate(context.Background(), loginResult.AccessToken)
+		if got := authResult.User.Metadata["auth_time"]; got == "" || got != loginAuth.User.Metadata["auth_time"] {
+			t.Errorf("auth_time = %q, want the login's %q", got, loginAuth.User.Metadata["auth_time"])
+		}
 	})
 
 	t.Run("invalid refresh token", func(t *testing.T) {
@@ -377,6 +405,77 @@ func TestRefreshAccessToken(t *testing.T) {
 		}
 	})
 
+	t.Run("disabled account", func(t *testing.T) {
+		user, _ := database.GetUserByUsername("carol")
+		if err := database.SetUserDisabled(user.ID, true); err != nil {
+			t.Fatalf("SetUserDisabled failed: %v", err)
+		}
+
+		// Disabling revokes refresh tokens and cuts off access tokens
+		_, err := provider.RefreshAccessToken(context.Background(), loginResult.RefreshToken)
+		if !errors.Is(err, ErrRefreshTokenRevoked) {
+			t.Errorf("expected ErrRefreshTokenRevoked, got %v", err)
+		}
+		authResult, err := provider.Authenticate(context.Background(), loginResult.AccessToken)
+		if err != nil || authResult.Authenticated {
+			t.Errorf("Authenticate() = %+v, %v for a disabled account", authResult, err)
+		}
+
+		if err := database.SetUserDisabled(user.ID, false); err != nil {
+			t.Fatalf("SetUserDisabled failed: %v", err)
+		}
+		loginResult, err = provider.LoginWithCredentials(context.Background(), "carol", "mypass")
+		if err != nil {
+			t.Fatalf("login failed: %v", err)
+		}
+	})
+
+	t.Run("revoked refresh token", func(t *testing.T) {
+		ctx := WithClientInfo(context.Background(), ClientInfo{UserAgent: "test-agent", IPAddress: "192.0.2.1"})
+		result, err := provider.LoginWithCredentials(ctx, "carol", "mypass")
+		if err != nil {
+			t.Fatalf("login failed: %v", err)
+		}
+
+		user, _ := database.GetUserByUsername("carol")
+		devices, err := database.ListRefreshTokensByUser(user.ID)
+		if err != nil {
+			t.Fatalf("ListRefreshTokensByUser failed: %v", err)
+		}
+		var deviceID string
+		for _, d := range devices {
+			if d.UserAgent == "test-agent" && d.IPAddress == "192.0.2.1" {
+				deviceID = d.ID
+			}
+		}
+		if deviceID == "" {
+			t.Fatalf("expected device with client info to be recorded, got %+v", devices)
+		}
+
+		if err := database.DeleteUserRefreshToken(user.ID, deviceID); err != nil {
+			t.Fatalf("DeleteUserRefreshToken failed: %v", err)
+		}
+		_, err = provider.RefreshAccessToken(context.Background(), result.RefreshToken)
+		if !errors.Is(err, ErrRefreshTokenRevoked) {
+			t.Errorf("expected ErrRefreshTokenRevoked, got %v", err)
+		}
+
+		// Other devices are unaffected
+		if _, err := provider.RefreshAccessToken(context.Background(), loginResult.RefreshToken); err != nil {
+			t.Errorf("other device refresh failed: %v", err)
+		}
+	})
+
+	t.Run("access token carries device ID", func(t *testing.T) {
+		authResult, err := provider.Authenticate(context.Background(), loginResult.AccessToken)
+		if err != nil || !authResult.Authenticated {
+			t.Fatalf("Authenticate failed: %v", err)
+		}
+		if authResult.User.Metadata["device_id"] == "" {
+			t.Error("expected device_id in auth metadata")
+		}
+	})
+
 	t.Run("no database", func(t *testing.T) {
 		p := NewJWTAuthProvider()
 		p.Initialize(context.Background(), map[string]string{"jwt_secret": testSecret})
@@ -0,0 +1,293 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// TOTP parameters (RFC 6238). These are the defaults every authenticator
+// app supports.
+const (
+	totpPeriod = 30 // Seconds per time step
+	totpDigits = 6
+	totpSkew   = 1 // Steps of clock drift accepted either side of now
+)
+
+// MFATokenExpiry is how long a user has to enter their code after their
+// password is accepted.
+const MFATokenExpiry = 5 * time.Minute
+
+// BackupCodeCount is the number of backup codes issued at a time.
+const BackupCodeCount = 10
+
+// MFA errors.
+var (
+	// ErrInvalidMFAToken is returned when the token from the first login
+	// step is missing, expired or forged.
+	ErrInvalidMFAToken = errors.New("invalid MFA token")
+	// ErrInvalidMFACode is returned when a TOTP or backup code does not
+	// match, or was already used.
+	ErrInvalidMFACode = errors.New("invalid MFA code")
+	// ErrMFANotEnrolled is returned when verifying a code for a user without
+	// a TOTP enrollment.
+	ErrMFANotEnrolled = errors.New("MFA not enrolled")
+)
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret returns a new random base32-encoded TOTP key.
+func GenerateTOTPSecret() (string, error) {
+	key := make([]byte, 20)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+	return base32NoPadding.EncodeToString(key), nil
+}
+
+// TOTPURI returns the otpauth:// URI authenticator apps scan from a QR code.
+func TOTPURI(issuer, account, secret string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// TOTPCode returns the code for secret at time step.
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := base32NoPadding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for range totpDigits {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod), nil
+}
+
+// TOTPStep returns the time step t falls in.
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / totpPeriod
+}
+
+// matchTOTP returns the time step near now whose code is code, or false if
+// none matches.
+func matchTOTP(secret, code string, now time.Time) (int64, bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	current := TOTPStep(now)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		want, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// GenerateBackupCodes returns BackupCodeCount new backup codes, formatted
+// for display, and their hashes for storage.
+func GenerateBackupCodes() (codes, hashes []string, err error) {
+	for range BackupCodeCount {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate backup code: %w", err)
+		}
+		code := hex.EncodeToString(b)
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashBackupCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// hashBackupCode hashes a backup code, ignoring case, dashes and spaces.
+func hashBackupCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// StartMFAEnrollment gives a user a new, pending TOTP secret, replacing any
+// previous enrollment once it is verified. It returns the secret.
+func StartMFAEnrollment(database *db.DB, userID string) (string, error) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return "", err
+	}
+	if err := database.SaveUserMFA(userID, secret); err != nil {
+		return "", fmt.Errorf("failed to store TOTP secret: %w", err)
+	}
+	return secret, nil
+}
+
+// ConfirmMFAEnrollment enables a user's pending TOTP enrollment if code
+// matches its secret, and returns their new backup codes.
+func ConfirmMFAEnrollment(database *db.DB, userID, code string) ([]string, error) {
+	mfa, err := database.GetUserMFA(userID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if mfa == nil {
+		return nil, ErrMFANotEnrolled
+	}
+	step, ok := matchTOTP(mfa.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+	codes, hashes, err := GenerateBackupCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := database.EnableUserMFA(userID, step, hashes); err != nil {
+		return nil, fmt.Errorf("failed to enable MFA: %w", err)
+	}
+	return codes, nil
+}
+
+// VerifyMFACode checks a TOTP code, or failing that a backup code, for a
+// user with MFA enabled. Each code is accepted only once.
+func VerifyMFACode(database *db.DB, userID, code string) error {
+	mfa, err := database.GetUserMFA(userID)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if !mfa.Enabled() {
+		return ErrMFANotEnrolled
+	}
+	if step, ok := matchTOTP(mfa.Secret, code, time.Now()); ok {
+		used, err := database.UseMFAStep(userID, step)
+		if err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		if !used {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+	used, err := database.UseMFABackupCode(userID, hashBackupCode(code))
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// MFARequired reports whether a user's tenant policy requires them to use
+// MFA.
+func MFARequired(database *db.DB, user *db.User) (bool, error) {
+	tenantID := user.TenantID
+	if tenantID == "" {
+		tenantID = db.DefaultTenantID
+	}
+	tenant, err := database.GetTenant(tenantID)
+	if err != nil {
+		return false, fmt.Errorf("failed to load tenant MFA policy: %w", err)
+	}
+	if tenant == nil {
+		return false, nil
+	}
+	roles := append(append([]string{}, user.Roles...), user.TenantRoles...)
+	return tenant.Settings.MFAPolicy.Requires(roles), nil
+}
+
+// mfaChallenge returns the first-step login result for a user who must
+// enter a TOTP code, or enroll first if they have none.
+func (p *JWTAuthProvider) mfaChallenge(user *db.User, enrolled bool) (*LoginResult, error) {
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(MFATokenExpiry)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "sortie",
+			Subject:   user.ID,
+		},
+		UserID:    user.ID,
+		Username:  user.Username,
+		TokenType: TokenTypeMFA,
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(p.jwtSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate MFA token: %w", err)
+	}
+	return &LoginResult{
+		ExpiresIn:        int64(MFATokenExpiry.Seconds()),
+		MFARequired:      true,
+		MFASetupRequired: !enrolled,
+		MFAToken:         token,
+	}, nil
+}
+
+// MFATokenUser returns the user an MFA token from the first login step was
+// issued to.
+func (p *JWTAuthProvider) MFATokenUser(token string) (*db.User, error) {
+	if p.database == nil {
+		return nil, errors.New("database not configured")
+	}
+	claims := &Claims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (any, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return p.jwtSecret, nil
+	})
+	if err != nil || !parsed.Valid || claims.TokenType != TokenTypeMFA {
+		return nil, ErrInvalidMFAToken
+	}
+	user, err := p.database.GetUserByID(claims.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if user == nil {
+		return nil, ErrInvalidMFAToken
+	}
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+	return user, nil
+}
+
+// CompleteMFALogin finishes a login that stopped at the MFA step: it checks
+// code for the user the MFA token was issued to and returns their tokens.
+func (p *JWTAuthProvider) CompleteMFALogin(ctx context.Context, mfaToken, code string) (*LoginResult, error) {
+	user, err := p.MFATokenUser(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := VerifyMFACode(p.database, user.ID, code); err != nil {
+		return nil, err
+	}
+	return p.IssueTokens(ctx, user)
+}
@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// rfc6238Secret is the SHA-1 key from RFC 6238 appendix B, base32-encoded.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode_RFC6238(t *testing.T) {
+	// The RFC lists 8-digit codes; 6-digit codes are their last six digits
+	for _, tt := range []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{20000000000, "353130"},
+	} {
+		got, err := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil || got != tt.want {
+			t.Errorf("TOTPCode at %d = %q, %v; want %q", tt.unix, got, err, tt.want)
+		}
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := TOTPStep(now)
+	prev, _ := TOTPCode(rfc6238Secret, step-1)
+	old, _ := TOTPCode(rfc6238Secret, step-3)
+
+	if got, ok := matchTOTP(rfc6238Secret, "005924", now); !ok || got != step {
+		t.Errorf("matchTOTP(current) = %d, %v; want %d", got, ok, step)
+	}
+	if got, ok := matchTOTP(rfc6238Secret, prev, now); !ok || got != step-1 {
+		t.Errorf("matchTOTP(previous step) = %d, %v; want %d", got, ok, step-1)
+	}
+	for _, code := range []string{old, "", "12345", "0059245"} {
+		if _, ok := matchTOTP(rfc6238Secret, code, now); ok {
+			t.Errorf("matchTOTP(%q) matched", code)
+		}
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("Sortie", "alice", "ABC")
+	if !strings.HasPrefix(uri, "otpauth://totp/Sortie:alice?") || !strings.Contains(uri, "secret=ABC") || !strings.Contains(uri, "issuer=Sortie") {
+		t.Errorf("TOTPURI() = %q", uri)
+	}
+}
+
+func TestGenerateBackupCodes(t *testing.T) {
+	codes, hashes, err := GenerateBackupCodes()
+	if err != nil {
+		t.Fatalf("GenerateBackupCodes() error = %v", err)
+	}
+	if len(codes) != BackupCodeCount || len(hashes) != BackupCodeCount {
+		t.Fatalf("got %d codes and %d hashes, want %d", len(codes), len(hashes), BackupCodeCount)
+	}
+	if hashBackupCode(strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))) != hashes[0] {
+		t.Error("backup code hash depends on case or dashes")
+	}
+}
+
+// currentCode returns the TOTP code a user's authenticator shows now.
+func currentCode(t *testing.T, database *db.DB, userID string) string {
+	t.Helper()
+	mfa, err := database.GetUserMFA(userID)
+	if err != nil || mfa == nil {
+		t.Fatalf("GetUserMFA() = %+v, %v", mfa, err)
+	}
+	code, err := TOTPCode(mfa.Secret, TOTPStep(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestMFALogin(t *testing.T) {
+	provider, database := setupTestProvider(t)
+	ctx := context.Background()
+	user := seedTestUser(t, database, "alice", "password123", []string{"user"})
+
+	// Without MFA, login issues tokens straight away
+	result, err := provider.LoginWithCredentials(ctx, "alice", "password123")
+	if err != nil || result.AccessToken == "" || result.MFARequired {
+		t.Fatalf("LoginWithCredentials() = %+v, %v; want tokens", result, err)
+	}
+
+	if _, err := StartMFAEnrollment(database, user.ID); err != nil {
+		t.Fatalf("StartMFAEnrollment() error = %v", err)
+	}
+	// A pending enrollment does not change login
+	if result, _ := provider.LoginWithCredentials(ctx, "alice", "password123"); result.MFARequired {
+		t.Error("login asked for MFA before enrollment was confirmed")
+	}
+	if _, err := ConfirmMFAEnrollment(database, user.ID, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("ConfirmMFAEnrollment(wrong code) error = %v, want ErrInvalidMFACode", err)
+	}
+	backupCodes, err := ConfirmMFAEnrollment(database, user.ID, currentCode(t, database, user.ID))
+	if err != nil || len(backupCodes) != BackupCodeCount {
+		t.Fatalf("ConfirmMFAEnrollment() = %v, %v", backupCodes, err)
+	}
+
+	result, err = provider.LoginWithCredentials(ctx, "alice", "password123")
+	if err != nil {
+		t.Fatalf("LoginWithCredentials() error = %v", err)
+	}
+	if !result.MFARequired || result.MFASetupRequired || result.MFAToken == "" || result.AccessToken != "" || result.RefreshToken != "" {
+		t.Fatalf("LoginWithCredentials() = %+v, want an MFA challenge only", result)
+	}
+
+	// The MFA token is not an access token
+	if auth, _ := provider.Authenticate(ctx, result.MFAToken); auth.Authenticated {
+		t.Error("MFA token authenticated as an access token")
+	}
+
+	t.Run("wrong code", func(t *testing.T) {
+		if _, err := provider.CompleteMFALogin(ctx, result.MFAToken, "12345x"); !errors.Is(err, ErrInvalidMFACode) {
+			t.Errorf("CompleteMFALogin() error = %v, want ErrInvalidMFACode", err)
+		}
+	})
+
+	t.Run("forged token", func(t *testing.T) {
+		if _, err := provider.CompleteMFALogin(ctx, "not-a-token", backupCodes[0]); !errors.Is(err, ErrInvalidMFAToken) {
+			t.Errorf("CompleteMFALogin() error = %v, want ErrInvalidMFAToken", err)
+		}
+	})
+
+	t.Run("backup code works once", func(t *testing.T) {
+		done, err := provider.CompleteMFALogin(ctx, result.MFAToken, strings.ToUpper(backupCodes[0]))
+		if err != nil || done.AccessToken == "" || done.RefreshToken == "" {
+			t.Fatalf("CompleteMFALogin(backup code) = %+v, %v; want tokens", done, err)
+		}
+		if _, err := provider.CompleteMFALogin(ctx, result.MFAToken, backupCodes[0]); !errors.Is(err, ErrInvalidMFACode) {
+			t.Errorf("reused backup code error = %v, want ErrInvalidMFACode", err)
+		}
+	})
+
+	t.Run("TOTP codes work once", func(t *testing.T) {
+		mfa, _ := database.GetUserMFA(user.ID)
+		// The code used to confirm enrollment cannot log in
+		used, _ := TOTPCode(mfa.Secret, mfa.LastStep)
+		if _, err := provider.CompleteMFALogin(ctx, result.MFAToken, used); !errors.Is(err, ErrInvalidMFACode) {
+			t.Errorf("replayed TOTP code error = %v, want ErrInvalidMFACode", err)
+		}
+		// The next step's code is within the allowed clock drift
+		next, _ := TOTPCode(mfa.Secret, mfa.LastStep+1)
+		if done, err := provider.CompleteMFALogin(ctx, result.MFAToken, next); err != nil || done.AccessToken == "" {
+			t.Fatalf("CompleteMFALogin(TOTP code) = %+v, %v; want tokens", done, err)
+		}
+		if _, err := provider.CompleteMFALogin(ctx, result.MFAToken, next); !errors.Is(err, ErrInvalidMFACode) {
+			t.Errorf("reused TOTP code error = %v, want ErrInvalidMFACode", err)
+		}
+	})
+}
+
+func TestMFALogin_RequiredByTenantPolicy(t *testing.T) {
+	provider, database := setupTestProvider(t)
+	ctx := context.Background()
+	seedTestUser(t, database, "admin1", "password123", []string{"admin"})
+	seedTestUser(t, database, "bob", "password123", []string{"user"})
+
+	tenant, err := database.GetTenant(db.DefaultTenantID)
+	if err != nil {
+		t.Fatalf("GetTenant failed: %v", err)
+	}
+	tenant.Settings.MFAPolicy = &db.MFAPolicy{Roles: []string{"admin"}}
+	if err := database.UpdateTenant(*tenant); err != nil {
+		t.Fatalf("UpdateTenant failed: %v", err)
+	}
+
+	result, err := provider.LoginWithCredentials(ctx, "admin1", "password123")
+	if err != nil || !result.MFARequired || !result.MFASetupRequired || result.AccessToken != "" {
+		t.Fatalf("admin login = %+v, %v; want an MFA setup challenge", result, err)
+	}
+	user, err := provider.MFATokenUser(result.MFAToken)
+	if err != nil || user.Username != "admin1" {
+		t.Errorf("MFATokenUser() = %+v, %v; want admin1", user, err)
+	}
+	// Without an enrollment there is no code to finish with
+	if _, err := provider.CompleteMFALogin(ctx, result.MFAToken, "123456"); !errors.Is(err, ErrMFANotEnrolled) {
+		t.Errorf("CompleteMFALogin() error = %v, want ErrMFANotEnrolled", err)
+	}
+
+	if result, _ := provider.LoginWithCredentials(ctx, "bob", "password123"); result.MFARequired {
+		t.Error("policy for admins applied to a plain user")
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,6 +17,7 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/offline"
 	"github.com/rjsadow/sortie/internal/plugins"
 	"golang.org/x/oauth2"
 )
@@ -37,6 +40,15 @@ type OIDCAuthProvider struct {
 	oauth2Config oauth2.Config
 }
 
+// providerClient is the HTTP client used to reach the identity provider.
+var providerClient = offline.Client(30 * time.Second)
+
+// providerContext returns ctx carrying providerClient, which the OIDC and
+// OAuth2 libraries use for discovery, key fetches and token requests.
+func providerContext(ctx context.Context) context.Context {
+	return oidc.ClientContext(ctx, providerClient)
+}
+
 func init() {
 	plugins.RegisterGlobal(plugins.PluginTypeAuth, "oidc", func() plugins.Plugin {
 		return NewOIDCAuthProvider()
@@ -104,7 +116,7 @@ func (p *OIDCAuthProvider) Initialize(ctx context.Context, config map[string]str
 	}
 
 	// Discover OIDC provider (fetches .well-known/openid-configuration)
-	provider, err := oidc.NewProvider(ctx, issuer)
+	provider, err := oidc.NewProvider(providerContext(ctx), issuer)
 	if err != nil {
 		return fmt.Errorf("oidc: failed to discover provider at %s: %w", issuer, err)
 	}
@@ -175,6 +187,17 @@ func (p *OIDCAuthProvider) Authenticate(ctx context.Context, tokenString string)
 		return &plugins.AuthResult{Authenticated: false, Message: "Invalid token type"}, nil
 	}
 
+	var metadata map[string]string
+	if claims.Locale != "" {
+		metadata = map[string]string{"locale": claims.Locale}
+	}
+	if claims.AuthTime != nil {
+		if metadata == nil {
+			metadata = map[string]string{}
+		}
+		metadata["auth_time"] = strconv.FormatInt(claims.AuthTime.Unix(), 10)
+	}
+
 	expiresAt := claims.ExpiresAt.Time
 	return &plugins.AuthResult{
 		Authenticated: true,
@@ -182,6 +205,7 @@ func (p *OIDCAuthProvider) Authenticate(ctx context.Context, tokenString string)
 			ID:       claims.UserID,
 			Username: claims.Username,
 			Roles:    claims.Roles,
+			Metadata: metadata,
 		},
 		Token:     tokenString,
 		ExpiresAt: &expiresAt,
@@ -224,7 +248,7 @@ func (p *OIDCAuthProvider) HandleCallback(ctx context.Context, code, state strin
 	}
 
 	// Exchange code for tokens
-	oauth2Token, err := p.oauth2Config.Exchange(ctx, code)
+	oauth2Token, err := p.oauth2Config.Exchange(providerContext(ctx), code)
 	if err != nil {
 		return nil, fmt.Errorf("oidc: failed to exchange code: %w", err)
 	}
@@ -241,14 +265,7 @@ func (p *OIDCAuthProvider) HandleCallback(ctx context.Context, code, state strin
 	}
 
 	// Extract claims from ID token
-	var claims struct {
-		Sub               string   `json:"sub"`
-		Email             string   `json:"email"`
-		EmailVerified     bool     `json:"email_verified"`
-		Name              string   `json:"name"`
-		PreferredUsername  string   `json:"preferred_username"`
-		Groups            []string `json:"groups"`
-	}
+	var claims oidcClaims
 	if err := idToken.Claims(&claims); err != nil {
 		return nil, fmt.Errorf("oidc: failed to parse claims: %w", err)
 	}
@@ -267,18 +284,39 @@ func (p *OIDCAuthProvider) HandleCallback(ctx context.Context, code, state strin
 	if err != nil {
 		return nil, fmt.Errorf("oidc: failed to find/create user: %w", err)
 	}
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+
+	if err := p.database.UpdateUserLastLogin(user.ID, time.Now()); err != nil {
+		log.Printf("oidc: failed to record last login for %s: %v", user.ID, err)
+	}
+	// Keep the provider's refresh token so an admin can re-sync the user's
+	// groups later. Providers only issue one for the offline_access scope.
+	if oauth2Token.RefreshToken != "" {
+		if err := p.database.SaveIdPToken(user.ID, "oidc", oauth2Token.RefreshToken); err != nil {
+			log.Printf("oidc: failed to store provider refresh token for %s: %v", user.ID, err)
+		}
+	}
 
-	// Issue local JWT tokens
-	accessToken, err := p.generateToken(user, TokenTypeAccess)
+	settings := p.tokenSettings(user)
+
+	// Issue local JWT tokens bound to a new device record
+	deviceID, err := recordRefreshToken(ctx, p.database, user, settings.RefreshExpiry)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: %w", err)
+	}
+	authTime := time.Now()
+	accessToken, err := p.generateToken(user, TokenTypeAccess, deviceID, authTime, settings.AccessExpiry)
 	if err != nil {
 		return nil, fmt.Errorf("oidc: failed to generate access token: %w", err)
 	}
-	refreshToken, err := p.generateToken(user, TokenTypeRefresh)
+	refreshToken, err := p.generateToken(user, TokenTypeRefresh, deviceID, authTime, settings.RefreshExpiry)
 	if err != nil {
 		return nil, fmt.Errorf("oidc: failed to generate refresh token: %w", err)
 	}
 
-	accessExpiresAt := time.Now().Add(p.accessExpiry)
+	accessExpiresAt := time.Now().Add(settings.AccessExpiry)
 	return &plugins.AuthResult{
 		Authenticated: true,
 		User: &plugins.User{
@@ -287,6 +325,7 @@ func (p *OIDCAuthProvider) HandleCallback(ctx context.Context, code, state strin
 			Email:    user.Email,
 			Name:     user.DisplayName,
 			Roles:    user.Roles,
+			Metadata: map[string]string{"locale": user.Locale},
 		},
 		Token:     accessToken,
 		ExpiresAt: &accessExpiresAt,
@@ -295,8 +334,9 @@ func (p *OIDCAuthProvider) HandleCallback(ctx context.Context, code, state strin
 }
 
 // findOrCreateUser looks up a user by their OIDC subject identifier.
-// If no user exists, it creates one. If the user exists, it updates profile fields.
-func (p *OIDCAuthProvider) findOrCreateUser(sub, username, email, displayName string, groups []string) (*db.User, error) {
+// If no user exists, it creates one. If the user exists, it updates profile
+// fields, and their roles when the provider sent a groups claim.
+func (p *OIDCAuthProvider) findOrCreateUser(sub, username, email, displayName string, groups *[]string) (*db.User, error) {
 	// Try to find user by auth_provider + auth_provider_id
 	user, err := p.database.GetUserByAuthProvider("oidc", sub)
 	if err != nil {
@@ -314,6 +354,12 @@ func (p *OIDCAuthProvider) findOrCreateUser(sub, username, email, displayName st
 			user.DisplayName = displayName
 			changed = true
 		}
+		if groups != nil {
+			if roles := syncGroupRoles(user.Roles, *groups); !slices.Equal(roles, user.Roles) {
+				user.Roles = roles
+				changed = true
+			}
+		}
 		if changed {
 			p.database.UpdateUser(*user)
 		}
@@ -341,16 +387,10 @@ func (p *OIDCAuthProvider) findOrCreateUser(sub, username, email, displayName st
 		return existing, nil
 	}
 
-	// Create new user
+	// Create new user, mapping OIDC groups to roles if present
 	roles := []string{"user"}
-	// Map OIDC groups to roles if present
-	for _, g := range groups {
-		switch strings.ToLower(g) {
-		case "admin", "admins", "administrators":
-			roles = append(roles, "admin")
-		case "app-author", "app-authors", "authors":
-			roles = append(roles, "app-author")
-		}
+	if groups != nil {
+		roles = syncGroupRoles(roles, *groups)
 	}
 
 	newUser := db.User{
@@ -373,15 +413,197 @@ func (p *OIDCAuthProvider) findOrCreateUser(sub, username, email, displayName st
 	return &newUser, nil
 }
 
-// generateToken creates a local JWT token for the user.
-func (p *OIDCAuthProvider) generateToken(user *db.User, tokenType TokenType) (string, error) {
-	var expiry time.Duration
-	if tokenType == TokenTypeAccess {
-		expiry = p.accessExpiry
-	} else {
-		expiry = p.refreshExpiry
+// oidcClaims are the ID token and userinfo claims Sortie reads. Groups is
+// nil when the provider did not send a groups claim at all.
+type oidcClaims struct {
+	Sub               string    `json:"sub"`
+	Email             string    `json:"email"`
+	EmailVerified     bool      `json:"email_verified"`
+	Name              string    `json:"name"`
+	PreferredUsername string    `json:"preferred_username"`
+	Groups            *[]string `json:"groups"`
+}
+
+// oidcGroupRoles maps provider group names to the roles they grant. Only
+// these roles are managed by group sync; others, such as "user" or roles
+// granted directly, are kept.
+var oidcGroupRoles = map[string]string{
+	"admin":          "admin",
+	"admins":         "admin",
+	"administrators": "admin",
+	"app-author":     "app-author",
+	"app-authors":    "app-author",
+	"authors":        "app-author",
+}
+
+// syncGroupRoles returns current with the group-managed roles replaced by
+// the ones groups grant.
+func syncGroupRoles(current, groups []string) []string {
+	managed := map[string]bool{}
+	for _, role := range oidcGroupRoles {
+		managed[role] = true
+	}
+	var roles []string
+	for _, role := range current {
+		if !managed[role] {
+			roles = append(roles, role)
+		}
+	}
+	for _, g := range groups {
+		if role, ok := oidcGroupRoles[strings.ToLower(g)]; ok && !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+var (
+	// ErrSyncUnsupported is returned when syncing a user that did not sign
+	// in through the OIDC provider.
+	ErrSyncUnsupported = errors.New("user is not managed by the identity provider")
+	// ErrIdPTokenMissing is returned when no provider refresh token is
+	// stored for a user, because they have not signed in since it was
+	// revoked or the provider does not issue them.
+	ErrIdPTokenMissing = errors.New("no identity provider token is stored for the user; they must sign in again")
+	// ErrGroupsUnavailable is returned when the provider does not include a
+	// groups claim, so roles cannot be derived.
+	ErrGroupsUnavailable = errors.New("identity provider did not return a groups claim")
+)
+
+// SyncResult describes the changes a group sync made to a user.
+type SyncResult struct {
+	User      *db.User `json:"user"`
+	Added     []string `json:"added"`
+	Removed   []string `json:"removed"`
+	SignedOut bool     `json:"signed_out"` // Refresh tokens were revoked because roles were removed
+}
+
+// SyncUser re-reads a user's groups from the provider with the refresh
+// token stored at their last login, and updates their profile and roles.
+// Groups come from the refreshed ID token, or the userinfo endpoint if the
+// provider does not return one. If any role was removed, the user's
+// refresh tokens are revoked so the change takes effect once their current
+// access token expires.
+func (p *OIDCAuthProvider) SyncUser(ctx context.Context, userID string) (*SyncResult, error) {
+	if p.database == nil {
+		return nil, errors.New("database not configured")
+	}
+	user, err := p.database.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, nil
+	}
+	if user.AuthProvider != "oidc" {
+		return nil, ErrSyncUnsupported
+	}
+	stored, err := p.database.GetIdPToken(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return nil, ErrIdPTokenMissing
+	}
+
+	token, err := p.oauth2Config.TokenSource(providerContext(ctx), &oauth2.Token{RefreshToken: stored.RefreshToken}).Token()
+	if err != nil {
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) {
+			// The provider rejected the token, e.g. because the user was
+			// removed or their grant revoked; it will never work again.
+			if err := p.database.DeleteIdPToken(user.ID); err != nil {
+				log.Printf("oidc: failed to delete rejected provider token for %s: %v", user.ID, err)
+			}
+		}
+		return nil, fmt.Errorf("oidc: failed to refresh provider token: %w", err)
+	}
+	if token.RefreshToken != "" && token.RefreshToken != stored.RefreshToken {
+		if err := p.database.SaveIdPToken(user.ID, "oidc", token.RefreshToken); err != nil {
+			return nil, fmt.Errorf("oidc: failed to store provider refresh token: %w", err)
+		}
+	}
+
+	claims, err := p.refreshedClaims(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Sub != user.AuthProviderID {
+		return nil, fmt.Errorf("oidc: provider returned subject %q, want %q", claims.Sub, user.AuthProviderID)
+	}
+	if claims.Groups == nil {
+		return nil, ErrGroupsUnavailable
+	}
+
+	result := &SyncResult{User: user, Added: []string{}, Removed: []string{}}
+	roles := syncGroupRoles(user.Roles, *claims.Groups)
+	for _, role := range roles {
+		if !slices.Contains(user.Roles, role) {
+			result.Added = append(result.Added, role)
+		}
+	}
+	for _, role := range user.Roles {
+		if !slices.Contains(roles, role) {
+			result.Removed = append(result.Removed, role)
+		}
+	}
+	user.Roles = roles
+	if claims.Email != "" {
+		user.Email = claims.Email
+	}
+	if claims.Name != "" {
+		user.DisplayName = claims.Name
+	}
+	if err := p.database.UpdateUser(*user); err != nil {
+		return nil, err
+	}
+
+	if len(result.Removed) > 0 {
+		if err := p.database.DeleteRefreshTokensByUser(user.ID); err != nil {
+			return nil, fmt.Errorf("failed to revoke refresh tokens: %w", err)
+		}
+		result.SignedOut = true
+	}
+	return result, nil
+}
+
+// refreshedClaims reads the user's claims from a refreshed token response.
+func (p *OIDCAuthProvider) refreshedClaims(ctx context.Context, token *oauth2.Token) (*oidcClaims, error) {
+	var claims oidcClaims
+	if rawIDToken, ok := token.Extra("id_token").(string); ok && rawIDToken != "" {
+		idToken, err := p.verifier.Verify(ctx, rawIDToken)
+		if err != nil {
+			return nil, fmt.Errorf("oidc: failed to verify id_token: %w", err)
+		}
+		if err := idToken.Claims(&claims); err != nil {
+			return nil, fmt.Errorf("oidc: failed to parse claims: %w", err)
+		}
+		return &claims, nil
 	}
 
+	info, err := p.provider.UserInfo(providerContext(ctx), oauth2.StaticTokenSource(token))
+	if err != nil {
+		return nil, fmt.Errorf("oidc: failed to fetch userinfo: %w", err)
+	}
+	if err := info.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("oidc: failed to parse userinfo claims: %w", err)
+	}
+	return &claims, nil
+}
+
+// tokenSettings resolves the token lifetimes for a user from the global
+// configuration and their tenant's token policy.
+func (p *OIDCAuthProvider) tokenSettings(user *db.User) TokenSettings {
+	return tokenSettingsFor(p.database, TokenSettings{
+		AccessExpiry:  p.accessExpiry,
+		RefreshExpiry: p.refreshExpiry,
+	}, user)
+}
+
+// generateToken creates a local JWT token for the user that expires after
+// expiry. Refresh tokens use deviceID as their jti; access tokens carry it as
+// the device_id claim. authTime is when the user signed in.
+func (p *OIDCAuthProvider) generateToken(user *db.User, tokenType TokenType, deviceID string, authTime time.Time, expiry time.Duration) (string, error) {
 	claims := Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiry)),
@@ -391,8 +613,16 @@ func (p *OIDCAuthProvider) generateToken(user *db.User, tokenType TokenType) (st
 		},
 		UserID:    user.ID,
 		Username:  user.Username,
+		Email:     user.Email,
 		Roles:     user.Roles,
 		TokenType: tokenType,
+		Locale:    user.Locale,
+		AuthTime:  jwt.NewNumericDate(authTime),
+	}
+	if tokenType == TokenTypeRefresh {
+		claims.ID = deviceID
+	} else {
+		claims.DeviceID = deviceID
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -419,6 +649,11 @@ func (p *OIDCAuthProvider) RefreshAccessToken(ctx context.Context, refreshTokenS
 		return nil, errors.New("invalid token type")
 	}
 
+	stored, err := checkRefreshToken(p.database, claims)
+	if err != nil {
+		return nil, err
+	}
+
 	user, err := p.database.GetUserByID(claims.UserID)
 	if err != nil {
 		return nil, fmt.Errorf("database error: %w", err)
@@ -426,8 +661,25 @@ func (p *OIDCAuthProvider) RefreshAccessToken(ctx context.Context, refreshTokenS
 	if user == nil {
 		return nil, errors.New("user not found")
 	}
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+
+	settings := p.tokenSettings(user)
+	authTime := claims.signInTime()
+
+	deviceID, refreshExpiry, reissue, err := refreshTokenDevice(p.database, claims, stored, settings)
+	if err != nil {
+		return nil, err
+	}
+	if reissue {
+		refreshTokenString, err = p.generateToken(user, TokenTypeRefresh, deviceID, authTime, refreshExpiry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+		}
+	}
 
-	accessToken, err := p.generateToken(user, TokenTypeAccess)
+	accessToken, err := p.generateToken(user, TokenTypeAccess, deviceID, authTime, settings.AccessExpiry)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
@@ -435,7 +687,7 @@ func (p *OIDCAuthProvider) RefreshAccessToken(ctx context.Context, refreshTokenS
 	return &LoginResult{
 		AccessToken:  accessToken,
 		RefreshToken: refreshTokenString,
-		ExpiresIn:    int64(p.accessExpiry.Seconds()),
+		ExpiresIn:    int64(settings.AccessExpiry.Seconds()),
 		User: &plugins.User{
 			ID:       user.ID,
 			Username: user.Username,
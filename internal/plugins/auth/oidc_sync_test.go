@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/rjsadow/sortie/internal/db"
+	"golang.org/x/oauth2"
+)
+
+// fakeIdP serves a token endpoint that accepts the refresh token "valid"
+// and a userinfo endpoint returning userinfo.
+func fakeIdP(t *testing.T, userinfo string) *OIDCAuthProvider {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			w.Header().Set("Content-Type", "application/json")
+			if r.FormValue("refresh_token") != "valid" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"invalid_grant"}`))
+				return
+			}
+			w.Write([]byte(`{"access_token":"access","token_type":"Bearer","expires_in":300}`))
+		case "/userinfo":
+			if r.Header.Get("Authorization") != "Bearer access" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(userinfo))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	p := NewOIDCAuthProvider()
+	p.provider = (&oidc.ProviderConfig{
+		IssuerURL:   srv.URL,
+		TokenURL:    srv.URL + "/token",
+		UserInfoURL: srv.URL + "/userinfo",
+	}).NewProvider(context.Background())
+	p.oauth2Config = oauth2.Config{ClientID: "sortie", ClientSecret: "secret", Endpoint: p.provider.Endpoint()}
+	return p
+}
+
+func seedOIDCUser(t *testing.T, database *db.DB, roles []string) {
+	t.Helper()
+	if err := database.CreateUser(db.User{
+		ID: "oidc-1", Username: "alice", Roles: roles,
+		AuthProvider: "oidc", AuthProviderID: "sub-1",
+	}); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if err := database.CreateRefreshToken(db.RefreshToken{ID: "device-1", UserID: "oidc-1", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("CreateRefreshToken() error = %v", err)
+	}
+}
+
+func TestOIDCAuthProvider_SyncUser(t *testing.T) {
+	database := newTestDB(t)
+	p := fakeIdP(t, `{"sub":"sub-1","email":"alice@example.com","groups":["Authors"]}`)
+	p.SetDatabase(database)
+	seedOIDCUser(t, database, []string{"user", "admin", "auditor"})
+	database.SaveIdPToken("oidc-1", "oidc", "valid")
+
+	result, err := p.SyncUser(context.Background(), "oidc-1")
+	if err != nil {
+		t.Fatalf("SyncUser() error = %v", err)
+	}
+	if !slices.Equal(result.Added, []string{"app-author"}) || !slices.Equal(result.Removed, []string{"admin"}) {
+		t.Errorf("added %v, removed %v; want [app-author], [admin]", result.Added, result.Removed)
+	}
+	if !result.SignedOut {
+		t.Error("expected refresh tokens to be revoked after a role was removed")
+	}
+
+	user, _ := database.GetUserByID("oidc-1")
+	if !slices.Equal(user.Roles, []string{"user", "auditor", "app-author"}) || user.Email != "alice@example.com" {
+		t.Errorf("user after sync = roles %v, email %q", user.Roles, user.Email)
+	}
+	if tokens, _ := database.ListRefreshTokensByUser("oidc-1"); len(tokens) != 0 {
+		t.Errorf("expected refresh tokens to be revoked, got %d", len(tokens))
+	}
+
+	// A second sync changes nothing and keeps the user signed in.
+	database.CreateRefreshToken(db.RefreshToken{ID: "device-2", UserID: "oidc-1", ExpiresAt: time.Now().Add(time.Hour)})
+	result, err = p.SyncUser(context.Background(), "oidc-1")
+	if err != nil {
+		t.Fatalf("second SyncUser() error = %v", err)
+	}
+	if len(result.Added) != 0 || len(result.Removed) != 0 || result.SignedOut {
+		t.Errorf("second sync = %+v, want no changes", result)
+	}
+}
+
+func TestOIDCAuthProvider_SyncUserErrors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("local user", func(t *testing.T) {
+		database := newTestDB(t)
+		p := fakeIdP(t, `{}`)
+		p.SetDatabase(database)
+		database.CreateUser(db.User{ID: "local-1", Username: "bob", Roles: []string{"user"}})
+		if _, err := p.SyncUser(ctx, "local-1"); !errors.Is(err, ErrSyncUnsupported) {
+			t.Errorf("got %v, want ErrSyncUnsupported", err)
+		}
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		p := fakeIdP(t, `{}`)
+		p.SetDatabase(newTestDB(t))
+		if result, err := p.SyncUser(ctx, "missing"); result != nil || err != nil {
+			t.Errorf("got %v, %v; want nil, nil", result, err)
+		}
+	})
+
+	t.Run("no stored token", func(t *testing.T) {
+		database := newTestDB(t)
+		p := fakeIdP(t, `{}`)
+		p.SetDatabase(database)
+		seedOIDCUser(t, database, []string{"user"})
+		if _, err := p.SyncUser(ctx, "oidc-1"); !errors.Is(err, ErrIdPTokenMissing) {
+			t.Errorf("got %v, want ErrIdPTokenMissing", err)
+		}
+	})
+
+	t.Run("rejected token is discarded", func(t *testing.T) {
+		database := newTestDB(t)
+		p := fakeIdP(t, `{}`)
+		p.SetDatabase(database)
+		seedOIDCUser(t, database, []string{"user"})
+		database.SaveIdPToken("oidc-1", "oidc", "revoked")
+		if _, err := p.SyncUser(ctx, "oidc-1"); err == nil {
+			t.Fatal("expected an error for a rejected token")
+		}
+		if stored, _ := database.GetIdPToken("oidc-1"); stored != nil {
+			t.Error("expected the rejected token to be deleted")
+		}
+	})
+
+	t.Run("no groups claim", func(t *testing.T) {
+		database := newTestDB(t)
+		p := fakeIdP(t, `{"sub":"sub-1"}`)
+		p.SetDatabase(database)
+		seedOIDCUser(t, database, []string{"user", "admin"})
+		database.SaveIdPToken("oidc-1", "oidc", "valid")
+		if _, err := p.SyncUser(ctx, "oidc-1"); !errors.Is(err, ErrGroupsUnavailable) {
+			t.Errorf("got %v, want ErrGroupsUnavailable", err)
+		}
+	})
+}
+
+func TestSyncGroupRoles(t *testing.T) {
+	got := syncGroupRoles([]string{"user", "admin"}, []string{"Administrators", "app-authors", "staff"})
+	if !slices.Equal(got, []string{"user", "admin", "app-author"}) {
+		t.Errorf("syncGroupRoles() = %v", got)
+	}
+	if got := syncGroupRoles([]string{"user", "admin"}, nil); !slices.Equal(got, []string{"user"}) {
+		t.Errorf("syncGroupRoles() with no groups = %v, want [user]", got)
+	}
+}
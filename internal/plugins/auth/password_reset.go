@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// PasswordResetExpiry is how long an emailed password reset link works.
+const PasswordResetExpiry = time.Hour
+
+// ErrInvalidResetToken is returned when a password reset token is unknown,
+// expired, already used, or belongs to an account that cannot reset its
+// password.
+var ErrInvalidResetToken = errors.New("invalid password reset token")
+
+// CanResetPassword reports whether user signs in with a Sortie password,
+// rather than through an identity provider, and so can reset it.
+func CanResetPassword(user *db.User) bool {
+	return (user.AuthProvider == "" || user.AuthProvider == "local") && !user.Disabled
+}
+
+// NewPasswordResetToken creates a one-time password reset token for a user.
+// The token is random; the database stores only its HMAC under the JWT
+// secret, so a leaked database copy cannot be used to reset passwords.
+func (p *JWTAuthProvider) NewPasswordResetToken(userID string) (string, error) {
+	if p.database == nil {
+		return "", errors.New("database not configured")
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate reset token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	expiresAt := time.Now().Add(PasswordResetExpiry)
+	if err := p.database.CreatePasswordResetToken(p.resetTokenHash(token), userID, expiresAt); err != nil {
+		return "", fmt.Errorf("failed to store reset token: %w", err)
+	}
+	return token, nil
+}
+
+// ResetPassword sets a new password for the user a reset token was issued
+// to. The token is used up, the user's other reset tokens are discarded,
+// and their refresh tokens are revoked, signing out every device.
+func (p *JWTAuthProvider) ResetPassword(token, newPassword string) (*db.User, error) {
+	if p.database == nil {
+		return nil, errors.New("database not configured")
+	}
+	stored, err := p.database.ConsumePasswordResetToken(p.resetTokenHash(token))
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if stored == nil {
+		return nil, ErrInvalidResetToken
+	}
+	user, err := p.database.GetUserByID(stored.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if user == nil || !CanResetPassword(user) {
+		return nil, ErrInvalidResetToken
+	}
+
+	hash, err := HashPassword(newPassword)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.database.UpdateUserPassword(user.ID, hash); err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if err := p.database.DeletePasswordResetTokens(user.ID); err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if err := p.database.DeleteRefreshTokensByUser(user.ID); err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return user, nil
+}
+
+// resetTokenHash is the keyed hash a reset token is stored under.
+func (p *JWTAuthProvider) resetTokenHash(token string) string {
+	mac := hmac.New(sha256.New, p.jwtSecret)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+func TestResetPassword(t *testing.T) {
+	provider, database := setupTestProvider(t)
+	ctx := context.Background()
+	user := seedTestUser(t, database, "alice", "old-password", []string{"user"})
+
+	login, err := provider.LoginWithCredentials(ctx, "alice", "old-password")
+	if err != nil {
+		t.Fatalf("LoginWithCredentials() error = %v", err)
+	}
+
+	token, err := provider.NewPasswordResetToken(user.ID)
+	if err != nil {
+		t.Fatalf("NewPasswordResetToken() error = %v", err)
+	}
+	if _, err := provider.ResetPassword(token+"x", "new-password"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("ResetPassword(wrong token) error = %v, want ErrInvalidResetToken", err)
+	}
+
+	got, err := provider.ResetPassword(token, "new-password")
+	if err != nil {
+		t.Fatalf("ResetPassword() error = %v", err)
+	}
+	if got.ID != user.ID {
+		t.Errorf("ResetPassword() user = %q, want %q", got.ID, user.ID)
+	}
+
+	if _, err := provider.LoginWithCredentials(ctx, "alice", "old-password"); err == nil {
+		t.Error("old password still works")
+	}
+	if _, err := provider.LoginWithCredentials(ctx, "alice", "new-password"); err != nil {
+		t.Errorf("new password: LoginWithCredentials() error = %v", err)
+	}
+	if _, err := provider.RefreshAccessToken(ctx, login.RefreshToken); err == nil {
+		t.Error("refresh token from before the reset still works")
+	}
+	if _, err := provider.ResetPassword(token, "another-password"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("reused token: error = %v, want ErrInvalidResetToken", err)
+	}
+}
+
+func TestResetPassword_OtherTokensDiscarded(t *testing.T) {
+	provider, database := setupTestProvider(t)
+	user := seedTestUser(t, database, "alice", "old-password", []string{"user"})
+
+	first, _ := provider.NewPasswordResetToken(user.ID)
+	second, _ := provider.NewPasswordResetToken(user.ID)
+	if _, err := provider.ResetPassword(second, "new-password"); err != nil {
+		t.Fatalf("ResetPassword() error = %v", err)
+	}
+	if _, err := provider.ResetPassword(first, "other-password"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("earlier token: error = %v, want ErrInvalidResetToken", err)
+	}
+}
+
+func TestResetPassword_IneligibleAccounts(t *testing.T) {
+	provider, database := setupTestProvider(t)
+
+	sso := db.User{ID: "user-sso", Username: "sso", AuthProvider: "oidc", AuthProviderID: "sub-1", Roles: []string{"user"}}
+	if err := database.CreateUser(sso); err != nil {
+		t.Fatal(err)
+	}
+	disabled := seedTestUser(t, database, "bob", "password", []string{"user"})
+
+	for _, userID := range []string{sso.ID, disabled.ID} {
+		token, err := provider.NewPasswordResetToken(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if userID == disabled.ID {
+			if err := database.SetUserDisabled(userID, true); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := provider.ResetPassword(token, "new-password"); !errors.Is(err, ErrInvalidResetToken) {
+			t.Errorf("%s: error = %v, want ErrInvalidResetToken", userID, err)
+		}
+	}
+}
+
+func TestResetPassword_Expired(t *testing.T) {
+	provider, database := setupTestProvider(t)
+	user := seedTestUser(t, database, "alice", "old-password", []string{"user"})
+
+	token := "expired-token"
+	if err := database.CreatePasswordResetToken(provider.resetTokenHash(token), user.ID, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.ResetPassword(token, "new-password"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("error = %v, want ErrInvalidResetToken", err)
+	}
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// TokenSettings are the effective token lifetimes and refresh behavior for a
+// user, resolved at issuance.
+type TokenSettings struct {
+	AccessExpiry  time.Duration
+	RefreshExpiry time.Duration
+	RotateRefresh bool
+}
+
+// ResolveTokenSettings layers a tenant token policy and its per-role
+// overrides on top of the global settings. When several of the given roles
+// override the same lifetime, the shortest wins; refresh rotation is enabled
+// if any matching role override enables it.
+func ResolveTokenSettings(global TokenSettings, policy *db.TokenPolicy, roles []string) TokenSettings {
+	settings := global
+	if policy == nil {
+		return settings
+	}
+	settings = applyLifetimes(settings, policy.TokenLifetimes)
+
+	var access, refresh time.Duration
+	var rotate *bool
+	for _, role := range roles {
+		l, ok := policy.Roles[role]
+		if !ok {
+			continue
+		}
+		if d := time.Duration(l.AccessExpiryMinutes) * time.Minute; d > 0 && (access == 0 || d < access) {
+			access = d
+		}
+		if d := time.Duration(l.RefreshExpiryHours) * time.Hour; d > 0 && (refresh == 0 || d < refresh) {
+			refresh = d
+		}
+		if l.RotateRefreshTokens != nil {
+			v := *l.RotateRefreshTokens || (rotate != nil && *rotate)
+			rotate = &v
+		}
+	}
+	if access > 0 {
+		settings.AccessExpiry = access
+	}
+	if refresh > 0 {
+		settings.RefreshExpiry = refresh
+	}
+	if rotate != nil {
+		settings.RotateRefresh = *rotate
+	}
+	return settings
+}
+
+func applyLifetimes(settings TokenSettings, l db.TokenLifetimes) TokenSettings {
+	if l.AccessExpiryMinutes > 0 {
+		settings.AccessExpiry = time.Duration(l.AccessExpiryMinutes) * time.Minute
+	}
+	if l.RefreshExpiryHours > 0 {
+		settings.RefreshExpiry = time.Duration(l.RefreshExpiryHours) * time.Hour
+	}
+	if l.RotateRefreshTokens != nil {
+		settings.RotateRefresh = *l.RotateRefreshTokens
+	}
+	return settings
+}
+
+// tokenSettingsFor resolves the token settings for a user from their tenant's
+// policy. Lookup failures fall back to the global settings.
+func tokenSettingsFor(database *db.DB, global TokenSettings, user *db.User) TokenSettings {
+	tenantID := user.TenantID
+	if tenantID == "" {
+		tenantID = db.DefaultTenantID
+	}
+	tenant, err := database.GetTenant(tenantID)
+	if err != nil {
+		slog.Warn("failed to load tenant token policy", "tenant_id", tenantID, "error", err)
+		return global
+	}
+	if tenant == nil {
+		return global
+	}
+	roles := append(append([]string{}, user.Roles...), user.TenantRoles...)
+	return ResolveTokenSettings(global, tenant.Settings.TokenPolicy, roles)
+}
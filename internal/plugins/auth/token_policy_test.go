@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+func TestResolveTokenSettings(t *testing.T) {
+	enabled, disabled := true, false
+	global := TokenSettings{AccessExpiry: 15 * time.Minute, RefreshExpiry: 168 * time.Hour}
+
+	policy := &db.TokenPolicy{
+		TokenLifetimes: db.TokenLifetimes{AccessExpiryMinutes: 30},
+		Roles: map[string]db.TokenLifetimes{
+			"admin":      {AccessExpiryMinutes: 5, RefreshExpiryHours: 8, RotateRefreshTokens: &enabled},
+			"app-author": {AccessExpiryMinutes: 10, RefreshExpiryHours: 24, RotateRefreshTokens: &disabled},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		policy *db.TokenPolicy
+		roles  []string
+		want   TokenSettings
+	}{
+		{
+			name:  "no policy uses global",
+			roles: []string{"admin"},
+			want:  global,
+		},
+		{
+			name:   "tenant override",
+			policy: policy,
+			roles:  []string{"user"},
+			want:   TokenSettings{AccessExpiry: 30 * time.Minute, RefreshExpiry: 168 * time.Hour},
+		},
+		{
+			name:   "role override",
+			policy: policy,
+			roles:  []string{"user", "admin"},
+			want:   TokenSettings{AccessExpiry: 5 * time.Minute, RefreshExpiry: 8 * time.Hour, RotateRefresh: true},
+		},
+		{
+			name:   "shortest lifetime and any rotation win across roles",
+			policy: policy,
+			roles:  []string{"app-author", "admin"},
+			want:   TokenSettings{AccessExpiry: 5 * time.Minute, RefreshExpiry: 8 * time.Hour, RotateRefresh: true},
+		},
+		{
+			name: "role inherits unset fields from tenant",
+			policy: &db.TokenPolicy{
+				TokenLifetimes: db.TokenLifetimes{RefreshExpiryHours: 12, RotateRefreshTokens: &enabled},
+				Roles:          map[string]db.TokenLifetimes{"admin": {AccessExpiryMinutes: 5}},
+			},
+			roles: []string{"admin"},
+			want:  TokenSettings{AccessExpiry: 5 * time.Minute, RefreshExpiry: 12 * time.Hour, RotateRefresh: true},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ResolveTokenSettings(global, tc.policy, tc.roles)
+			if got != tc.want {
+				t.Errorf("ResolveTokenSettings() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTokenPolicyIssuance(t *testing.T) {
+	provider, database := setupTestProvider(t)
+	defer database.Close()
+
+	rotate := true
+	tenant, err := database.GetTenant(db.DefaultTenantID)
+	if err != nil || tenant == nil {
+		t.Fatalf("GetTenant failed: %v", err)
+	}
+	tenant.Settings.TokenPolicy = &db.TokenPolicy{
+		Roles: map[string]db.TokenLifetimes{
+			"admin": {AccessExpiryMinutes: 5, RefreshExpiryHours: 2, RotateRefreshTokens: &rotate},
+		},
+	}
+	if err := database.UpdateTenant(*tenant); err != nil {
+		t.Fatalf("UpdateTenant failed: %v", err)
+	}
+
+	seedTestUser(t, database, "root", "rootpass", []string{"admin"})
+	seedTestUser(t, database, "dave", "davepass", []string{"user"})
+
+	t.Run("role lifetime applied at login", func(t *testing.T) {
+		result, err := provider.LoginWithCredentials(context.Background(), "root", "rootpass")
+		if err != nil {
+			t.Fatalf("login failed: %v", err)
+		}
+		if result.ExpiresIn != int64((5 * time.Minute).Seconds()) {
+			t.Errorf("expected ExpiresIn 300, got %d", result.ExpiresIn)
+		}
+
+		user, _ := database.GetUserByUsername("root")
+		devices, err := database.ListRefreshTokensByUser(user.ID)
+		if err != nil || len(devices) != 1 {
+			t.Fatalf("expected 1 device, got %d (err %v)", len(devices), err)
+		}
+		if d := time.Until(devices[0].ExpiresAt); d > 2*time.Hour || d < time.Hour {
+			t.Errorf("expected refresh expiry of ~2h, got %v", d)
+		}
+	})
+
+	t.Run("other roles keep global lifetimes", func(t *testing.T) {
+		result, err := provider.LoginWithCredentials(context.Background(), "dave", "davepass")
+		if err != nil {
+			t.Fatalf("login failed: %v", err)
+		}
+		if result.ExpiresIn != int64((15 * time.Minute).Seconds()) {
+			t.Errorf("expected ExpiresIn 900, got %d", result.ExpiresIn)
+		}
+
+		refreshed, err := provider.RefreshAccessToken(context.Background(), result.RefreshToken)
+		if err != nil {
+			t.Fatalf("RefreshAccessToken failed: %v", err)
+		}
+		if refreshed.RefreshToken != result.RefreshToken {
+			t.Error("expected refresh token to be reused without rotation")
+		}
+	})
+
+	t.Run("refresh rotation", func(t *testing.T) {
+		result, err := provider.LoginWithCredentials(context.Background(), "root", "rootpass")
+		if err != nil {
+			t.Fatalf("login failed: %v", err)
+		}
+
+		rotated, err := provider.RefreshAccessToken(context.Background(), result.RefreshToken)
+		if err != nil {
+			t.Fatalf("RefreshAccessToken failed: %v", err)
+		}
+		if rotated.RefreshToken == result.RefreshToken {
+			t.Fatal("expected a new refresh token")
+		}
+
+		// A client that missed the rotation can retry with the previous token
+		// during the grace period and is handed the same device
+		retried, err := provider.RefreshAccessToken(context.Background(), result.RefreshToken)
+		if err != nil {
+			t.Fatalf("retry with previous refresh token failed: %v", err)
+		}
+		rotatedAuth, _ := provider.Authenticate(context.Background(), rotated.AccessToken)
+		retriedAuth, _ := provider.Authenticate(context.Background(), retried.AccessToken)
+		if retriedAuth.User.Metadata["device_id"] != rotatedAuth.User.Metadata["device_id"] {
+			t.Errorf("retry device = %q, want the rotated device %q",
+				retriedAuth.User.Metadata["device_id"], rotatedAuth.User.Metadata["device_id"])
+		}
+		user, _ := database.GetUserByUsername("root")
+		if devices, _ := database.ListRefreshTokensByUser(user.ID); len(devices) != 2 {
+			t.Errorf("expected retry not to add a device, got %d devices", len(devices))
+		}
+
+		// After the grace period the previous refresh token is no longer accepted
+		if _, err := database.ExecRaw("UPDATE refresh_tokens SET expires_at = ? WHERE replaced_by <> ''", time.Now().Add(-time.Second)); err != nil {
+			t.Fatalf("ExecRaw failed: %v", err)
+		}
+		_, err = provider.RefreshAccessToken(context.Background(), result.RefreshToken)
+		if !errors.Is(err, ErrRefreshTokenRevoked) {
+			t.Errorf("expected ErrRefreshTokenRevoked, got %v", err)
+		}
+
+		// The access token is bound to the rotated device record
+		authResult, err := provider.Authenticate(context.Background(), rotated.AccessToken)
+		if err != nil || !authResult.Authenticated {
+			t.Fatalf("Authenticate failed: %v", err)
+		}
+		stored, err := database.GetRefreshToken(authResult.User.Metadata["device_id"])
+		if err != nil || stored == nil {
+			t.Fatalf("expected rotated device record, got %v (err %v)", stored, err)
+		}
+
+		if _, err := provider.RefreshAccessToken(context.Background(), rotated.RefreshToken); err != nil {
+			t.Errorf("rotated refresh token rejected: %v", err)
+		}
+	})
+
+	t.Run("racing rotations share a successor", func(t *testing.T) {
+		user, _ := database.GetUserByUsername("root")
+		stored := db.RefreshToken{ID: "race-device", UserID: user.ID, CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+		if err := database.CreateRefreshToken(stored); err != nil {
+			t.Fatalf("CreateRefreshToken failed: %v", err)
+		}
+		first, err := rotateRefreshToken(database, &stored, time.Hour)
+		if err != nil {
+			t.Fatalf("rotateRefreshToken failed: %v", err)
+		}
+		second, err := rotateRefreshToken(database, &stored, time.Hour)
+		if err != nil {
+			t.Fatalf("losing rotateRefreshToken failed: %v", err)
+		}
+		if first != second {
+			t.Errorf("losing rotation got %q, want the winner's %q", second, first)
+		}
+	})
+}
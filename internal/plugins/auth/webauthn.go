@@ -0,0 +1,508 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// PasskeyChallengeExpiry is how long a passkey registration or sign-in has
+// to complete after it begins.
+const PasskeyChallengeExpiry = 5 * time.Minute
+
+// Passkey errors.
+var (
+	// ErrInvalidPasskey is returned when a passkey response fails
+	// verification: a wrong or reused challenge, origin, signature or
+	// authenticator data.
+	ErrInvalidPasskey = errors.New("invalid passkey")
+	// ErrUnsupportedPasskey is returned when registering a passkey whose
+	// key type is not supported.
+	ErrUnsupportedPasskey = errors.New("unsupported passkey algorithm")
+)
+
+// COSE algorithm identifiers of the supported passkey key types.
+const (
+	coseAlgES256 = -7
+	coseAlgEdDSA = -8
+	coseAlgRS256 = -257
+)
+
+// Authenticator data flags (WebAuthn §6.1).
+const (
+	flagUserPresent      = 0x01
+	flagUserVerified     = 0x04
+	flagAttestedCredData = 0x40
+)
+
+// RelyingParty identifies the site passkeys are registered with. ID is the
+// domain passkeys are bound to, and Origins the exact page origins that
+// may use them.
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+}
+
+// PasskeyCreationOptions are the options for navigator.credentials.create,
+// with binary values base64url-encoded.
+type PasskeyCreationOptions struct {
+	Challenge string `json:"challenge"`
+	RP        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"rp"`
+	User struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	} `json:"user"`
+	PubKeyCredParams       []PasskeyCredentialParameter `json:"pubKeyCredParams"`
+	Timeout                int64                        `json:"timeout"`
+	Attestation            string                       `json:"attestation"`
+	AuthenticatorSelection struct {
+		ResidentKey      string `json:"residentKey"`
+		UserVerification string `json:"userVerification"`
+	} `json:"authenticatorSelection"`
+	ExcludeCredentials []PasskeyCredentialDescriptor `json:"excludeCredentials"`
+}
+
+// PasskeyRequestOptions are the options for navigator.credentials.get. No
+// credentials are listed: the authenticator offers the user's passkeys
+// for the site.
+type PasskeyRequestOptions struct {
+	Challenge        string `json:"challenge"`
+	RPID             string `json:"rpId"`
+	Timeout          int64  `json:"timeout"`
+	UserVerification string `json:"userVerification"`
+}
+
+// PasskeyCredentialParameter names a key type the server accepts.
+type PasskeyCredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+// PasskeyCredentialDescriptor identifies an existing credential.
+type PasskeyCredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// PasskeyCredential is the browser's PublicKeyCredential, with binary
+// values base64url-encoded. Registration responses carry an attestation
+// object; sign-in responses carry authenticator data and a signature.
+type PasskeyCredential struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AttestationObject string `json:"attestationObject,omitempty"`
+		AuthenticatorData string `json:"authenticatorData,omitempty"`
+		Signature         string `json:"signature,omitempty"`
+		UserHandle        string `json:"userHandle,omitempty"`
+	} `json:"response"`
+}
+
+// clientData is the part of clientDataJSON the server checks.
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// authenticatorData is parsed authenticator data (WebAuthn §6.1).
+// CredentialID and PublicKey are only present at registration.
+type authenticatorData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	CredentialID []byte
+	PublicKey    []byte // COSE key
+}
+
+// BeginPasskeyRegistration starts registering a passkey for user and
+// returns the options to pass to the browser.
+func BeginPasskeyRegistration(database *db.DB, rp RelyingParty, user *db.User) (*PasskeyCreationOptions, error) {
+	challenge, err := newPasskeyChallenge(database, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := database.ListWebAuthnCredentials(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	opts := &PasskeyCreationOptions{
+		Challenge: challenge,
+		PubKeyCredParams: []PasskeyCredentialParameter{
+			{Type: "public-key", Alg: coseAlgES256},
+			{Type: "public-key", Alg: coseAlgEdDSA},
+			{Type: "public-key", Alg: coseAlgRS256},
+		},
+		Timeout:            PasskeyChallengeExpiry.Milliseconds(),
+		Attestation:        "none",
+		ExcludeCredentials: []PasskeyCredentialDescriptor{},
+	}
+	opts.RP.ID = rp.ID
+	opts.RP.Name = rp.Name
+	opts.User.ID = encodeB64(passkeyUserHandle(user.ID))
+	opts.User.Name = user.Username
+	opts.User.DisplayName = user.DisplayName
+	if opts.User.DisplayName == "" {
+		opts.User.DisplayName = user.Username
+	}
+	// Discoverable credentials let users sign in without a username
+	opts.AuthenticatorSelection.ResidentKey = "required"
+	opts.AuthenticatorSelection.UserVerification = "required"
+	for _, c := range existing {
+		opts.ExcludeCredentials = append(opts.ExcludeCredentials, PasskeyCredentialDescriptor{Type: "public-key", ID: c.ID})
+	}
+	return opts, nil
+}
+
+// FinishPasskeyRegistration verifies the browser's response to
+// BeginPasskeyRegistration and stores the new passkey under name.
+// Attestation statements are not verified: any authenticator is accepted.
+func FinishPasskeyRegistration(database *db.DB, rp RelyingParty, user *db.User, name string, cred PasskeyCredential) (*db.WebAuthnCredential, error) {
+	if _, err := verifyClientData(database, rp, cred, "webauthn.create", user.ID); err != nil {
+		return nil, err
+	}
+
+	attObj, err := decodeB64(cred.Response.AttestationObject)
+	if err != nil {
+		return nil, ErrInvalidPasskey
+	}
+	var att struct {
+		AuthData []byte `cbor:"authData"`
+	}
+	if err := cbor.Unmarshal(attObj, &att); err != nil {
+		return nil, ErrInvalidPasskey
+	}
+	auth, err := parseAuthenticatorData(att.AuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAuthenticatorData(auth, rp); err != nil {
+		return nil, err
+	}
+	if auth.CredentialID == nil {
+		return nil, ErrInvalidPasskey
+	}
+	if _, err := parseCOSEKey(auth.PublicKey); err != nil {
+		return nil, err
+	}
+
+	stored := db.WebAuthnCredential{
+		ID:        encodeB64(auth.CredentialID),
+		UserID:    user.ID,
+		Name:      strings.TrimSpace(name),
+		PublicKey: encodeB64(auth.PublicKey),
+		SignCount: int64(auth.SignCount),
+		CreatedAt: time.Now(),
+	}
+	if stored.Name == "" {
+		stored.Name = "Passkey"
+	}
+	if err := database.CreateWebAuthnCredential(stored); err != nil {
+		return nil, fmt.Errorf("failed to store passkey: %w", err)
+	}
+	return &stored, nil
+}
+
+// BeginPasskeyLogin starts a passkey sign-in and returns the options to
+// pass to the browser.
+func BeginPasskeyLogin(database *db.DB, rp RelyingParty) (*PasskeyRequestOptions, error) {
+	challenge, err := newPasskeyChallenge(database, "")
+	if err != nil {
+		return nil, err
+	}
+	return &PasskeyRequestOptions{
+		Challenge:        challenge,
+		RPID:             rp.ID,
+		Timeout:          PasskeyChallengeExpiry.Milliseconds(),
+		UserVerification: "required",
+	}, nil
+}
+
+// LoginWithPasskey verifies the browser's response to BeginPasskeyLogin
+// and returns tokens for the passkey's owner. A passkey verifies the user
+// as well as their device, so no further MFA step is needed.
+func (p *JWTAuthProvider) LoginWithPasskey(ctx context.Context, rp RelyingParty, cred PasskeyCredential) (*LoginResult, error) {
+	if p.database == nil {
+		return nil, errors.New("database not configured")
+	}
+	rawClientData, err := verifyClientData(p.database, rp, cred, "webauthn.get", "")
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := p.database.GetWebAuthnCredential(cred.ID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if stored == nil {
+		return nil, ErrInvalidPasskey
+	}
+	if cred.Response.UserHandle != "" {
+		handle, err := decodeB64(cred.Response.UserHandle)
+		if err != nil || !bytes.Equal(handle, passkeyUserHandle(stored.UserID)) {
+			return nil, ErrInvalidPasskey
+		}
+	}
+
+	rawAuth, err := decodeB64(cred.Response.AuthenticatorData)
+	if err != nil {
+		return nil, ErrInvalidPasskey
+	}
+	auth, err := parseAuthenticatorData(rawAuth)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAuthenticatorData(auth, rp); err != nil {
+		return nil, err
+	}
+
+	keyBytes, err := decodeB64(stored.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("stored passkey is corrupt: %w", err)
+	}
+	key, err := parseCOSEKey(keyBytes)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := decodeB64(cred.Response.Signature)
+	if err != nil {
+		return nil, ErrInvalidPasskey
+	}
+	clientHash := sha256.Sum256(rawClientData)
+	if !key.verify(append(rawAuth[:len(rawAuth):len(rawAuth)], clientHash[:]...), sig) {
+		return nil, ErrInvalidPasskey
+	}
+
+	ok, err := p.database.UseWebAuthnCredential(stored.ID, int64(auth.SignCount))
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if !ok {
+		return nil, ErrInvalidPasskey
+	}
+
+	user, err := p.database.GetUserByID(stored.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if user == nil {
+		return nil, ErrInvalidPasskey
+	}
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+	return p.IssueTokens(ctx, user)
+}
+
+// newPasskeyChallenge stores and returns a new random challenge.
+func newPasskeyChallenge(database *db.DB, userID string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate challenge: %w", err)
+	}
+	challenge := encodeB64(b)
+	if err := database.SaveWebAuthnChallenge(challenge, userID, time.Now().Add(PasskeyChallengeExpiry)); err != nil {
+		return "", fmt.Errorf("failed to store challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// verifyClientData checks a response's client data is for a ceremony of
+// the given type, from an origin of rp, answering a challenge issued to
+// userID ("" for sign-in). The challenge is consumed. It returns the raw
+// client data, which sign-in signatures cover.
+func verifyClientData(database *db.DB, rp RelyingParty, cred PasskeyCredential, ceremony, userID string) ([]byte, error) {
+	raw, err := decodeB64(cred.Response.ClientDataJSON)
+	if err != nil {
+		return nil, ErrInvalidPasskey
+	}
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return nil, ErrInvalidPasskey
+	}
+	if cd.Type != ceremony || cd.Challenge == "" || !originAllowed(cd.Origin, rp.Origins) {
+		return nil, ErrInvalidPasskey
+	}
+	entry, err := database.ConsumeWebAuthnChallenge(strings.TrimRight(cd.Challenge, "="))
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if entry == nil || entry.UserID != userID {
+		return nil, ErrInvalidPasskey
+	}
+	return raw, nil
+}
+
+// originAllowed reports whether a page origin is one of origins. The
+// comparison is exact, so a sibling subdomain of the relying party ID
+// cannot use its passkeys.
+func originAllowed(origin string, origins []string) bool {
+	origin = strings.ToLower(origin)
+	for _, o := range origins {
+		if origin == o {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAuthenticatorData checks authenticator data is scoped to rp and
+// that the user was present and verified.
+func checkAuthenticatorData(auth *authenticatorData, rp RelyingParty) error {
+	want := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(auth.RPIDHash, want[:]) {
+		return ErrInvalidPasskey
+	}
+	if auth.Flags&flagUserPresent == 0 || auth.Flags&flagUserVerified == 0 {
+		return ErrInvalidPasskey
+	}
+	return nil
+}
+
+// parseAuthenticatorData parses authenticator data, including the attested
+// credential when the AT flag is set.
+func parseAuthenticatorData(b []byte) (*authenticatorData, error) {
+	if len(b) < 37 {
+		return nil, ErrInvalidPasskey
+	}
+	auth := &authenticatorData{
+		RPIDHash:  b[:32],
+		Flags:     b[32],
+		SignCount: binary.BigEndian.Uint32(b[33:37]),
+	}
+	if auth.Flags&flagAttestedCredData == 0 {
+		return auth, nil
+	}
+
+	// AAGUID (16 bytes), credential ID length (2) and ID, then the key
+	rest := b[37:]
+	if len(rest) < 18 {
+		return nil, ErrInvalidPasskey
+	}
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLen == 0 || len(rest) < idLen {
+		return nil, ErrInvalidPasskey
+	}
+	auth.CredentialID = rest[:idLen]
+	var key cbor.RawMessage
+	if _, err := cbor.UnmarshalFirst(rest[idLen:], &key); err != nil {
+		return nil, ErrInvalidPasskey
+	}
+	auth.PublicKey = key
+	return auth, nil
+}
+
+// coseKey is a parsed passkey public key.
+type coseKey struct {
+	pub crypto.PublicKey
+}
+
+// verify checks sig is the key's signature of data.
+func (k *coseKey) verify(data, sig []byte) bool {
+	switch pub := k.pub.(type) {
+	case *ecdsa.PublicKey:
+		sum := sha256.Sum256(data)
+		return ecdsa.VerifyASN1(pub, sum[:], sig)
+	case ed25519.PublicKey:
+		return ed25519.Verify(pub, data, sig)
+	case *rsa.PublicKey:
+		sum := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig) == nil
+	}
+	return false
+}
+
+// parseCOSEKey parses a COSE_Key (RFC 9052) of one of the supported
+// algorithms.
+func parseCOSEKey(b []byte) (*coseKey, error) {
+	var m map[int]any
+	if err := cbor.Unmarshal(b, &m); err != nil {
+		return nil, ErrInvalidPasskey
+	}
+	kty, _ := coseInt(m[1])
+	alg, _ := coseInt(m[3])
+
+	switch {
+	case kty == 2 && alg == coseAlgES256:
+		crv, _ := coseInt(m[-1])
+		x, _ := m[-2].([]byte)
+		y, _ := m[-3].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, ErrInvalidPasskey
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, ErrInvalidPasskey
+		}
+		return &coseKey{pub: pub}, nil
+	case kty == 1 && alg == coseAlgEdDSA:
+		crv, _ := coseInt(m[-1])
+		x, _ := m[-2].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, ErrInvalidPasskey
+		}
+		return &coseKey{pub: ed25519.PublicKey(x)}, nil
+	case kty == 3 && alg == coseAlgRS256:
+		n, _ := m[-1].([]byte)
+		e, _ := m[-2].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, ErrInvalidPasskey
+		}
+		exp := int(new(big.Int).SetBytes(e).Int64())
+		return &coseKey{pub: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exp}}, nil
+	}
+	return nil, ErrUnsupportedPasskey
+}
+
+// coseInt converts a decoded CBOR integer to int.
+func coseInt(v any) (int, bool) {
+	switch n := v.(type) {
+	case int64:
+		return int(n), true
+	case uint64:
+		return int(n), true
+	}
+	return 0, false
+}
+
+// passkeyUserHandle is the WebAuthn user handle for a user: a hash of
+// their ID, which keeps it within the 64-byte limit and says nothing about
+// the account.
+func passkeyUserHandle(userID string) []byte {
+	sum := sha256.Sum256([]byte("sortie-passkey:" + userID))
+	return sum[:]
+}
+
+func encodeB64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeB64 decodes base64url, padded or not.
+func decodeB64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,268 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+var testRP = RelyingParty{ID: "sortie.example.com", Name: "Sortie", Origins: []string{"https://sortie.example.com"}}
+
+const testOrigin = "https://sortie.example.com"
+
+// testAuthenticator is a software passkey authenticator.
+type testAuthenticator struct {
+	signer    crypto.Signer
+	coseKey   []byte
+	credID    []byte
+	signCount uint32
+	flags     byte
+}
+
+func newTestAuthenticator(t *testing.T, ed bool) *testAuthenticator {
+	t.Helper()
+	a := &testAuthenticator{credID: []byte("cred-" + t.Name()), flags: flagUserPresent | flagUserVerified}
+	var key map[int]any
+	if ed {
+		pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+		a.signer = priv
+		key = map[int]any{1: 1, 3: coseAlgEdDSA, -1: 6, -2: []byte(pub)}
+	} else {
+		priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		a.signer = priv
+		x, y := make([]byte, 32), make([]byte, 32)
+		priv.X.FillBytes(x)
+		priv.Y.FillBytes(y)
+		key = map[int]any{1: 2, 3: coseAlgES256, -1: 1, -2: x, -3: y}
+	}
+	a.coseKey, _ = cbor.Marshal(key)
+	return a
+}
+
+func (a *testAuthenticator) clientData(ceremony, challenge, origin string) []byte {
+	b, _ := json.Marshal(clientData{Type: ceremony, Challenge: challenge, Origin: origin})
+	return b
+}
+
+func (a *testAuthenticator) authData(rpID string, attested bool) []byte {
+	rpHash := sha256.Sum256([]byte(rpID))
+	b := append(rpHash[:], a.flags)
+	b = binary.BigEndian.AppendUint32(b, a.signCount)
+	if attested {
+		b[32] |= flagAttestedCredData
+		b = append(b, make([]byte, 16)...) // AAGUID
+		b = binary.BigEndian.AppendUint16(b, uint16(len(a.credID)))
+		b = append(b, a.credID...)
+		b = append(b, a.coseKey...)
+	}
+	return b
+}
+
+// create answers navigator.credentials.create.
+func (a *testAuthenticator) create(opts *PasskeyCreationOptions, origin string) PasskeyCredential {
+	att, _ := cbor.Marshal(map[string]any{"fmt": "none", "attStmt": map[string]any{}, "authData": a.authData(opts.RP.ID, true)})
+	var cred PasskeyCredential
+	cred.ID = encodeB64(a.credID)
+	cred.Type = "public-key"
+	cred.Response.ClientDataJSON = encodeB64(a.clientData("webauthn.create", opts.Challenge, origin))
+	cred.Response.AttestationObject = encodeB64(att)
+	return cred
+}
+
+// get answers navigator.credentials.get.
+func (a *testAuthenticator) get(opts *PasskeyRequestOptions, origin string) PasskeyCredential {
+	a.signCount++
+	authData := a.authData(opts.RPID, false)
+	cd := a.clientData("webauthn.get", opts.Challenge, origin)
+	hash := sha256.Sum256(cd)
+	signed := append(authData[:len(authData):len(authData)], hash[:]...)
+
+	var sig []byte
+	if _, ok := a.signer.(ed25519.PrivateKey); ok {
+		sig, _ = a.signer.Sign(rand.Reader, signed, crypto.Hash(0))
+	} else {
+		sum := sha256.Sum256(signed)
+		sig, _ = a.signer.Sign(rand.Reader, sum[:], crypto.SHA256)
+	}
+
+	var cred PasskeyCredential
+	cred.ID = encodeB64(a.credID)
+	cred.Type = "public-key"
+	cred.Response.ClientDataJSON = encodeB64(cd)
+	cred.Response.AuthenticatorData = encodeB64(authData)
+	cred.Response.Signature = encodeB64(sig)
+	return cred
+}
+
+func TestPasskeyLogin(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		ed   bool
+	}{{"ES256", false}, {"EdDSA", true}} {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, database := setupTestProvider(t)
+			ctx := context.Background()
+			user := seedTestUser(t, database, "alice", "password123", []string{"user"})
+			authn := newTestAuthenticator(t, tt.ed)
+
+			opts, err := BeginPasskeyRegistration(database, testRP, user)
+			if err != nil {
+				t.Fatalf("BeginPasskeyRegistration() error = %v", err)
+			}
+			if opts.RP.ID != testRP.ID || opts.User.Name != "alice" || opts.AuthenticatorSelection.UserVerification != "required" {
+				t.Errorf("creation options = %+v", opts)
+			}
+			stored, err := FinishPasskeyRegistration(database, testRP, user, "Laptop", authn.create(opts, testOrigin))
+			if err != nil {
+				t.Fatalf("FinishPasskeyRegistration() error = %v", err)
+			}
+			if stored.Name != "Laptop" || stored.ID != encodeB64(authn.credID) {
+				t.Errorf("stored passkey = %+v", stored)
+			}
+
+			// A second registration excludes the first passkey
+			opts, _ = BeginPasskeyRegistration(database, testRP, user)
+			if len(opts.ExcludeCredentials) != 1 || opts.ExcludeCredentials[0].ID != stored.ID {
+				t.Errorf("ExcludeCredentials = %+v, want the registered passkey", opts.ExcludeCredentials)
+			}
+
+			login, err := BeginPasskeyLogin(database, testRP)
+			if err != nil {
+				t.Fatalf("BeginPasskeyLogin() error = %v", err)
+			}
+			cred := authn.get(login, testOrigin)
+			result, err := provider.LoginWithPasskey(ctx, testRP, cred)
+			if err != nil || result.AccessToken == "" || result.User.Username != "alice" {
+				t.Fatalf("LoginWithPasskey() = %+v, %v; want tokens for alice", result, err)
+			}
+
+			// Each challenge works once
+			if _, err := provider.LoginWithPasskey(ctx, testRP, cred); !errors.Is(err, ErrInvalidPasskey) {
+				t.Errorf("replayed login error = %v, want ErrInvalidPasskey", err)
+			}
+		})
+	}
+}
+
+func TestPasskeyLogin_Rejects(t *testing.T) {
+	provider, database := setupTestProvider(t)
+	ctx := context.Background()
+	user := seedTestUser(t, database, "alice", "password123", []string{"user"})
+	authn := newTestAuthenticator(t, false)
+	opts, _ := BeginPasskeyRegistration(database, testRP, user)
+	if _, err := FinishPasskeyRegistration(database, testRP, user, "", authn.create(opts, testOrigin)); err != nil {
+		t.Fatalf("FinishPasskeyRegistration() error = %v", err)
+	}
+
+	attempt := func(mutate func(*PasskeyRequestOptions, *PasskeyCredential)) error {
+		login, err := BeginPasskeyLogin(database, testRP)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cred := authn.get(login, testOrigin)
+		if mutate != nil {
+			mutate(login, &cred)
+		}
+		_, err = provider.LoginWithPasskey(ctx, testRP, cred)
+		return err
+	}
+
+	if err := attempt(nil); err != nil {
+		t.Fatalf("valid login error = %v", err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		mutate func(*PasskeyRequestOptions, *PasskeyCredential)
+	}{
+		{"other origin", func(o *PasskeyRequestOptions, c *PasskeyCredential) {
+			c.Response.ClientDataJSON = encodeB64(authn.clientData("webauthn.get", o.Challenge, "https://evil.example.net"))
+		}},
+		{"unknown challenge", func(o *PasskeyRequestOptions, c *PasskeyCredential) {
+			c.Response.ClientDataJSON = encodeB64(authn.clientData("webauthn.get", "bm90LWlzc3VlZA", testOrigin))
+		}},
+		{"registration client data", func(o *PasskeyRequestOptions, c *PasskeyCredential) {
+			c.Response.ClientDataJSON = encodeB64(authn.clientData("webauthn.create", o.Challenge, testOrigin))
+		}},
+		{"bad signature", func(o *PasskeyRequestOptions, c *PasskeyCredential) {
+			c.Response.Signature = encodeB64([]byte("not a signature"))
+		}},
+		{"unknown credential", func(o *PasskeyRequestOptions, c *PasskeyCredential) {
+			c.ID = encodeB64([]byte("someone-else"))
+		}},
+		{"other user's handle", func(o *PasskeyRequestOptions, c *PasskeyCredential) {
+			c.Response.UserHandle = encodeB64(passkeyUserHandle("user-bob"))
+		}},
+		{"replayed counter", func(o *PasskeyRequestOptions, c *PasskeyCredential) {
+			// Report the counter of the last accepted login again
+			stored, _ := database.GetWebAuthnCredential(c.ID)
+			authn.signCount = uint32(stored.SignCount) - 1
+			*c = authn.get(o, testOrigin)
+		}},
+		{"user not verified", func(o *PasskeyRequestOptions, c *PasskeyCredential) {
+			authn.flags = flagUserPresent
+			defer func() { authn.flags = flagUserPresent | flagUserVerified }()
+			*c = authn.get(o, testOrigin)
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := attempt(tt.mutate); !errors.Is(err, ErrInvalidPasskey) {
+				t.Errorf("LoginWithPasskey() error = %v, want ErrInvalidPasskey", err)
+			}
+		})
+	}
+
+	t.Run("disabled account", func(t *testing.T) {
+		user.Disabled = true
+		if err := database.UpdateUser(*user); err != nil {
+			t.Fatal(err)
+		}
+		if err := attempt(nil); !errors.Is(err, ErrAccountDisabled) {
+			t.Errorf("LoginWithPasskey() error = %v, want ErrAccountDisabled", err)
+		}
+	})
+}
+
+func TestFinishPasskeyRegistration_WrongUser(t *testing.T) {
+	_, database := setupTestProvider(t)
+	alice := seedTestUser(t, database, "alice", "password123", []string{"user"})
+	bob := seedTestUser(t, database, "bob", "password123", []string{"user"})
+	authn := newTestAuthenticator(t, false)
+
+	// A challenge issued to alice can't register a passkey for bob
+	opts, _ := BeginPasskeyRegistration(database, testRP, alice)
+	if _, err := FinishPasskeyRegistration(database, testRP, bob, "", authn.create(opts, testOrigin)); !errors.Is(err, ErrInvalidPasskey) {
+		t.Errorf("FinishPasskeyRegistration() error = %v, want ErrInvalidPasskey", err)
+	}
+}
+
+func TestOriginAllowed(t *testing.T) {
+	origins := []string{"https://sortie.example.com", "http://localhost:5173"}
+	for _, tt := range []struct {
+		origin string
+		want   bool
+	}{
+		{"https://sortie.example.com", true},
+		{"https://Sortie.Example.com", true},
+		{"https://sortie.example.com:8443", false},
+		{"https://portal.sortie.example.com", false},
+		{"http://sortie.example.com", false},
+		{"https://evilsortie.example.com", false},
+		{"http://localhost:5173", true},
+		{"http://localhost:8080", false},
+	} {
+		if got := originAllowed(tt.origin, origins); got != tt.want {
+			t.Errorf("originAllowed(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
@@ -77,6 +77,7 @@ func NewRegistry() *Registry {
 			PluginTypeLauncher: make(map[string]PluginFactory),
 			PluginTypeAuth:     make(map[string]PluginFactory),
 			PluginTypeStorage:  make(map[string]PluginFactory),
+			PluginTypeSidecar:  make(map[string]PluginFactory),
 		},
 	}
 }
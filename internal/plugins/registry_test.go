@@ -300,6 +300,9 @@ func TestRegisterAllPluginTypes(t *testing.T) {
 		{PluginTypeStorage, func() Plugin {
 			return &mockStorage{mockPlugin: mockPlugin{name: "s", pluginType: PluginTypeStorage}}
 		}},
+		{PluginTypeSidecar, func() Plugin {
+			return &mockPlugin{name: "sc", pluginType: PluginTypeSidecar}
+		}},
 	}
 
 	for _, tt := range types {
@@ -0,0 +1,155 @@
+// Package sidecar provides sidecar injector plugins, which add extra
+// containers such as VPN clients, logging agents, or license proxies to
+// session pods.
+//
+// The built-in template injector adds the admin-defined sidecar templates
+// attached to a session's tenant and app. Custom injectors implement
+// plugins.SidecarInjector and are passed to the session manager alongside it.
+package sidecar
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/plugins"
+)
+
+// TemplateInjector implements SidecarInjector using the sidecar templates
+// that admins attach to tenants and apps.
+type TemplateInjector struct {
+	database *db.DB
+}
+
+func init() {
+	plugins.RegisterGlobal(plugins.PluginTypeSidecar, "template", func() plugins.Plugin {
+		return NewTemplateInjector()
+	})
+}
+
+// NewTemplateInjector creates a new template sidecar injector.
+func NewTemplateInjector() *TemplateInjector {
+	return &TemplateInjector{}
+}
+
+// SetDatabase sets the database connection for template lookups.
+func (p *TemplateInjector) SetDatabase(database *db.DB) {
+	p.database = database
+}
+
+// Name returns the plugin name.
+func (p *TemplateInjector) Name() string {
+	return "template"
+}
+
+// Type returns the plugin type.
+func (p *TemplateInjector) Type() plugins.PluginType {
+	return plugins.PluginTypeSidecar
+}
+
+// Version returns the plugin version.
+func (p *TemplateInjector) Version() string {
+	return "1.0.0"
+}
+
+// Description returns a human-readable description.
+func (p *TemplateInjector) Description() string {
+	return "Adds the admin-defined sidecar templates attached to the session's tenant and app"
+}
+
+// Initialize sets up the plugin with configuration.
+func (p *TemplateInjector) Initialize(ctx context.Context, config map[string]string) error {
+	return nil
+}
+
+// Healthy returns true if the plugin is operational.
+func (p *TemplateInjector) Healthy(ctx context.Context) bool {
+	return p.database != nil
+}
+
+// Close releases resources.
+func (p *TemplateInjector) Close() error {
+	return nil
+}
+
+// Sidecars returns the templates attached to the session's tenant, followed
+// by those attached to its app. A template attached to both is added once.
+// A missing template, or one scoped to another tenant, fails the launch
+// rather than starting the session without it.
+func (p *TemplateInjector) Sidecars(ctx context.Context, req *plugins.SidecarRequest) ([]plugins.Sidecar, error) {
+	if p.database == nil {
+		return nil, plugins.ErrPluginNotReady
+	}
+
+	app, err := p.database.GetApp(req.AppID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get app: %w", err)
+	}
+	if app == nil {
+		return nil, nil
+	}
+
+	tenantID := req.TenantID
+	if tenantID == "" {
+		tenantID = app.TenantID
+	}
+
+	var names []string
+	if tenantID != "" {
+		tenant, err := p.database.GetTenant(tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tenant: %w", err)
+		}
+		if tenant != nil {
+			names = append(names, tenant.Settings.Sidecars...)
+		}
+	}
+	names = append(names, app.Sidecars...)
+
+	var sidecars []plugins.Sidecar
+	seen := map[string]bool{}
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		tmpl, err := p.database.GetSidecarTemplate(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get sidecar template %s: %w", name, err)
+		}
+		if tmpl == nil {
+			return nil, fmt.Errorf("sidecar template %s not found", name)
+		}
+		if !tmpl.AvailableTo(tenantID) {
+			return nil, fmt.Errorf("sidecar template %s is not available to tenant %s", name, tenantID)
+		}
+		sidecars = append(sidecars, FromTemplate(*tmpl))
+	}
+	return sidecars, nil
+}
+
+// FromTemplate converts a sidecar template into the sidecar it renders.
+func FromTemplate(tmpl db.SidecarTemplate) plugins.Sidecar {
+	sc := plugins.Sidecar{
+		Name:           tmpl.Name,
+		Image:          tmpl.Spec.Image,
+		Command:        tmpl.Spec.Command,
+		Args:           tmpl.Spec.Args,
+		Env:            tmpl.Spec.Env,
+		Ports:          tmpl.Spec.Ports,
+		MountWorkspace: tmpl.Spec.MountWorkspace,
+	}
+	if r := tmpl.Spec.Resources; r != nil {
+		sc.Resources = &plugins.ResourceLimits{
+			CPURequest:    r.CPURequest,
+			CPULimit:      r.CPULimit,
+			MemoryRequest: r.MemoryRequest,
+			MemoryLimit:   r.MemoryLimit,
+		}
+	}
+	return sc
+}
+
+// Compile-time interface check.
+var _ plugins.SidecarInjector = (*TemplateInjector)(nil)
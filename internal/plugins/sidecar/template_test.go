@@ -0,0 +1,120 @@
+package sidecar
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/plugins"
+)
+
+func setupTestInjector(t *testing.T) (*TemplateInjector, *db.DB) {
+	t.Helper()
+
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	p := NewTemplateInjector()
+	if err := p.Initialize(context.Background(), nil); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	p.SetDatabase(database)
+	return p, database
+}
+
+func TestTemplateInjector_Metadata(t *testing.T) {
+	p := NewTemplateInjector()
+	if p.Name() != "template" {
+		t.Errorf("expected name 'template', got %q", p.Name())
+	}
+	if p.Type() != plugins.PluginTypeSidecar {
+		t.Errorf("expected type %q, got %q", plugins.PluginTypeSidecar, p.Type())
+	}
+	if p.Healthy(context.Background()) {
+		t.Error("injector without a database should not be healthy")
+	}
+	if _, err := p.Sidecars(context.Background(), &plugins.SidecarRequest{AppID: "app"}); !errors.Is(err, plugins.ErrPluginNotReady) {
+		t.Errorf("Sidecars() without database error = %v, want ErrPluginNotReady", err)
+	}
+}
+
+func TestTemplateInjector_Sidecars(t *testing.T) {
+	p, database := setupTestInjector(t)
+	ctx := context.Background()
+
+	for _, tmpl := range []db.SidecarTemplate{
+		{Name: "logs", Spec: db.SidecarSpec{Image: "logs:1", Resources: &db.ResourceLimits{CPULimit: "50m"}}},
+		{Name: "vpn", TenantID: "t1", Spec: db.SidecarSpec{Image: "vpn:1", Ports: []int{1080}}},
+		{Name: "proxy", Spec: db.SidecarSpec{Image: "proxy:1", MountWorkspace: true}},
+	} {
+		if err := database.CreateSidecarTemplate(tmpl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := database.CreateTenant(db.Tenant{ID: "t1", Name: "T1", Slug: "t1", Settings: db.TenantSettings{Sidecars: []string{"vpn", "logs"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.CreateApp(db.Application{ID: "app1", Name: "App", URL: "https://example.com", TenantID: "t1", Sidecars: []string{"proxy", "logs"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.CreateApp(db.Application{ID: "plain", Name: "Plain", URL: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	sidecars, err := p.Sidecars(ctx, &plugins.SidecarRequest{AppID: "app1"})
+	if err != nil {
+		t.Fatalf("Sidecars() error = %v", err)
+	}
+	var names []string
+	for _, sc := range sidecars {
+		names = append(names, sc.Name)
+	}
+	if len(names) != 3 || names[0] != "vpn" || names[1] != "logs" || names[2] != "proxy" {
+		t.Fatalf("Sidecars() names = %v, want [vpn logs proxy]", names)
+	}
+	if sidecars[0].Image != "vpn:1" || len(sidecars[0].Ports) != 1 {
+		t.Errorf("vpn sidecar = %+v", sidecars[0])
+	}
+	if sidecars[1].Resources == nil || sidecars[1].Resources.CPULimit != "50m" {
+		t.Errorf("logs sidecar resources = %+v", sidecars[1].Resources)
+	}
+	if !sidecars[2].MountWorkspace {
+		t.Error("proxy sidecar should mount the workspace")
+	}
+
+	sidecars, err = p.Sidecars(ctx, &plugins.SidecarRequest{AppID: "plain"})
+	if err != nil || len(sidecars) != 0 {
+		t.Errorf("Sidecars(plain) = %v, %v; want none", sidecars, err)
+	}
+
+	sidecars, err = p.Sidecars(ctx, &plugins.SidecarRequest{AppID: "missing"})
+	if err != nil || len(sidecars) != 0 {
+		t.Errorf("Sidecars(missing app) = %v, %v; want none", sidecars, err)
+	}
+}
+
+func TestTemplateInjector_RejectsUnavailableTemplates(t *testing.T) {
+	p, database := setupTestInjector(t)
+	ctx := context.Background()
+
+	if err := database.CreateSidecarTemplate(db.SidecarTemplate{Name: "vpn", TenantID: "t1", Spec: db.SidecarSpec{Image: "vpn:1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.CreateApp(db.Application{ID: "other", Name: "Other", URL: "https://example.com", Sidecars: []string{"vpn"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Sidecars(ctx, &plugins.SidecarRequest{AppID: "other"}); err == nil {
+		t.Error("expected error for a template scoped to another tenant")
+	}
+
+	if err := database.CreateApp(db.Application{ID: "gone", Name: "Gone", URL: "https://example.com", Sidecars: []string{"deleted"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Sidecars(ctx, &plugins.SidecarRequest{AppID: "gone"}); err == nil {
+		t.Error("expected error for a missing template")
+	}
+}
@@ -1,14 +1,15 @@
 // Package plugins provides a plugin architecture for extensible connectors.
-// It allows adding new app launchers, auth providers, and storage providers
-// without modifying core code.
+// It allows adding new app launchers, auth providers, storage providers, and
+// sidecar injectors without modifying core code.
 //
 // Plugin Types:
 //   - Launcher: Handles different application launch mechanisms (URL, container, etc.)
 //   - AuthProvider: Handles authentication and authorization
 //   - StorageProvider: Handles data persistence
+//   - SidecarInjector: Adds extra containers to session pods
 //
 // Adding new plugins:
-//  1. Implement the appropriate interface (Launcher, AuthProvider, or StorageProvider)
+//  1. Implement the appropriate interface (Launcher, AuthProvider, StorageProvider, or SidecarInjector)
 //  2. Register the plugin with the Registry
 //  3. Configure via environment variables or config file
 package plugins
@@ -41,6 +42,7 @@ const (
 	PluginTypeLauncher PluginType = "launcher"
 	PluginTypeAuth     PluginType = "auth"
 	PluginTypeStorage  PluginType = "storage"
+	PluginTypeSidecar  PluginType = "sidecar"
 )
 
 // Plugin is the base interface all plugins must implement.
@@ -299,3 +301,40 @@ type StorageProvider interface {
 	RecordLaunch(ctx context.Context, appID string) error
 	GetAnalyticsStats(ctx context.Context) (map[string]interface{}, error)
 }
+
+// SidecarRequest describes the session pod a sidecar injector is asked about.
+type SidecarRequest struct {
+	SessionID  string `json:"session_id"`
+	AppID      string `json:"app_id"`
+	AppName    string `json:"app_name"`
+	TenantID   string `json:"tenant_id,omitempty"`
+	UserID     string `json:"user_id"`
+	LaunchType string `json:"launch_type"`
+	OsType     string `json:"os_type,omitempty"`
+}
+
+// Sidecar is an extra container to run in a session pod alongside the app,
+// such as a VPN client, logging agent, or license proxy.
+type Sidecar struct {
+	// Name identifies the sidecar within the pod. The container is named
+	// "sidecar-<name>".
+	Name           string            `json:"name"`
+	Image          string            `json:"image"`
+	Command        []string          `json:"command,omitempty"`
+	Args           []string          `json:"args,omitempty"`
+	Env            map[string]string `json:"env,omitempty"`
+	Ports          []int             `json:"ports,omitempty"`
+	Resources      *ResourceLimits   `json:"resources,omitempty"`
+	MountWorkspace bool              `json:"mount_workspace,omitempty"`
+}
+
+// SidecarInjector defines the interface for plugins that add sidecar
+// containers to session pods. Unlike other plugin types, any number of
+// injectors may be active; their sidecars are combined.
+type SidecarInjector interface {
+	Plugin
+
+	// Sidecars returns the sidecars to add to the session's pod.
+	// Returning an error fails the session launch.
+	Sidecars(ctx context.Context, req *SidecarRequest) ([]Sidecar, error)
+}
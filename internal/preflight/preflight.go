@@ -0,0 +1,285 @@
+// Package preflight checks that the server's configuration works before it
+// serves traffic: the database, Kubernetes credentials, sidecar images,
+// the JWT secret, and OIDC discovery. It backs both `sortie check` and the
+// checks run at startup.
+package preflight
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/config"
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/k8s"
+	"github.com/rjsadow/sortie/internal/offline"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip"
+)
+
+// Names of the checks, usable with Options.Skip.
+const (
+	CheckDatabase   = "database"
+	CheckKubernetes = "kubernetes"
+	CheckImages     = "images"
+	CheckJWTSecret  = "jwt-secret"
+	CheckOIDC       = "oidc"
+)
+
+// checkTimeout bounds each check that talks to another service.
+const checkTimeout = 15 * time.Second
+
+// Minimum estimated JWT secret entropy, in bits, before a warning.
+const minSecretBits = 128
+
+// Result is the outcome of one check.
+type Result struct {
+	Name   string
+	Status Status
+	Detail string
+}
+
+// Options selects what Run checks.
+type Options struct {
+	Config *config.Config
+	// Images are the sidecar images to look up in their registries.
+	Images []string
+	// Skip names checks not to run, e.g. CheckKubernetes with the mock
+	// runner.
+	Skip []string
+	// HTTPClient is used for registry and OIDC requests. Nil uses a client
+	// with checkTimeout.
+	HTTPClient *http.Client
+}
+
+type check struct {
+	name string
+	run  func(ctx context.Context, opts Options) (Status, string)
+}
+
+var checks = []check{
+	{CheckDatabase, checkDatabase},
+	{CheckKubernetes, checkKubernetes},
+	{CheckImages, checkImages},
+	{CheckJWTSecret, checkJWTSecret},
+	{CheckOIDC, checkOIDC},
+}
+
+// Run runs the checks concurrently and returns their results in a fixed
+// order.
+func Run(ctx context.Context, opts Options) []Result {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = offline.Client(checkTimeout)
+	}
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		results[i].Name = c.name
+		if isSkipped(c.name, opts.Skip) {
+			results[i].Status, results[i].Detail = StatusSkip, "skipped"
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+			results[i].Status, results[i].Detail = c.run(checkCtx, opts)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// Failed reports whether any check failed.
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if r.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Report writes results as a table.
+func Report(w io.Writer, results []Result) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tDETAIL")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Name, strings.ToUpper(string(r.Status)), r.Detail)
+	}
+	tw.Flush()
+}
+
+func isSkipped(name string, skip []string) bool {
+	for _, s := range skip {
+		if strings.TrimSpace(s) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDatabase connects to the database and compares its schema version
+// with the binary's migrations. Pending migrations are applied at startup.
+func checkDatabase(_ context.Context, opts Options) (Status, string) {
+	cfg := opts.Config
+	if cfg.DBType == "sqlite" && cfg.DB != ":memory:" {
+		if _, err := os.Stat(cfg.DB); errors.Is(err, os.ErrNotExist) {
+			return StatusOK, fmt.Sprintf("%s does not exist and will be created", cfg.DB)
+		}
+	}
+
+	status, err := db.CheckMigrations(cfg.DBType, cfg.DSN())
+	if err != nil {
+		return StatusFail, err.Error()
+	}
+	switch {
+	case status.Dirty:
+		return StatusFail, fmt.Sprintf("migration %d failed part way; fix the schema and force the version with the migrate tool", status.Current)
+	case status.Current > status.Latest:
+		return StatusFail, fmt.Sprintf("schema version %d is newer than this release supports (%d)", status.Current, status.Latest)
+	case status.Current < status.Latest:
+		return StatusOK, fmt.Sprintf("%s reachable, schema version %d, %d migration(s) pending", cfg.DBType, status.Current, status.Latest-status.Current)
+	}
+	return StatusOK, fmt.Sprintf("%s reachable, schema version %d is current", cfg.DBType, status.Current)
+}
+
+// checkKubernetes checks the server has credentials and the RBAC
+// permissions session management uses.
+func checkKubernetes(ctx context.Context, opts Options) (Status, string) {
+	required := k8s.RequiredPermissions
+	if opts.Config != nil && opts.Config.SessionDomain != "" {
+		required = append(required[:len(required):len(required)], k8s.SessionIngressPermissions(k8s.GetSessionIngressConfig())...)
+	}
+	missing, err := k8s.MissingPermissions(ctx, required)
+	if err != nil {
+		return StatusFail, err.Error()
+	}
+	if len(missing) > 0 {
+		names := make([]string, len(missing))
+		for i, p := range missing {
+			names[i] = p.String()
+		}
+		return StatusFail, fmt.Sprintf("missing permissions in namespace %s: %s", k8s.GetNamespace(), strings.Join(names, ", "))
+	}
+	return StatusOK, fmt.Sprintf("credentials have all %d required permissions in namespace %s", len(required), k8s.GetNamespace())
+}
+
+// checkImages looks up each sidecar image in its registry. Images that
+// need credentials are only warned about, since the cluster may have pull
+// secrets for them.
+func checkImages(ctx context.Context, opts Options) (Status, string) {
+	if len(opts.Images) == 0 {
+		return StatusSkip, "no sidecar images configured"
+	}
+	var failed, unauthorized []string
+	for _, image := range opts.Images {
+		image = k8s.MirrorImage(image) // Check where the cluster will pull from
+		err := k8s.CheckImage(ctx, opts.HTTPClient, image)
+		switch {
+		case err == nil:
+		case errors.Is(err, k8s.ErrImageUnauthorized):
+			unauthorized = append(unauthorized, image)
+		default:
+			failed = append(failed, fmt.Sprintf("%s (%v)", image, err))
+		}
+	}
+	if len(failed) > 0 {
+		return StatusFail, "cannot resolve " + strings.Join(failed, ", ")
+	}
+	if len(unauthorized) > 0 {
+		return StatusWarn, "registry requires credentials for " + strings.Join(unauthorized, ", ")
+	}
+	return StatusOK, fmt.Sprintf("%d image(s) resolved", len(opts.Images))
+}
+
+// checkJWTSecret checks the JWT secret is long enough for the auth
+// providers to accept and not obviously guessable.
+func checkJWTSecret(_ context.Context, opts Options) (Status, string) {
+	secret := opts.Config.JWTSecret
+	if secret == "" {
+		return StatusWarn, "SORTIE_JWT_SECRET is not set; authentication is disabled"
+	}
+	if len(secret) < 32 {
+		return StatusFail, fmt.Sprintf("SORTIE_JWT_SECRET is %d characters, must be at least 32", len(secret))
+	}
+	if bits := secretEntropy(secret); bits < minSecretBits {
+		return StatusWarn, fmt.Sprintf("SORTIE_JWT_SECRET has about %.0f bits of entropy, want at least %d; generate one with `openssl rand -base64 48`", bits, minSecretBits)
+	}
+	return StatusOK, fmt.Sprintf("%d characters", len(secret))
+}
+
+// secretEntropy estimates the entropy of s in bits from the frequency of
+// its characters. It overestimates structured secrets but catches
+// repeated and low-variety ones.
+func secretEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	var perChar float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		perChar -= p * math.Log2(p)
+	}
+	return perChar * float64(n)
+}
+
+// checkOIDC fetches the issuer's discovery document.
+func checkOIDC(ctx context.Context, opts Options) (Status, string) {
+	cfg := opts.Config
+	if !cfg.OIDCEnabled() {
+		return StatusSkip, "OIDC is not configured"
+	}
+	issuer := strings.TrimSuffix(cfg.OIDCIssuer, "/")
+	discoveryURL := issuer + "/.well-known/openid-configuration"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return StatusFail, fmt.Sprintf("invalid issuer URL: %v", err)
+	}
+	resp, err := opts.HTTPClient.Do(req)
+	if err != nil {
+		return StatusFail, fmt.Sprintf("discovery unreachable: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return StatusFail, fmt.Sprintf("%s returned status %d", discoveryURL, resp.StatusCode)
+	}
+
+	var doc struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&doc); err != nil {
+		return StatusFail, fmt.Sprintf("invalid discovery document: %v", err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != issuer {
+		return StatusFail, fmt.Sprintf("discovery document is for issuer %q, not %q", doc.Issuer, cfg.OIDCIssuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" {
+		return StatusFail, "discovery document has no authorization or token endpoint"
+	}
+	return StatusOK, fmt.Sprintf("discovery reachable at %s", discoveryURL)
+}
@@ -0,0 +1,169 @@
+package preflight
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/config"
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// testConfig returns a config that passes every check but Kubernetes.
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+	return &config.Config{
+		DBType:    "sqlite",
+		DB:        filepath.Join(t.TempDir(), "sortie.db"),
+		JWTSecret: "kR9vXq2LmZ7tW4bN8cYhP3sJ6fD1gA5eU0oI",
+	}
+}
+
+func resultFor(t *testing.T, results []Result, name string) Result {
+	t.Helper()
+	for _, r := range results {
+		if r.Name == name {
+			return r
+		}
+	}
+	t.Fatalf("no result for %s", name)
+	return Result{}
+}
+
+func TestRun_Database(t *testing.T) {
+	cfg := testConfig(t)
+	opts := Options{Config: cfg, Skip: []string{CheckKubernetes}}
+
+	r := resultFor(t, Run(context.Background(), opts), CheckDatabase)
+	if r.Status != StatusOK || !strings.Contains(r.Detail, "will be created") {
+		t.Errorf("missing database = %+v, want ok and created", r)
+	}
+
+	database, err := db.OpenDB("sqlite", cfg.DB)
+	if err != nil {
+		t.Fatalf("OpenDB() error = %v", err)
+	}
+	database.Close()
+	r = resultFor(t, Run(context.Background(), opts), CheckDatabase)
+	if r.Status != StatusOK || !strings.Contains(r.Detail, "is current") {
+		t.Errorf("migrated database = %+v, want ok and current", r)
+	}
+}
+
+func TestRun_Images(t *testing.T) {
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/sortie/vnc/manifests/v1":
+			w.WriteHeader(http.StatusOK)
+		case "/v2/private/app/manifests/v1":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer registry.Close()
+	host := strings.TrimPrefix(registry.URL, "http://")
+
+	tests := []struct {
+		name   string
+		images []string
+		want   Status
+	}{
+		{"none", nil, StatusSkip},
+		{"found", []string{host + "/sortie/vnc:v1"}, StatusOK},
+		{"needs credentials", []string{host + "/sortie/vnc:v1", host + "/private/app:v1"}, StatusWarn},
+		{"missing tag", []string{host + "/sortie/vnc:v2", host + "/private/app:v1"}, StatusFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{Config: testConfig(t), Images: tt.images, Skip: []string{CheckKubernetes, CheckDatabase}}
+			if r := resultFor(t, Run(context.Background(), opts), CheckImages); r.Status != tt.want {
+				t.Errorf("images = %+v, want %s", r, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckJWTSecret(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		want   Status
+	}{
+		{"unset", "", StatusWarn},
+		{"short", "tooshort", StatusFail},
+		{"repetitive", strings.Repeat("ab", 20), StatusWarn},
+		{"random", "kR9vXq2LmZ7tW4bN8cYhP3sJ6fD1gA5eU0oI", StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, detail := checkJWTSecret(context.Background(), Options{Config: &config.Config{JWTSecret: tt.secret}})
+			if status != tt.want {
+				t.Errorf("checkJWTSecret() = %s (%s), want %s", status, detail, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckOIDC(t *testing.T) {
+	var issuer string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/openid-configuration" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": issuer + "/authorize",
+			"token_endpoint":         issuer + "/token",
+		})
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{OIDCIssuer: srv.URL, OIDCClientID: "sortie", OIDCClientSecret: "secret"}
+	opts := Options{Config: cfg, HTTPClient: srv.Client()}
+
+	issuer = srv.URL
+	if status, detail := checkOIDC(context.Background(), opts); status != StatusOK {
+		t.Errorf("checkOIDC() = %s (%s), want ok", status, detail)
+	}
+
+	issuer = "https://other.example.com"
+	if status, _ := checkOIDC(context.Background(), opts); status != StatusFail {
+		t.Errorf("checkOIDC() with mismatched issuer = %s, want fail", status)
+	}
+
+	cfg.OIDCIssuer = srv.URL + "/missing"
+	if status, _ := checkOIDC(context.Background(), opts); status != StatusFail {
+		t.Errorf("checkOIDC() without discovery = %s, want fail", status)
+	}
+
+	if status, _ := checkOIDC(context.Background(), Options{Config: &config.Config{}}); status != StatusSkip {
+		t.Errorf("checkOIDC() unconfigured = %s, want skip", status)
+	}
+}
+
+func TestFailedAndReport(t *testing.T) {
+	results := []Result{
+		{Name: CheckDatabase, Status: StatusOK, Detail: "sqlite reachable"},
+		{Name: CheckKubernetes, Status: StatusFail, Detail: "missing permissions"},
+	}
+	if !Failed(results) {
+		t.Error("Failed() = false, want true")
+	}
+	if Failed(results[:1]) {
+		t.Error("Failed() = true for passing results")
+	}
+
+	var buf bytes.Buffer
+	Report(&buf, results)
+	out := buf.String()
+	if !strings.Contains(out, "CHECK") || !strings.Contains(out, "FAIL") || !strings.Contains(out, "missing permissions") {
+		t.Errorf("Report() = %q", out)
+	}
+}
@@ -0,0 +1,132 @@
+// Package prepull pulls apps' images onto nodes ahead of launches, so that
+// sessions started at busy times skip the image pull. Admins start
+// pre-pulls on demand and apps may set a cron schedule for them; a
+// background job on the leader replica starts scheduled pre-pulls and
+// removes pre-pulls once they expire.
+package prepull
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	// Embed the timezone database so schedules work in minimal images
+	// without /usr/share/zoneinfo.
+	_ "time/tzdata"
+
+	"github.com/rjsadow/sortie/internal/cron"
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/runner"
+	"github.com/rjsadow/sortie/internal/sessions"
+)
+
+// TTL is how long a pre-pull runs before it is removed. Images pulled by
+// then stay cached on their nodes.
+const TTL = 30 * time.Minute
+
+// MissedGrace is how late a scheduled pre-pull may still start, such as
+// after a leader change. Older ones are skipped.
+const MissedGrace = 15 * time.Minute
+
+// Puller starts, lists, and removes pre-pulls. *sessions.Manager
+// implements it.
+type Puller interface {
+	PrePullApp(ctx context.Context, app *db.Application) error
+	ListPrePulls(ctx context.Context) ([]runner.PrePull, error)
+	DeletePrePull(ctx context.Context, appID string) error
+}
+
+// Validate checks an app's pre-pull schedule. An empty schedule is valid.
+func Validate(schedule string) error {
+	if schedule == "" {
+		return nil
+	}
+	_, err := cron.Parse(schedule)
+	return err
+}
+
+// Due reports whether app's pre-pull schedule fires after after and no
+// later than now. Schedules are read in the timezone of the app's launch
+// schedule, or UTC.
+func Due(app *db.Application, after, now time.Time) bool {
+	if app.PrePullSchedule == "" {
+		return false
+	}
+	sched, err := cron.Parse(app.PrePullSchedule)
+	if err != nil {
+		return false
+	}
+	loc := time.UTC
+	if app.Schedule != nil && app.Schedule.Timezone != "" {
+		if l, err := time.LoadLocation(app.Schedule.Timezone); err == nil {
+			loc = l
+		}
+	}
+	next := sched.Next(after.In(loc))
+	return !next.IsZero() && !next.After(now)
+}
+
+// Scheduler starts scheduled pre-pulls and removes expired ones.
+type Scheduler struct {
+	db     *db.DB
+	puller Puller
+	last   time.Time
+	now    func() time.Time
+}
+
+// NewScheduler creates a Scheduler that pre-pulls with puller. Schedules
+// that fired before it was created are not caught up on.
+func NewScheduler(database *db.DB, puller Puller) *Scheduler {
+	return &Scheduler{db: database, puller: puller, last: time.Now(), now: time.Now}
+}
+
+// Run removes pre-pulls older than TTL, then starts the pre-pulls whose
+// schedules fired since the last run. It is run as a background job, and
+// does nothing on runners without pre-pulls.
+func (s *Scheduler) Run(ctx context.Context) error {
+	now := s.now()
+	after := s.last
+	if after.Before(now.Add(-MissedGrace)) {
+		after = now.Add(-MissedGrace)
+	}
+	s.last = now
+
+	pulls, err := s.puller.ListPrePulls(ctx)
+	if errors.Is(err, sessions.ErrPrePullUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list pre-pulls: %w", err)
+	}
+
+	var errs []error
+	for _, p := range pulls {
+		if now.Sub(p.StartedAt) < TTL {
+			continue
+		}
+		if err := s.puller.DeletePrePull(ctx, p.AppID); err != nil {
+			errs = append(errs, fmt.Errorf("pre-pull of app %s: %w", p.AppID, err))
+		}
+	}
+
+	apps, err := s.db.ListApps()
+	if err != nil {
+		return errors.Join(append(errs, fmt.Errorf("failed to list apps: %w", err))...)
+	}
+	for i := range apps {
+		app := &apps[i]
+		if !Due(app, after, now) {
+			continue
+		}
+		if err := s.puller.PrePullApp(ctx, app); err != nil {
+			slog.Warn("scheduled image pre-pull failed", "app_id", app.ID, "error", err)
+			errs = append(errs, fmt.Errorf("app %s: %w", app.ID, err))
+			continue
+		}
+		slog.Info("started scheduled image pre-pull", "app_id", app.ID)
+		s.db.LogAudit("system", "PREPULL_IMAGES", fmt.Sprintf("Started scheduled image pre-pull for app %s", app.ID))
+	}
+	return errors.Join(errs...)
+}
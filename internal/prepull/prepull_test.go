@@ -0,0 +1,170 @@
+package prepull
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/db/dbtest"
+	"github.com/rjsadow/sortie/internal/runner"
+	"github.com/rjsadow/sortie/internal/sessions"
+)
+
+// fakePuller records pre-pulls in memory.
+type fakePuller struct {
+	pulls       map[string]runner.PrePull
+	unsupported bool
+	now         time.Time
+}
+
+func (p *fakePuller) PrePullApp(_ context.Context, app *db.Application) error {
+	p.pulls[app.ID] = runner.PrePull{AppID: app.ID, StartedAt: p.now}
+	return nil
+}
+
+func (p *fakePuller) ListPrePulls(context.Context) ([]runner.PrePull, error) {
+	if p.unsupported {
+		return nil, sessions.ErrPrePullUnsupported
+	}
+	var pulls []runner.PrePull
+	for _, pull := range p.pulls {
+		pulls = append(pulls, pull)
+	}
+	return pulls, nil
+}
+
+func (p *fakePuller) DeletePrePull(_ context.Context, appID string) error {
+	delete(p.pulls, appID)
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	for _, schedule := range []string{"", "0 7 * * mon-fri", "@daily"} {
+		if err := Validate(schedule); err != nil {
+			t.Errorf("Validate(%q) error = %v", schedule, err)
+		}
+	}
+	for _, schedule := range []string{"every morning", "0 25 * * *"} {
+		if err := Validate(schedule); err == nil {
+			t.Errorf("Validate(%q) accepted an invalid schedule", schedule)
+		}
+	}
+}
+
+func TestDue(t *testing.T) {
+	// 06:59 UTC on a Monday
+	now := time.Date(2026, 3, 9, 6, 59, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		app   db.Application
+		after time.Time
+		now   time.Time
+		want  bool
+	}{
+		{name: "no schedule", app: db.Application{}, after: now.Add(-time.Minute), now: now},
+		{name: "not yet", app: db.Application{PrePullSchedule: "0 7 * * *"}, after: now.Add(-time.Minute), now: now},
+		{name: "fired", app: db.Application{PrePullSchedule: "0 7 * * *"}, after: now, now: now.Add(time.Minute), want: true},
+		{name: "already fired", app: db.Application{PrePullSchedule: "0 7 * * *"}, after: now.Add(time.Minute), now: now.Add(2 * time.Minute)},
+		{
+			name:  "app timezone",
+			app:   db.Application{PrePullSchedule: "0 8 * * *", Schedule: &db.AppSchedule{Timezone: "Europe/Berlin"}},
+			after: now, now: now.Add(time.Minute), want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Due(&tt.app, tt.after, tt.now); got != tt.want {
+				t.Errorf("Due() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchedulerRun(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+	for _, app := range []db.Application{
+		{ID: "cad", Name: "CAD", LaunchType: db.LaunchTypeContainer, ContainerImage: "cad:1", PrePullSchedule: "0 7 * * *"},
+		{ID: "ide", Name: "IDE", LaunchType: db.LaunchTypeContainer, ContainerImage: "ide:1"},
+	} {
+		if err := database.CreateApp(app); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	start := time.Date(2026, 3, 9, 6, 58, 30, 0, time.UTC)
+	puller := &fakePuller{pulls: map[string]runner.PrePull{
+		"stale": {AppID: "stale", StartedAt: start.Add(-TTL)},
+		"fresh": {AppID: "fresh", StartedAt: start.Add(-time.Minute)},
+	}}
+	s := NewScheduler(database, puller)
+	s.last = start
+	clock := start
+	s.now = func() time.Time { return clock }
+
+	clock = start.Add(time.Minute)
+	puller.now = clock
+	if err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if _, ok := puller.pulls["stale"]; ok {
+		t.Error("expected the expired pre-pull removed")
+	}
+	if _, ok := puller.pulls["fresh"]; !ok {
+		t.Error("expected the recent pre-pull kept")
+	}
+	if _, ok := puller.pulls["cad"]; ok {
+		t.Error("pre-pull started before its schedule")
+	}
+
+	clock = start.Add(2 * time.Minute)
+	puller.now = clock
+	if err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if _, ok := puller.pulls["cad"]; !ok {
+		t.Error("expected the scheduled pre-pull started")
+	}
+	if _, ok := puller.pulls["ide"]; ok {
+		t.Error("app without a schedule was pre-pulled")
+	}
+
+	// A schedule is not fired twice
+	delete(puller.pulls, "cad")
+	clock = start.Add(3 * time.Minute)
+	if err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if _, ok := puller.pulls["cad"]; ok {
+		t.Error("scheduled pre-pull started twice")
+	}
+}
+
+func TestSchedulerRun_Unsupported(t *testing.T) {
+	s := NewScheduler(dbtest.NewTestDB(t), &fakePuller{unsupported: true})
+	if err := s.Run(context.Background()); err != nil {
+		t.Errorf("Run() error = %v, want nil on runners without pre-pulls", err)
+	}
+}
+
+func TestSchedulerRun_MissedGrace(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+	if err := database.CreateApp(db.Application{ID: "cad", Name: "CAD", LaunchType: db.LaunchTypeContainer, ContainerImage: "cad:1", PrePullSchedule: "0 7 * * *"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The last run was long before the schedule fired an hour ago
+	now := time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC)
+	puller := &fakePuller{pulls: map[string]runner.PrePull{}, now: now}
+	s := NewScheduler(database, puller)
+	s.last = now.Add(-24 * time.Hour)
+	s.now = func() time.Time { return now }
+
+	if err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if _, ok := puller.pulls["cad"]; ok {
+		t.Error("expected a pre-pull missed by more than MissedGrace to be skipped")
+	}
+}
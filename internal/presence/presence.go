@@ -0,0 +1,92 @@
+// Package presence tracks who is currently connected to each session's
+// display stream.
+package presence
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Role describes how a viewer is connected to a session.
+type Role string
+
+const (
+	RoleOwner     Role = "owner"     // the user who launched the session
+	RoleShared    Role = "shared"    // a user the session was shared with
+	RoleSpectator Role = "spectator" // an admin watching read-only
+)
+
+// Viewer is one active stream connection.
+type Viewer struct {
+	UserID      string    `json:"user_id"`
+	Username    string    `json:"username"`
+	Role        Role      `json:"role"`
+	ViewOnly    bool      `json:"view_only"`
+	ConnectedAt time.Time `json:"connected_at"`
+	// Hidden viewers are only listed for admins, e.g. silent spectators.
+	Hidden bool `json:"hidden,omitempty"`
+}
+
+// Tracker records active viewers per session. It is safe for concurrent use.
+type Tracker struct {
+	mu       sync.Mutex
+	sessions map[string]map[*Viewer]struct{}
+}
+
+// NewTracker creates an empty Tracker.
+func NewTracker() *Tracker {
+	return &Tracker{sessions: make(map[string]map[*Viewer]struct{})}
+}
+
+// Join records a viewer connecting to a session and returns a function that
+// removes it again. A user connected twice is listed twice.
+func (t *Tracker) Join(sessionID string, v Viewer) (leave func()) {
+	if v.ConnectedAt.IsZero() {
+		v.ConnectedAt = time.Now()
+	}
+	entry := &v
+
+	t.mu.Lock()
+	viewers, ok := t.sessions[sessionID]
+	if !ok {
+		viewers = make(map[*Viewer]struct{})
+		t.sessions[sessionID] = viewers
+	}
+	viewers[entry] = struct{}{}
+	t.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			delete(viewers, entry)
+			if len(viewers) == 0 {
+				delete(t.sessions, sessionID)
+			}
+		})
+	}
+}
+
+// List returns the viewers of a session in connection order. Hidden viewers
+// are included only when includeHidden is true.
+func (t *Tracker) List(sessionID string, includeHidden bool) []Viewer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	viewers := []Viewer{}
+	for v := range t.sessions[sessionID] {
+		if v.Hidden && !includeHidden {
+			continue
+		}
+		viewers = append(viewers, *v)
+	}
+	sort.Slice(viewers, func(i, j int) bool {
+		if !viewers[i].ConnectedAt.Equal(viewers[j].ConnectedAt) {
+			return viewers[i].ConnectedAt.Before(viewers[j].ConnectedAt)
+		}
+		return viewers[i].Username < viewers[j].Username
+	})
+	return viewers
+}
@@ -0,0 +1,37 @@
+package presence
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	tr := NewTracker()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	leaveOwner := tr.Join("sess-1", Viewer{UserID: "u1", Username: "owner", Role: RoleOwner, ConnectedAt: start})
+	leaveSpy := tr.Join("sess-1", Viewer{UserID: "a1", Username: "admin", Role: RoleSpectator, Hidden: true, ConnectedAt: start.Add(time.Minute)})
+	tr.Join("sess-2", Viewer{UserID: "u2", Username: "other", Role: RoleOwner})
+
+	if got := tr.List("sess-1", false); len(got) != 1 || got[0].Username != "owner" {
+		t.Errorf("List(visible) = %+v, want only owner", got)
+	}
+	got := tr.List("sess-1", true)
+	if len(got) != 2 || got[0].Username != "owner" || got[1].Role != RoleSpectator {
+		t.Errorf("List(all) = %+v, want owner then spectator", got)
+	}
+
+	leaveSpy()
+	leaveSpy() // leaving twice is harmless
+	if got := tr.List("sess-1", true); len(got) != 1 {
+		t.Errorf("after leave, List() = %+v, want 1 viewer", got)
+	}
+
+	leaveOwner()
+	if got := tr.List("sess-1", true); got == nil || len(got) != 0 {
+		t.Errorf("after all left, List() = %#v, want empty slice", got)
+	}
+	if _, ok := tr.sessions["sess-1"]; ok {
+		t.Error("empty session entry not removed")
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/middleware"
 	"github.com/rjsadow/sortie/internal/sessions"
 )
 
@@ -49,6 +50,27 @@ func (p *HTTPProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Strip the proxy prefix from the path, and add it to redirects
+	prefix := "/api/sessions/" + sessionID + "/proxy"
+	p.serve(w, r, session, stripProxyPrefix(r.URL.Path, sessionID), prefix)
+}
+
+// ServeSession proxies a request for a session's own hostname to the
+// session, with its path unchanged. The caller has checked the requester
+// may use the session.
+func (p *HTTPProxy) ServeSession(w http.ResponseWriter, r *http.Request, session *db.Session) {
+	path := r.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	p.serve(w, r, session, path, "")
+}
+
+// serve proxies r to session's pod at proxyPath. Redirects to absolute
+// paths are rewritten to start with prefix.
+func (p *HTTPProxy) serve(w http.ResponseWriter, r *http.Request, session *db.Session, proxyPath, prefix string) {
+	sessionID := session.ID
+
 	// Check session status
 	if session.Status != db.SessionStatusRunning {
 		http.Error(w, "Session not available", http.StatusServiceUnavailable)
@@ -70,9 +92,6 @@ func (p *HTTPProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Strip the proxy prefix from the path
-	proxyPath := stripProxyPrefix(r.URL.Path, sessionID)
-
 	// Check if this is a WebSocket upgrade request
 	if isWebSocketRequest(r) {
 		p.handleWebSocket(w, r, target, proxyPath, sessionID)
@@ -108,8 +127,10 @@ func (p *HTTPProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		// Preserve the original query string
 		req.URL.RawQuery = r.URL.RawQuery
 
-		// Remove the proxy authorization header if present (we already authenticated)
+		// Remove the proxy authorization header and Sortie's session
+		// cookie (we already authenticated)
 		req.Header.Del("Authorization")
+		stripAccessTokenCookie(req.Header)
 
 		// Set headers to help the backend understand the proxy context
 		req.Header.Set("X-Forwarded-Host", r.Host)
@@ -137,8 +158,8 @@ func (p *HTTPProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		// Rewrite Location header for redirects to go through the proxy
 		if location := resp.Header.Get("Location"); location != "" {
 			// If it's a relative path or same-origin redirect, prepend our proxy prefix
-			if strings.HasPrefix(location, "/") {
-				resp.Header.Set("Location", "/api/sessions/"+sessionID+"/proxy"+location)
+			if prefix != "" && strings.HasPrefix(location, "/") {
+				resp.Header.Set("Location", prefix+location)
 			}
 		}
 		return nil
@@ -214,6 +235,7 @@ func (p *HTTPProxy) handleWebSocket(w http.ResponseWriter, r *http.Request, targ
 	r.URL.Scheme = "" // Clear scheme for the request line
 	r.Host = target.Host
 	r.Header.Del("Authorization")
+	stripAccessTokenCookie(r.Header)
 
 	// Write the original request to the backend
 	if err := r.Write(backendConn); err != nil {
@@ -238,6 +260,22 @@ func (p *HTTPProxy) handleWebSocket(w http.ResponseWriter, r *http.Request, targ
 	<-errCh
 }
 
+// stripAccessTokenCookie removes Sortie's access token cookie from the
+// Cookie headers in h, so the session's app never sees it.
+func stripAccessTokenCookie(h http.Header) {
+	cookies := (&http.Request{Header: h}).Cookies()
+	h.Del("Cookie")
+	var kept []string
+	for _, c := range cookies {
+		if c.Name != middleware.AccessTokenCookieName {
+			kept = append(kept, c.Name+"="+c.Value)
+		}
+	}
+	if len(kept) > 0 {
+		h.Set("Cookie", strings.Join(kept, "; "))
+	}
+}
+
 // extractSessionID extracts the session ID from the proxy path
 // Path format: /api/sessions/{id}/proxy/...
 func extractSessionID(path string) string {
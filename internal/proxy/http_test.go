@@ -853,3 +853,58 @@ func TestServeHTTP_ContextCancellation(t *testing.T) {
 		t.Error("expected non-OK status with cancelled context")
 	}
 }
+
+func TestServeSession_KeepsPathAndStripsSortieCookie(t *testing.T) {
+	var gotPath, gotCookie string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotCookie = r.Header.Get("Cookie")
+		w.Header().Set("Location", "/login")
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer backend.Close()
+
+	database, mgr := setupTestDBAndManager(t)
+
+	app := db.Application{
+		ID:             "app-host",
+		Name:           "Host Test",
+		LaunchType:     db.LaunchTypeWebProxy,
+		ContainerImage: "jupyter:latest",
+		ContainerPort:  backend.Listener.Addr().(*net.TCPAddr).Port,
+	}
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("failed to create app: %v", err)
+	}
+
+	host, _, _ := net.SplitHostPort(backend.Listener.Addr().String())
+	session := db.Session{
+		ID:        "sess-host",
+		UserID:    "user-1",
+		AppID:     "app-host",
+		PodName:   "pod-host",
+		PodIP:     host,
+		Status:    db.SessionStatusRunning,
+		Hostname:  "jupyter-alice.sortie.example.com",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	proxy := NewHTTPProxy(mgr)
+	req := httptest.NewRequest(http.MethodGet, "https://jupyter-alice.sortie.example.com/lab/tree", nil)
+	req.Header.Set("Cookie", "sortie_access_token=secret; _xsrf=abc")
+	rr := httptest.NewRecorder()
+
+	proxy.ServeSession(rr, req, &session)
+
+	if gotPath != "/lab/tree" {
+		t.Errorf("backend path = %q, want /lab/tree", gotPath)
+	}
+	if gotCookie != "_xsrf=abc" {
+		t.Errorf("backend Cookie = %q, want only the app's cookie", gotCookie)
+	}
+	// Redirects stay on the session's hostname
+	if location := rr.Header().Get("Location"); location != "/login" {
+		t.Errorf("Location = %q, want /login", location)
+	}
+}
@@ -0,0 +1,301 @@
+package recordings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/middleware"
+)
+
+// maxChunkSeq is the highest chunk sequence number, so chunk names sort
+// in order.
+const maxChunkSeq = 999999
+
+var (
+	errNoChunks      = errors.New("no recording chunks uploaded")
+	errNotInProgress = errors.New("recording is not in progress")
+)
+
+// handleChunk stores one chunk of a recording while the session runs and
+// checkpoints the recording. The multipart form has recording_id, seq
+// (from 0), duration so far, and file. Re-sending a seq replaces it.
+func (h *Handler) handleChunk(w http.ResponseWriter, r *http.Request, session *db.Session) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	maxSize := int64(h.config.RecordingMaxSizeMB) * 1024 * 1024
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+
+	if err := r.ParseMultipartForm(maxSize); err != nil {
+		if strings.Contains(err.Error(), "http: request body too large") {
+			http.Error(w, fmt.Sprintf("Recording too large (max %d MB)", h.config.RecordingMaxSizeMB), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Failed to parse upload", http.StatusBadRequest)
+		return
+	}
+
+	recordingID := r.FormValue("recording_id")
+	if recordingID == "" {
+		http.Error(w, "Missing recording_id", http.StatusBadRequest)
+		return
+	}
+	seq, err := strconv.Atoi(r.FormValue("seq"))
+	if err != nil || seq < 0 || seq > maxChunkSeq {
+		http.Error(w, "Invalid seq", http.StatusBadRequest)
+		return
+	}
+	var duration float64
+	if durationStr := r.FormValue("duration"); durationStr != "" {
+		fmt.Sscanf(durationStr, "%f", &duration)
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Missing 'file' field in upload", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	rec, ok := h.sessionRecording(w, recordingID, session)
+	if !ok {
+		return
+	}
+	if rec.Status != db.RecordingStatusRecording && rec.Status != db.RecordingStatusUploading {
+		http.Error(w, "Recording is not in progress", http.StatusConflict)
+		return
+	}
+	if rec.SizeBytes+header.Size > maxSize {
+		http.Error(w, fmt.Sprintf("Recording too large (max %d MB)", h.config.RecordingMaxSizeMB), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	store, err := h.storeFor(rec)
+	if err != nil {
+		slog.Error("failed to resolve recording storage", "recording_id", recordingID, "error", err)
+		http.Error(w, "Failed to save recording chunk", http.StatusInternalServerError)
+		return
+	}
+	storagePath, err := store.SaveChunk(recordingID, seq, file)
+	if err != nil {
+		slog.Error("failed to save recording chunk", "recording_id", recordingID, "seq", seq, "error", err)
+		http.Error(w, "Failed to save recording chunk", http.StatusInternalServerError)
+		return
+	}
+
+	chunk := db.RecordingChunk{
+		RecordingID: recordingID,
+		Seq:         seq,
+		StoragePath: storagePath,
+		SizeBytes:   header.Size,
+	}
+	if err := h.database.SaveRecordingChunk(chunk, duration); err != nil {
+		slog.Error("failed to record recording chunk", "recording_id", recordingID, "seq", seq, "error", err)
+		http.Error(w, "Failed to save recording chunk", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleComplete assembles a recording from its uploaded chunks once the
+// client has sent the last one.
+func (h *Handler) handleComplete(w http.ResponseWriter, r *http.Request, session *db.Session) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		RecordingID string  `json:"recording_id"`
+		Duration    float64 `json:"duration"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.RecordingID == "" {
+		http.Error(w, "Missing recording_id", http.StatusBadRequest)
+		return
+	}
+
+	rec, ok := h.sessionRecording(w, body.RecordingID, session)
+	if !ok {
+		return
+	}
+	chunks, err := h.database.ListRecordingChunks(rec.ID)
+	if err != nil {
+		slog.Error("failed to list recording chunks", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if len(chunks) == 0 {
+		http.Error(w, "No recording chunks uploaded", http.StatusConflict)
+		return
+	}
+
+	status, size, err := h.finalizeChunks(rec, body.Duration)
+	switch {
+	case errors.Is(err, errNotInProgress):
+		http.Error(w, "Recording is not in progress", http.StatusConflict)
+		return
+	case err != nil:
+		slog.Error("failed to finalize recording", "recording_id", rec.ID, "error", err)
+		http.Error(w, "Failed to finalize recording", http.StatusInternalServerError)
+		return
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	userID := ""
+	if user != nil {
+		userID = user.ID
+	}
+	h.database.LogAuditRequest(middleware.GetRequestID(r.Context()), userID, "RECORDING_UPLOAD", fmt.Sprintf("Uploaded recording %s for session %s (%d bytes)", rec.ID, session.ID, size))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": string(status)})
+}
+
+// sessionRecording loads a recording of the session, writing an error
+// response if it cannot.
+func (h *Handler) sessionRecording(w http.ResponseWriter, recordingID string, session *db.Session) (*db.Recording, bool) {
+	rec, err := h.database.GetRecording(recordingID)
+	if err != nil {
+		slog.Error("failed to get recording", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if rec == nil || rec.SessionID != session.ID {
+		http.Error(w, "Recording not found", http.StatusNotFound)
+		return nil, false
+	}
+	return rec, true
+}
+
+// finalizeChunks concatenates the chunks of a recording into the final
+// recording file, marks it complete, and starts video conversion as an
+// upload does. The duration is kept from the last checkpoint if not
+// positive. Chunks are deleted once assembled. It returns the recording's
+// new status and size.
+func (h *Handler) finalizeChunks(rec *db.Recording, duration float64) (db.RecordingStatus, int64, error) {
+	ok, err := h.database.BeginRecordingFinalize(rec.ID)
+	if err != nil {
+		return "", 0, err
+	}
+	if !ok {
+		return "", 0, errNotInProgress
+	}
+
+	chunks, err := h.database.ListRecordingChunks(rec.ID)
+	if err == nil && len(chunks) == 0 {
+		err = errNoChunks
+	}
+	if err != nil {
+		h.markFailed(rec.ID)
+		return "", 0, err
+	}
+
+	store, err := h.storeFor(rec)
+	if err != nil {
+		h.markFailed(rec.ID)
+		return "", 0, err
+	}
+
+	var size int64
+	for i, chunk := range chunks {
+		size += chunk.SizeBytes
+		if chunk.Seq != i {
+			slog.Warn("Recording is missing chunks", "recording_id", rec.ID, "seq", chunk.Seq, "expected_seq", i)
+		}
+	}
+
+	src := &chunkReader{store: store, chunks: chunks}
+	storagePath, err := store.Save(rec.ID, src)
+	src.Close()
+	if err != nil {
+		h.markFailed(rec.ID)
+		return "", 0, fmt.Errorf("failed to assemble recording: %w", err)
+	}
+
+	if duration <= 0 {
+		duration = rec.DurationSeconds
+	}
+	if err := h.database.UpdateRecordingComplete(rec.ID, storagePath, size, duration); err != nil {
+		return "", 0, err
+	}
+	h.deleteChunks(store, rec.ID, chunks)
+
+	status := db.RecordingStatusReady
+	if h.convertsLocally(rec) {
+		status = db.RecordingStatusProcessing
+		if err := h.database.UpdateRecordingStatus(rec.ID, db.RecordingStatusProcessing); err != nil {
+			slog.Error("failed to set processing status", "error", err)
+		}
+		go h.convertToVideo(rec.ID, storagePath)
+	}
+	return status, size, nil
+}
+
+// deleteChunks removes the chunk files, from store, and records of a
+// recording.
+func (h *Handler) deleteChunks(store RecordingStore, recordingID string, chunks []db.RecordingChunk) {
+	for _, chunk := range chunks {
+		if err := store.Delete(chunk.StoragePath); err != nil {
+			slog.Warn("failed to delete recording chunk", "error", err, "path", chunk.StoragePath)
+		}
+	}
+	if err := h.database.DeleteRecordingChunks(recordingID); err != nil {
+		slog.Warn("failed to delete recording chunk records", "recording_id", recordingID, "error", err)
+	}
+}
+
+func (h *Handler) markFailed(recordingID string) {
+	if err := h.database.UpdateRecordingStatus(recordingID, db.RecordingStatusFailed); err != nil {
+		slog.Error("failed to mark recording as failed", "recording_id", recordingID, "error", err)
+	}
+}
+
+// chunkReader reads the chunks of a recording one after another, opening
+// each only when the previous one is used up.
+type chunkReader struct {
+	store  RecordingStore
+	chunks []db.RecordingChunk
+	cur    io.ReadCloser
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if c.cur == nil {
+			if len(c.chunks) == 0 {
+				return 0, io.EOF
+			}
+			rc, err := c.store.Get(c.chunks[0].StoragePath)
+			if err != nil {
+				return 0, err
+			}
+			c.cur, c.chunks = rc, c.chunks[1:]
+		}
+		n, err := c.cur.Read(p)
+		if err == io.EOF {
+			c.cur.Close()
+			c.cur = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (c *chunkReader) Close() error {
+	if c.cur == nil {
+		return nil
+	}
+	return c.cur.Close()
+}
@@ -0,0 +1,285 @@
+package recordings
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// setupChunkHandler returns a handler storing into a mock S3 bucket, so
+// finalized recordings are ready without a video conversion.
+func setupChunkHandler(t *testing.T, recordingID string) (*Handler, *db.DB, *mockS3Client) {
+	t.Helper()
+	handler, database, _ := setupTestHandler(t)
+	mock := newMockS3Client()
+	handler.store = NewS3StoreWithClient(mock, "test-bucket", "recordings/")
+	handler.config.RecordingStorageBackend = "s3"
+
+	rec := db.Recording{
+		ID: recordingID, SessionID: "test-sess", UserID: "user-1",
+		Filename: recordingID + ".vncrec", Format: "vncrec", StorageBackend: "s3",
+		Status: db.RecordingStatusRecording, CreatedAt: time.Now(),
+	}
+	if err := database.CreateRecording(rec); err != nil {
+		t.Fatalf("CreateRecording() error = %v", err)
+	}
+	return handler, database, mock
+}
+
+func postChunk(t *testing.T, handler *Handler, recordingID string, seq string, data string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("recording_id", recordingID)
+	writer.WriteField("seq", seq)
+	writer.WriteField("duration", "5.0")
+	part, err := writer.CreateFormFile("file", "chunk")
+	if err != nil {
+		t.Fatalf("CreateFormFile() error = %v", err)
+	}
+	part.Write([]byte(data))
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sessions/test-sess/recording/chunk", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req = reqWithUser(req, ownerUser())
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func postComplete(t *testing.T, handler *Handler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/sessions/test-sess/recording/complete", strings.NewReader(body))
+	req = reqWithUser(req, ownerUser())
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestHandler_ChunkedRecording(t *testing.T) {
+	handler, database, mock := setupChunkHandler(t, "chunk-rec")
+
+	for seq, data := range []string{"VREC-header|", "frame-1|", "frame-2"} {
+		if rr := postChunk(t, handler, "chunk-rec", strconv.Itoa(seq), data); rr.Code != http.StatusNoContent {
+			t.Fatalf("chunk %d: status = %d, want %d (body: %s)", seq, rr.Code, http.StatusNoContent, rr.Body.String())
+		}
+	}
+
+	rec, _ := database.GetRecording("chunk-rec")
+	if rec.SizeBytes != int64(len("VREC-header|frame-1|frame-2")) || rec.CheckpointAt == nil {
+		t.Errorf("after chunks, recording = %+v, want checkpointed size", rec)
+	}
+
+	rr := postComplete(t, handler, `{"recording_id":"chunk-rec","duration":12.5}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("complete: status = %d, want %d (body: %s)", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var resp map[string]string
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp["status"] != string(db.RecordingStatusReady) {
+		t.Errorf("complete status = %q, want ready", resp["status"])
+	}
+
+	rec, _ = database.GetRecording("chunk-rec")
+	if rec.Status != db.RecordingStatusReady || rec.DurationSeconds != 12.5 || rec.CompletedAt == nil {
+		t.Errorf("recording = %+v, want ready with duration 12.5", rec)
+	}
+	if got := string(mock.objects[rec.StoragePath]); got != "VREC-header|frame-1|frame-2" {
+		t.Errorf("assembled recording = %q", got)
+	}
+	for key := range mock.objects {
+		if strings.Contains(key, "/chunks/") {
+			t.Errorf("chunk %s not deleted after assembly", key)
+		}
+	}
+	if chunks, _ := database.ListRecordingChunks("chunk-rec"); len(chunks) != 0 {
+		t.Errorf("%d chunk records remain", len(chunks))
+	}
+
+	// The recording is finished: no more chunks, no second completion.
+	if rr := postChunk(t, handler, "chunk-rec", "3", "late"); rr.Code != http.StatusConflict {
+		t.Errorf("late chunk: status = %d, want %d", rr.Code, http.StatusConflict)
+	}
+	if rr := postComplete(t, handler, `{"recording_id":"chunk-rec"}`); rr.Code != http.StatusConflict {
+		t.Errorf("second complete: status = %d, want %d", rr.Code, http.StatusConflict)
+	}
+}
+
+func TestHandler_ChunkValidation(t *testing.T) {
+	handler, database, _ := setupChunkHandler(t, "chunk-val")
+
+	tests := []struct {
+		name        string
+		recordingID string
+		seq         string
+		want        int
+	}{
+		{"missing seq", "chunk-val", "", http.StatusBadRequest},
+		{"negative seq", "chunk-val", "-1", http.StatusBadRequest},
+		{"seq too large", "chunk-val", "1000000", http.StatusBadRequest},
+		{"missing recording_id", "", "0", http.StatusBadRequest},
+		{"unknown recording", "nope", "0", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rr := postChunk(t, handler, tt.recordingID, tt.seq, "data"); rr.Code != tt.want {
+				t.Errorf("status = %d, want %d (body: %s)", rr.Code, tt.want, rr.Body.String())
+			}
+		})
+	}
+
+	t.Run("recording of another session", func(t *testing.T) {
+		now := time.Now()
+		if err := database.CreateSession(db.Session{
+			ID: "other-sess", UserID: "user-1", AppID: "test-app",
+			PodName: "pod-2", Status: db.SessionStatusRunning, CreatedAt: now, UpdatedAt: now,
+		}); err != nil {
+			t.Fatalf("CreateSession() error = %v", err)
+		}
+		if err := database.CreateRecording(db.Recording{
+			ID: "other-rec", SessionID: "other-sess", UserID: "user-1",
+			Filename: "f", Format: "vncrec", Status: db.RecordingStatusRecording, CreatedAt: now,
+		}); err != nil {
+			t.Fatalf("CreateRecording() error = %v", err)
+		}
+		if rr := postChunk(t, handler, "other-rec", "0", "data"); rr.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want %d", rr.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("total size over limit", func(t *testing.T) {
+		handler.config.RecordingMaxSizeMB = 1
+		defer func() { handler.config.RecordingMaxSizeMB = 10 }()
+		half := strings.Repeat("x", 600*1024)
+		if rr := postChunk(t, handler, "chunk-val", "0", half); rr.Code != http.StatusNoContent {
+			t.Fatalf("first chunk: status = %d, want %d", rr.Code, http.StatusNoContent)
+		}
+		if rr := postChunk(t, handler, "chunk-val", "1", half); rr.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("second chunk: status = %d, want %d", rr.Code, http.StatusRequestEntityTooLarge)
+		}
+	})
+
+	t.Run("complete without chunks", func(t *testing.T) {
+		if err := database.CreateRecording(db.Recording{
+			ID: "empty-rec", SessionID: "test-sess", UserID: "user-1",
+			Filename: "f", Format: "vncrec", Status: db.RecordingStatusRecording, CreatedAt: time.Now(),
+		}); err != nil {
+			t.Fatalf("CreateRecording() error = %v", err)
+		}
+		if rr := postComplete(t, handler, `{"recording_id":"empty-rec"}`); rr.Code != http.StatusConflict {
+			t.Errorf("status = %d, want %d", rr.Code, http.StatusConflict)
+		}
+		if rec, _ := database.GetRecording("empty-rec"); rec.Status != db.RecordingStatusRecording {
+			t.Errorf("status = %s, want recording to be left alone", rec.Status)
+		}
+	})
+}
+
+func TestHandler_DeleteRemovesChunks(t *testing.T) {
+	handler, database, mock := setupChunkHandler(t, "chunk-del")
+	if rr := postChunk(t, handler, "chunk-del", "0", "data"); rr.Code != http.StatusNoContent {
+		t.Fatalf("chunk: status = %d", rr.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/recordings/chunk-del", nil)
+	req = reqWithUser(req, ownerUser())
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("delete: status = %d, want %d", rr.Code, http.StatusNoContent)
+	}
+	if len(mock.objects) != 0 {
+		t.Errorf("objects remain after delete: %v", mock.objects)
+	}
+	if chunks, _ := database.ListRecordingChunks("chunk-del"); len(chunks) != 0 {
+		t.Errorf("%d chunk records remain", len(chunks))
+	}
+}
+
+func TestRepairer_FinalizesStaleRecordings(t *testing.T) {
+	handler, database, mock := setupChunkHandler(t, "stale-rec")
+	for seq, data := range []string{"part-0|", "part-1"} {
+		if rr := postChunk(t, handler, "stale-rec", strconv.Itoa(seq), data); rr.Code != http.StatusNoContent {
+			t.Fatalf("chunk %d: status = %d", seq, rr.Code)
+		}
+	}
+	for _, id := range []string{"no-chunks-rec", "fresh-rec"} {
+		if err := database.CreateRecording(db.Recording{
+			ID: id, SessionID: "test-sess", UserID: "user-1",
+			Filename: "f", Format: "vncrec", Status: db.RecordingStatusRecording, CreatedAt: time.Now(),
+		}); err != nil {
+			t.Fatalf("CreateRecording() error = %v", err)
+		}
+	}
+	past := time.Now().Add(-time.Hour)
+	if _, err := database.ExecRaw("UPDATE recordings SET checkpoint_at = ?, created_at = ? WHERE id IN (?, ?)", past, past, "stale-rec", "no-chunks-rec"); err != nil {
+		t.Fatalf("backdate recordings: %v", err)
+	}
+
+	NewRepairer(handler, 10*time.Minute).run()
+
+	rec, _ := database.GetRecording("stale-rec")
+	if rec.Status != db.RecordingStatusReady {
+		t.Fatalf("stale-rec status = %s, want ready", rec.Status)
+	}
+	if rec.DurationSeconds != 5 {
+		t.Errorf("DurationSeconds = %v, want 5 from the last checkpoint", rec.DurationSeconds)
+	}
+	if got := string(mock.objects[rec.StoragePath]); got != "part-0|part-1" {
+		t.Errorf("repaired recording = %q", got)
+	}
+	if rec, _ := database.GetRecording("no-chunks-rec"); rec.Status != db.RecordingStatusFailed {
+		t.Errorf("no-chunks-rec status = %s, want failed", rec.Status)
+	}
+	if rec, _ := database.GetRecording("fresh-rec"); rec.Status != db.RecordingStatusRecording {
+		t.Errorf("fresh-rec status = %s, want recording", rec.Status)
+	}
+}
+
+func TestRepairer_MissingChunkFails(t *testing.T) {
+	handler, database, mock := setupChunkHandler(t, "lost-rec")
+	if rr := postChunk(t, handler, "lost-rec", "0", "data"); rr.Code != http.StatusNoContent {
+		t.Fatalf("chunk: status = %d", rr.Code)
+	}
+	clear(mock.objects)
+
+	rec, _ := database.GetRecording("lost-rec")
+	if _, _, err := handler.finalizeChunks(rec, 0); err == nil {
+		t.Fatal("finalizeChunks() succeeded without the chunk file")
+	}
+	if rec, _ := database.GetRecording("lost-rec"); rec.Status != db.RecordingStatusFailed {
+		t.Errorf("status = %s, want failed", rec.Status)
+	}
+}
+
+func TestChunkReader(t *testing.T) {
+	store := newMockS3Client()
+	store.objects["a"] = []byte("hello, ")
+	store.objects["b"] = nil
+	store.objects["c"] = []byte("world")
+	r := &chunkReader{
+		store:  NewS3StoreWithClient(store, "bucket", ""),
+		chunks: []db.RecordingChunk{{StoragePath: "a"}, {StoragePath: "b"}, {StoragePath: "c"}},
+	}
+	data, err := io.ReadAll(r)
+	if err != nil || string(data) != "hello, world" {
+		t.Errorf("ReadAll() = %q, %v; want %q", data, err, "hello, world")
+	}
+
+	store.getErr = errors.New("boom")
+	r = &chunkReader{store: NewS3StoreWithClient(store, "bucket", ""), chunks: []db.RecordingChunk{{StoragePath: "a"}}}
+	if _, err := io.ReadAll(r); err == nil {
+		t.Error("ReadAll() should fail when a chunk cannot be opened")
+	}
+}
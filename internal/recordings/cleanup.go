@@ -1,19 +1,23 @@
 package recordings
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
 	"time"
 
 	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/leader"
 )
 
 // Cleaner periodically removes expired recordings from storage and the database.
 type Cleaner struct {
 	db            *db.DB
-	store         RecordingStore
+	stores        *Stores
 	retentionDays int
 	interval      time.Duration
 	stopCh        chan struct{}
+	leader        leader.Checker
 }
 
 // NewCleaner creates a Cleaner that deletes recordings older than retentionDays.
@@ -21,13 +25,20 @@ type Cleaner struct {
 func NewCleaner(database *db.DB, store RecordingStore, retentionDays int) *Cleaner {
 	return &Cleaner{
 		db:            database,
-		store:         store,
+		stores:        NewStores(database, store, ""),
 		retentionDays: retentionDays,
 		interval:      1 * time.Hour,
 		stopCh:        make(chan struct{}),
 	}
 }
 
+// SetStores makes the cleaner find each recording's files through s, such
+// as a Handler's Stores, so recordings in tenant storage locations are
+// deleted from there.
+func (c *Cleaner) SetStores(s *Stores) {
+	c.stores = s
+}
+
 // Start launches the cleanup goroutine. It returns immediately.
 func (c *Cleaner) Start() {
 	if c.retentionDays <= 0 {
@@ -36,6 +47,12 @@ func (c *Cleaner) Start() {
 	go c.loop()
 }
 
+// SetLeader makes the cleaner run only while l reports this replica as
+// leader. Call it before Start.
+func (c *Cleaner) SetLeader(l leader.Checker) {
+	c.leader = l
+}
+
 // Stop signals the cleanup goroutine to exit.
 func (c *Cleaner) Stop() {
 	close(c.stopCh)
@@ -48,7 +65,9 @@ func (c *Cleaner) loop() {
 	for {
 		select {
 		case <-ticker.C:
-			c.run()
+			if leader.IsLeader(c.leader) {
+				c.run()
+			}
 		case <-c.stopCh:
 			return
 		}
@@ -56,19 +75,38 @@ func (c *Cleaner) loop() {
 }
 
 func (c *Cleaner) run() {
+	if err := c.Run(context.Background()); err != nil {
+		slog.Warn("Recording cleanup failed", "error", err)
+	}
+}
+
+// Run deletes expired recordings once. It returns an error if they could
+// not be listed or any of them could not be deleted.
+func (c *Cleaner) Run(ctx context.Context) error {
 	if c.retentionDays <= 0 {
-		return
+		return nil
 	}
 	cutoff := time.Now().Add(-time.Duration(c.retentionDays) * 24 * time.Hour)
 	expired, err := c.db.ListExpiredRecordings(cutoff)
 	if err != nil {
-		slog.Warn("Recording cleanup: failed to list expired recordings", "error", err)
-		return
+		return fmt.Errorf("failed to list expired recordings: %w", err)
 	}
 
+	failed := 0
 	for _, rec := range expired {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		store, err := c.stores.ForRecording(&rec)
+		if err != nil {
+			slog.Warn("Recording cleanup: failed to resolve storage",
+				"recording_id", rec.ID,
+				"error", err)
+			failed++
+			continue
+		}
 		if rec.StoragePath != "" {
-			if err := c.store.Delete(rec.StoragePath); err != nil {
+			if err := store.Delete(rec.StoragePath); err != nil {
 				slog.Warn("Recording cleanup: failed to delete file",
 					"recording_id", rec.ID,
 					"storage_path", rec.StoragePath,
@@ -76,7 +114,7 @@ func (c *Cleaner) run() {
 			}
 		}
 		if rec.VideoPath != "" {
-			if err := c.store.Delete(rec.VideoPath); err != nil {
+			if err := store.Delete(rec.VideoPath); err != nil {
 				slog.Warn("Recording cleanup: failed to delete video file",
 					"recording_id", rec.ID,
 					"video_path", rec.VideoPath,
@@ -88,6 +126,7 @@ func (c *Cleaner) run() {
 			slog.Warn("Recording cleanup: failed to delete DB record",
 				"recording_id", rec.ID,
 				"error", err)
+			failed++
 			continue
 		}
 
@@ -95,4 +134,9 @@ func (c *Cleaner) run() {
 			"recording_id", rec.ID,
 			"completed_at", rec.CompletedAt)
 	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to delete %d of %d expired recordings", failed, len(expired))
+	}
+	return nil
 }
@@ -8,6 +8,7 @@ import (
 
 	"github.com/rjsadow/sortie/internal/db"
 	"github.com/rjsadow/sortie/internal/db/dbtest"
+	"github.com/rjsadow/sortie/internal/storagebrowser"
 )
 
 // memoryStore is a simple in-memory RecordingStore for testing cleanup.
@@ -26,6 +27,17 @@ func (m *memoryStore) Save(id string, _ io.Reader) (string, error) {
 	return key, nil
 }
 
+func (m *memoryStore) SaveChunk(id string, seq int, _ io.Reader) (string, error) {
+	key := "chunks/" + id + "/" + chunkName(seq)
+	m.files[key] = true
+	return key, nil
+}
+
+func (m *memoryStore) Put(storagePath string, _ io.Reader) error {
+	m.files[storagePath] = true
+	return nil
+}
+
 func (m *memoryStore) Get(_ string) (io.ReadCloser, error) {
 	return io.NopCloser(bytes.NewReader(nil)), nil
 }
@@ -38,6 +50,14 @@ func (m *memoryStore) Delete(storagePath string) error {
 	return nil
 }
 
+func (m *memoryStore) List() ([]storagebrowser.Object, error) {
+	var objects []storagebrowser.Object
+	for key := range m.files {
+		objects = append(objects, storagebrowser.Object{Path: key})
+	}
+	return objects, nil
+}
+
 func openTestDB(t *testing.T) *db.DB {
 	t.Helper()
 
@@ -2,10 +2,13 @@ package recordings
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
 	"net/http"
+	"os"
 	"path/filepath"
 	"slices"
 	"strings"
@@ -20,25 +23,39 @@ import (
 type Handler struct {
 	database *db.DB
 	store    RecordingStore
+	stores   *Stores
 	config   *config.Config
 }
 
-// NewHandler creates a new recording handler.
+// NewHandler creates a new recording handler that keeps recordings in
+// store, or in their tenant's storage location once enabled on Stores.
 func NewHandler(database *db.DB, store RecordingStore, cfg *config.Config) *Handler {
 	return &Handler{
 		database: database,
 		store:    store,
+		stores:   NewStores(database, store, cfg.RecordingStorageBackend),
 		config:   cfg,
 	}
 }
 
+// Stores returns the handler's store selection, to enable tenant storage
+// locations and to share with the Cleaner.
+func (h *Handler) Stores() *Stores {
+	return h.stores
+}
+
 // ServeHTTP routes recording requests.
 // Expected paths:
 //   - POST   /api/sessions/{id}/recording/start
 //   - POST   /api/sessions/{id}/recording/stop
 //   - POST   /api/sessions/{id}/recording/upload
+//   - POST   /api/sessions/{id}/recording/chunk
+//   - POST   /api/sessions/{id}/recording/complete
 //   - GET    /api/recordings
 //   - GET    /api/recordings/{id}/download
+//   - GET    /api/recordings/{id}/link
+//   - GET    /api/recordings/{id}/stream
+//   - GET    /api/recordings/{id}/stream-link
 //   - DELETE /api/recordings/{id}
 //   - GET    /api/admin/recordings
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -68,6 +85,12 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		switch {
 		case action == "download" && r.Method == http.MethodGet:
 			h.handleDownload(w, r, recordingID)
+		case action == "link" && r.Method == http.MethodGet:
+			h.handleLink(w, r, recordingID)
+		case action == "stream" && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+			h.handleStream(w, r, recordingID)
+		case action == "stream-link" && r.Method == http.MethodGet:
+			h.handleStreamLink(w, r, recordingID)
 		case action == "" && r.Method == http.MethodDelete:
 			h.handleDelete(w, r, recordingID)
 		default:
@@ -119,6 +142,10 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			h.handleStop(w, r)
 		case "upload":
 			h.handleUpload(w, r, session)
+		case "chunk":
+			h.handleChunk(w, r, session)
+		case "complete":
+			h.handleComplete(w, r, session)
 		default:
 			http.Error(w, "Unknown action", http.StatusNotFound)
 		}
@@ -140,6 +167,13 @@ func (h *Handler) handleStart(w http.ResponseWriter, r *http.Request, session *d
 		userID = user.ID
 	}
 
+	_, backend, err := h.stores.ForTenant(session.TenantID)
+	if err != nil {
+		slog.Error("failed to resolve recording storage", "tenant_id", session.TenantID, "error", err)
+		http.Error(w, "Failed to create recording", http.StatusInternalServerError)
+		return
+	}
+
 	id := fmt.Sprintf("rec-%d", time.Now().UnixNano())
 	now := time.Now()
 
@@ -149,7 +183,7 @@ func (h *Handler) handleStart(w http.ResponseWriter, r *http.Request, session *d
 		UserID:         userID,
 		Filename:       fmt.Sprintf("%s-%s.vncrec", session.AppID, now.Format("20060102-150405")),
 		Format:         "vncrec",
-		StorageBackend: h.config.RecordingStorageBackend,
+		StorageBackend: backend,
 		Status:         db.RecordingStatusRecording,
 		TenantID:       session.TenantID,
 		CreatedAt:      now,
@@ -161,7 +195,7 @@ func (h *Handler) handleStart(w http.ResponseWriter, r *http.Request, session *d
 		return
 	}
 
-	h.database.LogAudit(userID, "RECORDING_START", fmt.Sprintf("Started recording %s for session %s", id, session.ID))
+	h.database.LogAuditRequest(middleware.GetRequestID(r.Context()), userID, "RECORDING_START", fmt.Sprintf("Started recording %s for session %s", id, session.ID))
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -232,7 +266,18 @@ func (h *Handler) handleUpload(w http.ResponseWriter, r *http.Request, session *
 	}
 	defer file.Close()
 
-	storagePath, err := h.store.Save(recordingID, file)
+	rec, ok := h.sessionRecording(w, recordingID, session)
+	if !ok {
+		return
+	}
+	store, err := h.storeFor(rec)
+	if err != nil {
+		slog.Error("failed to resolve recording storage", "recording_id", recordingID, "error", err)
+		http.Error(w, "Failed to save recording", http.StatusInternalServerError)
+		return
+	}
+
+	storagePath, err := store.Save(recordingID, file)
 	if err != nil {
 		slog.Error("failed to save recording", "error", err)
 		if uerr := h.database.UpdateRecordingStatus(recordingID, db.RecordingStatusFailed); uerr != nil {
@@ -253,11 +298,11 @@ func (h *Handler) handleUpload(w http.ResponseWriter, r *http.Request, session *
 	if user != nil {
 		userID = user.ID
 	}
-	h.database.LogAudit(userID, "RECORDING_UPLOAD", fmt.Sprintf("Uploaded recording %s for session %s (%d bytes)", recordingID, session.ID, header.Size))
+	h.database.LogAuditRequest(middleware.GetRequestID(r.Context()), userID, "RECORDING_UPLOAD", fmt.Sprintf("Uploaded recording %s for session %s (%d bytes)", recordingID, session.ID, header.Size))
 
 	// Trigger background video conversion for local storage
 	responseStatus := db.RecordingStatusReady
-	if h.config.RecordingStorageBackend == "local" {
+	if h.convertsLocally(rec) {
 		responseStatus = db.RecordingStatusProcessing
 		if err := h.database.UpdateRecordingStatus(recordingID, db.RecordingStatusProcessing); err != nil {
 			slog.Error("failed to set processing status", "error", err)
@@ -269,6 +314,20 @@ func (h *Handler) handleUpload(w http.ResponseWriter, r *http.Request, session *
 	json.NewEncoder(w).Encode(map[string]string{"status": string(responseStatus)})
 }
 
+// storeFor returns the store holding the recording's files.
+func (h *Handler) storeFor(rec *db.Recording) (RecordingStore, error) {
+	if rec.StorageBackend == TenantBackend {
+		return h.stores.ForRecording(rec)
+	}
+	return h.store, nil
+}
+
+// convertsLocally reports whether the recording is converted to MP4 once
+// complete, which needs it on the local disk.
+func (h *Handler) convertsLocally(rec *db.Recording) bool {
+	return h.config.RecordingStorageBackend == "local" && rec.StorageBackend != TenantBackend
+}
+
 // convertToVideo converts a .vncrec recording to MP4 in the background.
 func (h *Handler) convertToVideo(recordingID, storagePath string) {
 	baseDir := filepath.Clean(h.config.RecordingStoragePath)
@@ -286,7 +345,13 @@ func (h *Handler) convertToVideo(recordingID, storagePath string) {
 
 	slog.Info("Starting video conversion", "recording_id", recordingID, "input", inputPath)
 
-	if err := ConvertToMP4(inputPath, outputPath); err != nil {
+	var err error
+	if _, ok := h.store.(*EncryptedStore); ok {
+		err = h.convertEncrypted(storagePath, videoRelPath)
+	} else {
+		err = ConvertToMP4(inputPath, outputPath)
+	}
+	if err != nil {
 		slog.Error("Video conversion failed", "recording_id", recordingID, "error", err)
 		if uerr := h.database.UpdateRecordingStatus(recordingID, db.RecordingStatusFailed); uerr != nil {
 			slog.Error("failed to mark recording as failed after conversion error", "error", uerr)
@@ -309,6 +374,47 @@ func (h *Handler) convertToVideo(recordingID, storagePath string) {
 	slog.Info("Video conversion complete", "recording_id", recordingID, "video_path", videoRelPath)
 }
 
+// convertEncrypted converts an encrypted recording by decrypting it into a
+// temporary directory, so plaintext never lands in the storage directory,
+// and stores the encrypted video at videoPath.
+func (h *Handler) convertEncrypted(storagePath, videoPath string) error {
+	tmpDir, err := os.MkdirTemp("", "sortie-convert-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "recording.vncrec")
+	outputPath := filepath.Join(tmpDir, "recording.mp4")
+
+	src, err := h.store.Get(storagePath)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(inputPath)
+	if err == nil {
+		_, err = io.Copy(f, src)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	src.Close()
+	if err != nil {
+		return fmt.Errorf("failed to decrypt recording: %w", err)
+	}
+
+	if err := ConvertToMP4(inputPath, outputPath); err != nil {
+		return err
+	}
+
+	video, err := os.Open(outputPath)
+	if err != nil {
+		return err
+	}
+	defer video.Close()
+	return h.store.Put(videoPath, video)
+}
+
 func (h *Handler) handleUserRecordings(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -357,16 +463,18 @@ func (h *Handler) handleAdminRecordings(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(recs)
 }
 
-func (h *Handler) handleDownload(w http.ResponseWriter, r *http.Request, recordingID string) {
+// readyRecording loads a recording the user may download, writing an error
+// response and returning nil if there is none or it is not ready.
+func (h *Handler) readyRecording(w http.ResponseWriter, r *http.Request, recordingID string) *db.Recording {
 	rec, err := h.database.GetRecording(recordingID)
 	if err != nil {
 		slog.Error("failed to get recording", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+		return nil
 	}
 	if rec == nil {
 		http.Error(w, "Recording not found", http.StatusNotFound)
-		return
+		return nil
 	}
 
 	// Check access: owner or admin
@@ -374,12 +482,27 @@ func (h *Handler) handleDownload(w http.ResponseWriter, r *http.Request, recordi
 	if user != nil && rec.UserID != user.ID {
 		if !slices.Contains(user.Roles, "admin") {
 			http.Error(w, "Access denied", http.StatusForbidden)
-			return
+			return nil
 		}
 	}
 
 	if rec.Status != db.RecordingStatusReady {
 		http.Error(w, "Recording not ready", http.StatusConflict)
+		return nil
+	}
+	return rec
+}
+
+func (h *Handler) handleDownload(w http.ResponseWriter, r *http.Request, recordingID string) {
+	rec := h.readyRecording(w, r, recordingID)
+	if rec == nil {
+		return
+	}
+
+	store, err := h.storeFor(rec)
+	if err != nil {
+		slog.Error("failed to resolve recording storage", "recording_id", recordingID, "error", err)
+		http.Error(w, "Failed to read recording", http.StatusInternalServerError)
 		return
 	}
 
@@ -390,7 +513,7 @@ func (h *Handler) handleDownload(w http.ResponseWriter, r *http.Request, recordi
 
 	if rec.VideoPath != "" {
 		// Try to serve the MP4 video
-		reader, verr := h.store.Get(rec.VideoPath)
+		reader, verr := store.Get(rec.VideoPath)
 		if verr == nil {
 			defer reader.Close()
 			filename = strings.TrimSuffix(rec.Filename, filepath.Ext(rec.Filename)) + ".mp4"
@@ -403,8 +526,13 @@ func (h *Handler) handleDownload(w http.ResponseWriter, r *http.Request, recordi
 	}
 
 	// Fall back to original vncrec
-	reader, err := h.store.Get(servePath)
+	reader, err := store.Get(servePath)
 	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("recording file is missing", "recording_id", recordingID, "path", servePath)
+			http.Error(w, "Recording file not found", http.StatusNotFound)
+			return
+		}
 		slog.Error("failed to open recording file", "error", err)
 		http.Error(w, "Failed to read recording", http.StatusInternalServerError)
 		return
@@ -420,6 +548,61 @@ func (h *Handler) handleDownload(w http.ResponseWriter, r *http.Request, recordi
 	io.Copy(w, reader)
 }
 
+// linkResponse is a direct download link for a recording.
+type linkResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// handleLink returns a time-limited URL to fetch the recording straight
+// from its store, so large files do not stream through Sortie. It returns
+// 404 when direct links are disabled or the recording's store cannot sign
+// them, such as local or encrypted storage; clients then use /download.
+func (h *Handler) handleLink(w http.ResponseWriter, r *http.Request, recordingID string) {
+	rec := h.readyRecording(w, r, recordingID)
+	if rec == nil {
+		return
+	}
+	if h.config.RecordingS3PresignMinutes <= 0 {
+		http.Error(w, "Direct links are not enabled", http.StatusNotFound)
+		return
+	}
+
+	store, err := h.storeFor(rec)
+	if err != nil {
+		slog.Error("failed to resolve recording storage", "recording_id", recordingID, "error", err)
+		http.Error(w, "Failed to read recording", http.StatusInternalServerError)
+		return
+	}
+	signer, ok := store.(URLSigner)
+	if !ok {
+		http.Error(w, "Direct links are not available for this recording", http.StatusNotFound)
+		return
+	}
+
+	// Link the same file /download would serve
+	path, filename := rec.StoragePath, rec.Filename
+	if rec.VideoPath != "" {
+		path = rec.VideoPath
+		filename = strings.TrimSuffix(rec.Filename, filepath.Ext(rec.Filename)) + ".mp4"
+	}
+	expires := time.Duration(h.config.RecordingS3PresignMinutes) * time.Minute
+	url, err := signer.SignedURL(path, filename, expires)
+	if errors.Is(err, ErrNoSignedURLs) {
+		http.Error(w, "Direct links are not available for this recording", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to sign recording URL", "recording_id", recordingID, "error", err)
+		http.Error(w, "Failed to create link", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(linkResponse{URL: url, ExpiresAt: time.Now().Add(expires).UTC()})
+}
+
 func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request, recordingID string) {
 	rec, err := h.database.GetRecording(recordingID)
 	if err != nil {
@@ -442,13 +625,24 @@ func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request, recording
 	}
 
 	// Delete storage files if they exist
+	store, err := h.storeFor(rec)
+	if err != nil {
+		slog.Error("failed to resolve recording storage", "recording_id", recordingID, "error", err)
+		http.Error(w, "Failed to delete recording", http.StatusInternalServerError)
+		return
+	}
+	chunks, err := h.database.ListRecordingChunks(recordingID)
+	if err != nil {
+		slog.Warn("failed to list recording chunks", "error", err, "recording_id", recordingID)
+	}
+	h.deleteChunks(store, recordingID, chunks)
 	if rec.StoragePath != "" {
-		if err := h.store.Delete(rec.StoragePath); err != nil {
+		if err := store.Delete(rec.StoragePath); err != nil {
 			slog.Warn("failed to delete recording file", "error", err, "path", rec.StoragePath)
 		}
 	}
 	if rec.VideoPath != "" {
-		if err := h.store.Delete(rec.VideoPath); err != nil {
+		if err := store.Delete(rec.VideoPath); err != nil {
 			slog.Warn("failed to delete video file", "error", err, "path", rec.VideoPath)
 		}
 	}
@@ -463,7 +657,7 @@ func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request, recording
 	if user != nil {
 		userID = user.ID
 	}
-	h.database.LogAudit(userID, "RECORDING_DELETE", fmt.Sprintf("Deleted recording %s", recordingID))
+	h.database.LogAuditRequest(middleware.GetRequestID(r.Context()), userID, "RECORDING_DELETE", fmt.Sprintf("Deleted recording %s", recordingID))
 
 	w.WriteHeader(http.StatusNoContent)
 }
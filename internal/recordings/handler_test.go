@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
@@ -12,6 +13,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
 	"github.com/rjsadow/sortie/internal/config"
 	"github.com/rjsadow/sortie/internal/db"
 	"github.com/rjsadow/sortie/internal/db/dbtest"
@@ -484,6 +488,233 @@ func TestHandler_DownloadNotReady(t *testing.T) {
 	}
 }
 
+func TestHandler_DownloadMissingFile(t *testing.T) {
+	handler, database, _ := setupTestHandler(t)
+
+	rec := db.Recording{
+		ID: "gone-rec", SessionID: "test-sess", UserID: "user-1",
+		Filename: "gone.webm", Format: "webm", StorageBackend: "local",
+		StoragePath: "2024/01/gone-rec.webm", Status: db.RecordingStatusReady,
+		CreatedAt: time.Now(),
+	}
+	if err := database.CreateRecording(rec); err != nil {
+		t.Fatalf("CreateRecording() error = %v", err)
+	}
+
+	req := reqWithUser(httptest.NewRequest(http.MethodGet, "/api/recordings/gone-rec/download", nil), ownerUser())
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d (body: %s)", rr.Code, http.StatusNotFound, rr.Body.String())
+	}
+}
+
+func TestHandler_RecordingLink(t *testing.T) {
+	database := setupTestDB(t)
+	store := NewS3StoreWithClient(newMockS3Client(), "test-bucket", "recordings/")
+	store.SetPresigner(s3.NewPresignClient(s3.New(s3.Options{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+	})))
+	cfg := &config.Config{RecordingStorageBackend: "s3", RecordingS3PresignMinutes: 10}
+	handler := NewHandler(database, store, cfg)
+
+	rec := db.Recording{
+		ID: "s3-rec", SessionID: "test-sess", UserID: "user-1",
+		Filename: "demo.vncrec", Format: "vncrec", StorageBackend: "s3",
+		StoragePath: "recordings/2024/01/s3-rec.vncrec", Status: db.RecordingStatusReady,
+		CreatedAt: time.Now(),
+	}
+	if err := database.CreateRecording(rec); err != nil {
+		t.Fatalf("CreateRecording() error = %v", err)
+	}
+
+	get := func(user *plugins.User) *httptest.ResponseRecorder {
+		req := reqWithUser(httptest.NewRequest(http.MethodGet, "/api/recordings/s3-rec/link", nil), user)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("owner gets a signed link", func(t *testing.T) {
+		rr := get(ownerUser())
+		if rr.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d (body: %s)", rr.Code, http.StatusOK, rr.Body.String())
+		}
+		var resp linkResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(resp.URL, "s3-rec.vncrec") || !strings.Contains(resp.URL, "X-Amz-Expires=600") {
+			t.Errorf("url = %s", resp.URL)
+		}
+		if until := time.Until(resp.ExpiresAt); until < 9*time.Minute || until > 10*time.Minute {
+			t.Errorf("expires_at = %v, want in 10 minutes", resp.ExpiresAt)
+		}
+	})
+
+	t.Run("other users are denied", func(t *testing.T) {
+		if rr := get(otherUser()); rr.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want %d", rr.Code, http.StatusForbidden)
+		}
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		cfg.RecordingS3PresignMinutes = 0
+		defer func() { cfg.RecordingS3PresignMinutes = 10 }()
+		if rr := get(ownerUser()); rr.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want %d", rr.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("stores that cannot sign fall back", func(t *testing.T) {
+		local := NewHandler(database, NewLocalStore(t.TempDir()), cfg)
+		req := reqWithUser(httptest.NewRequest(http.MethodGet, "/api/recordings/s3-rec/link", nil), ownerUser())
+		rr := httptest.NewRecorder()
+		local.ServeHTTP(rr, req)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want %d", rr.Code, http.StatusNotFound)
+		}
+	})
+}
+
+func TestHandler_StreamRecording(t *testing.T) {
+	handler, database, store := setupTestHandler(t)
+	handler.config.JWTSecret = "test-secret"
+
+	content := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	if err := store.Put("2024/01/stream-rec.mp4", bytes.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range []db.Recording{
+		{
+			ID: "stream-rec", SessionID: "test-sess", UserID: "user-1",
+			Filename: "stream.vncrec", Format: "vncrec", StorageBackend: "local",
+			StoragePath: "2024/01/stream-rec.vncrec", VideoPath: "2024/01/stream-rec.mp4",
+			Status: db.RecordingStatusReady, CreatedAt: time.Now(),
+		},
+		{
+			ID: "unconverted-rec", SessionID: "test-sess", UserID: "user-1",
+			Filename: "raw.vncrec", Format: "vncrec", StorageBackend: "local",
+			StoragePath: "2024/01/raw.vncrec", Status: db.RecordingStatusReady, CreatedAt: time.Now(),
+		},
+	} {
+		if err := database.CreateRecording(rec); err != nil {
+			t.Fatalf("CreateRecording() error = %v", err)
+		}
+	}
+
+	stream := func(target string, user *plugins.User, rangeHeader string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if user != nil {
+			req = reqWithUser(req, user)
+		}
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("whole file", func(t *testing.T) {
+		rr := stream("/api/recordings/stream-rec/stream", ownerUser(), "")
+		if rr.Code != http.StatusOK || !bytes.Equal(rr.Body.Bytes(), content) {
+			t.Fatalf("status = %d, body = %q", rr.Code, rr.Body.String())
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "video/mp4" {
+			t.Errorf("Content-Type = %s, want video/mp4", ct)
+		}
+		if ar := rr.Header().Get("Accept-Ranges"); ar != "bytes" {
+			t.Errorf("Accept-Ranges = %q, want bytes", ar)
+		}
+	})
+
+	t.Run("byte range", func(t *testing.T) {
+		rr := stream("/api/recordings/stream-rec/stream", ownerUser(), "bytes=10-15")
+		if rr.Code != http.StatusPartialContent {
+			t.Fatalf("status = %d, want %d", rr.Code, http.StatusPartialContent)
+		}
+		if got := rr.Body.String(); got != "abcdef" {
+			t.Errorf("body = %q, want abcdef", got)
+		}
+		if cr := rr.Header().Get("Content-Range"); cr != "bytes 10-15/36" {
+			t.Errorf("Content-Range = %q", cr)
+		}
+	})
+
+	t.Run("unsatisfiable range", func(t *testing.T) {
+		rr := stream("/api/recordings/stream-rec/stream", ownerUser(), "bytes=100-")
+		if rr.Code != http.StatusRequestedRangeNotSatisfiable {
+			t.Errorf("status = %d, want %d", rr.Code, http.StatusRequestedRangeNotSatisfiable)
+		}
+	})
+
+	t.Run("other users are denied", func(t *testing.T) {
+		if rr := stream("/api/recordings/stream-rec/stream", otherUser(), ""); rr.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want %d", rr.Code, http.StatusForbidden)
+		}
+	})
+
+	t.Run("unconverted recordings are not playable", func(t *testing.T) {
+		if rr := stream("/api/recordings/unconverted-rec/stream", ownerUser(), ""); rr.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want %d", rr.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("signed URL", func(t *testing.T) {
+		rr := stream("/api/recordings/stream-rec/stream-link", ownerUser(), "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("status = %d (body: %s)", rr.Code, rr.Body.String())
+		}
+		var link linkResponse
+		if err := json.NewDecoder(rr.Body).Decode(&link); err != nil {
+			t.Fatal(err)
+		}
+		if until := time.Until(link.ExpiresAt); until < StreamURLTTL-time.Minute || until > StreamURLTTL {
+			t.Errorf("expires_at = %v, want in %v", link.ExpiresAt, StreamURLTTL)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, link.URL, nil)
+		if !handler.SignedStream(req) {
+			t.Fatalf("SignedStream(%s) = false", link.URL)
+		}
+		rr = stream(link.URL, nil, "bytes=0-9")
+		if rr.Code != http.StatusPartialContent || rr.Body.String() != "0123456789" {
+			t.Errorf("signed stream: status = %d, body = %q", rr.Code, rr.Body.String())
+		}
+
+		// The signature covers the recording and the expiry
+		other := strings.Replace(link.URL, "stream-rec", "unconverted-rec", 1)
+		later := strings.Replace(link.URL, "expires=", "expires=9", 1)
+		for _, target := range []string{other, later, "/api/recordings/stream-rec/stream", "/api/recordings/stream-rec/stream?expires=9999999999&signature=bogus"} {
+			if handler.SignedStream(httptest.NewRequest(http.MethodGet, target, nil)) {
+				t.Errorf("SignedStream(%s) = true", target)
+			}
+			if rr := stream(target, nil, ""); rr.Code != http.StatusUnauthorized {
+				t.Errorf("%s: status = %d, want %d", target, rr.Code, http.StatusUnauthorized)
+			}
+		}
+	})
+
+	t.Run("expired signed URL", func(t *testing.T) {
+		expires := time.Now().Add(-time.Minute).Unix()
+		target := fmt.Sprintf("/api/recordings/stream-rec/stream?expires=%d&signature=%s", expires, handler.streamSignature("stream-rec", expires))
+		if handler.SignedStream(httptest.NewRequest(http.MethodGet, target, nil)) {
+			t.Error("expired URL accepted")
+		}
+	})
+
+	t.Run("no signed URLs without auth", func(t *testing.T) {
+		handler.config.JWTSecret = ""
+		defer func() { handler.config.JWTSecret = "test-secret" }()
+		if rr := stream("/api/recordings/stream-rec/stream-link", ownerUser(), ""); rr.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want %d", rr.Code, http.StatusNotFound)
+		}
+	})
+}
+
 func TestHandler_DeleteRecording(t *testing.T) {
 	handler, database, store := setupTestHandler(t)
 
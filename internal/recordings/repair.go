@@ -0,0 +1,109 @@
+package recordings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/leader"
+)
+
+// Repairer finalizes recordings whose client stopped sending chunks without
+// completing them, for example because the browser or a server pod went
+// away, from the chunks that did arrive. Recordings without any chunk are
+// marked failed.
+type Repairer struct {
+	handler    *Handler
+	staleAfter time.Duration
+	interval   time.Duration
+	stopCh     chan struct{}
+	leader     leader.Checker
+}
+
+// NewRepairer creates a Repairer that finalizes recordings with no chunk
+// for staleAfter.
+func NewRepairer(h *Handler, staleAfter time.Duration) *Repairer {
+	return &Repairer{
+		handler:    h,
+		staleAfter: staleAfter,
+		interval:   1 * time.Minute,
+		stopCh:     make(chan struct{}),
+	}
+}
+
+// SetLeader makes the repairer run only while l reports this replica as
+// leader. Call it before Start.
+func (r *Repairer) SetLeader(l leader.Checker) {
+	r.leader = l
+}
+
+// Start launches the repair goroutine, which also runs once right away to
+// pick up recordings left by a previous process. It returns immediately.
+func (r *Repairer) Start() {
+	go r.loop()
+}
+
+// Stop signals the repair goroutine to exit.
+func (r *Repairer) Stop() {
+	close(r.stopCh)
+}
+
+func (r *Repairer) loop() {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if leader.IsLeader(r.leader) {
+			r.run()
+		}
+		select {
+		case <-ticker.C:
+		case <-r.stopCh:
+			return
+		}
+	}
+}
+
+func (r *Repairer) run() {
+	if err := r.Run(context.Background()); err != nil {
+		slog.Warn("Recording repair failed", "error", err)
+	}
+}
+
+// Run finalizes stale recordings once. It returns an error if they could
+// not be listed or any of them could not be finalized.
+func (r *Repairer) Run(ctx context.Context) error {
+	stale, err := r.handler.database.ListStaleRecordings(time.Now().Add(-r.staleAfter))
+	if err != nil {
+		return fmt.Errorf("failed to list stale recordings: %w", err)
+	}
+
+	failed := 0
+	for _, rec := range stale {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		status, size, err := r.handler.finalizeChunks(&rec, 0)
+		switch {
+		case errors.Is(err, errNotInProgress):
+			// Completed by the client meanwhile.
+		case errors.Is(err, errNoChunks):
+			slog.Warn("Recording repair: no chunks uploaded, marked failed", "recording_id", rec.ID)
+		case err != nil:
+			slog.Warn("Recording repair: failed to finalize recording", "recording_id", rec.ID, "error", err)
+			failed++
+		default:
+			slog.Info("Recording repair: finalized partial recording",
+				"recording_id", rec.ID,
+				"status", status,
+				"size_bytes", size)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to finalize %d of %d stale recordings", failed, len(stale))
+	}
+	return nil
+}
@@ -1,15 +1,81 @@
 package recordings
 
-import "io"
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/storagebrowser"
+)
 
 // RecordingStore abstracts video recording file storage.
 type RecordingStore interface {
 	// Save writes a recording file from the reader and returns the storage path.
 	Save(id string, r io.Reader) (storagePath string, err error)
 
+	// SaveChunk writes one chunk of an in-progress recording and returns its
+	// storage path. Saving the same id and seq again replaces the chunk.
+	SaveChunk(id string, seq int, r io.Reader) (storagePath string, err error)
+
+	// Put writes a file at a storage path returned by Save or SaveChunk,
+	// or derived from one, replacing any existing file.
+	Put(storagePath string, r io.Reader) error
+
 	// Get returns a ReadCloser for the recording file at the given storage path.
 	Get(storagePath string) (io.ReadCloser, error)
 
 	// Delete removes the recording file at the given storage path.
 	Delete(storagePath string) error
+
+	// List returns every stored file, with paths in the same form as Save.
+	List() ([]storagebrowser.Object, error)
+}
+
+// URLSigner is implemented by stores that can hand clients a time-limited
+// URL to download a file from directly, instead of through Sortie.
+type URLSigner interface {
+	// SignedURL returns a URL that downloads the file at storagePath,
+	// saved as filename, until expires has passed.
+	SignedURL(storagePath, filename string, expires time.Duration) (string, error)
+}
+
+// SeekableStore is implemented by stores that can open a file for random
+// access, so playback can serve byte ranges without reading the whole
+// file. Stores without it are streamed from the start.
+type SeekableStore interface {
+	// Open returns the file at storagePath for reading and seeking.
+	Open(storagePath string) (io.ReadSeekCloser, error)
+}
+
+// ReferencedPaths returns the storage and video paths of every recording in
+// the database, and the paths of chunks not yet assembled, for orphan
+// detection.
+func ReferencedPaths(database *db.DB) (map[string]bool, error) {
+	recs, err := database.ListAllRecordings()
+	if err != nil {
+		return nil, err
+	}
+	refs := make(map[string]bool, 2*len(recs))
+	for _, rec := range recs {
+		if rec.StoragePath != "" {
+			refs[rec.StoragePath] = true
+		}
+		if rec.VideoPath != "" {
+			refs[rec.VideoPath] = true
+		}
+	}
+	chunks, err := database.ListAllRecordingChunks()
+	if err != nil {
+		return nil, err
+	}
+	for _, chunk := range chunks {
+		refs[chunk.StoragePath] = true
+	}
+	return refs, nil
+}
+
+// chunkName returns the file name of a recording chunk, sortable by seq.
+func chunkName(seq int) string {
+	return fmt.Sprintf("%06d.part", seq)
 }
@@ -0,0 +1,81 @@
+package recordings
+
+import (
+	"io"
+
+	"github.com/rjsadow/sortie/internal/storagebrowser"
+	"github.com/rjsadow/sortie/internal/storagecrypt"
+)
+
+// EncryptedStore encrypts files written to another RecordingStore and
+// decrypts them on read. Files stored before encryption was enabled are
+// only read if the cipher allows plaintext.
+type EncryptedStore struct {
+	store  RecordingStore
+	cipher *storagecrypt.Cipher
+}
+
+// NewEncryptedStore wraps store so files are encrypted at rest with c.
+func NewEncryptedStore(store RecordingStore, c *storagecrypt.Cipher) *EncryptedStore {
+	return &EncryptedStore{store: store, cipher: c}
+}
+
+// Unwrap returns the underlying store, which reads and writes files as
+// stored.
+func (s *EncryptedStore) Unwrap() RecordingStore {
+	return s.store
+}
+
+// Save encrypts and writes a recording file.
+func (s *EncryptedStore) Save(id string, r io.Reader) (string, error) {
+	enc, err := s.cipher.Encrypt(r)
+	if err != nil {
+		return "", err
+	}
+	return s.store.Save(id, enc)
+}
+
+// SaveChunk encrypts and writes a recording chunk.
+func (s *EncryptedStore) SaveChunk(id string, seq int, r io.Reader) (string, error) {
+	enc, err := s.cipher.Encrypt(r)
+	if err != nil {
+		return "", err
+	}
+	return s.store.SaveChunk(id, seq, enc)
+}
+
+// Put encrypts and writes a file at the given storage path.
+func (s *EncryptedStore) Put(storagePath string, r io.Reader) error {
+	enc, err := s.cipher.Encrypt(r)
+	if err != nil {
+		return err
+	}
+	return s.store.Put(storagePath, enc)
+}
+
+// Get returns the decrypted contents of the file at the given storage path.
+func (s *EncryptedStore) Get(storagePath string) (io.ReadCloser, error) {
+	rc, err := s.store.Get(storagePath)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := s.cipher.Decrypt(rc)
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{plain, rc}, nil
+}
+
+// Delete removes the file at the given storage path.
+func (s *EncryptedStore) Delete(storagePath string) error {
+	return s.store.Delete(storagePath)
+}
+
+// List returns every stored file. Sizes are the encrypted sizes.
+func (s *EncryptedStore) List() ([]storagebrowser.Object, error) {
+	return s.store.List()
+}
@@ -0,0 +1,112 @@
+package recordings
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/storagecrypt"
+)
+
+func testCipher(t *testing.T) *storagecrypt.Cipher {
+	t.Helper()
+	keys, err := storagecrypt.ParseKeys("test:" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+	if err != nil {
+		t.Fatalf("ParseKeys() error = %v", err)
+	}
+	return storagecrypt.New(keys)
+}
+
+func readAll(t *testing.T, store RecordingStore, path string) []byte {
+	t.Helper()
+	r, err := store.Get(path)
+	if err != nil {
+		t.Fatalf("Get(%s) error = %v", path, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll(%s) error = %v", path, err)
+	}
+	return data
+}
+
+func TestEncryptedStore(t *testing.T) {
+	baseDir := t.TempDir()
+	local := NewLocalStore(baseDir)
+	store := NewEncryptedStore(local, testCipher(t))
+	content := []byte("VREC recording data")
+
+	path, err := store.Save("enc-rec", bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	onDisk, _ := os.ReadFile(filepath.Join(baseDir, path))
+	if bytes.Contains(onDisk, content) {
+		t.Error("recording stored in plaintext")
+	}
+	if keyID, _ := storagecrypt.Inspect(bytes.NewReader(onDisk)); keyID != "test" {
+		t.Errorf("stored key ID = %q, want test", keyID)
+	}
+	if got := readAll(t, store, path); !bytes.Equal(got, content) {
+		t.Errorf("Get() = %q, want %q", got, content)
+	}
+
+	chunkPath, err := store.SaveChunk("enc-rec", 0, bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("SaveChunk() error = %v", err)
+	}
+	if got := readAll(t, store, chunkPath); !bytes.Equal(got, content) {
+		t.Errorf("Get(chunk) = %q, want %q", got, content)
+	}
+
+	if err := store.Put("2024/01/enc-rec.mp4", bytes.NewReader(content)); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if got := readAll(t, local, "2024/01/enc-rec.mp4"); bytes.Equal(got, content) {
+		t.Error("Put() stored plaintext")
+	}
+
+	// Recordings stored before encryption was enabled are refused, unless
+	// the cipher allows plaintext
+	legacy, err := local.Save("legacy-rec", bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if _, err := store.Get(legacy); !errors.Is(err, storagecrypt.ErrNotEncrypted) {
+		t.Errorf("Get(legacy) error = %v, want ErrNotEncrypted", err)
+	}
+	c := testCipher(t)
+	c.AllowPlaintext()
+	if got := readAll(t, NewEncryptedStore(local, c), legacy); !bytes.Equal(got, content) {
+		t.Errorf("Get(legacy) = %q, want %q", got, content)
+	}
+}
+
+func TestHandler_ChunkedRecordingEncrypted(t *testing.T) {
+	handler, database, mock := setupChunkHandler(t, "enc-chunk-rec")
+	handler.store = NewEncryptedStore(handler.store, testCipher(t))
+
+	for seq, data := range []string{"VREC-header|", "frame-1"} {
+		if rr := postChunk(t, handler, "enc-chunk-rec", strconv.Itoa(seq), data); rr.Code != http.StatusNoContent {
+			t.Fatalf("chunk %d: status = %d (body: %s)", seq, rr.Code, rr.Body.String())
+		}
+	}
+	if rr := postComplete(t, handler, `{"recording_id":"enc-chunk-rec"}`); rr.Code != http.StatusOK {
+		t.Fatalf("complete: status = %d (body: %s)", rr.Code, rr.Body.String())
+	}
+
+	rec, _ := database.GetRecording("enc-chunk-rec")
+	if bytes.Contains(mock.objects[rec.StoragePath], []byte("frame-1")) {
+		t.Error("assembled recording stored in plaintext")
+	}
+	if got := readAll(t, handler.store, rec.StoragePath); string(got) != "VREC-header|frame-1" {
+		t.Errorf("assembled recording = %q", got)
+	}
+}
@@ -1,16 +1,21 @@
 package recordings
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/rjsadow/sortie/internal/storagebrowser"
 )
 
 // LocalStore implements RecordingStore using the local filesystem.
-// Files are stored at {baseDir}/{year}/{month}/{id}.vncrec.
+// Files are stored at {baseDir}/{year}/{month}/{id}.vncrec, and chunks of
+// in-progress recordings at {baseDir}/chunks/{id}/.
 type LocalStore struct {
 	baseDir string
 }
@@ -25,42 +30,71 @@ func (s *LocalStore) Save(id string, r io.Reader) (string, error) {
 	now := time.Now()
 	cleanID := filepath.Base(id) // strip any directory components
 	relPath := filepath.Join(fmt.Sprintf("%d", now.Year()), fmt.Sprintf("%02d", now.Month()), cleanID+".vncrec")
+	if err := s.write(relPath, r); err != nil {
+		return "", err
+	}
+	return relPath, nil
+}
+
+// SaveChunk writes a chunk of an in-progress recording to
+// {baseDir}/chunks/{id}/{seq}.part and returns the relative storage path.
+func (s *LocalStore) SaveChunk(id string, seq int, r io.Reader) (string, error) {
+	cleanID := filepath.Base(id) // strip any directory components
+	relPath := filepath.Join("chunks", cleanID, chunkName(seq))
+	if err := s.write(relPath, r); err != nil {
+		return "", err
+	}
+	return relPath, nil
+}
 
+// Put writes a file at the given relative storage path.
+func (s *LocalStore) Put(storagePath string, r io.Reader) error {
+	return s.write(storagePath, r)
+}
+
+// write copies r to relPath under the base directory, creating parent
+// directories as needed.
+func (s *LocalStore) write(relPath string, r io.Reader) error {
 	// Validate path stays within baseDir
 	fullPath := filepath.Clean(filepath.Join(s.baseDir, relPath))
 	absBase, err := filepath.Abs(s.baseDir)
 	if err != nil {
-		return "", fmt.Errorf("invalid base dir: %w", err)
+		return fmt.Errorf("invalid base dir: %w", err)
 	}
 	absPath, err := filepath.Abs(fullPath)
 	if err != nil {
-		return "", fmt.Errorf("invalid path: %w", err)
+		return fmt.Errorf("invalid path: %w", err)
 	}
 	if !strings.HasPrefix(absPath, absBase+string(filepath.Separator)) {
-		return "", fmt.Errorf("invalid recording id: path traversal detected")
+		return fmt.Errorf("invalid recording id: path traversal detected")
 	}
 
 	dir := filepath.Dir(absPath)
 	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return "", fmt.Errorf("failed to create directory %s: %w", dir, err)
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
 	}
 
 	f, err := os.Create(absPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to create file %s: %w", absPath, err)
+		return fmt.Errorf("failed to create file %s: %w", absPath, err)
 	}
 	defer f.Close()
 
 	if _, err := io.Copy(f, r); err != nil {
 		os.Remove(absPath)
-		return "", fmt.Errorf("failed to write recording: %w", err)
+		return fmt.Errorf("failed to write recording: %w", err)
 	}
-
-	return relPath, nil
+	return nil
 }
 
 // Get opens the recording file at the given storage path for reading.
 func (s *LocalStore) Get(storagePath string) (io.ReadCloser, error) {
+	return s.Open(storagePath)
+}
+
+// Open opens the recording file at the given storage path for reading and
+// seeking.
+func (s *LocalStore) Open(storagePath string) (io.ReadSeekCloser, error) {
 	// Validate path stays within baseDir
 	fullPath := filepath.Clean(filepath.Join(s.baseDir, storagePath))
 	absBase, err := filepath.Abs(s.baseDir)
@@ -101,5 +135,44 @@ func (s *LocalStore) Delete(storagePath string) error {
 	if err := os.Remove(absPath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to delete recording: %w", err)
 	}
+	// Each recording's chunks have their own directory; drop it once empty.
+	if dir := filepath.Dir(absPath); filepath.Base(filepath.Dir(dir)) == "chunks" {
+		os.Remove(dir)
+	}
 	return nil
 }
+
+// List walks the base directory and returns every file with its path
+// relative to the base directory. A missing base directory is empty.
+func (s *LocalStore) List() ([]storagebrowser.Object, error) {
+	var objects []storagebrowser.Object
+	err := filepath.WalkDir(s.baseDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == s.baseDir && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(s.baseDir, path)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, storagebrowser.Object{
+			Path:    relPath,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recordings: %w", err)
+	}
+	return objects, nil
+}
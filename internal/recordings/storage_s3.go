@@ -1,15 +1,24 @@
 package recordings
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"os"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/rjsadow/sortie/internal/offline"
+	"github.com/rjsadow/sortie/internal/storagebrowser"
 )
 
 // S3API defines the subset of the S3 client used by S3Store, enabling test mocking.
@@ -17,22 +26,65 @@ type S3API interface {
 	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
 	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
 	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+}
+
+// S3MultipartAPI is the part of the S3 client used for multipart uploads.
+// Clients without it upload each file with a single PutObject.
+type S3MultipartAPI interface {
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+}
+
+// S3HeadAPI is the part of the S3 client used to look up an object's size.
+// Clients without it learn the size from a GetObject of the whole object.
+type S3HeadAPI interface {
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+}
+
+// S3Presigner signs GetObject requests for clients to make directly.
+type S3Presigner interface {
+	PresignGetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
 }
 
+// s3PartSize is the size of each part of a multipart upload. Files no
+// larger than one part are uploaded with a single PutObject.
+const s3PartSize = 8 << 20 // 8 MiB
+
+// ErrNoSignedURLs is returned by SignedURL when the store cannot sign URLs.
+var ErrNoSignedURLs = errors.New("signed URLs are not available")
+
 // S3Store implements RecordingStore using an S3-compatible object store.
 type S3Store struct {
-	client S3API
-	bucket string
-	prefix string
+	client    S3API
+	presigner S3Presigner
+	bucket    string
+	prefix    string
+	kmsKeyID  string
 }
 
 // NewS3Store creates an S3Store configured from AWS defaults and the given parameters.
+// See NewS3Client for how the endpoint and credentials are used.
+func NewS3Store(bucket, region, endpoint, prefix, accessKeyID, secretAccessKey string) (*S3Store, error) {
+	client, err := NewS3Client(region, endpoint, accessKeyID, secretAccessKey)
+	if err != nil {
+		return nil, err
+	}
+	store := NewS3StoreWithClient(client, bucket, prefix)
+	store.SetPresigner(s3.NewPresignClient(client))
+	return store, nil
+}
+
+// NewS3Client creates an S3 client from AWS defaults and the given parameters.
 // An empty endpoint uses the standard AWS S3 endpoint; a non-empty endpoint targets
 // MinIO or another S3-compatible service. When accessKeyID and secretAccessKey are
 // both non-empty, static credentials are used instead of the default credential chain.
-func NewS3Store(bucket, region, endpoint, prefix, accessKeyID, secretAccessKey string) (*S3Store, error) {
+func NewS3Client(region, endpoint, accessKeyID, secretAccessKey string) (*s3.Client, error) {
 	opts := []func(*awsconfig.LoadOptions) error{
 		awsconfig.WithRegion(region),
+		awsconfig.WithHTTPClient(offline.Client(0)),
 	}
 
 	if accessKeyID != "" && secretAccessKey != "" {
@@ -54,8 +106,7 @@ func NewS3Store(bucket, region, endpoint, prefix, accessKeyID, secretAccessKey s
 		})
 	}
 
-	client := s3.NewFromConfig(cfg, s3Opts...)
-	return NewS3StoreWithClient(client, bucket, prefix), nil
+	return s3.NewFromConfig(cfg, s3Opts...), nil
 }
 
 // NewS3StoreWithClient creates an S3Store with an injected S3API client (for testing).
@@ -67,44 +118,358 @@ func NewS3StoreWithClient(client S3API, bucket, prefix string) *S3Store {
 	}
 }
 
+// SetPresigner lets the store sign URLs for clients to download files
+// from the bucket directly. See SignedURL.
+func (s *S3Store) SetPresigner(p S3Presigner) {
+	s.presigner = p
+}
+
+// SetKMSKey makes the store encrypt uploads with the given AWS KMS key
+// (SSE-KMS) rather than the bucket's default encryption.
+func (s *S3Store) SetKMSKey(keyID string) {
+	s.kmsKeyID = keyID
+}
+
 // Save uploads a recording to S3 and returns the object key as the storage path.
 func (s *S3Store) Save(id string, r io.Reader) (string, error) {
 	now := time.Now()
 	key := fmt.Sprintf("%s%d/%02d/%s.vncrec", s.prefix, now.Year(), now.Month(), id)
 
-	_, err := s.client.PutObject(context.Background(), &s3.PutObjectInput{
+	if err := s.put(key, r); err != nil {
+		return "", fmt.Errorf("failed to upload recording to S3: %w", err)
+	}
+
+	return key, nil
+}
+
+// SaveChunk uploads a chunk of an in-progress recording under
+// {prefix}chunks/{id}/ and returns the object key as the storage path.
+func (s *S3Store) SaveChunk(id string, seq int, r io.Reader) (string, error) {
+	key := fmt.Sprintf("%schunks/%s/%s", s.prefix, id, chunkName(seq))
+
+	if err := s.put(key, r); err != nil {
+		return "", fmt.Errorf("failed to upload recording chunk to S3: %w", err)
+	}
+
+	return key, nil
+}
+
+// Put uploads a file to S3 at the given object key.
+func (s *S3Store) Put(storagePath string, r io.Reader) error {
+	if err := s.put(storagePath, r); err != nil {
+		return fmt.Errorf("failed to upload recording to S3: %w", err)
+	}
+	return nil
+}
+
+// put uploads r to key. Files larger than one part are sent as a multipart
+// upload, buffering a part at a time, so recordings of any length upload
+// without spooling to disk. A failed multipart upload is aborted so its
+// parts do not linger, and bill, in the bucket.
+func (s *S3Store) put(key string, r io.Reader) error {
+	mp, ok := s.client.(S3MultipartAPI)
+	if !ok {
+		return s.putObject(key, r)
+	}
+
+	first := make([]byte, s3PartSize)
+	n, err := io.ReadFull(r, first)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return s.putObject(key, bytes.NewReader(first[:n]))
+	}
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	create := &s3.CreateMultipartUploadInput{
 		Bucket:      aws.String(s.bucket),
 		Key:         aws.String(key),
-		Body:        r,
 		ContentType: aws.String("application/octet-stream"),
+	}
+	if s.kmsKeyID != "" {
+		create.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+		create.SSEKMSKeyId = aws.String(s.kmsKeyID)
+	}
+	upload, err := mp.CreateMultipartUpload(ctx, create)
+	if err != nil {
+		return err
+	}
+	if err := s.uploadParts(ctx, mp, upload.UploadId, key, first, r); err != nil {
+		if _, aerr := mp.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(s.bucket),
+			Key:      aws.String(key),
+			UploadId: upload.UploadId,
+		}); aerr != nil {
+			return fmt.Errorf("%w (abort failed: %v)", err, aerr)
+		}
+		return err
+	}
+	return nil
+}
+
+// uploadParts uploads part, then the rest of r in parts, and completes the
+// multipart upload.
+func (s *S3Store) uploadParts(ctx context.Context, mp S3MultipartAPI, uploadID *string, key string, part []byte, r io.Reader) error {
+	var completed []types.CompletedPart
+	buf := make([]byte, s3PartSize)
+	for num := int32(1); len(part) > 0; num++ {
+		out, err := mp.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:     aws.String(s.bucket),
+			Key:        aws.String(key),
+			UploadId:   uploadID,
+			PartNumber: aws.Int32(num),
+			Body:       bytes.NewReader(part),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to upload part %d: %w", num, err)
+		}
+		completed = append(completed, types.CompletedPart{ETag: out.ETag, PartNumber: aws.Int32(num)})
+
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		part = buf[:n]
+	}
+	_, err := mp.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             aws.String(key),
+		UploadId:        uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
 	})
+	return err
+}
+
+// putObject uploads r to key in one request. S3 needs the length of a body
+// up front, so readers that cannot seek, such as assembled or encrypted
+// streams, are spooled to a temporary file first.
+func (s *S3Store) putObject(key string, r io.Reader) error {
+	body, cleanup, err := Seekable(r)
 	if err != nil {
-		return "", fmt.Errorf("failed to upload recording to S3: %w", err)
+		return err
 	}
+	defer cleanup()
 
-	return key, nil
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String("application/octet-stream"),
+	}
+	if s.kmsKeyID != "" {
+		input.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+		input.SSEKMSKeyId = aws.String(s.kmsKeyID)
+	}
+	_, err = s.client.PutObject(context.Background(), input)
+	return err
+}
+
+// Seekable returns r if it can seek, or else a temporary file holding its
+// contents. Call cleanup when done with the returned reader.
+func Seekable(r io.Reader) (rs io.ReadSeeker, cleanup func(), err error) {
+	if rs, ok := r.(io.ReadSeeker); ok {
+		return rs, func() {}, nil
+	}
+	f, err := os.CreateTemp("", "sortie-upload-*")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create spool file: %w", err)
+	}
+	cleanup = func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to spool upload: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return f, cleanup, nil
 }
 
-// Get returns the S3 object body as an io.ReadCloser.
+// Get returns the S3 object body as an io.ReadCloser. An object that does
+// not exist, for example because a bucket lifecycle rule expired it, is
+// reported as fs.ErrNotExist like a missing local file.
 func (s *S3Store) Get(storagePath string) (io.ReadCloser, error) {
 	out, err := s.client.GetObject(context.Background(), &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(storagePath),
 	})
 	if err != nil {
+		if isNoSuchKey(err) {
+			return nil, fmt.Errorf("failed to get recording from S3: %w (%v)", fs.ErrNotExist, err)
+		}
 		return nil, fmt.Errorf("failed to get recording from S3: %w", err)
 	}
 	return out.Body, nil
 }
 
-// Delete removes the recording object from S3.
+// Open returns the S3 object for reading and seeking. Its size comes from a
+// HeadObject, so nothing is downloaded until the first read. Seeking is
+// cheap: the next read after a seek fetches the rest of the object from the
+// new offset with a ranged GetObject.
+func (s *S3Store) Open(storagePath string) (io.ReadSeekCloser, error) {
+	if head, ok := s.client.(S3HeadAPI); ok {
+		out, err := head.HeadObject(context.Background(), &s3.HeadObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(storagePath),
+		})
+		if err != nil {
+			if isNoSuchKey(err) {
+				return nil, fmt.Errorf("failed to get recording from S3: %w (%v)", fs.ErrNotExist, err)
+			}
+			return nil, fmt.Errorf("failed to get recording from S3: %w", err)
+		}
+		if out.ContentLength == nil {
+			return nil, fmt.Errorf("failed to get recording from S3: no content length for %s", storagePath)
+		}
+		return &s3Object{store: s, key: storagePath, size: *out.ContentLength}, nil
+	}
+
+	// Without HeadObject, the size comes from a GetObject whose body is
+	// kept for reading from the start
+	out, err := s.client.GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(storagePath),
+	})
+	if err != nil {
+		if isNoSuchKey(err) {
+			return nil, fmt.Errorf("failed to get recording from S3: %w (%v)", fs.ErrNotExist, err)
+		}
+		return nil, fmt.Errorf("failed to get recording from S3: %w", err)
+	}
+	if out.ContentLength == nil {
+		out.Body.Close()
+		return nil, fmt.Errorf("failed to get recording from S3: no content length for %s", storagePath)
+	}
+	return &s3Object{store: s, key: storagePath, size: *out.ContentLength, body: out.Body}, nil
+}
+
+// s3Object reads an S3 object from any offset. body, when open, is
+// positioned at bodyPos.
+type s3Object struct {
+	store   *S3Store
+	key     string
+	size    int64
+	pos     int64
+	body    io.ReadCloser
+	bodyPos int64
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.pos >= o.size {
+		return 0, io.EOF
+	}
+	if o.body != nil && o.bodyPos != o.pos {
+		o.body.Close()
+		o.body = nil
+	}
+	if o.body == nil {
+		input := &s3.GetObjectInput{
+			Bucket: aws.String(o.store.bucket),
+			Key:    aws.String(o.key),
+		}
+		if o.pos > 0 {
+			input.Range = aws.String(fmt.Sprintf("bytes=%d-", o.pos))
+		}
+		out, err := o.store.client.GetObject(context.Background(), input)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get recording from S3: %w", err)
+		}
+		o.body, o.bodyPos = out.Body, o.pos
+	}
+	n, err := o.body.Read(p)
+	o.pos += int64(n)
+	o.bodyPos = o.pos
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += o.pos
+	case io.SeekEnd:
+		offset += o.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	o.pos = offset
+	return offset, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+	err := o.body.Close()
+	o.body = nil
+	return err
+}
+
+// Delete removes the recording object from S3. Deleting an object that is
+// already gone, such as one a lifecycle rule expired, succeeds.
 func (s *S3Store) Delete(storagePath string) error {
 	_, err := s.client.DeleteObject(context.Background(), &s3.DeleteObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(storagePath),
 	})
-	if err != nil {
+	if err != nil && !isNoSuchKey(err) {
 		return fmt.Errorf("failed to delete recording from S3: %w", err)
 	}
 	return nil
 }
+
+func isNoSuchKey(err error) bool {
+	var noSuchKey *types.NoSuchKey
+	var notFound *types.NotFound
+	return errors.As(err, &noSuchKey) || errors.As(err, &notFound)
+}
+
+// SignedURL returns a URL that downloads the file at storagePath from the
+// bucket directly, saved as filename, until expires has passed. It returns
+// ErrNoSignedURLs if the store has no presigner.
+func (s *S3Store) SignedURL(storagePath, filename string, expires time.Duration) (string, error) {
+	if s.presigner == nil {
+		return "", ErrNoSignedURLs
+	}
+	req, err := s.presigner.PresignGetObject(context.Background(), &s3.GetObjectInput{
+		Bucket:                     aws.String(s.bucket),
+		Key:                        aws.String(storagePath),
+		ResponseContentDisposition: aws.String(fmt.Sprintf("attachment; filename=%q", filename)),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign recording URL: %w", err)
+	}
+	return req.URL, nil
+}
+
+// List returns every object under the store's prefix, using the full object
+// key as the storage path.
+func (s *S3Store) List() ([]storagebrowser.Object, error) {
+	var objects []storagebrowser.Object
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(s.prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list recordings in S3: %w", err)
+		}
+		for _, obj := range page.Contents {
+			objects = append(objects, storagebrowser.Object{
+				Path:    aws.ToString(obj.Key),
+				Size:    aws.ToInt64(obj.Size),
+				ModTime: aws.ToTime(obj.LastModified),
+			})
+		}
+	}
+	return objects, nil
+}
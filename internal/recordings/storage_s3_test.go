@@ -3,25 +3,35 @@ package recordings
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"net/url"
+	"sort"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 // mockS3Client implements S3API for testing.
 type mockS3Client struct {
 	objects   map[string][]byte
+	kmsKeys   map[string]string // SSE-KMS key of each object put with one
 	putErr    error
 	getErr    error
 	deleteErr error
+	gets      int      // GetObject calls
+	ranges    []string // Range of each ranged GetObject
 }
 
 func newMockS3Client() *mockS3Client {
-	return &mockS3Client{objects: make(map[string][]byte)}
+	return &mockS3Client{objects: make(map[string][]byte), kmsKeys: make(map[string]string)}
 }
 
 func (m *mockS3Client) PutObject(_ context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
@@ -34,6 +44,9 @@ func (m *mockS3Client) PutObject(_ context.Context, input *s3.PutObjectInput, _
 	}
 	key := *input.Key
 	m.objects[key] = data
+	if input.SSEKMSKeyId != nil {
+		m.kmsKeys[key] = *input.SSEKMSKeyId
+	}
 	return &s3.PutObjectOutput{}, nil
 }
 
@@ -41,13 +54,21 @@ func (m *mockS3Client) GetObject(_ context.Context, input *s3.GetObjectInput, _
 	if m.getErr != nil {
 		return nil, m.getErr
 	}
+	m.gets++
 	key := *input.Key
 	data, ok := m.objects[key]
 	if !ok {
 		return nil, fmt.Errorf("NoSuchKey: %s", key)
 	}
+	if input.Range != nil {
+		var start int
+		fmt.Sscanf(*input.Range, "bytes=%d-", &start)
+		m.ranges = append(m.ranges, *input.Range)
+		data = data[min(start, len(data)):]
+	}
 	return &s3.GetObjectOutput{
-		Body: io.NopCloser(bytes.NewReader(data)),
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: aws.Int64(int64(len(data))),
 	}, nil
 }
 
@@ -59,6 +80,31 @@ func (m *mockS3Client) DeleteObject(_ context.Context, input *s3.DeleteObjectInp
 	return &s3.DeleteObjectOutput{}, nil
 }
 
+// ListObjectsV2 returns one object per page so pagination is exercised.
+func (m *mockS3Client) ListObjectsV2(_ context.Context, input *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	var keys []string
+	for key := range m.objects {
+		if strings.HasPrefix(key, aws.ToString(input.Prefix)) && key > aws.ToString(input.ContinuationToken) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	out := &s3.ListObjectsV2Output{}
+	if len(keys) == 0 {
+		return out, nil
+	}
+	out.Contents = []types.Object{{
+		Key:          aws.String(keys[0]),
+		Size:         aws.Int64(int64(len(m.objects[keys[0]]))),
+		LastModified: aws.Time(time.Now()),
+	}}
+	if len(keys) > 1 {
+		out.IsTruncated = aws.Bool(true)
+		out.NextContinuationToken = aws.String(keys[0])
+	}
+	return out, nil
+}
+
 func TestS3Store_SaveGetDelete(t *testing.T) {
 	mock := newMockS3Client()
 	store := NewS3StoreWithClient(mock, "test-bucket", "recordings/")
@@ -187,3 +233,316 @@ func TestS3Store_DeleteError(t *testing.T) {
 		t.Errorf("unexpected error: %v", err)
 	}
 }
+
+func TestS3Store_List(t *testing.T) {
+	mock := newMockS3Client()
+	mock.objects["recordings/2026/01/a.vncrec"] = []byte("aaa")
+	mock.objects["recordings/2026/01/a.mp4"] = []byte("video")
+	mock.objects["recordings/2026/02/b.vncrec"] = []byte("b")
+	mock.objects["other/unrelated"] = []byte("x")
+	store := NewS3StoreWithClient(mock, "test-bucket", "recordings/")
+
+	objects, err := store.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(objects) != 3 {
+		t.Fatalf("List() returned %d objects, want 3: %+v", len(objects), objects)
+	}
+	sizes := map[string]int64{}
+	for _, o := range objects {
+		sizes[o.Path] = o.Size
+	}
+	if sizes["recordings/2026/01/a.mp4"] != 5 || sizes["recordings/2026/02/b.vncrec"] != 1 {
+		t.Errorf("unexpected objects: %+v", objects)
+	}
+	if _, ok := sizes["other/unrelated"]; ok {
+		t.Error("List() returned an object outside the prefix")
+	}
+}
+
+func TestS3Store_SaveChunk(t *testing.T) {
+	mock := newMockS3Client()
+	store := NewS3StoreWithClient(mock, "test-bucket", "recordings/")
+
+	key, err := store.SaveChunk("rec-123", 12, strings.NewReader("chunk"))
+	if err != nil {
+		t.Fatalf("SaveChunk failed: %v", err)
+	}
+	if want := "recordings/chunks/rec-123/000012.part"; key != want {
+		t.Errorf("key = %q, want %q", key, want)
+	}
+	if string(mock.objects[key]) != "chunk" {
+		t.Errorf("stored %q, want %q", mock.objects[key], "chunk")
+	}
+
+	mock.putErr = fmt.Errorf("access denied")
+	if _, err := store.SaveChunk("rec-123", 13, strings.NewReader("chunk")); err == nil {
+		t.Error("expected error from SaveChunk")
+	}
+}
+
+func TestS3Store_PutUnseekable(t *testing.T) {
+	mock := newMockS3Client()
+	store := NewS3StoreWithClient(mock, "test-bucket", "recordings/")
+
+	// io.MultiReader cannot seek, so the body is spooled first.
+	r := io.MultiReader(strings.NewReader("part-1|"), strings.NewReader("part-2"))
+	if err := store.Put("recordings/2024/01/rec.mp4", r); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if got := string(mock.objects["recordings/2024/01/rec.mp4"]); got != "part-1|part-2" {
+		t.Errorf("stored object = %q", got)
+	}
+}
+
+// mockMultipartS3Client adds multipart uploads to mockS3Client.
+type mockMultipartS3Client struct {
+	*mockS3Client
+	parts       map[int32][]byte
+	created     int
+	aborted     int
+	createKMS   string
+	uploadErrAt int32 // part number that fails to upload, 0 for none
+}
+
+func newMockMultipartS3Client() *mockMultipartS3Client {
+	return &mockMultipartS3Client{mockS3Client: newMockS3Client()}
+}
+
+func (m *mockMultipartS3Client) CreateMultipartUpload(_ context.Context, input *s3.CreateMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	m.created++
+	m.parts = make(map[int32][]byte)
+	m.createKMS = aws.ToString(input.SSEKMSKeyId)
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-1")}, nil
+}
+
+func (m *mockMultipartS3Client) UploadPart(_ context.Context, input *s3.UploadPartInput, _ ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	num := aws.ToInt32(input.PartNumber)
+	if num == m.uploadErrAt {
+		return nil, fmt.Errorf("connection reset")
+	}
+	data, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.parts[num] = data
+	return &s3.UploadPartOutput{ETag: aws.String(fmt.Sprintf("etag-%d", num))}, nil
+}
+
+func (m *mockMultipartS3Client) CompleteMultipartUpload(_ context.Context, input *s3.CompleteMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	var data []byte
+	for i, part := range input.MultipartUpload.Parts {
+		num := aws.ToInt32(part.PartNumber)
+		if num != int32(i+1) || aws.ToString(part.ETag) != fmt.Sprintf("etag-%d", num) {
+			return nil, fmt.Errorf("bad part %d", num)
+		}
+		data = append(data, m.parts[num]...)
+	}
+	m.objects[aws.ToString(input.Key)] = data
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (m *mockMultipartS3Client) AbortMultipartUpload(context.Context, *s3.AbortMultipartUploadInput, ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	m.aborted++
+	m.parts = nil
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func TestS3Store_MultipartUpload(t *testing.T) {
+	mock := newMockMultipartS3Client()
+	store := NewS3StoreWithClient(mock, "test-bucket", "recordings/")
+	store.SetKMSKey("kms-key")
+
+	// Two full parts and a short last one, from a reader that cannot seek
+	content := bytes.Repeat([]byte("0123456789abcdef"), (2*s3PartSize+1000)/16)
+	path, err := store.Save("big", io.MultiReader(bytes.NewReader(content)))
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if mock.created != 1 || len(mock.parts) != 3 {
+		t.Fatalf("created %d uploads with %d parts, want 1 upload with 3 parts", mock.created, len(mock.parts))
+	}
+	if len(mock.parts[1]) != s3PartSize || len(mock.parts[2]) != s3PartSize {
+		t.Errorf("part sizes = %d, %d, want %d", len(mock.parts[1]), len(mock.parts[2]), s3PartSize)
+	}
+	if !bytes.Equal(mock.objects[path], content) {
+		t.Errorf("stored %d bytes, want %d", len(mock.objects[path]), len(content))
+	}
+	if mock.createKMS != "kms-key" {
+		t.Errorf("multipart upload KMS key = %q, want kms-key", mock.createKMS)
+	}
+}
+
+func TestS3Store_MultipartSmallFile(t *testing.T) {
+	mock := newMockMultipartS3Client()
+	store := NewS3StoreWithClient(mock, "test-bucket", "recordings/")
+
+	path, err := store.Save("small", io.MultiReader(strings.NewReader("small recording")))
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if mock.created != 0 {
+		t.Errorf("created %d multipart uploads for a small file, want 0", mock.created)
+	}
+	if string(mock.objects[path]) != "small recording" {
+		t.Errorf("stored %q", mock.objects[path])
+	}
+}
+
+func TestS3Store_MultipartAbort(t *testing.T) {
+	mock := newMockMultipartS3Client()
+	mock.uploadErrAt = 2
+	store := NewS3StoreWithClient(mock, "test-bucket", "recordings/")
+
+	content := make([]byte, 2*s3PartSize)
+	if _, err := store.Save("broken", bytes.NewReader(content)); err == nil {
+		t.Fatal("expected Save to fail")
+	}
+	if mock.aborted != 1 {
+		t.Errorf("aborted %d uploads, want 1", mock.aborted)
+	}
+	if len(mock.objects) != 0 {
+		t.Errorf("objects = %d, want none", len(mock.objects))
+	}
+}
+
+func TestS3Store_MissingObject(t *testing.T) {
+	mock := newMockS3Client()
+	store := NewS3StoreWithClient(mock, "test-bucket", "recordings/")
+
+	// A bucket lifecycle rule may expire objects before Sortie deletes them
+	mock.getErr = &types.NoSuchKey{}
+	if _, err := store.Get("recordings/expired.vncrec"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Get of a missing object: err = %v, want fs.ErrNotExist", err)
+	}
+
+	mock.deleteErr = &types.NoSuchKey{}
+	if err := store.Delete("recordings/expired.vncrec"); err != nil {
+		t.Errorf("Delete of a missing object: %v", err)
+	}
+
+	mock.getErr = fmt.Errorf("access denied")
+	if _, err := store.Get("recordings/denied.vncrec"); err == nil || errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Get with another error: err = %v, want a non-ErrNotExist error", err)
+	}
+}
+
+func TestS3Store_Open(t *testing.T) {
+	mock := newMockS3Client()
+	store := NewS3StoreWithClient(mock, "test-bucket", "recordings/")
+	mock.objects["recordings/video.mp4"] = []byte("0123456789abcdef")
+
+	f, err := store.Open("recordings/video.mp4")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+
+	if size, err := f.Seek(0, io.SeekEnd); err != nil || size != 16 {
+		t.Fatalf("Seek(0, SeekEnd) = %d, %v; want 16", size, err)
+	}
+	// Reading from the start reuses the body fetched by Open
+	f.Seek(0, io.SeekStart)
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(f, buf); err != nil || string(buf) != "0123" {
+		t.Fatalf("read from start = %q, %v", buf, err)
+	}
+	if len(mock.ranges) != 0 {
+		t.Errorf("ranged requests = %v, want none", mock.ranges)
+	}
+
+	f.Seek(10, io.SeekStart)
+	rest, err := io.ReadAll(f)
+	if err != nil || string(rest) != "abcdef" {
+		t.Fatalf("read from 10 = %q, %v", rest, err)
+	}
+	if len(mock.ranges) != 1 || mock.ranges[0] != "bytes=10-" {
+		t.Errorf("ranged requests = %v, want [bytes=10-]", mock.ranges)
+	}
+
+	mock.getErr = &types.NoSuchKey{}
+	if _, err := store.Open("recordings/expired.mp4"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open of a missing object: err = %v, want fs.ErrNotExist", err)
+	}
+}
+
+// mockHeadS3Client adds HeadObject to mockS3Client.
+type mockHeadS3Client struct {
+	*mockS3Client
+}
+
+func (m mockHeadS3Client) HeadObject(_ context.Context, input *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	data, ok := m.objects[*input.Key]
+	if !ok {
+		return nil, &types.NotFound{}
+	}
+	return &s3.HeadObjectOutput{ContentLength: aws.Int64(int64(len(data)))}, nil
+}
+
+func TestS3Store_OpenWithHead(t *testing.T) {
+	mock := newMockS3Client()
+	store := NewS3StoreWithClient(mockHeadS3Client{mock}, "test-bucket", "recordings/")
+	mock.objects["recordings/video.mp4"] = []byte("0123456789abcdef")
+
+	f, err := store.Open("recordings/video.mp4")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+	if size, err := f.Seek(0, io.SeekEnd); err != nil || size != 16 {
+		t.Fatalf("Seek(0, SeekEnd) = %d, %v; want 16", size, err)
+	}
+	if mock.gets != 0 {
+		t.Errorf("Open made %d GetObject calls, want none", mock.gets)
+	}
+
+	// A range request downloads only the range
+	f.Seek(10, io.SeekStart)
+	rest, err := io.ReadAll(f)
+	if err != nil || string(rest) != "abcdef" {
+		t.Fatalf("read from 10 = %q, %v", rest, err)
+	}
+	if mock.gets != 1 || len(mock.ranges) != 1 || mock.ranges[0] != "bytes=10-" {
+		t.Errorf("GetObject calls = %d with ranges %v, want one for bytes=10-", mock.gets, mock.ranges)
+	}
+
+	if _, err := store.Open("recordings/expired.mp4"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open of a missing object: err = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestS3Store_SignedURL(t *testing.T) {
+	store := NewS3StoreWithClient(newMockS3Client(), "test-bucket", "recordings/")
+	if _, err := store.SignedURL("recordings/a.vncrec", "a.vncrec", time.Minute); !errors.Is(err, ErrNoSignedURLs) {
+		t.Fatalf("SignedURL without a presigner: err = %v, want ErrNoSignedURLs", err)
+	}
+
+	client := s3.New(s3.Options{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+	})
+	store.SetPresigner(s3.NewPresignClient(client))
+
+	signed, err := store.SignedURL("recordings/2024/01/a.vncrec", "demo.vncrec", 15*time.Minute)
+	if err != nil {
+		t.Fatalf("SignedURL failed: %v", err)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("invalid URL %q: %v", signed, err)
+	}
+	if !strings.Contains(u.Host+u.Path, "test-bucket") || !strings.HasSuffix(u.Path, "/recordings/2024/01/a.vncrec") {
+		t.Errorf("URL = %s, want the bucket and key", signed)
+	}
+	q := u.Query()
+	if q.Get("X-Amz-Expires") != "900" {
+		t.Errorf("X-Amz-Expires = %q, want 900", q.Get("X-Amz-Expires"))
+	}
+	if q.Get("X-Amz-Signature") == "" {
+		t.Error("URL is not signed")
+	}
+	if cd := q.Get("response-content-disposition"); !strings.Contains(cd, `filename="demo.vncrec"`) {
+		t.Errorf("response-content-disposition = %q", cd)
+	}
+}
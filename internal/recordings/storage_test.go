@@ -185,3 +185,76 @@ func TestNewLocalStore(t *testing.T) {
 		t.Errorf("baseDir = %s, want /tmp/test-recordings", store.baseDir)
 	}
 }
+
+func TestLocalStore_List(t *testing.T) {
+	baseDir := t.TempDir()
+	store := NewLocalStore(baseDir)
+
+	path, err := store.Save("list-rec", bytes.NewReader([]byte("data")))
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	videoPath := strings.TrimSuffix(path, ".vncrec") + ".mp4"
+	if err := os.WriteFile(filepath.Join(baseDir, videoPath), []byte("video"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	objects, err := store.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	sizes := map[string]int64{}
+	for _, o := range objects {
+		sizes[o.Path] = o.Size
+	}
+	if len(sizes) != 2 || sizes[path] != 4 || sizes[videoPath] != 5 {
+		t.Errorf("List() = %+v, want %s (4 bytes) and %s (5 bytes)", objects, path, videoPath)
+	}
+
+	t.Run("missing base directory", func(t *testing.T) {
+		objects, err := NewLocalStore(filepath.Join(baseDir, "missing")).List()
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		if len(objects) != 0 {
+			t.Errorf("List() = %+v, want empty", objects)
+		}
+	})
+}
+
+func TestLocalStore_SaveChunk(t *testing.T) {
+	baseDir := t.TempDir()
+	store := NewLocalStore(baseDir)
+
+	path, err := store.SaveChunk("rec-1", 7, strings.NewReader("first"))
+	if err != nil {
+		t.Fatalf("SaveChunk() error = %v", err)
+	}
+	if want := filepath.Join("chunks", "rec-1", "000007.part"); path != want {
+		t.Errorf("path = %s, want %s", path, want)
+	}
+
+	// Saving the same chunk again replaces it.
+	if _, err := store.SaveChunk("rec-1", 7, strings.NewReader("second")); err != nil {
+		t.Fatalf("SaveChunk() again error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(baseDir, path))
+	if err != nil || string(data) != "second" {
+		t.Errorf("chunk = %q, %v; want %q", data, err, "second")
+	}
+
+	if _, err := store.SaveChunk("../../etc", 0, strings.NewReader("x")); err != nil {
+		t.Errorf("SaveChunk() with traversal id should be confined, got error %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "chunks", "etc", "000000.part")); err != nil {
+		t.Errorf("traversal id not confined to the chunks directory: %v", err)
+	}
+
+	// Deleting the last chunk removes the recording's chunk directory.
+	if err := store.Delete(path); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "chunks", "rec-1")); !os.IsNotExist(err) {
+		t.Errorf("chunk directory still exists: %v", err)
+	}
+}
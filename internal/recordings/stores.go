@@ -0,0 +1,126 @@
+package recordings
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// TenantBackend is the storage backend recorded for recordings kept in
+// their tenant's own storage location.
+const TenantBackend = "tenant"
+
+// TenantStoreFunc builds the store for a tenant's storage location.
+type TenantStoreFunc func(loc db.StorageLocation) (RecordingStore, error)
+
+// Stores picks the store for each recording: the default store, or, for
+// tenants whose settings name a storage location, a store in that
+// location. Each recording keeps the store it was started in, so
+// recordings made before a tenant's location was set stay readable.
+type Stores struct {
+	db             *db.DB
+	defaultStore   RecordingStore
+	defaultBackend string
+
+	mu          sync.Mutex
+	tenantStore TenantStoreFunc
+	cache       map[db.StorageLocation]RecordingStore
+}
+
+// NewStores creates Stores that use defaultStore, of the named backend,
+// until SetTenantStoreFunc is called.
+func NewStores(database *db.DB, defaultStore RecordingStore, defaultBackend string) *Stores {
+	return &Stores{
+		db:             database,
+		defaultStore:   defaultStore,
+		defaultBackend: defaultBackend,
+		cache:          make(map[db.StorageLocation]RecordingStore),
+	}
+}
+
+// SetTenantStoreFunc makes new recordings of tenants with a storage
+// location go to a store built by fn. Without it, tenant locations are
+// ignored.
+func (s *Stores) SetTenantStoreFunc(fn TenantStoreFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tenantStore = fn
+	s.cache = make(map[db.StorageLocation]RecordingStore)
+}
+
+// Default returns the default store.
+func (s *Stores) Default() RecordingStore {
+	return s.defaultStore
+}
+
+// ForTenant returns the store for a new recording of the tenant, and the
+// backend to record on it.
+func (s *Stores) ForTenant(tenantID string) (RecordingStore, string, error) {
+	s.mu.Lock()
+	enabled := s.tenantStore != nil
+	s.mu.Unlock()
+	if !enabled {
+		return s.defaultStore, s.defaultBackend, nil
+	}
+
+	loc, err := s.tenantLocation(tenantID)
+	if err != nil {
+		return nil, "", err
+	}
+	if loc == nil {
+		return s.defaultStore, s.defaultBackend, nil
+	}
+	store, err := s.locationStore(*loc)
+	if err != nil {
+		return nil, "", err
+	}
+	return store, TenantBackend, nil
+}
+
+// ForRecording returns the store holding the recording's files.
+func (s *Stores) ForRecording(rec *db.Recording) (RecordingStore, error) {
+	if rec.StorageBackend != TenantBackend {
+		return s.defaultStore, nil
+	}
+	loc, err := s.tenantLocation(rec.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	if loc == nil {
+		return nil, fmt.Errorf("tenant %s no longer has a storage location", rec.TenantID)
+	}
+	return s.locationStore(*loc)
+}
+
+func (s *Stores) tenantLocation(tenantID string) (*db.StorageLocation, error) {
+	if tenantID == "" {
+		return nil, nil
+	}
+	tenant, err := s.db.GetTenant(tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+	if tenant == nil {
+		return nil, nil
+	}
+	return tenant.Settings.Storage, nil
+}
+
+// locationStore returns the store for loc, building it on first use.
+func (s *Stores) locationStore(loc db.StorageLocation) (RecordingStore, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if store, ok := s.cache[loc]; ok {
+		return store, nil
+	}
+	if s.tenantStore == nil {
+		return nil, fmt.Errorf("tenant storage locations are not enabled")
+	}
+	store, err := s.tenantStore(loc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage in bucket %s: %w", loc.Bucket, err)
+	}
+	s.cache[loc] = store
+	return store, nil
+}
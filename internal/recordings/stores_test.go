@@ -0,0 +1,93 @@
+package recordings
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+func TestHandler_TenantStorage(t *testing.T) {
+	handler, database, local := setupTestHandler(t)
+	tenantBucket := newMockS3Client()
+	handler.Stores().SetTenantStoreFunc(func(loc db.StorageLocation) (RecordingStore, error) {
+		store := NewS3StoreWithClient(tenantBucket, loc.Bucket, loc.Prefix)
+		store.SetKMSKey(loc.KMSKeyID)
+		return store, nil
+	})
+
+	// A recording made before the tenant had its own storage
+	legacy, err := local.Save("legacy-rec", strings.NewReader("old"))
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := database.CreateRecording(db.Recording{
+		ID: "legacy-rec", SessionID: "test-sess", UserID: "user-1", Filename: "legacy.vncrec",
+		StorageBackend: "local", StoragePath: legacy, Status: db.RecordingStatusReady,
+	}); err != nil {
+		t.Fatalf("CreateRecording() error = %v", err)
+	}
+
+	tenant, _ := database.GetTenant(db.DefaultTenantID)
+	tenant.Settings.Storage = &db.StorageLocation{Bucket: "eu-recordings", Region: "eu-central-1", Prefix: "sortie/", KMSKeyID: "alias/eu"}
+	if err := database.UpdateTenant(*tenant); err != nil {
+		t.Fatalf("UpdateTenant() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sessions/test-sess/recording/start", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, reqWithUser(req, ownerUser()))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("start: status = %d (body: %s)", rr.Code, rr.Body.String())
+	}
+	var started map[string]string
+	json.NewDecoder(rr.Body).Decode(&started)
+	id := started["recording_id"]
+
+	for seq, data := range []string{"part-0|", "part-1"} {
+		if rr := postChunk(t, handler, id, strconv.Itoa(seq), data); rr.Code != http.StatusNoContent {
+			t.Fatalf("chunk %d: status = %d (body: %s)", seq, rr.Code, rr.Body.String())
+		}
+	}
+	if rr := postComplete(t, handler, `{"recording_id":"`+id+`"}`); rr.Code != http.StatusOK {
+		t.Fatalf("complete: status = %d (body: %s)", rr.Code, rr.Body.String())
+	}
+
+	rec, _ := database.GetRecording(id)
+	if rec.StorageBackend != TenantBackend || rec.Status != db.RecordingStatusReady {
+		t.Errorf("recording = %+v, want ready in tenant storage", rec)
+	}
+	if got := string(tenantBucket.objects[rec.StoragePath]); got != "part-0|part-1" {
+		t.Errorf("tenant bucket object = %q", got)
+	}
+	if tenantBucket.kmsKeys[rec.StoragePath] != "alias/eu" {
+		t.Errorf("object KMS key = %q, want alias/eu", tenantBucket.kmsKeys[rec.StoragePath])
+	}
+	if len(tenantBucket.objects) != 1 {
+		t.Errorf("tenant bucket holds %d objects, want chunks removed", len(tenantBucket.objects))
+	}
+
+	// The earlier recording is still read from the default store
+	req = httptest.NewRequest(http.MethodGet, "/api/recordings/legacy-rec/download", nil)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, reqWithUser(req, ownerUser()))
+	if rr.Code != http.StatusOK || rr.Body.String() != "old" {
+		t.Errorf("legacy download: status = %d, body = %q", rr.Code, rr.Body.String())
+	}
+
+	// Retention deletes from the tenant bucket
+	cleaner := NewCleaner(database, local, 1)
+	cleaner.SetStores(handler.Stores())
+	if _, err := database.ExecRaw("UPDATE recordings SET completed_at = ? WHERE id = ?", time.Now().AddDate(0, 0, -2), id); err != nil {
+		t.Fatalf("backdate recording: %v", err)
+	}
+	cleaner.run()
+	if len(tenantBucket.objects) != 0 {
+		t.Errorf("tenant bucket holds %d objects after cleanup", len(tenantBucket.objects))
+	}
+}
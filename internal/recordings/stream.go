@@ -0,0 +1,167 @@
+package recordings
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/middleware"
+)
+
+// StreamURLTTL is how long a signed stream URL stays valid. Players fetch
+// a new one when it expires mid-playback.
+const StreamURLTTL = 15 * time.Minute
+
+// playableVideo returns the storage path and content type of the video a
+// browser can play for the recording, or false if it has none: .vncrec
+// recordings are only playable once converted to MP4.
+func playableVideo(rec *db.Recording) (string, string, bool) {
+	switch {
+	case rec.VideoPath != "":
+		return rec.VideoPath, "video/mp4", true
+	case rec.Format == "webm" && rec.StoragePath != "":
+		return rec.StoragePath, "video/webm", true
+	}
+	return "", "", false
+}
+
+// handleStream serves the recording's video for playback. Stores that can
+// seek serve Range requests, so players can skip ahead without downloading
+// the whole file. Requests either carry the user's credentials or a
+// signed URL from /stream-link.
+func (h *Handler) handleStream(w http.ResponseWriter, r *http.Request, recordingID string) {
+	if middleware.GetUserFromContext(r.Context()) == nil && !h.SignedStream(r) {
+		http.Error(w, "Invalid or expired stream URL", http.StatusUnauthorized)
+		return
+	}
+	rec := h.readyRecording(w, r, recordingID)
+	if rec == nil {
+		return
+	}
+	path, contentType, ok := playableVideo(rec)
+	if !ok {
+		http.Error(w, "Recording has no playable video", http.StatusNotFound)
+		return
+	}
+
+	store, err := h.storeFor(rec)
+	if err != nil {
+		slog.Error("failed to resolve recording storage", "recording_id", recordingID, "error", err)
+		http.Error(w, "Failed to read recording", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, no-store")
+
+	if seekable, ok := store.(SeekableStore); ok {
+		f, err := seekable.Open(path)
+		if err != nil {
+			h.streamError(w, recordingID, path, err)
+			return
+		}
+		defer f.Close()
+		var modified time.Time
+		if rec.CompletedAt != nil {
+			modified = *rec.CompletedAt
+		}
+		http.ServeContent(w, r, "", modified, f)
+		return
+	}
+
+	// Stores that cannot seek, such as encrypted ones, send the whole file
+	reader, err := store.Get(path)
+	if err != nil {
+		h.streamError(w, recordingID, path, err)
+		return
+	}
+	defer reader.Close()
+	w.Header().Set("Accept-Ranges", "none")
+	io.Copy(w, reader)
+}
+
+func (h *Handler) streamError(w http.ResponseWriter, recordingID, path string, err error) {
+	if errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("recording file is missing", "recording_id", recordingID, "path", path)
+		http.Error(w, "Recording file not found", http.StatusNotFound)
+		return
+	}
+	slog.Error("failed to open recording file", "recording_id", recordingID, "error", err)
+	http.Error(w, "Failed to read recording", http.StatusInternalServerError)
+}
+
+// handleStreamLink returns a signed URL for /stream that is valid for
+// StreamURLTTL without credentials, for video elements, which cannot send
+// an Authorization header. It returns 404 when authentication is disabled,
+// as there is no secret to sign with.
+func (h *Handler) handleStreamLink(w http.ResponseWriter, r *http.Request, recordingID string) {
+	rec := h.readyRecording(w, r, recordingID)
+	if rec == nil {
+		return
+	}
+	if _, _, ok := playableVideo(rec); !ok {
+		http.Error(w, "Recording has no playable video", http.StatusNotFound)
+		return
+	}
+	if h.config.JWTSecret == "" {
+		http.Error(w, "Signed stream URLs are not available", http.StatusNotFound)
+		return
+	}
+
+	expires := time.Now().Add(StreamURLTTL).Truncate(time.Second)
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	q.Set("signature", h.streamSignature(rec.ID, expires.Unix()))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(linkResponse{
+		URL:       fmt.Sprintf("/api/recordings/%s/stream?%s", url.PathEscape(rec.ID), q.Encode()),
+		ExpiresAt: expires.UTC(),
+	})
+}
+
+// SignedStream reports whether r is a request for a recording's stream
+// carrying a valid, unexpired signature. Such requests are authorized by
+// the signature alone, so the server routes them past authentication.
+func (h *Handler) SignedStream(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	id, ok := strings.CutPrefix(r.URL.Path, "/api/recordings/")
+	if !ok {
+		return false
+	}
+	id, ok = strings.CutSuffix(id, "/stream")
+	if !ok || id == "" || strings.Contains(id, "/") || h.config.JWTSecret == "" {
+		return false
+	}
+	q := r.URL.Query()
+	expires, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	want := h.streamSignature(id, expires)
+	return hmac.Equal([]byte(q.Get("signature")), []byte(want))
+}
+
+// streamSignature signs a recording ID and expiry with a key derived from
+// the JWT secret, so stream signatures cannot be confused with tokens.
+func (h *Handler) streamSignature(recordingID string, expires int64) string {
+	key := sha256.Sum256([]byte("sortie-recording-stream\x00" + h.config.JWTSecret))
+	mac := hmac.New(sha256.New, key[:])
+	fmt.Fprintf(mac, "%s\x00%d", recordingID, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
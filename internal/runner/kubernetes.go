@@ -2,6 +2,7 @@ package runner
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"time"
@@ -9,6 +10,7 @@ import (
 	"github.com/rjsadow/sortie/internal/db"
 	"github.com/rjsadow/sortie/internal/k8s"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
 // KubernetesRunner implements Runner for Kubernetes pod-based workloads.
@@ -28,6 +30,92 @@ func (r *KubernetesRunner) Type() Type {
 
 // CreateWorkload creates a Kubernetes pod for the given workload configuration.
 func (r *KubernetesRunner) CreateWorkload(ctx context.Context, config *WorkloadConfig) (*WorkloadResult, error) {
+	pod, err := workloadPod(config)
+	if err != nil {
+		return nil, err
+	}
+
+	createdPod, err := k8s.CreatePod(ctx, pod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pod: %w", err)
+	}
+
+	return &WorkloadResult{Name: createdPod.Name, SidecarImage: k8s.DisplaySidecarImage(pod)}, nil
+}
+
+// CreateWarmWorkload creates an unclaimed pod in the named warm pool.
+func (r *KubernetesRunner) CreateWarmWorkload(ctx context.Context, pool string, config *WorkloadConfig) (*WorkloadResult, error) {
+	pod, err := workloadPod(config)
+	if err != nil {
+		return nil, err
+	}
+	k8s.MakeWarmPod(pod, pool)
+
+	createdPod, err := k8s.CreatePod(ctx, pod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pod: %w", err)
+	}
+
+	return &WorkloadResult{Name: createdPod.Name, SidecarImage: k8s.DisplaySidecarImage(pod)}, nil
+}
+
+// ClaimWarmWorkload relabels a ready pod from the warm pool as the
+// session's pod.
+func (r *KubernetesRunner) ClaimWarmWorkload(ctx context.Context, pool, sessionID string) (*WorkloadResult, error) {
+	pod, err := k8s.ClaimWarmPod(ctx, pool, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim warm pod: %w", err)
+	}
+	if pod == nil {
+		return nil, nil
+	}
+	return &WorkloadResult{Name: pod.Name, SidecarImage: k8s.DisplaySidecarImage(pod)}, nil
+}
+
+// ListWarmWorkloads returns the unclaimed pods of every warm pool.
+func (r *KubernetesRunner) ListWarmWorkloads(ctx context.Context) ([]WarmWorkload, error) {
+	podList, err := k8s.ListWarmPods(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list warm pods: %w", err)
+	}
+
+	var workloads []WarmWorkload
+	for _, pod := range podList.Items {
+		workloads = append(workloads, WarmWorkload{
+			Name:   pod.Name,
+			AppID:  pod.Labels[k8s.AppLabelKey],
+			Pool:   pod.Labels[k8s.WarmPoolLabelKey],
+			Ready:  k8s.IsPodReady(&pod),
+			Failed: pod.Status.Phase == corev1.PodFailed || pod.Status.Phase == corev1.PodSucceeded,
+		})
+	}
+	return workloads, nil
+}
+
+// workloadPod validates a workload configuration and builds its pod.
+func workloadPod(config *WorkloadConfig) (*corev1.Pod, error) {
+	if err := k8s.ValidateSidecars(config.Sidecars); err != nil {
+		return nil, fmt.Errorf("invalid sidecars: %w", err)
+	}
+	if err := k8s.ValidateAppContainers(config.Containers, config.SharedVolumes); err != nil {
+		return nil, fmt.Errorf("invalid containers: %w", err)
+	}
+	if err := k8s.ValidateInitContainers(config.InitContainers); err != nil {
+		return nil, fmt.Errorf("invalid init containers: %w", err)
+	}
+	if err := k8s.ValidateDNSConfig(config.DNS); err != nil {
+		return nil, fmt.Errorf("invalid dns config: %w", err)
+	}
+	if err := k8s.ValidateScheduling(config.Scheduling); err != nil {
+		return nil, fmt.Errorf("invalid scheduling: %w", err)
+	}
+	if err := k8s.ValidateImagePullSecret(config.ImagePullSecret); err != nil {
+		return nil, err
+	}
+	if err := k8s.ValidateSidecarImages(config.SidecarImages); err != nil {
+		return nil, fmt.Errorf("invalid sidecar images: %w", err)
+	}
+
 	podConfig := k8s.DefaultPodConfig(config.SessionID, config.AppID, config.AppName, config.ContainerImage)
 	podConfig.ContainerPort = config.ContainerPort
 	podConfig.Command = config.Command
@@ -48,16 +136,211 @@ func (r *KubernetesRunner) CreateWorkload(ctx context.Context, config *WorkloadC
 	podConfig.ScreenResolution = config.ScreenResolution
 	podConfig.ScreenWidth = config.ScreenWidth
 	podConfig.ScreenHeight = config.ScreenHeight
+	podConfig.Sidecars = config.Sidecars
+	podConfig.Containers = config.Containers
+	podConfig.SharedVolumes = config.SharedVolumes
+	podConfig.InitContainers = config.InitContainers
+	podConfig.DNS = config.DNS
+	podConfig.Scheduling = config.Scheduling
+	podConfig.ImagePullSecret = config.ImagePullSecret
+	podConfig.SidecarImages = config.SidecarImages
+	if config.HomeVolume != nil {
+		podConfig.HomeVolumeClaim = config.HomeVolume.Name
+		podConfig.HomeMountPath = config.HomeVolume.MountPath
+		podConfig.HomeFSGroup = config.HomeVolume.FSGroup
+	}
+	podConfig.WorkspaceClaim = config.WorkspaceVolume
+	if config.ProjectDrive != nil {
+		podConfig.ProjectDriveClaim = config.ProjectDrive.Name
+		podConfig.ProjectDriveMountPath = config.ProjectDrive.MountPath
+	}
 
 	// Build the pod spec based on launch type and OS
-	pod := buildPod(podConfig, config.LaunchType, config.OsType)
+	return buildPod(podConfig, config.LaunchType, config.OsType), nil
+}
 
-	createdPod, err := k8s.CreatePod(ctx, pod)
+// EnsureUserVolume creates the user's home volume claim for the app, or
+// returns the existing one.
+func (r *KubernetesRunner) EnsureUserVolume(ctx context.Context, userID, appID string, vol *db.HomeVolume) (string, error) {
+	if err := k8s.ValidateHomeVolume(vol); err != nil {
+		return "", fmt.Errorf("invalid home volume: %w", err)
+	}
+	pvc, err := k8s.EnsurePVC(ctx, k8s.BuildHomeVolumeClaim(userID, appID, vol))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create pod: %w", err)
+		return "", fmt.Errorf("failed to create home volume claim: %w", err)
+	}
+	return pvc.Name, nil
+}
+
+// ListUserVolumes returns the home volume claims of every user.
+func (r *KubernetesRunner) ListUserVolumes(ctx context.Context) ([]UserVolume, error) {
+	list, err := k8s.ListHomeVolumeClaims(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list home volume claims: %w", err)
+	}
+
+	var volumes []UserVolume
+	for _, pvc := range list.Items {
+		userID, appID := k8s.HomeVolumeOwner(&pvc)
+		vol := UserVolume{
+			Name:      pvc.Name,
+			UserID:    userID,
+			AppID:     appID,
+			Status:    string(pvc.Status.Phase),
+			CreatedAt: pvc.CreationTimestamp.Time,
+		}
+		if q, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+			vol.Size = q.String()
+		}
+		if q, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok {
+			vol.Size = q.String()
+		}
+		if pvc.Spec.StorageClassName != nil {
+			vol.StorageClass = *pvc.Spec.StorageClassName
+		}
+		volumes = append(volumes, vol)
+	}
+	return volumes, nil
+}
+
+// DeleteUserVolume deletes a home volume claim by name.
+func (r *KubernetesRunner) DeleteUserVolume(ctx context.Context, name string) error {
+	return k8s.DeletePVC(ctx, name)
+}
+
+// EnsureProjectDrive creates the project's drive claim, or returns the
+// existing one.
+func (r *KubernetesRunner) EnsureProjectDrive(ctx context.Context, projectID string, drive *db.ProjectDrive) (string, error) {
+	if err := k8s.ValidateProjectDrive(drive); err != nil {
+		return "", fmt.Errorf("invalid project drive: %w", err)
+	}
+	pvc, err := k8s.EnsurePVC(ctx, k8s.BuildProjectDriveClaim(projectID, drive))
+	if err != nil {
+		return "", fmt.Errorf("failed to create project drive claim: %w", err)
+	}
+	return pvc.Name, nil
+}
+
+// DeleteProjectDrive deletes a project's drive claim.
+func (r *KubernetesRunner) DeleteProjectDrive(ctx context.Context, projectID string) error {
+	err := k8s.DeletePVC(ctx, k8s.ProjectDriveClaimName(projectID))
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// ApplyRegistrySecret creates or updates the credential's dockerconfigjson
+// Secret.
+func (r *KubernetesRunner) ApplyRegistrySecret(ctx context.Context, cred *db.RegistryCredential) error {
+	if err := k8s.ValidateImagePullSecret(cred.Name); err != nil {
+		return err
+	}
+	secret, err := k8s.BuildRegistrySecret(cred.Name, cred.Server, cred.Username, cred.Password, cred.Email)
+	if err != nil {
+		return err
+	}
+	return k8s.ApplyRegistrySecret(ctx, secret)
+}
+
+// DeleteRegistrySecret deletes a credential's dockerconfigjson Secret.
+func (r *KubernetesRunner) DeleteRegistrySecret(ctx context.Context, name string) error {
+	err := k8s.DeleteRegistrySecret(ctx, name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// StartPrePull starts a DaemonSet that pulls the images of config's pod
+// onto the nodes it may be scheduled on.
+func (r *KubernetesRunner) StartPrePull(ctx context.Context, config *WorkloadConfig) error {
+	pod, err := workloadPod(config)
+	if err != nil {
+		return err
+	}
+	if err := k8s.ApplyPrePull(ctx, k8s.BuildPrePullDaemonSet(config.AppID, pod)); err != nil {
+		return fmt.Errorf("failed to create pre-pull daemonset: %w", err)
 	}
+	return nil
+}
 
-	return &WorkloadResult{Name: createdPod.Name}, nil
+// ListPrePulls reports the progress of every pre-pull DaemonSet.
+func (r *KubernetesRunner) ListPrePulls(ctx context.Context) ([]PrePull, error) {
+	states, err := k8s.ListPrePulls(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pre-pull daemonsets: %w", err)
+	}
+
+	pulls := make([]PrePull, 0, len(states))
+	for _, state := range states {
+		ds := &state.DaemonSet
+		var images []string
+		for _, c := range ds.Spec.Template.Spec.Containers {
+			images = append(images, c.Image)
+		}
+		pull := PrePull{
+			AppID:        ds.Labels[k8s.AppLabelKey],
+			Images:       images,
+			StartedAt:    k8s.PrePullStartedAt(ds),
+			DesiredNodes: int(ds.Status.DesiredNumberScheduled),
+			Nodes:        []PrePullNode{},
+		}
+		for i := range state.Pods {
+			pod := &state.Pods[i]
+			if pod.Spec.NodeName == "" {
+				continue
+			}
+			pulled, pullErr := k8s.PrePullPodProgress(pod)
+			pull.Nodes = append(pull.Nodes, PrePullNode{Node: pod.Spec.NodeName, Pulled: pulled, Error: pullErr})
+		}
+		pulls = append(pulls, pull)
+	}
+	return pulls, nil
+}
+
+// DeletePrePull deletes an app's pre-pull DaemonSet.
+func (r *KubernetesRunner) DeletePrePull(ctx context.Context, appID string) error {
+	err := k8s.DeletePrePull(ctx, k8s.PrePullDaemonSetName(appID))
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// EnsureSessionVolume creates the session's workspace claim, or returns
+// the existing one.
+func (r *KubernetesRunner) EnsureSessionVolume(ctx context.Context, sessionID, appID string) (string, error) {
+	pvc, err := k8s.EnsurePVC(ctx, k8s.BuildWorkspaceClaim(sessionID, appID))
+	if err != nil {
+		return "", fmt.Errorf("failed to create workspace claim: %w", err)
+	}
+	return pvc.Name, nil
+}
+
+// ListSessionVolumes returns the workspace claims of every session.
+func (r *KubernetesRunner) ListSessionVolumes(ctx context.Context) ([]SessionVolume, error) {
+	list, err := k8s.ListWorkspaceClaims(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspace claims: %w", err)
+	}
+
+	var volumes []SessionVolume
+	for _, pvc := range list.Items {
+		if id := pvc.Labels[k8s.SessionLabelKey]; id != "" {
+			volumes = append(volumes, SessionVolume{SessionID: id, CreatedAt: pvc.CreationTimestamp.Time})
+		}
+	}
+	return volumes, nil
+}
+
+// DeleteSessionVolume deletes a session's workspace claim.
+func (r *KubernetesRunner) DeleteSessionVolume(ctx context.Context, sessionID string) error {
+	err := k8s.DeletePVC(ctx, k8s.WorkspaceClaimName(sessionID))
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
 }
 
 // DeleteWorkload deletes a Kubernetes pod by name.
@@ -70,11 +353,59 @@ func (r *KubernetesRunner) WaitForReady(ctx context.Context, name string, timeou
 	return k8s.WaitForPodReady(ctx, name, timeout)
 }
 
+// WorkloadProgress reports what a starting pod is waiting on, from its
+// conditions, container statuses and events.
+func (r *KubernetesRunner) WorkloadProgress(ctx context.Context, name string) (db.SessionSubstatus, string, error) {
+	return k8s.GetPodProgress(ctx, name)
+}
+
+// WatchRestarts reports session pod restarts seen by the pod cache's
+// watch, so it needs k8s.StartPodCache to have been called.
+func (r *KubernetesRunner) WatchRestarts(ctx context.Context, onRestart func(WorkloadRestart)) error {
+	remove := k8s.OnPodRestart(func(p k8s.PodRestart) {
+		onRestart(WorkloadRestart{Name: p.PodName, Restarts: p.Restarts, Detail: p.Detail})
+	})
+	defer remove()
+	<-ctx.Done()
+	return nil
+}
+
+// WatchDisruptions reports session pod evictions and deletions seen by the
+// pod cache's watch, so it needs k8s.StartPodCache to have been called.
+func (r *KubernetesRunner) WatchDisruptions(ctx context.Context, onDisruption func(WorkloadDisruption)) error {
+	remove := k8s.OnPodDisruption(func(p k8s.PodDisruption) {
+		onDisruption(WorkloadDisruption{Name: p.PodName, Reason: p.Reason, Deleted: p.Deleted})
+	})
+	defer remove()
+	<-ctx.Done()
+	return nil
+}
+
+// WaitForDeleted waits until a pod is gone, force deleting it if its node
+// never confirms the deletion.
+func (r *KubernetesRunner) WaitForDeleted(ctx context.Context, name string) error {
+	return k8s.WaitForPodDeleted(ctx, name)
+}
+
 // GetIP returns the pod IP address.
 func (r *KubernetesRunner) GetIP(ctx context.Context, name string) (string, error) {
 	return k8s.GetPodIP(ctx, name)
 }
 
+// CheckCapacity checks the workload's pod against the allocatable
+// resources of the cluster's nodes and the namespace's ResourceQuotas.
+func (r *KubernetesRunner) CheckCapacity(ctx context.Context, config *WorkloadConfig) error {
+	pod, err := workloadPod(config)
+	if err != nil {
+		return err
+	}
+	shortfall, err := k8s.CheckPodCapacity(ctx, pod)
+	if shortfall != nil {
+		return &CapacityError{Reason: shortfall.Reason, Temporary: shortfall.Temporary}
+	}
+	return err
+}
+
 // ListWorkloads returns all sortie session pods.
 func (r *KubernetesRunner) ListWorkloads(ctx context.Context) ([]WorkloadInfo, error) {
 	podList, err := k8s.ListSessionPods(ctx)
@@ -137,6 +468,104 @@ func (r *KubernetesRunner) DeleteNetworkPolicy(ctx context.Context, sessionID st
 	return k8s.DeleteSessionNetworkPolicy(ctx, sessionID)
 }
 
+// CreateSessionRoute creates an Ingress that routes hostname to the Sortie
+// server, configured by k8s.ConfigureSessionIngress. With direct routing it
+// routes to a new Service in front of the session's pod instead.
+func (r *KubernetesRunner) CreateSessionRoute(ctx context.Context, sessionID, appID, hostname string, port int) error {
+	cfg := k8s.GetSessionIngressConfig()
+	ing := k8s.BuildSessionIngress(sessionID, appID, hostname, cfg)
+	if cfg.Direct {
+		if port == 0 {
+			port = 8080
+		}
+		// Match the server's proxy, which uses HTTPS on these ports
+		if port == 443 || port == 8443 {
+			k8s.SetBackendHTTPS(ing)
+		}
+		if _, err := k8s.CreateService(ctx, k8s.BuildSessionService(sessionID, appID, int32(port))); err != nil {
+			return fmt.Errorf("failed to create service: %w", err)
+		}
+	}
+	if _, err := k8s.CreateIngress(ctx, ing); err != nil {
+		if cfg.Direct {
+			k8s.DeleteSessionIngress(ctx, sessionID, cfg)
+		}
+		return fmt.Errorf("failed to create ingress: %w", err)
+	}
+
+	log.Printf("Created Ingress for session %s (host: %s)", sessionID, hostname)
+	return nil
+}
+
+// DeleteSessionRoute removes the Ingress for a session and its per-session
+// certificate Secret, if it has one.
+func (r *KubernetesRunner) DeleteSessionRoute(ctx context.Context, sessionID string) error {
+	return k8s.DeleteSessionIngress(ctx, sessionID, k8s.GetSessionIngressConfig())
+}
+
+// InspectWorkload reports the images, resolved digests and resources of a
+// session pod's init containers and containers, and the NetworkPolicy
+// applied to the session.
+func (r *KubernetesRunner) InspectWorkload(ctx context.Context, name, sessionID string) (*WorkloadDetails, error) {
+	pod, err := k8s.GetPod(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod: %w", err)
+	}
+
+	details := &WorkloadDetails{}
+	if pod.Status.StartTime != nil {
+		details.StartedAt = pod.Status.StartTime.Time
+	}
+
+	imageIDs := map[string]string{}
+	for _, status := range append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+		imageIDs[status.Name] = status.ImageID
+	}
+	for _, c := range append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...) {
+		details.Containers = append(details.Containers, ContainerDetails{
+			Name:      c.Name,
+			Image:     c.Image,
+			ImageID:   imageIDs[c.Name],
+			Resources: resourceLimits(c.Resources),
+		})
+	}
+
+	np, err := k8s.GetSessionNetworkPolicy(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network policy: %w", err)
+	}
+	if np != nil {
+		spec, err := json.Marshal(np.Spec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal network policy: %w", err)
+		}
+		details.NetworkPolicy = &NetworkPolicyDetails{Name: np.Name, Spec: spec}
+	}
+
+	return details, nil
+}
+
+// resourceLimits converts container resource requirements to ResourceLimits,
+// returning nil when none are set.
+func resourceLimits(req corev1.ResourceRequirements) *db.ResourceLimits {
+	quantity := func(list corev1.ResourceList, name corev1.ResourceName) string {
+		if q, ok := list[name]; ok {
+			return q.String()
+		}
+		return ""
+	}
+	limits := &db.ResourceLimits{
+		CPURequest:    quantity(req.Requests, corev1.ResourceCPU),
+		CPULimit:      quantity(req.Limits, corev1.ResourceCPU),
+		MemoryRequest: quantity(req.Requests, corev1.ResourceMemory),
+		MemoryLimit:   quantity(req.Limits, corev1.ResourceMemory),
+	}
+	if *limits == (db.ResourceLimits{}) {
+		return nil
+	}
+	return limits
+}
+
 // buildPod selects the appropriate pod builder based on launch type and OS.
 func buildPod(podConfig *k8s.PodConfig, launchType, osType string) *corev1.Pod {
 	switch launchType {
@@ -154,6 +583,13 @@ func buildPod(podConfig *k8s.PodConfig, launchType, osType string) *corev1.Pod {
 
 // Compile-time interface checks.
 var (
-	_ Runner              = (*KubernetesRunner)(nil)
-	_ NetworkPolicyRunner = (*KubernetesRunner)(nil)
+	_ Runner               = (*KubernetesRunner)(nil)
+	_ NetworkPolicyRunner  = (*KubernetesRunner)(nil)
+	_ RouteRunner          = (*KubernetesRunner)(nil)
+	_ WorkloadInspector    = (*KubernetesRunner)(nil)
+	_ UserVolumeRunner     = (*KubernetesRunner)(nil)
+	_ SessionVolumeRunner  = (*KubernetesRunner)(nil)
+	_ ProjectDriveRunner   = (*KubernetesRunner)(nil)
+	_ RegistrySecretRunner = (*KubernetesRunner)(nil)
+	_ ImagePrePullRunner   = (*KubernetesRunner)(nil)
 )
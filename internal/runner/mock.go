@@ -2,7 +2,10 @@ package runner
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -11,18 +14,28 @@ import (
 
 // MockWorkload tracks a workload created by MockRunner.
 type MockWorkload struct {
-	Name   string
-	Config *WorkloadConfig
-	IP     string
-	Ready  bool
+	Name      string
+	Config    *WorkloadConfig
+	IP        string
+	Ready     bool
+	CreatedAt time.Time
+	Pool      string // Warm pool of an unclaimed workload
 }
 
-// MockRunner implements Runner and NetworkPolicyRunner for tests.
+// MockRunner implements Runner and its optional interfaces for tests.
 // It stores workloads in-memory and supports failure injection.
 type MockRunner struct {
-	mu        sync.Mutex
-	workloads map[string]*MockWorkload
-	ipCounter int
+	mu             sync.Mutex
+	workloads      map[string]*MockWorkload
+	warm           map[string]*MockWorkload
+	policies       map[string]*db.EgressPolicy
+	routes         map[string]string
+	volumes        map[string]*UserVolume
+	sessionVolumes map[string]*SessionVolume
+	projectDrives  map[string]*db.ProjectDrive
+	registries     map[string]db.RegistryCredential
+	prepulls       map[string]*PrePull
+	ipCounter      int
 
 	// Error injection: set these to non-nil to simulate failures.
 	CreateError error
@@ -31,6 +44,19 @@ type MockRunner struct {
 
 	// ReadyDelay adds a delay before WaitForReady returns. Default 0 for fast tests.
 	ReadyDelay time.Duration
+
+	// Progress and ProgressDetail are reported by WorkloadProgress for any
+	// existing workload, to simulate a slow launch stage.
+	Progress       db.SessionSubstatus
+	ProgressDetail string
+
+	// Capacity is returned by CheckCapacity, to simulate a cluster without
+	// room for new workloads.
+	Capacity *CapacityError
+
+	restartWatchers    map[int]func(WorkloadRestart)
+	disruptionWatchers map[int]func(WorkloadDisruption)
+	nextWatcher        int
 }
 
 // NewMockRunner creates a new MockRunner with a small default ReadyDelay
@@ -38,8 +64,16 @@ type MockRunner struct {
 // updates the DB before the initial HTTP response completes.
 func NewMockRunner() *MockRunner {
 	return &MockRunner{
-		workloads:  make(map[string]*MockWorkload),
-		ReadyDelay: 500 * time.Millisecond,
+		workloads:      make(map[string]*MockWorkload),
+		warm:           make(map[string]*MockWorkload),
+		policies:       make(map[string]*db.EgressPolicy),
+		routes:         make(map[string]string),
+		volumes:        make(map[string]*UserVolume),
+		sessionVolumes: make(map[string]*SessionVolume),
+		projectDrives:  make(map[string]*db.ProjectDrive),
+		registries:     make(map[string]db.RegistryCredential),
+		prepulls:       make(map[string]*PrePull),
+		ReadyDelay:     500 * time.Millisecond,
 	}
 }
 
@@ -60,13 +94,14 @@ func (m *MockRunner) CreateWorkload(_ context.Context, config *WorkloadConfig) (
 	ip := fmt.Sprintf("10.0.0.%d", m.ipCounter)
 
 	m.workloads[name] = &MockWorkload{
-		Name:   name,
-		Config: config,
-		IP:     ip,
-		Ready:  true,
+		Name:      name,
+		Config:    config,
+		IP:        ip,
+		Ready:     true,
+		CreatedAt: time.Now(),
 	}
 
-	return &WorkloadResult{Name: name}, nil
+	return &WorkloadResult{Name: name, SidecarImage: mockSidecarImage(config)}, nil
 }
 
 func (m *MockRunner) DeleteWorkload(_ context.Context, name string) error {
@@ -78,6 +113,7 @@ func (m *MockRunner) DeleteWorkload(_ context.Context, name string) error {
 	}
 
 	delete(m.workloads, name)
+	delete(m.warm, name)
 	return nil
 }
 
@@ -139,14 +175,515 @@ func (m *MockRunner) Close() error {
 
 // NetworkPolicyRunner implementation
 
-func (m *MockRunner) CreateNetworkPolicy(_ context.Context, _, _ string, _ *db.EgressPolicy) error {
+func (m *MockRunner) CreateNetworkPolicy(_ context.Context, sessionID, _ string, policy *db.EgressPolicy) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.policies[sessionID] = policy
+	return nil
+}
+
+func (m *MockRunner) DeleteNetworkPolicy(_ context.Context, sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.policies, sessionID)
+	return nil
+}
+
+// RouteRunner implementation
+
+func (m *MockRunner) CreateSessionRoute(_ context.Context, sessionID, _, hostname string, _ int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.routes[sessionID] = hostname
+	return nil
+}
+
+func (m *MockRunner) DeleteSessionRoute(_ context.Context, sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.routes, sessionID)
+	return nil
+}
+
+// WorkloadInspector implementation
+
+// InspectWorkload reports the workload's app container and sidecars. Image
+// digests are derived from the image names.
+func (m *MockRunner) InspectWorkload(_ context.Context, name, sessionID string) (*WorkloadDetails, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, ok := m.workloads[name]
+	if !ok {
+		return nil, fmt.Errorf("workload %s not found", name)
+	}
+
+	details := &WorkloadDetails{
+		StartedAt: w.CreatedAt,
+		Containers: []ContainerDetails{{
+			Name:    "app",
+			Image:   w.Config.ContainerImage,
+			ImageID: mockImageID(w.Config.ContainerImage),
+			Resources: &db.ResourceLimits{
+				CPURequest:    w.Config.CPURequest,
+				CPULimit:      w.Config.CPULimit,
+				MemoryRequest: w.Config.MemoryRequest,
+				MemoryLimit:   w.Config.MemoryLimit,
+			},
+		}},
+	}
+	for _, sc := range w.Config.Sidecars {
+		c := ContainerDetails{Name: "sidecar-" + sc.Name, Image: sc.Image, ImageID: mockImageID(sc.Image)}
+		if r := sc.Resources; r != nil {
+			c.Resources = &db.ResourceLimits{
+				CPURequest:    r.CPURequest,
+				CPULimit:      r.CPULimit,
+				MemoryRequest: r.MemoryRequest,
+				MemoryLimit:   r.MemoryLimit,
+			}
+		}
+		details.Containers = append(details.Containers, c)
+	}
+	for _, ic := range w.Config.InitContainers {
+		c := ContainerDetails{Name: "init-" + ic.Name, Image: ic.Image, ImageID: mockImageID(ic.Image)}
+		if r := ic.Resources; r != nil {
+			limits := *r
+			c.Resources = &limits
+		}
+		details.Containers = append(details.Containers, c)
+	}
+	for _, ac := range w.Config.Containers {
+		c := ContainerDetails{Name: "app-" + ac.Name, Image: ac.Image, ImageID: mockImageID(ac.Image)}
+		if r := ac.Resources; r != nil {
+			limits := *r
+			c.Resources = &limits
+		}
+		details.Containers = append(details.Containers, c)
+	}
+	if policy, ok := m.policies[sessionID]; ok {
+		spec, err := json.Marshal(policy)
+		if err != nil {
+			return nil, err
+		}
+		details.NetworkPolicy = &NetworkPolicyDetails{Name: "sortie-egress-" + sessionID, Spec: spec}
+	}
+	return details, nil
+}
+
+// ProgressReporter implementation
+
+// WorkloadProgress reports the configured Progress for an existing workload.
+func (m *MockRunner) WorkloadProgress(_ context.Context, name string) (db.SessionSubstatus, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.workloads[name]; !ok {
+		return "", "", fmt.Errorf("workload %s not found", name)
+	}
+	return m.Progress, m.ProgressDetail, nil
+}
+
+// CapacityChecker implementation
+
+// CheckCapacity returns Capacity, if set.
+func (m *MockRunner) CheckCapacity(_ context.Context, _ *WorkloadConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Capacity != nil {
+		return m.Capacity
+	}
+	return nil
+}
+
+// SetCapacity sets the error CheckCapacity returns; nil means room.
+func (m *MockRunner) SetCapacity(err *CapacityError) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Capacity = err
+}
+
+// RestartWatcher implementation
+
+// WatchRestarts calls onRestart for each restart simulated with Restart
+// until ctx is done.
+func (m *MockRunner) WatchRestarts(ctx context.Context, onRestart func(WorkloadRestart)) error {
+	m.mu.Lock()
+	if m.restartWatchers == nil {
+		m.restartWatchers = make(map[int]func(WorkloadRestart))
+	}
+	id := m.nextWatcher
+	m.nextWatcher++
+	m.restartWatchers[id] = onRestart
+	m.mu.Unlock()
+
+	<-ctx.Done()
+
+	m.mu.Lock()
+	delete(m.restartWatchers, id)
+	m.mu.Unlock()
+	return nil
+}
+
+// Restart simulates the containers of a workload restarting, bringing its
+// restart count to restarts, and reports it to the watchers.
+func (m *MockRunner) Restart(name string, restarts int, detail string) {
+	m.mu.Lock()
+	watchers := make([]func(WorkloadRestart), 0, len(m.restartWatchers))
+	for _, fn := range m.restartWatchers {
+		watchers = append(watchers, fn)
+	}
+	m.mu.Unlock()
+
+	for _, fn := range watchers {
+		fn(WorkloadRestart{Name: name, Restarts: restarts, Detail: detail})
+	}
+}
+
+// DisruptionWatcher implementation
+
+// WatchDisruptions calls onDisruption for each disruption simulated with
+// Evict until ctx is done.
+func (m *MockRunner) WatchDisruptions(ctx context.Context, onDisruption func(WorkloadDisruption)) error {
+	m.mu.Lock()
+	if m.disruptionWatchers == nil {
+		m.disruptionWatchers = make(map[int]func(WorkloadDisruption))
+	}
+	id := m.nextWatcher
+	m.nextWatcher++
+	m.disruptionWatchers[id] = onDisruption
+	m.mu.Unlock()
+
+	<-ctx.Done()
+
+	m.mu.Lock()
+	delete(m.disruptionWatchers, id)
+	m.mu.Unlock()
+	return nil
+}
+
+// WaitForDeleted returns at once, as mock workloads are deleted
+// immediately.
+func (m *MockRunner) WaitForDeleted(ctx context.Context, name string) error {
+	return nil
+}
+
+// Evict simulates a workload being evicted: it removes the workload, then
+// reports the eviction and the deletion to the watchers.
+func (m *MockRunner) Evict(name, reason string) {
+	m.mu.Lock()
+	delete(m.workloads, name)
+	watchers := make([]func(WorkloadDisruption), 0, len(m.disruptionWatchers))
+	for _, fn := range m.disruptionWatchers {
+		watchers = append(watchers, fn)
+	}
+	m.mu.Unlock()
+
+	for _, fn := range watchers {
+		fn(WorkloadDisruption{Name: name, Reason: reason})
+		fn(WorkloadDisruption{Name: name, Reason: reason, Deleted: true})
+	}
+}
+
+// WarmPoolRunner implementation
+
+// CreateWarmWorkload adds a ready, unclaimed workload to the pool.
+func (m *MockRunner) CreateWarmWorkload(_ context.Context, pool string, config *WorkloadConfig) (*WorkloadResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.CreateError != nil {
+		return nil, m.CreateError
+	}
+
+	name := fmt.Sprintf("session-%s", config.SessionID)
+	m.ipCounter++
+	m.warm[name] = &MockWorkload{
+		Name:      name,
+		Config:    config,
+		IP:        fmt.Sprintf("10.0.0.%d", m.ipCounter),
+		Ready:     true,
+		CreatedAt: time.Now(),
+		Pool:      pool,
+	}
+	return &WorkloadResult{Name: name, SidecarImage: mockSidecarImage(config)}, nil
+}
+
+// ClaimWarmWorkload moves the oldest ready workload in the pool to the
+// session's workloads.
+func (m *MockRunner) ClaimWarmWorkload(_ context.Context, pool, sessionID string) (*WorkloadResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var claimed *MockWorkload
+	for _, w := range m.warm {
+		if w.Pool == pool && w.Ready && (claimed == nil || w.CreatedAt.Before(claimed.CreatedAt)) {
+			claimed = w
+		}
+	}
+	if claimed == nil {
+		return nil, nil
+	}
+
+	delete(m.warm, claimed.Name)
+	config := *claimed.Config
+	config.SessionID = sessionID
+	claimed.Config = &config
+	claimed.Pool = ""
+	m.workloads[claimed.Name] = claimed
+	return &WorkloadResult{Name: claimed.Name, SidecarImage: mockSidecarImage(&config)}, nil
+}
+
+// ListWarmWorkloads returns the unclaimed workloads.
+func (m *MockRunner) ListWarmWorkloads(_ context.Context) ([]WarmWorkload, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]WarmWorkload, 0, len(m.warm))
+	for _, w := range m.warm {
+		result = append(result, WarmWorkload{
+			Name:  w.Name,
+			AppID: w.Config.AppID,
+			Pool:  w.Pool,
+			Ready: w.Ready,
+		})
+	}
+	return result, nil
+}
+
+// UserVolumeRunner implementation
+
+// EnsureUserVolume adds a bound volume for the user and app unless one
+// exists.
+func (m *MockRunner) EnsureUserVolume(_ context.Context, userID, appID string, vol *db.HomeVolume) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name := fmt.Sprintf("home-%x", sha256.Sum256([]byte(userID+"\x00"+appID)))[:21]
+	if _, ok := m.volumes[name]; !ok {
+		m.volumes[name] = &UserVolume{
+			Name:         name,
+			UserID:       userID,
+			AppID:        appID,
+			Size:         vol.Size,
+			StorageClass: vol.StorageClass,
+			Status:       "Bound",
+			CreatedAt:    time.Now(),
+		}
+	}
+	return name, nil
+}
+
+// ListUserVolumes returns the user volumes.
+func (m *MockRunner) ListUserVolumes(_ context.Context) ([]UserVolume, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]UserVolume, 0, len(m.volumes))
+	for _, v := range m.volumes {
+		result = append(result, *v)
+	}
+	return result, nil
+}
+
+// DeleteUserVolume removes a user volume.
+func (m *MockRunner) DeleteUserVolume(_ context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.volumes[name]; !ok {
+		return fmt.Errorf("volume %s not found", name)
+	}
+	delete(m.volumes, name)
+	return nil
+}
+
+// SessionVolumeRunner implementation
+
+// EnsureSessionVolume adds a workspace volume for the session unless one
+// exists.
+func (m *MockRunner) EnsureSessionVolume(_ context.Context, sessionID, _ string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.sessionVolumes[sessionID]; !ok {
+		m.sessionVolumes[sessionID] = &SessionVolume{SessionID: sessionID, CreatedAt: time.Now()}
+	}
+	return "workspace-" + sessionID, nil
+}
+
+// ListSessionVolumes returns the workspace volumes.
+func (m *MockRunner) ListSessionVolumes(_ context.Context) ([]SessionVolume, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]SessionVolume, 0, len(m.sessionVolumes))
+	for _, v := range m.sessionVolumes {
+		result = append(result, *v)
+	}
+	return result, nil
+}
+
+// DeleteSessionVolume removes a session's workspace volume.
+func (m *MockRunner) DeleteSessionVolume(_ context.Context, sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessionVolumes, sessionID)
 	return nil
 }
 
-func (m *MockRunner) DeleteNetworkPolicy(_ context.Context, _ string) error {
+// AddSessionVolume adds a workspace volume created at the given time, to
+// simulate one left behind.
+func (m *MockRunner) AddSessionVolume(sessionID string, createdAt time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessionVolumes[sessionID] = &SessionVolume{SessionID: sessionID, CreatedAt: createdAt}
+}
+
+// HasSessionVolume reports whether a session has a workspace volume.
+func (m *MockRunner) HasSessionVolume(sessionID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.sessionVolumes[sessionID]
+	return ok
+}
+
+// ProjectDriveRunner implementation
+
+// EnsureProjectDrive adds a drive for the project unless one exists.
+func (m *MockRunner) EnsureProjectDrive(_ context.Context, projectID string, drive *db.ProjectDrive) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.projectDrives[projectID]; !ok {
+		d := *drive
+		m.projectDrives[projectID] = &d
+	}
+	return "project-" + projectID, nil
+}
+
+// DeleteProjectDrive removes a project's drive.
+func (m *MockRunner) DeleteProjectDrive(_ context.Context, projectID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.projectDrives, projectID)
 	return nil
 }
 
+// HasProjectDrive reports whether a project has a drive.
+func (m *MockRunner) HasProjectDrive(projectID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.projectDrives[projectID]
+	return ok
+}
+
+// RegistrySecretRunner implementation
+
+// ApplyRegistrySecret stores a copy of the credential, with its password.
+func (m *MockRunner) ApplyRegistrySecret(_ context.Context, cred *db.RegistryCredential) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.registries[cred.Name] = *cred
+	return nil
+}
+
+// DeleteRegistrySecret removes a stored credential.
+func (m *MockRunner) DeleteRegistrySecret(_ context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.registries, name)
+	return nil
+}
+
+// RegistrySecret returns the credential last applied under name, or nil.
+func (m *MockRunner) RegistrySecret(name string) *db.RegistryCredential {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cred, ok := m.registries[name]
+	if !ok {
+		return nil
+	}
+	return &cred
+}
+
+// ImagePrePullRunner implementation
+
+// StartPrePull records a pre-pull of the workload's app and sidecar
+// images, complete on a single node.
+func (m *MockRunner) StartPrePull(_ context.Context, config *WorkloadConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	images := []string{config.ContainerImage}
+	if image := mockSidecarImage(config); image != "" {
+		images = append(images, image)
+	}
+	m.prepulls[config.AppID] = &PrePull{
+		AppID:        config.AppID,
+		Images:       images,
+		StartedAt:    time.Now(),
+		DesiredNodes: 1,
+		Nodes:        []PrePullNode{{Node: "mock-node", Pulled: len(images)}},
+	}
+	return nil
+}
+
+// ListPrePulls returns the recorded pre-pulls ordered by app.
+func (m *MockRunner) ListPrePulls(_ context.Context) ([]PrePull, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pulls := make([]PrePull, 0, len(m.prepulls))
+	for _, p := range m.prepulls {
+		pulls = append(pulls, *p)
+	}
+	sort.Slice(pulls, func(i, j int) bool { return pulls[i].AppID < pulls[j].AppID })
+	return pulls, nil
+}
+
+// DeletePrePull removes an app's pre-pull.
+func (m *MockRunner) DeletePrePull(_ context.Context, appID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.prepulls, appID)
+	return nil
+}
+
+// SetPrePullStartedAt backdates an app's pre-pull, to test expiry.
+func (m *MockRunner) SetPrePullStartedAt(appID string, t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if p, ok := m.prepulls[appID]; ok {
+		p.StartedAt = t
+	}
+}
+
+// mockSidecarImage returns the app's sidecar image override for the
+// workload's launch type. The mock has no configured default images.
+func mockSidecarImage(config *WorkloadConfig) string {
+	o := config.SidecarImages
+	switch {
+	case o == nil:
+		return ""
+	case config.LaunchType == string(db.LaunchTypeWebProxy):
+		return o.Browser
+	case config.OsType == "windows":
+		return o.Guacd
+	default:
+		return o.VNC
+	}
+}
+
+// mockImageID returns a stable fake digest for an image.
+func mockImageID(image string) string {
+	return fmt.Sprintf("%s@sha256:%x", image, sha256.Sum256([]byte(image)))
+}
+
 // WorkloadCount returns the number of active workloads.
 func (m *MockRunner) WorkloadCount() int {
 	m.mu.Lock()
@@ -154,6 +691,43 @@ func (m *MockRunner) WorkloadCount() int {
 	return len(m.workloads)
 }
 
+// WarmWorkloadCount returns the number of unclaimed warm workloads.
+func (m *MockRunner) WarmWorkloadCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.warm)
+}
+
+// Workload returns the active workload with the given name, or nil.
+func (m *MockRunner) Workload(name string) *MockWorkload {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.workloads[name]
+}
+
+// NetworkPolicy returns the egress policy created for a session, or nil.
+func (m *MockRunner) NetworkPolicy(sessionID string) *db.EgressPolicy {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.policies[sessionID]
+}
+
+// Route returns the hostname routed for a session, or "".
+func (m *MockRunner) Route(sessionID string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.routes[sessionID]
+}
+
 // Compile-time interface checks.
 var _ Runner = (*MockRunner)(nil)
 var _ NetworkPolicyRunner = (*MockRunner)(nil)
+var _ RouteRunner = (*MockRunner)(nil)
+var _ WorkloadInspector = (*MockRunner)(nil)
+var _ ProgressReporter = (*MockRunner)(nil)
+var _ WarmPoolRunner = (*MockRunner)(nil)
+var _ UserVolumeRunner = (*MockRunner)(nil)
+var _ SessionVolumeRunner = (*MockRunner)(nil)
+var _ ProjectDriveRunner = (*MockRunner)(nil)
+var _ RegistrySecretRunner = (*MockRunner)(nil)
+var _ ImagePrePullRunner = (*MockRunner)(nil)
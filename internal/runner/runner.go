@@ -8,9 +8,11 @@ package runner
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/plugins"
 )
 
 // Type identifies the workload orchestration backend.
@@ -43,11 +45,58 @@ type WorkloadConfig struct {
 	ScreenHeight     int
 	LaunchType       string // "container" or "web_proxy"
 	OsType           string // "linux" or "windows"
+	// Sidecars are extra containers to run alongside the app. Runners that
+	// cannot run them should reject workloads that request any.
+	Sidecars []plugins.Sidecar
+	// Containers are the app's extra containers, started with the app
+	// container, and SharedVolumes the shared volumes the app container
+	// mounts. Runners that cannot run them should reject workloads that
+	// request any.
+	Containers    []db.AppContainer
+	SharedVolumes []db.SharedVolumeMount
+	// InitContainers run to completion, in order, before the workload's
+	// containers start. Runners that cannot run them should reject
+	// workloads that request any.
+	InitContainers []db.InitContainer
+	// DNS customizes the workload's hostname and name resolution.
+	DNS *db.DNSConfig
+	// Scheduling pins the workload to nodes.
+	Scheduling *db.Scheduling
+	// ImagePullSecret names the Secret the workload's images are pulled
+	// with.
+	ImagePullSecret string
+	// SidecarImages overrides the runner's configured built-in sidecar
+	// images.
+	SidecarImages *db.SidecarImages
+	// HomeVolume mounts a volume from UserVolumeRunner.EnsureUserVolume in
+	// the app container.
+	HomeVolume *HomeVolumeMount
+	// WorkspaceVolume names a volume from
+	// SessionVolumeRunner.EnsureSessionVolume that holds the workspace
+	// instead of storage deleted with the workload.
+	WorkspaceVolume string
+	// ProjectDrive mounts a volume from ProjectDriveRunner.EnsureProjectDrive
+	// in the app container.
+	ProjectDrive *ProjectDriveMount
+}
+
+// HomeVolumeMount mounts a user's persistent volume in a workload.
+type HomeVolumeMount struct {
+	Name      string // Volume name from EnsureUserVolume
+	MountPath string
+	FSGroup   *int64 // Group given ownership of the volume, if set
+}
+
+// ProjectDriveMount mounts a project's shared drive in a workload.
+type ProjectDriveMount struct {
+	Name      string // Volume name from EnsureProjectDrive
+	MountPath string
 }
 
 // WorkloadResult contains the result of creating a workload.
 type WorkloadResult struct {
-	Name string // Unique workload identifier (pod name, container ID, etc.)
+	Name         string // Unique workload identifier (pod name, container ID, etc.)
+	SidecarImage string // Image of the built-in display sidecar, if the workload runs one
 }
 
 // WorkloadInfo contains runtime information about a workload.
@@ -95,3 +144,277 @@ type NetworkPolicyRunner interface {
 	// DeleteNetworkPolicy removes the network policy for a session.
 	DeleteNetworkPolicy(ctx context.Context, sessionID string) error
 }
+
+// RouteRunner is an optional interface for runners that can route a
+// hostname to a web_proxy session, through the Sortie server or straight to
+// the session's workload, so it can be served at its own subdomain.
+// Runners that can't leave sessions reachable only through the server's
+// own address.
+type RouteRunner interface {
+	// CreateSessionRoute routes hostname to a session whose web app
+	// listens on port.
+	CreateSessionRoute(ctx context.Context, sessionID, appID, hostname string, port int) error
+
+	// DeleteSessionRoute removes a session's route and anything created
+	// for it, such as a certificate.
+	DeleteSessionRoute(ctx context.Context, sessionID string) error
+}
+
+// WorkloadInspector is an optional interface for runners that can report
+// what a running workload actually consists of. The session manager uses it
+// to attest sessions before their workloads are deleted.
+type WorkloadInspector interface {
+	// InspectWorkload describes a running workload and the network policy
+	// applied to its session.
+	InspectWorkload(ctx context.Context, name, sessionID string) (*WorkloadDetails, error)
+}
+
+// ProgressReporter is an optional interface for runners that can report
+// what a starting workload is waiting on. The session manager polls it
+// while a session is creating, to explain slow launches.
+type ProgressReporter interface {
+	// WorkloadProgress returns the workload's current launch stage and a
+	// human-readable detail, or an empty stage once it is ready.
+	WorkloadProgress(ctx context.Context, name string) (db.SessionSubstatus, string, error)
+}
+
+// CapacityChecker is an optional interface for runners that can tell,
+// before creating a workload, whether the cluster has room for it. The
+// session manager asks before each launch, so a session that cannot be
+// scheduled is rejected or queued with a reason instead of failing later.
+type CapacityChecker interface {
+	// CheckCapacity returns a *CapacityError if the workload cannot run
+	// now. Other errors mean capacity could not be checked.
+	CheckCapacity(ctx context.Context, config *WorkloadConfig) error
+}
+
+// CapacityError reports that the cluster cannot run a workload.
+type CapacityError struct {
+	Reason string
+	// Temporary is set when the workload would fit once others end, and
+	// unset when it can never fit as the cluster is
+	Temporary bool
+}
+
+func (e *CapacityError) Error() string {
+	return "insufficient cluster capacity: " + e.Reason
+}
+
+// RestartWatcher is an optional interface for runners that can report when
+// a workload's containers restart. The session manager uses it to mark
+// sessions whose app keeps crashing as degraded, and to fail them once
+// they have restarted too often.
+type RestartWatcher interface {
+	// WatchRestarts calls onRestart each time a workload's containers
+	// restart, until ctx is done.
+	WatchRestarts(ctx context.Context, onRestart func(WorkloadRestart)) error
+}
+
+// WorkloadRestart reports that a workload's containers have restarted.
+type WorkloadRestart struct {
+	Name string
+	// Restarts is the total restart count of the workload's containers
+	Restarts int
+	// Detail says which container restarted and why
+	Detail string
+}
+
+// DisruptionWatcher is an optional interface for runners that can report
+// when a workload is evicted or deleted out from under its session, such
+// as during a node drain. The session manager uses it to move the session
+// to a new workload instead of leaving the user with a frozen screen.
+type DisruptionWatcher interface {
+	// WatchDisruptions calls onDisruption when a workload is disrupted or
+	// deleted, until ctx is done.
+	WatchDisruptions(ctx context.Context, onDisruption func(WorkloadDisruption)) error
+
+	// WaitForDeleted waits until the named workload is gone, so one with
+	// the same name can replace it.
+	WaitForDeleted(ctx context.Context, name string) error
+}
+
+// WorkloadDisruption reports that a workload is going away without the
+// session manager having deleted it.
+type WorkloadDisruption struct {
+	Name string
+	// Reason says why, if known
+	Reason string
+	// Deleted is set once the workload is gone. Workloads the session
+	// manager deletes are reported too.
+	Deleted bool
+}
+
+// WarmPoolRunner is an optional interface for runners that can start
+// workloads before any session asks for them and hand them to sessions as
+// they launch, so a launch skips scheduling and image pulls. Runners that
+// can't always start session workloads cold.
+type WarmPoolRunner interface {
+	// CreateWarmWorkload starts an unclaimed workload from config in the
+	// named pool. config.SessionID only names the workload.
+	CreateWarmWorkload(ctx context.Context, pool string, config *WorkloadConfig) (*WorkloadResult, error)
+
+	// ClaimWarmWorkload gives a ready workload from the pool to a session,
+	// which then owns it like a workload it created. It returns nil when
+	// the pool has none ready. Each workload is claimed at most once, even
+	// by concurrent callers on different replicas.
+	ClaimWarmWorkload(ctx context.Context, pool, sessionID string) (*WorkloadResult, error)
+
+	// ListWarmWorkloads returns the unclaimed workloads of every pool.
+	ListWarmWorkloads(ctx context.Context) ([]WarmWorkload, error)
+}
+
+// UserVolumeRunner is an optional interface for runners that can keep a
+// persistent volume for each user of an app, so files outlive sessions.
+// Sessions of apps with a home volume fail to launch on runners that can't.
+type UserVolumeRunner interface {
+	// EnsureUserVolume returns the name of the user's volume for the app,
+	// creating it from vol on first use. An existing volume is reused
+	// as it is.
+	EnsureUserVolume(ctx context.Context, userID, appID string, vol *db.HomeVolume) (string, error)
+
+	// ListUserVolumes returns the volumes of every user.
+	ListUserVolumes(ctx context.Context) ([]UserVolume, error)
+
+	// DeleteUserVolume deletes a user volume by name, with its data.
+	DeleteUserVolume(ctx context.Context, name string) error
+}
+
+// ProjectDriveRunner is an optional interface for runners that can keep a
+// volume shared by every session of a project. Sessions launched in a
+// project with a drive fail to launch on runners that can't.
+type ProjectDriveRunner interface {
+	// EnsureProjectDrive returns the name of the project's drive, creating
+	// it from drive on first use. An existing drive is reused as it is.
+	EnsureProjectDrive(ctx context.Context, projectID string, drive *db.ProjectDrive) (string, error)
+
+	// DeleteProjectDrive deletes a project's drive, with its data.
+	// Deleting a drive that does not exist is not an error.
+	DeleteProjectDrive(ctx context.Context, projectID string) error
+}
+
+// RegistrySecretRunner is an optional interface for runners that pull
+// images with credentials kept beside the workloads, so that admin-managed
+// registry credentials can be written where image pull secrets name them.
+type RegistrySecretRunner interface {
+	// ApplyRegistrySecret writes the image pull secret for a registry
+	// credential, replacing the one written before.
+	ApplyRegistrySecret(ctx context.Context, cred *db.RegistryCredential) error
+
+	// DeleteRegistrySecret deletes a registry credential's image pull
+	// secret. Deleting a secret that does not exist is not an error.
+	DeleteRegistrySecret(ctx context.Context, name string) error
+}
+
+// ImagePrePullRunner is an optional interface for runners that can pull an
+// app's images onto nodes before sessions need them, so launches at busy
+// times skip the pull.
+type ImagePrePullRunner interface {
+	// StartPrePull pulls the images of config's workload onto every node
+	// the workload may be scheduled on, replacing any pre-pull of the same
+	// app.
+	StartPrePull(ctx context.Context, config *WorkloadConfig) error
+
+	// ListPrePulls returns the pre-pulls not yet deleted, with each node's
+	// progress.
+	ListPrePulls(ctx context.Context) ([]PrePull, error)
+
+	// DeletePrePull stops and removes an app's pre-pull. Deleting one that
+	// does not exist is not an error.
+	DeletePrePull(ctx context.Context, appID string) error
+}
+
+// SessionVolumeRunner is an optional interface for runners that can keep a
+// session's workspace on a volume that outlives its workload, so that a
+// hibernated session can be resumed with its files. Apps that hibernate on
+// idle fail to launch on runners that can't.
+type SessionVolumeRunner interface {
+	// EnsureSessionVolume returns the name of the session's workspace
+	// volume, creating it on first use.
+	EnsureSessionVolume(ctx context.Context, sessionID, appID string) (string, error)
+
+	// ListSessionVolumes returns the workspace volumes of every session.
+	ListSessionVolumes(ctx context.Context) ([]SessionVolume, error)
+
+	// DeleteSessionVolume deletes a session's workspace volume, with its
+	// data. Deleting a volume that does not exist is not an error.
+	DeleteSessionVolume(ctx context.Context, sessionID string) error
+}
+
+// SessionVolume describes a session's workspace volume.
+type SessionVolume struct {
+	SessionID string
+	CreatedAt time.Time
+}
+
+// PrePull is the progress of pulling an app's images onto nodes.
+type PrePull struct {
+	AppID     string    `json:"app_id"`
+	Images    []string  `json:"images"`
+	StartedAt time.Time `json:"started_at"`
+	// DesiredNodes is the number of nodes the images are pulled onto.
+	DesiredNodes int           `json:"desired_nodes"`
+	Nodes        []PrePullNode `json:"nodes"`
+}
+
+// PrePullNode is the progress of a pre-pull on one node.
+type PrePullNode struct {
+	Node string `json:"node"`
+	// Pulled is the number of the pre-pull's images the node has.
+	Pulled int `json:"pulled"`
+	// Error is why an image could not be pulled, if one could not.
+	Error string `json:"error,omitempty"`
+}
+
+// Complete reports whether every node has every image.
+func (p *PrePull) Complete() bool {
+	if len(p.Nodes) < p.DesiredNodes {
+		return false
+	}
+	for _, n := range p.Nodes {
+		if n.Pulled < len(p.Images) {
+			return false
+		}
+	}
+	return true
+}
+
+// UserVolume describes a user's persistent volume for an app.
+type UserVolume struct {
+	Name         string    `json:"name"`
+	UserID       string    `json:"user_id"`
+	AppID        string    `json:"app_id"`
+	Size         string    `json:"size"`
+	StorageClass string    `json:"storage_class,omitempty"`
+	Status       string    `json:"status"` // e.g. Pending, Bound or Lost
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// WarmWorkload describes an unclaimed workload in a warm pool.
+type WarmWorkload struct {
+	Name   string
+	AppID  string
+	Pool   string
+	Ready  bool
+	Failed bool // Exited, so it will never become ready
+}
+
+// WorkloadDetails describes a running workload as reported by its backend.
+type WorkloadDetails struct {
+	StartedAt     time.Time
+	Containers    []ContainerDetails
+	NetworkPolicy *NetworkPolicyDetails // nil when no session policy is applied
+}
+
+// ContainerDetails describes a container in a running workload.
+type ContainerDetails struct {
+	Name      string
+	Image     string
+	ImageID   string // Image digest the runtime resolved Image to
+	Resources *db.ResourceLimits
+}
+
+// NetworkPolicyDetails is a session network policy as applied by the backend.
+type NetworkPolicyDetails struct {
+	Name string
+	Spec json.RawMessage
+}
@@ -8,6 +8,9 @@ import (
 	"time"
 
 	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/plugins"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 // --- Mock Runner for testing the interface contract ---
@@ -135,6 +138,18 @@ func TestKubernetesRunner_ImplementsNetworkPolicyRunner(t *testing.T) {
 	var _ NetworkPolicyRunner = (*KubernetesRunner)(nil)
 }
 
+func TestKubernetesRunner_ImplementsWorkloadInspector(t *testing.T) {
+	var _ WorkloadInspector = (*KubernetesRunner)(nil)
+}
+
+func TestKubernetesRunner_ImplementsProgressReporter(t *testing.T) {
+	var _ ProgressReporter = (*KubernetesRunner)(nil)
+}
+
+func TestKubernetesRunner_ImplementsWarmPoolRunner(t *testing.T) {
+	var _ WarmPoolRunner = (*KubernetesRunner)(nil)
+}
+
 // --- Type constants ---
 
 func TestRunnerTypes(t *testing.T) {
@@ -346,3 +361,91 @@ func TestKubernetesRunner_Close(t *testing.T) {
 		t.Errorf("Close error: %v", err)
 	}
 }
+
+func TestResourceLimits(t *testing.T) {
+	if got := resourceLimits(corev1.ResourceRequirements{}); got != nil {
+		t.Errorf("resourceLimits(empty) = %+v, want nil", got)
+	}
+
+	got := resourceLimits(corev1.ResourceRequirements{
+		Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("2Gi")},
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+	})
+	want := db.ResourceLimits{CPULimit: "2", MemoryLimit: "2Gi", CPURequest: "500m"}
+	if got == nil || *got != want {
+		t.Errorf("resourceLimits() = %+v, want %+v", got, want)
+	}
+}
+
+func TestMockRunner_InspectWorkload(t *testing.T) {
+	m := NewMockRunner()
+	ctx := context.Background()
+
+	result, err := m.CreateWorkload(ctx, &WorkloadConfig{
+		SessionID:      "s1",
+		ContainerImage: "nginx:latest",
+		CPULimit:       "1",
+		Sidecars:       []plugins.Sidecar{{Name: "logs", Image: "logs:1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.CreateNetworkPolicy(ctx, "s1", "a1", &db.EgressPolicy{Mode: "allowlist"})
+
+	details, err := m.InspectWorkload(ctx, result.Name, "s1")
+	if err != nil {
+		t.Fatalf("InspectWorkload() error = %v", err)
+	}
+	if details.StartedAt.IsZero() {
+		t.Error("StartedAt should be set")
+	}
+	if len(details.Containers) != 2 || details.Containers[0].ImageID == "" || details.Containers[1].Name != "sidecar-logs" {
+		t.Errorf("Containers = %+v", details.Containers)
+	}
+	if details.Containers[0].Resources.CPULimit != "1" {
+		t.Errorf("app resources = %+v", details.Containers[0].Resources)
+	}
+	if details.NetworkPolicy == nil || details.NetworkPolicy.Name != "sortie-egress-s1" {
+		t.Errorf("NetworkPolicy = %+v", details.NetworkPolicy)
+	}
+
+	m.DeleteNetworkPolicy(ctx, "s1")
+	details, _ = m.InspectWorkload(ctx, result.Name, "s1")
+	if details.NetworkPolicy != nil {
+		t.Error("expected no network policy after delete")
+	}
+
+	if _, err := m.InspectWorkload(ctx, "missing", "s1"); err == nil {
+		t.Error("expected error for missing workload")
+	}
+}
+
+func TestMockRunner_WarmPool(t *testing.T) {
+	m := NewMockRunner()
+	ctx := context.Background()
+
+	if _, err := m.CreateWarmWorkload(ctx, "pool-a", &WorkloadConfig{SessionID: "warm-1", AppID: "a1"}); err != nil {
+		t.Fatalf("CreateWarmWorkload() error = %v", err)
+	}
+	warm, _ := m.ListWarmWorkloads(ctx)
+	if len(warm) != 1 || warm[0].AppID != "a1" || warm[0].Pool != "pool-a" {
+		t.Fatalf("ListWarmWorkloads() = %+v", warm)
+	}
+	if list, _ := m.ListWorkloads(ctx); len(list) != 0 {
+		t.Errorf("unclaimed workload listed as a session workload: %+v", list)
+	}
+
+	if result, _ := m.ClaimWarmWorkload(ctx, "pool-b", "s1"); result != nil {
+		t.Errorf("claimed %+v from an empty pool", result)
+	}
+	result, err := m.ClaimWarmWorkload(ctx, "pool-a", "s1")
+	if err != nil || result == nil {
+		t.Fatalf("ClaimWarmWorkload() = %v, %v", result, err)
+	}
+	if w := m.Workload(result.Name); w == nil || w.Config.SessionID != "s1" {
+		t.Errorf("claimed workload = %+v, want one owned by s1", w)
+	}
+	if result, _ := m.ClaimWarmWorkload(ctx, "pool-a", "s2"); result != nil {
+		t.Errorf("claimed %s twice", result.Name)
+	}
+}
@@ -0,0 +1,223 @@
+// Package schedule decides when an application may be launched, from its
+// recurring weekly windows and one-off blackout periods.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	// Embed the timezone database so schedules work in minimal images
+	// without /usr/share/zoneinfo.
+	_ "time/tzdata"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// days maps schedule day names to weekdays.
+var days = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ClosedError is returned when an app's schedule does not allow launching
+// it.
+type ClosedError struct {
+	App     string    // App name, set by the caller
+	Reason  string    // Why the app is closed
+	OpensAt time.Time // When the app next opens; zero if not within a week
+}
+
+func (e *ClosedError) Error() string {
+	msg := fmt.Sprintf("application %s is not available: %s", e.App, e.Reason)
+	if !e.OpensAt.IsZero() {
+		msg += fmt.Sprintf(" (next available %s)", e.OpensAt.Format(time.RFC3339))
+	}
+	return msg
+}
+
+// Validate checks a schedule for unknown timezones and days, malformed
+// times, and blackouts that end before they start.
+func Validate(s *db.AppSchedule) error {
+	if s == nil {
+		return nil
+	}
+	if _, err := location(s); err != nil {
+		return err
+	}
+	for i, w := range s.Windows {
+		for _, d := range w.Days {
+			if _, ok := days[strings.ToLower(d)]; !ok {
+				return fmt.Errorf("window %d: unknown day %q (use mon, tue, wed, thu, fri, sat or sun)", i+1, d)
+			}
+		}
+		start, err := parseClock(w.Start, false)
+		if err != nil {
+			return fmt.Errorf("window %d: start: %w", i+1, err)
+		}
+		end, err := parseClock(w.End, true)
+		if err != nil {
+			return fmt.Errorf("window %d: end: %w", i+1, err)
+		}
+		if start == end {
+			return fmt.Errorf("window %d: start and end must differ", i+1)
+		}
+	}
+	for i, b := range s.Blackouts {
+		if b.Start.IsZero() || b.End.IsZero() {
+			return fmt.Errorf("blackout %d: start and end are required", i+1)
+		}
+		if !b.End.After(b.Start) {
+			return fmt.Errorf("blackout %d: end must be after start", i+1)
+		}
+	}
+	return nil
+}
+
+// Check returns a ClosedError if the schedule does not allow launching at
+// now. A nil schedule is always open.
+func Check(s *db.AppSchedule, now time.Time) *ClosedError {
+	reason := closedReason(s, now)
+	if reason == "" {
+		return nil
+	}
+	return &ClosedError{Reason: reason, OpensAt: NextOpen(s, now)}
+}
+
+// NextOpen returns the first time after now at which the schedule is open,
+// or zero if it does not open within a week of now or of the end of a
+// blackout.
+func NextOpen(s *db.AppSchedule, now time.Time) time.Time {
+	if s == nil {
+		return time.Time{}
+	}
+	loc, err := location(s)
+	if err != nil {
+		return time.Time{}
+	}
+
+	// The schedule can only open when a window starts or a blackout ends
+	var candidates []time.Time
+	from := []time.Time{now}
+	for _, b := range s.Blackouts {
+		if b.End.After(now) {
+			candidates = append(candidates, b.End)
+			from = append(from, b.End)
+		}
+	}
+	for _, t := range from {
+		candidates = append(candidates, windowStarts(s, t.In(loc))...)
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+
+	for _, c := range candidates {
+		if c.After(now) && closedReason(s, c) == "" {
+			return c
+		}
+	}
+	return time.Time{}
+}
+
+// closedReason returns why the schedule is closed at t, or "" if it is open.
+func closedReason(s *db.AppSchedule, t time.Time) string {
+	if s == nil {
+		return ""
+	}
+	for _, b := range s.Blackouts {
+		if !t.Before(b.Start) && t.Before(b.End) {
+			if b.Reason != "" {
+				return "blackout: " + b.Reason
+			}
+			return "blackout period"
+		}
+	}
+	if len(s.Windows) == 0 {
+		return ""
+	}
+	loc, err := location(s)
+	if err != nil {
+		return "invalid schedule"
+	}
+	local := t.In(loc)
+	for _, w := range s.Windows {
+		if inWindow(w, local) {
+			return ""
+		}
+	}
+	return "outside scheduled hours"
+}
+
+// inWindow reports whether t, in the schedule's timezone, falls inside w.
+func inWindow(w db.ScheduleWindow, t time.Time) bool {
+	start, err1 := parseClock(w.Start, false)
+	end, err2 := parseClock(w.End, true)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	minute := t.Hour()*60 + t.Minute()
+	if start < end {
+		return onDay(w, t.Weekday()) && minute >= start && minute < end
+	}
+	// Overnight: the window belongs to the day it starts on
+	yesterday := (t.Weekday() + 6) % 7
+	return (onDay(w, t.Weekday()) && minute >= start) || (onDay(w, yesterday) && minute < end)
+}
+
+// windowStarts returns the times windows start over the week from t.
+func windowStarts(s *db.AppSchedule, t time.Time) []time.Time {
+	var starts []time.Time
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for d := 0; d <= 7; d++ {
+		day := midnight.AddDate(0, 0, d)
+		for _, w := range s.Windows {
+			start, err := parseClock(w.Start, false)
+			if err != nil || !onDay(w, day.Weekday()) {
+				continue
+			}
+			starts = append(starts, time.Date(day.Year(), day.Month(), day.Day(), start/60, start%60, 0, 0, day.Location()))
+		}
+	}
+	return starts
+}
+
+func onDay(w db.ScheduleWindow, day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(w.Days, func(d string) bool {
+		wd, ok := days[strings.ToLower(d)]
+		return ok && wd == day
+	})
+}
+
+// parseClock parses "HH:MM" into minutes after midnight. "24:00" is
+// allowed only as an end time.
+func parseClock(s string, isEnd bool) (int, error) {
+	if isEnd && s == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (use HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func location(s *db.AppSchedule) (*time.Location, error) {
+	if s.Timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, errors.New("unknown timezone " + s.Timezone)
+	}
+	return loc, nil
+}
@@ -0,0 +1,156 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+var businessHours = &db.AppSchedule{
+	Timezone: "America/New_York",
+	Windows:  []db.ScheduleWindow{{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "09:00", End: "17:00"}},
+}
+
+func nyTime(t *testing.T, s string) time.Time {
+	t.Helper()
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, err := time.ParseInLocation("2006-01-02 15:04", s, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func TestValidate(t *testing.T) {
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	valid := []*db.AppSchedule{
+		nil,
+		{},
+		businessHours,
+		{Windows: []db.ScheduleWindow{{Start: "22:00", End: "06:00"}, {Days: []string{"Sat"}, Start: "00:00", End: "24:00"}}},
+		{Blackouts: []db.Blackout{{Start: start, End: start.Add(time.Hour), Reason: "upgrade"}}},
+	}
+	for _, s := range valid {
+		if err := Validate(s); err != nil {
+			t.Errorf("Validate(%+v) error = %v", s, err)
+		}
+	}
+
+	invalid := map[string]*db.AppSchedule{
+		"timezone":      {Timezone: "Mars/Olympus"},
+		"day":           {Windows: []db.ScheduleWindow{{Days: []string{"funday"}, Start: "09:00", End: "17:00"}}},
+		"start":         {Windows: []db.ScheduleWindow{{Start: "9am", End: "17:00"}}},
+		"end":           {Windows: []db.ScheduleWindow{{Start: "09:00", End: "25:00"}}},
+		"start 24:00":   {Windows: []db.ScheduleWindow{{Start: "24:00", End: "06:00"}}},
+		"empty window":  {Windows: []db.ScheduleWindow{{Start: "09:00", End: "09:00"}}},
+		"blackout end":  {Blackouts: []db.Blackout{{Start: start, End: start}}},
+		"blackout zero": {Blackouts: []db.Blackout{{End: start}}},
+	}
+	for name, s := range invalid {
+		if err := Validate(s); err == nil {
+			t.Errorf("Validate(%s) expected error", name)
+		}
+	}
+}
+
+func TestCheck_Windows(t *testing.T) {
+	tests := []struct {
+		at   string
+		open bool
+	}{
+		{"2026-03-02 09:00", true},  // Monday opening
+		{"2026-03-02 16:59", true},  // Monday before close
+		{"2026-03-02 17:00", false}, // Monday close
+		{"2026-03-02 08:59", false}, // Monday before opening
+		{"2026-03-07 12:00", false}, // Saturday
+	}
+	for _, tt := range tests {
+		closed := Check(businessHours, nyTime(t, tt.at))
+		if (closed == nil) != tt.open {
+			t.Errorf("Check(%s) = %v, want open=%v", tt.at, closed, tt.open)
+		}
+	}
+
+	// Times are interpreted in the schedule's timezone
+	if closed := Check(businessHours, time.Date(2026, 3, 2, 14, 0, 0, 0, time.UTC)); closed != nil {
+		t.Errorf("Check(14:00 UTC = 09:00 EST) = %v, want open", closed)
+	}
+}
+
+func TestCheck_OvernightWindow(t *testing.T) {
+	s := &db.AppSchedule{Windows: []db.ScheduleWindow{{Days: []string{"fri"}, Start: "22:00", End: "02:00"}}}
+	friday := time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC)
+
+	for offset, open := range map[time.Duration]bool{
+		23 * time.Hour:                true,  // Friday 23:00
+		25 * time.Hour:                true,  // Saturday 01:00
+		26 * time.Hour:                false, // Saturday 02:00
+		time.Hour:                     false, // Friday 01:00 belongs to Thursday
+		21*time.Hour + 59*time.Minute: false,
+	} {
+		if closed := Check(s, friday.Add(offset)); (closed == nil) != open {
+			t.Errorf("Check(Friday +%v) = %v, want open=%v", offset, closed, open)
+		}
+	}
+}
+
+func TestCheck_Blackout(t *testing.T) {
+	start := nyTime(t, "2026-03-03 12:00")
+	s := &db.AppSchedule{
+		Timezone:  businessHours.Timezone,
+		Windows:   businessHours.Windows,
+		Blackouts: []db.Blackout{{Start: start, End: start.Add(2 * time.Hour), Reason: "database upgrade"}},
+	}
+
+	closed := Check(s, start.Add(time.Hour))
+	if closed == nil {
+		t.Fatal("Check() during blackout should be closed")
+	}
+	if !strings.Contains(closed.Error(), "database upgrade") {
+		t.Errorf("Error() = %q, want blackout reason", closed.Error())
+	}
+	if !closed.OpensAt.Equal(start.Add(2 * time.Hour)) {
+		t.Errorf("OpensAt = %v, want blackout end", closed.OpensAt)
+	}
+
+	// Without windows, only blackouts close the app
+	if Check(&db.AppSchedule{Blackouts: s.Blackouts}, start.Add(-time.Hour)) != nil {
+		t.Error("Check() before blackout should be open")
+	}
+}
+
+func TestNextOpen(t *testing.T) {
+	// Friday evening opens Monday morning
+	got := NextOpen(businessHours, nyTime(t, "2026-03-06 18:30"))
+	if want := nyTime(t, "2026-03-09 09:00"); !got.Equal(want) {
+		t.Errorf("NextOpen(Friday evening) = %v, want %v", got, want)
+	}
+
+	// A blackout ending after hours opens at the next window
+	start := nyTime(t, "2026-03-03 16:00")
+	s := &db.AppSchedule{
+		Timezone:  businessHours.Timezone,
+		Windows:   businessHours.Windows,
+		Blackouts: []db.Blackout{{Start: start, End: start.Add(3 * time.Hour)}},
+	}
+	got = NextOpen(s, start.Add(time.Minute))
+	if want := nyTime(t, "2026-03-04 09:00"); !got.Equal(want) {
+		t.Errorf("NextOpen(after-hours blackout) = %v, want %v", got, want)
+	}
+
+	// Long blackouts are searched past their end
+	long := &db.AppSchedule{Windows: []db.ScheduleWindow{{Days: []string{"mon"}, Start: "09:00", End: "10:00"}},
+		Blackouts: []db.Blackout{{Start: start.Add(-time.Hour), End: time.Date(2027, 3, 3, 0, 0, 0, 0, time.UTC)}}}
+	if got, want := NextOpen(long, start), time.Date(2027, 3, 8, 9, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("NextOpen(long blackout) = %v, want %v", got, want)
+	}
+
+	if got := NextOpen(nil, start); !got.IsZero() {
+		t.Errorf("NextOpen(nil) = %v, want zero", got)
+	}
+}
@@ -0,0 +1,139 @@
+// Package scheduledsessions launches sessions at times users chose ahead
+// of time, so environments such as classroom labs are running before
+// people arrive. Schedules launch once or on a cron recurrence, and a
+// background job on the leader replica launches those that are due.
+package scheduledsessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	// Embed the timezone database so schedules work in minimal images
+	// without /usr/share/zoneinfo.
+	_ "time/tzdata"
+
+	"github.com/rjsadow/sortie/internal/cron"
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/sessions"
+)
+
+// MissedGrace is how late a launch may still start, such as after the
+// server was down. Later launches are skipped rather than started when
+// nobody expects them.
+const MissedGrace = time.Hour
+
+// Launcher creates sessions. *sessions.Manager implements it.
+type Launcher interface {
+	CreateSession(ctx context.Context, req *sessions.CreateSessionRequest) (*db.Session, error)
+}
+
+// FirstRun validates a new schedule and returns its first launch: start
+// for a one-off schedule, or the first time of recurrence at or after
+// start, in timezone. It fails if the schedule would never launch.
+func FirstRun(start time.Time, recurrence, timezone string, endsAt *time.Time) (time.Time, error) {
+	if recurrence == "" {
+		if endsAt != nil {
+			return time.Time{}, errors.New("ends_at requires a recurrence")
+		}
+		return start, nil
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unknown timezone %q", timezone)
+	}
+	sched, err := cron.Parse(recurrence)
+	if err != nil {
+		return time.Time{}, err
+	}
+	first := sched.Next(start.In(loc).Add(-time.Minute))
+	if first.IsZero() || (endsAt != nil && first.After(*endsAt)) {
+		return time.Time{}, errors.New("the recurrence has no launches in the scheduled period")
+	}
+	return first, nil
+}
+
+// nextRun returns a schedule's first launch after t, or nil if it has none
+// left.
+func nextRun(s *db.ScheduledSession, t time.Time) *time.Time {
+	if s.Recurrence == "" {
+		return nil
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil
+	}
+	sched, err := cron.Parse(s.Recurrence)
+	if err != nil {
+		return nil
+	}
+	next := sched.Next(t.In(loc))
+	if next.IsZero() || (s.EndsAt != nil && next.After(*s.EndsAt)) {
+		return nil
+	}
+	next = next.UTC()
+	return &next
+}
+
+// Runner launches due schedules.
+type Runner struct {
+	db       *db.DB
+	launcher Launcher
+	now      func() time.Time
+}
+
+// NewRunner creates a Runner that launches sessions with launcher.
+func NewRunner(database *db.DB, launcher Launcher) *Runner {
+	return &Runner{db: database, launcher: launcher, now: time.Now}
+}
+
+// Run launches every schedule that is due and moves each to its next
+// launch. A launch that fails, for example because the user is at their
+// session quota, is recorded on the schedule and not retried. It is run as
+// a background job.
+func (r *Runner) Run(ctx context.Context) error {
+	now := r.now()
+	due, err := r.db.ListDueScheduledSessions(now)
+	if err != nil {
+		return fmt.Errorf("failed to list due scheduled sessions: %w", err)
+	}
+
+	var errs []error
+	for i := range due {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := r.launch(ctx, &due[i], now); err != nil {
+			errs = append(errs, fmt.Errorf("scheduled session %s: %w", due[i].ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Runner) launch(ctx context.Context, s *db.ScheduledSession, now time.Time) error {
+	dueAt := *s.NextRunAt
+	// Launches missed while the server was down are not caught up on
+	ok, err := r.db.ClaimScheduledSession(s.ID, dueAt, nextRun(s, now))
+	if err != nil || !ok {
+		return err
+	}
+
+	var sessionID, errMsg string
+	if late := now.Sub(dueAt); late > MissedGrace {
+		errMsg = fmt.Sprintf("skipped the launch due at %s, %s late", dueAt.UTC().Format(time.RFC3339), late.Truncate(time.Minute))
+		slog.Warn("skipped missed scheduled session launch", "schedule_id", s.ID, "due_at", dueAt)
+	} else {
+		session, err := r.launcher.CreateSession(ctx, &sessions.CreateSessionRequest{AppID: s.AppID, UserID: s.UserID})
+		if err != nil {
+			errMsg = err.Error()
+			slog.Warn("scheduled session launch failed", "schedule_id", s.ID, "app_id", s.AppID, "user_id", s.UserID, "error", err)
+		} else {
+			sessionID = session.ID
+			slog.Info("launched scheduled session", "schedule_id", s.ID, "session_id", session.ID, "app_id", s.AppID, "user_id", s.UserID)
+			r.db.LogAudit(s.UserID, "CREATE_SESSION", fmt.Sprintf("Created session %s for app %s from schedule %s", session.ID, s.AppID, s.ID))
+		}
+	}
+	return r.db.RecordScheduledSessionRun(s.ID, now, sessionID, errMsg)
+}
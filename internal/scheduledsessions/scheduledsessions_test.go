@@ -0,0 +1,125 @@
+package scheduledsessions
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/db/dbtest"
+	"github.com/rjsadow/sortie/internal/sessions"
+)
+
+// fakeLauncher records launches and fails those for failApp.
+type fakeLauncher struct {
+	launched []sessions.CreateSessionRequest
+	failApp  string
+}
+
+func (l *fakeLauncher) CreateSession(_ context.Context, req *sessions.CreateSessionRequest) (*db.Session, error) {
+	if req.AppID == l.failApp {
+		return nil, errors.New("quota exceeded")
+	}
+	l.launched = append(l.launched, *req)
+	return &db.Session{ID: "sess-" + req.AppID}, nil
+}
+
+func TestFirstRun(t *testing.T) {
+	start := time.Date(2026, 3, 6, 12, 0, 0, 0, time.UTC) // Friday
+	ends := start.AddDate(0, 0, 1)
+
+	tests := []struct {
+		name       string
+		recurrence string
+		timezone   string
+		endsAt     *time.Time
+		want       time.Time
+		wantErr    bool
+	}{
+		{name: "one-off", want: start},
+		{name: "one-off with end", endsAt: &ends, wantErr: true},
+		{name: "weekday mornings", recurrence: "0 8 * * 1-5", timezone: "UTC", want: time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC)},
+		{name: "at start", recurrence: "0 12 * * *", timezone: "UTC", want: start},
+		{name: "in timezone", recurrence: "0 8 * * *", timezone: "Europe/Berlin", want: time.Date(2026, 3, 7, 7, 0, 0, 0, time.UTC)},
+		{name: "none before end", recurrence: "0 8 * * 1", timezone: "UTC", endsAt: &ends, wantErr: true},
+		{name: "bad cron", recurrence: "every day", timezone: "UTC", wantErr: true},
+		{name: "bad timezone", recurrence: "@daily", timezone: "Mars/Olympus", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FirstRun(start, tt.recurrence, tt.timezone, tt.endsAt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FirstRun() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !got.Equal(tt.want) {
+				t.Errorf("FirstRun() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunner_Run(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+	now := time.Date(2026, 3, 9, 7, 55, 0, 0, time.UTC) // Monday
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	ends := now.Add(36 * time.Hour)
+
+	for _, s := range []db.ScheduledSession{
+		{ID: "once", UserID: "u1", AppID: "lab", NextRunAt: at(-time.Minute)},
+		{ID: "daily", UserID: "u1", AppID: "ide", Recurrence: "55 7 * * *", Timezone: "UTC", EndsAt: &ends, NextRunAt: at(0)},
+		{ID: "failing", UserID: "u2", AppID: "full", Recurrence: "@hourly", Timezone: "UTC", NextRunAt: at(-5 * time.Minute)},
+		{ID: "missed", UserID: "u2", AppID: "lab", NextRunAt: at(-3 * time.Hour)},
+		{ID: "future", UserID: "u2", AppID: "lab", NextRunAt: at(time.Minute)},
+	} {
+		s.StartAt = now.Add(-24 * time.Hour)
+		if err := database.CreateScheduledSession(s); err != nil {
+			t.Fatalf("CreateScheduledSession() error = %v", err)
+		}
+	}
+
+	launcher := &fakeLauncher{failApp: "full"}
+	runner := NewRunner(database, launcher)
+	runner.now = func() time.Time { return now }
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(launcher.launched) != 2 {
+		t.Fatalf("launched %+v, want the one-off and daily schedules", launcher.launched)
+	}
+
+	get := func(id string) *db.ScheduledSession {
+		s, err := database.GetScheduledSession(id)
+		if err != nil || s == nil {
+			t.Fatalf("GetScheduledSession(%s) = %v, %v", id, s, err)
+		}
+		return s
+	}
+	if s := get("once"); s.NextRunAt != nil || s.LastSessionID != "sess-lab" || s.LastError != "" {
+		t.Errorf("one-off after launch = %+v, want finished with its session", s)
+	}
+	if s := get("daily"); s.NextRunAt == nil || !s.NextRunAt.Equal(now.AddDate(0, 0, 1)) || s.LastSessionID != "sess-ide" {
+		t.Errorf("daily after launch = %+v, want the next launch tomorrow", s)
+	}
+	if s := get("failing"); s.LastError != "quota exceeded" || s.NextRunAt == nil || !s.NextRunAt.Equal(now.Add(5*time.Minute)) {
+		t.Errorf("failing after launch = %+v, want the error recorded and the next hour scheduled", s)
+	}
+	if s := get("missed"); s.NextRunAt != nil || s.LastSessionID != "" || s.LastError == "" {
+		t.Errorf("missed after run = %+v, want skipped", s)
+	}
+	if s := get("future"); s.LastRunAt != nil {
+		t.Errorf("future schedule ran: %+v", s)
+	}
+
+	// The daily schedule ends after its launch on the 10th
+	runner.now = func() time.Time { return now.AddDate(0, 0, 1) }
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if s := get("daily"); s.NextRunAt != nil {
+		t.Errorf("daily after its last launch: next run %s, want none", s.NextRunAt)
+	}
+}
@@ -12,6 +12,8 @@ import (
 	"os"
 	"strings"
 	"time"
+
+	"github.com/rjsadow/sortie/internal/offline"
 )
 
 // AWSProvider reads secrets from AWS Secrets Manager.
@@ -32,9 +34,7 @@ func NewAWSProvider(cfg *Config) (*AWSProvider, error) {
 	}
 
 	p := &AWSProvider{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		client:       offline.Client(30 * time.Second),
 		region:       cfg.AWSRegion,
 		secretPrefix: cfg.AWSSecretPrefix,
 	}
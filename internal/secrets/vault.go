@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/rjsadow/sortie/internal/offline"
 )
 
 // VaultProvider reads secrets from HashiCorp Vault.
@@ -29,9 +31,7 @@ func NewVaultProvider(cfg *Config) (*VaultProvider, error) {
 	addr := strings.TrimSuffix(cfg.VaultAddr, "/")
 
 	p := &VaultProvider{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		client:    offline.Client(30 * time.Second),
 		addr:      addr,
 		token:     cfg.VaultToken,
 		mountPath: cfg.VaultMountPath,
@@ -1,23 +1,65 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"io"
 	"io/fs"
 	"log/slog"
+	"math"
 	"net/http"
 	"os"
+	"reflect"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/rjsadow/sortie/internal/attestation"
+	"github.com/rjsadow/sortie/internal/branding"
+	"github.com/rjsadow/sortie/internal/capacity"
+	"github.com/rjsadow/sortie/internal/chaos"
+	"github.com/rjsadow/sortie/internal/compliance"
 	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/email"
+	"github.com/rjsadow/sortie/internal/guacamole"
+	"github.com/rjsadow/sortie/internal/healthwatch"
+	"github.com/rjsadow/sortie/internal/i18n"
+	"github.com/rjsadow/sortie/internal/icons"
+	"github.com/rjsadow/sortie/internal/jobs"
+	"github.com/rjsadow/sortie/internal/k8s"
+	"github.com/rjsadow/sortie/internal/lint"
 	"github.com/rjsadow/sortie/internal/middleware"
 	"github.com/rjsadow/sortie/internal/plugins"
 	"github.com/rjsadow/sortie/internal/plugins/auth"
+	"github.com/rjsadow/sortie/internal/plugins/sidecar"
+	"github.com/rjsadow/sortie/internal/prepull"
+	"github.com/rjsadow/sortie/internal/presence"
+	"github.com/rjsadow/sortie/internal/runner"
+	"github.com/rjsadow/sortie/internal/schedule"
+	"github.com/rjsadow/sortie/internal/scheduledsessions"
 	"github.com/rjsadow/sortie/internal/sessions"
+	"github.com/rjsadow/sortie/internal/slo"
+	"github.com/rjsadow/sortie/internal/spectate"
+	"github.com/rjsadow/sortie/internal/ssrf"
+	"github.com/rjsadow/sortie/internal/storagebrowser"
+	"github.com/rjsadow/sortie/internal/storagecrypt"
+	"github.com/rjsadow/sortie/internal/streamstats"
+	"github.com/rjsadow/sortie/internal/templatebundle"
+	"github.com/rjsadow/sortie/internal/templatesync"
+	"github.com/rjsadow/sortie/internal/updatecheck"
+	"github.com/rjsadow/sortie/internal/version"
 )
 
 // handlers binds HTTP handler methods to an App's dependencies.
@@ -25,16 +67,46 @@ type handlers struct {
 	app *App
 }
 
-// getRecordingPolicy reads the recording_auto_record setting and returns
-// "auto" when enabled, or "" otherwise.
-func (h *handlers) getRecordingPolicy() string {
-	val, err := h.app.DB.GetSetting("recording_auto_record")
-	if err == nil && val == "true" {
+// recordingPolicy returns "auto" when sessions of app are recorded
+// automatically, or "" otherwise. app may be nil if it has been deleted.
+func (h *handlers) recordingPolicy(app *db.Application) string {
+	if app == nil {
+		app = &db.Application{}
+	}
+	policy, err := h.app.SessionManager.EffectivePolicy(app)
+	if err != nil {
+		slog.Error("error resolving recording policy", "app", app.ID, "error", err)
+		return ""
+	}
+	if policy.RecordingPolicy == db.RecordingPolicyAuto {
 		return "auto"
 	}
 	return ""
 }
 
+// clipboardPolicy returns the clipboard policy of app's sessions, or ""
+// (both ways) if app has been deleted.
+func clipboardPolicy(app *db.Application) db.ClipboardPolicy {
+	if app == nil {
+		return ""
+	}
+	return app.ClipboardPolicy
+}
+
+// fileTransferPolicy returns the file transfer policy of app's sessions, or
+// "" (both ways) if app has been deleted.
+func fileTransferPolicy(app *db.Application) db.FileTransferPolicy {
+	if app == nil {
+		return ""
+	}
+	return app.FileTransfer
+}
+
+// logAudit records an audit log entry tagged with the request's ID.
+func (h *handlers) logAudit(r *http.Request, user, action, details string) error {
+	return h.app.DB.LogAuditRequest(middleware.GetRequestID(r.Context()), user, action, details)
+}
+
 // --- Health endpoints ---
 
 func (h *handlers) handleHealthz(w http.ResponseWriter, r *http.Request) {
@@ -79,6 +151,7 @@ func (h *handlers) handleReadyz(w http.ResponseWriter, r *http.Request) {
 		pluginChecks = append(pluginChecks, entry)
 	}
 	checks["plugins"] = pluginChecks
+	checks["version"] = version.Get()
 
 	bp := h.app.BackpressureHandler
 	if bp != nil && !bp.EnhancedReadinessCheck() {
@@ -94,6 +167,11 @@ func (h *handlers) handleReadyz(w http.ResponseWriter, r *http.Request) {
 			"queue_depth":     loadStatus.QueueDepth,
 		}
 	}
+	if h.app.SessionManager != nil && h.app.SessionManager.ShuttingDown() {
+		// Stop the load balancer sending launches this replica will refuse
+		ready = false
+		checks["sessions"] = map[string]string{"status": "shutting_down"}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if ready {
@@ -106,16 +184,58 @@ func (h *handlers) handleReadyz(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(checks)
 }
 
+// ComponentHealth checks the components /readyz reports on, for the health
+// watcher. Plugins are named by type and name, such as
+// "launcher:kubernetes".
+func (a *App) ComponentHealth(ctx context.Context) []healthwatch.Status {
+	statuses := []healthwatch.Status{{Component: healthwatch.ComponentDatabase, State: healthwatch.StateHealthy}}
+	if err := a.DB.Ping(); err != nil {
+		statuses[0].State = healthwatch.StateUnhealthy
+		statuses[0].Reason = err.Error()
+	}
+
+	for _, ps := range plugins.Global().HealthCheck(ctx) {
+		status := healthwatch.Status{Component: string(ps.PluginType) + ":" + ps.PluginName, State: healthwatch.StateHealthy}
+		if !ps.Healthy {
+			status.State = healthwatch.StateUnhealthy
+			status.Reason = ps.Message
+		}
+		statuses = append(statuses, status)
+	}
+
+	if bp := a.BackpressureHandler; bp != nil {
+		status := healthwatch.Status{Component: "sessions", State: healthwatch.StateHealthy}
+		if !bp.EnhancedReadinessCheck() {
+			load := bp.GetLoadStatus()
+			status.State = healthwatch.StateUnhealthy
+			status.Reason = fmt.Sprintf("overloaded: %d of %d sessions", load.ActiveSessions, load.MaxSessions)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// handleVersion returns the running build's version, commit, and build date.
+func (h *handlers) handleVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(version.Get())
+}
+
 // --- Auth endpoints ---
 
 func (h *handlers) handleLogin(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		middleware.LocalizedError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	if h.app.JWTAuth == nil || h.app.Config.JWTSecret == "" {
-		http.Error(w, "Authentication not configured", http.StatusServiceUnavailable)
+		middleware.LocalizedError(w, r, "Authentication not configured", http.StatusServiceUnavailable)
 		return
 	}
 
@@ -126,38 +246,41 @@ func (h *handlers) handleLogin(w http.ResponseWriter, r *http.Request) {
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		middleware.LocalizedError(w, r, "Failed to read request body", http.StatusBadRequest)
 		return
 	}
 
 	if err := json.Unmarshal(body, &req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		middleware.LocalizedError(w, r, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
 	if req.Username == "" || req.Password == "" {
-		http.Error(w, "Username and password are required", http.StatusBadRequest)
+		middleware.LocalizedError(w, r, "Username and password are required", http.StatusBadRequest)
 		return
 	}
 
-	result, err := h.app.JWTAuth.LoginWithCredentials(r.Context(), req.Username, req.Password)
+	result, err := h.app.JWTAuth.LoginWithCredentials(withClientInfo(r), req.Username, req.Password)
 	if err != nil {
 		slog.Warn("login failed", "username", req.Username, "error", err)
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		if errors.Is(err, auth.ErrAccountDisabled) {
+			middleware.LocalizedError(w, r, "Account disabled", http.StatusForbidden)
+			return
+		}
+		middleware.LocalizedError(w, r, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
 
-	h.app.DB.LogAudit(req.Username, "LOGIN", "User logged in")
+	// Users with MFA finish logging in at /api/auth/mfa/verify
+	if result.MFARequired {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+		return
+	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     middleware.AccessTokenCookieName,
-		Value:    result.AccessToken,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   r.TLS != nil,
-		SameSite: http.SameSiteLaxMode,
-		MaxAge:   int(result.ExpiresIn),
-	})
+	h.logAudit(r, req.Username, "LOGIN", "User logged in")
+
+	h.setAccessTokenCookie(w, r, result.AccessToken, int(result.ExpiresIn))
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
@@ -165,29 +288,45 @@ func (h *handlers) handleLogin(w http.ResponseWriter, r *http.Request) {
 
 func (h *handlers) handleLogout(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		middleware.LocalizedError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
+	h.setAccessTokenCookie(w, r, "", -1)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setAccessTokenCookie sets the cookie that authenticates browser requests
+// that can't carry a header. When Sortie is served from the session domain
+// or one of its subdomains, the cookie is scoped to the domain so session
+// hostnames receive it too.
+func (h *handlers) setAccessTokenCookie(w http.ResponseWriter, r *http.Request, token string, maxAge int) {
+	cookie := &http.Cookie{
 		Name:     middleware.AccessTokenCookieName,
-		Value:    "",
+		Value:    token,
 		Path:     "/",
 		HttpOnly: true,
-		MaxAge:   -1,
-	})
-
-	w.WriteHeader(http.StatusNoContent)
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   maxAge,
+	}
+	if domain := h.app.Config.SessionDomain; domain != "" {
+		if host := requestHost(r); host == domain || strings.HasSuffix(host, "."+domain) {
+			cookie.Domain = domain
+		}
+	}
+	http.SetCookie(w, cookie)
 }
 
 func (h *handlers) handleRefreshToken(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		middleware.LocalizedError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	if h.app.JWTAuth == nil || h.app.Config.JWTSecret == "" {
-		http.Error(w, "Authentication not configured", http.StatusServiceUnavailable)
+		middleware.LocalizedError(w, r, "Authentication not configured", http.StatusServiceUnavailable)
 		return
 	}
 
@@ -197,17 +336,17 @@ func (h *handlers) handleRefreshToken(w http.ResponseWriter, r *http.Request) {
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		middleware.LocalizedError(w, r, "Failed to read request body", http.StatusBadRequest)
 		return
 	}
 
 	if err := json.Unmarshal(body, &req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		middleware.LocalizedError(w, r, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
 	if req.RefreshToken == "" {
-		http.Error(w, "Refresh token is required", http.StatusBadRequest)
+		middleware.LocalizedError(w, r, "Refresh token is required", http.StatusBadRequest)
 		return
 	}
 
@@ -217,19 +356,11 @@ func (h *handlers) handleRefreshToken(w http.ResponseWriter, r *http.Request) {
 	}
 	if err != nil {
 		slog.Warn("token refresh failed", "error", err)
-		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		middleware.LocalizedError(w, r, "Invalid refresh token", http.StatusUnauthorized)
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     middleware.AccessTokenCookieName,
-		Value:    result.AccessToken,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   r.TLS != nil,
-		SameSite: http.SameSiteLaxMode,
-		MaxAge:   int(result.ExpiresIn),
-	})
+	h.setAccessTokenCookie(w, r, result.AccessToken, int(result.ExpiresIn))
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
@@ -237,24 +368,24 @@ func (h *handlers) handleRefreshToken(w http.ResponseWriter, r *http.Request) {
 
 func (h *handlers) handleAuthMe(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		middleware.LocalizedError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	if h.app.JWTAuth == nil || h.app.Config.JWTSecret == "" {
-		http.Error(w, "Authentication not configured", http.StatusServiceUnavailable)
+		middleware.LocalizedError(w, r, "Authentication not configured", http.StatusServiceUnavailable)
 		return
 	}
 
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
-		http.Error(w, "Authorization header required", http.StatusUnauthorized)
+		middleware.LocalizedError(w, r, "Authorization header required", http.StatusUnauthorized)
 		return
 	}
 
 	parts := strings.SplitN(authHeader, " ", 2)
 	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
-		http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
+		middleware.LocalizedError(w, r, "Invalid authorization header format", http.StatusUnauthorized)
 		return
 	}
 
@@ -264,7 +395,7 @@ func (h *handlers) handleAuthMe(w http.ResponseWriter, r *http.Request) {
 		result, err = h.app.OIDCAuth.Authenticate(r.Context(), token)
 	}
 	if err != nil || !result.Authenticated {
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		middleware.LocalizedError(w, r, "Invalid token", http.StatusUnauthorized)
 		return
 	}
 
@@ -280,6 +411,7 @@ func (h *handlers) handleAuthMe(w http.ResponseWriter, r *http.Request) {
 		Groups          []string          `json:"groups,omitempty"`
 		Metadata        map[string]string `json:"metadata,omitempty"`
 		AdminCategories []string          `json:"admin_categories,omitempty"`
+		Locale          string            `json:"locale,omitempty"`
 	}
 
 	resp := meResponse{
@@ -292,11 +424,47 @@ func (h *handlers) handleAuthMe(w http.ResponseWriter, r *http.Request) {
 		Metadata:        result.User.Metadata,
 		AdminCategories: adminCats,
 	}
+	// Read the locale from the database; the token's copy may predate a change
+	if dbUser, err := h.app.DB.GetUserByID(result.User.ID); err == nil && dbUser != nil {
+		resp.Locale = dbUser.Locale
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
+// handleAuthMeLocale serves PUT /api/auth/me/locale, which saves the current
+// user's preferred language for server-generated text. An empty locale
+// clears the preference. Tokens issued afterwards carry the new locale.
+func (h *handlers) handleAuthMeLocale(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		middleware.LocalizedError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Locale string `json:"locale"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		middleware.LocalizedError(w, r, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Locale != "" && !i18n.IsSupported(req.Locale) {
+		http.Error(w, middleware.Localizer(r).T("Unsupported locale: %s", req.Locale), http.StatusBadRequest)
+		return
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	if err := h.app.DB.SetUserLocale(user.ID, req.Locale); err != nil {
+		slog.Error("error saving user locale", "user_id", user.ID, "error", err)
+		middleware.LocalizedError(w, r, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"locale": req.Locale})
+}
+
 func (h *handlers) handleUsersList(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -332,17 +500,17 @@ func (h *handlers) isRegistrationAllowed() bool {
 
 func (h *handlers) handleRegister(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		middleware.LocalizedError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	if h.app.JWTAuth == nil || h.app.Config.JWTSecret == "" {
-		http.Error(w, "Authentication not configured", http.StatusServiceUnavailable)
+		middleware.LocalizedError(w, r, "Authentication not configured", http.StatusServiceUnavailable)
 		return
 	}
 
 	if !h.isRegistrationAllowed() {
-		http.Error(w, "Registration is not enabled", http.StatusForbidden)
+		middleware.LocalizedError(w, r, "Registration is not enabled", http.StatusForbidden)
 		return
 	}
 
@@ -355,45 +523,35 @@ func (h *handlers) handleRegister(w http.ResponseWriter, r *http.Request) {
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		middleware.LocalizedError(w, r, "Failed to read request body", http.StatusBadRequest)
 		return
 	}
 
 	if err := json.Unmarshal(body, &req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		middleware.LocalizedError(w, r, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
 	if req.Username == "" || req.Password == "" {
-		http.Error(w, "Username and password are required", http.StatusBadRequest)
+		middleware.LocalizedError(w, r, "Username and password are required", http.StatusBadRequest)
 		return
 	}
 
 	if req.Email == "" {
-		http.Error(w, "Email is required", http.StatusBadRequest)
+		middleware.LocalizedError(w, r, "Email is required", http.StatusBadRequest)
 		return
 	}
 
 	if len(req.Password) < 6 {
-		http.Error(w, "Password must be at least 6 characters", http.StatusBadRequest)
-		return
-	}
-
-	existing, err := h.app.DB.GetUserByUsername(req.Username)
-	if err != nil {
-		slog.Error("error checking username", "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-	if existing != nil {
-		http.Error(w, "Username already taken", http.StatusConflict)
+		middleware.LocalizedError(w, r, "Password must be at least 6 characters", http.StatusBadRequest)
 		return
 	}
 
+	// Hash before taking the transaction, which holds the write lock
 	passwordHash, err := auth.HashPassword(req.Password)
 	if err != nil {
 		slog.Error("error hashing password", "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		middleware.LocalizedError(w, r, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
@@ -406,15 +564,24 @@ func (h *handlers) handleRegister(w http.ResponseWriter, r *http.Request) {
 		Roles:        []string{"user"},
 	}
 
-	if err := h.app.DB.CreateUser(user); err != nil {
-		slog.Error("error creating user", "error", err)
-		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+	err = h.app.DB.WithTx(func(tx *db.DB) error {
+		existing, err := tx.GetUserByUsername(req.Username)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return &httpError{http.StatusConflict, "Username already taken"}
+		}
+		return tx.CreateUser(user)
+	})
+	if err != nil {
+		writeTxError(w, r, err, "creating user")
 		return
 	}
 
-	h.app.DB.LogAudit(req.Username, "REGISTER", "User registered")
+	h.logAudit(r, req.Username, "REGISTER", "User registered")
 
-	result, err := h.app.JWTAuth.LoginWithCredentials(r.Context(), req.Username, req.Password)
+	result, err := h.app.JWTAuth.LoginWithCredentials(withClientInfo(r), req.Username, req.Password)
 	if err != nil {
 		slog.Error("error generating tokens after registration", "error", err)
 		w.WriteHeader(http.StatusCreated)
@@ -427,384 +594,398 @@ func (h *handlers) handleRegister(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(result)
 }
 
-// --- OIDC endpoints ---
+// --- Device (refresh token) management ---
 
-func (h *handlers) handleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+// withClientInfo returns the request context annotated with the caller's user
+// agent and IP so auth providers can record them against the refresh token.
+func withClientInfo(r *http.Request) context.Context {
+	return auth.WithClientInfo(r.Context(), auth.ClientInfo{
+		UserAgent: r.UserAgent(),
+		IPAddress: middleware.ClientIP(r),
+	})
+}
+
+// handleDevices lists the current user's signed-in devices, one per
+// outstanding refresh token.
+func (h *handlers) handleDevices(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	if h.app.OIDCAuth == nil {
-		http.Error(w, "SSO is not configured", http.StatusServiceUnavailable)
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
-
-	redirectURL := r.URL.Query().Get("redirect")
-	if redirectURL == "" {
-		redirectURL = "/"
+	if user.Metadata[auth.MetadataAPITokenID] != "" {
+		http.Error(w, "API tokens cannot manage devices", http.StatusForbidden)
+		return
 	}
 
-	loginURL := h.app.OIDCAuth.GetLoginURL(redirectURL)
-	if loginURL == "" {
-		http.Error(w, "Failed to generate login URL", http.StatusInternalServerError)
+	tokens, err := h.app.DB.ListRefreshTokensByUser(user.ID)
+	if err != nil {
+		slog.Error("error listing devices", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	http.Redirect(w, r, loginURL, http.StatusFound)
-}
+	type deviceResponse struct {
+		ID         string     `json:"id"`
+		UserAgent  string     `json:"user_agent"`
+		IPAddress  string     `json:"ip_address"`
+		IssuedAt   time.Time  `json:"issued_at"`
+		LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+		ExpiresAt  time.Time  `json:"expires_at"`
+		Current    bool       `json:"current"`
+	}
 
-func (h *handlers) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+	currentID := user.Metadata["device_id"]
+	response := make([]deviceResponse, len(tokens))
+	for i, t := range tokens {
+		response[i] = deviceResponse{
+			ID:         t.ID,
+			UserAgent:  t.UserAgent,
+			IPAddress:  t.IPAddress,
+			IssuedAt:   t.CreatedAt,
+			LastUsedAt: t.LastUsedAt,
+			ExpiresAt:  t.ExpiresAt,
+			Current:    t.ID == currentID,
+		}
 	}
 
-	if h.app.OIDCAuth == nil {
-		http.Error(w, "SSO is not configured", http.StatusServiceUnavailable)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleDeviceByID revokes one of the current user's devices. The device can
+// no longer refresh its access token; its current access token remains valid
+// until it expires.
+func (h *handlers) handleDeviceByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	if errParam := r.URL.Query().Get("error"); errParam != "" {
-		errDesc := r.URL.Query().Get("error_description")
-		slog.Warn("OIDC callback error", "error", errParam, "description", errDesc)
-		http.Error(w, fmt.Sprintf("SSO error: %s", errDesc), http.StatusBadRequest)
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
-
-	code := r.URL.Query().Get("code")
-	state := r.URL.Query().Get("state")
-	if code == "" || state == "" {
-		http.Error(w, "Missing code or state parameter", http.StatusBadRequest)
+	if user.Metadata[auth.MetadataAPITokenID] != "" {
+		http.Error(w, "API tokens cannot manage devices", http.StatusForbidden)
 		return
 	}
 
-	result, err := h.app.OIDCAuth.HandleCallback(r.Context(), code, state)
-	if err != nil {
-		slog.Error("OIDC callback failed", "error", err)
-		http.Error(w, "SSO authentication failed", http.StatusUnauthorized)
+	id := strings.TrimPrefix(r.URL.Path, "/api/auth/devices/")
+	if id == "" {
+		http.Error(w, "Device ID required", http.StatusBadRequest)
 		return
 	}
 
-	if !result.Authenticated || result.User == nil {
-		http.Error(w, "SSO authentication failed", http.StatusUnauthorized)
+	if err := h.app.DB.DeleteUserRefreshToken(user.ID, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Device not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("error revoking device", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	accessToken := result.Token
-	refreshToken := result.Message
-
-	h.app.DB.LogAudit(result.User.Username, "SSO_LOGIN", "User logged in via OIDC SSO")
-
-	http.SetCookie(w, &http.Cookie{
-		Name:     middleware.AccessTokenCookieName,
-		Value:    accessToken,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   r.TLS != nil,
-		SameSite: http.SameSiteLaxMode,
-		MaxAge:   int(h.app.Config.JWTAccessExpiry.Seconds()),
-	})
-
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	fmt.Fprintf(w, `<!DOCTYPE html>
-<html>
-<head><title>SSO Login</title></head>
-<body>
-<script>
-localStorage.setItem('sortie-access-token', %q);
-localStorage.setItem('sortie-refresh-token', %q);
-localStorage.setItem('sortie-user', JSON.stringify(%s));
-window.location.href = '/';
-</script>
-<noscript><p>Login successful. <a href="/">Click here</a> to continue.</p></noscript>
-</body>
-</html>`, accessToken, refreshToken, mustJSON(result.User))
-}
+	h.logAudit(r, user.Username, "REVOKE_DEVICE", fmt.Sprintf("Revoked device %s", id))
 
-func mustJSON(v any) string {
-	b, err := json.Marshal(v)
-	if err != nil {
-		return "{}"
-	}
-	return string(b)
+	w.WriteHeader(http.StatusNoContent)
 }
 
-// --- Config endpoint ---
-
-// BrandingConfig represents tenant branding configuration.
-type BrandingConfig struct {
-	LogoURL           string `json:"logo_url"`
-	PrimaryColor      string `json:"primary_color"`
-	SecondaryColor    string `json:"secondary_color"`
-	TenantName        string `json:"tenant_name"`
-	AllowRegistration bool   `json:"allow_registration"`
-	SSOEnabled        bool   `json:"sso_enabled"`
-}
+// --- API token (personal access token) management ---
 
-func (h *handlers) handleConfig(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+// API token lifetimes, in days.
+const (
+	defaultAPITokenDays = 30
+	maxAPITokenDays     = 365
+)
 
-	brandingCfg := BrandingConfig{
-		LogoURL:           h.app.Config.LogoURL,
-		PrimaryColor:      h.app.Config.PrimaryColor,
-		SecondaryColor:    h.app.Config.SecondaryColor,
-		TenantName:        h.app.Config.TenantName,
-		AllowRegistration: h.isRegistrationAllowed(),
+// apiTokenUser returns the user managing their API tokens. Requests made
+// with an API token are refused, so a token cannot mint one with more
+// scopes or a later expiry.
+func apiTokenUser(w http.ResponseWriter, r *http.Request) *plugins.User {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil
 	}
-
-	if data, err := os.ReadFile(h.app.Config.BrandingConfigPath); err == nil {
-		json.Unmarshal(data, &brandingCfg)
+	if user.Metadata[auth.MetadataAPITokenID] != "" {
+		http.Error(w, "API tokens cannot manage API tokens", http.StatusForbidden)
+		return nil
 	}
-
-	brandingCfg.AllowRegistration = h.isRegistrationAllowed()
-	brandingCfg.SSOEnabled = h.app.OIDCAuth != nil
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(brandingCfg)
+	return user
 }
 
-// --- App CRUD ---
-
-func (h *handlers) handleApps(w http.ResponseWriter, r *http.Request) {
+// handleAPITokens lists the current user's API tokens and mints new ones.
+func (h *handlers) handleAPITokens(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		user := middleware.GetUserFromContext(r.Context())
-		userID := ""
-		var userRoles []string
-		if user != nil {
-			userID = user.ID
-			userRoles = user.Roles
+		user := apiTokenUser(w, r)
+		if user == nil {
+			return
 		}
-		tenantID := middleware.GetTenantIDFromContext(r.Context())
-
-		apps, err := h.app.DB.ListAppsForUser(userID, userRoles, tenantID)
+		tokens, err := h.app.DB.ListAPITokensByUser(user.ID)
 		if err != nil {
-			slog.Error("error listing apps", "error", err)
+			slog.Error("error listing API tokens", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
-
-		if apps == nil {
-			apps = []db.Application{}
+		response := make([]apiTokenResponse, len(tokens))
+		for i, t := range tokens {
+			response[i] = newAPITokenResponse(t)
 		}
-
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(apps)
+		json.NewEncoder(w).Encode(response)
 
 	case http.MethodPost:
-		user := middleware.GetUserFromContext(r.Context())
-		// Check category admin permission for the app's category
-		isCatAdmin := false
-		// We'll check after parsing the body so we know the category
-		var app db.Application
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		user := apiTokenUser(w, r)
+		if user == nil {
 			return
 		}
 
-		if err := json.Unmarshal(body, &app); err != nil {
+		var req struct {
+			Name          string   `json:"name"`
+			Scopes        []string `json:"scopes"`
+			ExpiresInDays int      `json:"expires_in_days"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "Invalid JSON", http.StatusBadRequest)
 			return
 		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" || len(req.Name) > 100 {
+			http.Error(w, "name is required and must be at most 100 characters", http.StatusBadRequest)
+			return
+		}
+		if req.ExpiresInDays == 0 {
+			req.ExpiresInDays = defaultAPITokenDays
+		}
+		if req.ExpiresInDays < 1 || req.ExpiresInDays > maxAPITokenDays {
+			http.Error(w, fmt.Sprintf("expires_in_days must be between 1 and %d", maxAPITokenDays), http.StatusBadRequest)
+			return
+		}
 
-		if app.Visibility == "" {
-			app.Visibility = db.CategoryVisibilityPublic
+		dbUser, err := h.app.DB.GetUserByID(user.ID)
+		if err != nil || dbUser == nil {
+			slog.Error("error getting user for API token", "user_id", user.ID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
 		}
 
-		if app.Category != "" {
-			if cat, _ := h.app.DB.GetCategoryByName(app.Category); cat != nil {
-				isCatAdmin, _ = h.app.DB.IsCategoryAdmin(user.ID, cat.ID)
-			}
-		}
-
-		if !middleware.HasRole(user.Roles, middleware.RoleAdmin, middleware.RoleAppAuthor) && !isCatAdmin {
-			http.Error(w, "Insufficient permissions", http.StatusForbidden)
-			return
-		}
-
-		if app.ID == "" || app.Name == "" {
-			http.Error(w, "Missing required fields: id, name", http.StatusBadRequest)
-			return
-		}
-
-		if app.LaunchType == db.LaunchTypeContainer || app.LaunchType == db.LaunchTypeWebProxy {
-			if app.ContainerImage == "" {
-				http.Error(w, "Missing required field for container/web_proxy app: container_image", http.StatusBadRequest)
-				return
-			}
-		} else if app.URL == "" {
-			http.Error(w, "Missing required field: url", http.StatusBadRequest)
+		token, record, err := auth.CreateAPIToken(h.app.DB, dbUser, req.Name, req.Scopes,
+			time.Duration(req.ExpiresInDays)*24*time.Hour)
+		if errors.Is(err, auth.ErrInvalidScope) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-
-		// Auto-create category if it doesn't exist (backwards compat)
-		if app.Category != "" {
-			tenantID := middleware.GetTenantIDFromContext(r.Context())
-			h.app.DB.EnsureCategoryExists(app.Category, tenantID)
-		}
-
-		if err := h.app.DB.CreateApp(app); err != nil {
-			if db.IsDuplicateKeyError(err) {
-				http.Error(w, "Application with this ID already exists", http.StatusConflict)
-				return
-			}
-			slog.Error("error creating app", "error", err)
+		if err != nil {
+			slog.Error("error creating API token", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		details := fmt.Sprintf("Created app: %s (%s)", app.Name, app.ID)
-		h.app.DB.LogAudit(user.Username, "CREATE_APP", details)
+		h.logAudit(r, user.Username, "CREATE_API_TOKEN",
+			fmt.Sprintf("Created API token %s (%s) with scopes %s", record.ID, record.Name, record.Scopes))
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(app)
+		json.NewEncoder(w).Encode(struct {
+			apiTokenResponse
+			Token string `json:"token"`
+		}{newAPITokenResponse(*record), token})
 
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
-func (h *handlers) handleAppByID(w http.ResponseWriter, r *http.Request) {
-	id := strings.TrimPrefix(r.URL.Path, "/api/apps/")
+// handleAPITokenByID revokes one of the current user's API tokens.
+func (h *handlers) handleAPITokenByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := apiTokenUser(w, r)
+	if user == nil {
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/auth/tokens/")
 	if id == "" {
-		http.Error(w, "Missing app ID", http.StatusBadRequest)
+		http.Error(w, "Token ID required", http.StatusBadRequest)
 		return
 	}
 
-	switch r.Method {
-	case http.MethodGet:
-		app, err := h.app.DB.GetApp(id)
-		if err != nil {
-			slog.Error("error getting app", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-		if app == nil {
-			http.Error(w, "Application not found", http.StatusNotFound)
+	if err := h.app.DB.DeleteUserAPIToken(user.ID, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Token not found", http.StatusNotFound)
 			return
 		}
+		slog.Error("error revoking API token", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(app)
+	h.logAudit(r, user.Username, "REVOKE_API_TOKEN", fmt.Sprintf("Revoked API token %s", id))
 
-	case http.MethodPut:
-		user := middleware.GetUserFromContext(r.Context())
+	w.WriteHeader(http.StatusNoContent)
+}
 
-		var app db.Application
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, "Failed to read request body", http.StatusBadRequest)
-			return
-		}
+// apiTokenResponse describes an API token without its secret.
+type apiTokenResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+}
 
-		if err := json.Unmarshal(body, &app); err != nil {
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
-			return
-		}
+func newAPITokenResponse(t db.APIToken) apiTokenResponse {
+	return apiTokenResponse{
+		ID:         t.ID,
+		Name:       t.Name,
+		Prefix:     t.Prefix,
+		Scopes:     strings.Split(t.Scopes, ","),
+		CreatedAt:  t.CreatedAt,
+		LastUsedAt: t.LastUsedAt,
+		ExpiresAt:  t.ExpiresAt,
+	}
+}
 
-		app.ID = id
-		if app.Visibility == "" {
-			app.Visibility = db.CategoryVisibilityPublic
-		}
+// --- Multi-factor authentication (TOTP) ---
 
-		// Check category admin for the app's category
-		isCatAdmin := false
-		catName := app.Category
-		if catName == "" {
-			// Check existing app's category
-			if existing, _ := h.app.DB.GetApp(id); existing != nil {
-				catName = existing.Category
-			}
+// mfaUser returns the local account an MFA request is for. During login
+// the caller proves the password step with the MFA token from
+// /api/auth/login; otherwise they must be logged in, and not with an API
+// token. fromLogin reports which.
+func (h *handlers) mfaUser(w http.ResponseWriter, r *http.Request, mfaToken string) (user *db.User, fromLogin bool) {
+	if mfaToken != "" {
+		if h.app.JWTAuth == nil {
+			middleware.LocalizedError(w, r, "Authentication not configured", http.StatusServiceUnavailable)
+			return nil, false
 		}
-		if catName != "" {
-			if cat, _ := h.app.DB.GetCategoryByName(catName); cat != nil {
-				isCatAdmin, _ = h.app.DB.IsCategoryAdmin(user.ID, cat.ID)
-			}
+		user, err := h.app.JWTAuth.MFATokenUser(mfaToken)
+		if err != nil {
+			writeMFAError(w, r, err)
+			return nil, false
 		}
+		return user, true
+	}
 
-		if !middleware.HasRole(user.Roles, middleware.RoleAdmin, middleware.RoleAppAuthor) && !isCatAdmin {
-			http.Error(w, "Insufficient permissions", http.StatusForbidden)
-			return
-		}
+	current := middleware.GetUserFromContext(r.Context())
+	if current == nil {
+		middleware.LocalizedError(w, r, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	if current.Metadata[auth.MetadataAPITokenID] != "" {
+		http.Error(w, "API tokens cannot manage MFA", http.StatusForbidden)
+		return nil, false
+	}
+	user, err := h.app.DB.GetUserByID(current.ID)
+	if err != nil || user == nil {
+		slog.Error("error getting user for MFA", "user_id", current.ID, "error", err)
+		middleware.LocalizedError(w, r, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if user.AuthProvider != "local" {
+		http.Error(w, "MFA for SSO accounts is managed by the identity provider", http.StatusBadRequest)
+		return nil, false
+	}
+	return user, false
+}
 
-		if app.Name == "" {
-			http.Error(w, "Missing required field: name", http.StatusBadRequest)
+// writeMFAError replies with the status for an error from the auth
+// package's MFA functions.
+func writeMFAError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidMFAToken):
+		middleware.LocalizedError(w, r, "Invalid or expired MFA token", http.StatusUnauthorized)
+	case errors.Is(err, auth.ErrInvalidMFACode):
+		middleware.LocalizedError(w, r, "Invalid code", http.StatusUnauthorized)
+	case errors.Is(err, auth.ErrMFANotEnrolled):
+		http.Error(w, "MFA setup has not been started", http.StatusBadRequest)
+	case errors.Is(err, auth.ErrAccountDisabled):
+		middleware.LocalizedError(w, r, "Account disabled", http.StatusForbidden)
+	default:
+		slog.Error("MFA error", "error", err)
+		middleware.LocalizedError(w, r, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// handleMFA reports the current user's MFA status and disables MFA.
+// Disabling takes a current code and is refused when the user's tenant
+// requires MFA.
+func (h *handlers) handleMFA(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		user, _ := h.mfaUser(w, r, "")
+		if user == nil {
 			return
 		}
-
-		if app.LaunchType == db.LaunchTypeContainer || app.LaunchType == db.LaunchTypeWebProxy {
-			if app.ContainerImage == "" {
-				http.Error(w, "Missing required field for container/web_proxy app: container_image", http.StatusBadRequest)
-				return
-			}
-		} else if app.URL == "" {
-			http.Error(w, "Missing required field: url", http.StatusBadRequest)
+		mfa, err := h.app.DB.GetUserMFA(user.ID)
+		if err != nil {
+			writeMFAError(w, r, err)
 			return
 		}
-
-		// Auto-create category if it doesn't exist
-		if app.Category != "" {
-			tenantID := middleware.GetTenantIDFromContext(r.Context())
-			h.app.DB.EnsureCategoryExists(app.Category, tenantID)
+		required, err := auth.MFARequired(h.app.DB, user)
+		if err != nil {
+			writeMFAError(w, r, err)
+			return
 		}
-
-		if err := h.app.DB.UpdateApp(app); err != nil {
-			if err.Error() == "sql: no rows in result set" {
-				http.Error(w, "Application not found", http.StatusNotFound)
+		remaining := 0
+		if mfa.Enabled() {
+			if remaining, err = h.app.DB.CountMFABackupCodes(user.ID); err != nil {
+				writeMFAError(w, r, err)
 				return
 			}
-			slog.Error("error updating app", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
 		}
-
-		details := fmt.Sprintf("Updated app: %s (%s)", app.Name, app.ID)
-		h.app.DB.LogAudit(user.Username, "UPDATE_APP", details)
-
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(app)
+		json.NewEncoder(w).Encode(map[string]any{
+			"enabled":                mfa.Enabled(),
+			"required":               required,
+			"backup_codes_remaining": remaining,
+		})
 
 	case http.MethodDelete:
-		user := middleware.GetUserFromContext(r.Context())
-
-		app, err := h.app.DB.GetApp(id)
-		if err != nil {
-			slog.Error("error getting app", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		user, _ := h.mfaUser(w, r, "")
+		if user == nil {
 			return
 		}
-		if app == nil {
-			http.Error(w, "Application not found", http.StatusNotFound)
+		var req struct {
+			Code string `json:"code"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+			http.Error(w, "code is required", http.StatusBadRequest)
 			return
 		}
-
-		// Check category admin for the app's category
-		isCatAdmin := false
-		if app.Category != "" {
-			if cat, _ := h.app.DB.GetCategoryByName(app.Category); cat != nil {
-				isCatAdmin, _ = h.app.DB.IsCategoryAdmin(user.ID, cat.ID)
-			}
+		required, err := auth.MFARequired(h.app.DB, user)
+		if err != nil {
+			writeMFAError(w, r, err)
+			return
 		}
-
-		if !middleware.HasRole(user.Roles, middleware.RoleAdmin, middleware.RoleAppAuthor) && !isCatAdmin {
-			http.Error(w, "Insufficient permissions", http.StatusForbidden)
+		if required {
+			http.Error(w, "MFA is required for your account", http.StatusForbidden)
 			return
 		}
-
-		if err := h.app.DB.DeleteApp(id); err != nil {
-			slog.Error("error deleting app", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		if err := auth.VerifyMFACode(h.app.DB, user.ID, req.Code); err != nil {
+			writeMFAError(w, r, err)
+			return
+		}
+		if err := h.app.DB.DeleteUserMFA(user.ID); err != nil {
+			writeMFAError(w, r, err)
 			return
 		}
 
-		details := fmt.Sprintf("Deleted app: %s (%s)", app.Name, id)
-		h.app.DB.LogAudit(user.Username, "DELETE_APP", details)
+		h.logAudit(r, user.Username, "DISABLE_MFA", "Disabled MFA")
 
 		w.WriteHeader(http.StatusNoContent)
 
@@ -813,398 +994,6415 @@ func (h *handlers) handleAppByID(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// --- AppSpec CRUD ---
+// handleMFASetup starts TOTP enrollment, returning a new secret for the
+// user's authenticator app. It is refused while MFA is enabled: users
+// disable it first, so a stolen password alone cannot replace their
+// authenticator.
+func (h *handlers) handleMFASetup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-func (h *handlers) handleAppSpecs(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		specs, err := h.app.DB.ListAppSpecs()
-		if err != nil {
-			slog.Error("error listing app specs", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+	var req struct {
+		MFAToken string `json:"mfa_token"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
 			return
 		}
+	}
+	user, _ := h.mfaUser(w, r, req.MFAToken)
+	if user == nil {
+		return
+	}
 
-		if specs == nil {
-			specs = []db.AppSpec{}
-		}
+	existing, err := h.app.DB.GetUserMFA(user.ID)
+	if err != nil {
+		writeMFAError(w, r, err)
+		return
+	}
+	if existing.Enabled() {
+		http.Error(w, "MFA is already enabled", http.StatusConflict)
+		return
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(specs)
+	secret, err := auth.StartMFAEnrollment(h.app.DB, user.ID)
+	if err != nil {
+		writeMFAError(w, r, err)
+		return
+	}
 
-	case http.MethodPost:
-		user := middleware.GetUserFromContext(r.Context())
-		if !middleware.HasRole(user.Roles, middleware.RoleAdmin, middleware.RoleAppAuthor) {
-			http.Error(w, "Insufficient permissions", http.StatusForbidden)
-			return
-		}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret":      secret,
+		"otpauth_url": auth.TOTPURI(h.app.Config.TenantName, user.Username, secret),
+	})
+}
 
-		var spec db.AppSpec
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, "Failed to read request body", http.StatusBadRequest)
-			return
-		}
+// handleMFAVerify checks a code. With an MFA token from /api/auth/login it
+// is the second login step: it confirms a pending enrollment or checks the
+// code of an enrolled user, then logs them in. Without one it confirms the
+// logged-in user's pending enrollment. Confirming an enrollment returns the
+// user's backup codes, which are not shown again.
+func (h *handlers) handleMFAVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-		if err := json.Unmarshal(body, &spec); err != nil {
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
-			return
-		}
+	var req struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Code == "" {
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	}
+	user, fromLogin := h.mfaUser(w, r, req.MFAToken)
+	if user == nil {
+		return
+	}
 
-		if spec.ID == "" || spec.Name == "" || spec.Image == "" {
-			http.Error(w, "Missing required fields: id, name, image", http.StatusBadRequest)
-			return
-		}
+	mfa, err := h.app.DB.GetUserMFA(user.ID)
+	if err != nil {
+		writeMFAError(w, r, err)
+		return
+	}
 
-		if err := h.app.DB.CreateAppSpec(spec); err != nil {
-			if db.IsDuplicateKeyError(err) {
-				http.Error(w, "AppSpec with this ID already exists", http.StatusConflict)
-				return
-			}
-			slog.Error("error creating app spec", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+	var backupCodes []string
+	if !mfa.Enabled() {
+		backupCodes, err = auth.ConfirmMFAEnrollment(h.app.DB, user.ID, req.Code)
+		if err != nil {
+			writeMFAError(w, r, err)
 			return
 		}
-
-		h.app.DB.LogAudit(user.Username, "CREATE_APPSPEC", fmt.Sprintf("Created app spec: %s (%s)", spec.Name, spec.ID))
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(spec)
-
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		h.logAudit(r, user.Username, "ENABLE_MFA", "Enabled MFA")
+	} else if !fromLogin {
+		http.Error(w, "MFA is already enabled", http.StatusConflict)
+		return
 	}
-}
 
-func (h *handlers) handleAppSpecByID(w http.ResponseWriter, r *http.Request) {
-	id := strings.TrimPrefix(r.URL.Path, "/api/appspecs/")
-	if id == "" {
-		http.Error(w, "Missing app spec ID", http.StatusBadRequest)
+	if !fromLogin {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string][]string{"backup_codes": backupCodes})
+		return
+	}
+
+	var result *auth.LoginResult
+	if backupCodes != nil {
+		result, err = h.app.JWTAuth.IssueTokens(withClientInfo(r), user)
+	} else {
+		result, err = h.app.JWTAuth.CompleteMFALogin(withClientInfo(r), req.MFAToken, req.Code)
+	}
+	if err != nil {
+		slog.Warn("MFA login failed", "username", user.Username, "error", err)
+		writeMFAError(w, r, err)
+		return
+	}
+
+	h.logAudit(r, user.Username, "LOGIN", "User logged in with MFA")
+
+	h.setAccessTokenCookie(w, r, result.AccessToken, int(result.ExpiresIn))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		*auth.LoginResult
+		BackupCodes []string `json:"backup_codes,omitempty"`
+	}{result, backupCodes})
+}
+
+// handleMFABackupCodes replaces the current user's backup codes. It takes
+// a current TOTP or backup code.
+func (h *handlers) handleMFABackupCodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, _ := h.mfaUser(w, r, "")
+	if user == nil {
+		return
+	}
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	}
+	if err := auth.VerifyMFACode(h.app.DB, user.ID, req.Code); err != nil {
+		writeMFAError(w, r, err)
+		return
+	}
+
+	codes, hashes, err := auth.GenerateBackupCodes()
+	if err != nil {
+		writeMFAError(w, r, err)
+		return
+	}
+	if err := h.app.DB.ReplaceMFABackupCodes(user.ID, hashes); err != nil {
+		writeMFAError(w, r, err)
+		return
+	}
+
+	h.logAudit(r, user.Username, "REGENERATE_MFA_BACKUP_CODES", "Regenerated MFA backup codes")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"backup_codes": codes})
+}
+
+// --- Passkeys (WebAuthn) ---
+
+// passkeysConfigured reports whether the relying party ID and origins
+// passkeys need are configured. Neither is ever taken from the request,
+// whose Host header a client controls.
+func (h *handlers) passkeysConfigured() bool {
+	return h.app.Config.WebAuthnRPID != "" && len(h.app.Config.WebAuthnOrigins) > 0
+}
+
+// passkeysAllowed reports whether admins turned on passkey sign-in, and it
+// is configured.
+func (h *handlers) passkeysAllowed() bool {
+	if !h.passkeysConfigured() {
+		return false
+	}
+	if dbSetting, err := h.app.DB.GetSetting("allow_passkeys"); err == nil && dbSetting != "" {
+		return strings.EqualFold(dbSetting, "true") || dbSetting == "1"
+	}
+	return false
+}
+
+// relyingParty returns the configured WebAuthn relying party.
+func (h *handlers) relyingParty() auth.RelyingParty {
+	rp := auth.RelyingParty{
+		ID:      h.app.Config.WebAuthnRPID,
+		Name:    h.app.Config.TenantName,
+		Origins: h.app.Config.WebAuthnOrigins,
+	}
+	if rp.Name == "" {
+		rp.Name = "Sortie"
+	}
+	return rp
+}
+
+// passkeyUser returns the logged-in local user managing their passkeys, or
+// writes an error and returns nil.
+func (h *handlers) passkeyUser(w http.ResponseWriter, r *http.Request) *db.User {
+	current := middleware.GetUserFromContext(r.Context())
+	if current == nil {
+		middleware.LocalizedError(w, r, "Unauthorized", http.StatusUnauthorized)
+		return nil
+	}
+	if current.Metadata[auth.MetadataAPITokenID] != "" {
+		http.Error(w, "API tokens cannot manage passkeys", http.StatusForbidden)
+		return nil
+	}
+	user, err := h.app.DB.GetUserByID(current.ID)
+	if err != nil || user == nil {
+		slog.Error("error getting user for passkeys", "user_id", current.ID, "error", err)
+		middleware.LocalizedError(w, r, "Internal server error", http.StatusInternalServerError)
+		return nil
+	}
+	if user.AuthProvider != "local" {
+		http.Error(w, "Passkeys are only available for local accounts", http.StatusBadRequest)
+		return nil
+	}
+	return user
+}
+
+// writePasskeyError replies with the status for an error from the auth
+// package's passkey functions.
+func writePasskeyError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidPasskey):
+		http.Error(w, "Passkey verification failed", http.StatusUnauthorized)
+	case errors.Is(err, auth.ErrUnsupportedPasskey):
+		http.Error(w, "Unsupported passkey type", http.StatusBadRequest)
+	case errors.Is(err, auth.ErrAccountDisabled):
+		middleware.LocalizedError(w, r, "Account disabled", http.StatusForbidden)
+	default:
+		slog.Error("passkey error", "error", err)
+		middleware.LocalizedError(w, r, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// handlePasskeys lists the current user's passkeys. Listing works while
+// passkey sign-in is disabled, so users can still remove old passkeys.
+func (h *handlers) handlePasskeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := h.passkeyUser(w, r)
+	if user == nil {
+		return
+	}
+	creds, err := h.app.DB.ListWebAuthnCredentials(user.ID)
+	if err != nil {
+		writePasskeyError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(creds)
+}
+
+// handlePasskeyByID removes one of the current user's passkeys.
+func (h *handlers) handlePasskeyByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := h.passkeyUser(w, r)
+	if user == nil {
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/auth/passkeys/")
+	if id == "" {
+		http.Error(w, "Passkey ID required", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := h.app.DB.DeleteWebAuthnCredential(user.ID, id)
+	if err != nil {
+		writePasskeyError(w, r, err)
+		return
+	}
+	if !deleted {
+		http.Error(w, "Passkey not found", http.StatusNotFound)
+		return
+	}
+
+	h.logAudit(r, user.Username, "REMOVE_PASSKEY", fmt.Sprintf("Removed passkey %s", id))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlePasskeyRegisterOptions starts registering a passkey for the
+// current user and returns the options for navigator.credentials.create.
+func (h *handlers) handlePasskeyRegisterOptions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.passkeysAllowed() {
+		http.Error(w, "Passkeys are not enabled", http.StatusForbidden)
+		return
+	}
+
+	user := h.passkeyUser(w, r)
+	if user == nil {
+		return
+	}
+	opts, err := auth.BeginPasskeyRegistration(h.app.DB, h.relyingParty(), user)
+	if err != nil {
+		writePasskeyError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(opts)
+}
+
+// handlePasskeyRegister verifies the browser's response to the
+// registration options and stores the new passkey.
+func (h *handlers) handlePasskeyRegister(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.passkeysAllowed() {
+		http.Error(w, "Passkeys are not enabled", http.StatusForbidden)
+		return
+	}
+
+	user := h.passkeyUser(w, r)
+	if user == nil {
+		return
+	}
+	var req struct {
+		Name       string                 `json:"name"`
+		Credential auth.PasskeyCredential `json:"credential"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if len(req.Name) > 100 {
+		http.Error(w, "name must be at most 100 characters", http.StatusBadRequest)
+		return
+	}
+
+	cred, err := auth.FinishPasskeyRegistration(h.app.DB, h.relyingParty(), user, req.Name, req.Credential)
+	if err != nil {
+		writePasskeyError(w, r, err)
+		return
+	}
+
+	h.logAudit(r, user.Username, "ADD_PASSKEY", fmt.Sprintf("Added passkey %q", cred.Name))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(cred)
+}
+
+// handlePasskeyLoginOptions starts a passkey login and returns the options
+// for navigator.credentials.get. The browser offers any passkey the user
+// has for this site, so no username is needed.
+func (h *handlers) handlePasskeyLoginOptions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.passkeysAllowed() {
+		http.Error(w, "Passkeys are not enabled", http.StatusForbidden)
+		return
+	}
+
+	opts, err := auth.BeginPasskeyLogin(h.app.DB, h.relyingParty())
+	if err != nil {
+		writePasskeyError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(opts)
+}
+
+// handlePasskeyLogin verifies a passkey assertion and logs the user in.
+// The authenticator verified the user, so no MFA step follows.
+func (h *handlers) handlePasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.app.JWTAuth == nil {
+		middleware.LocalizedError(w, r, "Authentication not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if !h.passkeysAllowed() {
+		http.Error(w, "Passkeys are not enabled", http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		Credential auth.PasskeyCredential `json:"credential"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.app.JWTAuth.LoginWithPasskey(withClientInfo(r), h.relyingParty(), req.Credential)
+	if err != nil {
+		slog.Warn("passkey login failed", "error", err)
+		writePasskeyError(w, r, err)
+		return
+	}
+
+	h.logAudit(r, result.User.Username, "LOGIN", "User logged in with a passkey")
+
+	h.setAccessTokenCookie(w, r, result.AccessToken, int(result.ExpiresIn))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// --- Password reset ---
+
+// passwordResetEmail is the body of the email with a reset link. The
+// arguments are the username, the link and its lifetime in minutes.
+const passwordResetEmail = `Hello %s,
+
+Someone asked to reset the password for your Sortie account. To choose a
+new password, open this link:
+
+%s
+
+The link works once and expires in %d minutes. If you did not ask for a
+reset, you can ignore this email; your password has not changed.
+`
+
+// handleForgotPassword emails a password reset link to the local account
+// with the given username or email address. It answers 202 whether or not
+// an account matched, so it cannot be used to find out who has one.
+func (h *handlers) handleForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.app.JWTAuth == nil || h.app.Mailer == nil {
+		http.Error(w, "Password reset is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req struct {
+		Username string `json:"username"`
+		Email    string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		middleware.LocalizedError(w, r, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Username == "" && req.Email == "" {
+		http.Error(w, "username or email is required", http.StatusBadRequest)
+		return
+	}
+
+	var users []db.User
+	if req.Username != "" {
+		user, err := h.app.DB.GetUserByUsername(req.Username)
+		if err != nil {
+			slog.Error("error getting user for password reset", "error", err)
+			middleware.LocalizedError(w, r, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if user != nil {
+			users = append(users, *user)
+		}
+	} else {
+		var err error
+		if users, err = h.app.DB.ListUsersByEmail(req.Email); err != nil {
+			slog.Error("error getting users for password reset", "error", err)
+			middleware.LocalizedError(w, r, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	for i := range users {
+		user := &users[i]
+		if !auth.CanResetPassword(user) || user.Email == "" {
+			continue
+		}
+		token, err := h.app.JWTAuth.NewPasswordResetToken(user.ID)
+		if err != nil {
+			slog.Error("error creating password reset token", "user", user.Username, "error", err)
+			continue
+		}
+		msg := email.Message{
+			To:      user.Email,
+			Subject: "Reset your Sortie password",
+			Body: fmt.Sprintf(passwordResetEmail, user.Username,
+				h.app.Config.PublicURL+"/?reset_token="+token, int(auth.PasswordResetExpiry.Minutes())),
+		}
+		// Send in the background so the response time does not reveal
+		// whether an account matched
+		go func(username string) {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := h.app.Mailer.Send(ctx, msg); err != nil {
+				slog.Error("failed to send password reset email", "user", username, "error", err)
+			}
+		}(user.Username)
+
+		h.logAudit(r, user.Username, "PASSWORD_RESET_REQUESTED", "Password reset link emailed")
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleResetPassword sets a new password using the token from a reset
+// email. Every device the user was signed in on is signed out.
+func (h *handlers) handleResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.app.JWTAuth == nil || h.app.Mailer == nil {
+		http.Error(w, "Password reset is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		middleware.LocalizedError(w, r, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Password) < 6 {
+		middleware.LocalizedError(w, r, "Password must be at least 6 characters", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.app.JWTAuth.ResetPassword(req.Token, req.Password)
+	if errors.Is(err, auth.ErrInvalidResetToken) {
+		http.Error(w, "Invalid or expired reset link", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.Error("password reset failed", "error", err)
+		middleware.LocalizedError(w, r, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(r, user.Username, "RESET_PASSWORD", "Password reset with an emailed link")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- OIDC endpoints ---
+
+func (h *handlers) handleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		middleware.LocalizedError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.app.OIDCAuth == nil {
+		middleware.LocalizedError(w, r, "SSO is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	redirectURL := r.URL.Query().Get("redirect")
+	if redirectURL == "" {
+		redirectURL = "/"
+	}
+
+	loginURL := h.app.OIDCAuth.GetLoginURL(redirectURL)
+	if loginURL == "" {
+		middleware.LocalizedError(w, r, "Failed to generate login URL", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, loginURL, http.StatusFound)
+}
+
+func (h *handlers) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		middleware.LocalizedError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.app.OIDCAuth == nil {
+		middleware.LocalizedError(w, r, "SSO is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	if errParam := r.URL.Query().Get("error"); errParam != "" {
+		errDesc := r.URL.Query().Get("error_description")
+		slog.Warn("OIDC callback error", "error", errParam, "description", errDesc)
+		http.Error(w, middleware.Localizer(r).T("SSO error: %s", errDesc), http.StatusBadRequest)
+		return
+	}
+
+	code := r.URL.Query().Get("code")
+	state := r.URL.Query().Get("state")
+	if code == "" || state == "" {
+		middleware.LocalizedError(w, r, "Missing code or state parameter", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.app.OIDCAuth.HandleCallback(withClientInfo(r), code, state)
+	if err != nil {
+		slog.Error("OIDC callback failed", "error", err)
+		middleware.LocalizedError(w, r, "SSO authentication failed", http.StatusUnauthorized)
+		return
+	}
+
+	if !result.Authenticated || result.User == nil {
+		middleware.LocalizedError(w, r, "SSO authentication failed", http.StatusUnauthorized)
+		return
+	}
+
+	accessToken := result.Token
+	refreshToken := result.Message
+
+	h.logAudit(r, result.User.Username, "SSO_LOGIN", "User logged in via OIDC SSO")
+
+	maxAge := h.app.Config.JWTAccessExpiry
+	if result.ExpiresAt != nil {
+		maxAge = time.Until(*result.ExpiresAt)
+	}
+
+	h.setAccessTokenCookie(w, r, accessToken, int(maxAge.Seconds()))
+
+	// The page is shown in the user's saved language, if any
+	l := i18n.New(i18n.Negotiate(result.User.Metadata[middleware.LocaleMetadataKey], r.Header.Get("Accept-Language")))
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Language", l.Locale())
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html lang="%s">
+<head><title>%s</title></head>
+<body>
+<script>
+localStorage.setItem('sortie-access-token', %q);
+localStorage.setItem('sortie-refresh-token', %q);
+localStorage.setItem('sortie-user', JSON.stringify(%s));
+window.location.href = '/';
+</script>
+<noscript><p>%s</p></noscript>
+</body>
+</html>`, l.Locale(), html.EscapeString(l.T("SSO Login")), accessToken, refreshToken, mustJSON(result.User),
+		l.T(`Login successful. <a href="/">Click here</a> to continue.`))
+}
+
+func mustJSON(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
+
+// --- Config endpoint ---
+
+// BrandingConfig represents tenant branding configuration.
+type BrandingConfig struct {
+	LogoURL              string `json:"logo_url"`
+	PrimaryColor         string `json:"primary_color"`
+	SecondaryColor       string `json:"secondary_color"`
+	TenantName           string `json:"tenant_name"`
+	AllowRegistration    bool   `json:"allow_registration"`
+	SSOEnabled           bool   `json:"sso_enabled"`
+	PasskeysEnabled      bool   `json:"passkeys_enabled"`
+	PasswordResetEnabled bool   `json:"password_reset_enabled"`
+	Offline              bool   `json:"offline"`
+
+	// Locales available for server-generated text
+	Locales []string `json:"locales"`
+
+	// Uploaded tenant branding (see /api/admin/tenants/{id}/branding)
+	FaviconURL    string            `json:"favicon_url,omitempty"`
+	BackgroundURL string            `json:"background_url,omitempty"`
+	Theme         *db.BrandingTheme `json:"theme,omitempty"`
+}
+
+func (h *handlers) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	brandingCfg := BrandingConfig{
+		LogoURL:           h.app.Config.LogoURL,
+		PrimaryColor:      h.app.Config.PrimaryColor,
+		SecondaryColor:    h.app.Config.SecondaryColor,
+		TenantName:        h.app.Config.TenantName,
+		AllowRegistration: h.isRegistrationAllowed(),
+	}
+
+	if data, err := os.ReadFile(h.app.Config.BrandingConfigPath); err == nil {
+		json.Unmarshal(data, &brandingCfg)
+	}
+
+	h.applyTenantBranding(&brandingCfg, r)
+
+	brandingCfg.AllowRegistration = h.isRegistrationAllowed()
+	brandingCfg.SSOEnabled = h.app.OIDCAuth != nil
+	brandingCfg.PasskeysEnabled = h.passkeysAllowed()
+	brandingCfg.PasswordResetEnabled = h.app.Mailer != nil
+	brandingCfg.Offline = h.app.Config.Offline
+	brandingCfg.Locales = i18n.Supported()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(brandingCfg)
+}
+
+// applyTenantBranding overrides cfg with the branding uploaded for the
+// tenant named in the X-Tenant-ID header, or the default tenant. The
+// "primary" and "secondary" theme colors replace the configured colors.
+func (h *handlers) applyTenantBranding(cfg *BrandingConfig, r *http.Request) {
+	tenantID := r.Header.Get(middleware.TenantHeader)
+	if tenantID == "" {
+		tenantID = db.DefaultTenantID
+	}
+	b, err := h.app.DB.GetTenantBranding(tenantID)
+	if err != nil {
+		slog.Error("error getting tenant branding", "tenant_id", tenantID, "error", err)
+		return
+	}
+	if b == nil {
+		return
+	}
+
+	if asset, ok := b.Assets[branding.KindLogo]; ok {
+		cfg.LogoURL = branding.AssetURL(tenantID, branding.KindLogo, asset)
+	}
+	if asset, ok := b.Assets[branding.KindFavicon]; ok {
+		cfg.FaviconURL = branding.AssetURL(tenantID, branding.KindFavicon, asset)
+	}
+	if asset, ok := b.Assets[branding.KindBackground]; ok {
+		cfg.BackgroundURL = branding.AssetURL(tenantID, branding.KindBackground, asset)
+	}
+	if len(b.Theme.Colors) > 0 || len(b.Theme.Fonts) > 0 {
+		theme := b.Theme
+		cfg.Theme = &theme
+		if c := theme.Colors["primary"]; c != "" {
+			cfg.PrimaryColor = c
+		}
+		if c := theme.Colors["secondary"]; c != "" {
+			cfg.SecondaryColor = c
+		}
+	}
+}
+
+// --- App CRUD ---
+
+func (h *handlers) handleApps(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		user := middleware.GetUserFromContext(r.Context())
+		userID := ""
+		var userRoles []string
+		if user != nil {
+			userID = user.ID
+			userRoles = user.Roles
+		}
+		tenantID := middleware.GetTenantIDFromContext(r.Context())
+
+		apps, err := h.app.DB.ListAppsForUser(userID, userRoles, tenantID)
+		if err != nil {
+			slog.Error("error listing apps", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if apps == nil {
+			apps = []db.Application{}
+		}
+		h.setActiveSessions(apps)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(apps)
+
+	case http.MethodPost:
+		user := middleware.GetUserFromContext(r.Context())
+		// Check category admin permission for the app's category
+		isCatAdmin := false
+		// We'll check after parsing the body so we know the category
+		var app db.Application
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := json.Unmarshal(body, &app); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		if app.Visibility == "" {
+			app.Visibility = db.CategoryVisibilityPublic
+		}
+
+		if app.Category != "" {
+			if cat, _ := h.app.DB.GetCategoryByName(app.Category); cat != nil {
+				isCatAdmin, _ = h.app.DB.IsCategoryAdmin(user.ID, cat.ID)
+			}
+		}
+
+		if !middleware.HasRole(user.Roles, middleware.RoleAdmin, middleware.RoleAppAuthor) && !isCatAdmin {
+			http.Error(w, "Insufficient permissions", http.StatusForbidden)
+			return
+		}
+
+		// Sidecars run alongside the app with access to its pod, so only
+		// admins may attach them
+		if len(app.Sidecars) > 0 {
+			if !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+				http.Error(w, "Only admins can attach sidecars", http.StatusForbidden)
+				return
+			}
+			if code, err := h.checkSidecarRefs(app.Sidecars, app.TenantID); err != nil {
+				writeSidecarError(w, code, err)
+				return
+			}
+		}
+
+		// Session attestation is an audit control, so only admins turn it on
+		if !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+			app.AttestSessions = false
+		}
+
+		// Warm pods hold cluster resources while idle, so only admins size
+		// an app's pool
+		if !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+			app.WarmPoolSize = 0
+		}
+
+		// Pre-pulls run on every eligible node, so only admins schedule them
+		if !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+			app.PrePullSchedule = ""
+		}
+
+		// Sidecar images run alongside the app like sidecars, so only admins
+		// may override them
+		if app.SidecarImages != nil && !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+			http.Error(w, "Only admins can override sidecar images", http.StatusForbidden)
+			return
+		}
+
+		// Tolerations can place pods on nodes reserved for other workloads,
+		// so only admins pin apps to nodes
+		if app.Scheduling != nil && !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+			http.Error(w, "Only admins can set scheduling", http.StatusForbidden)
+			return
+		}
+
+		// Pull secrets hold registry credentials shared by every app in the
+		// namespace, so only admins may reference them
+		if app.ImagePullSecret != "" && !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+			http.Error(w, "Only admins can set image_pull_secret", http.StatusForbidden)
+			return
+		}
+
+		// Credential references can read any secret in the secrets
+		// provider, so only admins may set them
+		if app.Credentials != nil && !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+			http.Error(w, "Only admins can set app credentials", http.StatusForbidden)
+			return
+		}
+
+		// Objectives decide when operators are alerted, so only admins
+		// set them
+		if app.SLO != nil && !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+			http.Error(w, "Only admins can set app SLOs", http.StatusForbidden)
+			return
+		}
+
+		if err := k8s.ValidateDNSConfig(app.DNS); err != nil {
+			http.Error(w, "Invalid dns: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := k8s.ValidateScheduling(app.Scheduling); err != nil {
+			http.Error(w, "Invalid scheduling: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := prepull.Validate(app.PrePullSchedule); err != nil {
+			http.Error(w, "Invalid prepull_schedule: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := k8s.ValidateImagePullSecret(app.ImagePullSecret); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := k8s.ValidateHomeVolume(app.HomeVolume); err != nil {
+			http.Error(w, "Invalid home_volume: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := k8s.ValidateAppContainers(app.Containers, app.SharedVolumes); err != nil {
+			http.Error(w, "Invalid containers: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := k8s.ValidateInitContainers(app.InitContainers); err != nil {
+			http.Error(w, "Invalid init_containers: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := k8s.ValidateSidecarImages(app.SidecarImages); err != nil {
+			http.Error(w, "Invalid sidecar_images: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := h.checkIcon(app.Icon); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := guacamole.ValidateRDPSettings(app.RDPSettings); err != nil {
+			http.Error(w, "Invalid rdp_settings: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := guacamole.ValidateCredentialRef(app.Credentials); err != nil {
+			http.Error(w, "Invalid credentials: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := schedule.Validate(app.Schedule); err != nil {
+			http.Error(w, "Invalid schedule: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := slo.Validate(app.SLO); err != nil {
+			http.Error(w, "Invalid slo: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if app.IdleTimeout < 0 {
+			http.Error(w, "Invalid idle_timeout: must be non-negative", http.StatusBadRequest)
+			return
+		}
+		if app.LockTimeout < 0 {
+			http.Error(w, "Invalid lock_timeout: must be non-negative", http.StatusBadRequest)
+			return
+		}
+		if app.WarmPoolSize < 0 || app.WarmPoolSize > sessions.MaxWarmPoolSize {
+			http.Error(w, fmt.Sprintf("Invalid warm_pool_size: must be between 0 and %d", sessions.MaxWarmPoolSize), http.StatusBadRequest)
+			return
+		}
+		if app.MaxConcurrentSessions < 0 {
+			http.Error(w, "Invalid max_concurrent_sessions: must be non-negative", http.StatusBadRequest)
+			return
+		}
+		if !app.LaunchConfirmation.Valid() {
+			http.Error(w, "Invalid launch_confirmation: prerequisites must not be blank", http.StatusBadRequest)
+			return
+		}
+		if !app.RecordingPolicy.Valid() {
+			http.Error(w, "Invalid recording_policy: must be auto or manual", http.StatusBadRequest)
+			return
+		}
+		if !app.ClipboardPolicy.Valid() {
+			http.Error(w, "Invalid clipboard_policy: must be bidirectional, read, write or none", http.StatusBadRequest)
+			return
+		}
+		if !app.FileTransfer.Valid() {
+			http.Error(w, "Invalid file_transfer: must be both, upload_only, download_only or none", http.StatusBadRequest)
+			return
+		}
+
+		if app.ID == "" || app.Name == "" {
+			http.Error(w, "Missing required fields: id, name", http.StatusBadRequest)
+			return
+		}
+
+		if app.LaunchType == db.LaunchTypeContainer || app.LaunchType == db.LaunchTypeWebProxy {
+			if app.ContainerImage == "" {
+				http.Error(w, "Missing required field for container/web_proxy app: container_image", http.StatusBadRequest)
+				return
+			}
+		} else if app.URL == "" {
+			http.Error(w, "Missing required field: url", http.StatusBadRequest)
+			return
+		}
+
+		// Auto-create category if it doesn't exist (backwards compat), in
+		// the same transaction so a failed insert doesn't leave it behind
+		err = h.app.DB.WithTx(func(tx *db.DB) error {
+			tenantID := middleware.GetTenantIDFromContext(r.Context())
+			if _, err := tx.EnsureCategoryExists(app.Category, tenantID); err != nil {
+				return err
+			}
+			return tx.CreateApp(app)
+		})
+		if err != nil {
+			if db.IsDuplicateKeyError(err) {
+				http.Error(w, "Application with this ID already exists", http.StatusConflict)
+				return
+			}
+			slog.Error("error creating app", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		details := fmt.Sprintf("Created app: %s (%s)", app.Name, app.ID)
+		h.logAudit(r, user.Username, "CREATE_APP", details)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(app)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// setActiveSessions reports the active sessions of the apps that limit
+// their concurrent sessions, so clients can show the seats in use.
+func (h *handlers) setActiveSessions(apps []db.Application) {
+	if !slices.ContainsFunc(apps, func(app db.Application) bool { return app.MaxConcurrentSessions > 0 }) {
+		return
+	}
+	counts, err := h.app.DB.CountActiveSessionsPerApp()
+	if err != nil {
+		slog.Warn("failed to count active sessions per app", "error", err)
+		return
+	}
+	for i := range apps {
+		if apps[i].MaxConcurrentSessions > 0 {
+			count := counts[apps[i].ID]
+			apps[i].ActiveSessions = &count
+		}
+	}
+}
+
+func (h *handlers) handleAppByID(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/apps/")
+	if id == "" {
+		http.Error(w, "Missing app ID", http.StatusBadRequest)
+		return
+	}
+	if appID, ok := strings.CutSuffix(id, "/effective-policy"); ok {
+		h.handleAppPolicy(w, r, appID)
+		return
+	}
+	if appID, ok := strings.CutSuffix(id, "/launch-preview"); ok {
+		h.handleAppLaunchPreview(w, r, appID)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		app, err := h.app.DB.GetApp(id)
+		if err != nil {
+			slog.Error("error getting app", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if app == nil {
+			http.Error(w, "Application not found", http.StatusNotFound)
+			return
+		}
+		if app.MaxConcurrentSessions > 0 {
+			count, err := h.app.DB.CountActiveSessionsByApp(app.ID)
+			if err != nil {
+				slog.Error("error counting app sessions", "app_id", app.ID, "error", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			app.ActiveSessions = &count
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(app)
+
+	case http.MethodPut:
+		user := middleware.GetUserFromContext(r.Context())
+
+		var app db.Application
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := json.Unmarshal(body, &app); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		app.ID = id
+		if app.Visibility == "" {
+			app.Visibility = db.CategoryVisibilityPublic
+		}
+
+		err = h.app.DB.WithTx(func(tx *db.DB) error {
+			existing, err := tx.GetApp(id)
+			if err != nil {
+				return err
+			}
+
+			// Check category admin for the app's category
+			isCatAdmin := false
+			catName := app.Category
+			if catName == "" && existing != nil {
+				// Check existing app's category
+				catName = existing.Category
+			}
+			if catName != "" {
+				if cat, _ := tx.GetCategoryByName(catName); cat != nil {
+					isCatAdmin, _ = tx.IsCategoryAdmin(user.ID, cat.ID)
+				}
+			}
+
+			if !middleware.HasRole(user.Roles, middleware.RoleAdmin, middleware.RoleAppAuthor) && !isCatAdmin {
+				return &httpError{http.StatusForbidden, "Insufficient permissions"}
+			}
+
+			// Only admins may change sidecars; other editors keep the app's
+			// current ones when they leave the field out
+			if !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+				var current []string
+				if existing != nil {
+					current = existing.Sidecars
+				}
+				if app.Sidecars == nil {
+					app.Sidecars = current
+				} else if !slices.Equal(app.Sidecars, current) {
+					return &httpError{http.StatusForbidden, "Only admins can attach sidecars"}
+				}
+			} else if code, err := h.checkSidecarRefs(app.Sidecars, app.TenantID); err != nil {
+				if code == http.StatusInternalServerError {
+					return err
+				}
+				return &httpError{code, err.Error()}
+			}
+
+			// Only admins may change session attestation
+			if !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+				app.AttestSessions = existing != nil && existing.AttestSessions
+			}
+
+			// Only admins may change the warm pool size
+			if !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+				app.WarmPoolSize = 0
+				if existing != nil {
+					app.WarmPoolSize = existing.WarmPoolSize
+				}
+			}
+
+			// Only admins may change the pre-pull schedule
+			if !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+				app.PrePullSchedule = ""
+				if existing != nil {
+					app.PrePullSchedule = existing.PrePullSchedule
+				}
+			}
+
+			// Only admins may change sidecar images; other editors keep the
+			// app's current ones when they leave the field out
+			if !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+				var current *db.SidecarImages
+				if existing != nil {
+					current = existing.SidecarImages
+				}
+				if app.SidecarImages == nil {
+					app.SidecarImages = current
+				} else if current == nil || *app.SidecarImages != *current {
+					return &httpError{http.StatusForbidden, "Only admins can override sidecar images"}
+				}
+			}
+
+			// Only admins may change scheduling; other editors keep the app's
+			// current settings when they leave the field out
+			if !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+				var current *db.Scheduling
+				if existing != nil {
+					current = existing.Scheduling
+				}
+				if app.Scheduling == nil {
+					app.Scheduling = current
+				} else if !reflect.DeepEqual(app.Scheduling, current) {
+					return &httpError{http.StatusForbidden, "Only admins can set scheduling"}
+				}
+			}
+
+			// Only admins may change the pull secret; other editors keep the
+			// app's current one when they leave the field out
+			if !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+				var current string
+				if existing != nil {
+					current = existing.ImagePullSecret
+				}
+				if app.ImagePullSecret == "" {
+					app.ImagePullSecret = current
+				} else if app.ImagePullSecret != current {
+					return &httpError{http.StatusForbidden, "Only admins can set image_pull_secret"}
+				}
+			}
+
+			// Only admins may change credential references; other editors keep
+			// the app's current ones when they leave the field out
+			if !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+				var current *db.CredentialRef
+				if existing != nil {
+					current = existing.Credentials
+				}
+				if app.Credentials == nil {
+					app.Credentials = current
+				} else if current == nil || *app.Credentials != *current {
+					return &httpError{http.StatusForbidden, "Only admins can set app credentials"}
+				}
+			}
+
+			// Only admins may change objectives; other editors keep the
+			// app's current ones when they leave the field out
+			if !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+				var current *db.AppSLO
+				if existing != nil {
+					current = existing.SLO
+				}
+				if app.SLO == nil {
+					app.SLO = current
+				} else if current == nil || *app.SLO != *current {
+					return &httpError{http.StatusForbidden, "Only admins can set app SLOs"}
+				}
+			}
+
+			if err := k8s.ValidateDNSConfig(app.DNS); err != nil {
+				return &httpError{http.StatusBadRequest, "Invalid dns: " + err.Error()}
+			}
+			if err := k8s.ValidateScheduling(app.Scheduling); err != nil {
+				return &httpError{http.StatusBadRequest, "Invalid scheduling: " + err.Error()}
+			}
+			if err := prepull.Validate(app.PrePullSchedule); err != nil {
+				return &httpError{http.StatusBadRequest, "Invalid prepull_schedule: " + err.Error()}
+			}
+			if err := k8s.ValidateImagePullSecret(app.ImagePullSecret); err != nil {
+				return &httpError{http.StatusBadRequest, err.Error()}
+			}
+			if err := k8s.ValidateHomeVolume(app.HomeVolume); err != nil {
+				return &httpError{http.StatusBadRequest, "Invalid home_volume: " + err.Error()}
+			}
+			if err := k8s.ValidateAppContainers(app.Containers, app.SharedVolumes); err != nil {
+				return &httpError{http.StatusBadRequest, "Invalid containers: " + err.Error()}
+			}
+			if err := k8s.ValidateInitContainers(app.InitContainers); err != nil {
+				return &httpError{http.StatusBadRequest, "Invalid init_containers: " + err.Error()}
+			}
+			if err := k8s.ValidateSidecarImages(app.SidecarImages); err != nil {
+				return &httpError{http.StatusBadRequest, "Invalid sidecar_images: " + err.Error()}
+			}
+			if err := h.checkIcon(app.Icon); err != nil {
+				return &httpError{http.StatusBadRequest, err.Error()}
+			}
+			if err := guacamole.ValidateRDPSettings(app.RDPSettings); err != nil {
+				return &httpError{http.StatusBadRequest, "Invalid rdp_settings: " + err.Error()}
+			}
+			if err := guacamole.ValidateCredentialRef(app.Credentials); err != nil {
+				return &httpError{http.StatusBadRequest, "Invalid credentials: " + err.Error()}
+			}
+			if err := schedule.Validate(app.Schedule); err != nil {
+				return &httpError{http.StatusBadRequest, "Invalid schedule: " + err.Error()}
+			}
+			if err := slo.Validate(app.SLO); err != nil {
+				return &httpError{http.StatusBadRequest, "Invalid slo: " + err.Error()}
+			}
+			if app.IdleTimeout < 0 {
+				return &httpError{http.StatusBadRequest, "Invalid idle_timeout: must be non-negative"}
+			}
+			if app.LockTimeout < 0 {
+				return &httpError{http.StatusBadRequest, "Invalid lock_timeout: must be non-negative"}
+			}
+			if app.WarmPoolSize < 0 || app.WarmPoolSize > sessions.MaxWarmPoolSize {
+				return &httpError{http.StatusBadRequest, fmt.Sprintf("Invalid warm_pool_size: must be between 0 and %d", sessions.MaxWarmPoolSize)}
+			}
+			if app.MaxConcurrentSessions < 0 {
+				return &httpError{http.StatusBadRequest, "Invalid max_concurrent_sessions: must be non-negative"}
+			}
+			if !app.LaunchConfirmation.Valid() {
+				return &httpError{http.StatusBadRequest, "Invalid launch_confirmation: prerequisites must not be blank"}
+			}
+			if !app.RecordingPolicy.Valid() {
+				return &httpError{http.StatusBadRequest, "Invalid recording_policy: must be auto or manual"}
+			}
+			if !app.ClipboardPolicy.Valid() {
+				return &httpError{http.StatusBadRequest, "Invalid clipboard_policy: must be bidirectional, read, write or none"}
+			}
+			if !app.FileTransfer.Valid() {
+				return &httpError{http.StatusBadRequest, "Invalid file_transfer: must be both, upload_only, download_only or none"}
+			}
+
+			if app.Name == "" {
+				return &httpError{http.StatusBadRequest, "Missing required field: name"}
+			}
+
+			if app.LaunchType == db.LaunchTypeContainer || app.LaunchType == db.LaunchTypeWebProxy {
+				if app.ContainerImage == "" {
+					return &httpError{http.StatusBadRequest, "Missing required field for container/web_proxy app: container_image"}
+				}
+			} else if app.URL == "" {
+				return &httpError{http.StatusBadRequest, "Missing required field: url"}
+			}
+
+			// Auto-create category if it doesn't exist
+			if _, err := tx.EnsureCategoryExists(app.Category, middleware.GetTenantIDFromContext(r.Context())); err != nil {
+				return err
+			}
+			return tx.UpdateApp(app)
+		})
+		if err == sql.ErrNoRows {
+			http.Error(w, "Application not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			writeTxError(w, r, err, "updating app")
+			return
+		}
+
+		details := fmt.Sprintf("Updated app: %s (%s)", app.Name, app.ID)
+		h.logAudit(r, user.Username, "UPDATE_APP", details)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(app)
+
+	case http.MethodDelete:
+		user := middleware.GetUserFromContext(r.Context())
+
+		var app *db.Application
+		err := h.app.DB.WithTx(func(tx *db.DB) error {
+			var err error
+			app, err = tx.GetApp(id)
+			if err != nil {
+				return err
+			}
+			if app == nil {
+				return &httpError{http.StatusNotFound, "Application not found"}
+			}
+
+			// Check category admin for the app's category
+			isCatAdmin := false
+			if app.Category != "" {
+				if cat, _ := tx.GetCategoryByName(app.Category); cat != nil {
+					isCatAdmin, _ = tx.IsCategoryAdmin(user.ID, cat.ID)
+				}
+			}
+
+			if !middleware.HasRole(user.Roles, middleware.RoleAdmin, middleware.RoleAppAuthor) && !isCatAdmin {
+				return &httpError{http.StatusForbidden, "Insufficient permissions"}
+			}
+
+			return tx.DeleteApp(id)
+		})
+		if err != nil {
+			writeTxError(w, r, err, "deleting app")
+			return
+		}
+
+		details := fmt.Sprintf("Deleted app: %s (%s)", app.Name, id)
+		h.logAudit(r, user.Username, "DELETE_APP", details)
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// --- AppSpec CRUD ---
+
+// handleAppPolicy handles GET /api/apps/{id}/effective-policy, showing the
+// session settings the app's sessions get and which layer each comes from. Like
+// editing the app, it is open to admins, app authors, and admins of the
+// app's category.
+func (h *handlers) handleAppPolicy(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	app, err := h.app.DB.GetApp(id)
+	if err != nil {
+		slog.Error("error getting app", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if app == nil {
+		http.Error(w, "Application not found", http.StatusNotFound)
+		return
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	isCatAdmin := false
+	if app.Category != "" {
+		if cat, _ := h.app.DB.GetCategoryByName(app.Category); cat != nil {
+			isCatAdmin, _ = h.app.DB.IsCategoryAdmin(user.ID, cat.ID)
+		}
+	}
+	if !middleware.HasRole(user.Roles, middleware.RoleAdmin, middleware.RoleAppAuthor) && !isCatAdmin {
+		http.Error(w, "Insufficient permissions", http.StatusForbidden)
+		return
+	}
+
+	policy, err := h.app.SessionManager.EffectivePolicy(app)
+	if err != nil {
+		slog.Error("error resolving app policy", "app", app.ID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
+
+// launchConfirmTTL is how long the confirm token of a launch preview may
+// be used to launch the app.
+const launchConfirmTTL = 5 * time.Minute
+
+// launchCostWindow is how far back an app's session runs are averaged for
+// the typical run length in its launch preview.
+const launchCostWindow = 30 * 24 * time.Hour
+
+// launchCost is the estimated cost of a session in a launch preview, from
+// the cost report rates.
+type launchCost struct {
+	Currency      string  `json:"currency"`
+	CPUPerHour    float64 `json:"cpu_per_hour"`
+	MemoryPerHour float64 `json:"memory_per_hour"`
+	PerHour       float64 `json:"per_hour"`
+	// TypicalHours is the app's average run over the last 30 days, and
+	// Estimated a run of that length, when the app has runs.
+	TypicalHours float64 `json:"typical_hours,omitempty"`
+	Estimated    float64 `json:"estimated,omitempty"`
+}
+
+// launchPreviewResponse is the body of GET /api/apps/{id}/launch-preview.
+type launchPreviewResponse struct {
+	*sessions.LaunchPreview
+	Cost         launchCost             `json:"cost"`
+	Confirmation *db.LaunchConfirmation `json:"confirmation,omitempty"`
+	// ConfirmToken must be passed when creating a session of an app that
+	// requires confirmation.
+	ConfirmToken     string     `json:"confirm_token,omitempty"`
+	ConfirmExpiresAt *time.Time `json:"confirm_expires_at,omitempty"`
+}
+
+// handleAppLaunchPreview reports what launching an app would consume for
+// the caller: its resources, quota usage, estimated cost and prerequisites.
+// For apps that require confirmation it returns the token to launch with.
+func (h *handlers) handleAppLaunchPreview(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	app, err := h.app.DB.GetApp(id)
+	if err != nil {
+		slog.Error("error getting app", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if app == nil {
+		http.Error(w, "Application not found", http.StatusNotFound)
+		return
+	}
+	if app.LaunchType != db.LaunchTypeContainer && app.LaunchType != db.LaunchTypeWebProxy {
+		http.Error(w, "Application does not launch sessions", http.StatusBadRequest)
+		return
+	}
+
+	userID := "anonymous"
+	if user := middleware.GetUserFromContext(r.Context()); user != nil {
+		userID = user.ID
+	}
+	projectID := r.URL.Query().Get("project_id")
+	if projectID != "" {
+		member, err := h.app.DB.GetProjectMember(projectID, userID)
+		if err != nil {
+			slog.Error("error getting project member", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if member == nil || !member.Role.CanLaunch() {
+			http.Error(w, "Only project owners and members can launch sessions in a project", http.StatusForbidden)
+			return
+		}
+	}
+
+	preview, err := h.app.SessionManager.PreviewLaunch(app, userID, projectID)
+	if err != nil {
+		slog.Error("error previewing launch", "app", app.ID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	cfg := h.app.Config
+	round := func(v float64) float64 { return math.Round(v*1e4) / 1e4 }
+	cost := launchCost{
+		Currency:      cfg.CostCurrency,
+		CPUPerHour:    round(preview.CPUCores * cfg.CostCPUCoreHour),
+		MemoryPerHour: round(preview.MemoryGiB * cfg.CostMemoryGiBHour),
+	}
+	cost.PerHour = round(cost.CPUPerHour + cost.MemoryPerHour)
+	runs, err := h.app.DB.SummarizeSessionHistory(db.SessionHistoryFilter{
+		AppID: app.ID,
+		From:  time.Now().Add(-launchCostWindow),
+	}, "app")
+	if err != nil {
+		slog.Error("error summarizing session history", "app", app.ID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if len(runs) > 0 && runs[0].Sessions > 0 {
+		hours := float64(runs[0].TotalDurationSeconds) / 3600 / float64(runs[0].Sessions)
+		cost.TypicalHours = round(hours)
+		cost.Estimated = round(hours * cost.PerHour)
+	}
+
+	resp := launchPreviewResponse{
+		LaunchPreview: preview,
+		Cost:          cost,
+		Confirmation:  app.LaunchConfirmation,
+	}
+	if app.LaunchConfirmation != nil && app.LaunchConfirmation.Required {
+		expires := time.Now().Add(launchConfirmTTL).Truncate(time.Second)
+		resp.ConfirmToken = h.launchConfirmToken(userID, app.ID, expires.Unix())
+		resp.ConfirmExpiresAt = &expires
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// launchConfirmToken returns a token confirming that userID previewed a
+// launch of appID, valid until expires. It is signed with a key derived
+// from the JWT secret, so it cannot be confused with other tokens.
+func (h *handlers) launchConfirmToken(userID, appID string, expires int64) string {
+	key := sha256.Sum256([]byte("sortie-launch-confirm\x00" + h.app.Config.JWTSecret))
+	mac := hmac.New(sha256.New, key[:])
+	fmt.Fprintf(mac, "%s\x00%s\x00%d", userID, appID, expires)
+	return strconv.FormatInt(expires, 10) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// validLaunchConfirmToken reports whether token is an unexpired confirm
+// token for userID's launch of appID.
+func (h *handlers) validLaunchConfirmToken(token, userID, appID string) bool {
+	exp, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(token), []byte(h.launchConfirmToken(userID, appID, expires)))
+}
+
+func (h *handlers) handleAppSpecs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		specs, err := h.app.DB.ListAppSpecs()
+		if err != nil {
+			slog.Error("error listing app specs", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if specs == nil {
+			specs = []db.AppSpec{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(specs)
+
+	case http.MethodPost:
+		user := middleware.GetUserFromContext(r.Context())
+		if !middleware.HasRole(user.Roles, middleware.RoleAdmin, middleware.RoleAppAuthor) {
+			http.Error(w, "Insufficient permissions", http.StatusForbidden)
+			return
+		}
+
+		var spec db.AppSpec
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := json.Unmarshal(body, &spec); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		if spec.ID == "" || spec.Name == "" || spec.Image == "" {
+			http.Error(w, "Missing required fields: id, name, image", http.StatusBadRequest)
+			return
+		}
+
+		if err := k8s.ValidateDNSConfig(spec.DNS); err != nil {
+			http.Error(w, "Invalid dns: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := k8s.ValidateScheduling(spec.Scheduling); err != nil {
+			http.Error(w, "Invalid scheduling: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := k8s.ValidateImagePullSecret(spec.ImagePullSecret); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := k8s.ValidateHomeVolume(spec.HomeVolume); err != nil {
+			http.Error(w, "Invalid home_volume: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := k8s.ValidateAppContainers(spec.Containers, spec.SharedVolumes); err != nil {
+			http.Error(w, "Invalid containers: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := k8s.ValidateInitContainers(spec.InitContainers); err != nil {
+			http.Error(w, "Invalid init_containers: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := h.app.DB.CreateAppSpec(spec); err != nil {
+			if db.IsDuplicateKeyError(err) {
+				http.Error(w, "AppSpec with this ID already exists", http.StatusConflict)
+				return
+			}
+			slog.Error("error creating app spec", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		h.logAudit(r, user.Username, "CREATE_APPSPEC", fmt.Sprintf("Created app spec: %s (%s)", spec.Name, spec.ID))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(spec)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *handlers) handleAppSpecByID(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/appspecs/")
+	if id == "" {
+		http.Error(w, "Missing app spec ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		spec, err := h.app.DB.GetAppSpec(id)
+		if err != nil {
+			slog.Error("error getting app spec", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if spec == nil {
+			http.Error(w, "AppSpec not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(spec)
+
+	case http.MethodPut:
+		user := middleware.GetUserFromContext(r.Context())
+		if !middleware.HasRole(user.Roles, middleware.RoleAdmin, middleware.RoleAppAuthor) {
+			http.Error(w, "Insufficient permissions", http.StatusForbidden)
+			return
+		}
+
+		var spec db.AppSpec
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := json.Unmarshal(body, &spec); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		spec.ID = id
+
+		if spec.Name == "" || spec.Image == "" {
+			http.Error(w, "Missing required fields: name, image", http.StatusBadRequest)
+			return
+		}
+
+		if err := k8s.ValidateDNSConfig(spec.DNS); err != nil {
+			http.Error(w, "Invalid dns: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := k8s.ValidateScheduling(spec.Scheduling); err != nil {
+			http.Error(w, "Invalid scheduling: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := k8s.ValidateImagePullSecret(spec.ImagePullSecret); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := k8s.ValidateHomeVolume(spec.HomeVolume); err != nil {
+			http.Error(w, "Invalid home_volume: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := k8s.ValidateAppContainers(spec.Containers, spec.SharedVolumes); err != nil {
+			http.Error(w, "Invalid containers: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := k8s.ValidateInitContainers(spec.InitContainers); err != nil {
+			http.Error(w, "Invalid init_containers: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := h.app.DB.UpdateAppSpec(spec); err != nil {
+			if err.Error() == "sql: no rows in result set" {
+				http.Error(w, "AppSpec not found", http.StatusNotFound)
+				return
+			}
+			slog.Error("error updating app spec", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		h.logAudit(r, user.Username, "UPDATE_APPSPEC", fmt.Sprintf("Updated app spec: %s (%s)", spec.Name, spec.ID))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(spec)
+
+	case http.MethodDelete:
+		user := middleware.GetUserFromContext(r.Context())
+		if !middleware.HasRole(user.Roles, middleware.RoleAdmin, middleware.RoleAppAuthor) {
+			http.Error(w, "Insufficient permissions", http.StatusForbidden)
+			return
+		}
+
+		spec, err := h.app.DB.GetAppSpec(id)
+		if err != nil {
+			slog.Error("error getting app spec", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if spec == nil {
+			http.Error(w, "AppSpec not found", http.StatusNotFound)
+			return
+		}
+
+		if err := h.app.DB.DeleteAppSpec(id); err != nil {
+			slog.Error("error deleting app spec", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		h.logAudit(r, user.Username, "DELETE_APPSPEC", fmt.Sprintf("Deleted app spec: %s (%s)", spec.Name, id))
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// --- Session endpoints ---
+
+// writeCapacityError rejects a launch the cluster has no room for. One
+// that may fit later gets 503 with Retry-After; one that never can, as the
+// cluster is, gets 409.
+func writeCapacityError(w http.ResponseWriter, err *runner.CapacityError) {
+	if err.Temporary {
+		sessions.WriteRetryAfter(w, 1.0)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusConflict)
+}
+
+func (h *handlers) handleSessions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		userID := r.URL.Query().Get("user_id")
+		var sessionList []db.Session
+		var err error
+
+		if userID != "" {
+			sessionList, err = h.app.SessionManager.ListSessionsByUser(r.Context(), userID)
+		} else {
+			// Default to the authenticated user's sessions (admins use /api/admin/sessions for all)
+			user := middleware.GetUserFromContext(r.Context())
+			if user != nil {
+				sessionList, err = h.app.SessionManager.ListSessionsByUser(r.Context(), user.ID)
+			} else {
+				sessionList, err = h.app.SessionManager.ListSessions(r.Context())
+			}
+		}
+
+		if err != nil {
+			slog.Error("error listing sessions", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(h.sessionResponses(sessionList))
+
+	case http.MethodPost:
+		var req sessions.CreateSessionRequest
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		if req.AppID == "" {
+			http.Error(w, "Missing required field: app_id", http.StatusBadRequest)
+			return
+		}
+
+		if req.UserID == "" {
+			user := middleware.GetUserFromContext(r.Context())
+			if user != nil {
+				req.UserID = user.ID
+			} else {
+				req.UserID = "anonymous"
+			}
+		}
+
+		// Sessions are launched in a project by its owners and members
+		if req.ProjectID != "" {
+			member, err := h.app.DB.GetProjectMember(req.ProjectID, req.UserID)
+			if err != nil {
+				slog.Error("error getting project member", "error", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if member == nil || !member.Role.CanLaunch() {
+				http.Error(w, "Only project owners and members can launch sessions in a project", http.StatusForbidden)
+				return
+			}
+		}
+
+		// Expensive apps are launched only after the caller has seen what
+		// the session will consume
+		if app, err := h.app.DB.GetApp(req.AppID); err == nil && app != nil && app.LaunchConfirmation != nil && app.LaunchConfirmation.Required {
+			callerID := "anonymous"
+			if user := middleware.GetUserFromContext(r.Context()); user != nil {
+				callerID = user.ID
+			}
+			if !h.validLaunchConfirmToken(req.ConfirmToken, callerID, app.ID) {
+				http.Error(w, "Launch confirmation required: confirm the app's launch preview and pass its confirm_token", http.StatusPreconditionRequired)
+				return
+			}
+		}
+
+		session, err := h.app.SessionManager.CreateSession(r.Context(), &req)
+		if err != nil {
+			if errors.Is(err, sessions.ErrShuttingDown) {
+				sessions.WriteRetryAfter(w, 0)
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			switch err := err.(type) {
+			case *sessions.QuotaExceededError:
+				loadStatus := h.app.BackpressureHandler.GetLoadStatus()
+				sessions.WriteRetryAfter(w, loadStatus.LoadFactor)
+				http.Error(w, err.Error(), http.StatusTooManyRequests)
+				return
+			case *sessions.QueueFullError:
+				loadStatus := h.app.BackpressureHandler.GetLoadStatus()
+				sessions.WriteRetryAfter(w, loadStatus.LoadFactor)
+				http.Error(w, err.Error(), http.StatusTooManyRequests)
+				return
+			case *sessions.QueueTimeoutError:
+				sessions.WriteRetryAfter(w, 1.0)
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			case *schedule.ClosedError:
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			case *runner.CapacityError:
+				writeCapacityError(w, err)
+				return
+			default:
+				slog.Error("error creating session", "error", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		app, _ := h.app.DB.GetApp(session.AppID)
+		appName := ""
+		wsURL := ""
+		guacURL := ""
+		proxyURL := ""
+		if app != nil {
+			appName = app.Name
+			if app.LaunchType == db.LaunchTypeContainer || app.LaunchType == db.LaunchTypeWebProxy {
+				if app.OsType == "windows" {
+					guacURL = h.app.SessionManager.GetSessionGuacWebSocketURL(session)
+				} else {
+					wsURL = h.app.SessionManager.GetSessionWebSocketURL(session)
+				}
+			}
+		}
+
+		response := sessions.SessionFromDB(session, appName, wsURL, guacURL, proxyURL, h.recordingPolicy(app))
+		response.ClipboardPolicy = clipboardPolicy(app)
+		response.FileTransfer = fileTransferPolicy(app)
+
+		details := fmt.Sprintf("Created session %s for app %s", session.ID, session.AppID)
+		h.logAudit(r, req.UserID, "CREATE_SESSION", details)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(response)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// sessionResponses converts sessions to API responses, with the URLs and
+// policies of their apps.
+func (h *handlers) sessionResponses(sessionList []db.Session) []sessions.SessionResponse {
+	responses := make([]sessions.SessionResponse, len(sessionList))
+	for i, s := range sessionList {
+		app, _ := h.app.DB.GetApp(s.AppID)
+		appName := ""
+		wsURL := ""
+		guacURL := ""
+		proxyURL := ""
+		if app != nil {
+			appName = app.Name
+			if app.LaunchType == db.LaunchTypeContainer || app.LaunchType == db.LaunchTypeWebProxy {
+				if app.OsType == "windows" {
+					guacURL = h.app.SessionManager.GetSessionGuacWebSocketURL(&s)
+				} else {
+					wsURL = h.app.SessionManager.GetSessionWebSocketURL(&s)
+				}
+			}
+		}
+		responses[i] = *sessions.SessionFromDB(&s, appName, wsURL, guacURL, proxyURL, h.recordingPolicy(app))
+		responses[i].ClipboardPolicy = clipboardPolicy(app)
+		responses[i].FileTransfer = fileTransferPolicy(app)
+	}
+	return responses
+}
+
+// maxScheduledSessionsPerUser caps the schedules with launches left that a
+// user may create for themselves. Admins scheduling for others are not
+// limited.
+const maxScheduledSessionsPerUser = 50
+
+// scheduleSessionRequest is the body of POST /api/sessions/schedule.
+type scheduleSessionRequest struct {
+	AppID      string     `json:"app_id"`
+	UserID     string     `json:"user_id,omitempty"`
+	StartAt    time.Time  `json:"start_at"`
+	Recurrence string     `json:"recurrence,omitempty"`
+	Timezone   string     `json:"timezone,omitempty"`
+	EndsAt     *time.Time `json:"ends_at,omitempty"`
+}
+
+// handleScheduledSessions lists the caller's scheduled session launches and
+// schedules new ones. Admins may schedule launches for, and list those of,
+// other users with user_id.
+func (h *handlers) handleScheduledSessions(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	isAdmin := middleware.HasRole(user.Roles, middleware.RoleAdmin)
+
+	switch r.Method {
+	case http.MethodGet:
+		userID := r.URL.Query().Get("user_id")
+		if userID == "" || !isAdmin {
+			userID = user.ID
+		}
+		schedules, err := h.app.DB.ListScheduledSessions(userID)
+		if err != nil {
+			slog.Error("error listing scheduled sessions", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(schedules)
+
+	case http.MethodPost:
+		var req scheduleSessionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if req.AppID == "" || req.StartAt.IsZero() {
+			http.Error(w, "Missing required fields: app_id and start_at", http.StatusBadRequest)
+			return
+		}
+		if req.UserID == "" {
+			req.UserID = user.ID
+		} else if req.UserID != user.ID {
+			if !isAdmin {
+				http.Error(w, "Only admins can schedule sessions for other users", http.StatusForbidden)
+				return
+			}
+			if target, err := h.app.DB.GetUserByID(req.UserID); err != nil || target == nil {
+				http.Error(w, "User not found", http.StatusBadRequest)
+				return
+			}
+		}
+		if app, err := h.app.DB.GetApp(req.AppID); err != nil || app == nil {
+			http.Error(w, "App not found", http.StatusBadRequest)
+			return
+		}
+		if req.Timezone == "" {
+			req.Timezone = "UTC"
+		}
+
+		now := time.Now()
+		if req.StartAt.Before(now.Add(-time.Minute)) {
+			http.Error(w, "start_at is in the past", http.StatusBadRequest)
+			return
+		}
+		first, err := scheduledsessions.FirstRun(req.StartAt, req.Recurrence, req.Timezone, req.EndsAt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !isAdmin {
+			count, err := h.app.DB.CountScheduledSessions(user.ID)
+			if err != nil {
+				slog.Error("error counting scheduled sessions", "error", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if count >= maxScheduledSessionsPerUser {
+				http.Error(w, fmt.Sprintf("You already have %d scheduled sessions", count), http.StatusTooManyRequests)
+				return
+			}
+		}
+
+		first = first.UTC()
+		s := db.ScheduledSession{
+			ID:         uuid.New().String(),
+			UserID:     req.UserID,
+			CreatedBy:  user.ID,
+			AppID:      req.AppID,
+			TenantID:   middleware.GetTenantIDFromContext(r.Context()),
+			StartAt:    req.StartAt.UTC(),
+			Recurrence: req.Recurrence,
+			Timezone:   req.Timezone,
+			NextRunAt:  &first,
+			CreatedAt:  now,
+		}
+		if req.EndsAt != nil {
+			ends := req.EndsAt.UTC()
+			s.EndsAt = &ends
+		}
+		if err := h.app.DB.CreateScheduledSession(s); err != nil {
+			slog.Error("error creating scheduled session", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		details := fmt.Sprintf("Scheduled session %s of app %s for user %s at %s", s.ID, s.AppID, s.UserID, first.Format(time.RFC3339))
+		if s.Recurrence != "" {
+			details += fmt.Sprintf(", recurring %q in %s", s.Recurrence, s.Timezone)
+		}
+		h.logAudit(r, user.Username, "SCHEDULE_SESSION", details)
+
+		saved, err := h.app.DB.GetScheduledSession(s.ID)
+		if err != nil || saved == nil {
+			saved = &s
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(saved)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleScheduledSessionByID returns or cancels a scheduled session launch.
+// Users see the schedules they own or created; admins see all of them.
+func (h *handlers) handleScheduledSessionByID(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/api/sessions/schedule/")
+	if id == "" || strings.Contains(id, "/") {
+		http.Error(w, "Scheduled session not found", http.StatusNotFound)
+		return
+	}
+
+	s, err := h.app.DB.GetScheduledSession(id)
+	if err != nil {
+		slog.Error("error getting scheduled session", "id", id, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if s == nil || (s.UserID != user.ID && s.CreatedBy != user.ID && !middleware.HasRole(user.Roles, middleware.RoleAdmin)) {
+		http.Error(w, "Scheduled session not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s)
+
+	case http.MethodDelete:
+		if err := h.app.DB.DeleteScheduledSession(id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Scheduled session not found", http.StatusNotFound)
+				return
+			}
+			slog.Error("error deleting scheduled session", "id", id, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		h.logAudit(r, user.Username, "DELETE_SCHEDULED_SESSION", fmt.Sprintf("Deleted scheduled session %s of app %s for user %s", s.ID, s.AppID, s.UserID))
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *handlers) handleSessionByID(w http.ResponseWriter, r *http.Request) {
+	remainder := strings.TrimPrefix(r.URL.Path, "/api/sessions/")
+	if remainder == "" {
+		http.Error(w, "Missing session ID", http.StatusBadRequest)
+		return
+	}
+
+	parts := strings.SplitN(remainder, "/", 2)
+	id := parts[0]
+	action := ""
+	if len(parts) > 1 {
+		action = parts[1]
+	}
+
+	switch {
+	case action == "stop":
+		h.handleSessionStop(w, r, id)
+		return
+	case action == "restart":
+		h.handleSessionRestart(w, r, id)
+		return
+	case action == "resume":
+		h.handleSessionResume(w, r, id)
+		return
+	case action == "files" || strings.HasPrefix(action, "files/"):
+		h.app.FileHandler.ServeHTTP(w, r)
+		return
+	case action == "shares" || strings.HasPrefix(action, "shares/"):
+		h.handleSessionShares(w, r, id, strings.TrimPrefix(action, "shares"))
+		return
+	case action == "spectate" || strings.HasPrefix(action, "spectate/"):
+		h.handleSessionSpectate(w, r, id, strings.TrimPrefix(strings.TrimPrefix(action, "spectate"), "/"))
+		return
+	case action == "presence":
+		h.handleSessionPresence(w, r, id)
+		return
+	case action == "stream-stats":
+		h.handleSessionStreamStats(w, r, id)
+		return
+	case action == "effective-policy":
+		h.handleSessionPolicy(w, r, id)
+		return
+	case action == "welcome/dismiss":
+		h.handleSessionWelcomeDismiss(w, r, id)
+		return
+	case action == "unlock":
+		h.handleSessionUnlock(w, r, id)
+		return
+	case strings.HasPrefix(action, "recording/"):
+		if h.app.RecordingHandler != nil {
+			h.app.RecordingHandler.ServeHTTP(w, r)
+		} else {
+			http.Error(w, "Video recording not enabled", http.StatusNotFound)
+		}
+		return
+	case action == "":
+		// Fall through
+	default:
+		http.Error(w, "Unknown session action", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		session, err := h.app.SessionManager.GetSession(r.Context(), id)
+		if err != nil {
+			slog.Error("error getting session", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if session == nil {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+
+		app, _ := h.app.DB.GetApp(session.AppID)
+		appName := ""
+		wsURL := ""
+		guacURL := ""
+		proxyURL := ""
+		if app != nil {
+			appName = app.Name
+			if app.LaunchType == db.LaunchTypeContainer || app.LaunchType == db.LaunchTypeWebProxy {
+				if app.OsType == "windows" {
+					guacURL = h.app.SessionManager.GetSessionGuacWebSocketURL(session)
+				} else {
+					wsURL = h.app.SessionManager.GetSessionWebSocketURL(session)
+				}
+			}
+		}
+
+		response := sessions.SessionFromDB(session, appName, wsURL, guacURL, proxyURL, h.recordingPolicy(app))
+		response.ClipboardPolicy = clipboardPolicy(app)
+		response.FileTransfer = fileTransferPolicy(app)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+
+	case http.MethodDelete:
+		session, err := h.app.SessionManager.GetSession(r.Context(), id)
+		if err != nil {
+			slog.Error("error getting session", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if session == nil {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+
+		if err := h.app.SessionManager.TerminateSession(r.Context(), id); err != nil {
+			slog.Error("error terminating session", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		details := fmt.Sprintf("Terminated session %s", id)
+		h.logAudit(r, "admin", "TERMINATE_SESSION", details)
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *handlers) handleSessionStop(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := h.app.SessionManager.StopSession(r.Context(), id); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "invalid session state") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		slog.Error("error stopping session", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	session, err := h.app.SessionManager.GetSession(r.Context(), id)
+	if err != nil {
+		slog.Error("error getting session after stop", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	app, _ := h.app.DB.GetApp(session.AppID)
+	appName := ""
+	if app != nil {
+		appName = app.Name
+	}
+	response := sessions.SessionFromDB(session, appName, "", "", "", "")
+	response.ClipboardPolicy = clipboardPolicy(app)
+	response.FileTransfer = fileTransferPolicy(app)
+
+	h.logAudit(r, "user", "STOP_SESSION", fmt.Sprintf("Stopped session %s", id))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *handlers) handleSessionRestart(w http.ResponseWriter, r *http.Request, id string) {
+	h.relaunchSession(w, r, id, h.app.SessionManager.RestartSession, "must be stopped", "RESTART_SESSION", "Restarted")
+}
+
+// handleSessionResume recreates the pod of a hibernated session
+// (POST /api/sessions/{id}/resume) with its workspace volume.
+func (h *handlers) handleSessionResume(w http.ResponseWriter, r *http.Request, id string) {
+	h.relaunchSession(w, r, id, h.app.SessionManager.ResumeSession, "must be hibernated", "RESUME_SESSION", "Resumed")
+}
+
+// relaunchSession starts a new workload for a session with relaunch and
+// responds with the session. Errors containing conflict are the session
+// being in the wrong state.
+func (h *handlers) relaunchSession(w http.ResponseWriter, r *http.Request, id string, relaunch func(context.Context, string) (*db.Session, error), conflict, action, verb string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session, err := relaunch(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sessions.ErrShuttingDown) {
+			sessions.WriteRetryAfter(w, 0)
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if _, ok := err.(*sessions.QuotaExceededError); ok {
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		if _, ok := err.(*schedule.ClosedError); ok {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if capErr, ok := err.(*runner.CapacityError); ok {
+			writeCapacityError(w, capErr)
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), conflict) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		slog.Error("error relaunching session", "action", action, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	app, _ := h.app.DB.GetApp(session.AppID)
+	appName := ""
+	wsURL := ""
+	guacURL := ""
+	proxyURL := ""
+	if app != nil {
+		appName = app.Name
+		if app.LaunchType == db.LaunchTypeContainer || app.LaunchType == db.LaunchTypeWebProxy {
+			if app.OsType == "windows" {
+				guacURL = h.app.SessionManager.GetSessionGuacWebSocketURL(session)
+			} else {
+				wsURL = h.app.SessionManager.GetSessionWebSocketURL(session)
+			}
+		}
+	}
+
+	response := sessions.SessionFromDB(session, appName, wsURL, guacURL, proxyURL, h.recordingPolicy(app))
+	response.ClipboardPolicy = clipboardPolicy(app)
+	response.FileTransfer = fileTransferPolicy(app)
+
+	h.logAudit(r, "user", action, fmt.Sprintf("%s session %s", verb, id))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// --- Session sharing endpoints ---
+
+// handleSessionSpectate lets a session owner see admin spectate requests
+// (GET /api/sessions/{id}/spectate) and answer a pending one
+// (POST /api/sessions/{id}/spectate/{grantId} with {"approve": bool}).
+// Grants made under the silent policy are not shown to the owner.
+func (h *handlers) handleSessionSpectate(w http.ResponseWriter, r *http.Request, sessionID, grantID string) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.app.Spectate == nil {
+		http.Error(w, "Spectate not available", http.StatusNotFound)
+		return
+	}
+
+	session, err := h.app.SessionManager.GetSession(r.Context(), sessionID)
+	if err != nil {
+		slog.Error("error getting session for spectate", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if session == nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	isAdmin := middleware.HasRole(user.Roles, middleware.RoleAdmin)
+	if session.UserID != user.ID && !isAdmin {
+		http.Error(w, "Forbidden: only the session owner can manage spectate requests", http.StatusForbidden)
+		return
+	}
+
+	if grantID == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		grants := h.app.Spectate.ForSession(sessionID, isAdmin)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(grants)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if session.UserID != user.ID {
+		http.Error(w, "Forbidden: only the session owner can answer spectate requests", http.StatusForbidden)
+		return
+	}
+	var req struct {
+		Approve bool `json:"approve"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	grant, err := h.app.Spectate.Respond(grantID, sessionID, user.ID, req.Approve)
+	switch {
+	case errors.Is(err, spectate.ErrNotFound):
+		http.Error(w, "Spectate request not found", http.StatusNotFound)
+		return
+	case errors.Is(err, spectate.ErrNotPending):
+		http.Error(w, "Spectate request already answered", http.StatusConflict)
+		return
+	case err != nil:
+		slog.Error("error answering spectate request", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	action := "SPECTATE_DENY"
+	if req.Approve {
+		action = "SPECTATE_APPROVE"
+	}
+	h.logAudit(r, user.Username, action,
+		fmt.Sprintf("Answered spectate request from %s for session %s", grant.AdminUsername, sessionID))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(grant)
+}
+
+// handleSessionPresence lists who is connected to a session's stream. The
+// owner, users the session is shared with, and admins may see it; silent
+// spectators are only listed for admins.
+func (h *handlers) handleSessionPresence(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	session, err := h.app.SessionManager.GetSession(r.Context(), sessionID)
+	if err != nil {
+		slog.Error("error getting session for presence", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if session == nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	isAdmin := middleware.HasRole(user.Roles, middleware.RoleAdmin)
+	if session.UserID != user.ID && !isAdmin {
+		share, err := h.app.DB.CheckSessionAccess(sessionID, user.ID)
+		if err != nil || share == nil {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	viewers := []presence.Viewer{}
+	if h.app.Presence != nil {
+		viewers = h.app.Presence.List(sessionID, isAdmin)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(viewers)
+}
+
+// handleSessionStreamStats returns the streaming quality stats of a
+// session's display stream (GET /api/sessions/{id}/stream-stats), to its
+// owner, users it is shared with, and admins.
+func (h *handlers) handleSessionStreamStats(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	session, err := h.app.SessionManager.GetSession(r.Context(), sessionID)
+	if err != nil {
+		slog.Error("error getting session for stream stats", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if session == nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if session.UserID != user.ID && !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+		share, err := h.app.DB.CheckSessionAccess(sessionID, user.ID)
+		if err != nil || share == nil {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	stats := h.app.StreamStats.Get(sessionID)
+	if stats == nil {
+		stats = &streamstats.Stats{SessionID: sessionID}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// handleSessionPolicy handles GET /api/sessions/{id}/effective-policy,
+// showing the settings the session runs with and which layer each comes
+// from. It is open to the session's owner and admins.
+func (h *handlers) handleSessionPolicy(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	session, err := h.app.SessionManager.GetSession(r.Context(), sessionID)
+	if err != nil {
+		slog.Error("error getting session for policy", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if session == nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if session.UserID != user.ID && !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	app, err := h.app.DB.GetApp(session.AppID)
+	if err != nil {
+		slog.Error("error getting app", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if app == nil {
+		http.Error(w, "Application not found", http.StatusNotFound)
+		return
+	}
+
+	policy, err := h.app.SessionManager.SessionPolicy(session, app)
+	if err != nil {
+		slog.Error("error resolving session policy", "session", session.ID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
+
+// handleMetrics serves stream, database and Kubernetes API metrics in the
+// Prometheus text format.
+func (h *handlers) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	h.app.StreamStats.WritePrometheus(w)
+	h.app.DB.WritePrometheus(w)
+	h.app.BackpressureHandler.WritePrometheus(w)
+	k8s.WritePrometheus(w)
+}
+
+// handleAdminAutoscaling reports the session load and the demand for each
+// app (GET /api/admin/autoscaling), in a shape the KEDA metrics-api
+// scaler reads.
+func (h *handlers) handleAdminAutoscaling(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.app.BackpressureHandler == nil {
+		http.Error(w, "Session load is not available", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.app.BackpressureHandler.AutoscalingSignals())
+}
+
+// handleSessionWelcomeDismiss records that the session owner dismissed the
+// app's welcome message (POST /api/sessions/{id}/welcome/dismiss), so it is
+// not shown again on later connects.
+func (h *handlers) handleSessionWelcomeDismiss(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	session, err := h.app.SessionManager.GetSession(r.Context(), sessionID)
+	if err != nil {
+		slog.Error("error getting session for welcome dismiss", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if session == nil || session.UserID != user.ID {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.app.DB.DismissSessionWelcome(sessionID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("error dismissing session welcome", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(r, user.Username, "DISMISS_WELCOME", "session="+sessionID+" app="+session.AppID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSessionUnlock unlocks a session whose stream was locked for going
+// idle (POST /api/sessions/{id}/unlock). Only the owner can unlock it, and
+// only with a token from signing in again after it was locked.
+func (h *handlers) handleSessionUnlock(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	session, err := h.app.SessionManager.GetSession(r.Context(), sessionID)
+	if err != nil {
+		slog.Error("error getting session for unlock", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if session == nil || session.UserID != user.ID {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if session.LockedAt == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// auth_time has second precision, so a sign-in within the second the
+	// session was locked counts as after it
+	authTime, err := strconv.ParseInt(user.Metadata["auth_time"], 10, 64)
+	if err != nil || time.Unix(authTime, 0).Before(session.LockedAt.Truncate(time.Second)) {
+		http.Error(w, "Sign in again to unlock this session", http.StatusForbidden)
+		return
+	}
+
+	if err := h.app.DB.UnlockSession(sessionID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("error unlocking session", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(r, user.Username, "SESSION_UNLOCKED", "session="+sessionID+" app="+session.AppID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handlers) handleSessionShares(w http.ResponseWriter, r *http.Request, sessionID string, subPath string) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Check that the session exists
+	session, err := h.app.SessionManager.GetSession(r.Context(), sessionID)
+	if err != nil {
+		slog.Error("error getting session for shares", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if session == nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	// Only the session owner can manage shares
+	isOwner := session.UserID == user.ID
+
+	// Handle DELETE /api/sessions/{id}/shares/{shareId}
+	shareID := strings.TrimPrefix(subPath, "/")
+	if shareID != "" {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !isOwner && !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+			http.Error(w, "Forbidden: only session owner can revoke shares", http.StatusForbidden)
+			return
+		}
+		if err := h.app.DB.DeleteSessionShare(shareID); err != nil {
+			http.Error(w, "Share not found", http.StatusNotFound)
+			return
+		}
+		h.logAudit(r, user.Username, "REVOKE_SESSION_SHARE", fmt.Sprintf("Revoked share %s for session %s", shareID, sessionID))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if !isOwner && !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+			http.Error(w, "Forbidden: only session owner can list shares", http.StatusForbidden)
+			return
+		}
+		shares, err := h.app.DB.ListSessionShares(sessionID)
+		if err != nil {
+			slog.Error("error listing session shares", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if shares == nil {
+			shares = []db.SessionShare{}
+		}
+
+		responses := make([]sessions.ShareResponse, len(shares))
+		for i, s := range shares {
+			resp := sessions.ShareResponse{
+				ID:         s.ID,
+				SessionID:  s.SessionID,
+				UserID:     s.UserID,
+				Permission: string(s.Permission),
+				CreatedAt:  s.CreatedAt.Format(time.RFC3339),
+			}
+			if s.UserID != "" {
+				u, _ := h.app.DB.GetUserByID(s.UserID)
+				if u != nil {
+					resp.Username = u.Username
+				}
+			}
+			if s.ShareToken != "" {
+				resp.ShareURL = fmt.Sprintf("/session/%s?share_token=%s", sessionID, s.ShareToken)
+			}
+			responses[i] = resp
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(responses)
+
+	case http.MethodPost:
+		if !isOwner && !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+			http.Error(w, "Forbidden: only session owner can create shares", http.StatusForbidden)
+			return
+		}
+
+		// Only container sessions can be shared
+		app, _ := h.app.DB.GetApp(session.AppID)
+		if app != nil && app.LaunchType != db.LaunchTypeContainer {
+			http.Error(w, "Only container sessions can be shared", http.StatusBadRequest)
+			return
+		}
+
+		var req sessions.CreateShareRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		perm := db.SharePermissionReadOnly
+		if req.Permission == "read_write" {
+			perm = db.SharePermissionReadWrite
+		}
+
+		share := db.SessionShare{
+			ID:         fmt.Sprintf("share-%d", time.Now().UnixNano()),
+			SessionID:  sessionID,
+			Permission: perm,
+			CreatedBy:  user.ID,
+			CreatedAt:  time.Now(),
+		}
+
+		resp := sessions.ShareResponse{
+			SessionID:  sessionID,
+			Permission: string(perm),
+		}
+
+		if req.LinkShare {
+			token := fmt.Sprintf("%d", time.Now().UnixNano())
+			share.ShareToken = token
+			resp.ShareURL = fmt.Sprintf("/session/%s?share_token=%s", sessionID, token)
+		} else {
+			// Resolve user by username or ID
+			targetUserID := req.UserID
+			if targetUserID == "" && req.Username != "" {
+				u, err := h.app.DB.GetUserByUsername(req.Username)
+				if err != nil || u == nil {
+					http.Error(w, "User not found", http.StatusNotFound)
+					return
+				}
+				targetUserID = u.ID
+				resp.Username = u.Username
+			}
+			if targetUserID == "" {
+				http.Error(w, "Either user_id, username, or link_share is required", http.StatusBadRequest)
+				return
+			}
+			if targetUserID == user.ID {
+				http.Error(w, "Cannot share a session with yourself", http.StatusBadRequest)
+				return
+			}
+			share.UserID = targetUserID
+		}
+
+		if err := h.app.DB.CreateSessionShare(share); err != nil {
+			slog.Error("error creating session share", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		resp.ID = share.ID
+		resp.UserID = share.UserID
+		resp.CreatedAt = share.CreatedAt.Format(time.RFC3339)
+
+		h.logAudit(r, user.Username, "CREATE_SESSION_SHARE", fmt.Sprintf("Shared session %s (permission=%s)", sessionID, perm))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(resp)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *handlers) handleSharedSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	rows, err := h.app.DB.ListSharedSessionsForUser(user.ID)
+	if err != nil {
+		slog.Error("error listing shared sessions", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	responses := make([]sessions.SessionResponse, 0, len(rows))
+	for _, r := range rows {
+		app, _ := h.app.DB.GetApp(r.Session.AppID)
+		wsURL := ""
+		guacURL := ""
+		if app != nil {
+			if app.LaunchType == db.LaunchTypeContainer {
+				if app.OsType == "windows" {
+					guacURL = h.app.SessionManager.GetSessionGuacWebSocketURL(&r.Session)
+				} else {
+					wsURL = h.app.SessionManager.GetSessionWebSocketURL(&r.Session)
+				}
+			}
+		}
+
+		resp := *sessions.SessionFromDB(&r.Session, r.AppName, wsURL, guacURL, "", "")
+		resp.ClipboardPolicy = clipboardPolicy(app)
+		resp.FileTransfer = fileTransferPolicy(app)
+		resp.IsShared = true
+		resp.OwnerUsername = r.OwnerUsername
+		resp.SharePermission = string(r.Permission)
+		resp.ShareID = r.ShareID
+		responses = append(responses, resp)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(responses)
+}
+
+func (h *handlers) handleJoinShare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req sessions.JoinShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Token == "" {
+		http.Error(w, "Token is required", http.StatusBadRequest)
+		return
+	}
+
+	share, err := h.app.DB.GetSessionShareByToken(req.Token)
+	if err != nil {
+		slog.Error("error looking up share token", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if share == nil {
+		http.Error(w, "Invalid or expired share token", http.StatusNotFound)
+		return
+	}
+
+	// If this is a link-share with no user assigned, assign this user
+	if share.UserID == "" {
+		h.app.DB.UpdateSessionShareUserID(share.ID, user.ID)
+	} else if share.UserID != user.ID {
+		// Link was already claimed by someone else; create a new share for this user
+		newShare := db.SessionShare{
+			ID:         fmt.Sprintf("share-%d", time.Now().UnixNano()),
+			SessionID:  share.SessionID,
+			UserID:     user.ID,
+			Permission: share.Permission,
+			CreatedBy:  share.CreatedBy,
+			CreatedAt:  time.Now(),
+		}
+		if err := h.app.DB.CreateSessionShare(newShare); err != nil {
+			slog.Error("error creating share for joining user", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Return the session info
+	session, err := h.app.SessionManager.GetSession(r.Context(), share.SessionID)
+	if err != nil || session == nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	app, _ := h.app.DB.GetApp(session.AppID)
+	appName := ""
+	wsURL := ""
+	guacURL := ""
+	if app != nil {
+		appName = app.Name
+		if app.LaunchType == db.LaunchTypeContainer {
+			if app.OsType == "windows" {
+				guacURL = h.app.SessionManager.GetSessionGuacWebSocketURL(session)
+			} else {
+				wsURL = h.app.SessionManager.GetSessionWebSocketURL(session)
+			}
+		}
+	}
+
+	resp := *sessions.SessionFromDB(session, appName, wsURL, guacURL, "", "")
+	resp.ClipboardPolicy = clipboardPolicy(app)
+	resp.FileTransfer = fileTransferPolicy(app)
+	resp.IsShared = true
+	resp.SharePermission = string(share.Permission)
+
+	// Get owner username
+	owner, _ := h.app.DB.GetUserByID(session.UserID)
+	if owner != nil {
+		resp.OwnerUsername = owner.Username
+	}
+
+	h.logAudit(r, user.Username, "JOIN_SESSION_SHARE", fmt.Sprintf("Joined session %s via share token", share.SessionID))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// --- Quota endpoint ---
+
+func (h *handlers) handleQuotas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	userID := "anonymous"
+	if user != nil {
+		userID = user.ID
+	}
+
+	status, err := h.app.SessionManager.GetQuotaStatus(userID)
+	if err != nil {
+		slog.Error("error getting quota status", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// --- Project endpoints ---
+
+// projectRequest is the body of POST /api/projects and PUT
+// /api/projects/{id}.
+type projectRequest struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	MaxSessions int              `json:"max_sessions"`
+	Drive       *db.ProjectDrive `json:"drive"`
+}
+
+func (req *projectRequest) validate() error {
+	if strings.TrimSpace(req.Name) == "" {
+		return errors.New("Missing required field: name")
+	}
+	if req.MaxSessions < 0 {
+		return errors.New("max_sessions must not be negative")
+	}
+	if err := k8s.ValidateProjectDrive(req.Drive); err != nil {
+		return fmt.Errorf("invalid drive: %w", err)
+	}
+	return nil
+}
+
+// projectMemberResponse is a project member with their username.
+type projectMemberResponse struct {
+	db.ProjectMember
+	Username string `json:"username,omitempty"`
+}
+
+// handleProjects lists the projects the caller is a member of and creates
+// projects, owned by the caller. Only admins may set a project's session
+// limit or drive.
+func (h *handlers) handleProjects(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		projects, err := h.app.DB.ListProjectsForUser(user.ID)
+		if err != nil {
+			slog.Error("error listing projects", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(projects)
+
+	case http.MethodPost:
+		var req projectRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := req.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if (req.MaxSessions != 0 || req.Drive != nil) && !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
+			http.Error(w, "Only admins can set a project's session limit or drive", http.StatusForbidden)
+			return
+		}
+
+		now := time.Now()
+		p := db.Project{
+			ID:          uuid.New().String(),
+			TenantID:    middleware.GetTenantIDFromContext(r.Context()),
+			Name:        strings.TrimSpace(req.Name),
+			Description: req.Description,
+			MaxSessions: req.MaxSessions,
+			Drive:       req.Drive,
+			CreatedBy:   user.ID,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if err := h.app.DB.CreateProject(p); err != nil {
+			slog.Error("error creating project", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		h.logAudit(r, user.Username, "CREATE_PROJECT", fmt.Sprintf("Created project %s (%s)", p.Name, p.ID))
+
+		saved, err := h.app.DB.GetProject(p.ID)
+		if err != nil || saved == nil {
+			saved = &p
+		}
+		saved.Role = db.ProjectRoleOwner
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(saved)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleProjectByID serves a project to its members, and its members,
+// sessions and shares:
+//
+//	GET/PUT/DELETE /api/projects/{id}
+//	GET/POST       /api/projects/{id}/members
+//	DELETE         /api/projects/{id}/members/{userID}
+//	GET            /api/projects/{id}/sessions
+//	GET            /api/projects/{id}/shares
+//
+// Owners and admins manage the project and its members; members may leave.
+func (h *handlers) handleProjectByID(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/projects/"), "/")
+	projectID := parts[0]
+	if projectID == "" {
+		http.Error(w, "Project ID required", http.StatusBadRequest)
+		return
+	}
+
+	project, err := h.app.DB.GetProject(projectID)
+	if err != nil {
+		slog.Error("error getting project", "id", projectID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	var member *db.ProjectMember
+	if project != nil {
+		member, err = h.app.DB.GetProjectMember(projectID, user.ID)
+		if err != nil {
+			slog.Error("error getting project member", "id", projectID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+	isAdmin := middleware.HasRole(user.Roles, middleware.RoleAdmin)
+	if project == nil || (member == nil && !isAdmin) {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	if member != nil {
+		project.Role = member.Role
+	}
+	canManage := isAdmin || project.Role == db.ProjectRoleOwner
+
+	if len(parts) > 1 {
+		switch parts[1] {
+		case "members":
+			h.handleProjectMembers(w, r, user, project, canManage, parts[2:])
+		case "sessions":
+			h.handleProjectSessions(w, r, project)
+		case "shares":
+			h.handleProjectShares(w, r, project, canManage)
+		default:
+			http.Error(w, "Not found", http.StatusNotFound)
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(project)
+
+	case http.MethodPut:
+		if !canManage {
+			http.Error(w, "Only project owners can update a project", http.StatusForbidden)
+			return
+		}
+		var req projectRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := req.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Only admins may change the session limit or drive; owners keep
+		// the current ones when they leave the fields out
+		if !isAdmin {
+			if req.MaxSessions == 0 {
+				req.MaxSessions = project.MaxSessions
+			}
+			if req.Drive == nil {
+				req.Drive = project.Drive
+			}
+			if req.MaxSessions != project.MaxSessions || !reflect.DeepEqual(req.Drive, project.Drive) {
+				http.Error(w, "Only admins can set a project's session limit or drive", http.StatusForbidden)
+				return
+			}
+		}
+
+		project.Name = strings.TrimSpace(req.Name)
+		project.Description = req.Description
+		project.MaxSessions = req.MaxSessions
+		project.Drive = req.Drive
+		if err := h.app.DB.UpdateProject(*project); err != nil {
+			slog.Error("error updating project", "id", projectID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		h.logAudit(r, user.Username, "UPDATE_PROJECT", fmt.Sprintf("Updated project %s (%s)", project.Name, project.ID))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(project)
+
+	case http.MethodDelete:
+		if !canManage {
+			http.Error(w, "Only project owners can delete a project", http.StatusForbidden)
+			return
+		}
+		if err := h.app.SessionManager.DeleteProject(r.Context(), projectID); err != nil {
+			if errors.Is(err, sessions.ErrProjectInUse) {
+				http.Error(w, "Project has active sessions; stop them before deleting it", http.StatusConflict)
+				return
+			}
+			slog.Error("error deleting project", "id", projectID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		h.logAudit(r, user.Username, "DELETE_PROJECT", fmt.Sprintf("Deleted project %s (%s)", project.Name, project.ID))
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleProjectMembers lists a project's members to its members, and lets
+// owners and admins add members, change their roles and remove them.
+// Members may remove themselves. A project always keeps an owner.
+func (h *handlers) handleProjectMembers(w http.ResponseWriter, r *http.Request, user *plugins.User, project *db.Project, canManage bool, rest []string) {
+	if len(rest) > 0 && rest[0] != "" {
+		if len(rest) > 1 || r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		userID := rest[0]
+		if !canManage && userID != user.ID {
+			http.Error(w, "Only project owners can remove other members", http.StatusForbidden)
+			return
+		}
+		existing, err := h.app.DB.GetProjectMember(project.ID, userID)
+		if err != nil {
+			slog.Error("error getting project member", "id", project.ID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if existing == nil {
+			http.Error(w, "Member not found", http.StatusNotFound)
+			return
+		}
+		if existing.Role == db.ProjectRoleOwner && !h.hasOtherOwner(w, project.ID) {
+			return
+		}
+		if err := h.app.DB.DeleteProjectMember(project.ID, userID); err != nil {
+			slog.Error("error removing project member", "id", project.ID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		h.logAudit(r, user.Username, "REMOVE_PROJECT_MEMBER", fmt.Sprintf("Removed user %s from project %s", userID, project.ID))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		members, err := h.app.DB.ListProjectMembers(project.ID)
+		if err != nil {
+			slog.Error("error listing project members", "id", project.ID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		responses := make([]projectMemberResponse, len(members))
+		for i, m := range members {
+			responses[i] = projectMemberResponse{ProjectMember: m}
+			if u, _ := h.app.DB.GetUserByID(m.UserID); u != nil {
+				responses[i].Username = u.Username
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(responses)
+
+	case http.MethodPost:
+		if !canManage {
+			http.Error(w, "Only project owners can add members", http.StatusForbidden)
+			return
+		}
+		var req struct {
+			UserID   string         `json:"user_id"`
+			Username string         `json:"username"`
+			Role     db.ProjectRole `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if req.Role == "" {
+			req.Role = db.ProjectRoleMember
+		}
+		if !req.Role.Valid() {
+			http.Error(w, "role must be owner, member or viewer", http.StatusBadRequest)
+			return
+		}
+
+		var target *db.User
+		var err error
+		switch {
+		case req.UserID != "":
+			target, err = h.app.DB.GetUserByID(req.UserID)
+		case req.Username != "":
+			target, err = h.app.DB.GetUserByUsername(req.Username)
+		default:
+			http.Error(w, "Missing required field: user_id or username", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			slog.Error("error getting user", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if target == nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		existing, err := h.app.DB.GetProjectMember(project.ID, target.ID)
+		if err != nil {
+			slog.Error("error getting project member", "id", project.ID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if existing != nil && existing.Role == db.ProjectRoleOwner && req.Role != db.ProjectRoleOwner && !h.hasOtherOwner(w, project.ID) {
+			return
+		}
+		m := db.ProjectMember{ProjectID: project.ID, UserID: target.ID, Role: req.Role}
+		if existing != nil {
+			m.CreatedAt = existing.CreatedAt
+		}
+		if err := h.app.DB.SetProjectMember(m); err != nil {
+			slog.Error("error setting project member", "id", project.ID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		h.logAudit(r, user.Username, "SET_PROJECT_MEMBER", fmt.Sprintf("Set user %s as %s of project %s", target.Username, m.Role, project.ID))
+
+		saved, err := h.app.DB.GetProjectMember(project.ID, target.ID)
+		if err != nil || saved == nil {
+			saved = &m
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(projectMemberResponse{ProjectMember: *saved, Username: target.Username})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// hasOtherOwner reports whether a project has more than one owner, so one
+// can be removed or demoted, and replies with a conflict if it does not.
+func (h *handlers) hasOtherOwner(w http.ResponseWriter, projectID string) bool {
+	owners, err := h.app.DB.CountProjectOwners(projectID)
+	if err != nil {
+		slog.Error("error counting project owners", "id", projectID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+	if owners <= 1 {
+		http.Error(w, "A project must keep at least one owner", http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// handleProjectSessions lists the sessions launched in a project that
+// have not terminated or failed, newest first.
+func (h *handlers) handleProjectSessions(w http.ResponseWriter, r *http.Request, project *db.Project) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sessionList, err := h.app.DB.ListSessionsByProject(project.ID)
+	if err != nil {
+		slog.Error("error listing project sessions", "id", project.ID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.sessionResponses(sessionList))
+}
+
+// handleProjectShares lists the shares of the sessions launched in a
+// project. Share links are only included for owners and admins.
+func (h *handlers) handleProjectShares(w http.ResponseWriter, r *http.Request, project *db.Project, canManage bool) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	shares, err := h.app.DB.ListProjectShares(project.ID)
+	if err != nil {
+		slog.Error("error listing project shares", "id", project.ID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	responses := make([]sessions.ShareResponse, len(shares))
+	for i, s := range shares {
+		resp := sessions.ShareResponse{
+			ID:         s.ID,
+			SessionID:  s.SessionID,
+			UserID:     s.UserID,
+			Permission: string(s.Permission),
+			CreatedAt:  s.CreatedAt.Format(time.RFC3339),
+		}
+		if s.UserID != "" {
+			if u, _ := h.app.DB.GetUserByID(s.UserID); u != nil {
+				resp.Username = u.Username
+			}
+		}
+		if s.ShareToken != "" && canManage {
+			resp.ShareURL = fmt.Sprintf("/session/%s?share_token=%s", s.SessionID, s.ShareToken)
+		}
+		responses[i] = resp
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(responses)
+}
+
+// handleAdminProjects lists every project, optionally only those of one
+// tenant (tenant parameter).
+func (h *handlers) handleAdminProjects(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	projects, err := h.app.DB.ListProjects(r.URL.Query().Get("tenant"))
+	if err != nil {
+		slog.Error("error listing projects", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(projects)
+}
+
+// --- Audit endpoints ---
+
+func (h *handlers) handleAuditLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.app.DB.QueryAuditLogs(filter)
+	if err != nil {
+		slog.Error("error querying audit logs", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if page.Logs == nil {
+		page.Logs = []db.AuditLog{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+func (h *handlers) handleAuditExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.Limit <= 0 || filter.Limit > 10000 {
+		filter.Limit = 10000
+	}
+	filter.Offset = 0
+
+	page, err := h.app.DB.QueryAuditLogs(filter)
+	if err != nil {
+		slog.Error("error exporting audit logs", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if page.Logs == nil {
+		page.Logs = []db.AuditLog{}
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", "attachment; filename=audit_log.csv")
+		writeAuditCSV(w, page.Logs)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", "attachment; filename=audit_log.json")
+	json.NewEncoder(w).Encode(page.Logs)
+}
+
+func (h *handlers) handleAuditFilters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	actions, err := h.app.DB.GetAuditLogActions()
+	if err != nil {
+		slog.Error("error getting audit actions", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	users, err := h.app.DB.GetAuditLogUsers()
+	if err != nil {
+		slog.Error("error getting audit users", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if actions == nil {
+		actions = []string{}
+	}
+	if users == nil {
+		users = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{
+		"actions": actions,
+		"users":   users,
+	})
+}
+
+func parseAuditFilter(r *http.Request) (db.AuditLogFilter, error) {
+	q := r.URL.Query()
+	filter := db.AuditLogFilter{
+		User:      q.Get("user"),
+		Action:    q.Get("action"),
+		RequestID: q.Get("request_id"),
+	}
+
+	if from := q.Get("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return filter, fmt.Errorf("invalid 'from' date: %w", err)
+		}
+		filter.From = t
+	}
+	if to := q.Get("to"); to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return filter, fmt.Errorf("invalid 'to' date: %w", err)
+		}
+		filter.To = t
+	}
+	if limitStr := q.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			return filter, fmt.Errorf("invalid 'limit': %w", err)
+		}
+		filter.Limit = limit
+	}
+	if offsetStr := q.Get("offset"); offsetStr != "" {
+		offset, err := strconv.Atoi(offsetStr)
+		if err != nil {
+			return filter, fmt.Errorf("invalid 'offset': %w", err)
+		}
+		filter.Offset = offset
+	}
+
+	return filter, nil
+}
+
+func writeAuditCSV(w io.Writer, logs []db.AuditLog) {
+	fmt.Fprintf(w, "ID,Timestamp,User,Action,Details,Request ID\n")
+	for _, log := range logs {
+		details := strings.ReplaceAll(log.Details, "\"", "\"\"")
+		fmt.Fprintf(w, "%d,%s,%s,%s,\"%s\",%s\n",
+			log.ID,
+			log.Timestamp.Format(time.RFC3339),
+			log.User,
+			log.Action,
+			details,
+			log.RequestID,
+		)
+	}
+}
+
+// --- Analytics endpoints ---
+
+func (h *handlers) handleAnalyticsLaunch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		AppID string `json:"app_id"`
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.AppID == "" {
+		http.Error(w, "Missing required field: app_id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.app.DB.RecordLaunch(req.AppID); err != nil {
+		slog.Error("error recording launch", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"status": "recorded"})
+}
+
+func (h *handlers) handleAnalyticsStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats, err := h.app.DB.GetAnalyticsStats()
+	if err != nil {
+		slog.Error("error getting analytics stats", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// --- Admin endpoints ---
+
+func (h *handlers) handleAdminSettings(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		response, err := h.adminSettings()
+		if err != nil {
+			slog.Error("error getting settings", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+
+	case http.MethodPut:
+		var req map[string]string
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		if err := h.validateSettings(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// With the two-person rule on, changes to high-impact settings are
+		// staged for another admin to approve; the rest apply now
+		user := middleware.GetUserFromContext(r.Context())
+		staged, err := h.stagedSettings(req)
+		if err != nil {
+			slog.Error("error checking settings approval", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		for key := range staged {
+			delete(req, key)
+		}
+
+		for key, value := range req {
+			if err := h.app.DB.SetSetting(key, value); err != nil {
+				slog.Error("error updating setting", "key", key, "error", err)
+				http.Error(w, "Failed to update settings", http.StatusInternalServerError)
+				return
+			}
+		}
+		if len(req) > 0 {
+			h.logAudit(r, user.Username, "UPDATE_SETTINGS", fmt.Sprintf("Updated settings: %v", req))
+		}
+
+		if len(staged) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		change, err := h.requestSettingChange(r, db.SettingChangeRequest{Changes: staged})
+		if err != nil {
+			slog.Error("error creating setting change request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(change)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// validateSettings checks the values of settings an admin sets.
+func (h *handlers) validateSettings(req map[string]string) error {
+	if _, ok := req[tokenPolicySettingKey]; ok {
+		return errors.New("token_policy is read-only; configure it per tenant")
+	}
+	if v, ok := req["update_channel"]; ok && v != updatecheck.ChannelStable && v != updatecheck.ChannelPrerelease {
+		return errors.New("update_channel must be \"stable\" or \"prerelease\"")
+	}
+	for _, key := range []string{"allow_passkeys", "status_page_enabled", "status_page_uptime", settingsApprovalKey} {
+		if v, ok := req[key]; ok {
+			if _, err := strconv.ParseBool(v); err != nil {
+				return errors.New(key + " must be \"true\" or \"false\"")
+			}
+		}
+	}
+	if on, _ := strconv.ParseBool(req["allow_passkeys"]); on && !h.passkeysConfigured() {
+		return errors.New("allow_passkeys requires SORTIE_WEBAUTHN_RP_ID and SORTIE_WEBAUTHN_ORIGINS")
+	}
+	for _, key := range []string{"auth_rate_limit", "session_rate_limit"} {
+		if v, ok := req[key]; ok {
+			if rate, err := strconv.ParseFloat(v, 64); err != nil || rate < 0 {
+				return errors.New(key + " must be a non-negative number")
+			}
+		}
+	}
+	for _, key := range []string{"auth_rate_burst", "session_rate_burst"} {
+		if v, ok := req[key]; ok {
+			if b, err := strconv.Atoi(v); err != nil || b < 1 {
+				return errors.New(key + " must be a positive integer")
+			}
+		}
+	}
+	return nil
+}
+
+// adminSettings returns the admin settings: those stored in the database,
+// over the configured values of the ones that have not been set, and the
+// effective token policies.
+func (h *handlers) adminSettings() (map[string]interface{}, error) {
+	settings, err := h.app.DB.GetAllSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	response := map[string]interface{}{
+		"allow_registration":     h.isRegistrationAllowed(),
+		"allow_passkeys":         h.passkeysAllowed(),
+		"max_sessions_per_user":  h.app.Config.MaxSessionsPerUser,
+		"max_global_sessions":    h.app.Config.MaxGlobalSessions,
+		"default_cpu_request":    h.app.Config.DefaultCPURequest,
+		"default_cpu_limit":      h.app.Config.DefaultCPULimit,
+		"default_memory_request": h.app.Config.DefaultMemRequest,
+		"default_memory_limit":   h.app.Config.DefaultMemLimit,
+		"update_check":           h.app.Config.UpdateCheck,
+		"update_channel":         h.app.Config.UpdateChannel,
+		"auth_rate_limit":        h.app.Config.AuthRateLimit,
+		"auth_rate_burst":        h.app.Config.AuthRateBurst,
+		"session_rate_limit":     h.app.Config.SessionRateLimit,
+		"session_rate_burst":     h.app.Config.SessionRateBurst,
+		settingsApprovalKey:      false,
+	}
+
+	for k, v := range settings {
+		response[k] = v
+	}
+
+	tokenPolicy, err := h.tokenPolicySettings()
+	if err != nil {
+		return nil, fmt.Errorf("resolve token policies: %w", err)
+	}
+	response[tokenPolicySettingKey] = tokenPolicy
+	return response, nil
+}
+
+// settingsApprovalKey is the setting that turns on the two-person rule for
+// the settings in approvalSettings.
+const settingsApprovalKey = "settings_approval"
+
+// approvalSettings are the high-impact settings whose changes need a second
+// admin's approval while settings approval is on. Tenant quotas and egress
+// policies are staged too; see handleAdminTenantByID.
+var approvalSettings = map[string]bool{
+	"recording_auto_record": true,
+	settingsApprovalKey:     true,
+}
+
+// settingsApprovalOn reports whether the two-person rule is on.
+func (h *handlers) settingsApprovalOn() (bool, error) {
+	v, err := h.app.DB.GetSetting(settingsApprovalKey)
+	if err != nil {
+		return false, err
+	}
+	on, _ := strconv.ParseBool(v)
+	return on, nil
+}
+
+// stagedSettings returns the changes in req that must be approved by another
+// admin instead of being applied: those that change a high-impact setting
+// while settings approval is on. Turning settings approval on is never staged.
+func (h *handlers) stagedSettings(req map[string]string) (map[string]string, error) {
+	if on, err := h.settingsApprovalOn(); err != nil || !on {
+		return nil, err
+	}
+	current, err := h.adminSettings()
+	if err != nil {
+		return nil, err
+	}
+	// Unset, recording_auto_record is off
+	if _, ok := current["recording_auto_record"]; !ok {
+		current["recording_auto_record"] = "false"
+	}
+
+	staged := map[string]string{}
+	for key, value := range req {
+		if !approvalSettings[key] {
+			continue
+		}
+		if v, ok := current[key]; ok && fmt.Sprint(v) == value {
+			continue
+		}
+		staged[key] = value
+	}
+	return staged, nil
+}
+
+// requestSettingChange stages a change for another admin to approve.
+func (h *handlers) requestSettingChange(r *http.Request, change db.SettingChangeRequest) (*db.SettingChangeRequest, error) {
+	user := middleware.GetUserFromContext(r.Context())
+	change.ID = uuid.New().String()
+	change.RequestedBy = user.Username
+	if change.Changes == nil {
+		change.Changes = map[string]string{}
+	}
+	if err := h.app.DB.CreateSettingChangeRequest(change); err != nil {
+		return nil, err
+	}
+
+	h.logAudit(r, user.Username, "REQUEST_SETTINGS_CHANGE", fmt.Sprintf("Requested settings change %s: %s", change.ID, describeSettingChange(change)))
+	return h.app.DB.GetSettingChangeRequest(change.ID)
+}
+
+// describeSettingChange summarizes a change request for the audit log.
+func describeSettingChange(change db.SettingChangeRequest) string {
+	if change.Tenant == nil {
+		return fmt.Sprint(change.Changes)
+	}
+	desc := fmt.Sprintf("tenant %s quotas %+v", change.Tenant.TenantID, change.Tenant.Quotas)
+	if p := change.Tenant.EgressPolicy; p != nil {
+		desc += fmt.Sprintf(", egress policy %s with %d rules", p.Mode, len(p.Rules))
+	} else {
+		desc += ", no egress policy"
+	}
+	if len(change.Changes) > 0 {
+		desc = fmt.Sprint(change.Changes) + ", " + desc
+	}
+	return desc
+}
+
+// settingChangeRequest is the body of a setting change request.
+type settingChangeRequest struct {
+	Changes map[string]string `json:"changes"`
+	Reason  string            `json:"reason"`
+}
+
+// handleAdminSettingChanges lists setting change requests (GET, optionally
+// filtered by ?status=) and stages a new one (POST).
+func (h *handlers) handleAdminSettingChanges(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		status := r.URL.Query().Get("status")
+		switch status {
+		case "", db.SettingChangePending, db.SettingChangeApproved, db.SettingChangeRejected, db.SettingChangeCancelled:
+		default:
+			http.Error(w, "Invalid status", http.StatusBadRequest)
+			return
+		}
+
+		requests, err := h.app.DB.ListSettingChangeRequests(status)
+		if err != nil {
+			slog.Error("error listing setting change requests", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if requests == nil {
+			requests = []db.SettingChangeRequest{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"requests": requests})
+
+	case http.MethodPost:
+		var req settingChangeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if len(req.Changes) == 0 {
+			http.Error(w, "changes is required", http.StatusBadRequest)
+			return
+		}
+		if err := h.validateSettings(req.Changes); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		change, err := h.requestSettingChange(r, db.SettingChangeRequest{Changes: req.Changes, Reason: req.Reason})
+		if err != nil {
+			slog.Error("error creating setting change request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(change)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminSettingChangeByID gets a setting change request, and approves,
+// rejects or cancels a pending one. Only an admin other than the requester
+// may approve or reject it, and only the requester may cancel it.
+func (h *handlers) handleAdminSettingChangeByID(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/admin/setting-changes/")
+	id, action, _ := strings.Cut(id, "/")
+	if id == "" {
+		http.Error(w, "Setting change request ID required", http.StatusBadRequest)
+		return
+	}
+
+	var status, auditAction string
+	switch action {
+	case "":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+	case "approve":
+		status, auditAction = db.SettingChangeApproved, "APPROVE_SETTINGS_CHANGE"
+	case "reject":
+		status, auditAction = db.SettingChangeRejected, "REJECT_SETTINGS_CHANGE"
+	case "cancel":
+		status, auditAction = db.SettingChangeCancelled, "CANCEL_SETTINGS_CHANGE"
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if action != "" && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	change, err := h.app.DB.GetSettingChangeRequest(id)
+	if err != nil {
+		slog.Error("error getting setting change request", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if change == nil {
+		http.Error(w, "Setting change request not found", http.StatusNotFound)
+		return
+	}
+
+	if action != "" {
+		var req struct {
+			Comment string `json:"comment"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid JSON", http.StatusBadRequest)
+				return
+			}
+		}
+
+		user := middleware.GetUserFromContext(r.Context())
+		requester := user.Username == change.RequestedBy
+		if status == db.SettingChangeCancelled && !requester {
+			http.Error(w, "Only the requester can cancel a setting change request", http.StatusForbidden)
+			return
+		}
+		if status != db.SettingChangeCancelled && requester {
+			http.Error(w, "Setting change requests must be reviewed by another admin", http.StatusForbidden)
+			return
+		}
+		// The rules may have changed since the request was staged
+		if status == db.SettingChangeApproved {
+			if err := h.validateSettings(change.Changes); err != nil {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			if change.Tenant != nil {
+				defaults := db.PolicyDefaults{EgressPolicy: change.Tenant.EgressPolicy}
+				if err := defaults.Validate(); err != nil {
+					http.Error(w, err.Error(), http.StatusConflict)
+					return
+				}
+				tenant, err := h.app.DB.GetTenant(change.Tenant.TenantID)
+				if err != nil {
+					slog.Error("error getting tenant", "error", err)
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+					return
+				}
+				if tenant == nil {
+					http.Error(w, "Tenant no longer exists", http.StatusConflict)
+					return
+				}
+			}
+		}
+
+		if err := h.app.DB.ReviewSettingChangeRequest(id, status, user.Username, req.Comment); err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Setting change request is not pending", http.StatusConflict)
+				return
+			}
+			slog.Error("error reviewing setting change request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		details := fmt.Sprintf("Settings change %s requested by %s: %s", id, change.RequestedBy, describeSettingChange(*change))
+		if req.Comment != "" {
+			details += " (" + req.Comment + ")"
+		}
+		h.logAudit(r, user.Username, auditAction, details)
+
+		change, err = h.app.DB.GetSettingChangeRequest(id)
+		if err != nil || change == nil {
+			slog.Error("error getting setting change request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(change)
+}
+
+// handleAdminComplianceReport streams a compliance report (GET): a zip of
+// CSVs listing users, role assignments, category ACLs, app visibility,
+// active shares, API keys and settings at one point in time, with a
+// manifest signed by the attestation key.
+func (h *handlers) handleAdminComplianceReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.app.Attestor == nil {
+		http.Error(w, "Compliance reports need an attestation key", http.StatusNotFound)
+		return
+	}
+
+	settings, err := h.adminSettings()
+	if err != nil {
+		slog.Error("error getting settings", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	snapshot, err := compliance.Collect(h.app.DB, settings)
+	if err != nil {
+		slog.Error("error collecting compliance report", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	h.logAudit(r, user.Username, "EXPORT_COMPLIANCE_REPORT", "Exported compliance report generated at "+snapshot.GeneratedAt.Format(time.RFC3339))
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=compliance-report-%s.zip", snapshot.GeneratedAt.Format("20060102-150405")))
+	if err := snapshot.WriteZip(w, h.app.Attestor, user.Username); err != nil {
+		// The response has started, so the client sees a truncated zip
+		slog.Error("error writing compliance report", "error", err)
+	}
+}
+
+// tokenPolicySettingKey is the read-only admin settings key exposing the
+// effective token lifetimes.
+const tokenPolicySettingKey = "token_policy"
+
+// tokenLifetimeResponse describes effective token lifetimes in the units used
+// by the configuration.
+type tokenLifetimeResponse struct {
+	AccessExpiryMinutes int  `json:"access_expiry_minutes"`
+	RefreshExpiryHours  int  `json:"refresh_expiry_hours"`
+	RotateRefreshTokens bool `json:"rotate_refresh_tokens"`
+}
+
+// tenantTokenPolicyResponse describes the effective token lifetimes for a
+// tenant, by default and for each role the tenant overrides.
+type tenantTokenPolicyResponse struct {
+	TenantID string                           `json:"tenant_id"`
+	Slug     string                           `json:"slug"`
+	Default  tokenLifetimeResponse            `json:"default"`
+	Roles    map[string]tokenLifetimeResponse `json:"roles,omitempty"`
+}
+
+func newTokenLifetimeResponse(s auth.TokenSettings) tokenLifetimeResponse {
+	return tokenLifetimeResponse{
+		AccessExpiryMinutes: int(s.AccessExpiry / time.Minute),
+		RefreshExpiryHours:  int(s.RefreshExpiry / time.Hour),
+		RotateRefreshTokens: s.RotateRefresh,
+	}
+}
+
+// tokenPolicySettings resolves the global token lifetimes and the effective
+// lifetimes of every tenant with a token policy.
+func (h *handlers) tokenPolicySettings() (map[string]interface{}, error) {
+	global := auth.TokenSettings{
+		AccessExpiry:  h.app.Config.JWTAccessExpiry,
+		RefreshExpiry: h.app.Config.JWTRefreshExpiry,
+	}
+
+	tenants, err := h.app.DB.ListTenants()
+	if err != nil {
+		return nil, err
+	}
+
+	policies := []tenantTokenPolicyResponse{}
+	for _, t := range tenants {
+		policy := t.Settings.TokenPolicy
+		if policy == nil {
+			continue
+		}
+		resp := tenantTokenPolicyResponse{
+			TenantID: t.ID,
+			Slug:     t.Slug,
+			Default:  newTokenLifetimeResponse(auth.ResolveTokenSettings(global, policy, nil)),
+		}
+		if len(policy.Roles) > 0 {
+			resp.Roles = make(map[string]tokenLifetimeResponse, len(policy.Roles))
+			for role := range policy.Roles {
+				resp.Roles[role] = newTokenLifetimeResponse(auth.ResolveTokenSettings(global, policy, []string{role}))
+			}
+		}
+		policies = append(policies, resp)
+	}
+
+	return map[string]interface{}{
+		"global":  newTokenLifetimeResponse(global),
+		"tenants": policies,
+	}, nil
+}
+
+func (h *handlers) handleAdminSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessionList, err := h.app.SessionManager.ListSessions(r.Context())
+	if err != nil {
+		slog.Error("error listing sessions", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if sessionList == nil {
+		sessionList = []db.Session{}
+	}
+
+	responses := make([]sessions.SessionResponse, len(sessionList))
+	for i, s := range sessionList {
+		app, _ := h.app.DB.GetApp(s.AppID)
+		appName := ""
+		wsURL := ""
+		guacURL := ""
+		proxyURL := ""
+		if app != nil {
+			appName = app.Name
+			if app.LaunchType == db.LaunchTypeContainer || app.LaunchType == db.LaunchTypeWebProxy {
+				if app.OsType == "windows" {
+					guacURL = h.app.SessionManager.GetSessionGuacWebSocketURL(&s)
+				} else {
+					wsURL = h.app.SessionManager.GetSessionWebSocketURL(&s)
+				}
+			}
+		}
+		responses[i] = *sessions.SessionFromDB(&s, appName, wsURL, guacURL, proxyURL, h.recordingPolicy(app))
+		responses[i].ClipboardPolicy = clipboardPolicy(app)
+		responses[i].FileTransfer = fileTransferPolicy(app)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(responses)
+}
+
+// handleAdminSessionArchive lists sessions moved to the archive by session
+// retention, most recently ended first. Supported parameters: user_id and
+// limit (default 100, max 1000).
+func (h *handlers) handleAdminSessionArchive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid 'limit'", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, 1000)
+	}
+
+	archived, err := h.app.DB.ListArchivedSessions(r.URL.Query().Get("user_id"), limit)
+	if err != nil {
+		slog.Error("error listing archived sessions", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if archived == nil {
+		archived = []db.ArchivedSession{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(archived)
+}
+
+// handleAdminUserVolumes lists users' home volumes. Supported parameters:
+// user_id and app_id.
+func (h *handlers) handleAdminUserVolumes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	volumes, err := h.app.SessionManager.ListUserVolumes(r.Context(), r.URL.Query().Get("user_id"), r.URL.Query().Get("app_id"))
+	if errors.Is(err, sessions.ErrUserVolumesUnsupported) {
+		http.Error(w, "The session runner does not support user volumes", http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		slog.Error("error listing user volumes", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"volumes": volumes})
+}
+
+// handleAdminUserVolumeByName deletes a user's home volume (DELETE
+// /api/admin/user-volumes/{name}) and the files on it.
+func (h *handlers) handleAdminUserVolumeByName(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/admin/user-volumes/")
+	if name == "" || strings.Contains(name, "/") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	vol, err := h.app.SessionManager.DeleteUserVolume(r.Context(), name)
+	switch {
+	case errors.Is(err, sessions.ErrUserVolumesUnsupported):
+		http.Error(w, "The session runner does not support user volumes", http.StatusNotImplemented)
+		return
+	case errors.Is(err, sessions.ErrUserVolumeNotFound):
+		http.Error(w, "User volume not found", http.StatusNotFound)
+		return
+	case errors.Is(err, sessions.ErrUserVolumeInUse):
+		http.Error(w, "User volume is in use by an active session", http.StatusConflict)
+		return
+	case err != nil:
+		slog.Error("error deleting user volume", "volume", name, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	h.logAudit(r, user.Username, "DELETE_USER_VOLUME",
+		fmt.Sprintf("Deleted home volume %s of user %s for app %s", vol.Name, vol.UserID, vol.AppID))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// prePullStatus is a pre-pull as reported by the admin API.
+type prePullStatus struct {
+	runner.PrePull
+	Complete bool `json:"complete"`
+}
+
+// handleAdminPrePulls lists image pre-pulls with each node's progress
+// (GET), or starts pre-pulls for the apps in app_ids (POST).
+func (h *handlers) handleAdminPrePulls(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		pulls, err := h.app.SessionManager.ListPrePulls(r.Context())
+		if errors.Is(err, sessions.ErrPrePullUnsupported) {
+			http.Error(w, "The session runner does not support image pre-pulls", http.StatusNotImplemented)
+			return
+		}
+		if err != nil {
+			slog.Error("error listing image pre-pulls", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		statuses := make([]prePullStatus, 0, len(pulls))
+		for _, p := range pulls {
+			statuses = append(statuses, prePullStatus{PrePull: p, Complete: p.Complete()})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"prepulls": statuses})
+
+	case http.MethodPost:
+		var req struct {
+			AppIDs []string `json:"app_ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if len(req.AppIDs) == 0 {
+			http.Error(w, "Missing required field: app_ids", http.StatusBadRequest)
+			return
+		}
+
+		// Check every app before starting any
+		apps := make([]*db.Application, 0, len(req.AppIDs))
+		for _, id := range req.AppIDs {
+			app, err := h.app.DB.GetApp(id)
+			if err != nil {
+				slog.Error("error getting app", "error", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if app == nil {
+				http.Error(w, fmt.Sprintf("Application %s not found", id), http.StatusBadRequest)
+				return
+			}
+			if app.LaunchType != db.LaunchTypeContainer && app.LaunchType != db.LaunchTypeWebProxy {
+				http.Error(w, fmt.Sprintf("Application %s has no images to pull", id), http.StatusBadRequest)
+				return
+			}
+			apps = append(apps, app)
+		}
+
+		user := middleware.GetUserFromContext(r.Context())
+		for _, app := range apps {
+			err := h.app.SessionManager.PrePullApp(r.Context(), app)
+			if errors.Is(err, sessions.ErrPrePullUnsupported) {
+				http.Error(w, "The session runner does not support image pre-pulls", http.StatusNotImplemented)
+				return
+			}
+			if err != nil {
+				slog.Error("error starting image pre-pull", "app_id", app.ID, "error", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			h.logAudit(r, user.Username, "PREPULL_IMAGES", fmt.Sprintf("Started image pre-pull for app %s", app.ID))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]any{"started": req.AppIDs})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminPrePullByApp stops an app's image pre-pull (DELETE
+// /api/admin/prepull/{appID}).
+func (h *handlers) handleAdminPrePullByApp(w http.ResponseWriter, r *http.Request) {
+	appID := strings.TrimPrefix(r.URL.Path, "/api/admin/prepull/")
+	if appID == "" || strings.Contains(appID, "/") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := h.app.SessionManager.DeletePrePull(r.Context(), appID)
+	if errors.Is(err, sessions.ErrPrePullUnsupported) {
+		http.Error(w, "The session runner does not support image pre-pulls", http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		slog.Error("error deleting image pre-pull", "app_id", appID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	h.logAudit(r, user.Username, "DELETE_PREPULL", fmt.Sprintf("Stopped image pre-pull for app %s", appID))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAdminHistory returns a page of session run summaries from the
+// session history, most recently ended first.
+func (h *handlers) handleAdminHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := parseSessionHistoryFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.app.DB.QuerySessionHistory(filter)
+	if err != nil {
+		slog.Error("error querying session history", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if page.Sessions == nil {
+		page.Sessions = []db.SessionHistory{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// handleAdminHistorySummary aggregates session history by app, user or
+// final status (group_by, default app), with the same filters as
+// handleAdminHistory.
+func (h *handlers) handleAdminHistorySummary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := parseSessionHistoryFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	groupBy := r.URL.Query().Get("group_by")
+	if groupBy == "" {
+		groupBy = "app"
+	}
+	if _, ok := db.SessionHistoryGroupBy[groupBy]; !ok {
+		http.Error(w, "invalid 'group_by': "+groupBy, http.StatusBadRequest)
+		return
+	}
+
+	groups, err := h.app.DB.SummarizeSessionHistory(filter, groupBy)
+	if err != nil {
+		slog.Error("error summarizing session history", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if groups == nil {
+		groups = []db.SessionHistoryGroup{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"group_by": groupBy,
+		"groups":   groups,
+	})
+}
+
+// Limits of handleAdminHistoryConcurrency's weeks and forecast parameters.
+const (
+	defaultConcurrencyWeeks = 8
+	maxConcurrencyWeeks     = 52
+	defaultForecastWeeks    = 4
+	maxForecastWeeks        = 26
+)
+
+// handleAdminHistoryConcurrency reports how many sessions ran at once in
+// each hour of the week over the last complete weeks, optionally of one
+// app_id or tenant, with a linear forecast of the weekly peak. weeks
+// (default 8) sets how many weeks are analyzed, forecast (default 4) how
+// many are forecast, and tz the IANA time zone of the weeks and hours
+// (default UTC).
+func (h *handlers) handleAdminHistoryConcurrency(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	weeks, err := intParam(q.Get("weeks"), defaultConcurrencyWeeks, 1, maxConcurrencyWeeks)
+	if err != nil {
+		http.Error(w, "invalid 'weeks': "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	forecastWeeks, err := intParam(q.Get("forecast"), defaultForecastWeeks, 0, maxForecastWeeks)
+	if err != nil {
+		http.Error(w, "invalid 'forecast': "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	loc := time.UTC
+	if tz := q.Get("tz"); tz != "" {
+		if loc, err = time.LoadLocation(tz); err != nil {
+			http.Error(w, "invalid 'tz': "+tz, http.StatusBadRequest)
+			return
+		}
+	}
+
+	to := capacity.WeekStart(time.Now().In(loc))
+	from := to.AddDate(0, 0, -7*weeks)
+	runs, err := h.app.DB.ListSessionRuns(from, to, q.Get("app_id"), q.Get("tenant"))
+	if err != nil {
+		slog.Error("error listing session runs", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	intervals := make([]capacity.Run, len(runs))
+	for i, run := range runs {
+		intervals[i] = capacity.Run{Start: run.StartedAt, End: run.EndedAt}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(capacity.Analyze(intervals, from, weeks, forecastWeeks))
+}
+
+// defaultCostReportRange is the range handleAdminCostReport covers when
+// from is not given.
+const defaultCostReportRange = 30 * 24 * time.Hour
+
+// costReportRow is a group of session runs, or their total, in a cost
+// report. Hours and costs are rounded to four decimal places.
+type costReportRow struct {
+	Key            string  `json:"key,omitempty"`
+	Name           string  `json:"name,omitempty"`
+	Sessions       int     `json:"sessions"`
+	DurationHours  float64 `json:"duration_hours"`
+	CPUCoreHours   float64 `json:"cpu_core_hours"`
+	MemoryGiBHours float64 `json:"memory_gib_hours"`
+	CPUCost        float64 `json:"cpu_cost"`
+	MemoryCost     float64 `json:"memory_cost"`
+	Cost           float64 `json:"cost"`
+}
+
+// handleAdminCostReport estimates what the session runs that ended
+// between from and to (RFC 3339, default the last 30 days) cost, grouped
+// by user, app or tenant (group_by, default user), at the configured
+// rates for the CPU and memory they requested. It takes user_id, app_id
+// and tenant filters like handleAdminHistory, and returns CSV if format
+// is "csv".
+func (h *handlers) handleAdminCostReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	filter, err := parseSessionHistoryFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.To.IsZero() {
+		filter.To = time.Now().UTC()
+	}
+	if filter.From.IsZero() {
+		filter.From = filter.To.Add(-defaultCostReportRange)
+	}
+	if !filter.From.Before(filter.To) {
+		http.Error(w, "'from' must be before 'to'", http.StatusBadRequest)
+		return
+	}
+	groupBy := q.Get("group_by")
+	if groupBy == "" {
+		groupBy = "user"
+	}
+	if _, ok := db.SessionCostGroupBy[groupBy]; !ok {
+		http.Error(w, "invalid 'group_by': "+groupBy, http.StatusBadRequest)
+		return
+	}
+	format := q.Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		http.Error(w, "invalid 'format': "+format, http.StatusBadRequest)
+		return
+	}
+
+	groups, err := h.app.DB.SummarizeSessionCosts(filter, groupBy)
+	if err != nil {
+		slog.Error("error summarizing session costs", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	cfg := h.app.Config
+	round := func(v float64) float64 { return math.Round(v*1e4) / 1e4 }
+	row := func(g db.SessionCostGroup) costReportRow {
+		cpuHours := g.CPUCoreSeconds / 3600
+		memHours := g.MemoryGiBSeconds / 3600
+		cpuCost := cpuHours * cfg.CostCPUCoreHour
+		memCost := memHours * cfg.CostMemoryGiBHour
+		return costReportRow{
+			Key:            g.Key,
+			Name:           g.Name,
+			Sessions:       g.Sessions,
+			DurationHours:  round(float64(g.DurationSeconds) / 3600),
+			CPUCoreHours:   round(cpuHours),
+			MemoryGiBHours: round(memHours),
+			CPUCost:        round(cpuCost),
+			MemoryCost:     round(memCost),
+			Cost:           round(cpuCost + memCost),
+		}
+	}
+	rows := make([]costReportRow, len(groups))
+	var sum db.SessionCostGroup
+	for i, g := range groups {
+		rows[i] = row(g)
+		sum.Sessions += g.Sessions
+		sum.DurationSeconds += g.DurationSeconds
+		sum.CPUCoreSeconds += g.CPUCoreSeconds
+		sum.MemoryGiBSeconds += g.MemoryGiBSeconds
+	}
+	total := row(sum)
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", "attachment; filename=costs.csv")
+		cw := csv.NewWriter(w)
+		cw.Write([]string{groupBy, "name", "sessions", "duration_hours", "cpu_core_hours", "memory_gib_hours", "cpu_cost", "memory_cost", "cost", "currency"})
+		f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+		writeRow := func(key string, row costReportRow) {
+			cw.Write([]string{key, row.Name, strconv.Itoa(row.Sessions), f(row.DurationHours), f(row.CPUCoreHours),
+				f(row.MemoryGiBHours), f(row.CPUCost), f(row.MemoryCost), f(row.Cost), cfg.CostCurrency})
+		}
+		for _, row := range rows {
+			writeRow(row.Key, row)
+		}
+		writeRow("total", total)
+		cw.Flush()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"from":     filter.From,
+		"to":       filter.To,
+		"group_by": groupBy,
+		"currency": cfg.CostCurrency,
+		"rates": map[string]float64{
+			"cpu_core_hour":   cfg.CostCPUCoreHour,
+			"memory_gib_hour": cfg.CostMemoryGiBHour,
+		},
+		"groups": rows,
+		"total":  total,
+	})
+}
+
+// handleAdminSLOs reports how each app with availability objectives is
+// doing against them over its window, optionally of one app_id or tenant.
+func (h *handlers) handleAdminSLOs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	apps, err := h.app.DB.ListApps()
+	if err != nil {
+		slog.Error("error listing apps", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	q := r.URL.Query()
+	now := time.Now()
+	reports := []*slo.Report{}
+	for i := range apps {
+		app := &apps[i]
+		if app.SLO == nil {
+			continue
+		}
+		if id := q.Get("app_id"); id != "" && app.ID != id {
+			continue
+		}
+		if tenant := q.Get("tenant"); tenant != "" && app.TenantID != tenant {
+			continue
+		}
+		report, err := slo.Load(h.app.DB, app, now)
+		if err != nil {
+			slog.Error("error computing app SLO", "app", app.ID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		reports = append(reports, report)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reports": reports,
+	})
+}
+
+// Limits of handleAdminAPIUsage's parameters.
+const (
+	defaultAPIUsageRange = 24 * time.Hour
+	maxAPIUsageRange     = 90 * 24 * time.Hour
+	defaultAPIUsageTop   = 10
+	maxAPIUsageTop       = 100
+)
+
+// handleAdminAPIUsage reports the API requests made between from and to
+// (RFC 3339, default the last 24 hours), optionally by one user_id or
+// token_id: the counts in each hour, or each UTC day if bucket is "day",
+// and the limit (default 10) busiest endpoints and principals.
+func (h *handlers) handleAdminAPIUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := db.APIUsageFilter{
+		To:      time.Now().UTC(),
+		UserID:  q.Get("user_id"),
+		TokenID: q.Get("token_id"),
+	}
+	if to := q.Get("to"); to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			http.Error(w, "invalid 'to' date: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter.To = t.UTC()
+	}
+	filter.From = filter.To.Add(-defaultAPIUsageRange)
+	if from := q.Get("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			http.Error(w, "invalid 'from' date: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter.From = t.UTC()
+	}
+	if !filter.From.Before(filter.To) || filter.To.Sub(filter.From) > maxAPIUsageRange {
+		http.Error(w, "'from' must be before 'to' and at most 90 days earlier", http.StatusBadRequest)
+		return
+	}
+	bucket := q.Get("bucket")
+	if bucket == "" {
+		bucket = "hour"
+	}
+	if bucket != "hour" && bucket != "day" {
+		http.Error(w, "invalid 'bucket': "+bucket, http.StatusBadRequest)
+		return
+	}
+	limit, err := intParam(q.Get("limit"), defaultAPIUsageTop, 1, maxAPIUsageTop)
+	if err != nil {
+		http.Error(w, "invalid 'limit': "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	buckets, err := h.app.DB.ListAPIUsageBuckets(filter)
+	if err == nil && bucket == "day" {
+		buckets = foldAPIUsageDays(buckets)
+	}
+	var endpoints []db.APIEndpointUsage
+	if err == nil {
+		endpoints, err = h.app.DB.TopAPIEndpoints(filter, limit)
+	}
+	var principals []db.APIPrincipalUsage
+	if err == nil {
+		principals, err = h.app.DB.TopAPIPrincipals(filter, limit)
+	}
+	if err != nil {
+		slog.Error("error reading API usage", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"from":            filter.From,
+		"to":              filter.To,
+		"bucket":          bucket,
+		"buckets":         buckets,
+		"top_endpoints":   endpoints,
+		"top_principals":  principals,
+		"alert_threshold": h.app.Config.APIUsageAlertThreshold,
+	})
+}
+
+// foldAPIUsageDays sums hourly buckets, oldest first, into UTC days.
+func foldAPIUsageDays(hours []db.APIUsageBucket) []db.APIUsageBucket {
+	days := []db.APIUsageBucket{}
+	for _, b := range hours {
+		day := b.BucketStart.UTC().Truncate(24 * time.Hour)
+		if n := len(days); n > 0 && days[n-1].BucketStart.Equal(day) {
+			days[n-1].Requests += b.Requests
+			days[n-1].Errors += b.Errors
+			continue
+		}
+		days = append(days, db.APIUsageBucket{BucketStart: day, Requests: b.Requests, Errors: b.Errors})
+	}
+	return days
+}
+
+// handleAdminChaos reads, sets and clears the faults this replica injects.
+// It is only registered in binaries built with the chaos build tag.
+func (h *handlers) handleAdminChaos(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var faults chaos.Faults
+		if err := json.NewDecoder(r.Body).Decode(&faults); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := chaos.Default.Set(faults); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		slog.Warn("fault injection updated", "user", user.Username, "faults", faults)
+		h.logAudit(r, user.Username, "UPDATE_CHAOS", fmt.Sprintf("Set injected faults: %+v", faults))
+	case http.MethodDelete:
+		chaos.Default.Set(chaos.Faults{})
+		slog.Info("fault injection cleared", "user", user.Username)
+		h.logAudit(r, user.Username, "CLEAR_CHAOS", "Cleared injected faults")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"faults":   chaos.Default.Faults(),
+		"injected": chaos.Default.Injected(),
+	})
+}
+
+// intParam parses an optional integer query parameter between lo and hi,
+// returning def when it is empty.
+func intParam(value string, def, lo, hi int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if n < lo || n > hi {
+		return 0, fmt.Errorf("must be between %d and %d", lo, hi)
+	}
+	return n, nil
+}
+
+// parseSessionHistoryFilter builds a db.SessionHistoryFilter from the
+// query string. Supported parameters: user_id, app_id, tenant, status,
+// from and to (RFC 3339, matched against when runs ended), limit, and
+// offset.
+func parseSessionHistoryFilter(r *http.Request) (db.SessionHistoryFilter, error) {
+	q := r.URL.Query()
+	filter := db.SessionHistoryFilter{
+		UserID:    q.Get("user_id"),
+		AppID:     q.Get("app_id"),
+		TenantID:  q.Get("tenant"),
+		ProjectID: q.Get("project_id"),
+		Status:    q.Get("status"),
+	}
+
+	if from := q.Get("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return filter, fmt.Errorf("invalid 'from' date: %w", err)
+		}
+		filter.From = t
+	}
+	if to := q.Get("to"); to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return filter, fmt.Errorf("invalid 'to' date: %w", err)
+		}
+		filter.To = t
+	}
+	if limitStr := q.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			return filter, fmt.Errorf("invalid 'limit': %w", err)
+		}
+		filter.Limit = limit
+	}
+	if offsetStr := q.Get("offset"); offsetStr != "" {
+		offset, err := strconv.Atoi(offsetStr)
+		if err != nil {
+			return filter, fmt.Errorf("invalid 'offset': %w", err)
+		}
+		filter.Offset = offset
+	}
+
+	return filter, nil
+}
+
+// handleAdminSessionByID serves /api/admin/sessions/{id}/spectate.
+func (h *handlers) handleAdminSessionByID(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/admin/sessions/"), "/")
+	if len(parts) < 2 || parts[0] == "" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	switch {
+	case len(parts) == 2 && parts[1] == "spectate":
+		h.handleAdminSpectate(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "attestations":
+		h.handleAdminSessionAttestations(w, r, parts[0])
+	case len(parts) == 3 && parts[1] == "attestations" && parts[2] != "":
+		h.handleAdminSessionAttestationDownload(w, r, parts[0], parts[2])
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// sessionAttestationSummary is a stored attestation with its report decoded
+// for display. The signed envelope is served by the download endpoint.
+type sessionAttestationSummary struct {
+	ID        string              `json:"id"`
+	SessionID string              `json:"session_id"`
+	KeyID     string              `json:"key_id"`
+	CreatedAt time.Time           `json:"created_at"`
+	Report    *attestation.Report `json:"report"`
+}
+
+// handleAdminSessionAttestations lists the attestation reports stored for a
+// session, oldest first. Reports outlive the session record, so this works
+// for sessions that have since been deleted.
+func (h *handlers) handleAdminSessionAttestations(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stored, err := h.app.DB.ListSessionAttestations(sessionID)
+	if err != nil {
+		slog.Error("error listing session attestations", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	summaries := make([]sessionAttestationSummary, 0, len(stored))
+	for _, a := range stored {
+		var env attestation.Envelope
+		if err := json.Unmarshal([]byte(a.Envelope), &env); err != nil {
+			slog.Error("invalid stored attestation envelope", "id", a.ID, "error", err)
+			continue
+		}
+		report, err := attestation.Decode(&env)
+		if err != nil {
+			slog.Error("invalid stored attestation report", "id", a.ID, "error", err)
+			continue
+		}
+		summaries = append(summaries, sessionAttestationSummary{
+			ID:        a.ID,
+			SessionID: a.SessionID,
+			KeyID:     a.KeyID,
+			CreatedAt: a.CreatedAt,
+			Report:    report,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summaries)
+}
+
+// handleAdminSessionAttestationDownload serves a signed attestation envelope
+// as a file that auditors can verify offline.
+func (h *handlers) handleAdminSessionAttestationDownload(w http.ResponseWriter, r *http.Request, sessionID, attestationID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	a, err := h.app.DB.GetSessionAttestation(attestationID)
+	if err != nil {
+		slog.Error("error getting session attestation", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if a == nil || a.SessionID != sessionID {
+		http.Error(w, "Attestation not found", http.StatusNotFound)
+		return
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	h.logAudit(r, user.Username, "DOWNLOAD_ATTESTATION", fmt.Sprintf("Downloaded attestation %s for session %s", a.ID, a.SessionID))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=attestation-%s.json", a.ID))
+	w.Write([]byte(a.Envelope))
+}
+
+// handleAdminAttestationKey returns the public key that session attestation
+// reports are signed with, for verifying downloaded reports.
+func (h *handlers) handleAdminAttestationKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.app.Attestor == nil {
+		http.Error(w, "Session attestation not configured", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"key_id":         h.app.Attestor.KeyID(),
+		"algorithm":      "ed25519",
+		"public_key":     base64.StdEncoding.EncodeToString(h.app.Attestor.PublicKey()),
+		"public_key_pem": h.app.Attestor.PublicKeyPEM(),
+		"payload_type":   attestation.PayloadType,
+	})
+}
+
+// handleAdminSpectate requests read-only access to a user's session. The
+// tenant's spectate policy decides whether the grant is usable immediately
+// or must first be approved by the session owner.
+func (h *handlers) handleAdminSpectate(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.app.Spectate == nil {
+		http.Error(w, "Spectate not available", http.StatusNotFound)
+		return
+	}
+	admin := middleware.GetUserFromContext(r.Context())
+
+	session, err := h.app.SessionManager.GetSession(r.Context(), sessionID)
+	if err != nil {
+		slog.Error("error getting session for spectate", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if session == nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if session.Status != db.SessionStatusRunning {
+		http.Error(w, "Session is not running", http.StatusBadRequest)
+		return
+	}
+	if session.UserID == admin.ID {
+		http.Error(w, "Cannot spectate your own session", http.StatusBadRequest)
+		return
+	}
+
+	policy, err := spectate.PolicyFor(h.app.DB, session)
+	if err != nil {
+		slog.Error("error getting spectate policy", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	grant := h.app.Spectate.Request(session.ID, session.UserID, admin.ID, admin.Username, policy)
+	h.logAudit(r, admin.Username, "SPECTATE_REQUEST",
+		fmt.Sprintf("Requested to spectate session %s (policy=%s, status=%s)", session.ID, grant.Policy, grant.Status))
+
+	wsURL := h.app.SessionManager.GetSessionWebSocketURL(session)
+	if app, _ := h.app.DB.GetApp(session.AppID); app != nil && app.OsType == "windows" {
+		wsURL = h.app.SessionManager.GetSessionGuacWebSocketURL(session)
+	}
+	if wsURL != "" {
+		wsURL += "?spectate=" + grant.ID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(struct {
+		spectate.Grant
+		WebSocketURL string `json:"websocket_url,omitempty"`
+	}{grant, wsURL})
+}
+
+func (h *handlers) handleAdminUsers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		filter, err := parseUserFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		page, err := h.app.DB.QueryUsers(filter)
+		if err != nil {
+			slog.Error("error listing users", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		type userResponse struct {
+			ID          string     `json:"id"`
+			Username    string     `json:"username"`
+			Email       string     `json:"email,omitempty"`
+			DisplayName string     `json:"display_name,omitempty"`
+			Roles       []string   `json:"roles"`
+			TenantID    string     `json:"tenant_id,omitempty"`
+			Disabled    bool       `json:"disabled"`
+			LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+			CreatedAt   time.Time  `json:"created_at"`
+		}
+
+		response := make([]userResponse, len(page.Users))
+		for i, u := range page.Users {
+			response[i] = userResponse{
+				ID:          u.ID,
+				Username:    u.Username,
+				Email:       u.Email,
+				DisplayName: u.DisplayName,
+				Roles:       u.Roles,
+				TenantID:    u.TenantID,
+				Disabled:    u.Disabled,
+				LastLoginAt: u.LastLoginAt,
+				CreatedAt:   u.CreatedAt,
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if filter.All {
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"users": response,
+			"total": page.Total,
+		})
+
+	case http.MethodPost:
+		var req struct {
+			Username    string   `json:"username"`
+			Password    string   `json:"password"`
+			Email       string   `json:"email"`
+			DisplayName string   `json:"display_name"`
+			Roles       []string `json:"roles"`
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		if req.Username == "" || req.Password == "" {
+			http.Error(w, "Username and password are required", http.StatusBadRequest)
+			return
+		}
+
+		passwordHash, err := auth.HashPassword(req.Password)
+		if err != nil {
+			slog.Error("error hashing password", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		roles := req.Roles
+		if len(roles) == 0 {
+			roles = []string{"user"}
+		}
+
+		user := db.User{
+			ID:           fmt.Sprintf("user-%s-%d", req.Username, time.Now().UnixNano()),
+			Username:     req.Username,
+			Email:        req.Email,
+			DisplayName:  req.DisplayName,
+			PasswordHash: passwordHash,
+			Roles:        roles,
+		}
+
+		err = h.app.DB.WithTx(func(tx *db.DB) error {
+			existing, err := tx.GetUserByUsername(req.Username)
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				return &httpError{http.StatusConflict, "Username already exists"}
+			}
+			return tx.CreateUser(user)
+		})
+		if err != nil {
+			writeTxError(w, r, err, "creating user")
+			return
+		}
+
+		adminUser := middleware.GetUserFromContext(r.Context())
+		h.logAudit(r, adminUser.Username, "CREATE_USER", fmt.Sprintf("Created user: %s", req.Username))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{
+			"id":       user.ID,
+			"username": user.Username,
+			"message":  "User created successfully",
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// parseUserFilter builds a db.UserFilter from the admin users query string.
+// Supported parameters: q, role, tenant, sort (prefix with "-" for
+// descending), limit, and offset.
+func parseUserFilter(r *http.Request) (db.UserFilter, error) {
+	q := r.URL.Query()
+	filter := db.UserFilter{
+		Query:    strings.TrimSpace(q.Get("q")),
+		Role:     q.Get("role"),
+		TenantID: q.Get("tenant"),
+		Desc:     true,
+	}
+
+	if sort := q.Get("sort"); sort != "" {
+		filter.Desc = strings.HasPrefix(sort, "-")
+		filter.Sort = strings.TrimPrefix(sort, "-")
+		if _, ok := db.UserSortFields[filter.Sort]; !ok {
+			return filter, fmt.Errorf("invalid 'sort': %s", sort)
+		}
+	}
+	if limitStr := q.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			return filter, fmt.Errorf("invalid 'limit': %w", err)
+		}
+		filter.Limit = limit
+	}
+	if offsetStr := q.Get("offset"); offsetStr != "" {
+		offset, err := strconv.Atoi(offsetStr)
+		if err != nil {
+			return filter, fmt.Errorf("invalid 'offset': %w", err)
+		}
+		filter.Offset = offset
+	}
+	// Without pagination parameters every match is returned, as a plain
+	// array like before pagination was added
+	filter.All = !q.Has("limit") && !q.Has("offset")
+
+	return filter, nil
+}
+
+func (h *handlers) handleAdminUserByID(w http.ResponseWriter, r *http.Request) {
+	remainder := strings.TrimPrefix(r.URL.Path, "/api/admin/users/")
+	parts := strings.SplitN(remainder, "/", 2)
+	id := parts[0]
+	if id == "" {
+		http.Error(w, "User ID required", http.StatusBadRequest)
+		return
+	}
+	if len(parts) > 1 {
+		switch parts[1] {
+		case "enable":
+			h.handleAdminUserEnable(w, r, id)
+		case "sync":
+			h.handleAdminUserSync(w, r, id)
+		case "mfa":
+			h.handleAdminUserMFA(w, r, id)
+		default:
+			http.Error(w, "Not found", http.StatusNotFound)
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodDelete:
+		currentUser := middleware.GetUserFromContext(r.Context())
+		if currentUser != nil && currentUser.ID == id {
+			http.Error(w, "Cannot delete your own account", http.StatusBadRequest)
+			return
+		}
+
+		user, err := h.app.DB.GetUserByID(id)
+		if err != nil {
+			slog.Error("error getting user", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if user == nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		if err := h.app.DB.DeleteUser(id); err != nil {
+			slog.Error("error deleting user", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		h.logAudit(r, currentUser.Username, "DELETE_USER", fmt.Sprintf("Deleted user: %s (%s)", user.Username, id))
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminUserEnable re-enables an account that was disabled, typically by
+// the inactive-account policy. The last-login timestamp is reset to now so the
+// account is not immediately disabled again on the next cleanup run.
+func (h *handlers) handleAdminUserEnable(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, err := h.app.DB.GetUserByID(id)
+	if err != nil {
+		slog.Error("error getting user", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.app.DB.SetUserDisabled(id, false); err != nil {
+		slog.Error("error enabling user", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.app.DB.UpdateUserLastLogin(id, time.Now()); err != nil {
+		slog.Error("error resetting last login", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	actor := "admin"
+	if currentUser := middleware.GetUserFromContext(r.Context()); currentUser != nil {
+		actor = currentUser.Username
+	}
+	h.logAudit(r, actor, "ENABLE_USER", fmt.Sprintf("Enabled user: %s (%s)", user.Username, id))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAdminUserMFA resets a user's MFA, for users who lost both their
+// authenticator and their backup codes. If their tenant requires MFA they
+// enroll again at their next login.
+func (h *handlers) handleAdminUserMFA(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, err := h.app.DB.GetUserByID(id)
+	if err != nil {
+		slog.Error("error getting user", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.app.DB.DeleteUserMFA(id); err != nil {
+		slog.Error("error resetting MFA", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	actor := "admin"
+	if currentUser := middleware.GetUserFromContext(r.Context()); currentUser != nil {
+		actor = currentUser.Username
+	}
+	h.logAudit(r, actor, "RESET_MFA", fmt.Sprintf("Reset MFA for user: %s (%s)", user.Username, id))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAdminUserSync re-reads an SSO user's groups from the identity
+// provider and updates their roles, so group changes take effect without
+// waiting for the user to sign in again.
+func (h *handlers) handleAdminUserSync(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.app.OIDCAuth == nil {
+		http.Error(w, "SSO is not configured", http.StatusConflict)
+		return
+	}
+
+	result, err := h.app.OIDCAuth.SyncUser(r.Context(), id)
+	switch {
+	case errors.Is(err, auth.ErrSyncUnsupported), errors.Is(err, auth.ErrIdPTokenMissing), errors.Is(err, auth.ErrGroupsUnavailable):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		slog.Error("error syncing user from identity provider", "user_id", id, "error", err)
+		http.Error(w, "Failed to sync user from identity provider", http.StatusBadGateway)
+		return
+	case result == nil:
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	actor := "admin"
+	if currentUser := middleware.GetUserFromContext(r.Context()); currentUser != nil {
+		actor = currentUser.Username
+	}
+	h.logAudit(r, actor, "SYNC_USER", fmt.Sprintf("Synced user from identity provider: %s (%s) added=%v removed=%v",
+		result.User.Username, id, result.Added, result.Removed))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// --- Template endpoints ---
+
+func (h *handlers) handleTemplates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	templates, err := h.app.DB.ListTemplates()
+	if err != nil {
+		slog.Error("error listing templates", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if templates == nil {
+		templates = []db.Template{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(templates)
+}
+
+func (h *handlers) handleTemplateByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	templateID := strings.TrimPrefix(r.URL.Path, "/api/templates/")
+	if templateID == "" {
+		http.Error(w, "Template ID required", http.StatusBadRequest)
+		return
+	}
+
+	template, err := h.app.DB.GetTemplate(templateID)
+	if err != nil {
+		slog.Error("error getting template", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if template == nil {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(template)
+}
+
+func (h *handlers) handleAdminTemplates(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		templates, err := h.app.DB.ListTemplates()
+		if err != nil {
+			slog.Error("error listing templates", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if templates == nil {
+			templates = []db.Template{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(templates)
+
+	case http.MethodPost:
+		var template db.Template
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := json.Unmarshal(body, &template); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		if template.TemplateID == "" {
+			http.Error(w, "Missing required field: template_id", http.StatusBadRequest)
+			return
+		}
+		if template.Name == "" {
+			http.Error(w, "Missing required field: name", http.StatusBadRequest)
+			return
+		}
+		if template.TemplateCategory == "" {
+			http.Error(w, "Missing required field: template_category", http.StatusBadRequest)
+			return
+		}
+		if template.Category == "" {
+			http.Error(w, "Missing required field: category", http.StatusBadRequest)
+			return
+		}
+		if err := h.checkIcon(template.Icon); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if template.TemplateVersion == "" {
+			template.TemplateVersion = "1.0.0"
+		}
+		if template.LaunchType == "" {
+			template.LaunchType = "container"
+		}
+
+		if err := h.app.DB.CreateTemplate(template); err != nil {
+			if db.IsDuplicateKeyError(err) {
+				http.Error(w, "Template with this ID already exists", http.StatusConflict)
+				return
+			}
+			slog.Error("error creating template", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		user := middleware.GetUserFromContext(r.Context())
+		h.logAudit(r, user.Username, "CREATE_TEMPLATE", fmt.Sprintf("Created template: %s (%s)", template.Name, template.TemplateID))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(template)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *handlers) handleAdminTemplateByID(w http.ResponseWriter, r *http.Request) {
+	templateID := strings.TrimPrefix(r.URL.Path, "/api/admin/templates/")
+	if templateID == "" {
+		http.Error(w, "Template ID required", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		template, err := h.app.DB.GetTemplate(templateID)
+		if err != nil {
+			slog.Error("error getting template", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if template == nil {
+			http.Error(w, "Template not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(template)
+
+	case http.MethodPut:
+		var template db.Template
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := json.Unmarshal(body, &template); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		template.TemplateID = templateID
+
+		if template.Name == "" {
+			http.Error(w, "Missing required field: name", http.StatusBadRequest)
+			return
+		}
+		if err := h.checkIcon(template.Icon); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := h.app.DB.UpdateTemplate(template); err != nil {
+			if err.Error() == "sql: no rows in result set" {
+				http.Error(w, "Template not found", http.StatusNotFound)
+				return
+			}
+			slog.Error("error updating template", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		user := middleware.GetUserFromContext(r.Context())
+		h.logAudit(r, user.Username, "UPDATE_TEMPLATE", fmt.Sprintf("Updated template: %s (%s)", template.Name, templateID))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(template)
+
+	case http.MethodDelete:
+		template, err := h.app.DB.GetTemplate(templateID)
+		if err != nil {
+			slog.Error("error getting template", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if template == nil {
+			http.Error(w, "Template not found", http.StatusNotFound)
+			return
+		}
+
+		if err := h.app.DB.DeleteTemplate(templateID); err != nil {
+			slog.Error("error deleting template", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		user := middleware.GetUserFromContext(r.Context())
+		h.logAudit(r, user.Username, "DELETE_TEMPLATE", fmt.Sprintf("Deleted template: %s (%s)", template.Name, templateID))
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// templateImportResponse reports the outcome of a template bundle import.
+// A dry run reports what the import would do without changing anything.
+type templateImportResponse struct {
+	DryRun  bool                   `json:"dry_run"`
+	Created []string               `json:"created"`
+	Updated []string               `json:"updated"`
+	Icons   int                    `json:"icons"`
+	Results []templateImportResult `json:"results"`
+}
+
+// templateImportResult reports one template of an imported bundle. A
+// bundle with any failed template is rejected as a whole.
+type templateImportResult struct {
+	Index      int    `json:"index"` // 1-based position in the bundle
+	TemplateID string `json:"template_id,omitempty"`
+	Action     string `json:"action,omitempty"` // "create" or "update"
+	Error      string `json:"error,omitempty"`
+}
+
+// handleAdminTemplateImport imports a template bundle (POST), a JSON or
+// YAML catalog or a zip archive of one with its icons. Templates are
+// matched by template_id: new ones are created and existing ones
+// replaced. Nothing is changed unless every template in the bundle is
+// valid, or with ?dry_run=true.
+func (h *handlers) handleAdminTemplateImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	r.Body = http.MaxBytesReader(w, r.Body, templatebundle.MaxSize)
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, fmt.Sprintf("Bundle too large (max %d bytes)", templatebundle.MaxSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	bundle, err := templatebundle.Read(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	templates := bundle.Catalog.Templates
+	if len(templates) == 0 {
+		http.Error(w, "Bundle has no templates", http.StatusBadRequest)
+		return
+	}
+
+	// Validate everything before storing anything
+	type bundledIcon struct {
+		data        []byte
+		contentType string
+	}
+	bundled := make(map[int]bundledIcon)
+	seen := make(map[string]bool)
+	resp := templateImportResponse{DryRun: dryRun, Created: []string{}, Updated: []string{}}
+	failed := false
+	for i := range templates {
+		t := &templates[i]
+		result := templateImportResult{Index: i + 1, TemplateID: t.TemplateID}
+		fail := func(msg string) {
+			result.Error = msg
+			failed = true
+		}
+		iconData, bundledOK := bundle.File(t.Icon)
+
+		switch {
+		case t.TemplateID == "" || t.Name == "" || t.TemplateCategory == "" || t.Category == "":
+			fail("template_id, name, template_category and category are required")
+		case seen[t.TemplateID]:
+			fail("template appears more than once")
+		case !bundledOK:
+			if err := h.checkIcon(t.Icon); err != nil {
+				fail(err.Error())
+			}
+		case h.app.BrandingStore == nil:
+			fail("icon storage is not configured")
+		case len(iconData) > icons.MaxSize:
+			fail(fmt.Sprintf("icon too large (max %d bytes)", icons.MaxSize))
+		default:
+			contentType, err := branding.DetectContentType(iconData)
+			if err != nil {
+				fail(err.Error())
+			} else {
+				bundled[i] = bundledIcon{iconData, contentType}
+			}
+		}
+		if t.TemplateID != "" {
+			seen[t.TemplateID] = true
+		}
+		if t.TemplateVersion == "" {
+			t.TemplateVersion = "1.0.0"
+		}
+		if t.LaunchType == "" {
+			t.LaunchType = "container"
+		}
+
+		if result.Error == "" {
+			existing, err := h.app.DB.GetTemplate(t.TemplateID)
+			if err != nil {
+				slog.Error("error getting template", "template_id", t.TemplateID, "error", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			result.Action = "create"
+			if existing != nil {
+				result.Action = "update"
+			}
+		}
+		resp.Results = append(resp.Results, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if failed {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	for i := range templates {
+		t := &templates[i]
+		result := resp.Results[i]
+		if result.Action == "update" {
+			resp.Updated = append(resp.Updated, t.TemplateID)
+		} else {
+			resp.Created = append(resp.Created, t.TemplateID)
+		}
+		icon, ok := bundled[i]
+		if ok {
+			resp.Icons++
+		}
+		if dryRun {
+			continue
+		}
+
+		if ok {
+			id, err := h.putIcon(icon.data, icon.contentType)
+			if err != nil {
+				slog.Error("failed to store icon", "id", id, "error", err)
+				http.Error(w, "Failed to store icon", http.StatusInternalServerError)
+				return
+			}
+			t.Icon = icons.URL(id)
+		}
+		var err error
+		if result.Action == "update" {
+			err = h.app.DB.UpdateTemplate(*t)
+		} else {
+			err = h.app.DB.CreateTemplate(*t)
+		}
+		if err != nil {
+			slog.Error("error importing template", "template_id", t.TemplateID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if !dryRun {
+		user := middleware.GetUserFromContext(r.Context())
+		h.logAudit(r, user.Username, "IMPORT_TEMPLATES", fmt.Sprintf("Imported %d templates (%d created, %d updated, %d icons)",
+			len(templates), len(resp.Created), len(resp.Updated), resp.Icons))
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleAdminTemplateExport exports every template (GET) as a catalog
+// that POST /api/admin/templates/import reads back, in YAML or, with
+// ?format=json, JSON.
+func (h *handlers) handleAdminTemplateExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = templatebundle.FormatYAML
+	}
+	if format != templatebundle.FormatYAML && format != templatebundle.FormatJSON {
+		http.Error(w, "format must be yaml or json", http.StatusBadRequest)
+		return
+	}
+
+	templates, err := h.app.DB.ListTemplates()
+	if err != nil {
+		slog.Error("error listing templates", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	data, err := templatebundle.Export(templates, format)
+	if err != nil {
+		slog.Error("error exporting templates", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if format == templatebundle.FormatJSON {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", "attachment; filename=templates.json")
+	} else {
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Header().Set("Content-Disposition", "attachment; filename=templates.yaml")
+	}
+	w.Write(data)
+}
+
+// --- Remote template catalogs ---
+
+// remoteCatalogRequest is the body of a remote catalog create or update.
+type remoteCatalogRequest struct {
+	Name       string `json:"name"`
+	URL        string `json:"url"`
+	PublicKey  string `json:"public_key"`
+	OnConflict string `json:"on_conflict"`
+}
+
+// catalog validates the request and returns it as a remote catalog.
+func (req *remoteCatalogRequest) catalog() (*db.RemoteCatalog, error) {
+	c := &db.RemoteCatalog{
+		Name:       req.Name,
+		URL:        req.URL,
+		PublicKey:  req.PublicKey,
+		OnConflict: req.OnConflict,
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := attestation.ParsePublicKeyPEM(c.PublicKey); err != nil {
+		return nil, fmt.Errorf("public_key: %w", err)
+	}
+	return c, nil
+}
+
+// catalogSyncer returns a syncer that downloads catalogs under the
+// outbound request policy.
+func (h *handlers) catalogSyncer() *templatesync.Syncer {
+	return templatesync.NewSyncer(h.app.DB, h.outboundGuard().Client(templatesync.FetchTimeout, ssrf.Allowlist{}))
+}
+
+// handleAdminTemplateCatalogs lists and adds remote template catalogs. A
+// new catalog is synced by the next run of the template-catalog-sync job,
+// or at once with POST /api/admin/template-catalogs/{name}/sync.
+func (h *handlers) handleAdminTemplateCatalogs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		catalogs, err := h.app.DB.ListRemoteCatalogs()
+		if err != nil {
+			slog.Error("error listing remote catalogs", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if catalogs == nil {
+			catalogs = []db.RemoteCatalog{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(catalogs)
+
+	case http.MethodPost:
+		var req remoteCatalogRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		catalog, err := req.catalog()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		user := middleware.GetUserFromContext(r.Context())
+		catalog.CreatedBy = user.Username
+		if err := h.app.DB.CreateRemoteCatalog(*catalog); err != nil {
+			if db.IsDuplicateKeyError(err) {
+				http.Error(w, "Template catalog with this name already exists", http.StatusConflict)
+				return
+			}
+			slog.Error("error creating remote catalog", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		created, err := h.app.DB.GetRemoteCatalog(catalog.Name)
+		if err != nil || created == nil {
+			slog.Error("error getting remote catalog", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		h.logAudit(r, user.Username, "CREATE_TEMPLATE_CATALOG", fmt.Sprintf("Added template catalog: %s (%s)", catalog.Name, catalog.URL))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminTemplateCatalogByName gets, updates, deletes and syncs a
+// remote template catalog.
+func (h *handlers) handleAdminTemplateCatalogByName(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/admin/template-catalogs/")
+	name, action, _ := strings.Cut(name, "/")
+	if name == "" {
+		http.Error(w, "Template catalog name required", http.StatusBadRequest)
+		return
+	}
+	switch action {
+	case "":
+	case "sync":
+		h.handleAdminTemplateCatalogSync(w, r, name)
+		return
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
 		return
 	}
 
 	switch r.Method {
 	case http.MethodGet:
-		spec, err := h.app.DB.GetAppSpec(id)
+		catalog, err := h.app.DB.GetRemoteCatalog(name)
 		if err != nil {
-			slog.Error("error getting app spec", "error", err)
+			slog.Error("error getting remote catalog", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
-		if spec == nil {
-			http.Error(w, "AppSpec not found", http.StatusNotFound)
+		if catalog == nil {
+			http.Error(w, "Template catalog not found", http.StatusNotFound)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(spec)
+		json.NewEncoder(w).Encode(catalog)
 
 	case http.MethodPut:
-		user := middleware.GetUserFromContext(r.Context())
-		if !middleware.HasRole(user.Roles, middleware.RoleAdmin, middleware.RoleAppAuthor) {
-			http.Error(w, "Insufficient permissions", http.StatusForbidden)
+		var req remoteCatalogRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
 			return
 		}
-
-		var spec db.AppSpec
-		body, err := io.ReadAll(r.Body)
+		req.Name = name
+		catalog, err := req.catalog()
 		if err != nil {
-			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		if err := json.Unmarshal(body, &spec); err != nil {
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		if err := h.app.DB.UpdateRemoteCatalog(*catalog); err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Template catalog not found", http.StatusNotFound)
+				return
+			}
+			slog.Error("error updating remote catalog", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		spec.ID = id
-
-		if spec.Name == "" || spec.Image == "" {
-			http.Error(w, "Missing required fields: name, image", http.StatusBadRequest)
+		updated, err := h.app.DB.GetRemoteCatalog(name)
+		if err != nil || updated == nil {
+			slog.Error("error getting remote catalog", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		if err := h.app.DB.UpdateAppSpec(spec); err != nil {
-			if err.Error() == "sql: no rows in result set" {
-				http.Error(w, "AppSpec not found", http.StatusNotFound)
+		user := middleware.GetUserFromContext(r.Context())
+		h.logAudit(r, user.Username, "UPDATE_TEMPLATE_CATALOG", fmt.Sprintf("Updated template catalog: %s (%s, on conflict %s)", name, catalog.URL, catalog.OnConflict))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(updated)
+
+	case http.MethodDelete:
+		if err := h.app.DB.DeleteRemoteCatalog(name); err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Template catalog not found", http.StatusNotFound)
 				return
 			}
-			slog.Error("error updating app spec", "error", err)
+			slog.Error("error deleting remote catalog", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		h.app.DB.LogAudit(user.Username, "UPDATE_APPSPEC", fmt.Sprintf("Updated app spec: %s (%s)", spec.Name, spec.ID))
+		user := middleware.GetUserFromContext(r.Context())
+		h.logAudit(r, user.Username, "DELETE_TEMPLATE_CATALOG", fmt.Sprintf("Deleted template catalog: %s", name))
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(spec)
+		w.WriteHeader(http.StatusNoContent)
 
-	case http.MethodDelete:
-		user := middleware.GetUserFromContext(r.Context())
-		if !middleware.HasRole(user.Roles, middleware.RoleAdmin, middleware.RoleAppAuthor) {
-			http.Error(w, "Insufficient permissions", http.StatusForbidden)
-			return
-		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
 
-		spec, err := h.app.DB.GetAppSpec(id)
+// handleAdminTemplateCatalogSync syncs a remote catalog now (POST) and
+// returns it with the outcome. A sync that fails is reported in the
+// catalog's sync_status and sync_error rather than as an HTTP error.
+func (h *handlers) handleAdminTemplateCatalogSync(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	catalog, err := h.app.DB.GetRemoteCatalog(name)
+	if err != nil {
+		slog.Error("error getting remote catalog", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if catalog == nil {
+		http.Error(w, "Template catalog not found", http.StatusNotFound)
+		return
+	}
+
+	details := fmt.Sprintf("Synced template catalog: %s", name)
+	if err := h.catalogSyncer().Sync(r.Context(), catalog); err != nil {
+		details = fmt.Sprintf("Failed to sync template catalog: %s: %v", name, err)
+	} else if res := catalog.SyncResult; catalog.SyncStatus == db.CatalogSyncOK && res != nil {
+		details = fmt.Sprintf("Synced template catalog: %s (%d created, %d updated, %d removed, %d conflicts)",
+			name, len(res.Created), len(res.Updated), len(res.Removed), len(res.Conflicts))
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	h.logAudit(r, user.Username, "SYNC_TEMPLATE_CATALOG", details)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(catalog)
+}
+
+// --- Sidecar templates ---
+
+// validateSidecarTemplate checks a sidecar template before it is saved: the
+// container it renders must be valid and a scoped tenant must exist.
+func (h *handlers) validateSidecarTemplate(tmpl db.SidecarTemplate) (int, error) {
+	if err := k8s.ValidateSidecar(sidecar.FromTemplate(tmpl)); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if tmpl.TenantID != "" {
+		tenant, err := h.app.DB.GetTenant(tmpl.TenantID)
 		if err != nil {
-			slog.Error("error getting app spec", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
+			return http.StatusInternalServerError, err
 		}
-		if spec == nil {
-			http.Error(w, "AppSpec not found", http.StatusNotFound)
-			return
+		if tenant == nil {
+			return http.StatusBadRequest, fmt.Errorf("tenant %s not found", tmpl.TenantID)
 		}
+	}
+	return 0, nil
+}
 
-		if err := h.app.DB.DeleteAppSpec(id); err != nil {
-			slog.Error("error deleting app spec", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
+// checkSidecarRefs checks that every named sidecar template exists and is
+// available to the tenant, and that none is named twice.
+func (h *handlers) checkSidecarRefs(names []string, tenantID string) (int, error) {
+	seen := map[string]bool{}
+	for _, name := range names {
+		if seen[name] {
+			return http.StatusBadRequest, fmt.Errorf("sidecar %s is listed more than once", name)
 		}
+		seen[name] = true
 
-		h.app.DB.LogAudit(user.Username, "DELETE_APPSPEC", fmt.Sprintf("Deleted app spec: %s (%s)", spec.Name, id))
+		tmpl, err := h.app.DB.GetSidecarTemplate(name)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if tmpl == nil {
+			return http.StatusBadRequest, fmt.Errorf("sidecar template %s not found", name)
+		}
+		if !tmpl.AvailableTo(tenantID) {
+			return http.StatusBadRequest, fmt.Errorf("sidecar template %s is not available to this tenant", name)
+		}
+	}
+	return 0, nil
+}
 
-		w.WriteHeader(http.StatusNoContent)
+// httpError is returned from a WithTx closure to roll the transaction back
+// and reply with Code and Message.
+type httpError struct {
+	Code    int
+	Message string
+}
 
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+func (e *httpError) Error() string { return e.Message }
+
+// writeTxError replies with an error from WithTx: the status and message of
+// an httpError, or Internal server error, logged as action, for any other.
+func writeTxError(w http.ResponseWriter, r *http.Request, err error, action string) {
+	var he *httpError
+	if errors.As(err, &he) {
+		middleware.LocalizedError(w, r, he.Message, he.Code)
+		return
 	}
+	slog.Error("error "+action, "error", err)
+	middleware.LocalizedError(w, r, "Internal server error", http.StatusInternalServerError)
 }
 
-// --- Session endpoints ---
+// writeSidecarError replies with an error from validateSidecarTemplate or
+// checkSidecarRefs, logging internal errors instead of returning them.
+func writeSidecarError(w http.ResponseWriter, code int, err error) {
+	if code == http.StatusInternalServerError {
+		slog.Error("error checking sidecar templates", "error", err)
+		http.Error(w, "Internal server error", code)
+		return
+	}
+	http.Error(w, err.Error(), code)
+}
 
-func (h *handlers) handleSessions(w http.ResponseWriter, r *http.Request) {
+func (h *handlers) handleAdminSidecars(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		userID := r.URL.Query().Get("user_id")
-		var sessionList []db.Session
-		var err error
-
-		if userID != "" {
-			sessionList, err = h.app.SessionManager.ListSessionsByUser(r.Context(), userID)
-		} else {
-			// Default to the authenticated user's sessions (admins use /api/admin/sessions for all)
-			user := middleware.GetUserFromContext(r.Context())
-			if user != nil {
-				sessionList, err = h.app.SessionManager.ListSessionsByUser(r.Context(), user.ID)
-			} else {
-				sessionList, err = h.app.SessionManager.ListSessions(r.Context())
-			}
-		}
-
+		templates, err := h.app.DB.ListSidecarTemplates()
 		if err != nil {
-			slog.Error("error listing sessions", "error", err)
+			slog.Error("error listing sidecar templates", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		if sessionList == nil {
-			sessionList = []db.Session{}
-		}
-
-		recPolicy := h.getRecordingPolicy()
-		responses := make([]sessions.SessionResponse, len(sessionList))
-		for i, s := range sessionList {
-			app, _ := h.app.DB.GetApp(s.AppID)
-			appName := ""
-			wsURL := ""
-			guacURL := ""
-			proxyURL := ""
-			if app != nil {
-				appName = app.Name
-				if app.LaunchType == db.LaunchTypeContainer || app.LaunchType == db.LaunchTypeWebProxy {
-					if app.OsType == "windows" {
-						guacURL = h.app.SessionManager.GetSessionGuacWebSocketURL(&s)
-					} else {
-						wsURL = h.app.SessionManager.GetSessionWebSocketURL(&s)
-					}
-				}
-			}
-			responses[i] = *sessions.SessionFromDB(&s, appName, wsURL, guacURL, proxyURL, recPolicy)
+		if templates == nil {
+			templates = []db.SidecarTemplate{}
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(responses)
+		json.NewEncoder(w).Encode(templates)
 
 	case http.MethodPost:
-		var req sessions.CreateSessionRequest
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, "Failed to read request body", http.StatusBadRequest)
-			return
-		}
-
-		if err := json.Unmarshal(body, &req); err != nil {
+		var tmpl db.SidecarTemplate
+		if err := json.NewDecoder(r.Body).Decode(&tmpl); err != nil {
 			http.Error(w, "Invalid JSON", http.StatusBadRequest)
 			return
 		}
 
-		if req.AppID == "" {
-			http.Error(w, "Missing required field: app_id", http.StatusBadRequest)
+		if code, err := h.validateSidecarTemplate(tmpl); err != nil {
+			writeSidecarError(w, code, err)
 			return
 		}
 
-		if req.UserID == "" {
-			user := middleware.GetUserFromContext(r.Context())
-			if user != nil {
-				req.UserID = user.ID
-			} else {
-				req.UserID = "anonymous"
-			}
-		}
-
-		session, err := h.app.SessionManager.CreateSession(r.Context(), &req)
-		if err != nil {
-			switch err.(type) {
-			case *sessions.QuotaExceededError:
-				loadStatus := h.app.BackpressureHandler.GetLoadStatus()
-				sessions.WriteRetryAfter(w, loadStatus.LoadFactor)
-				http.Error(w, err.Error(), http.StatusTooManyRequests)
-				return
-			case *sessions.QueueFullError:
-				loadStatus := h.app.BackpressureHandler.GetLoadStatus()
-				sessions.WriteRetryAfter(w, loadStatus.LoadFactor)
-				http.Error(w, err.Error(), http.StatusTooManyRequests)
-				return
-			case *sessions.QueueTimeoutError:
-				sessions.WriteRetryAfter(w, 1.0)
-				http.Error(w, err.Error(), http.StatusServiceUnavailable)
-				return
-			default:
-				slog.Error("error creating session", "error", err)
-				http.Error(w, err.Error(), http.StatusBadRequest)
+		if err := h.app.DB.CreateSidecarTemplate(tmpl); err != nil {
+			if db.IsDuplicateKeyError(err) {
+				http.Error(w, "Sidecar template with this name already exists", http.StatusConflict)
 				return
 			}
+			slog.Error("error creating sidecar template", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
 		}
 
-		app, _ := h.app.DB.GetApp(session.AppID)
-		appName := ""
-		wsURL := ""
-		guacURL := ""
-		proxyURL := ""
-		if app != nil {
-			appName = app.Name
-			if app.LaunchType == db.LaunchTypeContainer || app.LaunchType == db.LaunchTypeWebProxy {
-				if app.OsType == "windows" {
-					guacURL = h.app.SessionManager.GetSessionGuacWebSocketURL(session)
-				} else {
-					wsURL = h.app.SessionManager.GetSessionWebSocketURL(session)
-				}
-			}
+		created, err := h.app.DB.GetSidecarTemplate(tmpl.Name)
+		if err != nil || created == nil {
+			slog.Error("error getting sidecar template", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
 		}
 
-		response := sessions.SessionFromDB(session, appName, wsURL, guacURL, proxyURL, h.getRecordingPolicy())
-
-		details := fmt.Sprintf("Created session %s for app %s", session.ID, session.AppID)
-		h.app.DB.LogAudit(req.UserID, "CREATE_SESSION", details)
+		user := middleware.GetUserFromContext(r.Context())
+		h.logAudit(r, user.Username, "CREATE_SIDECAR_TEMPLATE", fmt.Sprintf("Created sidecar template: %s (%s)", tmpl.Name, tmpl.Spec.Image))
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(response)
+		json.NewEncoder(w).Encode(created)
 
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
-func (h *handlers) handleSessionByID(w http.ResponseWriter, r *http.Request) {
-	remainder := strings.TrimPrefix(r.URL.Path, "/api/sessions/")
-	if remainder == "" {
-		http.Error(w, "Missing session ID", http.StatusBadRequest)
-		return
-	}
-
-	parts := strings.SplitN(remainder, "/", 2)
-	id := parts[0]
-	action := ""
-	if len(parts) > 1 {
-		action = parts[1]
-	}
-
-	switch {
-	case action == "stop":
-		h.handleSessionStop(w, r, id)
-		return
-	case action == "restart":
-		h.handleSessionRestart(w, r, id)
-		return
-	case action == "files" || strings.HasPrefix(action, "files/"):
-		h.app.FileHandler.ServeHTTP(w, r)
-		return
-	case action == "shares" || strings.HasPrefix(action, "shares/"):
-		h.handleSessionShares(w, r, id, strings.TrimPrefix(action, "shares"))
-		return
-	case strings.HasPrefix(action, "recording/"):
-		if h.app.RecordingHandler != nil {
-			h.app.RecordingHandler.ServeHTTP(w, r)
-		} else {
-			http.Error(w, "Video recording not enabled", http.StatusNotFound)
-		}
-		return
-	case action == "":
-		// Fall through
-	default:
-		http.Error(w, "Unknown session action", http.StatusNotFound)
+func (h *handlers) handleAdminSidecarByName(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/admin/sidecars/")
+	if name == "" {
+		http.Error(w, "Sidecar template name required", http.StatusBadRequest)
 		return
 	}
 
 	switch r.Method {
 	case http.MethodGet:
-		session, err := h.app.SessionManager.GetSession(r.Context(), id)
+		tmpl, err := h.app.DB.GetSidecarTemplate(name)
 		if err != nil {
-			slog.Error("error getting session", "error", err)
+			slog.Error("error getting sidecar template", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
-		if session == nil {
-			http.Error(w, "Session not found", http.StatusNotFound)
+		if tmpl == nil {
+			http.Error(w, "Sidecar template not found", http.StatusNotFound)
 			return
 		}
 
-		app, _ := h.app.DB.GetApp(session.AppID)
-		appName := ""
-		wsURL := ""
-		guacURL := ""
-		proxyURL := ""
-		if app != nil {
-			appName = app.Name
-			if app.LaunchType == db.LaunchTypeContainer || app.LaunchType == db.LaunchTypeWebProxy {
-				if app.OsType == "windows" {
-					guacURL = h.app.SessionManager.GetSessionGuacWebSocketURL(session)
-				} else {
-					wsURL = h.app.SessionManager.GetSessionWebSocketURL(session)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tmpl)
+
+	case http.MethodPut:
+		var tmpl db.SidecarTemplate
+		if err := json.NewDecoder(r.Body).Decode(&tmpl); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		tmpl.Name = name
+
+		if code, err := h.validateSidecarTemplate(tmpl); err != nil {
+			writeSidecarError(w, code, err)
+			return
+		}
+
+		// Scoping a template to a tenant must not strand other tenants'
+		// apps that already use it.
+		if tmpl.TenantID != "" {
+			appIDs, tenantIDs, err := h.app.DB.SidecarTemplateUsers(name)
+			if err != nil {
+				slog.Error("error checking sidecar template usage", "error", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			for _, tenantID := range tenantIDs {
+				if tenantID != tmpl.TenantID {
+					http.Error(w, fmt.Sprintf("Sidecar template is attached to tenant %s", tenantID), http.StatusConflict)
+					return
+				}
+			}
+			for _, appID := range appIDs {
+				if app, _ := h.app.DB.GetApp(appID); app != nil && app.TenantID != tmpl.TenantID {
+					http.Error(w, fmt.Sprintf("Sidecar template is attached to app %s of another tenant", appID), http.StatusConflict)
+					return
 				}
 			}
 		}
 
-		response := sessions.SessionFromDB(session, appName, wsURL, guacURL, proxyURL, h.getRecordingPolicy())
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
-
-	case http.MethodDelete:
-		session, err := h.app.SessionManager.GetSession(r.Context(), id)
-		if err != nil {
-			slog.Error("error getting session", "error", err)
+		if err := h.app.DB.UpdateSidecarTemplate(tmpl); err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Sidecar template not found", http.StatusNotFound)
+				return
+			}
+			slog.Error("error updating sidecar template", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
-		if session == nil {
-			http.Error(w, "Session not found", http.StatusNotFound)
+
+		updated, err := h.app.DB.GetSidecarTemplate(name)
+		if err != nil || updated == nil {
+			slog.Error("error getting sidecar template", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		if err := h.app.SessionManager.TerminateSession(r.Context(), id); err != nil {
-			slog.Error("error terminating session", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		user := middleware.GetUserFromContext(r.Context())
+		h.logAudit(r, user.Username, "UPDATE_SIDECAR_TEMPLATE", fmt.Sprintf("Updated sidecar template: %s (%s)", name, tmpl.Spec.Image))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(updated)
+
+	case http.MethodDelete:
+		// Check usage in the same transaction, so an app can't attach the
+		// template between the check and the delete
+		err := h.app.DB.WithTx(func(tx *db.DB) error {
+			appIDs, tenantIDs, err := tx.SidecarTemplateUsers(name)
+			if err != nil {
+				return err
+			}
+			if len(appIDs) > 0 || len(tenantIDs) > 0 {
+				return &httpError{http.StatusConflict, fmt.Sprintf("Sidecar template is in use by %d app(s) and %d tenant(s)", len(appIDs), len(tenantIDs))}
+			}
+			return tx.DeleteSidecarTemplate(name)
+		})
+		if err == sql.ErrNoRows {
+			http.Error(w, "Sidecar template not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			writeTxError(w, r, err, "deleting sidecar template")
 			return
 		}
 
-		details := fmt.Sprintf("Terminated session %s", id)
-		h.app.DB.LogAudit("admin", "TERMINATE_SESSION", details)
+		user := middleware.GetUserFromContext(r.Context())
+		h.logAudit(r, user.Username, "DELETE_SIDECAR_TEMPLATE", fmt.Sprintf("Deleted sidecar template: %s", name))
 
 		w.WriteHeader(http.StatusNoContent)
 
@@ -1213,1371 +7411,1640 @@ func (h *handlers) handleSessionByID(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *handlers) handleSessionStop(w http.ResponseWriter, r *http.Request, id string) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+// registryRequest is the body of a registry credential create or update.
+// Password may be left out of an update to keep the stored one.
+type registryRequest struct {
+	Name     string `json:"name"`
+	Server   string `json:"server"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email"`
+}
 
-	if err := h.app.SessionManager.StopSession(r.Context(), id); err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			http.Error(w, "Session not found", http.StatusNotFound)
-			return
-		}
-		if strings.Contains(err.Error(), "invalid session state") {
-			http.Error(w, err.Error(), http.StatusConflict)
-			return
-		}
-		slog.Error("error stopping session", "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+func (req *registryRequest) validate(create bool) error {
+	if req.Name == "" {
+		return errors.New("Missing required field: name")
 	}
-
-	session, err := h.app.SessionManager.GetSession(r.Context(), id)
-	if err != nil {
-		slog.Error("error getting session after stop", "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+	if err := k8s.ValidateImagePullSecret(req.Name); err != nil {
+		return err
 	}
-
-	app, _ := h.app.DB.GetApp(session.AppID)
-	appName := ""
-	if app != nil {
-		appName = app.Name
+	if req.Server == "" || strings.ContainsAny(req.Server, " \t\r\n") {
+		return errors.New("server must be a registry host, such as ghcr.io")
 	}
-	response := sessions.SessionFromDB(session, appName, "", "", "", "")
-
-	h.app.DB.LogAudit("user", "STOP_SESSION", fmt.Sprintf("Stopped session %s", id))
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	if req.Username == "" {
+		return errors.New("Missing required field: username")
+	}
+	if create && req.Password == "" {
+		return errors.New("Missing required field: password")
+	}
+	return nil
 }
 
-func (h *handlers) handleSessionRestart(w http.ResponseWriter, r *http.Request, id string) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// credentialCipher returns the cipher that seals registry passwords: the
+// storage encryption cipher if one is configured, or else one keyed from
+// the JWT secret.
+func (h *handlers) credentialCipher() (*storagecrypt.Cipher, error) {
+	if h.app.CredentialCipher != nil {
+		return h.app.CredentialCipher, nil
 	}
-
-	session, err := h.app.SessionManager.RestartSession(r.Context(), id)
+	key := sha256.Sum256([]byte("sortie-registry-credentials\x00" + h.app.Config.JWTSecret))
+	keys, err := storagecrypt.ParseKeys("jwt:" + base64.StdEncoding.EncodeToString(key[:]))
 	if err != nil {
-		if _, ok := err.(*sessions.QuotaExceededError); ok {
-			http.Error(w, err.Error(), http.StatusTooManyRequests)
-			return
-		}
-		if strings.Contains(err.Error(), "not found") {
-			http.Error(w, "Session not found", http.StatusNotFound)
-			return
-		}
-		if strings.Contains(err.Error(), "must be stopped") {
-			http.Error(w, err.Error(), http.StatusConflict)
-			return
-		}
-		slog.Error("error restarting session", "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+		return nil, err
 	}
+	return storagecrypt.New(keys), nil
+}
 
-	app, _ := h.app.DB.GetApp(session.AppID)
-	appName := ""
-	wsURL := ""
-	guacURL := ""
-	proxyURL := ""
-	if app != nil {
-		appName = app.Name
-		if app.LaunchType == db.LaunchTypeContainer || app.LaunchType == db.LaunchTypeWebProxy {
-			if app.OsType == "windows" {
-				guacURL = h.app.SessionManager.GetSessionGuacWebSocketURL(session)
-			} else {
-				wsURL = h.app.SessionManager.GetSessionWebSocketURL(session)
-			}
-		}
+// sealRegistryPassword encrypts a registry password for storage.
+func (h *handlers) sealRegistryPassword(password string) (string, error) {
+	c, err := h.credentialCipher()
+	if err != nil {
+		return "", err
 	}
-
-	response := sessions.SessionFromDB(session, appName, wsURL, guacURL, proxyURL, h.getRecordingPolicy())
-
-	h.app.DB.LogAudit("user", "RESTART_SESSION", fmt.Sprintf("Restarted session %s", id))
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	sealed, err := c.Encrypt(strings.NewReader(password))
+	if err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(sealed)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
 }
 
-// --- Session sharing endpoints ---
-
-func (h *handlers) handleSessionShares(w http.ResponseWriter, r *http.Request, sessionID string, subPath string) {
-	user := middleware.GetUserFromContext(r.Context())
-	if user == nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
+// openRegistryPassword decrypts a password sealed by sealRegistryPassword.
+func (h *handlers) openRegistryPassword(encrypted string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", err
 	}
-
-	// Check that the session exists
-	session, err := h.app.SessionManager.GetSession(r.Context(), sessionID)
+	c, err := h.credentialCipher()
 	if err != nil {
-		slog.Error("error getting session for shares", "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+		return "", err
 	}
-	if session == nil {
-		http.Error(w, "Session not found", http.StatusNotFound)
-		return
+	plain, err := c.Decrypt(bytes.NewReader(data))
+	if err != nil {
+		return "", err
 	}
+	password, err := io.ReadAll(plain)
+	return string(password), err
+}
 
-	// Only the session owner can manage shares
-	isOwner := session.UserID == user.ID
-
-	// Handle DELETE /api/sessions/{id}/shares/{shareId}
-	shareID := strings.TrimPrefix(subPath, "/")
-	if shareID != "" {
-		if r.Method != http.MethodDelete {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		if !isOwner && !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
-			http.Error(w, "Forbidden: only session owner can revoke shares", http.StatusForbidden)
-			return
-		}
-		if err := h.app.DB.DeleteSessionShare(shareID); err != nil {
-			http.Error(w, "Share not found", http.StatusNotFound)
-			return
-		}
-		h.app.DB.LogAudit(user.Username, "REVOKE_SESSION_SHARE", fmt.Sprintf("Revoked share %s for session %s", shareID, sessionID))
-		w.WriteHeader(http.StatusNoContent)
-		return
+// syncRegistrySecret writes the credential's image pull secret and records
+// the outcome on the credential. A failure is recorded rather than
+// returned, so that the credential is kept and can be saved again.
+func (h *handlers) syncRegistrySecret(ctx context.Context, cred *db.RegistryCredential) {
+	syncErr := h.app.SessionManager.ApplyRegistrySecret(ctx, cred)
+	if syncErr != nil {
+		slog.Warn("failed to write registry secret", "name", cred.Name, "error", syncErr)
 	}
+	if err := h.app.DB.SetRegistryCredentialSync(cred.Name, syncErr); err != nil {
+		slog.Error("error recording registry secret sync", "name", cred.Name, "error", err)
+	}
+}
 
+// handleAdminRegistries lists and creates registry credentials.
+func (h *handlers) handleAdminRegistries(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		if !isOwner && !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
-			http.Error(w, "Forbidden: only session owner can list shares", http.StatusForbidden)
-			return
-		}
-		shares, err := h.app.DB.ListSessionShares(sessionID)
+		creds, err := h.app.DB.ListRegistryCredentials()
 		if err != nil {
-			slog.Error("error listing session shares", "error", err)
+			slog.Error("error listing registry credentials", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
-		if shares == nil {
-			shares = []db.SessionShare{}
-		}
 
-		responses := make([]sessions.ShareResponse, len(shares))
-		for i, s := range shares {
-			resp := sessions.ShareResponse{
-				ID:         s.ID,
-				SessionID:  s.SessionID,
-				UserID:     s.UserID,
-				Permission: string(s.Permission),
-				CreatedAt:  s.CreatedAt.Format(time.RFC3339),
-			}
-			if s.UserID != "" {
-				u, _ := h.app.DB.GetUserByID(s.UserID)
-				if u != nil {
-					resp.Username = u.Username
-				}
-			}
-			if s.ShareToken != "" {
-				resp.ShareURL = fmt.Sprintf("/session/%s?share_token=%s", sessionID, s.ShareToken)
-			}
-			responses[i] = resp
+		if creds == nil {
+			creds = []db.RegistryCredential{}
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(responses)
+		json.NewEncoder(w).Encode(creds)
 
 	case http.MethodPost:
-		if !isOwner && !middleware.HasRole(user.Roles, middleware.RoleAdmin) {
-			http.Error(w, "Forbidden: only session owner can create shares", http.StatusForbidden)
+		var req registryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
 			return
 		}
-
-		// Only container sessions can be shared
-		app, _ := h.app.DB.GetApp(session.AppID)
-		if app != nil && app.LaunchType != db.LaunchTypeContainer {
-			http.Error(w, "Only container sessions can be shared", http.StatusBadRequest)
+		if err := req.validate(true); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		var req sessions.CreateShareRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		sealed, err := h.sealRegistryPassword(req.Password)
+		if err != nil {
+			slog.Error("error encrypting registry password", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		perm := db.SharePermissionReadOnly
-		if req.Permission == "read_write" {
-			perm = db.SharePermissionReadWrite
+		user := middleware.GetUserFromContext(r.Context())
+		cred := db.RegistryCredential{
+			Name:              req.Name,
+			Server:            req.Server,
+			Username:          req.Username,
+			Email:             req.Email,
+			Password:          req.Password,
+			EncryptedPassword: sealed,
+			CreatedBy:         user.Username,
+		}
+		if err := h.app.DB.CreateRegistryCredential(cred); err != nil {
+			if db.IsDuplicateKeyError(err) {
+				http.Error(w, "Registry credential with this name already exists", http.StatusConflict)
+				return
+			}
+			slog.Error("error creating registry credential", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
 		}
+		h.syncRegistrySecret(r.Context(), &cred)
 
-		share := db.SessionShare{
-			ID:         fmt.Sprintf("share-%d", time.Now().UnixNano()),
-			SessionID:  sessionID,
-			Permission: perm,
-			CreatedBy:  user.ID,
-			CreatedAt:  time.Now(),
+		created, err := h.app.DB.GetRegistryCredential(cred.Name)
+		if err != nil || created == nil {
+			slog.Error("error getting registry credential", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
 		}
 
-		resp := sessions.ShareResponse{
-			SessionID:  sessionID,
-			Permission: string(perm),
+		h.logAudit(r, user.Username, "CREATE_REGISTRY", fmt.Sprintf("Created registry credential: %s (%s@%s)", cred.Name, cred.Username, cred.Server))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminRegistryByName gets, updates, and deletes a registry
+// credential.
+func (h *handlers) handleAdminRegistryByName(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/admin/registries/")
+	if name == "" {
+		http.Error(w, "Registry credential name required", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		cred, err := h.app.DB.GetRegistryCredential(name)
+		if err != nil {
+			slog.Error("error getting registry credential", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if cred == nil {
+			http.Error(w, "Registry credential not found", http.StatusNotFound)
+			return
 		}
 
-		if req.LinkShare {
-			token := fmt.Sprintf("%d", time.Now().UnixNano())
-			share.ShareToken = token
-			resp.ShareURL = fmt.Sprintf("/session/%s?share_token=%s", sessionID, token)
-		} else {
-			// Resolve user by username or ID
-			targetUserID := req.UserID
-			if targetUserID == "" && req.Username != "" {
-				u, err := h.app.DB.GetUserByUsername(req.Username)
-				if err != nil || u == nil {
-					http.Error(w, "User not found", http.StatusNotFound)
-					return
-				}
-				targetUserID = u.ID
-				resp.Username = u.Username
-			}
-			if targetUserID == "" {
-				http.Error(w, "Either user_id, username, or link_share is required", http.StatusBadRequest)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(cred)
+
+	case http.MethodPut:
+		var req registryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		req.Name = name
+		if err := req.validate(false); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		cred, err := h.app.DB.GetRegistryCredential(name)
+		if err != nil {
+			slog.Error("error getting registry credential", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if cred == nil {
+			http.Error(w, "Registry credential not found", http.StatusNotFound)
+			return
+		}
+
+		if req.Password != "" {
+			sealed, err := h.sealRegistryPassword(req.Password)
+			if err != nil {
+				slog.Error("error encrypting registry password", "error", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
 				return
 			}
-			if targetUserID == user.ID {
-				http.Error(w, "Cannot share a session with yourself", http.StatusBadRequest)
+			cred.Password = req.Password
+			cred.EncryptedPassword = sealed
+		} else {
+			// The stored password is rewritten to the Secret with the new
+			// server and username
+			password, err := h.openRegistryPassword(cred.EncryptedPassword)
+			if err != nil {
+				slog.Warn("failed to decrypt registry password", "name", name, "error", err)
+				http.Error(w, "Stored password cannot be decrypted; include the password", http.StatusConflict)
 				return
 			}
-			share.UserID = targetUserID
+			cred.Password = password
 		}
+		cred.Server = req.Server
+		cred.Username = req.Username
+		cred.Email = req.Email
 
-		if err := h.app.DB.CreateSessionShare(share); err != nil {
-			slog.Error("error creating session share", "error", err)
+		if err := h.app.DB.UpdateRegistryCredential(*cred); err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Registry credential not found", http.StatusNotFound)
+				return
+			}
+			slog.Error("error updating registry credential", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
+		h.syncRegistrySecret(r.Context(), cred)
 
-		resp.ID = share.ID
-		resp.UserID = share.UserID
-		resp.CreatedAt = share.CreatedAt.Format(time.RFC3339)
+		updated, err := h.app.DB.GetRegistryCredential(name)
+		if err != nil || updated == nil {
+			slog.Error("error getting registry credential", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
 
-		h.app.DB.LogAudit(user.Username, "CREATE_SESSION_SHARE", fmt.Sprintf("Shared session %s (permission=%s)", sessionID, perm))
+		user := middleware.GetUserFromContext(r.Context())
+		h.logAudit(r, user.Username, "UPDATE_REGISTRY", fmt.Sprintf("Updated registry credential: %s (%s@%s)", name, cred.Username, cred.Server))
 
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(resp)
+		json.NewEncoder(w).Encode(updated)
+
+	case http.MethodDelete:
+		appIDs, tenantIDs, err := h.app.DB.RegistryCredentialUsers(name)
+		if err != nil {
+			slog.Error("error checking registry credential usage", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if len(appIDs) > 0 || len(tenantIDs) > 0 {
+			http.Error(w, fmt.Sprintf("Registry credential is in use by %d app(s) and %d tenant(s)", len(appIDs), len(tenantIDs)), http.StatusConflict)
+			return
+		}
+
+		cred, err := h.app.DB.GetRegistryCredential(name)
+		if err != nil {
+			slog.Error("error getting registry credential", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if cred == nil {
+			http.Error(w, "Registry credential not found", http.StatusNotFound)
+			return
+		}
+
+		if err := h.app.SessionManager.DeleteRegistrySecret(r.Context(), name); err != nil {
+			slog.Error("error deleting registry secret", "name", name, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err := h.app.DB.DeleteRegistryCredential(name); err != nil && err != sql.ErrNoRows {
+			slog.Error("error deleting registry credential", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		user := middleware.GetUserFromContext(r.Context())
+		h.logAudit(r, user.Username, "DELETE_REGISTRY", fmt.Sprintf("Deleted registry credential: %s", name))
+
+		w.WriteHeader(http.StatusNoContent)
 
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
-func (h *handlers) handleSharedSessions(w http.ResponseWriter, r *http.Request) {
+// --- Diagnostics / Health / Support ---
+
+func (h *handlers) handleDiagnosticsBundle(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	user := middleware.GetUserFromContext(r.Context())
-	if user == nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	h.logAudit(r, user.Username, "GENERATE_DIAGNOSTICS", "Generated diagnostics bundle")
+
+	if r.Header.Get("Accept") == "application/gzip" {
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=diagnostics-%s.tar.gz", time.Now().UTC().Format("20060102-150405")))
+		if err := h.app.DiagCollector.WriteTarGz(r.Context(), w); err != nil {
+			slog.Error("failed to generate diagnostics archive", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
 		return
 	}
 
-	rows, err := h.app.DB.ListSharedSessionsForUser(user.ID)
+	bundle, err := h.app.DiagCollector.Collect(r.Context())
 	if err != nil {
-		slog.Error("error listing shared sessions", "error", err)
+		slog.Error("failed to collect diagnostics", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	responses := make([]sessions.SessionResponse, 0, len(rows))
-	for _, r := range rows {
-		app, _ := h.app.DB.GetApp(r.Session.AppID)
-		wsURL := ""
-		guacURL := ""
-		if app != nil {
-			if app.LaunchType == db.LaunchTypeContainer {
-				if app.OsType == "windows" {
-					guacURL = h.app.SessionManager.GetSessionGuacWebSocketURL(&r.Session)
-				} else {
-					wsURL = h.app.SessionManager.GetSessionWebSocketURL(&r.Session)
-				}
-			}
-		}
-
-		resp := *sessions.SessionFromDB(&r.Session, r.AppName, wsURL, guacURL, "", "")
-		resp.IsShared = true
-		resp.OwnerUsername = r.OwnerUsername
-		resp.SharePermission = string(r.Permission)
-		resp.ShareID = r.ShareID
-		responses = append(responses, resp)
-	}
-
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(responses)
+	json.NewEncoder(w).Encode(bundle)
 }
 
-func (h *handlers) handleJoinShare(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
+// handleAdminStorage reports object storage usage for each configured
+// storage category.
+func (h *handlers) handleAdminStorage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	user := middleware.GetUserFromContext(r.Context())
-	if user == nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
+	usage := []storagebrowser.Usage{}
+	if h.app.StorageBrowser != nil {
+		var err error
+		usage, err = h.app.StorageBrowser.Usage()
+		if err != nil {
+			slog.Error("failed to collect storage usage", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
 	}
 
-	var req sessions.JoinShareRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
-		return
-	}
-	if req.Token == "" {
-		http.Error(w, "Token is required", http.StatusBadRequest)
-		return
-	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"categories": usage})
+}
 
-	share, err := h.app.DB.GetSessionShareByToken(req.Token)
-	if err != nil {
-		slog.Error("error looking up share token", "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-	if share == nil {
-		http.Error(w, "Invalid or expired share token", http.StatusNotFound)
+// handleAdminStorageCategory serves /api/admin/storage/{category}/orphans
+// and /api/admin/storage/{category}/cleanup.
+func (h *handlers) handleAdminStorageCategory(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/admin/storage/"), "/")
+	if len(parts) != 2 || parts[0] == "" || h.app.StorageBrowser == nil {
+		http.Error(w, "Not found", http.StatusNotFound)
 		return
 	}
+	category := parts[0]
 
-	// If this is a link-share with no user assigned, assign this user
-	if share.UserID == "" {
-		h.app.DB.UpdateSessionShareUserID(share.ID, user.ID)
-	} else if share.UserID != user.ID {
-		// Link was already claimed by someone else; create a new share for this user
-		newShare := db.SessionShare{
-			ID:         fmt.Sprintf("share-%d", time.Now().UnixNano()),
-			SessionID:  share.SessionID,
-			UserID:     user.ID,
-			Permission: share.Permission,
-			CreatedBy:  share.CreatedBy,
-			CreatedAt:  time.Now(),
+	switch parts[1] {
+	case "orphans":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
 		}
-		if err := h.app.DB.CreateSessionShare(newShare); err != nil {
-			slog.Error("error creating share for joining user", "error", err)
+		orphans, err := h.app.StorageBrowser.Orphans(category)
+		if errors.Is(err, storagebrowser.ErrUnknownCategory) {
+			http.Error(w, "Storage category not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.Error("failed to find orphaned objects", "category", category, "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
-	}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"category": category, "orphans": orphans})
 
-	// Return the session info
-	session, err := h.app.SessionManager.GetSession(r.Context(), share.SessionID)
-	if err != nil || session == nil {
-		http.Error(w, "Session not found", http.StatusNotFound)
-		return
-	}
+	case "cleanup":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			Paths []string `json:"paths"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
 
-	app, _ := h.app.DB.GetApp(session.AppID)
-	appName := ""
-	wsURL := ""
-	guacURL := ""
-	if app != nil {
-		appName = app.Name
-		if app.LaunchType == db.LaunchTypeContainer {
-			if app.OsType == "windows" {
-				guacURL = h.app.SessionManager.GetSessionGuacWebSocketURL(session)
-			} else {
-				wsURL = h.app.SessionManager.GetSessionWebSocketURL(session)
-			}
+		deleted, err := h.app.StorageBrowser.DeleteOrphans(category, req.Paths)
+		if len(deleted) > 0 {
+			currentUser := middleware.GetUserFromContext(r.Context())
+			h.logAudit(r, currentUser.Username, "STORAGE_CLEANUP",
+				fmt.Sprintf("Deleted %d orphaned %s objects", len(deleted), category))
+		}
+		switch {
+		case errors.Is(err, storagebrowser.ErrUnknownCategory):
+			http.Error(w, "Storage category not found", http.StatusNotFound)
+			return
+		case errors.Is(err, storagebrowser.ErrNotOrphaned):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			slog.Error("failed to clean up orphaned objects", "category", category, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
 		}
-	}
 
-	resp := *sessions.SessionFromDB(session, appName, wsURL, guacURL, "", "")
-	resp.IsShared = true
-	resp.SharePermission = string(share.Permission)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"category": category, "deleted": deleted})
 
-	// Get owner username
-	owner, _ := h.app.DB.GetUserByID(session.UserID)
-	if owner != nil {
-		resp.OwnerUsername = owner.Username
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
 	}
-
-	h.app.DB.LogAudit(user.Username, "JOIN_SESSION_SHARE", fmt.Sprintf("Joined session %s via share token", share.SessionID))
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
 }
 
-// --- Quota endpoint ---
-
-func (h *handlers) handleQuotas(w http.ResponseWriter, r *http.Request) {
+func (h *handlers) handleAdminHealth(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	user := middleware.GetUserFromContext(r.Context())
-	userID := "anonymous"
-	if user != nil {
-		userID = user.ID
-	}
-
-	status, err := h.app.SessionManager.GetQuotaStatus(userID)
-	if err != nil {
-		slog.Error("error getting quota status", "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+	dbHealthy := true
+	dbMessage := "OK"
+	if err := h.app.DB.Ping(); err != nil {
+		dbHealthy = false
+		dbMessage = err.Error()
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(status)
-}
-
-// --- Audit endpoints ---
+	activeSessionCount, _ := h.app.DB.CountActiveSessions()
+	apps, _ := h.app.DB.ListApps()
+	users, _ := h.app.DB.ListUsers()
 
-func (h *handlers) handleAuditLogs(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+	pluginStatuses := plugins.Global().HealthCheck(r.Context())
+	allHealthy := dbHealthy
+	for _, ps := range pluginStatuses {
+		if !ps.Healthy {
+			allHealthy = false
+		}
 	}
 
-	filter, err := parseAuditFilter(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	health := map[string]any{
+		"status": "healthy",
+		"components": map[string]any{
+			"database": map[string]any{
+				"healthy": dbHealthy,
+				"message": dbMessage,
+			},
+			"plugins": pluginStatuses,
+		},
+		"stats": map[string]any{
+			"active_sessions": activeSessionCount,
+			"total_apps":      len(apps),
+			"total_users":     len(users),
+			"goroutines":      runtime.NumGoroutine(),
+			"memory_alloc_mb": float64(memStats.Alloc) / 1024 / 1024,
+		},
 	}
 
-	page, err := h.app.DB.QueryAuditLogs(filter)
-	if err != nil {
-		slog.Error("error querying audit logs", "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+	if !allHealthy {
+		health["status"] = "degraded"
 	}
-
-	if page.Logs == nil {
-		page.Logs = []db.AuditLog{}
+	if h.app.UpdateChecker != nil {
+		health["update"] = h.app.UpdateChecker.Status()
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(page)
+	json.NewEncoder(w).Encode(health)
 }
 
-func (h *handlers) handleAuditExport(w http.ResponseWriter, r *http.Request) {
+// healthHistoryWindow is the uptime window when the request gives no
+// start time.
+const healthHistoryWindow = 7 * 24 * time.Hour
+
+// handleAdminHealthHistory returns recorded health state transitions, newest
+// first, with each component's current state and its uptime over the
+// requested window (default the last seven days).
+func (h *handlers) handleAdminHealthHistory(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	filter, err := parseAuditFilter(r)
+	// Same query parameters as the audit log
+	af, err := parseAuditFilter(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if filter.Limit <= 0 || filter.Limit > 10000 {
-		filter.Limit = 10000
+	filter := db.HealthEventFilter{
+		Component: r.URL.Query().Get("component"),
+		From:      af.From,
+		To:        af.To,
+		Limit:     af.Limit,
+		Offset:    af.Offset,
 	}
-	filter.Offset = 0
 
-	page, err := h.app.DB.QueryAuditLogs(filter)
+	page, err := h.app.DB.QueryHealthEvents(filter)
 	if err != nil {
-		slog.Error("error exporting audit logs", "error", err)
+		slog.Error("error querying health events", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	if page.Logs == nil {
-		page.Logs = []db.AuditLog{}
+	now := time.Now()
+	to := filter.To
+	if to.IsZero() || to.After(now) {
+		to = now
 	}
-
-	format := r.URL.Query().Get("format")
-	if format == "csv" {
-		w.Header().Set("Content-Type", "text/csv")
-		w.Header().Set("Content-Disposition", "attachment; filename=audit_log.csv")
-		writeAuditCSV(w, page.Logs)
-		return
+	from := filter.From
+	if from.IsZero() {
+		from = to.Add(-healthHistoryWindow)
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Content-Disposition", "attachment; filename=audit_log.json")
-	json.NewEncoder(w).Encode(page.Logs)
-}
-
-func (h *handlers) handleAuditFilters(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	before, err := h.app.DB.LatestHealthEvents(from)
+	if err != nil {
+		slog.Error("error querying health events", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
-
-	actions, err := h.app.DB.GetAuditLogActions()
+	events, err := h.app.DB.ListHealthEventsBetween(from, to)
 	if err != nil {
-		slog.Error("error getting audit actions", "error", err)
+		slog.Error("error querying health events", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
-
-	users, err := h.app.DB.GetAuditLogUsers()
+	current, err := h.app.DB.LatestHealthEvents(now.Add(time.Second))
 	if err != nil {
-		slog.Error("error getting audit users", "error", err)
+		slog.Error("error querying health events", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	if actions == nil {
-		actions = []string{}
+	uptime := healthwatch.ComputeUptime(before, events, from, to)
+	states := make(map[string]string, len(current))
+	for component, e := range current {
+		states[component] = e.ToState
 	}
-	if users == nil {
-		users = []string{}
+	if filter.Component != "" {
+		for component := range uptime {
+			if component != filter.Component {
+				delete(uptime, component)
+			}
+		}
+		for component := range states {
+			if component != filter.Component {
+				delete(states, component)
+			}
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string][]string{
-		"actions": actions,
-		"users":   users,
+	json.NewEncoder(w).Encode(map[string]any{
+		"events":  page.Events,
+		"total":   page.Total,
+		"current": states,
+		"uptime":  uptime,
+		"from":    from,
+		"to":      to,
 	})
 }
 
-func parseAuditFilter(r *http.Request) (db.AuditLogFilter, error) {
-	q := r.URL.Query()
-	filter := db.AuditLogFilter{
-		User:   q.Get("user"),
-		Action: q.Get("action"),
-	}
-
-	if from := q.Get("from"); from != "" {
-		t, err := time.Parse(time.RFC3339, from)
-		if err != nil {
-			return filter, fmt.Errorf("invalid 'from' date: %w", err)
-		}
-		filter.From = t
-	}
-	if to := q.Get("to"); to != "" {
-		t, err := time.Parse(time.RFC3339, to)
-		if err != nil {
-			return filter, fmt.Errorf("invalid 'to' date: %w", err)
-		}
-		filter.To = t
-	}
-	if limitStr := q.Get("limit"); limitStr != "" {
-		limit, err := strconv.Atoi(limitStr)
-		if err != nil {
-			return filter, fmt.Errorf("invalid 'limit': %w", err)
-		}
-		filter.Limit = limit
-	}
-	if offsetStr := q.Get("offset"); offsetStr != "" {
-		offset, err := strconv.Atoi(offsetStr)
-		if err != nil {
-			return filter, fmt.Errorf("invalid 'offset': %w", err)
-		}
-		filter.Offset = offset
-	}
+// statusPageWindows are the periods the status page reports uptime over,
+// longest first.
+var statusPageWindows = []struct {
+	name string
+	d    time.Duration
+}{
+	{"90d", 90 * 24 * time.Hour},
+	{"30d", 30 * 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+	{"24h", 24 * time.Hour},
+}
 
-	return filter, nil
+// statusComponent is a component as shown on the public status page.
+type statusComponent struct {
+	Name   string             `json:"name"`
+	Status string             `json:"status"`
+	Uptime map[string]float64 `json:"uptime,omitempty"`
 }
 
-func writeAuditCSV(w io.Writer, logs []db.AuditLog) {
-	fmt.Fprintf(w, "ID,Timestamp,User,Action,Details\n")
-	for _, log := range logs {
-		details := strings.ReplaceAll(log.Details, "\"", "\"\"")
-		fmt.Fprintf(w, "%d,%s,%s,%s,\"%s\"\n",
-			log.ID,
-			log.Timestamp.Format(time.RFC3339),
-			log.User,
-			log.Action,
-			details,
-		)
-	}
+// statusAnnouncement is an announcement as shown on the public status page,
+// without its author.
+type statusAnnouncement struct {
+	ID       string              `json:"id"`
+	Kind     db.AnnouncementKind `json:"kind"`
+	Title    string              `json:"title"`
+	Message  string              `json:"message,omitempty"`
+	StartsAt time.Time           `json:"starts_at"`
+	EndsAt   *time.Time          `json:"ends_at,omitempty"`
 }
 
-// --- Analytics endpoints ---
+// settingEnabled reports whether a boolean setting is on. Unset settings
+// are off.
+func (h *handlers) settingEnabled(key string) bool {
+	v, err := h.app.DB.GetSetting(key)
+	return err == nil && (strings.EqualFold(v, "true") || v == "1")
+}
 
-func (h *handlers) handleAnalyticsLaunch(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
+// handleStatus serves the public status page summary: the overall status,
+// the components admins chose to disclose with their state and, if
+// enabled, uptime, and open incident and maintenance announcements.
+// Failure reasons are never included. The endpoint is off unless the
+// status_page_enabled setting is true.
+func (h *handlers) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-
-	var req struct {
-		AppID string `json:"app_id"`
-	}
-
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, "Failed to read request body", http.StatusBadRequest)
-		return
-	}
-
-	if err := json.Unmarshal(body, &req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
-		return
-	}
-
-	if req.AppID == "" {
-		http.Error(w, "Missing required field: app_id", http.StatusBadRequest)
+	if !h.settingEnabled("status_page_enabled") {
+		http.Error(w, "Not found", http.StatusNotFound)
 		return
 	}
 
-	if err := h.app.DB.RecordLaunch(req.AppID); err != nil {
-		slog.Error("error recording launch", "error", err)
+	now := time.Now()
+	current, err := h.app.DB.LatestHealthEvents(now.Add(time.Second))
+	if err != nil {
+		slog.Error("error querying health events", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"status": "recorded"})
-}
-
-func (h *handlers) handleAnalyticsStats(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+	// status_page_components is a comma-separated list of components, or
+	// "*" for every component with recorded health
+	var names []string
+	setting, _ := h.app.DB.GetSetting("status_page_components")
+	if strings.TrimSpace(setting) == "*" {
+		names = append(names, healthwatch.ComponentDatabase)
+		for component := range current {
+			if component != healthwatch.ComponentDatabase {
+				names = append(names, component)
+			}
+		}
+		slices.Sort(names[1:])
+	} else {
+		for _, name := range strings.Split(setting, ",") {
+			if name = strings.TrimSpace(name); name != "" && !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
 	}
 
-	stats, err := h.app.DB.GetAnalyticsStats()
-	if err != nil {
-		slog.Error("error getting analytics stats", "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+	overall := "operational"
+	components := make([]statusComponent, 0, len(names))
+	for _, name := range names {
+		// Components are healthy until a transition is recorded
+		c := statusComponent{Name: name, Status: healthwatch.StateHealthy}
+		if e, ok := current[name]; ok {
+			c.Status = e.ToState
+		}
+		if c.Status == healthwatch.StateUnhealthy {
+			overall = "degraded"
+		}
+		components = append(components, c)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(stats)
-}
-
-// --- Admin endpoints ---
-
-func (h *handlers) handleAdminSettings(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		settings, err := h.app.DB.GetAllSettings()
+	if len(components) > 0 && h.settingEnabled("status_page_uptime") {
+		from := now.Add(-statusPageWindows[0].d)
+		before, err := h.app.DB.LatestHealthEvents(from)
 		if err != nil {
-			slog.Error("error getting settings", "error", err)
+			slog.Error("error querying health events", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
-
-		response := map[string]interface{}{
-			"allow_registration":     h.isRegistrationAllowed(),
-			"max_sessions_per_user":  h.app.Config.MaxSessionsPerUser,
-			"max_global_sessions":    h.app.Config.MaxGlobalSessions,
-			"default_cpu_request":    h.app.Config.DefaultCPURequest,
-			"default_cpu_limit":      h.app.Config.DefaultCPULimit,
-			"default_memory_request": h.app.Config.DefaultMemRequest,
-			"default_memory_limit":   h.app.Config.DefaultMemLimit,
-		}
-
-		for k, v := range settings {
-			response[k] = v
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
-
-	case http.MethodPut:
-		var req map[string]string
-		body, err := io.ReadAll(r.Body)
+		events, err := h.app.DB.ListHealthEventsBetween(from, now)
 		if err != nil {
-			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			slog.Error("error querying health events", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
-
-		if err := json.Unmarshal(body, &req); err != nil {
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
-			return
+		for i := range components {
+			components[i].Uptime = make(map[string]float64, len(statusPageWindows))
 		}
-
-		for key, value := range req {
-			if err := h.app.DB.SetSetting(key, value); err != nil {
-				slog.Error("error updating setting", "key", key, "error", err)
-				http.Error(w, "Failed to update settings", http.StatusInternalServerError)
-				return
+		for _, window := range statusPageWindows {
+			from := now.Add(-window.d)
+			before, events = healthwatch.Advance(before, events, from)
+			uptime := healthwatch.ComputeUptime(before, events, from, now)
+			for i := range components {
+				percent := 100.0
+				if u, ok := uptime[components[i].Name]; ok {
+					percent = u.Percent
+				}
+				components[i].Uptime[window.name] = percent
 			}
 		}
-
-		user := middleware.GetUserFromContext(r.Context())
-		h.app.DB.LogAudit(user.Username, "UPDATE_SETTINGS", fmt.Sprintf("Updated settings: %v", req))
-
-		w.WriteHeader(http.StatusNoContent)
-
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
-}
 
-func (h *handlers) handleAdminSessions(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	open, err := h.app.DB.ListOpenStatusAnnouncements(now)
+	if err != nil {
+		slog.Error("error listing status announcements", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
-
-	sessionList, err := h.app.SessionManager.ListSessions(r.Context())
+	active := []statusAnnouncement{}
+	upcoming := []statusAnnouncement{}
+	for _, a := range open {
+		view := statusAnnouncement{
+			ID:       a.ID,
+			Kind:     a.Kind,
+			Title:    a.Title,
+			Message:  a.Message,
+			StartsAt: a.StartsAt,
+			EndsAt:   a.EndsAt,
+		}
+		if !a.ActiveAt(now) {
+			upcoming = append(upcoming, view)
+			continue
+		}
+		active = append(active, view)
+		switch {
+		case a.Kind == db.AnnouncementIncident:
+			overall = "degraded"
+		case a.Kind == db.AnnouncementMaintenance && overall == "operational":
+			overall = "maintenance"
+		}
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"status":        overall,
+		"components":    components,
+		"announcements": active,
+		"upcoming":      upcoming,
+	})
 	if err != nil {
-		slog.Error("error listing sessions", "error", err)
+		slog.Error("error encoding status", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	if sessionList == nil {
-		sessionList = []db.Session{}
-	}
-
-	recPolicy := h.getRecordingPolicy()
-	responses := make([]sessions.SessionResponse, len(sessionList))
-	for i, s := range sessionList {
-		app, _ := h.app.DB.GetApp(s.AppID)
-		appName := ""
-		wsURL := ""
-		guacURL := ""
-		proxyURL := ""
-		if app != nil {
-			appName = app.Name
-			if app.LaunchType == db.LaunchTypeContainer || app.LaunchType == db.LaunchTypeWebProxy {
-				if app.OsType == "windows" {
-					guacURL = h.app.SessionManager.GetSessionGuacWebSocketURL(&s)
-				} else {
-					wsURL = h.app.SessionManager.GetSessionWebSocketURL(&s)
-				}
-			}
-		}
-		responses[i] = *sessions.SessionFromDB(&s, appName, wsURL, guacURL, proxyURL, recPolicy)
+	// Status pages are polled from anywhere; let browsers and caches share
+	// a response for a short while
+	sum := sha256.Sum256(body)
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Cache-Control", "public, max-age=30")
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
 	}
-
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(responses)
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(body)
 }
 
-func (h *handlers) handleAdminUsers(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		users, err := h.app.DB.ListUsers()
-		if err != nil {
-			slog.Error("error listing users", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-
-		if users == nil {
-			users = []db.User{}
-		}
-
-		type userResponse struct {
-			ID          string    `json:"id"`
-			Username    string    `json:"username"`
-			Email       string    `json:"email,omitempty"`
-			DisplayName string    `json:"display_name,omitempty"`
-			Roles       []string  `json:"roles"`
-			CreatedAt   time.Time `json:"created_at"`
-		}
-
-		response := make([]userResponse, len(users))
-		for i, u := range users {
-			response[i] = userResponse{
-				ID:          u.ID,
-				Username:    u.Username,
-				Email:       u.Email,
-				DisplayName: u.DisplayName,
-				Roles:       u.Roles,
-				CreatedAt:   u.CreatedAt,
-			}
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+// statusAnnouncementRequest is the body of announcement create and update
+// requests. StartsAt defaults to now.
+type statusAnnouncementRequest struct {
+	Kind     db.AnnouncementKind `json:"kind"`
+	Title    string              `json:"title"`
+	Message  string              `json:"message"`
+	StartsAt *time.Time          `json:"starts_at"`
+	EndsAt   *time.Time          `json:"ends_at"`
+}
 
-	case http.MethodPost:
-		var req struct {
-			Username    string   `json:"username"`
-			Password    string   `json:"password"`
-			Email       string   `json:"email"`
-			DisplayName string   `json:"display_name"`
-			Roles       []string `json:"roles"`
-		}
+func (req *statusAnnouncementRequest) validate() error {
+	if !req.Kind.Valid() {
+		return errors.New("kind must be \"incident\" or \"maintenance\"")
+	}
+	if strings.TrimSpace(req.Title) == "" {
+		return errors.New("Missing required field: title")
+	}
+	if req.StartsAt == nil {
+		now := time.Now()
+		req.StartsAt = &now
+	}
+	if req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+	return nil
+}
 
-		body, err := io.ReadAll(r.Body)
+// handleAdminAnnouncements lists and creates status page announcements.
+func (h *handlers) handleAdminAnnouncements(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		announcements, err := h.app.DB.ListStatusAnnouncements(0)
 		if err != nil {
-			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			slog.Error("error listing status announcements", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(announcements)
 
-		if err := json.Unmarshal(body, &req); err != nil {
+	case http.MethodPost:
+		var req statusAnnouncementRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "Invalid JSON", http.StatusBadRequest)
 			return
 		}
-
-		if req.Username == "" || req.Password == "" {
-			http.Error(w, "Username and password are required", http.StatusBadRequest)
+		if err := req.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		existing, err := h.app.DB.GetUserByUsername(req.Username)
-		if err != nil {
-			slog.Error("error checking username", "error", err)
+		user := middleware.GetUserFromContext(r.Context())
+		now := time.Now()
+		a := db.StatusAnnouncement{
+			ID:        uuid.New().String(),
+			Kind:      req.Kind,
+			Title:     strings.TrimSpace(req.Title),
+			Message:   req.Message,
+			StartsAt:  *req.StartsAt,
+			EndsAt:    req.EndsAt,
+			CreatedBy: user.Username,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := h.app.DB.CreateStatusAnnouncement(a); err != nil {
+			slog.Error("error creating status announcement", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
-		if existing != nil {
-			http.Error(w, "Username already exists", http.StatusConflict)
+		h.logAudit(r, user.Username, "CREATE_STATUS_ANNOUNCEMENT", fmt.Sprintf("Created %s announcement %s (%s)", a.Kind, a.Title, a.ID))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(a)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminAnnouncementByID gets, updates and deletes a status page
+// announcement. Setting ends_at resolves an incident or ends maintenance.
+func (h *handlers) handleAdminAnnouncementByID(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/admin/announcements/")
+	if id == "" || strings.Contains(id, "/") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	a, err := h.app.DB.GetStatusAnnouncement(id)
+	if err != nil {
+		slog.Error("error getting status announcement", "id", id, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if a == nil {
+		http.Error(w, "Announcement not found", http.StatusNotFound)
+		return
+	}
+	user := middleware.GetUserFromContext(r.Context())
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(a)
+
+	case http.MethodPut:
+		req := statusAnnouncementRequest{StartsAt: &a.StartsAt}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
 			return
 		}
-
-		passwordHash, err := auth.HashPassword(req.Password)
-		if err != nil {
-			slog.Error("error hashing password", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		if err := req.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		roles := req.Roles
-		if len(roles) == 0 {
-			roles = []string{"user"}
+		a.Kind = req.Kind
+		a.Title = strings.TrimSpace(req.Title)
+		a.Message = req.Message
+		a.StartsAt = *req.StartsAt
+		a.EndsAt = req.EndsAt
+		if err := h.app.DB.UpdateStatusAnnouncement(*a); err != nil {
+			slog.Error("error updating status announcement", "id", id, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
 		}
+		h.logAudit(r, user.Username, "UPDATE_STATUS_ANNOUNCEMENT", fmt.Sprintf("Updated %s announcement %s (%s)", a.Kind, a.Title, a.ID))
 
-		user := db.User{
-			ID:           fmt.Sprintf("user-%s-%d", req.Username, time.Now().UnixNano()),
-			Username:     req.Username,
-			Email:        req.Email,
-			DisplayName:  req.DisplayName,
-			PasswordHash: passwordHash,
-			Roles:        roles,
-		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(a)
 
-		if err := h.app.DB.CreateUser(user); err != nil {
-			slog.Error("error creating user", "error", err)
-			http.Error(w, "Failed to create user", http.StatusInternalServerError)
+	case http.MethodDelete:
+		if err := h.app.DB.DeleteStatusAnnouncement(id); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			slog.Error("error deleting status announcement", "id", id, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
-
-		adminUser := middleware.GetUserFromContext(r.Context())
-		h.app.DB.LogAudit(adminUser.Username, "CREATE_USER", fmt.Sprintf("Created user: %s", req.Username))
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(map[string]string{
-			"id":       user.ID,
-			"username": user.Username,
-			"message":  "User created successfully",
-		})
+		h.logAudit(r, user.Username, "DELETE_STATUS_ANNOUNCEMENT", fmt.Sprintf("Deleted %s announcement %s (%s)", a.Kind, a.Title, a.ID))
+		w.WriteHeader(http.StatusNoContent)
 
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
-func (h *handlers) handleAdminUserByID(w http.ResponseWriter, r *http.Request) {
-	id := strings.TrimPrefix(r.URL.Path, "/api/admin/users/")
-	if id == "" {
-		http.Error(w, "User ID required", http.StatusBadRequest)
+// handleAdminJobs lists background jobs with their latest runs.
+func (h *handlers) handleAdminJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	switch r.Method {
-	case http.MethodDelete:
-		currentUser := middleware.GetUserFromContext(r.Context())
-		if currentUser != nil && currentUser.ID == id {
-			http.Error(w, "Cannot delete your own account", http.StatusBadRequest)
+	statuses := []jobs.Status{}
+	if h.app.Jobs != nil {
+		var err error
+		if statuses, err = h.app.Jobs.Status(); err != nil {
+			slog.Error("error getting job status", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
+	}
 
-		user, err := h.app.DB.GetUserByID(id)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"jobs": statuses})
+}
+
+// handleAdminJobByName serves a job's run history (GET
+// /api/admin/jobs/{name}/runs) and starts a run by hand (POST
+// /api/admin/jobs/{name}/run).
+func (h *handlers) handleAdminJobByName(w http.ResponseWriter, r *http.Request) {
+	name, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/admin/jobs/"), "/")
+	if h.app.Jobs == nil || !h.app.Jobs.Has(name) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	switch action {
+	case "runs":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		af, err := parseAuditFilter(r)
 		if err != nil {
-			slog.Error("error getting user", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		if user == nil {
-			http.Error(w, "User not found", http.StatusNotFound)
+		page, err := h.app.DB.QueryJobRuns(name, af.Limit, af.Offset)
+		if err != nil {
+			slog.Error("error querying job runs", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(page)
 
-		if err := h.app.DB.DeleteUser(id); err != nil {
-			slog.Error("error deleting user", "error", err)
+	case "run":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		user := middleware.GetUserFromContext(r.Context())
+		run, err := h.app.Jobs.Trigger(name, user.Username)
+		if errors.Is(err, jobs.ErrJobActive) {
+			http.Error(w, "Job is already queued or running", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			slog.Error("error triggering job", "job", name, "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
+		h.logAudit(r, user.Username, "RUN_JOB", fmt.Sprintf("Started job %s", name))
 
-		h.app.DB.LogAudit(currentUser.Username, "DELETE_USER", fmt.Sprintf("Deleted user: %s (%s)", user.Username, id))
-
-		w.WriteHeader(http.StatusNoContent)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(run)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		http.Error(w, "Not found", http.StatusNotFound)
 	}
 }
 
-// --- Template endpoints ---
-
-func (h *handlers) handleTemplates(w http.ResponseWriter, r *http.Request) {
+func (h *handlers) handleSupportInfo(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	templates, err := h.app.DB.ListTemplates()
-	if err != nil {
-		slog.Error("error listing templates", "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+	info := map[string]any{
+		"application": "sortie",
+		"version":     version.Get(),
+		"go_version":  runtime.Version(),
+		"os":          runtime.GOOS,
+		"arch":        runtime.GOARCH,
+		"config": map[string]any{
+			"auth_enabled":          h.app.Config.JWTSecret != "",
+			"oidc_enabled":          h.app.Config.OIDCEnabled(),
+			"namespace":             h.app.Config.Namespace,
+			"max_sessions_per_user": h.app.Config.MaxSessionsPerUser,
+			"max_global_sessions":   h.app.Config.MaxGlobalSessions,
+			"recording_enabled":     h.app.Config.RecordingEnabled,
+		},
 	}
 
-	if templates == nil {
-		templates = []db.Template{}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// --- Outbound request policy ---
+
+// outboundGuard returns the policy for requests to admin-supplied URLs.
+func (h *handlers) outboundGuard() *ssrf.Guard {
+	if h.app.Outbound != nil {
+		return h.app.Outbound
 	}
+	return ssrf.NewGuard(ssrf.Allowlist{})
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(templates)
+// tenantOutboundAllowlist returns the outbound allowlist of a tenant, or
+// nil if the tenant does not exist.
+func (h *handlers) tenantOutboundAllowlist(tenantID string) (*ssrf.Allowlist, error) {
+	tenant, err := h.app.DB.GetTenant(tenantID)
+	if err != nil || tenant == nil {
+		return nil, err
+	}
+	allow, err := ssrf.ParseAllowlist(tenant.Settings.OutboundAllowlist)
+	if err != nil {
+		return nil, err
+	}
+	return &allow, nil
 }
 
-func (h *handlers) handleTemplateByID(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+// handleAdminOutboundCheck reports whether the server may make a request
+// to a URL, optionally on behalf of a tenant, and which addresses it would
+// connect to. No request is made.
+func (h *handlers) handleAdminOutboundCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	templateID := strings.TrimPrefix(r.URL.Path, "/api/templates/")
-	if templateID == "" {
-		http.Error(w, "Template ID required", http.StatusBadRequest)
-		return
+	var req struct {
+		URL      string `json:"url"`
+		TenantID string `json:"tenant_id"`
 	}
-
-	template, err := h.app.DB.GetTemplate(templateID)
-	if err != nil {
-		slog.Error("error getting template", "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
-	if template == nil {
-		http.Error(w, "Template not found", http.StatusNotFound)
+	if req.URL == "" {
+		http.Error(w, "url is required", http.StatusBadRequest)
 		return
 	}
 
+	var only ssrf.Allowlist
+	if req.TenantID != "" {
+		allow, err := h.tenantOutboundAllowlist(req.TenantID)
+		if err != nil {
+			slog.Error("error getting tenant outbound allowlist", "tenant_id", req.TenantID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if allow == nil {
+			http.Error(w, "Tenant not found", http.StatusNotFound)
+			return
+		}
+		only = *allow
+	}
+
+	result := struct {
+		Allowed   bool     `json:"allowed"`
+		Addresses []string `json:"addresses,omitempty"`
+		Reason    string   `json:"reason,omitempty"`
+	}{}
+	addrs, err := h.outboundGuard().Check(r.Context(), req.URL, only)
+	if err != nil {
+		result.Reason = err.Error()
+	} else {
+		result.Allowed = true
+		for _, addr := range addrs {
+			result.Addresses = append(result.Addresses, addr.String())
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(template)
+	json.NewEncoder(w).Encode(result)
 }
 
-func (h *handlers) handleAdminTemplates(w http.ResponseWriter, r *http.Request) {
+// --- Tenant admin endpoints ---
+
+func (h *handlers) handleAdminTenants(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		templates, err := h.app.DB.ListTemplates()
+		tenants, err := h.app.DB.ListTenants()
 		if err != nil {
-			slog.Error("error listing templates", "error", err)
+			slog.Error("error listing tenants", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
-
-		if templates == nil {
-			templates = []db.Template{}
+		if tenants == nil {
+			tenants = []db.Tenant{}
 		}
-
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(templates)
+		json.NewEncoder(w).Encode(tenants)
 
 	case http.MethodPost:
-		var template db.Template
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		var req struct {
+			Name     string            `json:"name"`
+			Slug     string            `json:"slug"`
+			Settings db.TenantSettings `json:"settings"`
+			Quotas   db.TenantQuotas   `json:"quotas"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
 
-		if err := json.Unmarshal(body, &template); err != nil {
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		if req.Name == "" || req.Slug == "" {
+			http.Error(w, "name and slug are required", http.StatusBadRequest)
 			return
 		}
 
-		if template.TemplateID == "" {
-			http.Error(w, "Missing required field: template_id", http.StatusBadRequest)
+		if req.Settings.TokenPolicy != nil {
+			if err := req.Settings.TokenPolicy.Validate(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if req.Settings.MFAPolicy != nil {
+			if err := req.Settings.MFAPolicy.Validate(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if !req.Settings.SpectatePolicy.Valid() {
+			http.Error(w, "spectate_policy must be silent, notify, or require_consent", http.StatusBadRequest)
+			return
+		}
+		if req.Settings.UploadPolicy != nil {
+			if err := req.Settings.UploadPolicy.Validate(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if _, err := ssrf.ParseAllowlist(req.Settings.OutboundAllowlist); err != nil {
+			http.Error(w, "outbound_allowlist: "+err.Error(), http.StatusBadRequest)
 			return
 		}
-		if template.Name == "" {
-			http.Error(w, "Missing required field: name", http.StatusBadRequest)
+		if req.Settings.Defaults != nil {
+			if err := req.Settings.Defaults.Validate(); err != nil {
+				http.Error(w, "defaults: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if req.Settings.Storage != nil {
+			if err := req.Settings.Storage.Validate(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if err := k8s.ValidateImagePullSecret(req.Settings.ImagePullSecret); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		if template.TemplateCategory == "" {
-			http.Error(w, "Missing required field: template_category", http.StatusBadRequest)
+
+		tenantID := tenantUUID()
+		if code, err := h.checkSidecarRefs(req.Settings.Sidecars, tenantID); err != nil {
+			writeSidecarError(w, code, err)
 			return
 		}
-		if template.Category == "" {
-			http.Error(w, "Missing required field: category", http.StatusBadRequest)
+
+		// With the two-person rule on, a new tenant starts with the default
+		// quotas and egress policy, and the requested ones are staged for
+		// another admin to approve
+		approval, err := h.settingsApprovalOn()
+		if err != nil {
+			slog.Error("error checking settings approval", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
-
-		if template.TemplateVersion == "" {
-			template.TemplateVersion = "1.0.0"
+		var staged *db.TenantChange
+		egress := req.Settings.DefaultEgressPolicy()
+		if approval && (req.Quotas != (db.TenantQuotas{}) || egress != nil) {
+			staged = &db.TenantChange{TenantID: tenantID, Quotas: req.Quotas, EgressPolicy: egress}
+			req.Quotas = db.TenantQuotas{}
+			req.Settings.SetDefaultEgressPolicy(nil)
 		}
-		if template.LaunchType == "" {
-			template.LaunchType = "container"
+
+		tenant := db.Tenant{
+			ID:        tenantID,
+			Name:      req.Name,
+			Slug:      req.Slug,
+			Settings:  req.Settings,
+			Quotas:    req.Quotas,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
 		}
 
-		if err := h.app.DB.CreateTemplate(template); err != nil {
-			if db.IsDuplicateKeyError(err) {
-				http.Error(w, "Template with this ID already exists", http.StatusConflict)
-				return
+		err = h.app.DB.WithTx(func(tx *db.DB) error {
+			existing, err := tx.GetTenantBySlug(req.Slug)
+			if err != nil {
+				return err
 			}
-			slog.Error("error creating template", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			if existing != nil {
+				return &httpError{http.StatusConflict, "Tenant slug already exists"}
+			}
+			return tx.CreateTenant(tenant)
+		})
+		if err != nil {
+			writeTxError(w, r, err, "creating tenant")
 			return
 		}
 
-		user := middleware.GetUserFromContext(r.Context())
-		h.app.DB.LogAudit(user.Username, "CREATE_TEMPLATE", fmt.Sprintf("Created template: %s (%s)", template.Name, template.TemplateID))
+		h.logAudit(r, "admin", "CREATE_TENANT", "Created tenant: "+tenant.Name)
+
+		if staged == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(tenant)
+			return
+		}
+		change, err := h.requestSettingChange(r, db.SettingChangeRequest{Tenant: staged})
+		if err != nil {
+			slog.Error("error creating setting change request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
 
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(template)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(change)
 
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
-func (h *handlers) handleAdminTemplateByID(w http.ResponseWriter, r *http.Request) {
-	templateID := strings.TrimPrefix(r.URL.Path, "/api/admin/templates/")
-	if templateID == "" {
-		http.Error(w, "Template ID required", http.StatusBadRequest)
+func (h *handlers) handleAdminTenantByID(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/admin/tenants/"), "/")
+	tenantID := parts[0]
+	if tenantID == "" {
+		http.Error(w, "Tenant ID required", http.StatusBadRequest)
+		return
+	}
+	if len(parts) > 1 {
+		if parts[1] == "branding" {
+			h.handleAdminTenantBranding(w, r, tenantID, parts[2:])
+			return
+		}
+		http.Error(w, "Not found", http.StatusNotFound)
 		return
 	}
 
 	switch r.Method {
 	case http.MethodGet:
-		template, err := h.app.DB.GetTemplate(templateID)
+		tenant, err := h.app.DB.GetTenant(tenantID)
 		if err != nil {
-			slog.Error("error getting template", "error", err)
+			slog.Error("error getting tenant", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
-		if template == nil {
-			http.Error(w, "Template not found", http.StatusNotFound)
+		if tenant == nil {
+			http.Error(w, "Tenant not found", http.StatusNotFound)
 			return
 		}
-
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(template)
+		json.NewEncoder(w).Encode(tenant)
 
 	case http.MethodPut:
-		var template db.Template
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		var req struct {
+			Name     string            `json:"name"`
+			Slug     string            `json:"slug"`
+			Settings db.TenantSettings `json:"settings"`
+			Quotas   db.TenantQuotas   `json:"quotas"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
 
-		if err := json.Unmarshal(body, &template); err != nil {
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		if req.Settings.TokenPolicy != nil {
+			if err := req.Settings.TokenPolicy.Validate(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if req.Settings.MFAPolicy != nil {
+			if err := req.Settings.MFAPolicy.Validate(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if !req.Settings.SpectatePolicy.Valid() {
+			http.Error(w, "spectate_policy must be silent, notify, or require_consent", http.StatusBadRequest)
 			return
 		}
-
-		template.TemplateID = templateID
-
-		if template.Name == "" {
-			http.Error(w, "Missing required field: name", http.StatusBadRequest)
+		if req.Settings.UploadPolicy != nil {
+			if err := req.Settings.UploadPolicy.Validate(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if _, err := ssrf.ParseAllowlist(req.Settings.OutboundAllowlist); err != nil {
+			http.Error(w, "outbound_allowlist: "+err.Error(), http.StatusBadRequest)
 			return
 		}
-
-		if err := h.app.DB.UpdateTemplate(template); err != nil {
-			if err.Error() == "sql: no rows in result set" {
-				http.Error(w, "Template not found", http.StatusNotFound)
+		if req.Settings.Defaults != nil {
+			if err := req.Settings.Defaults.Validate(); err != nil {
+				http.Error(w, "defaults: "+err.Error(), http.StatusBadRequest)
 				return
 			}
-			slog.Error("error updating template", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		if req.Settings.Storage != nil {
+			if err := req.Settings.Storage.Validate(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if err := k8s.ValidateImagePullSecret(req.Settings.ImagePullSecret); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		user := middleware.GetUserFromContext(r.Context())
-		h.app.DB.LogAudit(user.Username, "UPDATE_TEMPLATE", fmt.Sprintf("Updated template: %s (%s)", template.Name, templateID))
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(template)
-
-	case http.MethodDelete:
-		template, err := h.app.DB.GetTemplate(templateID)
+		tenant, err := h.app.DB.GetTenant(tenantID)
 		if err != nil {
-			slog.Error("error getting template", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
-		if template == nil {
-			http.Error(w, "Template not found", http.StatusNotFound)
+		if tenant == nil {
+			http.Error(w, "Tenant not found", http.StatusNotFound)
 			return
 		}
 
-		if err := h.app.DB.DeleteTemplate(templateID); err != nil {
-			slog.Error("error deleting template", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		if req.Name != "" {
+			tenant.Name = req.Name
+		}
+		if req.Slug != "" {
+			tenant.Slug = req.Slug
+		}
+		if code, err := h.checkSidecarRefs(req.Settings.Sidecars, tenant.ID); err != nil {
+			writeSidecarError(w, code, err)
 			return
 		}
 
-		user := middleware.GetUserFromContext(r.Context())
-		h.app.DB.LogAudit(user.Username, "DELETE_TEMPLATE", fmt.Sprintf("Deleted template: %s (%s)", template.Name, templateID))
+		// With the two-person rule on, quota and egress policy changes are
+		// staged for another admin to approve; the rest apply now
+		approval, err := h.settingsApprovalOn()
+		if err != nil {
+			slog.Error("error checking settings approval", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		var staged *db.TenantChange
+		egress := req.Settings.DefaultEgressPolicy()
+		if approval && (req.Quotas != tenant.Quotas || !reflect.DeepEqual(egress, tenant.Settings.DefaultEgressPolicy())) {
+			staged = &db.TenantChange{TenantID: tenant.ID, Quotas: req.Quotas, EgressPolicy: egress}
+			req.Quotas = tenant.Quotas
+			req.Settings.SetDefaultEgressPolicy(tenant.Settings.DefaultEgressPolicy())
+		}
+		tenant.Settings = req.Settings
+		tenant.Quotas = req.Quotas
 
-		w.WriteHeader(http.StatusNoContent)
+		if err := h.app.DB.UpdateTenant(*tenant); err != nil {
+			slog.Error("error updating tenant", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
 
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
-}
+		h.logAudit(r, "admin", "UPDATE_TENANT", "Updated tenant: "+tenant.Name)
 
-// --- Diagnostics / Health / Support ---
+		if staged == nil {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(tenant)
+			return
+		}
+		change, err := h.requestSettingChange(r, db.SettingChangeRequest{Tenant: staged})
+		if err != nil {
+			slog.Error("error creating setting change request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
 
-func (h *handlers) handleDiagnosticsBundle(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(change)
 
-	user := middleware.GetUserFromContext(r.Context())
-	h.app.DB.LogAudit(user.Username, "GENERATE_DIAGNOSTICS", "Generated diagnostics bundle")
+	case http.MethodDelete:
+		if tenantID == db.DefaultTenantID {
+			http.Error(w, "Cannot delete the default tenant", http.StatusForbidden)
+			return
+		}
 
-	if r.Header.Get("Accept") == "application/gzip" {
-		w.Header().Set("Content-Type", "application/gzip")
-		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=diagnostics-%s.tar.gz", time.Now().UTC().Format("20060102-150405")))
-		if err := h.app.DiagCollector.WriteTarGz(r.Context(), w); err != nil {
-			slog.Error("failed to generate diagnostics archive", "error", err)
+		if err := h.app.DB.DeleteTenant(tenantID); err != nil {
+			slog.Error("error deleting tenant", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
-		return
-	}
 
-	bundle, err := h.app.DiagCollector.Collect(r.Context())
-	if err != nil {
-		slog.Error("failed to collect diagnostics", "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
+		h.logAudit(r, "admin", "DELETE_TENANT", "Deleted tenant: "+tenantID)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(bundle)
-}
+		w.WriteHeader(http.StatusNoContent)
 
-func (h *handlers) handleAdminHealth(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	dbHealthy := true
-	dbMessage := "OK"
-	if err := h.app.DB.Ping(); err != nil {
-		dbHealthy = false
-		dbMessage = err.Error()
 	}
+}
 
-	activeSessionCount, _ := h.app.DB.CountActiveSessions()
-	apps, _ := h.app.DB.ListApps()
-	users, _ := h.app.DB.ListUsers()
+// --- Tenant branding ---
 
-	pluginStatuses := plugins.Global().HealthCheck(r.Context())
-	allHealthy := dbHealthy
-	for _, ps := range pluginStatuses {
-		if !ps.Healthy {
-			allHealthy = false
-		}
-	}
+// brandingAssetResponse is an uploaded asset with the URL that serves it.
+type brandingAssetResponse struct {
+	db.BrandingAsset
+	URL string `json:"url"`
+}
 
-	var memStats runtime.MemStats
-	runtime.ReadMemStats(&memStats)
+// tenantBrandingResponse is the admin view of a tenant's branding.
+type tenantBrandingResponse struct {
+	TenantID string                           `json:"tenant_id"`
+	Theme    db.BrandingTheme                 `json:"theme"`
+	Assets   map[string]brandingAssetResponse `json:"assets"`
+}
 
-	health := map[string]any{
-		"status": "healthy",
-		"components": map[string]any{
-			"database": map[string]any{
-				"healthy": dbHealthy,
-				"message": dbMessage,
-			},
-			"plugins": pluginStatuses,
-		},
-		"stats": map[string]any{
-			"active_sessions": activeSessionCount,
-			"total_apps":      len(apps),
-			"total_users":     len(users),
-			"goroutines":      runtime.NumGoroutine(),
-			"memory_alloc_mb": float64(memStats.Alloc) / 1024 / 1024,
-		},
+func newTenantBrandingResponse(tenantID string, b *db.TenantBranding) tenantBrandingResponse {
+	resp := tenantBrandingResponse{TenantID: tenantID, Assets: map[string]brandingAssetResponse{}}
+	if b == nil {
+		return resp
 	}
-
-	if !allHealthy {
-		health["status"] = "degraded"
+	resp.Theme = b.Theme
+	for kind, asset := range b.Assets {
+		resp.Assets[kind] = brandingAssetResponse{BrandingAsset: asset, URL: branding.AssetURL(tenantID, kind, asset)}
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(health)
+	return resp
 }
 
-func (h *handlers) handleSupportInfo(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	info := map[string]any{
-		"application": "sortie",
-		"go_version":  runtime.Version(),
-		"os":          runtime.GOOS,
-		"arch":        runtime.GOARCH,
-		"config": map[string]any{
-			"auth_enabled":          h.app.Config.JWTSecret != "",
-			"oidc_enabled":          h.app.Config.OIDCEnabled(),
-			"namespace":             h.app.Config.Namespace,
-			"max_sessions_per_user": h.app.Config.MaxSessionsPerUser,
-			"max_global_sessions":   h.app.Config.MaxGlobalSessions,
-			"recording_enabled":     h.app.Config.RecordingEnabled,
-		},
+// loadTenantBranding returns the tenant's branding, or an empty one if none
+// has been saved. It writes an error response and returns nil if the tenant
+// does not exist or the lookup fails.
+func (h *handlers) loadTenantBranding(w http.ResponseWriter, tenantID string) *db.TenantBranding {
+	tenant, err := h.app.DB.GetTenant(tenantID)
+	if err != nil {
+		slog.Error("error getting tenant", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(info)
+	if tenant == nil {
+		http.Error(w, "Tenant not found", http.StatusNotFound)
+		return nil
+	}
+	b, err := h.app.DB.GetTenantBranding(tenantID)
+	if err != nil {
+		slog.Error("error getting tenant branding", "tenant_id", tenantID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil
+	}
+	if b == nil {
+		b = &db.TenantBranding{TenantID: tenantID}
+	}
+	return b
 }
 
-// --- Tenant admin endpoints ---
+// handleAdminTenantBranding serves /api/admin/tenants/{id}/branding and
+// /api/admin/tenants/{id}/branding/assets/{kind}.
+func (h *handlers) handleAdminTenantBranding(w http.ResponseWriter, r *http.Request, tenantID string, rest []string) {
+	if len(rest) == 2 && rest[0] == "assets" {
+		h.handleAdminTenantBrandingAsset(w, r, tenantID, rest[1])
+		return
+	}
+	if len(rest) != 0 {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
 
-func (h *handlers) handleAdminTenants(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		tenants, err := h.app.DB.ListTenants()
-		if err != nil {
-			slog.Error("error listing tenants", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		b := h.loadTenantBranding(w, tenantID)
+		if b == nil {
 			return
 		}
-		if tenants == nil {
-			tenants = []db.Tenant{}
-		}
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(tenants)
+		json.NewEncoder(w).Encode(newTenantBrandingResponse(tenantID, b))
 
-	case http.MethodPost:
+	case http.MethodPut:
 		var req struct {
-			Name     string            `json:"name"`
-			Slug     string            `json:"slug"`
-			Settings db.TenantSettings `json:"settings"`
-			Quotas   db.TenantQuotas   `json:"quotas"`
+			Theme db.BrandingTheme `json:"theme"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
-
-		if req.Name == "" || req.Slug == "" {
-			http.Error(w, "name and slug are required", http.StatusBadRequest)
+		if err := req.Theme.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		existing, err := h.app.DB.GetTenantBySlug(req.Slug)
-		if err != nil {
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-		if existing != nil {
-			http.Error(w, "Tenant slug already exists", http.StatusConflict)
+		b := h.loadTenantBranding(w, tenantID)
+		if b == nil {
 			return
 		}
-
-		tenant := db.Tenant{
-			ID:        tenantUUID(),
-			Name:      req.Name,
-			Slug:      req.Slug,
-			Settings:  req.Settings,
-			Quotas:    req.Quotas,
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-		}
-
-		if err := h.app.DB.CreateTenant(tenant); err != nil {
-			slog.Error("error creating tenant", "error", err)
+		b.Theme = req.Theme
+		if err := h.app.DB.SaveTenantBranding(*b); err != nil {
+			slog.Error("error saving tenant branding", "tenant_id", tenantID, "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		h.app.DB.LogAudit("admin", "CREATE_TENANT", "Created tenant: "+tenant.Name)
+		currentUser := middleware.GetUserFromContext(r.Context())
+		h.logAudit(r, currentUser.Username, "UPDATE_TENANT_THEME", "Updated branding theme for tenant: "+tenantID)
 
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(tenant)
+		json.NewEncoder(w).Encode(newTenantBrandingResponse(tenantID, b))
 
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
-func (h *handlers) handleAdminTenantByID(w http.ResponseWriter, r *http.Request) {
-	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/admin/tenants/"), "/")
-	tenantID := parts[0]
-	if tenantID == "" {
-		http.Error(w, "Tenant ID required", http.StatusBadRequest)
+// handleAdminTenantBrandingAsset uploads (PUT, multipart field "file") or
+// removes (DELETE) a tenant's logo, favicon, or background image.
+func (h *handlers) handleAdminTenantBrandingAsset(w http.ResponseWriter, r *http.Request, tenantID, kind string) {
+	if !branding.ValidKind(kind) {
+		http.Error(w, "Asset kind must be logo, favicon, or background", http.StatusNotFound)
+		return
+	}
+	if h.app.BrandingStore == nil {
+		http.Error(w, "Branding storage is not configured", http.StatusServiceUnavailable)
 		return
 	}
 
 	switch r.Method {
-	case http.MethodGet:
-		tenant, err := h.app.DB.GetTenant(tenantID)
-		if err != nil {
-			slog.Error("error getting tenant", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+	case http.MethodPut:
+		// Allow room for the multipart framing around the file
+		r.Body = http.MaxBytesReader(w, r.Body, branding.MaxAssetSize+64<<10)
+		if err := r.ParseMultipartForm(branding.MaxAssetSize); err != nil {
+			if strings.Contains(err.Error(), "http: request body too large") {
+				http.Error(w, fmt.Sprintf("File too large (max %d bytes)", branding.MaxAssetSize), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Failed to parse upload", http.StatusBadRequest)
 			return
 		}
-		if tenant == nil {
-			http.Error(w, "Tenant not found", http.StatusNotFound)
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "Missing 'file' field in upload", http.StatusBadRequest)
 			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(tenant)
+		defer file.Close()
 
-	case http.MethodPut:
-		var req struct {
-			Name     string            `json:"name"`
-			Slug     string            `json:"slug"`
-			Settings db.TenantSettings `json:"settings"`
-			Quotas   db.TenantQuotas   `json:"quotas"`
+		data, err := io.ReadAll(io.LimitReader(file, branding.MaxAssetSize+1))
+		if err != nil {
+			http.Error(w, "Failed to read upload", http.StatusBadRequest)
+			return
 		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		if len(data) > branding.MaxAssetSize {
+			http.Error(w, fmt.Sprintf("File too large (max %d bytes)", branding.MaxAssetSize), http.StatusRequestEntityTooLarge)
 			return
 		}
-
-		tenant, err := h.app.DB.GetTenant(tenantID)
+		contentType, err := branding.DetectContentType(data)
 		if err != nil {
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
 			return
 		}
-		if tenant == nil {
-			http.Error(w, "Tenant not found", http.StatusNotFound)
+
+		b := h.loadTenantBranding(w, tenantID)
+		if b == nil {
 			return
 		}
 
-		if req.Name != "" {
-			tenant.Name = req.Name
+		now := time.Now()
+		asset := db.BrandingAsset{
+			Path:        branding.AssetPath(tenantID, kind, contentType, now),
+			ContentType: contentType,
+			Size:        int64(len(data)),
+			UpdatedAt:   now,
 		}
-		if req.Slug != "" {
-			tenant.Slug = req.Slug
+		if err := h.app.BrandingStore.Put(asset.Path, contentType, bytes.NewReader(data)); err != nil {
+			slog.Error("failed to store branding asset", "tenant_id", tenantID, "kind", kind, "error", err)
+			http.Error(w, "Failed to store asset", http.StatusInternalServerError)
+			return
 		}
-		tenant.Settings = req.Settings
-		tenant.Quotas = req.Quotas
 
-		if err := h.app.DB.UpdateTenant(*tenant); err != nil {
-			slog.Error("error updating tenant", "error", err)
+		previous := b.Assets[kind]
+		if b.Assets == nil {
+			b.Assets = map[string]db.BrandingAsset{}
+		}
+		b.Assets[kind] = asset
+		if err := h.app.DB.SaveTenantBranding(*b); err != nil {
+			slog.Error("error saving tenant branding", "tenant_id", tenantID, "error", err)
+			h.app.BrandingStore.Delete(asset.Path)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
+		if previous.Path != "" {
+			if err := h.app.BrandingStore.Delete(previous.Path); err != nil {
+				slog.Warn("failed to delete replaced branding asset", "path", previous.Path, "error", err)
+			}
+		}
 
-		h.app.DB.LogAudit("admin", "UPDATE_TENANT", "Updated tenant: "+tenant.Name)
+		currentUser := middleware.GetUserFromContext(r.Context())
+		h.logAudit(r, currentUser.Username, "UPLOAD_BRANDING_ASSET",
+			fmt.Sprintf("Uploaded %s for tenant %s (%s, %d bytes)", kind, tenantID, contentType, asset.Size))
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(tenant)
+		json.NewEncoder(w).Encode(brandingAssetResponse{BrandingAsset: asset, URL: branding.AssetURL(tenantID, kind, asset)})
 
 	case http.MethodDelete:
-		if tenantID == db.DefaultTenantID {
-			http.Error(w, "Cannot delete the default tenant", http.StatusForbidden)
+		b := h.loadTenantBranding(w, tenantID)
+		if b == nil {
 			return
 		}
-
-		if err := h.app.DB.DeleteTenant(tenantID); err != nil {
-			slog.Error("error deleting tenant", "error", err)
+		asset, ok := b.Assets[kind]
+		if !ok {
+			http.Error(w, "Asset not found", http.StatusNotFound)
+			return
+		}
+		delete(b.Assets, kind)
+		if err := h.app.DB.SaveTenantBranding(*b); err != nil {
+			slog.Error("error saving tenant branding", "tenant_id", tenantID, "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
+		if err := h.app.BrandingStore.Delete(asset.Path); err != nil {
+			slog.Warn("failed to delete branding asset", "path", asset.Path, "error", err)
+		}
 
-		h.app.DB.LogAudit("admin", "DELETE_TENANT", "Deleted tenant: "+tenantID)
+		currentUser := middleware.GetUserFromContext(r.Context())
+		h.logAudit(r, currentUser.Username, "DELETE_BRANDING_ASSET",
+			fmt.Sprintf("Deleted %s for tenant %s", kind, tenantID))
 
 		w.WriteHeader(http.StatusNoContent)
 
@@ -2586,6 +9053,280 @@ func (h *handlers) handleAdminTenantByID(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// handleBrandingAsset serves GET /api/branding/assets/{tenantID}/{kind}.
+// Assets are public so the login page can show them.
+func (h *handlers) handleBrandingAsset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/branding/assets/"), "/")
+	if len(parts) != 2 || parts[0] == "" || !branding.ValidKind(parts[1]) || h.app.BrandingStore == nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	tenantID, kind := parts[0], parts[1]
+
+	b, err := h.app.DB.GetTenantBranding(tenantID)
+	if err != nil {
+		slog.Error("error getting tenant branding", "tenant_id", tenantID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if b == nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	asset, ok := b.Assets[kind]
+	if !ok {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	rc, err := h.app.BrandingStore.Get(asset.Path)
+	if err != nil {
+		slog.Error("failed to read branding asset", "path", asset.Path, "error", err)
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", asset.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(asset.Size, 10))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// SVGs can carry scripts; never run them when opened directly
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	// URLs carry a version that changes on every upload
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	if r.Method == http.MethodHead {
+		return
+	}
+	io.Copy(w, rc)
+}
+
+// iconResponse is returned after an icon upload.
+type iconResponse struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+}
+
+// handleIconUpload stores an app or template icon (POST, multipart field
+// "file") and returns the URL to put in the icon field. Identical images
+// share one ID, so uploading the same icon twice is harmless.
+func (h *handlers) handleIconUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.app.BrandingStore == nil {
+		http.Error(w, "Icon storage is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Allow room for the multipart framing around the file
+	r.Body = http.MaxBytesReader(w, r.Body, icons.MaxSize+64<<10)
+	if err := r.ParseMultipartForm(icons.MaxSize); err != nil {
+		if strings.Contains(err.Error(), "http: request body too large") {
+			http.Error(w, fmt.Sprintf("File too large (max %d bytes)", icons.MaxSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Failed to parse upload", http.StatusBadRequest)
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Missing 'file' field in upload", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, icons.MaxSize+1))
+	if err != nil {
+		http.Error(w, "Failed to read upload", http.StatusBadRequest)
+		return
+	}
+	if len(data) > icons.MaxSize {
+		http.Error(w, fmt.Sprintf("File too large (max %d bytes)", icons.MaxSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	contentType, err := branding.DetectContentType(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	id, err := h.putIcon(data, contentType)
+	if err != nil {
+		slog.Error("failed to store icon", "id", id, "error", err)
+		http.Error(w, "Failed to store icon", http.StatusInternalServerError)
+		return
+	}
+
+	currentUser := middleware.GetUserFromContext(r.Context())
+	h.logAudit(r, currentUser.Username, "UPLOAD_ICON",
+		fmt.Sprintf("Uploaded icon %s (%s, %d bytes)", id, contentType, len(data)))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(iconResponse{ID: id, URL: icons.URL(id)})
+}
+
+// handleLintApp checks a draft app against the lint rules (POST, the app
+// as it would be saved) and returns the warnings, for the app form.
+// Nothing is saved, so the draft need not be valid.
+func (h *handlers) handleLintApp(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var app db.Application
+	if err := json.NewDecoder(r.Body).Decode(&app); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"warnings": lint.App(&app)})
+}
+
+// handleLintTemplate checks a draft template against the lint rules
+// (POST, the template as it would be saved) and returns the warnings, for
+// the template form.
+func (h *handlers) handleLintTemplate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var template db.Template
+	if err := json.NewDecoder(r.Body).Decode(&template); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"warnings": lint.Template(&template)})
+}
+
+// handleAdminLint returns the lint warnings for the catalog found by the
+// last run of the catalog-lint job, filtered by kind, rule, severity and
+// tenant, with the number of warnings per rule.
+func (h *handlers) handleAdminLint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := db.LintWarningFilter{
+		Kind:     q.Get("kind"),
+		Rule:     q.Get("rule"),
+		Severity: q.Get("severity"),
+		TenantID: q.Get("tenant"),
+	}
+	if filter.Kind != "" && filter.Kind != db.LintKindApp && filter.Kind != db.LintKindTemplate {
+		http.Error(w, "invalid 'kind': "+filter.Kind, http.StatusBadRequest)
+		return
+	}
+	if filter.Severity != "" && filter.Severity != string(lint.SeverityWarning) && filter.Severity != string(lint.SeverityInfo) {
+		http.Error(w, "invalid 'severity': "+filter.Severity, http.StatusBadRequest)
+		return
+	}
+
+	warnings, err := h.app.DB.ListLintWarnings(filter)
+	if err != nil {
+		slog.Error("error listing lint warnings", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	counts := map[string]int{}
+	var checkedAt *time.Time
+	for i := range warnings {
+		counts[warnings[i].Rule]++
+		checkedAt = &warnings[i].CheckedAt
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"warnings":   warnings,
+		"counts":     counts,
+		"checked_at": checkedAt,
+	})
+}
+
+// putIcon stores an icon image and returns its ID.
+func (h *handlers) putIcon(data []byte, contentType string) (string, error) {
+	id := icons.ID(data, contentType)
+	return id, h.app.BrandingStore.Put(icons.Path(id), contentType, bytes.NewReader(data))
+}
+
+// checkIcon refuses app and template icons on other hosts in offline
+// mode, since browsers in an air-gapped network cannot load them.
+func (h *handlers) checkIcon(icon string) error {
+	if h.app.Config.Offline && icons.IsRemote(icon) {
+		return errors.New("Invalid icon: the server is offline, so upload the icon to /api/assets/icons instead of linking to another host")
+	}
+	return nil
+}
+
+// handleIcon serves GET /api/assets/icons/{id}. Icons are public so the
+// login page and template catalog can show them, and immutable because an
+// ID always names the same image.
+func (h *handlers) handleIcon(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, icons.URLPrefix)
+	contentType, ok := icons.ContentType(id)
+	if !ok {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	var body io.Reader
+	if icons.IsBuiltin(id) {
+		data, ok := icons.Builtin(id)
+		if !ok {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		body = bytes.NewReader(data)
+	} else {
+		if h.app.BrandingStore == nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		rc, err := h.app.BrandingStore.Get(icons.Path(id))
+		if err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		defer rc.Close()
+		body = rc
+	}
+
+	etag := `"` + id + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	if r.Header.Get("If-None-Match") == etag {
+		w.Header().Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// SVGs can carry scripts; never run them when opened directly
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	if r.Method == http.MethodHead {
+		return
+	}
+	io.Copy(w, body)
+}
+
 // --- Category endpoints ---
 
 func (h *handlers) handleCategories(w http.ResponseWriter, r *http.Request) {
@@ -2635,6 +9376,12 @@ func (h *handlers) handleCategories(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Missing required field: name", http.StatusBadRequest)
 			return
 		}
+		if cat.Defaults != nil {
+			if err := cat.Defaults.Validate(); err != nil {
+				http.Error(w, "defaults: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
 		if cat.ID == "" {
 			cat.ID = fmt.Sprintf("cat-%s-%d", cat.Name, time.Now().UnixNano())
 		}
@@ -2653,7 +9400,7 @@ func (h *handlers) handleCategories(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		h.app.DB.LogAudit(user.Username, "CREATE_CATEGORY", fmt.Sprintf("Created category: %s (%s)", cat.Name, cat.ID))
+		h.logAudit(r, user.Username, "CREATE_CATEGORY", fmt.Sprintf("Created category: %s (%s)", cat.Name, cat.ID))
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
@@ -2747,6 +9494,22 @@ func (h *handlers) handleCategoryByID(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// Keep the category's defaults when the field is left out
+		if cat.Defaults == nil {
+			existing, err := h.app.DB.GetCategory(catID)
+			if err != nil {
+				slog.Error("error getting category", "error", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if existing != nil {
+				cat.Defaults = existing.Defaults
+			}
+		} else if err := cat.Defaults.Validate(); err != nil {
+			http.Error(w, "defaults: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		if err := h.app.DB.UpdateCategory(cat); err != nil {
 			if err.Error() == "sql: no rows in result set" {
 				http.Error(w, "Category not found", http.StatusNotFound)
@@ -2757,7 +9520,7 @@ func (h *handlers) handleCategoryByID(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		h.app.DB.LogAudit(user.Username, "UPDATE_CATEGORY", fmt.Sprintf("Updated category: %s (%s)", cat.Name, catID))
+		h.logAudit(r, user.Username, "UPDATE_CATEGORY", fmt.Sprintf("Updated category: %s (%s)", cat.Name, catID))
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(cat)
@@ -2785,7 +9548,7 @@ func (h *handlers) handleCategoryByID(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		h.app.DB.LogAudit(user.Username, "DELETE_CATEGORY", fmt.Sprintf("Deleted category: %s (%s)", cat.Name, catID))
+		h.logAudit(r, user.Username, "DELETE_CATEGORY", fmt.Sprintf("Deleted category: %s (%s)", cat.Name, catID))
 
 		w.WriteHeader(http.StatusNoContent)
 
@@ -2836,7 +9599,7 @@ func (h *handlers) handleCategoryAdmins(w http.ResponseWriter, r *http.Request,
 			return
 		}
 
-		h.app.DB.LogAudit(user.Username, "ADD_CATEGORY_ADMIN", fmt.Sprintf("Added admin %s to category %s", req.UserID, catID))
+		h.logAudit(r, user.Username, "ADD_CATEGORY_ADMIN", fmt.Sprintf("Added admin %s to category %s", req.UserID, catID))
 
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(map[string]string{"status": "added"})
@@ -2864,7 +9627,7 @@ func (h *handlers) handleCategoryAdminByUserID(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	h.app.DB.LogAudit(currentUser.Username, "REMOVE_CATEGORY_ADMIN", fmt.Sprintf("Removed admin %s from category %s", userID, catID))
+	h.logAudit(r, currentUser.Username, "REMOVE_CATEGORY_ADMIN", fmt.Sprintf("Removed admin %s from category %s", userID, catID))
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -2911,7 +9674,7 @@ func (h *handlers) handleCategoryApprovedUsers(w http.ResponseWriter, r *http.Re
 			return
 		}
 
-		h.app.DB.LogAudit(user.Username, "ADD_CATEGORY_APPROVED_USER", fmt.Sprintf("Added approved user %s to category %s", req.UserID, catID))
+		h.logAudit(r, user.Username, "ADD_CATEGORY_APPROVED_USER", fmt.Sprintf("Added approved user %s to category %s", req.UserID, catID))
 
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(map[string]string{"status": "added"})
@@ -2939,7 +9702,7 @@ func (h *handlers) handleCategoryApprovedUserByUserID(w http.ResponseWriter, r *
 		return
 	}
 
-	h.app.DB.LogAudit(currentUser.Username, "REMOVE_CATEGORY_APPROVED_USER", fmt.Sprintf("Removed approved user %s from category %s", userID, catID))
+	h.logAudit(r, currentUser.Username, "REMOVE_CATEGORY_APPROVED_USER", fmt.Sprintf("Removed approved user %s from category %s", userID, catID))
 
 	w.WriteHeader(http.StatusNoContent)
 }
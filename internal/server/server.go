@@ -4,19 +4,41 @@
 package server
 
 import (
+	"expvar"
 	"io/fs"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
 
+	"github.com/rjsadow/sortie/internal/apiusage"
+	"github.com/rjsadow/sortie/internal/attestation"
+	"github.com/rjsadow/sortie/internal/branding"
+	"github.com/rjsadow/sortie/internal/icons"
+	"github.com/rjsadow/sortie/internal/chaos"
 	"github.com/rjsadow/sortie/internal/config"
 	"github.com/rjsadow/sortie/internal/db"
 	"github.com/rjsadow/sortie/internal/diagnostics"
+	"github.com/rjsadow/sortie/internal/email"
 	"github.com/rjsadow/sortie/internal/files"
 	"github.com/rjsadow/sortie/internal/gateway"
+	"github.com/rjsadow/sortie/internal/jobs"
 	"github.com/rjsadow/sortie/internal/middleware"
 	"github.com/rjsadow/sortie/internal/recordings"
+	"github.com/rjsadow/sortie/internal/plugins"
 	"github.com/rjsadow/sortie/internal/plugins/auth"
+	"github.com/rjsadow/sortie/internal/presence"
+	"github.com/rjsadow/sortie/internal/proxy"
 	"github.com/rjsadow/sortie/internal/sessions"
+	"github.com/rjsadow/sortie/internal/spectate"
 	"github.com/rjsadow/sortie/internal/sse"
+	"github.com/rjsadow/sortie/internal/ssrf"
+	"github.com/rjsadow/sortie/internal/storagebrowser"
+	"github.com/rjsadow/sortie/internal/storagecrypt"
+	"github.com/rjsadow/sortie/internal/streamstats"
+	"github.com/rjsadow/sortie/internal/updatecheck"
 )
 
 // App holds all dependencies needed to build the HTTP handler.
@@ -31,6 +53,20 @@ type App struct {
 	RecordingHandler    *recordings.Handler
 	SSEHub              *sse.Hub
 	DiagCollector       *diagnostics.Collector
+	StorageBrowser      *storagebrowser.Browser // nil when no object storage is configured
+	BrandingStore       branding.Store          // nil disables branding asset uploads
+	Spectate            *spectate.Manager       // nil disables admin spectate
+	Presence            *presence.Tracker       // nil disables the session presence list
+	StreamStats         *streamstats.Tracker    // nil reports no stream stats
+	Attestor            *attestation.Signer     // nil disables session attestation
+	CredentialCipher    *storagecrypt.Cipher    // nil seals registry passwords with a key derived from the JWT secret
+	UpdateChecker       *updatecheck.Checker    // nil omits update status from admin health
+	Jobs                *jobs.Scheduler         // nil lists no background jobs
+	APIUsage            *apiusage.Meter         // nil disables API usage metering
+	Mailer              email.Sender            // nil disables password reset
+	Outbound            *ssrf.Guard             // Policy for requests to admin-supplied URLs (nil = no allowlist)
+	AuthRateLimiter     *middleware.RateLimiter // nil disables login and registration rate limits
+	SessionRateLimiter  *middleware.RateLimiter // nil disables session creation rate limits
 	Config              *config.Config
 	StaticFS            fs.FS // web/dist content (nil disables static serving)
 	DocsFS              fs.FS // docs-site/dist content (nil disables docs serving)
@@ -48,13 +84,22 @@ func (a *App) Handler() http.Handler {
 	mux.HandleFunc("/healthz", h.handleHealthz)
 	mux.HandleFunc("/readyz", h.handleReadyz)
 	mux.HandleFunc("/api/load", a.BackpressureHandler.ServeLoadStatus)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/metrics", h.handleMetrics)
+	mux.HandleFunc("/api/version", h.handleVersion)
+	mux.HandleFunc("/api/status", h.handleStatus)
 
-	// Auth routes (public)
-	mux.HandleFunc("/api/auth/login", h.handleLogin)
+	// Auth routes (public). Login and registration are rate limited per
+	// client IP and per username.
+	authLimit := func(handler http.Handler) http.Handler { return handler }
+	if a.AuthRateLimiter != nil {
+		authLimit = middleware.RateLimitMiddleware(a.AuthRateLimiter, middleware.ClientIPKey, middleware.UsernameKey)
+	}
+	mux.Handle("/api/auth/login", authLimit(http.HandlerFunc(h.handleLogin)))
 	mux.HandleFunc("/api/auth/logout", h.handleLogout)
 	mux.HandleFunc("/api/auth/refresh", h.handleRefreshToken)
 	mux.HandleFunc("/api/auth/me", h.handleAuthMe)
-	mux.HandleFunc("/api/auth/register", h.handleRegister)
+	mux.Handle("/api/auth/register", authLimit(http.HandlerFunc(h.handleRegister)))
 
 	// OIDC/SSO routes (public)
 	mux.HandleFunc("/api/auth/oidc/login", h.handleOIDCLogin)
@@ -63,8 +108,17 @@ func (a *App) Handler() http.Handler {
 	// Config route (public)
 	mux.HandleFunc("/api/config", h.handleConfig)
 
-	// Protected API routes
-	authMiddleware := middleware.AuthMiddleware(a.JWTAuth)
+	// Uploaded branding assets (public, shown on the login page)
+	mux.HandleFunc("/api/branding/assets/", h.handleBrandingAsset)
+
+	// App and template icons (public, immutable)
+	mux.HandleFunc(icons.URLPrefix, h.handleIcon)
+
+	// Protected API routes, metered per user and API token
+	authenticate := middleware.AuthMiddleware(a.JWTAuth)
+	authMiddleware := func(next http.Handler) http.Handler {
+		return authenticate(a.APIUsage.Middleware(next))
+	}
 	tenantMiddleware := middleware.TenantMiddleware(a.DB)
 	requireAdmin := middleware.RequireRole(middleware.RoleAdmin)
 
@@ -72,23 +126,108 @@ func (a *App) Handler() http.Handler {
 		return authMiddleware(tenantMiddleware(handler))
 	}
 
+	// Icon uploads for the app and template forms
+	mux.Handle("/api/assets/icons", authMiddleware(middleware.RequireRole(middleware.RoleAdmin, middleware.RoleAppAuthor)(http.HandlerFunc(h.handleIconUpload))))
+
+	// Lint checks for drafts in the app and template forms
+	mux.Handle("/api/lint/app", authMiddleware(middleware.RequireRole(middleware.RoleAdmin, middleware.RoleAppAuthor)(http.HandlerFunc(h.handleLintApp))))
+	mux.Handle("/api/lint/template", authMiddleware(middleware.RequireRole(middleware.RoleAdmin, middleware.RoleAppAuthor)(http.HandlerFunc(h.handleLintTemplate))))
+
 	// Admin routes (protected, admin-only)
 	mux.Handle("/api/admin/settings", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminSettings))))
+	mux.Handle("/api/admin/setting-changes", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminSettingChanges))))
+	mux.Handle("/api/admin/setting-changes/", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminSettingChangeByID))))
 	mux.Handle("/api/admin/users", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminUsers))))
 	mux.Handle("/api/admin/users/", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminUserByID))))
 	mux.Handle("/api/admin/sessions", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminSessions))))
+	mux.Handle("/api/admin/sessions/", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminSessionByID))))
+	mux.Handle("/api/admin/session-archive", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminSessionArchive))))
+	mux.Handle("/api/admin/projects", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminProjects))))
+	mux.Handle("/api/admin/user-volumes", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminUserVolumes))))
+	mux.Handle("/api/admin/user-volumes/", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminUserVolumeByName))))
+	mux.Handle("/api/admin/prepull", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminPrePulls))))
+	mux.Handle("/api/admin/prepull/", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminPrePullByApp))))
+	mux.Handle("/api/admin/history", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminHistory))))
+	mux.Handle("/api/admin/history/summary", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminHistorySummary))))
+	mux.Handle("/api/admin/history/concurrency", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminHistoryConcurrency))))
+	mux.Handle("/api/admin/reports/costs", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminCostReport))))
+	mux.Handle("/api/admin/slos", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminSLOs))))
+	mux.Handle("/api/admin/api-usage", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminAPIUsage))))
+	mux.Handle("/api/admin/autoscaling", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminAutoscaling))))
+	mux.Handle("/api/admin/attestation-key", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminAttestationKey))))
+	mux.Handle("/api/admin/compliance-report", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminComplianceReport))))
 	mux.Handle("/api/admin/templates", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminTemplates))))
+	mux.Handle("/api/admin/templates/import", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminTemplateImport))))
+	mux.Handle("/api/admin/templates/export", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminTemplateExport))))
 	mux.Handle("/api/admin/templates/", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminTemplateByID))))
+	mux.Handle("/api/admin/template-catalogs", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminTemplateCatalogs))))
+	mux.Handle("/api/admin/template-catalogs/", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminTemplateCatalogByName))))
+	mux.Handle("/api/admin/sidecars", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminSidecars))))
+	mux.Handle("/api/admin/sidecars/", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminSidecarByName))))
+	mux.Handle("/api/admin/registries", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminRegistries))))
+	mux.Handle("/api/admin/registries/", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminRegistryByName))))
+	mux.Handle("/api/admin/lint", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminLint))))
+	mux.Handle("/api/admin/outbound/check", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminOutboundCheck))))
 
 	// Enterprise support endpoints (admin-only)
 	mux.Handle("/api/admin/diagnostics", authMiddleware(requireAdmin(http.HandlerFunc(h.handleDiagnosticsBundle))))
 	mux.Handle("/api/admin/health", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminHealth))))
+	mux.Handle("/api/admin/health/history", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminHealthHistory))))
+	mux.Handle("/api/admin/announcements", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminAnnouncements))))
+	mux.Handle("/api/admin/announcements/", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminAnnouncementByID))))
+	mux.Handle("/api/admin/jobs", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminJobs))))
+	mux.Handle("/api/admin/jobs/", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminJobByName))))
+	if chaos.Enabled {
+		mux.Handle("/api/admin/chaos", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminChaos))))
+	}
 	mux.Handle("/api/admin/support/info", authMiddleware(requireAdmin(http.HandlerFunc(h.handleSupportInfo))))
+	mux.Handle("/api/admin/storage", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminStorage))))
+	mux.Handle("/api/admin/storage/", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminStorageCategory))))
 
 	// Tenant admin routes (protected, admin-only)
 	mux.Handle("/api/admin/tenants", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminTenants))))
 	mux.Handle("/api/admin/tenants/", authMiddleware(requireAdmin(http.HandlerFunc(h.handleAdminTenantByID))))
 
+	// Device management: the current user's refresh tokens (auth-protected)
+	mux.Handle("/api/auth/devices", authMiddleware(http.HandlerFunc(h.handleDevices)))
+	mux.Handle("/api/auth/devices/", authMiddleware(http.HandlerFunc(h.handleDeviceByID)))
+
+	// Personal access tokens: the current user's API tokens (auth-protected)
+	mux.Handle("/api/auth/tokens", authMiddleware(http.HandlerFunc(h.handleAPITokens)))
+	mux.Handle("/api/auth/tokens/", authMiddleware(http.HandlerFunc(h.handleAPITokenByID)))
+
+	// TOTP multi-factor authentication. Setup and verify also serve the
+	// second login step, authenticated by the MFA token from login, so they
+	// take an optional access token; all are rate limited like login.
+	optionalAuth := middleware.OptionalAuthMiddleware(a.JWTAuth)
+	mux.Handle("/api/auth/mfa", authLimit(authMiddleware(http.HandlerFunc(h.handleMFA))))
+	mux.Handle("/api/auth/mfa/setup", authLimit(optionalAuth(http.HandlerFunc(h.handleMFASetup))))
+	mux.Handle("/api/auth/mfa/verify", authLimit(optionalAuth(http.HandlerFunc(h.handleMFAVerify))))
+	mux.Handle("/api/auth/mfa/backup-codes", authLimit(authMiddleware(http.HandlerFunc(h.handleMFABackupCodes))))
+
+	// Passkeys (WebAuthn). Registering and listing need a logged-in user;
+	// the login ceremony is public and rate limited like login.
+	mux.Handle("/api/auth/passkeys", authMiddleware(http.HandlerFunc(h.handlePasskeys)))
+	mux.Handle("/api/auth/passkeys/", authMiddleware(http.HandlerFunc(h.handlePasskeyByID)))
+	mux.Handle("/api/auth/passkeys/register/options", authLimit(authMiddleware(http.HandlerFunc(h.handlePasskeyRegisterOptions))))
+	mux.Handle("/api/auth/passkeys/register", authLimit(authMiddleware(http.HandlerFunc(h.handlePasskeyRegister))))
+	mux.Handle("/api/auth/passkeys/login/options", authLimit(http.HandlerFunc(h.handlePasskeyLoginOptions)))
+	mux.Handle("/api/auth/passkeys/login", authLimit(http.HandlerFunc(h.handlePasskeyLogin)))
+
+	// Password reset by email (public, rate limited like login)
+	mux.Handle("/api/auth/forgot-password", authLimit(http.HandlerFunc(h.handleForgotPassword)))
+	mux.Handle("/api/auth/reset-password", authLimit(http.HandlerFunc(h.handleResetPassword)))
+
+	// Forward-auth check for directly routed session hostnames, called by
+	// the ingress controller for each request
+	mux.HandleFunc("/api/auth/session-host", a.handleSessionHostAuth)
+
+	// Forward-auth check for other services behind the ingress controller
+	mux.HandleFunc("/api/authz", a.handleAuthz)
+
+	// Current user's language preference (auth-protected)
+	mux.Handle("/api/auth/me/locale", authMiddleware(http.HandlerFunc(h.handleAuthMeLocale)))
+
 	// User list endpoint (auth-protected, non-admin)
 	mux.Handle("/api/users", authMiddleware(http.HandlerFunc(h.handleUsersList)))
 
@@ -118,13 +257,28 @@ func (a *App) Handler() http.Handler {
 	// Session API routes
 	mux.Handle("/api/sessions/shared", withTenant(http.HandlerFunc(h.handleSharedSessions)))
 	mux.Handle("/api/sessions/shares/join", withTenant(http.HandlerFunc(h.handleJoinShare)))
-	mux.Handle("/api/sessions", withTenant(http.HandlerFunc(h.handleSessions)))
+	mux.Handle("/api/sessions/schedule", withTenant(http.HandlerFunc(h.handleScheduledSessions)))
+	mux.Handle("/api/sessions/schedule/", withTenant(http.HandlerFunc(h.handleScheduledSessionByID)))
+	mux.Handle("/api/sessions", withTenant(a.limitSessionCreation(http.HandlerFunc(h.handleSessions))))
 	mux.Handle("/api/sessions/", withTenant(http.HandlerFunc(h.handleSessionByID)))
 
+	// Project API routes
+	mux.Handle("/api/projects", withTenant(http.HandlerFunc(h.handleProjects)))
+	mux.Handle("/api/projects/", withTenant(http.HandlerFunc(h.handleProjectByID)))
+
 	// Recording API routes
 	if a.RecordingHandler != nil {
 		mux.Handle("/api/recordings", withTenant(a.RecordingHandler))
-		mux.Handle("/api/recordings/", withTenant(a.RecordingHandler))
+		// Signed stream URLs authorize themselves, as video elements
+		// cannot send a bearer token
+		recordingByID := withTenant(a.RecordingHandler)
+		mux.Handle("/api/recordings/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if a.RecordingHandler.SignedStream(r) {
+				a.RecordingHandler.ServeHTTP(w, r)
+				return
+			}
+			recordingByID.ServeHTTP(w, r)
+		}))
 		mux.Handle("/api/admin/recordings", authMiddleware(requireAdmin(a.RecordingHandler)))
 	}
 
@@ -159,6 +313,222 @@ func (a *App) Handler() http.Handler {
 		mux.HandleFunc("/", h.staticHandler(fileServer))
 	}
 
-	// Wrap with middleware
-	return middleware.SecurityHeaders(middleware.RequestID(mux))
+	// Wrap with middleware. Session hostnames are served outside it, since
+	// the apps behind them set their own security headers.
+	var trustedProxies []netip.Prefix
+	if a.Config != nil {
+		trustedProxies = a.Config.TrustedProxies
+	}
+	return a.sessionHosts(middleware.SecurityHeaders(middleware.RequestID(middleware.RealIP(trustedProxies)(middleware.Tracing(mux)))))
+}
+
+// sessionHosts serves requests for session hostnames, such as
+// jupyter-alice.sortie.example.com, by proxying them to the web_proxy
+// session holding the hostname. Only the session's owner may use it,
+// authenticated by the access token cookie, which Sortie scopes to the
+// session domain. Other requests go to next.
+func (a *App) sessionHosts(next http.Handler) http.Handler {
+	if a.Config == nil || a.Config.SessionDomain == "" || a.SessionManager == nil {
+		return next
+	}
+	domain := a.Config.SessionDomain
+	sessionProxy := proxy.NewHTTPProxy(a.SessionManager)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := requestHost(r)
+		if !isSessionHost(host, domain) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		session, status, msg := a.authorizeSessionHost(r, host, r.Method)
+		if session == nil {
+			http.Error(w, msg, status)
+			return
+		}
+
+		sessionProxy.ServeSession(w, r, session)
+	})
+}
+
+// handleSessionHostAuth is the forward-auth endpoint ingress controllers
+// call before passing a request to a directly routed session. It answers
+// 200 if the original request may use the session at its host, and 401 or
+// 403 otherwise.
+//
+// The original host and method are taken from the host and method query
+// parameters, which each session Ingress sets in its auth URL, so clients
+// can't change them. Without them they come from X-Forwarded-Host and
+// X-Forwarded-Method, for controllers such as Traefik that set those
+// headers themselves.
+func (a *App) handleSessionHostAuth(w http.ResponseWriter, r *http.Request) {
+	if a.Config == nil || a.Config.SessionDomain == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	q := r.URL.Query()
+	host, method := q.Get("host"), q.Get("method")
+	if host == "" {
+		host = r.Header.Get("X-Forwarded-Host")
+	}
+	if method == "" {
+		method = r.Header.Get("X-Forwarded-Method")
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	if !isSessionHost(host, a.Config.SessionDomain) {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return
+	}
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	if session, status, msg := a.authorizeSessionHost(r, host, method); session == nil {
+		http.Error(w, msg, status)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// isSessionHost reports whether host is a single label under domain, the
+// shape of session hostnames.
+func isSessionHost(host, domain string) bool {
+	label, ok := strings.CutSuffix(host, "."+domain)
+	return ok && label != "" && !strings.Contains(label, ".")
+}
+
+// authorizeSessionHost returns the session holding host if r, a request
+// with the given method, may use it: only the session's owner may. If not,
+// it returns the status and message to refuse the request with.
+func (a *App) authorizeSessionHost(r *http.Request, host, method string) (*db.Session, int, string) {
+	session, err := a.DB.WithContext(r.Context()).GetSessionByHostname(host)
+	if err != nil {
+		slog.Error("failed to look up session hostname", "host", host, "error", err)
+		return nil, http.StatusInternalServerError, "Internal server error"
+	}
+	if session == nil {
+		return nil, http.StatusNotFound, "Session not found"
+	}
+
+	user := a.forwardedUser(r, method)
+	if user == nil {
+		return nil, http.StatusUnauthorized, "Unauthorized"
+	}
+	if user.ID != session.UserID {
+		return nil, http.StatusForbidden, "Access denied"
+	}
+	return session, http.StatusOK, ""
+}
+
+// forwardedUser authenticates a request passed on by a reverse proxy by its
+// access token cookie or, for non-browser clients, bearer token, checking
+// API token scopes against each of methods. It returns nil if the request
+// is not authenticated.
+func (a *App) forwardedUser(r *http.Request, methods ...string) *plugins.User {
+	if a.JWTAuth == nil {
+		return nil
+	}
+	token := ""
+	if c, err := r.Cookie(middleware.AccessTokenCookieName); err == nil {
+		token = c.Value
+	}
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); token == "" && ok {
+		token = bearer
+	}
+	if token == "" {
+		return nil
+	}
+	result, err := a.JWTAuth.Authenticate(r.Context(), token)
+	if err != nil || !result.Authenticated || result.User == nil {
+		return nil
+	}
+	// API tokens only allow the requests their scopes cover
+	if scopes, ok := result.User.Metadata[auth.MetadataAPITokenScopes]; ok {
+		for _, method := range methods {
+			if !auth.ScopesAllow(scopes, method) {
+				return nil
+			}
+		}
+	}
+	return result.User
+}
+
+// handleAuthz is a general forward-auth endpoint for nginx auth_request,
+// Traefik forwardAuth and similar, so other services in the cluster can
+// sit behind Sortie's login. It answers 200 with the user's identity in
+// X-Auth-Request-* headers if the request carries a valid access token,
+// and 401 otherwise. A roles query parameter, a comma-separated list,
+// additionally requires one of those roles, answering 403 without it.
+//
+// Any method is accepted since proxies may pass on the original one.
+// API token scopes are checked against the request method and the
+// original method headers of nginx and Traefik; a client can add one
+// of these headers itself, so all of them must be allowed.
+func (a *App) handleAuthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	methods := []string{r.Method}
+	for _, header := range []string{"X-Original-Method", "X-Forwarded-Method"} {
+		if m := r.Header.Get(header); m != "" {
+			methods = append(methods, strings.ToUpper(m))
+		}
+	}
+	user := a.forwardedUser(r, methods...)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if roles := r.URL.Query().Get("roles"); roles != "" {
+		allowed := false
+		for _, role := range strings.Split(roles, ",") {
+			if slices.Contains(user.Roles, strings.TrimSpace(role)) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			http.Error(w, "Access denied", http.StatusForbidden)
+			return
+		}
+	}
+
+	w.Header().Set("X-Auth-Request-User", user.Username)
+	w.Header().Set("X-Auth-Request-User-Id", user.ID)
+	w.Header().Set("X-Auth-Request-Email", user.Email)
+	w.Header().Set("X-Auth-Request-Groups", strings.Join(user.Roles, ","))
+	if tenantID := user.Metadata["tenant_id"]; tenantID != "" {
+		w.Header().Set("X-Auth-Request-Tenant", tenantID)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// requestHost returns the lowercased host of r, without a port.
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// limitSessionCreation rate limits POST requests to handler per client IP
+// and per user. Listing sessions is not limited.
+func (a *App) limitSessionCreation(handler http.Handler) http.Handler {
+	if a.SessionRateLimiter == nil {
+		return handler
+	}
+	limited := middleware.RateLimitMiddleware(a.SessionRateLimiter, middleware.ClientIPKey, middleware.UserKey)(handler)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			limited.ServeHTTP(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
 }
@@ -0,0 +1,110 @@
+package sessions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rjsadow/sortie/internal/attestation"
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/runner"
+)
+
+// attestSession stores a signed report of the environment a session ran in,
+// if its app asks for one. It must be called before the session's workload
+// is deleted so the runner can still inspect it. Failures are logged rather
+// than returned so that they never keep a session running.
+func (m *Manager) attestSession(ctx context.Context, session *db.Session, finalStatus db.SessionStatus, reason string) {
+	if m.attestor == nil || session.PodName == "" {
+		return
+	}
+	app, err := m.db.GetApp(session.AppID)
+	if err != nil || app == nil || !app.AttestSessions {
+		return
+	}
+	if err := m.storeAttestation(ctx, session, app, finalStatus, reason); err != nil {
+		log.Printf("Warning: failed to attest session %s: %v", session.ID, err)
+	}
+}
+
+func (m *Manager) storeAttestation(ctx context.Context, session *db.Session, app *db.Application, finalStatus db.SessionStatus, reason string) error {
+	report, err := m.buildReport(ctx, session, app, finalStatus, reason)
+	if err != nil {
+		return err
+	}
+	env, err := m.attestor.Sign(report)
+	if err != nil {
+		return err
+	}
+	envelope, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+	return m.db.CreateSessionAttestation(db.SessionAttestation{
+		ID:        uuid.New().String(),
+		SessionID: session.ID,
+		TenantID:  session.TenantID,
+		KeyID:     m.attestor.KeyID(),
+		Envelope:  string(envelope),
+		CreatedAt: report.GeneratedAt,
+	})
+}
+
+// buildReport describes a session run from the session record, its app and
+// owner, and what the runner reports about its workload.
+func (m *Manager) buildReport(ctx context.Context, session *db.Session, app *db.Application, finalStatus db.SessionStatus, reason string) (*attestation.Report, error) {
+	now := time.Now().UTC()
+	report := &attestation.Report{
+		Version:   attestation.ReportVersion,
+		SessionID: session.ID,
+		TenantID:  session.TenantID,
+		App: attestation.App{
+			ID:         app.ID,
+			Name:       app.Name,
+			LaunchType: string(app.LaunchType),
+			OsType:     app.OsType,
+		},
+		User:             attestation.User{ID: session.UserID},
+		SessionCreatedAt: session.CreatedAt.UTC(),
+		StartedAt:        session.CreatedAt.UTC(),
+		EndedAt:          now,
+		FinalStatus:      string(finalStatus),
+		Reason:           reason,
+		Workload:         session.PodName,
+		Containers:       []attestation.Container{},
+		GeneratedAt:      now,
+	}
+
+	if user, err := m.db.GetUserByID(session.UserID); err == nil && user != nil {
+		report.User.Username = user.Username
+		report.User.Email = user.Email
+		report.User.AuthProvider = user.AuthProvider
+	}
+
+	inspector, ok := m.runner.(runner.WorkloadInspector)
+	if !ok {
+		return report, nil
+	}
+	details, err := inspector.InspectWorkload(ctx, session.PodName, session.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect workload: %w", err)
+	}
+	if !details.StartedAt.IsZero() {
+		report.StartedAt = details.StartedAt.UTC()
+	}
+	for _, c := range details.Containers {
+		report.Containers = append(report.Containers, attestation.Container{
+			Name:        c.Name,
+			Image:       c.Image,
+			ImageDigest: c.ImageID,
+			Resources:   c.Resources,
+		})
+	}
+	if np := details.NetworkPolicy; np != nil {
+		report.NetworkPolicy = &attestation.NetworkPolicy{Name: np.Name, Spec: np.Spec}
+	}
+	return report, nil
+}
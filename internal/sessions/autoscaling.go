@@ -0,0 +1,63 @@
+package sessions
+
+import (
+	"log"
+	"time"
+)
+
+// AutoscalingLaunchWindow is how far back AppDemand counts launches.
+const AutoscalingLaunchWindow = 15 * time.Minute
+
+// AutoscalingSignals is the demand reported to autoscalers, such as the
+// KEDA metrics-api scaler, so the server and warm pools can be scaled on
+// sessions rather than CPU.
+type AutoscalingSignals struct {
+	LoadStatus
+	// Apps holds the demand for each app that has active sessions,
+	// recent launches or a warm pool, by app ID.
+	Apps map[string]AppDemand `json:"apps"`
+}
+
+// AppDemand is the demand for one app.
+type AppDemand struct {
+	ActiveSessions int `json:"active_sessions"`
+	// Launches counts the launches requested in the last
+	// AutoscalingLaunchWindow, including failed ones.
+	Launches     int `json:"launches"`
+	WarmPoolSize int `json:"warm_pool_size"`
+}
+
+// AutoscalingSignals returns the current load and the demand for each
+// app. Like GetLoadStatus, session counts are for all replicas and the
+// queue depth is for this one.
+func (h *BackpressureHandler) AutoscalingSignals() *AutoscalingSignals {
+	signals := &AutoscalingSignals{
+		LoadStatus: *h.GetLoadStatus(),
+		Apps:       make(map[string]AppDemand),
+	}
+
+	active, err := h.manager.db.CountActiveSessionsPerApp()
+	if err != nil {
+		log.Printf("Warning: failed to count active sessions per app: %v", err)
+	}
+	launches, err := h.manager.db.CountSessionLaunchesPerApp(time.Now().Add(-AutoscalingLaunchWindow))
+	if err != nil {
+		log.Printf("Warning: failed to count recent launches per app: %v", err)
+	}
+	apps, err := h.manager.db.ListApps()
+	if err != nil {
+		log.Printf("Warning: failed to list apps for autoscaling: %v", err)
+	}
+
+	for _, app := range apps {
+		demand := AppDemand{
+			ActiveSessions: active[app.ID],
+			Launches:       launches[app.ID],
+			WarmPoolSize:   app.WarmPoolSize,
+		}
+		if demand != (AppDemand{}) {
+			signals.Apps[app.ID] = demand
+		}
+	}
+	return signals
+}
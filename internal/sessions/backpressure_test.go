@@ -1,10 +1,15 @@
 package sessions
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -302,6 +307,83 @@ func TestBackpressureMiddleware(t *testing.T) {
 	}
 }
 
+func TestBackpressureWritePrometheus(t *testing.T) {
+	database := newTestDB(t)
+	m := NewManagerWithConfig(database, ManagerConfig{
+		MaxGlobalSessions: 10,
+	})
+	if err := database.CreateTenant(db.Tenant{
+		ID: "acme", Name: "Acme", Slug: "acme",
+		Quotas: db.TenantQuotas{MaxTotalSessions: 4},
+	}); err != nil {
+		t.Fatalf("CreateTenant: %v", err)
+	}
+	seedContainerApp(t, database, "app1", "Test App", "test:latest")
+	now := time.Now()
+	for i, tenant := range []string{"acme", "acme", db.DefaultTenantID} {
+		s := db.Session{
+			ID: fmt.Sprintf("s%d", i), UserID: "u1", AppID: "app1", TenantID: tenant,
+			PodName: fmt.Sprintf("p%d", i), Status: db.SessionStatusRunning,
+			CreatedAt: now, UpdatedAt: now,
+		}
+		if err := database.CreateSession(s); err != nil {
+			t.Fatalf("CreateSession error: %v", err)
+		}
+	}
+
+	q := NewSessionQueue(QueueConfig{
+		MaxSize:      10,
+		Timeout:      200 * time.Millisecond,
+		PollInterval: time.Hour,
+	}, func() bool { return false })
+	defer q.Stop()
+	var timeout *QueueTimeoutError
+	if err := q.Enqueue(context.Background(), "acme"); !errors.As(err, &timeout) {
+		t.Fatalf("Enqueue() error = %v, want a timeout", err)
+	}
+	go q.Enqueue(context.Background(), "acme") //nolint:errcheck
+	time.Sleep(50 * time.Millisecond)
+
+	m.countQuotaRejection("acme", &QuotaExceededError{Reason: "full", Limit: QuotaLimitTenant})
+	m.countQuotaRejection("acme", &QuotaExceededError{Reason: "full", Limit: QuotaLimitTenant})
+	m.countQuotaRejection(db.DefaultTenantID, &QuotaExceededError{Reason: "full", Limit: QuotaLimitUser})
+	m.countQuotaRejection("acme", errors.New("not a quota error"))
+
+	var buf bytes.Buffer
+	NewBackpressureHandler(m, q, 10).WritePrometheus(&buf)
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE sortie_active_sessions gauge\n",
+		`sortie_active_sessions{tenant="acme"} 2`,
+		`sortie_active_sessions{tenant="default"} 1`,
+		"sortie_max_sessions 10\n",
+		"sortie_load_factor 0.3\n",
+		`sortie_tenant_max_sessions{tenant="acme"} 4`,
+		`sortie_tenant_load_factor{tenant="acme"} 0.5`,
+		`sortie_queue_depth{tenant="acme"} 1`,
+		"sortie_queue_max_size 10\n",
+		"sortie_accepting_sessions 1\n",
+		"# TYPE sortie_quota_rejections_total counter\n",
+		`sortie_quota_rejections_total{tenant="acme",limit="tenant"} 2`,
+		`sortie_quota_rejections_total{tenant="default",limit="user"} 1`,
+		`sortie_queue_timeouts_total{tenant="acme"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `sortie_tenant_load_factor{tenant="default"}`) {
+		t.Errorf("tenant without a session limit has a load factor:\n%s", out)
+	}
+
+	// A handler without a queue still reports its load
+	buf.Reset()
+	NewBackpressureHandler(m, nil, 0).WritePrometheus(&buf)
+	if !strings.Contains(buf.String(), "sortie_queue_max_size 0\n") {
+		t.Errorf("metrics without a queue:\n%s", buf.String())
+	}
+}
+
 func TestEstimateWaitTime(t *testing.T) {
 	if got := EstimateWaitTime(0, time.Minute); got != 0 {
 		t.Errorf("EstimateWaitTime(0) = %v, want 0", got)
@@ -312,3 +394,52 @@ func TestEstimateWaitTime(t *testing.T) {
 		t.Errorf("EstimateWaitTime(5) should be positive, got %v", got)
 	}
 }
+
+func TestAutoscalingSignals(t *testing.T) {
+	database := newTestDB(t)
+	m := NewManagerWithConfig(database, ManagerConfig{
+		MaxGlobalSessions: 4,
+	})
+	seedContainerApp(t, database, "app1", "Busy App", "test:latest")
+	seedContainerApp(t, database, "idle", "Idle App", "test:latest")
+	pooled := seedContainerApp(t, database, "pooled", "Pooled App", "test:latest")
+	pooled.WarmPoolSize = 3
+	if err := database.UpdateApp(pooled); err != nil {
+		t.Fatalf("UpdateApp error: %v", err)
+	}
+
+	now := time.Now()
+	for i := range 2 {
+		s := db.Session{
+			ID: fmt.Sprintf("s%d", i), UserID: "u1", AppID: "app1",
+			PodName: fmt.Sprintf("p%d", i), Status: db.SessionStatusRunning,
+			CreatedAt: now, UpdatedAt: now,
+		}
+		if err := database.CreateSession(s); err != nil {
+			t.Fatalf("CreateSession error: %v", err)
+		}
+	}
+	for i, requested := range []time.Time{now.Add(-time.Minute), now.Add(-2 * time.Minute), now.Add(-time.Hour)} {
+		l := db.SessionLaunch{
+			SessionID: fmt.Sprintf("l%d", i), AppID: "app1",
+			RequestedAt: requested, FinishedAt: requested.Add(10 * time.Second), Succeeded: true,
+		}
+		if err := database.CreateSessionLaunch(l); err != nil {
+			t.Fatalf("CreateSessionLaunch error: %v", err)
+		}
+	}
+
+	h := NewBackpressureHandler(m, nil, 0)
+	signals := h.AutoscalingSignals()
+
+	if signals.ActiveSessions != 2 || signals.LoadFactor != 0.5 {
+		t.Errorf("load = %+v, want 2 active sessions at 0.5", signals.LoadStatus)
+	}
+	want := map[string]AppDemand{
+		"app1":   {ActiveSessions: 2, Launches: 2},
+		"pooled": {WarmPoolSize: 3},
+	}
+	if !reflect.DeepEqual(signals.Apps, want) {
+		t.Errorf("Apps = %+v, want %+v", signals.Apps, want)
+	}
+}
@@ -0,0 +1,151 @@
+package sessions
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// DefaultSessionBurstDuration is the default burst time allowance when a
+// burst limit is configured without a duration.
+const DefaultSessionBurstDuration = 30 * time.Minute
+
+// burstPolicy makes the per-user session limit soft: users may run up to
+// burst sessions, but only for a total of window above the soft limit.
+// Time spent above the limit is tracked as debt, which time spent at or
+// under the limit pays back at the same rate.
+type burstPolicy struct {
+	soft   int
+	burst  int
+	window time.Duration
+}
+
+func (p burstPolicy) enabled() bool {
+	return p.soft > 0 && p.burst > p.soft && p.window > 0
+}
+
+// userBurstPolicy returns the burst policy for the per-user session limit.
+func (m *Manager) userBurstPolicy() burstPolicy {
+	return burstPolicy{
+		soft:   m.maxSessionsPerUser,
+		burst:  m.maxSessionsPerUserBurst,
+		window: m.sessionBurstDuration,
+	}
+}
+
+// burstDebt returns a user's burst debt, in seconds, as of now. Only whole
+// seconds are accounted, so it also returns the time they are accounted up
+// to; the remainder carries over to the next settlement.
+func burstDebt(b *db.QuotaBurst, now time.Time, p burstPolicy) (int64, time.Time) {
+	if b == nil {
+		return 0, now
+	}
+	elapsed := int64(now.Sub(b.UpdatedAt) / time.Second)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	debt := b.DebtSeconds
+	if b.Bursting {
+		debt += elapsed
+	} else {
+		debt -= elapsed
+	}
+	debt = max(0, min(debt, int64(p.window/time.Second)))
+	return debt, b.UpdatedAt.Add(time.Duration(elapsed) * time.Second)
+}
+
+// settleBurst brings a user's burst debt up to date and records whether
+// their current session count is above the soft limit.
+func (m *Manager) settleBurst(userID string, count int, p burstPolicy) (*db.QuotaBurst, error) {
+	b, err := m.db.GetQuotaBurst(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get burst state: %w", err)
+	}
+	debt, settledAt := burstDebt(b, time.Now(), p)
+	settled := db.QuotaBurst{
+		UserID:      userID,
+		DebtSeconds: debt,
+		Bursting:    count > p.soft,
+		UpdatedAt:   settledAt,
+	}
+	if err := m.db.SaveQuotaBurst(settled); err != nil {
+		return nil, fmt.Errorf("failed to save burst state: %w", err)
+	}
+	return &settled, nil
+}
+
+// checkBurst decides whether a user at or above the soft limit may start
+// another session. Without a burst policy the limit is hard and limitReason
+// describes the rejection.
+func (m *Manager) checkBurst(userID string, count int, p burstPolicy, limitReason string) error {
+	if !p.enabled() {
+		return &QuotaExceededError{Reason: limitReason, Limit: QuotaLimitUser}
+	}
+	if count >= p.burst {
+		return &QuotaExceededError{
+			Reason: fmt.Sprintf("user %s has %d active sessions (burst max %d)", userID, count, p.burst),
+			Limit:  QuotaLimitUser,
+		}
+	}
+	b, err := m.settleBurst(userID, count, p)
+	if err != nil {
+		return err
+	}
+	if b.DebtSeconds >= int64(p.window/time.Second) {
+		return &QuotaExceededError{
+			Reason: fmt.Sprintf("user %s has used their %s burst allowance above %d sessions", userID, p.window, p.soft),
+			Limit:  QuotaLimitUser,
+		}
+	}
+	return nil
+}
+
+// recordBurstUsage settles a user's burst debt after their session count
+// changes, so that debt accrues only while they are above the soft limit.
+func (m *Manager) recordBurstUsage(userID string) {
+	p := m.userBurstPolicy()
+	if !p.enabled() {
+		return
+	}
+	count, err := m.db.CountActiveSessionsByUser(userID)
+	if err == nil {
+		_, err = m.settleBurst(userID, count, p)
+	}
+	if err != nil {
+		log.Printf("Warning: failed to record burst usage for user %s: %v", userID, err)
+	}
+}
+
+// recordSessionBurstUsage is recordBurstUsage for the owner of a session.
+func (m *Manager) recordSessionBurstUsage(sessionID string) {
+	if !m.userBurstPolicy().enabled() {
+		return
+	}
+	if session, err := m.db.GetSession(sessionID); err == nil && session != nil {
+		m.recordBurstUsage(session.UserID)
+	}
+}
+
+// burstStatus reports a user's burst allowance without settling it.
+func (m *Manager) burstStatus(userID string, count int) (*BurstStatus, error) {
+	p := m.userBurstPolicy()
+	if !p.enabled() {
+		return nil, nil
+	}
+	b, err := m.db.GetQuotaBurst(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get burst state: %w", err)
+	}
+	debt, _ := burstDebt(b, time.Now(), p)
+	window := int64(p.window / time.Second)
+	return &BurstStatus{
+		MaxSessions:      p.burst,
+		DurationSeconds:  window,
+		DebtSeconds:      debt,
+		RemainingSeconds: window - debt,
+		Bursting:         count > p.soft,
+		Available:        count < p.burst && debt < window,
+	}, nil
+}
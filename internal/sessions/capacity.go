@@ -0,0 +1,54 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/rjsadow/sortie/internal/runner"
+)
+
+// checkCapacity asks runners that can tell whether the cluster has room
+// for the workload before it is created, so a session that cannot be
+// scheduled is turned away with the reason rather than failing once its
+// workload is pending. A workload that would fit once other sessions end
+// waits in the session queue, if there is one; one that can never fit is
+// rejected. If capacity cannot be checked the launch goes ahead. tenantID
+// is the tenant the session is launched in.
+func (m *Manager) checkCapacity(ctx context.Context, wc *runner.WorkloadConfig, tenantID string) error {
+	checker, ok := m.runner.(runner.CapacityChecker)
+	if !ok {
+		return nil
+	}
+
+	err := checker.CheckCapacity(ctx, wc)
+	var capErr *runner.CapacityError
+	if !errors.As(err, &capErr) {
+		if err != nil {
+			log.Printf("Warning: failed to check cluster capacity for session %s: %v", wc.SessionID, err)
+		}
+		return nil
+	}
+	if !capErr.Temporary || m.queue == nil {
+		return capErr
+	}
+
+	log.Printf("No cluster capacity for session %s, queueing: %s", wc.SessionID, capErr.Reason)
+	err = m.queue.EnqueueUntil(ctx, tenantID, func() bool {
+		var stillShort *runner.CapacityError
+		return !errors.As(checker.CheckCapacity(ctx, wc), &stillShort)
+	})
+	var timeout *QueueTimeoutError
+	if errors.As(err, &timeout) {
+		// The reason the session waited says more than the timeout
+		return capErr
+	}
+	return err
+}
+
+// launchRejected reports whether a checkCapacity error is the cluster
+// turning the launch away, which counts as a failed launch.
+func launchRejected(err error) bool {
+	var capErr *runner.CapacityError
+	return errors.As(err, &capErr)
+}
@@ -0,0 +1,98 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/runner"
+)
+
+func TestCreateSession_RejectedWithoutCapacity(t *testing.T) {
+	database := newTestDB(t)
+	mockRunner := runner.NewMockRunner()
+	mockRunner.ReadyDelay = 0
+	mockRunner.SetCapacity(&runner.CapacityError{Reason: "no node can fit the session's requests (cpu=64)"})
+	m := NewManagerWithConfig(database, ManagerConfig{
+		Runner:            mockRunner,
+		MaxGlobalSessions: 10,
+		QueueMaxSize:      5,
+		QueueTimeout:      10 * time.Second,
+	})
+	seedContainerApp(t, database, "app1", "App", "test:latest")
+
+	// A session that can never fit is rejected at once, even with a queue
+	_, err := m.CreateSession(context.Background(), &CreateSessionRequest{AppID: "app1", UserID: "user1"})
+	var capErr *runner.CapacityError
+	if !errors.As(err, &capErr) || capErr.Temporary {
+		t.Fatalf("CreateSession() error = %v, want a permanent capacity error", err)
+	}
+	if mockRunner.WorkloadCount() != 0 {
+		t.Errorf("created %d workloads, want none", mockRunner.WorkloadCount())
+	}
+
+	launches, err := database.ListSessionLaunches("app1", time.Now().Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatalf("ListSessionLaunches() error = %v", err)
+	}
+	if len(launches) != 1 || launches[0].Succeeded || launches[0].Reason != capErr.Error() {
+		t.Errorf("launches = %+v, want one failed for lack of capacity", launches)
+	}
+}
+
+func TestCreateSession_QueuedUntilCapacity(t *testing.T) {
+	database := newTestDB(t)
+	mockRunner := runner.NewMockRunner()
+	mockRunner.ReadyDelay = 0
+	mockRunner.SetCapacity(&runner.CapacityError{Reason: "ResourceQuota sessions has pods=0 of 10 left", Temporary: true})
+	m := NewManagerWithConfig(database, ManagerConfig{
+		Runner:            mockRunner,
+		MaxGlobalSessions: 10,
+		QueueMaxSize:      5,
+		QueueTimeout:      10 * time.Second,
+		QueuePollInterval: 20 * time.Millisecond,
+	})
+	defer m.Stop()
+	seedContainerApp(t, database, "app1", "App", "test:latest")
+
+	created := make(chan error, 1)
+	go func() {
+		_, err := m.CreateSession(context.Background(), &CreateSessionRequest{AppID: "app1", UserID: "user1"})
+		created <- err
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for m.Queue().Len() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if m.Queue().Len() != 1 {
+		t.Fatal("session was not queued for capacity")
+	}
+
+	mockRunner.SetCapacity(nil)
+	select {
+	case err := <-created:
+		if err != nil {
+			t.Fatalf("CreateSession() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("CreateSession() did not proceed once there was capacity")
+	}
+	if mockRunner.WorkloadCount() != 1 {
+		t.Errorf("created %d workloads, want 1", mockRunner.WorkloadCount())
+	}
+}
+
+func TestCreateSession_NoQueueRejectsTemporaryShortfall(t *testing.T) {
+	database := newTestDB(t)
+	mockRunner := runner.NewMockRunner()
+	mockRunner.SetCapacity(&runner.CapacityError{Reason: "the nodes large enough for the session are cordoned or not ready", Temporary: true})
+	m := NewManagerWithConfig(database, ManagerConfig{Runner: mockRunner})
+	seedContainerApp(t, database, "app1", "App", "test:latest")
+
+	_, err := m.CreateSession(context.Background(), &CreateSessionRequest{AppID: "app1", UserID: "user1"})
+	var capErr *runner.CapacityError
+	if !errors.As(err, &capErr) || !capErr.Temporary {
+		t.Fatalf("CreateSession() error = %v, want a temporary capacity error", err)
+	}
+}
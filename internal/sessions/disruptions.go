@@ -0,0 +1,219 @@
+package sessions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/runner"
+)
+
+const (
+	// defaultRescheduleBackoff is the wait before the first retry of a
+	// replacement workload; later retries wait longer.
+	defaultRescheduleBackoff = 5 * time.Second
+
+	// defaultDeletionGrace is how long a deleted workload's session is
+	// given to end before the deletion is taken as a disruption. The
+	// manager deletes the workloads of sessions it ends before it records
+	// the end.
+	defaultDeletionGrace = 10 * time.Second
+)
+
+// watchDisruptions reschedules the sessions whose workloads the runner
+// reports disrupted until the manager stops.
+func (m *Manager) watchDisruptions() {
+	watcher, ok := m.runner.(runner.DisruptionWatcher)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-m.stopCh
+		cancel()
+	}()
+	if err := watcher.WatchDisruptions(ctx, m.handleDisruption); err != nil {
+		log.Printf("Error watching workload disruptions: %v", err)
+	}
+}
+
+// handleDisruption moves a running session whose workload was disrupted to
+// a new workload in the background. It is called on the watch's goroutine.
+func (m *Manager) handleDisruption(d runner.WorkloadDisruption) {
+	if err := m.beginLaunch(); err != nil {
+		// Shutting down; other replicas see the same disruption
+		return
+	}
+	go func() {
+		defer m.launches.Done()
+		m.rescheduleDisrupted(d)
+	}()
+}
+
+// rescheduleDisrupted claims the session running the disrupted workload
+// and reschedules it. A deletion is only acted on once the grace period
+// shows it was not the manager ending the session, and the workload has
+// not been replaced. The claim succeeds once per disruption, so with
+// several replicas only one reschedules the session.
+func (m *Manager) rescheduleDisrupted(d runner.WorkloadDisruption) {
+	reason := d.Reason
+	if d.Deleted {
+		// Shutdown does not wait out the grace period; other replicas see
+		// the same deletion
+		select {
+		case <-time.After(m.deletionGrace):
+		case <-m.stopCh:
+			return
+		}
+		// The session is read before the workloads are listed, so a
+		// replacement that finishes in between is seen
+		session, err := m.db.GetRunningSessionByPodName(d.Name)
+		if err != nil || session == nil || session.Substatus == db.SubstatusRescheduling {
+			return
+		}
+		workloads, err := m.runner.ListWorkloads(m.launchCtx)
+		if err != nil {
+			log.Printf("Warning: failed to list workloads after %s was deleted: %v", d.Name, err)
+			return
+		}
+		if slices.ContainsFunc(workloads, func(w runner.WorkloadInfo) bool { return w.Name == d.Name }) {
+			return
+		}
+		if reason == "" {
+			reason = "workload deleted"
+		}
+	}
+
+	session, err := m.db.ClaimSessionDisruption(d.Name, reason)
+	if err != nil {
+		log.Printf("Warning: failed to record disruption of workload %s: %v", d.Name, err)
+		return
+	}
+	if session == nil {
+		return
+	}
+	log.Printf("Session %s workload %s was disrupted (%s), rescheduling", session.ID, d.Name, reason)
+	m.emitEvent(context.Background(), EventSessionReconnecting, session, reason)
+
+	if err := m.reschedule(session, d.Name); err != nil {
+		failure := fmt.Sprintf("workload disrupted (%s) and could not be rescheduled: %v", reason, err)
+		if err := m.terminateWithStatus(context.Background(), session.ID, db.SessionStatusFailed, failure); err != nil {
+			log.Printf("Error failing disrupted session %s: %v", session.ID, err)
+		}
+	}
+}
+
+// reschedule replaces a claimed session's disrupted workload with a new
+// one built from its app, keeping its ID and volumes. A replacement that
+// fails is retried with backoff up to maxReschedules times in all.
+func (m *Manager) reschedule(session *db.Session, oldWorkload string) error {
+	ctx := m.launchCtx
+	if err := m.removeWorkload(ctx, oldWorkload); err != nil {
+		return fmt.Errorf("old workload was not removed: %w", err)
+	}
+	if m.maxReschedules <= 0 {
+		return errors.New("rescheduling is disabled")
+	}
+
+	app, err := m.db.GetApp(session.AppID)
+	if err != nil {
+		return fmt.Errorf("failed to get application: %w", err)
+	}
+	if app == nil {
+		return fmt.Errorf("application not found: %s", session.AppID)
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= m.maxReschedules; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-time.After(m.rescheduleBackoff * time.Duration(attempt-1)):
+			case <-ctx.Done():
+				return context.Cause(ctx)
+			}
+		}
+		if current, err := m.db.GetSession(session.ID); err == nil &&
+			(current == nil || current.Status != db.SessionStatusRunning || current.Substatus != db.SubstatusRescheduling) {
+			// The session ended while it was being rescheduled
+			return nil
+		}
+		if lastErr = m.replaceWorkload(ctx, session, app); lastErr == nil || errors.Is(lastErr, sql.ErrNoRows) {
+			return nil
+		}
+		log.Printf("Rescheduling session %s failed (attempt %d of %d): %v", session.ID, attempt, m.maxReschedules, lastErr)
+	}
+	return lastErr
+}
+
+// replaceWorkload creates a session's new workload and waits for it to be
+// ready. It returns sql.ErrNoRows if the session stopped rescheduling
+// meanwhile, having removed the new workload.
+func (m *Manager) replaceWorkload(ctx context.Context, session *db.Session, app *db.Application) error {
+	wc, _, err := m.sessionWorkload(ctx, session, app)
+	if err != nil {
+		return err
+	}
+	if err := m.checkCapacity(ctx, wc, app.TenantID); err != nil {
+		return err
+	}
+	result, err := m.runner.CreateWorkload(ctx, wc)
+	if err != nil {
+		return fmt.Errorf("failed to create workload: %w", err)
+	}
+
+	ip, err := m.waitForReplacement(ctx, result.Name)
+	if err == nil {
+		err = m.db.FinishSessionReschedule(session.ID, result.Name, ip, result.SidecarImage)
+	}
+	if err != nil {
+		if rmErr := m.removeWorkload(context.Background(), result.Name); rmErr != nil {
+			log.Printf("Warning: failed to remove workload %s: %v", result.Name, rmErr)
+		}
+		return err
+	}
+
+	session.PodName = result.Name
+	session.PodIP = ip
+	m.routeSession(ctx, m.db, session, app)
+	log.Printf("Session %s rescheduled to workload %s", session.ID, result.Name)
+	m.emitEventByID(session.ID, EventSessionReady, "workload rescheduled")
+	return nil
+}
+
+// waitForReplacement waits for a replacement workload to be ready and
+// returns its IP.
+func (m *Manager) waitForReplacement(ctx context.Context, name string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.podReadyTimeout)
+	defer cancel()
+	if err := m.runner.WaitForReady(ctx, name, m.podReadyTimeout); err != nil {
+		return "", fmt.Errorf("workload failed to become ready: %w", err)
+	}
+	ip, err := m.runner.GetIP(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to get workload IP: %w", err)
+	}
+	return ip, nil
+}
+
+// removeWorkload deletes a workload and waits until it is gone, so its
+// replacement can take its name.
+func (m *Manager) removeWorkload(ctx context.Context, name string) error {
+	if err := m.runner.DeleteWorkload(ctx, name); err != nil {
+		// An evicted workload may be gone already
+		log.Printf("Deleting workload %s: %v", name, err)
+	}
+	watcher, ok := m.runner.(runner.DisruptionWatcher)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, m.podReadyTimeout)
+	defer cancel()
+	return watcher.WaitForDeleted(ctx, name)
+}
@@ -0,0 +1,131 @@
+package sessions
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/runner"
+)
+
+// newDisruptionTestManager starts a manager over a mock runner with a
+// running session of a seeded app.
+func newDisruptionTestManager(t *testing.T, maxReschedules int) (*Manager, *runner.MockRunner, *mockRecorder, *db.Session) {
+	t.Helper()
+	database := newTestDB(t)
+	mockRunner := runner.NewMockRunner()
+	mockRunner.ReadyDelay = 0
+	recorder := &mockRecorder{}
+	m := NewManagerWithConfig(database, ManagerConfig{
+		Runner:         mockRunner,
+		Recorder:       recorder,
+		MaxReschedules: maxReschedules,
+	})
+	// The grace period keeps an eviction's deletion from being handled
+	// before the eviction itself
+	m.rescheduleBackoff = 0
+	m.deletionGrace = 50 * time.Millisecond
+	m.Start()
+	t.Cleanup(m.Stop)
+	seedContainerApp(t, database, "app1", "App", "test:latest")
+
+	session, err := m.CreateSession(context.Background(), &CreateSessionRequest{AppID: "app1", UserID: "user1"})
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	waitForStatus(t, m, session.ID, db.SessionStatusRunning)
+	session, _ = m.GetSession(context.Background(), session.ID)
+	return m, mockRunner, recorder, session
+}
+
+func hasEvent(recorder *mockRecorder, event SessionEvent) bool {
+	for _, e := range recorder.getEvents() {
+		if e.Event == event {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDisruptions_EvictedSessionIsRescheduled(t *testing.T) {
+	m, mockRunner, recorder, session := newDisruptionTestManager(t, 3)
+
+	// The watch starts in the background, so evict until it is seen
+	deadline := time.Now().Add(5 * time.Second)
+	var got *db.Session
+	for {
+		mockRunner.Evict(session.PodName, "EvictionByEvictionAPI: Eviction API: evicting")
+		time.Sleep(20 * time.Millisecond)
+		got, _ = m.GetSession(context.Background(), session.ID)
+		if got.RescheduleCount > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("eviction was not handled")
+		}
+	}
+
+	deadline = time.Now().Add(5 * time.Second)
+	for got.Substatus == db.SubstatusRescheduling && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		got, _ = m.GetSession(context.Background(), session.ID)
+	}
+	if got.Status != db.SessionStatusRunning || got.Substatus != "" || got.PodIP == "" || got.PodIP == session.PodIP {
+		t.Errorf("after rescheduling session is %s/%q at %q, want running at a new IP", got.Status, got.Substatus, got.PodIP)
+	}
+	if got.RescheduleCount != 1 || got.PodName != session.PodName {
+		t.Errorf("session = %d reschedules on %s, want 1 on %s", got.RescheduleCount, got.PodName, session.PodName)
+	}
+	if mockRunner.Workload(session.PodName) == nil {
+		t.Error("replacement workload was not created")
+	}
+	if !hasEvent(recorder, EventSessionReconnecting) {
+		t.Error("no reconnecting event was emitted")
+	}
+}
+
+func TestDisruptions_FailsWhenRetriesExhausted(t *testing.T) {
+	m, mockRunner, recorder, session := newDisruptionTestManager(t, 2)
+	mockRunner.SetCapacity(&runner.CapacityError{Reason: "no node has room"})
+
+	m.rescheduleDisrupted(runner.WorkloadDisruption{Name: session.PodName, Reason: "Preempting"})
+
+	got, _ := m.GetSession(context.Background(), session.ID)
+	if got.Status != db.SessionStatusFailed || got.RescheduleCount != 1 {
+		t.Errorf("session is %s with %d reschedules, want failed with 1", got.Status, got.RescheduleCount)
+	}
+	if !hasEvent(recorder, EventSessionReconnecting) || !hasEvent(recorder, EventSessionFailed) {
+		t.Errorf("events = %+v, want reconnecting then failed", recorder.getEvents())
+	}
+}
+
+func TestDisruptions_FailsAtOnceWithoutReschedules(t *testing.T) {
+	m, _, _, session := newDisruptionTestManager(t, 0)
+
+	m.rescheduleDisrupted(runner.WorkloadDisruption{Name: session.PodName, Reason: "NodeLost"})
+
+	if got, _ := m.GetSession(context.Background(), session.ID); got.Status != db.SessionStatusFailed {
+		t.Errorf("session is %s, want failed", got.Status)
+	}
+}
+
+func TestDisruptions_IgnoresDeletionsOfEndedOrReplacedWorkloads(t *testing.T) {
+	m, _, _, session := newDisruptionTestManager(t, 3)
+
+	// The workload still exists, so its deletion was followed by a
+	// replacement
+	m.rescheduleDisrupted(runner.WorkloadDisruption{Name: session.PodName, Deleted: true})
+	if got, _ := m.GetSession(context.Background(), session.ID); got.RescheduleCount != 0 {
+		t.Errorf("replaced workload's session was rescheduled %d times", got.RescheduleCount)
+	}
+
+	if err := m.TerminateSession(context.Background(), session.ID); err != nil {
+		t.Fatalf("TerminateSession() error = %v", err)
+	}
+	m.rescheduleDisrupted(runner.WorkloadDisruption{Name: session.PodName, Deleted: true})
+	got, _ := m.GetSession(context.Background(), session.ID)
+	if got.Status != db.SessionStatusStopped || got.RescheduleCount != 0 {
+		t.Errorf("terminated session is %s with %d reschedules, want stopped with none", got.Status, got.RescheduleCount)
+	}
+}
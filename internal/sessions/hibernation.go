@@ -0,0 +1,106 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/runner"
+)
+
+// ErrSessionVolumesUnsupported is returned when launching an app that
+// hibernates on idle on a runner that cannot keep workspace volumes.
+var ErrSessionVolumesUnsupported = errors.New("runner does not support session workspace volumes")
+
+// orphanedVolumeGrace is how old a workspace volume without a session must
+// be before it is deleted. Volumes are created just before their session
+// is stored.
+const orphanedVolumeGrace = 10 * time.Minute
+
+// attachWorkspaceVolume keeps the workload's workspace on the session's
+// own volume when the app hibernates on idle, so the files survive
+// hibernation. A resumed or restarted session gets its volume back.
+func (m *Manager) attachWorkspaceVolume(ctx context.Context, wc *runner.WorkloadConfig, app *db.Application) error {
+	if !app.HibernateOnIdle {
+		return nil
+	}
+	svr, ok := m.runner.(runner.SessionVolumeRunner)
+	if !ok {
+		return fmt.Errorf("application %s hibernates on idle: %w", app.ID, ErrSessionVolumesUnsupported)
+	}
+	name, err := svr.EnsureSessionVolume(ctx, wc.SessionID, app.ID)
+	if err != nil {
+		return fmt.Errorf("failed to prepare workspace volume: %w", err)
+	}
+	wc.WorkspaceVolume = name
+	return nil
+}
+
+// deleteWorkspaceVolume deletes a session's workspace volume, if the
+// runner keeps them. Failures are logged; cleanup retries later.
+func (m *Manager) deleteWorkspaceVolume(ctx context.Context, sessionID string) {
+	svr, ok := m.runner.(runner.SessionVolumeRunner)
+	if !ok {
+		return
+	}
+	if err := svr.DeleteSessionVolume(ctx, sessionID); err != nil {
+		log.Printf("Warning: failed to delete workspace volume of session %s: %v", sessionID, err)
+	}
+}
+
+// HibernateSession deletes an idle session's workload but keeps the session
+// and its workspace volume, so it can be resumed where it was left.
+func (m *Manager) HibernateSession(ctx context.Context, sessionID string) error {
+	return m.stopWithStatus(ctx, sessionID, db.SessionStatusHibernated, "idle timeout", EventSessionHibernated)
+}
+
+// ResumeSession recreates the workload of a hibernated session, with its
+// workspace volume.
+func (m *Manager) ResumeSession(ctx context.Context, sessionID string) (*db.Session, error) {
+	session, err := m.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	if session.Status != db.SessionStatusHibernated {
+		return nil, fmt.Errorf("session must be hibernated to resume (current status: %s)", session.Status)
+	}
+	return m.relaunch(ctx, session, "user resumed", EventSessionResumed)
+}
+
+// cleanupWorkspaceVolumes deletes the workspace volumes of sessions that
+// can no longer be resumed or restarted: those that failed, expired or
+// are gone.
+func (m *Manager) cleanupWorkspaceVolumes(ctx context.Context) error {
+	svr, ok := m.runner.(runner.SessionVolumeRunner)
+	if !ok {
+		return nil
+	}
+	volumes, err := svr.ListSessionVolumes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list workspace volumes: %w", err)
+	}
+
+	for _, v := range volumes {
+		session, err := m.db.GetSession(v.SessionID)
+		if err != nil {
+			log.Printf("Error getting session %s for its workspace volume: %v", v.SessionID, err)
+			continue
+		}
+		switch {
+		case session == nil && time.Since(v.CreatedAt) < orphanedVolumeGrace:
+			continue
+		case session != nil && !IsTerminalState(session.Status):
+			continue
+		}
+		log.Printf("Deleting workspace volume of ended session %s", v.SessionID)
+		m.deleteWorkspaceVolume(ctx, v.SessionID)
+	}
+	return nil
+}
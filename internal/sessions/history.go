@@ -0,0 +1,129 @@
+package sessions
+
+import (
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rjsadow/sortie/internal/db"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// recordHistory stores a summary of a session run that just ended. session
+// must be read before its final status is written, while UpdatedAt is still
+// when the run became ready (or was requested, if it never did). Failures
+// are logged rather than returned so that they never affect the session.
+func (m *Manager) recordHistory(session *db.Session, finalStatus db.SessionStatus, reason string) {
+	endedAt := time.Now()
+	h := db.SessionHistory{
+		ID:              uuid.New().String(),
+		SessionID:       session.ID,
+		TenantID:        session.TenantID,
+		UserID:          session.UserID,
+		AppID:           session.AppID,
+		StartedAt:       session.UpdatedAt,
+		EndedAt:         endedAt,
+		DurationSeconds: max(0, int64(endedAt.Sub(session.UpdatedAt)/time.Second)),
+		FinalStatus:     finalStatus,
+		ExitReason:      reason,
+		ProjectID:       session.ProjectID,
+	}
+
+	if user, err := m.db.GetUserByID(session.UserID); err == nil && user != nil {
+		h.Username = user.Username
+	}
+	if app, err := m.db.GetApp(session.AppID); err == nil && app != nil {
+		h.AppName = app.Name
+		wc := m.buildWorkloadConfig(session.ID, app)
+		m.applyDefaultResourceLimits(wc, app)
+		h.CPURequest = wc.CPURequest
+		h.CPULimit = wc.CPULimit
+		h.MemoryRequest = wc.MemoryRequest
+		h.MemoryLimit = wc.MemoryLimit
+		h.CPUCoreSeconds = resourceSeconds(wc.CPURequest, wc.CPULimit, h.DurationSeconds, 1)
+		h.MemoryGiBSeconds = resourceSeconds(wc.MemoryRequest, wc.MemoryLimit, h.DurationSeconds, 1<<30)
+	}
+
+	if err := m.db.CreateSessionHistory(h); err != nil {
+		log.Printf("Warning: failed to record history for session %s: %v", session.ID, err)
+	}
+}
+
+// resourceSeconds returns a resource requested for a run, in units of
+// unit, multiplied by the run's duration. A resource with no request is
+// requested at its limit, as Kubernetes does; one with neither, or that
+// cannot be parsed, counts as zero.
+func resourceSeconds(request, limit string, seconds int64, unit float64) float64 {
+	if request == "" {
+		request = limit
+	}
+	if request == "" {
+		return 0
+	}
+	q, err := resource.ParseQuantity(request)
+	if err != nil {
+		log.Printf("Warning: failed to parse resource quantity %q: %v", request, err)
+		return 0
+	}
+	return q.AsApproximateFloat64() / unit * float64(seconds)
+}
+
+// recordHistoryByID is recordHistory for a session that has only its ID
+// at hand.
+func (m *Manager) recordHistoryByID(sessionID string, finalStatus db.SessionStatus, reason string) {
+	session, err := m.db.GetSession(sessionID)
+	if err != nil {
+		log.Printf("Warning: failed to record history for session %s: %v", sessionID, err)
+		return
+	}
+	if session == nil {
+		return
+	}
+	m.recordHistory(session, finalStatus, reason)
+}
+
+// recordLaunch stores how a launch of the session went, for availability
+// objectives. It must be called before the session leaves the creating
+// state, while UpdatedAt is still when the launch was requested. Failures
+// are logged like those of recordHistory.
+func (m *Manager) recordLaunch(sessionID string, launchErr error) {
+	session, err := m.db.GetSession(sessionID)
+	if err != nil || session == nil {
+		log.Printf("Warning: failed to record launch of session %s: %v", sessionID, err)
+		return
+	}
+	finishedAt := time.Now()
+	l := db.SessionLaunch{
+		SessionID:   session.ID,
+		TenantID:    session.TenantID,
+		AppID:       session.AppID,
+		RequestedAt: session.UpdatedAt,
+		FinishedAt:  finishedAt,
+		Succeeded:   launchErr == nil,
+	}
+	if launchErr != nil {
+		l.Reason = launchErr.Error()
+	} else {
+		l.StartSeconds = max(0, finishedAt.Sub(session.UpdatedAt).Seconds())
+	}
+	if err := m.db.CreateSessionLaunch(l); err != nil {
+		log.Printf("Warning: failed to record launch of session %s: %v", sessionID, err)
+	}
+}
+
+// recordFailedLaunch stores a launch that failed before the session was
+// stored or updated, when creating its workload.
+func (m *Manager) recordFailedLaunch(sessionID string, app *db.Application, launchErr error) {
+	now := time.Now()
+	l := db.SessionLaunch{
+		SessionID:   sessionID,
+		TenantID:    app.TenantID,
+		AppID:       app.ID,
+		RequestedAt: now,
+		FinishedAt:  now,
+		Reason:      launchErr.Error(),
+	}
+	if err := m.db.CreateSessionLaunch(l); err != nil {
+		log.Printf("Warning: failed to record launch of session %s: %v", sessionID, err)
+	}
+}
@@ -0,0 +1,113 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/runner"
+)
+
+var (
+	// ErrUserVolumesUnsupported is returned when the runner cannot keep
+	// user volumes.
+	ErrUserVolumesUnsupported = errors.New("runner does not support user volumes")
+
+	// ErrUserVolumeNotFound is returned when no user volume has the name.
+	ErrUserVolumeNotFound = errors.New("user volume not found")
+
+	// ErrUserVolumeInUse is returned when deleting a volume mounted by an
+	// active session.
+	ErrUserVolumeInUse = errors.New("user volume is in use by an active session")
+)
+
+// attachHomeVolume mounts the user's home volume for the app in the
+// workload, creating the volume on the user's first launch.
+func (m *Manager) attachHomeVolume(ctx context.Context, wc *runner.WorkloadConfig, app *db.Application, userID string) error {
+	if app.HomeVolume == nil {
+		return nil
+	}
+	uvr, ok := m.runner.(runner.UserVolumeRunner)
+	if !ok {
+		return fmt.Errorf("application %s has a home volume: %w", app.ID, ErrUserVolumesUnsupported)
+	}
+	name, err := uvr.EnsureUserVolume(ctx, userID, app.ID, app.HomeVolume)
+	if err != nil {
+		return fmt.Errorf("failed to prepare home volume: %w", err)
+	}
+	wc.HomeVolume = &runner.HomeVolumeMount{
+		Name:      name,
+		MountPath: app.HomeVolume.MountPath,
+		FSGroup:   app.HomeVolume.FSGroup,
+	}
+	return nil
+}
+
+// ListUserVolumes returns the home volumes of every user, optionally only
+// those of one user or app, ordered by user and app.
+func (m *Manager) ListUserVolumes(ctx context.Context, userID, appID string) ([]runner.UserVolume, error) {
+	uvr, ok := m.runner.(runner.UserVolumeRunner)
+	if !ok {
+		return nil, ErrUserVolumesUnsupported
+	}
+	all, err := uvr.ListUserVolumes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := []runner.UserVolume{}
+	for _, v := range all {
+		if (userID == "" || v.UserID == userID) && (appID == "" || v.AppID == appID) {
+			volumes = append(volumes, v)
+		}
+	}
+	sort.Slice(volumes, func(i, j int) bool {
+		if volumes[i].UserID != volumes[j].UserID {
+			return volumes[i].UserID < volumes[j].UserID
+		}
+		return volumes[i].AppID < volumes[j].AppID
+	})
+	return volumes, nil
+}
+
+// DeleteUserVolume deletes a user's home volume and the files on it. A
+// volume cannot be deleted while its user has a creating or running
+// session of its app; a stopped session gets a new, empty volume when
+// restarted.
+func (m *Manager) DeleteUserVolume(ctx context.Context, name string) (*runner.UserVolume, error) {
+	uvr, ok := m.runner.(runner.UserVolumeRunner)
+	if !ok {
+		return nil, ErrUserVolumesUnsupported
+	}
+	all, err := uvr.ListUserVolumes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var vol *runner.UserVolume
+	for i := range all {
+		if all[i].Name == name {
+			vol = &all[i]
+			break
+		}
+	}
+	if vol == nil {
+		return nil, ErrUserVolumeNotFound
+	}
+
+	sessions, err := m.db.ListSessionsByUser(vol.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user sessions: %w", err)
+	}
+	for _, s := range sessions {
+		if s.AppID == vol.AppID && (s.Status == db.SessionStatusCreating || s.Status == db.SessionStatusRunning) {
+			return nil, ErrUserVolumeInUse
+		}
+	}
+
+	if err := uvr.DeleteUserVolume(ctx, name); err != nil {
+		return nil, err
+	}
+	return vol, nil
+}
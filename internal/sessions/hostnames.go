@@ -0,0 +1,84 @@
+package sessions
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/runner"
+)
+
+// SessionDomain returns the domain web_proxy sessions get their own
+// subdomains of, or "" if session hostnames are disabled.
+func (m *Manager) SessionDomain() string {
+	return m.sessionDomain
+}
+
+// routeSession gives a web_proxy session a hostname under the session
+// domain, such as jupyter-alice.sortie.example.com, and asks the runner to
+// route it to the session. It does nothing unless session hostnames are
+// enabled and the runner supports them. Failures are logged rather than
+// returned: the session is still reachable through the server's proxy path.
+func (m *Manager) routeSession(ctx context.Context, store *db.DB, session *db.Session, app *db.Application) {
+	if m.sessionDomain == "" || app.LaunchType != db.LaunchTypeWebProxy {
+		return
+	}
+	rr, ok := m.runner.(runner.RouteRunner)
+	if !ok {
+		return
+	}
+
+	username := session.UserID
+	if user, err := store.GetUserByID(session.UserID); err == nil && user != nil {
+		username = user.Username
+	}
+	hostname, err := store.AllocateSessionHostname(session.ID, hostnameLabel(app.ID+"-"+username), m.sessionDomain)
+	if err != nil {
+		log.Printf("Warning: failed to allocate hostname for session %s: %v", session.ID, err)
+		return
+	}
+	if err := rr.CreateSessionRoute(ctx, session.ID, app.ID, hostname, app.ContainerPort); err != nil {
+		log.Printf("Warning: failed to route %s to session %s: %v", hostname, session.ID, err)
+		return
+	}
+	session.Hostname = hostname
+}
+
+// unrouteSession removes a session's route, if the runner supports them.
+// The session keeps its hostname, so a restart routes the same one.
+func (m *Manager) unrouteSession(ctx context.Context, sessionID string) {
+	if m.sessionDomain == "" {
+		return
+	}
+	if rr, ok := m.runner.(runner.RouteRunner); ok {
+		if err := rr.DeleteSessionRoute(ctx, sessionID); err != nil {
+			log.Printf("Warning: failed to delete route for session %s: %v", sessionID, err)
+		}
+	}
+}
+
+// hostnameLabel turns s into a DNS label: lowercase letters, digits and
+// single hyphens, at most 63 characters.
+func hostnameLabel(s string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			hyphen = false
+		} else if !hyphen && b.Len() > 0 {
+			b.WriteByte('-')
+			hyphen = true
+		}
+	}
+	label := b.String()
+	if len(label) > 63 {
+		label = label[:63]
+	}
+	label = strings.TrimRight(label, "-")
+	if label == "" {
+		return "session"
+	}
+	return label
+}
@@ -2,14 +2,25 @@ package sessions
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/rjsadow/sortie/internal/attestation"
+	"github.com/rjsadow/sortie/internal/chaos"
 	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/jobs"
+	"github.com/rjsadow/sortie/internal/leader"
+	"github.com/rjsadow/sortie/internal/plugins"
 	"github.com/rjsadow/sortie/internal/runner"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -22,14 +33,25 @@ const (
 	// DefaultPodReadyTimeout is the default timeout for waiting for pod ready.
 	// Set to 5 minutes to accommodate large container image pulls.
 	DefaultPodReadyTimeout = 5 * time.Minute
+
+	// CleanupJobName names the session cleanup job when it runs under a
+	// jobs.Scheduler
+	CleanupJobName = "session-cleanup"
 )
 
+// tracer records spans for session operations.
+var tracer = otel.Tracer("github.com/rjsadow/sortie/internal/sessions")
+
 // ManagerConfig holds configuration for the session manager.
 type ManagerConfig struct {
 	SessionTimeout  time.Duration
 	CleanupInterval time.Duration
 	PodReadyTimeout time.Duration
 
+	// SessionRetention is how long ended sessions are kept before they are
+	// moved to the session archive (0 = keep forever)
+	SessionRetention time.Duration
+
 	// Resource quota settings
 	MaxSessionsPerUser int    // Max concurrent sessions per user (0 = unlimited)
 	MaxGlobalSessions  int    // Max concurrent sessions globally (0 = unlimited)
@@ -38,6 +60,10 @@ type ManagerConfig struct {
 	DefaultMemRequest  string // Default memory request for new sessions
 	DefaultMemLimit    string // Default memory limit for new sessions
 
+	// Burst allowance above MaxSessionsPerUser (0 = hard limit)
+	MaxSessionsPerUserBurst int           // Max concurrent sessions per user while bursting
+	SessionBurstDuration    time.Duration // Total time a user may spend above MaxSessionsPerUser
+
 	// Session recording
 	Recorder SessionRecorder // Optional recorder for lifecycle events (nil = noop)
 
@@ -48,6 +74,40 @@ type ManagerConfig struct {
 
 	// Runner is the workload orchestration backend (nil = noop/tests only)
 	Runner runner.Runner
+
+	// SidecarInjectors add extra containers to session workloads, in order
+	SidecarInjectors []plugins.SidecarInjector
+
+	// Attestor signs session environment reports for apps that request
+	// them (nil = no reports)
+	Attestor *attestation.Signer
+
+	// Leader decides whether this replica runs the cleanup loop
+	// (nil = always)
+	Leader leader.Checker
+
+	// SessionDomain gives each web_proxy session its own subdomain of it,
+	// routed by runners that implement runner.RouteRunner (empty = off)
+	SessionDomain string
+
+	// WarmPoolInterval is how often apps' warm pools are topped up, with
+	// runners that implement runner.WarmPoolRunner (0 = default)
+	WarmPoolInterval time.Duration
+
+	// Scheduler runs session cleanup as the "session-cleanup" job instead
+	// of the manager's own loop (nil = own loop)
+	Scheduler *jobs.Scheduler
+
+	// MaxRestarts fails running sessions whose workload containers have
+	// restarted this often, with runners that implement
+	// runner.RestartWatcher (0 = never)
+	MaxRestarts int
+
+	// MaxReschedules is how many replacement workloads are tried for a
+	// running session whose workload was evicted, with runners that
+	// implement runner.DisruptionWatcher, before the session fails
+	// (0 = fail at once)
+	MaxReschedules int
 }
 
 // Manager handles session lifecycle.
@@ -60,6 +120,9 @@ type Manager struct {
 	cleanupInterval time.Duration
 	podReadyTimeout time.Duration
 
+	// Ended session retention
+	sessionRetention time.Duration
+
 	// Resource quota settings
 	maxSessionsPerUser int
 	maxGlobalSessions  int
@@ -68,13 +131,58 @@ type Manager struct {
 	defaultMemRequest  string
 	defaultMemLimit    string
 
+	// Burst allowance above the per-user limit
+	maxSessionsPerUserBurst int
+	sessionBurstDuration    time.Duration
+
 	// Session recording
 	recorder SessionRecorder
 
 	// Session queueing
 	queue *SessionQueue
 
+	// Launches turned away by a session limit
+	rejectionsMu    sync.Mutex
+	quotaRejections map[quotaRejection]int64
+
+	// Sidecar injection
+	sidecarInjectors []plugins.SidecarInjector
+
+	// Session attestation
+	attestor *attestation.Signer
+
+	// Leader election for the cleanup loop
+	leader leader.Checker
+
+	// Session hostnames
+	sessionDomain string
+
+	// Warm pools
+	warmPoolInterval time.Duration
+	warmPoolCh       chan struct{}
+
+	// Background job scheduler for cleanup
+	scheduler *jobs.Scheduler
+
+	// Crash loop detection
+	maxRestarts int
+
+	// Rescheduling of evicted workloads
+	maxReschedules    int
+	rescheduleBackoff time.Duration
+	deletionGrace     time.Duration
+
 	stopCh chan struct{}
+
+	// Shutdown: background loops and launches in flight are waited for,
+	// and launches still waiting for their workload when the shutdown
+	// deadline passes are cancelled through launchCtx
+	shutdownMu     sync.Mutex
+	shuttingDown   bool
+	loops          sync.WaitGroup
+	launches       sync.WaitGroup
+	launchCtx      context.Context
+	cancelLaunches context.CancelCauseFunc
 }
 
 // NewManager creates a new session manager with default configuration.
@@ -99,6 +207,12 @@ func NewManagerWithConfig(database *db.DB, cfg ManagerConfig) *Manager {
 	if cfg.PodReadyTimeout == 0 {
 		cfg.PodReadyTimeout = DefaultPodReadyTimeout
 	}
+	if cfg.WarmPoolInterval == 0 {
+		cfg.WarmPoolInterval = DefaultWarmPoolInterval
+	}
+	if cfg.MaxSessionsPerUserBurst > 0 && cfg.SessionBurstDuration == 0 {
+		cfg.SessionBurstDuration = DefaultSessionBurstDuration
+	}
 
 	recorder := cfg.Recorder
 	if recorder == nil {
@@ -106,20 +220,35 @@ func NewManagerWithConfig(database *db.DB, cfg ManagerConfig) *Manager {
 	}
 
 	m := &Manager{
-		db:                 database,
-		runner:             cfg.Runner,
-		sessionTimeout:     cfg.SessionTimeout,
-		cleanupInterval:    cfg.CleanupInterval,
-		podReadyTimeout:    cfg.PodReadyTimeout,
-		maxSessionsPerUser: cfg.MaxSessionsPerUser,
-		maxGlobalSessions:  cfg.MaxGlobalSessions,
-		defaultCPURequest:  cfg.DefaultCPURequest,
-		defaultCPULimit:    cfg.DefaultCPULimit,
-		defaultMemRequest:  cfg.DefaultMemRequest,
-		defaultMemLimit:    cfg.DefaultMemLimit,
-		recorder:           recorder,
-		stopCh:             make(chan struct{}),
-	}
+		db:                      database,
+		runner:                  cfg.Runner,
+		sessionTimeout:          cfg.SessionTimeout,
+		cleanupInterval:         cfg.CleanupInterval,
+		podReadyTimeout:         cfg.PodReadyTimeout,
+		sessionRetention:        cfg.SessionRetention,
+		maxSessionsPerUser:      cfg.MaxSessionsPerUser,
+		maxGlobalSessions:       cfg.MaxGlobalSessions,
+		defaultCPURequest:       cfg.DefaultCPURequest,
+		defaultCPULimit:         cfg.DefaultCPULimit,
+		defaultMemRequest:       cfg.DefaultMemRequest,
+		defaultMemLimit:         cfg.DefaultMemLimit,
+		maxSessionsPerUserBurst: cfg.MaxSessionsPerUserBurst,
+		sessionBurstDuration:    cfg.SessionBurstDuration,
+		recorder:                recorder,
+		sidecarInjectors:        cfg.SidecarInjectors,
+		attestor:                cfg.Attestor,
+		leader:                  cfg.Leader,
+		sessionDomain:           cfg.SessionDomain,
+		warmPoolInterval:        cfg.WarmPoolInterval,
+		scheduler:               cfg.Scheduler,
+		maxRestarts:             cfg.MaxRestarts,
+		maxReschedules:          cfg.MaxReschedules,
+		rescheduleBackoff:       defaultRescheduleBackoff,
+		deletionGrace:           defaultDeletionGrace,
+		warmPoolCh:              make(chan struct{}, 1),
+		stopCh:                  make(chan struct{}),
+	}
+	m.launchCtx, m.cancelLaunches = context.WithCancelCause(context.Background())
 
 	// Initialize session queue if configured
 	if cfg.QueueMaxSize > 0 && cfg.MaxGlobalSessions > 0 {
@@ -163,18 +292,60 @@ func (m *Manager) emitEventByID(sessionID string, event SessionEvent, reason str
 	m.emitEvent(context.Background(), event, session, reason)
 }
 
-// Start begins the background cleanup goroutine
+// Start begins the background cleanup goroutine, or registers the cleanup
+// job with the scheduler, and the warm pool goroutine if the runner
+// supports warm pools.
 func (m *Manager) Start() {
-	go m.cleanupLoop()
+	if m.scheduler != nil {
+		err := m.scheduler.Register(jobs.Job{
+			Name:        CleanupJobName,
+			Description: "Expires stale sessions, stops sessions outside app schedules and archives ended sessions",
+			Interval:    m.cleanupInterval,
+			Run:         m.cleanup,
+		})
+		if err != nil {
+			log.Printf("Error registering session cleanup job, falling back to cleanup loop: %v", err)
+			m.goLoop(m.cleanupLoop)
+		}
+	} else {
+		m.goLoop(m.cleanupLoop)
+	}
+	if _, ok := m.runner.(runner.WarmPoolRunner); ok {
+		m.goLoop(m.warmPoolLoop)
+	}
+	if _, ok := m.runner.(runner.RestartWatcher); ok {
+		m.goLoop(m.watchRestarts)
+	}
+	if _, ok := m.runner.(runner.DisruptionWatcher); ok {
+		m.goLoop(m.watchDisruptions)
+	}
 	log.Printf("Session manager started (timeout: %v, cleanup interval: %v)", m.sessionTimeout, m.cleanupInterval)
 }
 
-// Stop stops the background cleanup goroutine and session queue.
+// Stop shuts the manager down, waiting up to DefaultShutdownTimeout for
+// launches in flight. See Shutdown.
 func (m *Manager) Stop() {
-	close(m.stopCh)
-	if m.queue != nil {
-		m.queue.Stop()
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultShutdownTimeout)
+	defer cancel()
+	m.Shutdown(ctx)
+}
+
+// goLoop runs a background loop that Shutdown waits for.
+func (m *Manager) goLoop(loop func()) {
+	m.loops.Add(1)
+	go func() {
+		defer m.loops.Done()
+		loop()
+	}()
+}
+
+// goWaitForWorkloadReady waits in the background for the workload of a
+// launch registered with beginLaunch, and resolves the launch when done.
+func (m *Manager) goWaitForWorkloadReady(sessionID, workloadName string) {
+	go func() {
+		defer m.launches.Done()
+		m.waitForWorkloadReady(sessionID, workloadName)
+	}()
 }
 
 // Queue returns the session queue (may be nil if queueing is disabled).
@@ -190,15 +361,44 @@ func (m *Manager) cleanupLoop() {
 	for {
 		select {
 		case <-ticker.C:
-			if err := m.cleanupStaleSessions(); err != nil {
-				log.Printf("Error cleaning up stale sessions: %v", err)
-			}
+			m.runCleanup()
 		case <-m.stopCh:
 			return
 		}
 	}
 }
 
+// runCleanup expires or hibernates stale sessions, stops sessions outside
+// their app's schedule, archives ended sessions and deletes the workspace
+// volumes of ended sessions. With several replicas, only the leader runs
+// it, so sessions are not terminated twice.
+func (m *Manager) runCleanup() {
+	if !leader.IsLeader(m.leader) {
+		return
+	}
+	if err := m.cleanup(context.Background()); err != nil {
+		log.Printf("Error cleaning up sessions: %v", err)
+	}
+}
+
+// cleanup runs each cleanup step, carrying on past failed ones.
+func (m *Manager) cleanup(ctx context.Context) error {
+	var errs []error
+	if err := m.cleanupStaleSessions(); err != nil {
+		errs = append(errs, fmt.Errorf("stale sessions: %w", err))
+	}
+	if err := m.stopClosedSessions(); err != nil {
+		errs = append(errs, fmt.Errorf("sessions outside app schedules: %w", err))
+	}
+	if err := m.archiveEndedSessions(); err != nil {
+		errs = append(errs, fmt.Errorf("ended sessions: %w", err))
+	}
+	if err := m.cleanupWorkspaceVolumes(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("workspace volumes: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
 // cleanupStaleSessions expires sessions that have been running too long
 func (m *Manager) cleanupStaleSessions() error {
 	sessions, err := m.db.GetStaleSessions(m.sessionTimeout)
@@ -206,7 +406,27 @@ func (m *Manager) cleanupStaleSessions() error {
 		return fmt.Errorf("failed to get stale sessions: %w", err)
 	}
 
+	hibernates := map[string]bool{}
 	for _, session := range sessions {
+		hibernate, checked := hibernates[session.AppID]
+		if !checked {
+			app, err := m.db.GetApp(session.AppID)
+			if err != nil {
+				log.Printf("Error getting app %s for stale session %s: %v", session.AppID, session.ID, err)
+			}
+			hibernate = app != nil && app.HibernateOnIdle
+			hibernates[session.AppID] = hibernate
+		}
+
+		// Only running sessions hibernate; one stuck creating has no
+		// work to keep
+		if hibernate && session.Status == db.SessionStatusRunning {
+			log.Printf("Hibernating idle session: %s (pod: %s)", session.ID, session.PodName)
+			if err := m.HibernateSession(context.Background(), session.ID); err != nil {
+				log.Printf("Error hibernating idle session %s: %v", session.ID, err)
+			}
+			continue
+		}
 		log.Printf("Expiring stale session: %s (pod: %s)", session.ID, session.PodName)
 		if err := m.ExpireSession(context.Background(), session.ID); err != nil {
 			log.Printf("Error expiring stale session %s: %v", session.ID, err)
@@ -230,8 +450,9 @@ func (m *Manager) checkQuotasWithTenant(userID, tenantID string) error {
 			return fmt.Errorf("failed to check user session count: %w", err)
 		}
 		if count >= m.maxSessionsPerUser {
-			return &QuotaExceededError{
-				Reason: fmt.Sprintf("user %s has %d active sessions (max %d)", userID, count, m.maxSessionsPerUser),
+			limitReason := fmt.Sprintf("user %s has %d active sessions (max %d)", userID, count, m.maxSessionsPerUser)
+			if err := m.checkBurst(userID, count, m.userBurstPolicy(), limitReason); err != nil {
+				return err
 			}
 		}
 	}
@@ -250,6 +471,7 @@ func (m *Manager) checkQuotasWithTenant(userID, tenantID string) error {
 			if count >= tenant.Quotas.MaxTotalSessions {
 				return &QuotaExceededError{
 					Reason: fmt.Sprintf("tenant %s session limit reached (%d/%d)", tenant.Name, count, tenant.Quotas.MaxTotalSessions),
+					Limit:  QuotaLimitTenant,
 				}
 			}
 		}
@@ -262,6 +484,7 @@ func (m *Manager) checkQuotasWithTenant(userID, tenantID string) error {
 			if count >= tenant.Quotas.MaxSessionsPerUser {
 				return &QuotaExceededError{
 					Reason: fmt.Sprintf("tenant per-user session limit reached (%d/%d)", count, tenant.Quotas.MaxSessionsPerUser),
+					Limit:  QuotaLimitTenant,
 				}
 			}
 		}
@@ -276,6 +499,7 @@ func (m *Manager) checkQuotasWithTenant(userID, tenantID string) error {
 		if count >= m.maxGlobalSessions {
 			return &QuotaExceededError{
 				Reason: fmt.Sprintf("global session limit reached (%d/%d)", count, m.maxGlobalSessions),
+				Limit:  QuotaLimitGlobal,
 			}
 		}
 	}
@@ -283,38 +507,46 @@ func (m *Manager) checkQuotasWithTenant(userID, tenantID string) error {
 	return nil
 }
 
-// applyDefaultResourceLimits sets default CPU/memory on a workload config when no app-specific limits are configured.
-func (m *Manager) applyDefaultResourceLimits(wc *runner.WorkloadConfig, app *db.Application) {
-	// App-specific limits take priority
-	if app.ResourceLimits != nil {
-		if app.ResourceLimits.CPURequest != "" {
-			wc.CPURequest = app.ResourceLimits.CPURequest
-		}
-		if app.ResourceLimits.CPULimit != "" {
-			wc.CPULimit = app.ResourceLimits.CPULimit
-		}
-		if app.ResourceLimits.MemoryRequest != "" {
-			wc.MemoryRequest = app.ResourceLimits.MemoryRequest
-		}
-		if app.ResourceLimits.MemoryLimit != "" {
-			wc.MemoryLimit = app.ResourceLimits.MemoryLimit
-		}
-		return
+// checkAppLimit verifies that the app's active sessions, across all users,
+// are below its MaxConcurrentSessions.
+func (m *Manager) checkAppLimit(app *db.Application) error {
+	if app.MaxConcurrentSessions <= 0 {
+		return nil
 	}
-
-	// Apply global defaults from config
-	if m.defaultCPURequest != "" {
-		wc.CPURequest = m.defaultCPURequest
-	}
-	if m.defaultCPULimit != "" {
-		wc.CPULimit = m.defaultCPULimit
+	count, err := m.db.CountActiveSessionsByApp(app.ID)
+	if err != nil {
+		return fmt.Errorf("failed to check app session count: %w", err)
 	}
-	if m.defaultMemRequest != "" {
-		wc.MemoryRequest = m.defaultMemRequest
+	if count >= app.MaxConcurrentSessions {
+		return &QuotaExceededError{
+			Reason: fmt.Sprintf("app %s at capacity (%d/%d sessions)", app.Name, count, app.MaxConcurrentSessions),
+			Limit:  QuotaLimitApp,
+		}
 	}
-	if m.defaultMemLimit != "" {
-		wc.MemoryLimit = m.defaultMemLimit
+	return nil
+}
+
+// addSidecars asks each sidecar injector for the session's sidecars and adds
+// them to the workload. An injector error fails the launch, since a session
+// may depend on its sidecars (e.g. a VPN client) to work as intended.
+func (m *Manager) addSidecars(ctx context.Context, wc *runner.WorkloadConfig, app *db.Application, userID string) error {
+	req := &plugins.SidecarRequest{
+		SessionID:  wc.SessionID,
+		AppID:      app.ID,
+		AppName:    app.Name,
+		TenantID:   app.TenantID,
+		UserID:     userID,
+		LaunchType: string(app.LaunchType),
+		OsType:     app.OsType,
+	}
+	for _, injector := range m.sidecarInjectors {
+		sidecars, err := injector.Sidecars(ctx, req)
+		if err != nil {
+			return fmt.Errorf("sidecar injector %s: %w", injector.Name(), err)
+		}
+		wc.Sidecars = append(wc.Sidecars, sidecars...)
 	}
+	return nil
 }
 
 // buildWorkloadConfig creates a WorkloadConfig from an app and session ID.
@@ -328,6 +560,12 @@ func (m *Manager) buildWorkloadConfig(sessionID string, app *db.Application) *ru
 		Args:           app.ContainerArgs,
 		LaunchType:     string(app.LaunchType),
 		OsType:         app.OsType,
+		DNS:            app.DNS,
+		Scheduling:     app.Scheduling,
+		SidecarImages:  app.SidecarImages,
+		Containers:     app.Containers,
+		SharedVolumes:  app.SharedVolumes,
+		InitContainers: app.InitContainers,
 	}
 }
 
@@ -359,6 +597,11 @@ func (m *Manager) GetQuotaStatusWithTenant(userID, tenantID string) (*QuotaStatu
 		DefaultMemLimit:    m.defaultMemLimit,
 	}
 
+	status.Burst, err = m.burstStatus(userID, userCount)
+	if err != nil {
+		return nil, err
+	}
+
 	// Add tenant quota info if tenant specified
 	if tenantID != "" {
 		tenantCount, err := m.db.CountActiveSessionsByTenant(tenantID)
@@ -398,8 +641,38 @@ func (m *Manager) GetQuotaStatusWithTenant(userID, tenantID string) (*QuotaStatu
 
 // CreateSession creates a new session for an application
 func (m *Manager) CreateSession(ctx context.Context, req *CreateSessionRequest) (*db.Session, error) {
+	ctx, span := tracer.Start(ctx, "sessions.CreateSession", trace.WithAttributes(
+		attribute.String("sortie.app_id", req.AppID),
+		attribute.String("sortie.user_id", req.UserID),
+	))
+	defer span.End()
+
+	session, err := m.createSession(ctx, req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attribute.String("sortie.session_id", session.ID))
+	return session, nil
+}
+
+func (m *Manager) createSession(ctx context.Context, req *CreateSessionRequest) (*db.Session, error) {
+	if err := m.beginLaunch(); err != nil {
+		return nil, err
+	}
+	waiting := false
+	defer func() {
+		if !waiting {
+			m.launches.Done()
+		}
+	}()
+
+	// Queries made here join the request's trace
+	store := m.db.WithContext(ctx)
+
 	// Get the application
-	app, err := m.db.GetApp(req.AppID)
+	app, err := store.GetApp(req.AppID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get application: %w", err)
 	}
@@ -412,6 +685,21 @@ func (m *Manager) CreateSession(ctx context.Context, req *CreateSessionRequest)
 	if app.ContainerImage == "" {
 		return nil, fmt.Errorf("application %s has no container image configured", req.AppID)
 	}
+	if err := checkSchedule(app); err != nil {
+		return nil, err
+	}
+	if err := m.checkAppLimit(app); err != nil {
+		m.countQuotaRejection(app.TenantID, err)
+		return nil, err
+	}
+	project, err := sessionProject(store, req.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkProjectLimit(project); err != nil {
+		m.countQuotaRejection(app.TenantID, err)
+		return nil, err
+	}
 
 	// Check quotas before creating resources.
 	// If the global limit is hit and a queue is configured, wait for capacity.
@@ -419,17 +707,23 @@ func (m *Manager) CreateSession(ctx context.Context, req *CreateSessionRequest)
 		if m.queue != nil {
 			if _, isQuotaErr := err.(*QuotaExceededError); isQuotaErr {
 				log.Printf("Global session limit reached, queueing request for user %s", req.UserID)
-				if qErr := m.queue.Enqueue(ctx); qErr != nil {
+				if qErr := m.queue.Enqueue(ctx, app.TenantID); qErr != nil {
+					var full *QueueFullError
+					if errors.As(qErr, &full) {
+						m.countQuotaRejection(app.TenantID, err)
+					}
 					return nil, qErr
 				}
 				// Re-check per-user quota after dequeue (global capacity is now available)
 				if err := m.checkQuotas(req.UserID); err != nil {
+					m.countQuotaRejection(app.TenantID, err)
 					return nil, err
 				}
 			} else {
 				return nil, err
 			}
 		} else {
+			m.countQuotaRejection(app.TenantID, err)
 			return nil, err
 		}
 	}
@@ -447,19 +741,48 @@ func (m *Manager) CreateSession(ctx context.Context, req *CreateSessionRequest)
 		wc.ScreenHeight = req.ScreenHeight
 	}
 
-	// Apply resource limits (app-specific override global defaults)
-	m.applyDefaultResourceLimits(wc, app)
-
-	// Create the workload via the runner
-	result, err := m.runner.CreateWorkload(ctx, wc)
+	// Apply the app's settings, inherited from its category, tenant, and
+	// the server where it sets none
+	policy, err := m.effectivePolicy(store, app)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create workload: %w", err)
+		return nil, err
+	}
+	policy.applyResourceLimits(wc)
+	wc.ImagePullSecret = policy.ImagePullSecret
+
+	if err := m.addSidecars(ctx, wc, app, req.UserID); err != nil {
+		return nil, err
+	}
+	if err := m.attachHomeVolume(ctx, wc, app, req.UserID); err != nil {
+		return nil, err
+	}
+	if err := m.attachWorkspaceVolume(ctx, wc, app); err != nil {
+		return nil, err
+	}
+	if err := m.attachProjectDrive(ctx, wc, project); err != nil {
+		return nil, err
+	}
+
+	// Take a workload from the app's warm pool, or create one via the runner
+	result := m.claimWarmWorkload(ctx, app, wc)
+	if result == nil {
+		if err := m.checkCapacity(ctx, wc, app.TenantID); err != nil {
+			if launchRejected(err) {
+				m.recordFailedLaunch(sessionID, app, err)
+			}
+			return nil, err
+		}
+		result, err = m.runner.CreateWorkload(ctx, wc)
+		if err != nil {
+			m.recordFailedLaunch(sessionID, app, err)
+			return nil, fmt.Errorf("failed to create workload: %w", err)
+		}
 	}
 
 	// Create per-session network policy if the runner supports it and the app has egress rules
-	if app.EgressPolicy != nil && app.EgressPolicy.Mode != "" {
+	if policy.EgressPolicy != nil {
 		if npr, ok := m.runner.(runner.NetworkPolicyRunner); ok {
-			if err := npr.CreateNetworkPolicy(ctx, sessionID, app.ID, app.EgressPolicy); err != nil {
+			if err := npr.CreateNetworkPolicy(ctx, sessionID, app.ID, policy.EgressPolicy); err != nil {
 				log.Printf("Warning: failed to create egress policy for session %s: %v", sessionID, err)
 				// Non-fatal: workload still runs, just without custom egress rules
 			}
@@ -469,17 +792,20 @@ func (m *Manager) CreateSession(ctx context.Context, req *CreateSessionRequest)
 	// Create session in database
 	now := time.Now()
 	session := &db.Session{
-		ID:          sessionID,
-		UserID:      req.UserID,
-		AppID:       req.AppID,
-		PodName:     result.Name,
-		Status:      db.SessionStatusCreating,
-		IdleTimeout: req.IdleTimeout,
-		CreatedAt:   now,
-		UpdatedAt:   now,
-	}
-
-	if err := m.db.CreateSession(*session); err != nil {
+		ID:           sessionID,
+		UserID:       req.UserID,
+		AppID:        req.AppID,
+		PodName:      result.Name,
+		Status:       db.SessionStatusCreating,
+		IdleTimeout:  policy.sessionIdleTimeout(req.IdleTimeout),
+		TenantID:     app.TenantID,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		SidecarImage: result.SidecarImage,
+		ProjectID:    req.ProjectID,
+	}
+
+	if err := store.CreateSession(*session); err != nil {
 		// Try to clean up the workload and network policy
 		m.runner.DeleteWorkload(ctx, result.Name)
 		if npr, ok := m.runner.(runner.NetworkPolicyRunner); ok {
@@ -488,28 +814,52 @@ func (m *Manager) CreateSession(ctx context.Context, req *CreateSessionRequest)
 		return nil, fmt.Errorf("failed to create session in database: %w", err)
 	}
 
+	m.routeSession(ctx, store, session, app)
+
+	m.recordBurstUsage(req.UserID)
+
 	// Emit session created event
 	m.emitEvent(ctx, EventSessionCreated, session, "session created")
 
 	// Start goroutine to wait for workload ready and update session
-	go m.waitForWorkloadReady(sessionID, result.Name)
+	waiting = true
+	m.goWaitForWorkloadReady(sessionID, result.Name)
 
 	return session, nil
 }
 
 // waitForWorkloadReady waits for the workload to be ready and updates the session
 func (m *Manager) waitForWorkloadReady(sessionID, workloadName string) {
-	ctx, cancel := context.WithTimeout(context.Background(), m.podReadyTimeout)
+	ctx, cancel := context.WithTimeout(m.launchCtx, m.podReadyTimeout)
 	defer cancel()
 
-	// Wait for workload to be ready
-	if err := m.runner.WaitForReady(ctx, workloadName, m.podReadyTimeout); err != nil {
+	// Report launch progress while waiting for the workload to be ready
+	progressCtx, stopProgress := context.WithCancel(ctx)
+	progressDone := make(chan struct{})
+	go func() {
+		defer close(progressDone)
+		m.trackProgress(progressCtx, sessionID, workloadName)
+	}()
+	chaos.DelayPodReady(ctx)
+	err := m.runner.WaitForReady(ctx, workloadName, m.podReadyTimeout)
+	stopProgress()
+	<-progressDone
+	if cause := context.Cause(ctx); err != nil && errors.Is(cause, ErrShuttingDown) {
+		err = cause
+	}
+
+	if err != nil {
+		m.recordFailedStage(sessionID, workloadName)
 		LogTransition(sessionID, db.SessionStatusCreating, db.SessionStatusFailed, fmt.Sprintf("workload failed to become ready: %v", err))
+		m.recordLaunch(sessionID, fmt.Errorf("workload failed to become ready: %w", err))
+		m.recordHistoryByID(sessionID, db.SessionStatusFailed, fmt.Sprintf("workload failed to become ready: %v", err))
 		m.db.UpdateSessionStatus(sessionID, db.SessionStatusFailed)
+		m.recordSessionBurstUsage(sessionID)
 		m.emitEventByID(sessionID, EventSessionFailed, fmt.Sprintf("workload failed to become ready: %v", err))
 		if delErr := m.runner.DeleteWorkload(context.Background(), workloadName); delErr != nil {
 			log.Printf("Failed to delete workload %s after timeout: %v", workloadName, delErr)
 		}
+		m.unrouteSession(context.Background(), sessionID)
 		return
 	}
 
@@ -517,16 +867,21 @@ func (m *Manager) waitForWorkloadReady(sessionID, workloadName string) {
 	ip, err := m.runner.GetIP(ctx, workloadName)
 	if err != nil {
 		LogTransition(sessionID, db.SessionStatusCreating, db.SessionStatusFailed, fmt.Sprintf("failed to get workload IP: %v", err))
+		m.recordLaunch(sessionID, fmt.Errorf("failed to get workload IP: %w", err))
+		m.recordHistoryByID(sessionID, db.SessionStatusFailed, fmt.Sprintf("failed to get workload IP: %v", err))
 		m.db.UpdateSessionStatus(sessionID, db.SessionStatusFailed)
+		m.recordSessionBurstUsage(sessionID)
 		m.emitEventByID(sessionID, EventSessionFailed, fmt.Sprintf("failed to get workload IP: %v", err))
 		if delErr := m.runner.DeleteWorkload(context.Background(), workloadName); delErr != nil {
 			log.Printf("Failed to delete workload %s after IP lookup failure: %v", workloadName, delErr)
 		}
+		m.unrouteSession(context.Background(), sessionID)
 		return
 	}
 
 	// Update session with IP and running status in a single operation
 	LogTransition(sessionID, db.SessionStatusCreating, db.SessionStatusRunning, "workload ready")
+	m.recordLaunch(sessionID, nil)
 	if err := m.db.UpdateSessionPodIPAndStatus(sessionID, ip, db.SessionStatusRunning); err != nil {
 		log.Printf("Failed to update session for %s: %v", sessionID, err)
 	}
@@ -567,6 +922,12 @@ func (m *Manager) ListSessionsByUser(ctx context.Context, userID string) ([]db.S
 // StopSession stops a running session, deleting the workload but keeping the session
 // record so it can be restarted later.
 func (m *Manager) StopSession(ctx context.Context, sessionID string) error {
+	return m.stopWithStatus(ctx, sessionID, db.SessionStatusStopped, "user stopped", EventSessionStopped)
+}
+
+// stopWithStatus deletes a running session's workload but keeps the session
+// record, so the session can be started again with a new workload.
+func (m *Manager) stopWithStatus(ctx context.Context, sessionID string, status db.SessionStatus, reason string, event SessionEvent) error {
 	session, err := m.GetSession(ctx, sessionID)
 	if err != nil {
 		return err
@@ -575,10 +936,12 @@ func (m *Manager) StopSession(ctx context.Context, sessionID string) error {
 		return fmt.Errorf("session not found: %s", sessionID)
 	}
 
-	if err := ValidateAndLogTransition(sessionID, session.Status, db.SessionStatusStopped, "user stopped"); err != nil {
+	if err := ValidateAndLogTransition(sessionID, session.Status, status, reason); err != nil {
 		return err
 	}
 
+	m.attestSession(ctx, session, status, reason)
+
 	// Delete the workload
 	if err := m.runner.DeleteWorkload(ctx, session.PodName); err != nil {
 		log.Printf("Warning: failed to delete workload %s: %v", session.PodName, err)
@@ -588,14 +951,16 @@ func (m *Manager) StopSession(ctx context.Context, sessionID string) error {
 	if npr, ok := m.runner.(runner.NetworkPolicyRunner); ok {
 		npr.DeleteNetworkPolicy(ctx, sessionID)
 	}
+	m.unrouteSession(ctx, sessionID)
 
-	// Update status to stopped
-	if err := m.db.UpdateSessionStatus(sessionID, db.SessionStatusStopped); err != nil {
+	if err := m.db.UpdateSessionStatus(sessionID, status); err != nil {
 		return fmt.Errorf("failed to update session status: %w", err)
 	}
 
-	// Emit session stopped event
-	m.emitEvent(ctx, EventSessionStopped, session, "user stopped")
+	m.recordHistory(session, status, reason)
+	m.recordBurstUsage(session.UserID)
+
+	m.emitEvent(ctx, event, session, reason)
 
 	// Notify the queue that capacity may be available
 	if m.queue != nil {
@@ -615,12 +980,31 @@ func (m *Manager) RestartSession(ctx context.Context, sessionID string) (*db.Ses
 		return nil, fmt.Errorf("session not found: %s", sessionID)
 	}
 
-	if err := ValidateAndLogTransition(sessionID, session.Status, db.SessionStatusCreating, "user restarted"); err != nil {
+	if session.Status != db.SessionStatusStopped {
 		return nil, fmt.Errorf("session must be stopped to restart (current status: %s)", session.Status)
 	}
+	return m.relaunch(ctx, session, "user restarted", EventSessionRestarted)
+}
+
+// relaunch creates a new workload for a stopped or hibernated session.
+func (m *Manager) relaunch(ctx context.Context, session *db.Session, reason string, event SessionEvent) (*db.Session, error) {
+	sessionID := session.ID
+	if err := m.beginLaunch(); err != nil {
+		return nil, err
+	}
+	waiting := false
+	defer func() {
+		if !waiting {
+			m.launches.Done()
+		}
+	}()
+	if err := ValidateAndLogTransition(sessionID, session.Status, db.SessionStatusCreating, reason); err != nil {
+		return nil, err
+	}
 
 	// Check quotas before recreating resources
 	if err := m.checkQuotas(session.UserID); err != nil {
+		m.countQuotaRejection(session.TenantID, err)
 		return nil, err
 	}
 
@@ -632,30 +1016,51 @@ func (m *Manager) RestartSession(ctx context.Context, sessionID string) (*db.Ses
 	if app == nil {
 		return nil, fmt.Errorf("application not found: %s", session.AppID)
 	}
+	if err := checkSchedule(app); err != nil {
+		return nil, err
+	}
+	if err := m.checkAppLimit(app); err != nil {
+		m.countQuotaRejection(session.TenantID, err)
+		return nil, err
+	}
+	project, err := sessionProject(m.db, session.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkProjectLimit(project); err != nil {
+		m.countQuotaRejection(session.TenantID, err)
+		return nil, err
+	}
 
-	// Build workload configuration using the existing session ID
-	wc := m.buildWorkloadConfig(sessionID, app)
-
-	// Apply resource limits (app-specific override global defaults)
-	m.applyDefaultResourceLimits(wc, app)
+	wc, policy, err := m.sessionWorkload(ctx, session, app)
+	if err != nil {
+		return nil, err
+	}
 
-	// Create the workload via the runner
+	// Create the workload via the runner, if the cluster has room for it
+	if err := m.checkCapacity(ctx, wc, app.TenantID); err != nil {
+		if launchRejected(err) {
+			m.recordFailedLaunch(sessionID, app, err)
+		}
+		return nil, err
+	}
 	result, err := m.runner.CreateWorkload(ctx, wc)
 	if err != nil {
+		m.recordFailedLaunch(sessionID, app, err)
 		return nil, fmt.Errorf("failed to create workload: %w", err)
 	}
 
 	// Create per-session network policy if the runner supports it and the app has egress rules
-	if app.EgressPolicy != nil && app.EgressPolicy.Mode != "" {
+	if policy.EgressPolicy != nil {
 		if npr, ok := m.runner.(runner.NetworkPolicyRunner); ok {
-			if err := npr.CreateNetworkPolicy(ctx, sessionID, app.ID, app.EgressPolicy); err != nil {
+			if err := npr.CreateNetworkPolicy(ctx, sessionID, app.ID, policy.EgressPolicy); err != nil {
 				log.Printf("Warning: failed to create egress policy for restarted session %s: %v", sessionID, err)
 			}
 		}
 	}
 
 	// Update session in database with new workload name and creating status
-	if err := m.db.UpdateSessionRestart(sessionID, result.Name); err != nil {
+	if err := m.db.UpdateSessionRestart(sessionID, result.Name, result.SidecarImage); err != nil {
 		m.runner.DeleteWorkload(ctx, result.Name)
 		if npr, ok := m.runner.(runner.NetworkPolicyRunner); ok {
 			npr.DeleteNetworkPolicy(ctx, sessionID)
@@ -669,15 +1074,55 @@ func (m *Manager) RestartSession(ctx context.Context, sessionID string) (*db.Ses
 		return nil, fmt.Errorf("failed to re-read session after restart: %w", err)
 	}
 
-	// Emit session restarted event
-	m.emitEvent(ctx, EventSessionRestarted, session, "user restarted")
+	// Route the session's hostname again; it kept it while stopped
+	m.routeSession(ctx, m.db, session, app)
+
+	m.recordBurstUsage(session.UserID)
+
+	m.emitEvent(ctx, event, session, reason)
 
 	// Wait for workload ready in background
-	go m.waitForWorkloadReady(sessionID, result.Name)
+	waiting = true
+	m.goWaitForWorkloadReady(sessionID, result.Name)
 
 	return session, nil
 }
 
+// sessionWorkload builds the configuration of a new workload for an
+// existing session, keeping its ID and volumes, and returns it with the
+// app's effective policy.
+func (m *Manager) sessionWorkload(ctx context.Context, session *db.Session, app *db.Application) (*runner.WorkloadConfig, *EffectivePolicy, error) {
+	// Build workload configuration using the existing session ID
+	wc := m.buildWorkloadConfig(session.ID, app)
+
+	// Apply the app's settings, inherited from its category, tenant, and
+	// the server where it sets none
+	policy, err := m.EffectivePolicy(app)
+	if err != nil {
+		return nil, nil, err
+	}
+	policy.applyResourceLimits(wc)
+	wc.ImagePullSecret = policy.ImagePullSecret
+
+	if err := m.addSidecars(ctx, wc, app, session.UserID); err != nil {
+		return nil, nil, err
+	}
+	if err := m.attachHomeVolume(ctx, wc, app, session.UserID); err != nil {
+		return nil, nil, err
+	}
+	if err := m.attachWorkspaceVolume(ctx, wc, app); err != nil {
+		return nil, nil, err
+	}
+	project, err := sessionProject(m.db, session.ProjectID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := m.attachProjectDrive(ctx, wc, project); err != nil {
+		return nil, nil, err
+	}
+	return wc, policy, nil
+}
+
 // TerminateSession stops a session (user-initiated) and deletes the pod
 func (m *Manager) TerminateSession(ctx context.Context, sessionID string) error {
 	return m.terminateWithStatus(ctx, sessionID, db.SessionStatusStopped, "user requested")
@@ -709,25 +1154,41 @@ func (m *Manager) terminateWithStatus(ctx context.Context, sessionID string, fin
 		return err
 	}
 
-	// Delete the workload
-	if err := m.runner.DeleteWorkload(ctx, session.PodName); err != nil {
-		log.Printf("Warning: failed to delete workload %s: %v", session.PodName, err)
-	}
+	// A hibernated session's run already ended when its workload was
+	// deleted; only its workspace volume is left
+	hibernated := session.Status == db.SessionStatusHibernated
+	if !hibernated {
+		m.attestSession(ctx, session, finalStatus, reason)
 
-	// Clean up network policy (if runner supports it)
-	if npr, ok := m.runner.(runner.NetworkPolicyRunner); ok {
-		npr.DeleteNetworkPolicy(ctx, sessionID)
+		// Delete the workload
+		if err := m.runner.DeleteWorkload(ctx, session.PodName); err != nil {
+			log.Printf("Warning: failed to delete workload %s: %v", session.PodName, err)
+		}
+
+		// Clean up network policy (if runner supports it)
+		if npr, ok := m.runner.(runner.NetworkPolicyRunner); ok {
+			npr.DeleteNetworkPolicy(ctx, sessionID)
+		}
+		m.unrouteSession(ctx, sessionID)
 	}
 
 	// Update status to final state
 	if err := m.db.UpdateSessionStatus(sessionID, finalStatus); err != nil {
 		return fmt.Errorf("failed to update session status: %w", err)
 	}
+	m.deleteWorkspaceVolume(ctx, sessionID)
+
+	if !hibernated {
+		m.recordHistory(session, finalStatus, reason)
+		m.recordBurstUsage(session.UserID)
+	}
 
 	// Emit event based on final status
 	switch finalStatus {
 	case db.SessionStatusExpired:
 		m.emitEvent(ctx, EventSessionExpired, session, reason)
+	case db.SessionStatusFailed:
+		m.emitEvent(ctx, EventSessionFailed, session, reason)
 	default:
 		m.emitEvent(ctx, EventSessionTerminated, session, reason)
 	}
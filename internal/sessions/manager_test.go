@@ -2,13 +2,20 @@ package sessions
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/rjsadow/sortie/internal/attestation"
 	"github.com/rjsadow/sortie/internal/db"
 	"github.com/rjsadow/sortie/internal/db/dbtest"
+	"github.com/rjsadow/sortie/internal/jobs"
+	"github.com/rjsadow/sortie/internal/plugins"
 	"github.com/rjsadow/sortie/internal/runner"
+	"github.com/rjsadow/sortie/internal/schedule"
 )
 
 // newTestDB creates a test database using the shared dbtest helper.
@@ -757,6 +764,120 @@ func TestCheckQuotas_PerUserLimit(t *testing.T) {
 	}
 }
 
+func TestCheckQuotas_BurstAboveSoftLimit(t *testing.T) {
+	database := newTestDB(t)
+	m := NewManagerWithConfig(database, ManagerConfig{
+		MaxSessionsPerUser:      2,
+		MaxSessionsPerUserBurst: 4,
+		SessionBurstDuration:    30 * time.Minute,
+	})
+
+	seedContainerApp(t, database, "app1", "Test App", "test:latest")
+	now := time.Now()
+	addSession := func(id string) {
+		t.Helper()
+		s := db.Session{ID: id, UserID: "user-a", AppID: "app1", PodName: "p-" + id, Status: db.SessionStatusRunning, CreatedAt: now, UpdatedAt: now}
+		if err := database.CreateSession(s); err != nil {
+			t.Fatalf("CreateSession error: %v", err)
+		}
+		m.recordBurstUsage("user-a")
+	}
+
+	addSession("s1")
+	addSession("s2")
+
+	// At the soft limit, bursting is allowed
+	if err := m.checkQuotas("user-a"); err != nil {
+		t.Fatalf("checkQuotas() at soft limit should allow a burst, got %v", err)
+	}
+	addSession("s3")
+	addSession("s4")
+
+	// At the burst limit, new sessions are rejected
+	if err := m.checkQuotas("user-a"); err == nil {
+		t.Fatal("checkQuotas() expected error at burst limit, got nil")
+	}
+
+	status, err := m.GetQuotaStatus("user-a")
+	if err != nil {
+		t.Fatalf("GetQuotaStatus() error = %v", err)
+	}
+	if status.Burst == nil || !status.Burst.Bursting || status.Burst.Available || status.Burst.MaxSessions != 4 || status.Burst.DurationSeconds != 1800 {
+		t.Errorf("GetQuotaStatus().Burst = %+v", status.Burst)
+	}
+}
+
+func TestCheckQuotas_BurstAllowanceExhausted(t *testing.T) {
+	database := newTestDB(t)
+	m := NewManagerWithConfig(database, ManagerConfig{
+		MaxSessionsPerUser:      1,
+		MaxSessionsPerUserBurst: 3,
+		SessionBurstDuration:    30 * time.Minute,
+	})
+
+	seedContainerApp(t, database, "app1", "Test App", "test:latest")
+	now := time.Now()
+	for _, s := range []db.Session{
+		{ID: "s1", UserID: "user-a", AppID: "app1", PodName: "p1", Status: db.SessionStatusRunning, CreatedAt: now, UpdatedAt: now},
+		{ID: "s2", UserID: "user-a", AppID: "app1", PodName: "p2", Status: db.SessionStatusRunning, CreatedAt: now, UpdatedAt: now},
+	} {
+		if err := database.CreateSession(s); err != nil {
+			t.Fatalf("CreateSession error: %v", err)
+		}
+	}
+
+	// The user has been above the soft limit for 40 minutes
+	if err := database.SaveQuotaBurst(db.QuotaBurst{UserID: "user-a", Bursting: true, UpdatedAt: now.Add(-40 * time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	err := m.checkQuotas("user-a")
+	if _, ok := err.(*QuotaExceededError); !ok {
+		t.Fatalf("checkQuotas() expected QuotaExceededError after burst time is used, got %v", err)
+	}
+
+	status, err := m.GetQuotaStatus("user-a")
+	if err != nil {
+		t.Fatalf("GetQuotaStatus() error = %v", err)
+	}
+	if status.Burst == nil || status.Burst.DebtSeconds != 1800 || status.Burst.RemainingSeconds != 0 || status.Burst.Available {
+		t.Errorf("GetQuotaStatus().Burst = %+v", status.Burst)
+	}
+
+	// Time spent under the soft limit pays the debt back
+	if err := database.SaveQuotaBurst(db.QuotaBurst{UserID: "user-a", DebtSeconds: 1800, UpdatedAt: now.Add(-10 * time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.checkQuotas("user-a"); err != nil {
+		t.Errorf("checkQuotas() after paying back debt should succeed, got %v", err)
+	}
+	burst, _ := database.GetQuotaBurst("user-a")
+	if burst == nil || burst.DebtSeconds < 1199 || burst.DebtSeconds > 1200 || !burst.Bursting {
+		t.Errorf("settled burst = %+v, want ~1200s debt and bursting", burst)
+	}
+}
+
+func TestBurstDebt(t *testing.T) {
+	p := burstPolicy{soft: 1, burst: 2, window: time.Hour}
+	now := time.Now()
+
+	if debt, _ := burstDebt(nil, now, p); debt != 0 {
+		t.Errorf("burstDebt(nil) = %d, want 0", debt)
+	}
+
+	debt, settledAt := burstDebt(&db.QuotaBurst{DebtSeconds: 10, Bursting: true, UpdatedAt: now.Add(-1500 * time.Millisecond)}, now, p)
+	if debt != 11 || !settledAt.Equal(now.Add(-500*time.Millisecond)) {
+		t.Errorf("burstDebt(bursting) = %d, %v; want 11 and the fraction carried over", debt, settledAt)
+	}
+
+	if debt, _ := burstDebt(&db.QuotaBurst{DebtSeconds: 10, UpdatedAt: now.Add(-time.Minute)}, now, p); debt != 0 {
+		t.Errorf("burstDebt(repaid) = %d, want 0", debt)
+	}
+
+	if debt, _ := burstDebt(&db.QuotaBurst{Bursting: true, UpdatedAt: now.Add(-2 * time.Hour)}, now, p); debt != 3600 {
+		t.Errorf("burstDebt(capped) = %d, want 3600", debt)
+	}
+}
+
 func TestCheckQuotas_GlobalLimit(t *testing.T) {
 	database := newTestDB(t)
 	m := NewManagerWithConfig(database, ManagerConfig{
@@ -787,6 +908,40 @@ func TestCheckQuotas_GlobalLimit(t *testing.T) {
 	}
 }
 
+func TestCheckAppLimit(t *testing.T) {
+	database := newTestDB(t)
+	m := NewManagerWithConfig(database, ManagerConfig{})
+
+	app := seedContainerApp(t, database, "app1", "Licensed App", "test:latest")
+	now := time.Now()
+	for _, s := range []db.Session{
+		{ID: "s1", UserID: "u1", AppID: "app1", PodName: "p1", Status: db.SessionStatusRunning, CreatedAt: now, UpdatedAt: now},
+		{ID: "s2", UserID: "u2", AppID: "app1", PodName: "p2", Status: db.SessionStatusCreating, CreatedAt: now, UpdatedAt: now},
+		{ID: "s3", UserID: "u3", AppID: "app1", PodName: "p3", Status: db.SessionStatusStopped, CreatedAt: now, UpdatedAt: now},
+	} {
+		if err := database.CreateSession(s); err != nil {
+			t.Fatalf("CreateSession error: %v", err)
+		}
+	}
+
+	if err := m.checkAppLimit(&app); err != nil {
+		t.Errorf("checkAppLimit() without a limit should succeed, got %v", err)
+	}
+	app.MaxConcurrentSessions = 3
+	if err := m.checkAppLimit(&app); err != nil {
+		t.Errorf("checkAppLimit() should succeed (2 active of 3 max), got %v", err)
+	}
+	app.MaxConcurrentSessions = 2
+	err := m.checkAppLimit(&app)
+	var quotaErr *QuotaExceededError
+	if !errors.As(err, &quotaErr) || quotaErr.Limit != QuotaLimitApp {
+		t.Fatalf("checkAppLimit() error = %v, want an app QuotaExceededError", err)
+	}
+	if !strings.Contains(err.Error(), "app Licensed App at capacity (2/2 sessions)") {
+		t.Errorf("checkAppLimit() error = %q", err)
+	}
+}
+
 func TestCheckQuotas_UnlimitedWhenZero(t *testing.T) {
 	database := newTestDB(t)
 	m := NewManagerWithConfig(database, ManagerConfig{
@@ -977,3 +1132,920 @@ func TestApplyDefaultResourceLimits_GlobalDefaultsWhenNoAppLimits(t *testing.T)
 		t.Errorf("MemoryLimit = %q, want 1Gi", wc.MemoryLimit)
 	}
 }
+
+// --- Sidecar injection ---
+
+// fakeSidecarInjector returns fixed sidecars and records the requests it sees.
+type fakeSidecarInjector struct {
+	name     string
+	sidecars []plugins.Sidecar
+	err      error
+	requests []*plugins.SidecarRequest
+}
+
+func (f *fakeSidecarInjector) Name() string                                        { return f.name }
+func (f *fakeSidecarInjector) Type() plugins.PluginType                            { return plugins.PluginTypeSidecar }
+func (f *fakeSidecarInjector) Version() string                                     { return "test" }
+func (f *fakeSidecarInjector) Description() string                                 { return "test injector" }
+func (f *fakeSidecarInjector) Initialize(context.Context, map[string]string) error { return nil }
+func (f *fakeSidecarInjector) Healthy(context.Context) bool                        { return true }
+func (f *fakeSidecarInjector) Close() error                                        { return nil }
+
+func (f *fakeSidecarInjector) Sidecars(_ context.Context, req *plugins.SidecarRequest) ([]plugins.Sidecar, error) {
+	f.requests = append(f.requests, req)
+	return f.sidecars, f.err
+}
+
+func TestAddSidecars_CombinesInjectors(t *testing.T) {
+	database := newTestDB(t)
+	first := &fakeSidecarInjector{name: "first", sidecars: []plugins.Sidecar{{Name: "vpn", Image: "vpn:1"}}}
+	second := &fakeSidecarInjector{name: "second", sidecars: []plugins.Sidecar{{Name: "logs", Image: "logs:1"}}}
+	m := NewManagerWithConfig(database, ManagerConfig{
+		SidecarInjectors: []plugins.SidecarInjector{first, second},
+	})
+
+	app := &db.Application{ID: "app-1", Name: "App", TenantID: "t1", LaunchType: db.LaunchTypeContainer, OsType: "linux"}
+	wc := &runner.WorkloadConfig{SessionID: "sess-1"}
+
+	if err := m.addSidecars(context.Background(), wc, app, "user-1"); err != nil {
+		t.Fatalf("addSidecars() error = %v", err)
+	}
+	if len(wc.Sidecars) != 2 || wc.Sidecars[0].Name != "vpn" || wc.Sidecars[1].Name != "logs" {
+		t.Errorf("Sidecars = %+v, want vpn then logs", wc.Sidecars)
+	}
+
+	req := first.requests[0]
+	if req.SessionID != "sess-1" || req.AppID != "app-1" || req.TenantID != "t1" || req.UserID != "user-1" || req.LaunchType != "container" {
+		t.Errorf("SidecarRequest = %+v", req)
+	}
+}
+
+func TestAddSidecars_InjectorErrorFailsLaunch(t *testing.T) {
+	database := newTestDB(t)
+	m := NewManagerWithConfig(database, ManagerConfig{
+		SidecarInjectors: []plugins.SidecarInjector{&fakeSidecarInjector{name: "broken", err: fmt.Errorf("boom")}},
+	})
+
+	wc := &runner.WorkloadConfig{SessionID: "sess-1"}
+	if err := m.addSidecars(context.Background(), wc, &db.Application{ID: "app-1"}, "user-1"); err == nil {
+		t.Error("expected injector error to be returned")
+	}
+}
+
+func TestTerminateSession_StoresAttestation(t *testing.T) {
+	database := newTestDB(t)
+	mockRunner := runner.NewMockRunner()
+	signer := attestation.DeriveSigner("test-secret")
+	m := NewManagerWithConfig(database, ManagerConfig{Runner: mockRunner, Attestor: signer})
+	ctx := context.Background()
+
+	app := seedContainerApp(t, database, "app1", "Test App", "test:latest")
+	app.AttestSessions = true
+	app.EgressPolicy = &db.EgressPolicy{Mode: "allowlist"}
+	if err := database.UpdateApp(app); err != nil {
+		t.Fatal(err)
+	}
+	seedContainerApp(t, database, "plain", "Plain App", "test:latest")
+	if err := database.CreateUser(db.User{ID: "user1", Username: "alice", Roles: []string{"user"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"s1", "s2"} {
+		appID := "app1"
+		if id == "s2" {
+			appID = "plain"
+		}
+		result, err := mockRunner.CreateWorkload(ctx, &runner.WorkloadConfig{SessionID: id, ContainerImage: "test:latest", CPULimit: "1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mockRunner.CreateNetworkPolicy(ctx, id, appID, app.EgressPolicy)
+		now := time.Now()
+		database.CreateSession(db.Session{
+			ID:        id,
+			UserID:    "user1",
+			AppID:     appID,
+			PodName:   result.Name,
+			Status:    db.SessionStatusRunning,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+	}
+
+	if err := m.TerminateSession(ctx, "s1"); err != nil {
+		t.Fatalf("TerminateSession() error = %v", err)
+	}
+	if err := m.TerminateSession(ctx, "s2"); err != nil {
+		t.Fatalf("TerminateSession() error = %v", err)
+	}
+
+	if list, _ := database.ListSessionAttestations("s2"); len(list) != 0 {
+		t.Errorf("expected no attestation for an app without attest_sessions, got %d", len(list))
+	}
+
+	list, err := database.ListSessionAttestations("s1")
+	if err != nil || len(list) != 1 {
+		t.Fatalf("ListSessionAttestations() = %v, %v; want 1", list, err)
+	}
+	if list[0].KeyID != signer.KeyID() {
+		t.Errorf("KeyID = %q, want %q", list[0].KeyID, signer.KeyID())
+	}
+
+	var env attestation.Envelope
+	if err := json.Unmarshal([]byte(list[0].Envelope), &env); err != nil {
+		t.Fatal(err)
+	}
+	report, err := attestation.Verify(&env, signer.PublicKey())
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if report.SessionID != "s1" || report.User.Username != "alice" || report.FinalStatus != "stopped" {
+		t.Errorf("report = %+v", report)
+	}
+	if len(report.Containers) != 1 || report.Containers[0].ImageDigest == "" || report.Containers[0].Resources.CPULimit != "1" {
+		t.Errorf("report containers = %+v", report.Containers)
+	}
+	if report.NetworkPolicy == nil || report.NetworkPolicy.Name != "sortie-egress-s1" {
+		t.Errorf("report network policy = %+v", report.NetworkPolicy)
+	}
+	if report.EndedAt.Before(report.StartedAt) {
+		t.Errorf("EndedAt %v before StartedAt %v", report.EndedAt, report.StartedAt)
+	}
+}
+
+func TestCreateSession_ScheduleClosed(t *testing.T) {
+	database := newTestDB(t)
+	m := NewManagerWithConfig(database, ManagerConfig{Runner: runner.NewMockRunner()})
+
+	app := seedContainerApp(t, database, "app1", "Test App", "test:latest")
+	now := time.Now()
+	app.Schedule = &db.AppSchedule{
+		Blackouts: []db.Blackout{{Start: now.Add(-time.Hour), End: now.Add(time.Hour), Reason: "maintenance"}},
+	}
+	if err := database.UpdateApp(app); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := m.CreateSession(context.Background(), &CreateSessionRequest{AppID: "app1", UserID: "user1"})
+	closed, ok := err.(*schedule.ClosedError)
+	if !ok {
+		t.Fatalf("CreateSession() error = %v, want *schedule.ClosedError", err)
+	}
+	if closed.App != "Test App" || closed.Reason != "blackout: maintenance" {
+		t.Errorf("unexpected ClosedError: %+v", closed)
+	}
+}
+
+func TestStopClosedSessions(t *testing.T) {
+	database := newTestDB(t)
+	mockRunner := runner.NewMockRunner()
+	m := NewManagerWithConfig(database, ManagerConfig{Runner: mockRunner})
+	ctx := context.Background()
+
+	now := time.Now()
+	blackout := []db.Blackout{{Start: now.Add(-time.Minute), End: now.Add(time.Hour)}}
+	stopping := seedContainerApp(t, database, "stopping", "Stopping App", "test:latest")
+	stopping.Schedule = &db.AppSchedule{Blackouts: blackout, StopAtWindowEnd: true}
+	keeping := seedContainerApp(t, database, "keeping", "Keeping App", "test:latest")
+	keeping.Schedule = &db.AppSchedule{Blackouts: blackout}
+	for _, app := range []db.Application{stopping, keeping} {
+		if err := database.UpdateApp(app); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for id, appID := range map[string]string{"s1": "stopping", "s2": "keeping"} {
+		result, err := mockRunner.CreateWorkload(ctx, &runner.WorkloadConfig{SessionID: id, ContainerImage: "test:latest"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		database.CreateSession(db.Session{
+			ID:        id,
+			UserID:    "user1",
+			AppID:     appID,
+			PodName:   result.Name,
+			Status:    db.SessionStatusRunning,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+	}
+
+	if err := m.stopClosedSessions(); err != nil {
+		t.Fatalf("stopClosedSessions() error = %v", err)
+	}
+
+	for id, want := range map[string]db.SessionStatus{"s1": db.SessionStatusStopped, "s2": db.SessionStatusRunning} {
+		session, err := database.GetSession(id)
+		if err != nil || session == nil {
+			t.Fatalf("GetSession(%s) = %v, %v", id, session, err)
+		}
+		if session.Status != want {
+			t.Errorf("session %s status = %s, want %s", id, session.Status, want)
+		}
+	}
+}
+
+func TestArchiveEndedSessions(t *testing.T) {
+	database := newTestDB(t)
+	seedContainerApp(t, database, "app1", "Test App", "test:latest")
+
+	old := time.Now().Add(-72 * time.Hour)
+	for id, status := range map[string]db.SessionStatus{"ended": db.SessionStatusExpired, "running": db.SessionStatusRunning} {
+		database.CreateSession(db.Session{
+			ID:        id,
+			UserID:    "user1",
+			AppID:     "app1",
+			PodName:   "pod-" + id,
+			Status:    status,
+			CreatedAt: old,
+			UpdatedAt: old,
+		})
+	}
+
+	// Without retention nothing is archived
+	if err := NewManager(database).archiveEndedSessions(); err != nil {
+		t.Fatalf("archiveEndedSessions() error = %v", err)
+	}
+	if s, _ := database.GetSession("ended"); s == nil {
+		t.Fatal("session archived without retention configured")
+	}
+
+	m := NewManagerWithConfig(database, ManagerConfig{SessionRetention: 48 * time.Hour})
+	if err := m.archiveEndedSessions(); err != nil {
+		t.Fatalf("archiveEndedSessions() error = %v", err)
+	}
+	if s, _ := database.GetSession("ended"); s != nil {
+		t.Error("expected ended session to be archived")
+	}
+	if s, _ := database.GetSession("running"); s == nil {
+		t.Error("running session should not be archived")
+	}
+	if list, _ := database.ListArchivedSessions("user1", 10); len(list) != 1 || list[0].ID != "ended" {
+		t.Errorf("ListArchivedSessions() = %+v", list)
+	}
+}
+
+func TestTerminateSession_RecordsHistory(t *testing.T) {
+	database := newTestDB(t)
+	mockRunner := runner.NewMockRunner()
+	m := NewManagerWithConfig(database, ManagerConfig{Runner: mockRunner, DefaultCPURequest: "500m", DefaultCPULimit: "2", DefaultMemLimit: "4Gi"})
+	ctx := context.Background()
+
+	seedContainerApp(t, database, "app1", "Test App", "test:latest")
+	if err := database.CreateUser(db.User{ID: "user1", Username: "alice", Roles: []string{"user"}}); err != nil {
+		t.Fatal(err)
+	}
+	result, err := mockRunner.CreateWorkload(ctx, &runner.WorkloadConfig{SessionID: "s1", ContainerImage: "test:latest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	readyAt := time.Now().Add(-10 * time.Minute)
+	database.CreateSession(db.Session{
+		ID:        "s1",
+		UserID:    "user1",
+		AppID:     "app1",
+		PodName:   result.Name,
+		Status:    db.SessionStatusRunning,
+		CreatedAt: readyAt.Add(-time.Minute),
+		UpdatedAt: readyAt,
+	})
+
+	if err := m.ExpireSession(ctx, "s1"); err != nil {
+		t.Fatalf("ExpireSession() error = %v", err)
+	}
+
+	page, err := database.QuerySessionHistory(db.SessionHistoryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 {
+		t.Fatalf("expected one history entry, got %d", page.Total)
+	}
+	h := page.Sessions[0]
+	if h.SessionID != "s1" || h.Username != "alice" || h.AppName != "Test App" {
+		t.Errorf("unexpected history identity: %+v", h)
+	}
+	if h.FinalStatus != db.SessionStatusExpired || h.ExitReason != "session timeout" {
+		t.Errorf("FinalStatus, ExitReason = %s, %q", h.FinalStatus, h.ExitReason)
+	}
+	if h.DurationSeconds < 599 || h.DurationSeconds > 660 {
+		t.Errorf("DurationSeconds = %d, want about 600", h.DurationSeconds)
+	}
+	if h.CPULimit != "2" || h.MemoryLimit != "4Gi" {
+		t.Errorf("resources = %s/%s, want 2/4Gi", h.CPULimit, h.MemoryLimit)
+	}
+	// CPU is counted at its request, memory at its limit as it has no request
+	if want := 0.5 * float64(h.DurationSeconds); h.CPUCoreSeconds != want {
+		t.Errorf("CPUCoreSeconds = %v, want %v", h.CPUCoreSeconds, want)
+	}
+	if want := 4 * float64(h.DurationSeconds); h.MemoryGiBSeconds != want {
+		t.Errorf("MemoryGiBSeconds = %v, want %v", h.MemoryGiBSeconds, want)
+	}
+}
+
+type fixedLeader bool
+
+func (l fixedLeader) IsLeader() bool { return bool(l) }
+
+func TestRunCleanup_OnlyOnLeader(t *testing.T) {
+	database := newTestDB(t)
+	mockRunner := runner.NewMockRunner()
+	ctx := context.Background()
+
+	seedContainerApp(t, database, "app1", "Test App", "test:latest")
+	result, err := mockRunner.CreateWorkload(ctx, &runner.WorkloadConfig{SessionID: "s1", ContainerImage: "test:latest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-3 * time.Hour)
+	database.CreateSession(db.Session{
+		ID:        "s1",
+		UserID:    "user1",
+		AppID:     "app1",
+		PodName:   result.Name,
+		Status:    db.SessionStatusRunning,
+		CreatedAt: old,
+		UpdatedAt: old,
+	})
+
+	follower := NewManagerWithConfig(database, ManagerConfig{Runner: mockRunner, SessionTimeout: time.Hour, Leader: fixedLeader(false)})
+	follower.runCleanup()
+	if session, _ := database.GetSession("s1"); session.Status != db.SessionStatusRunning {
+		t.Fatalf("follower expired a session: status = %s", session.Status)
+	}
+
+	leader := NewManagerWithConfig(database, ManagerConfig{Runner: mockRunner, SessionTimeout: time.Hour, Leader: fixedLeader(true)})
+	leader.runCleanup()
+	if session, _ := database.GetSession("s1"); session.Status != db.SessionStatusExpired {
+		t.Errorf("leader did not expire stale session: status = %s", session.Status)
+	}
+}
+
+func TestStart_RegistersCleanupJob(t *testing.T) {
+	database := newTestDB(t)
+	scheduler := jobs.NewScheduler(database, "test")
+	m := NewManagerWithConfig(database, ManagerConfig{Runner: runner.NewMockRunner(), Scheduler: scheduler})
+	m.Start()
+	defer m.Stop()
+
+	if !scheduler.Has(CleanupJobName) {
+		t.Fatalf("Start() did not register the %s job", CleanupJobName)
+	}
+	statuses, err := scheduler.Status()
+	if err != nil || len(statuses) != 1 {
+		t.Fatalf("Status() = %v, %v", statuses, err)
+	}
+	if statuses[0].IntervalSeconds != int64(DefaultCleanupInterval/time.Second) {
+		t.Errorf("interval = %ds, want the cleanup interval", statuses[0].IntervalSeconds)
+	}
+}
+
+func TestSessionHostnames(t *testing.T) {
+	database := newTestDB(t)
+	mockRunner := runner.NewMockRunner()
+	mockRunner.ReadyDelay = 0
+	m := NewManagerWithConfig(database, ManagerConfig{Runner: mockRunner, SessionDomain: "sortie.example.com"})
+	ctx := context.Background()
+
+	seedContainerApp(t, database, "desktop", "Desktop", "test:latest")
+	if err := database.CreateApp(db.Application{
+		ID: "jupyter", Name: "Jupyter", LaunchType: db.LaunchTypeWebProxy, ContainerImage: "jupyter:latest",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.CreateUser(db.User{ID: "user1", Username: "Alice", Roles: []string{"user"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := m.CreateSession(ctx, &CreateSessionRequest{AppID: "jupyter", UserID: "user1"})
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	const want = "jupyter-alice.sortie.example.com"
+	if session.Hostname != want || mockRunner.Route(session.ID) != want {
+		t.Errorf("Hostname = %q, route = %q, want %q", session.Hostname, mockRunner.Route(session.ID), want)
+	}
+
+	// A second session of the same app gets the next free hostname
+	second, err := m.CreateSession(ctx, &CreateSessionRequest{AppID: "jupyter", UserID: "user1"})
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	if second.Hostname != "jupyter-alice-2.sortie.example.com" {
+		t.Errorf("second Hostname = %q", second.Hostname)
+	}
+
+	// Only web_proxy sessions get hostnames
+	desktop, err := m.CreateSession(ctx, &CreateSessionRequest{AppID: "desktop", UserID: "user1"})
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	if desktop.Hostname != "" || mockRunner.Route(desktop.ID) != "" {
+		t.Errorf("container session got hostname %q", desktop.Hostname)
+	}
+
+	// Stopping removes the route but keeps the hostname for a restart
+	waitForStatus(t, m, session.ID, db.SessionStatusRunning)
+	if err := m.StopSession(ctx, session.ID); err != nil {
+		t.Fatalf("StopSession() error = %v", err)
+	}
+	if route := mockRunner.Route(session.ID); route != "" {
+		t.Errorf("route after stop = %q, want none", route)
+	}
+	restarted, err := m.RestartSession(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("RestartSession() error = %v", err)
+	}
+	if restarted.Hostname != want || mockRunner.Route(session.ID) != want {
+		t.Errorf("after restart Hostname = %q, route = %q, want %q", restarted.Hostname, mockRunner.Route(session.ID), want)
+	}
+	waitForStatus(t, m, session.ID, db.SessionStatusRunning)
+}
+
+// waitForStatus waits for the session manager to move a session to status.
+func waitForStatus(t *testing.T, m *Manager, sessionID string, status db.SessionStatus) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if s, _ := m.GetSession(context.Background(), sessionID); s != nil && s.Status == status {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("session %s did not reach status %s", sessionID, status)
+}
+
+func TestHostnameLabel(t *testing.T) {
+	tests := map[string]string{
+		"jupyter-alice":                "jupyter-alice",
+		"Code_Server-bob.smith":        "code-server-bob-smith",
+		"app--user@example.com":        "app-user-example-com",
+		"--":                           "session",
+		strings.Repeat("x", 70):        strings.Repeat("x", 63),
+		strings.Repeat("y", 62) + "-z": strings.Repeat("y", 62),
+	}
+	for in, want := range tests {
+		if got := hostnameLabel(in); got != want {
+			t.Errorf("hostnameLabel(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWarmPool(t *testing.T) {
+	database := newTestDB(t)
+	mockRunner := runner.NewMockRunner()
+	mockRunner.ReadyDelay = 0
+	ctx := context.Background()
+
+	app := db.Application{ID: "app1", Name: "App", LaunchType: db.LaunchTypeContainer, ContainerImage: "test:v1", WarmPoolSize: 2}
+	if err := database.CreateApp(app); err != nil {
+		t.Fatal(err)
+	}
+	seedContainerApp(t, database, "cold", "Cold", "test:latest")
+
+	follower := NewManagerWithConfig(database, ManagerConfig{Runner: mockRunner, Leader: fixedLeader(false)})
+	follower.fillWarmPools(ctx)
+	if n := mockRunner.WarmWorkloadCount(); n != 0 {
+		t.Fatalf("follower started %d warm workloads", n)
+	}
+
+	m := NewManagerWithConfig(database, ManagerConfig{Runner: mockRunner})
+	m.fillWarmPools(ctx)
+	if n := mockRunner.WarmWorkloadCount(); n != 2 {
+		t.Fatalf("%d warm workloads, want 2", n)
+	}
+
+	// A session claims a warm workload whatever its screen size
+	session, err := m.CreateSession(ctx, &CreateSessionRequest{AppID: "app1", UserID: "user1", ScreenWidth: 1280, ScreenHeight: 720})
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	if !strings.HasPrefix(session.PodName, "session-warm-") {
+		t.Errorf("PodName = %q, want a warm workload", session.PodName)
+	}
+	if w := mockRunner.Workload(session.PodName); w == nil || w.Config.SessionID != session.ID {
+		t.Errorf("claimed workload = %+v, want one owned by the session", w)
+	}
+	if n := mockRunner.WarmWorkloadCount(); n != 1 {
+		t.Errorf("%d warm workloads after a claim, want 1", n)
+	}
+	waitForStatus(t, m, session.ID, db.SessionStatusRunning)
+
+	// Apps without a pool start cold
+	cold, err := m.CreateSession(ctx, &CreateSessionRequest{AppID: "cold", UserID: "user1"})
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	if cold.PodName != "session-"+cold.ID {
+		t.Errorf("PodName = %q, want a cold start", cold.PodName)
+	}
+
+	// Changing the app replaces the pool's workloads
+	app.ContainerImage = "test:v2"
+	if err := database.UpdateApp(app); err != nil {
+		t.Fatal(err)
+	}
+	m.fillWarmPools(ctx)
+	if n := mockRunner.WarmWorkloadCount(); n != 2 {
+		t.Fatalf("%d warm workloads, want 2", n)
+	}
+	session, err = m.CreateSession(ctx, &CreateSessionRequest{AppID: "app1", UserID: "user1"})
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	if w := mockRunner.Workload(session.PodName); w == nil || w.Config.ContainerImage != "test:v2" {
+		t.Errorf("claimed workload = %+v, want one running test:v2", w)
+	}
+	waitForStatus(t, m, session.ID, db.SessionStatusRunning)
+
+	app.WarmPoolSize = 0
+	if err := database.UpdateApp(app); err != nil {
+		t.Fatal(err)
+	}
+	m.fillWarmPools(ctx)
+	if n := mockRunner.WarmWorkloadCount(); n != 0 {
+		t.Errorf("%d warm workloads after the pool was disabled, want 0", n)
+	}
+}
+
+func TestHomeVolume(t *testing.T) {
+	database := newTestDB(t)
+	mockRunner := runner.NewMockRunner()
+	mockRunner.ReadyDelay = 0
+	ctx := context.Background()
+
+	app := db.Application{
+		ID: "app1", Name: "App", LaunchType: db.LaunchTypeContainer, ContainerImage: "test:v1",
+		WarmPoolSize: 1, HomeVolume: &db.HomeVolume{MountPath: "/home/user", Size: "1Gi"},
+	}
+	if err := database.CreateApp(app); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManagerWithConfig(database, ManagerConfig{Runner: mockRunner})
+
+	// Home volumes are per user, so the app gets no warm pool
+	m.fillWarmPools(ctx)
+	if n := mockRunner.WarmWorkloadCount(); n != 0 {
+		t.Errorf("%d warm workloads for an app with a home volume, want 0", n)
+	}
+
+	first, err := m.CreateSession(ctx, &CreateSessionRequest{AppID: "app1", UserID: "user1"})
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	mount := mockRunner.Workload(first.PodName).Config.HomeVolume
+	if mount == nil || mount.MountPath != "/home/user" || mount.Name == "" {
+		t.Fatalf("home volume mount = %+v", mount)
+	}
+	waitForStatus(t, m, first.ID, db.SessionStatusRunning)
+
+	if _, err := m.DeleteUserVolume(ctx, mount.Name); !errors.Is(err, ErrUserVolumeInUse) {
+		t.Errorf("DeleteUserVolume() while running error = %v, want ErrUserVolumeInUse", err)
+	}
+	if err := m.TerminateSession(ctx, first.ID); err != nil {
+		t.Fatalf("TerminateSession() error = %v", err)
+	}
+
+	// The next session reattaches the same volume; other users get their own
+	second, err := m.CreateSession(ctx, &CreateSessionRequest{AppID: "app1", UserID: "user1"})
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	if got := mockRunner.Workload(second.PodName).Config.HomeVolume; got == nil || got.Name != mount.Name {
+		t.Errorf("second session mounted %+v, want %s", got, mount.Name)
+	}
+	other, err := m.CreateSession(ctx, &CreateSessionRequest{AppID: "app1", UserID: "user2"})
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	if got := mockRunner.Workload(other.PodName).Config.HomeVolume; got == nil || got.Name == mount.Name {
+		t.Errorf("another user mounted %+v", got)
+	}
+
+	volumes, err := m.ListUserVolumes(ctx, "user1", "")
+	if err != nil || len(volumes) != 1 || volumes[0].Name != mount.Name || volumes[0].AppID != "app1" {
+		t.Errorf("ListUserVolumes(user1) = %+v, %v", volumes, err)
+	}
+	if _, err := m.DeleteUserVolume(ctx, "missing"); !errors.Is(err, ErrUserVolumeNotFound) {
+		t.Errorf("DeleteUserVolume(missing) error = %v, want ErrUserVolumeNotFound", err)
+	}
+	waitForStatus(t, m, second.ID, db.SessionStatusRunning)
+	if err := m.TerminateSession(ctx, second.ID); err != nil {
+		t.Fatalf("TerminateSession() error = %v", err)
+	}
+	if _, err := m.DeleteUserVolume(ctx, mount.Name); err != nil {
+		t.Errorf("DeleteUserVolume() error = %v", err)
+	}
+	if volumes, _ := m.ListUserVolumes(ctx, "", ""); len(volumes) != 1 {
+		t.Errorf("%d volumes after delete, want 1", len(volumes))
+	}
+
+	// Runners without user volumes cannot launch the app
+	basic := NewManagerWithConfig(database, ManagerConfig{Runner: struct{ runner.Runner }{mockRunner}})
+	if _, err := basic.CreateSession(ctx, &CreateSessionRequest{AppID: "app1", UserID: "user1"}); !errors.Is(err, ErrUserVolumesUnsupported) {
+		t.Errorf("CreateSession() error = %v, want ErrUserVolumesUnsupported", err)
+	}
+}
+
+func TestProjectSessions(t *testing.T) {
+	database := newTestDB(t)
+	mockRunner := runner.NewMockRunner()
+	mockRunner.ReadyDelay = 0
+	ctx := context.Background()
+
+	app := db.Application{
+		ID: "app1", Name: "App", LaunchType: db.LaunchTypeContainer, ContainerImage: "test:v1", WarmPoolSize: 1,
+	}
+	if err := database.CreateApp(app); err != nil {
+		t.Fatal(err)
+	}
+	project := db.Project{
+		ID: "proj1", Name: "Genomics", MaxSessions: 1, CreatedBy: "user1",
+		Drive: &db.ProjectDrive{MountPath: "/data", Size: "1Gi"},
+	}
+	if err := database.CreateProject(project); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManagerWithConfig(database, ManagerConfig{Runner: mockRunner})
+	m.fillWarmPools(ctx)
+
+	// Sessions in a project mount its drive, so they start cold
+	session, err := m.CreateSession(ctx, &CreateSessionRequest{AppID: "app1", UserID: "user1", ProjectID: "proj1"})
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	if session.ProjectID != "proj1" {
+		t.Errorf("session project = %q, want proj1", session.ProjectID)
+	}
+	drive := mockRunner.Workload(session.PodName).Config.ProjectDrive
+	if drive == nil || drive.MountPath != "/data" || !mockRunner.HasProjectDrive("proj1") {
+		t.Fatalf("project drive mount = %+v", drive)
+	}
+	if n := mockRunner.WarmWorkloadCount(); n != 1 {
+		t.Errorf("%d warm workloads after a project launch, want the pool left as it was", n)
+	}
+	waitForStatus(t, m, session.ID, db.SessionStatusRunning)
+
+	// The project's limit counts sessions across its members
+	_, err = m.CreateSession(ctx, &CreateSessionRequest{AppID: "app1", UserID: "user2", ProjectID: "proj1"})
+	var quotaErr *QuotaExceededError
+	if !errors.As(err, &quotaErr) || quotaErr.Limit != QuotaLimitProject {
+		t.Fatalf("CreateSession() over the project limit error = %v, want a project QuotaExceededError", err)
+	}
+	if _, err := m.CreateSession(ctx, &CreateSessionRequest{AppID: "app1", UserID: "user2", ProjectID: "missing"}); err == nil {
+		t.Error("CreateSession() in a missing project succeeded")
+	}
+
+	if err := m.DeleteProject(ctx, "proj1"); !errors.Is(err, ErrProjectInUse) {
+		t.Errorf("DeleteProject() while running error = %v, want ErrProjectInUse", err)
+	}
+	if err := m.TerminateSession(ctx, session.ID); err != nil {
+		t.Fatalf("TerminateSession() error = %v", err)
+	}
+	history, _ := database.QuerySessionHistory(db.SessionHistoryFilter{ProjectID: "proj1"})
+	if history == nil || history.Total != 1 {
+		t.Errorf("project session history = %+v, want 1 run", history)
+	}
+
+	// Runners without project drives cannot launch in the project
+	basic := NewManagerWithConfig(database, ManagerConfig{Runner: struct{ runner.Runner }{mockRunner}})
+	if _, err := basic.CreateSession(ctx, &CreateSessionRequest{AppID: "app1", UserID: "user1", ProjectID: "proj1"}); !errors.Is(err, ErrProjectDrivesUnsupported) {
+		t.Errorf("CreateSession() error = %v, want ErrProjectDrivesUnsupported", err)
+	}
+
+	if err := m.DeleteProject(ctx, "proj1"); err != nil {
+		t.Fatalf("DeleteProject() error = %v", err)
+	}
+	if mockRunner.HasProjectDrive("proj1") {
+		t.Error("project drive kept after the project was deleted")
+	}
+	if p, _ := database.GetProject("proj1"); p != nil {
+		t.Errorf("project kept after delete: %+v", p)
+	}
+}
+
+func TestHibernation(t *testing.T) {
+	database := newTestDB(t)
+	mockRunner := runner.NewMockRunner()
+	mockRunner.ReadyDelay = 0
+	ctx := context.Background()
+
+	app := db.Application{
+		ID: "app1", Name: "App", LaunchType: db.LaunchTypeContainer, ContainerImage: "test:v1",
+		WarmPoolSize: 1, HibernateOnIdle: true,
+	}
+	if err := database.CreateApp(app); err != nil {
+		t.Fatal(err)
+	}
+	// Every session is idle as soon as it runs
+	m := NewManagerWithConfig(database, ManagerConfig{Runner: mockRunner, SessionTimeout: time.Millisecond})
+
+	// Workspace volumes are per session, so the app gets no warm pool
+	m.fillWarmPools(ctx)
+	if n := mockRunner.WarmWorkloadCount(); n != 0 {
+		t.Errorf("%d warm workloads for an app that hibernates, want 0", n)
+	}
+
+	session, err := m.CreateSession(ctx, &CreateSessionRequest{AppID: "app1", UserID: "user1"})
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	volume := mockRunner.Workload(session.PodName).Config.WorkspaceVolume
+	if volume == "" || !mockRunner.HasSessionVolume(session.ID) {
+		t.Fatalf("session has no workspace volume: %q", volume)
+	}
+	waitForStatus(t, m, session.ID, db.SessionStatusRunning)
+
+	// Idle timeout hibernates instead of expiring
+	if err := m.cleanup(ctx); err != nil {
+		t.Fatalf("cleanup() error = %v", err)
+	}
+	got, _ := database.GetSession(session.ID)
+	if got.Status != db.SessionStatusHibernated {
+		t.Fatalf("status after idle timeout = %s, want hibernated", got.Status)
+	}
+	if n := mockRunner.WorkloadCount(); n != 0 {
+		t.Errorf("%d workloads after hibernation, want 0", n)
+	}
+	if !mockRunner.HasSessionVolume(session.ID) {
+		t.Error("hibernation deleted the workspace volume")
+	}
+
+	if _, err := m.RestartSession(ctx, session.ID); err == nil {
+		t.Error("RestartSession() of a hibernated session succeeded")
+	}
+	resumed, err := m.ResumeSession(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("ResumeSession() error = %v", err)
+	}
+	if resumed.Status != db.SessionStatusCreating {
+		t.Errorf("resumed session status = %s, want creating", resumed.Status)
+	}
+	if got := mockRunner.Workload(resumed.PodName).Config.WorkspaceVolume; got != volume {
+		t.Errorf("resumed session mounted %q, want %q", got, volume)
+	}
+	waitForStatus(t, m, session.ID, db.SessionStatusRunning)
+	if _, err := m.ResumeSession(ctx, session.ID); err == nil {
+		t.Error("ResumeSession() of a running session succeeded")
+	}
+
+	// Deleting a hibernated session deletes its volume and records no
+	// further run
+	if err := m.cleanup(ctx); err != nil {
+		t.Fatalf("cleanup() error = %v", err)
+	}
+	if err := m.TerminateSession(ctx, session.ID); err != nil {
+		t.Fatalf("TerminateSession() error = %v", err)
+	}
+	if mockRunner.HasSessionVolume(session.ID) {
+		t.Error("workspace volume kept after the session was deleted")
+	}
+	page, err := database.QuerySessionHistory(db.SessionHistoryFilter{AppID: "app1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 2 || page.Sessions[0].FinalStatus != db.SessionStatusHibernated {
+		t.Errorf("history = %d runs, latest %+v; want 2 hibernated runs", page.Total, page.Sessions)
+	}
+
+	// Volumes left without a resumable session are deleted once old enough
+	mockRunner.AddSessionVolume("gone", time.Now().Add(-time.Hour))
+	mockRunner.AddSessionVolume("launching", time.Now())
+	if err := m.cleanup(ctx); err != nil {
+		t.Fatalf("cleanup() error = %v", err)
+	}
+	if mockRunner.HasSessionVolume("gone") || !mockRunner.HasSessionVolume("launching") {
+		t.Errorf("orphaned volumes: gone kept = %v, launching kept = %v",
+			mockRunner.HasSessionVolume("gone"), mockRunner.HasSessionVolume("launching"))
+	}
+
+	// Runners without session volumes cannot launch the app
+	basic := NewManagerWithConfig(database, ManagerConfig{Runner: struct{ runner.Runner }{mockRunner}})
+	if _, err := basic.CreateSession(ctx, &CreateSessionRequest{AppID: "app1", UserID: "user1"}); !errors.Is(err, ErrSessionVolumesUnsupported) {
+		t.Errorf("CreateSession() error = %v, want ErrSessionVolumesUnsupported", err)
+	}
+}
+
+func TestShutdown_FinishesLaunches(t *testing.T) {
+	database := newTestDB(t)
+	mockRunner := runner.NewMockRunner()
+	mockRunner.ReadyDelay = 200 * time.Millisecond
+	m := NewManagerWithConfig(database, ManagerConfig{Runner: mockRunner, CleanupInterval: time.Hour})
+	m.Start()
+	ctx := context.Background()
+	seedContainerApp(t, database, "app1", "App", "test:latest")
+
+	session, err := m.CreateSession(ctx, &CreateSessionRequest{AppID: "app1", UserID: "user1"})
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := m.Shutdown(shutdownCtx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if !m.ShuttingDown() {
+		t.Error("ShuttingDown() = false after Shutdown")
+	}
+	// The launch in flight was waited for
+	if s, _ := m.GetSession(ctx, session.ID); s == nil || s.Status != db.SessionStatusRunning {
+		t.Errorf("session after shutdown = %+v, want running", s)
+	}
+
+	// No new launches are taken
+	if _, err := m.CreateSession(ctx, &CreateSessionRequest{AppID: "app1", UserID: "user1"}); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("CreateSession() error = %v, want ErrShuttingDown", err)
+	}
+	if err := m.StopSession(ctx, session.ID); err != nil {
+		t.Fatalf("StopSession() error = %v", err)
+	}
+	if _, err := m.RestartSession(ctx, session.ID); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("RestartSession() error = %v, want ErrShuttingDown", err)
+	}
+
+	// Shutting down again does nothing
+	if err := m.Shutdown(shutdownCtx); err != nil {
+		t.Errorf("second Shutdown() error = %v", err)
+	}
+	m.Stop()
+}
+
+func TestShutdown_FailsLaunchesAtDeadline(t *testing.T) {
+	database := newTestDB(t)
+	mockRunner := runner.NewMockRunner()
+	mockRunner.ReadyDelay = time.Minute
+	m := NewManagerWithConfig(database, ManagerConfig{Runner: mockRunner})
+	ctx := context.Background()
+	seedContainerApp(t, database, "app1", "App", "test:latest")
+
+	session, err := m.CreateSession(ctx, &CreateSessionRequest{AppID: "app1", UserID: "user1"})
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := m.Shutdown(shutdownCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Shutdown() took %v", elapsed)
+	}
+
+	// The abandoned launch is recorded as failed and its workload deleted
+	s, _ := m.GetSession(ctx, session.ID)
+	if s == nil || s.Status != db.SessionStatusFailed {
+		t.Errorf("session after shutdown = %+v, want failed", s)
+	}
+	if n := mockRunner.WorkloadCount(); n != 0 {
+		t.Errorf("%d workloads left after shutdown, want 0", n)
+	}
+	launches, err := database.ListSessionLaunches("app1", time.Now().Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(launches) != 1 || launches[0].Succeeded || !strings.Contains(launches[0].Reason, ErrShuttingDown.Error()) {
+		t.Errorf("launches = %+v, want one failed by the shutdown", launches)
+	}
+}
+
+func TestShutdown_RejectsQueuedLaunches(t *testing.T) {
+	database := newTestDB(t)
+	mockRunner := runner.NewMockRunner()
+	mockRunner.ReadyDelay = 0
+	m := NewManagerWithConfig(database, ManagerConfig{
+		Runner:            mockRunner,
+		MaxGlobalSessions: 1,
+		QueueMaxSize:      5,
+		QueueTimeout:      10 * time.Second,
+	})
+	ctx := context.Background()
+	seedContainerApp(t, database, "app1", "App", "test:latest")
+
+	if _, err := m.CreateSession(ctx, &CreateSessionRequest{AppID: "app1", UserID: "user1"}); err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	queued := make(chan error, 1)
+	go func() {
+		_, err := m.CreateSession(ctx, &CreateSessionRequest{AppID: "app1", UserID: "user2"})
+		queued <- err
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for m.Queue().Len() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := m.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	select {
+	case err := <-queued:
+		if !errors.Is(err, ErrShuttingDown) {
+			t.Errorf("queued CreateSession() error = %v, want ErrShuttingDown", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued CreateSession() did not return after shutdown")
+	}
+}
@@ -0,0 +1,124 @@
+package sessions
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"slices"
+)
+
+// quotaRejection labels the launches turned away by a session limit.
+type quotaRejection struct {
+	tenantID string
+	limit    QuotaLimit
+}
+
+// countQuotaRejection counts a launch in a tenant that failed with err, if
+// err is a session limit being reached.
+func (m *Manager) countQuotaRejection(tenantID string, err error) {
+	var quotaErr *QuotaExceededError
+	if !errors.As(err, &quotaErr) {
+		return
+	}
+	m.rejectionsMu.Lock()
+	defer m.rejectionsMu.Unlock()
+	if m.quotaRejections == nil {
+		m.quotaRejections = make(map[quotaRejection]int64)
+	}
+	m.quotaRejections[quotaRejection{tenantID, quotaErr.Limit}]++
+}
+
+// WritePrometheus writes the load status, per tenant where sessions
+// belong to one, and the launches turned away by session limits or that
+// timed out in the queue, in the Prometheus text exposition format.
+// Counters are for this replica; gauges read from the database are for
+// all replicas.
+func (h *BackpressureHandler) WritePrometheus(w io.Writer) {
+	if h == nil {
+		return
+	}
+	status := h.GetLoadStatus()
+	active, err := h.manager.db.CountActiveSessionsPerTenant()
+	if err != nil {
+		log.Printf("Warning: failed to count active sessions per tenant: %v", err)
+	}
+	tenants, err := h.manager.db.ListTenants()
+	if err != nil {
+		log.Printf("Warning: failed to list tenants for metrics: %v", err)
+	}
+	var depths map[string]int
+	var timeouts map[string]int64
+	if h.queue != nil {
+		depths = h.queue.DepthByTenant()
+		timeouts = h.queue.TimeoutsByTenant()
+	}
+	h.manager.rejectionsMu.Lock()
+	rejections := maps.Clone(h.manager.quotaRejections)
+	h.manager.rejectionsMu.Unlock()
+
+	header := func(name, kind, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	metric := func(name, kind, help string, value float64) {
+		header(name, kind, help)
+		fmt.Fprintf(w, "%s %g\n", name, value)
+	}
+	perTenant := func(name, kind, help string, values map[string]float64) {
+		header(name, kind, help)
+		for _, tenant := range slices.Sorted(maps.Keys(values)) {
+			fmt.Fprintf(w, "%s{tenant=%q} %g\n", name, tenant, values[tenant])
+		}
+	}
+
+	activeValues := make(map[string]float64, len(active))
+	for tenant, n := range active {
+		activeValues[tenant] = float64(n)
+	}
+	perTenant("sortie_active_sessions", "gauge", "Sessions creating or running.", activeValues)
+	metric("sortie_max_sessions", "gauge", "Global limit on active sessions (0 = unlimited).",
+		float64(status.MaxSessions))
+	metric("sortie_load_factor", "gauge", "Ratio of active sessions to the global limit.",
+		status.LoadFactor)
+
+	tenantMax := make(map[string]float64)
+	tenantLoad := make(map[string]float64)
+	for _, t := range tenants {
+		if t.Quotas.MaxTotalSessions <= 0 {
+			continue
+		}
+		tenantMax[t.ID] = float64(t.Quotas.MaxTotalSessions)
+		tenantLoad[t.ID] = min(float64(active[t.ID])/float64(t.Quotas.MaxTotalSessions), 1)
+	}
+	perTenant("sortie_tenant_max_sessions", "gauge", "Limit on the active sessions of tenants that have one.", tenantMax)
+	perTenant("sortie_tenant_load_factor", "gauge", "Ratio of a tenant's active sessions to its limit.", tenantLoad)
+
+	depthValues := make(map[string]float64, len(depths))
+	for tenant, n := range depths {
+		depthValues[tenant] = float64(n)
+	}
+	perTenant("sortie_queue_depth", "gauge", "Session launches waiting in this replica's queue.", depthValues)
+	metric("sortie_queue_max_size", "gauge", "Launches this replica's queue holds (0 = queueing disabled).",
+		float64(status.MaxQueueSize))
+	accepting := 0.0
+	if status.Accepting {
+		accepting = 1
+	}
+	metric("sortie_accepting_sessions", "gauge", "Whether new sessions are accepted (1) or turned away as overloaded (0).",
+		accepting)
+
+	header("sortie_quota_rejections_total", "counter", "Session launches turned away by a session limit, by tenant and limit.")
+	keys := slices.SortedFunc(maps.Keys(rejections), func(a, b quotaRejection) int {
+		return cmp.Or(cmp.Compare(a.tenantID, b.tenantID), cmp.Compare(a.limit, b.limit))
+	})
+	for _, k := range keys {
+		fmt.Fprintf(w, "sortie_quota_rejections_total{tenant=%q,limit=%q} %d\n", k.tenantID, k.limit, rejections[k])
+	}
+	timeoutValues := make(map[string]float64, len(timeouts))
+	for tenant, n := range timeouts {
+		timeoutValues[tenant] = float64(n)
+	}
+	perTenant("sortie_queue_timeouts_total", "counter", "Session launches that timed out waiting in the queue.", timeoutValues)
+}
@@ -0,0 +1,307 @@
+package sessions
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/runner"
+)
+
+// Layers an effective policy setting can come from, most specific first.
+const (
+	PolicyLayerSession  = "session"
+	PolicyLayerApp      = "app"
+	PolicyLayerCategory = "category"
+	PolicyLayerTenant   = "tenant"
+	PolicyLayerSetting  = "setting"
+	PolicyLayerEnv      = "env"
+)
+
+// PolicySource says where an effective policy setting came from.
+type PolicySource struct {
+	Layer string `json:"layer"`
+	// ID is the app, category, tenant, or session that set the value.
+	ID string `json:"id,omitempty"`
+	// Key is the field, setting, or environment variable within the layer
+	// that holds the value, e.g. "quotas.default_cpu_limit" for a tenant or
+	// "SORTIE_SESSION_TIMEOUT" for env.
+	Key string `json:"key,omitempty"`
+}
+
+// EffectivePolicy is the session settings an app's sessions get once its
+// own settings are filled in from its category, tenant, and the server.
+type EffectivePolicy struct {
+	AppID           string             `json:"app_id"`
+	SessionID       string             `json:"session_id,omitempty"`
+	ResourceLimits  db.ResourceLimits  `json:"resource_limits"`
+	EgressPolicy    *db.EgressPolicy   `json:"egress_policy,omitempty"`
+	IdleTimeout     int64              `json:"idle_timeout"` // Seconds
+	RecordingPolicy db.RecordingPolicy `json:"recording_policy"`
+	// LockTimeout is how long in seconds a session may go without input
+	// before its stream is locked. 0 never locks it.
+	LockTimeout int64 `json:"lock_timeout"`
+	// ImagePullSecret names the Secret the session's images are pulled
+	// with, from the app or else its tenant.
+	ImagePullSecret string `json:"image_pull_secret,omitempty"`
+	// Sources says where each setting came from, keyed by its JSON name
+	// (resource limits by field, e.g. "cpu_limit").
+	Sources map[string]PolicySource `json:"sources"`
+}
+
+// policyLevel is one level of the inheritance chain.
+type policyLevel struct {
+	source   PolicySource
+	defaults db.PolicyDefaults
+	// keys overrides source.Key for individual settings.
+	keys map[string]string
+}
+
+// sourceOf returns where field came from if this level supplied it.
+func (l policyLevel) sourceOf(field string) PolicySource {
+	src := l.source
+	if key, ok := l.keys[field]; ok {
+		src.Key = key
+	}
+	return src
+}
+
+// EffectivePolicy resolves the session settings for app.
+func (m *Manager) EffectivePolicy(app *db.Application) (*EffectivePolicy, error) {
+	return m.effectivePolicy(m.db, app)
+}
+
+// effectivePolicy resolves the session settings for app, reading its
+// category and tenant from store.
+func (m *Manager) effectivePolicy(store *db.DB, app *db.Application) (*EffectivePolicy, error) {
+	var category *db.Category
+	if app.Category != "" {
+		var err error
+		category, err = store.GetCategoryByName(app.Category)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get category: %w", err)
+		}
+	}
+	var tenant *db.Tenant
+	if app.TenantID != "" {
+		var err error
+		tenant, err = store.GetTenant(app.TenantID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tenant: %w", err)
+		}
+	}
+	return m.resolvePolicy(app, category, tenant), nil
+}
+
+// SessionPolicy resolves the settings a session runs with: its app's
+// effective policy, with the idle timeout stored on the session.
+func (m *Manager) SessionPolicy(session *db.Session, app *db.Application) (*EffectivePolicy, error) {
+	p, err := m.EffectivePolicy(app)
+	if err != nil {
+		return nil, err
+	}
+	p.SessionID = session.ID
+	switch {
+	case session.IdleTimeout <= 0:
+		// A stored 0 follows the server's timeout, whatever the app says now.
+		p.IdleTimeout = int64(m.sessionTimeout.Seconds())
+		p.Sources["idle_timeout"] = PolicySource{Layer: PolicyLayerEnv, Key: "SORTIE_SESSION_TIMEOUT"}
+	case session.IdleTimeout != p.IdleTimeout:
+		// Requested at launch, or the policy has changed since.
+		p.IdleTimeout = session.IdleTimeout
+		p.Sources["idle_timeout"] = PolicySource{Layer: PolicyLayerSession, ID: session.ID}
+	}
+	return p, nil
+}
+
+// resolvePolicy fills in each of app's settings from the first level of
+// app, category, tenant, and server that sets it.
+func (m *Manager) resolvePolicy(app *db.Application, category *db.Category, tenant *db.Tenant) *EffectivePolicy {
+	levels := []policyLevel{{
+		source: PolicySource{Layer: PolicyLayerApp, ID: app.ID},
+		defaults: db.PolicyDefaults{
+			ResourceLimits:  app.ResourceLimits,
+			EgressPolicy:    app.EgressPolicy,
+			IdleTimeout:     app.IdleTimeout,
+			RecordingPolicy: app.RecordingPolicy,
+			LockTimeout:     app.LockTimeout,
+		},
+	}}
+	if category != nil && category.Defaults != nil {
+		levels = append(levels, policyLevel{
+			source:   PolicySource{Layer: PolicyLayerCategory, ID: category.ID},
+			defaults: *category.Defaults,
+		})
+	}
+	if tenant != nil {
+		if tenant.Settings.Defaults != nil {
+			levels = append(levels, policyLevel{
+				source:   PolicySource{Layer: PolicyLayerTenant, ID: tenant.ID, Key: "defaults"},
+				defaults: *tenant.Settings.Defaults,
+			})
+		}
+		// The tenant's default_* quotas predate its defaults and fill in
+		// the resource limits they leave out.
+		levels = append(levels, policyLevel{
+			source: PolicySource{Layer: PolicyLayerTenant, ID: tenant.ID},
+			defaults: db.PolicyDefaults{ResourceLimits: &db.ResourceLimits{
+				CPURequest:    tenant.Quotas.DefaultCPURequest,
+				CPULimit:      tenant.Quotas.DefaultCPULimit,
+				MemoryRequest: tenant.Quotas.DefaultMemRequest,
+				MemoryLimit:   tenant.Quotas.DefaultMemLimit,
+			}},
+			keys: map[string]string{
+				"cpu_request":    "quotas.default_cpu_request",
+				"cpu_limit":      "quotas.default_cpu_limit",
+				"memory_request": "quotas.default_mem_request",
+				"memory_limit":   "quotas.default_mem_limit",
+			},
+		})
+	}
+	levels = append(levels, policyLevel{
+		source:   PolicySource{Layer: PolicyLayerSetting, Key: "recording_auto_record"},
+		defaults: db.PolicyDefaults{RecordingPolicy: m.globalRecordingPolicy()},
+	}, policyLevel{
+		source: PolicySource{Layer: PolicyLayerEnv},
+		defaults: db.PolicyDefaults{
+			ResourceLimits: &db.ResourceLimits{
+				CPURequest:    m.defaultCPURequest,
+				CPULimit:      m.defaultCPULimit,
+				MemoryRequest: m.defaultMemRequest,
+				MemoryLimit:   m.defaultMemLimit,
+			},
+			IdleTimeout: int64(m.sessionTimeout.Seconds()),
+		},
+		keys: map[string]string{
+			"cpu_request":    "SORTIE_DEFAULT_CPU_REQUEST",
+			"cpu_limit":      "SORTIE_DEFAULT_CPU_LIMIT",
+			"memory_request": "SORTIE_DEFAULT_MEM_REQUEST",
+			"memory_limit":   "SORTIE_DEFAULT_MEM_LIMIT",
+			"idle_timeout":   "SORTIE_SESSION_TIMEOUT",
+		},
+	})
+
+	p := &EffectivePolicy{AppID: app.ID, Sources: make(map[string]PolicySource)}
+	for _, field := range []struct {
+		name string
+		dst  *string
+		get  func(*db.ResourceLimits) string
+	}{
+		{"cpu_request", &p.ResourceLimits.CPURequest, func(l *db.ResourceLimits) string { return l.CPURequest }},
+		{"cpu_limit", &p.ResourceLimits.CPULimit, func(l *db.ResourceLimits) string { return l.CPULimit }},
+		{"memory_request", &p.ResourceLimits.MemoryRequest, func(l *db.ResourceLimits) string { return l.MemoryRequest }},
+		{"memory_limit", &p.ResourceLimits.MemoryLimit, func(l *db.ResourceLimits) string { return l.MemoryLimit }},
+	} {
+		for _, l := range levels {
+			if l.defaults.ResourceLimits != nil {
+				if v := field.get(l.defaults.ResourceLimits); v != "" {
+					*field.dst = v
+					p.Sources[field.name] = l.sourceOf(field.name)
+					break
+				}
+			}
+		}
+	}
+	for _, l := range levels {
+		if l.defaults.EgressPolicy != nil && l.defaults.EgressPolicy.Mode != "" {
+			p.EgressPolicy = l.defaults.EgressPolicy
+			p.Sources["egress_policy"] = l.sourceOf("egress_policy")
+			break
+		}
+	}
+	for _, l := range levels {
+		if l.defaults.IdleTimeout > 0 {
+			p.IdleTimeout = l.defaults.IdleTimeout
+			p.Sources["idle_timeout"] = l.sourceOf("idle_timeout")
+			break
+		}
+	}
+	for _, l := range levels {
+		if l.defaults.RecordingPolicy != "" {
+			p.RecordingPolicy = l.defaults.RecordingPolicy
+			p.Sources["recording_policy"] = l.sourceOf("recording_policy")
+			break
+		}
+	}
+	for _, l := range levels {
+		if l.defaults.LockTimeout > 0 {
+			p.LockTimeout = l.defaults.LockTimeout
+			p.Sources["lock_timeout"] = l.sourceOf("lock_timeout")
+			break
+		}
+	}
+	// Pull secrets grant access to a registry, so categories cannot set one
+	switch {
+	case app.ImagePullSecret != "":
+		p.ImagePullSecret = app.ImagePullSecret
+		p.Sources["image_pull_secret"] = PolicySource{Layer: PolicyLayerApp, ID: app.ID}
+	case tenant != nil && tenant.Settings.ImagePullSecret != "":
+		p.ImagePullSecret = tenant.Settings.ImagePullSecret
+		p.Sources["image_pull_secret"] = PolicySource{Layer: PolicyLayerTenant, ID: tenant.ID, Key: "settings.image_pull_secret"}
+	}
+	return p
+}
+
+// mergeResourceLimits returns l with its empty fields taken from fallback.
+func mergeResourceLimits(l, fallback db.ResourceLimits) db.ResourceLimits {
+	if l.CPURequest == "" {
+		l.CPURequest = fallback.CPURequest
+	}
+	if l.CPULimit == "" {
+		l.CPULimit = fallback.CPULimit
+	}
+	if l.MemoryRequest == "" {
+		l.MemoryRequest = fallback.MemoryRequest
+	}
+	if l.MemoryLimit == "" {
+		l.MemoryLimit = fallback.MemoryLimit
+	}
+	return l
+}
+
+// globalRecordingPolicy returns the server-wide recording policy, set by
+// admins with the recording_auto_record setting.
+func (m *Manager) globalRecordingPolicy() db.RecordingPolicy {
+	if val, err := m.db.GetSetting("recording_auto_record"); err == nil && val == "true" {
+		return db.RecordingPolicyAuto
+	}
+	return db.RecordingPolicyManual
+}
+
+// sessionIdleTimeout returns the idle timeout to store on a new session:
+// the one requested, else the policy's unless it is the server's, which
+// sessions follow through a stored 0 so changes to it apply to them.
+func (p *EffectivePolicy) sessionIdleTimeout(requested int64) int64 {
+	if requested > 0 || p.Sources["idle_timeout"].Layer == PolicyLayerEnv {
+		return requested
+	}
+	return p.IdleTimeout
+}
+
+// applyResourceLimits sets the policy's CPU and memory on a workload.
+func (p *EffectivePolicy) applyResourceLimits(wc *runner.WorkloadConfig) {
+	if p.ResourceLimits.CPURequest != "" {
+		wc.CPURequest = p.ResourceLimits.CPURequest
+	}
+	if p.ResourceLimits.CPULimit != "" {
+		wc.CPULimit = p.ResourceLimits.CPULimit
+	}
+	if p.ResourceLimits.MemoryRequest != "" {
+		wc.MemoryRequest = p.ResourceLimits.MemoryRequest
+	}
+	if p.ResourceLimits.MemoryLimit != "" {
+		wc.MemoryLimit = p.ResourceLimits.MemoryLimit
+	}
+}
+
+// applyDefaultResourceLimits sets CPU and memory on a workload config from
+// the app's effective policy. If the category or tenant cannot be read,
+// only the app's limits and the server defaults apply.
+func (m *Manager) applyDefaultResourceLimits(wc *runner.WorkloadConfig, app *db.Application) {
+	p, err := m.EffectivePolicy(app)
+	if err != nil {
+		log.Printf("Warning: failed to resolve policy for app %s: %v", app.ID, err)
+		p = m.resolvePolicy(app, nil, nil)
+	}
+	p.applyResourceLimits(wc)
+}
@@ -0,0 +1,211 @@
+package sessions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/runner"
+)
+
+func TestEffectivePolicy_Inheritance(t *testing.T) {
+	database := newTestDB(t)
+	m := NewManagerWithConfig(database, ManagerConfig{
+		SessionTimeout:    time.Hour,
+		DefaultCPURequest: "100m",
+		DefaultCPULimit:   "1",
+		DefaultMemRequest: "256Mi",
+		DefaultMemLimit:   "1Gi",
+	})
+
+	if err := database.CreateTenant(db.Tenant{
+		ID:   "acme",
+		Name: "Acme",
+		Slug: "acme",
+		Settings: db.TenantSettings{
+			Defaults: &db.PolicyDefaults{
+				EgressPolicy:    &db.EgressPolicy{Mode: "denylist"},
+				RecordingPolicy: db.RecordingPolicyAuto,
+				LockTimeout:     900,
+			},
+			ImagePullSecret: "acme-registry",
+		},
+		Quotas: db.TenantQuotas{DefaultMemLimit: "4Gi"},
+	}); err != nil {
+		t.Fatalf("CreateTenant: %v", err)
+	}
+	if err := database.CreateCategory(db.Category{
+		ID:       "cat-lab",
+		Name:     "Lab",
+		TenantID: "acme",
+		Defaults: &db.PolicyDefaults{
+			ResourceLimits: &db.ResourceLimits{CPULimit: "2"},
+			EgressPolicy:   &db.EgressPolicy{Mode: "allowlist", Rules: []db.EgressRule{{CIDR: "10.0.0.0/8"}}},
+			IdleTimeout:    600,
+		},
+	}); err != nil {
+		t.Fatalf("CreateCategory: %v", err)
+	}
+
+	app := &db.Application{
+		ID:             "lab-app",
+		Category:       "Lab",
+		TenantID:       "acme",
+		ResourceLimits: &db.ResourceLimits{CPURequest: "250m"},
+	}
+	p, err := m.EffectivePolicy(app)
+	if err != nil {
+		t.Fatalf("EffectivePolicy: %v", err)
+	}
+
+	want := db.ResourceLimits{CPURequest: "250m", CPULimit: "2", MemoryRequest: "256Mi", MemoryLimit: "4Gi"}
+	if p.ResourceLimits != want {
+		t.Errorf("ResourceLimits = %+v, want %+v", p.ResourceLimits, want)
+	}
+	if p.EgressPolicy == nil || p.EgressPolicy.Mode != "allowlist" {
+		t.Errorf("EgressPolicy = %+v, want the category's allowlist", p.EgressPolicy)
+	}
+	if p.IdleTimeout != 600 {
+		t.Errorf("IdleTimeout = %d, want 600", p.IdleTimeout)
+	}
+	if p.RecordingPolicy != db.RecordingPolicyAuto {
+		t.Errorf("RecordingPolicy = %q, want auto", p.RecordingPolicy)
+	}
+	if p.ImagePullSecret != "acme-registry" {
+		t.Errorf("ImagePullSecret = %q, want the tenant's acme-registry", p.ImagePullSecret)
+	}
+	if p.LockTimeout != 900 {
+		t.Errorf("LockTimeout = %d, want the tenant's 900", p.LockTimeout)
+	}
+	for field, source := range map[string]PolicySource{
+		"cpu_request":       {Layer: PolicyLayerApp, ID: "lab-app"},
+		"cpu_limit":         {Layer: PolicyLayerCategory, ID: "cat-lab"},
+		"memory_request":    {Layer: PolicyLayerEnv, Key: "SORTIE_DEFAULT_MEM_REQUEST"},
+		"memory_limit":      {Layer: PolicyLayerTenant, ID: "acme", Key: "quotas.default_mem_limit"},
+		"egress_policy":     {Layer: PolicyLayerCategory, ID: "cat-lab"},
+		"idle_timeout":      {Layer: PolicyLayerCategory, ID: "cat-lab"},
+		"recording_policy":  {Layer: PolicyLayerTenant, ID: "acme", Key: "defaults"},
+		"lock_timeout":      {Layer: PolicyLayerTenant, ID: "acme", Key: "defaults"},
+		"image_pull_secret": {Layer: PolicyLayerTenant, ID: "acme", Key: "settings.image_pull_secret"},
+	} {
+		if p.Sources[field] != source {
+			t.Errorf("Sources[%s] = %+v, want %+v", field, p.Sources[field], source)
+		}
+	}
+
+	// The app's own settings win over everything
+	app.IdleTimeout = 60
+	app.RecordingPolicy = db.RecordingPolicyManual
+	app.ImagePullSecret = "lab-registry"
+	p, err = m.EffectivePolicy(app)
+	if err != nil {
+		t.Fatalf("EffectivePolicy: %v", err)
+	}
+	if p.IdleTimeout != 60 || p.RecordingPolicy != db.RecordingPolicyManual || p.ImagePullSecret != "lab-registry" {
+		t.Errorf("policy = %d, %q, %q; want the app's 60, manual, lab-registry", p.IdleTimeout, p.RecordingPolicy, p.ImagePullSecret)
+	}
+}
+
+func TestEffectivePolicy_GlobalDefaults(t *testing.T) {
+	database := newTestDB(t)
+	m := NewManagerWithConfig(database, ManagerConfig{SessionTimeout: 2 * time.Hour, DefaultCPULimit: "1"})
+
+	p, err := m.EffectivePolicy(&db.Application{ID: "plain"})
+	if err != nil {
+		t.Fatalf("EffectivePolicy: %v", err)
+	}
+	if p.ResourceLimits.CPULimit != "1" || p.Sources["cpu_limit"].Key != "SORTIE_DEFAULT_CPU_LIMIT" {
+		t.Errorf("CPULimit = %q from %+v, want 1 from SORTIE_DEFAULT_CPU_LIMIT", p.ResourceLimits.CPULimit, p.Sources["cpu_limit"])
+	}
+	if p.EgressPolicy != nil {
+		t.Errorf("EgressPolicy = %+v, want nil", p.EgressPolicy)
+	}
+	if _, ok := p.Sources["lock_timeout"]; p.LockTimeout != 0 || ok {
+		t.Errorf("LockTimeout = %d, want 0 with no source so sessions never lock", p.LockTimeout)
+	}
+	if p.IdleTimeout != 7200 {
+		t.Errorf("IdleTimeout = %d, want 7200", p.IdleTimeout)
+	}
+	if p.RecordingPolicy != db.RecordingPolicyManual || p.Sources["recording_policy"].Layer != PolicyLayerSetting {
+		t.Errorf("RecordingPolicy = %q from %+v, want manual from the setting", p.RecordingPolicy, p.Sources["recording_policy"])
+	}
+	if got := p.sessionIdleTimeout(0); got != 0 {
+		t.Errorf("sessionIdleTimeout(0) = %d, want 0 so the global timeout applies", got)
+	}
+
+	if err := database.SetSetting("recording_auto_record", "true"); err != nil {
+		t.Fatalf("SetSetting: %v", err)
+	}
+	p, _ = m.EffectivePolicy(&db.Application{ID: "plain"})
+	if p.RecordingPolicy != db.RecordingPolicyAuto {
+		t.Errorf("RecordingPolicy = %q, want auto from the setting", p.RecordingPolicy)
+	}
+}
+
+func TestCreateSession_CategoryDefaults(t *testing.T) {
+	database := newTestDB(t)
+	mockRunner := runner.NewMockRunner()
+	m := NewManagerWithConfig(database, ManagerConfig{Runner: mockRunner})
+
+	if err := database.CreateCategory(db.Category{
+		ID:   "cat-secure",
+		Name: "Secure",
+		Defaults: &db.PolicyDefaults{
+			EgressPolicy: &db.EgressPolicy{Mode: "allowlist"},
+			IdleTimeout:  900,
+		},
+	}); err != nil {
+		t.Fatalf("CreateCategory: %v", err)
+	}
+	if err := database.CreateApp(db.Application{
+		ID:             "secure-app",
+		Name:           "Secure App",
+		Category:       "Secure",
+		LaunchType:     db.LaunchTypeContainer,
+		ContainerImage: "nginx:latest",
+	}); err != nil {
+		t.Fatalf("CreateApp: %v", err)
+	}
+
+	session, err := m.CreateSession(t.Context(), &CreateSessionRequest{AppID: "secure-app", UserID: "user-1"})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if session.IdleTimeout != 900 {
+		t.Errorf("IdleTimeout = %d, want the category's 900", session.IdleTimeout)
+	}
+	if policy := mockRunner.NetworkPolicy(session.ID); policy == nil || policy.Mode != "allowlist" {
+		t.Errorf("network policy = %+v, want the category's allowlist", policy)
+	}
+}
+
+func TestSessionPolicy_IdleTimeout(t *testing.T) {
+	database := newTestDB(t)
+	m := NewManagerWithConfig(database, ManagerConfig{SessionTimeout: time.Hour})
+	app := &db.Application{ID: "timed", IdleTimeout: 600}
+
+	tests := []struct {
+		name    string
+		stored  int64
+		want    int64
+		wantSrc PolicySource
+	}{
+		{"from the app", 600, 600, PolicySource{Layer: PolicyLayerApp, ID: "timed"}},
+		{"requested at launch", 120, 120, PolicySource{Layer: PolicyLayerSession, ID: "sess-1"}},
+		{"follows the server", 0, 3600, PolicySource{Layer: PolicyLayerEnv, Key: "SORTIE_SESSION_TIMEOUT"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := m.SessionPolicy(&db.Session{ID: "sess-1", IdleTimeout: tt.stored}, app)
+			if err != nil {
+				t.Fatalf("SessionPolicy: %v", err)
+			}
+			if p.SessionID != "sess-1" {
+				t.Errorf("SessionID = %q, want sess-1", p.SessionID)
+			}
+			if p.IdleTimeout != tt.want || p.Sources["idle_timeout"] != tt.wantSrc {
+				t.Errorf("IdleTimeout = %d from %+v, want %d from %+v", p.IdleTimeout, p.Sources["idle_timeout"], tt.want, tt.wantSrc)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/runner"
+)
+
+var (
+	// ErrPrePullUnsupported is returned when the runner cannot pull
+	// images onto nodes ahead of launches.
+	ErrPrePullUnsupported = errors.New("runner does not support image pre-pulls")
+
+	// ErrNoImages is returned when pre-pulling an app that launches no
+	// workload, such as a URL app.
+	ErrNoImages = errors.New("application has no images to pull")
+)
+
+// PrePullApp starts pulling the images of app's session workload, with its
+// sidecars, pull secret, and node scheduling, onto the nodes its sessions
+// may run on.
+func (m *Manager) PrePullApp(ctx context.Context, app *db.Application) error {
+	ppr, ok := m.runner.(runner.ImagePrePullRunner)
+	if !ok {
+		return ErrPrePullUnsupported
+	}
+	if app.LaunchType != db.LaunchTypeContainer && app.LaunchType != db.LaunchTypeWebProxy {
+		return fmt.Errorf("application %s: %w", app.ID, ErrNoImages)
+	}
+	wc, err := m.warmWorkloadConfig(ctx, app)
+	if err != nil {
+		return err
+	}
+	wc.SessionID = "prepull"
+	return ppr.StartPrePull(ctx, wc)
+}
+
+// ListPrePulls returns the pre-pulls that have not been deleted.
+func (m *Manager) ListPrePulls(ctx context.Context) ([]runner.PrePull, error) {
+	ppr, ok := m.runner.(runner.ImagePrePullRunner)
+	if !ok {
+		return nil, ErrPrePullUnsupported
+	}
+	return ppr.ListPrePulls(ctx)
+}
+
+// DeletePrePull stops and removes an app's pre-pull.
+func (m *Manager) DeletePrePull(ctx context.Context, appID string) error {
+	ppr, ok := m.runner.(runner.ImagePrePullRunner)
+	if !ok {
+		return ErrPrePullUnsupported
+	}
+	return ppr.DeletePrePull(ctx, appID)
+}
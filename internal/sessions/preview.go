@@ -0,0 +1,84 @@
+package sessions
+
+import (
+	"errors"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// LaunchPreview is what launching an app would consume, for users to review
+// before they launch it.
+type LaunchPreview struct {
+	AppID string `json:"app_id"`
+	// ResourceLimits are the app's effective requests and limits.
+	ResourceLimits db.ResourceLimits `json:"resource_limits"`
+	// CPUCores and MemoryGiB are the resources the session would request,
+	// counted as cost reports count them: a resource with no request
+	// counts at its limit.
+	CPUCores  float64 `json:"cpu_cores"`
+	MemoryGiB float64 `json:"memory_gib"`
+	// Quota is the user's session usage before the launch.
+	Quota *QuotaStatus `json:"quota"`
+	// Blocked is why the launch would be refused now, if it would be.
+	Blocked string `json:"blocked,omitempty"`
+	// Limit is the session limit that Blocked or Queued is about.
+	Limit QuotaLimit `json:"limit,omitempty"`
+	// Queued is set when the launch would wait in the session queue for
+	// capacity.
+	Queued bool `json:"queued,omitempty"`
+}
+
+// PreviewLaunch reports what launching app would consume for userID, in
+// the project projectID if set, and whether the launch would be refused
+// now, without launching it.
+func (m *Manager) PreviewLaunch(app *db.Application, userID, projectID string) (*LaunchPreview, error) {
+	policy, err := m.EffectivePolicy(app)
+	if err != nil {
+		return nil, err
+	}
+	quota, err := m.GetQuotaStatusWithTenant(userID, app.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	limits := policy.ResourceLimits
+	p := &LaunchPreview{
+		AppID:          app.ID,
+		ResourceLimits: limits,
+		CPUCores:       resourceSeconds(limits.CPURequest, limits.CPULimit, 1, 1),
+		MemoryGiB:      resourceSeconds(limits.MemoryRequest, limits.MemoryLimit, 1, 1<<30),
+		Quota:          quota,
+	}
+
+	// The checks createSession makes before launching
+	if closed := checkSchedule(app); closed != nil {
+		p.Blocked = closed.Error()
+		return p, nil
+	}
+	project, err := sessionProject(m.db, projectID)
+	if err != nil {
+		return nil, err
+	}
+	err = m.checkAppLimit(app)
+	if err == nil {
+		err = m.checkProjectLimit(project)
+	}
+	if err == nil {
+		if err = m.checkQuotas(userID); err != nil && m.queue != nil {
+			var quotaErr *QuotaExceededError
+			if errors.As(err, &quotaErr) {
+				p.Queued = true
+				p.Limit = quotaErr.Limit
+				return p, nil
+			}
+		}
+	}
+	if err != nil {
+		var quotaErr *QuotaExceededError
+		if !errors.As(err, &quotaErr) {
+			return nil, err
+		}
+		p.Blocked = quotaErr.Error()
+		p.Limit = quotaErr.Limit
+	}
+	return p, nil
+}
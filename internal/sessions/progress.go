@@ -0,0 +1,66 @@
+package sessions
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/runner"
+)
+
+// progressPollInterval is how often a creating session's launch stage is
+// refreshed from the runner.
+const progressPollInterval = 2 * time.Second
+
+// trackProgress records a creating session's launch stage, as reported by
+// the runner, until ctx is done. Only changes are written.
+func (m *Manager) trackProgress(ctx context.Context, sessionID, workloadName string) {
+	reporter, ok := m.runner.(runner.ProgressReporter)
+	if !ok {
+		return
+	}
+
+	ticker := time.NewTicker(progressPollInterval)
+	defer ticker.Stop()
+
+	var last db.SessionSubstatus
+	var lastDetail string
+	for {
+		substatus, detail, err := reporter.WorkloadProgress(ctx, workloadName)
+		if err == nil && substatus != "" && (substatus != last || detail != lastDetail) {
+			if err := m.db.UpdateSessionSubstatus(sessionID, substatus, detail); err != nil {
+				log.Printf("Warning: failed to record launch progress for session %s: %v", sessionID, err)
+			} else {
+				log.Printf("Session %s launch stage: %s (%s)", sessionID, substatus, detail)
+				last, lastDetail = substatus, detail
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recordFailedStage records the launch stage of a session whose workload
+// failed to become ready, so a failure the progress poll had not seen yet,
+// such as an init container exiting with an error, is kept on the session.
+func (m *Manager) recordFailedStage(sessionID, workloadName string) {
+	reporter, ok := m.runner.(runner.ProgressReporter)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), progressPollInterval)
+	defer cancel()
+	substatus, detail, err := reporter.WorkloadProgress(ctx, workloadName)
+	if err != nil || substatus == "" {
+		return
+	}
+	if err := m.db.UpdateSessionSubstatus(sessionID, substatus, detail); err != nil {
+		log.Printf("Warning: failed to record launch progress for session %s: %v", sessionID, err)
+	}
+}
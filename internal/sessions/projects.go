@@ -0,0 +1,99 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/runner"
+)
+
+var (
+	// ErrProjectDrivesUnsupported is returned when the runner cannot keep
+	// project drives.
+	ErrProjectDrivesUnsupported = errors.New("runner does not support project drives")
+
+	// ErrProjectInUse is returned when deleting a project that has active
+	// sessions.
+	ErrProjectInUse = errors.New("project has active sessions")
+)
+
+// sessionProject returns the project a session is launched in, or nil if
+// projectID is empty.
+func sessionProject(store *db.DB, projectID string) (*db.Project, error) {
+	if projectID == "" {
+		return nil, nil
+	}
+	project, err := store.GetProject(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project: %w", err)
+	}
+	if project == nil {
+		return nil, fmt.Errorf("project not found: %s", projectID)
+	}
+	return project, nil
+}
+
+// checkProjectLimit verifies that the project's active sessions, across
+// all of its members, are below its MaxSessions. A nil project has no
+// limit.
+func (m *Manager) checkProjectLimit(project *db.Project) error {
+	if project == nil || project.MaxSessions <= 0 {
+		return nil
+	}
+	count, err := m.db.CountActiveSessionsByProject(project.ID)
+	if err != nil {
+		return fmt.Errorf("failed to check project session count: %w", err)
+	}
+	if count >= project.MaxSessions {
+		return &QuotaExceededError{
+			Reason: fmt.Sprintf("project %s session limit reached (%d/%d)", project.Name, count, project.MaxSessions),
+			Limit:  QuotaLimitProject,
+		}
+	}
+	return nil
+}
+
+// attachProjectDrive mounts the project's drive in the workload, creating
+// the drive on the project's first launch.
+func (m *Manager) attachProjectDrive(ctx context.Context, wc *runner.WorkloadConfig, project *db.Project) error {
+	if project == nil || project.Drive == nil {
+		return nil
+	}
+	pdr, ok := m.runner.(runner.ProjectDriveRunner)
+	if !ok {
+		return fmt.Errorf("project %s has a drive: %w", project.ID, ErrProjectDrivesUnsupported)
+	}
+	if wc.HomeVolume != nil && wc.HomeVolume.MountPath == project.Drive.MountPath {
+		return fmt.Errorf("project drive and home volume are both mounted at %s", project.Drive.MountPath)
+	}
+	name, err := pdr.EnsureProjectDrive(ctx, project.ID, project.Drive)
+	if err != nil {
+		return fmt.Errorf("failed to prepare project drive: %w", err)
+	}
+	wc.ProjectDrive = &runner.ProjectDriveMount{
+		Name:      name,
+		MountPath: project.Drive.MountPath,
+	}
+	return nil
+}
+
+// DeleteProject deletes a project, its memberships and its drive, with the
+// files on it. A project cannot be deleted while it has creating or
+// running sessions.
+func (m *Manager) DeleteProject(ctx context.Context, projectID string) error {
+	count, err := m.db.CountActiveSessionsByProject(projectID)
+	if err != nil {
+		return fmt.Errorf("failed to check project session count: %w", err)
+	}
+	if count > 0 {
+		return ErrProjectInUse
+	}
+	if pdr, ok := m.runner.(runner.ProjectDriveRunner); ok {
+		if err := pdr.DeleteProjectDrive(ctx, projectID); err != nil {
+			return fmt.Errorf("failed to delete project drive: %w", err)
+		}
+	}
+	return m.db.DeleteProject(projectID)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"maps"
 	"sync"
 	"time"
 )
@@ -47,8 +48,10 @@ type QueueConfig struct {
 
 // queueEntry represents a pending session creation request.
 type queueEntry struct {
-	ready chan struct{} // closed when the entry may proceed
-	err   error        // set if the entry is rejected
+	ready    chan struct{} // closed when the entry may proceed
+	err      error        // set if the entry is rejected
+	check    func() bool  // returns true when the entry may proceed (nil = the queue's capacity check)
+	tenantID string       // tenant the session is launched in, for metrics
 }
 
 // SessionQueue manages pending session requests when capacity is full.
@@ -61,6 +64,7 @@ type SessionQueue struct {
 	config   QueueConfig
 	checkCap func() bool // returns true when capacity is available
 	stopCh   chan struct{}
+	timeouts map[string]int64 // requests that timed out, by tenant
 }
 
 // NewSessionQueue creates a queue with the given config and capacity checker.
@@ -88,12 +92,24 @@ func NewSessionQueue(cfg QueueConfig, capacityCheck func() bool) *SessionQueue {
 
 // Enqueue adds a request to the queue and blocks until capacity is available
 // or the context/timeout expires. Returns nil when the caller may proceed,
-// or an error if the queue is full or the wait timed out.
-func (q *SessionQueue) Enqueue(ctx context.Context) error {
+// or an error if the queue is full or the wait timed out. tenantID is the
+// tenant the session is launched in, which labels the queue's metrics.
+func (q *SessionQueue) Enqueue(ctx context.Context, tenantID string) error {
+	return q.enqueue(ctx, tenantID, nil)
+}
+
+// EnqueueUntil is Enqueue for a request that waits on its own condition,
+// such as the cluster having room for its workload, rather than the
+// queue's capacity check.
+func (q *SessionQueue) EnqueueUntil(ctx context.Context, tenantID string, check func() bool) error {
+	return q.enqueue(ctx, tenantID, check)
+}
+
+func (q *SessionQueue) enqueue(ctx context.Context, tenantID string, check func() bool) error {
 	q.mu.Lock()
 
 	// Check if capacity is already available (fast path)
-	if q.checkCap() {
+	if (check == nil && q.checkCap()) || (check != nil && check()) {
 		q.mu.Unlock()
 		return nil
 	}
@@ -106,7 +122,9 @@ func (q *SessionQueue) Enqueue(ctx context.Context) error {
 	}
 
 	entry := &queueEntry{
-		ready: make(chan struct{}),
+		ready:    make(chan struct{}),
+		check:    check,
+		tenantID: tenantID,
 	}
 	q.entries = append(q.entries, entry)
 	position := len(q.entries)
@@ -122,13 +140,20 @@ func (q *SessionQueue) Enqueue(ctx context.Context) error {
 	case <-entry.ready:
 		return entry.err
 	case <-timeout.C:
-		q.remove(entry)
+		if q.remove(entry) {
+			q.mu.Lock()
+			if q.timeouts == nil {
+				q.timeouts = make(map[string]int64)
+			}
+			q.timeouts[tenantID]++
+			q.mu.Unlock()
+		}
 		return &QueueTimeoutError{WaitDuration: q.config.Timeout}
 	case <-ctx.Done():
 		q.remove(entry)
 		return ctx.Err()
 	case <-q.stopCh:
-		return fmt.Errorf("session queue shutting down")
+		return ErrShuttingDown
 	}
 }
 
@@ -139,6 +164,26 @@ func (q *SessionQueue) Len() int {
 	return len(q.entries)
 }
 
+// DepthByTenant returns the number of queued requests of each tenant with
+// requests in the queue.
+func (q *SessionQueue) DepthByTenant() map[string]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	depths := make(map[string]int)
+	for _, e := range q.entries {
+		depths[e.tenantID]++
+	}
+	return depths
+}
+
+// TimeoutsByTenant returns the number of requests of each tenant that timed
+// out waiting in the queue since it was created.
+func (q *SessionQueue) TimeoutsByTenant() map[string]int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return maps.Clone(q.timeouts)
+}
+
 // NotifyCapacity wakes the processing loop to re-check capacity.
 // Call this when a session ends (stopped, expired, terminated, failed).
 func (q *SessionQueue) NotifyCapacity() {
@@ -167,34 +212,46 @@ func (q *SessionQueue) processLoop() {
 	}
 }
 
-// tryRelease releases the front-of-queue entry if capacity is available.
+// tryRelease releases the earliest entry that may proceed. Entries waiting
+// on the same condition are released in the order they were queued.
 func (q *SessionQueue) tryRelease() {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	if len(q.entries) == 0 {
-		return
-	}
-
-	if q.checkCap() {
-		entry := q.entries[0]
-		q.entries = q.entries[1:]
-		close(entry.ready)
-		log.Printf("Session dequeued (remaining: %d)", len(q.entries))
+	// The queue's capacity check is made at most once per pass
+	var capChecked, hasCap bool
+	for i, entry := range q.entries {
+		ready := false
+		if entry.check != nil {
+			ready = entry.check()
+		} else {
+			if !capChecked {
+				hasCap, capChecked = q.checkCap(), true
+			}
+			ready = hasCap
+		}
+		if ready {
+			q.entries = append(q.entries[:i], q.entries[i+1:]...)
+			close(entry.ready)
+			log.Printf("Session dequeued (remaining: %d)", len(q.entries))
+			return
+		}
 	}
 }
 
 // remove removes a specific entry from the queue (for timeout/cancellation).
-func (q *SessionQueue) remove(target *queueEntry) {
+// It reports false if the entry had already left the queue.
+func (q *SessionQueue) remove(target *queueEntry) bool {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
 	for i, e := range q.entries {
 		if e == target {
 			q.entries = append(q.entries[:i], q.entries[i+1:]...)
-			return
+			return true
 		}
 	}
+	return false
 }
 
 // drainOnShutdown rejects all queued entries during shutdown.
@@ -203,7 +260,7 @@ func (q *SessionQueue) drainOnShutdown() {
 	defer q.mu.Unlock()
 
 	for _, entry := range q.entries {
-		entry.err = fmt.Errorf("session queue shutting down")
+		entry.err = ErrShuttingDown
 		close(entry.ready)
 	}
 	q.entries = nil
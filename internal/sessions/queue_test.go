@@ -2,6 +2,7 @@ package sessions
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -17,7 +18,7 @@ func TestSessionQueue_FastPath(t *testing.T) {
 	}, func() bool { return true })
 	defer q.Stop()
 
-	err := q.Enqueue(context.Background())
+	err := q.Enqueue(context.Background(), "")
 	if err != nil {
 		t.Fatalf("Enqueue() with available capacity should succeed, got %v", err)
 	}
@@ -40,7 +41,7 @@ func TestSessionQueue_WaitAndRelease(t *testing.T) {
 
 	done := make(chan error, 1)
 	go func() {
-		done <- q.Enqueue(context.Background())
+		done <- q.Enqueue(context.Background(), "")
 	}()
 
 	// Verify request is queued
@@ -71,7 +72,7 @@ func TestSessionQueue_Timeout(t *testing.T) {
 	defer q.Stop()
 
 	start := time.Now()
-	err := q.Enqueue(context.Background())
+	err := q.Enqueue(context.Background(), "")
 	elapsed := time.Since(start)
 
 	if err == nil {
@@ -96,7 +97,7 @@ func TestSessionQueue_ContextCancellation(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
 	defer cancel()
 
-	err := q.Enqueue(ctx)
+	err := q.Enqueue(ctx, "")
 	if err == nil {
 		t.Fatal("Enqueue() should return error on context cancellation")
 	}
@@ -121,7 +122,7 @@ func TestSessionQueue_Full(t *testing.T) {
 			defer wg.Done()
 			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 			defer cancel()
-			q.Enqueue(ctx) //nolint:errcheck
+			q.Enqueue(ctx, "") //nolint:errcheck
 		}()
 	}
 
@@ -129,7 +130,7 @@ func TestSessionQueue_Full(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 
 	// Third request should get QueueFullError
-	err := q.Enqueue(context.Background())
+	err := q.Enqueue(context.Background(), "")
 	if err == nil {
 		t.Fatal("Enqueue() should return error when queue is full")
 	}
@@ -164,7 +165,7 @@ func TestSessionQueue_FIFO(t *testing.T) {
 		idx := i
 		go func() {
 			defer wg.Done()
-			err := q.Enqueue(context.Background())
+			err := q.Enqueue(context.Background(), "")
 			if err == nil {
 				mu.Lock()
 				order = append(order, idx)
@@ -205,7 +206,7 @@ func TestSessionQueue_Shutdown(t *testing.T) {
 
 	done := make(chan error, 1)
 	go func() {
-		done <- q.Enqueue(context.Background())
+		done <- q.Enqueue(context.Background(), "")
 	}()
 
 	time.Sleep(100 * time.Millisecond)
@@ -213,8 +214,8 @@ func TestSessionQueue_Shutdown(t *testing.T) {
 
 	select {
 	case err := <-done:
-		if err == nil {
-			t.Fatal("Enqueue() should return error on shutdown")
+		if !errors.Is(err, ErrShuttingDown) {
+			t.Fatalf("Enqueue() error = %v on shutdown, want ErrShuttingDown", err)
 		}
 	case <-time.After(time.Second):
 		t.Fatal("Enqueue() did not return after shutdown")
@@ -244,7 +245,7 @@ func TestSessionQueue_LenAccuracy(t *testing.T) {
 			defer wg.Done()
 			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 			defer cancel()
-			q.Enqueue(ctx) //nolint:errcheck
+			q.Enqueue(ctx, "") //nolint:errcheck
 		}()
 	}
 
@@ -255,3 +256,49 @@ func TestSessionQueue_LenAccuracy(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestSessionQueue_EnqueueUntil(t *testing.T) {
+	// A request waiting on its own condition does not hold up requests
+	// queued behind it, and is released once its condition holds.
+	var hasCapacity, hasRoom atomic.Bool
+	q := NewSessionQueue(QueueConfig{
+		MaxSize:      10,
+		Timeout:      2 * time.Second,
+		PollInterval: 20 * time.Millisecond,
+	}, func() bool { return hasCapacity.Load() })
+	defer q.Stop()
+
+	waiting := make(chan error, 1)
+	go func() { waiting <- q.EnqueueUntil(context.Background(), "", func() bool { return hasRoom.Load() }) }()
+	for q.Len() != 1 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	behind := make(chan error, 1)
+	go func() { behind <- q.Enqueue(context.Background(), "") }()
+	for q.Len() != 2 {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	hasCapacity.Store(true)
+	select {
+	case err := <-behind:
+		if err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Enqueue() was held up by a request waiting on another condition")
+	}
+	if q.Len() != 1 {
+		t.Errorf("Len() = %d, want the request still waiting on its condition", q.Len())
+	}
+
+	hasRoom.Store(true)
+	select {
+	case err := <-waiting:
+		if err != nil {
+			t.Fatalf("EnqueueUntil() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("EnqueueUntil() did not return once its condition held")
+	}
+}
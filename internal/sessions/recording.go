@@ -29,6 +29,15 @@ const (
 
 	// EventSessionTerminated is emitted when a session is terminated by user action (DELETE).
 	EventSessionTerminated SessionEvent = "session.terminated"
+
+	// EventSessionHibernated is emitted when an idle session is hibernated (pod deleted, workspace kept).
+	EventSessionHibernated SessionEvent = "session.hibernated"
+
+	// EventSessionResumed is emitted when a hibernated session is resumed with a new pod.
+	EventSessionResumed SessionEvent = "session.resumed"
+
+	// EventSessionReconnecting is emitted when a running session's pod was evicted and the session is moving to a new pod.
+	EventSessionReconnecting SessionEvent = "session.reconnecting"
 )
 
 // SessionEventData holds data associated with a session lifecycle event.
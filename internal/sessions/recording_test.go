@@ -50,6 +50,8 @@ func TestSessionEventTypes(t *testing.T) {
 		EventSessionRestarted,
 		EventSessionExpired,
 		EventSessionTerminated,
+		EventSessionHibernated,
+		EventSessionResumed,
 	}
 
 	expected := []string{
@@ -60,6 +62,8 @@ func TestSessionEventTypes(t *testing.T) {
 		"session.restarted",
 		"session.expired",
 		"session.terminated",
+		"session.hibernated",
+		"session.resumed",
 	}
 
 	if len(events) != len(expected) {
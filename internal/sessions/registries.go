@@ -0,0 +1,33 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/runner"
+)
+
+// ErrRegistrySecretsUnsupported is returned when the runner cannot keep
+// image pull secrets for registry credentials.
+var ErrRegistrySecretsUnsupported = errors.New("runner does not support registry secrets")
+
+// ApplyRegistrySecret writes the image pull secret for a registry
+// credential, whose Password must be set.
+func (m *Manager) ApplyRegistrySecret(ctx context.Context, cred *db.RegistryCredential) error {
+	rsr, ok := m.runner.(runner.RegistrySecretRunner)
+	if !ok {
+		return ErrRegistrySecretsUnsupported
+	}
+	return rsr.ApplyRegistrySecret(ctx, cred)
+}
+
+// DeleteRegistrySecret deletes a registry credential's image pull secret.
+// Runners without registry secrets have nothing to delete.
+func (m *Manager) DeleteRegistrySecret(ctx context.Context, name string) error {
+	rsr, ok := m.runner.(runner.RegistrySecretRunner)
+	if !ok {
+		return nil
+	}
+	return rsr.DeleteRegistrySecret(ctx, name)
+}
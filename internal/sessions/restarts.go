@@ -0,0 +1,55 @@
+package sessions
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/runner"
+)
+
+// watchRestarts records the workload restarts the runner reports until
+// the manager stops.
+func (m *Manager) watchRestarts() {
+	watcher, ok := m.runner.(runner.RestartWatcher)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-m.stopCh
+		cancel()
+	}()
+	if err := watcher.WatchRestarts(ctx, m.handleRestart); err != nil {
+		log.Printf("Error watching workload restarts: %v", err)
+	}
+}
+
+// handleRestart marks the session running a restarted workload degraded,
+// and fails it once its containers have restarted maxRestarts times, so a
+// crash-looping app's pod is not left restarting forever. A creating
+// session is only marked; its launch fails if the workload never becomes
+// ready. Each restart count is recorded once, so with several replicas
+// only one acts on it.
+func (m *Manager) handleRestart(restart runner.WorkloadRestart) {
+	session, err := m.db.RecordWorkloadRestarts(restart.Name, restart.Restarts, restart.Detail)
+	if err != nil {
+		log.Printf("Warning: failed to record restarts of workload %s: %v", restart.Name, err)
+		return
+	}
+	if session == nil {
+		return
+	}
+	log.Printf("Session %s workload %s has restarted %d times: %s", session.ID, restart.Name, restart.Restarts, restart.Detail)
+
+	if m.maxRestarts <= 0 || restart.Restarts < m.maxRestarts || session.Status != db.SessionStatusRunning {
+		return
+	}
+	reason := fmt.Sprintf("workload restarted %d times: %s", restart.Restarts, restart.Detail)
+	if err := m.terminateWithStatus(context.Background(), session.ID, db.SessionStatusFailed, reason); err != nil {
+		log.Printf("Error failing crash-looping session %s: %v", session.ID, err)
+	}
+}
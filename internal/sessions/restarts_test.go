@@ -0,0 +1,79 @@
+package sessions
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/runner"
+)
+
+func TestRestarts_DegradedThenFailed(t *testing.T) {
+	database := newTestDB(t)
+	mockRunner := runner.NewMockRunner()
+	mockRunner.ReadyDelay = 0
+	m := NewManagerWithConfig(database, ManagerConfig{
+		Runner:      mockRunner,
+		MaxRestarts: 3,
+	})
+	m.Start()
+	defer m.Stop()
+	seedContainerApp(t, database, "app1", "App", "test:latest")
+
+	session, err := m.CreateSession(context.Background(), &CreateSessionRequest{AppID: "app1", UserID: "user1"})
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	waitForStatus(t, m, session.ID, db.SessionStatusRunning)
+
+	// The watch starts in the background, so report until it is seen
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mockRunner.Restart(session.PodName, 1, "app: CrashLoopBackOff, last exited with Error (exit code 1)")
+		got, _ := m.GetSession(context.Background(), session.ID)
+		if got.RestartCount == 1 {
+			if got.Status != db.SessionStatusRunning || got.Substatus != db.SubstatusDegraded ||
+				got.SubstatusDetail != "app: CrashLoopBackOff, last exited with Error (exit code 1)" {
+				t.Errorf("after a restart session is %s/%s (%q), want running/degraded", got.Status, got.Substatus, got.SubstatusDetail)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("restart was not recorded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Restarts of other workloads are ignored
+	mockRunner.Restart("sortie-session-unknown", 5, "app: Error")
+
+	mockRunner.Restart(session.PodName, 3, "app: CrashLoopBackOff")
+	got, _ := m.GetSession(context.Background(), session.ID)
+	if got.Status != db.SessionStatusFailed || got.RestartCount != 3 {
+		t.Errorf("after 3 restarts session is %s with %d restarts, want failed with 3", got.Status, got.RestartCount)
+	}
+	if mockRunner.Workload(session.PodName) != nil {
+		t.Error("workload of the failed session was not deleted")
+	}
+}
+
+func TestRestarts_NeverFailWithoutLimit(t *testing.T) {
+	database := newTestDB(t)
+	mockRunner := runner.NewMockRunner()
+	mockRunner.ReadyDelay = 0
+	m := NewManagerWithConfig(database, ManagerConfig{Runner: mockRunner})
+	seedContainerApp(t, database, "app1", "App", "test:latest")
+
+	session, err := m.CreateSession(context.Background(), &CreateSessionRequest{AppID: "app1", UserID: "user1"})
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	waitForStatus(t, m, session.ID, db.SessionStatusRunning)
+
+	m.handleRestart(runner.WorkloadRestart{Name: session.PodName, Restarts: 50, Detail: "app: CrashLoopBackOff"})
+	got, _ := m.GetSession(context.Background(), session.ID)
+	if got.Status != db.SessionStatusRunning || got.RestartCount != 50 {
+		t.Errorf("session is %s with %d restarts, want running with 50", got.Status, got.RestartCount)
+	}
+}
@@ -0,0 +1,36 @@
+package sessions
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// archiveBatchSize caps how many sessions are archived per transaction, so
+// a large backlog does not hold a long write lock.
+const archiveBatchSize = 500
+
+// archiveEndedSessions moves sessions that ended more than the retention
+// period ago from the sessions table to the session archive.
+func (m *Manager) archiveEndedSessions() error {
+	if m.sessionRetention <= 0 {
+		return nil
+	}
+	cutoff := time.Now().Add(-m.sessionRetention)
+
+	total := 0
+	for {
+		n, err := m.db.ArchiveSessions(cutoff, archiveBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to archive sessions: %w", err)
+		}
+		total += n
+		if n < archiveBatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		log.Printf("Archived %d sessions that ended before %s", total, cutoff.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package sessions
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/schedule"
+)
+
+// checkSchedule returns a schedule.ClosedError if the app's schedule does
+// not allow launching it now.
+func checkSchedule(app *db.Application) error {
+	if closed := schedule.Check(app.Schedule, time.Now()); closed != nil {
+		closed.App = app.Name
+		return closed
+	}
+	return nil
+}
+
+// stopClosedSessions stops the running sessions of apps whose schedule has
+// closed, for apps that ask for sessions to be stopped at window end.
+func (m *Manager) stopClosedSessions() error {
+	sessions, err := m.db.ListSessions()
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	now := time.Now()
+	closed := map[string]*schedule.ClosedError{}
+	checked := map[string]bool{}
+	for _, session := range sessions {
+		if session.Status != db.SessionStatusRunning {
+			continue
+		}
+		if !checked[session.AppID] {
+			checked[session.AppID] = true
+			app, err := m.db.GetApp(session.AppID)
+			if err != nil {
+				log.Printf("Error getting app %s for schedule check: %v", session.AppID, err)
+				continue
+			}
+			if app != nil && app.Schedule != nil && app.Schedule.StopAtWindowEnd {
+				closed[session.AppID] = schedule.Check(app.Schedule, now)
+			}
+		}
+		c := closed[session.AppID]
+		if c == nil {
+			continue
+		}
+		log.Printf("Stopping session %s: app schedule closed (%s)", session.ID, c.Reason)
+		if err := m.terminateWithStatus(context.Background(), session.ID, db.SessionStatusStopped, "app schedule closed: "+c.Reason); err != nil {
+			log.Printf("Error stopping session %s outside app schedule: %v", session.ID, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// DefaultShutdownTimeout bounds how long Stop waits for launches in flight.
+const DefaultShutdownTimeout = 30 * time.Second
+
+// abandonGrace is how long Shutdown waits, once its context is done, for
+// abandoned launches to record their failure.
+var abandonGrace = 5 * time.Second
+
+// ErrShuttingDown is returned for launches requested, or still queued, once
+// the manager has begun shutting down. Another replica, or this one after
+// it restarts, can take the request.
+var ErrShuttingDown = errors.New("session manager is shutting down")
+
+// beginLaunch registers a session launch, so Shutdown waits for it. It
+// fails once the manager is shutting down. The caller must call
+// m.launches.Done when the launch resolves, which for a launch that gets
+// as far as creating a workload is when waitForWorkloadReady returns.
+func (m *Manager) beginLaunch() error {
+	m.shutdownMu.Lock()
+	defer m.shutdownMu.Unlock()
+	if m.shuttingDown {
+		return ErrShuttingDown
+	}
+	m.launches.Add(1)
+	return nil
+}
+
+// ShuttingDown reports whether Shutdown has been called.
+func (m *Manager) ShuttingDown() bool {
+	m.shutdownMu.Lock()
+	defer m.shutdownMu.Unlock()
+	return m.shuttingDown
+}
+
+// Shutdown stops the manager in phases:
+//
+//  1. Stop intake: new launches, restarts and resumes fail with
+//     ErrShuttingDown.
+//  2. Reject queued launch requests with ErrShuttingDown. A queued request
+//     holds nothing beyond the waiting caller, so there is no queue state
+//     to keep; callers retry.
+//  3. Stop the cleanup and warm pool loops, and wait for a pass in
+//     progress to finish.
+//  4. Wait for launches in flight to create their workloads and for them
+//     to become ready, so their sessions are left running or failed
+//     rather than creating.
+//
+// If ctx is done before then, the remaining launches are cancelled: each
+// records its session as failed, deletes its workload and releases its
+// hostname, and Shutdown waits a few more seconds for them to do so before
+// returning ctx's error. The database and the leader lease are not the
+// manager's; close and release them after Shutdown returns. Only the first
+// call does anything.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.shutdownMu.Lock()
+	if m.shuttingDown {
+		m.shutdownMu.Unlock()
+		return nil
+	}
+	m.shuttingDown = true
+	m.shutdownMu.Unlock()
+	log.Printf("Session manager shutting down")
+
+	if m.queue != nil {
+		if n := m.queue.Len(); n > 0 {
+			log.Printf("Rejecting %d queued session requests", n)
+		}
+		m.queue.Stop()
+	}
+
+	close(m.stopCh)
+	if err := waitDone(ctx, m.loops.Wait); err != nil {
+		log.Printf("Session manager shutdown: background loops still running: %v", err)
+	}
+
+	launchesDone := make(chan struct{})
+	go func() {
+		m.launches.Wait()
+		close(launchesDone)
+	}()
+	select {
+	case <-launchesDone:
+		log.Printf("Session manager stopped")
+		return nil
+	case <-ctx.Done():
+	}
+
+	log.Printf("Session manager shutdown timed out, failing launches still in flight")
+	m.cancelLaunches(ErrShuttingDown)
+	select {
+	case <-launchesDone:
+	case <-time.After(abandonGrace):
+		log.Printf("Session manager shutdown: launches still in flight after %v", abandonGrace)
+	}
+	return ctx.Err()
+}
+
+// waitDone runs wait and returns when it does, or with ctx's error when ctx
+// is done first.
+func waitDone(ctx context.Context, wait func()) error {
+	done := make(chan struct{})
+	go func() {
+		wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -17,12 +17,18 @@ var ValidTransitions = map[db.SessionStatus][]db.SessionStatus{
 	db.SessionStatusRunning: {
 		db.SessionStatusStopped,
 		db.SessionStatusExpired,
+		db.SessionStatusHibernated,
 		db.SessionStatusFailed,
 	},
 	// Stopped sessions can be restarted
 	db.SessionStatusStopped: {
 		db.SessionStatusCreating, // restart
 	},
+	// Hibernated sessions can be resumed or deleted
+	db.SessionStatusHibernated: {
+		db.SessionStatusCreating, // resume
+		db.SessionStatusStopped,
+	},
 	// Terminal states with no valid transitions
 	db.SessionStatusExpired: {},
 	db.SessionStatusFailed:  {},
@@ -33,6 +33,14 @@ func TestCanTransition(t *testing.T) {
 		{"stopped to creating", db.SessionStatusStopped, db.SessionStatusCreating, true},
 		{"stopped to running", db.SessionStatusStopped, db.SessionStatusRunning, false},
 
+		// Idle sessions can hibernate, and hibernated ones be resumed or deleted
+		{"running to hibernated", db.SessionStatusRunning, db.SessionStatusHibernated, true},
+		{"creating to hibernated", db.SessionStatusCreating, db.SessionStatusHibernated, false},
+		{"hibernated to creating", db.SessionStatusHibernated, db.SessionStatusCreating, true},
+		{"hibernated to stopped", db.SessionStatusHibernated, db.SessionStatusStopped, true},
+		{"hibernated to running", db.SessionStatusHibernated, db.SessionStatusRunning, false},
+		{"hibernated to expired", db.SessionStatusHibernated, db.SessionStatusExpired, false},
+
 		// No transitions from terminal states
 		{"expired to running", db.SessionStatusExpired, db.SessionStatusRunning, false},
 		{"expired to creating", db.SessionStatusExpired, db.SessionStatusCreating, false},
@@ -58,6 +66,7 @@ func TestIsTerminalState(t *testing.T) {
 		{db.SessionStatusCreating, false},
 		{db.SessionStatusRunning, false},
 		{db.SessionStatusStopped, false},
+		{db.SessionStatusHibernated, false},
 		{db.SessionStatusExpired, true},
 		{db.SessionStatusFailed, true},
 	}
@@ -0,0 +1,59 @@
+package sessions
+
+import (
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/runner"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestCreateSession_Trace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	database := newTestDB(t)
+	m := NewManagerWithConfig(database, ManagerConfig{Runner: runner.NewMockRunner()})
+	if err := database.CreateApp(db.Application{
+		ID:             "traced-app",
+		Name:           "Traced App",
+		LaunchType:     db.LaunchTypeContainer,
+		ContainerImage: "nginx:latest",
+	}); err != nil {
+		t.Fatalf("CreateApp: %v", err)
+	}
+
+	ctx, root := provider.Tracer("test").Start(t.Context(), "request")
+	if _, err := m.CreateSession(ctx, &CreateSessionRequest{AppID: "traced-app", UserID: "user-1"}); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	root.End()
+
+	var create sdktrace.ReadOnlySpan
+	for _, s := range recorder.Ended() {
+		if s.Name() == "sessions.CreateSession" {
+			create = s
+		}
+	}
+	if create == nil {
+		t.Fatal("no sessions.CreateSession span recorded")
+	}
+	if create.Parent().SpanID() != root.SpanContext().SpanID() {
+		t.Errorf("sessions.CreateSession parent = %s, want the request span", create.Parent().SpanID())
+	}
+
+	queries := map[string]bool{}
+	for _, s := range recorder.Ended() {
+		if s.Parent().SpanID() == create.SpanContext().SpanID() {
+			queries[s.Name()] = true
+		}
+	}
+	if !queries["db.SELECT"] || !queries["db.INSERT"] {
+		t.Errorf("child spans of sessions.CreateSession = %v, want the app lookup and session insert", queries)
+	}
+}
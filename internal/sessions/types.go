@@ -10,8 +10,20 @@ import (
 // QuotaExceededError is returned when a session cannot be created due to quota limits.
 type QuotaExceededError struct {
 	Reason string
+	Limit  QuotaLimit // The limit that was reached
 }
 
+// QuotaLimit names the session limit a launch was turned away by.
+type QuotaLimit string
+
+const (
+	QuotaLimitUser    QuotaLimit = "user"    // The user's sessions, including burst
+	QuotaLimitTenant  QuotaLimit = "tenant"  // The tenant's sessions, or its per-user limit
+	QuotaLimitGlobal  QuotaLimit = "global"  // All sessions
+	QuotaLimitApp     QuotaLimit = "app"     // The app's sessions across all users
+	QuotaLimitProject QuotaLimit = "project" // The project's sessions across all members
+)
+
 func (e *QuotaExceededError) Error() string {
 	return fmt.Sprintf("quota exceeded: %s", e.Reason)
 }
@@ -28,6 +40,20 @@ type QuotaStatus struct {
 	DefaultCPULimit    string `json:"default_cpu_limit,omitempty"`
 	DefaultMemRequest  string `json:"default_mem_request,omitempty"`
 	DefaultMemLimit    string `json:"default_mem_limit,omitempty"`
+
+	// Burst is set when MaxSessionsPerUser is a soft limit with a burst
+	// allowance above it
+	Burst *BurstStatus `json:"burst,omitempty"`
+}
+
+// BurstStatus reports a user's burst allowance above a soft session limit.
+type BurstStatus struct {
+	MaxSessions      int   `json:"max_sessions"`      // Hard limit while bursting
+	DurationSeconds  int64 `json:"duration_seconds"`  // Total burst time allowed
+	DebtSeconds      int64 `json:"debt_seconds"`      // Burst time used and not yet paid back
+	RemainingSeconds int64 `json:"remaining_seconds"` // Burst time left
+	Bursting         bool  `json:"bursting"`          // User is above the soft limit
+	Available        bool  `json:"available"`         // A session above the soft limit can start now
 }
 
 // CreateSessionRequest represents a request to create a new session
@@ -36,28 +62,40 @@ type CreateSessionRequest struct {
 	UserID       string `json:"user_id"`
 	ScreenWidth  int    `json:"screen_width,omitempty"`
 	ScreenHeight int    `json:"screen_height,omitempty"`
-	IdleTimeout  int64  `json:"idle_timeout,omitempty"` // Per-session idle timeout in seconds (0 = use global default)
+	IdleTimeout  int64  `json:"idle_timeout,omitempty"`  // Per-session idle timeout in seconds (0 = use global default)
+	ProjectID    string `json:"project_id,omitempty"`    // Project to launch the session in, if any
+	ConfirmToken string `json:"confirm_token,omitempty"` // From the app's launch preview, for apps that require confirmation
 }
 
 // SessionResponse represents a session in API responses
 type SessionResponse struct {
-	ID              string           `json:"id"`
-	UserID          string           `json:"user_id"`
-	AppID           string           `json:"app_id"`
-	AppName         string           `json:"app_name,omitempty"`
-	PodName         string           `json:"pod_name"`
-	Status          db.SessionStatus `json:"status"`
-	IdleTimeout     int64            `json:"idle_timeout,omitempty"` // Per-session idle timeout in seconds (0 = global default)
-	WebSocketURL    string           `json:"websocket_url,omitempty"`  // For Linux container apps (VNC)
-	GuacamoleURL    string           `json:"guacamole_url,omitempty"`  // For Windows container apps (RDP via Guacamole)
-	ProxyURL        string           `json:"proxy_url,omitempty"`      // For web_proxy apps
-	IsShared        bool             `json:"is_shared,omitempty"`        // true if this is a shared session (viewer is not owner)
-	OwnerUsername   string           `json:"owner_username,omitempty"`   // set for shared sessions
-	SharePermission string           `json:"share_permission,omitempty"` // "read_only" or "read_write" for shared sessions
-	ShareID         string           `json:"share_id,omitempty"`         // share record ID for shared sessions
-	RecordingPolicy string           `json:"recording_policy,omitempty"` // "auto" when admin enables auto-record
-	CreatedAt       time.Time        `json:"created_at"`
-	UpdatedAt       time.Time        `json:"updated_at"`
+	ID              string                `json:"id"`
+	UserID          string                `json:"user_id"`
+	AppID           string                `json:"app_id"`
+	AppName         string                `json:"app_name,omitempty"`
+	PodName         string                `json:"pod_name"`
+	Status          db.SessionStatus      `json:"status"`
+	Substatus       db.SessionSubstatus   `json:"substatus,omitempty"`        // Launch stage while creating, the stage a failed launch stopped in, degraded or rescheduling
+	SubstatusDetail string                `json:"substatus_detail,omitempty"` // Runner detail for Substatus, e.g. a scheduling message
+	RestartCount    int                   `json:"restart_count,omitempty"`    // Container restarts of the session's current workload
+	RescheduleCount int                   `json:"reschedule_count,omitempty"` // Times the session moved to a new workload after an eviction
+	IdleTimeout     int64                 `json:"idle_timeout,omitempty"`     // Per-session idle timeout in seconds (0 = global default)
+	SidecarImage    string                `json:"sidecar_image,omitempty"`    // Built-in display sidecar image the session runs
+	ProjectID       string                `json:"project_id,omitempty"`       // Project the session was launched in
+	WebSocketURL    string                `json:"websocket_url,omitempty"`    // For Linux container apps (VNC)
+	GuacamoleURL    string                `json:"guacamole_url,omitempty"`    // For Windows container apps (RDP via Guacamole)
+	ProxyURL        string                `json:"proxy_url,omitempty"`        // For web_proxy apps
+	URL             string                `json:"url,omitempty"`              // The session's own hostname, for web_proxy apps when enabled
+	IsShared        bool                  `json:"is_shared,omitempty"`        // true if this is a shared session (viewer is not owner)
+	OwnerUsername   string                `json:"owner_username,omitempty"`   // set for shared sessions
+	SharePermission string                `json:"share_permission,omitempty"` // "read_only" or "read_write" for shared sessions
+	ShareID         string                `json:"share_id,omitempty"`         // share record ID for shared sessions
+	RecordingPolicy string                `json:"recording_policy,omitempty"` // "auto" when admin enables auto-record
+	ClipboardPolicy db.ClipboardPolicy    `json:"clipboard_policy,omitempty"` // Which way the clipboard may be used; empty = both
+	FileTransfer    db.FileTransferPolicy `json:"file_transfer,omitempty"`    // Which way files may be transferred; empty = both
+	LockedAt        *time.Time            `json:"locked_at,omitempty"`        // When the stream was locked for going idle
+	CreatedAt       time.Time             `json:"created_at"`
+	UpdatedAt       time.Time             `json:"updated_at"`
 }
 
 // CreateShareRequest represents a request to share a session.
@@ -86,6 +124,10 @@ type JoinShareRequest struct {
 
 // SessionFromDB converts a database session to an API response
 func SessionFromDB(session *db.Session, appName string, wsURL string, guacURL string, proxyURL string, recordingPolicy string) *SessionResponse {
+	sessionURL := ""
+	if session.Hostname != "" && session.Status == db.SessionStatusRunning {
+		sessionURL = "https://" + session.Hostname + "/"
+	}
 	return &SessionResponse{
 		ID:              session.ID,
 		UserID:          session.UserID,
@@ -93,11 +135,19 @@ func SessionFromDB(session *db.Session, appName string, wsURL string, guacURL st
 		AppName:         appName,
 		PodName:         session.PodName,
 		Status:          session.Status,
+		Substatus:       session.Substatus,
+		SubstatusDetail: session.SubstatusDetail,
+		RestartCount:    session.RestartCount,
+		RescheduleCount: session.RescheduleCount,
 		IdleTimeout:     session.IdleTimeout,
+		SidecarImage:    session.SidecarImage,
+		ProjectID:       session.ProjectID,
 		WebSocketURL:    wsURL,
 		GuacamoleURL:    guacURL,
 		ProxyURL:        proxyURL,
+		URL:             sessionURL,
 		RecordingPolicy: recordingPolicy,
+		LockedAt:        session.LockedAt,
 		CreatedAt:       session.CreatedAt,
 		UpdatedAt:       session.UpdatedAt,
 	}
@@ -107,6 +107,27 @@ func TestSessionFromDB_RecordingPolicy(t *testing.T) {
 	})
 }
 
+func TestSessionFromDB_Hostname(t *testing.T) {
+	session := &db.Session{
+		ID:       "sess-host",
+		UserID:   "user1",
+		AppID:    "jupyter",
+		Status:   db.SessionStatusRunning,
+		Hostname: "jupyter-alice.sortie.example.com",
+	}
+
+	resp := SessionFromDB(session, "Jupyter", "", "", "", "")
+	if resp.URL != "https://jupyter-alice.sortie.example.com/" {
+		t.Errorf("URL = %q, want https://jupyter-alice.sortie.example.com/", resp.URL)
+	}
+
+	// Only running sessions are reachable at their hostname
+	session.Status = db.SessionStatusStopped
+	if resp := SessionFromDB(session, "Jupyter", "", "", "", ""); resp.URL != "" {
+		t.Errorf("URL of a stopped session = %q, want empty", resp.URL)
+	}
+}
+
 func TestSessionFromDB_ZeroIdleTimeout(t *testing.T) {
 	session := &db.Session{
 		ID:          "sess-zero",
@@ -0,0 +1,184 @@
+package sessions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/leader"
+	"github.com/rjsadow/sortie/internal/runner"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DefaultWarmPoolInterval is how often warm pools are topped up.
+	DefaultWarmPoolInterval = 15 * time.Second
+
+	// MaxWarmPoolSize caps the number of idle pods kept for one app.
+	MaxWarmPoolSize = 20
+)
+
+// warmPool is the warm pool an app should have.
+type warmPool struct {
+	app    *db.Application
+	config *runner.WorkloadConfig
+	size   int
+}
+
+// warmPoolLoop keeps warm pools filled, on each tick and whenever a
+// session claims a workload.
+func (m *Manager) warmPoolLoop() {
+	m.fillWarmPools(context.Background())
+
+	ticker := time.NewTicker(m.warmPoolInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.fillWarmPools(context.Background())
+		case <-m.warmPoolCh:
+			m.fillWarmPools(context.Background())
+		case <-m.stopCh:
+			return
+		}
+	}
+}
+
+// fillWarmPools starts workloads until each app with a warm pool has
+// WarmPoolSize unclaimed ones, and deletes those no app needs: extras,
+// failed ones, and ones started from an app's old configuration. With
+// several replicas, only the leader runs it, so pools are not overfilled.
+func (m *Manager) fillWarmPools(ctx context.Context) {
+	if !leader.IsLeader(m.leader) {
+		return
+	}
+	wpr, ok := m.runner.(runner.WarmPoolRunner)
+	if !ok {
+		return
+	}
+
+	apps, err := m.db.ListApps()
+	if err != nil {
+		log.Printf("Error listing apps for warm pools: %v", err)
+		return
+	}
+	pools := make(map[string]*warmPool)
+	for i := range apps {
+		app := &apps[i]
+		// Home and workspace volumes are per user and session, so a warm
+		// workload could not mount one
+		if app.WarmPoolSize <= 0 || app.HomeVolume != nil || app.HibernateOnIdle || app.ContainerImage == "" || checkSchedule(app) != nil {
+			continue
+		}
+		if app.LaunchType != db.LaunchTypeContainer && app.LaunchType != db.LaunchTypeWebProxy {
+			continue
+		}
+		wc, err := m.warmWorkloadConfig(ctx, app)
+		if err != nil {
+			log.Printf("Error building warm pool workload for app %s: %v", app.ID, err)
+			continue
+		}
+		pools[warmPoolKey(wc)] = &warmPool{app: app, config: wc, size: app.WarmPoolSize}
+	}
+
+	warm, err := wpr.ListWarmWorkloads(ctx)
+	if err != nil {
+		log.Printf("Error listing warm workloads: %v", err)
+		return
+	}
+	// Keep ready workloads over starting ones when a pool shrinks
+	sort.SliceStable(warm, func(i, j int) bool { return warm[i].Ready && !warm[j].Ready })
+
+	counts := make(map[string]int)
+	for _, w := range warm {
+		pool, ok := pools[w.Pool]
+		if ok && !w.Failed && counts[w.Pool] < pool.size {
+			counts[w.Pool]++
+			continue
+		}
+		if err := m.runner.DeleteWorkload(ctx, w.Name); err != nil {
+			log.Printf("Error deleting warm workload %s: %v", w.Name, err)
+		}
+	}
+
+	for key, pool := range pools {
+		for i := counts[key]; i < pool.size; i++ {
+			wc := *pool.config
+			wc.SessionID = "warm-" + uuid.New().String()
+			if _, err := wpr.CreateWarmWorkload(ctx, key, &wc); err != nil {
+				log.Printf("Error starting warm workload for app %s: %v", pool.app.ID, err)
+				break
+			}
+		}
+	}
+}
+
+// warmWorkloadConfig builds the workload an app's warm pool starts: a
+// session's, with the app's default screen size and no user.
+func (m *Manager) warmWorkloadConfig(ctx context.Context, app *db.Application) (*runner.WorkloadConfig, error) {
+	wc := m.buildWorkloadConfig("warm", app)
+	policy, err := m.effectivePolicy(m.db, app)
+	if err != nil {
+		return nil, err
+	}
+	policy.applyResourceLimits(wc)
+	wc.ImagePullSecret = policy.ImagePullSecret
+	if err := m.addSidecars(ctx, wc, app, ""); err != nil {
+		return nil, err
+	}
+	return wc, nil
+}
+
+// claimWarmWorkload gives the session a ready workload from its app's warm
+// pool, or returns nil if there is none. Only workloads started from the
+// same configuration as wc match, apart from the session ID and screen
+// size, so a session whose sidecars depend on its user or ID starts cold.
+func (m *Manager) claimWarmWorkload(ctx context.Context, app *db.Application, wc *runner.WorkloadConfig) *runner.WorkloadResult {
+	if app.WarmPoolSize <= 0 || app.HomeVolume != nil || app.HibernateOnIdle || wc.ProjectDrive != nil {
+		return nil
+	}
+	wpr, ok := m.runner.(runner.WarmPoolRunner)
+	if !ok {
+		return nil
+	}
+
+	result, err := wpr.ClaimWarmWorkload(ctx, warmPoolKey(wc), wc.SessionID)
+	if err != nil {
+		log.Printf("Warning: failed to claim warm workload for session %s: %v", wc.SessionID, err)
+		return nil
+	}
+	if result == nil {
+		return nil
+	}
+
+	log.Printf("Session %s claimed warm workload %s", wc.SessionID, result.Name)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("sortie.warm_start", true))
+
+	// Replace the claimed workload without waiting for the next tick
+	select {
+	case m.warmPoolCh <- struct{}{}:
+	default:
+	}
+	return result
+}
+
+// warmPoolKey identifies the workloads interchangeable with wc: those with
+// the same configuration apart from the session ID and screen size.
+func warmPoolKey(wc *runner.WorkloadConfig) string {
+	c := *wc
+	c.SessionID = ""
+	c.ScreenResolution = ""
+	c.ScreenWidth = 0
+	c.ScreenHeight = 0
+	data, _ := json.Marshal(c)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
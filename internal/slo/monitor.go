@@ -0,0 +1,135 @@
+package slo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// Load measures an app with an SLO against the launches recorded in its
+// window ending at now.
+func Load(database *db.DB, app *db.Application, now time.Time) (*Report, error) {
+	launches, err := database.ListSessionLaunches(app.ID, Window(*app.SLO, now), now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list launches of %s: %w", app.ID, err)
+	}
+	return Evaluate(app, launches, now), nil
+}
+
+// Monitor evaluates every app's objectives and notifies operators when an
+// objective starts burning its error budget faster than a burn window
+// allows, and again when it stops. Firing alerts are remembered in memory,
+// so a new leader announces those still firing again.
+type Monitor struct {
+	db        *db.DB
+	notifiers []Notifier
+	firing    map[string]Alert // By app ID and objective
+}
+
+// NewMonitor creates a Monitor that sends alerts to notifiers. With no
+// notifiers, alerts are logged.
+func NewMonitor(database *db.DB, notifiers ...Notifier) *Monitor {
+	if len(notifiers) == 0 {
+		notifiers = []Notifier{&LogNotifier{}}
+	}
+	return &Monitor{
+		db:        database,
+		notifiers: notifiers,
+		firing:    make(map[string]Alert),
+	}
+}
+
+// Run evaluates the objectives of every app that has them. It is run as a
+// background job.
+func (m *Monitor) Run(ctx context.Context) error {
+	apps, err := m.db.ListApps()
+	if err != nil {
+		return fmt.Errorf("failed to list apps: %w", err)
+	}
+
+	now := time.Now()
+	seen := make(map[string]bool)
+	for i := range apps {
+		app := &apps[i]
+		if app.SLO == nil {
+			continue
+		}
+		report, err := Load(m.db, app, now)
+		if err != nil {
+			return err
+		}
+		for objective, a := range map[string]*Attainment{
+			ObjectiveLaunchSuccess: report.LaunchSuccess,
+			ObjectiveStartLatency:  report.StartLatency,
+		} {
+			if a == nil {
+				continue
+			}
+			key := app.ID + "/" + objective
+			seen[key] = true
+			m.update(ctx, key, report, objective, a, now)
+		}
+	}
+
+	// Objectives removed, or apps deleted, while firing
+	for key, alert := range m.firing {
+		if !seen[key] {
+			m.resolve(ctx, key, alert, now)
+		}
+	}
+	return nil
+}
+
+// update fires or resolves the alert of one objective.
+func (m *Monitor) update(ctx context.Context, key string, report *Report, objective string, a *Attainment, now time.Time) {
+	prev, firing := m.firing[key]
+	if a.Alert == "" {
+		if firing {
+			prev.BurnRate = a.BurnRates[prev.Window]
+			prev.BudgetRemaining = a.BudgetRemaining
+			m.resolve(ctx, key, prev, now)
+		}
+		return
+	}
+	if firing && prev.Window == a.Alert {
+		return
+	}
+
+	alert := Alert{
+		AppID:           report.AppID,
+		AppName:         report.AppName,
+		TenantID:        report.TenantID,
+		Objective:       objective,
+		State:           StateFiring,
+		Window:          a.Alert,
+		BurnRate:        a.BurnRates[a.Alert],
+		BudgetRemaining: a.BudgetRemaining,
+		Timestamp:       now,
+	}
+	for _, w := range BurnWindows {
+		if w.Name == a.Alert {
+			alert.Threshold = w.Threshold
+		}
+	}
+	m.firing[key] = alert
+	m.notify(ctx, alert)
+}
+
+// resolve announces that a firing alert stopped.
+func (m *Monitor) resolve(ctx context.Context, key string, alert Alert, now time.Time) {
+	delete(m.firing, key)
+	alert.State = StateResolved
+	alert.Timestamp = now
+	m.notify(ctx, alert)
+}
+
+func (m *Monitor) notify(ctx context.Context, alert Alert) {
+	for _, n := range m.notifiers {
+		if err := n.Notify(ctx, alert); err != nil {
+			slog.Warn("SLO monitor: failed to send alert", "notifier", n.Name(), "app", alert.AppID, "error", err)
+		}
+	}
+}
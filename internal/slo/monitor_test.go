@@ -0,0 +1,136 @@
+package slo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/db/dbtest"
+	"github.com/rjsadow/sortie/internal/notify"
+	"github.com/rjsadow/sortie/internal/ssrf"
+	"github.com/rjsadow/sortie/internal/ssrf/ssrftest"
+)
+
+// recordingNotifier captures alerts for assertions.
+type recordingNotifier struct {
+	alerts []Alert
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, alert Alert) error {
+	n.alerts = append(n.alerts, alert)
+	return nil
+}
+
+func (n *recordingNotifier) Name() string { return "recording" }
+
+func TestMonitor_Run(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+	app := db.Application{
+		ID:         "app-a",
+		Name:       "App A",
+		LaunchType: db.LaunchTypeContainer,
+		SLO:        &db.AppSLO{LaunchSuccessTarget: 99, StartLatencyP95Seconds: 60},
+	}
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("CreateApp() error = %v", err)
+	}
+	if err := database.CreateApp(db.Application{ID: "app-b", Name: "App B"}); err != nil {
+		t.Fatalf("CreateApp() error = %v", err)
+	}
+
+	record := func(appID string, ok bool) {
+		now := time.Now()
+		l := db.SessionLaunch{SessionID: "s", AppID: appID, RequestedAt: now, FinishedAt: now, Succeeded: ok, StartSeconds: 5}
+		if err := database.CreateSessionLaunch(l); err != nil {
+			t.Fatalf("CreateSessionLaunch() error = %v", err)
+		}
+	}
+	for i := range 6 {
+		record("app-a", i%2 == 0)
+		record("app-b", false)
+	}
+
+	notifier := &recordingNotifier{}
+	monitor := NewMonitor(database, notifier)
+	ctx := context.Background()
+	if err := monitor.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(notifier.alerts) != 1 {
+		t.Fatalf("alerts = %+v, want one for app-a's launch success", notifier.alerts)
+	}
+	alert := notifier.alerts[0]
+	if alert.AppID != "app-a" || alert.Objective != ObjectiveLaunchSuccess || alert.State != StateFiring ||
+		alert.Window != "fast" || alert.BurnRate != 50 || alert.Threshold != 14.4 {
+		t.Errorf("alert = %+v", alert)
+	}
+
+	// Still firing: no repeat
+	monitor.Run(ctx)
+	if len(notifier.alerts) != 1 {
+		t.Fatalf("alerts = %+v, want no repeat", notifier.alerts)
+	}
+
+	// Removing the objective resolves the alert
+	app.SLO = nil
+	if err := database.UpdateApp(app); err != nil {
+		t.Fatalf("UpdateApp() error = %v", err)
+	}
+	monitor.Run(ctx)
+	if len(notifier.alerts) != 2 || notifier.alerts[1].State != StateResolved || notifier.alerts[1].AppID != "app-a" {
+		t.Errorf("alerts = %+v, want app-a resolved", notifier.alerts)
+	}
+}
+
+func TestNotifiers(t *testing.T) {
+	var got Alert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	alert := Alert{AppID: "app-a", Objective: ObjectiveLaunchSuccess, State: StateFiring, Timestamp: time.Now()}
+	if err := NewWebhookNotifier(srv.URL, ssrftest.LoopbackGuard(t), nil).Notify(context.Background(), alert); err != nil {
+		t.Fatalf("WebhookNotifier.Notify() error = %v", err)
+	}
+	if got.AppID != "app-a" || got.State != StateFiring {
+		t.Errorf("webhook received %+v", got)
+	}
+
+	// A tenant's outbound allowlist limits where its alerts are sent
+	database := dbtest.NewTestDB(t)
+	tenant, err := database.GetTenant(db.DefaultTenantID)
+	if err != nil || tenant == nil {
+		t.Fatalf("GetTenant() = %v, %v", tenant, err)
+	}
+	tenant.Settings.OutboundAllowlist = []string{"hooks.acme.example"}
+	if err := database.UpdateTenant(*tenant); err != nil {
+		t.Fatalf("UpdateTenant() error = %v", err)
+	}
+	tenantAlert := alert
+	tenantAlert.TenantID = db.DefaultTenantID
+	err = NewWebhookNotifier(srv.URL, ssrftest.LoopbackGuard(t), notify.TenantAllowlists(database)).Notify(context.Background(), tenantAlert)
+	if !errors.Is(err, ssrf.ErrBlocked) {
+		t.Errorf("tenant alert to a destination outside its allowlist: error = %v, want ErrBlocked", err)
+	}
+
+	pub := &fakePublisher{}
+	NewAdminNotifier(pub).Notify(context.Background(), alert)
+	if pub.role != "admin" || pub.event != "slo" {
+		t.Errorf("published %q to %q, want slo to admin", pub.event, pub.role)
+	}
+}
+
+type fakePublisher struct {
+	role, event string
+}
+
+func (p *fakePublisher) PublishToRole(role, event string, _ any) {
+	p.role, p.event = role, event
+}
@@ -0,0 +1,76 @@
+package slo
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/notify"
+	"github.com/rjsadow/sortie/internal/ssrf"
+)
+
+// Alert states.
+const (
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+// Alert describes an objective starting or stopping to burn its error
+// budget too fast.
+type Alert struct {
+	AppID     string  `json:"app_id"`
+	AppName   string  `json:"app_name"`
+	TenantID  string  `json:"tenant_id,omitempty"`
+	Objective string  `json:"objective"`
+	State     string  `json:"state"`
+	Window    string  `json:"window"`
+	BurnRate  float64 `json:"burn_rate"`
+	Threshold float64 `json:"threshold"`
+	// BudgetRemaining is the percentage of the objective window's error
+	// budget left.
+	BudgetRemaining float64   `json:"budget_remaining"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+// Notifier delivers SLO alerts to operators. Notify errors are logged
+// and do not stop the evaluation.
+type Notifier = notify.Notifier[Alert]
+
+// LogNotifier writes alerts to the application log: firing alerts as
+// warnings, resolutions as info.
+type LogNotifier struct{}
+
+// Notify logs the alert.
+func (n *LogNotifier) Notify(_ context.Context, alert Alert) error {
+	log := slog.Info
+	if alert.State == StateFiring {
+		log = slog.Warn
+	}
+	log("SLO error budget burn alert",
+		"app", alert.AppID,
+		"objective", alert.Objective,
+		"state", alert.State,
+		"window", alert.Window,
+		"burn_rate", alert.BurnRate,
+		"budget_remaining", alert.BudgetRemaining)
+	return nil
+}
+
+// Name returns "log".
+func (n *LogNotifier) Name() string { return "log" }
+
+// NewWebhookNotifier returns a notifier that POSTs each alert as JSON to
+// endpoint through the outbound guard. An alert for a tenant may only be
+// sent where that tenant's outbound allowlist, read with allowlist, permits.
+func NewWebhookNotifier(endpoint string, guard *ssrf.Guard, allowlist notify.AllowlistFunc) *notify.Webhook[Alert] {
+	n := notify.NewWebhook[Alert](endpoint, guard)
+	n.Tenant = func(alert Alert) string { return alert.TenantID }
+	n.Allowlist = allowlist
+	return n
+}
+
+// NewAdminNotifier returns a notifier that pushes each alert to connected
+// admins as a "slo" server-sent event.
+func NewAdminNotifier(publisher notify.RolePublisher) *notify.Admin[Alert] {
+	return &notify.Admin[Alert]{Publisher: publisher, Event: "slo"}
+}
@@ -0,0 +1,227 @@
+// Package slo measures applications against their availability
+// objectives: the share of launches that reach running and the 95th
+// percentile time they take to get there. Attainment is computed over each
+// app's rolling window from recorded launches, and the rate at which the
+// error budget burns over the last hours drives alerts.
+package slo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+const (
+	// DefaultWindowDays is the objective window when an app sets none.
+	DefaultWindowDays = 30
+
+	// MaxWindowDays is the longest objective window.
+	MaxWindowDays = 90
+
+	// latencyQuantile is the share of launches the start latency objective
+	// covers, so its error budget is the remaining 5%.
+	latencyQuantile = 0.95
+)
+
+// Objective names.
+const (
+	ObjectiveLaunchSuccess = "launch_success"
+	ObjectiveStartLatency  = "start_latency"
+)
+
+// BurnWindow is a look-back period whose burn rate raises an alert when it
+// reaches Threshold. The thresholds spend 2% of a 30-day budget in an hour
+// and 5% in six hours, following common multiwindow burn rate alerting.
+type BurnWindow struct {
+	Name      string
+	Duration  time.Duration
+	Threshold float64
+}
+
+// BurnWindows are checked fastest first.
+var BurnWindows = []BurnWindow{
+	{Name: "fast", Duration: time.Hour, Threshold: 14.4},
+	{Name: "slow", Duration: 6 * time.Hour, Threshold: 6},
+}
+
+// MinAlertLaunches is the fewest launches a burn window needs before it
+// can alert, so a single failure of a rarely used app does not page.
+const MinAlertLaunches = 5
+
+// Validate checks that an objective's targets and window are in range.
+func Validate(o *db.AppSLO) error {
+	if o == nil {
+		return nil
+	}
+	if o.LaunchSuccessTarget < 0 || o.LaunchSuccessTarget >= 100 {
+		return errors.New("launch_success_target must be a percentage below 100")
+	}
+	if o.StartLatencyP95Seconds < 0 {
+		return errors.New("start_latency_p95_seconds must be non-negative")
+	}
+	if o.WindowDays < 0 || o.WindowDays > MaxWindowDays {
+		return fmt.Errorf("window_days must be between 0 and %d", MaxWindowDays)
+	}
+	return nil
+}
+
+// Attainment is how one objective fared over the window.
+type Attainment struct {
+	Target float64 `json:"target"`
+	Actual float64 `json:"actual"`
+	Met    bool    `json:"met"`
+	// BudgetRemaining is the percentage of the window's error budget left.
+	// It goes negative once the budget is spent.
+	BudgetRemaining float64 `json:"budget_remaining"`
+	// BurnRates is how fast the budget burned over each burn window: 1
+	// spends exactly the budget over the objective window.
+	BurnRates map[string]float64 `json:"burn_rates"`
+	// Alert names the fastest burn window over its threshold, if any.
+	Alert string `json:"alert,omitempty"`
+}
+
+// Report is an app's attainment of its objectives.
+type Report struct {
+	AppID      string    `json:"app_id"`
+	AppName    string    `json:"app_name"`
+	TenantID   string    `json:"tenant_id,omitempty"`
+	WindowDays int       `json:"window_days"`
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	Objective  db.AppSLO `json:"objective"`
+	Launches   int       `json:"launches"`
+	Failed     int       `json:"failed"`
+	// StartLatencyP95 is the 95th percentile start time of the window's
+	// successful launches, in seconds.
+	StartLatencyP95 float64     `json:"start_latency_p95_seconds"`
+	LaunchSuccess   *Attainment `json:"launch_success,omitempty"`
+	StartLatency    *Attainment `json:"start_latency,omitempty"`
+}
+
+// Window returns the start of an objective's window ending at now.
+func Window(o db.AppSLO, now time.Time) time.Time {
+	days := o.WindowDays
+	if days <= 0 {
+		days = DefaultWindowDays
+	}
+	return now.AddDate(0, 0, -days)
+}
+
+// Evaluate measures app's objectives over the launches that finished in
+// its window ending at now. app must have an SLO.
+func Evaluate(app *db.Application, launches []db.SessionLaunch, now time.Time) *Report {
+	o := *app.SLO
+	if o.WindowDays <= 0 {
+		o.WindowDays = DefaultWindowDays
+	}
+	from := Window(o, now)
+	report := &Report{
+		AppID:      app.ID,
+		AppName:    app.Name,
+		TenantID:   app.TenantID,
+		WindowDays: o.WindowDays,
+		From:       from,
+		To:         now,
+		Objective:  o,
+	}
+
+	var starts []float64
+	for _, l := range launches {
+		if l.FinishedAt.Before(from) || l.FinishedAt.After(now) {
+			continue
+		}
+		report.Launches++
+		if l.Succeeded {
+			starts = append(starts, l.StartSeconds)
+		} else {
+			report.Failed++
+		}
+	}
+	report.StartLatencyP95 = round(quantile(starts, latencyQuantile))
+
+	if o.LaunchSuccessTarget > 0 {
+		budget := 1 - o.LaunchSuccessTarget/100
+		failed := func(l db.SessionLaunch) (bool, bool) { return !l.Succeeded, true }
+		a := attain(launches, from, now, budget, failed)
+		a.Target = o.LaunchSuccessTarget
+		a.Actual = 100
+		if report.Launches > 0 {
+			a.Actual = round(100 * float64(report.Launches-report.Failed) / float64(report.Launches))
+		}
+		a.Met = a.Actual >= a.Target
+		report.LaunchSuccess = a
+	}
+
+	if o.StartLatencyP95Seconds > 0 {
+		slow := func(l db.SessionLaunch) (bool, bool) {
+			return l.StartSeconds > o.StartLatencyP95Seconds, l.Succeeded
+		}
+		a := attain(launches, from, now, 1-latencyQuantile, slow)
+		a.Target = o.StartLatencyP95Seconds
+		a.Actual = report.StartLatencyP95
+		a.Met = a.Actual <= a.Target
+		report.StartLatency = a
+	}
+	return report
+}
+
+// attain computes the budget left over [from, now] and the burn rates of
+// an objective whose error budget is the fraction budget. bad reports
+// whether a launch counts against the objective and whether it counts at
+// all.
+func attain(launches []db.SessionLaunch, from, now time.Time, budget float64, bad func(db.SessionLaunch) (bad, counts bool)) *Attainment {
+	badFraction := func(since time.Time) (float64, int) {
+		var total, failed int
+		for _, l := range launches {
+			if l.FinishedAt.Before(since) || l.FinishedAt.After(now) {
+				continue
+			}
+			b, counts := bad(l)
+			if !counts {
+				continue
+			}
+			total++
+			if b {
+				failed++
+			}
+		}
+		if total == 0 {
+			return 0, 0
+		}
+		return float64(failed) / float64(total), total
+	}
+
+	a := &Attainment{BurnRates: make(map[string]float64, len(BurnWindows))}
+	spent, _ := badFraction(from)
+	a.BudgetRemaining = round(100 * (1 - spent/budget))
+	for _, w := range BurnWindows {
+		fraction, total := badFraction(now.Add(-w.Duration))
+		rate := fraction / budget
+		a.BurnRates[w.Name] = round(rate)
+		if a.Alert == "" && total >= MinAlertLaunches && rate >= w.Threshold {
+			a.Alert = w.Name
+		}
+	}
+	return a
+}
+
+// quantile returns the nearest-rank q quantile of values, or 0 if there
+// are none.
+func quantile(values []float64, q float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+	return sorted[max(rank, 0)]
+}
+
+// round rounds to two decimal places.
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
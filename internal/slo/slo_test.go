@@ -0,0 +1,113 @@
+package slo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		slo     *db.AppSLO
+		wantErr bool
+	}{
+		{nil, false},
+		{&db.AppSLO{LaunchSuccessTarget: 99.5, StartLatencyP95Seconds: 30, WindowDays: 7}, false},
+		{&db.AppSLO{LaunchSuccessTarget: 100}, true},
+		{&db.AppSLO{LaunchSuccessTarget: -1}, true},
+		{&db.AppSLO{StartLatencyP95Seconds: -5}, true},
+		{&db.AppSLO{LaunchSuccessTarget: 99, WindowDays: MaxWindowDays + 1}, true},
+	}
+	for _, tt := range tests {
+		if err := Validate(tt.slo); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) error = %v, wantErr %v", tt.slo, err, tt.wantErr)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	app := &db.Application{
+		ID:   "app-a",
+		Name: "App A",
+		SLO:  &db.AppSLO{LaunchSuccessTarget: 90, StartLatencyP95Seconds: 20},
+	}
+
+	var launches []db.SessionLaunch
+	add := func(ago time.Duration, ok bool, seconds float64) {
+		launches = append(launches, db.SessionLaunch{FinishedAt: now.Add(-ago), Succeeded: ok, StartSeconds: seconds})
+	}
+	// 18 quick launches over the last week, and one failure and one slow
+	// launch ten days ago
+	for i := range 18 {
+		add(time.Duration(i+1)*8*time.Hour, true, 10)
+	}
+	add(10*24*time.Hour, false, 0)
+	add(10*24*time.Hour, true, 40)
+	// Outside the 30-day window
+	add(40*24*time.Hour, false, 0)
+
+	report := Evaluate(app, launches, now)
+	if report.WindowDays != DefaultWindowDays || !report.From.Equal(now.AddDate(0, 0, -30)) {
+		t.Errorf("window = %d days from %v", report.WindowDays, report.From)
+	}
+	if report.Launches != 20 || report.Failed != 1 {
+		t.Errorf("launches = %d, failed = %d, want 20 and 1", report.Launches, report.Failed)
+	}
+
+	ls := report.LaunchSuccess
+	if ls == nil || ls.Actual != 95 || !ls.Met || ls.BudgetRemaining != 50 || ls.Alert != "" {
+		t.Errorf("launch success = %+v, want 95%% met with half the budget left", ls)
+	}
+
+	// 19 successful launches: the nearest-rank p95 is the 19th, the slow one
+	sl := report.StartLatency
+	if report.StartLatencyP95 != 40 || sl == nil || sl.Met {
+		t.Errorf("start latency p95 = %v, %+v, want 40 and missed", report.StartLatencyP95, sl)
+	}
+	// 1 of 19 slow against a 5% budget
+	if sl.BudgetRemaining != -5.26 {
+		t.Errorf("start latency budget remaining = %v, want -5.26", sl.BudgetRemaining)
+	}
+}
+
+func TestEvaluate_BurnRateAlerts(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	app := &db.Application{ID: "app-a", SLO: &db.AppSLO{LaunchSuccessTarget: 99}}
+
+	launches := func(failed, total int, ago time.Duration) []db.SessionLaunch {
+		var out []db.SessionLaunch
+		for i := range total {
+			out = append(out, db.SessionLaunch{FinishedAt: now.Add(-ago), Succeeded: i >= failed})
+		}
+		return out
+	}
+
+	// Half of the last hour's launches failed: 50x the 1% budget
+	report := Evaluate(app, launches(5, 10, 30*time.Minute), now)
+	if a := report.LaunchSuccess; a.Alert != "fast" || a.BurnRates["fast"] != 50 {
+		t.Errorf("attainment = %+v, want a fast burn alert at 50", a)
+	}
+
+	// 10% failed three hours ago: the 6-hour window burns 10x
+	report = Evaluate(app, launches(2, 20, 3*time.Hour), now)
+	if a := report.LaunchSuccess; a.Alert != "slow" || a.BurnRates["fast"] != 0 || a.BurnRates["slow"] != 10 {
+		t.Errorf("attainment = %+v, want a slow burn alert at 10", a)
+	}
+
+	// Too few launches to alert on
+	report = Evaluate(app, launches(2, 2, 30*time.Minute), now)
+	if a := report.LaunchSuccess; a.Alert != "" || a.BurnRates["fast"] != 100 {
+		t.Errorf("attainment = %+v, want no alert", a)
+	}
+
+	// No launches meets the objective
+	report = Evaluate(app, nil, now)
+	if a := report.LaunchSuccess; a.Actual != 100 || !a.Met || a.BudgetRemaining != 100 || a.Alert != "" {
+		t.Errorf("attainment = %+v, want met with the full budget", a)
+	}
+	if report.StartLatency != nil {
+		t.Errorf("start latency = %+v, want untracked", report.StartLatency)
+	}
+}
@@ -0,0 +1,250 @@
+// Package spectate manages admin requests to watch a user's session
+// read-only. Each request produces a grant that the gateway checks when the
+// admin connects. Depending on the tenant's policy the grant is approved
+// immediately, optionally with a notice to the user, or waits for the user
+// to consent.
+package spectate
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// DefaultGrantTTL is how long a grant stays usable after it is requested.
+// A pending grant that is not answered in time expires unused.
+const DefaultGrantTTL = 15 * time.Minute
+
+// Events published to the session owner.
+const (
+	EventRequested = "spectate_requested" // consent needed
+	EventStarted   = "spectate_started"   // an admin started watching
+	EventEnded     = "spectate_ended"     // an admin stopped watching
+)
+
+var (
+	// ErrNotFound is returned for unknown, expired, or foreign grants.
+	ErrNotFound = errors.New("spectate grant not found")
+	// ErrNotPending is returned when answering a grant that was already answered.
+	ErrNotPending = errors.New("spectate grant is not pending")
+	// ErrNotApproved is returned when connecting with a pending or denied grant.
+	ErrNotApproved = errors.New("spectate grant is not approved")
+	// ErrConsentRequired is returned when observing a session whose owner
+	// must approve each request.
+	ErrConsentRequired = errors.New("the session owner must approve spectating")
+)
+
+// Status is the state of a grant.
+type Status string
+
+const (
+	StatusPending  Status = "pending"
+	StatusApproved Status = "approved"
+	StatusDenied   Status = "denied"
+)
+
+// Grant is an admin's permission to watch one session.
+type Grant struct {
+	ID            string            `json:"id"`
+	SessionID     string            `json:"session_id"`
+	OwnerID       string            `json:"-"`
+	AdminID       string            `json:"admin_id"`
+	AdminUsername string            `json:"admin_username"`
+	Policy        db.SpectatePolicy `json:"policy"`
+	Status        Status            `json:"status"`
+	CreatedAt     time.Time         `json:"created_at"`
+	ExpiresAt     time.Time         `json:"expires_at"`
+}
+
+// Notifier delivers events to a user's connected browsers.
+type Notifier interface {
+	Publish(userID, event string, payload any)
+}
+
+// Manager holds outstanding grants in memory.
+type Manager struct {
+	notifier Notifier
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu     sync.Mutex
+	grants map[string]*Grant
+}
+
+// NewManager creates a Manager. A nil notifier disables user notices.
+func NewManager(notifier Notifier) *Manager {
+	return &Manager{
+		notifier: notifier,
+		ttl:      DefaultGrantTTL,
+		now:      time.Now,
+		grants:   make(map[string]*Grant),
+	}
+}
+
+// Request records an admin's request to watch a session owned by ownerID.
+// Under the silent and notify policies the grant is approved immediately;
+// under require_consent it is pending and the owner is asked.
+func (m *Manager) Request(sessionID, ownerID, adminID, adminUsername string, policy db.SpectatePolicy) Grant {
+	policy = policy.OrDefault()
+	now := m.now()
+	g := &Grant{
+		ID:            uuid.New().String(),
+		SessionID:     sessionID,
+		OwnerID:       ownerID,
+		AdminID:       adminID,
+		AdminUsername: adminUsername,
+		Policy:        policy,
+		Status:        StatusApproved,
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(m.ttl),
+	}
+	if policy == db.SpectateRequireConsent {
+		g.Status = StatusPending
+	}
+
+	m.mu.Lock()
+	m.pruneLocked()
+	m.grants[g.ID] = g
+	m.mu.Unlock()
+
+	if g.Status == StatusPending {
+		m.publish(*g, EventRequested)
+	}
+	return *g
+}
+
+// Observe grants an admin who connects to a session in observe mode,
+// without requesting access first, read-only access under policy. The
+// grant is approved immediately, so under require_consent, where the owner
+// must approve each request, it fails with ErrConsentRequired.
+func (m *Manager) Observe(sessionID, ownerID, adminID, adminUsername string, policy db.SpectatePolicy) (Grant, error) {
+	if policy.OrDefault() == db.SpectateRequireConsent {
+		return Grant{}, ErrConsentRequired
+	}
+	return m.Request(sessionID, ownerID, adminID, adminUsername, policy), nil
+}
+
+// PolicyFor returns the spectate policy of the session's tenant.
+func PolicyFor(database *db.DB, session *db.Session) (db.SpectatePolicy, error) {
+	tenantID := session.TenantID
+	if tenantID == "" {
+		tenantID = db.DefaultTenantID
+	}
+	tenant, err := database.GetTenant(tenantID)
+	if err != nil {
+		return "", err
+	}
+	if tenant == nil {
+		return db.DefaultSpectatePolicy, nil
+	}
+	return tenant.Settings.SpectatePolicy.OrDefault(), nil
+}
+
+// Respond records the owner's answer to a pending grant.
+func (m *Manager) Respond(grantID, sessionID, ownerID string, approve bool) (Grant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.liveLocked(grantID)
+	if !ok || g.SessionID != sessionID || g.OwnerID != ownerID {
+		return Grant{}, ErrNotFound
+	}
+	if g.Status != StatusPending {
+		return Grant{}, ErrNotPending
+	}
+	if approve {
+		g.Status = StatusApproved
+	} else {
+		g.Status = StatusDenied
+	}
+	return *g, nil
+}
+
+// Get returns a live grant for a session.
+func (m *Manager) Get(grantID, sessionID string) (Grant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.liveLocked(grantID)
+	if !ok || g.SessionID != sessionID {
+		return Grant{}, ErrNotFound
+	}
+	return *g, nil
+}
+
+// Authorize checks that adminID holds an approved grant for the session.
+func (m *Manager) Authorize(grantID, sessionID, adminID string) (Grant, error) {
+	g, err := m.Get(grantID, sessionID)
+	if err != nil {
+		return Grant{}, err
+	}
+	if g.AdminID != adminID {
+		return Grant{}, ErrNotFound
+	}
+	if g.Status != StatusApproved {
+		return Grant{}, ErrNotApproved
+	}
+	return g, nil
+}
+
+// ForSession returns the live grants for a session, oldest first. Grants
+// made under the silent policy are included only when includeSilent is true.
+func (m *Manager) ForSession(sessionID string, includeSilent bool) []Grant {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pruneLocked()
+	grants := []Grant{}
+	for _, g := range m.grants {
+		if g.SessionID != sessionID || (g.Policy == db.SpectateSilent && !includeSilent) {
+			continue
+		}
+		grants = append(grants, *g)
+	}
+	sort.Slice(grants, func(i, j int) bool { return grants[i].CreatedAt.Before(grants[j].CreatedAt) })
+	return grants
+}
+
+// Started notifies the owner that the grant's admin is now watching, unless
+// the grant is silent.
+func (m *Manager) Started(g Grant) {
+	m.publish(g, EventStarted)
+}
+
+// Ended notifies the owner that the grant's admin stopped watching, unless
+// the grant is silent.
+func (m *Manager) Ended(g Grant) {
+	m.publish(g, EventEnded)
+}
+
+func (m *Manager) publish(g Grant, event string) {
+	if m.notifier == nil || g.Policy == db.SpectateSilent {
+		return
+	}
+	m.notifier.Publish(g.OwnerID, event, g)
+}
+
+func (m *Manager) liveLocked(grantID string) (*Grant, bool) {
+	g, ok := m.grants[grantID]
+	if !ok {
+		return nil, false
+	}
+	if !m.now().Before(g.ExpiresAt) {
+		delete(m.grants, grantID)
+		return nil, false
+	}
+	return g, true
+}
+
+func (m *Manager) pruneLocked() {
+	now := m.now()
+	for id, g := range m.grants {
+		if !now.Before(g.ExpiresAt) {
+			delete(m.grants, id)
+		}
+	}
+}
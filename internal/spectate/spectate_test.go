@@ -0,0 +1,187 @@
+package spectate
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/db/dbtest"
+)
+
+// recordingNotifier captures published events for assertions.
+type recordingNotifier struct {
+	events []string
+}
+
+func (n *recordingNotifier) Publish(userID, event string, _ any) {
+	n.events = append(n.events, userID+":"+event)
+}
+
+func TestRequest_Policies(t *testing.T) {
+	tests := []struct {
+		policy     db.SpectatePolicy
+		wantPolicy db.SpectatePolicy
+		wantStatus Status
+		wantEvents int
+	}{
+		{db.SpectateSilent, db.SpectateSilent, StatusApproved, 0},
+		{db.SpectateNotify, db.SpectateNotify, StatusApproved, 0},
+		{"", db.DefaultSpectatePolicy, StatusApproved, 0},
+		{db.SpectateRequireConsent, db.SpectateRequireConsent, StatusPending, 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(string(tc.wantPolicy), func(t *testing.T) {
+			n := &recordingNotifier{}
+			m := NewManager(n)
+			g := m.Request("sess-1", "owner", "admin-id", "admin", tc.policy)
+			if g.Policy != tc.wantPolicy || g.Status != tc.wantStatus {
+				t.Errorf("grant = %+v, want policy %s status %s", g, tc.wantPolicy, tc.wantStatus)
+			}
+			if len(n.events) != tc.wantEvents {
+				t.Errorf("events = %v, want %d", n.events, tc.wantEvents)
+			}
+		})
+	}
+}
+
+func TestConsentFlow(t *testing.T) {
+	n := &recordingNotifier{}
+	m := NewManager(n)
+	g := m.Request("sess-1", "owner", "admin-id", "admin", db.SpectateRequireConsent)
+
+	if _, err := m.Authorize(g.ID, "sess-1", "admin-id"); !errors.Is(err, ErrNotApproved) {
+		t.Fatalf("Authorize() before consent error = %v, want ErrNotApproved", err)
+	}
+	if _, err := m.Respond(g.ID, "sess-1", "someone-else", true); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Respond() by non-owner error = %v, want ErrNotFound", err)
+	}
+	if _, err := m.Respond(g.ID, "sess-1", "owner", true); err != nil {
+		t.Fatalf("Respond() error = %v", err)
+	}
+	if _, err := m.Respond(g.ID, "sess-1", "owner", false); !errors.Is(err, ErrNotPending) {
+		t.Errorf("second Respond() error = %v, want ErrNotPending", err)
+	}
+
+	got, err := m.Authorize(g.ID, "sess-1", "admin-id")
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	if _, err := m.Authorize(g.ID, "sess-1", "other-admin"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Authorize() by another admin error = %v, want ErrNotFound", err)
+	}
+	if _, err := m.Authorize(g.ID, "sess-2", "admin-id"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Authorize() for another session error = %v, want ErrNotFound", err)
+	}
+
+	m.Started(got)
+	m.Ended(got)
+	want := []string{"owner:" + EventRequested, "owner:" + EventStarted, "owner:" + EventEnded}
+	if len(n.events) != len(want) {
+		t.Fatalf("events = %v, want %v", n.events, want)
+	}
+	for i := range want {
+		if n.events[i] != want[i] {
+			t.Errorf("events[%d] = %s, want %s", i, n.events[i], want[i])
+		}
+	}
+}
+
+func TestDeniedGrant(t *testing.T) {
+	m := NewManager(nil)
+	g := m.Request("sess-1", "owner", "admin-id", "admin", db.SpectateRequireConsent)
+	if _, err := m.Respond(g.ID, "sess-1", "owner", false); err != nil {
+		t.Fatalf("Respond() error = %v", err)
+	}
+	if _, err := m.Authorize(g.ID, "sess-1", "admin-id"); !errors.Is(err, ErrNotApproved) {
+		t.Errorf("Authorize() error = %v, want ErrNotApproved", err)
+	}
+}
+
+func TestSilentGrantsHidden(t *testing.T) {
+	n := &recordingNotifier{}
+	m := NewManager(n)
+	silent := m.Request("sess-1", "owner", "admin-id", "admin", db.SpectateSilent)
+	m.Request("sess-1", "owner", "admin-id", "admin", db.SpectateNotify)
+
+	m.Started(silent)
+	m.Ended(silent)
+	if len(n.events) != 0 {
+		t.Errorf("silent grant published events: %v", n.events)
+	}
+
+	if got := m.ForSession("sess-1", false); len(got) != 1 || got[0].Policy != db.SpectateNotify {
+		t.Errorf("ForSession(owner view) = %+v, want only the notify grant", got)
+	}
+	if got := m.ForSession("sess-1", true); len(got) != 2 {
+		t.Errorf("ForSession(admin view) returned %d grants, want 2", len(got))
+	}
+}
+
+func TestGrantExpiry(t *testing.T) {
+	m := NewManager(nil)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	g := m.Request("sess-1", "owner", "admin-id", "admin", db.SpectateNotify)
+
+	now = now.Add(DefaultGrantTTL)
+	if _, err := m.Authorize(g.ID, "sess-1", "admin-id"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Authorize() after expiry error = %v, want ErrNotFound", err)
+	}
+	if got := m.ForSession("sess-1", true); len(got) != 0 {
+		t.Errorf("ForSession() = %+v, want expired grant pruned", got)
+	}
+}
+
+func TestObserve(t *testing.T) {
+	n := &recordingNotifier{}
+	m := NewManager(n)
+
+	g, err := m.Observe("sess-1", "owner", "admin-id", "admin", db.SpectateNotify)
+	if err != nil {
+		t.Fatalf("Observe() error = %v", err)
+	}
+	if g.Status != StatusApproved {
+		t.Errorf("status = %s, want approved", g.Status)
+	}
+	if _, err := m.Authorize(g.ID, "sess-1", "admin-id"); err != nil {
+		t.Errorf("Authorize() error = %v", err)
+	}
+	if len(n.events) != 0 {
+		t.Errorf("events = %v, want none before the admin connects", n.events)
+	}
+
+	if _, err := m.Observe("sess-1", "owner", "admin-id", "admin", db.SpectateRequireConsent); !errors.Is(err, ErrConsentRequired) {
+		t.Errorf("Observe() under require_consent error = %v, want ErrConsentRequired", err)
+	}
+	if len(n.events) != 0 {
+		t.Errorf("events = %v, want no consent request", n.events)
+	}
+}
+
+func TestPolicyFor(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+	tenant := db.Tenant{ID: "acme", Name: "Acme", Slug: "acme", Settings: db.TenantSettings{SpectatePolicy: db.SpectateSilent}}
+	if err := database.CreateTenant(tenant); err != nil {
+		t.Fatalf("CreateTenant() error = %v", err)
+	}
+
+	tests := []struct {
+		tenantID string
+		want     db.SpectatePolicy
+	}{
+		{"acme", db.SpectateSilent},
+		{"", db.DefaultSpectatePolicy},
+		{"missing", db.DefaultSpectatePolicy},
+	}
+	for _, tt := range tests {
+		got, err := PolicyFor(database, &db.Session{TenantID: tt.tenantID})
+		if err != nil {
+			t.Fatalf("PolicyFor(%q) error = %v", tt.tenantID, err)
+		}
+		if got != tt.want {
+			t.Errorf("PolicyFor(%q) = %s, want %s", tt.tenantID, got, tt.want)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -39,6 +40,7 @@ type sseEvent struct {
 // client represents a single connected EventSource.
 type client struct {
 	userID string
+	roles  []string
 	ch     chan sseEvent
 }
 
@@ -50,6 +52,9 @@ type Hub struct {
 
 	mu      sync.RWMutex
 	clients map[*client]struct{}
+
+	closeOnce sync.Once
+	done      chan struct{} // closed by Close
 }
 
 // NewHub creates an SSE hub.
@@ -57,9 +62,16 @@ func NewHub(authProvider Authenticator) *Hub {
 	return &Hub{
 		authProvider: authProvider,
 		clients:      make(map[*client]struct{}),
+		done:         make(chan struct{}),
 	}
 }
 
+// Close ends every stream, so a server shutting down is not held up by
+// them. Clients reconnect, to another replica if there is one.
+func (h *Hub) Close() {
+	h.closeOnce.Do(func() { close(h.done) })
+}
+
 // OnEvent implements sessions.SessionRecorder. It encodes the event as JSON
 // and performs a non-blocking fan-out to every client whose userID matches.
 func (h *Hub) OnEvent(_ context.Context, event sessions.SessionEventData) {
@@ -79,13 +91,45 @@ func (h *Hub) OnEvent(_ context.Context, event sessions.SessionEventData) {
 		return
 	}
 
-	msg := sseEvent{Event: "session", Data: data}
+	h.send(event.UserID, sseEvent{Event: "session", Data: data})
+}
+
+// Publish encodes payload as JSON and sends it as the given event type to
+// every client of userID. It is used for notifications that are not
+// session lifecycle events, such as spectate requests.
+func (h *Hub) Publish(userID, event string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("sse: failed to marshal event", "event", event, "error", err)
+		return
+	}
+	h.send(userID, sseEvent{Event: event, Data: data})
+}
+
+// PublishToRole is like Publish but sends to every client whose user has
+// role, such as admins for health notifications.
+func (h *Hub) PublishToRole(role, event string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("sse: failed to marshal event", "event", event, "error", err)
+		return
+	}
+	h.sendMatching(func(c *client) bool { return slices.Contains(c.roles, role) }, sseEvent{Event: event, Data: data})
+}
+
+// send performs a non-blocking fan-out of msg to every client of userID.
+func (h *Hub) send(userID string, msg sseEvent) {
+	h.sendMatching(func(c *client) bool { return c.userID == userID }, msg)
+}
 
+// sendMatching performs a non-blocking fan-out of msg to every client
+// match accepts.
+func (h *Hub) sendMatching(match func(*client) bool, msg sseEvent) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
 	for c := range h.clients {
-		if c.userID != event.UserID {
+		if !match(c) {
 			continue
 		}
 		select {
@@ -126,6 +170,7 @@ func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Register client.
 	c := &client{
 		userID: user.ID,
+		roles:  user.Roles,
 		ch:     make(chan sseEvent, clientBufSize),
 	}
 	h.mu.Lock()
@@ -150,6 +195,8 @@ func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		select {
 		case <-ctx.Done():
 			return
+		case <-h.done:
+			return
 		case msg := <-c.ch:
 			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.Event, msg.Data)
 			flusher.Flush()
@@ -216,6 +263,12 @@ func eventToStatus(event sessions.SessionEvent) string {
 		return "expired"
 	case sessions.EventSessionTerminated:
 		return "stopped"
+	case sessions.EventSessionHibernated:
+		return "hibernated"
+	case sessions.EventSessionResumed:
+		return "creating"
+	case sessions.EventSessionReconnecting:
+		return "reconnecting"
 	default:
 		return "unknown"
 	}
@@ -3,6 +3,7 @@ package sse
 import (
 	"bufio"
 	"context"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -115,6 +116,34 @@ func TestHub_ConnectedEventSent(t *testing.T) {
 	}
 }
 
+func TestHub_CloseEndsStreams(t *testing.T) {
+	hub, _ := newTestHub()
+
+	ts := httptest.NewServer(hub)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/sessions/events?token=valid")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	hub.Close()
+	hub.Close() // closing twice is safe
+
+	// The stream ends after the connected event
+	done := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, resp.Body)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream still open after Close")
+	}
+}
+
 func TestHub_EventRouting(t *testing.T) {
 	hub, _ := newTestHub()
 
@@ -246,6 +275,60 @@ func TestHub_FullBufferNonBlocking(t *testing.T) {
 	hub.mu.Unlock()
 }
 
+func TestHub_Publish(t *testing.T) {
+	hub, _ := newTestHub()
+
+	mine := &client{userID: "user1", ch: make(chan sseEvent, 1)}
+	other := &client{userID: "user2", ch: make(chan sseEvent, 1)}
+	hub.mu.Lock()
+	hub.clients[mine] = struct{}{}
+	hub.clients[other] = struct{}{}
+	hub.mu.Unlock()
+
+	hub.Publish("user1", "spectate_requested", map[string]string{"id": "grant-1"})
+
+	select {
+	case msg := <-mine.ch:
+		if msg.Event != "spectate_requested" || string(msg.Data) != `{"id":"grant-1"}` {
+			t.Errorf("got event %q with data %s", msg.Event, msg.Data)
+		}
+	default:
+		t.Error("expected event for user1")
+	}
+	select {
+	case msg := <-other.ch:
+		t.Errorf("unexpected event for user2: %+v", msg)
+	default:
+	}
+}
+
+func TestHub_PublishToRole(t *testing.T) {
+	hub, _ := newTestHub()
+
+	admin := &client{userID: "admin1", roles: []string{"user", "admin"}, ch: make(chan sseEvent, 1)}
+	user := &client{userID: "user1", roles: []string{"user"}, ch: make(chan sseEvent, 1)}
+	hub.mu.Lock()
+	hub.clients[admin] = struct{}{}
+	hub.clients[user] = struct{}{}
+	hub.mu.Unlock()
+
+	hub.PublishToRole("admin", "health", map[string]string{"component": "database"})
+
+	select {
+	case msg := <-admin.ch:
+		if msg.Event != "health" || string(msg.Data) != `{"component":"database"}` {
+			t.Errorf("got event %q with data %s", msg.Event, msg.Data)
+		}
+	default:
+		t.Error("expected event for admin")
+	}
+	select {
+	case msg := <-user.ch:
+		t.Errorf("unexpected event for non-admin: %+v", msg)
+	default:
+	}
+}
+
 func TestEventToStatus(t *testing.T) {
 	tests := []struct {
 		event  sessions.SessionEvent
@@ -258,6 +341,9 @@ func TestEventToStatus(t *testing.T) {
 		{sessions.EventSessionRestarted, "creating"},
 		{sessions.EventSessionExpired, "expired"},
 		{sessions.EventSessionTerminated, "stopped"},
+		{sessions.EventSessionHibernated, "hibernated"},
+		{sessions.EventSessionResumed, "creating"},
+		{sessions.EventSessionReconnecting, "reconnecting"},
 		{sessions.SessionEvent("unknown.event"), "unknown"},
 	}
 
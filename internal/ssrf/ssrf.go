@@ -0,0 +1,294 @@
+// Package ssrf guards outbound requests to admin-supplied URLs, such as
+// webhooks and health probes, against server-side request forgery. By
+// default only public addresses are reachable: loopback, private,
+// link-local (including cloud metadata endpoints) and other internal
+// ranges are refused unless the server-wide allowlist names them.
+//
+// Host names are resolved once and the connection is made to the checked
+// address, so a name that resolves to a public address when checked and an
+// internal one when connecting (DNS rebinding) cannot slip through.
+package ssrf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrBlocked is returned for a destination the policy does not allow.
+var ErrBlocked = errors.New("destination not allowed")
+
+// maxRedirects bounds the redirects a guarded client follows. Each hop is
+// checked like the first request.
+const maxRedirects = 5
+
+// internalPrefixes are ranges that are not reachable on the internet, or
+// that reach infrastructure the server should not be made to call, in
+// addition to those netip.Addr reports (loopback, private, link-local,
+// multicast and unspecified).
+var internalPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "This network"
+	netip.MustParsePrefix("100.64.0.0/10"),  // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // Benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),    // Reserved, and broadcast
+	netip.MustParsePrefix("100::/64"),       // Discard-only
+	netip.MustParsePrefix("2001:db8::/32"),  // Documentation
+	netip.MustParsePrefix("64:ff9b:1::/48"), // Local-use NAT64
+}
+
+// nat64Prefix is the well-known NAT64 prefix. Its addresses embed an IPv4
+// address, which is checked in their place.
+var nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+
+// IsInternal reports whether addr is in a range that outbound requests may
+// not reach unless allowlisted.
+func IsInternal(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if nat64Prefix.Contains(addr) {
+		b := addr.As16()
+		return IsInternal(netip.AddrFrom4([4]byte(b[12:])))
+	}
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return true
+	}
+	for _, p := range internalPrefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowlist is a set of destinations: IP addresses, CIDR ranges, host
+// names, and "*.example.com" wildcards, which match subdomains but not
+// example.com itself. The zero Allowlist is empty.
+type Allowlist struct {
+	prefixes []netip.Prefix
+	hosts    []string
+	suffixes []string // Wildcards, as ".example.com"
+}
+
+// ParseAllowlist parses allowlist entries. Empty entries are skipped.
+func ParseAllowlist(entries []string) (Allowlist, error) {
+	var a Allowlist
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case strings.Contains(entry, "/"):
+			p, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return Allowlist{}, fmt.Errorf("invalid CIDR range %q", entry)
+			}
+			a.prefixes = append(a.prefixes, p.Masked())
+		default:
+			if addr, err := netip.ParseAddr(entry); err == nil {
+				a.prefixes = append(a.prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+				continue
+			}
+			host, wildcard := strings.CutPrefix(entry, "*.")
+			if !validHostname(host) {
+				return Allowlist{}, fmt.Errorf("invalid host %q", entry)
+			}
+			if wildcard {
+				a.suffixes = append(a.suffixes, "."+host)
+			} else {
+				a.hosts = append(a.hosts, host)
+			}
+		}
+	}
+	return a, nil
+}
+
+// SplitAllowlist parses a comma-separated allowlist.
+func SplitAllowlist(s string) (Allowlist, error) {
+	return ParseAllowlist(strings.Split(s, ","))
+}
+
+// Empty reports whether the allowlist has no entries.
+func (a Allowlist) Empty() bool {
+	return len(a.prefixes)+len(a.hosts)+len(a.suffixes) == 0
+}
+
+// Allows reports whether an entry matches host, a lowercase name or
+// address literal, or addr, one of the addresses it resolves to.
+func (a Allowlist) Allows(host string, addr netip.Addr) bool {
+	return a.matchHost(host) || a.matchAddr(addr)
+}
+
+// matchHost reports whether a host name entry matches host.
+func (a Allowlist) matchHost(host string) bool {
+	for _, h := range a.hosts {
+		if h == host {
+			return true
+		}
+	}
+	for _, s := range a.suffixes {
+		if strings.HasSuffix(host, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAddr reports whether an address or range entry contains addr.
+func (a Allowlist) matchAddr(addr netip.Addr) bool {
+	for _, p := range a.prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// validHostname reports whether s is a DNS name of letters, digits,
+// hyphens and underscores.
+func validHostname(s string) bool {
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' && c != '_' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Guard applies the server-wide outbound policy. Entries in its allowlist
+// may be reached even when internal. Callers may pass a narrower
+// allowlist, such as a tenant's, that limits destinations further; it
+// cannot open internal ranges.
+type Guard struct {
+	allow   Allowlist
+	offline bool
+	lookup  func(ctx context.Context, host string) ([]netip.Addr, error)
+}
+
+// NewGuard returns a Guard that allows internal destinations only if allow
+// matches them.
+func NewGuard(allow Allowlist) *Guard {
+	return &Guard{allow: allow, lookup: func(ctx context.Context, host string) ([]netip.Addr, error) {
+		return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	}}
+}
+
+// SetOffline makes the guard refuse public destinations that its
+// allowlist does not match, for air-gapped deployments.
+func (g *Guard) SetOffline(offline bool) {
+	g.offline = offline
+}
+
+// Check returns the addresses a request to rawURL would connect to, or an
+// error wrapping ErrBlocked if the URL is not allowed. A non-empty only
+// allowlist must match the host or every address.
+func (g *Guard) Check(ctx context.Context, rawURL string, only Allowlist) ([]netip.Addr, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%w: scheme must be http or https", ErrBlocked)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid URL: missing host")
+	}
+	return g.resolve(ctx, u.Hostname(), only)
+}
+
+// resolve looks up host and checks every address it resolves to. A host
+// with any disallowed address is refused outright rather than filtered,
+// since mixing public and internal records is a rebinding tell.
+func (g *Guard) resolve(ctx context.Context, host string, only Allowlist) ([]netip.Addr, error) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	var addrs []netip.Addr
+	literal, err := netip.ParseAddr(strings.Trim(host, "[]"))
+	if err == nil {
+		addrs = []netip.Addr{literal}
+	} else {
+		if addrs, err = g.lookup(ctx, host); err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", host, err)
+		}
+		if len(addrs) == 0 {
+			return nil, fmt.Errorf("failed to resolve %s: no addresses", host)
+		}
+	}
+
+	for i, addr := range addrs {
+		addr = addr.Unmap().WithZone("")
+		addrs[i] = addr
+		if !only.Empty() && !only.matchHost(host) && !only.matchAddr(addr) {
+			return nil, fmt.Errorf("%w: %s is not in the allowlist", ErrBlocked, host)
+		}
+		if IsInternal(addr) && !g.allow.matchHost(host) && !g.allow.matchAddr(addr) {
+			if literal.IsValid() {
+				return nil, fmt.Errorf("%w: %s is an internal address", ErrBlocked, addr)
+			}
+			return nil, fmt.Errorf("%w: %s resolves to internal address %s", ErrBlocked, host, addr)
+		}
+		if g.offline && !IsInternal(addr) && !g.allow.matchHost(host) && !g.allow.matchAddr(addr) {
+			return nil, fmt.Errorf("%w: %s is on the internet and the server is offline", ErrBlocked, host)
+		}
+	}
+	return addrs, nil
+}
+
+// Client returns an HTTP client that connects only to destinations the
+// policy and only allow, including on redirects. It ignores proxy
+// environment variables, since the proxy would make the connection the
+// guard cannot see.
+func (g *Guard) Client(timeout time.Duration, only Allowlist) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				host, port, err := net.SplitHostPort(address)
+				if err != nil {
+					return nil, err
+				}
+				addrs, err := g.resolve(ctx, host, only)
+				if err != nil {
+					return nil, err
+				}
+				// Dial the checked addresses, never the name again
+				var dialErr error
+				for _, addr := range addrs {
+					conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+					if err == nil {
+						return conn, nil
+					}
+					dialErr = err
+				}
+				return nil, dialErr
+			},
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("%w: redirect to %s URL", ErrBlocked, req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
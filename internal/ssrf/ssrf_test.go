@@ -0,0 +1,244 @@
+package ssrf
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestIsInternal(t *testing.T) {
+	for _, tt := range []struct {
+		addr string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true}, // Cloud metadata
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"255.255.255.255", true},
+		{"224.0.0.1", true},
+		{"::1", true},
+		{"fe80::1", true},
+		{"fd00:ec2::254", true}, // AWS metadata over IPv6
+		{"::ffff:127.0.0.1", true},
+		{"64:ff9b::a9fe:a9fe", true}, // NAT64 of 169.254.169.254
+		{"8.8.8.8", false},
+		{"93.184.216.34", false},
+		{"2606:4700::1111", false},
+		{"64:ff9b::808:808", false}, // NAT64 of 8.8.8.8
+	} {
+		if got := IsInternal(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("IsInternal(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestParseAllowlist(t *testing.T) {
+	a, err := ParseAllowlist([]string{" 10.0.0.0/8", "192.168.1.5", "Hooks.Example.com", "*.internal.example.com", ""})
+	if err != nil {
+		t.Fatalf("ParseAllowlist() error = %v", err)
+	}
+	for _, addr := range []string{"10.2.3.4", "192.168.1.5"} {
+		if !a.matchAddr(netip.MustParseAddr(addr)) {
+			t.Errorf("%s not matched", addr)
+		}
+	}
+	if a.matchAddr(netip.MustParseAddr("192.168.1.6")) {
+		t.Error("192.168.1.6 matched")
+	}
+	for _, host := range []string{"hooks.example.com", "a.internal.example.com", "a.b.internal.example.com"} {
+		if !a.matchHost(host) {
+			t.Errorf("%s not matched", host)
+		}
+	}
+	for _, host := range []string{"internal.example.com", "example.com", "evilinternal.example.com"} {
+		if a.matchHost(host) {
+			t.Errorf("%s matched", host)
+		}
+	}
+
+	for _, bad := range []string{"10.0.0.0/33", "not a host", "*.", "-bad.example.com", "http://example.com"} {
+		if _, err := ParseAllowlist([]string{bad}); err == nil {
+			t.Errorf("ParseAllowlist(%q) = nil error", bad)
+		}
+	}
+	if a, _ := SplitAllowlist(""); !a.Empty() {
+		t.Error("SplitAllowlist(\"\") is not empty")
+	}
+}
+
+// fakeGuard returns a Guard that resolves names from records.
+func fakeGuard(t *testing.T, allow []string, records map[string][]string) *Guard {
+	t.Helper()
+	a, err := ParseAllowlist(allow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGuard(a)
+	g.lookup = func(_ context.Context, host string) ([]netip.Addr, error) {
+		var addrs []netip.Addr
+		for _, s := range records[host] {
+			addrs = append(addrs, netip.MustParseAddr(s))
+		}
+		if addrs == nil {
+			return nil, errors.New("no such host")
+		}
+		return addrs, nil
+	}
+	return g
+}
+
+func TestGuard_Check(t *testing.T) {
+	g := fakeGuard(t, []string{"10.0.5.0/24", "hooks.corp.example"}, map[string][]string{
+		"public.example.com": {"93.184.216.34"},
+		"metadata.example":   {"169.254.169.254"},
+		"mixed.example.com":  {"93.184.216.34", "10.0.0.1"},
+		"hooks.corp.example": {"10.9.9.9"},
+		"ci.corp.example":    {"10.0.5.20"},
+	})
+	ctx := context.Background()
+	tenant, _ := ParseAllowlist([]string{"*.example.com"})
+
+	for _, tt := range []struct {
+		url     string
+		only    Allowlist
+		allowed bool
+	}{
+		{"https://public.example.com/hook", Allowlist{}, true},
+		{"http://127.0.0.1:8080/", Allowlist{}, false},
+		{"http://[::1]/", Allowlist{}, false},
+		{"http://169.254.169.254/latest/meta-data/", Allowlist{}, false},
+		{"http://metadata.example/", Allowlist{}, false},
+		{"https://mixed.example.com/", Allowlist{}, false},
+		{"https://hooks.corp.example/", Allowlist{}, true}, // Allowlisted name
+		{"https://ci.corp.example/", Allowlist{}, true},    // Allowlisted range
+		{"http://10.0.5.1/", Allowlist{}, true},
+		{"http://10.0.6.1/", Allowlist{}, false},
+		{"ftp://public.example.com/", Allowlist{}, false},
+		// A tenant allowlist narrows, and cannot open internal ranges
+		{"https://public.example.com/", tenant, true},
+		{"https://ci.corp.example/", tenant, false},
+		{"http://metadata.example/", mustAllowlist(t, "metadata.example"), false},
+	} {
+		_, err := g.Check(ctx, tt.url, tt.only)
+		if tt.allowed && err != nil {
+			t.Errorf("Check(%s) error = %v, want allowed", tt.url, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrBlocked) {
+			t.Errorf("Check(%s) error = %v, want ErrBlocked", tt.url, err)
+		}
+	}
+
+	if _, err := g.Check(ctx, "https://unknown.example.com/", Allowlist{}); err == nil || errors.Is(err, ErrBlocked) {
+		t.Errorf("unresolvable host: error = %v, want a lookup error", err)
+	}
+}
+
+func TestGuard_CheckOffline(t *testing.T) {
+	g := fakeGuard(t, []string{"10.0.5.0/24", "updates.example.com"}, map[string][]string{
+		"public.example.com":  {"93.184.216.34"},
+		"updates.example.com": {"93.184.216.35"},
+		"ci.corp.example":     {"10.0.5.20"},
+	})
+	g.SetOffline(true)
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://public.example.com/", false},
+		{"https://updates.example.com/", true}, // Allowlisted name
+		{"https://ci.corp.example/", true},
+		{"http://10.0.6.1/", false},
+	} {
+		_, err := g.Check(ctx, tt.url, Allowlist{})
+		if tt.allowed && err != nil {
+			t.Errorf("Check(%s) error = %v, want allowed", tt.url, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrBlocked) {
+			t.Errorf("Check(%s) error = %v, want ErrBlocked", tt.url, err)
+		}
+	}
+}
+
+func mustAllowlist(t *testing.T, entries ...string) Allowlist {
+	t.Helper()
+	a, err := ParseAllowlist(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestGuard_Client(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://127.0.0.1:1/", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer backend.Close()
+	_, port, _ := net.SplitHostPort(backend.Listener.Addr().String())
+
+	t.Run("blocked by default", func(t *testing.T) {
+		client := NewGuard(Allowlist{}).Client(5*time.Second, Allowlist{})
+		if _, err := client.Get(backend.URL); !errors.Is(err, ErrBlocked) {
+			t.Errorf("Get(loopback) error = %v, want ErrBlocked", err)
+		}
+	})
+
+	t.Run("allowlisted", func(t *testing.T) {
+		client := NewGuard(mustAllowlist(t, "127.0.0.1")).Client(5*time.Second, Allowlist{})
+		resp, err := client.Get(backend.URL)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Errorf("status %d, want 204", resp.StatusCode)
+		}
+	})
+
+	t.Run("rebinding", func(t *testing.T) {
+		// The name first resolves to a public address, then to loopback.
+		// The client checks and dials the same lookup, so the switch is
+		// refused.
+		g := NewGuard(Allowlist{})
+		lookups := 0
+		g.lookup = func(context.Context, string) ([]netip.Addr, error) {
+			lookups++
+			if lookups == 1 {
+				return []netip.Addr{netip.MustParseAddr("93.184.216.34")}, nil
+			}
+			return []netip.Addr{netip.MustParseAddr("127.0.0.1")}, nil
+		}
+		if _, err := g.Check(context.Background(), "http://rebind.example:"+port, Allowlist{}); err != nil {
+			t.Fatalf("first lookup: Check() error = %v", err)
+		}
+		if _, err := g.Client(5*time.Second, Allowlist{}).Get("http://rebind.example:" + port); !errors.Is(err, ErrBlocked) {
+			t.Errorf("Get() after rebinding error = %v, want ErrBlocked", err)
+		}
+	})
+
+	t.Run("redirect to internal address", func(t *testing.T) {
+		g := fakeGuard(t, nil, map[string][]string{"public.example": {"127.0.0.1"}})
+		// Allow the first hop by name only; the redirect goes to an IP
+		g.allow = mustAllowlist(t, "public.example")
+		_, err := g.Client(5*time.Second, Allowlist{}).Get("http://public.example:" + port + "/redirect")
+		var urlErr *url.Error
+		if !errors.As(err, &urlErr) || !errors.Is(err, ErrBlocked) {
+			t.Errorf("Get() error = %v, want ErrBlocked on the redirect", err)
+		}
+	})
+}
@@ -0,0 +1,20 @@
+// Package ssrftest provides shared test helpers for code that makes
+// outbound requests through an ssrf.Guard.
+package ssrftest
+
+import (
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/ssrf"
+)
+
+// LoopbackGuard returns an outbound guard that lets requests reach test
+// servers on the loopback address, such as those from httptest.
+func LoopbackGuard(t *testing.T) *ssrf.Guard {
+	t.Helper()
+	allow, err := ssrf.ParseAllowlist([]string{"127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ssrf.NewGuard(allow)
+}
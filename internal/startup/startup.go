@@ -0,0 +1,148 @@
+// Package startup brings the server's dependencies up in order. A step
+// whose dependency is briefly unavailable, such as a database that is
+// still starting or an API server mid-upgrade, is retried with backoff
+// instead of exiting, and while steps run the server answers /healthz as
+// alive and /readyz as not ready, so an orchestrator waits rather than
+// restarting it in a crash loop.
+package startup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Backoff bounds the delay between attempts of a step: the first retry
+// waits Initial, and each later one twice as long, up to Max.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// DefaultBackoff is the backoff used by New.
+var DefaultBackoff = Backoff{Initial: time.Second, Max: 30 * time.Second}
+
+// Sequence runs startup steps and reports their progress. Its handler
+// serves the probes until Ready is called, then the server's handler.
+type Sequence struct {
+	backoff Backoff
+	timeout time.Duration
+	started time.Time
+	handler atomic.Pointer[http.Handler]
+
+	mu        sync.Mutex
+	step      string
+	attempt   int
+	lastErr   error
+	completed []string
+}
+
+// New returns a Sequence whose steps are retried until timeout has passed
+// since it was created, or indefinitely if timeout is zero.
+func New(timeout time.Duration) *Sequence {
+	return &Sequence{backoff: DefaultBackoff, timeout: timeout, started: time.Now()}
+}
+
+// SetBackoff replaces the delay between attempts.
+func (s *Sequence) SetBackoff(b Backoff) {
+	s.backoff = b
+}
+
+// Run runs fn until it succeeds. Failed attempts are logged and retried
+// after a backoff; Run returns the last error once the startup timeout
+// has passed or ctx is done.
+func (s *Sequence) Run(ctx context.Context, step string, fn func(ctx context.Context) error) error {
+	var deadline <-chan time.Time
+	if s.timeout > 0 {
+		timer := time.NewTimer(time.Until(s.started.Add(s.timeout)))
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	delay := s.backoff.Initial
+	for attempt := 1; ; attempt++ {
+		s.mu.Lock()
+		s.step, s.attempt = step, attempt
+		s.mu.Unlock()
+
+		err := fn(ctx)
+		if err == nil {
+			s.mu.Lock()
+			s.step, s.attempt, s.lastErr = "", 0, nil
+			s.completed = append(s.completed, step)
+			s.mu.Unlock()
+			if attempt > 1 {
+				slog.Info("startup step succeeded after retrying", "step", step, "attempts", attempt)
+			}
+			return nil
+		}
+
+		s.mu.Lock()
+		s.lastErr = err
+		s.mu.Unlock()
+		slog.Warn("startup step failed; retrying", "step", step, "attempt", attempt, "retry_in", delay, "error", err)
+
+		select {
+		case <-time.After(delay):
+		case <-deadline:
+			return fmt.Errorf("%s: gave up after %d attempts: %w", step, attempt, err)
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %w", step, err)
+		}
+		delay = min(2*delay, s.backoff.Max)
+	}
+}
+
+// Ready hands requests to h from now on.
+func (s *Sequence) Ready(h http.Handler) {
+	s.handler.Store(&h)
+}
+
+// Status is the startup progress /readyz reports until the server is
+// ready.
+type Status struct {
+	Status    string   `json:"status"`
+	Step      string   `json:"step,omitempty"`
+	Attempt   int      `json:"attempt,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Completed []string `json:"completed"`
+}
+
+// Status returns the current startup progress.
+func (s *Sequence) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := Status{Status: "starting", Step: s.step, Attempt: s.attempt, Completed: append([]string{}, s.completed...)}
+	if s.lastErr != nil {
+		st.Error = s.lastErr.Error()
+	}
+	return st
+}
+
+// ServeHTTP serves the server's handler once Ready has been called.
+// Before then, /healthz reports the process alive, /readyz reports the
+// startup progress with 503, and other requests get 503.
+func (s *Sequence) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h := s.handler.Load(); h != nil {
+		(*h).ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/healthz":
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	case "/readyz":
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(s.Status())
+	default:
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sortie is starting"})
+	}
+}
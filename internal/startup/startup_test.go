@@ -0,0 +1,128 @@
+package startup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testBackoff = Backoff{Initial: time.Millisecond, Max: 4 * time.Millisecond}
+
+func TestRun_RetriesUntilSuccess(t *testing.T) {
+	s := New(0)
+	s.SetBackoff(testBackoff)
+
+	attempts := 0
+	err := s.Run(context.Background(), "database", func(context.Context) error {
+		if attempts++; attempts < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("attempts = %d, want 3", attempts)
+	}
+	if st := s.Status(); st.Step != "" || st.Error != "" || len(st.Completed) != 1 || st.Completed[0] != "database" {
+		t.Errorf("Status() = %+v", st)
+	}
+}
+
+func TestRun_Timeout(t *testing.T) {
+	s := New(20 * time.Millisecond)
+	s.SetBackoff(testBackoff)
+
+	refused := errors.New("connection refused")
+	err := s.Run(context.Background(), "database", func(context.Context) error { return refused })
+	if !errors.Is(err, refused) {
+		t.Fatalf("Run() error = %v, want the step's error", err)
+	}
+
+	// Once the timeout has passed, later steps get a single attempt
+	attempts := 0
+	s.Run(context.Background(), "kubernetes", func(context.Context) error {
+		attempts++
+		return refused
+	})
+	if attempts != 1 {
+		t.Errorf("attempts after the timeout = %d, want 1", attempts)
+	}
+}
+
+func TestRun_Canceled(t *testing.T) {
+	s := New(0)
+	s.SetBackoff(Backoff{Initial: time.Hour, Max: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() {
+		done <- s.Run(ctx, "database", func(context.Context) error { return errors.New("connection refused") })
+	}()
+	cancel()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Run() should fail when canceled")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after cancel")
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	s := New(0)
+	s.SetBackoff(Backoff{Initial: time.Hour, Max: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	failed := make(chan struct{})
+	go s.Run(ctx, "migrations", func(context.Context) error {
+		defer close(failed)
+		return errors.New("database is locked")
+	})
+	<-failed
+	// Wait for the failure to be recorded
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Status().Error == "" && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	if rr := get("/healthz"); rr.Code != http.StatusOK {
+		t.Errorf("/healthz while starting: status %d, want 200", rr.Code)
+	}
+	rr := get("/readyz")
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz while starting: status %d, want 503", rr.Code)
+	}
+	var st Status
+	if err := json.NewDecoder(rr.Body).Decode(&st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Status != "starting" || st.Step != "migrations" || st.Attempt != 1 || st.Error != "database is locked" {
+		t.Errorf("/readyz body = %+v", st)
+	}
+	if rr := get("/api/apps"); rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("/api/apps while starting: status %d, Retry-After %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+
+	s.Ready(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	for _, path := range []string{"/healthz", "/readyz", "/api/apps"} {
+		if rr := get(path); rr.Code != http.StatusTeapot {
+			t.Errorf("%s once ready: status %d, want the server's handler", path, rr.Code)
+		}
+	}
+}
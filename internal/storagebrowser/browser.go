@@ -0,0 +1,209 @@
+// Package storagebrowser reports object storage usage for admins. Each
+// storage category (recordings, and later uploads, archives, and backups)
+// pairs an object store with the set of paths its database records refer
+// to, so objects no record points at can be found and removed.
+package storagebrowser
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultGracePeriod is how old an unreferenced object must be before it is
+// treated as orphaned. Objects are written before their database record is
+// updated, so very recent objects may simply be in flight.
+const DefaultGracePeriod = time.Hour
+
+// ErrUnknownCategory is returned for a category that is not configured.
+var ErrUnknownCategory = errors.New("unknown storage category")
+
+// ErrNotOrphaned is returned when cleanup is asked to delete an object that
+// is still referenced or too recent to be considered orphaned.
+var ErrNotOrphaned = errors.New("object is not orphaned")
+
+// Object describes one stored object.
+type Object struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Store is the object store behind a category.
+type Store interface {
+	// List returns every object in the store. Paths must be in the same form
+	// the store returns from Save and accepts in Delete.
+	List() ([]Object, error)
+
+	// Delete removes the object at the given path.
+	Delete(path string) error
+}
+
+// Category is a named group of objects backed by one store.
+type Category struct {
+	Name    string
+	Backend string
+	Store   Store
+
+	// References returns the set of paths referred to by database records.
+	References func() (map[string]bool, error)
+}
+
+// Usage summarizes one category.
+type Usage struct {
+	Category      string `json:"category"`
+	Backend       string `json:"backend"`
+	Objects       int    `json:"objects"`
+	Bytes         int64  `json:"bytes"`
+	Orphaned      int    `json:"orphaned"`
+	OrphanedBytes int64  `json:"orphaned_bytes"`
+	// Missing counts database references with no matching object.
+	Missing int `json:"missing"`
+}
+
+// Browser inspects and cleans up the configured storage categories.
+type Browser struct {
+	categories  []Category
+	gracePeriod time.Duration
+	now         func() time.Time
+}
+
+// New creates a Browser over the given categories.
+func New(categories ...Category) *Browser {
+	return &Browser{
+		categories:  categories,
+		gracePeriod: DefaultGracePeriod,
+		now:         time.Now,
+	}
+}
+
+// SetGracePeriod overrides DefaultGracePeriod.
+func (b *Browser) SetGracePeriod(d time.Duration) {
+	b.gracePeriod = d
+}
+
+// Categories returns the names of the configured categories.
+func (b *Browser) Categories() []string {
+	names := make([]string, len(b.categories))
+	for i, c := range b.categories {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// Usage returns a summary for every configured category.
+func (b *Browser) Usage() ([]Usage, error) {
+	usage := make([]Usage, 0, len(b.categories))
+	for _, c := range b.categories {
+		objects, refs, err := b.scan(c)
+		if err != nil {
+			return nil, err
+		}
+		u := Usage{Category: c.Name, Backend: c.Backend, Objects: len(objects)}
+		found := make(map[string]bool, len(objects))
+		for _, o := range objects {
+			u.Bytes += o.Size
+			found[o.Path] = true
+			if b.orphaned(o, refs) {
+				u.Orphaned++
+				u.OrphanedBytes += o.Size
+			}
+		}
+		for path := range refs {
+			if !found[path] {
+				u.Missing++
+			}
+		}
+		usage = append(usage, u)
+	}
+	return usage, nil
+}
+
+// Orphans returns the orphaned objects in a category, sorted by path.
+func (b *Browser) Orphans(category string) ([]Object, error) {
+	c, err := b.category(category)
+	if err != nil {
+		return nil, err
+	}
+	objects, refs, err := b.scan(c)
+	if err != nil {
+		return nil, err
+	}
+	orphans := []Object{}
+	for _, o := range objects {
+		if b.orphaned(o, refs) {
+			orphans = append(orphans, o)
+		}
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].Path < orphans[j].Path })
+	return orphans, nil
+}
+
+// DeleteOrphans removes orphaned objects from a category. If paths is empty
+// every orphan is removed; otherwise each path must be an orphan, and
+// nothing is deleted if one is not. It returns the paths that were deleted,
+// which on error covers the deletions made before the failure.
+func (b *Browser) DeleteOrphans(category string, paths []string) ([]string, error) {
+	c, err := b.category(category)
+	if err != nil {
+		return nil, err
+	}
+	orphans, err := b.Orphans(category)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]string, 0, len(orphans))
+	if len(paths) == 0 {
+		for _, o := range orphans {
+			targets = append(targets, o.Path)
+		}
+	} else {
+		isOrphan := make(map[string]bool, len(orphans))
+		for _, o := range orphans {
+			isOrphan[o.Path] = true
+		}
+		for _, p := range paths {
+			if !isOrphan[p] {
+				return nil, fmt.Errorf("%w: %s", ErrNotOrphaned, p)
+			}
+		}
+		targets = append(targets, paths...)
+	}
+
+	deleted := make([]string, 0, len(targets))
+	for _, p := range targets {
+		if err := c.Store.Delete(p); err != nil {
+			return deleted, fmt.Errorf("failed to delete %s: %w", p, err)
+		}
+		deleted = append(deleted, p)
+	}
+	return deleted, nil
+}
+
+func (b *Browser) category(name string) (Category, error) {
+	for _, c := range b.categories {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	return Category{}, fmt.Errorf("%w: %s", ErrUnknownCategory, name)
+}
+
+// scan lists a category's objects and loads its references.
+func (b *Browser) scan(c Category) ([]Object, map[string]bool, error) {
+	objects, err := c.Store.List()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list %s objects: %w", c.Name, err)
+	}
+	refs, err := c.References()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load %s references: %w", c.Name, err)
+	}
+	return objects, refs, nil
+}
+
+func (b *Browser) orphaned(o Object, refs map[string]bool) bool {
+	return !refs[o.Path] && b.now().Sub(o.ModTime) >= b.gracePeriod
+}
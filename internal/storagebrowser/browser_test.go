@@ -0,0 +1,158 @@
+package storagebrowser
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeStore is an in-memory Store for testing.
+type fakeStore struct {
+	objects   map[string]Object
+	deleteErr error
+}
+
+func (f *fakeStore) List() ([]Object, error) {
+	objects := make([]Object, 0, len(f.objects))
+	for _, o := range f.objects {
+		objects = append(objects, o)
+	}
+	return objects, nil
+}
+
+func (f *fakeStore) Delete(path string) error {
+	if f.deleteErr != nil {
+		return f.deleteErr
+	}
+	delete(f.objects, path)
+	return nil
+}
+
+func newTestBrowser(t *testing.T) (*Browser, *fakeStore) {
+	t.Helper()
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	old := now.Add(-2 * time.Hour)
+	store := &fakeStore{objects: map[string]Object{
+		"2026/05/kept.vncrec":   {Path: "2026/05/kept.vncrec", Size: 100, ModTime: old},
+		"2026/05/kept.mp4":      {Path: "2026/05/kept.mp4", Size: 50, ModTime: old},
+		"2026/05/orphan.vncrec": {Path: "2026/05/orphan.vncrec", Size: 30, ModTime: old},
+		"2026/05/stray.mp4":     {Path: "2026/05/stray.mp4", Size: 20, ModTime: old},
+		"2026/06/new.vncrec":    {Path: "2026/06/new.vncrec", Size: 10, ModTime: now.Add(-time.Minute)},
+	}}
+	refs := map[string]bool{
+		"2026/05/kept.vncrec": true,
+		"2026/05/kept.mp4":    true,
+		"2026/04/gone.vncrec": true,
+	}
+	b := New(Category{
+		Name:       "recordings",
+		Backend:    "local",
+		Store:      store,
+		References: func() (map[string]bool, error) { return refs, nil },
+	})
+	b.now = func() time.Time { return now }
+	return b, store
+}
+
+func TestUsage(t *testing.T) {
+	b, _ := newTestBrowser(t)
+
+	usage, err := b.Usage()
+	if err != nil {
+		t.Fatalf("Usage() error = %v", err)
+	}
+	want := []Usage{{
+		Category: "recordings", Backend: "local",
+		Objects: 5, Bytes: 210,
+		Orphaned: 2, OrphanedBytes: 50,
+		Missing: 1,
+	}}
+	if !reflect.DeepEqual(usage, want) {
+		t.Errorf("Usage() = %+v, want %+v", usage, want)
+	}
+}
+
+func TestOrphans(t *testing.T) {
+	b, _ := newTestBrowser(t)
+
+	orphans, err := b.Orphans("recordings")
+	if err != nil {
+		t.Fatalf("Orphans() error = %v", err)
+	}
+	var paths []string
+	for _, o := range orphans {
+		paths = append(paths, o.Path)
+	}
+	want := []string{"2026/05/orphan.vncrec", "2026/05/stray.mp4"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("Orphans() = %v, want %v", paths, want)
+	}
+
+	t.Run("grace period", func(t *testing.T) {
+		b.SetGracePeriod(0)
+		orphans, err := b.Orphans("recordings")
+		if err != nil {
+			t.Fatalf("Orphans() error = %v", err)
+		}
+		if len(orphans) != 3 {
+			t.Errorf("got %d orphans with no grace period, want 3", len(orphans))
+		}
+	})
+
+	t.Run("unknown category", func(t *testing.T) {
+		if _, err := b.Orphans("backups"); !errors.Is(err, ErrUnknownCategory) {
+			t.Errorf("Orphans() error = %v, want ErrUnknownCategory", err)
+		}
+	})
+}
+
+func TestDeleteOrphans(t *testing.T) {
+	t.Run("selected paths", func(t *testing.T) {
+		b, store := newTestBrowser(t)
+		deleted, err := b.DeleteOrphans("recordings", []string{"2026/05/stray.mp4"})
+		if err != nil {
+			t.Fatalf("DeleteOrphans() error = %v", err)
+		}
+		if !reflect.DeepEqual(deleted, []string{"2026/05/stray.mp4"}) {
+			t.Errorf("deleted = %v", deleted)
+		}
+		if _, ok := store.objects["2026/05/orphan.vncrec"]; !ok {
+			t.Error("unselected orphan was deleted")
+		}
+	})
+
+	t.Run("referenced path rejected", func(t *testing.T) {
+		b, store := newTestBrowser(t)
+		_, err := b.DeleteOrphans("recordings", []string{"2026/05/stray.mp4", "2026/05/kept.vncrec"})
+		if !errors.Is(err, ErrNotOrphaned) {
+			t.Fatalf("DeleteOrphans() error = %v, want ErrNotOrphaned", err)
+		}
+		if len(store.objects) != 5 {
+			t.Errorf("got %d objects, want nothing deleted", len(store.objects))
+		}
+	})
+
+	t.Run("all orphans", func(t *testing.T) {
+		b, store := newTestBrowser(t)
+		deleted, err := b.DeleteOrphans("recordings", nil)
+		if err != nil {
+			t.Fatalf("DeleteOrphans() error = %v", err)
+		}
+		if len(deleted) != 2 || len(store.objects) != 3 {
+			t.Errorf("deleted = %v, remaining %d objects; want 2 deleted, 3 remaining", deleted, len(store.objects))
+		}
+	})
+
+	t.Run("delete error", func(t *testing.T) {
+		b, store := newTestBrowser(t)
+		store.deleteErr = errors.New("boom")
+		deleted, err := b.DeleteOrphans("recordings", nil)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if len(deleted) != 0 {
+			t.Errorf("deleted = %v, want none", deleted)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package storagecrypt
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// StaticKeys wraps data keys with master keys held in configuration. The
+// first key is current; the others are kept to unwrap objects not yet
+// rotated.
+type StaticKeys struct {
+	current string
+	keys    map[string][]byte
+}
+
+// ParseKeys parses master keys in the form "id1:base64key,id2:base64key",
+// current key first. Each key must be 32 bytes.
+func ParseKeys(spec string) (*StaticKeys, error) {
+	s := &StaticKeys{keys: make(map[string][]byte)}
+	for entry := range strings.SplitSeq(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("key %q: want id:base64key", entry)
+		}
+		if len(id) > 64 {
+			return nil, fmt.Errorf("key %q: ID longer than 64 characters", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("key %q: must be 32 bytes encoded as base64 (generate with: openssl rand -base64 32)", id)
+		}
+		if _, dup := s.keys[id]; dup {
+			return nil, fmt.Errorf("key %q: duplicate ID", id)
+		}
+		s.keys[id] = key
+		if s.current == "" {
+			s.current = id
+		}
+	}
+	if s.current == "" {
+		return nil, fmt.Errorf("no keys given")
+	}
+	return s, nil
+}
+
+// KeyID returns the ID of the current master key.
+func (s *StaticKeys) KeyID() string {
+	return s.current
+}
+
+// Wrap seals the data key with the current master key, bound to its ID.
+func (s *StaticKeys) Wrap(dataKey []byte) ([]byte, error) {
+	aead, err := newAEAD(s.keys[s.current])
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, dataKey, []byte(s.current)), nil
+}
+
+// Unwrap opens a data key sealed by Wrap with the master key keyID.
+func (s *StaticKeys) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := s.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, keyID)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrCorrupt
+	}
+	nonce, sealed := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	dataKey, err := aead.Open(nil, nonce, sealed, []byte(keyID))
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return dataKey, nil
+}
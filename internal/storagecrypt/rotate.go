@@ -0,0 +1,124 @@
+package storagecrypt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rjsadow/sortie/internal/storagebrowser"
+)
+
+// Objects is an object store as rotation sees it: objects are read and
+// written as stored, without decryption.
+type Objects interface {
+	List() ([]storagebrowser.Object, error)
+	Get(path string) (io.ReadCloser, error)
+	Put(path string, r io.Reader) error
+}
+
+// RotateOptions controls Rotate.
+type RotateOptions struct {
+	// All rewraps objects already wrapped with the current key ID, for
+	// example after rotating a Vault transit key, which keeps its name.
+	All bool
+
+	// DryRun counts the objects that would change without writing them.
+	DryRun bool
+}
+
+// RotateResult counts the objects Rotate visited.
+type RotateResult struct {
+	Rewrapped int // encrypted objects given a header for the current key
+	Encrypted int // plaintext objects encrypted
+	Current   int // objects already wrapped with the current key, skipped
+	Failed    []RotateError
+}
+
+// RotateError is an object Rotate could not rewrite.
+type RotateError struct {
+	Path string
+	Err  error
+}
+
+func (e RotateError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+// Rotate rewraps every object in objs with the current master key and
+// encrypts objects stored in plaintext. Data is not re-encrypted, so each
+// rewritten object costs one read and one write. Objects that fail are
+// reported in the result and do not stop the run; the error is only for
+// failing to list the store.
+func (c *Cipher) Rotate(objs Objects, opts RotateOptions) (RotateResult, error) {
+	var res RotateResult
+	list, err := objs.List()
+	if err != nil {
+		return res, err
+	}
+	current := c.KeyID()
+	for _, obj := range list {
+		encrypted, err := c.rotateObject(objs, obj.Path, current, opts)
+		switch {
+		case errors.Is(err, errCurrent):
+			res.Current++
+		case err != nil:
+			res.Failed = append(res.Failed, RotateError{Path: obj.Path, Err: err})
+		case encrypted:
+			res.Rewrapped++
+		default:
+			res.Encrypted++
+		}
+	}
+	return res, nil
+}
+
+// errCurrent reports an object skipped because it uses the current key.
+var errCurrent = errors.New("object uses the current key")
+
+// rotateObject rewrites one object and reports whether it was encrypted
+// before. The new object is spooled to a temporary file before it is
+// written, since it replaces the object being read.
+func (c *Cipher) rotateObject(objs Objects, path, current string, opts RotateOptions) (bool, error) {
+	rc, err := objs.Get(path)
+	if err != nil {
+		return false, err
+	}
+	defer rc.Close()
+
+	br := bufio.NewReader(rc)
+	h, err := readHeader(br)
+	if err != nil {
+		return false, err
+	}
+	if h != nil && h.keyID == current && !opts.All {
+		return true, errCurrent
+	}
+	if opts.DryRun {
+		return h != nil, nil
+	}
+
+	out, err := c.rewrap(br, h)
+	if err != nil {
+		return false, err
+	}
+	f, err := os.CreateTemp("", "sortie-rotate-*")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := io.Copy(f, out); err != nil {
+		return false, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	rc.Close()
+
+	if err := objs.Put(path, f); err != nil {
+		return false, err
+	}
+	return h != nil, nil
+}
@@ -0,0 +1,109 @@
+package storagecrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/storagebrowser"
+)
+
+// memObjects is an in-memory Objects.
+type memObjects map[string][]byte
+
+func (m memObjects) List() ([]storagebrowser.Object, error) {
+	var objs []storagebrowser.Object
+	for path, data := range m {
+		objs = append(objs, storagebrowser.Object{Path: path, Size: int64(len(data))})
+	}
+	return objs, nil
+}
+
+func (m memObjects) Get(path string) (io.ReadCloser, error) {
+	data, ok := m[path]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m memObjects) Put(path string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	m[path] = data
+	return nil
+}
+
+func TestRotate(t *testing.T) {
+	oldKey := make([]byte, 32)
+	newKey := bytes.Repeat([]byte{1}, 32)
+	enc := func(k []byte) string { return base64.StdEncoding.EncodeToString(k) }
+
+	oldKeys, _ := ParseKeys("old:" + enc(oldKey))
+	keys, err := ParseKeys("new:" + enc(newKey) + ",old:" + enc(oldKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New(keys)
+
+	objs := memObjects{
+		"old":     encrypt(t, New(oldKeys), []byte("old data")),
+		"current": encrypt(t, c, []byte("current data")),
+		"plain":   []byte("plain data"),
+		"corrupt": []byte("SRTE\x01"),
+	}
+
+	dry, err := c.Rotate(objs, RotateOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Rotate dry run: %v", err)
+	}
+	if dry.Rewrapped != 1 || dry.Encrypted != 1 || dry.Current != 1 || len(dry.Failed) != 1 {
+		t.Errorf("dry run = %+v", dry)
+	}
+	if string(objs["plain"]) != "plain data" {
+		t.Error("dry run changed an object")
+	}
+
+	res, err := c.Rotate(objs, RotateOptions{})
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if res.Rewrapped != 1 || res.Encrypted != 1 || res.Current != 1 {
+		t.Errorf("result = %+v", res)
+	}
+	if len(res.Failed) != 1 || res.Failed[0].Path != "corrupt" {
+		t.Errorf("failed = %v, want corrupt", res.Failed)
+	}
+
+	want := map[string]string{"old": "old data", "current": "current data", "plain": "plain data"}
+	for path, plain := range want {
+		if keyID, _ := Inspect(bytes.NewReader(objs[path])); keyID != "new" {
+			t.Errorf("%s: key ID = %q, want new", path, keyID)
+		}
+		got, err := decrypt(New(mustParse(t, "new:"+enc(newKey))), objs[path])
+		if err != nil || string(got) != plain {
+			t.Errorf("%s: decrypt = %q, %v", path, got, err)
+		}
+	}
+
+	all, err := c.Rotate(objs, RotateOptions{All: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all.Rewrapped != 3 || all.Current != 0 {
+		t.Errorf("rotate all = %+v", all)
+	}
+}
+
+func mustParse(t *testing.T, spec string) *StaticKeys {
+	t.Helper()
+	keys, err := ParseKeys(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
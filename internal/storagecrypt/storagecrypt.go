@@ -0,0 +1,335 @@
+// Package storagecrypt encrypts stored objects, such as recordings and
+// uploaded branding assets, at rest. Each object is encrypted with its own
+// AES-256-GCM data key, which is stored in the object's header wrapped by a
+// master key. Rotating the master key only rewrites object headers.
+package storagecrypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Object layout:
+//
+//	magic "SRTE" | version 1 | key ID length (1) | key ID |
+//	wrapped key length (2, big-endian) | wrapped key | nonce prefix (7) |
+//	segments
+//
+// The plaintext is sealed in segments of segmentSize bytes. A segment's
+// nonce is the prefix, its index (4, big-endian), and 1 for the last
+// segment or 0 otherwise, so segments cannot be reordered, dropped, or
+// truncated without detection.
+const (
+	magic       = "SRTE"
+	version     = 1
+	segmentSize = 64 * 1024
+	prefixSize  = 7
+	dataKeySize = 32
+)
+
+var (
+	// ErrUnknownKey is returned when an object's data key is wrapped by a
+	// master key that is not configured.
+	ErrUnknownKey = errors.New("unknown master key")
+	// ErrCorrupt is returned for objects that fail authentication.
+	ErrCorrupt = errors.New("encrypted object is corrupt or truncated")
+	// ErrNotEncrypted is returned by Decrypt for an object without an
+	// encryption header, unless the Cipher allows plaintext.
+	ErrNotEncrypted = errors.New("object is not encrypted")
+)
+
+// KeyWrapper wraps and unwraps data keys with a master key.
+type KeyWrapper interface {
+	// KeyID identifies the master key that new data keys are wrapped with.
+	KeyID() string
+
+	// Wrap encrypts a data key with the current master key.
+	Wrap(dataKey []byte) ([]byte, error)
+
+	// Unwrap decrypts a data key wrapped by the master key with the given ID.
+	Unwrap(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Cipher encrypts and decrypts objects. It is safe for concurrent use.
+type Cipher struct {
+	keys           KeyWrapper
+	allowPlaintext bool
+}
+
+// New creates a Cipher that wraps data keys with keys. It only decrypts
+// encrypted objects: anything without an encryption header is rejected, so
+// an object planted in the storage backend is never served as is.
+func New(keys KeyWrapper) *Cipher {
+	return &Cipher{keys: keys}
+}
+
+// AllowPlaintext makes Decrypt return objects without an encryption header
+// unchanged, to read objects stored before encryption was enabled until
+// Rotate has encrypted them. Call it before the Cipher is used.
+func (c *Cipher) AllowPlaintext() {
+	c.allowPlaintext = true
+}
+
+// KeyID returns the ID of the master key new objects are wrapped with.
+func (c *Cipher) KeyID() string {
+	return c.keys.KeyID()
+}
+
+// header is the unencrypted start of an object.
+type header struct {
+	keyID   string
+	wrapped []byte
+	prefix  []byte
+}
+
+func (h *header) marshal() []byte {
+	var b bytes.Buffer
+	b.WriteString(magic)
+	b.WriteByte(version)
+	b.WriteByte(byte(len(h.keyID)))
+	b.WriteString(h.keyID)
+	binary.Write(&b, binary.BigEndian, uint16(len(h.wrapped)))
+	b.Write(h.wrapped)
+	b.Write(h.prefix)
+	return b.Bytes()
+}
+
+// readHeader reads an object header. It returns nil, with nothing consumed
+// from r, if the object is not encrypted.
+func readHeader(r *bufio.Reader) (*header, error) {
+	start, err := r.Peek(len(magic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if string(start) != magic {
+		return nil, nil
+	}
+	r.Discard(len(magic))
+
+	var fixed [2]byte
+	if _, err := io.ReadFull(r, fixed[:]); err != nil {
+		return nil, ErrCorrupt
+	}
+	if fixed[0] != version {
+		return nil, fmt.Errorf("unsupported encrypted object version %d", fixed[0])
+	}
+	keyID := make([]byte, fixed[1])
+	if _, err := io.ReadFull(r, keyID); err != nil {
+		return nil, ErrCorrupt
+	}
+	var n uint16
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, ErrCorrupt
+	}
+	h := &header{keyID: string(keyID), wrapped: make([]byte, n), prefix: make([]byte, prefixSize)}
+	if _, err := io.ReadFull(r, h.wrapped); err != nil {
+		return nil, ErrCorrupt
+	}
+	if _, err := io.ReadFull(r, h.prefix); err != nil {
+		return nil, ErrCorrupt
+	}
+	return h, nil
+}
+
+// newHeader wraps the data key with the current master key.
+func (c *Cipher) newHeader(dataKey, prefix []byte) (*header, error) {
+	keyID := c.keys.KeyID()
+	if len(keyID) > 255 {
+		return nil, fmt.Errorf("master key ID too long: %d bytes", len(keyID))
+	}
+	wrapped, err := c.keys.Wrap(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	if len(wrapped) > 0xffff {
+		return nil, fmt.Errorf("wrapped data key too long: %d bytes", len(wrapped))
+	}
+	return &header{keyID: keyID, wrapped: wrapped, prefix: prefix}, nil
+}
+
+// Encrypt returns a reader of r's contents encrypted under a new data key.
+func (c *Cipher) Encrypt(r io.Reader) (io.Reader, error) {
+	dataKey := make([]byte, dataKeySize)
+	prefix := make([]byte, prefixSize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	h, err := c.newHeader(dataKey, prefix)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &segmentReader{
+		src:    r,
+		aead:   aead,
+		prefix: prefix,
+		in:     make([]byte, segmentSize+1),
+		size:   segmentSize,
+		out:    h.marshal(),
+	}, nil
+}
+
+// Decrypt returns a reader of the plaintext of an object read from r. An
+// object that is not encrypted returns ErrNotEncrypted, or is returned
+// unchanged if the Cipher allows plaintext.
+func (c *Cipher) Decrypt(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	h, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	if h == nil {
+		if !c.allowPlaintext {
+			return nil, ErrNotEncrypted
+		}
+		return br, nil
+	}
+	dataKey, err := c.keys.Unwrap(h.keyID, h.wrapped)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &segmentReader{
+		src:     br,
+		aead:    aead,
+		prefix:  h.prefix,
+		in:      make([]byte, segmentSize+aead.Overhead()+1),
+		size:    segmentSize + aead.Overhead(),
+		decrypt: true,
+	}, nil
+}
+
+// Inspect reports the master key an object read from r is wrapped with,
+// or "" if the object is not encrypted.
+func Inspect(r io.Reader) (keyID string, err error) {
+	h, err := readHeader(bufio.NewReader(r))
+	if err != nil || h == nil {
+		return "", err
+	}
+	return h.keyID, nil
+}
+
+// Rewrap returns a reader of an object read from r with its data key
+// wrapped by the current master key. Only the header changes. Objects that
+// are not encrypted are encrypted.
+func (c *Cipher) Rewrap(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	h, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	return c.rewrap(br, h)
+}
+
+// rewrap rewraps an object whose header h has been read from r.
+func (c *Cipher) rewrap(r io.Reader, h *header) (io.Reader, error) {
+	if h == nil {
+		return c.Encrypt(r)
+	}
+	dataKey, err := c.keys.Unwrap(h.keyID, h.wrapped)
+	if err != nil {
+		return nil, err
+	}
+	nh, err := c.newHeader(dataKey, h.prefix)
+	if err != nil {
+		return nil, err
+	}
+	return io.MultiReader(bytes.NewReader(nh.marshal()), r), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// segmentReader seals or opens a stream segment by segment. It reads one
+// byte past each segment to learn whether the segment is the last one.
+type segmentReader struct {
+	src     io.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	decrypt bool
+
+	in    []byte // read-ahead buffer of size+1 bytes
+	n     int    // bytes buffered in in
+	size  int    // input segment size
+	index uint32
+	out   []byte // output not yet returned
+	buf   []byte
+	done  bool
+}
+
+func (s *segmentReader) Read(p []byte) (int, error) {
+	for len(s.out) == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+		if err := s.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, s.out)
+	s.out = s.out[n:]
+	return n, nil
+}
+
+func (s *segmentReader) next() error {
+	m, err := io.ReadFull(s.src, s.in[s.n:])
+	s.n += m
+	last := false
+	switch {
+	case err == nil:
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		last = true
+	default:
+		return err
+	}
+	seg := min(s.n, s.size)
+
+	nonce := make([]byte, 0, prefixSize+5)
+	nonce = append(nonce, s.prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, s.index)
+	if last {
+		nonce = append(nonce, 1)
+	} else {
+		nonce = append(nonce, 0)
+	}
+
+	if s.decrypt {
+		plain, err := s.aead.Open(s.buf[:0], nonce, s.in[:seg], nil)
+		if err != nil {
+			return ErrCorrupt
+		}
+		s.buf, s.out = plain, plain
+	} else {
+		sealed := s.aead.Seal(s.buf[:0], nonce, s.in[:seg], nil)
+		s.buf, s.out = sealed, sealed
+	}
+
+	s.n = copy(s.in, s.in[seg:s.n])
+	if s.index == ^uint32(0) && !last {
+		return errors.New("encrypted object too large")
+	}
+	s.index++
+	s.done = last
+	return nil
+}
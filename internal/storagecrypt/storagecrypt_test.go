@@ -0,0 +1,187 @@
+package storagecrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func testKeys(t *testing.T, ids ...string) *StaticKeys {
+	t.Helper()
+	var specs []string
+	for _, id := range ids {
+		key := make([]byte, 32)
+		rand.Read(key)
+		specs = append(specs, id+":"+base64.StdEncoding.EncodeToString(key))
+	}
+	keys, err := ParseKeys(strings.Join(specs, ","))
+	if err != nil {
+		t.Fatalf("ParseKeys: %v", err)
+	}
+	return keys
+}
+
+func encrypt(t *testing.T, c *Cipher, plain []byte) []byte {
+	t.Helper()
+	r, err := c.Encrypt(bytes.NewReader(plain))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read encrypted: %v", err)
+	}
+	return out
+}
+
+func decrypt(c *Cipher, data []byte) ([]byte, error) {
+	r, err := c.Decrypt(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestRoundTrip(t *testing.T) {
+	c := New(testKeys(t, "k1"))
+
+	for _, size := range []int{0, 1, segmentSize - 1, segmentSize, segmentSize + 1, 3 * segmentSize, 3*segmentSize + 17} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+
+		enc := encrypt(t, c, plain)
+		if size > 0 && bytes.Contains(enc, plain) {
+			t.Errorf("size %d: ciphertext contains plaintext", size)
+		}
+		got, err := decrypt(c, enc)
+		if err != nil {
+			t.Fatalf("size %d: Decrypt: %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("size %d: round trip mismatch", size)
+		}
+	}
+}
+
+func TestDecrypt_Plaintext(t *testing.T) {
+	c := New(testKeys(t, "k1"))
+	for _, plain := range []string{"", "SR", "VREC plaintext recording"} {
+		if _, err := decrypt(c, []byte(plain)); !errors.Is(err, ErrNotEncrypted) {
+			t.Errorf("Decrypt(%q) error = %v, want ErrNotEncrypted", plain, err)
+		}
+	}
+
+	c.AllowPlaintext()
+	for _, plain := range []string{"", "SR", "VREC plaintext recording"} {
+		got, err := decrypt(c, []byte(plain))
+		if err != nil {
+			t.Fatalf("Decrypt(%q): %v", plain, err)
+		}
+		if string(got) != plain {
+			t.Errorf("Decrypt(%q) = %q", plain, got)
+		}
+	}
+}
+
+func TestDecrypt_Tampered(t *testing.T) {
+	c := New(testKeys(t, "k1"))
+	plain := make([]byte, 2*segmentSize+100)
+	rand.Read(plain)
+	enc := encrypt(t, c, plain)
+
+	flipped := bytes.Clone(enc)
+	flipped[len(flipped)-5] ^= 1
+
+	tests := map[string][]byte{
+		"flipped byte":      flipped,
+		"truncated segment": enc[:len(enc)-10],
+		"dropped segment":   enc[:len(enc)-(100+16)],
+		"truncated header":  enc[:8],
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := decrypt(c, data); !errors.Is(err, ErrCorrupt) {
+				t.Errorf("err = %v, want ErrCorrupt", err)
+			}
+		})
+	}
+}
+
+func TestDecrypt_UnknownKey(t *testing.T) {
+	enc := encrypt(t, New(testKeys(t, "old")), []byte("secret"))
+	if _, err := decrypt(New(testKeys(t, "new")), enc); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("err = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	old := testKeys(t, "old")
+	plain := make([]byte, segmentSize+5)
+	rand.Read(plain)
+	enc := encrypt(t, New(old), plain)
+
+	// The new cipher keeps the old key to unwrap with.
+	both, err := ParseKeys("new:" + base64.StdEncoding.EncodeToString(make([]byte, 32)) + ",old:" + base64.StdEncoding.EncodeToString(old.keys["old"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New(both)
+	r, err := c.Rewrap(bytes.NewReader(enc))
+	if err != nil {
+		t.Fatalf("Rewrap: %v", err)
+	}
+	rewrapped, _ := io.ReadAll(r)
+
+	keyID, err := Inspect(bytes.NewReader(rewrapped))
+	if err != nil || keyID != "new" {
+		t.Fatalf("Inspect = %q, %v; want new", keyID, err)
+	}
+	if !bytes.HasSuffix(rewrapped, enc[len(enc)-segmentSize:]) {
+		t.Error("Rewrap changed the encrypted data")
+	}
+	got, err := decrypt(c, rewrapped)
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Errorf("decrypt rewrapped: %v", err)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	keyID, err := Inspect(strings.NewReader("plain"))
+	if err != nil || keyID != "" {
+		t.Errorf("Inspect(plaintext) = %q, %v", keyID, err)
+	}
+	enc := encrypt(t, New(testKeys(t, "k1")), []byte("x"))
+	if keyID, _ := Inspect(bytes.NewReader(enc)); keyID != "k1" {
+		t.Errorf("Inspect = %q, want k1", keyID)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	valid := base64.StdEncoding.EncodeToString(make([]byte, 32))
+
+	keys, err := ParseKeys(" a:" + valid + " , b:" + valid)
+	if err != nil {
+		t.Fatalf("ParseKeys: %v", err)
+	}
+	if keys.KeyID() != "a" {
+		t.Errorf("KeyID = %q, want a", keys.KeyID())
+	}
+
+	for _, spec := range []string{
+		"",
+		valid,
+		":" + valid,
+		"a:notbase64!",
+		"a:" + base64.StdEncoding.EncodeToString(make([]byte, 16)),
+		"a:" + valid + ",a:" + valid,
+		strings.Repeat("x", 65) + ":" + valid,
+	} {
+		if _, err := ParseKeys(spec); err == nil {
+			t.Errorf("ParseKeys(%q) succeeded, want error", spec)
+		}
+	}
+}
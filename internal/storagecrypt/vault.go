@@ -0,0 +1,126 @@
+package storagecrypt
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/offline"
+)
+
+// VaultTransit wraps data keys with a key in HashiCorp Vault's transit
+// secrets engine, so the master key never leaves Vault. Vault encrypts
+// with the latest version of the key; rotate it in Vault, then rewrap
+// stored objects to retire old versions.
+type VaultTransit struct {
+	client    *http.Client
+	addr      string
+	token     string
+	namespace string
+	mount     string
+	key       string
+}
+
+// NewVaultTransit creates a VaultTransit using the transit key at
+// {mount}/keys/{key}.
+func NewVaultTransit(addr, token, namespace, mount, key string) (*VaultTransit, error) {
+	if addr == "" {
+		return nil, fmt.Errorf("vault address is required")
+	}
+	if key == "" {
+		return nil, fmt.Errorf("transit key name is required")
+	}
+	if mount == "" {
+		mount = "transit"
+	}
+	return &VaultTransit{
+		client:    offline.Client(30 * time.Second),
+		addr:      strings.TrimSuffix(addr, "/"),
+		token:     token,
+		namespace: namespace,
+		mount:     strings.Trim(mount, "/"),
+		key:       key,
+	}, nil
+}
+
+// KeyID returns "vault:" and the transit key name.
+func (v *VaultTransit) KeyID() string {
+	return "vault:" + v.key
+}
+
+// Wrap encrypts the data key with the latest version of the transit key.
+func (v *VaultTransit) Wrap(dataKey []byte) ([]byte, error) {
+	var resp struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+	req := map[string]string{"plaintext": base64.StdEncoding.EncodeToString(dataKey)}
+	if err := v.post("encrypt", req, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Data.Ciphertext == "" {
+		return nil, fmt.Errorf("vault returned no ciphertext")
+	}
+	return []byte(resp.Data.Ciphertext), nil
+}
+
+// Unwrap decrypts a data key wrapped by Wrap.
+func (v *VaultTransit) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	if keyID != v.KeyID() {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, keyID)
+	}
+	var resp struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	if err := v.post("decrypt", map[string]string{"ciphertext": string(wrapped)}, &resp); err != nil {
+		return nil, err
+	}
+	dataKey, err := base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode vault plaintext: %w", err)
+	}
+	return dataKey, nil
+}
+
+func (v *VaultTransit) post(op string, body any, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/v1/%s/%s/%s", v.addr, v.mount, op, v.key)
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if v.token != "" {
+		req.Header.Set("X-Vault-Token", v.token)
+	}
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("vault %s returned status %d: %s", op, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to parse vault response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package storagecrypt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeTransit mimics Vault's transit encrypt and decrypt endpoints by
+// prefixing the plaintext.
+func fakeTransit() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "tok" || r.Header.Get("X-Vault-Namespace") != "ns" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/v1/transit/encrypt/storage":
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{"ciphertext": "vault:v1:" + body["plaintext"]}})
+		case "/v1/transit/decrypt/storage":
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{"plaintext": strings.TrimPrefix(body["ciphertext"], "vault:v1:")}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestVaultTransit(t *testing.T) {
+	srv := fakeTransit()
+	defer srv.Close()
+
+	v, err := NewVaultTransit(srv.URL+"/", "tok", "ns", "", "storage")
+	if err != nil {
+		t.Fatalf("NewVaultTransit: %v", err)
+	}
+	if v.KeyID() != "vault:storage" {
+		t.Errorf("KeyID = %q", v.KeyID())
+	}
+
+	c := New(v)
+	enc := encrypt(t, c, []byte("recording"))
+	got, err := decrypt(c, enc)
+	if err != nil || string(got) != "recording" {
+		t.Fatalf("round trip = %q, %v", got, err)
+	}
+
+	other, _ := NewVaultTransit(srv.URL, "tok", "ns", "transit", "other")
+	if _, err := decrypt(New(other), enc); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("decrypt with other key: %v, want ErrUnknownKey", err)
+	}
+
+	denied, _ := NewVaultTransit(srv.URL, "bad", "ns", "transit", "storage")
+	if _, err := New(denied).Encrypt(bytes.NewReader(nil)); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Encrypt with bad token: %v, want status 403", err)
+	}
+	if _, err := decrypt(New(denied), enc); err == nil {
+		t.Error("decrypt with bad token succeeded")
+	}
+}
+
+func TestNewVaultTransit_Validation(t *testing.T) {
+	if _, err := NewVaultTransit("", "", "", "", "k"); err == nil {
+		t.Error("missing address accepted")
+	}
+	if _, err := NewVaultTransit("http://vault", "", "", "", ""); err == nil {
+		t.Error("missing key accepted")
+	}
+}
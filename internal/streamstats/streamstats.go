@@ -0,0 +1,340 @@
+// Package streamstats collects streaming quality stats for each session's
+// display stream, as seen by the VNC and Guacamole relays.
+package streamstats
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Backend is the relay a session streams through.
+type Backend string
+
+const (
+	BackendVNC  Backend = "vnc"  // Linux container apps
+	BackendGuac Backend = "guac" // Windows apps
+)
+
+var backends = []Backend{BackendVNC, BackendGuac}
+
+const (
+	// rateWindow is how many seconds the per-second rates average over.
+	rateWindow = 10
+	// maxPendingPings bounds the round-trip probes awaiting an answer, for
+	// clients that never answer.
+	maxPendingPings = 32
+	// rttWeight is the weight of a new sample in the smoothed round-trip
+	// time, as in TCP's SRTT.
+	rttWeight = 0.125
+	// retention is how long a session's stats are kept after its last
+	// stream closes.
+	retention = 10 * time.Minute
+)
+
+// Stats are the streaming stats of one session, summed over every client
+// connected to it.
+type Stats struct {
+	SessionID       string  `json:"session_id"`
+	Backend         Backend `json:"backend,omitempty"`
+	ActiveStreams   int     `json:"active_streams"`
+	FramesPerSecond float64 `json:"frames_per_second"`
+	BytesPerSecond  float64 `json:"bytes_per_second"`
+	// RTTMillis is the smoothed round-trip time to the clients; 0 until
+	// the first measurement.
+	RTTMillis     float64 `json:"rtt_ms"`
+	Frames        int64   `json:"frames"`
+	Bytes         int64   `json:"bytes"`
+	DroppedFrames int64   `json:"dropped_frames"` // Frames that could not be delivered to a client
+	Reconnects    int64   `json:"reconnects"`     // Times the stream was re-established after dropping
+}
+
+// Tracker records stream stats per session. It is safe for concurrent use.
+// A nil Tracker records nothing.
+type Tracker struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+	totals   map[Backend]*totals
+}
+
+// totals are the counters of every session of a backend since the
+// process started.
+type totals struct {
+	frames, bytes, dropped, reconnects int64
+}
+
+type session struct {
+	backend  Backend
+	started  time.Time
+	active   int
+	closedAt time.Time // when the last stream closed; zero while any is open
+
+	frames, bytes meter
+	stats         Stats
+	srtt          time.Duration
+}
+
+// NewTracker creates an empty Tracker.
+func NewTracker() *Tracker {
+	t := &Tracker{
+		sessions: make(map[string]*session),
+		totals:   make(map[Backend]*totals),
+	}
+	for _, b := range backends {
+		t.totals[b] = &totals{}
+	}
+	return t
+}
+
+// Open records a client connecting to a session's stream. Opening a
+// stream after all earlier ones of the session closed counts as a
+// reconnect. Call Close on the returned Stream when the client disconnects.
+func (t *Tracker) Open(sessionID string, backend Backend) *Stream {
+	if t == nil {
+		return nil
+	}
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prune(now)
+	s, ok := t.sessions[sessionID]
+	if !ok {
+		s = &session{backend: backend, started: now, stats: Stats{SessionID: sessionID, Backend: backend}}
+		t.sessions[sessionID] = s
+	}
+	if s.active == 0 && !s.closedAt.IsZero() {
+		s.stats.Reconnects++
+		t.total(s).reconnects++
+	}
+	s.active++
+	s.closedAt = time.Time{}
+	return &Stream{t: t, s: s, pings: make(map[string]time.Time)}
+}
+
+// Get returns the stats of a session, or nil if it has not streamed
+// recently.
+func (t *Tracker) Get(sessionID string) *Stats {
+	if t == nil {
+		return nil
+	}
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.sessions[sessionID]
+	if !ok {
+		return nil
+	}
+	stats := s.stats
+	stats.ActiveStreams = s.active
+	stats.FramesPerSecond = s.frames.rate(now, s.started)
+	stats.BytesPerSecond = s.bytes.rate(now, s.started)
+	stats.RTTMillis = float64(s.srtt) / float64(time.Millisecond)
+	return &stats
+}
+
+// WritePrometheus writes the stream metrics of all sessions, per backend,
+// in the Prometheus text exposition format.
+func (t *Tracker) WritePrometheus(w io.Writer) {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+
+	t.mu.Lock()
+	t.prune(now)
+	active := make(map[Backend]int)
+	rttSum := make(map[Backend]time.Duration)
+	rttCount := make(map[Backend]int)
+	for _, s := range t.sessions {
+		active[s.backend] += s.active
+		if s.active > 0 && s.srtt > 0 {
+			rttSum[s.backend] += s.srtt
+			rttCount[s.backend]++
+		}
+	}
+	totals := make(map[Backend]totals, len(t.totals))
+	for b, tot := range t.totals {
+		totals[b] = *tot
+	}
+	t.mu.Unlock()
+
+	metric := func(name, kind, help string, value func(Backend) float64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, b := range backends {
+			fmt.Fprintf(w, "%s{backend=%q} %g\n", name, b, value(b))
+		}
+	}
+	metric("sortie_stream_active", "gauge", "Open session display streams.",
+		func(b Backend) float64 { return float64(active[b]) })
+	metric("sortie_stream_frames_total", "counter", "Frames relayed to clients.",
+		func(b Backend) float64 { return float64(totals[b].frames) })
+	metric("sortie_stream_bytes_total", "counter", "Bytes relayed to clients.",
+		func(b Backend) float64 { return float64(totals[b].bytes) })
+	metric("sortie_stream_dropped_frames_total", "counter", "Frames that could not be delivered to a client.",
+		func(b Backend) float64 { return float64(totals[b].dropped) })
+	metric("sortie_stream_reconnects_total", "counter", "Streams re-established after dropping.",
+		func(b Backend) float64 { return float64(totals[b].reconnects) })
+	metric("sortie_stream_rtt_seconds", "gauge", "Mean smoothed round-trip time of sessions with open streams.",
+		func(b Backend) float64 {
+			if rttCount[b] == 0 {
+				return 0
+			}
+			return (rttSum[b] / time.Duration(rttCount[b])).Seconds()
+		})
+}
+
+// prune forgets sessions whose last stream closed longer than retention
+// ago. t.mu must be held.
+func (t *Tracker) prune(now time.Time) {
+	for id, s := range t.sessions {
+		if s.active == 0 && now.Sub(s.closedAt) > retention {
+			delete(t.sessions, id)
+		}
+	}
+}
+
+func (t *Tracker) total(s *session) *totals {
+	tot, ok := t.totals[s.backend]
+	if !ok {
+		tot = &totals{}
+		t.totals[s.backend] = tot
+	}
+	return tot
+}
+
+// Stream records the stats of one client's connection to a session. All
+// methods are safe for concurrent use, and do nothing on a nil Stream.
+type Stream struct {
+	t      *Tracker
+	s      *session
+	pings  map[string]time.Time // guarded by t.mu
+	closed bool                 // guarded by t.mu
+}
+
+// Sent records frames and bytes delivered to the client.
+func (st *Stream) Sent(frames, bytes int) {
+	if st == nil {
+		return
+	}
+	now := time.Now()
+	st.t.mu.Lock()
+	defer st.t.mu.Unlock()
+	st.s.frames.add(now, int64(frames))
+	st.s.bytes.add(now, int64(bytes))
+	st.s.stats.Frames += int64(frames)
+	st.s.stats.Bytes += int64(bytes)
+	tot := st.t.total(st.s)
+	tot.frames += int64(frames)
+	tot.bytes += int64(bytes)
+}
+
+// Dropped records frames that could not be delivered to the client.
+func (st *Stream) Dropped(frames int) {
+	if st == nil {
+		return
+	}
+	st.t.mu.Lock()
+	defer st.t.mu.Unlock()
+	st.s.stats.DroppedFrames += int64(frames)
+	st.t.total(st.s).dropped += int64(frames)
+}
+
+// Reconnected records the session's stream being re-established upstream
+// while the client stayed connected.
+func (st *Stream) Reconnected() {
+	if st == nil {
+		return
+	}
+	st.t.mu.Lock()
+	defer st.t.mu.Unlock()
+	st.s.stats.Reconnects++
+	st.t.total(st.s).reconnects++
+}
+
+// Ping records a round-trip probe with the given ID being sent to the
+// client.
+func (st *Stream) Ping(id string) {
+	if st == nil {
+		return
+	}
+	now := time.Now()
+	st.t.mu.Lock()
+	defer st.t.mu.Unlock()
+	if len(st.pings) >= maxPendingPings {
+		clear(st.pings)
+	}
+	st.pings[id] = now
+}
+
+// Pong records the client answering the probe with the given ID and
+// updates the session's smoothed round-trip time. Unknown IDs are ignored.
+func (st *Stream) Pong(id string) {
+	if st == nil {
+		return
+	}
+	now := time.Now()
+	st.t.mu.Lock()
+	defer st.t.mu.Unlock()
+	sent, ok := st.pings[id]
+	if !ok {
+		return
+	}
+	delete(st.pings, id)
+	sample := now.Sub(sent)
+	if st.s.srtt == 0 {
+		st.s.srtt = sample
+	} else {
+		st.s.srtt += time.Duration(rttWeight * float64(sample-st.s.srtt))
+	}
+}
+
+// Close records the client disconnecting. Calling it again does nothing.
+func (st *Stream) Close() {
+	if st == nil {
+		return
+	}
+	st.t.mu.Lock()
+	defer st.t.mu.Unlock()
+	if st.closed {
+		return
+	}
+	st.closed = true
+	st.s.active--
+	if st.s.active == 0 {
+		st.s.closedAt = time.Now()
+	}
+}
+
+// meter counts events in one-second buckets to report a recent rate.
+type meter struct {
+	buckets [rateWindow + 1]struct{ sec, n int64 }
+}
+
+func (m *meter) add(now time.Time, n int64) {
+	sec := now.Unix()
+	b := &m.buckets[sec%int64(len(m.buckets))]
+	if b.sec != sec {
+		b.sec, b.n = sec, 0
+	}
+	b.n += n
+}
+
+// rate returns the per-second average over the last rateWindow complete
+// seconds, or over the complete seconds since started if fewer.
+func (m *meter) rate(now, started time.Time) float64 {
+	sec := now.Unix()
+	span := min(int64(rateWindow), sec-started.Unix())
+	if span <= 0 {
+		return 0
+	}
+	var sum int64
+	for _, b := range m.buckets {
+		if b.sec < sec && b.sec >= sec-span {
+			sum += b.n
+		}
+	}
+	return float64(sum) / float64(span)
+}
@@ -0,0 +1,150 @@
+package streamstats
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	tr := NewTracker()
+
+	a := tr.Open("sess-1", BackendGuac)
+	b := tr.Open("sess-1", BackendGuac)
+	a.Sent(3, 300)
+	b.Sent(2, 200)
+	b.Dropped(1)
+
+	got := tr.Get("sess-1")
+	if got == nil {
+		t.Fatal("Get() = nil, want stats")
+	}
+	if got.ActiveStreams != 2 || got.Frames != 5 || got.Bytes != 500 || got.DroppedFrames != 1 {
+		t.Errorf("Get() = %+v, want 2 streams, 5 frames, 500 bytes, 1 dropped", got)
+	}
+	if got.Backend != BackendGuac || got.Reconnects != 0 {
+		t.Errorf("Get() = %+v, want guac backend and no reconnects", got)
+	}
+
+	a.Close()
+	a.Close() // closing twice is harmless
+	b.Close()
+	if got := tr.Get("sess-1"); got.ActiveStreams != 0 {
+		t.Errorf("after close, ActiveStreams = %d, want 0", got.ActiveStreams)
+	}
+
+	c := tr.Open("sess-1", BackendGuac)
+	c.Reconnected()
+	if got := tr.Get("sess-1"); got.Reconnects != 2 {
+		t.Errorf("Reconnects = %d, want 2 (reopen plus upstream reconnect)", got.Reconnects)
+	}
+	c.Close()
+
+	if tr.Get("sess-2") != nil {
+		t.Error("Get() of unknown session should be nil")
+	}
+}
+
+func TestStream_RTT(t *testing.T) {
+	tr := NewTracker()
+	st := tr.Open("sess-1", BackendVNC)
+	defer st.Close()
+
+	st.Ping("1")
+	time.Sleep(20 * time.Millisecond)
+	st.Pong("1")
+	st.Pong("1")       // answered already
+	st.Pong("unknown") // never sent
+
+	rtt := tr.Get("sess-1").RTTMillis
+	if rtt < 20 || rtt > 1000 {
+		t.Errorf("RTTMillis = %v, want about 20", rtt)
+	}
+
+	for i := range maxPendingPings + 1 {
+		st.Ping(string(rune('a' + i)))
+	}
+	if len(st.pings) > maxPendingPings {
+		t.Errorf("pending pings = %d, want at most %d", len(st.pings), maxPendingPings)
+	}
+}
+
+func TestTracker_Prune(t *testing.T) {
+	tr := NewTracker()
+	tr.Open("old", BackendVNC).Close()
+	tr.sessions["old"].closedAt = time.Now().Add(-retention - time.Minute)
+	open := tr.Open("open", BackendVNC)
+	defer open.Close()
+
+	tr.WritePrometheus(&bytes.Buffer{})
+	if tr.Get("old") != nil {
+		t.Error("expired session not pruned")
+	}
+	if tr.Get("open") == nil {
+		t.Error("open session pruned")
+	}
+}
+
+func TestTracker_WritePrometheus(t *testing.T) {
+	tr := NewTracker()
+	vnc := tr.Open("sess-1", BackendVNC)
+	vnc.Sent(4, 1024)
+	vnc.Dropped(2)
+	defer vnc.Close()
+
+	var buf bytes.Buffer
+	tr.WritePrometheus(&buf)
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE sortie_stream_active gauge\n",
+		`sortie_stream_active{backend="vnc"} 1` + "\n",
+		`sortie_stream_active{backend="guac"} 0` + "\n",
+		`sortie_stream_frames_total{backend="vnc"} 4` + "\n",
+		`sortie_stream_bytes_total{backend="vnc"} 1024` + "\n",
+		`sortie_stream_dropped_frames_total{backend="vnc"} 2` + "\n",
+		`sortie_stream_reconnects_total{backend="guac"} 0` + "\n",
+		"# TYPE sortie_stream_rtt_seconds gauge\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestNilSafe(t *testing.T) {
+	var tr *Tracker
+	st := tr.Open("sess-1", BackendVNC)
+	st.Sent(1, 1)
+	st.Dropped(1)
+	st.Reconnected()
+	st.Ping("1")
+	st.Pong("1")
+	st.Close()
+	if tr.Get("sess-1") != nil {
+		t.Error("nil Tracker Get() should be nil")
+	}
+	var buf bytes.Buffer
+	tr.WritePrometheus(&buf)
+	if buf.Len() != 0 {
+		t.Errorf("nil Tracker wrote %q", buf.String())
+	}
+}
+
+func TestMeter(t *testing.T) {
+	start := time.Unix(1000, 0)
+	var m meter
+	for i := range 5 {
+		m.add(start.Add(time.Duration(i)*time.Second), 10)
+	}
+	// The current second is incomplete and not counted.
+	if got := m.rate(start.Add(4*time.Second), start); got != 10 {
+		t.Errorf("rate after 4s = %v, want 10", got)
+	}
+	if got := m.rate(start.Add(30*time.Second), start); got != 0 {
+		t.Errorf("rate after idle window = %v, want 0", got)
+	}
+	if got := m.rate(start, start); got != 0 {
+		t.Errorf("rate at start = %v, want 0", got)
+	}
+}
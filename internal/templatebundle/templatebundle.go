@@ -0,0 +1,196 @@
+// Package templatebundle reads template catalog bundles, which let
+// air-gapped deployments import templates without fetching a catalog or
+// icons from the internet.
+//
+// A bundle is either a catalog, in JSON or YAML, or a zip archive holding
+// the catalog as templates.json or templates.yaml and the icon images it
+// refers to. A template whose icon is the path of a file in the archive,
+// such as "icons/vscode.png", gets that file as its icon.
+package templatebundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// MaxSize is the largest bundle accepted.
+const MaxSize = 32 << 20 // 32 MiB
+
+// CatalogFile is the name of the catalog inside a zip bundle.
+const CatalogFile = "templates.json"
+
+// catalogFiles are the names a zip bundle's catalog may have.
+var catalogFiles = []string{CatalogFile, "templates.yaml", "templates.yml"}
+
+// Export formats.
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// CatalogVersion is the version of exported catalogs.
+const CatalogVersion = "1.0.0"
+
+// localFields are template fields that only mean something on the server
+// a template was exported from, and are left out of exports.
+var localFields = []string{"id", "catalog", "created_at", "updated_at"}
+
+// maxFiles limits the entries read from an archive.
+const maxFiles = 1000
+
+// ErrInvalid is returned for a bundle that cannot be read.
+var ErrInvalid = errors.New("invalid template bundle")
+
+// Bundle is a parsed template bundle.
+type Bundle struct {
+	Catalog db.TemplateCatalog
+	// Files holds the archive's files other than the catalog, keyed by
+	// their cleaned path within it.
+	Files map[string][]byte
+}
+
+// Read parses a bundle from data, which is either a zip archive or a JSON
+// or YAML catalog.
+func Read(data []byte) (*Bundle, error) {
+	b := &Bundle{Files: map[string][]byte{}}
+	if !bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		// JSON is YAML, so one parser reads both
+		if err := yaml.Unmarshal(data, &b.Catalog); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		return b, nil
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if len(zr.File) > maxFiles {
+		return nil, fmt.Errorf("%w: more than %d files", ErrInvalid, maxFiles)
+	}
+	var catalog []byte
+	var catalogName string
+	total := 0
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		name, ok := cleanPath(f.Name)
+		if !ok {
+			return nil, fmt.Errorf("%w: bad file name %q", ErrInvalid, f.Name)
+		}
+		// Sizes in the header can lie, so count what is actually read
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalid, name, err)
+		}
+		content, err := io.ReadAll(io.LimitReader(rc, int64(MaxSize-total)+1))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalid, name, err)
+		}
+		if total += len(content); total > MaxSize {
+			return nil, fmt.Errorf("%w: more than %d bytes uncompressed", ErrInvalid, MaxSize)
+		}
+		if !isCatalogFile(name) {
+			b.Files[name] = content
+			continue
+		}
+		if catalog != nil {
+			return nil, fmt.Errorf("%w: archive has both %s and %s", ErrInvalid, catalogName, name)
+		}
+		catalog, catalogName = content, name
+	}
+	if catalog == nil {
+		return nil, fmt.Errorf("%w: archive has no %s", ErrInvalid, CatalogFile)
+	}
+	if err := yaml.Unmarshal(catalog, &b.Catalog); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalid, catalogName, err)
+	}
+	return b, nil
+}
+
+func isCatalogFile(name string) bool {
+	for _, f := range catalogFiles {
+		if name == f {
+			return true
+		}
+	}
+	return false
+}
+
+// Export writes templates as a catalog in format, FormatJSON or
+// FormatYAML, that Read reads back. Fields local to this server, such as
+// IDs and timestamps, are left out, as are empty ones.
+func Export(templates []db.Template, format string) ([]byte, error) {
+	if format != FormatJSON && format != FormatYAML {
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	entries := make([]map[string]any, 0, len(templates))
+	for _, t := range templates {
+		raw, err := json.Marshal(t)
+		if err != nil {
+			return nil, err
+		}
+		var entry map[string]any
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return nil, err
+		}
+		for _, f := range localFields {
+			delete(entry, f)
+		}
+		for k, v := range entry {
+			if v == nil || v == "" {
+				delete(entry, k)
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	data, err := json.MarshalIndent(map[string]any{
+		"version":   CatalogVersion,
+		"templates": entries,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if format == FormatYAML {
+		return yaml.JSONToYAML(data)
+	}
+	return append(data, '\n'), nil
+}
+
+// File returns the archive file a template icon refers to. It reports
+// false if icon is not the path of a file in the bundle.
+func (b *Bundle) File(icon string) ([]byte, bool) {
+	name, ok := cleanPath(icon)
+	if !ok {
+		return nil, false
+	}
+	data, ok := b.Files[name]
+	return data, ok
+}
+
+// cleanPath normalizes a relative path within the archive, reporting
+// false for absolute paths and paths that leave it.
+func cleanPath(name string) (string, bool) {
+	name = strings.TrimPrefix(strings.ReplaceAll(name, "\\", "/"), "./")
+	if name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, ":") {
+		return "", false
+	}
+	name = path.Clean(name)
+	if name == ".." || strings.HasPrefix(name, "../") {
+		return "", false
+	}
+	return name, true
+}
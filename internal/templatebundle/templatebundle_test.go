@@ -0,0 +1,146 @@
+package templatebundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// zipBundle builds a zip archive from name/content pairs.
+func zipBundle(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRead_JSON(t *testing.T) {
+	b, err := Read([]byte(`{"version":"1","templates":[{"template_id":"t1","name":"T1"}]}`))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(b.Catalog.Templates) != 1 || b.Catalog.Templates[0].TemplateID != "t1" {
+		t.Errorf("Catalog = %+v", b.Catalog)
+	}
+	if len(b.Files) != 0 {
+		t.Errorf("Files = %v, want none", b.Files)
+	}
+}
+
+func TestRead_YAML(t *testing.T) {
+	b, err := Read([]byte("version: \"1\"\ntemplates:\n  - template_id: t1\n    name: T1\n    tags: [a, b]\n"))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(b.Catalog.Templates) != 1 || b.Catalog.Templates[0].TemplateID != "t1" || len(b.Catalog.Templates[0].Tags) != 2 {
+		t.Errorf("Catalog = %+v", b.Catalog)
+	}
+
+	b, err = Read(zipBundle(t, map[string]string{"templates.yaml": "templates:\n  - template_id: t2\n"}))
+	if err != nil {
+		t.Fatalf("Read(zip) error = %v", err)
+	}
+	if len(b.Catalog.Templates) != 1 || b.Catalog.Templates[0].TemplateID != "t2" {
+		t.Errorf("zip Catalog = %+v", b.Catalog)
+	}
+}
+
+func TestExport(t *testing.T) {
+	templates := []db.Template{{
+		ID:                7,
+		TemplateID:        "t1",
+		Name:              "T1",
+		Catalog:           "vendor",
+		ContainerPort:     8080,
+		Tags:              []string{"a"},
+		RecommendedLimits: &db.ResourceLimits{CPULimit: "2"},
+		CreatedAt:         time.Now(),
+	}}
+	for _, format := range []string{FormatJSON, FormatYAML} {
+		data, err := Export(templates, format)
+		if err != nil {
+			t.Fatalf("Export(%s) error = %v", format, err)
+		}
+		for _, field := range []string{"catalog", "created_at", "description", "vendor"} {
+			if strings.Contains(string(data), field) {
+				t.Errorf("Export(%s) has %q:\n%s", format, field, data)
+			}
+		}
+		b, err := Read(data)
+		if err != nil {
+			t.Fatalf("Read(Export(%s)) error = %v", format, err)
+		}
+		got := b.Catalog.Templates
+		if b.Catalog.Version != CatalogVersion || len(got) != 1 || got[0].ID != 0 || got[0].TemplateID != "t1" ||
+			got[0].ContainerPort != 8080 || len(got[0].Tags) != 1 || got[0].RecommendedLimits == nil || got[0].RecommendedLimits.CPULimit != "2" {
+			t.Errorf("%s round trip = %+v", format, b.Catalog)
+		}
+	}
+
+	if _, err := Export(templates, "xml"); err == nil {
+		t.Error("Export(xml) succeeded")
+	}
+}
+
+func TestRead_Zip(t *testing.T) {
+	data := zipBundle(t, map[string]string{
+		"templates.json":   `{"templates":[{"template_id":"t1","name":"T1","icon":"icons/t1.svg"}]}`,
+		"icons/t1.svg":     `<svg></svg>`,
+		"./icons/logo.png": "png",
+	})
+	b, err := Read(data)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(b.Catalog.Templates) != 1 {
+		t.Fatalf("Catalog = %+v", b.Catalog)
+	}
+	for icon, want := range map[string]string{
+		"icons/t1.svg":          `<svg></svg>`,
+		"./icons/t1.svg":        `<svg></svg>`,
+		"icons/../icons/t1.svg": `<svg></svg>`,
+		"icons/logo.png":        "png",
+	} {
+		if got, ok := b.File(icon); !ok || string(got) != want {
+			t.Errorf("File(%q) = %q, %v; want %q", icon, got, ok, want)
+		}
+	}
+	for _, icon := range []string{"https://example.com/t1.svg", "/icons/t1.svg", "../icons/t1.svg", "templates.json", ""} {
+		if _, ok := b.File(icon); ok {
+			t.Errorf("File(%q) should not be found", icon)
+		}
+	}
+}
+
+func TestRead_Invalid(t *testing.T) {
+	tests := map[string][]byte{
+		"bad json":      []byte(`{"templates":`),
+		"no catalog":    zipBundle(t, map[string]string{"icons/t1.svg": "<svg></svg>"}),
+		"bad catalog":   zipBundle(t, map[string]string{"templates.json": "not json"}),
+		"two catalogs":  zipBundle(t, map[string]string{"templates.json": "{}", "templates.yaml": "{}"}),
+		"escaping path": zipBundle(t, map[string]string{"templates.json": "{}", "../evil.svg": "x"}),
+		"absolute path": zipBundle(t, map[string]string{"templates.json": "{}", "/etc/evil.svg": "x"}),
+		"truncated zip": zipBundle(t, map[string]string{"templates.json": "{}"})[:20],
+		"too much data": zipBundle(t, map[string]string{"templates.json": "{}", "big.bin": string(make([]byte, MaxSize+1))}),
+	}
+	for name, data := range tests {
+		if _, err := Read(data); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: Read() error = %v, want ErrInvalid", name, err)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+// Package templatesync keeps the template marketplace in step with remote
+// template catalogs.
+//
+// A remote catalog is a templates.json catalog published at a URL inside a
+// DSSE envelope signed with Ed25519, the format session attestations use.
+// Each sync downloads the catalog unless its ETag is unchanged, checks the
+// signature against the catalog's public key, and creates, updates and
+// removes the templates synced from it.
+//
+// A template edited locally since it was synced, or one that exists
+// locally without coming from the catalog, is a conflict: by default it is
+// left alone and reported, or it is replaced if the catalog is set to
+// overwrite local changes.
+package templatesync
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/attestation"
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/icons"
+	"github.com/rjsadow/sortie/internal/offline"
+	"github.com/rjsadow/sortie/internal/templatebundle"
+)
+
+// PayloadType identifies template catalogs inside a DSSE envelope.
+const PayloadType = "application/vnd.sortie.template-catalog+json"
+
+// FetchTimeout bounds downloading a catalog.
+const FetchTimeout = 30 * time.Second
+
+// ErrInvalid is returned for a catalog that is not signed by the
+// catalog's key or that holds invalid templates.
+var ErrInvalid = errors.New("invalid template catalog")
+
+// Sign wraps a templates.json catalog in an envelope signed with key, as
+// published for remote catalogs.
+func Sign(catalog []byte, key ed25519.PrivateKey) ([]byte, error) {
+	var c db.TemplateCatalog
+	if err := json.Unmarshal(catalog, &c); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return json.MarshalIndent(attestation.SignPayload(key, PayloadType, catalog), "", "  ")
+}
+
+// Open checks that data is a catalog envelope signed by pub and returns
+// the catalog, with template defaults filled in as for an import. Every
+// template must be valid.
+func Open(data []byte, pub ed25519.PublicKey) (*db.TemplateCatalog, error) {
+	var env attestation.Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("%w: not a signed envelope: %v", ErrInvalid, err)
+	}
+	payload, err := attestation.VerifyPayload(&env, PayloadType, pub)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	var catalog db.TemplateCatalog
+	if err := json.Unmarshal(payload, &catalog); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	seen := make(map[string]bool)
+	for i := range catalog.Templates {
+		t := &catalog.Templates[i]
+		if t.TemplateID == "" || t.Name == "" || t.TemplateCategory == "" || t.Category == "" {
+			return nil, fmt.Errorf("%w: template %d: template_id, name, template_category and category are required", ErrInvalid, i+1)
+		}
+		if seen[t.TemplateID] {
+			return nil, fmt.Errorf("%w: template %s appears more than once", ErrInvalid, t.TemplateID)
+		}
+		seen[t.TemplateID] = true
+		if offline.Enabled() && icons.IsRemote(t.Icon) {
+			return nil, fmt.Errorf("%w: template %s: the server is offline, so its icon cannot be on another host", ErrInvalid, t.TemplateID)
+		}
+		if t.TemplateVersion == "" {
+			t.TemplateVersion = "1.0.0"
+		}
+		if t.LaunchType == "" {
+			t.LaunchType = "container"
+		}
+	}
+	return &catalog, nil
+}
+
+// ContentHash returns a hash of what a template offers: everything but its
+// IDs, timestamps and catalog. A synced template whose hash no longer
+// matches the one recorded when it was synced has been edited locally.
+func ContentHash(t db.Template) string {
+	t.ID = 0
+	t.Catalog, t.CatalogHash = "", ""
+	t.CreatedAt, t.UpdatedAt = time.Time{}, time.Time{}
+	// Match how templates read back from the database
+	if t.OsType == "" {
+		t.OsType = "linux"
+	}
+	if len(t.ContainerArgs) == 0 {
+		t.ContainerArgs = nil
+	}
+	if len(t.Tags) == 0 {
+		t.Tags = nil
+	}
+	if l := t.RecommendedLimits; l != nil && *l == (db.ResourceLimits{}) {
+		t.RecommendedLimits = nil
+	}
+	b, _ := json.Marshal(t)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Syncer syncs remote catalogs into the template marketplace.
+type Syncer struct {
+	db     *db.DB
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewSyncer creates a Syncer that downloads catalogs with client, which
+// should apply the outbound policy for admin-supplied URLs.
+func NewSyncer(database *db.DB, client *http.Client) *Syncer {
+	return &Syncer{db: database, client: client, now: time.Now}
+}
+
+// Run syncs every remote catalog. It is run as a background job, and fails
+// if any catalog could not be synced.
+func (s *Syncer) Run(ctx context.Context) error {
+	catalogs, err := s.db.ListRemoteCatalogs()
+	if err != nil {
+		return fmt.Errorf("failed to list remote catalogs: %w", err)
+	}
+	var errs []error
+	for i := range catalogs {
+		if err := s.Sync(ctx, &catalogs[i]); err != nil {
+			errs = append(errs, fmt.Errorf("catalog %s: %w", catalogs[i].Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Sync syncs one remote catalog and records the outcome in c and the
+// database. The returned error, if any, is also recorded as c's sync
+// error. c keeps the result of the last download when the catalog is
+// unchanged or cannot be synced.
+func (s *Syncer) Sync(ctx context.Context, c *db.RemoteCatalog) error {
+	result, etag, err := s.sync(ctx, c)
+	now := s.now().UTC()
+	c.SyncedAt = &now
+	switch {
+	case err != nil:
+		c.SyncStatus = db.CatalogSyncError
+		c.SyncError = err.Error()
+		slog.Warn("template catalog sync failed", "catalog", c.Name, "error", err)
+	case result == nil:
+		c.SyncStatus = db.CatalogSyncNotModified
+		c.SyncError = ""
+	default:
+		c.SyncStatus = db.CatalogSyncOK
+		c.SyncError = ""
+		c.SyncResult = result
+		c.ETag = etag
+		slog.Info("synced template catalog", "catalog", c.Name,
+			"created", len(result.Created), "updated", len(result.Updated),
+			"removed", len(result.Removed), "conflicts", len(result.Conflicts))
+	}
+	if dbErr := s.db.SetRemoteCatalogSync(*c); dbErr != nil {
+		return errors.Join(err, fmt.Errorf("failed to record sync: %w", dbErr))
+	}
+	return err
+}
+
+// sync downloads and applies a catalog. It returns a nil result if the
+// catalog is unchanged since its ETag.
+func (s *Syncer) sync(ctx context.Context, c *db.RemoteCatalog) (*db.CatalogSyncResult, string, error) {
+	pub, err := attestation.ParsePublicKeyPEM(c.PublicKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, FetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.ETag != "" {
+		req.Header.Set("If-None-Match", c.ETag)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch catalog: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && c.ETag != "" {
+		return nil, "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to fetch catalog: HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, templatebundle.MaxSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch catalog: %w", err)
+	}
+	if len(data) > templatebundle.MaxSize {
+		return nil, "", fmt.Errorf("%w: larger than %d bytes", ErrInvalid, templatebundle.MaxSize)
+	}
+
+	catalog, err := Open(data, pub)
+	if err != nil {
+		return nil, "", err
+	}
+	result, err := s.apply(c, catalog.Templates)
+	if err != nil {
+		return nil, "", err
+	}
+	return result, resp.Header.Get("ETag"), nil
+}
+
+// apply creates, updates and removes the templates synced from c so they
+// match remote, subject to c's conflict handling.
+func (s *Syncer) apply(c *db.RemoteCatalog, remote []db.Template) (*db.CatalogSyncResult, error) {
+	locals, err := s.db.ListTemplates()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+	byID := make(map[string]*db.Template, len(locals))
+	for i := range locals {
+		byID[locals[i].TemplateID] = &locals[i]
+	}
+	overwrite := c.OnConflict == db.CatalogConflictOverwrite
+	result := &db.CatalogSyncResult{
+		Created:   []string{},
+		Updated:   []string{},
+		Removed:   []string{},
+		Conflicts: []db.CatalogConflict{},
+	}
+	conflict := func(id, reason string) {
+		result.Conflicts = append(result.Conflicts, db.CatalogConflict{TemplateID: id, Reason: reason, Overwritten: overwrite})
+	}
+
+	inRemote := make(map[string]bool, len(remote))
+	for _, t := range remote {
+		inRemote[t.TemplateID] = true
+		t.Catalog = c.Name
+		t.CatalogHash = ContentHash(t)
+
+		local, exists := byID[t.TemplateID]
+		if !exists {
+			if err := s.db.SaveCatalogTemplate(t); err != nil {
+				return nil, fmt.Errorf("failed to create template %s: %w", t.TemplateID, err)
+			}
+			result.Created = append(result.Created, t.TemplateID)
+			continue
+		}
+
+		var reason string
+		switch {
+		case local.Catalog == "":
+			reason = "a local template has the same template_id"
+		case local.Catalog != c.Name:
+			reason = fmt.Sprintf("already synced from catalog %s", local.Catalog)
+		case local.CatalogHash == t.CatalogHash:
+			// Unchanged in the catalog; any local edits stand
+			continue
+		case ContentHash(*local) != local.CatalogHash:
+			reason = "edited locally and changed in the catalog"
+		}
+		if reason != "" {
+			conflict(t.TemplateID, reason)
+			if !overwrite {
+				continue
+			}
+		}
+		if err := s.db.SaveCatalogTemplate(t); err != nil {
+			return nil, fmt.Errorf("failed to update template %s: %w", t.TemplateID, err)
+		}
+		result.Updated = append(result.Updated, t.TemplateID)
+	}
+
+	for _, local := range locals {
+		if local.Catalog != c.Name || inRemote[local.TemplateID] {
+			continue
+		}
+		if ContentHash(local) != local.CatalogHash {
+			conflict(local.TemplateID, "edited locally and removed from the catalog")
+			if !overwrite {
+				continue
+			}
+		}
+		if err := s.db.DeleteTemplate(local.TemplateID); err != nil {
+			return nil, fmt.Errorf("failed to remove template %s: %w", local.TemplateID, err)
+		}
+		result.Removed = append(result.Removed, local.TemplateID)
+	}
+	return result, nil
+}
@@ -0,0 +1,233 @@
+package templatesync
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/db/dbtest"
+)
+
+func newKey(t *testing.T) (ed25519.PrivateKey, string) {
+	t.Helper()
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func signCatalog(t *testing.T, key ed25519.PrivateKey, templates ...db.Template) []byte {
+	t.Helper()
+	catalog, err := json.Marshal(db.TemplateCatalog{Version: "1.0.0", Templates: templates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := Sign(catalog, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func tmpl(id, image string) db.Template {
+	return db.Template{
+		TemplateID: id, Name: id, TemplateCategory: "development", Category: "Development",
+		ContainerImage: image, Tags: []string{"ide"},
+	}
+}
+
+// catalogServer serves a signed catalog with an ETag and answers 304 when
+// the client already has it.
+type catalogServer struct {
+	mu   sync.Mutex
+	data []byte
+	etag string
+}
+
+func (c *catalogServer) set(data []byte, etag string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data, c.etag = data, etag
+}
+
+func (c *catalogServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.etag != "" && r.Header.Get("If-None-Match") == c.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", c.etag)
+	w.Write(c.data)
+}
+
+func TestSignOpen(t *testing.T) {
+	key, _ := newKey(t)
+	data := signCatalog(t, key, tmpl("ide", "code-server:4"))
+
+	pub, _, _ := ed25519.GenerateKey(nil)
+	if _, err := Open(data, pub); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Open() with another key error = %v, want ErrInvalid", err)
+	}
+
+	catalog, err := Open(data, key.Public().(ed25519.PublicKey))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if len(catalog.Templates) != 1 || catalog.Templates[0].TemplateVersion != "1.0.0" || catalog.Templates[0].LaunchType != "container" {
+		t.Errorf("Open() = %+v, want one template with defaults", catalog.Templates)
+	}
+
+	for name, templates := range map[string][]db.Template{
+		"missing category": {{TemplateID: "ide", Name: "IDE"}},
+		"duplicate":        {tmpl("ide", "a:1"), tmpl("ide", "b:1")},
+	} {
+		if _, err := Open(signCatalog(t, key, templates...), key.Public().(ed25519.PublicKey)); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: Open() error = %v, want ErrInvalid", name, err)
+		}
+	}
+
+	if _, err := Sign([]byte("not json"), key); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Sign() error = %v, want ErrInvalid", err)
+	}
+}
+
+func TestContentHashSurvivesDatabase(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+	t1 := tmpl("ide", "code-server:4")
+	t1.TemplateVersion, t1.LaunchType = "1.0.0", "container"
+	if err := database.CreateTemplate(t1); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := database.GetTemplate("ide")
+	if err != nil || stored == nil {
+		t.Fatalf("GetTemplate() = %v, %v", stored, err)
+	}
+	if ContentHash(*stored) != ContentHash(t1) {
+		t.Errorf("ContentHash changed across a database round trip:\n%+v\n%+v", t1, *stored)
+	}
+	stored.Description = "edited"
+	if ContentHash(*stored) == ContentHash(t1) {
+		t.Error("ContentHash ignores an edit")
+	}
+}
+
+func TestSyncer(t *testing.T) {
+	database := dbtest.NewTestDB(t)
+	key, pubPEM := newKey(t)
+	srv := &catalogServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	if err := database.CreateTemplate(db.Template{TemplateID: "mine", Name: "Mine", TemplateCategory: "x", Category: "X"}); err != nil {
+		t.Fatal(err)
+	}
+	catalog := db.RemoteCatalog{Name: "vendor", URL: ts.URL, PublicKey: pubPEM, OnConflict: db.CatalogConflictKeepLocal, CreatedBy: "admin"}
+	if err := database.CreateRemoteCatalog(catalog); err != nil {
+		t.Fatal(err)
+	}
+	syncer := NewSyncer(database, ts.Client())
+	sync := func() *db.RemoteCatalog {
+		t.Helper()
+		if err := syncer.Run(context.Background()); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		c, err := database.GetRemoteCatalog("vendor")
+		if err != nil || c == nil {
+			t.Fatalf("GetRemoteCatalog() = %v, %v", c, err)
+		}
+		return c
+	}
+
+	// First sync creates the catalog's templates; a clash with a local
+	// template is left alone
+	srv.set(signCatalog(t, key, tmpl("ide", "code-server:4"), tmpl("lab", "jupyter:1"), tmpl("mine", "theirs:1")), `"v1"`)
+	c := sync()
+	if c.SyncStatus != db.CatalogSyncOK || c.ETag != `"v1"` || c.SyncedAt == nil {
+		t.Fatalf("after first sync: %+v", c)
+	}
+	if r := c.SyncResult; len(r.Created) != 2 || len(r.Conflicts) != 1 || r.Conflicts[0].TemplateID != "mine" || r.Conflicts[0].Overwritten {
+		t.Errorf("first sync result = %+v", r)
+	}
+	if mine, _ := database.GetTemplate("mine"); mine.Catalog != "" || mine.ContainerImage != "" {
+		t.Errorf("local template was changed: %+v", mine)
+	}
+	if ide, _ := database.GetTemplate("ide"); ide.Catalog != "vendor" {
+		t.Errorf("synced template catalog = %q", ide.Catalog)
+	}
+
+	// Unchanged catalog is not downloaded again
+	if c = sync(); c.SyncStatus != db.CatalogSyncNotModified || len(c.SyncResult.Created) != 2 {
+		t.Errorf("after unchanged sync: %+v", c)
+	}
+
+	// A local edit survives an update to another template and is a
+	// conflict once the catalog changes it too
+	lab, _ := database.GetTemplate("lab")
+	lab.Description = "our notes"
+	if err := database.UpdateTemplate(*lab); err != nil {
+		t.Fatal(err)
+	}
+	srv.set(signCatalog(t, key, tmpl("ide", "code-server:5"), tmpl("lab", "jupyter:1")), `"v2"`)
+	c = sync()
+	if r := c.SyncResult; len(r.Updated) != 1 || r.Updated[0] != "ide" || len(r.Conflicts) != 0 {
+		t.Errorf("second sync result = %+v", r)
+	}
+	if lab, _ = database.GetTemplate("lab"); lab.Description != "our notes" || lab.Catalog != "vendor" {
+		t.Errorf("edited template = %+v", lab)
+	}
+
+	srv.set(signCatalog(t, key, tmpl("ide", "code-server:5")), `"v3"`)
+	c = sync()
+	if r := c.SyncResult; len(r.Removed) != 0 || len(r.Conflicts) != 1 || r.Conflicts[0].TemplateID != "lab" {
+		t.Errorf("third sync result = %+v", r)
+	}
+
+	// Overwriting replaces local edits and removes what the catalog dropped
+	c.OnConflict = db.CatalogConflictOverwrite
+	if err := database.UpdateRemoteCatalog(*c); err != nil {
+		t.Fatal(err)
+	}
+	c = sync()
+	if r := c.SyncResult; len(r.Removed) != 1 || r.Removed[0] != "lab" || len(r.Conflicts) != 1 || !r.Conflicts[0].Overwritten {
+		t.Errorf("overwrite sync result = %+v", r)
+	}
+	if lab, _ = database.GetTemplate("lab"); lab != nil {
+		t.Errorf("removed template still exists: %+v", lab)
+	}
+
+	// A bad signature is recorded and changes nothing
+	other, _ := newKey(t)
+	srv.set(signCatalog(t, other), `"v4"`)
+	if err := syncer.Run(context.Background()); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Run() with a bad signature error = %v, want ErrInvalid", err)
+	}
+	c, _ = database.GetRemoteCatalog("vendor")
+	if c.SyncStatus != db.CatalogSyncError || c.SyncError == "" || c.ETag != `"v3"` {
+		t.Errorf("after bad signature: %+v", c)
+	}
+	if ide, _ := database.GetTemplate("ide"); ide == nil || ide.ContainerImage != "code-server:5" {
+		t.Errorf("template after bad signature = %+v", ide)
+	}
+
+	// Deleting the catalog keeps its templates as local ones
+	if err := database.DeleteRemoteCatalog("vendor"); err != nil {
+		t.Fatal(err)
+	}
+	if ide, _ := database.GetTemplate("ide"); ide == nil || ide.Catalog != "" || ide.CatalogHash != "" {
+		t.Errorf("template after deleting its catalog = %+v", ide)
+	}
+}
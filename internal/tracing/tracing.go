@@ -0,0 +1,90 @@
+// Package tracing sets up OpenTelemetry tracing for the server.
+//
+// Spans are created throughout the code with otel.Tracer; until Setup
+// installs a tracer provider they are no-ops.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/rjsadow/sortie/internal/offline"
+	"github.com/rjsadow/sortie/internal/version"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"google.golang.org/grpc"
+)
+
+// Config selects where spans are exported.
+type Config struct {
+	Exporter    string // "none", "otlp", or "console"
+	Protocol    string // OTLP protocol: "http/protobuf" or "grpc"
+	ServiceName string
+}
+
+// Setup installs the global tracer provider and W3C trace context
+// propagation for cfg. The OTLP exporters read their endpoint, headers,
+// and timeouts from the standard OTEL_EXPORTER_OTLP_* variables, and the
+// sampler comes from OTEL_TRACES_SAMPLER. The returned function flushes
+// and stops the provider.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		if cfg.Protocol == "grpc" {
+			exporter, err = otlptracegrpc.New(ctx, otlptracegrpc.WithDialOption(
+				grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+					return offline.Dial(ctx, &net.Dialer{}, "tcp", addr)
+				})))
+		} else {
+			var opts []otlptracehttp.Option
+			if offline.Enabled() {
+				// The exporter's own client honours OTEL_EXPORTER_OTLP_CERTIFICATE,
+				// so it is replaced only when offline mode needs to guard it
+				opts = append(opts, otlptracehttp.WithHTTPClient(offline.Client(0)))
+			}
+			exporter, err = otlptracehttp.New(ctx, opts...)
+		}
+	case "console":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stderr))
+	default:
+		return nil, fmt.Errorf("unknown traces exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", cfg.Exporter, err)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			semconv.ServiceName(cfg.ServiceName),
+			semconv.ServiceVersion(version.Get().Version),
+		),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
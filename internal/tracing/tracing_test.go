@@ -0,0 +1,28 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSetup(t *testing.T) {
+	shutdown, err := Setup(t.Context(), Config{Exporter: "none"})
+	if err != nil {
+		t.Fatalf("Setup(none) error = %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown() error = %v", err)
+	}
+
+	shutdown, err = Setup(t.Context(), Config{Exporter: "console", ServiceName: "sortie-test"})
+	if err != nil {
+		t.Fatalf("Setup(console) error = %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown() error = %v", err)
+	}
+
+	if _, err := Setup(t.Context(), Config{Exporter: "zipkin"}); err == nil {
+		t.Error("Setup(zipkin) expected error")
+	}
+}
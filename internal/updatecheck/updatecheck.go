@@ -0,0 +1,253 @@
+// Package updatecheck compares the running version with the latest
+// published release. It is off unless an admin enables it, and sends only
+// a plain GET for the release list: no version, instance ID, or usage data
+// leaves the server, and the comparison happens locally.
+package updatecheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/mod/semver"
+
+	"github.com/rjsadow/sortie/internal/offline"
+)
+
+// Release channels.
+const (
+	ChannelStable     = "stable"
+	ChannelPrerelease = "prerelease"
+)
+
+// maxResponseSize bounds the release list read from the metadata URL.
+const maxResponseSize = 5 << 20
+
+// Release is an entry in the release metadata, in the GitHub releases API
+// format.
+type Release struct {
+	TagName     string    `json:"tag_name"`
+	HTMLURL     string    `json:"html_url"`
+	Draft       bool      `json:"draft"`
+	Prerelease  bool      `json:"prerelease"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// Settings are the admin-controlled parts of the check.
+type Settings struct {
+	Enabled bool
+	Channel string
+}
+
+// Status is the outcome of the most recent check.
+type Status struct {
+	Enabled         bool       `json:"enabled"`
+	Channel         string     `json:"channel"`
+	CurrentVersion  string     `json:"current_version"`
+	LatestVersion   string     `json:"latest_version,omitempty"`
+	UpdateAvailable bool       `json:"update_available"`
+	ReleaseURL      string     `json:"release_url,omitempty"`
+	CheckedAt       *time.Time `json:"checked_at,omitempty"`
+	Error           string     `json:"error,omitempty"`
+}
+
+// Checker periodically fetches the release list while the check is
+// enabled and remembers the result.
+type Checker struct {
+	url      string
+	current  string
+	settings func() Settings
+	client   *http.Client
+	interval time.Duration // how often to refetch
+	poll     time.Duration // how often to look at the settings
+	stopCh   chan struct{}
+
+	mu      sync.Mutex
+	status  Status
+	checked bool
+}
+
+// NewChecker creates a Checker that fetches release metadata from url and
+// compares it with current. settings is consulted before every check, so
+// enabling the check or changing the channel takes effect without a
+// restart.
+func NewChecker(url, current string, settings func() Settings) *Checker {
+	return &Checker{
+		url:      url,
+		current:  current,
+		settings: settings,
+		client:   offline.Client(30 * time.Second),
+		interval: 24 * time.Hour,
+		poll:     5 * time.Minute,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start launches the check goroutine. It returns immediately.
+func (c *Checker) Start() {
+	go c.loop()
+}
+
+// Stop signals the check goroutine to exit.
+func (c *Checker) Stop() {
+	close(c.stopCh)
+}
+
+func (c *Checker) loop() {
+	c.checkIfDue()
+
+	ticker := time.NewTicker(c.poll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.checkIfDue()
+		case <-c.stopCh:
+			return
+		}
+	}
+}
+
+// checkIfDue runs a check when enabled and the last one is older than the
+// interval or was for another channel.
+func (c *Checker) checkIfDue() {
+	s := c.settings()
+	if !s.Enabled {
+		return
+	}
+	c.mu.Lock()
+	due := !c.checked || c.status.Channel != s.Channel ||
+		c.status.CheckedAt == nil || time.Since(*c.status.CheckedAt) >= c.interval
+	c.mu.Unlock()
+	if !due {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.client.Timeout)
+	defer cancel()
+	if err := c.Check(ctx); err != nil {
+		slog.Warn("Update check failed", "url", c.url, "error", err)
+	}
+}
+
+// Check fetches the release list now and records the result.
+func (c *Checker) Check(ctx context.Context) error {
+	channel := c.settings().Channel
+	now := time.Now()
+	status := Status{Channel: channel, CurrentVersion: c.current, CheckedAt: &now}
+
+	releases, err := c.fetch(ctx)
+	if err == nil {
+		if latest, ok := Latest(releases, channel); ok {
+			status.LatestVersion = latest.TagName
+			status.ReleaseURL = latest.HTMLURL
+			status.UpdateAvailable = Newer(latest.TagName, c.current)
+		}
+	} else {
+		status.Error = err.Error()
+	}
+
+	c.mu.Lock()
+	c.status = status
+	c.checked = true
+	c.mu.Unlock()
+
+	if status.UpdateAvailable {
+		slog.Info("Update available", "current", c.current, "latest", status.LatestVersion, "url", status.ReleaseURL)
+	}
+	return err
+}
+
+// Status returns the result of the last check. When the check is disabled
+// only Enabled, Channel, and CurrentVersion are set.
+func (c *Checker) Status() Status {
+	s := c.settings()
+	if !s.Enabled {
+		return Status{Channel: s.Channel, CurrentVersion: c.current}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	status := c.status
+	status.Enabled = true
+	if !c.checked || status.Channel != s.Channel {
+		// Not yet checked on this channel
+		status = Status{Enabled: true, CurrentVersion: c.current}
+	}
+	status.Channel = s.Channel
+	return status
+}
+
+func (c *Checker) fetch(ctx context.Context) ([]Release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "sortie-update-check")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("release metadata returned status %d", resp.StatusCode)
+	}
+
+	var releases []Release
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&releases); err != nil {
+		return nil, fmt.Errorf("invalid release metadata: %w", err)
+	}
+	return releases, nil
+}
+
+// Latest returns the highest release on channel. Drafts and tags that are
+// not semantic versions are ignored, as are pre-releases on the stable
+// channel.
+func Latest(releases []Release, channel string) (Release, bool) {
+	var latest Release
+	found := false
+	for _, r := range releases {
+		v := canonical(r.TagName)
+		if r.Draft || v == "" {
+			continue
+		}
+		if channel != ChannelPrerelease && (r.Prerelease || semver.Prerelease(v) != "") {
+			continue
+		}
+		if !found || semver.Compare(v, canonical(latest.TagName)) > 0 {
+			latest, found = r, true
+		}
+	}
+	return latest, found
+}
+
+// Newer reports whether version a is newer than b. Builds that are not
+// semantic versions, such as "dev", are never reported as out of date.
+func Newer(a, b string) bool {
+	va, vb := canonical(a), canonical(b)
+	if va == "" || vb == "" {
+		return false
+	}
+	return semver.Compare(va, vb) > 0
+}
+
+// canonical returns v as a semantic version with a "v" prefix, or "" if it
+// is not one.
+func canonical(v string) string {
+	if !strings.HasPrefix(v, "v") {
+		v = "v" + v
+	}
+	if !semver.IsValid(v) {
+		return ""
+	}
+	return v
+}
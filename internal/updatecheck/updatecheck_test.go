@@ -0,0 +1,113 @@
+package updatecheck
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+var testReleases = []Release{
+	{TagName: "v1.3.0-rc.1", HTMLURL: "https://example.com/v1.3.0-rc.1", Prerelease: true},
+	{TagName: "v1.4.0", HTMLURL: "https://example.com/v1.4.0", Draft: true},
+	{TagName: "v1.2.0", HTMLURL: "https://example.com/v1.2.0"},
+	{TagName: "nightly", HTMLURL: "https://example.com/nightly"},
+	{TagName: "v1.10.0-beta", HTMLURL: "https://example.com/v1.10.0-beta"},
+	{TagName: "1.2.1", HTMLURL: "https://example.com/1.2.1"},
+}
+
+func TestLatest(t *testing.T) {
+	if r, ok := Latest(testReleases, ChannelStable); !ok || r.TagName != "1.2.1" {
+		t.Errorf("Latest(stable) = %q, %v; want 1.2.1", r.TagName, ok)
+	}
+	if r, ok := Latest(testReleases, ChannelPrerelease); !ok || r.TagName != "v1.10.0-beta" {
+		t.Errorf("Latest(prerelease) = %q, %v; want v1.10.0-beta", r.TagName, ok)
+	}
+	if _, ok := Latest([]Release{{TagName: "nightly"}}, ChannelStable); ok {
+		t.Error("Latest() found a release with no semantic versions")
+	}
+}
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"v1.2.1", "v1.2.0", true},
+		{"1.2.1", "v1.2.0", true},
+		{"v1.2.0", "v1.2.0", false},
+		{"v1.3.0-rc.1", "v1.2.0", true},
+		{"v1.3.0", "v1.3.0-rc.1", true},
+		{"v1.2.0", "v1.10.0", false},
+		{"v1.2.0", "dev", false},
+	}
+	for _, tt := range tests {
+		if got := Newer(tt.a, tt.b); got != tt.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestChecker(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if ua := r.Header.Get("User-Agent"); ua != "sortie-update-check" {
+			t.Errorf("User-Agent = %q, want no version or instance details", ua)
+		}
+		if r.URL.RawQuery != "" {
+			t.Errorf("query = %q, want none", r.URL.RawQuery)
+		}
+		json.NewEncoder(w).Encode(testReleases)
+	}))
+	defer srv.Close()
+
+	settings := Settings{Channel: ChannelStable}
+	c := NewChecker(srv.URL, "v1.2.0", func() Settings { return settings })
+
+	c.checkIfDue()
+	if n := requests.Load(); n != 0 {
+		t.Fatalf("disabled checker made %d requests", n)
+	}
+	if s := c.Status(); s.Enabled || s.CheckedAt != nil || s.CurrentVersion != "v1.2.0" {
+		t.Errorf("disabled Status() = %+v", s)
+	}
+
+	settings.Enabled = true
+	c.checkIfDue()
+	s := c.Status()
+	if !s.Enabled || !s.UpdateAvailable || s.LatestVersion != "1.2.1" || s.ReleaseURL != "https://example.com/1.2.1" || s.CheckedAt == nil {
+		t.Errorf("Status() = %+v, want 1.2.1 available", s)
+	}
+
+	c.checkIfDue()
+	if n := requests.Load(); n != 1 {
+		t.Errorf("checker made %d requests within the interval, want 1", n)
+	}
+
+	settings.Channel = ChannelPrerelease
+	if s := c.Status(); s.CheckedAt != nil || s.Channel != ChannelPrerelease {
+		t.Errorf("Status() after changing channel = %+v, want unchecked", s)
+	}
+	c.checkIfDue()
+	if s := c.Status(); s.LatestVersion != "v1.10.0-beta" || requests.Load() != 2 {
+		t.Errorf("Status() on prerelease channel = %+v after %d requests", s, requests.Load())
+	}
+}
+
+func TestChecker_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	c := NewChecker(srv.URL, "v1.2.0", func() Settings { return Settings{Enabled: true, Channel: ChannelStable} })
+	if err := c.Check(context.Background()); err == nil {
+		t.Fatal("Check() expected error")
+	}
+	if s := c.Status(); s.Error == "" || s.UpdateAvailable || s.CheckedAt == nil {
+		t.Errorf("Status() = %+v, want the error recorded", s)
+	}
+}
@@ -0,0 +1,91 @@
+// Package version reports which build of Sortie is running. Release builds
+// set Version, Commit, and BuildDate with the linker:
+//
+//	go build -ldflags "-X github.com/rjsadow/sortie/internal/version.Version=v1.2.3 \
+//	  -X github.com/rjsadow/sortie/internal/version.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/rjsadow/sortie/internal/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Builds without ldflags fall back to the VCS information the Go toolchain
+// embeds, so `go build` in a checkout still reports its commit.
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at build time with -ldflags -X.
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// Info describes the running build.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build information, filling the commit and build date
+// from the embedded VCS settings when they were not set with ldflags.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if info.Version == "dev" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		info.Version = bi.Main.Version
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = s.Value
+			}
+		case "vcs.time":
+			if info.BuildDate == "" {
+				info.BuildDate = s.Value
+			}
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}
+
+// ShortCommit returns the first 12 characters of the commit.
+func (i Info) ShortCommit() string {
+	if len(i.Commit) > 12 {
+		return i.Commit[:12]
+	}
+	return i.Commit
+}
+
+// String formats the build as "v1.2.3 (abc123def456, built 2024-01-02T03:04:05Z)".
+func (i Info) String() string {
+	s := i.Version
+	commit := i.ShortCommit()
+	if i.Modified {
+		commit += "-dirty"
+	}
+	switch {
+	case commit != "" && i.BuildDate != "":
+		s += fmt.Sprintf(" (%s, built %s)", commit, i.BuildDate)
+	case commit != "":
+		s += fmt.Sprintf(" (%s)", commit)
+	case i.BuildDate != "":
+		s += fmt.Sprintf(" (built %s)", i.BuildDate)
+	}
+	return s
+}
@@ -0,0 +1,37 @@
+package version
+
+import "testing"
+
+func TestGet_LDFlags(t *testing.T) {
+	defer func(v, c, d string) { Version, Commit, BuildDate = v, c, d }(Version, Commit, BuildDate)
+	Version, Commit, BuildDate = "v1.2.3", "0123456789abcdef0123", "2024-01-02T03:04:05Z"
+
+	info := Get()
+	if info.Version != "v1.2.3" || info.Commit != Commit || info.BuildDate != BuildDate {
+		t.Errorf("Get() = %+v, want the ldflags values", info)
+	}
+	if info.GoVersion == "" {
+		t.Error("Get().GoVersion is empty")
+	}
+	if got := info.ShortCommit(); got != "0123456789ab" {
+		t.Errorf("ShortCommit() = %q, want 0123456789ab", got)
+	}
+}
+
+func TestInfo_String(t *testing.T) {
+	tests := []struct {
+		info Info
+		want string
+	}{
+		{Info{Version: "dev"}, "dev"},
+		{Info{Version: "v1.0.0", Commit: "abc123"}, "v1.0.0 (abc123)"},
+		{Info{Version: "dev", Commit: "abc123", Modified: true}, "dev (abc123-dirty)"},
+		{Info{Version: "v1.0.0", Commit: "0123456789abcdef", BuildDate: "2024-01-02T03:04:05Z"}, "v1.0.0 (0123456789ab, built 2024-01-02T03:04:05Z)"},
+		{Info{Version: "v1.0.0", BuildDate: "2024-01-02T03:04:05Z"}, "v1.0.0 (built 2024-01-02T03:04:05Z)"},
+	}
+	for _, tt := range tests {
+		if got := tt.info.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.info, got, tt.want)
+		}
+	}
+}
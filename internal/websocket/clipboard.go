@@ -0,0 +1,730 @@
+package websocket
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// ClipboardBlockedFunc receives each clipboard transfer the clipboard
+// policy blocks. pasteIn is true for clipboard data sent from the browser
+// into the session, and false for data sent from the session to the
+// browser.
+type ClipboardBlockedFunc func(pasteIn bool)
+
+type clipboardRule struct {
+	policy    db.ClipboardPolicy
+	onBlocked ClipboardBlockedFunc
+}
+
+type clipboardRuleKey struct{}
+
+// WithClipboardPolicy returns a context that makes the VNC handler drop
+// the clipboard messages policy does not allow between the connecting
+// client and the session. Each blocked message is reported to onBlocked,
+// which may be nil.
+func WithClipboardPolicy(ctx context.Context, policy db.ClipboardPolicy, onBlocked ClipboardBlockedFunc) context.Context {
+	return context.WithValue(ctx, clipboardRuleKey{}, clipboardRule{policy: policy, onBlocked: onBlocked})
+}
+
+func clipboardRuleFrom(ctx context.Context) clipboardRule {
+	rule, _ := ctx.Value(clipboardRuleKey{}).(clipboardRule)
+	return rule
+}
+
+// RFB security types the filter can follow. Other types, such as VeNCrypt,
+// encrypt the stream, so its clipboard messages cannot be found.
+const (
+	rfbSecurityNone = 1
+	rfbSecurityVNC  = 2
+)
+
+// RFB encodings the filter can find the end of. See framedEncoding.
+const (
+	encodingRaw                  = 0
+	encodingCopyRect             = 1
+	encodingRRE                  = 2
+	encodingHextile              = 5
+	encodingTight                = 7
+	encodingZRLE                 = 16
+	encodingH264                 = 50
+	encodingTightPNG             = -260
+	encodingCursor               = -239
+	encodingDesktopSize          = -223
+	encodingLastRect             = -224
+	encodingQEMUPointerMotion    = -257
+	encodingQEMUExtendedKeyEvent = -258
+	encodingDesktopName          = -307
+	encodingExtendedDesktopSize  = -308
+	encodingXVP                  = -309
+	encodingFence                = -312
+	encodingContinuousUpdates    = -313
+	encodingExtendedMouseButtons = -316
+	encodingExtendedClipboard    = -1063131698
+)
+
+// Hextile subencoding flags.
+const (
+	hextileRaw                 = 1
+	hextileBackgroundSpecified = 2
+	hextileForegroundSpecified = 4
+	hextileAnySubrects         = 8
+	hextileSubrectsColoured    = 16
+)
+
+// maxRFBMessageHeader bounds the bytes the filter holds back waiting for
+// the rest of a message part. The longest it parses whole is a
+// SetEncodings message with every encoding.
+const maxRFBMessageHeader = 4 + 4*65535
+
+// framedEncoding reports whether the filter can find the end of
+// rectangles in encoding, or knows the encoding never sends any. While
+// copying out of a session is blocked, the client's other encodings are
+// removed from its SetEncodings message so the server never uses them.
+func framedEncoding(e int32) bool {
+	switch e {
+	case encodingRaw, encodingCopyRect, encodingRRE, encodingHextile, encodingTight, encodingZRLE,
+		encodingH264, encodingTightPNG, encodingCursor, encodingDesktopSize, encodingLastRect,
+		encodingQEMUPointerMotion, encodingQEMUExtendedKeyEvent, encodingDesktopName,
+		encodingExtendedDesktopSize, encodingXVP, encodingFence, encodingContinuousUpdates,
+		encodingExtendedMouseButtons, encodingExtendedClipboard:
+		return true
+	}
+	// Compression, quality and subsampling levels only ask the server for
+	// a tradeoff
+	return e >= -256 && e <= -247 || e >= -32 && e <= -23 || e >= -512 && e <= -412 || e >= -768 && e <= -763
+}
+
+// errRFBHeaderTooLong is returned for a message header longer than any
+// the filter expects, rather than buffering it.
+var errRFBHeaderTooLong = errors.New("RFB message header too long")
+
+// rfbFilter drops clipboard messages from an RFB connection: ClientCutText
+// from the client while pasting in is blocked, and ServerCutText from the
+// server while copying out is. For a view-only client it drops all input,
+// and otherwise reports the client's keyboard and pointer input, for the
+// idle lock. RFB has no framing, so the filter follows
+// both directions of the connection message by message: the handshake,
+// to learn the protocol version, security type and pixel format, and then
+// every message, including every rectangle of every framebuffer update
+// when copying out is blocked. Messages the filter cannot follow are an
+// error, and the proxy closes the connection rather than relay a stream
+// whose clipboard messages it cannot see.
+type rfbFilter struct {
+	onBlocked ClipboardBlockedFunc
+	onInput   func()
+
+	mu       sync.Mutex // guards everything below; held while filtering
+	input    bool       // the data being filtered holds input
+	viewOnly bool       // the client's input is dropped
+	pasteIn  bool       // ClientCutText is relayed
+	copyOut  bool       // ServerCutText is relayed
+	minor    int        // protocol minor version: 3, 7 or 8
+	security int        // security type
+	pf       pixelFormat
+	client   rfbStream
+	server   rfbStream
+}
+
+// pixelFormat is the part of an RFB pixel format that sizes pixel data.
+type pixelFormat struct {
+	bytesPerPixel int
+	tightPixel    int // bytes per pixel in Tight rectangles
+}
+
+func parsePixelFormat(b []byte) pixelFormat {
+	pf := pixelFormat{bytesPerPixel: int(b[0]) / 8}
+	pf.tightPixel = pf.bytesPerPixel
+	depth, trueColour := b[1], b[3] != 0
+	redMax, greenMax, blueMax := binary.BigEndian.Uint16(b[4:]), binary.BigEndian.Uint16(b[6:]), binary.BigEndian.Uint16(b[8:])
+	if trueColour && pf.bytesPerPixel == 4 && depth == 24 && redMax == 255 && greenMax == 255 && blueMax == 255 {
+		pf.tightPixel = 3
+	}
+	return pf
+}
+
+// newRFBFilter returns a filter for policy that reports input to onInput,
+// or drops it if viewOnly. It returns nil if there is nothing to filter:
+// policy allows clipboard data both ways, onInput is nil and the client
+// may send input.
+func newRFBFilter(policy db.ClipboardPolicy, onBlocked ClipboardBlockedFunc, onInput func(), viewOnly bool) *rfbFilter {
+	if policy.AllowsPasteIn() && policy.AllowsCopyOut() && onInput == nil && !viewOnly {
+		return nil
+	}
+	f := &rfbFilter{
+		onBlocked: onBlocked,
+		onInput:   onInput,
+		viewOnly:  viewOnly,
+		pasteIn:   policy.AllowsPasteIn(),
+		copyOut:   policy.AllowsCopyOut(),
+	}
+	f.client.state = f.clientVersion
+	f.server.state = f.serverVersion
+	return f
+}
+
+// filterClient returns the bytes of data from the client to relay to the
+// server.
+func (f *rfbFilter) filterClient(data []byte) ([]byte, error) {
+	return f.filter(&f.client, data, true)
+}
+
+// filterServer returns the bytes of data from the server to relay to the
+// client.
+func (f *rfbFilter) filterServer(data []byte) ([]byte, error) {
+	return f.filter(&f.server, data, false)
+}
+
+func (f *rfbFilter) filter(s *rfbStream, data []byte, fromClient bool) ([]byte, error) {
+	f.mu.Lock()
+	out, blocked, err := s.filter(data)
+	input := f.input
+	f.input = false
+	f.mu.Unlock()
+	if f.onBlocked != nil {
+		for range blocked {
+			f.onBlocked(fromClient)
+		}
+	}
+	if input && f.onInput != nil {
+		f.onInput()
+	}
+	return out, err
+}
+
+// rfbState parses the message part at the start of b.
+type rfbState func(b []byte) (rfbStep, error)
+
+// rfbStep is the result of parsing a message part.
+type rfbStep struct {
+	n       int      // bytes of b parsed
+	skip    int      // bytes after those relayed without parsing
+	drop    bool     // drop the parsed and skipped bytes, a clipboard message
+	discard bool     // drop the parsed and skipped bytes, input from a view-only client
+	replace []byte   // relayed instead of the parsed bytes, if not nil
+	next    rfbState // nil waits for more bytes
+}
+
+// wait is the step of a state that needs more bytes.
+var wait = rfbStep{}
+
+// rfbStream is one direction of an RFB connection.
+type rfbStream struct {
+	state    rfbState
+	buf      []byte // bytes not parsed yet
+	skip     int    // bytes still to relay, or drop, without parsing
+	dropping bool
+}
+
+// filter returns the bytes of data to relay and the number of clipboard
+// messages dropped from them. A message part that does not fit in data is
+// held back until the rest of it arrives.
+func (s *rfbStream) filter(data []byte) ([]byte, int, error) {
+	if len(s.buf) > 0 {
+		data = append(s.buf, data...)
+		s.buf = nil
+	}
+	out := make([]byte, 0, len(data))
+	blocked := 0
+	for len(data) > 0 {
+		if s.skip > 0 {
+			k := min(s.skip, len(data))
+			if !s.dropping {
+				out = append(out, data[:k]...)
+			}
+			s.skip -= k
+			data = data[k:]
+			continue
+		}
+		step, err := s.state(data)
+		if err != nil {
+			return nil, blocked, err
+		}
+		if step.next == nil {
+			if len(data) > maxRFBMessageHeader {
+				return nil, blocked, errRFBHeaderTooLong
+			}
+			s.buf = append([]byte(nil), data...)
+			break
+		}
+		switch {
+		case step.drop:
+			blocked++
+		case step.discard:
+		case step.replace != nil:
+			out = append(out, step.replace...)
+		default:
+			out = append(out, data[:step.n]...)
+		}
+		data = data[step.n:]
+		s.skip, s.dropping, s.state = step.skip, step.drop || step.discard, step.next
+	}
+	return out, blocked, nil
+}
+
+// relayRest relays everything that follows without parsing it.
+func relayRest(b []byte) (rfbStep, error) {
+	return rfbStep{n: len(b), next: relayRest}, nil
+}
+
+// reasonThen parses a failure reason, after which the server closes the
+// connection.
+func reasonThen(b []byte) (rfbStep, error) {
+	if len(b) < 4 {
+		return wait, nil
+	}
+	return rfbStep{n: 4, skip: int(binary.BigEndian.Uint32(b)), next: relayRest}, nil
+}
+
+// parseVersion returns the minor version of a ProtocolVersion message.
+// Versions other than 3.7 and 3.8 are treated as 3.3, as the RFB
+// specification requires; later versions as 3.8.
+func parseVersion(b []byte) (int, error) {
+	if string(b[:4]) != "RFB " || b[11] != '\n' {
+		return 0, fmt.Errorf("invalid RFB protocol version %q", b[:12])
+	}
+	minor, err := strconv.Atoi(string(b[8:11]))
+	if err != nil {
+		return 0, fmt.Errorf("invalid RFB protocol version %q", b[:12])
+	}
+	switch {
+	case minor >= 8:
+		return 8, nil
+	case minor == 7:
+		return 7, nil
+	}
+	return 3, nil
+}
+
+// The client's handshake: ProtocolVersion, the security type (3.7 and
+// later), the security handshake, and ClientInit.
+
+func (f *rfbFilter) clientVersion(b []byte) (rfbStep, error) {
+	if len(b) < 12 {
+		return wait, nil
+	}
+	minor, err := parseVersion(b)
+	if err != nil {
+		return wait, err
+	}
+	f.minor = minor
+	if minor < 7 {
+		// The server chose the security type
+		return rfbStep{n: 12, next: f.clientSecurity}, nil
+	}
+	return rfbStep{n: 12, next: f.clientSecurityType}, nil
+}
+
+func (f *rfbFilter) clientSecurityType(b []byte) (rfbStep, error) {
+	f.security = int(b[0])
+	return rfbStep{n: 1, next: f.clientSecurity}, nil
+}
+
+func (f *rfbFilter) clientSecurity(b []byte) (rfbStep, error) {
+	switch f.security {
+	case rfbSecurityNone:
+		return rfbStep{next: f.clientInit}, nil
+	case rfbSecurityVNC:
+		// The response to the server's challenge
+		return rfbStep{skip: 16, next: f.clientInit}, nil
+	}
+	return wait, fmt.Errorf("unsupported RFB security type %d", f.security)
+}
+
+func (f *rfbFilter) clientInit(b []byte) (rfbStep, error) {
+	return rfbStep{n: 1, next: f.clientMessage}, nil
+}
+
+// clientMessage parses a message from the client.
+func (f *rfbFilter) clientMessage(b []byte) (rfbStep, error) {
+	switch t := b[0]; t {
+	case 0: // SetPixelFormat
+		if len(b) < 20 {
+			return wait, nil
+		}
+		f.pf = parsePixelFormat(b[4:20])
+		return rfbStep{n: 20, next: f.clientMessage}, nil
+	case 2: // SetEncodings
+		if len(b) < 4 {
+			return wait, nil
+		}
+		n := 4 + 4*int(binary.BigEndian.Uint16(b[2:]))
+		if len(b) < n {
+			return wait, nil
+		}
+		step := rfbStep{n: n, next: f.clientMessage}
+		if !f.copyOut {
+			step.replace = framedEncodings(b[:n])
+		}
+		return step, nil
+	case 3: // FramebufferUpdateRequest
+		return rfbStep{skip: 10, next: f.clientMessage}, nil
+	case 4: // KeyEvent
+		return f.inputStep(rfbStep{skip: 8, next: f.clientMessage}), nil
+	case 5: // PointerEvent
+		return f.inputStep(rfbStep{skip: 6, next: f.clientMessage}), nil
+	case 6: // ClientCutText
+		if len(b) < 8 {
+			return wait, nil
+		}
+		step := cutText(b, f.pasteIn, f.clientMessage)
+		if f.viewOnly {
+			step.drop, step.discard = false, true
+		}
+		return step, nil
+	case 150: // EnableContinuousUpdates
+		return rfbStep{skip: 10, next: f.clientMessage}, nil
+	case 248: // ClientFence
+		if len(b) < 9 {
+			return wait, nil
+		}
+		return rfbStep{n: 9, skip: int(b[8]), next: f.clientMessage}, nil
+	case 250: // xvp
+		return rfbStep{skip: 4, discard: f.viewOnly, next: f.clientMessage}, nil
+	case 251: // SetDesktopSize
+		if len(b) < 8 {
+			return wait, nil
+		}
+		return rfbStep{n: 8, skip: 16 * int(b[6]), discard: f.viewOnly, next: f.clientMessage}, nil
+	case 255: // QEMU
+		if len(b) < 2 {
+			return wait, nil
+		}
+		if b[1] == 0 { // Extended key event
+			return f.inputStep(rfbStep{skip: 12, next: f.clientMessage}), nil
+		}
+		return wait, fmt.Errorf("unsupported RFB QEMU client message %d", b[1])
+	default:
+		return wait, fmt.Errorf("unsupported RFB client message type %d", t)
+	}
+}
+
+// inputStep is step, a keyboard or pointer event, discarded for a
+// view-only client and otherwise reported as input.
+func (f *rfbFilter) inputStep(step rfbStep) rfbStep {
+	if f.viewOnly {
+		step.discard = true
+	} else {
+		f.input = true
+	}
+	return step
+}
+
+// cutText parses a ClientCutText or ServerCutText message, whose header b
+// starts with, dropping it unless allow. A negative length is the length
+// of an extended clipboard message.
+func cutText(b []byte, allow bool, next rfbState) rfbStep {
+	length := int64(int32(binary.BigEndian.Uint32(b[4:])))
+	if length < 0 {
+		length = -length
+	}
+	return rfbStep{n: 8, skip: int(length), drop: !allow, next: next}
+}
+
+// framedEncodings returns a SetEncodings message with only the encodings
+// of msg that framedEncoding accepts.
+func framedEncodings(msg []byte) []byte {
+	out := append([]byte(nil), msg[:4]...)
+	n := 0
+	for i := 4; i+4 <= len(msg); i += 4 {
+		if framedEncoding(int32(binary.BigEndian.Uint32(msg[i:]))) {
+			out = append(out, msg[i:i+4]...)
+			n++
+		}
+	}
+	binary.BigEndian.PutUint16(out[2:], uint16(n))
+	return out
+}
+
+// The server's handshake: ProtocolVersion, the security types, the
+// security handshake and result, and ServerInit.
+
+func (f *rfbFilter) serverVersion(b []byte) (rfbStep, error) {
+	if len(b) < 12 {
+		return wait, nil
+	}
+	if _, err := parseVersion(b); err != nil {
+		return wait, err
+	}
+	return rfbStep{n: 12, next: f.serverSecurityTypes}, nil
+}
+
+// serverSecurityTypes parses the security types the server offers, or, in
+// version 3.3, the one it chose. It only gets data once the client has
+// sent its version.
+func (f *rfbFilter) serverSecurityTypes(b []byte) (rfbStep, error) {
+	if f.minor < 7 {
+		if len(b) < 4 {
+			return wait, nil
+		}
+		f.security = int(binary.BigEndian.Uint32(b))
+		if f.security == 0 {
+			return rfbStep{n: 4, next: reasonThen}, nil
+		}
+		return rfbStep{n: 4, next: f.serverSecurity}, nil
+	}
+	if b[0] == 0 {
+		return rfbStep{n: 1, next: reasonThen}, nil
+	}
+	return rfbStep{n: 1, skip: int(b[0]), next: f.serverSecurity}, nil
+}
+
+// serverSecurity parses the server's side of the security handshake. It
+// only gets data once the client has chosen the security type.
+func (f *rfbFilter) serverSecurity(b []byte) (rfbStep, error) {
+	switch f.security {
+	case rfbSecurityNone:
+		if f.minor < 8 {
+			return rfbStep{next: f.serverInit}, nil
+		}
+		return rfbStep{next: f.serverSecurityResult}, nil
+	case rfbSecurityVNC:
+		// The challenge
+		return rfbStep{skip: 16, next: f.serverSecurityResult}, nil
+	}
+	return wait, fmt.Errorf("unsupported RFB security type %d", f.security)
+}
+
+func (f *rfbFilter) serverSecurityResult(b []byte) (rfbStep, error) {
+	if len(b) < 4 {
+		return wait, nil
+	}
+	if binary.BigEndian.Uint32(b) == 0 {
+		return rfbStep{n: 4, next: f.serverInit}, nil
+	}
+	if f.minor < 8 {
+		return rfbStep{n: 4, next: relayRest}, nil
+	}
+	return rfbStep{n: 4, next: reasonThen}, nil
+}
+
+// serverInit parses ServerInit. While copying out is allowed, the server's
+// messages need not be followed after it.
+func (f *rfbFilter) serverInit(b []byte) (rfbStep, error) {
+	if len(b) < 24 {
+		return wait, nil
+	}
+	f.pf = parsePixelFormat(b[4:20])
+	next := f.serverMessage
+	if f.copyOut {
+		next = relayRest
+	}
+	return rfbStep{n: 24, skip: int(binary.BigEndian.Uint32(b[20:])), next: next}, nil
+}
+
+// serverMessage parses a message from the server.
+func (f *rfbFilter) serverMessage(b []byte) (rfbStep, error) {
+	switch t := b[0]; t {
+	case 0: // FramebufferUpdate
+		if len(b) < 4 {
+			return wait, nil
+		}
+		return rfbStep{n: 4, next: f.rects(int(binary.BigEndian.Uint16(b[2:])))}, nil
+	case 1: // SetColourMapEntries
+		if len(b) < 6 {
+			return wait, nil
+		}
+		return rfbStep{n: 6, skip: 6 * int(binary.BigEndian.Uint16(b[4:])), next: f.serverMessage}, nil
+	case 2: // Bell
+		return rfbStep{n: 1, next: f.serverMessage}, nil
+	case 3: // ServerCutText
+		if len(b) < 8 {
+			return wait, nil
+		}
+		return cutText(b, f.copyOut, f.serverMessage), nil
+	case 150: // EndOfContinuousUpdates
+		return rfbStep{n: 1, next: f.serverMessage}, nil
+	case 248: // ServerFence
+		if len(b) < 9 {
+			return wait, nil
+		}
+		return rfbStep{n: 9, skip: int(b[8]), next: f.serverMessage}, nil
+	case 250: // xvp
+		return rfbStep{skip: 4, next: f.serverMessage}, nil
+	default:
+		return wait, fmt.Errorf("unsupported RFB server message type %d", t)
+	}
+}
+
+// rects parses the remaining n rectangles of a framebuffer update.
+func (f *rfbFilter) rects(n int) rfbState {
+	return func(b []byte) (rfbStep, error) {
+		if n == 0 {
+			return rfbStep{next: f.serverMessage}, nil
+		}
+		if len(b) < 12 {
+			return wait, nil
+		}
+		w, h := int(binary.BigEndian.Uint16(b[4:])), int(binary.BigEndian.Uint16(b[6:]))
+		next := f.rects(n - 1)
+		bpp := f.pf.bytesPerPixel
+		switch e := int32(binary.BigEndian.Uint32(b[8:])); e {
+		case encodingRaw:
+			return rfbStep{n: 12, skip: w * h * bpp, next: next}, nil
+		case encodingCopyRect:
+			return rfbStep{n: 12, skip: 4, next: next}, nil
+		case encodingRRE:
+			return rfbStep{n: 12, next: rre(bpp, next)}, nil
+		case encodingHextile:
+			return rfbStep{n: 12, next: f.hextile(w, h, 0, next)}, nil
+		case encodingTight, encodingTightPNG:
+			return rfbStep{n: 12, next: f.tight(w, h, next)}, nil
+		case encodingZRLE:
+			return rfbStep{n: 12, next: lengthPrefixed(4, next)}, nil
+		case encodingH264: // length, flags
+			return rfbStep{n: 12, next: lengthPrefixed(8, next)}, nil
+		case encodingCursor:
+			return rfbStep{n: 12, skip: w*h*bpp + (w+7)/8*h, next: next}, nil
+		case encodingDesktopSize:
+			return rfbStep{n: 12, next: next}, nil
+		case encodingLastRect:
+			return rfbStep{n: 12, next: f.serverMessage}, nil
+		case encodingDesktopName:
+			return rfbStep{n: 12, next: lengthPrefixed(4, next)}, nil
+		case encodingExtendedDesktopSize:
+			return rfbStep{n: 12, next: func(b []byte) (rfbStep, error) {
+				if len(b) < 4 {
+					return wait, nil
+				}
+				return rfbStep{n: 4, skip: 16 * int(b[0]), next: next}, nil
+			}}, nil
+		default:
+			return wait, fmt.Errorf("unsupported RFB encoding %d", e)
+		}
+	}
+}
+
+// lengthPrefixed parses a header of size bytes that starts with the
+// length of the data after it.
+func lengthPrefixed(size int, next rfbState) rfbState {
+	return func(b []byte) (rfbStep, error) {
+		if len(b) < size {
+			return wait, nil
+		}
+		return rfbStep{n: size, skip: int(binary.BigEndian.Uint32(b)), next: next}, nil
+	}
+}
+
+// rre parses an RRE rectangle: the number of subrectangles, the
+// background pixel, and the subrectangles.
+func rre(bpp int, next rfbState) rfbState {
+	return func(b []byte) (rfbStep, error) {
+		if len(b) < 4+bpp {
+			return wait, nil
+		}
+		return rfbStep{n: 4 + bpp, skip: int(binary.BigEndian.Uint32(b)) * (bpp + 8), next: next}, nil
+	}
+}
+
+// hextile parses the tiles of a w by h Hextile rectangle from tile i on.
+func (f *rfbFilter) hextile(w, h, i int, next rfbState) rfbState {
+	cols, rows := (w+15)/16, (h+15)/16
+	return func(b []byte) (rfbStep, error) {
+		if i >= cols*rows {
+			return rfbStep{next: next}, nil
+		}
+		tw, th := min(16, w-i%cols*16), min(16, h-i/cols*16)
+		bpp := f.pf.bytesPerPixel
+		sub := b[0]
+		if sub&hextileRaw != 0 {
+			return rfbStep{n: 1, skip: tw * th * bpp, next: f.hextile(w, h, i+1, next)}, nil
+		}
+		n := 1
+		if sub&hextileBackgroundSpecified != 0 {
+			n += bpp
+		}
+		if sub&hextileForegroundSpecified != 0 {
+			n += bpp
+		}
+		if sub&hextileAnySubrects == 0 {
+			if len(b) < n {
+				return wait, nil
+			}
+			return rfbStep{n: n, next: f.hextile(w, h, i+1, next)}, nil
+		}
+		if len(b) < n+1 {
+			return wait, nil
+		}
+		subrect := 2
+		if sub&hextileSubrectsColoured != 0 {
+			subrect += bpp
+		}
+		return rfbStep{n: n + 1, skip: int(b[n]) * subrect, next: f.hextile(w, h, i+1, next)}, nil
+	}
+}
+
+// tight parses a w by h Tight or TightPNG rectangle.
+func (f *rfbFilter) tight(w, h int, next rfbState) rfbState {
+	return func(b []byte) (rfbStep, error) {
+		tpixel := f.pf.tightPixel
+		control := b[0] >> 4
+		switch {
+		case control == 0x08: // Fill
+			return rfbStep{n: 1, skip: tpixel, next: next}, nil
+		case control == 0x09, control == 0x0A: // JPEG, PNG
+			return compactLength(b, 1, next), nil
+		case control&0x08 != 0:
+			return wait, fmt.Errorf("invalid Tight compression control %#x", b[0])
+		}
+
+		// Basic compression, with an optional filter
+		n, size := 1, w*h*tpixel
+		if control&0x04 != 0 {
+			if len(b) < 2 {
+				return wait, nil
+			}
+			n = 2
+			switch b[1] {
+			case 0: // Copy
+			case 1: // Palette
+				if len(b) < 3 {
+					return wait, nil
+				}
+				colors := int(b[2]) + 1
+				n = 3 + colors*tpixel
+				size = w * h
+				if colors == 2 {
+					size = (w + 7) / 8 * h
+				}
+			case 2: // Gradient
+			default:
+				return wait, fmt.Errorf("invalid Tight filter %d", b[1])
+			}
+		}
+		if len(b) < n {
+			return wait, nil
+		}
+		if size < 12 {
+			// Too small to be compressed
+			return rfbStep{n: n, skip: size, next: next}, nil
+		}
+		return compactLength(b, n, next), nil
+	}
+}
+
+// compactLength parses the Tight compact length at b[at:], after the rest
+// of a rectangle's header, and skips the data it is the length of. The
+// length takes one to three bytes, seven bits in each byte but the last.
+func compactLength(b []byte, at int, next rfbState) rfbStep {
+	length := 0
+	for i := 0; i < 3; i++ {
+		if len(b) <= at+i {
+			return wait
+		}
+		c := int(b[at+i])
+		if i == 2 {
+			length |= c << 14
+			break
+		}
+		length |= (c & 0x7F) << (7 * i)
+		if c&0x80 == 0 {
+			return rfbStep{n: at + i + 1, skip: length, next: next}
+		}
+	}
+	return rfbStep{n: at + 3, skip: length, next: next}
+}
@@ -0,0 +1,268 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/db"
+)
+
+// rfbMessage builds an RFB message from bytes and big-endian integers.
+func rfbMessage(parts ...any) []byte {
+	var buf bytes.Buffer
+	for _, p := range parts {
+		switch v := p.(type) {
+		case string:
+			buf.WriteString(v)
+		case []byte:
+			buf.Write(v)
+		default:
+			binary.Write(&buf, binary.BigEndian, v)
+		}
+	}
+	return buf.Bytes()
+}
+
+// pixelFormat32 is the 32 bits per pixel true colour format noVNC asks for.
+var pixelFormat32 = rfbMessage(uint8(32), uint8(24), uint8(0), uint8(1), uint16(255), uint16(255), uint16(255), []byte{16, 8, 0, 0, 0, 0})
+
+// rfbConversation is an RFB 3.8 connection with no authentication, and a
+// clipboard message each way.
+type rfbConversation struct {
+	client, server         [][]byte // messages in the order they are sent
+	clientCut, serverCut   int      // index of the clipboard messages
+	setEncodings, wantEncs int      // index of SetEncodings, and the encodings left in it
+}
+
+func newRFBConversation() rfbConversation {
+	var c rfbConversation
+	c.server = append(c.server, []byte("RFB 003.008\n"))
+	c.client = append(c.client, []byte("RFB 003.008\n"))
+	c.server = append(c.server, []byte{1, rfbSecurityNone})
+	c.client = append(c.client, []byte{rfbSecurityNone})
+	c.server = append(c.server, rfbMessage(uint32(0)))
+	c.client = append(c.client, []byte{1}) // ClientInit, shared
+	c.server = append(c.server, rfbMessage(uint16(64), uint16(32), pixelFormat32, uint32(4), "test"))
+
+	c.client = append(c.client, rfbMessage(uint8(0), []byte{0, 0, 0}, pixelFormat32))
+	// Tight, JPEG (not followed), ZRLE, Cursor, quality level 6
+	c.setEncodings = len(c.client)
+	c.client = append(c.client, rfbMessage(uint8(2), uint8(0), uint16(5), int32(7), int32(21), int32(16), int32(-239), int32(-26)))
+	c.wantEncs = 4
+	c.client = append(c.client, rfbMessage(uint8(3), uint8(0), uint16(0), uint16(0), uint16(64), uint16(32)))
+	c.clientCut = len(c.client)
+	c.client = append(c.client, rfbMessage(uint8(6), []byte{0, 0, 0}, uint32(6), "secret"))
+	c.client = append(c.client, rfbMessage(uint8(4), uint8(1), uint16(0), uint32(0x61)))
+
+	c.server = append(c.server, rfbMessage(uint8(0), uint8(0), uint16(6),
+		// Raw 2x2
+		uint16(0), uint16(0), uint16(2), uint16(2), int32(encodingRaw), bytes.Repeat([]byte{1}, 2*2*4),
+		// Hextile 20x16: a raw tile, then a tile with a background and two coloured subrects
+		uint16(0), uint16(0), uint16(20), uint16(16), int32(encodingHextile),
+		uint8(hextileRaw), bytes.Repeat([]byte{2}, 16*16*4),
+		uint8(hextileBackgroundSpecified|hextileAnySubrects|hextileSubrectsColoured), []byte{0, 0, 0, 0}, uint8(2), bytes.Repeat([]byte{3}, 2*(4+2)),
+		// Tight fill, with 3 byte pixels
+		uint16(0), uint16(0), uint16(8), uint16(8), int32(encodingTight), uint8(0x80), []byte{4, 4, 4},
+		// Tight basic 16x100 with a two colour palette: 200 bytes of data after a two byte compact length
+		uint16(0), uint16(0), uint16(16), uint16(100), int32(encodingTight), uint8(0x40), uint8(1), uint8(1), []byte{5, 5, 5, 6, 6, 6}, []byte{0xC8, 0x01}, bytes.Repeat([]byte{7}, 200),
+		// ZRLE
+		uint16(0), uint16(0), uint16(8), uint16(8), int32(encodingZRLE), uint32(3), []byte{8, 8, 8},
+		// Cursor 9x2
+		uint16(0), uint16(0), uint16(9), uint16(2), int32(encodingCursor), bytes.Repeat([]byte{9}, 9*2*4+2*2),
+	))
+	c.serverCut = len(c.server)
+	c.server = append(c.server, rfbMessage(uint8(3), []byte{0, 0, 0}, uint32(4), "copy"))
+	c.server = append(c.server, []byte{2}) // Bell
+	return c
+}
+
+// relay passes the conversation through f, alternating directions the way
+// the handshake does, in chunks of chunk bytes, and returns what was relayed
+// each way.
+func (c rfbConversation) relay(t *testing.T, f *rfbFilter, chunk int) (client, server []byte) {
+	t.Helper()
+	feed := func(msgs [][]byte, filter func([]byte) ([]byte, error), out *[]byte) {
+		data := bytes.Join(msgs, nil)
+		for len(data) > 0 {
+			n := min(chunk, len(data))
+			got, err := filter(data[:n])
+			if err != nil {
+				t.Fatalf("filter error: %v", err)
+			}
+			*out = append(*out, got...)
+			data = data[n:]
+		}
+	}
+	// The handshake takes turns, up to ServerInit and SetPixelFormat
+	for i := 0; i < 4; i++ {
+		feed(c.server[i:i+1], f.filterServer, &server)
+		feed(c.client[i:i+1], f.filterClient, &client)
+	}
+	feed(c.client[4:], f.filterClient, &client)
+	feed(c.server[4:], f.filterServer, &server)
+	return client, server
+}
+
+func TestRFBFilter_BlocksBothWays(t *testing.T) {
+	for _, chunk := range []int{1, 7, 1 << 20} {
+		c := newRFBConversation()
+		var blocked []bool
+		f := newRFBFilter(db.ClipboardNone, func(pasteIn bool) { blocked = append(blocked, pasteIn) }, nil, false)
+		client, server := c.relay(t, f, chunk)
+
+		wantClient := append([][]byte(nil), c.client...)
+		wantClient[c.clientCut] = nil
+		wantClient[c.setEncodings] = rfbMessage(uint8(2), uint8(0), uint16(c.wantEncs), int32(7), int32(16), int32(-239), int32(-26))
+		if want := bytes.Join(wantClient, nil); !bytes.Equal(client, want) {
+			t.Errorf("chunk %d: client relayed %x, want %x", chunk, client, want)
+		}
+		wantServer := append([][]byte(nil), c.server...)
+		wantServer[c.serverCut] = nil
+		if want := bytes.Join(wantServer, nil); !bytes.Equal(server, want) {
+			t.Errorf("chunk %d: server relayed %x, want %x", chunk, server, want)
+		}
+		if len(blocked) != 2 || !blocked[0] || blocked[1] {
+			t.Errorf("chunk %d: blocked = %v, want a paste in then a copy out", chunk, blocked)
+		}
+	}
+}
+
+func TestRFBFilter_BlocksPasteIn(t *testing.T) {
+	c := newRFBConversation()
+	f := newRFBFilter(db.ClipboardRead, nil, nil, false)
+	client, server := c.relay(t, f, 5)
+
+	// The server's messages and the client's encodings are left alone
+	wantClient := append([][]byte(nil), c.client...)
+	wantClient[c.clientCut] = nil
+	if want := bytes.Join(wantClient, nil); !bytes.Equal(client, want) {
+		t.Errorf("client relayed %x, want %x", client, want)
+	}
+	if want := bytes.Join(c.server, nil); !bytes.Equal(server, want) {
+		t.Errorf("server relayed %x, want %x", server, want)
+	}
+}
+
+func TestRFBFilter_VNCAuthentication(t *testing.T) {
+	f := newRFBFilter(db.ClipboardWrite, nil, nil, false)
+	challenge, response := bytes.Repeat([]byte{0xAA}, 16), bytes.Repeat([]byte{0xBB}, 16)
+	steps := []struct {
+		fromClient bool
+		data       []byte
+	}{
+		{false, []byte("RFB 003.008\n")},
+		{true, []byte("RFB 003.008\n")},
+		{false, []byte{2, rfbSecurityNone, rfbSecurityVNC}},
+		{true, []byte{rfbSecurityVNC}},
+		{false, challenge},
+		{true, response},
+		{false, rfbMessage(uint32(0))},
+		{true, []byte{1}},
+		{false, rfbMessage(uint16(64), uint16(32), pixelFormat32, uint32(0))},
+		{false, rfbMessage(uint8(3), []byte{0, 0, 0}, uint32(2), "hi")},
+	}
+	for i, s := range steps {
+		filter, want := f.filterServer, s.data
+		if s.fromClient {
+			filter = f.filterClient
+		}
+		if i == len(steps)-1 {
+			want = nil // ServerCutText
+		}
+		got, err := filter(s.data)
+		if err != nil {
+			t.Fatalf("step %d: filter error: %v", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("step %d: relayed %x, want %x", i, got, want)
+		}
+	}
+}
+
+func TestRFBFilter_Unfollowable(t *testing.T) {
+	tests := []struct {
+		name   string
+		server [][]byte
+		client [][]byte
+	}{
+		{
+			name:   "encrypted security type",
+			server: [][]byte{[]byte("RFB 003.008\n"), {1, 19}, {0, 2}},
+			client: [][]byte{[]byte("RFB 003.008\n"), {19}},
+		},
+		{
+			name:   "unknown server message",
+			server: [][]byte{[]byte("RFB 003.008\n"), {1, rfbSecurityNone}, rfbMessage(uint32(0), uint16(1), uint16(1), pixelFormat32, uint32(0), uint8(99))},
+			client: [][]byte{[]byte("RFB 003.008\n"), {rfbSecurityNone}, {1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newRFBFilter(db.ClipboardNone, nil, nil, false)
+			var err error
+			for i := 0; err == nil && i < max(len(tt.server), len(tt.client)); i++ {
+				if i < len(tt.server) {
+					_, err = f.filterServer(tt.server[i])
+				}
+				if err == nil && i < len(tt.client) {
+					_, err = f.filterClient(tt.client[i])
+				}
+			}
+			if err == nil {
+				t.Error("filter relayed a stream it cannot follow")
+			}
+		})
+	}
+}
+
+func TestRFBFilter_ReportsInput(t *testing.T) {
+	for _, chunk := range []int{1, 1 << 20} {
+		c := newRFBConversation()
+		inputs := 0
+		f := newRFBFilter(db.ClipboardBidirectional, nil, func() { inputs++ }, false)
+		if f == nil {
+			t.Fatal("newRFBFilter() = nil with an input callback")
+		}
+		client, server := c.relay(t, f, chunk)
+		if !bytes.Equal(client, bytes.Join(c.client, nil)) || !bytes.Equal(server, bytes.Join(c.server, nil)) {
+			t.Errorf("chunk %d: filter changed a stream it has no clipboard policy for", chunk)
+		}
+		// The conversation's only input is one KeyEvent
+		if inputs != 1 {
+			t.Errorf("chunk %d: input reported %d times, want 1", chunk, inputs)
+		}
+	}
+}
+
+func TestRFBFilter_ViewOnly(t *testing.T) {
+	for _, chunk := range []int{1, 1 << 20} {
+		c := newRFBConversation()
+		inputs, blocked := 0, 0
+		f := newRFBFilter(db.ClipboardBidirectional, func(bool) { blocked++ }, func() { inputs++ }, true)
+		client, server := c.relay(t, f, chunk)
+
+		// The clipboard message and the KeyEvent are dropped, and are neither
+		// input nor blocked clipboard transfers
+		wantClient := append([][]byte(nil), c.client...)
+		wantClient[c.clientCut] = nil
+		wantClient[len(wantClient)-1] = nil
+		if want := bytes.Join(wantClient, nil); !bytes.Equal(client, want) {
+			t.Errorf("chunk %d: client relayed %x, want %x", chunk, client, want)
+		}
+		if !bytes.Equal(server, bytes.Join(c.server, nil)) {
+			t.Errorf("chunk %d: filter changed the server's stream", chunk)
+		}
+		if inputs != 0 || blocked != 0 {
+			t.Errorf("chunk %d: inputs = %d, blocked = %d, want none", chunk, inputs, blocked)
+		}
+	}
+}
+
+func TestNewRFBFilter_Bidirectional(t *testing.T) {
+	for _, p := range []db.ClipboardPolicy{"", db.ClipboardBidirectional} {
+		if f := newRFBFilter(p, nil, nil, false); f != nil {
+			t.Errorf("newRFBFilter(%q, nil, nil, false) = %v, want nil", p, f)
+		}
+	}
+}
@@ -7,11 +7,14 @@ import (
 
 	"github.com/rjsadow/sortie/internal/db"
 	"github.com/rjsadow/sortie/internal/sessions"
+	"github.com/rjsadow/sortie/internal/streamstats"
 )
 
 // Handler handles WebSocket connections for sessions
 type Handler struct {
 	sessionManager *sessions.Manager
+	proxyOptions   ProxyOptions
+	stats          *streamstats.Tracker
 }
 
 // NewHandler creates a new WebSocket handler
@@ -21,6 +24,16 @@ func NewHandler(sessionManager *sessions.Manager) *Handler {
 	}
 }
 
+// SetProxyOptions sets the options session streams are relayed with.
+func (h *Handler) SetProxyOptions(opts ProxyOptions) {
+	h.proxyOptions = opts
+}
+
+// SetStreamStats sets the tracker session streams' stats are recorded to.
+func (h *Handler) SetStreamStats(t *streamstats.Tracker) {
+	h.stats = t
+}
+
 // ServeHTTP handles WebSocket upgrade requests for session connections
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Extract session ID from path: /ws/sessions/{id}
@@ -66,6 +79,16 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Proxying WebSocket for session %s to %s", sessionID, targetURL)
 
 	// Create and serve the proxy
-	proxy := NewProxy(targetURL)
+	proxy := NewProxyWithOptions(targetURL, h.proxyOptions)
+	proxy.stream = h.stats.Open(sessionID, streamstats.BackendVNC)
+	rule := clipboardRuleFrom(r.Context())
+	var onInput func()
+	if proxy.lock = idleLockFrom(r.Context()); proxy.lock != nil {
+		onInput = proxy.lock.Input
+	}
+	// Read-only shares and spectators (set by the gateway) send no input
+	viewOnly := r.URL.Query().Get("view_only") == "true"
+	proxy.filter = newRFBFilter(rule.policy, rule.onBlocked, onInput, viewOnly)
+	defer proxy.stream.Close()
 	proxy.ServeHTTP(w, r)
 }
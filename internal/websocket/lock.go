@@ -0,0 +1,21 @@
+package websocket
+
+import (
+	"context"
+
+	"github.com/rjsadow/sortie/internal/idlelock"
+)
+
+type idleLockKey struct{}
+
+// WithIdleLock returns a context that makes the VNC handler report the
+// connecting client's keyboard and pointer input to lock, and close the
+// client with idlelock.CloseCode once lock locks the session.
+func WithIdleLock(ctx context.Context, lock *idlelock.Watcher) context.Context {
+	return context.WithValue(ctx, idleLockKey{}, lock)
+}
+
+func idleLockFrom(ctx context.Context) *idlelock.Watcher {
+	lock, _ := ctx.Value(idleLockKey{}).(*idlelock.Watcher)
+	return lock
+}
@@ -5,43 +5,114 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"slices"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/rjsadow/sortie/internal/chaos"
+	"github.com/rjsadow/sortie/internal/idlelock"
+	"github.com/rjsadow/sortie/internal/streamstats"
 )
 
+// vncSubprotocol is the WebSocket subprotocol noVNC and websockify speak.
+const vncSubprotocol = "binary"
+
+// upgrader accepts clients from the origin the request was sent to; the
+// proxy's options may allow others.
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  4096,
 	WriteBufferSize: 4096,
-	CheckOrigin: func(r *http.Request) bool {
-		// Allow all origins for development
-		// In production, this should be restricted
-		return true
-	},
-	Subprotocols: []string{"binary"}, // VNC/noVNC requires binary subprotocol
+	Subprotocols:    []string{vncSubprotocol},
+}
+
+// DefaultMaxBatchSize is the batch size the gateway relays VNC streams
+// with.
+const DefaultMaxBatchSize = 256 * 1024
+
+// relayQueueLen is how many messages from the VNC server may wait for the
+// client before reads from the server block.
+const relayQueueLen = 64
+
+// ProxyOptions tune how the proxy relays the VNC server's messages to the
+// client. The zero value relays each message as is.
+type ProxyOptions struct {
+	// CompressionLevel enables permessage-deflate on the client connection
+	// at the given level, from 1 (fastest) to 9 (smallest). 0 disables
+	// it. Compression is only used when the client offers it.
+	CompressionLevel int
+	// MaxBatchSize is the most bytes of binary messages coalesced into one
+	// message when the client falls behind the server. 0 disables
+	// batching.
+	MaxBatchSize int
+	// CheckOrigin reports whether a client's Origin header may connect.
+	// nil allows only the origin the request was sent to.
+	CheckOrigin func(r *http.Request) bool
+	// MaxMessageSize is the largest message in bytes the client may send;
+	// a client sending a larger one is disconnected. 0 disables the limit.
+	MaxMessageSize int64
+	// IdleTimeout disconnects a client that sends nothing, not even an
+	// answer to the proxy's pings, for this long. 0 disables it.
+	IdleTimeout time.Duration
 }
 
+// streamPingInterval is how often the client is pinged to measure the
+// round-trip time when stream stats are recorded.
+var streamPingInterval = 5 * time.Second
+
 // Proxy handles bidirectional WebSocket proxying
 type Proxy struct {
 	targetURL string
+	opts      ProxyOptions
+	stream    *streamstats.Stream // optional stream stats recorder
+	filter    *rfbFilter          // optional clipboard policy and input filter
+	lock      *idlelock.Watcher   // optional idle lock that closes the client
 }
 
 // NewProxy creates a new WebSocket proxy
 func NewProxy(targetURL string) *Proxy {
+	return NewProxyWithOptions(targetURL, ProxyOptions{})
+}
+
+// NewProxyWithOptions creates a new WebSocket proxy that relays with the
+// given options.
+func NewProxyWithOptions(targetURL string, opts ProxyOptions) *Proxy {
 	return &Proxy{
 		targetURL: targetURL,
+		opts:      opts,
 	}
 }
 
 // ServeHTTP upgrades the connection and starts proxying
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Upgrade the client connection
-	clientConn, err := upgrader.Upgrade(w, r, nil)
+	// A client that asks for subprotocols must be able to speak VNC's;
+	// noVNC may ask for none
+	if !offersSubprotocol(r, vncSubprotocol) {
+		http.Error(w, "Unsupported WebSocket subprotocol", http.StatusBadRequest)
+		return
+	}
+
+	// Upgrade the client connection, offering permessage-deflate when
+	// compression is on. A disallowed origin is refused with 403.
+	u := upgrader
+	u.CheckOrigin = p.opts.CheckOrigin
+	u.EnableCompression = p.opts.CompressionLevel > 0
+	clientConn, err := u.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("Failed to upgrade client connection: %v", err)
 		return
 	}
 	defer clientConn.Close()
+	if u.EnableCompression {
+		if err := clientConn.SetCompressionLevel(p.opts.CompressionLevel); err != nil {
+			log.Printf("Invalid WebSocket compression level %d: %v", p.opts.CompressionLevel, err)
+			return
+		}
+	}
+	if p.opts.MaxMessageSize > 0 {
+		clientConn.SetReadLimit(p.opts.MaxMessageSize)
+	}
 
 	// Parse the target URL
 	targetURL, err := url.Parse(p.targetURL)
@@ -54,7 +125,7 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	dialer := websocket.Dialer{
 		ReadBufferSize:  4096,
 		WriteBufferSize: 4096,
-		Subprotocols:    []string{"binary"},
+		Subprotocols:    []string{vncSubprotocol},
 	}
 
 	targetConn, _, err := dialer.Dial(targetURL.String(), nil)
@@ -65,6 +136,11 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	defer targetConn.Close()
 
+	if p.stream != nil || p.opts.IdleTimeout > 0 {
+		stop := p.pingClient(clientConn)
+		defer stop()
+	}
+
 	// Start bidirectional proxying
 	var wg sync.WaitGroup
 	errCh := make(chan error, 2)
@@ -73,7 +149,7 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		if err := proxyMessages(clientConn, targetConn); err != nil {
+		if err := proxyMessages(clientConn, targetConn, p.filter, p.opts.IdleTimeout); err != nil {
 			errCh <- err
 		}
 	}()
@@ -82,7 +158,7 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		if err := proxyMessages(targetConn, clientConn); err != nil {
+		if err := relayToClient(targetConn, clientConn, p.opts.MaxBatchSize, p.stream, p.filter); err != nil {
 			errCh <- err
 		}
 	}()
@@ -93,19 +169,44 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		close(errCh)
 	}()
 
-	// Log first error
-	if err := <-errCh; err != nil && !isCloseError(err) {
-		log.Printf("WebSocket proxy error: %v", err)
+	// Log first error, or close the client once the session is locked
+	var locked <-chan struct{}
+	if p.lock != nil {
+		locked = p.lock.Locked()
+	}
+	select {
+	case err := <-errCh:
+		if err != nil && !isCloseError(err) {
+			log.Printf("WebSocket proxy error: %v", err)
+		}
+	case <-locked:
+		clientConn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(idlelock.CloseCode, idlelock.CloseReason), time.Now().Add(time.Second))
 	}
 }
 
-// proxyMessages copies messages from src to dst
-func proxyMessages(src, dst *websocket.Conn) error {
+// proxyMessages copies messages from src to dst through filter, which may
+// be nil. With idleTimeout > 0, src is disconnected once it sends nothing
+// for that long.
+func proxyMessages(src, dst *websocket.Conn, filter *rfbFilter, idleTimeout time.Duration) error {
 	for {
+		if err := extendReadDeadline(src, idleTimeout); err != nil {
+			return err
+		}
 		messageType, message, err := src.ReadMessage()
 		if err != nil {
 			return err
 		}
+		if chaos.DropFrame() {
+			continue
+		}
+		if filter != nil && messageType == websocket.BinaryMessage {
+			if message, err = filter.filterClient(message); err != nil {
+				return err
+			}
+			if len(message) == 0 {
+				continue
+			}
+		}
 
 		if err := dst.WriteMessage(messageType, message); err != nil {
 			return err
@@ -113,6 +214,175 @@ func proxyMessages(src, dst *websocket.Conn) error {
 	}
 }
 
+// pingClient pings the client every streamPingInterval, or twice per idle
+// timeout if that is more often. Its pongs keep the connection from idling
+// out and give the round-trip time recorded to the stream. It returns a
+// function that stops pinging.
+func (p *Proxy) pingClient(conn *websocket.Conn) (stop func()) {
+	interval := streamPingInterval
+	if idle := p.opts.IdleTimeout; idle > 0 && idle/2 < interval {
+		interval = idle / 2
+	}
+	conn.SetPongHandler(func(data string) error {
+		p.stream.Pong(data)
+		return extendReadDeadline(conn, p.opts.IdleTimeout)
+	})
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for seq := 1; ; seq++ {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			id := strconv.Itoa(seq)
+			p.stream.Ping(id)
+			if err := conn.WriteControl(websocket.PingMessage, []byte(id), time.Now().Add(interval)); err != nil {
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// extendReadDeadline gives conn idleTimeout more to send something. It does
+// nothing when idleTimeout is 0.
+func extendReadDeadline(conn *websocket.Conn, idleTimeout time.Duration) error {
+	if idleTimeout <= 0 {
+		return nil
+	}
+	return conn.SetReadDeadline(time.Now().Add(idleTimeout))
+}
+
+// offersSubprotocol reports whether a client asks for no subprotocol or
+// includes protocol among those it asks for.
+func offersSubprotocol(r *http.Request, protocol string) bool {
+	offered := websocket.Subprotocols(r)
+	return len(offered) == 0 || slices.Contains(offered, protocol)
+}
+
+// relayMessage is a message read from the VNC server.
+type relayMessage struct {
+	messageType int
+	data        []byte
+}
+
+// relayToClient copies messages from the VNC server to the client,
+// recording each message as a frame in stream, which may be nil, and
+// dropping the clipboard messages filter, which may be nil, blocks. With
+// maxBatch > 0, binary messages that queue up while the client is slower
+// than the server are coalesced into one message of up to maxBatch bytes.
+// RFB is a byte stream, so noVNC reads coalesced messages the same as the
+// originals. Messages are never held back waiting for more, so batching
+// adds no latency when the client keeps up.
+func relayToClient(src, dst *websocket.Conn, maxBatch int, stream *streamstats.Stream, filter *rfbFilter) error {
+	read := func() (int, []byte, error) {
+		for {
+			messageType, message, err := src.ReadMessage()
+			if err != nil || filter == nil || messageType != websocket.BinaryMessage {
+				return messageType, message, err
+			}
+			if message, err = filter.filterServer(message); err != nil || len(message) > 0 {
+				return messageType, message, err
+			}
+		}
+	}
+
+	if maxBatch <= 0 {
+		for {
+			messageType, message, err := read()
+			if err != nil {
+				return err
+			}
+			if chaos.DropFrame() {
+				stream.Dropped(1)
+				continue
+			}
+			if err := dst.WriteMessage(messageType, message); err != nil {
+				stream.Dropped(1)
+				return err
+			}
+			stream.Sent(1, len(message))
+		}
+	}
+
+	queue := make(chan relayMessage, relayQueueLen)
+	readErr := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(queue)
+		for {
+			messageType, data, err := read()
+			if err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case queue <- relayMessage{messageType, data}:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var pending *relayMessage
+	batch := make([]byte, 0, maxBatch)
+	for {
+		var m relayMessage
+		if pending != nil {
+			m, pending = *pending, nil
+		} else {
+			var ok bool
+			if m, ok = <-queue; !ok {
+				return <-readErr
+			}
+		}
+		frames := 1
+		if m.messageType == websocket.BinaryMessage && len(queue) > 0 {
+			// WriteMessage copies the data, so the batch buffer is reused
+			var n int
+			batch, n, pending = coalesce(append(batch[:0], m.data...), queue, maxBatch)
+			m.data = batch
+			frames += n
+		}
+		if chaos.DropFrame() {
+			stream.Dropped(frames)
+			continue
+		}
+		if err := dst.WriteMessage(m.messageType, m.data); err != nil {
+			stream.Dropped(frames)
+			return err
+		}
+		stream.Sent(frames, len(m.data))
+	}
+}
+
+// coalesce appends the binary messages already queued to data, up to
+// maxBatch bytes. It returns the batch, the number of messages appended,
+// and the first queued message of another type, if it stopped at one.
+func coalesce(data []byte, queue <-chan relayMessage, maxBatch int) ([]byte, int, *relayMessage) {
+	n := 0
+	for len(data) < maxBatch {
+		select {
+		case next, ok := <-queue:
+			if !ok {
+				return data, n, nil
+			}
+			if next.messageType != websocket.BinaryMessage {
+				return data, n, &next
+			}
+			data = append(data, next.data...)
+			n++
+		default:
+			return data, n, nil
+		}
+	}
+	return data, n, nil
+}
+
 // isCloseError checks if the error is a normal close error
 func isCloseError(err error) bool {
 	if err == nil {
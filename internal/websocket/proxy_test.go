@@ -1,14 +1,18 @@
 package websocket
 
 import (
+	"bytes"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/idlelock"
 )
 
 func TestNewProxy(t *testing.T) {
@@ -350,9 +354,404 @@ func TestUpgraderConfig(t *testing.T) {
 	if len(upgrader.Subprotocols) != 1 || upgrader.Subprotocols[0] != "binary" {
 		t.Errorf("Subprotocols = %v, want [binary]", upgrader.Subprotocols)
 	}
-	// CheckOrigin should allow all origins (returns true)
-	req := httptest.NewRequest(http.MethodGet, "/", nil)
-	if !upgrader.CheckOrigin(req) {
-		t.Error("CheckOrigin() returned false, want true")
+	// A nil CheckOrigin allows only the request's own origin
+	if upgrader.CheckOrigin != nil {
+		t.Error("CheckOrigin is set, want the same-origin default")
+	}
+}
+
+func TestProxy_OriginAndSubprotocol(t *testing.T) {
+	echoSrv := echoServer(t)
+	defer echoSrv.Close()
+	targetURL := "ws" + strings.TrimPrefix(echoSrv.URL, "http")
+
+	allowPartner := func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		return origin == "" || origin == "https://partner.example.com"
+	}
+	tests := []struct {
+		name         string
+		checkOrigin  func(r *http.Request) bool
+		origin       string // empty sends the proxy's own origin
+		subprotocols []string
+		wantStatus   int
+		wantProtocol string
+	}{
+		{name: "same origin", wantStatus: http.StatusSwitchingProtocols},
+		{name: "cross origin by default", origin: "https://evil.example.com", wantStatus: http.StatusForbidden},
+		{name: "allowed origin", checkOrigin: allowPartner, origin: "https://partner.example.com", wantStatus: http.StatusSwitchingProtocols},
+		{name: "disallowed origin", checkOrigin: allowPartner, origin: "https://evil.example.com", wantStatus: http.StatusForbidden},
+		{name: "binary subprotocol", subprotocols: []string{"base64", "binary"}, wantStatus: http.StatusSwitchingProtocols, wantProtocol: "binary"},
+		{name: "unsupported subprotocol", subprotocols: []string{"base64"}, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := NewProxyWithOptions(targetURL, ProxyOptions{CheckOrigin: tt.checkOrigin})
+			proxySrv := httptest.NewServer(http.HandlerFunc(proxy.ServeHTTP))
+			defer proxySrv.Close()
+
+			origin := tt.origin
+			if origin == "" {
+				origin = proxySrv.URL
+			}
+			dialer := websocket.Dialer{Subprotocols: tt.subprotocols}
+			conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(proxySrv.URL, "http"), http.Header{"Origin": {origin}})
+			if conn != nil {
+				defer conn.Close()
+			}
+			if resp == nil {
+				t.Fatalf("Dial() error = %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if conn != nil && conn.Subprotocol() != tt.wantProtocol {
+				t.Errorf("Subprotocol() = %q, want %q", conn.Subprotocol(), tt.wantProtocol)
+			}
+		})
+	}
+}
+
+func TestProxy_MaxMessageSize(t *testing.T) {
+	echoSrv := echoServer(t)
+	defer echoSrv.Close()
+
+	proxy := NewProxyWithOptions("ws"+strings.TrimPrefix(echoSrv.URL, "http"), ProxyOptions{MaxMessageSize: 1024})
+	proxySrv := httptest.NewServer(http.HandlerFunc(proxy.ServeHTTP))
+	defer proxySrv.Close()
+
+	clientConn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(proxySrv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	defer clientConn.Close()
+
+	// A message at the limit is relayed
+	if err := clientConn.WriteMessage(websocket.BinaryMessage, make([]byte, 1024)); err != nil {
+		t.Fatalf("failed to write message: %v", err)
+	}
+	clientConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, received, err := clientConn.ReadMessage(); err != nil || len(received) != 1024 {
+		t.Fatalf("ReadMessage() = %d bytes, %v; want the 1024-byte echo", len(received), err)
+	}
+
+	// A larger one disconnects the client
+	if err := clientConn.WriteMessage(websocket.BinaryMessage, make([]byte, 1025)); err != nil {
+		t.Fatalf("failed to write message: %v", err)
+	}
+	_, _, err = clientConn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Errorf("ReadMessage() error = %v, want close %d", err, websocket.CloseMessageTooBig)
+	}
+}
+
+func TestProxy_IdleTimeout(t *testing.T) {
+	echoSrv := echoServer(t)
+	defer echoSrv.Close()
+
+	proxy := NewProxyWithOptions("ws"+strings.TrimPrefix(echoSrv.URL, "http"), ProxyOptions{IdleTimeout: 200 * time.Millisecond})
+	proxySrv := httptest.NewServer(http.HandlerFunc(proxy.ServeHTTP))
+	defer proxySrv.Close()
+	proxyURL := "ws" + strings.TrimPrefix(proxySrv.URL, "http")
+
+	// A client that reads answers the proxy's pings and stays connected
+	// while sending nothing
+	live, _, err := websocket.DefaultDialer.Dial(proxyURL, nil)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	defer live.Close()
+	go func() {
+		for {
+			if _, _, err := live.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// A client that never reads never answers, and is disconnected
+	silent, _, err := websocket.DefaultDialer.Dial(proxyURL, nil)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	defer silent.Close()
+
+	time.Sleep(600 * time.Millisecond)
+	if err := live.WriteMessage(websocket.TextMessage, []byte("still here")); err != nil {
+		t.Errorf("live client was disconnected: %v", err)
+	}
+
+	// The silent client's connection is closed by the proxy
+	silent.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		if _, _, err := silent.ReadMessage(); err != nil {
+			if ne, ok := err.(interface{ Timeout() bool }); ok && ne.Timeout() {
+				t.Error("silent client was not disconnected")
+			}
+			break
+		}
+	}
+}
+
+func TestProxy_IdleLock(t *testing.T) {
+	echoSrv := echoServer(t)
+	defer echoSrv.Close()
+
+	lock, release := idlelock.NewTracker().Watch("s1", 100*time.Millisecond, nil)
+	defer release()
+	proxy := NewProxy("ws" + strings.TrimPrefix(echoSrv.URL, "http"))
+	proxy.lock = lock
+	proxySrv := httptest.NewServer(http.HandlerFunc(proxy.ServeHTTP))
+	defer proxySrv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(proxySrv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, idlelock.CloseCode) {
+		t.Errorf("read error = %v, want a close with code %d", err, idlelock.CloseCode)
+	}
+}
+
+func TestProxy_ViewOnlyDropsInput(t *testing.T) {
+	received := make(chan []byte, 16)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			received <- msg
+		}
+	}))
+	defer backend.Close()
+
+	proxy := NewProxy("ws" + strings.TrimPrefix(backend.URL, "http"))
+	proxy.filter = newRFBFilter(db.ClipboardBidirectional, nil, nil, true)
+	proxySrv := httptest.NewServer(http.HandlerFunc(proxy.ServeHTTP))
+	defer proxySrv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(proxySrv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	defer conn.Close()
+
+	handshake := [][]byte{[]byte("RFB 003.008\n"), {rfbSecurityNone}, {1}}
+	input := [][]byte{
+		rfbMessage(uint8(4), uint8(1), uint16(0), uint32(0x61)),                 // KeyEvent
+		rfbMessage(uint8(5), uint8(1), uint16(10), uint16(20)),                  // PointerEvent
+		rfbMessage(uint8(6), []byte{0, 0, 0}, uint32(5), "paste"),               // ClientCutText
+		rfbMessage(uint8(255), uint8(0), uint16(1), uint32(0x61), uint32(0x1e)), // QEMU extended key event
+	}
+	update := rfbMessage(uint8(3), uint8(0), uint16(0), uint16(0), uint16(64), uint16(32))
+	for _, msg := range append(append(handshake, input...), update) {
+		if err := conn.WriteMessage(websocket.BinaryMessage, msg); err != nil {
+			t.Fatalf("failed to write message: %v", err)
+		}
+	}
+
+	// Everything up to the FramebufferUpdateRequest reaches the backend, but
+	// none of the input
+	want := bytes.Join(append(handshake, update), nil)
+	var got []byte
+	timeout := time.After(2 * time.Second)
+	for len(got) < len(want) {
+		select {
+		case msg := <-received:
+			got = append(got, msg...)
+		case <-timeout:
+			t.Fatalf("backend received %x, want %x", got, want)
+		}
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("backend received %x, want %x", got, want)
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	queue := make(chan relayMessage, 8)
+	queue <- relayMessage{websocket.BinaryMessage, []byte("bc")}
+	queue <- relayMessage{websocket.BinaryMessage, []byte("de")}
+	queue <- relayMessage{websocket.TextMessage, []byte("text")}
+	queue <- relayMessage{websocket.BinaryMessage, []byte("f")}
+
+	data, n, pending := coalesce([]byte("a"), queue, 1024)
+	if string(data) != "abcde" || n != 2 {
+		t.Errorf("coalesce() = %q, %d; want %q, 2", data, n, "abcde")
+	}
+	if pending == nil || pending.messageType != websocket.TextMessage || string(pending.data) != "text" {
+		t.Errorf("coalesce() pending = %+v, want the text message", pending)
+	}
+
+	// The batch stops growing once it reaches the limit
+	data, n, pending = coalesce([]byte("xyz"), queue, 3)
+	if string(data) != "xyz" || n != 0 || pending != nil {
+		t.Errorf("coalesce() at limit = %q, %d, %+v; want %q, 0, nil", data, n, pending, "xyz")
+	}
+
+	// An empty queue returns the message unchanged
+	data, _, _ = coalesce([]byte("f"), queue, 1024)
+	if string(data) != "ff" {
+		t.Errorf("coalesce() = %q, want %q", data, "ff")
+	}
+	data, n, pending = coalesce([]byte("g"), queue, 1024)
+	if string(data) != "g" || n != 0 || pending != nil {
+		t.Errorf("coalesce() with empty queue = %q, %d, %+v", data, n, pending)
+	}
+}
+
+// burstServer creates a WebSocket server that answers each text message n
+// with n binary messages of size bytes, followed by a text "end" message.
+func burstServer(t testing.TB, size int) *httptest.Server {
+	t.Helper()
+	frame := make([]byte, size)
+	for i := range frame {
+		frame[i] = byte(i / 64) // runs of equal bytes, like framebuffer rows
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(string(msg))
+			for range n {
+				if err := conn.WriteMessage(websocket.BinaryMessage, frame); err != nil {
+					return
+				}
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, []byte("end")); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+// readBurst requests n messages from a burstServer through conn and
+// returns the number of binary bytes and messages received.
+func readBurst(t testing.TB, conn *websocket.Conn, n int) (bytes, messages int) {
+	t.Helper()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(strconv.Itoa(n))); err != nil {
+		t.Fatalf("failed to request burst: %v", err)
+	}
+	for {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("failed to read burst: %v", err)
+		}
+		if messageType == websocket.TextMessage {
+			if string(data) != "end" {
+				t.Fatalf("got text message %q, want %q", data, "end")
+			}
+			return bytes, messages
+		}
+		bytes += len(data)
+		messages++
+	}
+}
+
+func dialProxy(t testing.TB, target *httptest.Server, opts ProxyOptions, compress bool) (*websocket.Conn, *http.Response) {
+	t.Helper()
+	proxy := NewProxyWithOptions("ws"+strings.TrimPrefix(target.URL, "http"), opts)
+	proxySrv := httptest.NewServer(http.HandlerFunc(proxy.ServeHTTP))
+	t.Cleanup(proxySrv.Close)
+
+	dialer := websocket.Dialer{EnableCompression: compress}
+	conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(proxySrv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, resp
+}
+
+func TestProxy_BatchingPreservesStream(t *testing.T) {
+	srv := burstServer(t, 1000)
+	defer srv.Close()
+
+	conn, _ := dialProxy(t, srv, ProxyOptions{MaxBatchSize: 16 * 1024}, false)
+	for range 3 {
+		bytes, messages := readBurst(t, conn, 200)
+		if bytes != 200*1000 {
+			t.Errorf("got %d bytes, want %d", bytes, 200*1000)
+		}
+		if messages == 0 || messages > 200 {
+			t.Errorf("got %d messages, want between 1 and 200", messages)
+		}
+	}
+}
+
+func TestProxy_CompressionNegotiation(t *testing.T) {
+	srv := burstServer(t, 1000)
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		level    int
+		compress bool
+		want     bool
+	}{
+		{"enabled and offered", 1, true, true},
+		{"enabled but not offered", 1, false, false},
+		{"disabled", 0, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, resp := dialProxy(t, srv, ProxyOptions{CompressionLevel: tt.level}, tt.compress)
+			got := strings.Contains(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+			if got != tt.want {
+				t.Errorf("permessage-deflate negotiated = %v, want %v", got, tt.want)
+			}
+			if bytes, _ := readBurst(t, conn, 10); bytes != 10*1000 {
+				t.Errorf("got %d bytes, want %d", bytes, 10*1000)
+			}
+		})
+	}
+}
+
+// BenchmarkProxy_Relay measures the proxy's throughput relaying bursts of
+// framebuffer-sized messages to a client, with compression and batching
+// off (the previous behavior) and on.
+func BenchmarkProxy_Relay(b *testing.B) {
+	const frameSize, burst = 16 * 1024, 64
+
+	srv := burstServer(b, frameSize)
+	defer srv.Close()
+
+	cases := []struct {
+		name string
+		opts ProxyOptions
+	}{
+		{"plain", ProxyOptions{}},
+		{"batched", ProxyOptions{MaxBatchSize: DefaultMaxBatchSize}},
+		{"compressed", ProxyOptions{CompressionLevel: 1}},
+		{"compressed-batched", ProxyOptions{CompressionLevel: 1, MaxBatchSize: DefaultMaxBatchSize}},
+	}
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			conn, _ := dialProxy(b, srv, c.opts, true)
+			b.SetBytes(frameSize * burst)
+			b.ResetTimer()
+			for b.Loop() {
+				readBurst(b, conn, burst)
+			}
+		})
 	}
 }
@@ -8,25 +8,61 @@ import (
 	"fmt"
 	"io/fs"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
+	"github.com/rjsadow/sortie/internal/accounts"
+	"github.com/rjsadow/sortie/internal/apiusage"
+	"github.com/rjsadow/sortie/internal/attestation"
 	"github.com/rjsadow/sortie/internal/billing"
+	"github.com/rjsadow/sortie/internal/branding"
+	"github.com/rjsadow/sortie/internal/chaos"
 	"github.com/rjsadow/sortie/internal/config"
 	"github.com/rjsadow/sortie/internal/db"
 	"github.com/rjsadow/sortie/internal/diagnostics"
+	"github.com/rjsadow/sortie/internal/email"
 	"github.com/rjsadow/sortie/internal/files"
 	"github.com/rjsadow/sortie/internal/gateway"
+	"github.com/rjsadow/sortie/internal/guacamole"
+	"github.com/rjsadow/sortie/internal/healthwatch"
+	"github.com/rjsadow/sortie/internal/icons"
+	"github.com/rjsadow/sortie/internal/jobs"
 	"github.com/rjsadow/sortie/internal/recordings"
 	"github.com/rjsadow/sortie/internal/k8s"
+	"github.com/rjsadow/sortie/internal/leader"
+	"github.com/rjsadow/sortie/internal/lint"
+	"github.com/rjsadow/sortie/internal/middleware"
+	"github.com/rjsadow/sortie/internal/notify"
+	"github.com/rjsadow/sortie/internal/offline"
 	"github.com/rjsadow/sortie/internal/plugins"
 	"github.com/rjsadow/sortie/internal/plugins/auth"
+	"github.com/rjsadow/sortie/internal/plugins/sidecar"
 	"github.com/rjsadow/sortie/internal/plugins/storage"
+	"github.com/rjsadow/sortie/internal/preflight"
+	"github.com/rjsadow/sortie/internal/prepull"
+	"github.com/rjsadow/sortie/internal/presence"
 	"github.com/rjsadow/sortie/internal/runner"
+	"github.com/rjsadow/sortie/internal/scheduledsessions"
+	"github.com/rjsadow/sortie/internal/secrets"
 	"github.com/rjsadow/sortie/internal/server"
 	"github.com/rjsadow/sortie/internal/sessions"
+	"github.com/rjsadow/sortie/internal/slo"
+	"github.com/rjsadow/sortie/internal/spectate"
 	"github.com/rjsadow/sortie/internal/sse"
+	"github.com/rjsadow/sortie/internal/ssrf"
+	"github.com/rjsadow/sortie/internal/startup"
+	"github.com/rjsadow/sortie/internal/storagebrowser"
+	"github.com/rjsadow/sortie/internal/streamstats"
+	"github.com/rjsadow/sortie/internal/templatesync"
+	"github.com/rjsadow/sortie/internal/tracing"
+	"github.com/rjsadow/sortie/internal/updatecheck"
+	"github.com/rjsadow/sortie/internal/version"
+	"github.com/rjsadow/sortie/internal/websocket"
 
 	"golang.org/x/time/rate"
 )
@@ -41,12 +77,39 @@ var embeddedDocs embed.FS
 var embeddedTemplates []byte
 
 func main() {
+	// Subcommands run instead of the server
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate-data":
+			os.Exit(runMigrateData(os.Args[2:]))
+		case "export-data":
+			os.Exit(runExportData(os.Args[2:]))
+		case "rotate-storage-keys":
+			os.Exit(runRotateStorageKeys(os.Args[2:]))
+		case "sign-catalog":
+			os.Exit(runSignCatalog(os.Args[2:]))
+		case "check":
+			os.Exit(runCheck(os.Args[2:]))
+		case "version", "--version":
+			fmt.Println("sortie " + version.Get().String())
+			os.Exit(0)
+		}
+	}
+
 	// Initialize structured logging with JSON handler for production
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
 	}))
 	slog.SetDefault(logger)
 
+	build := version.Get()
+	slog.Info("Sortie "+build.String(),
+		"version", build.Version,
+		"commit", build.Commit,
+		"build_date", build.BuildDate,
+		"go_version", build.GoVersion,
+	)
+
 	// Parse command-line flags (can override env vars)
 	port := flag.Int("port", config.DefaultPort, "Port to listen on")
 	dbPath := flag.String("db", config.DefaultDBPath, "Path to SQLite database")
@@ -61,24 +124,112 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Outbound requests to admin-supplied URLs reach internal addresses
+	// only if allowlisted (validated by config.Load)
+	outboundAllow, _ := ssrf.SplitAllowlist(appConfig.OutboundAllowlist)
+
+	// In offline mode every outbound connection is refused unless it is to
+	// an internal or allowlisted address. Enable it before anything dials.
+	if appConfig.Offline {
+		offline.Enable(outboundAllow)
+		slog.Info("Offline mode enabled: connections to the internet are blocked")
+	}
+	outboundGuard := ssrf.NewGuard(outboundAllow)
+	outboundGuard.SetOffline(appConfig.Offline)
+
+	// Initialize tracing; spans are no-ops unless an exporter is configured
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    appConfig.TracesExporter,
+		Protocol:    appConfig.OTLPProtocol,
+		ServiceName: appConfig.OTelServiceName,
+	})
+	if err != nil {
+		slog.Error("failed to initialize tracing", "error", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+	if appConfig.TracesExporter != "none" {
+		slog.Info("Tracing enabled", "exporter", appConfig.TracesExporter, "service", appConfig.OTelServiceName)
+	}
+
 	// Initialize Kubernetes configuration (skip when using mock runner)
 	if !*mockRunnerFlag {
 		k8s.Configure(appConfig.Namespace, appConfig.Kubeconfig, appConfig.VNCSidecarImage)
 		k8s.ConfigureBrowserSidecar(appConfig.BrowserSidecarImage)
 		k8s.ConfigureGuacdSidecar(appConfig.GuacdSidecarImage)
+		k8s.ConfigureRateLimit(float32(appConfig.K8sQPS), appConfig.K8sBurst)
+		k8s.ConfigureCapacityCheck(appConfig.CapacityCheck)
+		k8s.ConfigureRegistryMirrors(appConfig.RegistryMirrors)
+		if appConfig.SessionDomain != "" {
+			serviceName, servicePort := appConfig.SessionIngressBackend()
+			k8s.ConfigureSessionIngress(k8s.SessionIngressConfig{
+				ServiceName: serviceName,
+				ServicePort: servicePort,
+				ClassName:   appConfig.SessionIngressClass,
+				TLSSecret:   appConfig.SessionTLSSecret,
+				Annotations: appConfig.SessionIngressAnnotations,
+				Direct:      appConfig.SessionRouting == config.SessionRoutingDirect,
+				AuthURL:     appConfig.SessionAuthEndpoint(),
+			})
+		}
 	}
 
-	// Initialize database
-	database, err := db.OpenDB(appConfig.DBType, appConfig.DSN())
+	// Listen before the dependencies are up. While startup waits for
+	// them, /healthz reports the server alive and /readyz not ready, so a
+	// database or API server that is briefly down delays startup instead
+	// of crash-looping the pod.
+	addr := fmt.Sprintf(":%d", appConfig.Port)
+	listener, err := net.Listen("tcp", addr)
 	if err != nil {
-		slog.Error("failed to open database", "error", err)
+		slog.Error("failed to listen", "addr", addr, "error", err)
 		os.Exit(1)
 	}
+	slog.Info("Sortie server starting", "addr", "http://localhost"+addr)
+	boot := startup.New(appConfig.StartupTimeout)
+	srv := &http.Server{Handler: boot}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.Serve(listener) }()
+
+	// A shutdown signal stops startup waiting
+	startCtx, stopStartup := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	// Initialize database: wait for the server, then run migrations
+	mustStart(startCtx, boot, "database", func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, startupAttemptTimeout)
+		defer cancel()
+		return db.CheckConnection(ctx, appConfig.DBType, appConfig.DSN())
+	})
+	var database *db.DB
+	mustStart(startCtx, boot, "migrations", func(context.Context) error {
+		database, err = db.OpenDB(appConfig.DBType, appConfig.DSN())
+		return err
+	})
 	defer database.Close()
+	if appConfig.DBType == "sqlite" && appConfig.DBSingleWriter {
+		database.SetSingleWriter(true)
+		slog.Info("SQLite single-writer mode enabled")
+	}
 
 	// Wire shared DB into the storage plugin before any plugin initialization
 	storage.SetDB(database)
 
+	// Create the bootstrap file's categories, tenant settings, and apps on
+	// a fresh install
+	if appConfig.Bootstrap != "" {
+		bootstrap, err := db.LoadBootstrap(appConfig.Bootstrap)
+		if err != nil {
+			slog.Error("failed to load bootstrap file", "error", err)
+			os.Exit(1)
+		}
+		if applied, err := database.ApplyBootstrap(bootstrap); err != nil {
+			slog.Error("failed to apply bootstrap file", "error", err)
+			os.Exit(1)
+		} else if applied {
+			slog.Info("applied bootstrap file", "path", appConfig.Bootstrap,
+				"categories", len(bootstrap.Categories), "apps", len(bootstrap.Apps))
+		}
+	}
+
 	// Seed from JSON if provided and database is empty
 	if appConfig.Seed != "" {
 		if err := database.SeedFromJSON(appConfig.Seed); err != nil {
@@ -123,6 +274,10 @@ func main() {
 		slog.Warn("SORTIE_JWT_SECRET not set - authentication disabled")
 	}
 
+	if chaos.Enabled {
+		slog.Warn("built with fault injection - for resilience testing only", "endpoint", "/api/admin/chaos")
+	}
+
 	// Initialize OIDC auth provider if configured
 	var oidcAuthProvider *auth.OIDCAuthProvider
 	if appConfig.OIDCEnabled() && appConfig.JWTSecret != "" {
@@ -197,24 +352,141 @@ func main() {
 		workloadRunner = runner.NewMockRunner()
 	} else {
 		workloadRunner = runner.NewKubernetesRunner()
+		mustStart(startCtx, boot, "kubernetes", func(ctx context.Context) error {
+			// Missing credentials will not come back on a retry; start
+			// without them as before, leaving launches to fail
+			if _, err := k8s.GetClient(); err != nil {
+				slog.Warn("no Kubernetes credentials; sessions cannot launch", "error", err)
+				return nil
+			}
+			ctx, cancel := context.WithTimeout(ctx, startupAttemptTimeout)
+			defer cancel()
+			return k8s.CheckConnection(ctx)
+		})
+
+		// Watch session pods so launch and status polling read them from
+		// memory rather than each GETting the API server
+		podCacheCtx, podCacheCancel := context.WithCancel(context.Background())
+		defer podCacheCancel()
+		if err := k8s.StartPodCache(podCacheCtx); err != nil {
+			slog.Warn("failed to start pod cache; reading pods from the API server", "error", err)
+		}
 	}
 	slog.Info("Workload runner initialized", "type", workloadRunner.Type())
+	stopStartup()
+
+	// Run the preflight checks, including that the configured sidecar
+	// images and app overrides exist, so a bad tag is reported at startup
+	// rather than as sessions stuck pulling images. In warn mode they run
+	// in the background so a slow registry doesn't delay startup.
+	if appConfig.Preflight != "off" {
+		apps, err := database.ListApps()
+		if err != nil {
+			slog.Warn("failed to list apps for sidecar image check", "error", err)
+		}
+		opts := preflight.Options{Config: appConfig, Images: k8s.SidecarImages(apps)}
+		if *mockRunnerFlag {
+			opts.Skip = []string{preflight.CheckKubernetes, preflight.CheckImages}
+		}
+		if appConfig.Preflight == "strict" {
+			results := preflight.Run(context.Background(), opts)
+			logPreflight(results)
+			if preflight.Failed(results) {
+				slog.Error("preflight checks failed; fix them or set SORTIE_PREFLIGHT=warn to start anyway")
+				os.Exit(1)
+			}
+		} else {
+			go func() {
+				logPreflight(preflight.Run(context.Background(), opts))
+			}()
+		}
+	}
+
+	// Initialize the secrets provider that app credential references are
+	// resolved from
+	secretsManager, err := secrets.NewManager(secrets.LoadConfig())
+	if err != nil {
+		slog.Error("failed to initialize secrets provider", "error", err)
+		os.Exit(1)
+	}
+	defer secretsManager.Close()
+	slog.Info("Secrets provider initialized", "provider", secretsManager.ProviderName())
+
+	// Initialize the built-in sidecar injector, which adds the sidecar
+	// templates admins attach to tenants and apps
+	sidecarTemplates := sidecar.NewTemplateInjector()
+	if err := sidecarTemplates.Initialize(context.Background(), nil); err != nil {
+		slog.Error("failed to initialize sidecar template injector", "error", err)
+		os.Exit(1)
+	}
+	sidecarTemplates.SetDatabase(database)
+
+	// Initialize the session attestation signer. Without an explicit key,
+	// the key is derived from the JWT secret; without either, apps cannot
+	// have attested sessions.
+	var attestor *attestation.Signer
+	if appConfig.AttestationKey != "" {
+		attestor, err = attestation.ParseSigner(appConfig.AttestationKey)
+		if err != nil {
+			slog.Error("failed to load attestation key", "error", err)
+			os.Exit(1)
+		}
+	} else if appConfig.JWTSecret != "" {
+		attestor = attestation.DeriveSigner(appConfig.JWTSecret)
+	}
+	if attestor != nil {
+		slog.Info("Session attestation enabled", "key_id", attestor.KeyID())
+	}
+
+	// Elect one replica to run maintenance loops, so that replicas sharing
+	// a database do not expire or clean up the same sessions twice
+	var maintenanceLeader leader.Checker
+	var elector *leader.Elector
+	if appConfig.LeaderElection {
+		elector = leader.NewElector(database, leader.MaintenanceLease, leader.Identity(), leader.DefaultLeaseDuration)
+		elector.Start()
+		defer elector.Stop()
+		maintenanceLeader = elector
+		slog.Info("Leader election enabled", "identity", elector.Identity(), "leader", elector.IsLeader())
+	}
+
+	// Periodic maintenance runs as background jobs on the leader, which
+	// record their runs for the admin API. Jobs register below and the
+	// scheduler starts once all have.
+	jobScheduler := jobs.NewScheduler(database, leader.Identity())
+	jobScheduler.SetLeader(maintenanceLeader)
+	registerJob := func(job jobs.Job) {
+		if err := jobScheduler.Register(job); err != nil {
+			slog.Error("failed to register background job", "job", job.Name, "error", err)
+			os.Exit(1)
+		}
+	}
 
 	// Initialize session manager with config
 	sessionManager := sessions.NewManagerWithConfig(database, sessions.ManagerConfig{
-		SessionTimeout:     appConfig.SessionTimeout,
-		CleanupInterval:    appConfig.SessionCleanupInterval,
-		PodReadyTimeout:    appConfig.PodReadyTimeout,
-		MaxSessionsPerUser: appConfig.MaxSessionsPerUser,
-		MaxGlobalSessions:  appConfig.MaxGlobalSessions,
-		DefaultCPURequest:  appConfig.DefaultCPURequest,
-		DefaultCPULimit:    appConfig.DefaultCPULimit,
-		DefaultMemRequest:  appConfig.DefaultMemRequest,
-		DefaultMemLimit:    appConfig.DefaultMemLimit,
-		Recorder:           sessionRecorder,
-		QueueMaxSize:       appConfig.QueueMaxSize,
-		QueueTimeout:       appConfig.QueueTimeout,
-		Runner:             workloadRunner,
+		SessionTimeout:          appConfig.SessionTimeout,
+		CleanupInterval:         appConfig.SessionCleanupInterval,
+		PodReadyTimeout:         appConfig.PodReadyTimeout,
+		MaxRestarts:             appConfig.SessionMaxRestarts,
+		MaxReschedules:          appConfig.SessionMaxReschedules,
+		SessionRetention:        time.Duration(appConfig.SessionRetentionDays) * 24 * time.Hour,
+		MaxSessionsPerUser:      appConfig.MaxSessionsPerUser,
+		MaxGlobalSessions:       appConfig.MaxGlobalSessions,
+		MaxSessionsPerUserBurst: appConfig.MaxSessionsPerUserBurst,
+		SessionBurstDuration:    appConfig.SessionBurstDuration,
+		DefaultCPURequest:       appConfig.DefaultCPURequest,
+		DefaultCPULimit:         appConfig.DefaultCPULimit,
+		DefaultMemRequest:       appConfig.DefaultMemRequest,
+		DefaultMemLimit:         appConfig.DefaultMemLimit,
+		Recorder:                sessionRecorder,
+		QueueMaxSize:            appConfig.QueueMaxSize,
+		QueueTimeout:            appConfig.QueueTimeout,
+		Runner:                  workloadRunner,
+		SidecarInjectors:        []plugins.SidecarInjector{sidecarTemplates},
+		Attestor:                attestor,
+		Leader:                  maintenanceLeader,
+		SessionDomain:           appConfig.SessionDomain,
+		Scheduler:               jobScheduler,
 	})
 	sessionManager.Start()
 	defer sessionManager.Stop()
@@ -233,6 +505,9 @@ func main() {
 
 	// Initialize gateway handler (WebSocket proxy with auth + rate limiting)
 	var gwHandler *gateway.Handler
+	var spectateManager *spectate.Manager
+	var presenceTracker *presence.Tracker
+	var streamStats *streamstats.Tracker
 	if appConfig.JWTSecret != "" {
 		var rl *gateway.RateLimiter
 		if appConfig.GatewayRateLimit > 0 {
@@ -241,11 +516,33 @@ func main() {
 				"rate", appConfig.GatewayRateLimit,
 				"burst", appConfig.GatewayBurst)
 		}
+		// Admin spectate notices and welcome messages are pushed to the
+		// session owner over SSE
+		var notifier spectate.Notifier
+		if sseHub != nil {
+			notifier = sseHub
+		}
+		spectateManager = spectate.NewManager(notifier)
+		presenceTracker = presence.NewTracker()
+		streamStats = streamstats.NewTracker()
+
 		gwHandler = gateway.NewHandler(gateway.Config{
 			SessionManager: sessionManager,
 			AuthProvider:   jwtAuthProvider,
 			Database:       database,
 			RateLimiter:    rl,
+			Spectate:       spectateManager,
+			Presence:       presenceTracker,
+			Notifier:       notifier,
+			Secrets:        secretsManager,
+			VNCProxy: websocket.ProxyOptions{
+				CompressionLevel: appConfig.GatewayCompressionLevel,
+				MaxBatchSize:     websocket.DefaultMaxBatchSize,
+			},
+			StreamStats:    streamStats,
+			AllowedOrigins: appConfig.GatewayAllowedOrigins,
+			MaxMessageSize: appConfig.GatewayMaxMessageSize,
+			IdleTimeout:    appConfig.GatewayIdleTimeout,
 		})
 		slog.Info("Gateway service initialized with auth and rate limiting")
 	} else {
@@ -254,49 +551,195 @@ func main() {
 
 	// Initialize file transfer handler
 	fileHandler := files.NewHandler(sessionManager, database, appConfig.MaxUploadSize)
+	fileHandler.SetTransferLimits(files.Limits{
+		MaxConcurrent:  appConfig.FileTransferMaxConcurrent,
+		BytesPerSecond: int64(appConfig.FileTransferBandwidthKBps) * 1024,
+	})
+	uploadPolicy := &db.UploadPolicy{
+		AllowedExtensions: files.ParseList(appConfig.FileUploadAllowedExtensions),
+		BlockedExtensions: files.ParseList(appConfig.FileUploadBlockedExtensions),
+		AllowedTypes:      files.ParseList(appConfig.FileUploadAllowedTypes),
+		BlockedTypes:      files.ParseList(appConfig.FileUploadBlockedTypes),
+	}
+	if err := uploadPolicy.Validate(); err != nil {
+		slog.Error("invalid file upload policy", "error", err)
+		os.Exit(1)
+	}
+	fileHandler.SetUploadPolicy(uploadPolicy)
+
+	// Initialize encryption at rest for recordings and branding assets
+	storageCipher, err := newStorageCipher(appConfig)
+	if err != nil {
+		slog.Error("failed to initialize storage encryption", "error", err)
+		os.Exit(1)
+	}
+	if storageCipher != nil {
+		slog.Info("Storage encryption enabled", "mode", appConfig.StorageEncryption, "key_id", storageCipher.KeyID())
+	}
 
 	// Initialize video recording handler
 	var recordingHandler *recordings.Handler
+	var storageCategories []storagebrowser.Category
 	if appConfig.VideoRecordingEnabled {
-		var recordingStore recordings.RecordingStore
-		switch appConfig.RecordingStorageBackend {
-		case "s3":
-			s3Store, err := recordings.NewS3Store(
-				appConfig.RecordingS3Bucket,
-				appConfig.RecordingS3Region,
-				appConfig.RecordingS3Endpoint,
-				appConfig.RecordingS3Prefix,
-				appConfig.RecordingS3AccessKeyID,
-				appConfig.RecordingS3SecretAccessKey,
-			)
-			if err != nil {
-				slog.Error("failed to initialize S3 recording store", "error", err)
-				os.Exit(1)
-			}
-			recordingStore = s3Store
+		recordingStore, err := newRecordingStore(appConfig)
+		if err != nil {
+			slog.Error("failed to initialize recording store", "error", err)
+			os.Exit(1)
+		}
+		if appConfig.RecordingStorageBackend == "s3" {
 			slog.Info("Video recording enabled",
 				"storage_backend", "s3",
 				"bucket", appConfig.RecordingS3Bucket,
 				"region", appConfig.RecordingS3Region,
 				"max_size_mb", appConfig.RecordingMaxSizeMB)
-		default:
-			recordingStore = recordings.NewLocalStore(appConfig.RecordingStoragePath)
+		} else {
 			slog.Info("Video recording enabled",
 				"storage_backend", "local",
 				"storage_path", appConfig.RecordingStoragePath,
 				"max_size_mb", appConfig.RecordingMaxSizeMB)
 		}
+		if storageCipher != nil {
+			recordingStore = recordings.NewEncryptedStore(recordingStore, storageCipher)
+		}
 
 		recordingHandler = recordings.NewHandler(database, recordingStore, appConfig)
+		// Tenants with a storage location in their settings keep their
+		// recordings there
+		recordingHandler.Stores().SetTenantStoreFunc(func(loc db.StorageLocation) (recordings.RecordingStore, error) {
+			return newTenantRecordingStore(appConfig, loc, storageCipher)
+		})
+		storageCategories = append(storageCategories, storagebrowser.Category{
+			Name:    "recordings",
+			Backend: appConfig.RecordingStorageBackend,
+			Store:   recordingStore,
+			References: func() (map[string]bool, error) {
+				return recordings.ReferencedPaths(database)
+			},
+		})
 
 		if appConfig.RecordingRetentionDays > 0 {
 			cleaner := recordings.NewCleaner(database, recordingStore, appConfig.RecordingRetentionDays)
-			cleaner.Start()
-			defer cleaner.Stop()
+			cleaner.SetStores(recordingHandler.Stores())
+			registerJob(jobs.Job{
+				Name:        "recording-retention",
+				Description: "Deletes recordings older than the retention period",
+				Interval:    time.Hour,
+				Retries:     2,
+				Run:         cleaner.Run,
+			})
 			slog.Info("Recording retention cleanup enabled", "retention_days", appConfig.RecordingRetentionDays)
 		}
+
+		if appConfig.RecordingRepairMinutes > 0 {
+			repairer := recordings.NewRepairer(recordingHandler, time.Duration(appConfig.RecordingRepairMinutes)*time.Minute)
+			registerJob(jobs.Job{
+				Name:        "recording-repair",
+				Description: "Finalizes recordings whose upload stopped without completing",
+				Interval:    time.Minute,
+				Run:         repairer.Run,
+			})
+			slog.Info("Recording repair enabled", "repair_minutes", appConfig.RecordingRepairMinutes)
+		}
+	}
+
+	// Initialize branding asset storage
+	brandingStore, err := newBrandingStore(appConfig)
+	if err != nil {
+		slog.Error("failed to initialize branding store", "error", err)
+		os.Exit(1)
+	}
+	if storageCipher != nil {
+		brandingStore = branding.NewEncryptedStore(brandingStore, storageCipher)
+	}
+	storageCategories = append(storageCategories, storagebrowser.Category{
+		Name:    "branding",
+		Backend: appConfig.BrandingStorageBackend,
+		Store:   brandingStore,
+		// Uploaded app and template icons share the branding store
+		References: func() (map[string]bool, error) {
+			refs, err := branding.ReferencedPaths(database)
+			if err != nil {
+				return nil, err
+			}
+			iconRefs, err := icons.ReferencedPaths(database)
+			if err != nil {
+				return nil, err
+			}
+			for path := range iconRefs {
+				refs[path] = true
+			}
+			return refs, nil
+		},
+	})
+
+	// Initialize inactive account policy
+	if appConfig.InactiveUserDisableDays > 0 || appConfig.InactiveUserDeleteDays > 0 {
+		var notifier accounts.Notifier = &accounts.LogNotifier{}
+		if appConfig.InactiveUserWebhookURL != "" {
+			notifier = accounts.NewWebhookNotifier(appConfig.InactiveUserWebhookURL, outboundGuard, notify.TenantAllowlists(database))
+		}
+		accountCleaner := accounts.NewCleaner(database, appConfig.InactiveUserDisableDays, appConfig.InactiveUserDeleteDays, notifier)
+		registerJob(jobs.Job{
+			Name:        "inactive-accounts",
+			Description: "Disables and deletes accounts that have not logged in recently",
+			Interval:    time.Hour,
+			Retries:     2,
+			Run:         accountCleaner.Run,
+		})
+		slog.Info("Inactive account cleanup enabled",
+			"disable_after_days", appConfig.InactiveUserDisableDays,
+			"delete_after_days", appConfig.InactiveUserDeleteDays,
+			"notifier", notifier.Name())
 	}
 
+	// Initialize the update check. It does nothing until enabled by
+	// SORTIE_UPDATE_CHECK or the update_check admin setting, and never runs
+	// in offline mode.
+	updateChecker := updatecheck.NewChecker(appConfig.UpdateCheckURL, build.Version, func() updatecheck.Settings {
+		s := updatecheck.Settings{Enabled: appConfig.UpdateCheck, Channel: appConfig.UpdateChannel}
+		if v, err := database.GetSetting("update_check"); err == nil && v != "" {
+			s.Enabled = v == "true" || v == "1"
+		}
+		if appConfig.Offline {
+			s.Enabled = false
+		}
+		if v, err := database.GetSetting("update_channel"); err == nil && v != "" {
+			s.Channel = v
+		}
+		return s
+	})
+	updateChecker.Start()
+	defer updateChecker.Stop()
+
+	// Rate limit login, registration, and session creation. The
+	// <name>_rate_limit and <name>_rate_burst admin settings override the
+	// configured limits.
+	settingsRateLimit := func(name string, rate float64, burst int) func() middleware.RateLimit {
+		return func() middleware.RateLimit {
+			limit := middleware.RateLimit{Rate: rate, Burst: burst}
+			if v, err := database.GetSetting(name + "_rate_limit"); err == nil && v != "" {
+				if r, err := strconv.ParseFloat(v, 64); err == nil && r >= 0 {
+					limit.Rate = r
+				}
+			}
+			if v, err := database.GetSetting(name + "_rate_burst"); err == nil && v != "" {
+				if b, err := strconv.Atoi(v); err == nil && b > 0 {
+					limit.Burst = b
+				}
+			}
+			return limit
+		}
+	}
+	authLimiter := middleware.NewRateLimiter(settingsRateLimit("auth", appConfig.AuthRateLimit, appConfig.AuthRateBurst))
+	defer authLimiter.Stop()
+	sessionLimiter := middleware.NewRateLimiter(settingsRateLimit("session", appConfig.SessionRateLimit, appConfig.SessionRateBurst))
+	defer sessionLimiter.Stop()
+
+	// Count API requests per user and API token
+	apiUsageMeter := apiusage.NewMeter(database)
+	apiUsageMeter.Start()
+	defer apiUsageMeter.Stop()
+
 	// Get the subdirectory from the embedded filesystem
 	distFS, err := fs.Sub(embeddedFiles, "web/dist")
 	if err != nil {
@@ -332,6 +775,54 @@ func main() {
 		return status.LoadFactor
 	}))
 
+	// Publish guacd tunnel counters for Windows sessions
+	expvar.Publish("sortie_guacd_tunnels", expvar.Func(func() any {
+		return guacamole.Stats()
+	}))
+
+	// Publish leader election state: whether this replica runs maintenance
+	// loops, and which replica currently does
+	expvar.Publish("sortie_is_leader", expvar.Func(func() any {
+		if leader.IsLeader(maintenanceLeader) {
+			return 1
+		}
+		return 0
+	}))
+	if elector != nil {
+		expvar.Publish("sortie_leader", expvar.Func(func() any {
+			holder, err := elector.Leader()
+			if err != nil {
+				return ""
+			}
+			return holder
+		}))
+	}
+
+	// Publish background job counters for this replica
+	expvar.Publish("sortie_jobs", expvar.Func(func() any {
+		return jobScheduler.Metrics()
+	}))
+
+	// Object storage browser for admins (only categories with a backend)
+	var storageBrowser *storagebrowser.Browser
+	if len(storageCategories) > 0 {
+		storageBrowser = storagebrowser.New(storageCategories...)
+	}
+
+	// Outgoing email, used for password reset links
+	var mailer email.Sender
+	if appConfig.SMTPHost != "" {
+		mailer = &email.SMTPSender{
+			Host:     appConfig.SMTPHost,
+			Port:     appConfig.SMTPPort,
+			Username: appConfig.SMTPUsername,
+			Password: appConfig.SMTPPassword,
+			From:     appConfig.SMTPFrom,
+			TLS:      appConfig.SMTPTLS,
+		}
+		slog.Info("Email enabled", "smtp_host", appConfig.SMTPHost, "smtp_port", appConfig.SMTPPort, "tls", appConfig.SMTPTLS)
+	}
+
 	// Build the application handler using the server package
 	app := &server.App{
 		DB:                  database,
@@ -344,18 +835,151 @@ func main() {
 		RecordingHandler:    recordingHandler,
 		SSEHub:              sseHub,
 		DiagCollector:       diagCollector,
+		StorageBrowser:      storageBrowser,
+		BrandingStore:       brandingStore,
+		Spectate:            spectateManager,
+		Presence:            presenceTracker,
+		StreamStats:         streamStats,
+		Attestor:            attestor,
+		CredentialCipher:    storageCipher,
+		UpdateChecker:       updateChecker,
+		Jobs:                jobScheduler,
+		APIUsage:            apiUsageMeter,
+		Mailer:              mailer,
+		Outbound:            outboundGuard,
+		AuthRateLimiter:     authLimiter,
+		SessionRateLimiter:  sessionLimiter,
 		Config:              appConfig,
 		StaticFS:            distFS,
 		DocsFS:              docsFS,
 	}
 
-	handler := app.Handler()
+	// Record component health transitions and notify operators of them
+	healthNotifiers := []healthwatch.Notifier{&healthwatch.LogNotifier{}}
+	if sseHub != nil {
+		healthNotifiers = append(healthNotifiers, healthwatch.NewAdminNotifier(sseHub))
+	}
+	if appConfig.HealthWebhookURL != "" {
+		healthNotifiers = append(healthNotifiers, healthwatch.NewWebhookNotifier(appConfig.HealthWebhookURL, outboundGuard))
+	}
+	healthWatcher := healthwatch.NewWatcher(database, app.ComponentHealth, 30*time.Second, healthNotifiers...)
+	healthWatcher.SetLeader(maintenanceLeader)
+	healthWatcher.Start()
+	defer healthWatcher.Stop()
+
+	// Alert operators when an app burns its SLO error budget too fast
+	sloNotifiers := []slo.Notifier{&slo.LogNotifier{}}
+	if sseHub != nil {
+		sloNotifiers = append(sloNotifiers, slo.NewAdminNotifier(sseHub))
+	}
+	if appConfig.SLOWebhookURL != "" {
+		sloNotifiers = append(sloNotifiers, slo.NewWebhookNotifier(appConfig.SLOWebhookURL, outboundGuard, notify.TenantAllowlists(database)))
+	}
+	registerJob(jobs.Job{
+		Name:        "slo-alerts",
+		Description: "Checks app SLO error budget burn rates and sends alerts",
+		Interval:    5 * time.Minute,
+		Run:         slo.NewMonitor(database, sloNotifiers...).Run,
+	})
+
+	// Alert operators when a user or API token calls the API too much, and
+	// delete old usage
+	apiUsageNotifiers := []apiusage.Notifier{&apiusage.LogNotifier{}}
+	if sseHub != nil {
+		apiUsageNotifiers = append(apiUsageNotifiers, apiusage.NewAdminNotifier(sseHub))
+	}
+	if appConfig.APIUsageWebhookURL != "" {
+		apiUsageNotifiers = append(apiUsageNotifiers, apiusage.NewWebhookNotifier(appConfig.APIUsageWebhookURL, outboundGuard))
+	}
+	registerJob(jobs.Job{
+		Name:        "api-usage-alerts",
+		Description: "Checks API usage per user and API token against the hourly threshold and deletes old usage",
+		Interval:    5 * time.Minute,
+		Run:         apiusage.NewMonitor(database, appConfig.APIUsageAlertThreshold, apiusage.DefaultRetention, apiUsageNotifiers...).Run,
+	})
 
-	addr := fmt.Sprintf(":%d", appConfig.Port)
-	slog.Info("Sortie server starting", "addr", "http://localhost"+addr)
+	// Launch sessions users scheduled ahead of time
+	registerJob(jobs.Job{
+		Name:        "scheduled-sessions",
+		Description: "Launches scheduled sessions that are due",
+		Interval:    time.Minute,
+		Run:         scheduledsessions.NewRunner(database, sessionManager).Run,
+	})
+
+	// Pull apps' images onto nodes on their pre-pull schedules
+	registerJob(jobs.Job{
+		Name:        "image-prepull",
+		Description: "Starts scheduled image pre-pulls and removes expired ones",
+		Interval:    time.Minute,
+		Run:         prepull.NewScheduler(database, sessionManager).Run,
+	})
+
+	// Check the catalog against the lint rules for the admin report
+	registerJob(jobs.Job{
+		Name:        "catalog-lint",
+		Description: "Checks apps and templates against the lint rules",
+		Interval:    time.Hour,
+		Run:         lint.NewChecker(database).Run,
+	})
+
+	// Keep templates synced from remote catalogs up to date
+	registerJob(jobs.Job{
+		Name:        "template-catalog-sync",
+		Description: "Syncs the template marketplace from signed remote catalogs",
+		Interval:    time.Hour,
+		Run:         templatesync.NewSyncer(database, outboundGuard.Client(templatesync.FetchTimeout, ssrf.Allowlist{})).Run,
+	})
+
+	jobScheduler.Start()
+	defer jobScheduler.Stop()
+
+	if sseHub != nil {
+		srv.RegisterOnShutdown(sseHub.Close)
+	}
+	boot.Ready(app.Handler())
+	slog.Info("Sortie server ready", "addr", "http://localhost"+addr)
 
-	if err := http.ListenAndServe(addr, handler); err != nil {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-serveErr:
 		slog.Error("server error", "error", err)
 		os.Exit(1)
+	case sig := <-stop:
+		slog.Info("Sortie server shutting down", "signal", sig.String(), "timeout", appConfig.ShutdownTimeout)
+	}
+
+	// Stop taking launches, which fails /readyz and rejects queued
+	// requests, and drain HTTP requests while launches in flight finish.
+	// The deferred stops then run: the job scheduler waits for running
+	// jobs, the elector releases the leader lease and the database closes
+	// last.
+	ctx, cancel := context.WithTimeout(context.Background(), appConfig.ShutdownTimeout)
+	defer cancel()
+	managerDone := make(chan error, 1)
+	go func() { managerDone <- sessionManager.Shutdown(ctx) }()
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Warn("HTTP server shutdown incomplete", "error", err)
+	}
+	if err := <-managerDone; err != nil {
+		slog.Warn("session manager shutdown incomplete", "error", err)
+	}
+}
+
+// startupAttemptTimeout bounds each attempt to reach a dependency at
+// startup, so one that hangs is retried like one that refuses.
+const startupAttemptTimeout = 10 * time.Second
+
+// mustStart runs a startup step, retrying it while it fails. It exits if
+// the step gives up after SORTIE_STARTUP_TIMEOUT, or cleanly if a
+// shutdown signal arrives first.
+func mustStart(ctx context.Context, boot *startup.Sequence, step string, fn func(context.Context) error) {
+	if err := boot.Run(ctx, step, fn); err != nil {
+		if ctx.Err() != nil {
+			slog.Info("Sortie server shutting down during startup", "step", step)
+			os.Exit(0)
+		}
+		slog.Error("startup failed", "step", step, "error", err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/rjsadow/sortie/internal/config"
+	"github.com/rjsadow/sortie/internal/storagecrypt"
+)
+
+// runRotateStorageKeys implements `sortie rotate-storage-keys`, which
+// rewraps the data keys of stored recordings and branding assets with the
+// current master key and encrypts objects stored in plaintext. Storage and
+// encryption settings come from the same environment as the server. It
+// returns the process exit code.
+func runRotateStorageKeys(args []string) int {
+	fs := flag.NewFlagSet("rotate-storage-keys", flag.ContinueOnError)
+	category := fs.String("category", "all", "Objects to rotate: recordings, branding, or all")
+	all := fs.Bool("all", false, "Also rewrap objects already on the current key ID (after rotating a Vault transit key)")
+	dryRun := fs.Bool("dry-run", false, "Report what would change without writing")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: sortie rotate-storage-keys [--category all] [--all] [--dry-run]")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Rewraps stored objects with the current storage encryption key and encrypts")
+		fmt.Fprintln(fs.Output(), "objects stored before encryption was enabled. Keep retired keys configured")
+		fmt.Fprintln(fs.Output(), "until this reports no failures.")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *category != "all" && *category != "recordings" && *category != "branding" {
+		fs.Usage()
+		return 2
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		return 1
+	}
+	c, err := newStorageCipher(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	if c == nil {
+		fmt.Fprintln(os.Stderr, "Storage encryption is disabled; set SORTIE_STORAGE_ENCRYPTION first")
+		return 1
+	}
+
+	type target struct {
+		name    string
+		objects storagecrypt.Objects
+	}
+	var targets []target
+	if *category != "branding" && cfg.VideoRecordingEnabled {
+		store, err := newRecordingStore(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		targets = append(targets, target{"recordings", store})
+	}
+	if *category != "recordings" {
+		store, err := newBrandingStore(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		targets = append(targets, target{"branding", brandingObjects{store}})
+	}
+
+	opts := storagecrypt.RotateOptions{All: *all, DryRun: *dryRun}
+	code := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CATEGORY\tREWRAPPED\tENCRYPTED\tCURRENT\tFAILED")
+	for _, t := range targets {
+		res, err := c.Rotate(t.objects, opts)
+		if err != nil {
+			w.Flush()
+			fmt.Fprintf(os.Stderr, "Failed to list %s: %v\n", t.name, err)
+			return 1
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\n", t.name, res.Rewrapped, res.Encrypted, res.Current, len(res.Failed))
+		for _, f := range res.Failed {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", t.name, f)
+			code = 1
+		}
+	}
+	w.Flush()
+
+	if *dryRun {
+		fmt.Println("\nDry run: nothing was written")
+	} else if code == 0 {
+		fmt.Printf("\nObjects are now wrapped with key %s\n", c.KeyID())
+	}
+	return code
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/rjsadow/sortie/internal/templatesync"
+)
+
+// runSignCatalog implements `sortie sign-catalog`, which signs a
+// templates.json catalog for publishing as a remote template catalog. It
+// returns the process exit code.
+func runSignCatalog(args []string) int {
+	fs := flag.NewFlagSet("sign-catalog", flag.ContinueOnError)
+	keyFile := fs.String("key", "", "PEM-encoded Ed25519 private key (required)")
+	output := fs.String("o", "", "Write the signed catalog to this file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: sortie sign-catalog --key key.pem [-o catalog.signed.json] templates.json")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Signs a template catalog for servers that sync it as a remote catalog.")
+		fmt.Fprintln(fs.Output(), "Create a key with: openssl genpkey -algorithm ed25519 -out key.pem")
+		fmt.Fprintln(fs.Output(), "and give servers its public key: openssl pkey -in key.pem -pubout")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *keyFile == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	key, err := readSigningKey(*keyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *keyFile, err)
+		return 1
+	}
+	catalog, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	signed, err := templatesync.Sign(catalog, key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", fs.Arg(0), err)
+		return 1
+	}
+	signed = append(signed, '\n')
+
+	if *output == "" {
+		os.Stdout.Write(signed)
+		return 0
+	}
+	if err := os.WriteFile(*output, signed, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// readSigningKey reads a PKCS #8 Ed25519 private key, as written by
+// `openssl genpkey -algorithm ed25519`.
+func readSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("not a PEM-encoded private key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an Ed25519 key")
+	}
+	return priv, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/rjsadow/sortie/internal/branding"
+	"github.com/rjsadow/sortie/internal/config"
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/recordings"
+	"github.com/rjsadow/sortie/internal/secrets"
+	"github.com/rjsadow/sortie/internal/storagecrypt"
+)
+
+// newRecordingStore creates the configured recording store, without
+// encryption.
+func newRecordingStore(cfg *config.Config) (recordings.RecordingStore, error) {
+	if cfg.RecordingStorageBackend == "s3" {
+		s3Store, err := recordings.NewS3Store(
+			cfg.RecordingS3Bucket,
+			cfg.RecordingS3Region,
+			cfg.RecordingS3Endpoint,
+			cfg.RecordingS3Prefix,
+			cfg.RecordingS3AccessKeyID,
+			cfg.RecordingS3SecretAccessKey,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize S3 recording store: %w", err)
+		}
+		return s3Store, nil
+	}
+	return recordings.NewLocalStore(cfg.RecordingStoragePath), nil
+}
+
+// newTenantRecordingStore creates the recording store for a tenant's own
+// storage location, reached with the server's S3 endpoint and
+// credentials, with the server's encryption at rest if enabled.
+func newTenantRecordingStore(cfg *config.Config, loc db.StorageLocation, cipher *storagecrypt.Cipher) (recordings.RecordingStore, error) {
+	region := loc.Region
+	if region == "" {
+		region = cfg.RecordingS3Region
+	}
+	s3Store, err := recordings.NewS3Store(
+		loc.Bucket,
+		region,
+		cfg.RecordingS3Endpoint,
+		loc.Prefix,
+		cfg.RecordingS3AccessKeyID,
+		cfg.RecordingS3SecretAccessKey,
+	)
+	if err != nil {
+		return nil, err
+	}
+	s3Store.SetKMSKey(loc.KMSKeyID)
+	if cipher != nil {
+		return recordings.NewEncryptedStore(s3Store, cipher), nil
+	}
+	return s3Store, nil
+}
+
+// newBrandingStore creates the configured branding asset store, without
+// encryption.
+func newBrandingStore(cfg *config.Config) (branding.Store, error) {
+	if cfg.BrandingStorageBackend == "s3" {
+		client, err := recordings.NewS3Client(
+			cfg.RecordingS3Region,
+			cfg.RecordingS3Endpoint,
+			cfg.RecordingS3AccessKeyID,
+			cfg.RecordingS3SecretAccessKey,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize S3 branding store: %w", err)
+		}
+		return branding.NewS3Store(client, cfg.RecordingS3Bucket, cfg.BrandingS3Prefix), nil
+	}
+	return branding.NewLocalStore(cfg.BrandingStoragePath), nil
+}
+
+// newStorageCipher creates the cipher for encryption at rest, or returns
+// nil if storage encryption is disabled.
+func newStorageCipher(cfg *config.Config) (*storagecrypt.Cipher, error) {
+	var c *storagecrypt.Cipher
+	switch cfg.StorageEncryption {
+	case "local":
+		keys, err := storagecrypt.ParseKeys(cfg.StorageEncryptionKeys)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SORTIE_STORAGE_ENCRYPTION_KEYS: %w", err)
+		}
+		c = storagecrypt.New(keys)
+	case "vault":
+		sc := secrets.LoadConfig()
+		transit, err := storagecrypt.NewVaultTransit(sc.VaultAddr, sc.VaultToken, sc.VaultNamespace,
+			cfg.StorageEncryptionTransitMount, cfg.StorageEncryptionTransitKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vault storage encryption config: %w", err)
+		}
+		c = storagecrypt.New(transit)
+	default:
+		return nil, nil
+	}
+	if cfg.StorageEncryptionAllowPlaintext {
+		c.AllowPlaintext()
+	}
+	return c, nil
+}
+
+// brandingObjects adapts a branding store for key rotation. Encrypted
+// objects are stored as application/octet-stream.
+type brandingObjects struct {
+	branding.Store
+}
+
+func (b brandingObjects) Put(path string, r io.Reader) error {
+	return b.Store.Put(path, "application/octet-stream", r)
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/rjsadow/sortie/tests/integration/testutil"
@@ -39,6 +40,100 @@ func TestAdmin_UpdateSettings(t *testing.T) {
 	}
 }
 
+func TestAdmin_TokenPolicySettings(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	t.Run("tenant token policy validated", func(t *testing.T) {
+		body := []byte(`{"name":"Default","settings":{"token_policy":{"access_expiry_minutes":-1}}}`)
+		resp := testutil.AuthPut(t, ts.URL+"/api/admin/tenants/default", ts.AdminToken, body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("effective lifetimes exposed", func(t *testing.T) {
+		body := []byte(`{"name":"Default","settings":{"token_policy":{"access_expiry_minutes":30,"roles":{"admin":{"access_expiry_minutes":5,"rotate_refresh_tokens":true}}}}}`)
+		resp := testutil.AuthPut(t, ts.URL+"/api/admin/tenants/default", ts.AdminToken, body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 updating tenant, got %d", resp.StatusCode)
+		}
+
+		resp = testutil.AuthGet(t, ts.URL+"/api/admin/settings", ts.AdminToken)
+		var settings struct {
+			TokenPolicy struct {
+				Global struct {
+					AccessExpiryMinutes int `json:"access_expiry_minutes"`
+				} `json:"global"`
+				Tenants []struct {
+					TenantID string `json:"tenant_id"`
+					Default  struct {
+						AccessExpiryMinutes int `json:"access_expiry_minutes"`
+					} `json:"default"`
+					Roles map[string]struct {
+						AccessExpiryMinutes int  `json:"access_expiry_minutes"`
+						RotateRefreshTokens bool `json:"rotate_refresh_tokens"`
+					} `json:"roles"`
+				} `json:"tenants"`
+			} `json:"token_policy"`
+		}
+		testutil.ReadJSON(t, resp, &settings)
+
+		if settings.TokenPolicy.Global.AccessExpiryMinutes == 0 {
+			t.Error("expected global access expiry in token_policy")
+		}
+		if len(settings.TokenPolicy.Tenants) != 1 {
+			t.Fatalf("expected 1 tenant policy, got %d", len(settings.TokenPolicy.Tenants))
+		}
+		tenant := settings.TokenPolicy.Tenants[0]
+		if tenant.TenantID != "default" || tenant.Default.AccessExpiryMinutes != 30 {
+			t.Errorf("unexpected tenant policy: %+v", tenant)
+		}
+		admin := tenant.Roles["admin"]
+		if admin.AccessExpiryMinutes != 5 || !admin.RotateRefreshTokens {
+			t.Errorf("unexpected admin role policy: %+v", admin)
+		}
+	})
+
+	t.Run("token_policy is read-only", func(t *testing.T) {
+		body := []byte(`{"token_policy":"{}"}`)
+		resp := testutil.AuthPut(t, ts.URL+"/api/admin/settings", ts.AdminToken, body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("update_channel must be known", func(t *testing.T) {
+		body := []byte(`{"update_channel":"nightly"}`)
+		resp := testutil.AuthPut(t, ts.URL+"/api/admin/settings", ts.AdminToken, body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("rate limits must be valid", func(t *testing.T) {
+		for _, body := range []string{`{"auth_rate_limit":"-1"}`, `{"session_rate_burst":"0"}`, `{"auth_rate_burst":"lots"}`} {
+			resp := testutil.AuthPut(t, ts.URL+"/api/admin/settings", ts.AdminToken, []byte(body))
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("%s: expected 400, got %d", body, resp.StatusCode)
+			}
+		}
+
+		resp := testutil.AuthPut(t, ts.URL+"/api/admin/settings", ts.AdminToken, []byte(`{"auth_rate_limit":"0","session_rate_burst":"20"}`))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Errorf("expected 204, got %d", resp.StatusCode)
+		}
+	})
+}
+
 func TestAdmin_ListUsers(t *testing.T) {
 	ts := testutil.NewTestServer(t)
 
@@ -49,6 +144,7 @@ func TestAdmin_ListUsers(t *testing.T) {
 		t.Errorf("expected 200, got %d", resp.StatusCode)
 	}
 
+	// Without pagination parameters the list is a plain array
 	var users []map[string]interface{}
 	json.NewDecoder(resp.Body).Decode(&users)
 
@@ -63,6 +159,125 @@ func TestAdmin_ListUsers(t *testing.T) {
 	if !found {
 		t.Error("expected admin user in user list")
 	}
+
+	// Pagination parameters return a page with the total
+	var page struct {
+		Users []map[string]interface{} `json:"users"`
+		Total int                      `json:"total"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/users?limit=1", ts.AdminToken), &page)
+	if page.Total != len(users) || len(page.Users) != 1 {
+		t.Errorf("expected total=%d len=1, got total=%d len=%d", len(users), page.Total, len(page.Users))
+	}
+}
+
+func TestAdmin_ListUsersSearchAndPaginate(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "searchalice", "password123", []string{"user"})
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "searchbob", "password123", []string{"user"})
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "othercarol", "password123", []string{"admin", "user"})
+
+	type usersPage struct {
+		Users []map[string]interface{} `json:"users"`
+		Total int                      `json:"total"`
+	}
+	get := func(query string) usersPage {
+		t.Helper()
+		resp := testutil.AuthGet(t, ts.URL+"/api/admin/users?"+query, ts.AdminToken)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET ?%s: expected 200, got %d", query, resp.StatusCode)
+		}
+		var page usersPage
+		json.NewDecoder(resp.Body).Decode(&page)
+		return page
+	}
+
+	page := get("q=SEARCH&sort=username&offset=0")
+	if page.Total != 2 || len(page.Users) != 2 {
+		t.Fatalf("expected 2 search matches, got total=%d len=%d", page.Total, len(page.Users))
+	}
+	if page.Users[0]["username"] != "searchalice" {
+		t.Errorf("expected searchalice first, got %v", page.Users[0]["username"])
+	}
+
+	page = get("q=search&sort=-username&limit=1&offset=0")
+	if page.Total != 2 || len(page.Users) != 1 {
+		t.Fatalf("expected total=2 len=1, got total=%d len=%d", page.Total, len(page.Users))
+	}
+	if page.Users[0]["username"] != "searchbob" {
+		t.Errorf("expected searchbob first in descending order, got %v", page.Users[0]["username"])
+	}
+
+	page = get("role=admin&q=carol&limit=10")
+	if page.Total != 1 {
+		t.Errorf("expected 1 admin matching carol, got %d", page.Total)
+	}
+
+	resp := testutil.AuthGet(t, ts.URL+"/api/admin/users?sort=password_hash", ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid sort, got %d", resp.StatusCode)
+	}
+}
+
+func TestAdmin_DisabledUserAndEnable(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	userID := testutil.CreateUser(t, ts.URL, ts.AdminToken, "dormant", "password123", []string{"user"})
+	testutil.LoginAs(t, ts.URL, "dormant", "password123")
+
+	// Login records last_login_at, which the admin list exposes
+	resp := testutil.AuthGet(t, ts.URL+"/api/admin/users?q=dormant&limit=10", ts.AdminToken)
+	var page struct {
+		Users []map[string]interface{} `json:"users"`
+	}
+	testutil.ReadJSON(t, resp, &page)
+	if len(page.Users) != 1 {
+		t.Fatalf("expected 1 user, got %d", len(page.Users))
+	}
+	if page.Users[0]["last_login_at"] == nil {
+		t.Error("expected last_login_at to be set after login")
+	}
+	if page.Users[0]["disabled"] != false {
+		t.Errorf("expected disabled=false, got %v", page.Users[0]["disabled"])
+	}
+
+	if err := ts.DB.SetUserDisabled(userID, true); err != nil {
+		t.Fatalf("SetUserDisabled: %v", err)
+	}
+
+	login := func() int {
+		t.Helper()
+		body := `{"username":"dormant","password":"password123"}`
+		resp, err := http.Post(ts.URL+"/api/auth/login", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("login request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := login(); code != http.StatusForbidden {
+		t.Errorf("expected 403 for disabled account, got %d", code)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/users/"+userID+"/enable", ts.AdminToken, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204 from enable, got %d", resp.StatusCode)
+	}
+
+	if code := login(); code != http.StatusOK {
+		t.Errorf("expected 200 after re-enable, got %d", code)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/users/nonexistent/enable", ts.AdminToken, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown user, got %d", resp.StatusCode)
+	}
 }
 
 func TestAdmin_CreateUser(t *testing.T) {
@@ -85,6 +300,23 @@ func TestAdmin_CreateUser(t *testing.T) {
 	}
 }
 
+func TestAdmin_SyncUserWithoutSSO(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	userID := testutil.CreateUser(t, ts.URL, ts.AdminToken, "syncuser", "password123", []string{"user"})
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/admin/users/"+userID+"/sync", ts.AdminToken, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected 409 when SSO is not configured, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthGet(t, ts.URL+"/api/admin/users/"+userID+"/sync", ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", resp.StatusCode)
+	}
+}
+
 func TestAdmin_DeleteUser(t *testing.T) {
 	ts := testutil.NewTestServer(t)
 
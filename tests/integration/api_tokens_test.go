@@ -0,0 +1,126 @@
+package integration
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+type apiToken struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Prefix string   `json:"prefix"`
+	Scopes []string `json:"scopes"`
+	Token  string   `json:"token"`
+}
+
+func createAPIToken(t *testing.T, ts *testutil.TestServer, body string) apiToken {
+	t.Helper()
+	resp := testutil.AuthPost(t, ts.URL+"/api/auth/tokens", ts.AdminToken, []byte(body))
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create token: status %d: %s", resp.StatusCode, testutil.ReadBody(t, resp))
+	}
+	var tok apiToken
+	testutil.ReadJSON(t, resp, &tok)
+	return tok
+}
+
+func statusOf(resp *http.Response) int {
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestAPITokens(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	createContainerApp(t, ts, "pat-app")
+
+	ci := createAPIToken(t, ts, `{"name":"ci","scopes":["read","write"],"expires_in_days":7}`)
+	if !strings.HasPrefix(ci.Token, "sortie_pat_") || !strings.HasPrefix(ci.Token, ci.Prefix) {
+		t.Fatalf("token = %q, prefix = %q", ci.Token, ci.Prefix)
+	}
+
+	t.Run("authenticates like a JWT", func(t *testing.T) {
+		if code := statusOf(testutil.AuthGet(t, ts.URL+"/api/sessions", ci.Token)); code != http.StatusOK {
+			t.Errorf("list sessions: status %d, want 200", code)
+		}
+		resp := testutil.AuthPost(t, ts.URL+"/api/sessions", ci.Token, []byte(`{"app_id":"pat-app"}`))
+		if code := statusOf(resp); code != http.StatusCreated {
+			t.Errorf("create session: status %d, want 201", code)
+		}
+		if code := statusOf(testutil.AuthGet(t, ts.URL+"/api/auth/me", ci.Token)); code != http.StatusOK {
+			t.Errorf("auth/me: status %d, want 200", code)
+		}
+	})
+
+	t.Run("scopes", func(t *testing.T) {
+		readOnly := createAPIToken(t, ts, `{"name":"reports","scopes":["read"]}`)
+		resp := testutil.AuthPost(t, ts.URL+"/api/sessions", readOnly.Token, []byte(`{"app_id":"pat-app"}`))
+		if code := statusOf(resp); code != http.StatusForbidden {
+			t.Errorf("read-only token creating a session: status %d, want 403", code)
+		}
+
+		// The admin user's token needs the admin scope for admin routes
+		if code := statusOf(testutil.AuthGet(t, ts.URL+"/api/admin/settings", ci.Token)); code != http.StatusForbidden {
+			t.Errorf("admin route without admin scope: status %d, want 403", code)
+		}
+		admin := createAPIToken(t, ts, `{"name":"ops","scopes":["read","admin"]}`)
+		if code := statusOf(testutil.AuthGet(t, ts.URL+"/api/admin/settings", admin.Token)); code != http.StatusOK {
+			t.Errorf("admin route with admin scope: status %d, want 200", code)
+		}
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		for _, body := range []string{
+			`{"name":"","scopes":["read"]}`,
+			`{"name":"x","scopes":["delete"]}`,
+			`{"name":"x","scopes":[]}`,
+			`{"name":"x","scopes":["read"],"expires_in_days":1000}`,
+		} {
+			resp := testutil.AuthPost(t, ts.URL+"/api/auth/tokens", ts.AdminToken, []byte(body))
+			if code := statusOf(resp); code != http.StatusBadRequest {
+				t.Errorf("%s: status %d, want 400", body, code)
+			}
+		}
+	})
+
+	t.Run("tokens cannot manage tokens", func(t *testing.T) {
+		if code := statusOf(testutil.AuthGet(t, ts.URL+"/api/auth/tokens", ci.Token)); code != http.StatusForbidden {
+			t.Errorf("list with API token: status %d, want 403", code)
+		}
+	})
+
+	t.Run("tokens cannot manage devices", func(t *testing.T) {
+		if code := statusOf(testutil.AuthGet(t, ts.URL+"/api/auth/devices", ci.Token)); code != http.StatusForbidden {
+			t.Errorf("list devices with API token: status %d, want 403", code)
+		}
+		if code := statusOf(testutil.AuthDelete(t, ts.URL+"/api/auth/devices/any", ci.Token)); code != http.StatusForbidden {
+			t.Errorf("revoke device with API token: status %d, want 403", code)
+		}
+	})
+
+	t.Run("list and revoke", func(t *testing.T) {
+		resp := testutil.AuthGet(t, ts.URL+"/api/auth/tokens", ts.AdminToken)
+		var tokens []apiToken
+		testutil.ReadJSON(t, resp, &tokens)
+		if len(tokens) != 3 {
+			t.Fatalf("listed %d tokens, want 3", len(tokens))
+		}
+		for _, tok := range tokens {
+			if tok.Token != "" {
+				t.Errorf("listing exposed the secret of token %s", tok.ID)
+			}
+		}
+
+		if code := statusOf(testutil.AuthDelete(t, ts.URL+"/api/auth/tokens/"+ci.ID, ts.AdminToken)); code != http.StatusNoContent {
+			t.Fatalf("revoke: status %d, want 204", code)
+		}
+		if code := statusOf(testutil.AuthGet(t, ts.URL+"/api/sessions", ci.Token)); code != http.StatusUnauthorized {
+			t.Errorf("revoked token: status %d, want 401", code)
+		}
+		if code := statusOf(testutil.AuthDelete(t, ts.URL+"/api/auth/tokens/"+ci.ID, ts.AdminToken)); code != http.StatusNotFound {
+			t.Errorf("revoke again: status %d, want 404", code)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestAdminAPIUsage(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	ci := createAPIToken(t, ts, `{"name":"ci","scopes":["read"]}`)
+
+	for range 3 {
+		statusOf(testutil.AuthGet(t, ts.URL+"/api/apps", ci.Token))
+	}
+	statusOf(testutil.AuthGet(t, ts.URL+"/api/auth/tokens/"+ci.ID, ts.AdminToken))
+	statusOf(testutil.AuthGet(t, ts.URL+"/api/apps", "")) // Not metered
+	if err := ts.APIUsage.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	var usage struct {
+		Bucket        string                 `json:"bucket"`
+		Buckets       []db.APIUsageBucket    `json:"buckets"`
+		TopEndpoints  []db.APIEndpointUsage  `json:"top_endpoints"`
+		TopPrincipals []db.APIPrincipalUsage `json:"top_principals"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/api-usage", ts.AdminToken), &usage)
+	if usage.Bucket != "hour" || len(usage.Buckets) != 1 {
+		t.Fatalf("buckets = %+v, want one hour", usage.Buckets)
+	}
+	if len(usage.TopEndpoints) == 0 || usage.TopEndpoints[0].Endpoint != "/api/apps" || usage.TopEndpoints[0].Requests != 3 {
+		t.Errorf("top endpoints = %+v, want /api/apps with 3 requests first", usage.TopEndpoints)
+	}
+	if len(usage.TopPrincipals) == 0 {
+		t.Fatal("no top principals")
+	}
+	if p := usage.TopPrincipals[0]; p.TokenID != ci.ID || p.TokenName != "ci" || p.Username != testutil.TestAdminUsername || p.Requests != 3 {
+		t.Errorf("top principal = %+v, want the ci token with 3 requests", p)
+	}
+	for _, e := range usage.TopEndpoints {
+		if e.Endpoint == "/api/auth/tokens/"+ci.ID {
+			t.Errorf("endpoint %q was not normalized", e.Endpoint)
+		}
+	}
+
+	// Filter by token, by day
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/api-usage?bucket=day&token_id="+ci.ID, ts.AdminToken), &usage)
+	if usage.Bucket != "day" || len(usage.Buckets) == 0 || usage.Buckets[len(usage.Buckets)-1].Requests != 3 {
+		t.Errorf("daily buckets = %+v, want 3 requests today", usage.Buckets)
+	}
+
+	for _, query := range []string{"?bucket=week", "?from=yesterday", "?limit=0", "?from=2020-01-01T00:00:00Z"} {
+		if code := statusOf(testutil.AuthGet(t, ts.URL+"/api/admin/api-usage"+query, ts.AdminToken)); code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, code)
+		}
+	}
+
+	// Users without the admin role cannot read usage
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "usage-user", "password123", []string{"user"})
+	userToken := testutil.LoginAs(t, ts.URL, "usage-user", "password123")
+	if code := statusOf(testutil.AuthGet(t, ts.URL+"/api/admin/api-usage", userToken)); code != http.StatusForbidden {
+		t.Errorf("non-admin: status %d, want 403", code)
+	}
+}
@@ -0,0 +1,84 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/attestation"
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestAppContainers(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken, []byte(`{
+		"id":"ide","name":"IDE","launch_type":"container","container_image":"nginx:latest","attest_sessions":true,
+		"containers":[{"name":"db","image":"postgres:16","env":{"POSTGRES_PASSWORD":"dev"},"ports":[5432],
+			"volume_mounts":[{"name":"sockets","mount_path":"/var/run/postgresql"}]}],
+		"shared_volumes":[{"name":"sockets","mount_path":"/run/db"}]}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+
+	var app db.Application
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/apps/ide", ts.AdminToken), &app)
+	if len(app.Containers) != 1 || app.Containers[0].Image != "postgres:16" || len(app.SharedVolumes) != 1 {
+		t.Fatalf("app = %+v, want its container and shared volume", app)
+	}
+
+	for name, body := range map[string]string{
+		"reserved port":  `{"id":"ide","name":"IDE","launch_type":"container","container_image":"nginx:latest","containers":[{"name":"db","image":"postgres:16","ports":[5900]}]}`,
+		"duplicate name": `{"id":"ide","name":"IDE","launch_type":"container","container_image":"nginx:latest","containers":[{"name":"db","image":"a"},{"name":"db","image":"b"}]}`,
+		"bad app mount":  `{"id":"ide","name":"IDE","launch_type":"container","container_image":"nginx:latest","shared_volumes":[{"name":"data","mount_path":"/workspace"}]}`,
+	} {
+		resp = testutil.AuthPut(t, ts.URL+"/api/apps/ide", ts.AdminToken, []byte(body))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, resp.StatusCode)
+		}
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/appspecs", ts.AdminToken,
+		[]byte(`{"id":"ide-spec","name":"IDE","image":"ide:latest","containers":[{"name":"db","image":""}]}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("app spec container without image: expected 400, got %d", resp.StatusCode)
+	}
+
+	// The containers run in the session's workload next to the app
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, []byte(`{"app_id":"ide"}`))
+	if resp.StatusCode != http.StatusCreated {
+		resp.Body.Close()
+		t.Fatalf("create session: expected 201, got %d", resp.StatusCode)
+	}
+	var session struct {
+		ID string `json:"id"`
+	}
+	testutil.ReadJSON(t, resp, &session)
+	waitForRunning(t, ts, session.ID)
+
+	resp = testutil.AuthDelete(t, ts.URL+"/api/sessions/"+session.ID, ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		t.Fatalf("terminate session: got %d", resp.StatusCode)
+	}
+
+	var summaries []struct {
+		Report *attestation.Report `json:"report"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/sessions/"+session.ID+"/attestations", ts.AdminToken), &summaries)
+	if len(summaries) != 1 || summaries[0].Report == nil {
+		t.Fatalf("expected one attestation, got %+v", summaries)
+	}
+	var found bool
+	for _, c := range summaries[0].Report.Containers {
+		if c.Name == "app-db" && c.Image == "postgres:16" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("report containers = %+v, want app-db", summaries[0].Report.Containers)
+	}
+}
@@ -0,0 +1,57 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestAppCredentials_OnlyAdminsSet(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "author", "pass123", []string{"app-author"})
+	authorToken := testutil.LoginAs(t, ts.URL, "author", "pass123")
+
+	body := []byte(`{"id":"win-app","name":"Win","launch_type":"container","os_type":"windows","container_image":"win:latest","credentials":{"username":"svc-erp","password_key":"erp/password"}}`)
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", authorToken, body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for app author setting credentials, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"bad-app","name":"Bad","launch_type":"container","os_type":"windows","container_image":"win:latest","credentials":{"username":"svc-erp"}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for credentials without a password key, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken, body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+
+	// The author's edits keep the admin's reference
+	resp = testutil.AuthPut(t, ts.URL+"/api/apps/win-app", authorToken,
+		[]byte(`{"name":"Renamed","launch_type":"container","os_type":"windows","container_image":"win:latest"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var app struct {
+		Credentials map[string]string `json:"credentials"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/apps/win-app", ts.AdminToken), &app)
+	if app.Credentials["password_key"] != "erp/password" || app.Credentials["username"] != "svc-erp" {
+		t.Errorf("expected credentials to be kept, got %v", app.Credentials)
+	}
+
+	resp = testutil.AuthPut(t, ts.URL+"/api/apps/win-app", authorToken,
+		[]byte(`{"name":"Renamed","launch_type":"container","os_type":"windows","container_image":"win:latest","credentials":{"password_key":"jwt-secret"}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for app author changing credentials, got %d", resp.StatusCode)
+	}
+}
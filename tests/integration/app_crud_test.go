@@ -219,3 +219,46 @@ func TestAppCRUD_DeleteApp(t *testing.T) {
 		t.Errorf("expected 404 after delete, got %d", resp.StatusCode)
 	}
 }
+
+func TestAppCRUD_DNSConfig(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"bad-dns","name":"Bad","launch_type":"container","container_image":"nginx:latest","dns":{"policy":"None"}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for policy None without nameservers, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"dns-app","name":"DNS App","launch_type":"container","container_image":"nginx:latest",
+		"dns":{"hostname":"workstation","searches":["corp.example.com"],"host_aliases":[{"ip":"10.1.2.3","hostnames":["license.corp.example.com"]}]}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPut(t, ts.URL+"/api/apps/dns-app", ts.AdminToken,
+		[]byte(`{"name":"DNS App","launch_type":"container","container_image":"nginx:latest","dns":{"nameservers":["not-an-ip"]}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid nameserver, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, []byte(`{"app_id":"dns-app"}`))
+	var session map[string]interface{}
+	testutil.ReadJSON(t, resp, &session)
+	sessionID, _ := session["id"].(string)
+	if sessionID == "" {
+		t.Fatalf("expected session, got %v", session)
+	}
+
+	workload := ts.Runner.Workload("session-" + sessionID)
+	if workload == nil {
+		t.Fatal("expected workload for session")
+	}
+	dns := workload.Config.DNS
+	if dns == nil || dns.Hostname != "workstation" || len(dns.HostAliases) != 1 || dns.HostAliases[0].IP != "10.1.2.3" {
+		t.Errorf("expected app DNS config on workload, got %+v", dns)
+	}
+}
@@ -0,0 +1,118 @@
+package integration
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/attestation"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestAttestation_SignedReportOnTerminate(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"audited","name":"Audited","launch_type":"container","container_image":"nginx:latest","attest_sessions":true}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, []byte(`{"app_id":"audited"}`))
+	var session map[string]interface{}
+	testutil.ReadJSON(t, resp, &session)
+	sessionID, _ := session["id"].(string)
+	if sessionID == "" {
+		t.Fatalf("no session ID in response: %v", session)
+	}
+	waitForRunning(t, ts, sessionID)
+
+	resp = testutil.AuthDelete(t, ts.URL+"/api/sessions/"+sessionID, ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected session delete to succeed, got %d", resp.StatusCode)
+	}
+
+	base := ts.URL + "/api/admin/sessions/" + sessionID + "/attestations"
+	var summaries []struct {
+		ID     string              `json:"id"`
+		KeyID  string              `json:"key_id"`
+		Report *attestation.Report `json:"report"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, base, ts.AdminToken), &summaries)
+	if len(summaries) != 1 {
+		t.Fatalf("expected one attestation, got %d", len(summaries))
+	}
+	if r := summaries[0].Report; r == nil || r.App.ID != "audited" || r.User.Username != testutil.TestAdminUsername {
+		t.Errorf("unexpected report: %+v", summaries[0].Report)
+	}
+
+	var key map[string]string
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/attestation-key", ts.AdminToken), &key)
+	if key["key_id"] != summaries[0].KeyID || key["algorithm"] != "ed25519" {
+		t.Errorf("unexpected attestation key: %v", key)
+	}
+	pub, err := base64.StdEncoding.DecodeString(key["public_key"])
+	if err != nil {
+		t.Fatalf("invalid public key: %v", err)
+	}
+
+	// The downloaded envelope verifies against the published key
+	resp = testutil.AuthGet(t, base+"/"+summaries[0].ID, ts.AdminToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 downloading attestation, got %d", resp.StatusCode)
+	}
+	var env attestation.Envelope
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		t.Fatalf("failed to decode envelope: %v", err)
+	}
+	resp.Body.Close()
+	report, err := attestation.Verify(&env, ed25519.PublicKey(pub))
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if report.SessionID != sessionID || len(report.Containers) == 0 || report.Containers[0].Image != "nginx:latest" {
+		t.Errorf("unexpected verified report: %+v", report)
+	}
+
+	resp = testutil.AuthGet(t, base+"/missing", ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for missing attestation, got %d", resp.StatusCode)
+	}
+
+	// Non-admins cannot read attestations
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "viewer", "pass123", []string{"user"})
+	viewerToken := testutil.LoginAs(t, ts.URL, "viewer", "pass123")
+	resp = testutil.AuthGet(t, base, viewerToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for non-admin, got %d", resp.StatusCode)
+	}
+}
+
+func TestAttestation_NotStoredWhenDisabled(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"plain","name":"Plain","launch_type":"container","container_image":"nginx:latest"}`))
+	resp.Body.Close()
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, []byte(`{"app_id":"plain"}`))
+	var session map[string]interface{}
+	testutil.ReadJSON(t, resp, &session)
+	sessionID, _ := session["id"].(string)
+	waitForRunning(t, ts, sessionID)
+
+	resp = testutil.AuthDelete(t, ts.URL+"/api/sessions/"+sessionID, ts.AdminToken)
+	resp.Body.Close()
+
+	var summaries []map[string]interface{}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/sessions/"+sessionID+"/attestations", ts.AdminToken), &summaries)
+	if len(summaries) != 0 {
+		t.Errorf("expected no attestations, got %d", len(summaries))
+	}
+}
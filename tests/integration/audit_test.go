@@ -156,3 +156,49 @@ func TestAudit_Filters(t *testing.T) {
 		t.Error("expected users in filters")
 	}
 }
+
+func TestAudit_RecordsRequestID(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	body := `{"id":"traced-app","name":"Traced App","url":"https://example.com","launch_type":"url"}`
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/apps", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+ts.AdminToken)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-ID", "support-case-17")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("X-Request-ID"); got != "support-case-17" {
+		t.Errorf("expected X-Request-ID to be echoed, got %q", got)
+	}
+
+	resp = testutil.AuthGet(t, ts.URL+"/api/audit?request_id=support-case-17", ts.AdminToken)
+	var page struct {
+		Logs []struct {
+			Action    string `json:"action"`
+			RequestID string `json:"request_id"`
+		} `json:"logs"`
+	}
+	testutil.ReadJSON(t, resp, &page)
+	if len(page.Logs) != 1 || page.Logs[0].Action != "CREATE_APP" || page.Logs[0].RequestID != "support-case-17" {
+		t.Errorf("expected one CREATE_APP entry for the request, got %+v", page.Logs)
+	}
+}
+
+func TestAudit_ErrorBodyIncludesRequestID(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp := testutil.AuthGet(t, ts.URL+"/api/apps/does-not-exist", ts.AdminToken)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", resp.StatusCode)
+	}
+	id := resp.Header.Get("X-Request-ID")
+	if id == "" {
+		t.Fatal("expected X-Request-ID header")
+	}
+	if got := testutil.ReadBody(t, resp); !strings.Contains(got, "Request ID: "+id) {
+		t.Errorf("expected error body to include request ID %q, got %q", id, got)
+	}
+}
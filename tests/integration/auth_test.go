@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"sync"
 	"testing"
 
 	"github.com/rjsadow/sortie/tests/integration/testutil"
@@ -218,3 +219,179 @@ func TestAuth_RegisterDisabled(t *testing.T) {
 		t.Errorf("expected 403, got %d", resp.StatusCode)
 	}
 }
+
+func TestAuth_RegisterConcurrentDuplicate(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	const n = 5
+	codes := make(chan int, n)
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body := `{"username":"racer","password":"password123","email":"racer@test.local"}`
+			resp, err := http.Post(ts.URL+"/api/auth/register", "application/json", bytes.NewBufferString(body))
+			if err != nil {
+				t.Errorf("request failed: %v", err)
+				return
+			}
+			resp.Body.Close()
+			codes <- resp.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	counts := map[int]int{}
+	for code := range codes {
+		counts[code]++
+	}
+	if counts[http.StatusCreated] != 1 || counts[http.StatusConflict] != n-1 {
+		t.Errorf("status counts = %v, want one 201 and %d 409s", counts, n-1)
+	}
+}
+
+func TestAuth_DevicesListAndRevoke(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "roamer", "password123", []string{"user"})
+
+	login := func(userAgent string) (access, refresh string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/auth/login",
+			bytes.NewBufferString(`{"username":"roamer","password":"password123"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", userAgent)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("login failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var result struct {
+			AccessToken  string `json:"access_token"`
+			RefreshToken string `json:"refresh_token"`
+		}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return result.AccessToken, result.RefreshToken
+	}
+
+	laptopToken, _ := login("LaptopBrowser/1.0")
+	_, libraryRefresh := login("LibraryKiosk/2.0")
+
+	type device struct {
+		ID        string `json:"id"`
+		UserAgent string `json:"user_agent"`
+		IPAddress string `json:"ip_address"`
+		IssuedAt  string `json:"issued_at"`
+		Current   bool   `json:"current"`
+	}
+	resp := testutil.AuthGet(t, ts.URL+"/api/auth/devices", laptopToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var devices []device
+	testutil.ReadJSON(t, resp, &devices)
+	if len(devices) != 2 {
+		t.Fatalf("expected 2 devices, got %d", len(devices))
+	}
+
+	var libraryID string
+	for _, d := range devices {
+		if d.IPAddress == "" || d.IssuedAt == "" {
+			t.Errorf("device missing ip or issued_at: %+v", d)
+		}
+		switch d.UserAgent {
+		case "LaptopBrowser/1.0":
+			if !d.Current {
+				t.Error("expected laptop device to be marked current")
+			}
+		case "LibraryKiosk/2.0":
+			if d.Current {
+				t.Error("library device should not be current")
+			}
+			libraryID = d.ID
+		}
+	}
+	if libraryID == "" {
+		t.Fatal("library device not listed")
+	}
+
+	// Another user cannot revoke it
+	resp = testutil.AuthDelete(t, ts.URL+"/api/auth/devices/"+libraryID, ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 revoking another user's device, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthDelete(t, ts.URL+"/api/auth/devices/"+libraryID, laptopToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", resp.StatusCode)
+	}
+
+	// The revoked device can no longer refresh
+	refreshBody, _ := json.Marshal(map[string]string{"refresh_token": libraryRefresh})
+	refreshResp, err := http.Post(ts.URL+"/api/auth/refresh", "application/json", bytes.NewReader(refreshBody))
+	if err != nil {
+		t.Fatalf("refresh request failed: %v", err)
+	}
+	refreshResp.Body.Close()
+	if refreshResp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 refreshing revoked device, got %d", refreshResp.StatusCode)
+	}
+
+	resp = testutil.AuthGet(t, ts.URL+"/api/auth/devices", laptopToken)
+	testutil.ReadJSON(t, resp, &devices)
+	if len(devices) != 1 {
+		t.Errorf("expected 1 device after revoke, got %d", len(devices))
+	}
+}
+
+func TestAuth_RateLimits(t *testing.T) {
+	// The server logs in as admin once at startup, leaving one login
+	ts := testutil.NewTestServer(t, testutil.WithRateLimits(1, 2, 1, 1))
+
+	login := func() *http.Response {
+		body := `{"username":"admin","password":"admin123"}`
+		resp, err := http.Post(ts.URL+"/api/auth/login", "application/json", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	if resp := login(); resp.StatusCode != http.StatusOK {
+		t.Fatalf("login within burst: status %d, want 200", resp.StatusCode)
+	}
+	resp := login()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("login over limit: status %d, want 429", resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("429 response missing Retry-After")
+	}
+
+	t.Run("session creation", func(t *testing.T) {
+		body := []byte(`{"id":"rl-app","name":"RL App","launch_type":"container","container_image":"nginx:latest"}`)
+		testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken, body).Body.Close()
+
+		create := func() int {
+			resp := testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, []byte(`{"app_id":"rl-app"}`))
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+		if code := create(); code != http.StatusCreated {
+			t.Fatalf("first session: status %d, want 201", code)
+		}
+		if code := create(); code != http.StatusTooManyRequests {
+			t.Fatalf("second session: status %d, want 429", code)
+		}
+
+		resp := testutil.AuthGet(t, ts.URL+"/api/sessions", ts.AdminToken)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("listing sessions: status %d, want 200", resp.StatusCode)
+		}
+	})
+}
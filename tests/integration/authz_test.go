@@ -0,0 +1,86 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestAuthz(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "alice", "alice-pass-123", []string{"user"})
+	aliceToken := testutil.LoginAs(t, ts.URL, "alice", "alice-pass-123")
+
+	// check calls /api/authz the way an ingress controller does, passing on
+	// the original request's cookie or Authorization header
+	check := func(query string, headers map[string]string, cookie string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/authz"+query, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "sortie_access_token", Value: cookie})
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := check("", nil, aliceToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("cookie: status %d, want 200", resp.StatusCode)
+	}
+	if got := resp.Header.Get("X-Auth-Request-User"); got != "alice" {
+		t.Errorf("X-Auth-Request-User = %q, want alice", got)
+	}
+	if got := resp.Header.Get("X-Auth-Request-Groups"); got != "user" {
+		t.Errorf("X-Auth-Request-Groups = %q, want user", got)
+	}
+	if resp.Header.Get("X-Auth-Request-User-Id") == "" {
+		t.Error("X-Auth-Request-User-Id is empty")
+	}
+	if got := resp.Header.Get("X-Auth-Request-Email"); got != "alice@test.local" {
+		t.Errorf("X-Auth-Request-Email = %q, want alice@test.local", got)
+	}
+
+	if code := check("", map[string]string{"Authorization": "Bearer " + aliceToken}, "").StatusCode; code != http.StatusOK {
+		t.Errorf("bearer token: status %d, want 200", code)
+	}
+	if code := check("", nil, "").StatusCode; code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated: status %d, want 401", code)
+	}
+	if code := check("", nil, "not-a-token").StatusCode; code != http.StatusUnauthorized {
+		t.Errorf("invalid token: status %d, want 401", code)
+	}
+
+	t.Run("roles", func(t *testing.T) {
+		if code := check("?roles=admin", nil, aliceToken).StatusCode; code != http.StatusForbidden {
+			t.Errorf("user without role: status %d, want 403", code)
+		}
+		if code := check("?roles=admin,%20user", nil, aliceToken).StatusCode; code != http.StatusOK {
+			t.Errorf("user with one of the roles: status %d, want 200", code)
+		}
+		if code := check("?roles=admin", nil, ts.AdminToken).StatusCode; code != http.StatusOK {
+			t.Errorf("admin: status %d, want 200", code)
+		}
+	})
+
+	t.Run("API token scopes", func(t *testing.T) {
+		readOnly := createAPIToken(t, ts, `{"name":"reader","scopes":["read"]}`)
+		bearer := map[string]string{"Authorization": "Bearer " + readOnly.Token}
+		if code := check("", bearer, "").StatusCode; code != http.StatusOK {
+			t.Errorf("read-only token, GET: status %d, want 200", code)
+		}
+		for _, header := range []string{"X-Original-Method", "X-Forwarded-Method"} {
+			bearer := map[string]string{"Authorization": "Bearer " + readOnly.Token, header: "POST"}
+			if code := check("", bearer, "").StatusCode; code != http.StatusUnauthorized {
+				t.Errorf("read-only token, %s POST: status %d, want 401", header, code)
+			}
+		}
+	})
+}
@@ -0,0 +1,194 @@
+package integration
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+type brandingConfigResponse struct {
+	LogoURL              string `json:"logo_url"`
+	PrimaryColor         string `json:"primary_color"`
+	FaviconURL           string `json:"favicon_url"`
+	BackgroundURL        string `json:"background_url"`
+	PasswordResetEnabled bool   `json:"password_reset_enabled"`
+	Theme                *struct {
+		Colors map[string]string `json:"colors"`
+		Fonts  map[string]string `json:"fonts"`
+	} `json:"theme"`
+}
+
+func getBrandingConfig(t *testing.T, url, tenantID string) brandingConfigResponse {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url+"/api/config", nil)
+	if tenantID != "" {
+		req.Header.Set("X-Tenant-ID", tenantID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /api/config: %v", err)
+	}
+	var cfg brandingConfigResponse
+	testutil.ReadJSON(t, resp, &cfg)
+	return cfg
+}
+
+func TestBranding_Theme(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	base := ts.URL + "/api/admin/tenants/default/branding"
+
+	resp := testutil.AuthPut(t, base, ts.AdminToken,
+		[]byte(`{"theme":{"colors":{"primary":"#112233","surface":"#ffffff"},"fonts":{"body":"Inter, sans-serif"}}}`))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT theme: expected 200, got %d: %s", resp.StatusCode, testutil.ReadBody(t, resp))
+	}
+	resp.Body.Close()
+
+	cfg := getBrandingConfig(t, ts.URL, "")
+	if cfg.PrimaryColor != "#112233" {
+		t.Errorf("primary_color = %q, want theme color", cfg.PrimaryColor)
+	}
+	if cfg.Theme == nil || cfg.Theme.Fonts["body"] != "Inter, sans-serif" || cfg.Theme.Colors["surface"] != "#ffffff" {
+		t.Errorf("theme = %+v", cfg.Theme)
+	}
+
+	// Values that could escape CSS are rejected
+	resp = testutil.AuthPut(t, base, ts.AdminToken, []byte(`{"theme":{"fonts":{"body":"x;}body{display:none"}}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid font: expected 400, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPut(t, ts.URL+"/api/admin/tenants/missing/branding", ts.AdminToken, []byte(`{"theme":{}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown tenant: expected 404, got %d", resp.StatusCode)
+	}
+
+	// Non-admins cannot change branding
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "plain", "pass123", []string{"user"})
+	userToken := testutil.LoginAs(t, ts.URL, "plain", "pass123")
+	resp = testutil.AuthPut(t, base, userToken, []byte(`{"theme":{}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("non-admin: expected 403, got %d", resp.StatusCode)
+	}
+}
+
+func TestBranding_AssetUpload(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	assetURL := ts.URL + "/api/admin/tenants/default/branding/assets/logo"
+	logo := testPNG(t)
+
+	resp := testutil.AuthPutMultipart(t, assetURL, ts.AdminToken, nil, "logo.png", logo)
+	var uploaded struct {
+		ContentType string `json:"content_type"`
+		Size        int64  `json:"size"`
+		URL         string `json:"url"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("upload: expected 200, got %d: %s", resp.StatusCode, testutil.ReadBody(t, resp))
+	}
+	testutil.ReadJSON(t, resp, &uploaded)
+	if uploaded.ContentType != "image/png" || uploaded.Size != int64(len(logo)) {
+		t.Errorf("uploaded = %+v", uploaded)
+	}
+	if !strings.HasPrefix(uploaded.URL, "/api/branding/assets/default/logo?v=") {
+		t.Errorf("url = %q", uploaded.URL)
+	}
+
+	cfg := getBrandingConfig(t, ts.URL, "")
+	if cfg.LogoURL != uploaded.URL {
+		t.Errorf("logo_url = %q, want %q", cfg.LogoURL, uploaded.URL)
+	}
+
+	// The asset is served publicly with its sniffed type
+	resp, err := http.Get(ts.URL + uploaded.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := testutil.ReadBody(t, resp)
+	if resp.StatusCode != http.StatusOK || body != string(logo) {
+		t.Fatalf("serve asset: status %d, %d bytes", resp.StatusCode, len(body))
+	}
+	if got := resp.Header.Get("Content-Type"); got != "image/png" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := resp.Header.Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("X-Content-Type-Options = %q", got)
+	}
+
+	// Non-images are rejected regardless of the file name
+	resp = testutil.AuthPutMultipart(t, ts.URL+"/api/admin/tenants/default/branding/assets/favicon", ts.AdminToken,
+		nil, "favicon.png", []byte("<html><script>alert(1)</script></html>"))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("html upload: expected 415, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPutMultipart(t, ts.URL+"/api/admin/tenants/default/branding/assets/banner", ts.AdminToken,
+		nil, "banner.png", logo)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown kind: expected 404, got %d", resp.StatusCode)
+	}
+
+	// Deleting the asset removes it from config and storage
+	resp = testutil.AuthDelete(t, assetURL, ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", resp.StatusCode)
+	}
+	if cfg := getBrandingConfig(t, ts.URL, ""); cfg.LogoURL == uploaded.URL {
+		t.Error("logo_url still points at the deleted asset")
+	}
+	resp, err = http.Get(ts.URL + uploaded.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("deleted asset: expected 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestBranding_PerTenant(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/admin/tenants", ts.AdminToken, []byte(`{"name":"Acme","slug":"acme"}`))
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create tenant: expected 201, got %d", resp.StatusCode)
+	}
+	var tenant struct {
+		ID string `json:"id"`
+	}
+	testutil.ReadJSON(t, resp, &tenant)
+
+	resp = testutil.AuthPutMultipart(t, ts.URL+"/api/admin/tenants/"+tenant.ID+"/branding/assets/background", ts.AdminToken,
+		nil, "bg.png", testPNG(t))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("upload: expected 200, got %d", resp.StatusCode)
+	}
+
+	if cfg := getBrandingConfig(t, ts.URL, tenant.ID); !strings.HasPrefix(cfg.BackgroundURL, "/api/branding/assets/"+tenant.ID+"/background") {
+		t.Errorf("tenant background_url = %q", cfg.BackgroundURL)
+	}
+	if cfg := getBrandingConfig(t, ts.URL, ""); cfg.BackgroundURL != "" {
+		t.Errorf("default tenant background_url = %q, want empty", cfg.BackgroundURL)
+	}
+}
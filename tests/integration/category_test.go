@@ -637,3 +637,106 @@ func TestCategory_AutoCreateOnAppCreate(t *testing.T) {
 		t.Error("expected auto-created category 'NewCat' not found")
 	}
 }
+
+// --- Category defaults ---
+
+func TestCategory_DefaultsAndEffectivePolicy(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	body := []byte(`{"id":"cat-bad","name":"Bad","defaults":{"recording_policy":"always"}}`)
+	resp := testutil.AuthPost(t, ts.URL+"/api/categories", ts.AdminToken, body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid defaults: expected 400, got %d", resp.StatusCode)
+	}
+
+	body = []byte(`{"id":"cat-labs","name":"Labs","defaults":{"resource_limits":{"cpu_limit":"3"},"idle_timeout":1200,"recording_policy":"auto"}}`)
+	resp = testutil.AuthPost(t, ts.URL+"/api/categories", ts.AdminToken, body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+
+	// Updating without the field keeps the defaults
+	resp = testutil.AuthPut(t, ts.URL+"/api/categories/cat-labs", ts.AdminToken, []byte(`{"name":"Labs","description":"Lab machines"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 updating category, got %d", resp.StatusCode)
+	}
+
+	appBody := []byte(`{"id":"lab-vm","name":"Lab VM","category":"Labs","launch_type":"container","container_image":"nginx:latest","resource_limits":{"cpu_request":"200m"}}`)
+	resp = testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken, appBody)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201 creating app, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthGet(t, ts.URL+"/api/apps/lab-vm/effective-policy", ts.AdminToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var policy struct {
+		ResourceLimits  db.ResourceLimits `json:"resource_limits"`
+		IdleTimeout     int64             `json:"idle_timeout"`
+		RecordingPolicy string            `json:"recording_policy"`
+		Sources         map[string]struct {
+			Layer string `json:"layer"`
+			ID    string `json:"id"`
+		} `json:"sources"`
+	}
+	testutil.ReadJSON(t, resp, &policy)
+	if policy.ResourceLimits.CPURequest != "200m" || policy.ResourceLimits.CPULimit != "3" {
+		t.Errorf("resource_limits = %+v, want cpu_request from the app and cpu_limit from the category", policy.ResourceLimits)
+	}
+	if policy.IdleTimeout != 1200 || policy.RecordingPolicy != "auto" {
+		t.Errorf("idle_timeout, recording_policy = %d, %q; want 1200, auto", policy.IdleTimeout, policy.RecordingPolicy)
+	}
+	if policy.Sources["cpu_request"].Layer != "app" || policy.Sources["cpu_limit"].Layer != "category" ||
+		policy.Sources["idle_timeout"].ID != "cat-labs" {
+		t.Errorf("sources = %v", policy.Sources)
+	}
+
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "labuser", "pass123", []string{"user"})
+	userToken := testutil.LoginAs(t, ts.URL, "labuser", "pass123")
+	resp = testutil.AuthGet(t, ts.URL+"/api/apps/lab-vm/effective-policy", userToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("regular user: expected 403, got %d", resp.StatusCode)
+	}
+
+	// Sessions of the app are recorded automatically
+	userID := getAdminUserID(t, ts)
+	sessBody := []byte(fmt.Sprintf(`{"app_id":"lab-vm","user_id":"%s"}`, userID))
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, sessBody)
+	var session map[string]interface{}
+	testutil.ReadJSON(t, resp, &session)
+	if session["recording_policy"] != "auto" {
+		t.Errorf("session recording_policy = %v, want auto", session["recording_policy"])
+	}
+	if session["idle_timeout"] != float64(1200) {
+		t.Errorf("session idle_timeout = %v, want 1200", session["idle_timeout"])
+	}
+
+	sessionID, _ := session["id"].(string)
+	resp = testutil.AuthGet(t, ts.URL+"/api/sessions/"+sessionID+"/effective-policy", ts.AdminToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("session policy: expected 200, got %d", resp.StatusCode)
+	}
+	var sessionPolicy struct {
+		SessionID   string `json:"session_id"`
+		IdleTimeout int64  `json:"idle_timeout"`
+		Sources     map[string]struct {
+			Layer string `json:"layer"`
+		} `json:"sources"`
+	}
+	testutil.ReadJSON(t, resp, &sessionPolicy)
+	if sessionPolicy.SessionID != sessionID || sessionPolicy.IdleTimeout != 1200 || sessionPolicy.Sources["idle_timeout"].Layer != "category" {
+		t.Errorf("session policy = %+v", sessionPolicy)
+	}
+
+	resp = testutil.AuthGet(t, ts.URL+"/api/sessions/"+sessionID+"/effective-policy", userToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("other user's session: expected 403, got %d", resp.StatusCode)
+	}
+}
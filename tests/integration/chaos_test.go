@@ -0,0 +1,70 @@
+//go:build chaos
+
+package integration
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/chaos"
+	"github.com/rjsadow/sortie/internal/sessions"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestAdminChaos(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	t.Cleanup(func() { chaos.Default.Set(chaos.Faults{}) })
+
+	resp := testutil.AuthPut(t, ts.URL+"/api/admin/chaos", ts.AdminToken, []byte(`{"websocket_drop_rate":2}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("drop rate of 2: status %d, want 400", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPut(t, ts.URL+"/api/admin/chaos", ts.AdminToken, []byte(`{"pod_ready_delay_seconds":1}`))
+	var result struct {
+		Faults   chaos.Faults   `json:"faults"`
+		Injected chaos.Injected `json:"injected"`
+	}
+	testutil.ReadJSON(t, resp, &result)
+	if result.Faults.PodReadyDelaySeconds != 1 {
+		t.Fatalf("faults = %+v, want a 1s readiness delay", result.Faults)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"chaos-app","name":"Chaos App","launch_type":"container","container_image":"nginx:latest"}`))
+	resp.Body.Close()
+
+	start := time.Now()
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, []byte(`{"app_id":"chaos-app"}`))
+	var session sessions.SessionResponse
+	testutil.ReadJSON(t, resp, &session)
+	waitForRunning(t, ts, session.ID)
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("session ran after %v, want the injected 1s delay", elapsed)
+	}
+
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/chaos", ts.AdminToken), &result)
+	if result.Injected.PodReadyDelays < 1 {
+		t.Errorf("injected = %+v, want a readiness delay", result.Injected)
+	}
+
+	resp = testutil.AuthDelete(t, ts.URL+"/api/admin/chaos", ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("clear: status %d, want 204", resp.StatusCode)
+	}
+	if f := chaos.Default.Faults(); f != (chaos.Faults{}) {
+		t.Errorf("faults = %+v after clearing", f)
+	}
+
+	// Users without the admin role cannot inject faults
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "chaos-user", "password123", []string{"user"})
+	userToken := testutil.LoginAs(t, ts.URL, "chaos-user", "password123")
+	resp = testutil.AuthPut(t, ts.URL+"/api/admin/chaos", userToken, []byte(`{"db_latency_ms":100}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("non-admin: status %d, want 403", resp.StatusCode)
+	}
+}
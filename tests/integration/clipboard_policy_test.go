@@ -0,0 +1,49 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/sessions"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestClipboardPolicy_ValidatedAndReturned(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"bad-app","name":"Bad","launch_type":"container","container_image":"nginx:latest","clipboard_policy":"sometimes"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown clipboard policy, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"clip-app","name":"Clip","launch_type":"container","container_image":"nginx:latest","clipboard_policy":"read"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+
+	var app db.Application
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/apps/clip-app", ts.AdminToken), &app)
+	if app.ClipboardPolicy != db.ClipboardRead {
+		t.Errorf("expected clipboard policy read, got %q", app.ClipboardPolicy)
+	}
+
+	// Sessions carry the policy so the viewer can hide what it blocks
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, []byte(`{"app_id":"clip-app"}`))
+	var session sessions.SessionResponse
+	testutil.ReadJSON(t, resp, &session)
+	if session.ClipboardPolicy != db.ClipboardRead {
+		t.Errorf("expected session clipboard policy read, got %q", session.ClipboardPolicy)
+	}
+
+	resp = testutil.AuthPut(t, ts.URL+"/api/apps/clip-app", ts.AdminToken,
+		[]byte(`{"name":"Clip","launch_type":"container","container_image":"nginx:latest","clipboard_policy":"both"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown clipboard policy on update, got %d", resp.StatusCode)
+	}
+}
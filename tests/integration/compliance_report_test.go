@@ -0,0 +1,62 @@
+package integration
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/attestation"
+	"github.com/rjsadow/sortie/internal/compliance"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestComplianceReport(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "auditee", "pass123", []string{"user"})
+
+	resp := testutil.AuthGet(t, ts.URL+"/api/admin/compliance-report", ts.AdminToken)
+	report := testutil.ReadBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d: %s", resp.StatusCode, report)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/zip" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if cd := resp.Header.Get("Content-Disposition"); !strings.Contains(cd, "compliance-report-") {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+
+	// The report verifies against the published attestation key
+	resp = testutil.AuthGet(t, ts.URL+"/api/admin/attestation-key", ts.AdminToken)
+	var key struct {
+		PublicKeyPEM string `json:"public_key_pem"`
+	}
+	testutil.ReadJSON(t, resp, &key)
+	pub, err := attestation.ParsePublicKeyPEM(key.PublicKeyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := compliance.Verify([]byte(report), pub)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if manifest.GeneratedBy != "admin" {
+		t.Errorf("generated_by = %q, want admin", manifest.GeneratedBy)
+	}
+	users := -1
+	for _, f := range manifest.Files {
+		if f.Name == "users.csv" {
+			users = f.Rows
+		}
+	}
+	if users < 2 {
+		t.Errorf("users.csv has %d rows, want the admin and auditee", users)
+	}
+
+	token := testutil.LoginAs(t, ts.URL, "auditee", "pass123")
+	resp = testutil.AuthGet(t, ts.URL+"/api/admin/compliance-report", token)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("non-admin: status %d, want 403", resp.StatusCode)
+	}
+}
@@ -0,0 +1,58 @@
+package integration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/sessions"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestSession_EvictedSessionIsRescheduled(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	createContainerApp(t, ts, "evict-app")
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, []byte(`{"app_id":"evict-app"}`))
+	var session sessions.SessionResponse
+	testutil.ReadJSON(t, resp, &session)
+	waitForRunning(t, ts, session.ID)
+	// Fields left out of a response are empty, so each is read afresh
+	get := func() sessions.SessionResponse {
+		t.Helper()
+		var s sessions.SessionResponse
+		testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/sessions/"+session.ID, ts.AdminToken), &s)
+		return s
+	}
+	session = get()
+
+	// The session manager watches for disruptions in the background, so
+	// evict until the session is seen moving
+	podName := session.PodName
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		ts.Runner.Evict(podName, "EvictionByEvictionAPI: Eviction API: evicting")
+		time.Sleep(50 * time.Millisecond)
+		session = get()
+		if session.RescheduleCount > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("eviction was not handled")
+		}
+	}
+
+	deadline = time.Now().Add(10 * time.Second)
+	for session.Substatus == db.SubstatusRescheduling && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+		session = get()
+	}
+	if session.Status != db.SessionStatusRunning || session.Substatus != "" || session.WebSocketURL == "" {
+		t.Errorf("after rescheduling session = %s/%q with websocket URL %q, want running and connectable",
+			session.Status, session.Substatus, session.WebSocketURL)
+	}
+	if session.RescheduleCount != 1 || ts.Runner.Workload(podName) == nil {
+		t.Errorf("session has %d reschedules, workload %s exists = %v; want 1 and a replacement",
+			session.RescheduleCount, podName, ts.Runner.Workload(podName) != nil)
+	}
+}
@@ -0,0 +1,72 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/middleware"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+// TestGolden checks the responses of read endpoints against golden files,
+// starting from the catalog fixtures. To cover another endpoint, add a
+// case and run with -update, then review the new file in
+// testdata/golden.
+func TestGolden(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	ts.LoadFixtures(t, "testdata/fixtures/catalog.yaml")
+
+	tokens := map[string]string{
+		"admin": ts.AdminToken,
+		"alice": testutil.LoginAs(t, ts.URL, "alice", "alice-password"),
+		"bob":   testutil.LoginAs(t, ts.URL, "bob", "bob-password"),
+		"carol": testutil.LoginAs(t, ts.URL, "carol", "carol-password"),
+	}
+
+	tests := []struct {
+		name   string
+		user   string
+		tenant string
+		path   string
+		ignore []string
+	}{
+		{name: "apps_admin", user: "admin", path: "/api/apps"},
+		{name: "apps_tenant", user: "bob", tenant: "acme", path: "/api/apps"},
+		{name: "app_by_id", user: "alice", path: "/api/apps/vscode"},
+		{name: "app_not_found", user: "alice", path: "/api/apps/missing"},
+		{name: "categories", user: "alice", path: "/api/categories"},
+		{name: "categories_tenant", user: "bob", tenant: "acme", path: "/api/categories"},
+		{name: "sessions_owner", user: "alice", path: "/api/sessions"},
+		{name: "admin_users", user: "admin", path: "/api/admin/users", ignore: []string{"id"}},
+		{name: "admin_users_page", user: "admin", path: "/api/admin/users?sort=username&limit=2", ignore: []string{"id"}},
+		{name: "admin_users_forbidden", user: "carol", path: "/api/admin/users"},
+		{name: "admin_tenants", user: "admin", path: "/api/admin/tenants"},
+		{name: "admin_autoscaling", user: "admin", path: "/api/admin/autoscaling"},
+		{name: "admin_autoscaling_forbidden", user: "carol", path: "/api/admin/autoscaling"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+tt.path, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+tokens[tt.user])
+			if tt.tenant != "" {
+				req.Header.Set(middleware.TenantHeader, tt.tenant)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("GET %s: %v", tt.path, err)
+			}
+			testutil.AssertGolden(t, resp, tt.name, tt.ignore...)
+		})
+	}
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		resp, err := http.Get(ts.URL + "/api/apps")
+		if err != nil {
+			t.Fatalf("GET /api/apps: %v", err)
+		}
+		testutil.AssertGolden(t, resp, "apps_unauthenticated")
+	})
+}
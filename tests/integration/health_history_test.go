@@ -0,0 +1,98 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+type healthHistory struct {
+	Events  []db.HealthEvent  `json:"events"`
+	Total   int               `json:"total"`
+	Current map[string]string `json:"current"`
+	Uptime  map[string]struct {
+		Percent   float64 `json:"percent"`
+		Incidents int     `json:"incidents"`
+	} `json:"uptime"`
+}
+
+func getHealthHistory(t *testing.T, ts *testutil.TestServer, query url.Values) healthHistory {
+	t.Helper()
+	resp := testutil.AuthGet(t, ts.URL+"/api/admin/health/history?"+query.Encode(), ts.AdminToken)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d, want 200", resp.StatusCode)
+	}
+	var history healthHistory
+	if err := json.NewDecoder(resp.Body).Decode(&history); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return history
+}
+
+func TestHealthHistory(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	start := time.Now().Add(-4 * time.Hour).Truncate(time.Second)
+
+	for _, e := range []db.HealthEvent{
+		{Component: "database", FromState: "healthy", ToState: "unhealthy", Reason: "connection refused", CreatedAt: start},
+		{Component: "database", FromState: "unhealthy", ToState: "healthy", CreatedAt: start.Add(time.Hour)},
+		{Component: "sessions", FromState: "unknown", ToState: "unhealthy", Reason: "overloaded", CreatedAt: start.Add(2 * time.Hour)},
+	} {
+		if err := ts.DB.CreateHealthEvent(&e); err != nil {
+			t.Fatalf("CreateHealthEvent() error = %v", err)
+		}
+	}
+
+	history := getHealthHistory(t, ts, url.Values{})
+	if history.Total != 3 || len(history.Events) != 3 {
+		t.Fatalf("got %d events (total %d), want 3", len(history.Events), history.Total)
+	}
+	if history.Events[0].Component != "sessions" {
+		t.Errorf("first event = %+v, want the newest", history.Events[0])
+	}
+	if history.Current["database"] != "healthy" || history.Current["sessions"] != "unhealthy" {
+		t.Errorf("current = %+v", history.Current)
+	}
+	if up := history.Uptime["sessions"]; up.Percent != 0 {
+		t.Errorf("sessions uptime = %+v, want 0%%", up)
+	}
+
+	// Over a window starting at the outage, the database was down one of
+	// four hours
+	query := url.Values{"component": {"database"}, "from": {start.Format(time.RFC3339)}}
+	history = getHealthHistory(t, ts, query)
+	if history.Total != 2 {
+		t.Errorf("total = %d, want 2 database events", history.Total)
+	}
+	up, ok := history.Uptime["database"]
+	if !ok || up.Incidents != 1 || up.Percent < 74 || up.Percent > 76 {
+		t.Errorf("database uptime = %+v, want about 75%%", history.Uptime)
+	}
+	if _, ok := history.Uptime["sessions"]; ok {
+		t.Error("component filter did not apply to uptime")
+	}
+
+	t.Run("invalid date", func(t *testing.T) {
+		resp := testutil.AuthGet(t, ts.URL+"/api/admin/health/history?from=yesterday", ts.AdminToken)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("status %d, want 400", resp.StatusCode)
+		}
+	})
+
+	t.Run("admin only", func(t *testing.T) {
+		testutil.CreateUser(t, ts.URL, ts.AdminToken, "bob", "bob-pass-123", []string{"user"})
+		token := testutil.LoginAs(t, ts.URL, "bob", "bob-pass-123")
+		resp := testutil.AuthGet(t, ts.URL+"/api/admin/health/history", token)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("status %d, want 403", resp.StatusCode)
+		}
+	})
+}
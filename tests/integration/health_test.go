@@ -55,6 +55,40 @@ func TestHealth_Readiness(t *testing.T) {
 	}
 }
 
+func TestHealth_Version(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp, err := http.Get(ts.URL + "/api/version")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	var body map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if body["version"] == "" || body["version"] == nil {
+		t.Errorf("expected version, got %v", body)
+	}
+	if _, ok := body["go_version"]; !ok {
+		t.Errorf("expected go_version, got %v", body)
+	}
+
+	resp, err = http.Get(ts.URL + "/readyz")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var ready map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&ready)
+	if v, ok := ready["version"].(map[string]interface{}); !ok || v["version"] != body["version"] {
+		t.Errorf("expected readyz version %v, got %v", body["version"], ready["version"])
+	}
+}
+
 func TestHealth_LoadStatus(t *testing.T) {
 	ts := testutil.NewTestServer(t)
 
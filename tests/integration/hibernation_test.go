@@ -0,0 +1,85 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/sessions"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestSession_HibernateAndResume(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"hibernate-app","name":"Hibernate App","launch_type":"container","container_image":"nginx:latest","hibernate_on_idle":true}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("failed to create app: status %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, []byte(`{"app_id":"hibernate-app","idle_timeout":1}`))
+	var session sessions.SessionResponse
+	testutil.ReadJSON(t, resp, &session)
+	waitForRunning(t, ts, session.ID)
+	if !ts.Runner.HasSessionVolume(session.ID) {
+		t.Fatal("session has no workspace volume")
+	}
+
+	// The cleanup job hibernates the session once it is idle
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		resp = testutil.AuthPost(t, ts.URL+"/api/admin/jobs/"+sessions.CleanupJobName+"/run", ts.AdminToken, nil)
+		resp.Body.Close()
+		testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/sessions/"+session.ID, ts.AdminToken), &session)
+		if session.Status == db.SessionStatusHibernated {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("session status = %s, want hibernated", session.Status)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	if ts.Runner.WorkloadCount() != 0 || !ts.Runner.HasSessionVolume(session.ID) {
+		t.Errorf("after hibernation: %d workloads, volume kept = %v", ts.Runner.WorkloadCount(), ts.Runner.HasSessionVolume(session.ID))
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions/"+session.ID+"/restart", ts.AdminToken, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("restart of a hibernated session: status %d, want 409", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions/"+session.ID+"/resume", ts.AdminToken, nil)
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		t.Fatalf("resume: status %d, want 200", resp.StatusCode)
+	}
+	testutil.ReadJSON(t, resp, &session)
+	if session.Status != db.SessionStatusCreating && session.Status != db.SessionStatusRunning {
+		t.Errorf("status after resume = %s", session.Status)
+	}
+	waitForRunning(t, ts, session.ID)
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions/"+session.ID+"/resume", ts.AdminToken, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("resume of a running session: status %d, want 409", resp.StatusCode)
+	}
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions/missing/resume", ts.AdminToken, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("resume of a missing session: status %d, want 404", resp.StatusCode)
+	}
+
+	// Deleting the session deletes its workspace volume
+	resp = testutil.AuthDelete(t, ts.URL+"/api/sessions/"+session.ID, ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete: status %d, want 204", resp.StatusCode)
+	}
+	if ts.Runner.HasSessionVolume(session.ID) {
+		t.Error("workspace volume kept after the session was deleted")
+	}
+}
@@ -0,0 +1,161 @@
+package integration
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestIcons_UploadAndServe(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	icon := testPNG(t)
+
+	resp := testutil.AuthPostMultipart(t, ts.URL+"/api/assets/icons", ts.AdminToken, nil, "icon.png", icon)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("upload: expected 201, got %d: %s", resp.StatusCode, testutil.ReadBody(t, resp))
+	}
+	var uploaded struct {
+		ID  string `json:"id"`
+		URL string `json:"url"`
+	}
+	testutil.ReadJSON(t, resp, &uploaded)
+	if !strings.HasSuffix(uploaded.ID, ".png") || uploaded.URL != "/api/assets/icons/"+uploaded.ID {
+		t.Fatalf("uploaded = %+v", uploaded)
+	}
+
+	// Uploading the same image again returns the same URL
+	resp = testutil.AuthPostMultipart(t, ts.URL+"/api/assets/icons", ts.AdminToken, nil, "copy.png", icon)
+	var again struct {
+		URL string `json:"url"`
+	}
+	testutil.ReadJSON(t, resp, &again)
+	if again.URL != uploaded.URL {
+		t.Errorf("re-upload url = %q, want %q", again.URL, uploaded.URL)
+	}
+
+	// The icon is served publicly and cached for good
+	resp, err := http.Get(ts.URL + uploaded.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := testutil.ReadBody(t, resp)
+	if resp.StatusCode != http.StatusOK || body != string(icon) {
+		t.Fatalf("serve icon: status %d, %d bytes", resp.StatusCode, len(body))
+	}
+	if got := resp.Header.Get("Content-Type"); got != "image/png" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := resp.Header.Get("Cache-Control"); got != "public, max-age=31536000, immutable" {
+		t.Errorf("Cache-Control = %q", got)
+	}
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("missing ETag")
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+uploaded.URL, nil)
+	req.Header.Set("If-None-Match", etag)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("conditional GET: expected 304, got %d", resp.StatusCode)
+	}
+
+	// The uploaded URL is accepted as an app icon
+	resp = testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"iconic","name":"Iconic","url":"https://example.com","icon":"`+uploaded.URL+`","category":"Test"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("create app: expected 201, got %d", resp.StatusCode)
+	}
+
+	for _, path := range []string{
+		"/api/assets/icons/00000000000000000000000000000000.png",
+		"/api/assets/icons/../../etc/passwd",
+		"/api/assets/icons/builtin-missing.svg",
+	} {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %s: expected 404, got %d", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestIcons_UploadRejected(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp := testutil.AuthPostMultipart(t, ts.URL+"/api/assets/icons", ts.AdminToken,
+		nil, "icon.png", []byte("<html><script>alert(1)</script></html>"))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("html upload: expected 415, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPostMultipart(t, ts.URL+"/api/assets/icons", ts.AdminToken,
+		nil, "icon.png", append(testPNG(t), make([]byte, 600<<10)...))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized upload: expected 413, got %d", resp.StatusCode)
+	}
+
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "plain", "pass123", []string{"user"})
+	userToken := testutil.LoginAs(t, ts.URL, "plain", "pass123")
+	resp = testutil.AuthPostMultipart(t, ts.URL+"/api/assets/icons", userToken, nil, "icon.png", testPNG(t))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("plain user: expected 403, got %d", resp.StatusCode)
+	}
+
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "author", "pass123", []string{"app-author"})
+	authorToken := testutil.LoginAs(t, ts.URL, "author", "pass123")
+	resp = testutil.AuthPostMultipart(t, ts.URL+"/api/assets/icons", authorToken, nil, "icon.png", testPNG(t))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("app author: expected 201, got %d", resp.StatusCode)
+	}
+}
+
+func TestIcons_SeededTemplatesUseBuiltins(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	data, err := os.ReadFile("../../web/src/data/templates.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.DB.SeedTemplatesFromData(data); err != nil {
+		t.Fatalf("seed templates: %v", err)
+	}
+
+	resp := testutil.AuthGet(t, ts.URL+"/api/templates", ts.AdminToken)
+	var templates []struct {
+		TemplateID string `json:"template_id"`
+		Icon       string `json:"icon"`
+	}
+	testutil.ReadJSON(t, resp, &templates)
+	if len(templates) == 0 {
+		t.Fatal("no templates were seeded")
+	}
+	for _, tmpl := range templates {
+		if !strings.HasPrefix(tmpl.Icon, "/api/assets/icons/builtin-") {
+			t.Errorf("template %s icon = %q, want a built-in icon", tmpl.TemplateID, tmpl.Icon)
+			continue
+		}
+		resp, err := http.Get(ts.URL + tmpl.Icon)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/svg+xml" {
+			t.Errorf("GET %s: status %d, type %q", tmpl.Icon, resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+	}
+}
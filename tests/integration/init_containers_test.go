@@ -0,0 +1,79 @@
+package integration
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestInitContainers(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken, []byte(`{
+		"id":"seeded","name":"Seeded","launch_type":"container","container_image":"nginx:latest",
+		"init_containers":[{"name":"seed","image":"busybox:1.36","command":["sh","-c","cp -r /seed/. /data"],
+			"volume_mounts":[{"name":"data","mount_path":"/data"}]}],
+		"shared_volumes":[{"name":"data","mount_path":"/srv/data"}]}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+
+	var app db.Application
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/apps/seeded", ts.AdminToken), &app)
+	if len(app.InitContainers) != 1 || app.InitContainers[0].Image != "busybox:1.36" {
+		t.Fatalf("app = %+v, want its init container", app)
+	}
+
+	for name, body := range map[string]string{
+		"missing image":  `{"id":"seeded","name":"Seeded","launch_type":"container","container_image":"nginx:latest","init_containers":[{"name":"seed"}]}`,
+		"duplicate name": `{"id":"seeded","name":"Seeded","launch_type":"container","container_image":"nginx:latest","init_containers":[{"name":"seed","image":"a"},{"name":"seed","image":"b"}]}`,
+	} {
+		resp = testutil.AuthPut(t, ts.URL+"/api/apps/seeded", ts.AdminToken, []byte(body))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, resp.StatusCode)
+		}
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/appspecs", ts.AdminToken,
+		[]byte(`{"id":"seeded-spec","name":"Seeded","image":"seeded:latest","init_containers":[{"name":"License","image":"license:1"}]}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("app spec init container with invalid name: expected 400, got %d", resp.StatusCode)
+	}
+
+	// A failing init container fails the launch, and the session keeps
+	// the stage and the container's exit as its substatus
+	ts.Runner.ReadyError = errors.New("pod is in terminal state: Failed: init container init-seed: exited with code 1")
+	ts.Runner.Progress = db.SubstatusInitializing
+	ts.Runner.ProgressDetail = "init-seed: exited with code 1: seed bucket not found"
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, []byte(`{"app_id":"seeded"}`))
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create session: expected 201, got %d: %s", resp.StatusCode, testutil.ReadBody(t, resp))
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	testutil.ReadJSON(t, resp, &created)
+
+	var session map[string]any
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		session = nil
+		testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/sessions/"+created.ID, ts.AdminToken), &session)
+		if session["status"] == "failed" {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if session["status"] != "failed" || session["substatus"] != "initializing" ||
+		session["substatus_detail"] != "init-seed: exited with code 1: seed bucket not found" {
+		t.Fatalf("expected failed session with initializing substatus, got %v", session)
+	}
+}
@@ -0,0 +1,83 @@
+package integration
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/jobs"
+	"github.com/rjsadow/sortie/internal/sessions"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestAdminJobs(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	release := make(chan struct{})
+	if err := ts.Jobs.Register(jobs.Job{
+		Name:     "report",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			<-release
+			return nil
+		},
+	}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	var list struct {
+		Jobs []jobs.Status `json:"jobs"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/jobs", ts.AdminToken), &list)
+	if len(list.Jobs) != 2 || list.Jobs[0].Name != sessions.CleanupJobName || list.Jobs[1].Name != "report" {
+		t.Fatalf("jobs = %+v, want session cleanup and report", list.Jobs)
+	}
+	if list.Jobs[1].LastRun != nil {
+		t.Errorf("report has a last run before running: %+v", list.Jobs[1].LastRun)
+	}
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/admin/jobs/report/run", ts.AdminToken, nil)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("run: status %d, want 202", resp.StatusCode)
+	}
+	var run db.JobRun
+	testutil.ReadJSON(t, resp, &run)
+	if run.Status != db.JobRunRunning || run.RequestedBy != testutil.TestAdminUsername {
+		t.Errorf("run = %+v", run)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/jobs/report/run", ts.AdminToken, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("run while running: status %d, want 409", resp.StatusCode)
+	}
+	close(release)
+
+	var page db.JobRunPage
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/jobs/report/runs", ts.AdminToken), &page)
+		if len(page.Runs) == 1 && page.Runs[0].Status == db.JobRunSucceeded {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("runs = %+v, want one succeeded run", page)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/jobs/missing/run", ts.AdminToken, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown job: status %d, want 404", resp.StatusCode)
+	}
+
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "jobuser", "password123", []string{"user"})
+	userToken := testutil.LoginAs(t, ts.URL, "jobuser", "password123")
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/jobs/report/run", userToken, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("non-admin run: status %d, want 403", resp.StatusCode)
+	}
+}
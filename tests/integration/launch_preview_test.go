@@ -0,0 +1,140 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+type launchPreview struct {
+	CPUCores  float64 `json:"cpu_cores"`
+	MemoryGiB float64 `json:"memory_gib"`
+	Quota     struct {
+		UserSessions       int `json:"user_sessions"`
+		MaxSessionsPerUser int `json:"max_sessions_per_user"`
+	} `json:"quota"`
+	Blocked string `json:"blocked"`
+	Limit   string `json:"limit"`
+	Cost    struct {
+		Currency     string  `json:"currency"`
+		PerHour      float64 `json:"per_hour"`
+		TypicalHours float64 `json:"typical_hours"`
+		Estimated    float64 `json:"estimated"`
+	} `json:"cost"`
+	Confirmation *db.LaunchConfirmation `json:"confirmation"`
+	ConfirmToken string                 `json:"confirm_token"`
+}
+
+func getLaunchPreview(t *testing.T, ts *testutil.TestServer, appID, token string) launchPreview {
+	t.Helper()
+	resp := testutil.AuthGet(t, ts.URL+"/api/apps/"+appID+"/launch-preview", token)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("launch preview of %s: status %d, want 200: %s", appID, resp.StatusCode, testutil.ReadBody(t, resp))
+	}
+	var preview launchPreview
+	testutil.ReadJSON(t, resp, &preview)
+	return preview
+}
+
+func launchWithToken(t *testing.T, ts *testutil.TestServer, appID, token, confirmToken string) int {
+	t.Helper()
+	body := []byte(fmt.Sprintf(`{"app_id":%q,"confirm_token":%q}`, appID, confirmToken))
+	resp := testutil.AuthPost(t, ts.URL+"/api/sessions", token, body)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestLaunchPreview(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	ts.Config.CostCPUCoreHour = 0.04
+	ts.Config.CostMemoryGiBHour = 0.005
+	ts.Config.CostCurrency = "EUR"
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken, []byte(`{
+		"id": "render", "name": "Render Farm", "launch_type": "container", "container_image": "render:latest",
+		"resource_limits": {"cpu_request": "4", "cpu_limit": "8", "memory_limit": "16Gi"},
+		"max_concurrent_sessions": 1,
+		"launch_confirmation": {"required": true, "message": "Uses a GPU node.", "prerequisites": ["A render license"]}
+	}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create app: status %d, want 201", resp.StatusCode)
+	}
+	resp = testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken, []byte(`{
+		"id": "bad", "name": "Bad", "launch_type": "container", "container_image": "bad:latest",
+		"launch_confirmation": {"prerequisites": [" "]}
+	}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("create app with blank prerequisite: status %d, want 400", resp.StatusCode)
+	}
+
+	// Two past runs of 1 and 3 hours make a typical run 2 hours
+	ended := time.Now().Add(-time.Hour)
+	for i, hours := range []int64{1, 3} {
+		if err := ts.DB.CreateSessionHistory(db.SessionHistory{
+			ID: fmt.Sprintf("run-%d", i), SessionID: fmt.Sprintf("s-%d", i), UserID: "someone", AppID: "render",
+			StartedAt: ended.Add(-time.Duration(hours) * time.Hour), EndedAt: ended, DurationSeconds: hours * 3600,
+			FinalStatus: db.SessionStatusStopped,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "alice", "alice-pass", []string{"user"})
+	alice := testutil.LoginAs(t, ts.URL, "alice", "alice-pass")
+
+	preview := getLaunchPreview(t, ts, "render", alice)
+	if preview.CPUCores != 4 || preview.MemoryGiB != 16 {
+		t.Errorf("resources = %v cores, %v GiB, want 4 and 16", preview.CPUCores, preview.MemoryGiB)
+	}
+	// 4 cores at 0.04 and 16 GiB at 0.005 an hour
+	if preview.Cost.Currency != "EUR" || preview.Cost.PerHour != 0.24 || preview.Cost.TypicalHours != 2 || preview.Cost.Estimated != 0.48 {
+		t.Errorf("cost = %+v, want 0.24 EUR an hour, 0.48 for a typical 2 hours", preview.Cost)
+	}
+	if preview.Quota.UserSessions != 0 || preview.Quota.MaxSessionsPerUser != 10 || preview.Blocked != "" {
+		t.Errorf("quota = %+v, blocked %q", preview.Quota, preview.Blocked)
+	}
+	if preview.Confirmation == nil || preview.Confirmation.Message != "Uses a GPU node." || len(preview.Confirmation.Prerequisites) != 1 {
+		t.Errorf("confirmation = %+v", preview.Confirmation)
+	}
+	if preview.ConfirmToken == "" {
+		t.Fatal("no confirm token for an app that requires confirmation")
+	}
+
+	// Launches need a token from the caller's own preview of the app
+	adminPreview := getLaunchPreview(t, ts, "render", ts.AdminToken)
+	for name, token := range map[string]string{
+		"no token":          "",
+		"forged token":      "9999999999.c2lnbmF0dXJl",
+		"another's token":   adminPreview.ConfirmToken,
+		"tampered expiry":   "9" + preview.ConfirmToken,
+		"token without dot": "token",
+	} {
+		if status := launchWithToken(t, ts, "render", alice, token); status != http.StatusPreconditionRequired {
+			t.Errorf("launch with %s: status %d, want 428", name, status)
+		}
+	}
+	if status := launchWithToken(t, ts, "render", alice, preview.ConfirmToken); status != http.StatusCreated {
+		t.Fatalf("launch with confirm token: status %d, want 201", status)
+	}
+
+	// The app is now at its session limit
+	preview = getLaunchPreview(t, ts, "render", ts.AdminToken)
+	if preview.Blocked == "" || preview.Limit != "app" {
+		t.Errorf("preview at capacity: blocked %q, limit %q, want the app limit", preview.Blocked, preview.Limit)
+	}
+
+	// Apps that do not require confirmation launch as before, with no token
+	createContainerApp(t, ts, "editor")
+	if preview := getLaunchPreview(t, ts, "editor", alice); preview.ConfirmToken != "" || preview.Confirmation != nil {
+		t.Errorf("preview of editor = %+v, want no confirmation", preview)
+	}
+	if status := launchWithToken(t, ts, "editor", alice, ""); status != http.StatusCreated {
+		t.Errorf("launch editor: status %d, want 201", status)
+	}
+}
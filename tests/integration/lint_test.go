@@ -0,0 +1,109 @@
+package integration
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/lint"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+type lintResponse struct {
+	Warnings []struct {
+		Rule     string `json:"rule"`
+		Severity string `json:"severity"`
+		Field    string `json:"field"`
+	} `json:"warnings"`
+}
+
+func TestLint_Drafts(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	var got lintResponse
+	testutil.ReadJSON(t, testutil.AuthPost(t, ts.URL+"/api/lint/app", ts.AdminToken,
+		[]byte(`{"id":"cad","launch_type":"container","container_image":"cad:latest","icon":"cad.png","description":"CAD",
+			"resource_limits":{"cpu_limit":"2","memory_limit":"4Gi"},"egress_policy":{"mode":"allowlist"}}`)), &got)
+	if len(got.Warnings) != 1 || got.Warnings[0].Rule != "latest-tag" || got.Warnings[0].Field != "container_image" {
+		t.Errorf("app warnings = %+v, want one latest-tag", got.Warnings)
+	}
+
+	testutil.ReadJSON(t, testutil.AuthPost(t, ts.URL+"/api/lint/template", ts.AdminToken,
+		[]byte(`{"template_id":"docs","launch_type":"url","url":"https://example.com","icon":"docs.png","description":"Docs"}`)), &got)
+	if got.Warnings == nil || len(got.Warnings) != 0 {
+		t.Errorf("template warnings = %+v, want an empty list", got.Warnings)
+	}
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/lint/app", ts.AdminToken, []byte(`{`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid JSON: expected 400, got %d", resp.StatusCode)
+	}
+
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "plain", "pass123", []string{"user"})
+	userToken := testutil.LoginAs(t, ts.URL, "plain", "pass123")
+	resp = testutil.AuthPost(t, ts.URL+"/api/lint/app", userToken, []byte(`{}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("plain user: expected 403, got %d", resp.StatusCode)
+	}
+
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "author", "pass123", []string{"app-author"})
+	authorToken := testutil.LoginAs(t, ts.URL, "author", "pass123")
+	resp = testutil.AuthPost(t, ts.URL+"/api/lint/template", authorToken, []byte(`{}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("app author: expected 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestLint_CatalogReport(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	createContainerApp(t, ts, "cad")
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"docs","name":"Docs","launch_type":"url","url":"https://example.com","icon":"docs.png","description":"Docs"}`))
+	resp.Body.Close()
+
+	var report struct {
+		Warnings []struct {
+			Kind     string `json:"kind"`
+			TargetID string `json:"target_id"`
+			Rule     string `json:"rule"`
+			Severity string `json:"severity"`
+		} `json:"warnings"`
+		Counts    map[string]int `json:"counts"`
+		CheckedAt *string        `json:"checked_at"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/lint", ts.AdminToken), &report)
+	if len(report.Warnings) != 0 || report.CheckedAt != nil {
+		t.Fatalf("expected an empty report before the first check, got %+v", report)
+	}
+
+	if err := lint.NewChecker(ts.DB).Run(context.Background()); err != nil {
+		t.Fatalf("catalog lint: %v", err)
+	}
+
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/lint", ts.AdminToken), &report)
+	if len(report.Warnings) == 0 || report.CheckedAt == nil {
+		t.Fatalf("expected warnings after the check, got %+v", report)
+	}
+	for _, w := range report.Warnings {
+		if w.Kind != "app" || w.TargetID != "cad" {
+			t.Errorf("unexpected warning %+v; only cad breaks rules", w)
+		}
+	}
+	if report.Counts["latest-tag"] != 1 || report.Counts["egress-policy"] != 1 {
+		t.Errorf("counts = %v", report.Counts)
+	}
+
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/lint?rule=latest-tag", ts.AdminToken), &report)
+	if len(report.Warnings) != 1 || report.Warnings[0].Rule != "latest-tag" {
+		t.Errorf("filtered by rule = %+v", report.Warnings)
+	}
+
+	resp = testutil.AuthGet(t, ts.URL+"/api/admin/lint?severity=fatal", ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid severity: expected 400, got %d", resp.StatusCode)
+	}
+}
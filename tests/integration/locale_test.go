@@ -0,0 +1,110 @@
+package integration
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestLocale_AcceptLanguageTranslatesErrors(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	body := `{"username":"admin","password":"wrongpassword"}`
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/auth/login", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", "es-ES,es;q=0.9,en;q=0.5")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", resp.StatusCode)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	if got, _, _ := strings.Cut(string(b), "\n"); got != "Credenciales no válidas" {
+		t.Errorf("expected Spanish error, got %q", got)
+	}
+	if got := resp.Header.Get("Content-Language"); got != "es" {
+		t.Errorf("expected Content-Language es, got %q", got)
+	}
+}
+
+func TestLocale_UserPreference(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "localeuser", "pass123", []string{"user"})
+	token := testutil.LoginAs(t, ts.URL, "localeuser", "pass123")
+
+	resp := testutil.AuthPut(t, ts.URL+"/api/auth/me/locale", token, []byte(`{"locale":"xx"}`))
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for unsupported locale, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	resp = testutil.AuthPut(t, ts.URL+"/api/auth/me/locale", token, []byte(`{"locale":"es"}`))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.StatusCode, testutil.ReadBody(t, resp))
+	}
+	resp.Body.Close()
+
+	var me map[string]interface{}
+	resp = testutil.AuthGet(t, ts.URL+"/api/auth/me", token)
+	testutil.ReadJSON(t, resp, &me)
+	if me["locale"] != "es" {
+		t.Errorf("expected locale es in /api/auth/me, got %v", me["locale"])
+	}
+
+	// Tokens issued after the change carry the preference, which wins over
+	// Accept-Language.
+	token = testutil.LoginAs(t, ts.URL, "localeuser", "pass123")
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/admin/users", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept-Language", "en-US")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", resp.StatusCode)
+	}
+	if got, _, _ := strings.Cut(testutil.ReadBody(t, resp), "\n"); got != "Permisos insuficientes" {
+		t.Errorf("expected Spanish error, got %q", got)
+	}
+
+	// An empty locale clears the preference.
+	resp = testutil.AuthPut(t, ts.URL+"/api/auth/me/locale", token, []byte(`{"locale":""}`))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+	me = nil
+	resp = testutil.AuthGet(t, ts.URL+"/api/auth/me", token)
+	testutil.ReadJSON(t, resp, &me)
+	if _, ok := me["locale"]; ok {
+		t.Errorf("expected locale to be cleared, got %v", me["locale"])
+	}
+}
+
+func TestLocale_ConfigListsLocales(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp, err := http.Get(ts.URL + "/api/config")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	var cfg map[string]interface{}
+	testutil.ReadJSON(t, resp, &cfg)
+
+	locales, ok := cfg["locales"].([]interface{})
+	if !ok || len(locales) < 2 {
+		t.Fatalf("expected locales in /api/config, got %v", cfg["locales"])
+	}
+	if locales[0] != "en" {
+		t.Errorf("expected en first, got %v", locales)
+	}
+}
@@ -0,0 +1,198 @@
+package integration
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/plugins/auth"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+type mfaLoginResponse struct {
+	AccessToken      string   `json:"access_token"`
+	RefreshToken     string   `json:"refresh_token"`
+	MFARequired      bool     `json:"mfa_required"`
+	MFASetupRequired bool     `json:"mfa_setup_required"`
+	MFAToken         string   `json:"mfa_token"`
+	BackupCodes      []string `json:"backup_codes"`
+}
+
+// mfaPost posts body to path with an optional bearer token and decodes a
+// JSON reply into target.
+func mfaPost(t *testing.T, ts *testutil.TestServer, method, path, token, body string, target any) int {
+	t.Helper()
+	req, _ := http.NewRequest(method, ts.URL+path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	if target != nil && resp.StatusCode < 300 {
+		testutil.ReadJSON(t, resp, target)
+	} else {
+		resp.Body.Close()
+	}
+	return resp.StatusCode
+}
+
+// totpNow returns the current code for secret, or for the next time step
+// when the current one was already used.
+func totpNow(t *testing.T, secret string, next bool) string {
+	t.Helper()
+	step := auth.TOTPStep(time.Now())
+	if next {
+		step++
+	}
+	code, err := auth.TOTPCode(secret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestMFA_EnrollAndLogin(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "alice", "alice-pass-123", []string{"user"})
+	token := testutil.LoginAs(t, ts.URL, "alice", "alice-pass-123")
+
+	var setup struct {
+		Secret     string `json:"secret"`
+		OTPAuthURL string `json:"otpauth_url"`
+	}
+	if code := mfaPost(t, ts, http.MethodPost, "/api/auth/mfa/setup", token, "", &setup); code != http.StatusOK || setup.Secret == "" || setup.OTPAuthURL == "" {
+		t.Fatalf("setup: status %d, %+v", code, setup)
+	}
+	if code := mfaPost(t, ts, http.MethodPost, "/api/auth/mfa/verify", token, `{"code":"000000"}`, nil); code != http.StatusUnauthorized {
+		t.Errorf("verify with wrong code: status %d, want 401", code)
+	}
+	var verified struct {
+		BackupCodes []string `json:"backup_codes"`
+	}
+	body := `{"code":"` + totpNow(t, setup.Secret, false) + `"}`
+	if code := mfaPost(t, ts, http.MethodPost, "/api/auth/mfa/verify", token, body, &verified); code != http.StatusOK || len(verified.BackupCodes) != auth.BackupCodeCount {
+		t.Fatalf("verify: status %d, %d backup codes", code, len(verified.BackupCodes))
+	}
+	if code := mfaPost(t, ts, http.MethodPost, "/api/auth/mfa/setup", token, "", nil); code != http.StatusConflict {
+		t.Errorf("setup while enabled: status %d, want 409", code)
+	}
+
+	login := func() mfaLoginResponse {
+		t.Helper()
+		var result mfaLoginResponse
+		code := mfaPost(t, ts, http.MethodPost, "/api/auth/login", "", `{"username":"alice","password":"alice-pass-123"}`, &result)
+		if code != http.StatusOK || !result.MFARequired || result.MFAToken == "" || result.AccessToken != "" {
+			t.Fatalf("login: status %d, %+v; want an MFA challenge", code, result)
+		}
+		return result
+	}
+
+	t.Run("login needs a code", func(t *testing.T) {
+		challenge := login()
+		if code := mfaPost(t, ts, http.MethodGet, "/api/sessions", challenge.MFAToken, "", nil); code != http.StatusUnauthorized {
+			t.Errorf("MFA token used as access token: status %d, want 401", code)
+		}
+		if code := mfaPost(t, ts, http.MethodPost, "/api/auth/mfa/verify", "", `{"mfa_token":"`+challenge.MFAToken+`","code":"000000"}`, nil); code != http.StatusUnauthorized {
+			t.Errorf("wrong code: status %d, want 401", code)
+		}
+
+		var result mfaLoginResponse
+		body := `{"mfa_token":"` + challenge.MFAToken + `","code":"` + totpNow(t, setup.Secret, true) + `"}`
+		if code := mfaPost(t, ts, http.MethodPost, "/api/auth/mfa/verify", "", body, &result); code != http.StatusOK || result.AccessToken == "" || result.RefreshToken == "" {
+			t.Fatalf("verify: status %d, %+v; want tokens", code, result)
+		}
+		if code := statusOf(testutil.AuthGet(t, ts.URL+"/api/sessions", result.AccessToken)); code != http.StatusOK {
+			t.Errorf("access token after MFA: status %d, want 200", code)
+		}
+	})
+
+	t.Run("backup code", func(t *testing.T) {
+		challenge := login()
+		body := `{"mfa_token":"` + challenge.MFAToken + `","code":"` + verified.BackupCodes[0] + `"}`
+		var result mfaLoginResponse
+		if code := mfaPost(t, ts, http.MethodPost, "/api/auth/mfa/verify", "", body, &result); code != http.StatusOK || result.AccessToken == "" {
+			t.Fatalf("verify with backup code: status %d", code)
+		}
+		if code := mfaPost(t, ts, http.MethodPost, "/api/auth/mfa/verify", "", body, nil); code != http.StatusUnauthorized {
+			t.Errorf("reused backup code: status %d, want 401", code)
+		}
+
+		var status struct {
+			Enabled   bool `json:"enabled"`
+			Remaining int  `json:"backup_codes_remaining"`
+		}
+		mfaPost(t, ts, http.MethodGet, "/api/auth/mfa", token, "", &status)
+		if !status.Enabled || status.Remaining != auth.BackupCodeCount-1 {
+			t.Errorf("status = %+v, want enabled with %d backup codes", status, auth.BackupCodeCount-1)
+		}
+	})
+
+	t.Run("disable", func(t *testing.T) {
+		if code := mfaPost(t, ts, http.MethodDelete, "/api/auth/mfa", token, `{"code":"000000"}`, nil); code != http.StatusUnauthorized {
+			t.Errorf("disable with wrong code: status %d, want 401", code)
+		}
+		if code := mfaPost(t, ts, http.MethodDelete, "/api/auth/mfa", token, `{"code":"`+verified.BackupCodes[1]+`"}`, nil); code != http.StatusNoContent {
+			t.Fatalf("disable: status %d, want 204", code)
+		}
+		if testutil.LoginAs(t, ts.URL, "alice", "alice-pass-123") == "" {
+			t.Error("login after disabling MFA returned no access token")
+		}
+	})
+}
+
+func TestMFA_RequiredByTenantPolicy(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	userID := testutil.CreateUser(t, ts.URL, ts.AdminToken, "bob", "bob-pass-1234", []string{"user"})
+
+	resp := testutil.AuthPut(t, ts.URL+"/api/admin/tenants/default", ts.AdminToken,
+		[]byte(`{"settings":{"mfa_policy":{"roles":["user"]}}}`))
+	if code := statusOf(resp); code != http.StatusOK {
+		t.Fatalf("update tenant: status %d", code)
+	}
+
+	var challenge mfaLoginResponse
+	mfaPost(t, ts, http.MethodPost, "/api/auth/login", "", `{"username":"bob","password":"bob-pass-1234"}`, &challenge)
+	if !challenge.MFARequired || !challenge.MFASetupRequired || challenge.AccessToken != "" {
+		t.Fatalf("login = %+v, want an MFA setup challenge", challenge)
+	}
+
+	// Enroll with the MFA token, then finish logging in
+	var setup struct {
+		Secret string `json:"secret"`
+	}
+	if code := mfaPost(t, ts, http.MethodPost, "/api/auth/mfa/setup", "", `{"mfa_token":"`+challenge.MFAToken+`"}`, &setup); code != http.StatusOK {
+		t.Fatalf("setup: status %d", code)
+	}
+	var result mfaLoginResponse
+	body := `{"mfa_token":"` + challenge.MFAToken + `","code":"` + totpNow(t, setup.Secret, false) + `"}`
+	if code := mfaPost(t, ts, http.MethodPost, "/api/auth/mfa/verify", "", body, &result); code != http.StatusOK {
+		t.Fatalf("verify: status %d", code)
+	}
+	if result.AccessToken == "" || len(result.BackupCodes) != auth.BackupCodeCount {
+		t.Fatalf("verify = %+v, want tokens and backup codes", result)
+	}
+
+	// A password alone cannot replace the authenticator
+	mfaPost(t, ts, http.MethodPost, "/api/auth/login", "", `{"username":"bob","password":"bob-pass-1234"}`, &challenge)
+	if code := mfaPost(t, ts, http.MethodPost, "/api/auth/mfa/setup", "", `{"mfa_token":"`+challenge.MFAToken+`"}`, nil); code != http.StatusConflict {
+		t.Errorf("setup while enabled: status %d, want 409", code)
+	}
+
+	// The policy stops users turning MFA off
+	if code := mfaPost(t, ts, http.MethodDelete, "/api/auth/mfa", result.AccessToken, `{"code":"`+result.BackupCodes[0]+`"}`, nil); code != http.StatusForbidden {
+		t.Errorf("disable under policy: status %d, want 403", code)
+	}
+
+	// Admins can reset it; the user then enrolls again
+	if code := statusOf(testutil.AuthDelete(t, ts.URL+"/api/admin/users/"+userID+"/mfa", ts.AdminToken)); code != http.StatusNoContent {
+		t.Fatalf("admin reset: status %d, want 204", code)
+	}
+	mfaPost(t, ts, http.MethodPost, "/api/auth/login", "", `{"username":"bob","password":"bob-pass-1234"}`, &challenge)
+	if !challenge.MFASetupRequired {
+		t.Errorf("login after reset = %+v, want an MFA setup challenge", challenge)
+	}
+}
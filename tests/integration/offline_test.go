@@ -0,0 +1,330 @@
+package integration
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/config"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestOffline(t *testing.T) {
+	ts := testutil.NewTestServer(t, testutil.WithOffline(), func(c *config.Config) { c.OutboundAllowlist = "updates.example.com" })
+
+	resp := testutil.AuthGet(t, ts.URL+"/api/config", ts.AdminToken)
+	var cfg struct {
+		Offline bool `json:"offline"`
+	}
+	testutil.ReadJSON(t, resp, &cfg)
+	if !cfg.Offline {
+		t.Error("/api/config offline = false, want true")
+	}
+
+	t.Run("outbound check refuses the internet", func(t *testing.T) {
+		for _, tt := range []struct {
+			url     string
+			allowed bool
+		}{
+			{"https://93.184.216.34/hook", false},
+			{"http://10.20.1.1/", false}, // Internal, but not allowlisted
+		} {
+			_, result := checkOutbound(t, ts, jsonBody(t, map[string]string{"url": tt.url}))
+			if result.Allowed != tt.allowed {
+				t.Errorf("%s: allowed = %v (%s), want %v", tt.url, result.Allowed, result.Reason, tt.allowed)
+			}
+		}
+	})
+
+	t.Run("icons must be local", func(t *testing.T) {
+		resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+			[]byte(`{"id":"remote-icon","name":"Remote","url":"https://example.com","icon":"https://example.com/favicon.ico","category":"Test"}`))
+		body := testutil.ReadBody(t, resp)
+		if resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, "/api/assets/icons") {
+			t.Errorf("create app with remote icon: status %d: %s", resp.StatusCode, body)
+		}
+
+		resp = testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+			[]byte(`{"id":"builtin-icon","name":"Builtin","url":"https://example.com","icon":"/api/assets/icons/builtin-gimp.svg","category":"Test"}`))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("create app with built-in icon: status %d, want 201", resp.StatusCode)
+		}
+
+		resp = testutil.AuthPut(t, ts.URL+"/api/apps/builtin-icon", ts.AdminToken,
+			[]byte(`{"id":"builtin-icon","name":"Builtin","url":"https://example.com","icon":"http://cdn.example.com/icon.png","category":"Test"}`))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("update app with remote icon: status %d, want 400", resp.StatusCode)
+		}
+
+		resp = testutil.AuthPost(t, ts.URL+"/api/admin/templates", ts.AdminToken,
+			[]byte(`{"template_id":"remote","name":"Remote","template_category":"dev","category":"Dev","icon":"https://example.com/icon.svg"}`))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("create template with remote icon: status %d, want 400", resp.StatusCode)
+		}
+	})
+
+	t.Run("bundle import refuses remote icons", func(t *testing.T) {
+		resp := testutil.AuthPost(t, ts.URL+"/api/admin/templates/import", ts.AdminToken,
+			[]byte(`{"templates":[{"template_id":"t1","name":"T1","template_category":"dev","category":"Dev","icon":"https://example.com/t1.png"}]}`))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("status %d, want 400", resp.StatusCode)
+		}
+	})
+}
+
+func TestOnline_RemoteIconsAllowed(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp := testutil.AuthGet(t, ts.URL+"/api/config", ts.AdminToken)
+	var cfg struct {
+		Offline bool `json:"offline"`
+	}
+	testutil.ReadJSON(t, resp, &cfg)
+	if cfg.Offline {
+		t.Error("/api/config offline = true, want false")
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"remote-icon","name":"Remote","url":"https://example.com","icon":"https://example.com/favicon.ico","category":"Test"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("create app with remote icon: status %d, want 201", resp.StatusCode)
+	}
+}
+
+// templateBundle builds a zip bundle from name/content pairs.
+func templateBundle(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(content)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+type templateImport struct {
+	DryRun  bool     `json:"dry_run"`
+	Created []string `json:"created"`
+	Updated []string `json:"updated"`
+	Icons   int      `json:"icons"`
+	Results []struct {
+		Index      int    `json:"index"`
+		TemplateID string `json:"template_id"`
+		Action     string `json:"action"`
+		Error      string `json:"error"`
+	} `json:"results"`
+}
+
+func TestTemplateImport(t *testing.T) {
+	ts := testutil.NewTestServer(t, testutil.WithOffline())
+
+	bundle := templateBundle(t, map[string][]byte{
+		"templates.json": []byte(`{"version":"1.0.0","templates":[
+			{"template_id":"internal-ide","name":"Internal IDE","template_category":"development","category":"Development",
+			 "icon":"icons/ide.png","container_image":"registry.local/ide:1.0"},
+			{"template_id":"internal-db","name":"Internal DB","template_category":"database","category":"Databases",
+			 "icon":"/api/assets/icons/builtin-pgadmin.svg","container_image":"registry.local/db:2.0"}
+		]}`),
+		"icons/ide.png": testPNG(t),
+	})
+	resp := testutil.AuthPost(t, ts.URL+"/api/admin/templates/import", ts.AdminToken, bundle)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("import: status %d: %s", resp.StatusCode, testutil.ReadBody(t, resp))
+	}
+	var result templateImport
+	testutil.ReadJSON(t, resp, &result)
+	if len(result.Created) != 2 || len(result.Updated) != 0 || result.Icons != 1 {
+		t.Errorf("import result = %+v, want 2 created and 1 icon", result)
+	}
+
+	resp = testutil.AuthGet(t, ts.URL+"/api/admin/templates/internal-ide", ts.AdminToken)
+	var ide struct {
+		Icon            string `json:"icon"`
+		TemplateVersion string `json:"template_version"`
+		LaunchType      string `json:"launch_type"`
+	}
+	testutil.ReadJSON(t, resp, &ide)
+	if !strings.HasPrefix(ide.Icon, "/api/assets/icons/") || !strings.HasSuffix(ide.Icon, ".png") {
+		t.Fatalf("imported icon = %q, want an uploaded icon URL", ide.Icon)
+	}
+	if ide.TemplateVersion != "1.0.0" || ide.LaunchType != "container" {
+		t.Errorf("defaults: version %q, launch type %q", ide.TemplateVersion, ide.LaunchType)
+	}
+	iconResp, err := http.Get(ts.URL + ide.Icon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := testutil.ReadBody(t, iconResp); iconResp.StatusCode != http.StatusOK || body != string(testPNG(t)) {
+		t.Errorf("GET %s: status %d", ide.Icon, iconResp.StatusCode)
+	}
+
+	// Importing a plain catalog replaces the existing template
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/templates/import", ts.AdminToken,
+		[]byte(`{"templates":[{"template_id":"internal-db","name":"Internal DB 2","template_category":"database","category":"Databases","template_version":"2.0.0"}]}`))
+	result = templateImport{}
+	testutil.ReadJSON(t, resp, &result)
+	if len(result.Created) != 0 || len(result.Updated) != 1 || result.Updated[0] != "internal-db" {
+		t.Errorf("re-import result = %+v, want internal-db updated", result)
+	}
+	resp = testutil.AuthGet(t, ts.URL+"/api/admin/templates/internal-db", ts.AdminToken)
+	var db struct {
+		Name string `json:"name"`
+	}
+	testutil.ReadJSON(t, resp, &db)
+	if db.Name != "Internal DB 2" {
+		t.Errorf("updated name = %q", db.Name)
+	}
+}
+
+func TestTemplateImport_Rejected(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	for name, body := range map[string][]byte{
+		"not a bundle":    []byte("hello"),
+		"empty":           []byte(`{"templates":[]}`),
+		"missing name":    []byte(`{"templates":[{"template_id":"t1","template_category":"dev","category":"Dev"}]}`),
+		"duplicate":       []byte(`{"templates":[{"template_id":"t1","name":"T1","template_category":"dev","category":"Dev"},{"template_id":"t1","name":"T1","template_category":"dev","category":"Dev"}]}`),
+		"no catalog file": templateBundle(t, map[string][]byte{"icons/a.png": testPNG(t)}),
+		"bad icon": templateBundle(t, map[string][]byte{
+			"templates.json": []byte(`{"templates":[{"template_id":"t1","name":"T1","template_category":"dev","category":"Dev","icon":"a.html"}]}`),
+			"a.html":         []byte("<html><script>alert(1)</script></html>"),
+		}),
+	} {
+		resp := testutil.AuthPost(t, ts.URL+"/api/admin/templates/import", ts.AdminToken, body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, resp.StatusCode)
+		}
+	}
+
+	// A rejected bundle changes nothing
+	resp := testutil.AuthGet(t, ts.URL+"/api/admin/templates/t1", ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("template from rejected bundle: status %d, want 404", resp.StatusCode)
+	}
+
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "author", "pass123", []string{"app-author"})
+	token := testutil.LoginAs(t, ts.URL, "author", "pass123")
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/templates/import", token,
+		[]byte(`{"templates":[{"template_id":"t1","name":"T1","template_category":"dev","category":"Dev"}]}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("app author: status %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestTemplateImport_DryRunAndResults(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	yamlBundle := []byte(`templates:
+  - template_id: yaml-ide
+    name: YAML IDE
+    template_category: development
+    category: Development
+    container_image: registry.local/ide:1.0
+    tags: [ide]
+`)
+	resp := testutil.AuthPost(t, ts.URL+"/api/admin/templates/import?dry_run=true", ts.AdminToken, yamlBundle)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("dry run: status %d: %s", resp.StatusCode, testutil.ReadBody(t, resp))
+	}
+	var result templateImport
+	testutil.ReadJSON(t, resp, &result)
+	if !result.DryRun || len(result.Created) != 1 || len(result.Results) != 1 || result.Results[0].Action != "create" {
+		t.Errorf("dry run result = %+v, want yaml-ide to be created", result)
+	}
+	resp = testutil.AuthGet(t, ts.URL+"/api/admin/templates/yaml-ide", ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("template after dry run: status %d, want 404", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/templates/import", ts.AdminToken, yamlBundle)
+	result = templateImport{}
+	testutil.ReadJSON(t, resp, &result)
+	if result.DryRun || len(result.Created) != 1 || result.Created[0] != "yaml-ide" {
+		t.Errorf("import result = %+v, want yaml-ide created", result)
+	}
+
+	// Each invalid template is reported, and nothing is imported
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/templates/import", ts.AdminToken, []byte(`{"templates":[
+		{"template_id":"yaml-ide","name":"YAML IDE 2","template_category":"development","category":"Development"},
+		{"template_id":"no-name","template_category":"dev","category":"Dev"},
+		{"template_id":"yaml-ide","name":"Again","template_category":"dev","category":"Dev"}
+	]}`))
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid import: status %d, want 400", resp.StatusCode)
+	}
+	result = templateImport{}
+	testutil.ReadJSON(t, resp, &result)
+	if len(result.Results) != 3 {
+		t.Fatalf("results = %+v, want 3", result.Results)
+	}
+	if r := result.Results[0]; r.Action != "update" || r.Error != "" {
+		t.Errorf("result 1 = %+v, want a valid update", r)
+	}
+	for _, r := range result.Results[1:] {
+		if r.Error == "" {
+			t.Errorf("result %d = %+v, want an error", r.Index, r)
+		}
+	}
+	resp = testutil.AuthGet(t, ts.URL+"/api/admin/templates/yaml-ide", ts.AdminToken)
+	var ide struct {
+		Name string `json:"name"`
+	}
+	testutil.ReadJSON(t, resp, &ide)
+	if ide.Name != "YAML IDE" {
+		t.Errorf("name after rejected import = %q, want unchanged", ide.Name)
+	}
+}
+
+func TestTemplateExport(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/admin/templates/import", ts.AdminToken,
+		[]byte(`{"templates":[{"template_id":"exported","name":"Exported","template_category":"dev","category":"Dev","container_port":8080,"tags":["a"]}]}`))
+	resp.Body.Close()
+
+	for _, format := range []string{"yaml", "json"} {
+		resp = testutil.AuthGet(t, ts.URL+"/api/admin/templates/export?format="+format, ts.AdminToken)
+		body := testutil.ReadBody(t, resp)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s export: status %d: %s", format, resp.StatusCode, body)
+		}
+		if !strings.Contains(resp.Header.Get("Content-Type"), format) {
+			t.Errorf("%s export: Content-Type %q", format, resp.Header.Get("Content-Type"))
+		}
+		if !strings.Contains(body, "exported") || strings.Contains(body, "created_at") {
+			t.Errorf("%s export:\n%s", format, body)
+		}
+
+		// The export imports back as updates of the same templates
+		resp = testutil.AuthPost(t, ts.URL+"/api/admin/templates/import?dry_run=true", ts.AdminToken, []byte(body))
+		var result templateImport
+		testutil.ReadJSON(t, resp, &result)
+		if len(result.Created) != 0 || len(result.Updated) == 0 {
+			t.Errorf("%s re-import = %+v, want only updates", format, result)
+		}
+	}
+
+	resp = testutil.AuthGet(t, ts.URL+"/api/admin/templates/export?format=xml", ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("xml export: status %d, want 400", resp.StatusCode)
+	}
+}
@@ -0,0 +1,102 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/config"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+type outboundCheck struct {
+	Allowed   bool     `json:"allowed"`
+	Addresses []string `json:"addresses"`
+	Reason    string   `json:"reason"`
+}
+
+func checkOutbound(t *testing.T, ts *testutil.TestServer, body string) (int, outboundCheck) {
+	t.Helper()
+	resp := testutil.AuthPost(t, ts.URL+"/api/admin/outbound/check", ts.AdminToken, []byte(body))
+	defer resp.Body.Close()
+	var result outboundCheck
+	json.NewDecoder(resp.Body).Decode(&result)
+	return resp.StatusCode, result
+}
+
+func TestOutboundCheck(t *testing.T) {
+	ts := testutil.NewTestServer(t, func(c *config.Config) { c.OutboundAllowlist = "10.20.0.0/16" })
+
+	for _, tt := range []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://93.184.216.34/hook", true},
+		{"http://169.254.169.254/latest/meta-data/", false},
+		{"http://127.0.0.1:8080/", false},
+		{"http://[::ffff:10.0.0.1]/", false},
+		{"http://10.20.1.1/", true}, // Server-wide allowlist
+		{"file:///etc/passwd", false},
+	} {
+		code, result := checkOutbound(t, ts, jsonBody(t, map[string]string{"url": tt.url}))
+		if code != http.StatusOK {
+			t.Fatalf("%s: status %d, want 200", tt.url, code)
+		}
+		if result.Allowed != tt.allowed {
+			t.Errorf("%s: allowed = %v (%s), want %v", tt.url, result.Allowed, result.Reason, tt.allowed)
+		}
+		if !result.Allowed && result.Reason == "" {
+			t.Errorf("%s: no reason given", tt.url)
+		}
+	}
+
+	t.Run("tenant allowlist", func(t *testing.T) {
+		resp := testutil.AuthPost(t, ts.URL+"/api/admin/tenants", ts.AdminToken,
+			[]byte(`{"name":"Acme","slug":"acme","settings":{"outbound_allowlist":["93.184.216.0/24","10.20.0.0/16"]}}`))
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("create tenant: status %d, want 201", resp.StatusCode)
+		}
+		var tenant struct {
+			ID string `json:"id"`
+		}
+		json.NewDecoder(resp.Body).Decode(&tenant)
+
+		for _, tt := range []struct {
+			url     string
+			allowed bool
+		}{
+			{"https://93.184.216.34/", true},
+			{"https://1.1.1.1/", false},
+			{"http://10.20.1.1/", true},
+		} {
+			_, result := checkOutbound(t, ts, jsonBody(t, map[string]string{"url": tt.url, "tenant_id": tenant.ID}))
+			if result.Allowed != tt.allowed {
+				t.Errorf("%s: allowed = %v (%s), want %v", tt.url, result.Allowed, result.Reason, tt.allowed)
+			}
+		}
+
+		if code, _ := checkOutbound(t, ts, `{"url":"https://93.184.216.34/","tenant_id":"no-such-tenant"}`); code != http.StatusNotFound {
+			t.Errorf("unknown tenant: status %d, want 404", code)
+		}
+	})
+
+	t.Run("invalid tenant allowlist", func(t *testing.T) {
+		resp := testutil.AuthPost(t, ts.URL+"/api/admin/tenants", ts.AdminToken,
+			[]byte(`{"name":"Bad","slug":"bad","settings":{"outbound_allowlist":["10.0.0.0/33"]}}`))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("status %d, want 400", resp.StatusCode)
+		}
+	})
+
+	t.Run("admin only", func(t *testing.T) {
+		testutil.CreateUser(t, ts.URL, ts.AdminToken, "alice", "alice-pass-123", []string{"user"})
+		token := testutil.LoginAs(t, ts.URL, "alice", "alice-pass-123")
+		resp := testutil.AuthPost(t, ts.URL+"/api/admin/outbound/check", token, []byte(`{"url":"https://93.184.216.34/"}`))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("status %d, want 403", resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,205 @@
+package integration
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/rjsadow/sortie/internal/config"
+	"github.com/rjsadow/sortie/internal/plugins/auth"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+const passkeyOrigin = "http://localhost:5173"
+
+var b64 = base64.RawURLEncoding
+
+// fakePasskey is a software ES256 authenticator.
+type fakePasskey struct {
+	key       *ecdsa.PrivateKey
+	id        []byte
+	signCount uint32
+}
+
+func newFakePasskey(t *testing.T) *fakePasskey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakePasskey{key: key, id: []byte("passkey-" + t.Name())}
+}
+
+func (p *fakePasskey) authData(rpID string, attested bool) []byte {
+	rpHash := sha256.Sum256([]byte(rpID))
+	flags := byte(0x01 | 0x04) // user present and verified
+	if attested {
+		flags |= 0x40
+	}
+	b := append(rpHash[:], flags)
+	b = binary.BigEndian.AppendUint32(b, p.signCount)
+	if attested {
+		x, y := make([]byte, 32), make([]byte, 32)
+		p.key.X.FillBytes(x)
+		p.key.Y.FillBytes(y)
+		coseKey, _ := cbor.Marshal(map[int]any{1: 2, 3: -7, -1: 1, -2: x, -3: y})
+		b = append(b, make([]byte, 16)...) // AAGUID
+		b = binary.BigEndian.AppendUint16(b, uint16(len(p.id)))
+		b = append(b, p.id...)
+		b = append(b, coseKey...)
+	}
+	return b
+}
+
+func passkeyClientData(ceremony, challenge string) []byte {
+	b, _ := json.Marshal(map[string]string{"type": ceremony, "challenge": challenge, "origin": passkeyOrigin})
+	return b
+}
+
+func (p *fakePasskey) create(opts auth.PasskeyCreationOptions) auth.PasskeyCredential {
+	att, _ := cbor.Marshal(map[string]any{"fmt": "none", "attStmt": map[string]any{}, "authData": p.authData(opts.RP.ID, true)})
+	var cred auth.PasskeyCredential
+	cred.ID = b64.EncodeToString(p.id)
+	cred.Type = "public-key"
+	cred.Response.ClientDataJSON = b64.EncodeToString(passkeyClientData("webauthn.create", opts.Challenge))
+	cred.Response.AttestationObject = b64.EncodeToString(att)
+	return cred
+}
+
+func (p *fakePasskey) get(opts auth.PasskeyRequestOptions) auth.PasskeyCredential {
+	p.signCount++
+	authData := p.authData(opts.RPID, false)
+	cd := passkeyClientData("webauthn.get", opts.Challenge)
+	hash := sha256.Sum256(cd)
+	sum := sha256.Sum256(append(authData, hash[:]...))
+	sig, _ := ecdsa.SignASN1(rand.Reader, p.key, sum[:])
+
+	var cred auth.PasskeyCredential
+	cred.ID = b64.EncodeToString(p.id)
+	cred.Type = "public-key"
+	cred.Response.ClientDataJSON = b64.EncodeToString(cd)
+	cred.Response.AuthenticatorData = b64.EncodeToString(authData)
+	cred.Response.Signature = b64.EncodeToString(sig)
+	return cred
+}
+
+func jsonBody(t *testing.T, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestPasskeys_RegisterAndLogin(t *testing.T) {
+	ts := testutil.NewTestServer(t, func(c *config.Config) {
+		c.WebAuthnRPID = "localhost"
+		c.WebAuthnOrigins = []string{passkeyOrigin}
+	})
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "alice", "alice-pass-123", []string{"user"})
+	token := testutil.LoginAs(t, ts.URL, "alice", "alice-pass-123")
+	passkey := newFakePasskey(t)
+
+	// Off until an admin enables it
+	if code := mfaPost(t, ts, http.MethodPost, "/api/auth/passkeys/login/options", "", "", nil); code != http.StatusForbidden {
+		t.Errorf("login options while disabled: status %d, want 403", code)
+	}
+	if code := statusOf(testutil.AuthPut(t, ts.URL+"/api/admin/settings", ts.AdminToken, []byte(`{"allow_passkeys":"yes"}`))); code != http.StatusBadRequest {
+		t.Errorf("non-boolean allow_passkeys: status %d, want 400", code)
+	}
+	if code := statusOf(testutil.AuthPut(t, ts.URL+"/api/admin/settings", ts.AdminToken, []byte(`{"allow_passkeys":"true"}`))); code != http.StatusNoContent {
+		t.Fatalf("enable passkeys: status %d", code)
+	}
+	var cfg struct {
+		PasskeysEnabled bool `json:"passkeys_enabled"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/config", ""), &cfg)
+	if !cfg.PasskeysEnabled {
+		t.Error("/api/config passkeys_enabled = false after enabling")
+	}
+
+	var created auth.PasskeyCreationOptions
+	if code := mfaPost(t, ts, http.MethodPost, "/api/auth/passkeys/register/options", token, "", &created); code != http.StatusOK || created.RP.ID != "localhost" {
+		t.Fatalf("register options: status %d, %+v", code, created)
+	}
+	body := jsonBody(t, map[string]any{"name": "Laptop", "credential": passkey.create(created)})
+	if code := mfaPost(t, ts, http.MethodPost, "/api/auth/passkeys/register", token, body, nil); code != http.StatusCreated {
+		t.Fatalf("register: status %d, want 201", code)
+	}
+	// The challenge was used up
+	if code := mfaPost(t, ts, http.MethodPost, "/api/auth/passkeys/register", token, body, nil); code != http.StatusUnauthorized {
+		t.Errorf("replayed registration: status %d, want 401", code)
+	}
+
+	var list []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	mfaPost(t, ts, http.MethodGet, "/api/auth/passkeys", token, "", &list)
+	if len(list) != 1 || list[0].Name != "Laptop" {
+		t.Fatalf("passkeys = %+v, want the Laptop passkey", list)
+	}
+
+	login := func() (int, mfaLoginResponse) {
+		t.Helper()
+		var opts auth.PasskeyRequestOptions
+		if code := mfaPost(t, ts, http.MethodPost, "/api/auth/passkeys/login/options", "", "", &opts); code != http.StatusOK {
+			t.Fatalf("login options: status %d", code)
+		}
+		var result mfaLoginResponse
+		code := mfaPost(t, ts, http.MethodPost, "/api/auth/passkeys/login", "", jsonBody(t, map[string]any{"credential": passkey.get(opts)}), &result)
+		return code, result
+	}
+
+	code, result := login()
+	if code != http.StatusOK || result.AccessToken == "" || result.RefreshToken == "" {
+		t.Fatalf("passkey login: status %d, %+v; want tokens", code, result)
+	}
+	if code := statusOf(testutil.AuthGet(t, ts.URL+"/api/sessions", result.AccessToken)); code != http.StatusOK {
+		t.Errorf("access token from passkey login: status %d, want 200", code)
+	}
+
+	// Registering needs a logged-in user
+	if code := mfaPost(t, ts, http.MethodPost, "/api/auth/passkeys/register/options", "", "", nil); code != http.StatusUnauthorized {
+		t.Errorf("register options without login: status %d, want 401", code)
+	}
+
+	// Password login keeps working alongside passkeys
+	if testutil.LoginAs(t, ts.URL, "alice", "alice-pass-123") == "" {
+		t.Error("password login returned no access token")
+	}
+
+	if code := mfaPost(t, ts, http.MethodDelete, "/api/auth/passkeys/"+list[0].ID, ts.AdminToken, "", nil); code != http.StatusNotFound {
+		t.Errorf("delete another user's passkey: status %d, want 404", code)
+	}
+	if code := mfaPost(t, ts, http.MethodDelete, "/api/auth/passkeys/"+list[0].ID, token, "", nil); code != http.StatusNoContent {
+		t.Fatalf("delete: status %d, want 204", code)
+	}
+	if code, _ := login(); code != http.StatusUnauthorized {
+		t.Errorf("login with a removed passkey: status %d, want 401", code)
+	}
+}
+
+func TestPasskeys_RequireConfiguredRelyingParty(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	// Without an explicit relying party ID and origins, passkeys cannot be
+	// turned on, and are never bound to the request's Host header
+	if code := statusOf(testutil.AuthPut(t, ts.URL+"/api/admin/settings", ts.AdminToken, []byte(`{"allow_passkeys":"true"}`))); code != http.StatusBadRequest {
+		t.Errorf("enable unconfigured passkeys: status %d, want 400", code)
+	}
+	if err := ts.DB.SetSetting("allow_passkeys", "true"); err != nil {
+		t.Fatalf("SetSetting() error = %v", err)
+	}
+	if code := mfaPost(t, ts, http.MethodPost, "/api/auth/passkeys/login/options", "", "", nil); code != http.StatusForbidden {
+		t.Errorf("login options while unconfigured: status %d, want 403", code)
+	}
+}
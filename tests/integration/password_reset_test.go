@@ -0,0 +1,197 @@
+package integration
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/config"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+// startMailSink runs a minimal SMTP server that accepts every message and
+// passes it on, parsed, to the returned channel.
+func startMailSink(t *testing.T) (int, <-chan *mail.Message) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	messages := make(chan *mail.Message, 10)
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+				reply("220 sink")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+					case cmd == "DATA":
+						reply("354 go ahead")
+						var data strings.Builder
+						for {
+							l, err := r.ReadString('\n')
+							if err != nil || l == ".\r\n" {
+								break
+							}
+							data.WriteString(l)
+						}
+						if msg, err := mail.ReadMessage(strings.NewReader(data.String())); err == nil {
+							messages <- msg
+						}
+						reply("250 queued")
+					case cmd == "QUIT":
+						reply("221 bye")
+						return
+					default:
+						reply("250 OK")
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, messages
+}
+
+var resetTokenPattern = regexp.MustCompile(`reset_token=([A-Za-z0-9_-]+)`)
+
+// waitForResetToken returns the recipient and reset token of the next
+// email the sink receives.
+func waitForResetToken(t *testing.T, messages <-chan *mail.Message) (string, string) {
+	t.Helper()
+	select {
+	case msg := <-messages:
+		body, _ := io.ReadAll(quotedprintable.NewReader(msg.Body))
+		m := resetTokenPattern.FindSubmatch(body)
+		if m == nil {
+			t.Fatalf("no reset link in email body %q", body)
+		}
+		return msg.Header.Get("To"), string(m[1])
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the reset email")
+		return "", ""
+	}
+}
+
+func postJSON(t *testing.T, url, body string) int {
+	t.Helper()
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestPasswordReset(t *testing.T) {
+	port, messages := startMailSink(t)
+	ts := testutil.NewTestServer(t, func(c *config.Config) {
+		c.SMTPHost = "127.0.0.1"
+		c.SMTPPort = port
+		c.SMTPFrom = "noreply@example.com"
+		c.SMTPTLS = "none"
+		c.PublicURL = "https://sortie.example.com"
+	})
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "alice", "alice-pass-123", []string{"user"})
+
+	if !getBrandingConfig(t, ts.URL, "").PasswordResetEnabled {
+		t.Error("password_reset_enabled = false, want true")
+	}
+
+	if code := postJSON(t, ts.URL+"/api/auth/forgot-password", `{"username":"alice"}`); code != http.StatusAccepted {
+		t.Fatalf("forgot-password: status %d, want 202", code)
+	}
+	to, token := waitForResetToken(t, messages)
+	if to != "<alice@test.local>" {
+		t.Errorf("email sent to %q, want <alice@test.local>", to)
+	}
+
+	if code := postJSON(t, ts.URL+"/api/auth/reset-password", jsonBody(t, map[string]string{"token": token, "password": "short"})); code != http.StatusBadRequest {
+		t.Errorf("short password: status %d, want 400", code)
+	}
+	if code := postJSON(t, ts.URL+"/api/auth/reset-password", jsonBody(t, map[string]string{"token": token, "password": "alice-new-456"})); code != http.StatusNoContent {
+		t.Fatalf("reset-password: status %d, want 204", code)
+	}
+	testutil.LoginAs(t, ts.URL, "alice", "alice-new-456")
+	if code := postJSON(t, ts.URL+"/api/auth/login", `{"username":"alice","password":"alice-pass-123"}`); code != http.StatusUnauthorized {
+		t.Errorf("old password: status %d, want 401", code)
+	}
+	if code := postJSON(t, ts.URL+"/api/auth/reset-password", jsonBody(t, map[string]string{"token": token, "password": "alice-other-789"})); code != http.StatusBadRequest {
+		t.Errorf("reused token: status %d, want 400", code)
+	}
+
+	t.Run("by email", func(t *testing.T) {
+		if code := postJSON(t, ts.URL+"/api/auth/forgot-password", `{"email":"ALICE@test.local"}`); code != http.StatusAccepted {
+			t.Fatalf("status %d, want 202", code)
+		}
+		if to, _ := waitForResetToken(t, messages); to != "<alice@test.local>" {
+			t.Errorf("email sent to %q, want <alice@test.local>", to)
+		}
+	})
+
+	t.Run("unknown account", func(t *testing.T) {
+		if code := postJSON(t, ts.URL+"/api/auth/forgot-password", `{"username":"nobody"}`); code != http.StatusAccepted {
+			t.Errorf("status %d, want 202", code)
+		}
+		select {
+		case <-messages:
+			t.Error("email sent for an unknown account")
+		case <-time.After(200 * time.Millisecond):
+		}
+	})
+
+	t.Run("audit", func(t *testing.T) {
+		resp := testutil.AuthGet(t, ts.URL+"/api/audit", ts.AdminToken)
+		defer resp.Body.Close()
+		var page struct {
+			Logs []struct {
+				Action string `json:"action"`
+				User   string `json:"user"`
+			} `json:"logs"`
+		}
+		json.NewDecoder(resp.Body).Decode(&page)
+		found := map[string]bool{}
+		for _, log := range page.Logs {
+			if log.User == "alice" {
+				found[log.Action] = true
+			}
+		}
+		for _, action := range []string{"PASSWORD_RESET_REQUESTED", "RESET_PASSWORD"} {
+			if !found[action] {
+				t.Errorf("no %s audit entry for alice", action)
+			}
+		}
+	})
+}
+
+func TestPasswordReset_Disabled(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	if getBrandingConfig(t, ts.URL, "").PasswordResetEnabled {
+		t.Error("password_reset_enabled = true without SMTP")
+	}
+	if code := postJSON(t, ts.URL+"/api/auth/forgot-password", `{"username":"admin"}`); code != http.StatusServiceUnavailable {
+		t.Errorf("forgot-password: status %d, want 503", code)
+	}
+	if code := postJSON(t, ts.URL+"/api/auth/reset-password", `{"token":"x","password":"secret123"}`); code != http.StatusServiceUnavailable {
+		t.Errorf("reset-password: status %d, want 503", code)
+	}
+}
@@ -0,0 +1,104 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestPrePull_StartListAndStop(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	createContainerApp(t, ts, "cad")
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"docs","name":"Docs","launch_type":"url","url":"https://example.com"}`))
+	resp.Body.Close()
+
+	for _, body := range []string{
+		`{"app_ids":[]}`,
+		`{"app_ids":["missing"]}`,
+		`{"app_ids":["docs"]}`,
+	} {
+		resp = testutil.AuthPost(t, ts.URL+"/api/admin/prepull", ts.AdminToken, []byte(body))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", body, resp.StatusCode)
+		}
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/prepull", ts.AdminToken, []byte(`{"app_ids":["cad"]}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected 202 starting a pre-pull, got %d", resp.StatusCode)
+	}
+
+	var list struct {
+		PrePulls []struct {
+			AppID        string   `json:"app_id"`
+			Images       []string `json:"images"`
+			DesiredNodes int      `json:"desired_nodes"`
+			Complete     bool     `json:"complete"`
+			Nodes        []struct {
+				Node   string `json:"node"`
+				Pulled int    `json:"pulled"`
+			} `json:"nodes"`
+		} `json:"prepulls"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/prepull", ts.AdminToken), &list)
+	if len(list.PrePulls) != 1 {
+		t.Fatalf("expected 1 pre-pull, got %+v", list.PrePulls)
+	}
+	p := list.PrePulls[0]
+	if p.AppID != "cad" || len(p.Images) == 0 || p.Images[0] != "nginx:latest" || !p.Complete {
+		t.Errorf("unexpected pre-pull %+v", p)
+	}
+	if len(p.Nodes) != 1 || p.Nodes[0].Pulled != len(p.Images) {
+		t.Errorf("unexpected node progress %+v", p.Nodes)
+	}
+
+	resp = testutil.AuthDelete(t, ts.URL+"/api/admin/prepull/cad", ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204 stopping the pre-pull, got %d", resp.StatusCode)
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/prepull", ts.AdminToken), &list)
+	if len(list.PrePulls) != 0 {
+		t.Errorf("expected no pre-pulls after stopping, got %+v", list.PrePulls)
+	}
+}
+
+func TestPrePull_Schedule(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"cad","name":"CAD","launch_type":"container","container_image":"cad:1","prepull_schedule":"every morning"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid schedule, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"cad","name":"CAD","launch_type":"container","container_image":"cad:1","prepull_schedule":"0 7 * * mon-fri"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+
+	app, err := ts.DB.GetApp("cad")
+	if err != nil || app == nil || app.PrePullSchedule != "0 7 * * mon-fri" {
+		t.Fatalf("GetApp() = %+v, %v", app, err)
+	}
+}
+
+func TestPrePull_AdminOnly(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "regular", "pass123", []string{"user"})
+	token := testutil.LoginAs(t, ts.URL, "regular", "pass123")
+
+	resp := testutil.AuthGet(t, ts.URL+"/api/admin/prepull", token)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for non-admin, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,270 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestProjects_MembershipAndLaunch(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"notebook","name":"Notebook","launch_type":"container","container_image":"jupyter:latest"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201 creating app, got %d", resp.StatusCode)
+	}
+
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "alice", "pass123", []string{"user"})
+	bobID := testutil.CreateUser(t, ts.URL, ts.AdminToken, "bob", "pass123", []string{"user"})
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "carol", "pass123", []string{"user"})
+	alice := testutil.LoginAs(t, ts.URL, "alice", "pass123")
+	bob := testutil.LoginAs(t, ts.URL, "bob", "pass123")
+	carol := testutil.LoginAs(t, ts.URL, "carol", "pass123")
+
+	// Only admins set a project's session limit or drive
+	resp = testutil.AuthPost(t, ts.URL+"/api/projects", alice, []byte(`{"name":"Genomics","max_sessions":5}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for a user setting max_sessions, got %d", resp.StatusCode)
+	}
+
+	var project struct {
+		ID   string `json:"id"`
+		Role string `json:"role"`
+	}
+	resp = testutil.AuthPost(t, ts.URL+"/api/projects", alice, []byte(`{"name":"Genomics","description":"Sequencing runs"}`))
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201 creating project, got %d", resp.StatusCode)
+	}
+	testutil.ReadJSON(t, resp, &project)
+	if project.ID == "" || project.Role != "owner" {
+		t.Fatalf("expected alice to own the new project, got %+v", project)
+	}
+	base := ts.URL + "/api/projects/" + project.ID
+
+	// Non-members cannot see the project or launch in it
+	resp = testutil.AuthGet(t, base, carol)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for a non-member, got %d", resp.StatusCode)
+	}
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", carol, []byte(fmt.Sprintf(`{"app_id":"notebook","project_id":%q}`, project.ID)))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for a non-member launching, got %d", resp.StatusCode)
+	}
+
+	// Viewers see the project but cannot launch in it
+	resp = testutil.AuthPost(t, base+"/members", alice, []byte(`{"username":"carol","role":"viewer"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 adding viewer, got %d", resp.StatusCode)
+	}
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", carol, []byte(fmt.Sprintf(`{"app_id":"notebook","project_id":%q}`, project.ID)))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for a viewer launching, got %d", resp.StatusCode)
+	}
+	resp = testutil.AuthPost(t, base+"/members", carol, []byte(`{"username":"bob"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for a viewer adding members, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, base+"/members", alice, []byte(fmt.Sprintf(`{"user_id":%q,"role":"member"}`, bobID)))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 adding member, got %d", resp.StatusCode)
+	}
+	var members []struct {
+		Username string `json:"username"`
+		Role     string `json:"role"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, base+"/members", carol), &members)
+	if len(members) != 3 || members[0].Username != "alice" || members[0].Role != "owner" {
+		t.Errorf("expected alice, carol and bob as members, got %+v", members)
+	}
+
+	var session struct {
+		ID        string `json:"id"`
+		ProjectID string `json:"project_id"`
+	}
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", bob, []byte(fmt.Sprintf(`{"app_id":"notebook","project_id":%q}`, project.ID)))
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201 for a member launching, got %d: %s", resp.StatusCode, testutil.ReadBody(t, resp))
+	}
+	testutil.ReadJSON(t, resp, &session)
+	if session.ProjectID != project.ID {
+		t.Errorf("expected session in project %s, got %+v", project.ID, session)
+	}
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions/"+session.ID+"/shares", bob, []byte(`{"link_share":true,"permission":"read_only"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201 sharing session, got %d", resp.StatusCode)
+	}
+
+	var sessions []struct {
+		ID string `json:"id"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, base+"/sessions", carol), &sessions)
+	if len(sessions) != 1 || sessions[0].ID != session.ID {
+		t.Errorf("expected the project's session, got %+v", sessions)
+	}
+
+	// Share links are only shown to owners
+	var shares []struct {
+		SessionID string `json:"session_id"`
+		ShareURL  string `json:"share_url"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, base+"/shares", carol), &shares)
+	if len(shares) != 1 || shares[0].SessionID != session.ID || shares[0].ShareURL != "" {
+		t.Errorf("expected the project's share without its link for a viewer, got %+v", shares)
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, base+"/shares", alice), &shares)
+	if len(shares) != 1 || shares[0].ShareURL == "" {
+		t.Errorf("expected the project's share with its link for the owner, got %+v", shares)
+	}
+
+	// The project cannot be deleted while it has sessions, nor lose its owner
+	resp = testutil.AuthDelete(t, base, alice)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected 409 deleting a project with sessions, got %d", resp.StatusCode)
+	}
+	resp = testutil.AuthDelete(t, base+"/members/"+project.ID, alice)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 removing a non-member, got %d", resp.StatusCode)
+	}
+
+	var listed []struct {
+		ID   string `json:"id"`
+		Role string `json:"role"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/projects", bob), &listed)
+	if len(listed) != 1 || listed[0].ID != project.ID || listed[0].Role != "member" {
+		t.Errorf("expected bob's project, got %+v", listed)
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/projects", ts.AdminToken), &listed)
+	if len(listed) != 1 {
+		t.Errorf("expected 1 project for admins, got %+v", listed)
+	}
+
+	// Members may leave
+	resp = testutil.AuthDelete(t, base+"/members/"+bobID, bob)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected 204 for a member leaving, got %d", resp.StatusCode)
+	}
+}
+
+func TestProjects_SessionLimitAndOwner(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"notebook","name":"Notebook","launch_type":"container","container_image":"jupyter:latest"}`))
+	resp.Body.Close()
+
+	aliceID := testutil.CreateUser(t, ts.URL, ts.AdminToken, "alice", "pass123", []string{"user"})
+	alice := testutil.LoginAs(t, ts.URL, "alice", "pass123")
+
+	var project struct {
+		ID          string `json:"id"`
+		MaxSessions int    `json:"max_sessions"`
+	}
+	resp = testutil.AuthPost(t, ts.URL+"/api/projects", ts.AdminToken, []byte(`{"name":"Lab","max_sessions":1,"drive":{"mount_path":"/data","size":"20Gi"}}`))
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201 creating project, got %d", resp.StatusCode)
+	}
+	testutil.ReadJSON(t, resp, &project)
+	base := ts.URL + "/api/projects/" + project.ID
+
+	resp = testutil.AuthPost(t, base+"/members", ts.AdminToken, []byte(fmt.Sprintf(`{"user_id":%q,"role":"owner"}`, aliceID)))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 adding owner, got %d", resp.StatusCode)
+	}
+
+	// Owners keep the admin's limit when they leave it out
+	resp = testutil.AuthPut(t, base, alice, []byte(`{"name":"Renamed Lab"}`))
+	testutil.ReadJSON(t, resp, &project)
+	if project.MaxSessions != 1 {
+		t.Errorf("expected the session limit to be kept, got %+v", project)
+	}
+	resp = testutil.AuthPut(t, base, alice, []byte(`{"name":"Renamed Lab","max_sessions":10}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for an owner raising the limit, got %d", resp.StatusCode)
+	}
+
+	var session struct {
+		ID string `json:"id"`
+	}
+	body := []byte(fmt.Sprintf(`{"app_id":"notebook","project_id":%q}`, project.ID))
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", alice, body)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+	testutil.ReadJSON(t, resp, &session)
+
+	// Sessions in the project mount its drive
+	workload := ts.Runner.Workload("session-" + session.ID)
+	if workload == nil || workload.Config.ProjectDrive == nil || workload.Config.ProjectDrive.MountPath != "/data" {
+		t.Fatalf("expected the project drive mounted at /data, got %+v", workload)
+	}
+	if !ts.Runner.HasProjectDrive(project.ID) {
+		t.Error("expected the project drive to be created")
+	}
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", alice, body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected 429 over the project's limit, got %d", resp.StatusCode)
+	}
+
+	// The last owner cannot leave or be demoted
+	resp = testutil.AuthPost(t, base+"/members", ts.AdminToken, []byte(fmt.Sprintf(`{"user_id":%q,"role":"member"}`, aliceID)))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 demoting one of two owners, got %d", resp.StatusCode)
+	}
+	var adminID string
+	var members []struct {
+		UserID string `json:"user_id"`
+		Role   string `json:"role"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, base+"/members", ts.AdminToken), &members)
+	for _, m := range members {
+		if m.Role == "owner" {
+			adminID = m.UserID
+		}
+	}
+	resp = testutil.AuthDelete(t, base+"/members/"+adminID, ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected 409 removing the last owner, got %d", resp.StatusCode)
+	}
+
+	var status struct {
+		Status string `json:"status"`
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) && status.Status != "running" {
+		testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/sessions/"+session.ID, alice), &status)
+		time.Sleep(50 * time.Millisecond)
+	}
+	resp = testutil.AuthDelete(t, ts.URL+"/api/sessions/"+session.ID, alice)
+	resp.Body.Close()
+	resp = testutil.AuthDelete(t, base, ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204 deleting an idle project, got %d", resp.StatusCode)
+	}
+	if ts.Runner.HasProjectDrive(project.ID) {
+		t.Error("expected the project drive to be deleted with the project")
+	}
+}
@@ -0,0 +1,97 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestImagePullSecret_AppAndTenantDefault(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"bad-app","name":"Bad","launch_type":"container","container_image":"nginx:latest","image_pull_secret":"Registry/Creds"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid secret name, got %d", resp.StatusCode)
+	}
+
+	for _, body := range []string{
+		`{"id":"private-app","name":"Private","launch_type":"container","container_image":"registry.example.com/private:latest","image_pull_secret":"private-creds"}`,
+		`{"id":"public-app","name":"Public","launch_type":"container","container_image":"nginx:latest"}`,
+	} {
+		resp = testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken, []byte(body))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected 201 creating app, got %d", resp.StatusCode)
+		}
+	}
+
+	resp = testutil.AuthPut(t, ts.URL+"/api/admin/tenants/default", ts.AdminToken,
+		[]byte(`{"name":"Default","settings":{"image_pull_secret":"tenant_creds"}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid tenant secret name, got %d", resp.StatusCode)
+	}
+	resp = testutil.AuthPut(t, ts.URL+"/api/admin/tenants/default", ts.AdminToken,
+		[]byte(`{"name":"Default","settings":{"image_pull_secret":"tenant-creds"}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 updating tenant, got %d", resp.StatusCode)
+	}
+
+	// The app's own secret wins over the tenant's default
+	for appID, want := range map[string]string{"private-app": "private-creds", "public-app": "tenant-creds"} {
+		var session struct {
+			ID string `json:"id"`
+		}
+		testutil.ReadJSON(t, testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, []byte(`{"app_id":"`+appID+`"}`)), &session)
+		workload := ts.Runner.Workload("session-" + session.ID)
+		if workload == nil || workload.Config.ImagePullSecret != want {
+			t.Errorf("expected %s workload pulled with %s, got %+v", appID, want, workload)
+		}
+	}
+}
+
+func TestImagePullSecret_OnlyAdminsSet(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "author", "pass123", []string{"app-author"})
+	authorToken := testutil.LoginAs(t, ts.URL, "author", "pass123")
+
+	body := []byte(`{"id":"author-app","name":"Author App","launch_type":"container","container_image":"nginx:latest","image_pull_secret":"private-creds"}`)
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", authorToken, body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for app author setting image_pull_secret, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken, body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+
+	// The author's edits keep the admin's secret
+	resp = testutil.AuthPut(t, ts.URL+"/api/apps/author-app", authorToken,
+		[]byte(`{"name":"Renamed","launch_type":"container","container_image":"nginx:latest"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var app struct {
+		ImagePullSecret string `json:"image_pull_secret"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/apps/author-app", ts.AdminToken), &app)
+	if app.ImagePullSecret != "private-creds" {
+		t.Errorf("expected image_pull_secret to be kept, got %q", app.ImagePullSecret)
+	}
+
+	resp = testutil.AuthPut(t, ts.URL+"/api/apps/author-app", authorToken,
+		[]byte(`{"name":"Renamed","launch_type":"container","container_image":"nginx:latest","image_pull_secret":"other-creds"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for app author changing image_pull_secret, got %d", resp.StatusCode)
+	}
+}
@@ -3,9 +3,13 @@ package integration
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/rjsadow/sortie/internal/runner"
 	"github.com/rjsadow/sortie/tests/integration/testutil"
 )
 
@@ -104,3 +108,115 @@ func TestQuota_StoppingSessionFreesQuota(t *testing.T) {
 		t.Errorf("expected 201 after freeing quota, got %d", resp.StatusCode)
 	}
 }
+
+func TestQuota_BurstAboveSoftLimit(t *testing.T) {
+	ts := testutil.NewTestServer(t, testutil.WithMaxSessionsPerUser(1), testutil.WithSessionBurst(2, 30*time.Minute))
+
+	createContainerApp(t, ts, "burst-app")
+
+	// The second session bursts above the soft limit; the third exceeds the burst limit
+	for i, want := range []int{http.StatusCreated, http.StatusCreated, http.StatusTooManyRequests} {
+		resp := testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, []byte(`{"app_id":"burst-app"}`))
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("session %d: expected %d, got %d", i, want, resp.StatusCode)
+		}
+	}
+
+	var status struct {
+		Burst *struct {
+			MaxSessions      int   `json:"max_sessions"`
+			RemainingSeconds int64 `json:"remaining_seconds"`
+			Bursting         bool  `json:"bursting"`
+			Available        bool  `json:"available"`
+		} `json:"burst"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/quotas", ts.AdminToken), &status)
+	if status.Burst == nil {
+		t.Fatal("expected burst in quota status")
+	}
+	if status.Burst.MaxSessions != 2 || !status.Burst.Bursting || status.Burst.Available || status.Burst.RemainingSeconds <= 0 {
+		t.Errorf("unexpected burst status: %+v", *status.Burst)
+	}
+}
+
+func TestQuota_ClusterCapacityShortfall(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	createContainerApp(t, ts, "capacity-app")
+	body := []byte(`{"app_id":"capacity-app","user_id":"capuser"}`)
+
+	// A session no node can fit is rejected outright
+	ts.Runner.SetCapacity(&runner.CapacityError{Reason: "no node can fit the session's requests"})
+	resp := testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected 409, got %d", resp.StatusCode)
+	}
+
+	// Without a queue to wait in, a temporary shortfall asks the client to retry
+	ts.Runner.SetCapacity(&runner.CapacityError{Reason: "quota used up", Temporary: true})
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("expected a Retry-After header")
+	}
+
+	ts.Runner.SetCapacity(nil)
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("expected 201 once there is capacity, got %d", resp.StatusCode)
+	}
+}
+
+func TestQuota_AppConcurrencyLimit(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	body := []byte(`{"id":"seats-app","name":"Seats App","launch_type":"container","container_image":"nginx:latest","max_concurrent_sessions":2}`)
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken, body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("failed to create app: status %d", resp.StatusCode)
+	}
+
+	// The limit is shared by all users
+	for i := 0; i < 2; i++ {
+		body := []byte(fmt.Sprintf(`{"app_id":"seats-app","user_id":"user%d"}`, i))
+		resp := testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("session %d creation failed: %d", i, resp.StatusCode)
+		}
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, []byte(`{"app_id":"seats-app","user_id":"user99"}`))
+	msg, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", resp.StatusCode)
+	}
+	if !strings.Contains(string(msg), "app Seats App at capacity (2/2 sessions)") {
+		t.Errorf("error = %q, want the app at capacity", msg)
+	}
+
+	resp = testutil.AuthGet(t, ts.URL+"/api/apps/seats-app", ts.AdminToken)
+	var app struct {
+		MaxConcurrentSessions int  `json:"max_concurrent_sessions"`
+		ActiveSessions        *int `json:"active_sessions"`
+	}
+	testutil.ReadJSON(t, resp, &app)
+	if app.MaxConcurrentSessions != 2 || app.ActiveSessions == nil || *app.ActiveSessions != 2 {
+		t.Errorf("app = max %d, active %v; want 2 of 2", app.MaxConcurrentSessions, app.ActiveSessions)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"bad-app","name":"Bad App","launch_type":"container","container_image":"nginx:latest","max_concurrent_sessions":-1}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("negative max_concurrent_sessions: expected 400, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,41 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestRDPSettings_ValidatedAndStored(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"bad-app","name":"Bad","launch_type":"container","os_type":"windows","container_image":"win:latest","rdp_settings":{"security":"kerberos"}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown security mode, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"win-app","name":"Win","launch_type":"container","os_type":"windows","container_image":"win:latest","rdp_settings":{"security":"nla","domain":"CORP"}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+
+	var app struct {
+		RDPSettings map[string]interface{} `json:"rdp_settings"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/apps/win-app", ts.AdminToken), &app)
+	if app.RDPSettings["security"] != "nla" || app.RDPSettings["domain"] != "CORP" {
+		t.Errorf("expected rdp settings to be stored, got %v", app.RDPSettings)
+	}
+
+	resp = testutil.AuthPut(t, ts.URL+"/api/apps/win-app", ts.AdminToken,
+		[]byte(`{"name":"Win","launch_type":"container","os_type":"windows","container_image":"win:latest","rdp_settings":{"gateway_port":443}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for gateway port without hostname, got %d", resp.StatusCode)
+	}
+}
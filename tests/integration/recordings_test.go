@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -417,6 +419,77 @@ func TestRecording_DownloadAccessControl(t *testing.T) {
 	}
 }
 
+func TestRecording_Stream(t *testing.T) {
+	ts := testutil.NewTestServer(t, testutil.WithRecordingEnabled())
+	ownerID := testutil.CreateUser(t, ts.URL, ts.AdminToken, "stream-owner", "pass123", []string{"user"})
+	ownerToken := testutil.LoginAs(t, ts.URL, "stream-owner", "pass123")
+	recID := recStartAndUpload(t, ts, "stream-app", ownerToken, ownerID)
+
+	// Unconverted recordings have nothing a browser can play
+	resp := testutil.AuthGet(t, ts.URL+"/api/recordings/"+recID+"/stream", ownerToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unconverted stream: expected 404, got %d", resp.StatusCode)
+	}
+
+	// Stand the uploaded file in for the converted video
+	rec, err := ts.DB.GetRecording(recID)
+	if err != nil || rec == nil {
+		t.Fatalf("GetRecording: %v", err)
+	}
+	if err := ts.DB.UpdateRecordingVideoPath(recID, rec.StoragePath); err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/recordings/"+recID+"/stream", nil)
+	req.Header.Set("Authorization", "Bearer "+ownerToken)
+	req.Header.Set("Range", "bytes=6-")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := testutil.ReadBody(t, resp); resp.StatusCode != http.StatusPartialContent || body != "video" {
+		t.Errorf("ranged stream: status %d, body %q; want 206 \"video\"", resp.StatusCode, body)
+	}
+
+	// Without credentials, only a signed URL is accepted
+	resp, err = http.Get(ts.URL + "/api/recordings/" + recID + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unauthenticated stream: expected 401, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthGet(t, ts.URL+"/api/recordings/"+recID+"/stream-link", ownerToken)
+	var link struct {
+		URL       string    `json:"url"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	testutil.ReadJSON(t, resp, &link)
+	if !strings.HasPrefix(link.URL, "/api/recordings/"+recID+"/stream?") || link.ExpiresAt.Before(time.Now()) {
+		t.Fatalf("stream link = %+v", link)
+	}
+	resp, err = http.Get(ts.URL + link.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := testutil.ReadBody(t, resp); resp.StatusCode != http.StatusOK || body != "dummy-video" {
+		t.Errorf("signed stream: status %d, body %q", resp.StatusCode, body)
+	}
+
+	// The signature does not unlock other routes
+	resp, err = http.Get(ts.URL + strings.Replace(link.URL, "/stream?", "/download?", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("signed download: expected 401, got %d", resp.StatusCode)
+	}
+}
+
 // recStartAndUpload is a helper that creates a session, starts a recording,
 // stops it, uploads a dummy file, and returns the recording ID.
 func recStartAndUpload(t *testing.T, ts *testutil.TestServer, appID, token, userID string) string {
@@ -463,3 +536,88 @@ func recStartAndUpload(t *testing.T, ts *testutil.TestServer, appID, token, user
 
 	return recordingID
 }
+
+func TestAdminStorage_RecordingOrphans(t *testing.T) {
+	ts := testutil.NewTestServer(t, testutil.WithRecordingEnabled())
+	recordingID := recStartAndUpload(t, ts, "storage-app", ts.AdminToken, "admin-admin")
+	rec, err := ts.DB.GetRecording(recordingID)
+	if err != nil || rec == nil {
+		t.Fatalf("GetRecording() = %v, %v", rec, err)
+	}
+
+	orphanPath := filepath.Join("2020", "01", "orphan.vncrec")
+	if err := os.MkdirAll(filepath.Join(ts.RecordingDir, "2020", "01"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(ts.RecordingDir, orphanPath), []byte("left behind"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Usage
+	resp := testutil.AuthGet(t, ts.URL+"/api/admin/storage", ts.AdminToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("storage usage: expected 200, got %d", resp.StatusCode)
+	}
+	var usage struct {
+		Categories []struct {
+			Category string `json:"category"`
+			Backend  string `json:"backend"`
+			Objects  int    `json:"objects"`
+			Orphaned int    `json:"orphaned"`
+		} `json:"categories"`
+	}
+	testutil.ReadJSON(t, resp, &usage)
+	if len(usage.Categories) != 1 || usage.Categories[0].Category != "recordings" || usage.Categories[0].Backend != "local" {
+		t.Fatalf("unexpected categories: %+v", usage.Categories)
+	}
+	if usage.Categories[0].Objects < 2 || usage.Categories[0].Orphaned < 1 {
+		t.Errorf("usage = %+v, want at least 2 objects and 1 orphan", usage.Categories[0])
+	}
+
+	// Orphans include the stray file but not the recording
+	resp = testutil.AuthGet(t, ts.URL+"/api/admin/storage/recordings/orphans", ts.AdminToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("list orphans: expected 200, got %d", resp.StatusCode)
+	}
+	var orphans struct {
+		Orphans []struct {
+			Path string `json:"path"`
+		} `json:"orphans"`
+	}
+	testutil.ReadJSON(t, resp, &orphans)
+	paths := map[string]bool{}
+	for _, o := range orphans.Orphans {
+		paths[o.Path] = true
+	}
+	if !paths[orphanPath] || paths[rec.StoragePath] {
+		t.Errorf("orphans = %v, want %s and not %s", paths, orphanPath, rec.StoragePath)
+	}
+
+	// Referenced objects cannot be cleaned up
+	body, _ := json.Marshal(map[string][]string{"paths": {rec.StoragePath}})
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/storage/recordings/cleanup", ts.AdminToken, body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("cleanup referenced object: expected 400, got %d", resp.StatusCode)
+	}
+
+	body, _ = json.Marshal(map[string][]string{"paths": {orphanPath}})
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/storage/recordings/cleanup", ts.AdminToken, body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("cleanup: expected 200, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+	if _, err := os.Stat(filepath.Join(ts.RecordingDir, orphanPath)); !os.IsNotExist(err) {
+		t.Errorf("orphan still exists: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(ts.RecordingDir, rec.StoragePath)); err != nil {
+		t.Errorf("recording file removed: %v", err)
+	}
+
+	// Unknown category
+	resp = testutil.AuthGet(t, ts.URL+"/api/admin/storage/backups/orphans", ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown category: expected 404, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,124 @@
+package integration
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestRegistries_CRUDAndSecretSync(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	for _, body := range []string{
+		`{"name":"Bad/Name","server":"ghcr.io","username":"bot","password":"s3cret"}`,
+		`{"name":"ghcr","server":"","username":"bot","password":"s3cret"}`,
+		`{"name":"ghcr","server":"ghcr.io","username":"bot"}`,
+	} {
+		resp := testutil.AuthPost(t, ts.URL+"/api/admin/registries", ts.AdminToken, []byte(body))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", body, resp.StatusCode)
+		}
+	}
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/admin/registries", ts.AdminToken,
+		[]byte(`{"name":"ghcr","server":"ghcr.io","username":"bot","password":"s3cret"}`))
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+	body := testutil.ReadBody(t, resp)
+	if strings.Contains(body, "s3cret") || strings.Contains(body, "password") {
+		t.Errorf("response exposes the password: %s", body)
+	}
+	if !strings.Contains(body, `"synced_at"`) {
+		t.Errorf("expected a synced credential, got %s", body)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/registries", ts.AdminToken,
+		[]byte(`{"name":"ghcr","server":"ghcr.io","username":"bot","password":"s3cret"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected 409 for duplicate name, got %d", resp.StatusCode)
+	}
+
+	secret := ts.Runner.RegistrySecret("ghcr")
+	if secret == nil || secret.Server != "ghcr.io" || secret.Username != "bot" || secret.Password != "s3cret" {
+		t.Fatalf("expected secret written with the credential, got %+v", secret)
+	}
+
+	// The password is encrypted at rest
+	stored, err := ts.DB.GetRegistryCredential("ghcr")
+	if err != nil || stored == nil {
+		t.Fatalf("GetRegistryCredential() = %v, %v", stored, err)
+	}
+	if stored.EncryptedPassword == "" || strings.Contains(stored.EncryptedPassword, "s3cret") {
+		t.Errorf("password stored as %q", stored.EncryptedPassword)
+	}
+
+	// Updating without a password rewrites the secret with the stored one
+	resp = testutil.AuthPut(t, ts.URL+"/api/admin/registries/ghcr", ts.AdminToken,
+		[]byte(`{"server":"ghcr.io","username":"robot"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 updating, got %d", resp.StatusCode)
+	}
+	if secret := ts.Runner.RegistrySecret("ghcr"); secret.Username != "robot" || secret.Password != "s3cret" {
+		t.Errorf("expected secret for robot with the stored password, got %+v", secret)
+	}
+
+	resp = testutil.AuthPut(t, ts.URL+"/api/admin/registries/ghcr", ts.AdminToken,
+		[]byte(`{"server":"ghcr.io","username":"robot","password":"rotated"}`))
+	resp.Body.Close()
+	if secret := ts.Runner.RegistrySecret("ghcr"); secret.Password != "rotated" {
+		t.Errorf("expected rotated password in secret, got %+v", secret)
+	}
+
+	var list []map[string]any
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/registries", ts.AdminToken), &list)
+	if len(list) != 1 || list[0]["name"] != "ghcr" || list[0]["username"] != "robot" {
+		t.Errorf("unexpected registry list %v", list)
+	}
+
+	// Apps reference the credential by name through their image pull secret
+	resp = testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"private-app","name":"Private","launch_type":"container","container_image":"ghcr.io/acme/app:latest","image_pull_secret":"ghcr"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201 creating app, got %d", resp.StatusCode)
+	}
+	resp = testutil.AuthDelete(t, ts.URL+"/api/admin/registries/ghcr", ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected 409 deleting a credential in use, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthDelete(t, ts.URL+"/api/apps/private-app", ts.AdminToken)
+	resp.Body.Close()
+	resp = testutil.AuthDelete(t, ts.URL+"/api/admin/registries/ghcr", ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204 deleting, got %d", resp.StatusCode)
+	}
+	if ts.Runner.RegistrySecret("ghcr") != nil {
+		t.Error("expected secret deleted with the credential")
+	}
+	resp = testutil.AuthGet(t, ts.URL+"/api/admin/registries/ghcr", ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 after delete, got %d", resp.StatusCode)
+	}
+}
+
+func TestRegistries_AdminOnly(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "regular", "pass123", []string{"user"})
+	token := testutil.LoginAs(t, ts.URL, "regular", "pass123")
+
+	resp := testutil.AuthGet(t, ts.URL+"/api/admin/registries", token)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for non-admin, got %d", resp.StatusCode)
+	}
+}
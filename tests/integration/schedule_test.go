@@ -0,0 +1,61 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestSchedule_InvalidScheduleRejected(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"bad","name":"Bad","launch_type":"container","container_image":"nginx:latest","schedule":{"timezone":"Mars/Olympus","windows":[{"start":"09:00","end":"17:00"}]}}`))
+	body := testutil.ReadBody(t, resp)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", resp.StatusCode, body)
+	}
+	if !strings.Contains(body, "Invalid schedule") {
+		t.Errorf("expected schedule error, got %q", body)
+	}
+}
+
+func TestSchedule_BlackoutBlocksLaunch(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	now := time.Now().UTC()
+	app := fmt.Sprintf(`{"id":"scheduled","name":"Scheduled","launch_type":"container","container_image":"nginx:latest",`+
+		`"schedule":{"blackouts":[{"start":%q,"end":%q,"reason":"upgrade"}]}}`,
+		now.Add(-time.Hour).Format(time.RFC3339), now.Add(time.Hour).Format(time.RFC3339))
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken, []byte(app))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, []byte(`{"app_id":"scheduled"}`))
+	body := testutil.ReadBody(t, resp)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 during blackout, got %d: %s", resp.StatusCode, body)
+	}
+	if !strings.Contains(body, "upgrade") {
+		t.Errorf("expected blackout reason in response, got %q", body)
+	}
+
+	// Removing the blackout opens the app again
+	resp = testutil.AuthPut(t, ts.URL+"/api/apps/scheduled", ts.AdminToken,
+		[]byte(`{"name":"Scheduled","launch_type":"container","container_image":"nginx:latest"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 updating app, got %d", resp.StatusCode)
+	}
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, []byte(`{"app_id":"scheduled"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("expected 201 after removing blackout, got %d", resp.StatusCode)
+	}
+}
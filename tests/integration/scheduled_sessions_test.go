@@ -0,0 +1,131 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/scheduledsessions"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestScheduledSessions(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	createContainerApp(t, ts, "lab-app")
+	studentID := testutil.CreateUser(t, ts.URL, ts.AdminToken, "student", "pass123", []string{"user"})
+	studentToken := testutil.LoginAs(t, ts.URL, "student", "pass123")
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "other", "pass123", []string{"user"})
+	otherToken := testutil.LoginAs(t, ts.URL, "other", "pass123")
+
+	schedule := func(token, body string) (*http.Response, db.ScheduledSession) {
+		t.Helper()
+		resp := testutil.AuthPost(t, ts.URL+"/api/sessions/schedule", token, []byte(body))
+		var s db.ScheduledSession
+		if resp.StatusCode == http.StatusCreated {
+			testutil.ReadJSON(t, resp, &s)
+		} else {
+			resp.Body.Close()
+		}
+		return resp, s
+	}
+	soon := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	t.Run("rejects invalid schedules", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+		for name, body := range map[string]string{
+			"missing start":    `{"app_id":"lab-app"}`,
+			"unknown app":      fmt.Sprintf(`{"app_id":"nope","start_at":%q}`, soon),
+			"in the past":      fmt.Sprintf(`{"app_id":"lab-app","start_at":%q}`, past),
+			"bad recurrence":   fmt.Sprintf(`{"app_id":"lab-app","start_at":%q,"recurrence":"every morning"}`, soon),
+			"bad timezone":     fmt.Sprintf(`{"app_id":"lab-app","start_at":%q,"recurrence":"@daily","timezone":"Nowhere/City"}`, soon),
+			"end before start": fmt.Sprintf(`{"app_id":"lab-app","start_at":%q,"recurrence":"@daily","ends_at":%q}`, soon, past),
+		} {
+			if resp, _ := schedule(studentToken, body); resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("%s: status %d, want 400", name, resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("only admins schedule for others", func(t *testing.T) {
+		resp, _ := schedule(otherToken, fmt.Sprintf(`{"app_id":"lab-app","start_at":%q,"user_id":%q}`, soon, studentID))
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("status %d, want 403", resp.StatusCode)
+		}
+	})
+
+	resp, recurring := schedule(studentToken, fmt.Sprintf(
+		`{"app_id":"lab-app","start_at":%q,"recurrence":"45 7 * * mon-fri","timezone":"America/Chicago"}`, soon))
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("schedule recurring: status %d", resp.StatusCode)
+	}
+	if recurring.UserID != studentID || recurring.NextRunAt == nil || recurring.Timezone != "America/Chicago" {
+		t.Fatalf("recurring schedule = %+v", recurring)
+	}
+	if local := recurring.NextRunAt.In(mustLoadLocation(t, "America/Chicago")); local.Hour() != 7 || local.Minute() != 45 {
+		t.Errorf("next run = %s, want 07:45 in Chicago", local)
+	}
+
+	// An admin schedules a launch for the student that is due now
+	resp, due := schedule(ts.AdminToken, fmt.Sprintf(`{"app_id":"lab-app","start_at":%q,"user_id":%q}`,
+		time.Now().UTC().Format(time.RFC3339), studentID))
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("admin schedule: status %d", resp.StatusCode)
+	}
+
+	var list []db.ScheduledSession
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/sessions/schedule", studentToken), &list)
+	if len(list) != 2 {
+		t.Errorf("student lists %d schedules, want 2", len(list))
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/sessions/schedule", otherToken), &list)
+	if len(list) != 0 {
+		t.Errorf("other user lists %d schedules, want 0", len(list))
+	}
+	resp = testutil.AuthGet(t, ts.URL+"/api/sessions/schedule/"+recurring.ID, otherToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("other user gets the student's schedule: status %d, want 404", resp.StatusCode)
+	}
+
+	// The background job launches the due schedule as the student
+	if err := scheduledsessions.NewRunner(ts.DB, ts.SessionManager).Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var launched db.ScheduledSession
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/sessions/schedule/"+due.ID, studentToken), &launched)
+	if launched.LastSessionID == "" || launched.LastError != "" || launched.NextRunAt != nil {
+		t.Fatalf("after launch = %+v, want a session and no next run", launched)
+	}
+	session, err := ts.DB.GetSession(launched.LastSessionID)
+	if err != nil || session == nil || session.UserID != studentID || session.AppID != "lab-app" {
+		t.Errorf("launched session = %+v, %v", session, err)
+	}
+
+	resp = testutil.AuthDelete(t, ts.URL+"/api/sessions/schedule/"+recurring.ID, otherToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("other user deletes: status %d, want 404", resp.StatusCode)
+	}
+	resp = testutil.AuthDelete(t, ts.URL+"/api/sessions/schedule/"+recurring.ID, studentToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("delete: status %d, want 204", resp.StatusCode)
+	}
+	resp = testutil.AuthGet(t, ts.URL+"/api/sessions/schedule/"+recurring.ID, studentToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("deleted schedule: status %d, want 404", resp.StatusCode)
+	}
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
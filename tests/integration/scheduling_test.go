@@ -0,0 +1,85 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestScheduling_AppliedToWorkload(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"bad-app","name":"Bad","launch_type":"container","container_image":"nginx:latest","scheduling":{"tolerations":[{"key":"gpu","effect":"Sometimes"}]}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid toleration, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"gpu-app","name":"GPU","launch_type":"container","container_image":"nginx:latest","scheduling":{"node_selector":{"sortie.io/pool":"gpu"},"tolerations":[{"key":"nvidia.com/gpu","operator":"Exists"}]}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, []byte(`{"app_id":"gpu-app"}`))
+	var session map[string]interface{}
+	testutil.ReadJSON(t, resp, &session)
+	sessionID, _ := session["id"].(string)
+	if sessionID == "" {
+		t.Fatalf("expected session, got %v", session)
+	}
+
+	workload := ts.Runner.Workload("session-" + sessionID)
+	if workload == nil || workload.Config.Scheduling == nil ||
+		workload.Config.Scheduling.NodeSelector["sortie.io/pool"] != "gpu" ||
+		len(workload.Config.Scheduling.Tolerations) != 1 {
+		t.Fatalf("expected workload to get the app's scheduling, got %+v", workload)
+	}
+}
+
+func TestScheduling_OnlyAdminsSet(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "author", "pass123", []string{"app-author"})
+	authorToken := testutil.LoginAs(t, ts.URL, "author", "pass123")
+
+	body := []byte(`{"id":"author-app","name":"Author App","launch_type":"container","container_image":"nginx:latest","scheduling":{"node_selector":{"pool":"cad"}}}`)
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", authorToken, body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for app author setting scheduling, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken, body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+
+	// The author's edits keep the admin's settings
+	resp = testutil.AuthPut(t, ts.URL+"/api/apps/author-app", authorToken,
+		[]byte(`{"name":"Renamed","launch_type":"container","container_image":"nginx:latest"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var app struct {
+		Scheduling struct {
+			NodeSelector map[string]string `json:"node_selector"`
+		} `json:"scheduling"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/apps/author-app", ts.AdminToken), &app)
+	if app.Scheduling.NodeSelector["pool"] != "cad" {
+		t.Errorf("expected scheduling to be kept, got %+v", app.Scheduling)
+	}
+
+	resp = testutil.AuthPut(t, ts.URL+"/api/apps/author-app", authorToken,
+		[]byte(`{"name":"Renamed","launch_type":"container","container_image":"nginx:latest","scheduling":{"node_selector":{"pool":"gpu"}}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for app author changing scheduling, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,60 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestSessionArchive_ListArchivedSessions(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	createContainerApp(t, ts, "archived-app")
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, []byte(`{"app_id":"archived-app"}`))
+	var session map[string]interface{}
+	testutil.ReadJSON(t, resp, &session)
+	sessionID, _ := session["id"].(string)
+	waitForRunning(t, ts, sessionID)
+
+	resp = testutil.AuthDelete(t, ts.URL+"/api/sessions/"+sessionID, ts.AdminToken)
+	resp.Body.Close()
+
+	// Archive everything that has ended, as retention would once it is old enough
+	if n, err := ts.DB.ArchiveSessions(time.Now().Add(time.Minute), 100); err != nil || n != 1 {
+		t.Fatalf("ArchiveSessions() = %d, %v; want 1", n, err)
+	}
+
+	resp = testutil.AuthGet(t, ts.URL+"/api/sessions/"+sessionID, ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for archived session, got %d", resp.StatusCode)
+	}
+
+	var archived []db.ArchivedSession
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/session-archive", ts.AdminToken), &archived)
+	if len(archived) != 1 || archived[0].ID != sessionID || archived[0].AppID != "archived-app" {
+		t.Fatalf("unexpected archive: %+v", archived)
+	}
+
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/session-archive?user_id=nobody", ts.AdminToken), &archived)
+	if len(archived) != 0 {
+		t.Errorf("expected no archived sessions for another user, got %d", len(archived))
+	}
+
+	resp = testutil.AuthGet(t, ts.URL+"/api/admin/session-archive?limit=abc", ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid limit, got %d", resp.StatusCode)
+	}
+
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "viewer", "pass123", []string{"user"})
+	viewerToken := testutil.LoginAs(t, ts.URL, "viewer", "pass123")
+	resp = testutil.AuthGet(t, ts.URL+"/api/admin/session-archive", viewerToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for non-admin, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,192 @@
+package integration
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/sessions"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestSessionFileHistory(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	ownerID := testutil.CreateUser(t, ts.URL, ts.AdminToken, "filer", "pass123", []string{"user"})
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "snooper", "pass123", []string{"user"})
+	ownerToken := testutil.LoginAs(t, ts.URL, "filer", "pass123")
+	snooperToken := testutil.LoginAs(t, ts.URL, "snooper", "pass123")
+	sessionID := createRunningSession(t, ts, "file-history-app", ownerToken, ownerID)
+
+	for _, e := range []db.SessionFileEvent{
+		{SessionID: sessionID, Username: "filer", Direction: db.FileDirectionUpload, Path: "notes.txt", SizeBytes: 5, SHA256: "aa"},
+		{SessionID: sessionID, Username: "filer", Direction: db.FileDirectionDownload, Path: "result.csv", SizeBytes: 9, SHA256: "bb"},
+		{SessionID: sessionID, Username: "admin", Direction: db.FileDirectionDelete, Path: "notes.txt"},
+	} {
+		if err := ts.DB.LogSessionFileEvent(e); err != nil {
+			t.Fatalf("LogSessionFileEvent: %v", err)
+		}
+	}
+
+	resp := testutil.AuthGet(t, ts.URL+"/api/sessions/"+sessionID+"/files/history", ownerToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var events []db.SessionFileEvent
+	testutil.ReadJSON(t, resp, &events)
+	if len(events) != 3 || events[0].Direction != db.FileDirectionUpload || events[0].SHA256 != "aa" {
+		t.Fatalf("history = %+v, want 3 events starting with the upload", events)
+	}
+
+	resp = testutil.AuthGet(t, ts.URL+"/api/sessions/"+sessionID+"/files/history?path=notes.txt", ts.AdminToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("admin: expected 200, got %d", resp.StatusCode)
+	}
+	testutil.ReadJSON(t, resp, &events)
+	if len(events) != 2 || events[1].Direction != db.FileDirectionDelete || events[1].Username != "admin" {
+		t.Errorf("notes.txt history = %+v, want upload then delete by admin", events)
+	}
+
+	resp = testutil.AuthGet(t, ts.URL+"/api/sessions/"+sessionID+"/files/history", snooperToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("stranger: expected 403, got %d", resp.StatusCode)
+	}
+
+	// History outlives the session.
+	if err := ts.DB.UpdateSessionStatus(sessionID, db.SessionStatusStopped); err != nil {
+		t.Fatalf("UpdateSessionStatus: %v", err)
+	}
+	resp = testutil.AuthGet(t, ts.URL+"/api/sessions/"+sessionID+"/files/history", ownerToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("stopped session: expected 200, got %d", resp.StatusCode)
+	}
+	resp = testutil.AuthGet(t, ts.URL+"/api/sessions/"+sessionID+"/files", ownerToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("listing files of stopped session: expected 409, got %d", resp.StatusCode)
+	}
+}
+
+func TestSessionFileUploadPolicy(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	ownerID := testutil.CreateUser(t, ts.URL, ts.AdminToken, "uploader", "pass123", []string{"user"})
+	ownerToken := testutil.LoginAs(t, ts.URL, "uploader", "pass123")
+	sessionID := createRunningSession(t, ts, "upload-policy-app", ownerToken, ownerID)
+
+	resp := testutil.AuthPut(t, ts.URL+"/api/admin/tenants/default", ts.AdminToken,
+		[]byte(`{"name":"Default","settings":{"upload_policy":{"blocked_types":["executable"]}}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid policy: expected 400, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPut(t, ts.URL+"/api/admin/tenants/default", ts.AdminToken,
+		[]byte(`{"name":"Default","settings":{"upload_policy":{"blocked_extensions":[".exe"],"blocked_types":["application/x-executable"]}}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 updating tenant, got %d", resp.StatusCode)
+	}
+
+	uploadURL := ts.URL + "/api/sessions/" + sessionID + "/files/upload"
+	for _, tc := range []struct{ name, content string }{
+		{"setup.exe", "MZ\x90\x00"},
+		{"data.csv", "\x7fELF\x02\x01\x01"},
+	} {
+		resp = testutil.AuthPostMultipart(t, uploadURL, ownerToken, nil, tc.name, []byte(tc.content))
+		body := testutil.ReadBody(t, resp)
+		if resp.StatusCode != http.StatusUnsupportedMediaType {
+			t.Errorf("%s: expected 415, got %d: %s", tc.name, resp.StatusCode, body)
+		}
+	}
+
+	logs, err := ts.DB.GetAuditLogs(50)
+	if err != nil {
+		t.Fatalf("GetAuditLogs: %v", err)
+	}
+	blocked := 0
+	for _, l := range logs {
+		if l.Action == "FILE_UPLOAD_BLOCKED" && l.User == "uploader" {
+			blocked++
+		}
+	}
+	if blocked != 2 {
+		t.Errorf("found %d FILE_UPLOAD_BLOCKED audit entries, want 2", blocked)
+	}
+}
+
+func TestSessionFileTransferPolicy(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	ownerID := testutil.CreateUser(t, ts.URL, ts.AdminToken, "transferer", "pass123", []string{"user"})
+	ownerToken := testutil.LoginAs(t, ts.URL, "transferer", "pass123")
+	sessionID := createRunningSession(t, ts, "transfer-policy-app", ownerToken, ownerID)
+
+	resp := testutil.AuthPut(t, ts.URL+"/api/apps/transfer-policy-app", ts.AdminToken,
+		[]byte(`{"name":"Transfer","launch_type":"container","container_image":"nginx:latest","file_transfer":"sideways"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("unknown policy: expected 400, got %d", resp.StatusCode)
+	}
+
+	setPolicy := func(policy db.FileTransferPolicy) {
+		t.Helper()
+		app, err := ts.DB.GetApp("transfer-policy-app")
+		if err != nil || app == nil {
+			t.Fatalf("GetApp: %v", err)
+		}
+		app.FileTransfer = policy
+		if err := ts.DB.UpdateApp(*app); err != nil {
+			t.Fatalf("UpdateApp: %v", err)
+		}
+	}
+	uploadURL := ts.URL + "/api/sessions/" + sessionID + "/files/upload"
+	downloadURL := ts.URL + "/api/sessions/" + sessionID + "/files/download?path=report.pdf"
+
+	setPolicy(db.FileTransferDownloadOnly)
+	resp = testutil.AuthPostMultipart(t, uploadURL, ownerToken, nil, "notes.txt", []byte("hello"))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("download_only upload: expected 403, got %d", resp.StatusCode)
+	}
+
+	setPolicy(db.FileTransferUploadOnly)
+	resp = testutil.AuthGet(t, downloadURL, ownerToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("upload_only download: expected 403, got %d", resp.StatusCode)
+	}
+
+	// Sessions carry the policy so clients can hide what it blocks
+	var session sessions.SessionResponse
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/sessions/"+sessionID, ownerToken), &session)
+	if session.FileTransfer != db.FileTransferUploadOnly {
+		t.Errorf("session file_transfer = %q, want upload_only", session.FileTransfer)
+	}
+
+	logs, err := ts.DB.GetAuditLogs(50)
+	if err != nil {
+		t.Fatalf("GetAuditLogs: %v", err)
+	}
+	var blocked []string
+	for _, l := range logs {
+		if l.Action == "FILE_TRANSFER_BLOCKED" && l.User == "transferer" {
+			blocked = append(blocked, l.Details)
+		}
+	}
+	if len(blocked) != 2 {
+		t.Fatalf("found %d FILE_TRANSFER_BLOCKED audit entries, want 2", len(blocked))
+	}
+	for _, want := range []string{"direction=upload policy=download_only", `direction=download policy=upload_only path="report.pdf"`} {
+		found := false
+		for _, d := range blocked {
+			found = found || strings.Contains(d, want)
+		}
+		if !found {
+			t.Errorf("no FILE_TRANSFER_BLOCKED entry with %q in %q", want, blocked)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package integration
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/capacity"
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestSessionHistory_RecordedAndQueryable(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	createContainerApp(t, ts, "history-app")
+
+	var ids []string
+	for range 2 {
+		resp := testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, []byte(`{"app_id":"history-app"}`))
+		var session map[string]interface{}
+		testutil.ReadJSON(t, resp, &session)
+		id, _ := session["id"].(string)
+		waitForRunning(t, ts, id)
+		ids = append(ids, id)
+	}
+
+	// Stop one session and delete the other
+	resp := testutil.AuthPost(t, ts.URL+"/api/sessions/"+ids[0]+"/stop", ts.AdminToken, nil)
+	resp.Body.Close()
+	resp = testutil.AuthDelete(t, ts.URL+"/api/sessions/"+ids[1], ts.AdminToken)
+	resp.Body.Close()
+
+	var page db.SessionHistoryPage
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/history?app_id=history-app", ts.AdminToken), &page)
+	if page.Total != 2 || len(page.Sessions) != 2 {
+		t.Fatalf("expected 2 history entries, got %d", page.Total)
+	}
+	reasons := map[string]string{}
+	for _, h := range page.Sessions {
+		if h.FinalStatus != db.SessionStatusStopped || h.Username != testutil.TestAdminUsername {
+			t.Errorf("unexpected history entry: %+v", h)
+		}
+		reasons[h.SessionID] = h.ExitReason
+	}
+	if reasons[ids[0]] != "user stopped" || reasons[ids[1]] != "user requested" {
+		t.Errorf("unexpected exit reasons: %v", reasons)
+	}
+
+	// History survives the session being archived
+	if _, err := ts.DB.ArchiveSessions(time.Now().Add(time.Minute), 100); err != nil {
+		t.Fatal(err)
+	}
+	var summary struct {
+		GroupBy string                   `json:"group_by"`
+		Groups  []db.SessionHistoryGroup `json:"groups"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/history/summary?group_by=user", ts.AdminToken), &summary)
+	if summary.GroupBy != "user" || len(summary.Groups) != 1 || summary.Groups[0].Sessions != 2 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+
+	for _, url := range []string{
+		"/api/admin/history?from=yesterday",
+		"/api/admin/history/summary?group_by=pod",
+	} {
+		resp = testutil.AuthGet(t, ts.URL+url, ts.AdminToken)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("GET %s: expected 400, got %d", url, resp.StatusCode)
+		}
+	}
+
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "viewer", "pass123", []string{"user"})
+	viewerToken := testutil.LoginAs(t, ts.URL, "viewer", "pass123")
+	resp = testutil.AuthGet(t, ts.URL+"/api/admin/history", viewerToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for non-admin, got %d", resp.StatusCode)
+	}
+}
+
+func TestSessionHistory_Concurrency(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	// Two overlapping runs on Monday of last week, 09:00 to 10:00 UTC
+	lastWeek := capacity.WeekStart(time.Now().UTC()).AddDate(0, 0, -7)
+	for i, app := range []string{"concurrency-app", "concurrency-app", "other-app"} {
+		start := lastWeek.Add(9 * time.Hour)
+		if err := ts.DB.CreateSessionHistory(db.SessionHistory{
+			ID:          fmt.Sprintf("concurrency-%d", i),
+			SessionID:   fmt.Sprintf("session-%d", i),
+			UserID:      "alice",
+			AppID:       app,
+			StartedAt:   start,
+			EndedAt:     start.Add(time.Hour),
+			FinalStatus: db.SessionStatusStopped,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var report capacity.Report
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/history/concurrency?app_id=concurrency-app&weeks=2&forecast=1", ts.AdminToken), &report)
+	if len(report.Heatmap) != 7*24 || len(report.WeeklyPeaks) != 2 || len(report.Forecast) != 1 {
+		t.Fatalf("unexpected report shape: %d cells, %d weeks, %d forecast",
+			len(report.Heatmap), len(report.WeeklyPeaks), len(report.Forecast))
+	}
+	if c := report.Heatmap[9]; c.Day != 0 || c.Hour != 9 || c.Peak != 2 || c.Average != 1 {
+		t.Errorf("Monday 09:00 = %+v, want peak 2, average 1", c)
+	}
+	if report.WeeklyPeaks[0].Peak != 0 || report.WeeklyPeaks[1].Peak != 2 || !report.WeeklyPeaks[1].WeekStart.Equal(lastWeek) {
+		t.Errorf("weekly peaks = %+v", report.WeeklyPeaks)
+	}
+	if report.TrendPerWeek != 2 || report.Forecast[0].Peak != 4 {
+		t.Errorf("trend %v, forecast %+v; want 2 and 4", report.TrendPerWeek, report.Forecast)
+	}
+
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/history/concurrency?weeks=1&tz=Asia/Tokyo", ts.AdminToken), &report)
+	if report.Timezone != "Asia/Tokyo" || len(report.Forecast) != 4 {
+		t.Errorf("report = %s with %d forecast weeks", report.Timezone, len(report.Forecast))
+	}
+
+	for _, url := range []string{
+		"/api/admin/history/concurrency?weeks=0",
+		"/api/admin/history/concurrency?weeks=53",
+		"/api/admin/history/concurrency?forecast=many",
+		"/api/admin/history/concurrency?tz=Mars/Olympus",
+	} {
+		resp := testutil.AuthGet(t, ts.URL+url, ts.AdminToken)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("GET %s: expected 400, got %d", url, resp.StatusCode)
+		}
+	}
+}
+
+func TestSessionHistory_CostReport(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	ts.Config.CostCPUCoreHour = 0.04
+	ts.Config.CostMemoryGiBHour = 0.005
+	ts.Config.CostCurrency = "EUR"
+
+	ended := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	for i, h := range []db.SessionHistory{
+		// 2 cores and 4 GiB for 3 hours
+		{UserID: "alice", Username: "alice", AppID: "cad", AppName: "CAD", DurationSeconds: 3 * 3600, CPUCoreSeconds: 6 * 3600, MemoryGiBSeconds: 12 * 3600},
+		// Half a core and 1 GiB for 2 hours
+		{UserID: "bob", Username: "bob", AppID: "editor", AppName: "Editor", DurationSeconds: 2 * 3600, CPUCoreSeconds: 3600, MemoryGiBSeconds: 2 * 3600},
+		{UserID: "alice", Username: "alice", AppID: "editor", AppName: "Editor", TenantID: "acme", DurationSeconds: 3600, CPUCoreSeconds: 1800, MemoryGiBSeconds: 3600},
+	} {
+		h.ID = fmt.Sprintf("cost-%d", i)
+		h.SessionID = fmt.Sprintf("session-%d", i)
+		h.EndedAt = ended
+		h.StartedAt = ended.Add(-time.Duration(h.DurationSeconds) * time.Second)
+		h.FinalStatus = db.SessionStatusStopped
+		if err := ts.DB.CreateSessionHistory(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type row struct {
+		Key            string  `json:"key"`
+		Name           string  `json:"name"`
+		Sessions       int     `json:"sessions"`
+		CPUCoreHours   float64 `json:"cpu_core_hours"`
+		MemoryGiBHours float64 `json:"memory_gib_hours"`
+		Cost           float64 `json:"cost"`
+	}
+	var report struct {
+		GroupBy  string             `json:"group_by"`
+		Currency string             `json:"currency"`
+		Rates    map[string]float64 `json:"rates"`
+		Groups   []row              `json:"groups"`
+		Total    row                `json:"total"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/reports/costs", ts.AdminToken), &report)
+	if report.GroupBy != "user" || report.Currency != "EUR" || report.Rates["cpu_core_hour"] != 0.04 {
+		t.Errorf("report = %+v", report)
+	}
+	// alice: 6.5 core-hours and 13 GiB-hours = 0.26 + 0.065
+	if len(report.Groups) != 2 || report.Groups[0] != (row{Key: "alice", Name: "alice", Sessions: 2, CPUCoreHours: 6.5, MemoryGiBHours: 13, Cost: 0.325}) {
+		t.Errorf("groups = %+v", report.Groups)
+	}
+	if report.Total.Sessions != 3 || report.Total.CPUCoreHours != 7.5 || report.Total.Cost != 0.375 {
+		t.Errorf("total = %+v", report.Total)
+	}
+
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/reports/costs?group_by=tenant&user_id=alice", ts.AdminToken), &report)
+	if len(report.Groups) != 2 || report.Groups[0].Key != db.DefaultTenantID || report.Groups[1].Key != "acme" || report.Total.Sessions != 2 {
+		t.Errorf("tenant groups = %+v", report.Groups)
+	}
+
+	// Runs that ended before the range are left out
+	from := ended.Add(time.Minute).Format(time.RFC3339)
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/reports/costs?from="+from, ts.AdminToken), &report)
+	if len(report.Groups) != 0 || report.Total.Sessions != 0 {
+		t.Errorf("groups after the runs = %+v", report.Groups)
+	}
+
+	resp := testutil.AuthGet(t, ts.URL+"/api/admin/reports/costs?group_by=app&format=csv", ts.AdminToken)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/csv" {
+		t.Fatalf("CSV export: status %d, type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	wantCSV := "app,name,sessions,duration_hours,cpu_core_hours,memory_gib_hours,cpu_cost,memory_cost,cost,currency\n" +
+		"cad,CAD,1,3,6,12,0.24,0.06,0.3,EUR\n" +
+		"editor,Editor,2,3,1.5,3,0.06,0.015,0.075,EUR\n" +
+		"total,,3,6,7.5,15,0.3,0.075,0.375,EUR\n"
+	if string(body) != wantCSV {
+		t.Errorf("CSV export =\n%s\nwant\n%s", body, wantCSV)
+	}
+
+	for _, url := range []string{
+		"/api/admin/reports/costs?group_by=status",
+		"/api/admin/reports/costs?from=yesterday",
+		"/api/admin/reports/costs?format=xml",
+		"/api/admin/reports/costs?from=2025-02-01T00:00:00Z&to=2025-01-01T00:00:00Z",
+	} {
+		resp := testutil.AuthGet(t, ts.URL+url, ts.AdminToken)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("GET %s: expected 400, got %d", url, resp.StatusCode)
+		}
+	}
+
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "viewer", "pass123", []string{"user"})
+	viewerToken := testutil.LoginAs(t, ts.URL, "viewer", "pass123")
+	resp = testutil.AuthGet(t, ts.URL+"/api/admin/reports/costs", viewerToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for non-admin, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,186 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestSessionHostnames(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("sortie_access_token"); err == nil {
+			t.Error("app received Sortie's access token cookie")
+		}
+		fmt.Fprintf(w, "notebook %s", r.URL.Path)
+	}))
+	defer backend.Close()
+	port := backend.Listener.Addr().(*net.TCPAddr).Port
+
+	ts := testutil.NewTestServer(t, testutil.WithSessionDomain("sortie.example.com"))
+	body := []byte(fmt.Sprintf(`{"id":"jupyter","name":"Jupyter","launch_type":"web_proxy","container_image":"jupyter/base-notebook:latest","container_port":%d}`, port))
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken, body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create app: status %d", resp.StatusCode)
+	}
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "alice", "alice-pass-123", []string{"user"})
+	aliceToken := testutil.LoginAs(t, ts.URL, "alice", "alice-pass-123")
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", aliceToken, []byte(`{"app_id":"jupyter"}`))
+	var session struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+		URL    string `json:"url"`
+	}
+	testutil.ReadJSON(t, resp, &session)
+	if ts.Runner.Route(session.ID) != "jupyter-alice.sortie.example.com" {
+		t.Fatalf("route = %q, want jupyter-alice.sortie.example.com", ts.Runner.Route(session.ID))
+	}
+	waitForRunning(t, ts, session.ID)
+
+	resp = testutil.AuthGet(t, ts.URL+"/api/sessions/"+session.ID, aliceToken)
+	testutil.ReadJSON(t, resp, &session)
+	if session.URL != "https://jupyter-alice.sortie.example.com/" {
+		t.Errorf("url = %q, want https://jupyter-alice.sortie.example.com/", session.URL)
+	}
+
+	// Point the session at the test backend in place of a pod
+	if err := ts.DB.UpdateSessionPodIP(session.ID, "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(host, token string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/lab", nil)
+		req.Host = host
+		if token != "" {
+			req.AddCookie(&http.Cookie{Name: "sortie_access_token", Value: token})
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+
+	if code, body := get("jupyter-alice.sortie.example.com", aliceToken); code != http.StatusOK || body != "notebook /lab" {
+		t.Errorf("owner request = %d %q, want 200 from the app", code, body)
+	}
+	if code, _ := get("jupyter-alice.sortie.example.com", ""); code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated request = %d, want 401", code)
+	}
+	if code, _ := get("jupyter-alice.sortie.example.com", ts.AdminToken); code != http.StatusForbidden {
+		t.Errorf("other user's request = %d, want 403", code)
+	}
+	if code, _ := get("jupyter-bob.sortie.example.com", aliceToken); code != http.StatusNotFound {
+		t.Errorf("unknown hostname = %d, want 404", code)
+	}
+
+	// Terminating the session removes its route
+	resp = testutil.AuthDelete(t, ts.URL+"/api/sessions/"+session.ID, aliceToken)
+	resp.Body.Close()
+	if route := ts.Runner.Route(session.ID); route != "" {
+		t.Errorf("route after terminate = %q, want none", route)
+	}
+	if code, _ := get("jupyter-alice.sortie.example.com", aliceToken); code != http.StatusServiceUnavailable {
+		t.Errorf("request after terminate = %d, want 503", code)
+	}
+}
+
+func TestSessionHostnames_LoginCookieDomain(t *testing.T) {
+	ts := testutil.NewTestServer(t, testutil.WithSessionDomain("sortie.example.com"))
+
+	login := func(host string) *http.Cookie {
+		t.Helper()
+		body, _ := json.Marshal(map[string]string{"username": testutil.TestAdminUsername, "password": testutil.TestAdminPassword})
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/auth/login", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Host = host
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		for _, c := range resp.Cookies() {
+			if c.Name == "sortie_access_token" {
+				return c
+			}
+		}
+		t.Fatalf("login on %s set no access token cookie", host)
+		return nil
+	}
+
+	if c := login("sortie.example.com"); c.Domain != "sortie.example.com" {
+		t.Errorf("cookie Domain on the session domain = %q, want sortie.example.com", c.Domain)
+	}
+	// Browsers reject a cookie for a domain the page is not on
+	if c := login("portal.other.example"); c.Domain != "" {
+		t.Errorf("cookie Domain on another host = %q, want none", c.Domain)
+	}
+}
+
+func TestSessionHostnames_ForwardAuth(t *testing.T) {
+	ts := testutil.NewTestServer(t, testutil.WithSessionDomain("sortie.example.com"))
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"jupyter","name":"Jupyter","launch_type":"web_proxy","container_image":"jupyter/base-notebook:latest","container_port":8888}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create app: status %d", resp.StatusCode)
+	}
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "alice", "alice-pass-123", []string{"user"})
+	aliceToken := testutil.LoginAs(t, ts.URL, "alice", "alice-pass-123")
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", aliceToken, []byte(`{"app_id":"jupyter"}`))
+	resp.Body.Close()
+
+	// check calls the endpoint the way an ingress controller does, with the
+	// user's cookie and the session Ingress's query parameters or headers
+	check := func(query string, headers map[string]string, token string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/auth/session-host"+query, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		if token != "" {
+			req.AddCookie(&http.Cookie{Name: "sortie_access_token", Value: token})
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	const query = "?host=jupyter-alice.sortie.example.com&method=GET"
+	if code := check(query, nil, aliceToken); code != http.StatusOK {
+		t.Errorf("owner = %d, want 200", code)
+	}
+	if code := check(query, nil, ""); code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated = %d, want 401", code)
+	}
+	if code := check(query, nil, ts.AdminToken); code != http.StatusForbidden {
+		t.Errorf("other user = %d, want 403", code)
+	}
+	if code := check("?host=sortie.example.com", nil, aliceToken); code != http.StatusForbidden {
+		t.Errorf("host outside the session domain = %d, want 403", code)
+	}
+
+	// Controllers that set forwarded headers themselves
+	fwd := map[string]string{"X-Forwarded-Host": "jupyter-alice.sortie.example.com:443", "X-Forwarded-Method": "POST"}
+	if code := check("", fwd, aliceToken); code != http.StatusOK {
+		t.Errorf("owner via forwarded headers = %d, want 200", code)
+	}
+	// The Ingress's own host wins over one the client forwards
+	if code := check("?host=jupyter-admin.sortie.example.com&method=GET", fwd, aliceToken); code == http.StatusOK {
+		t.Error("forwarded host overrode the host in the auth URL")
+	}
+}
@@ -0,0 +1,61 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestSessionLock_Unlock(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	ownerID := testutil.CreateUser(t, ts.URL, ts.AdminToken, "locked", "pass123", []string{"user"})
+	ownerToken := testutil.LoginAs(t, ts.URL, "locked", "pass123")
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "stranger", "pass123", []string{"user"})
+	otherToken := testutil.LoginAs(t, ts.URL, "stranger", "pass123")
+	sessionID := createRunningSession(t, ts, "lock-app", ownerToken, ownerID)
+
+	// Sign-in times have second precision
+	time.Sleep(1100 * time.Millisecond)
+	if err := ts.DB.LockSession(sessionID); err != nil {
+		t.Fatalf("LockSession() error = %v", err)
+	}
+	var session struct {
+		LockedAt *time.Time `json:"locked_at"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/sessions/"+sessionID, ownerToken), &session)
+	if session.LockedAt == nil {
+		t.Fatal("locked_at missing from the locked session")
+	}
+
+	for _, tt := range []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"other user", otherToken, http.StatusNotFound},
+		{"token from before the lock", ownerToken, http.StatusForbidden},
+	} {
+		resp := testutil.AuthPost(t, ts.URL+"/api/sessions/"+sessionID+"/unlock", tt.token, nil)
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, resp.StatusCode)
+		}
+	}
+
+	freshToken := testutil.LoginAs(t, ts.URL, "locked", "pass123")
+	resp := testutil.AuthPost(t, ts.URL+"/api/sessions/"+sessionID+"/unlock", freshToken, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("unlock after signing in: expected 204, got %d", resp.StatusCode)
+	}
+	stored, err := ts.DB.GetSession(sessionID)
+	if err != nil || stored == nil {
+		t.Fatalf("GetSession() = %v, %v", stored, err)
+	}
+	if stored.LockedAt != nil {
+		t.Errorf("locked_at = %v after unlocking", stored.LockedAt)
+	}
+}
@@ -0,0 +1,47 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestSession_ReportsLaunchSubstatus(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	createContainerApp(t, ts, "slow-app")
+	ts.Runner.ReadyDelay = time.Second
+	ts.Runner.Progress = db.SubstatusPullingImage
+	ts.Runner.ProgressDetail = `pulling image "nginx:latest"`
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, []byte(`{"app_id":"slow-app"}`))
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", resp.StatusCode, testutil.ReadBody(t, resp))
+	}
+	var created map[string]any
+	testutil.ReadJSON(t, resp, &created)
+	id := created["id"].(string)
+
+	var session map[string]any
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		session = nil
+		testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/sessions/"+id, ts.AdminToken), &session)
+		if session["substatus"] != nil || session["status"] != "creating" {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if session["substatus"] != "pulling_image" || session["substatus_detail"] != `pulling image "nginx:latest"` {
+		t.Fatalf("expected pulling_image substatus while creating, got %v", session)
+	}
+
+	waitForRunning(t, ts, id)
+	var running map[string]any
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/sessions/"+id, ts.AdminToken), &running)
+	if _, ok := running["substatus"]; ok {
+		t.Errorf("expected substatus to be cleared once running, got %v", running["substatus"])
+	}
+}
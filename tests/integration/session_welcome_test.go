@@ -0,0 +1,55 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestSessionWelcome_Dismiss(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	ownerID := testutil.CreateUser(t, ts.URL, ts.AdminToken, "welcomed", "pass123", []string{"user"})
+	ownerToken := testutil.LoginAs(t, ts.URL, "welcomed", "pass123")
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "stranger", "pass123", []string{"user"})
+	otherToken := testutil.LoginAs(t, ts.URL, "stranger", "pass123")
+	sessionID := createRunningSession(t, ts, "welcome-app", ownerToken, ownerID)
+
+	// The welcome message is part of the app definition
+	body := []byte(`{"id":"welcome-app","name":"Lab","launch_type":"container","container_image":"nginx:latest","welcome_message":"**Acceptable use** applies."}`)
+	resp := testutil.AuthPut(t, ts.URL+"/api/apps/welcome-app", ts.AdminToken, body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("update app: expected 200, got %d", resp.StatusCode)
+	}
+	resp = testutil.AuthGet(t, ts.URL+"/api/apps/welcome-app", ts.AdminToken)
+	var app struct {
+		WelcomeMessage string `json:"welcome_message"`
+	}
+	testutil.ReadJSON(t, resp, &app)
+	if app.WelcomeMessage != "**Acceptable use** applies." {
+		t.Errorf("welcome_message = %q", app.WelcomeMessage)
+	}
+
+	// Only the owner can dismiss it
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions/"+sessionID+"/welcome/dismiss", otherToken, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("dismiss by other user: expected 404, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions/"+sessionID+"/welcome/dismiss", ownerToken, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("dismiss: expected 204, got %d", resp.StatusCode)
+	}
+
+	session, err := ts.DB.GetSession(sessionID)
+	if err != nil || session == nil {
+		t.Fatalf("GetSession() = %v, %v", session, err)
+	}
+	if session.WelcomeDismissedAt == nil {
+		t.Error("welcome_dismissed_at not recorded")
+	}
+}
@@ -0,0 +1,244 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestSettingChangeApproval(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "admin2", "password123", []string{"admin", "user"})
+	admin2 := testutil.LoginAs(t, ts.URL, "admin2", "password123")
+
+	// Turning approval on applies immediately
+	resp := testutil.AuthPut(t, ts.URL+"/api/admin/settings", ts.AdminToken, []byte(`{"settings_approval":"true"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("enable approval: expected 204, got %d", resp.StatusCode)
+	}
+
+	// High-impact changes are staged; others still apply
+	resp = testutil.AuthPut(t, ts.URL+"/api/admin/settings", ts.AdminToken,
+		[]byte(`{"recording_auto_record":"true","allow_registration":"true"}`))
+	if resp.StatusCode != http.StatusAccepted {
+		resp.Body.Close()
+		t.Fatalf("update settings: expected 202, got %d", resp.StatusCode)
+	}
+	var staged db.SettingChangeRequest
+	testutil.ReadJSON(t, resp, &staged)
+	if staged.Status != db.SettingChangePending || staged.Changes["recording_auto_record"] != "true" || len(staged.Changes) != 1 {
+		t.Fatalf("staged request = %+v", staged)
+	}
+	if v, _ := ts.DB.GetSetting("recording_auto_record"); v != "" {
+		t.Errorf("staged setting applied before approval: %q", v)
+	}
+	if v, _ := ts.DB.GetSetting("allow_registration"); v != "true" {
+		t.Errorf("allow_registration = %q, want it applied", v)
+	}
+
+	// The requester cannot approve their own request
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/setting-changes/"+staged.ID+"/approve", ts.AdminToken, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("self-approval: expected 403, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/setting-changes/"+staged.ID+"/approve", admin2, []byte(`{"comment":"ok"}`))
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		t.Fatalf("approve: expected 200, got %d", resp.StatusCode)
+	}
+	var approved db.SettingChangeRequest
+	testutil.ReadJSON(t, resp, &approved)
+	if approved.Status != db.SettingChangeApproved || approved.ReviewedBy != "admin2" || approved.ReviewComment != "ok" {
+		t.Errorf("approved request = %+v", approved)
+	}
+	if v, _ := ts.DB.GetSetting("recording_auto_record"); v != "true" {
+		t.Errorf("recording_auto_record = %q, want true after approval", v)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/setting-changes/"+staged.ID+"/reject", admin2, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("review twice: expected 409, got %d", resp.StatusCode)
+	}
+
+	// A rejected request is never applied
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/setting-changes", ts.AdminToken,
+		[]byte(`{"changes":{"recording_auto_record":"false"},"reason":"Cut storage costs"}`))
+	if resp.StatusCode != http.StatusCreated {
+		resp.Body.Close()
+		t.Fatalf("create request: expected 201, got %d", resp.StatusCode)
+	}
+	var rejected db.SettingChangeRequest
+	testutil.ReadJSON(t, resp, &rejected)
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/setting-changes/"+rejected.ID+"/cancel", admin2, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("cancel by another admin: expected 403, got %d", resp.StatusCode)
+	}
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/setting-changes/"+rejected.ID+"/reject", admin2, []byte(`{"comment":"no"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("reject: expected 200, got %d", resp.StatusCode)
+	}
+	if v, _ := ts.DB.GetSetting("recording_auto_record"); v != "true" {
+		t.Errorf("rejected setting applied: %q", v)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/setting-changes", ts.AdminToken, []byte(`{"changes":{"allow_passkeys":"maybe"}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid change: expected 400, got %d", resp.StatusCode)
+	}
+
+	// A staged change that no longer validates is not applied
+	if err := ts.DB.CreateSettingChangeRequest(db.SettingChangeRequest{
+		ID:          "stale",
+		Changes:     map[string]string{"update_channel": "nightly"},
+		RequestedBy: "admin",
+	}); err != nil {
+		t.Fatalf("CreateSettingChangeRequest() error = %v", err)
+	}
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/setting-changes/stale/approve", admin2, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("approve invalid change: expected 409, got %d", resp.StatusCode)
+	}
+	if v, _ := ts.DB.GetSetting("update_channel"); v != "" {
+		t.Errorf("invalid setting applied: %q", v)
+	}
+
+	var list struct {
+		Requests []db.SettingChangeRequest `json:"requests"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/setting-changes?status=rejected", ts.AdminToken), &list)
+	if len(list.Requests) != 1 || list.Requests[0].ID != rejected.ID {
+		t.Errorf("rejected requests = %+v", list.Requests)
+	}
+
+	audit, err := ts.DB.QueryAuditLogs(db.AuditLogFilter{Action: "APPROVE_SETTINGS_CHANGE"})
+	if err != nil {
+		t.Fatalf("QueryAuditLogs() error = %v", err)
+	}
+	if len(audit.Logs) != 1 || audit.Logs[0].User != "admin2" {
+		t.Errorf("approval audit logs = %+v", audit.Logs)
+	}
+}
+
+func TestTenantQuotaApproval(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "admin2", "password123", []string{"admin", "user"})
+	admin2 := testutil.LoginAs(t, ts.URL, "admin2", "password123")
+
+	resp := testutil.AuthPut(t, ts.URL+"/api/admin/settings", ts.AdminToken, []byte(`{"settings_approval":"true"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("enable approval: expected 204, got %d", resp.StatusCode)
+	}
+
+	// A quota increase is staged; the rename applies now
+	resp = testutil.AuthPut(t, ts.URL+"/api/admin/tenants/"+db.DefaultTenantID, ts.AdminToken,
+		[]byte(`{"name":"Renamed","quotas":{"max_total_sessions":500}}`))
+	if resp.StatusCode != http.StatusAccepted {
+		resp.Body.Close()
+		t.Fatalf("update tenant: expected 202, got %d", resp.StatusCode)
+	}
+	var staged db.SettingChangeRequest
+	testutil.ReadJSON(t, resp, &staged)
+	if staged.Status != db.SettingChangePending || staged.Tenant == nil || staged.Tenant.Quotas.MaxTotalSessions != 500 {
+		t.Fatalf("staged request = %+v", staged)
+	}
+	tenant, err := ts.DB.GetTenant(db.DefaultTenantID)
+	if err != nil || tenant == nil {
+		t.Fatalf("GetTenant() = %v, %v", tenant, err)
+	}
+	if tenant.Quotas.MaxTotalSessions != 0 {
+		t.Errorf("max_total_sessions = %d before approval, want 0", tenant.Quotas.MaxTotalSessions)
+	}
+	if tenant.Name != "Renamed" {
+		t.Errorf("name = %q, want the rename applied", tenant.Name)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/setting-changes/"+staged.ID+"/approve", ts.AdminToken, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("self-approval: expected 403, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/setting-changes/"+staged.ID+"/approve", admin2, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("approve: expected 200, got %d", resp.StatusCode)
+	}
+	tenant, err = ts.DB.GetTenant(db.DefaultTenantID)
+	if err != nil || tenant == nil {
+		t.Fatalf("GetTenant() = %v, %v", tenant, err)
+	}
+	if tenant.Quotas.MaxTotalSessions != 500 {
+		t.Errorf("max_total_sessions = %d after approval, want 500", tenant.Quotas.MaxTotalSessions)
+	}
+
+	// Saving the tenant unchanged stages nothing
+	resp = testutil.AuthPut(t, ts.URL+"/api/admin/tenants/"+db.DefaultTenantID, ts.AdminToken,
+		[]byte(`{"quotas":{"max_total_sessions":500}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("unchanged quotas: expected 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestTenantCreateQuotaApproval(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "admin2", "password123", []string{"admin", "user"})
+	admin2 := testutil.LoginAs(t, ts.URL, "admin2", "password123")
+
+	resp := testutil.AuthPut(t, ts.URL+"/api/admin/settings", ts.AdminToken, []byte(`{"settings_approval":"true"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("enable approval: expected 204, got %d", resp.StatusCode)
+	}
+
+	// A new tenant's quotas and egress policy are staged, not created with it
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/tenants", ts.AdminToken,
+		[]byte(`{"name":"Acme","slug":"acme","quotas":{"max_total_sessions":500},"settings":{"defaults":{"egress_policy":{"mode":"allowlist"}}}}`))
+	if resp.StatusCode != http.StatusAccepted {
+		resp.Body.Close()
+		t.Fatalf("create tenant: expected 202, got %d", resp.StatusCode)
+	}
+	var staged db.SettingChangeRequest
+	testutil.ReadJSON(t, resp, &staged)
+	if staged.Status != db.SettingChangePending || staged.Tenant == nil || staged.Tenant.Quotas.MaxTotalSessions != 500 || staged.Tenant.EgressPolicy == nil {
+		t.Fatalf("staged request = %+v", staged)
+	}
+	tenant, err := ts.DB.GetTenant(staged.Tenant.TenantID)
+	if err != nil || tenant == nil {
+		t.Fatalf("GetTenant() = %v, %v", tenant, err)
+	}
+	if tenant.Quotas.MaxTotalSessions != 0 || tenant.Settings.DefaultEgressPolicy() != nil {
+		t.Errorf("tenant created with quotas %+v and egress policy %+v before approval", tenant.Quotas, tenant.Settings.DefaultEgressPolicy())
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/setting-changes/"+staged.ID+"/approve", admin2, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("approve: expected 200, got %d", resp.StatusCode)
+	}
+	tenant, err = ts.DB.GetTenant(staged.Tenant.TenantID)
+	if err != nil || tenant == nil {
+		t.Fatalf("GetTenant() = %v, %v", tenant, err)
+	}
+	if tenant.Quotas.MaxTotalSessions != 500 || tenant.Settings.DefaultEgressPolicy() == nil {
+		t.Errorf("after approval: quotas %+v, egress policy %+v", tenant.Quotas, tenant.Settings.DefaultEgressPolicy())
+	}
+
+	// A tenant without quotas or an egress policy is created as before
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/tenants", ts.AdminToken, []byte(`{"name":"Plain","slug":"plain"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("create plain tenant: expected 201, got %d", resp.StatusCode)
+	}
+}
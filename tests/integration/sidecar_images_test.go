@@ -0,0 +1,84 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestSidecarImages_OverrideRecordedOnSession(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"bad-app","name":"Bad","launch_type":"container","os_type":"windows","container_image":"win:latest","sidecar_images":{"guacd":"Not An Image"}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for malformed sidecar image, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"win-app","name":"Win","launch_type":"container","os_type":"windows","container_image":"win:latest","sidecar_images":{"guacd":"guacamole/guacd:1.5.5"}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, []byte(`{"app_id":"win-app"}`))
+	var session map[string]interface{}
+	testutil.ReadJSON(t, resp, &session)
+	sessionID, _ := session["id"].(string)
+	if sessionID == "" {
+		t.Fatalf("expected session, got %v", session)
+	}
+	if session["sidecar_image"] != "guacamole/guacd:1.5.5" {
+		t.Errorf("expected session to record guacd override, got %v", session["sidecar_image"])
+	}
+
+	workload := ts.Runner.Workload("session-" + sessionID)
+	if workload == nil || workload.Config.SidecarImages == nil || workload.Config.SidecarImages.Guacd != "guacamole/guacd:1.5.5" {
+		t.Fatalf("expected workload to get the guacd override, got %+v", workload)
+	}
+}
+
+func TestSidecarImages_OnlyAdminsOverride(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "author", "pass123", []string{"app-author"})
+	authorToken := testutil.LoginAs(t, ts.URL, "author", "pass123")
+
+	body := []byte(`{"id":"author-app","name":"Author App","launch_type":"container","container_image":"nginx:latest","sidecar_images":{"vnc":"example.com/vnc:2"}}`)
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", authorToken, body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for app author overriding sidecar images, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken, body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+
+	// The author's edits keep the admin's override
+	resp = testutil.AuthPut(t, ts.URL+"/api/apps/author-app", authorToken,
+		[]byte(`{"name":"Renamed","launch_type":"container","container_image":"nginx:latest"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var app struct {
+		SidecarImages map[string]string `json:"sidecar_images"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/apps/author-app", ts.AdminToken), &app)
+	if app.SidecarImages["vnc"] != "example.com/vnc:2" {
+		t.Errorf("expected sidecar images to be kept, got %v", app.SidecarImages)
+	}
+
+	resp = testutil.AuthPut(t, ts.URL+"/api/apps/author-app", authorToken,
+		[]byte(`{"name":"Renamed","launch_type":"container","container_image":"nginx:latest","sidecar_images":{"vnc":"example.com/vnc:3"}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for app author changing sidecar images, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,186 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func createSidecarTemplate(t *testing.T, ts *testutil.TestServer, body string) {
+	t.Helper()
+	resp := testutil.AuthPost(t, ts.URL+"/api/admin/sidecars", ts.AdminToken, []byte(body))
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("failed to create sidecar template: status %d: %s", resp.StatusCode, testutil.ReadBody(t, resp))
+	}
+	resp.Body.Close()
+}
+
+func TestSidecar_TemplateCRUD(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	base := ts.URL + "/api/admin/sidecars"
+
+	createSidecarTemplate(t, ts, `{"name":"vpn","description":"VPN client","spec":{"image":"example.com/vpn:1.0","ports":[1080],"resources":{"cpu_limit":"100m"}}}`)
+
+	resp := testutil.AuthPost(t, base, ts.AdminToken, []byte(`{"name":"vpn","spec":{"image":"example.com/vpn:1.0"}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected 409 for duplicate name, got %d", resp.StatusCode)
+	}
+
+	for _, body := range []string{
+		`{"name":"Bad Name","spec":{"image":"x:1"}}`,
+		`{"name":"noimage","spec":{}}`,
+		`{"name":"reserved","spec":{"image":"x:1","ports":[5900]}}`,
+		`{"name":"badcpu","spec":{"image":"x:1","resources":{"cpu_limit":"lots"}}}`,
+		`{"name":"scoped","tenant_id":"missing","spec":{"image":"x:1"}}`,
+	} {
+		resp = testutil.AuthPost(t, base, ts.AdminToken, []byte(body))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", body, resp.StatusCode)
+		}
+	}
+
+	var templates []map[string]interface{}
+	testutil.ReadJSON(t, testutil.AuthGet(t, base, ts.AdminToken), &templates)
+	if len(templates) != 1 || templates[0]["name"] != "vpn" {
+		t.Fatalf("expected one template, got %v", templates)
+	}
+
+	resp = testutil.AuthPut(t, base+"/vpn", ts.AdminToken, []byte(`{"description":"Updated","spec":{"image":"example.com/vpn:2.0"}}`))
+	var updated map[string]interface{}
+	testutil.ReadJSON(t, resp, &updated)
+	spec, _ := updated["spec"].(map[string]interface{})
+	if updated["description"] != "Updated" || spec["image"] != "example.com/vpn:2.0" {
+		t.Errorf("unexpected update response: %v", updated)
+	}
+
+	resp = testutil.AuthPut(t, base+"/missing", ts.AdminToken, []byte(`{"spec":{"image":"x:1"}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 updating missing template, got %d", resp.StatusCode)
+	}
+
+	// Non-admins cannot manage templates
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "author", "pass123", []string{"app-author"})
+	authorToken := testutil.LoginAs(t, ts.URL, "author", "pass123")
+	resp = testutil.AuthGet(t, base, authorToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for non-admin, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthDelete(t, base+"/vpn", ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", resp.StatusCode)
+	}
+	resp = testutil.AuthGet(t, base+"/vpn", ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 after delete, got %d", resp.StatusCode)
+	}
+}
+
+func TestSidecar_AttachedToAppAndTenant(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	createSidecarTemplate(t, ts, `{"name":"vpn","spec":{"image":"example.com/vpn:1.0","env":{"VPN_SERVER":"vpn.example.com"}}}`)
+	createSidecarTemplate(t, ts, `{"name":"logs","spec":{"image":"example.com/logs:1.0","mount_workspace":true}}`)
+
+	// Unknown templates are rejected
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"bad-app","name":"Bad","launch_type":"container","container_image":"nginx:latest","sidecars":["missing"]}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown sidecar, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"vpn-app","name":"VPN App","launch_type":"container","container_image":"nginx:latest","sidecars":["vpn"]}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+
+	// Attach logs to the default tenant
+	resp = testutil.AuthPut(t, ts.URL+"/api/admin/tenants/default", ts.AdminToken,
+		[]byte(`{"settings":{"sidecars":["logs"]}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 updating tenant, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, []byte(`{"app_id":"vpn-app"}`))
+	var session map[string]interface{}
+	testutil.ReadJSON(t, resp, &session)
+	sessionID, _ := session["id"].(string)
+	if sessionID == "" {
+		t.Fatalf("expected session, got %v", session)
+	}
+
+	workload := ts.Runner.Workload("session-" + sessionID)
+	if workload == nil {
+		t.Fatal("expected workload for session")
+	}
+	sidecars := workload.Config.Sidecars
+	if len(sidecars) != 2 || sidecars[0].Name != "logs" || sidecars[1].Name != "vpn" {
+		t.Fatalf("expected tenant then app sidecars, got %+v", sidecars)
+	}
+	if sidecars[1].Env["VPN_SERVER"] != "vpn.example.com" || !sidecars[0].MountWorkspace {
+		t.Errorf("unexpected sidecar specs: %+v", sidecars)
+	}
+
+	// Templates in use cannot be deleted
+	resp = testutil.AuthDelete(t, ts.URL+"/api/admin/sidecars/vpn", ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected 409 deleting template in use, got %d", resp.StatusCode)
+	}
+}
+
+func TestSidecar_OnlyAdminsAttach(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	createSidecarTemplate(t, ts, `{"name":"vpn","spec":{"image":"example.com/vpn:1.0"}}`)
+
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "author", "pass123", []string{"app-author"})
+	authorToken := testutil.LoginAs(t, ts.URL, "author", "pass123")
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", authorToken,
+		[]byte(`{"id":"author-app","name":"Author App","launch_type":"container","container_image":"nginx:latest","sidecars":["vpn"]}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for app author attaching sidecars, got %d", resp.StatusCode)
+	}
+
+	// An admin attaches the sidecar; the author's later edits keep it
+	resp = testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"author-app","name":"Author App","launch_type":"container","container_image":"nginx:latest","sidecars":["vpn"]}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPut(t, ts.URL+"/api/apps/author-app", authorToken,
+		[]byte(`{"name":"Renamed","launch_type":"container","container_image":"nginx:latest"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	var app map[string]interface{}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/apps/author-app", ts.AdminToken), &app)
+	raw, _ := json.Marshal(app["sidecars"])
+	if string(raw) != `["vpn"]` {
+		t.Errorf("expected sidecars to be kept, got %s", raw)
+	}
+
+	resp = testutil.AuthPut(t, ts.URL+"/api/apps/author-app", authorToken,
+		[]byte(`{"name":"Renamed","launch_type":"container","container_image":"nginx:latest","sidecars":[]}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for app author removing sidecars, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,78 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/sessions"
+	"github.com/rjsadow/sortie/internal/slo"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestAdminSLOs(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"slo-app","name":"SLO App","launch_type":"container","container_image":"nginx:latest","slo":{"launch_success_target":100}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("app with a 100%% target: status %d, want 400", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"slo-app","name":"SLO App","launch_type":"container","container_image":"nginx:latest","slo":{"launch_success_target":99,"start_latency_p95_seconds":60,"window_days":7}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("failed to create app: status %d", resp.StatusCode)
+	}
+	resp = testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"no-slo-app","name":"No SLO App","launch_type":"container","container_image":"nginx:latest"}`))
+	resp.Body.Close()
+
+	// One launch reaches running and one fails to create its workload
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, []byte(`{"app_id":"slo-app"}`))
+	var session sessions.SessionResponse
+	testutil.ReadJSON(t, resp, &session)
+	waitForRunning(t, ts, session.ID)
+
+	ts.Runner.CreateError = fmt.Errorf("simulated runner failure")
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, []byte(`{"app_id":"slo-app"}`))
+	resp.Body.Close()
+	ts.Runner.CreateError = nil
+	if resp.StatusCode == http.StatusCreated {
+		t.Fatal("launch succeeded despite the runner failure")
+	}
+
+	var result struct {
+		Reports []slo.Report `json:"reports"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/slos", ts.AdminToken), &result)
+	if len(result.Reports) != 1 {
+		t.Fatalf("got %d reports, want one for slo-app", len(result.Reports))
+	}
+	report := result.Reports[0]
+	if report.AppID != "slo-app" || report.WindowDays != 7 || report.Launches != 2 || report.Failed != 1 {
+		t.Errorf("report = %+v, want 2 launches with 1 failed over 7 days", report)
+	}
+	if ls := report.LaunchSuccess; ls == nil || ls.Actual != 50 || ls.Met {
+		t.Errorf("launch success = %+v, want 50%% and missed", ls)
+	}
+	if sl := report.StartLatency; sl == nil || !sl.Met {
+		t.Errorf("start latency = %+v, want met", sl)
+	}
+
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/slos?app_id=no-slo-app", ts.AdminToken), &result)
+	if len(result.Reports) != 0 {
+		t.Errorf("got %d reports for an app without objectives, want 0", len(result.Reports))
+	}
+
+	// Users without the admin role cannot read reports
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "slo-user", "password123", []string{"user"})
+	userToken := testutil.LoginAs(t, ts.URL, "slo-user", "password123")
+	resp = testutil.AuthGet(t, ts.URL+"/api/admin/slos", userToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("non-admin: status %d, want 403", resp.StatusCode)
+	}
+}
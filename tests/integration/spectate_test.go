@@ -0,0 +1,165 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+// setDefaultSpectatePolicy sets the default tenant's spectate policy.
+func setDefaultSpectatePolicy(t *testing.T, ts *testutil.TestServer, policy string) {
+	t.Helper()
+	resp := testutil.AuthGet(t, ts.URL+"/api/admin/tenants/default", ts.AdminToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("get default tenant: expected 200, got %d", resp.StatusCode)
+	}
+	var tenant map[string]any
+	testutil.ReadJSON(t, resp, &tenant)
+
+	settings, _ := tenant["settings"].(map[string]any)
+	if settings == nil {
+		settings = map[string]any{}
+	}
+	settings["spectate_policy"] = policy
+	body, _ := json.Marshal(map[string]any{
+		"name":     tenant["name"],
+		"slug":     tenant["slug"],
+		"settings": settings,
+	})
+	resp = testutil.AuthPut(t, ts.URL+"/api/admin/tenants/default", ts.AdminToken, body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("update default tenant: expected 200, got %d", resp.StatusCode)
+	}
+}
+
+type spectateGrant struct {
+	ID           string `json:"id"`
+	Policy       string `json:"policy"`
+	Status       string `json:"status"`
+	WebSocketURL string `json:"websocket_url"`
+}
+
+func requestSpectate(t *testing.T, ts *testutil.TestServer, sessionID string) spectateGrant {
+	t.Helper()
+	resp := testutil.AuthPost(t, ts.URL+"/api/admin/sessions/"+sessionID+"/spectate", ts.AdminToken, nil)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("request spectate: expected 201, got %d: %s", resp.StatusCode, testutil.ReadBody(t, resp))
+	}
+	var grant spectateGrant
+	testutil.ReadJSON(t, resp, &grant)
+	return grant
+}
+
+func TestSpectate_RequireConsent(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	setDefaultSpectatePolicy(t, ts, "require_consent")
+
+	ownerID := testutil.CreateUser(t, ts.URL, ts.AdminToken, "watched", "pass123", []string{"user"})
+	ownerToken := testutil.LoginAs(t, ts.URL, "watched", "pass123")
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "bystander", "pass123", []string{"user"})
+	otherToken := testutil.LoginAs(t, ts.URL, "bystander", "pass123")
+	sessionID := createRunningSession(t, ts, "spectate-consent-app", ownerToken, ownerID)
+
+	grant := requestSpectate(t, ts, sessionID)
+	if grant.Status != "pending" || grant.Policy != "require_consent" {
+		t.Fatalf("grant = %+v, want pending require_consent", grant)
+	}
+	if grant.WebSocketURL != "/ws/sessions/"+sessionID+"?spectate="+grant.ID {
+		t.Errorf("websocket_url = %q", grant.WebSocketURL)
+	}
+
+	// The owner sees the pending request
+	resp := testutil.AuthGet(t, ts.URL+"/api/sessions/"+sessionID+"/spectate", ownerToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("list spectate requests: expected 200, got %d", resp.StatusCode)
+	}
+	var grants []spectateGrant
+	testutil.ReadJSON(t, resp, &grants)
+	if len(grants) != 1 || grants[0].ID != grant.ID {
+		t.Fatalf("grants = %+v, want the pending request", grants)
+	}
+
+	// Only the owner may answer
+	approve := []byte(`{"approve":true}`)
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions/"+sessionID+"/spectate/"+grant.ID, otherToken, approve)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("non-owner answer: expected 403, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions/"+sessionID+"/spectate/"+grant.ID, ownerToken, approve)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("approve: expected 200, got %d: %s", resp.StatusCode, testutil.ReadBody(t, resp))
+	}
+	var answered spectateGrant
+	testutil.ReadJSON(t, resp, &answered)
+	if answered.Status != "approved" {
+		t.Errorf("status = %q, want approved", answered.Status)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions/"+sessionID+"/spectate/"+grant.ID, ownerToken, approve)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("second answer: expected 409, got %d", resp.StatusCode)
+	}
+
+	// Requests and answers are audited
+	resp = testutil.AuthGet(t, ts.URL+"/api/audit", ts.AdminToken)
+	body := testutil.ReadBody(t, resp)
+	for _, action := range []string{"SPECTATE_REQUEST", "SPECTATE_APPROVE"} {
+		if !strings.Contains(body, action) {
+			t.Errorf("audit log missing %s", action)
+		}
+	}
+}
+
+func TestSpectate_SilentHiddenFromOwner(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	setDefaultSpectatePolicy(t, ts, "silent")
+
+	ownerID := testutil.CreateUser(t, ts.URL, ts.AdminToken, "quiet", "pass123", []string{"user"})
+	ownerToken := testutil.LoginAs(t, ts.URL, "quiet", "pass123")
+	sessionID := createRunningSession(t, ts, "spectate-silent-app", ownerToken, ownerID)
+
+	grant := requestSpectate(t, ts, sessionID)
+	if grant.Status != "approved" {
+		t.Fatalf("grant = %+v, want approved", grant)
+	}
+
+	resp := testutil.AuthGet(t, ts.URL+"/api/sessions/"+sessionID+"/spectate", ownerToken)
+	var grants []spectateGrant
+	testutil.ReadJSON(t, resp, &grants)
+	if len(grants) != 0 {
+		t.Errorf("owner sees silent grants: %+v", grants)
+	}
+
+	resp = testutil.AuthGet(t, ts.URL+"/api/sessions/"+sessionID+"/spectate", ts.AdminToken)
+	testutil.ReadJSON(t, resp, &grants)
+	if len(grants) != 1 {
+		t.Errorf("admin sees %d grants, want 1", len(grants))
+	}
+
+	resp = testutil.AuthGet(t, ts.URL+"/api/sessions/"+sessionID+"/presence", ownerToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("presence: expected 200, got %d", resp.StatusCode)
+	}
+	var viewers []map[string]any
+	testutil.ReadJSON(t, resp, &viewers)
+	if len(viewers) != 0 {
+		t.Errorf("viewers = %+v, want none without a gateway connection", viewers)
+	}
+}
+
+func TestSpectate_InvalidTenantPolicy(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	body := []byte(`{"name":"Bad","slug":"bad","settings":{"spectate_policy":"sometimes"}}`)
+	resp := testutil.AuthPost(t, ts.URL+"/api/admin/tenants", ts.AdminToken, body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", resp.StatusCode)
+	}
+}
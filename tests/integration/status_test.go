@@ -0,0 +1,214 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+type statusPage struct {
+	Status     string `json:"status"`
+	Components []struct {
+		Name   string             `json:"name"`
+		Status string             `json:"status"`
+		Uptime map[string]float64 `json:"uptime"`
+	} `json:"components"`
+	Announcements []map[string]any `json:"announcements"`
+	Upcoming      []map[string]any `json:"upcoming"`
+}
+
+func getStatusPage(t *testing.T, ts *testutil.TestServer) statusPage {
+	t.Helper()
+	resp, err := http.Get(ts.URL + "/api/status")
+	if err != nil {
+		t.Fatalf("GET /api/status: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d, want 200", resp.StatusCode)
+	}
+	var page statusPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return page
+}
+
+func putSettings(t *testing.T, ts *testutil.TestServer, settings map[string]string) {
+	t.Helper()
+	resp := testutil.AuthPut(t, ts.URL+"/api/admin/settings", ts.AdminToken, []byte(jsonBody(t, settings)))
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("PUT settings: status %d, want 204", resp.StatusCode)
+	}
+}
+
+func TestStatusPage(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	// Off by default
+	resp, err := http.Get(ts.URL + "/api/status")
+	if err != nil {
+		t.Fatalf("GET /api/status: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("disabled status page: status %d, want 404", resp.StatusCode)
+	}
+
+	putSettings(t, ts, map[string]string{"status_page_enabled": "true"})
+	page := getStatusPage(t, ts)
+	if page.Status != "operational" || len(page.Components) != 0 {
+		t.Errorf("default status page = %+v, want operational with no components", page)
+	}
+
+	start := time.Now().Add(-2 * time.Hour)
+	for _, e := range []db.HealthEvent{
+		{Component: "database", FromState: "healthy", ToState: "unhealthy", Reason: "connection refused", CreatedAt: start},
+		{Component: "database", FromState: "unhealthy", ToState: "healthy", CreatedAt: start.Add(time.Hour)},
+		{Component: "sessions", FromState: "unknown", ToState: "unhealthy", Reason: "overloaded", CreatedAt: start.Add(time.Hour)},
+	} {
+		if err := ts.DB.CreateHealthEvent(&e); err != nil {
+			t.Fatalf("CreateHealthEvent() error = %v", err)
+		}
+	}
+
+	putSettings(t, ts, map[string]string{"status_page_components": "database, storage"})
+	page = getStatusPage(t, ts)
+	if page.Status != "operational" || len(page.Components) != 2 {
+		t.Fatalf("status page = %+v, want database and storage", page)
+	}
+	if page.Components[0].Name != "database" || page.Components[0].Status != "healthy" || page.Components[0].Uptime != nil {
+		t.Errorf("database = %+v, want healthy without uptime", page.Components[0])
+	}
+
+	putSettings(t, ts, map[string]string{"status_page_components": "*", "status_page_uptime": "true"})
+	page = getStatusPage(t, ts)
+	if page.Status != "degraded" || len(page.Components) != 2 || page.Components[1].Name != "sessions" {
+		t.Fatalf("status page = %+v, want degraded with database and sessions", page)
+	}
+	// The hour-long outage weighs less in longer windows
+	uptime := page.Components[0].Uptime
+	if !(uptime["24h"] > 0 && uptime["24h"] < uptime["7d"] && uptime["7d"] < uptime["30d"] && uptime["30d"] < uptime["90d"] && uptime["90d"] < 100) {
+		t.Errorf("database uptime = %+v, want rising with the window", uptime)
+	}
+
+	// Reasons stay private
+	resp, _ = http.Get(ts.URL + "/api/status")
+	body := testutil.ReadBody(t, resp)
+	if strings.Contains(body, "connection refused") || strings.Contains(body, "overloaded") {
+		t.Errorf("status page discloses failure reasons: %s", body)
+	}
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want *", got)
+	}
+	etag := resp.Header.Get("ETag")
+	if etag == "" || !strings.Contains(resp.Header.Get("Cache-Control"), "max-age") {
+		t.Fatalf("missing cache headers: %v", resp.Header)
+	}
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/status", nil)
+	req.Header.Set("If-None-Match", etag)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("conditional GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("conditional GET: status %d, want 304", resp.StatusCode)
+	}
+}
+
+func TestStatusAnnouncements(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	putSettings(t, ts, map[string]string{"status_page_enabled": "true"})
+
+	// Non-admins cannot publish announcements
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "alice", "alice-pass", []string{"user"})
+	token := testutil.LoginAs(t, ts.URL, "alice", "alice-pass")
+	resp := testutil.AuthPost(t, ts.URL+"/api/admin/announcements", token, []byte(`{"kind":"incident","title":"Outage"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("non-admin create: status %d, want 403", resp.StatusCode)
+	}
+
+	for _, invalid := range []map[string]any{
+		{"kind": "outage", "title": "Outage"},
+		{"kind": "incident"},
+		{"kind": "incident", "title": "Outage", "starts_at": time.Now(), "ends_at": time.Now().Add(-time.Hour)},
+	} {
+		resp := testutil.AuthPost(t, ts.URL+"/api/admin/announcements", ts.AdminToken, []byte(jsonBody(t, invalid)))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("create %v: status %d, want 400", invalid, resp.StatusCode)
+		}
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/announcements", ts.AdminToken, []byte(jsonBody(t, map[string]any{
+		"kind": "maintenance", "title": "Database upgrade",
+		"starts_at": time.Now().Add(24 * time.Hour), "ends_at": time.Now().Add(26 * time.Hour),
+	})))
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create maintenance: status %d, want 201", resp.StatusCode)
+	}
+	var maintenance db.StatusAnnouncement
+	testutil.ReadJSON(t, resp, &maintenance)
+
+	page := getStatusPage(t, ts)
+	if page.Status != "operational" || len(page.Announcements) != 0 || len(page.Upcoming) != 1 {
+		t.Fatalf("status page = %+v, want upcoming maintenance", page)
+	}
+	if _, ok := page.Upcoming[0]["created_by"]; ok {
+		t.Errorf("announcement discloses its author: %+v", page.Upcoming[0])
+	}
+
+	// Starting the maintenance now puts the page in maintenance
+	resp = testutil.AuthPut(t, ts.URL+"/api/admin/announcements/"+maintenance.ID, ts.AdminToken, []byte(jsonBody(t, map[string]any{
+		"kind": "maintenance", "title": "Database upgrade", "starts_at": time.Now().Add(-time.Minute),
+	})))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("update: status %d, want 200", resp.StatusCode)
+	}
+	if page := getStatusPage(t, ts); page.Status != "maintenance" || len(page.Announcements) != 1 {
+		t.Errorf("status page = %+v, want active maintenance", page)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/announcements", ts.AdminToken, []byte(`{"kind":"incident","title":"Sessions failing to start"}`))
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create incident: status %d, want 201", resp.StatusCode)
+	}
+	var incident db.StatusAnnouncement
+	testutil.ReadJSON(t, resp, &incident)
+	if page := getStatusPage(t, ts); page.Status != "degraded" || len(page.Announcements) != 2 {
+		t.Errorf("status page = %+v, want degraded with two announcements", page)
+	}
+
+	// Resolving the incident and deleting the maintenance clears the page
+	resp = testutil.AuthPut(t, ts.URL+"/api/admin/announcements/"+incident.ID, ts.AdminToken, []byte(jsonBody(t, map[string]any{
+		"kind": "incident", "title": "Sessions failing to start", "ends_at": time.Now(),
+	})))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("resolve: status %d, want 200", resp.StatusCode)
+	}
+	resp = testutil.AuthDelete(t, ts.URL+"/api/admin/announcements/"+maintenance.ID, ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete: status %d, want 204", resp.StatusCode)
+	}
+	if page := getStatusPage(t, ts); page.Status != "operational" || len(page.Announcements) != 0 || len(page.Upcoming) != 0 {
+		t.Errorf("status page = %+v, want operational with no announcements", page)
+	}
+
+	resp = testutil.AuthGet(t, ts.URL+"/api/admin/announcements", ts.AdminToken)
+	var all []db.StatusAnnouncement
+	testutil.ReadJSON(t, resp, &all)
+	if len(all) != 1 || all[0].ID != incident.ID || all[0].CreatedBy != "admin" {
+		t.Errorf("announcements = %+v, want the resolved incident", all)
+	}
+}
@@ -0,0 +1,78 @@
+package integration
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/streamstats"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestStreamStats(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	ownerID := testutil.CreateUser(t, ts.URL, ts.AdminToken, "streamer", "pass123", []string{"user"})
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "stranger", "pass123", []string{"user"})
+	ownerToken := testutil.LoginAs(t, ts.URL, "streamer", "pass123")
+	strangerToken := testutil.LoginAs(t, ts.URL, "stranger", "pass123")
+	sessionID := createRunningSession(t, ts, "stream-stats-app", ownerToken, ownerID)
+
+	// Before any client connects the stats are empty.
+	resp := testutil.AuthGet(t, ts.URL+"/api/sessions/"+sessionID+"/stream-stats", ownerToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var stats streamstats.Stats
+	testutil.ReadJSON(t, resp, &stats)
+	if stats.SessionID != sessionID || stats.ActiveStreams != 0 || stats.Frames != 0 {
+		t.Errorf("stats before streaming = %+v, want empty", stats)
+	}
+
+	stream := ts.StreamStats.Open(sessionID, streamstats.BackendVNC)
+	defer stream.Close()
+	stream.Sent(10, 4096)
+	stream.Dropped(1)
+
+	resp = testutil.AuthGet(t, ts.URL+"/api/sessions/"+sessionID+"/stream-stats", ts.AdminToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("admin: expected 200, got %d", resp.StatusCode)
+	}
+	testutil.ReadJSON(t, resp, &stats)
+	if stats.Backend != streamstats.BackendVNC || stats.ActiveStreams != 1 ||
+		stats.Frames != 10 || stats.Bytes != 4096 || stats.DroppedFrames != 1 {
+		t.Errorf("stats = %+v, want 1 vnc stream with 10 frames, 4096 bytes, 1 dropped", stats)
+	}
+
+	resp = testutil.AuthGet(t, ts.URL+"/api/sessions/"+sessionID+"/stream-stats", strangerToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("stranger: expected 403, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthGet(t, ts.URL+"/api/sessions/nonexistent/stream-stats", ownerToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown session: expected 404, got %d", resp.StatusCode)
+	}
+
+	resp, err := http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("metrics: expected 200, got %d", resp.StatusCode)
+	}
+	for _, want := range []string{
+		`sortie_stream_active{backend="vnc"} 1`,
+		`sortie_stream_frames_total{backend="vnc"} 10`,
+		`sortie_stream_dropped_frames_total{backend="vnc"} 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package integration
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/config"
+	"github.com/rjsadow/sortie/internal/templatesync"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+type catalogResponse struct {
+	Name       string `json:"name"`
+	OnConflict string `json:"on_conflict"`
+	SyncStatus string `json:"sync_status"`
+	SyncError  string `json:"sync_error"`
+	SyncResult *struct {
+		Created   []string `json:"created"`
+		Updated   []string `json:"updated"`
+		Conflicts []struct {
+			TemplateID string `json:"template_id"`
+		} `json:"conflicts"`
+	} `json:"sync_result"`
+}
+
+func TestTemplateCatalogs_Sync(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(pub)
+	pubPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	signed, err := templatesync.Sign([]byte(`{"version":"1.0.0","templates":[
+		{"template_id":"vendor-ide","name":"Vendor IDE","template_category":"development","category":"Development","container_image":"ide:1"},
+		{"template_id":"mine","name":"Theirs","template_category":"development","category":"Development"}]}`), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(signed)
+	}))
+	defer remote.Close()
+
+	ts := testutil.NewTestServer(t, func(c *config.Config) { c.OutboundAllowlist = "127.0.0.1" })
+	resp := testutil.AuthPost(t, ts.URL+"/api/admin/templates", ts.AdminToken,
+		[]byte(`{"template_id":"mine","name":"Mine","template_category":"development","category":"Development"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create local template: expected 201, got %d", resp.StatusCode)
+	}
+
+	body, _ := json.Marshal(map[string]string{"name": "vendor", "url": remote.URL, "public_key": pubPEM})
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/template-catalogs", ts.AdminToken, body)
+	if resp.StatusCode != http.StatusCreated {
+		resp.Body.Close()
+		t.Fatalf("create catalog: expected 201, got %d", resp.StatusCode)
+	}
+	var c catalogResponse
+	testutil.ReadJSON(t, resp, &c)
+	if c.Name != "vendor" || c.OnConflict != "keep_local" || c.SyncStatus != "" {
+		t.Errorf("created catalog = %+v", c)
+	}
+
+	testutil.ReadJSON(t, testutil.AuthPost(t, ts.URL+"/api/admin/template-catalogs/vendor/sync", ts.AdminToken, nil), &c)
+	if c.SyncStatus != "ok" || c.SyncResult == nil {
+		t.Fatalf("sync = %+v", c)
+	}
+	if len(c.SyncResult.Created) != 1 || c.SyncResult.Created[0] != "vendor-ide" ||
+		len(c.SyncResult.Conflicts) != 1 || c.SyncResult.Conflicts[0].TemplateID != "mine" {
+		t.Errorf("sync result = %+v", c.SyncResult)
+	}
+
+	type templateResponse struct {
+		Name    string `json:"name"`
+		Catalog string `json:"catalog"`
+	}
+	getTemplate := func(id string) templateResponse {
+		var tmpl templateResponse
+		testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/templates/"+id, ts.AdminToken), &tmpl)
+		return tmpl
+	}
+	if tmpl := getTemplate("vendor-ide"); tmpl.Name != "Vendor IDE" || tmpl.Catalog != "vendor" {
+		t.Errorf("synced template = %+v", tmpl)
+	}
+	if tmpl := getTemplate("mine"); tmpl.Name != "Mine" || tmpl.Catalog != "" {
+		t.Errorf("local template = %+v", tmpl)
+	}
+
+	// Overwriting takes over the clashing local template
+	body, _ = json.Marshal(map[string]string{"url": remote.URL, "public_key": pubPEM, "on_conflict": "overwrite"})
+	resp = testutil.AuthPut(t, ts.URL+"/api/admin/template-catalogs/vendor", ts.AdminToken, body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("update catalog: expected 200, got %d", resp.StatusCode)
+	}
+	testutil.ReadJSON(t, testutil.AuthPost(t, ts.URL+"/api/admin/template-catalogs/vendor/sync", ts.AdminToken, nil), &c)
+	if c.SyncStatus != "ok" || len(c.SyncResult.Updated) != 1 || c.SyncResult.Updated[0] != "mine" {
+		t.Errorf("overwrite sync = %+v", c.SyncResult)
+	}
+
+	resp = testutil.AuthDelete(t, ts.URL+"/api/admin/template-catalogs/vendor", ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("delete catalog: expected 204, got %d", resp.StatusCode)
+	}
+	if tmpl := getTemplate("vendor-ide"); tmpl.Name != "Vendor IDE" || tmpl.Catalog != "" {
+		t.Errorf("template after deleting its catalog = %+v", tmpl)
+	}
+}
+
+func TestTemplateCatalogs_Rejected(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(nil)
+	der, _ := x509.MarshalPKIXPublicKey(pub)
+	pubPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	remote := httptest.NewServer(http.NotFoundHandler())
+	defer remote.Close()
+
+	ts := testutil.NewTestServer(t)
+	for _, tt := range []struct {
+		name string
+		body map[string]string
+	}{
+		{"bad name", map[string]string{"name": "Vendor!", "url": remote.URL, "public_key": pubPEM}},
+		{"bad url", map[string]string{"name": "vendor", "url": "ftp://example.com", "public_key": pubPEM}},
+		{"bad key", map[string]string{"name": "vendor", "url": remote.URL, "public_key": "nope"}},
+		{"bad on_conflict", map[string]string{"name": "vendor", "url": remote.URL, "public_key": pubPEM, "on_conflict": "merge"}},
+	} {
+		body, _ := json.Marshal(tt.body)
+		resp := testutil.AuthPost(t, ts.URL+"/api/admin/template-catalogs", ts.AdminToken, body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", tt.name, resp.StatusCode)
+		}
+	}
+
+	// Without an allowlist entry, the loopback catalog server is blocked
+	body, _ := json.Marshal(map[string]string{"name": "vendor", "url": remote.URL, "public_key": pubPEM})
+	resp := testutil.AuthPost(t, ts.URL+"/api/admin/template-catalogs", ts.AdminToken, body)
+	resp.Body.Close()
+	var c catalogResponse
+	testutil.ReadJSON(t, testutil.AuthPost(t, ts.URL+"/api/admin/template-catalogs/vendor/sync", ts.AdminToken, nil), &c)
+	if c.SyncStatus != "error" || c.SyncError == "" {
+		t.Errorf("blocked sync = %+v", c)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/admin/template-catalogs/missing/sync", ts.AdminToken, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing catalog: expected 404, got %d", resp.StatusCode)
+	}
+
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "plain", "pass123", []string{"user"})
+	userToken := testutil.LoginAs(t, ts.URL, "plain", "pass123")
+	resp = testutil.AuthGet(t, ts.URL+"/api/admin/template-catalogs", userToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("plain user: expected 403, got %d", resp.StatusCode)
+	}
+}
+
+func TestTemplateCatalogs_ForgedSignature(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(nil)
+	der, _ := x509.MarshalPKIXPublicKey(pub)
+	pubPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	_, other, _ := ed25519.GenerateKey(nil)
+	forged, _ := templatesync.Sign([]byte(`{"version":"1.0.0","templates":[
+		{"template_id":"evil","name":"Evil","template_category":"development","category":"Development"}]}`), other)
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(forged)
+	}))
+	defer remote.Close()
+
+	ts := testutil.NewTestServer(t, func(c *config.Config) { c.OutboundAllowlist = "127.0.0.1" })
+	body, _ := json.Marshal(map[string]string{"name": "vendor", "url": remote.URL, "public_key": pubPEM})
+	resp := testutil.AuthPost(t, ts.URL+"/api/admin/template-catalogs", ts.AdminToken, body)
+	resp.Body.Close()
+
+	var c catalogResponse
+	testutil.ReadJSON(t, testutil.AuthPost(t, ts.URL+"/api/admin/template-catalogs/vendor/sync", ts.AdminToken, nil), &c)
+	if c.SyncStatus != "error" || c.SyncError == "" || c.SyncResult != nil {
+		t.Errorf("forged catalog sync = %+v", c)
+	}
+	resp = testutil.AuthGet(t, ts.URL+"/api/templates/evil", ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("forged template: expected 404, got %d", resp.StatusCode)
+	}
+}
@@ -117,3 +117,41 @@ func TestTenant_DeleteNonDefaultTenant(t *testing.T) {
 		t.Errorf("expected 204, got %d", resp.StatusCode)
 	}
 }
+
+func TestTenant_StorageLocation(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/admin/tenants", ts.AdminToken,
+		[]byte(`{"name":"EU","slug":"eu","settings":{"storage":{"bucket":"eu-recordings","region":"eu-central-1","kms_key_id":"alias/eu"}}}`))
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		b, _ := io.ReadAll(resp.Body)
+		t.Fatalf("expected 201, got %d: %s", resp.StatusCode, string(b))
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	json.NewDecoder(resp.Body).Decode(&created)
+
+	tenant, err := ts.DB.GetTenant(created.ID)
+	if err != nil || tenant == nil {
+		t.Fatalf("GetTenant() = %v, %v", tenant, err)
+	}
+	if loc := tenant.Settings.Storage; loc == nil || loc.Bucket != "eu-recordings" || loc.Region != "eu-central-1" || loc.KMSKeyID != "alias/eu" {
+		t.Errorf("storage = %+v", tenant.Settings.Storage)
+	}
+
+	for _, storage := range []string{
+		`{"bucket":""}`,
+		`{"bucket":"Not_A_Bucket"}`,
+		`{"bucket":"ok-bucket","region":"eu central"}`,
+		`{"bucket":"ok-bucket","prefix":"/abs"}`,
+	} {
+		resp := testutil.AuthPut(t, ts.URL+"/api/admin/tenants/"+created.ID, ts.AdminToken,
+			[]byte(`{"name":"EU","settings":{"storage":`+storage+`}}`))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("storage %s: expected 400, got %d", storage, resp.StatusCode)
+		}
+	}
+}
@@ -138,6 +138,17 @@ func AuthDelete(t *testing.T, url, token string) *http.Response {
 // AuthPostMultipart sends a multipart POST request with form fields and a file attachment.
 func AuthPostMultipart(t *testing.T, url, token string, fields map[string]string, fileName string, fileContent []byte) *http.Response {
 	t.Helper()
+	return authMultipart(t, http.MethodPost, url, token, fields, fileName, fileContent)
+}
+
+// AuthPutMultipart sends a multipart PUT request with form fields and a file attachment.
+func AuthPutMultipart(t *testing.T, url, token string, fields map[string]string, fileName string, fileContent []byte) *http.Response {
+	t.Helper()
+	return authMultipart(t, http.MethodPut, url, token, fields, fileName, fileContent)
+}
+
+func authMultipart(t *testing.T, method, url, token string, fields map[string]string, fileName string, fileContent []byte) *http.Response {
+	t.Helper()
 
 	var buf bytes.Buffer
 	writer := multipart.NewWriter(&buf)
@@ -160,7 +171,7 @@ func AuthPostMultipart(t *testing.T, url, token string, fields map[string]string
 		t.Fatalf("failed to close multipart writer: %v", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, url, &buf)
+	req, err := http.NewRequest(method, url, &buf)
 	if err != nil {
 		t.Fatalf("failed to create request: %v", err)
 	}
@@ -0,0 +1,122 @@
+package testutil
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/rjsadow/sortie/internal/db"
+	"github.com/rjsadow/sortie/internal/plugins/auth"
+)
+
+// Fixtures is the data a test starts from, read from a YAML file in
+// testdata/fixtures. Its fields use the same names as the API's JSON. IDs
+// are never generated at random, and users, categories and sessions are
+// created a minute apart from FixtureTime in the order listed, so
+// responses built from fixtures, including their order, can be compared
+// with golden files.
+type Fixtures struct {
+	// Tenants are created besides the default tenant.
+	Tenants []db.Tenant `json:"tenants,omitempty"`
+	// Users are created with their password, and log in with LoginAs.
+	Users []FixtureUser `json:"users,omitempty"`
+	// Categories are created in their tenant, or the default tenant. A
+	// category without an ID gets "cat-<name>".
+	Categories []db.Category `json:"categories,omitempty"`
+	// Apps are created in their tenant, or the default tenant. Categories
+	// they name must be listed above.
+	Apps []db.Application `json:"apps,omitempty"`
+	// Sessions are stored as they are, without a workload. Give them the
+	// status the test needs, usually "running".
+	Sessions []db.Session `json:"sessions,omitempty"`
+}
+
+// FixtureTime is when the first fixture row was created.
+var FixtureTime = time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+
+// FixtureUser is a user created from fixtures. ID defaults to
+// "user-<username>" and Roles to ["user"].
+type FixtureUser struct {
+	db.User
+	Password string `json:"password"`
+}
+
+// LoadFixtures reads a fixtures file, relative to the test's package
+// directory, and creates its contents in the test server's database.
+// Unknown fields fail the test, so a misspelt key is not silently ignored.
+func (ts *TestServer) LoadFixtures(t *testing.T, path string) *Fixtures {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read fixtures: %v", err)
+	}
+	var f Fixtures
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		t.Fatalf("failed to parse fixtures %s: %v", path, err)
+	}
+
+	// Create* methods stamp rows with the current time, so backdate them
+	created := FixtureTime
+	backdate := func(table, id string) {
+		if _, err := ts.DB.ExecRaw("UPDATE "+table+" SET created_at = ?, updated_at = ? WHERE id = ?", created, created, id); err != nil {
+			t.Fatalf("fixtures: failed to backdate %s %s: %v", table, id, err)
+		}
+		created = created.Add(time.Minute)
+	}
+
+	for _, tenant := range f.Tenants {
+		if err := ts.DB.CreateTenant(tenant); err != nil {
+			t.Fatalf("fixtures: failed to create tenant %s: %v", tenant.ID, err)
+		}
+	}
+	for i := range f.Users {
+		u := &f.Users[i]
+		if u.ID == "" {
+			u.ID = "user-" + u.Username
+		}
+		if len(u.Roles) == 0 {
+			u.Roles = []string{"user"}
+		}
+		hash, err := auth.HashPassword(u.Password)
+		if err != nil {
+			t.Fatalf("fixtures: failed to hash password of %s: %v", u.Username, err)
+		}
+		u.PasswordHash = hash
+		if err := ts.DB.CreateUser(u.User); err != nil {
+			t.Fatalf("fixtures: failed to create user %s: %v", u.Username, err)
+		}
+		backdate("users", u.ID)
+	}
+	for i := range f.Categories {
+		cat := &f.Categories[i]
+		if cat.ID == "" {
+			cat.ID = "cat-" + cat.Name
+		}
+		if cat.TenantID == "" {
+			cat.TenantID = db.DefaultTenantID
+		}
+		if err := ts.DB.CreateCategory(*cat); err != nil {
+			t.Fatalf("fixtures: failed to create category %s: %v", cat.Name, err)
+		}
+		backdate("categories", cat.ID)
+	}
+	for i := range f.Apps {
+		app := &f.Apps[i]
+		if app.TenantID == "" {
+			app.TenantID = db.DefaultTenantID
+		}
+		if err := ts.DB.CreateApp(*app); err != nil {
+			t.Fatalf("fixtures: failed to create app %s: %v", app.ID, err)
+		}
+	}
+	for _, s := range f.Sessions {
+		if err := ts.DB.CreateSession(s); err != nil {
+			t.Fatalf("fixtures: failed to create session %s: %v", s.ID, err)
+		}
+		backdate("sessions", s.ID)
+	}
+	return &f
+}
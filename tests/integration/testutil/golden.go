@@ -0,0 +1,102 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+)
+
+// update rewrites golden files with the responses tests receive:
+//
+//	go test ./tests/integration -run TestGolden -update
+var update = flag.Bool("update", false, "rewrite golden files in testdata/golden")
+
+var uuidPattern = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+// AssertGolden compares a response's status and body with the golden file
+// testdata/golden/<name>.json, relative to the test's package directory,
+// and closes the body. JSON bodies are compared with their keys sorted.
+// Values that differ between runs are replaced before comparing: RFC 3339
+// timestamps by "<time>", UUIDs by "<uuid>", and the values of the keys in
+// ignore, at any depth, by "<ignored>". Run the tests with -update to
+// write the files, and review them like code.
+func AssertGolden(t *testing.T, resp *http.Response, name string, ignore ...string) {
+	t.Helper()
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read response body: %v", err)
+	}
+	// Error responses are plain text; keep them as a string
+	var decoded any
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &decoded); err != nil {
+			decoded = string(body)
+		}
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(struct {
+		Status int `json:"status"`
+		Body   any `json:"body"`
+	}{resp.StatusCode, scrub(decoded, ignore)}); err != nil {
+		t.Fatalf("failed to encode response: %v", err)
+	}
+	got := buf.Bytes()
+
+	path := filepath.Join("testdata", "golden", name+".json")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create golden directory: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("failed to write golden file: %v", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("response differs from %s (run with -update to accept it)\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// scrub replaces the values of v that differ between runs.
+func scrub(v any, ignore []string) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, item := range v {
+			if slices.Contains(ignore, k) {
+				v[k] = "<ignored>"
+			} else {
+				v[k] = scrub(item, ignore)
+			}
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = scrub(item, ignore)
+		}
+		return v
+	case string:
+		if _, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return "<time>"
+		}
+		return uuidPattern.ReplaceAllString(v, "<uuid>")
+	default:
+		return v
+	}
+}
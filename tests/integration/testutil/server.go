@@ -10,18 +10,30 @@ import (
 
 	"golang.org/x/crypto/bcrypt"
 
+	"github.com/rjsadow/sortie/internal/apiusage"
+	"github.com/rjsadow/sortie/internal/attestation"
+	"github.com/rjsadow/sortie/internal/branding"
 	"github.com/rjsadow/sortie/internal/config"
 	"github.com/rjsadow/sortie/internal/db"
 	"github.com/rjsadow/sortie/internal/db/dbtest"
 	"github.com/rjsadow/sortie/internal/diagnostics"
+	"github.com/rjsadow/sortie/internal/email"
 	"github.com/rjsadow/sortie/internal/files"
+	"github.com/rjsadow/sortie/internal/jobs"
+	"github.com/rjsadow/sortie/internal/middleware"
 	"github.com/rjsadow/sortie/internal/plugins"
 	"github.com/rjsadow/sortie/internal/plugins/auth"
+	"github.com/rjsadow/sortie/internal/plugins/sidecar"
 	"github.com/rjsadow/sortie/internal/plugins/storage"
+	"github.com/rjsadow/sortie/internal/presence"
 	"github.com/rjsadow/sortie/internal/recordings"
 	"github.com/rjsadow/sortie/internal/server"
 	"github.com/rjsadow/sortie/internal/sessions"
+	"github.com/rjsadow/sortie/internal/spectate"
 	"github.com/rjsadow/sortie/internal/sse"
+	"github.com/rjsadow/sortie/internal/ssrf"
+	"github.com/rjsadow/sortie/internal/storagebrowser"
+	"github.com/rjsadow/sortie/internal/streamstats"
 )
 
 const (
@@ -49,6 +61,18 @@ type TestServer struct {
 	SessionManager *sessions.Manager
 	// Config is the test configuration.
 	Config *config.Config
+	// RecordingDir is the local recording storage directory, or "" when
+	// recording is disabled.
+	RecordingDir string
+	// StreamStats records session stream stats; tests open streams on it
+	// in place of the WebSocket gateway.
+	StreamStats *streamstats.Tracker
+	// Jobs is the background job scheduler. It is not started, so jobs
+	// run only when triggered.
+	Jobs *jobs.Scheduler
+	// APIUsage meters API requests. It is not started, so tests call
+	// Flush to store the counts.
+	APIUsage *apiusage.Meter
 }
 
 // Option is a function that modifies the test config before server creation.
@@ -64,6 +88,24 @@ func WithMaxGlobalSessions(n int) Option {
 	return func(c *config.Config) { c.MaxGlobalSessions = n }
 }
 
+// WithSessionBurst makes the per-user session quota a soft limit with a
+// burst allowance above it.
+func WithSessionBurst(limit int, duration time.Duration) Option {
+	return func(c *config.Config) {
+		c.MaxSessionsPerUserBurst = limit
+		c.SessionBurstDuration = duration
+	}
+}
+
+// WithRateLimits enables the login/registration and session creation rate
+// limits, in requests per minute.
+func WithRateLimits(authRate float64, authBurst int, sessionRate float64, sessionBurst int) Option {
+	return func(c *config.Config) {
+		c.AuthRateLimit, c.AuthRateBurst = authRate, authBurst
+		c.SessionRateLimit, c.SessionRateBurst = sessionRate, sessionBurst
+	}
+}
+
 // WithRecordingEnabled enables the video recording handler with local storage.
 func WithRecordingEnabled() Option {
 	return func(c *config.Config) {
@@ -73,6 +115,17 @@ func WithRecordingEnabled() Option {
 	}
 }
 
+// WithSessionDomain enables session hostnames under domain.
+func WithSessionDomain(domain string) Option {
+	return func(c *config.Config) { c.SessionDomain = domain }
+}
+
+// WithOffline runs the server in offline mode. Only the server's own
+// checks are affected; the process-wide connection guard stays off.
+func WithOffline() Option {
+	return func(c *config.Config) { c.Offline = true }
+}
+
 // NewTestServer creates a fully wired test server with:
 //   - Fresh in-memory SQLite database
 //   - JWT auth provider with test secret
@@ -113,6 +166,8 @@ func NewTestServer(t *testing.T, opts ...Option) *TestServer {
 		PodReadyTimeout:    30 * time.Second,
 		SessionTimeout:     1 * time.Hour,
 		MaxUploadSize:      10 * 1024 * 1024,
+
+		SessionMaxReschedules: config.DefaultSessionMaxReschedules,
 	}
 
 	// Apply custom options before building the server
@@ -146,14 +201,25 @@ func NewTestServer(t *testing.T, opts ...Option) *TestServer {
 	sseHub := sse.NewHub(jwtAuth)
 	recorder := sessions.NewMultiRecorder(nil, sseHub) // nil simulates the no-billing case
 	mockRunner := NewMockRunner()
+	sidecarTemplates := sidecar.NewTemplateInjector()
+	sidecarTemplates.SetDatabase(database)
+	attestor := attestation.DeriveSigner(cfg.JWTSecret)
+	jobScheduler := jobs.NewScheduler(database, "test")
 	sm := sessions.NewManagerWithConfig(database, sessions.ManagerConfig{
-		SessionTimeout:     cfg.SessionTimeout,
-		CleanupInterval:    5 * time.Minute,
-		PodReadyTimeout:    cfg.PodReadyTimeout,
-		MaxSessionsPerUser: cfg.MaxSessionsPerUser,
-		MaxGlobalSessions:  cfg.MaxGlobalSessions,
-		Recorder:           recorder,
-		Runner:             mockRunner,
+		SessionTimeout:          cfg.SessionTimeout,
+		CleanupInterval:         5 * time.Minute,
+		PodReadyTimeout:         cfg.PodReadyTimeout,
+		MaxSessionsPerUser:      cfg.MaxSessionsPerUser,
+		MaxGlobalSessions:       cfg.MaxGlobalSessions,
+		MaxSessionsPerUserBurst: cfg.MaxSessionsPerUserBurst,
+		SessionBurstDuration:    cfg.SessionBurstDuration,
+		Recorder:                recorder,
+		Runner:                  mockRunner,
+		SidecarInjectors:        []plugins.SidecarInjector{sidecarTemplates},
+		Attestor:                attestor,
+		SessionDomain:           cfg.SessionDomain,
+		Scheduler:               jobScheduler,
+		MaxReschedules:          cfg.SessionMaxReschedules,
 	})
 	sm.Start()
 
@@ -168,14 +234,58 @@ func NewTestServer(t *testing.T, opts ...Option) *TestServer {
 
 	// 9. Optionally create recording handler
 	var recordingHandler *recordings.Handler
+	var storageBrowser *storagebrowser.Browser
+	var recDir string
 	if cfg.VideoRecordingEnabled {
-		recDir := filepath.Join(tmpDir, "recordings")
+		recDir = filepath.Join(tmpDir, "recordings")
 		os.MkdirAll(recDir, 0o755)
 		recStore := recordings.NewLocalStore(recDir)
 		recordingHandler = recordings.NewHandler(database, recStore, cfg)
+		storageBrowser = storagebrowser.New(storagebrowser.Category{
+			Name:    "recordings",
+			Backend: cfg.RecordingStorageBackend,
+			Store:   recStore,
+			References: func() (map[string]bool, error) {
+				return recordings.ReferencedPaths(database)
+			},
+		})
+		storageBrowser.SetGracePeriod(0)
+	}
+
+	// Branding assets are always stored locally in tests
+	brandingStore := branding.NewLocalStore(filepath.Join(tmpDir, "branding"))
+
+	// Rate limits are off unless WithRateLimits is given
+	var authLimiter, sessionLimiter *middleware.RateLimiter
+	if cfg.AuthRateLimit > 0 {
+		authLimiter = middleware.NewRateLimiter(func() middleware.RateLimit {
+			return middleware.RateLimit{Rate: cfg.AuthRateLimit, Burst: cfg.AuthRateBurst}
+		})
+		t.Cleanup(authLimiter.Stop)
+	}
+	if cfg.SessionRateLimit > 0 {
+		sessionLimiter = middleware.NewRateLimiter(func() middleware.RateLimit {
+			return middleware.RateLimit{Rate: cfg.SessionRateLimit, Burst: cfg.SessionRateBurst}
+		})
+		t.Cleanup(sessionLimiter.Stop)
+	}
+
+	// Email is sent only when a test points SMTP at its own server
+	var mailer email.Sender
+	if cfg.SMTPHost != "" {
+		mailer = &email.SMTPSender{Host: cfg.SMTPHost, Port: cfg.SMTPPort, From: cfg.SMTPFrom, TLS: cfg.SMTPTLS}
+	}
+
+	outboundAllow, err := ssrf.SplitAllowlist(cfg.OutboundAllowlist)
+	if err != nil {
+		t.Fatalf("invalid outbound allowlist: %v", err)
 	}
+	outbound := ssrf.NewGuard(outboundAllow)
+	outbound.SetOffline(cfg.Offline)
 
 	// 10. Build server.App and handler
+	streamStats := streamstats.NewTracker()
+	apiUsage := apiusage.NewMeter(database)
 	app := &server.App{
 		DB:                  database,
 		SessionManager:      sm,
@@ -187,6 +297,18 @@ func NewTestServer(t *testing.T, opts ...Option) *TestServer {
 		FileHandler:         fh,
 		RecordingHandler:    recordingHandler,
 		DiagCollector:       dc,
+		StorageBrowser:      storageBrowser,
+		BrandingStore:       brandingStore,
+		Spectate:            spectate.NewManager(sseHub),
+		Presence:            presence.NewTracker(),
+		StreamStats:         streamStats,
+		Attestor:            attestor,
+		Jobs:                jobScheduler,
+		APIUsage:            apiUsage,
+		AuthRateLimiter:     authLimiter,
+		SessionRateLimiter:  sessionLimiter,
+		Mailer:              mailer,
+		Outbound:            outbound,
 		Config:              cfg,
 		StaticFS:            nil, // No static files in integration tests
 	}
@@ -199,6 +321,7 @@ func NewTestServer(t *testing.T, opts ...Option) *TestServer {
 	// 11. Register cleanup (database.Close() is handled by dbtest)
 	t.Cleanup(func() {
 		ts.Close()
+		jobScheduler.Stop()
 		sm.Stop()
 	})
 
@@ -210,5 +333,9 @@ func NewTestServer(t *testing.T, opts ...Option) *TestServer {
 		Runner:         mockRunner,
 		SessionManager: sm,
 		Config:         cfg,
+		RecordingDir:   recDir,
+		StreamStats:    streamStats,
+		Jobs:           jobScheduler,
+		APIUsage:       apiUsage,
 	}
 }
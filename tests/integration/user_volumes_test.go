@@ -0,0 +1,81 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rjsadow/sortie/internal/runner"
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestUserVolumes(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"home","name":"Home","launch_type":"container","container_image":"nginx:latest","home_volume":{"mount_path":"/home/user","size":"2Gi"}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+	if app, _ := ts.DB.GetApp("home"); app == nil || app.HomeVolume == nil || app.HomeVolume.MountPath != "/home/user" {
+		t.Fatalf("app = %+v, want a home volume at /home/user", app)
+	}
+
+	resp = testutil.AuthPut(t, ts.URL+"/api/apps/home", ts.AdminToken,
+		[]byte(`{"id":"home","name":"Home","launch_type":"container","container_image":"nginx:latest","home_volume":{"mount_path":"home"}}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("relative mount_path: expected 400, got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthPost(t, ts.URL+"/api/sessions", ts.AdminToken, []byte(`{"app_id":"home"}`))
+	if resp.StatusCode != http.StatusCreated {
+		resp.Body.Close()
+		t.Fatalf("create session: expected 201, got %d", resp.StatusCode)
+	}
+	var session struct {
+		ID string `json:"id"`
+	}
+	testutil.ReadJSON(t, resp, &session)
+
+	var list struct {
+		Volumes []runner.UserVolume `json:"volumes"`
+	}
+	testutil.ReadJSON(t, testutil.AuthGet(t, ts.URL+"/api/admin/user-volumes?app_id=home", ts.AdminToken), &list)
+	if len(list.Volumes) != 1 || list.Volumes[0].AppID != "home" || list.Volumes[0].Size != "2Gi" {
+		t.Fatalf("volumes = %+v, want one for the app", list.Volumes)
+	}
+	name := list.Volumes[0].Name
+
+	resp = testutil.AuthDelete(t, ts.URL+"/api/admin/user-volumes/"+name, ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("delete in use: expected 409, got %d", resp.StatusCode)
+	}
+
+	testutil.CreateUser(t, ts.URL, ts.AdminToken, "voluser", "password123", []string{"user"})
+	userToken := testutil.LoginAs(t, ts.URL, "voluser", "password123")
+	resp = testutil.AuthGet(t, ts.URL+"/api/admin/user-volumes", userToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("non-admin list: expected 403, got %d", resp.StatusCode)
+	}
+
+	waitForRunning(t, ts, session.ID)
+	resp = testutil.AuthDelete(t, ts.URL+"/api/sessions/"+session.ID, ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		t.Fatalf("terminate session: got %d", resp.StatusCode)
+	}
+
+	resp = testutil.AuthDelete(t, ts.URL+"/api/admin/user-volumes/"+name, ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", resp.StatusCode)
+	}
+	resp = testutil.AuthDelete(t, ts.URL+"/api/admin/user-volumes/"+name, ts.AdminToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("delete again: expected 404, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,56 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rjsadow/sortie/tests/integration/testutil"
+)
+
+func TestAppWarmPoolSize(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+
+	resp := testutil.AuthPost(t, ts.URL+"/api/apps", ts.AdminToken,
+		[]byte(`{"id":"warm","name":"Warm","launch_type":"container","container_image":"nginx:latest","warm_pool_size":2}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+	if app, _ := ts.DB.GetApp("warm"); app == nil || app.WarmPoolSize != 2 {
+		t.Fatalf("app = %+v, want warm_pool_size 2", app)
+	}
+
+	t.Run("too large", func(t *testing.T) {
+		resp := testutil.AuthPut(t, ts.URL+"/api/apps/warm", ts.AdminToken,
+			[]byte(`{"id":"warm","name":"Warm","launch_type":"container","container_image":"nginx:latest","warm_pool_size":21}`))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("admin only", func(t *testing.T) {
+		testutil.CreateUser(t, ts.URL, ts.AdminToken, "author", "author-pass-123", []string{"app-author"})
+		token := testutil.LoginAs(t, ts.URL, "author", "author-pass-123")
+
+		resp := testutil.AuthPut(t, ts.URL+"/api/apps/warm", token,
+			[]byte(`{"id":"warm","name":"Warm","launch_type":"container","container_image":"nginx:latest","warm_pool_size":10}`))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		if app, _ := ts.DB.GetApp("warm"); app.WarmPoolSize != 2 {
+			t.Errorf("warm_pool_size = %d after an author's update, want 2", app.WarmPoolSize)
+		}
+
+		resp = testutil.AuthPost(t, ts.URL+"/api/apps", token,
+			[]byte(`{"id":"warm2","name":"Warm 2","launch_type":"container","container_image":"nginx:latest","warm_pool_size":3}`))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected 201, got %d", resp.StatusCode)
+		}
+		if app, _ := ts.DB.GetApp("warm2"); app == nil || app.WarmPoolSize != 0 {
+			t.Errorf("app = %+v, want warm_pool_size 0", app)
+		}
+	})
+}
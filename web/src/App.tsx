@@ -15,10 +15,13 @@ import {
   logout as authLogout,
   getCurrentUser,
   isAuthenticated,
-  fetchWithAuth
+  fetchWithAuth,
+  passkeysSupported,
+  registerPasskey
 } from './services/auth';
 import { CommandPalette } from './components/CommandPalette';
 import { UserMenu } from './components/UserMenu';
+import { applyBranding } from './utils/branding';
 import sortieIconWhite from './assets/sortie-icon-white.svg';
 
 function App() {
@@ -60,10 +63,16 @@ function App() {
   const [showRecordings, setShowRecordings] = useState(false);
   const [allowRegistration, setAllowRegistration] = useState(false);
   const [ssoEnabled, setSsoEnabled] = useState(false);
+  const [passkeysEnabled, setPasskeysEnabled] = useState(false);
+  const [passwordResetEnabled, setPasswordResetEnabled] = useState(false);
+  // Token from a password reset email link: /?reset_token={token}
+  const [resetToken, setResetToken] = useState<string | null>(
+    () => new URLSearchParams(window.location.search).get('reset_token')
+  );
   const [showKeyboardHint, setShowKeyboardHint] = useState(false);
   const appRefs = useRef<(HTMLButtonElement | HTMLAnchorElement | null)[]>([]);
 
-  const { sessions } = useSessions(true);
+  const { sessions, welcomes, dismissWelcome } = useSessions(true);
   const activeSessionCount = sessions.filter(
     (s) => s.status === 'creating' || s.status === 'running'
   ).length;
@@ -78,6 +87,9 @@ function App() {
           const config = await configRes.json();
           setAllowRegistration(config.allow_registration === true);
           setSsoEnabled(config.sso_enabled === true);
+          setPasskeysEnabled(config.passkeys_enabled === true);
+          setPasswordResetEnabled(config.password_reset_enabled === true);
+          applyBranding(config);
         }
       } catch {
         // Ignore config fetch errors
@@ -377,6 +389,18 @@ function App() {
     setApps([]);
   };
 
+  const handleAddPasskey = async () => {
+    const name = prompt('Name this passkey, e.g. the device it is on:', 'Passkey');
+    if (name === null) return;
+    try {
+      await registerPasskey(name);
+      alert('Passkey added. You can now sign in with it.');
+    } catch (err) {
+      if (err instanceof DOMException && err.name === 'NotAllowedError') return;
+      alert(err instanceof Error ? err.message : 'Failed to add passkey');
+    }
+  };
+
   const handleAddApp = async (app: Application) => {
     const response = await fetchWithAuth('/api/apps', {
       method: 'POST',
@@ -417,6 +441,13 @@ function App() {
         onShowRegister={() => setShowRegister(true)}
         allowRegistration={allowRegistration}
         ssoEnabled={ssoEnabled}
+        passkeysEnabled={passkeysEnabled}
+        passwordResetEnabled={passwordResetEnabled}
+        resetToken={resetToken}
+        onResetDone={() => {
+          setResetToken(null);
+          window.history.replaceState(null, '', '/');
+        }}
         darkMode={darkMode}
       />
     );
@@ -437,6 +468,8 @@ function App() {
         viewOnly={sessionShareInfo?.viewOnly}
         ownerUsername={sessionShareInfo?.ownerUsername}
         sharePermission={sessionShareInfo?.sharePermission}
+        welcomes={welcomes}
+        onDismissWelcome={dismissWelcome}
       />
     );
   }
@@ -511,6 +544,7 @@ function App() {
                 onOpenDocs={() => window.open('/docs/', '_blank', 'noopener,noreferrer')}
                 onOpenAdmin={() => setShowAdmin(true)}
                 onOpenAuditLog={() => setShowAuditLog(true)}
+                onAddPasskey={passkeysEnabled && passkeysSupported() ? handleAddPasskey : undefined}
                 onLogout={handleLogout}
               />
             </div>
@@ -9,6 +9,8 @@ import {
   createTemplate,
   updateTemplate,
   deleteTemplate,
+  importTemplates,
+  exportTemplates,
   listAdminSessions,
   terminateAdminSession,
   listAdminRecordings,
@@ -18,11 +20,15 @@ import {
   createApp,
   updateApp,
   deleteApp,
+  uploadIcon,
+  lintApp,
+  lintTemplate,
   type AdminUser,
+  type LintWarning,
   listCategories,
   type AdminTemplate,
 } from '../services/auth';
-import type { Application, AppVisibility, Category, Session, SessionStatus, Recording, RecordingStatus } from '../types';
+import type { Application, AppVisibility, Category, ClipboardPolicy, FileTransferPolicy, Session, SessionStatus, Recording, RecordingStatus } from '../types';
 import { formatDuration } from '../utils/time';
 import { CategoryManager } from './CategoryManager';
 
@@ -58,6 +64,28 @@ const VISIBILITY_COLORS: Record<AppVisibility, string> = {
   admin_only: 'bg-red-500/20 text-red-400',
 };
 
+// How long the app and template forms wait after an edit before linting the draft
+const LINT_DELAY_MS = 500;
+
+function LintWarnings({ warnings, darkMode }: { warnings: LintWarning[]; darkMode: boolean }) {
+  if (warnings.length === 0) return null;
+  return (
+    <div className={`mt-6 p-3 rounded-lg border ${darkMode ? 'border-yellow-900/50 bg-yellow-900/20' : 'border-yellow-200 bg-yellow-50'}`}>
+      <p className={`text-sm font-medium mb-2 ${darkMode ? 'text-yellow-300' : 'text-yellow-800'}`}>
+        Best-practice warnings
+      </p>
+      <ul className="space-y-1">
+        {warnings.map((w) => (
+          <li key={`${w.rule} ${w.field}`} className={`text-xs ${darkMode ? 'text-yellow-200' : 'text-yellow-900'}`}>
+            <span className={`font-mono mr-2 ${w.severity === 'warning' ? 'font-semibold' : 'opacity-75'}`}>{w.rule}</span>
+            {w.message}
+          </li>
+        ))}
+      </ul>
+    </div>
+  );
+}
+
 const emptyTemplate: Omit<AdminTemplate, 'id' | 'created_at' | 'updated_at'> = {
   template_id: '',
   template_version: '1.0.0',
@@ -114,6 +142,7 @@ export function Admin({ darkMode, onClose, isSystemAdmin, adminCategoryIds }: Ad
 
   // Settings state
   const [allowRegistration, setAllowRegistration] = useState(false);
+  const [allowPasskeys, setAllowPasskeys] = useState(false);
   const [autoRecord, setAutoRecord] = useState(false);
 
   // Users state
@@ -152,6 +181,10 @@ export function Admin({ darkMode, onClose, isSystemAdmin, adminCategoryIds }: Ad
   const [tagsInput, setTagsInput] = useState('');
   const [containerArgsInput, setContainerArgsInput] = useState('');
 
+  // Lint warnings for the open app or template form
+  const [appLintWarnings, setAppLintWarnings] = useState<LintWarning[]>([]);
+  const [templateLintWarnings, setTemplateLintWarnings] = useState<LintWarning[]>([]);
+
   const loadData = async () => {
     setLoading(true);
     setError('');
@@ -167,6 +200,7 @@ export function Admin({ darkMode, onClose, isSystemAdmin, adminCategoryIds }: Ad
           listAdminRecordings(),
         ]);
         setAllowRegistration(settings.allow_registration === true || settings.allow_registration === 'true');
+        setAllowPasskeys(settings.allow_passkeys === true || settings.allow_passkeys === 'true');
         setAutoRecord(settings.recording_auto_record === true || settings.recording_auto_record === 'true');
         setUsers(userList);
         setCategories(catList);
@@ -196,15 +230,42 @@ export function Admin({ darkMode, onClose, isSystemAdmin, adminCategoryIds }: Ad
   // eslint-disable-next-line react-hooks/exhaustive-deps
   useEffect(() => { loadData(); }, []);
 
+  // Re-lint the app draft shortly after each edit. Lint failures, such as
+  // for category admins who may not lint, just leave no warnings.
+  useEffect(() => {
+    if (!showAppForm) {
+      setAppLintWarnings([]);
+      return;
+    }
+    const timer = setTimeout(() => {
+      lintApp(appForm).then(setAppLintWarnings).catch(() => setAppLintWarnings([]));
+    }, LINT_DELAY_MS);
+    return () => clearTimeout(timer);
+  }, [showAppForm, appForm]);
+
+  useEffect(() => {
+    if (!showTemplateForm) {
+      setTemplateLintWarnings([]);
+      return;
+    }
+    const timer = setTimeout(() => {
+      lintTemplate(templateForm).then(setTemplateLintWarnings).catch(() => setTemplateLintWarnings([]));
+    }, LINT_DELAY_MS);
+    return () => clearTimeout(timer);
+  }, [showTemplateForm, templateForm]);
+
   const handleSaveSettings = async () => {
     setError('');
     setSuccess('');
     try {
-      await updateAdminSettings({
+      const staged = await updateAdminSettings({
         allow_registration: allowRegistration.toString(),
+        allow_passkeys: allowPasskeys.toString(),
         recording_auto_record: autoRecord.toString(),
       });
-      setSuccess('Settings saved successfully');
+      setSuccess(staged
+        ? `Settings saved; changes to ${Object.keys(staged.changes).join(', ')} await another admin's approval`
+        : 'Settings saved successfully');
       setTimeout(() => setSuccess(''), 3000);
     } catch (err) {
       setError(err instanceof Error ? err.message : 'Failed to save settings');
@@ -271,6 +332,16 @@ export function Admin({ darkMode, onClose, isSystemAdmin, adminCategoryIds }: Ad
     setAppContainerArgsInput('');
   };
 
+  const handleIconUpload = async (file: File | undefined, onUploaded: (url: string) => void) => {
+    if (!file) return;
+    setError('');
+    try {
+      onUploaded(await uploadIcon(file));
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to upload icon');
+    }
+  };
+
   const handleSaveApp = async () => {
     setError('');
 
@@ -431,6 +502,36 @@ export function Admin({ darkMode, onClose, isSystemAdmin, adminCategoryIds }: Ad
     }
   };
 
+  const handleImportTemplates = async (file: File | undefined) => {
+    if (!file) return;
+    setError('');
+    try {
+      const result = await importTemplates(file);
+      await loadData();
+      setSuccess(`Imported templates: ${result.created.length} created, ${result.updated.length} updated`);
+      setTimeout(() => setSuccess(''), 3000);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to import templates');
+    }
+  };
+
+  const handleExportTemplates = async () => {
+    setError('');
+    try {
+      const blob = await exportTemplates('yaml');
+      const downloadUrl = URL.createObjectURL(blob);
+      const a = document.createElement('a');
+      a.href = downloadUrl;
+      a.download = 'templates.yaml';
+      document.body.appendChild(a);
+      a.click();
+      document.body.removeChild(a);
+      URL.revokeObjectURL(downloadUrl);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to export templates');
+    }
+  };
+
   const handleTerminateSession = async (session: Session) => {
     if (!confirm(`Are you sure you want to terminate session "${session.id}"?`)) {
       return;
@@ -452,6 +553,7 @@ export function Admin({ darkMode, onClose, isSystemAdmin, adminCategoryIds }: Ad
     failed: { bg: 'bg-red-500', text: 'text-red-500' },
     stopped: { bg: 'bg-gray-400', text: 'text-gray-400' },
     expired: { bg: 'bg-gray-400', text: 'text-gray-400' },
+    hibernated: { bg: 'bg-indigo-400', text: 'text-indigo-400' },
   };
 
   const bgColor = darkMode ? 'bg-gray-900' : 'bg-gray-100';
@@ -586,6 +688,21 @@ export function Admin({ darkMode, onClose, isSystemAdmin, adminCategoryIds }: Ad
                     </div>
                   </label>
 
+                  <label className="flex items-center space-x-3">
+                    <input
+                      type="checkbox"
+                      checked={allowPasskeys}
+                      onChange={(e) => setAllowPasskeys(e.target.checked)}
+                      className="w-5 h-5 rounded border-gray-500 text-brand-accent focus:ring-brand-accent"
+                    />
+                    <div>
+                      <span className={textColor}>Allow passkey sign-in</span>
+                      <p className={`text-sm ${mutedText}`}>
+                        When enabled, local users can add passkeys and sign in with them instead of a password
+                      </p>
+                    </div>
+                  </label>
+
                   <label className="flex items-center space-x-3">
                     <input
                       type="checkbox"
@@ -943,13 +1060,28 @@ export function Admin({ darkMode, onClose, isSystemAdmin, adminCategoryIds }: Ad
 
                         <div>
                           <label className={`block text-sm mb-1 ${mutedText}`}>Icon URL</label>
-                          <input
-                            type="text"
-                            value={appForm.icon}
-                            onChange={(e) => setAppForm({ ...appForm, icon: e.target.value })}
-                            placeholder="https://example.com/icon.png"
-                            className={`w-full px-3 py-2 rounded-lg border ${inputBg} ${inputText}`}
-                          />
+                          <div className="flex gap-2">
+                            <input
+                              type="text"
+                              value={appForm.icon}
+                              onChange={(e) => setAppForm({ ...appForm, icon: e.target.value })}
+                              placeholder="https://example.com/icon.png"
+                              className={`flex-1 px-3 py-2 rounded-lg border ${inputBg} ${inputText}`}
+                            />
+                            <label className={`px-3 py-2 rounded-lg border cursor-pointer text-sm ${inputBg} ${inputText}`}>
+                              Upload
+                              <input
+                                type="file"
+                                accept="image/png,image/jpeg,image/gif,image/webp,image/x-icon,image/svg+xml"
+                                className="hidden"
+                                onChange={(e) => {
+                                  handleIconUpload(e.target.files?.[0], (icon) => setAppForm((form) => ({ ...form, icon })));
+                                  e.target.value = '';
+                                }}
+                              />
+                            </label>
+                          </div>
+                          <p className={`text-xs mt-1 ${mutedText}`}>Uploaded icons are served by Sortie, so they work without internet access.</p>
                         </div>
 
                         {/* Resource Limits */}
@@ -1024,8 +1156,113 @@ export function Admin({ darkMode, onClose, isSystemAdmin, adminCategoryIds }: Ad
                             </div>
                           </div>
                         )}
+
+                        {appForm.launch_type === 'container' && (
+                          <label className="col-span-2 flex items-center space-x-3">
+                            <input
+                              type="checkbox"
+                              checked={!!appForm.audit_session_activity}
+                              onChange={(e) => setAppForm({ ...appForm, audit_session_activity: e.target.checked })}
+                              className="w-5 h-5 rounded border-gray-500 text-brand-accent focus:ring-brand-accent"
+                            />
+                            <div>
+                              <span className={textColor}>Audit in-session activity</span>
+                              <p className={`text-sm ${mutedText}`}>
+                                Log disconnects, clipboard use, file transfers, and printing to the audit log
+                              </p>
+                            </div>
+                          </label>
+                        )}
+
+                        {appForm.launch_type === 'container' && (
+                          <div className="col-span-2">
+                            <label className={`block text-sm mb-1 ${mutedText}`}>Clipboard</label>
+                            <select
+                              value={appForm.clipboard_policy || 'bidirectional'}
+                              onChange={(e) => setAppForm({ ...appForm, clipboard_policy: e.target.value as ClipboardPolicy })}
+                              className={`w-full px-3 py-2 rounded-lg border ${inputBg} ${inputText}`}
+                            >
+                              <option value="bidirectional">Copy and paste</option>
+                              <option value="read">Copy out of the session only</option>
+                              <option value="write">Paste into the session only</option>
+                              <option value="none">Disabled</option>
+                            </select>
+                            <p className={`text-sm mt-1 ${mutedText}`}>
+                              Blocked clipboard transfers are dropped by the server and logged to the audit log
+                            </p>
+                          </div>
+                        )}
+
+                        {appForm.launch_type === 'container' && (
+                          <div className="col-span-2">
+                            <label className={`block text-sm mb-1 ${mutedText}`}>File transfer</label>
+                            <select
+                              value={appForm.file_transfer || 'both'}
+                              onChange={(e) => setAppForm({ ...appForm, file_transfer: e.target.value as FileTransferPolicy })}
+                              className={`w-full px-3 py-2 rounded-lg border ${inputBg} ${inputText}`}
+                            >
+                              <option value="both">Upload and download</option>
+                              <option value="upload_only">Upload into the session only</option>
+                              <option value="download_only">Download from the session only</option>
+                              <option value="none">Disabled</option>
+                            </select>
+                            <p className={`text-sm mt-1 ${mutedText}`}>
+                              Blocked uploads and downloads are rejected by the server and logged to the audit log
+                            </p>
+                          </div>
+                        )}
+
+                        {appForm.launch_type !== 'url' && (
+                          <div className="col-span-2">
+                            <label className={`block text-sm mb-1 ${mutedText}`}>Max concurrent sessions</label>
+                            <input
+                              type="number"
+                              min={0}
+                              value={appForm.max_concurrent_sessions || ''}
+                              onChange={(e) => setAppForm({ ...appForm, max_concurrent_sessions: parseInt(e.target.value) || 0 })}
+                              placeholder="Unlimited"
+                              className={`w-full px-3 py-2 rounded-lg border ${inputBg} ${inputText}`}
+                            />
+                            <p className={`text-sm mt-1 ${mutedText}`}>
+                              Sessions of this app across all users, such as the seats of its license
+                              {appForm.active_sessions !== undefined && ` (${appForm.active_sessions} in use)`}
+                            </p>
+                          </div>
+                        )}
+
+                        {appForm.launch_type !== 'url' && (
+                          <label className="col-span-2 flex items-center space-x-3">
+                            <input
+                              type="checkbox"
+                              checked={!!appForm.hibernate_on_idle}
+                              onChange={(e) => setAppForm({ ...appForm, hibernate_on_idle: e.target.checked })}
+                              className="w-5 h-5 rounded border-gray-500 text-brand-accent focus:ring-brand-accent"
+                            />
+                            <div>
+                              <span className={textColor}>Hibernate idle sessions</span>
+                              <p className={`text-sm ${mutedText}`}>
+                                Keep the workspace of idle sessions on a volume so users can resume them
+                              </p>
+                            </div>
+                          </label>
+                        )}
+
+                        {appForm.launch_type === 'container' && (
+                          <div className="col-span-2">
+                            <label className={`block text-sm mb-1 ${mutedText}`}>Welcome Message (optional)</label>
+                            <textarea
+                              value={appForm.welcome_message || ''}
+                              onChange={(e) => setAppForm({ ...appForm, welcome_message: e.target.value })}
+                              placeholder="Markdown shown when a session first connects, e.g. acceptable-use reminders or lab instructions"
+                              rows={4}
+                              className={`w-full px-3 py-2 rounded-lg border font-mono text-sm ${inputBg} ${inputText}`}
+                            />
+                          </div>
+                        )}
                       </div>
 
+                      <LintWarnings warnings={appLintWarnings} darkMode={darkMode} />
+
                       <div className="flex justify-end gap-3 mt-6">
                         <button
                           onClick={handleCloseAppForm}
@@ -1137,12 +1374,32 @@ export function Admin({ darkMode, onClose, isSystemAdmin, adminCategoryIds }: Ad
               <div className={`${cardBg} rounded-lg p-6`}>
                 <div className="flex justify-between items-center mb-4">
                   <h2 className={`text-lg font-semibold ${textColor}`}>Template Management</h2>
-                  <button
-                    onClick={() => handleOpenTemplateForm()}
-                    className="px-4 py-2 bg-brand-accent text-white rounded-lg hover:bg-brand-primary transition-colors"
-                  >
-                    Create Template
-                  </button>
+                  <div className="flex gap-2">
+                    <label className={`px-4 py-2 rounded-lg border cursor-pointer ${inputBg} ${inputText}`}>
+                      Import Bundle
+                      <input
+                        type="file"
+                        accept=".json,.yaml,.yml,.zip,application/json,application/yaml,application/zip"
+                        className="hidden"
+                        onChange={(e) => {
+                          handleImportTemplates(e.target.files?.[0]);
+                          e.target.value = '';
+                        }}
+                      />
+                    </label>
+                    <button
+                      onClick={handleExportTemplates}
+                      className={`px-4 py-2 rounded-lg border ${inputBg} ${inputText}`}
+                    >
+                      Export
+                    </button>
+                    <button
+                      onClick={() => handleOpenTemplateForm()}
+                      className="px-4 py-2 bg-brand-accent text-white rounded-lg hover:bg-brand-primary transition-colors"
+                    >
+                      Create Template
+                    </button>
+                  </div>
                 </div>
 
                 {/* Template Form Modal */}
@@ -1323,13 +1580,28 @@ export function Admin({ darkMode, onClose, isSystemAdmin, adminCategoryIds }: Ad
 
                         <div>
                           <label className={`block text-sm mb-1 ${mutedText}`}>Icon URL</label>
-                          <input
-                            type="text"
-                            value={templateForm.icon}
-                            onChange={(e) => setTemplateForm({ ...templateForm, icon: e.target.value })}
-                            placeholder="https://example.com/icon.png"
-                            className={`w-full px-3 py-2 rounded-lg border ${inputBg} ${inputText}`}
-                          />
+                          <div className="flex gap-2">
+                            <input
+                              type="text"
+                              value={templateForm.icon}
+                              onChange={(e) => setTemplateForm({ ...templateForm, icon: e.target.value })}
+                              placeholder="https://example.com/icon.png"
+                              className={`flex-1 px-3 py-2 rounded-lg border ${inputBg} ${inputText}`}
+                            />
+                            <label className={`px-3 py-2 rounded-lg border cursor-pointer text-sm ${inputBg} ${inputText}`}>
+                              Upload
+                              <input
+                                type="file"
+                                accept="image/png,image/jpeg,image/gif,image/webp,image/x-icon,image/svg+xml"
+                                className="hidden"
+                                onChange={(e) => {
+                                  handleIconUpload(e.target.files?.[0], (icon) => setTemplateForm((form) => ({ ...form, icon })));
+                                  e.target.value = '';
+                                }}
+                              />
+                            </label>
+                          </div>
+                          <p className={`text-xs mt-1 ${mutedText}`}>Uploaded icons are served by Sortie, so they work without internet access.</p>
                         </div>
 
                         <div>
@@ -1437,6 +1709,8 @@ export function Admin({ darkMode, onClose, isSystemAdmin, adminCategoryIds }: Ad
                         </div>
                       </div>
 
+                      <LintWarnings warnings={templateLintWarnings} darkMode={darkMode} />
+
                       <div className="flex justify-end gap-3 mt-6">
                         <button
                           onClick={handleCloseTemplateForm}
@@ -1585,6 +1859,22 @@ export function Admin({ darkMode, onClose, isSystemAdmin, adminCategoryIds }: Ad
                               <div className="flex items-center gap-2">
                                 <span className={`inline-block w-2 h-2 rounded-full ${statusConfig.bg} ${statusConfig.pulse ? 'animate-pulse' : ''}`} />
                                 <span className={`text-sm ${statusConfig.text}`}>{session.status}</span>
+                                {session.restart_count ? (
+                                  <span
+                                    className="text-xs px-1.5 py-0.5 rounded bg-yellow-100 text-yellow-800 dark:bg-yellow-900/30 dark:text-yellow-300"
+                                    title={session.substatus_detail}
+                                  >
+                                    {session.restart_count} {session.restart_count === 1 ? 'restart' : 'restarts'}
+                                  </span>
+                                ) : null}
+                                {session.substatus === 'rescheduling' && (
+                                  <span
+                                    className="text-xs px-1.5 py-0.5 rounded bg-blue-100 text-blue-800 dark:bg-blue-900/30 dark:text-blue-300"
+                                    title={session.substatus_detail}
+                                  >
+                                    rescheduling
+                                  </span>
+                                )}
                               </div>
                             </td>
                             <td className={`py-3 ${textColor}`}>{session.app_name || session.app_id}</td>
@@ -18,6 +18,7 @@ const ACTION_STYLES: Record<string, { label: string; color: string }> = {
   CREATE_SESSION: { label: 'Launch', color: 'bg-brand-accent/10 text-brand-accent' },
   STOP_SESSION: { label: 'Stop', color: 'bg-yellow-100 text-yellow-800 dark:bg-yellow-900/30 dark:text-yellow-300' },
   RESTART_SESSION: { label: 'Restart', color: 'bg-cyan-100 text-cyan-800 dark:bg-cyan-900/30 dark:text-cyan-300' },
+  RESUME_SESSION: { label: 'Resume', color: 'bg-cyan-100 text-cyan-800 dark:bg-cyan-900/30 dark:text-cyan-300' },
   TERMINATE_SESSION: { label: 'Terminate', color: 'bg-red-100 text-red-800 dark:bg-red-900/30 dark:text-red-300' },
   CREATE_APP: { label: 'Add App', color: 'bg-purple-100 text-purple-800 dark:bg-purple-900/30 dark:text-purple-300' },
   UPDATE_APP: { label: 'Edit App', color: 'bg-purple-100 text-purple-800 dark:bg-purple-900/30 dark:text-purple-300' },
@@ -122,6 +123,26 @@ export function AuditLog({ darkMode, onClose }: AuditLogProps) {
     }
   };
 
+  // Signed snapshot of who can access what, for auditors
+  const handleComplianceReport = async () => {
+    try {
+      const response = await fetchWithAuth('/api/admin/compliance-report');
+      if (!response.ok) throw new Error('Export failed');
+      const disposition = response.headers.get('Content-Disposition') || '';
+      const blob = await response.blob();
+      const downloadUrl = URL.createObjectURL(blob);
+      const a = document.createElement('a');
+      a.href = downloadUrl;
+      a.download = disposition.match(/filename=([^;]+)/)?.[1] || 'compliance-report.zip';
+      document.body.appendChild(a);
+      a.click();
+      document.body.removeChild(a);
+      URL.revokeObjectURL(downloadUrl);
+    } catch {
+      setError('Failed to export compliance report');
+    }
+  };
+
   const clearFilters = () => {
     setFilterUser('');
     setFilterAction('');
@@ -230,6 +251,16 @@ export function AuditLog({ darkMode, onClose }: AuditLogProps) {
                 </svg>
                 JSON
               </button>
+              <button
+                onClick={handleComplianceReport}
+                title="Users, roles, category access, shares, API keys and settings, signed"
+                className="inline-flex items-center gap-1.5 px-3 py-1.5 rounded-lg border border-gray-300 dark:border-gray-600 bg-white dark:bg-gray-700 text-sm text-gray-700 dark:text-gray-300 hover:bg-gray-50 dark:hover:bg-gray-600 transition-colors"
+              >
+                <svg className="w-4 h-4" fill="none" stroke="currentColor" viewBox="0 0 24 24">
+                  <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M12 10v6m0 0l-3-3m3 3l3-3m2 8H7a2 2 0 01-2-2V5a2 2 0 012-2h5.586a1 1 0 01.707.293l5.414 5.414a1 1 0 01.293.707V19a2 2 0 01-2 2z" />
+                </svg>
+                Compliance report
+              </button>
             </div>
           </div>
         </div>
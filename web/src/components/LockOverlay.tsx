@@ -0,0 +1,137 @@
+import { useState, type FormEvent } from 'react';
+import { fetchWithAuth, getStoredUser, login, loginWithPasskey, passkeysSupported, verifyMFA } from '../services/auth';
+
+interface LockOverlayProps {
+  sessionId: string;
+  appName: string;
+  darkMode: boolean;
+  onUnlock: () => void;
+}
+
+// Shown over a session locked for inactivity. The stream comes back once
+// the owner signs in again.
+export function LockOverlay({ sessionId, appName, darkMode, onUnlock }: LockOverlayProps) {
+  const username = getStoredUser()?.username || '';
+  const [password, setPassword] = useState('');
+  const [mfaToken, setMfaToken] = useState('');
+  const [code, setCode] = useState('');
+  const [busy, setBusy] = useState(false);
+  const [error, setError] = useState('');
+
+  const bgColor = darkMode ? 'bg-gray-800' : 'bg-white';
+  const textColor = darkMode ? 'text-gray-100' : 'text-gray-900';
+  const subtextColor = darkMode ? 'text-gray-400' : 'text-gray-500';
+  const borderColor = darkMode ? 'border-gray-700' : 'border-gray-200';
+  const inputClass = `w-full px-3 py-2 rounded-lg border ${borderColor} ${darkMode ? 'bg-gray-700 text-gray-100' : 'bg-white text-gray-900'}`;
+
+  const unlock = async () => {
+    const response = await fetchWithAuth(`/api/sessions/${sessionId}/unlock`, { method: 'POST' });
+    if (!response.ok) {
+      const text = await response.text();
+      throw new Error(text || 'Failed to unlock session');
+    }
+    onUnlock();
+  };
+
+  const run = async (signIn: () => Promise<boolean>) => {
+    setBusy(true);
+    setError('');
+    try {
+      if (await signIn()) {
+        await unlock();
+      }
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Sign in failed');
+    } finally {
+      setBusy(false);
+    }
+  };
+
+  const handleSubmit = (e: FormEvent) => {
+    e.preventDefault();
+    run(async () => {
+      if (mfaToken) {
+        await verifyMFA(mfaToken, code);
+        return true;
+      }
+      const result = await login(username, password);
+      if ('mfa_required' in result) {
+        setMfaToken(result.mfa_token);
+        return false;
+      }
+      return true;
+    });
+  };
+
+  return (
+    <>
+      {/* Backdrop */}
+      <div className="fixed inset-0 bg-black/60 backdrop-blur-md z-50" />
+
+      {/* Dialog */}
+      <div
+        role="dialog"
+        aria-modal="true"
+        aria-labelledby="session-lock-title"
+        className={`fixed top-1/2 left-1/2 -translate-x-1/2 -translate-y-1/2 z-50 w-full max-w-sm ${bgColor} rounded-xl shadow-2xl border ${borderColor}`}
+      >
+        <form onSubmit={handleSubmit}>
+          <div className={`px-5 py-4 border-b ${borderColor}`}>
+            <h3 id="session-lock-title" className={`text-lg font-semibold ${textColor}`}>
+              {appName} is locked
+            </h3>
+            <p className={`text-sm mt-1 ${subtextColor}`}>
+              The session was locked after a period of inactivity. Sign in again as {username} to continue.
+            </p>
+          </div>
+
+          <div className="px-5 py-4 space-y-3">
+            {mfaToken ? (
+              <input
+                type="text"
+                inputMode="numeric"
+                autoComplete="one-time-code"
+                placeholder="Authentication code"
+                value={code}
+                onChange={(e) => setCode(e.target.value)}
+                autoFocus
+                className={inputClass}
+              />
+            ) : (
+              <input
+                type="password"
+                autoComplete="current-password"
+                placeholder="Password"
+                value={password}
+                onChange={(e) => setPassword(e.target.value)}
+                autoFocus
+                className={inputClass}
+              />
+            )}
+            {error && <p className="text-sm text-red-500">{error}</p>}
+          </div>
+
+          <div className={`flex justify-end gap-2 px-5 py-4 border-t ${borderColor}`}>
+            {passkeysSupported() && !mfaToken && (
+              <button
+                type="button"
+                disabled={busy}
+                onClick={() => run(async () => { await loginWithPasskey(); return true; })}
+                className={`px-4 py-2 rounded-lg border ${borderColor} ${textColor} disabled:opacity-50`}
+              >
+                Use passkey
+              </button>
+            )}
+            <button
+              type="submit"
+              disabled={busy || (mfaToken ? !code : !password)}
+              className="px-4 py-2 rounded-lg bg-brand-accent text-white hover:opacity-90 transition-opacity disabled:opacity-50"
+            >
+              {busy ? 'Unlocking...' : 'Unlock'}
+            </button>
+          </div>
+        </form>
+      </div>
+    </>
+  );
+}
@@ -1,6 +1,17 @@
 import { useState, type FormEvent } from 'react';
 import type { User } from '../types';
-import { login as authLogin } from '../services/auth';
+import {
+  forgotPassword,
+  login as authLogin,
+  loginWithPasskey,
+  passkeysSupported,
+  resetPassword,
+  startMFASetup,
+  verifyMFA,
+  type AuthResponse,
+  type MFAChallenge,
+  type MFASetup,
+} from '../services/auth';
 import sortieIconFull from '../assets/sortie-icon-full.svg';
 
 interface LoginProps {
@@ -8,14 +19,46 @@ interface LoginProps {
   onShowRegister?: () => void;
   allowRegistration?: boolean;
   ssoEnabled?: boolean;
+  passkeysEnabled?: boolean;
+  passwordResetEnabled?: boolean;
+  // Token from a password reset link; shows the new password form
+  resetToken?: string | null;
+  onResetDone?: () => void;
   darkMode: boolean;
 }
 
-export function Login({ onLogin, onShowRegister, allowRegistration, ssoEnabled, darkMode }: LoginProps) {
+export function Login({
+  onLogin,
+  onShowRegister,
+  allowRegistration,
+  ssoEnabled,
+  passkeysEnabled,
+  passwordResetEnabled,
+  resetToken,
+  onResetDone,
+  darkMode,
+}: LoginProps) {
   const [username, setUsername] = useState('');
   const [password, setPassword] = useState('');
   const [error, setError] = useState('');
   const [loading, setLoading] = useState(false);
+  const [challenge, setChallenge] = useState<MFAChallenge | null>(null);
+  const [setup, setSetup] = useState<MFASetup | null>(null);
+  const [code, setCode] = useState('');
+  const [backupCodes, setBackupCodes] = useState<string[] | null>(null);
+  const [pendingUser, setPendingUser] = useState<User | null>(null);
+  const [showForgot, setShowForgot] = useState(false);
+  const [notice, setNotice] = useState('');
+  const [newPassword, setNewPassword] = useState('');
+  const [confirmPassword, setConfirmPassword] = useState('');
+
+  const toUser = (response: AuthResponse): User => ({
+    id: response.user.id,
+    username: response.user.username,
+    displayName: response.user.name || response.user.username,
+    email: response.user.email,
+    roles: response.user.roles,
+  });
 
   const handleSubmit = async (e: FormEvent) => {
     e.preventDefault();
@@ -36,18 +79,123 @@ export function Login({ onLogin, onShowRegister, allowRegistration, ssoEnabled,
     try {
       const response = await authLogin(username.trim(), password);
 
-      const user: User = {
-        id: response.user.id,
-        username: response.user.username,
-        displayName: response.user.name || response.user.username,
-        email: response.user.email,
-        roles: response.user.roles,
-      };
+      if ('mfa_required' in response) {
+        if (response.mfa_setup_required) {
+          setSetup(await startMFASetup(response.mfa_token));
+        }
+        setChallenge(response);
+        return;
+      }
 
-      onLogin(user);
+      onLogin(toUser(response));
     } catch (err) {
       const message = err instanceof Error ? err.message : 'Login failed';
-      setError(message === 'Invalid credentials' ? 'Invalid username or password' : message);
+      setError(message.split('\n')[0].trim() === 'Invalid credentials' ? 'Invalid username or password' : message);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const handlePasskeyLogin = async () => {
+    setError('');
+    setLoading(true);
+
+    try {
+      onLogin(toUser(await loginWithPasskey()));
+    } catch (err) {
+      if (err instanceof DOMException && err.name === 'NotAllowedError') {
+        // The user dismissed the browser prompt
+        return;
+      }
+      const message = err instanceof Error ? err.message : 'Passkey login failed';
+      setError(message.split('\n')[0].trim());
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const handleForgot = async (e: FormEvent) => {
+    e.preventDefault();
+    setError('');
+
+    if (!username.trim()) {
+      setError('Username or email is required');
+      return;
+    }
+
+    setLoading(true);
+
+    try {
+      await forgotPassword(username.trim());
+      setShowForgot(false);
+      setNotice('If an account matches, a password reset link is on its way. Check your email.');
+    } catch (err) {
+      const message = err instanceof Error ? err.message : 'Failed to request a password reset';
+      setError(message.split('\n')[0].trim());
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const handleReset = async (e: FormEvent) => {
+    e.preventDefault();
+    setError('');
+
+    if (!resetToken) return;
+    if (newPassword.length < 6) {
+      setError('Password must be at least 6 characters');
+      return;
+    }
+    if (newPassword !== confirmPassword) {
+      setError('Passwords do not match');
+      return;
+    }
+
+    setLoading(true);
+
+    try {
+      await resetPassword(resetToken, newPassword);
+      setNewPassword('');
+      setConfirmPassword('');
+      setNotice('Your password has been reset. Sign in with your new password.');
+      onResetDone?.();
+    } catch (err) {
+      const message = err instanceof Error ? err.message : 'Failed to reset password';
+      setError(message.split('\n')[0].trim());
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const handleVerify = async (e: FormEvent) => {
+    e.preventDefault();
+    setError('');
+
+    if (!challenge || !code.trim()) {
+      setError('Code is required');
+      return;
+    }
+
+    setLoading(true);
+
+    try {
+      const response = await verifyMFA(challenge.mfa_token, code.trim());
+      if (response.backup_codes?.length) {
+        // Show the backup codes once before continuing
+        setBackupCodes(response.backup_codes);
+        setPendingUser(toUser(response));
+        return;
+      }
+      onLogin(toUser(response));
+    } catch (err) {
+      const message = err instanceof Error ? err.message : 'Verification failed';
+      if (message.includes('MFA token')) {
+        // The challenge expired; start over
+        setChallenge(null);
+        setSetup(null);
+        setCode('');
+      }
+      setError(message.split('\n')[0].trim());
     } finally {
       setLoading(false);
     }
@@ -58,7 +206,7 @@ export function Login({ onLogin, onShowRegister, allowRegistration, ssoEnabled,
   const inputText = darkMode ? 'text-gray-100 placeholder-gray-400' : 'text-gray-900 placeholder-gray-500';
 
   return (
-    <div className="min-h-screen flex items-center justify-center bg-brand-primary px-4 relative overflow-hidden">
+    <div className="min-h-screen flex items-center justify-center bg-brand-primary bg-[image:var(--branding-background)] bg-cover bg-center px-4 relative overflow-hidden">
       {/* Aurora background ribbons */}
       <div
         className="absolute top-[-20%] left-[-25%] w-[900px] h-[350px] rounded-full bg-brand-accent/40 blur-[80px]"
@@ -90,52 +238,241 @@ export function Login({ onLogin, onShowRegister, allowRegistration, ssoEnabled,
           Sign in to access your applications
         </p>
 
-        <form onSubmit={handleSubmit} className="space-y-4">
-          <div>
-            <label htmlFor="username" className={`block text-sm font-medium mb-1 ${textColor}`}>
-              Username
-            </label>
-            <input
-              id="username"
-              type="text"
-              value={username}
-              onChange={(e) => setUsername(e.target.value)}
-              className={`w-full px-4 py-2 rounded-lg border shadow-sm ${inputBg} ${inputText} focus:outline-none focus:ring-2 focus:ring-brand-accent`}
-              placeholder="Enter your username"
-              autoComplete="username"
-              autoFocus
-            />
+        {backupCodes ? (
+          <div className="space-y-4">
+            <p className={`text-sm ${textColor}`}>
+              Save these backup codes somewhere safe. Each one signs you in once if you lose your authenticator, and they will not be shown again.
+            </p>
+            <ul className={`grid grid-cols-2 gap-2 font-mono text-sm ${textColor}`}>
+              {backupCodes.map((c) => (
+                <li key={c}>{c}</li>
+              ))}
+            </ul>
+            <button
+              type="button"
+              onClick={() => pendingUser && onLogin(pendingUser)}
+              className="w-full py-2 px-4 bg-brand-accent text-white font-medium rounded-lg hover:bg-brand-primary transition-colors shadow-md hover:shadow-lg"
+            >
+              Continue
+            </button>
           </div>
+        ) : challenge ? (
+          <form onSubmit={handleVerify} className="space-y-4">
+            {setup ? (
+              <div className={`text-sm space-y-2 ${textColor}`}>
+                <p>Your account requires two-factor authentication. Add this key to your authenticator app, then enter the code it shows.</p>
+                <p className="font-mono break-all select-all">{setup.secret}</p>
+                <a href={setup.otpauth_url} className="text-brand-accent font-medium">
+                  Open in authenticator app
+                </a>
+              </div>
+            ) : (
+              <p className={`text-sm ${textColor}`}>
+                Enter the code from your authenticator app, or one of your backup codes.
+              </p>
+            )}
 
-          <div>
-            <label htmlFor="password" className={`block text-sm font-medium mb-1 ${textColor}`}>
-              Password
-            </label>
-            <input
-              id="password"
-              type="password"
-              value={password}
-              onChange={(e) => setPassword(e.target.value)}
-              className={`w-full px-4 py-2 rounded-lg border shadow-sm ${inputBg} ${inputText} focus:outline-none focus:ring-2 focus:ring-brand-accent`}
-              placeholder="Enter your password"
-              autoComplete="current-password"
-            />
-          </div>
+            <div>
+              <label htmlFor="mfa-code" className={`block text-sm font-medium mb-1 ${textColor}`}>
+                Code
+              </label>
+              <input
+                id="mfa-code"
+                type="text"
+                value={code}
+                onChange={(e) => setCode(e.target.value)}
+                className={`w-full px-4 py-2 rounded-lg border shadow-sm ${inputBg} ${inputText} focus:outline-none focus:ring-2 focus:ring-brand-accent`}
+                placeholder="123456"
+                autoComplete="one-time-code"
+                inputMode="numeric"
+                autoFocus
+              />
+            </div>
+
+            {error && (
+              <p className="text-red-500 text-sm">{error}</p>
+            )}
+
+            <button
+              type="submit"
+              disabled={loading}
+              className="w-full py-2 px-4 bg-brand-accent text-white font-medium rounded-lg hover:bg-brand-primary transition-colors shadow-md hover:shadow-lg disabled:opacity-50 disabled:cursor-not-allowed"
+            >
+              {loading ? 'Verifying...' : 'Verify'}
+            </button>
+          </form>
+        ) : resetToken ? (
+          <form onSubmit={handleReset} className="space-y-4">
+            <p className={`text-sm ${textColor}`}>Choose a new password for your account.</p>
+
+            <div>
+              <label htmlFor="new-password" className={`block text-sm font-medium mb-1 ${textColor}`}>
+                New password
+              </label>
+              <input
+                id="new-password"
+                type="password"
+                value={newPassword}
+                onChange={(e) => setNewPassword(e.target.value)}
+                className={`w-full px-4 py-2 rounded-lg border shadow-sm ${inputBg} ${inputText} focus:outline-none focus:ring-2 focus:ring-brand-accent`}
+                autoComplete="new-password"
+                autoFocus
+              />
+            </div>
+
+            <div>
+              <label htmlFor="confirm-password" className={`block text-sm font-medium mb-1 ${textColor}`}>
+                Confirm password
+              </label>
+              <input
+                id="confirm-password"
+                type="password"
+                value={confirmPassword}
+                onChange={(e) => setConfirmPassword(e.target.value)}
+                className={`w-full px-4 py-2 rounded-lg border shadow-sm ${inputBg} ${inputText} focus:outline-none focus:ring-2 focus:ring-brand-accent`}
+                autoComplete="new-password"
+              />
+            </div>
+
+            {error && (
+              <p className="text-red-500 text-sm">{error}</p>
+            )}
 
-          {error && (
-            <p className="text-red-500 text-sm">{error}</p>
-          )}
+            <button
+              type="submit"
+              disabled={loading}
+              className="w-full py-2 px-4 bg-brand-accent text-white font-medium rounded-lg hover:bg-brand-primary transition-colors shadow-md hover:shadow-lg disabled:opacity-50 disabled:cursor-not-allowed"
+            >
+              {loading ? 'Saving...' : 'Reset Password'}
+            </button>
+          </form>
+        ) : showForgot ? (
+          <form onSubmit={handleForgot} className="space-y-4">
+            <p className={`text-sm ${textColor}`}>
+              Enter your username or email address and we will email you a link to reset your password.
+            </p>
+
+            <div>
+              <label htmlFor="forgot-username" className={`block text-sm font-medium mb-1 ${textColor}`}>
+                Username or email
+              </label>
+              <input
+                id="forgot-username"
+                type="text"
+                value={username}
+                onChange={(e) => setUsername(e.target.value)}
+                className={`w-full px-4 py-2 rounded-lg border shadow-sm ${inputBg} ${inputText} focus:outline-none focus:ring-2 focus:ring-brand-accent`}
+                autoComplete="username"
+                autoFocus
+              />
+            </div>
+
+            {error && (
+              <p className="text-red-500 text-sm">{error}</p>
+            )}
+
+            <button
+              type="submit"
+              disabled={loading}
+              className="w-full py-2 px-4 bg-brand-accent text-white font-medium rounded-lg hover:bg-brand-primary transition-colors shadow-md hover:shadow-lg disabled:opacity-50 disabled:cursor-not-allowed"
+            >
+              {loading ? 'Sending...' : 'Send Reset Link'}
+            </button>
+            <button
+              type="button"
+              onClick={() => {
+                setShowForgot(false);
+                setError('');
+              }}
+              className={`w-full text-sm ${darkMode ? 'text-gray-400 hover:text-gray-300' : 'text-gray-600 hover:text-gray-800'}`}
+            >
+              Back to sign in
+            </button>
+          </form>
+        ) : (
+          <form onSubmit={handleSubmit} className="space-y-4">
+            {notice && (
+              <p className="text-green-600 text-sm">{notice}</p>
+            )}
 
+            <div>
+              <label htmlFor="username" className={`block text-sm font-medium mb-1 ${textColor}`}>
+                Username
+              </label>
+              <input
+                id="username"
+                type="text"
+                value={username}
+                onChange={(e) => setUsername(e.target.value)}
+                className={`w-full px-4 py-2 rounded-lg border shadow-sm ${inputBg} ${inputText} focus:outline-none focus:ring-2 focus:ring-brand-accent`}
+                placeholder="Enter your username"
+                autoComplete="username"
+                autoFocus
+              />
+            </div>
+
+            <div>
+              <label htmlFor="password" className={`block text-sm font-medium mb-1 ${textColor}`}>
+                Password
+              </label>
+              <input
+                id="password"
+                type="password"
+                value={password}
+                onChange={(e) => setPassword(e.target.value)}
+                className={`w-full px-4 py-2 rounded-lg border shadow-sm ${inputBg} ${inputText} focus:outline-none focus:ring-2 focus:ring-brand-accent`}
+                placeholder="Enter your password"
+                autoComplete="current-password"
+              />
+            </div>
+
+            {error && (
+              <p className="text-red-500 text-sm">{error}</p>
+            )}
+
+            <button
+              type="submit"
+              disabled={loading}
+              className="w-full py-2 px-4 bg-brand-accent text-white font-medium rounded-lg hover:bg-brand-primary transition-colors shadow-md hover:shadow-lg disabled:opacity-50 disabled:cursor-not-allowed"
+            >
+              {loading ? 'Signing in...' : 'Sign In'}
+            </button>
+
+            {passwordResetEnabled && (
+              <button
+                type="button"
+                onClick={() => {
+                  setShowForgot(true);
+                  setError('');
+                  setNotice('');
+                }}
+                className="w-full text-sm text-brand-accent font-medium"
+              >
+                Forgot password?
+              </button>
+            )}
+          </form>
+        )}
+
+        {passkeysEnabled && passkeysSupported() && !challenge && !backupCodes && !resetToken && !showForgot && (
           <button
-            type="submit"
+            type="button"
+            onClick={handlePasskeyLogin}
             disabled={loading}
-            className="w-full py-2 px-4 bg-brand-accent text-white font-medium rounded-lg hover:bg-brand-primary transition-colors shadow-md hover:shadow-lg disabled:opacity-50 disabled:cursor-not-allowed"
+            className={`mt-4 w-full flex items-center justify-center gap-2 py-2 px-4 rounded-lg border font-medium transition-colors disabled:opacity-50 disabled:cursor-not-allowed ${
+              darkMode
+                ? 'border-gray-600 text-gray-200 hover:bg-gray-700'
+                : 'border-gray-300 text-gray-700 hover:bg-gray-50'
+            }`}
           >
-            {loading ? 'Signing in...' : 'Sign In'}
+            <svg className="w-5 h-5" fill="none" stroke="currentColor" viewBox="0 0 24 24">
+              <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M15 7a2 2 0 012 2m4 0a6 6 0 01-7.743 5.743L11 17H9v2H7v2H4a1 1 0 01-1-1v-2.586a1 1 0 01.293-.707l5.964-5.964A6 6 0 1121 9z" />
+            </svg>
+            Sign in with a passkey
           </button>
-        </form>
+        )}
 
-        {ssoEnabled && (
+        {ssoEnabled && !resetToken && !showForgot && (
           <div className="mt-4">
             <div className="relative">
               <div className="absolute inset-0 flex items-center">
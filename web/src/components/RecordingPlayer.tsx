@@ -1,6 +1,6 @@
 import { useEffect, useRef, useState, useCallback } from 'react';
 import type RFB from '@novnc/novnc/lib/rfb.js';
-import { fetchWithAuth } from '../services/auth';
+import { fetchRecording } from '../services/auth';
 
 interface RecordingPlayerProps {
   recordingId: string;
@@ -228,11 +228,7 @@ export function RecordingPlayer({ recordingId, onClose, darkMode }: RecordingPla
 
     async function load() {
       try {
-        const response = await fetchWithAuth(`/api/recordings/${recordingId}/download`);
-        if (!response.ok) {
-          throw new Error('Failed to download recording');
-        }
-        const rawBuffer = await response.arrayBuffer();
+        const rawBuffer = await fetchRecording(recordingId);
         if (cancelled) return;
 
         // Check for VREC header
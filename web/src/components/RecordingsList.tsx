@@ -1,5 +1,5 @@
-import { useState, useEffect, useCallback } from 'react';
-import { listRecordings, downloadRecording, deleteRecording } from '../services/auth';
+import { useState, useEffect, useCallback, useRef } from 'react';
+import { listRecordings, downloadRecording, deleteRecording, getRecordingStreamURL } from '../services/auth';
 import type { Recording, RecordingStatus } from '../types';
 
 interface RecordingsListProps {
@@ -38,6 +38,11 @@ function formatDuration(seconds: number): string {
   return `${m}:${s.toString().padStart(2, '0')}`;
 }
 
+// Browsers can play converted MP4s and WebM uploads, but not raw .vncrec
+function isPlayable(recording: Recording): boolean {
+  return recording.status === 'ready' && (!!recording.video_path || recording.format === 'webm');
+}
+
 function formatDate(dateStr: string): string {
   const date = new Date(dateStr);
   if (isNaN(date.getTime())) return dateStr;
@@ -54,6 +59,10 @@ export function RecordingsList({ isOpen, onClose, darkMode }: RecordingsListProp
   const [recordings, setRecordings] = useState<Recording[]>([]);
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState('');
+  const [playing, setPlaying] = useState<{ recording: Recording; url: string } | null>(null);
+  const videoRef = useRef<HTMLVideoElement>(null);
+  const resumeAtRef = useRef(0);
+  const retriedRef = useRef(false);
 
   const loadRecordings = useCallback(async (showSpinner = true) => {
     if (showSpinner) setLoading(true);
@@ -103,6 +112,36 @@ export function RecordingsList({ isOpen, onClose, darkMode }: RecordingsListProp
     }
   };
 
+  const handlePlay = async (recording: Recording) => {
+    try {
+      resumeAtRef.current = 0;
+      retriedRef.current = false;
+      setPlaying({ recording, url: await getRecordingStreamURL(recording.id) });
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to play recording');
+    }
+  };
+
+  // Stream URLs expire; when one does mid-playback, get a fresh one and
+  // resume where playback stopped
+  const handleVideoError = async () => {
+    if (!playing || !videoRef.current) return;
+    if (retriedRef.current) {
+      setError('Failed to play recording');
+      setPlaying(null);
+      return;
+    }
+    retriedRef.current = true;
+    resumeAtRef.current = videoRef.current.currentTime;
+    try {
+      const url = await getRecordingStreamURL(playing.recording.id);
+      setPlaying((prev) => (prev && prev.recording.id === playing.recording.id ? { ...prev, url } : prev));
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to play recording');
+      setPlaying(null);
+    }
+  };
+
   const handleDelete = async (recording: Recording) => {
     if (!confirm(`Delete recording "${recording.filename}"?`)) return;
     try {
@@ -215,6 +254,15 @@ export function RecordingsList({ isOpen, onClose, darkMode }: RecordingsListProp
                         </td>
                         <td className="py-3 text-right">
                           <div className="flex items-center justify-end gap-2">
+                            {isPlayable(recording) && (
+                              <button
+                                onClick={() => handlePlay(recording)}
+                                className="text-brand-accent hover:text-brand-primary text-sm"
+                                title="Play"
+                              >
+                                Play
+                              </button>
+                            )}
                             {recording.status === 'ready' && (
                               <button
                                 onClick={() => handleDownload(recording)}
@@ -243,6 +291,38 @@ export function RecordingsList({ isOpen, onClose, darkMode }: RecordingsListProp
         </div>
       </div>
 
+      {playing && (
+        <div className="fixed inset-0 z-60 flex items-center justify-center bg-black/80" onClick={() => setPlaying(null)}>
+          <div className="w-full max-w-4xl mx-4" onClick={(e) => e.stopPropagation()}>
+            <div className="flex items-center justify-between mb-2 text-white">
+              <span className="text-sm">{playing.recording.filename}</span>
+              <button
+                onClick={() => setPlaying(null)}
+                className="p-1 rounded hover:bg-white/10"
+                aria-label="Close player"
+              >
+                <svg className="w-5 h-5" fill="none" stroke="currentColor" viewBox="0 0 24 24">
+                  <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M6 18L18 6M6 6l12 12" />
+                </svg>
+              </button>
+            </div>
+            <video
+              ref={videoRef}
+              src={playing.url}
+              controls
+              autoPlay
+              className="w-full rounded-lg bg-black"
+              onLoadedMetadata={(e) => {
+                retriedRef.current = false;
+                if (resumeAtRef.current > 0) {
+                  e.currentTarget.currentTime = resumeAtRef.current;
+                }
+              }}
+              onError={handleVideoError}
+            />
+          </div>
+        </div>
+      )}
     </div>
   );
 }
@@ -57,6 +57,12 @@ export function Register({ onRegister, onBackToLogin, darkMode }: RegisterProps)
         displayName.trim() || undefined
       );
 
+      // Accounts that must enroll in MFA finish signing in from the login page
+      if (!response.access_token) {
+        onBackToLogin();
+        return;
+      }
+
       const user: User = {
         id: response.user.id,
         username: response.user.username,
@@ -17,6 +17,7 @@ const STATUS_COLORS: Record<SessionStatus, { bg: string; text: string; pulse?: b
   failed: { bg: 'bg-red-500', text: 'text-red-500' },
   stopped: { bg: 'bg-gray-400', text: 'text-gray-400' },
   expired: { bg: 'bg-gray-400', text: 'text-gray-400' },
+  hibernated: { bg: 'bg-indigo-400', text: 'text-indigo-400' },
 };
 
 const STATUS_LABELS: Record<SessionStatus, string> = {
@@ -25,17 +26,20 @@ const STATUS_LABELS: Record<SessionStatus, string> = {
   failed: 'Failed',
   stopped: 'Stopped',
   expired: 'Expired',
+  hibernated: 'Hibernated',
 };
 
 function SessionCard({
   session,
   onReconnect,
+  onResume,
   onTerminate,
   onShare,
   darkMode,
 }: {
   session: Session;
   onReconnect: () => void;
+  onResume: () => void;
   onTerminate: () => void;
   onShare?: () => void;
   darkMode: boolean;
@@ -43,6 +47,7 @@ function SessionCard({
   const statusConfig = STATUS_COLORS[session.status];
   const isRunning = session.status === 'running';
   const isCreating = session.status === 'creating';
+  const isHibernated = session.status === 'hibernated';
   const canReconnect = isRunning;
   const isShared = session.is_shared;
 
@@ -106,6 +111,13 @@ function SessionCard({
           >
             {isShared ? 'Connect' : 'Reconnect'}
           </button>
+        ) : isHibernated && !isShared ? (
+          <button
+            onClick={onResume}
+            className="flex-1 px-3 py-2 text-sm font-medium text-white bg-brand-accent hover:bg-brand-primary rounded-xl transition-colors"
+          >
+            Resume
+          </button>
         ) : isCreating ? (
           <button
             disabled
@@ -151,7 +163,7 @@ export function SessionManager({
   onReconnect,
   darkMode,
 }: SessionManagerProps) {
-  const { sessions, isLoading, error, refresh, terminateSession } = useSessions(isOpen);
+  const { sessions, isLoading, error, refresh, terminateSession, resumeSession } = useSessions(isOpen);
   const [shareSessionId, setShareSessionId] = useState<string | null>(null);
 
   // Handle escape key
@@ -183,6 +195,12 @@ export function SessionManager({
     await terminateSession(session.id);
   };
 
+  const handleResume = async (session: Session) => {
+    if (await resumeSession(session.id)) {
+      onReconnect(session.app_id, session.id);
+    }
+  };
+
   if (!isOpen) return null;
 
   const bgColor = darkMode ? 'bg-gray-900' : 'bg-gray-50';
@@ -191,9 +209,10 @@ export function SessionManager({
   const mutedText = darkMode ? 'text-gray-400' : 'text-gray-600';
   const borderColor = darkMode ? 'border-gray-700' : 'border-gray-200';
 
-  // Filter to show only active sessions (creating or running)
+  // Filter to show only active sessions (creating or running) and
+  // hibernated ones that can be resumed
   const activeSessions = sessions.filter(
-    (s) => s.status === 'creating' || s.status === 'running'
+    (s) => s.status === 'creating' || s.status === 'running' || s.status === 'hibernated'
   );
 
   return (
@@ -289,6 +308,7 @@ export function SessionManager({
                   key={session.id}
                   session={session}
                   onReconnect={() => onReconnect(session.app_id, session.id)}
+                  onResume={() => handleResume(session)}
                   onTerminate={() => handleTerminate(session)}
                   onShare={() => setShareSessionId(session.id)}
                   darkMode={darkMode}
@@ -2,7 +2,9 @@ import { useEffect, useState, useCallback, useMemo } from 'react';
 import { useSession } from '../hooks/useSession';
 import { SessionViewer } from './SessionViewer';
 import { ShareSessionDialog } from './ShareSessionDialog';
-import type { Application, ClipboardPolicy } from '../types';
+import { LockOverlay } from './LockOverlay';
+import { WelcomeOverlay } from './WelcomeOverlay';
+import type { Application, ClipboardPolicy, SessionWelcome } from '../types';
 
 interface SessionPageProps {
   app: Application;
@@ -13,22 +15,26 @@ interface SessionPageProps {
   viewOnly?: boolean;         // Force read-only mode (for shared sessions)
   ownerUsername?: string;      // Owner's username (for shared sessions)
   sharePermission?: string;   // "read_only" or "read_write" (for shared sessions)
+  welcomes?: SessionWelcome[]; // Welcome messages pushed over the session event stream
+  onDismissWelcome?: (sessionId: string) => void;
 }
 
 type ConnectionState = 'idle' | 'creating' | 'waiting' | 'connecting' | 'connected' | 'error';
 
-export function SessionPage({ app, onClose, darkMode, sessionId, clipboardPolicy = 'bidirectional', viewOnly = false, ownerUsername, sharePermission }: SessionPageProps) {
-  const { session, isLoading, error, createSession, reconnectToSession, terminateSession } = useSession();
+export function SessionPage({ app, onClose, darkMode, sessionId, clipboardPolicy, viewOnly = false, ownerUsername, sharePermission, welcomes = [], onDismissWelcome }: SessionPageProps) {
+  const { session, isLoading, error, createSession, reconnectToSession, refreshSession, terminateSession } = useSession();
   const [viewerConnectionState, setViewerConnectionState] = useState<'idle' | 'connected' | 'error'>('idle');
   const [viewerErrorMessage, setViewerErrorMessage] = useState('');
   const [sessionCreationStarted, setSessionCreationStarted] = useState(false);
   const [showShareDialog, setShowShareDialog] = useState(false);
   const isShared = viewOnly || !!ownerUsername;
+  const welcome = session ? welcomes.find((w) => w.session_id === session.id) : undefined;
+  const isLocked = !isShared && !!session?.locked_at;
 
   // Handle close - defined early so it can be used in effects below
   const handleClose = useCallback(async () => {
     // Only terminate if not already in a terminal state AND this is not a reconnection AND not a shared session
-    const terminalStates = ['stopped', 'expired', 'failed'];
+    const terminalStates = ['stopped', 'expired', 'failed', 'hibernated'];
     if (session && !terminalStates.includes(session.status) && !sessionId && !isShared) {
       await terminateSession();
     }
@@ -82,6 +88,9 @@ export function SessionPage({ app, onClose, darkMode, sessionId, clipboardPolicy
 
   // Derive connection state and status message from session
   const { connectionState, statusMessage } = useMemo((): { connectionState: ConnectionState; statusMessage: string } => {
+    if (session?.status === 'running' && session.substatus === 'rescheduling') {
+      return { connectionState: 'waiting', statusMessage: 'Moving your session to another server. Reconnecting...' };
+    }
     if (viewerConnectionState === 'error') {
       return { connectionState: 'error', statusMessage: viewerErrorMessage || 'Connection error' };
     }
@@ -118,17 +127,35 @@ export function SessionPage({ app, onClose, darkMode, sessionId, clipboardPolicy
     setViewerConnectionState('connected');
   }, []);
 
-  const handleViewerDisconnect = useCallback((clean: boolean) => {
-    if (!clean && viewerConnectionState === 'connected') {
-      setViewerConnectionState('error');
-      setViewerErrorMessage('Connection lost');
+  // A lost connection may mean the session's pod was evicted. The session
+  // then moves to a new pod, and the viewer is shown again once it is ready.
+  const handleConnectionLost = useCallback(async (message: string) => {
+    const updated = await refreshSession();
+    // A locked session shows the lock overlay instead of an error
+    if (updated?.status === 'running' && (updated.substatus === 'rescheduling' || updated.locked_at)) {
+      setViewerConnectionState('idle');
+      return;
     }
-  }, [viewerConnectionState]);
-
-  const handleViewerError = useCallback((message: string) => {
     setViewerConnectionState('error');
     setViewerErrorMessage(message);
-  }, []);
+  }, [refreshSession]);
+
+  const handleViewerDisconnect = useCallback(async (clean: boolean) => {
+    if (viewerConnectionState !== 'connected') return;
+    if (!clean) {
+      handleConnectionLost('Connection lost');
+      return;
+    }
+    // The server closes the stream cleanly when it locks the session
+    const updated = await refreshSession();
+    if (updated?.locked_at) {
+      setViewerConnectionState('idle');
+    }
+  }, [viewerConnectionState, handleConnectionLost, refreshSession]);
+
+  const handleViewerError = useCallback((message: string) => {
+    handleConnectionLost(message);
+  }, [handleConnectionLost]);
 
   const bgColor = darkMode ? 'bg-gray-900' : 'bg-gray-100';
   const headerBgColor = darkMode ? 'bg-gray-800' : 'bg-white';
@@ -279,7 +306,7 @@ export function SessionPage({ app, onClose, darkMode, sessionId, clipboardPolicy
         )}
 
         {/* SessionViewer - renders when session has a viewer URL */}
-        {session && (session.websocket_url || session.guacamole_url || session.proxy_url) && connectionState !== 'error' && (
+        {session && (session.websocket_url || session.guacamole_url || session.proxy_url) && connectionState !== 'error' && !isLocked && (
           <SessionViewer
             session={session}
             app={app}
@@ -293,6 +320,25 @@ export function SessionPage({ app, onClose, darkMode, sessionId, clipboardPolicy
         )}
       </div>
 
+      {/* Sign-in prompt while the session is locked for inactivity */}
+      {isLocked && session && (
+        <LockOverlay
+          sessionId={session.id}
+          appName={app.name}
+          darkMode={darkMode}
+          onUnlock={refreshSession}
+        />
+      )}
+
+      {/* Welcome message, shown over the stream until dismissed */}
+      {welcome && !isLocked && (
+        <WelcomeOverlay
+          welcome={welcome}
+          darkMode={darkMode}
+          onDismiss={() => onDismissWelcome?.(welcome.session_id)}
+        />
+      )}
+
       {/* Share dialog */}
       {showShareDialog && session && (
         <ShareSessionDialog
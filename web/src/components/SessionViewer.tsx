@@ -29,13 +29,15 @@ export function SessionViewer({
   session,
   app,
   darkMode,
-  clipboardPolicy = 'bidirectional',
+  clipboardPolicy: clipboardPolicyProp,
   viewOnly = false,
   showStats: showStatsProp = false,
   onConnect,
   onDisconnect,
   onError,
 }: SessionViewerProps) {
+  // The server drops what the app's policy blocks; match it in the viewer
+  const clipboardPolicy = clipboardPolicyProp ?? session.clipboard_policy ?? 'bidirectional';
   const viewerContainerRef = useRef<HTMLDivElement>(null);
   const wsRef = useRef<WebSocket | null>(null);
   const [viewerState, setViewerState] = useState<ViewerState>('connecting');
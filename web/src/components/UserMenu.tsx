@@ -10,6 +10,7 @@ interface UserMenuProps {
   onOpenDocs: () => void;
   onOpenAdmin: () => void;
   onOpenAuditLog: () => void;
+  onAddPasskey?: () => void;
   onLogout: () => void;
 }
 
@@ -22,6 +23,7 @@ export function UserMenu({
   onOpenDocs,
   onOpenAdmin,
   onOpenAuditLog,
+  onAddPasskey,
   onLogout,
 }: UserMenuProps) {
   const [isOpen, setIsOpen] = useState(false);
@@ -129,6 +131,19 @@ export function UserMenu({
                 <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M10 6H6a2 2 0 00-2 2v10a2 2 0 002 2h10a2 2 0 002-2v-4M14 4h6m0 0v6m0-6L10 14" />
               </svg>
             </button>
+
+            {/* Passkeys */}
+            {onAddPasskey && (
+              <button
+                onClick={() => handleAction(onAddPasskey)}
+                className="w-full flex items-center gap-3 px-4 py-2.5 text-sm text-gray-700 dark:text-gray-300 hover:bg-gray-50 dark:hover:bg-gray-700/50 transition-colors"
+              >
+                <svg className="w-4 h-4" fill="none" stroke="currentColor" viewBox="0 0 24 24">
+                  <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M15 7a2 2 0 012 2m4 0a6 6 0 01-7.743 5.743L11 17H9v2H7v2H4a1 1 0 01-1-1v-2.586a1 1 0 01.293-.707l5.964-5.964A6 6 0 1121 9z" />
+                </svg>
+                <span>Add Passkey</span>
+              </button>
+            )}
           </div>
 
           {/* Admin section */}
@@ -312,6 +312,7 @@ export function VNCViewer({
 
         const rfb = new RFBClass(containerRef.current, fullWsUrl.current, {
           shared: true,
+          wsProtocols: ['binary'],
         });
 
         // Restore original WebSocket immediately
@@ -75,7 +75,7 @@ export function WebProxyModal({ app, isOpen, onClose, darkMode }: WebProxyModalP
   // Handle close
   const handleClose = useCallback(async () => {
     // Only terminate if not already in a terminal state
-    const terminalStates = ['stopped', 'expired', 'failed'];
+    const terminalStates = ['stopped', 'expired', 'failed', 'hibernated'];
     if (session && !terminalStates.includes(session.status)) {
       await terminateSession();
     }
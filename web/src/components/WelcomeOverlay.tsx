@@ -0,0 +1,49 @@
+import { renderMarkdown } from '../utils/markdown';
+import type { SessionWelcome } from '../types';
+
+interface WelcomeOverlayProps {
+  welcome: SessionWelcome;
+  darkMode: boolean;
+  onDismiss: () => void;
+}
+
+export function WelcomeOverlay({ welcome, darkMode, onDismiss }: WelcomeOverlayProps) {
+  const bgColor = darkMode ? 'bg-gray-800' : 'bg-white';
+  const textColor = darkMode ? 'text-gray-100' : 'text-gray-900';
+  const borderColor = darkMode ? 'border-gray-700' : 'border-gray-200';
+
+  return (
+    <>
+      {/* Backdrop */}
+      <div className="fixed inset-0 bg-black/40 backdrop-blur-sm z-50" />
+
+      {/* Dialog */}
+      <div
+        role="dialog"
+        aria-modal="true"
+        aria-labelledby="session-welcome-title"
+        className={`fixed top-1/2 left-1/2 -translate-x-1/2 -translate-y-1/2 z-50 w-full max-w-lg ${bgColor} rounded-xl shadow-2xl border ${borderColor}`}
+      >
+        <div className={`px-5 py-4 border-b ${borderColor}`}>
+          <h3 id="session-welcome-title" className={`text-lg font-semibold ${textColor}`}>
+            Welcome to {welcome.app_name || 'your session'}
+          </h3>
+        </div>
+
+        <div className={`px-5 py-4 space-y-3 max-h-[60vh] overflow-y-auto ${textColor}`}>
+          {renderMarkdown(welcome.message)}
+        </div>
+
+        <div className={`flex justify-end px-5 py-4 border-t ${borderColor}`}>
+          <button
+            onClick={onDismiss}
+            autoFocus
+            className="px-4 py-2 rounded-lg bg-brand-accent text-white hover:opacity-90 transition-opacity"
+          >
+            Got it
+          </button>
+        </div>
+      </div>
+    </>
+  );
+}
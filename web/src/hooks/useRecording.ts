@@ -15,6 +15,10 @@ interface UseRecordingReturn {
 
 const VREC_MAGIC = 0x56524543; // "VREC"
 
+/** How often captured frames are uploaded while recording, so a recording survives a crash. */
+const CHUNK_INTERVAL_MS = 10_000;
+const CHUNK_UPLOAD_ATTEMPTS = 3;
+
 /**
  * Binary frame format (per message):
  *   fromClient: 1 byte  (0 = server→client, 1 = client→server)
@@ -118,6 +122,11 @@ export function useRecording(): UseRecordingReturn {
   const timerRef = useRef<ReturnType<typeof setInterval> | null>(null);
   const recordStartTimeRef = useRef<number>(0); // Date.now() when recording started
 
+  // Chunked upload state
+  const chunkTimerRef = useRef<ReturnType<typeof setInterval> | null>(null);
+  const chunkSeqRef = useRef(0);
+  const uploadsRef = useRef<Promise<void>>(Promise.resolve());
+
   // Detach capture hooks from the WebSocket
   const detachCapture = useCallback(() => {
     const ws = wsRef.current;
@@ -178,6 +187,51 @@ export function useRecording(): UseRecordingReturn {
     attachedRef.current = true;
   }, [detachCapture]);
 
+  /** Upload the frames captured since the last chunk. Uploads run one at a time, in order. */
+  const flushChunk = useCallback(() => {
+    const frames = chunksRef.current;
+    if (frames.length === 0) return;
+    chunksRef.current = [];
+
+    const seq = chunkSeqRef.current++;
+    const parts: ArrayBuffer[] = seq === 0
+      ? [buildVrecHeader(screenWidthRef.current, screenHeightRef.current), ...frames]
+      : frames;
+    const blob = new Blob(parts, { type: 'application/octet-stream' });
+    const recordingId = recordingIdRef.current;
+    const sessionId = sessionIdRef.current;
+    const elapsedSeconds = (Date.now() - recordStartTimeRef.current) / 1000;
+
+    uploadsRef.current = uploadsRef.current.then(async () => {
+      for (let attempt = 1; attempt <= CHUNK_UPLOAD_ATTEMPTS; attempt++) {
+        const formData = new FormData();
+        formData.append('recording_id', recordingId);
+        formData.append('seq', String(seq));
+        formData.append('duration', elapsedSeconds.toFixed(1));
+        formData.append('file', blob, `${recordingId}-${seq}.part`);
+        try {
+          const res = await fetch(`/api/sessions/${sessionId}/recording/chunk`, {
+            method: 'POST',
+            headers: {
+              'Authorization': `Bearer ${getAccessToken()}`,
+            },
+            body: formData,
+          });
+          if (res.ok) return;
+          // Client errors will not succeed on retry
+          if (res.status < 500) {
+            setError((await res.text()) || 'Failed to upload recording');
+            return;
+          }
+        } catch {
+          // Network error: retry
+        }
+        await new Promise((resolve) => setTimeout(resolve, attempt * 1000));
+      }
+      setError('Failed to upload part of the recording');
+    });
+  }, []);
+
   const startRecording = useCallback(async (sessionId: string, screenWidth?: number, screenHeight?: number) => {
     setError(null);
     sessionIdRef.current = sessionId;
@@ -232,21 +286,31 @@ export function useRecording(): UseRecordingReturn {
       setIsRecording(true);
       setDuration(0);
       recordStartTimeRef.current = Date.now();
+      chunkSeqRef.current = 0;
+      uploadsRef.current = Promise.resolve();
 
       // Update duration counter
       timerRef.current = setInterval(() => {
         setDuration(Math.floor((Date.now() - recordStartTimeRef.current) / 1000));
       }, 1000);
+
+      // Upload captured frames periodically; the server finalizes whatever
+      // arrived if this page goes away before the recording is stopped.
+      chunkTimerRef.current = setInterval(flushChunk, CHUNK_INTERVAL_MS);
     } catch (err) {
       setError(err instanceof Error ? err.message : 'Failed to start recording');
     }
-  }, []);
+  }, [flushChunk]);
 
   const stopRecording = useCallback(async () => {
     if (timerRef.current) {
       clearInterval(timerRef.current);
       timerRef.current = null;
     }
+    if (chunkTimerRef.current) {
+      clearInterval(chunkTimerRef.current);
+      chunkTimerRef.current = null;
+    }
 
     const recordingId = recordingIdRef.current;
     const sessionId = sessionIdRef.current;
@@ -278,44 +342,25 @@ export function useRecording(): UseRecordingReturn {
       // Non-fatal: upload will still work
     }
 
-    // Build VREC header
-    const vrecHeader = buildVrecHeader(screenWidthRef.current, screenHeightRef.current);
-
-    // Assemble binary blob: VREC header + all captured frames
-    const totalFrameSize = chunksRef.current.reduce((sum, buf) => sum + buf.byteLength, 0);
-    const combined = new Uint8Array(vrecHeader.byteLength + totalFrameSize);
-    combined.set(new Uint8Array(vrecHeader), 0);
-    let offset = vrecHeader.byteLength;
-    for (const buf of chunksRef.current) {
-      combined.set(new Uint8Array(buf), offset);
-      offset += buf.byteLength;
-    }
-
-    // Don't clear chunks — keep capturing for potential future recordings
-    // But reset chunks for the next recording
-    chunksRef.current = [];
+    // Upload the remaining frames and wait for every chunk to arrive
+    flushChunk();
     // Restart the capture timer so a subsequent recording has fresh timestamps
     captureStartRef.current = performance.now();
+    await uploadsRef.current;
 
-    const blob = new Blob([combined], { type: 'application/octet-stream' });
-
-    if (blob.size <= vrecHeader.byteLength) {
+    if (chunkSeqRef.current === 0) {
       setError('No recording data captured');
       return;
     }
 
-    const formData = new FormData();
-    formData.append('recording_id', recordingId);
-    formData.append('duration', elapsedSeconds.toFixed(1));
-    formData.append('file', blob, `${recordingId}.vncrec`);
-
     try {
-      const res = await fetch(`/api/sessions/${sessionId}/recording/upload`, {
+      const res = await fetch(`/api/sessions/${sessionId}/recording/complete`, {
         method: 'POST',
         headers: {
           'Authorization': `Bearer ${getAccessToken()}`,
+          'Content-Type': 'application/json',
         },
-        body: formData,
+        body: JSON.stringify({ recording_id: recordingId, duration: Number(elapsedSeconds.toFixed(1)) }),
       });
 
       if (!res.ok) {
@@ -325,7 +370,7 @@ export function useRecording(): UseRecordingReturn {
     } catch (err) {
       setError(err instanceof Error ? err.message : 'Failed to upload recording');
     }
-  }, []);
+  }, [flushChunk]);
 
   return { isRecording, duration, attachWebSocket, startRecording, stopRecording, error };
 }
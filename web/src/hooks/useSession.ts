@@ -65,8 +65,10 @@ export function useSession(): UseSessionReturn {
     pollIntervalRef.current = window.setInterval(async () => {
       const updatedSession = await pollSessionStatus(sessionId);
       if (updatedSession) {
-        // Stop polling if session is in a terminal state
-        if (['running', 'stopped', 'expired', 'failed'].includes(updatedSession.status)) {
+        // Stop polling if session is in a terminal state, or running on a
+        // workload that is not being replaced
+        const moving = updatedSession.status === 'running' && updatedSession.substatus === 'rescheduling';
+        if (!moving && ['running', 'stopped', 'expired', 'failed', 'hibernated'].includes(updatedSession.status)) {
           if (pollIntervalRef.current) {
             clearInterval(pollIntervalRef.current);
             pollIntervalRef.current = null;
@@ -150,6 +152,17 @@ export function useSession(): UseSessionReturn {
     }
   }, [cleanup, startPolling]);
 
+  // Re-read the current session, polling on while it moves to a new
+  // workload after an eviction
+  const refreshSession = useCallback(async (): Promise<Session | null> => {
+    if (!session) return null;
+    const updated = await pollSessionStatus(session.id);
+    if (updated?.status === 'running' && updated.substatus === 'rescheduling') {
+      startPolling(updated.id);
+    }
+    return updated;
+  }, [session, pollSessionStatus, startPolling]);
+
   // Terminate the current session
   const terminateSession = useCallback(async () => {
     if (!session) return;
@@ -205,8 +218,9 @@ export function useSession(): UseSessionReturn {
         console.log('WebSocket closed:', event.code, event.reason);
         setWsConnected(false);
 
-        // Auto-reconnect if not a normal closure
-        if (event.code !== 1000 && retriesRef.current < MAX_RETRIES) {
+        // Auto-reconnect if not a normal closure. A session locked for
+        // inactivity (4003) stays closed until it is unlocked.
+        if (event.code !== 1000 && event.code !== 4003 && retriesRef.current < MAX_RETRIES) {
           retriesRef.current++;
           console.log(`Attempting reconnect (${retriesRef.current}/${MAX_RETRIES})...`);
           setTimeout(() => connectWebSocket(), 1000 * retriesRef.current);
@@ -249,6 +263,7 @@ export function useSession(): UseSessionReturn {
     error,
     createSession,
     reconnectToSession,
+    refreshSession,
     terminateSession,
     connectWebSocket,
     disconnectWebSocket,
@@ -1,16 +1,19 @@
 import { useEffect, useRef } from 'react';
 import { getAccessToken } from '../services/auth';
+import type { SessionWelcome } from '../types';
 
 interface UseSessionEventsOptions {
   onEvent: () => void;
   onConnected: () => void;
   onDisconnected: () => void;
+  onWelcome?: (welcome: SessionWelcome) => void;
 }
 
 export function useSessionEvents({
   onEvent,
   onConnected,
   onDisconnected,
+  onWelcome,
 }: UseSessionEventsOptions) {
   const esRef = useRef<EventSource | null>(null);
   const retryTimerRef = useRef<number | null>(null);
@@ -20,6 +23,7 @@ export function useSessionEvents({
   const onEventRef = useRef(onEvent);
   const onConnectedRef = useRef(onConnected);
   const onDisconnectedRef = useRef(onDisconnected);
+  const onWelcomeRef = useRef(onWelcome);
 
   useEffect(() => {
     onEventRef.current = onEvent;
@@ -30,6 +34,9 @@ export function useSessionEvents({
   useEffect(() => {
     onDisconnectedRef.current = onDisconnected;
   }, [onDisconnected]);
+  useEffect(() => {
+    onWelcomeRef.current = onWelcome;
+  }, [onWelcome]);
 
   useEffect(() => {
     // Use a local function so the retry closure can reference it
@@ -49,6 +56,15 @@ export function useSessionEvents({
         onEventRef.current();
       });
 
+      // Welcome message for a session the user just connected to
+      es.addEventListener('session_welcome', (e: MessageEvent) => {
+        try {
+          onWelcomeRef.current?.(JSON.parse(e.data) as SessionWelcome);
+        } catch {
+          // Ignore malformed payloads
+        }
+      });
+
       es.onerror = () => {
         es.close();
         esRef.current = null;
@@ -1,5 +1,5 @@
 import { useState, useEffect, useCallback, useRef } from 'react';
-import type { Session } from '../types';
+import type { Session, SessionWelcome } from '../types';
 import { fetchWithAuth } from '../services/auth';
 import { useSessionEvents } from './useSessionEvents';
 
@@ -9,6 +9,9 @@ interface UseSessionsReturn {
   error: string | null;
   refresh: () => Promise<void>;
   terminateSession: (id: string) => Promise<boolean>;
+  resumeSession: (id: string) => Promise<boolean>;
+  welcomes: SessionWelcome[];
+  dismissWelcome: (sessionId: string) => Promise<void>;
 }
 
 const POLL_INTERVAL_SSE = 120000; // 120s safety net when SSE is connected
@@ -19,6 +22,7 @@ export function useSessions(autoRefresh = false): UseSessionsReturn {
   const [isLoading, setIsLoading] = useState(true);
   const [error, setError] = useState<string | null>(null);
   const [sseConnected, setSseConnected] = useState(false);
+  const [welcomes, setWelcomes] = useState<SessionWelcome[]>([]);
   const refreshIntervalRef = useRef<number | null>(null);
 
   const fetchSessions = useCallback(async () => {
@@ -73,11 +77,49 @@ export function useSessions(autoRefresh = false): UseSessionsReturn {
     }
   }, []);
 
+  // Resume a hibernated session; its pod is recreated with the same
+  // workspace and the session goes back to creating.
+  const resumeSession = useCallback(async (id: string): Promise<boolean> => {
+    try {
+      const response = await fetchWithAuth(`/api/sessions/${id}/resume`, {
+        method: 'POST',
+      });
+
+      if (!response.ok) {
+        throw new Error(`Failed to resume session: ${response.statusText}`);
+      }
+
+      const resumed: Session = await response.json();
+      setSessions((prev) => prev.map((s) => (s.id === id ? resumed : s)));
+      return true;
+    } catch (err) {
+      const message = err instanceof Error ? err.message : 'Failed to resume session';
+      setError(message);
+      return false;
+    }
+  }, []);
+
+  // Hide the welcome message right away; the server remembers the
+  // dismissal so it is not shown on later connects.
+  const dismissWelcome = useCallback(async (sessionId: string) => {
+    setWelcomes((prev) => prev.filter((w) => w.session_id !== sessionId));
+    try {
+      await fetchWithAuth(`/api/sessions/${sessionId}/welcome/dismiss`, {
+        method: 'POST',
+      });
+    } catch {
+      // Shown again on the next connect if the dismissal was not recorded
+    }
+  }, []);
+
   // SSE integration: re-fetch on any session lifecycle event
   useSessionEvents({
     onEvent: fetchSessions,
     onConnected: useCallback(() => setSseConnected(true), []),
     onDisconnected: useCallback(() => setSseConnected(false), []),
+    onWelcome: useCallback((welcome: SessionWelcome) => {
+      setWelcomes((prev) => [...prev.filter((w) => w.session_id !== welcome.session_id), welcome]);
+    }, []),
   });
 
   // Initial fetch
@@ -109,5 +151,8 @@ export function useSessions(autoRefresh = false): UseSessionsReturn {
     error,
     refresh,
     terminateSession,
+    resumeSession,
+    welcomes,
+    dismissWelcome,
   };
 }
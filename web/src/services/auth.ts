@@ -8,6 +8,20 @@ export interface AuthResponse {
   user: User;
 }
 
+// Returned by login in place of tokens when the user must enter a TOTP
+// code, or enroll in MFA first when setup is required
+export interface MFAChallenge {
+  mfa_required: true;
+  mfa_setup_required?: boolean;
+  mfa_token: string;
+  expires_in: number;
+}
+
+export interface MFASetup {
+  secret: string;
+  otpauth_url: string;
+}
+
 // Storage keys
 const ACCESS_TOKEN_KEY = 'sortie-access-token';
 const REFRESH_TOKEN_KEY = 'sortie-refresh-token';
@@ -46,8 +60,9 @@ export function setStoredUser(user: User): void {
   localStorage.setItem(USER_KEY, JSON.stringify(user));
 }
 
-// Login with username and password
-export async function login(username: string, password: string): Promise<AuthResponse> {
+// Login with username and password. Users with MFA get a challenge to
+// answer with verifyMFA.
+export async function login(username: string, password: string): Promise<AuthResponse | MFAChallenge> {
   const response = await fetch('/api/auth/login', {
     method: 'POST',
     headers: {
@@ -61,7 +76,10 @@ export async function login(username: string, password: string): Promise<AuthRes
     throw new Error(error || 'Login failed');
   }
 
-  const data: AuthResponse = await response.json();
+  const data: AuthResponse | MFAChallenge = await response.json();
+  if ('mfa_required' in data) {
+    return data;
+  }
 
   // Store tokens and user
   setTokens(data.access_token, data.refresh_token);
@@ -70,6 +88,229 @@ export async function login(username: string, password: string): Promise<AuthRes
   return data;
 }
 
+// Start MFA enrollment during login, for users whose tenant requires it
+export async function startMFASetup(mfaToken: string): Promise<MFASetup> {
+  const response = await fetch('/api/auth/mfa/setup', {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ mfa_token: mfaToken }),
+  });
+
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'MFA setup failed');
+  }
+
+  return response.json();
+}
+
+// Finish an MFA login with a TOTP or backup code. Completing enrollment also
+// returns the user's backup codes.
+export async function verifyMFA(
+  mfaToken: string,
+  code: string
+): Promise<AuthResponse & { backup_codes?: string[] }> {
+  const response = await fetch('/api/auth/mfa/verify', {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ mfa_token: mfaToken, code }),
+  });
+
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Verification failed');
+  }
+
+  const data: AuthResponse & { backup_codes?: string[] } = await response.json();
+
+  setTokens(data.access_token, data.refresh_token);
+  setStoredUser(data.user);
+
+  return data;
+}
+
+// Registered passkey (WebAuthn credential)
+export interface Passkey {
+  id: string;
+  name: string;
+  created_at: string;
+  last_used_at?: string;
+}
+
+function fromBase64URL(value: string): ArrayBuffer {
+  const base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+  const binary = atob(base64.padEnd(Math.ceil(base64.length / 4) * 4, '='));
+  const bytes = new Uint8Array(binary.length);
+  for (let i = 0; i < binary.length; i++) {
+    bytes[i] = binary.charCodeAt(i);
+  }
+  return bytes.buffer;
+}
+
+function toBase64URL(buffer: ArrayBuffer | null): string | undefined {
+  if (!buffer) return undefined;
+  let binary = '';
+  for (const byte of new Uint8Array(buffer)) {
+    binary += String.fromCharCode(byte);
+  }
+  return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+}
+
+// Encode a PublicKeyCredential for the server
+function encodePasskeyCredential(credential: PublicKeyCredential) {
+  const response = credential.response as AuthenticatorAttestationResponse & AuthenticatorAssertionResponse;
+  return {
+    id: credential.id,
+    type: credential.type,
+    response: {
+      clientDataJSON: toBase64URL(response.clientDataJSON),
+      attestationObject: 'attestationObject' in response ? toBase64URL(response.attestationObject) : undefined,
+      authenticatorData: 'authenticatorData' in response ? toBase64URL(response.authenticatorData) : undefined,
+      signature: 'signature' in response ? toBase64URL(response.signature) : undefined,
+      userHandle: 'userHandle' in response ? toBase64URL(response.userHandle) : undefined,
+    },
+  };
+}
+
+// Whether the browser supports passkeys
+export function passkeysSupported(): boolean {
+  return typeof window !== 'undefined' && 'PublicKeyCredential' in window;
+}
+
+// Login with a passkey. The browser offers the user's passkeys for this site.
+export async function loginWithPasskey(): Promise<AuthResponse> {
+  const optionsRes = await fetch('/api/auth/passkeys/login/options', { method: 'POST' });
+  if (!optionsRes.ok) {
+    const error = await optionsRes.text();
+    throw new Error(error || 'Passkey login failed');
+  }
+  const options = await optionsRes.json();
+
+  const credential = (await navigator.credentials.get({
+    publicKey: {
+      challenge: fromBase64URL(options.challenge),
+      rpId: options.rpId,
+      timeout: options.timeout,
+      userVerification: options.userVerification,
+    },
+  })) as PublicKeyCredential | null;
+  if (!credential) {
+    throw new Error('Passkey login was cancelled');
+  }
+
+  const response = await fetch('/api/auth/passkeys/login', {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ credential: encodePasskeyCredential(credential) }),
+  });
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Passkey login failed');
+  }
+
+  const data: AuthResponse = await response.json();
+  setTokens(data.access_token, data.refresh_token);
+  setStoredUser(data.user);
+
+  return data;
+}
+
+// Register a new passkey for the current user
+export async function registerPasskey(name: string): Promise<Passkey> {
+  const optionsRes = await fetchWithAuth('/api/auth/passkeys/register/options', { method: 'POST' });
+  if (!optionsRes.ok) {
+    const error = await optionsRes.text();
+    throw new Error(error || 'Failed to add passkey');
+  }
+  const options = await optionsRes.json();
+
+  const credential = (await navigator.credentials.create({
+    publicKey: {
+      ...options,
+      challenge: fromBase64URL(options.challenge),
+      user: { ...options.user, id: fromBase64URL(options.user.id) },
+      excludeCredentials: (options.excludeCredentials || []).map((c: { type: 'public-key'; id: string }) => ({
+        type: c.type,
+        id: fromBase64URL(c.id),
+      })),
+    },
+  })) as PublicKeyCredential | null;
+  if (!credential) {
+    throw new Error('Passkey registration was cancelled');
+  }
+
+  const response = await fetchWithAuth('/api/auth/passkeys/register', {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ name, credential: encodePasskeyCredential(credential) }),
+  });
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to add passkey');
+  }
+  return response.json();
+}
+
+// List the current user's passkeys
+export async function listPasskeys(): Promise<Passkey[]> {
+  const response = await fetchWithAuth('/api/auth/passkeys');
+  if (!response.ok) {
+    throw new Error('Failed to list passkeys');
+  }
+  return response.json();
+}
+
+// Remove one of the current user's passkeys
+export async function deletePasskey(id: string): Promise<void> {
+  const response = await fetchWithAuth(`/api/auth/passkeys/${id}`, {
+    method: 'DELETE',
+  });
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to remove passkey');
+  }
+}
+
+// Ask for a password reset link by email. The server answers the same
+// whether or not an account matched.
+export async function forgotPassword(usernameOrEmail: string): Promise<void> {
+  const body = usernameOrEmail.includes('@') ? { email: usernameOrEmail } : { username: usernameOrEmail };
+  const response = await fetch('/api/auth/forgot-password', {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify(body),
+  });
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to request a password reset');
+  }
+}
+
+// Set a new password with the token from a reset email
+export async function resetPassword(token: string, password: string): Promise<void> {
+  const response = await fetch('/api/auth/reset-password', {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ token, password }),
+  });
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to reset password');
+  }
+}
+
 // Logout - clear tokens
 export async function logout(): Promise<void> {
   const token = getAccessToken();
@@ -91,43 +332,79 @@ export async function logout(): Promise<void> {
   clearTokens();
 }
 
-// Refresh access token using refresh token
-export async function refreshAccessToken(): Promise<AuthResponse | null> {
-  const refreshToken = getRefreshToken();
+// Delays between refresh attempts while the server is unreachable, long
+// enough to ride out a restart during a deploy
+const REFRESH_RETRY_DELAYS_MS = [500, 1000, 2000, 4000, 8000];
 
-  if (!refreshToken) {
-    return null;
-  }
+// The refresh in progress, shared by every caller that hits a 401 meanwhile
+let refreshInFlight: Promise<AuthResponse | null> | null = null;
 
-  try {
-    const response = await fetch('/api/auth/refresh', {
-      method: 'POST',
-      headers: {
-        'Content-Type': 'application/json',
-      },
-      body: JSON.stringify({ refresh_token: refreshToken }),
+// Refresh access token using refresh token. Concurrent callers share one
+// request, so a rotated refresh token is only presented once.
+export function refreshAccessToken(): Promise<AuthResponse | null> {
+  if (!refreshInFlight) {
+    refreshInFlight = requestTokenRefresh().finally(() => {
+      refreshInFlight = null;
     });
+  }
+  return refreshInFlight;
+}
 
-    if (!response.ok) {
-      // Refresh token is invalid - clear everything
-      clearTokens();
+// The server rejected the refresh token itself, as opposed to being
+// unreachable or restarting
+function refreshRejected(status: number): boolean {
+  return status === 400 || status === 401 || status === 403;
+}
+
+async function requestTokenRefresh(): Promise<AuthResponse | null> {
+  for (let attempt = 0; ; attempt++) {
+    // Re-read each attempt; another tab may have rotated the token
+    const refreshToken = getRefreshToken();
+
+    if (!refreshToken) {
       return null;
     }
 
-    const data: AuthResponse = await response.json();
+    let response: Response | null = null;
+    try {
+      response = await fetch('/api/auth/refresh', {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({ refresh_token: refreshToken }),
+      });
+    } catch {
+      // Network error - the server may be restarting
+    }
+
+    if (response?.ok) {
+      const data: AuthResponse = await response.json();
 
-    // Store new tokens
-    setTokens(data.access_token, data.refresh_token);
-    setStoredUser(data.user);
+      // Store new tokens
+      setTokens(data.access_token, data.refresh_token);
+      setStoredUser(data.user);
 
-    return data;
-  } catch {
-    clearTokens();
-    return null;
+      return data;
+    }
+
+    if (response && refreshRejected(response.status)) {
+      // Refresh token is invalid - clear everything
+      clearTokens();
+      return null;
+    }
+
+    // Keep the tokens and retry; give up for now once retries run out
+    if (attempt >= REFRESH_RETRY_DELAYS_MS.length) {
+      return null;
+    }
+    await new Promise((resolve) => setTimeout(resolve, REFRESH_RETRY_DELAYS_MS[attempt]));
   }
 }
 
-// Get current user from server (validates token)
+// Get current user from server (validates token). While the server is
+// unreachable the stored user is returned so a restart does not sign the
+// user out.
 export async function getCurrentUser(): Promise<User | null> {
   const token = getAccessToken();
 
@@ -149,6 +426,11 @@ export async function getCurrentUser(): Promise<User | null> {
         if (refreshed) {
           return refreshed.user;
         }
+        // A rejected refresh has already cleared the tokens
+        return getRefreshToken() ? getStoredUser() : null;
+      }
+      if (response.status >= 500) {
+        return getStoredUser();
       }
       clearTokens();
       return null;
@@ -158,7 +440,7 @@ export async function getCurrentUser(): Promise<User | null> {
     setStoredUser(user);
     return user;
   } catch {
-    return null;
+    return getStoredUser();
   }
 }
 
@@ -244,6 +526,37 @@ export async function register(
   return data;
 }
 
+// Signed-in device (one per refresh token)
+export interface Device {
+  id: string;
+  user_agent: string;
+  ip_address: string;
+  issued_at: string;
+  last_used_at?: string;
+  expires_at: string;
+  current: boolean;
+}
+
+// List the current user's signed-in devices
+export async function listDevices(): Promise<Device[]> {
+  const response = await fetchWithAuth('/api/auth/devices');
+  if (!response.ok) {
+    throw new Error('Failed to list devices');
+  }
+  return response.json();
+}
+
+// Revoke one of the current user's devices
+export async function revokeDevice(id: string): Promise<void> {
+  const response = await fetchWithAuth(`/api/auth/devices/${id}`, {
+    method: 'DELETE',
+  });
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to revoke device');
+  }
+}
+
 // List basic user info (non-admin endpoint, for category admin dropdowns)
 export async function listUsersBasic(): Promise<{ id: string; username: string }[]> {
   const response = await fetchWithAuth('/api/users');
@@ -282,10 +595,29 @@ export async function getAdminSettings(): Promise<Record<string, unknown>> {
   return response.json();
 }
 
-// Admin: Update settings
+// A staged change to settings awaiting a second admin's approval
+export interface SettingChangeRequest {
+  id: string;
+  changes: Record<string, string>;
+  tenant?: {
+    tenant_id: string;
+    quotas: Record<string, number | string>;
+    egress_policy?: { mode?: string; rules?: { cidr: string; port?: number; protocol?: string }[] };
+  };
+  reason?: string;
+  status: 'pending' | 'approved' | 'rejected' | 'cancelled';
+  requested_by: string;
+  reviewed_by?: string;
+  review_comment?: string;
+  created_at: string;
+  reviewed_at?: string;
+}
+
+// Admin: Update settings. Returns the change request when settings approval
+// staged some of the changes instead of applying them.
 export async function updateAdminSettings(
   settings: Record<string, string>
-): Promise<void> {
+): Promise<SettingChangeRequest | null> {
   const response = await fetchWithAuth('/api/admin/settings', {
     method: 'PUT',
     headers: { 'Content-Type': 'application/json' },
@@ -294,6 +626,10 @@ export async function updateAdminSettings(
   if (!response.ok) {
     throw new Error('Failed to update settings');
   }
+  if (response.status === 202) {
+    return response.json();
+  }
+  return null;
 }
 
 // Admin user type
@@ -303,9 +639,47 @@ export interface AdminUser {
   email?: string;
   display_name?: string;
   roles: string[];
+  tenant_id?: string;
+  disabled?: boolean;
+  last_login_at?: string;
   created_at: string;
 }
 
+// Admin: Paginated user list
+export interface AdminUserPage {
+  users: AdminUser[];
+  total: number;
+}
+
+// Admin: User list query parameters
+export interface AdminUserQuery {
+  q?: string;
+  role?: string;
+  tenant?: string;
+  sort?: string; // prefix with "-" for descending
+  limit?: number;
+  offset?: number;
+}
+
+// Admin: Search and page through users
+export async function queryUsers(query: AdminUserQuery = {}): Promise<AdminUserPage> {
+  const params = new URLSearchParams();
+  for (const [key, value] of Object.entries(query)) {
+    if (value !== undefined && value !== '') {
+      params.set(key, String(value));
+    }
+  }
+  // Without limit or offset the server returns a plain array, not a page
+  if (!params.has('limit') && !params.has('offset')) {
+    params.set('offset', '0');
+  }
+  const response = await fetchWithAuth(`/api/admin/users?${params.toString()}`);
+  if (!response.ok) {
+    throw new Error('Failed to list users');
+  }
+  return response.json();
+}
+
 // Admin: List users
 export async function listUsers(): Promise<AdminUser[]> {
   const response = await fetchWithAuth('/api/admin/users');
@@ -335,6 +709,17 @@ export async function createUser(user: {
   return response.json();
 }
 
+// Admin: Re-enable a user disabled by the inactive-account policy
+export async function enableUser(id: string): Promise<void> {
+  const response = await fetchWithAuth(`/api/admin/users/${id}/enable`, {
+    method: 'POST',
+  });
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to enable user');
+  }
+}
+
 // Admin: Delete user
 export async function deleteUser(id: string): Promise<void> {
   const response = await fetchWithAuth(`/api/admin/users/${id}`, {
@@ -441,6 +826,53 @@ export async function deleteTemplate(templateId: string): Promise<void> {
   }
 }
 
+export interface TemplateImportItem {
+  index: number;
+  template_id?: string;
+  action?: 'create' | 'update';
+  error?: string;
+}
+
+export interface TemplateImportResult {
+  dry_run: boolean;
+  created: string[];
+  updated: string[];
+  icons: number;
+  results: TemplateImportItem[];
+}
+
+// Admin: Import a template bundle, a JSON or YAML catalog or a zip of one
+// with its icons. A dry run only reports what the import would do.
+export async function importTemplates(file: File, dryRun = false): Promise<TemplateImportResult> {
+  const response = await fetchWithAuth(`/api/admin/templates/import${dryRun ? '?dry_run=true' : ''}`, {
+    method: 'POST',
+    headers: { 'Content-Type': file.type || 'application/octet-stream' },
+    body: file,
+  });
+  if (!response.ok) {
+    // Invalid templates are reported one by one
+    if (response.headers.get('Content-Type')?.includes('application/json')) {
+      const result: TemplateImportResult = await response.json();
+      const errors = result.results
+        .filter((r) => r.error)
+        .map((r) => `${r.template_id || `Template ${r.index}`}: ${r.error}`);
+      throw new Error(`Import rejected. ${errors.join('; ')}`);
+    }
+    const error = await response.text();
+    throw new Error(error || 'Failed to import templates');
+  }
+  return response.json();
+}
+
+// Admin: Export all templates as a catalog that imports back
+export async function exportTemplates(format: 'yaml' | 'json'): Promise<Blob> {
+  const response = await fetchWithAuth(`/api/admin/templates/export?format=${format}`);
+  if (!response.ok) {
+    throw new Error('Failed to export templates');
+  }
+  return response.blob();
+}
+
 // App catalog: List apps
 export async function listApps(): Promise<Application[]> {
   const response = await fetchWithAuth('/api/apps');
@@ -498,6 +930,53 @@ export async function deleteApp(id: string): Promise<void> {
   }
 }
 
+// Icons: Upload an app or template icon, returning the URL to store
+export async function uploadIcon(file: File): Promise<string> {
+  const formData = new FormData();
+  formData.append('file', file);
+  const response = await fetchWithAuth('/api/assets/icons', {
+    method: 'POST',
+    body: formData,
+  });
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to upload icon');
+  }
+  const data: { url: string } = await response.json();
+  return data.url;
+}
+
+// Lint: A best-practice rule that a draft app or template breaks
+export interface LintWarning {
+  rule: string;
+  severity: 'warning' | 'info';
+  field?: string;
+  message: string;
+}
+
+async function lintDraft(kind: 'app' | 'template', draft: object): Promise<LintWarning[]> {
+  const response = await fetchWithAuth(`/api/lint/${kind}`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(draft),
+  });
+  if (!response.ok) {
+    throw new Error(`Failed to lint ${kind}`);
+  }
+  const data: { warnings: LintWarning[] } = await response.json();
+  return data.warnings;
+}
+
+// Lint: Check a draft app against the lint rules
+export async function lintApp(app: Application): Promise<LintWarning[]> {
+  return lintDraft('app', app);
+}
+
+// Lint: Check a draft template against the lint rules
+export async function lintTemplate(template: Partial<AdminTemplate>): Promise<LintWarning[]> {
+  return lintDraft('template', template);
+}
+
 // --- Category API ---
 
 export async function listCategories(): Promise<Category[]> {
@@ -624,8 +1103,23 @@ export async function listAdminRecordings(): Promise<Recording[]> {
   return response.json();
 }
 
-// Download a recording — returns a blob URL
+// Get a time-limited link to fetch a recording straight from object
+// storage, or null when recordings must be fetched through Sortie
+export async function getRecordingLink(id: string): Promise<string | null> {
+  const response = await fetchWithAuth(`/api/recordings/${id}/link`);
+  if (!response.ok) {
+    return null;
+  }
+  const data: { url: string } = await response.json();
+  return data.url;
+}
+
+// Download a recording — returns a direct link or a blob URL
 export async function downloadRecording(id: string): Promise<string> {
+  const link = await getRecordingLink(id);
+  if (link) {
+    return link;
+  }
   const response = await fetchWithAuth(`/api/recordings/${id}/download`);
   if (!response.ok) {
     throw new Error('Failed to download recording');
@@ -634,6 +1128,30 @@ export async function downloadRecording(id: string): Promise<string> {
   return URL.createObjectURL(blob);
 }
 
+// Fetch a recording's contents, from object storage when it hands out
+// direct links
+export async function fetchRecording(id: string): Promise<ArrayBuffer> {
+  const link = await getRecordingLink(id);
+  const response = link
+    ? await fetch(link)
+    : await fetchWithAuth(`/api/recordings/${id}/download`);
+  if (!response.ok) {
+    throw new Error('Failed to download recording');
+  }
+  return response.arrayBuffer();
+}
+
+// Get a short-lived signed URL for playing a recording's video, which a
+// video element can load and seek without an Authorization header
+export async function getRecordingStreamURL(id: string): Promise<string> {
+  const response = await fetchWithAuth(`/api/recordings/${id}/stream-link`);
+  if (!response.ok) {
+    throw new Error('Failed to load recording video');
+  }
+  const data: { url: string } = await response.json();
+  return data.url;
+}
+
 // Delete a recording
 export async function deleteRecording(id: string): Promise<void> {
   const response = await fetchWithAuth(`/api/recordings/${id}`, {
@@ -8,6 +8,8 @@ export type OsType = 'linux' | 'windows';
 //   'bidirectional' - full two-way clipboard sync
 export type ClipboardPolicy = 'none' | 'read' | 'write' | 'bidirectional';
 
+export type FileTransferPolicy = 'both' | 'upload_only' | 'download_only' | 'none';
+
 // ResourceLimits defines CPU and memory constraints for container applications
 export interface ResourceLimits {
   cpu_request?: string;    // CPU request (e.g., "100m", "0.5")
@@ -30,6 +32,51 @@ export interface Application {
   container_port?: number;  // Port web app listens on (default: 8080 for web_proxy)
   container_args?: string[]; // Extra arguments to pass to the container
   resource_limits?: ResourceLimits; // Resource limits for container apps
+  audit_session_activity?: boolean; // Log in-session actions to the audit log
+  welcome_message?: string; // Markdown shown when a session first connects
+  hibernate_on_idle?: boolean; // Keep idle sessions' workspace for resuming instead of ending them
+  clipboard_policy?: ClipboardPolicy; // Enforced by the server; unset allows both ways
+  file_transfer?: FileTransferPolicy; // Enforced by the server; unset allows both ways
+  image_pull_secret?: string; // Secret the app's images are pulled with; unset uses the tenant's
+  max_concurrent_sessions?: number; // Limit on the app's active sessions across all users; unset for none
+  active_sessions?: number; // The app's active sessions, reported for apps with max_concurrent_sessions
+  launch_confirmation?: LaunchConfirmation; // Shown with the launch preview; may require confirming it
+}
+
+export interface LaunchConfirmation {
+  required?: boolean; // Launches must pass the preview's confirm_token
+  message?: string; // Markdown shown with the preview
+  prerequisites?: string[];
+}
+
+// GET /api/apps/:id/launch-preview
+export interface LaunchPreview {
+  app_id: string;
+  resource_limits: ResourceLimits;
+  cpu_cores: number;
+  memory_gib: number;
+  quota: { // The caller's session usage before the launch; 0 limits are unlimited
+    user_sessions: number;
+    max_sessions_per_user: number;
+    global_sessions: number;
+    max_global_sessions: number;
+    tenant_sessions?: number;
+    max_tenant_sessions?: number;
+  };
+  blocked?: string; // Why a launch would be refused now
+  limit?: string; // The session limit blocked or queued is about
+  queued?: boolean; // The launch would wait for capacity
+  cost: {
+    currency: string;
+    cpu_per_hour: number;
+    memory_per_hour: number;
+    per_hour: number;
+    typical_hours?: number; // Average run over the last 30 days
+    estimated?: number;
+  };
+  confirmation?: LaunchConfirmation;
+  confirm_token?: string;
+  confirm_expires_at?: string;
 }
 
 export interface AppConfig {
@@ -42,7 +89,9 @@ export interface AppConfig {
 //   running  -> stopped (user terminated)
 //   running  -> expired (timeout cleanup)
 //   running  -> failed  (runtime error)
-export type SessionStatus = 'creating' | 'running' | 'failed' | 'stopped' | 'expired';
+//   running  -> hibernated (idle timeout, for apps that hibernate on idle)
+//   hibernated -> creating (user resumed)
+export type SessionStatus = 'creating' | 'running' | 'failed' | 'stopped' | 'expired' | 'hibernated';
 
 export interface Session {
   id: string;
@@ -51,6 +100,10 @@ export interface Session {
   app_name?: string;
   pod_name: string;
   status: SessionStatus;
+  substatus?: string;        // Launch stage while creating, 'degraded' once containers restart, or 'rescheduling' after an eviction
+  substatus_detail?: string;
+  restart_count?: number;    // Container restarts of the current workload
+  reschedule_count?: number; // Times the session moved to a new workload after an eviction
   websocket_url?: string;    // For Linux container apps (VNC)
   guacamole_url?: string;    // For Windows container apps (RDP via Guacamole)
   proxy_url?: string;        // For web_proxy apps
@@ -59,10 +112,23 @@ export interface Session {
   share_permission?: 'read_only' | 'read_write';
   share_id?: string;
   recording_policy?: string;
+  clipboard_policy?: ClipboardPolicy; // The app's clipboard policy; unset allows both ways
+  file_transfer?: FileTransferPolicy; // The app's file transfer policy; unset allows both ways
+  project_id?: string;       // Project the session was launched in
+  locked_at?: string;        // Set while the stream is locked for inactivity
   created_at: string;
   updated_at: string;
 }
 
+// SessionWelcome is pushed over the session event stream when the owner
+// connects to a session whose app has an undismissed welcome message.
+export interface SessionWelcome {
+  session_id: string;
+  app_id: string;
+  app_name: string;
+  message: string; // markdown
+}
+
 export interface SessionShare {
   id: string;
   session_id: string;
@@ -78,6 +144,7 @@ export interface CreateSessionRequest {
   user_id?: string;
   screen_width?: number;
   screen_height?: number;
+  confirm_token?: string; // From the launch preview, for apps that require confirmation
 }
 
 export interface User {
@@ -161,6 +228,7 @@ export interface AuditLogEntry {
   user: string;
   action: string;
   details: string;
+  request_id?: string;
 }
 
 export interface AuditLogPage {
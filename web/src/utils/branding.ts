@@ -0,0 +1,46 @@
+export interface BrandingTheme {
+  colors?: Record<string, string>;
+  fonts?: Record<string, string>;
+}
+
+export interface BrandingConfig {
+  favicon_url?: string;
+  background_url?: string;
+  theme?: BrandingTheme;
+}
+
+/**
+ * Applies tenant branding from /api/config to the document.
+ * Theme colors override the matching --color-brand-* variables, the "body"
+ * font replaces the page font, and the favicon link points at the upload.
+ * Theme values are validated by the server before they are stored.
+ * @param config - The branding fields of the /api/config response
+ */
+export function applyBranding(config: BrandingConfig): void {
+  const root = document.documentElement;
+
+  for (const [name, value] of Object.entries(config.theme?.colors ?? {})) {
+    root.style.setProperty(`--color-brand-${name}`, value);
+  }
+  for (const [name, value] of Object.entries(config.theme?.fonts ?? {})) {
+    root.style.setProperty(`--font-${name}`, value);
+  }
+  if (config.theme?.fonts?.body) {
+    document.body.style.fontFamily = config.theme.fonts.body;
+  }
+
+  if (config.favicon_url) {
+    let link = document.querySelector<HTMLLinkElement>('link[rel="icon"]');
+    if (!link) {
+      link = document.createElement('link');
+      link.rel = 'icon';
+      document.head.appendChild(link);
+    }
+    link.removeAttribute('type');
+    link.href = config.favicon_url;
+  }
+
+  if (config.background_url) {
+    root.style.setProperty('--branding-background', `url("${config.background_url}")`);
+  }
+}
@@ -0,0 +1,94 @@
+import type { ReactNode } from 'react';
+
+// A small markdown renderer for admin-authored messages. It supports
+// headings, paragraphs, bullet and numbered lists, **bold**, *italic*,
+// `code`, and http(s)/mailto links. Output is built from React elements,
+// so raw HTML in the source is shown as text, never rendered.
+
+const INLINE = /(\*\*[^*]+\*\*|\*[^*]+\*|_[^_]+_|`[^`]+`|\[[^\]]+\]\([^)\s]+\))/g;
+
+function renderInline(text: string, keyPrefix: string): ReactNode[] {
+  return text.split(INLINE).filter(Boolean).map((part, i) => {
+    const key = `${keyPrefix}-${i}`;
+    if (part.startsWith('**') && part.endsWith('**') && part.length > 4) {
+      return <strong key={key}>{part.slice(2, -2)}</strong>;
+    }
+    if ((part.startsWith('*') && part.endsWith('*')) || (part.startsWith('_') && part.endsWith('_'))) {
+      if (part.length > 2) return <em key={key}>{part.slice(1, -1)}</em>;
+    }
+    if (part.startsWith('`') && part.endsWith('`') && part.length > 2) {
+      return <code key={key} className="px-1 rounded bg-black/10 font-mono text-sm">{part.slice(1, -1)}</code>;
+    }
+    const link = part.match(/^\[([^\]]+)\]\(([^)\s]+)\)$/);
+    if (link) {
+      if (/^(https?:|mailto:)/i.test(link[2])) {
+        return (
+          <a key={key} href={link[2]} target="_blank" rel="noopener noreferrer" className="text-blue-500 underline">
+            {link[1]}
+          </a>
+        );
+      }
+      return link[1];
+    }
+    return part;
+  });
+}
+
+export function renderMarkdown(source: string): ReactNode[] {
+  const blocks: ReactNode[] = [];
+  const lines = source.replace(/\r\n/g, '\n').split('\n');
+  let paragraph: string[] = [];
+  let list: { ordered: boolean; items: string[] } | null = null;
+
+  const flushParagraph = () => {
+    if (paragraph.length > 0) {
+      const key = `p-${blocks.length}`;
+      blocks.push(<p key={key}>{renderInline(paragraph.join(' '), key)}</p>);
+      paragraph = [];
+    }
+  };
+  const flushList = () => {
+    if (list) {
+      const key = `l-${blocks.length}`;
+      const items = list.items.map((item, i) => <li key={i}>{renderInline(item, `${key}-${i}`)}</li>);
+      blocks.push(list.ordered
+        ? <ol key={key} className="list-decimal pl-6 space-y-1">{items}</ol>
+        : <ul key={key} className="list-disc pl-6 space-y-1">{items}</ul>);
+      list = null;
+    }
+  };
+
+  for (const line of lines) {
+    const trimmed = line.trim();
+    const heading = trimmed.match(/^(#{1,3})\s+(.*)$/);
+    const bullet = trimmed.match(/^[-*]\s+(.*)$/);
+    const numbered = trimmed.match(/^\d+[.)]\s+(.*)$/);
+
+    if (trimmed === '') {
+      flushParagraph();
+      flushList();
+    } else if (heading) {
+      flushParagraph();
+      flushList();
+      const key = `h-${blocks.length}`;
+      const sizes = ['text-xl', 'text-lg', 'text-base'];
+      blocks.push(
+        <p key={key} className={`${sizes[heading[1].length - 1]} font-semibold`}>
+          {renderInline(heading[2], key)}
+        </p>
+      );
+    } else if (bullet || numbered) {
+      flushParagraph();
+      const ordered = !!numbered;
+      if (list && list.ordered !== ordered) flushList();
+      if (!list) list = { ordered, items: [] };
+      list.items.push((bullet || numbered)![1]);
+    } else {
+      flushList();
+      paragraph.push(trimmed);
+    }
+  }
+  flushParagraph();
+  flushList();
+  return blocks;
+}